@@ -0,0 +1,145 @@
+// Command proofline-loadgen replays agent workloads (create, claim, attest, complete)
+// against the engine in-process or against the HTTP API and reports throughput and
+// latency percentiles. Thresholds turn it into a regression gate for CI.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"workline/internal/loadgen"
+	"workline/pkg/proofline"
+	worklinesdk "workline/sdk/go"
+)
+
+func main() {
+	if err := rootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func rootCmd() *cobra.Command {
+	var target, url, projectID, workspace, apiKey, token string
+	var agents, tasks int
+	var asJSON bool
+	var maxP99 time.Duration
+	var minThroughput float64
+	cmd := &cobra.Command{
+		Use:   "proofline-loadgen",
+		Short: "Load test Workline with agent workloads",
+		Long: "Each agent repeatedly creates a bug task, claims it, attests ci.passed and review.approved and completes it.\n" +
+			"--target engine drives the engine in-process. --target http goes through the API: against --url when set, otherwise against a server started in-process on loopback.\n" +
+			"Without --workspace a temporary workspace is used and removed afterwards.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			var t loadgen.Target
+			if target == "http" && url != "" {
+				if apiKey == "" && token == "" {
+					return fmt.Errorf("--url requires --api-key or --token (or WORKLINE_API_KEY / WORKLINE_TOKEN)")
+				}
+				clients := make([]*worklinesdk.Client, agents)
+				for i := range clients {
+					c := worklinesdk.New(url, projectID)
+					c.APIKey, c.BearerToken = apiKey, token
+					c.HTTPClient = &http.Client{Timeout: c.Timeout}
+					clients[i] = c
+				}
+				t = loadgen.HTTP{Clients: clients}
+			} else {
+				if target != "engine" && target != "http" {
+					return fmt.Errorf("invalid --target %q: expected engine or http", target)
+				}
+				if workspace == "" {
+					dir, err := os.MkdirTemp("", "proofline-loadgen-")
+					if err != nil {
+						return err
+					}
+					defer os.RemoveAll(dir)
+					workspace = dir
+				}
+				l, err := proofline.Open(ctx, proofline.Options{Workspace: workspace, ProjectID: projectID, ActorID: "loadgen"})
+				if err != nil {
+					return err
+				}
+				defer l.Close()
+				t = loadgen.Engine{Local: l}
+				if target == "http" {
+					srv, err := loadgen.Serve(ctx, l)
+					if err != nil {
+						return err
+					}
+					defer srv.Close()
+					t = loadgen.HTTP{Clients: []*worklinesdk.Client{srv.Client(projectID)}}
+				}
+			}
+			rep, err := loadgen.Run(ctx, t, loadgen.Options{Agents: agents, Tasks: tasks})
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(rep); err != nil {
+					return err
+				}
+			} else {
+				printReport(rep)
+			}
+			return checkThresholds(rep, maxP99, minThroughput)
+		},
+	}
+	cmd.Flags().StringVar(&target, "target", "engine", "engine (in-process) or http")
+	cmd.Flags().StringVar(&url, "url", "", "base URL of a running server for --target http; empty starts one in-process")
+	cmd.Flags().StringVar(&projectID, "project", "loadgen", "project id; created in local workspaces when missing")
+	cmd.Flags().StringVar(&workspace, "workspace", "", "workspace for local runs (default: a temporary directory)")
+	cmd.Flags().StringVar(&apiKey, "api-key", os.Getenv("WORKLINE_API_KEY"), "API key for --url")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("WORKLINE_TOKEN"), "bearer token for --url")
+	cmd.Flags().IntVar(&agents, "agents", 4, "concurrent agents")
+	cmd.Flags().IntVar(&tasks, "tasks", 25, "workflows per agent")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+	cmd.Flags().DurationVar(&maxP99, "max-p99", 0, "fail when any operation's p99 latency exceeds this (0 disables)")
+	cmd.Flags().Float64Var(&minThroughput, "min-throughput", 0, "fail below this many completed tasks per second (0 disables)")
+	return cmd
+}
+
+func printReport(rep loadgen.Report) {
+	fmt.Printf("target %s: %d agents x %d tasks, %d completed, %d failed in %.0fms\n", rep.Target, rep.Agents, rep.Tasks, rep.Completed, rep.Failed, rep.DurationMS)
+	fmt.Printf("throughput: %.1f tasks/s, %.1f ops/s\n\n", rep.TasksPerSecond, rep.OpsPerSecond)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "op\tcount\terrors\tmean ms\tp50 ms\tp95 ms\tp99 ms\tmax ms\t")
+	for _, op := range rep.Ops {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n", op.Op, op.Count, op.Errors, op.MeanMS, op.P50MS, op.P95MS, op.P99MS, op.MaxMS)
+	}
+	w.Flush()
+	if rep.FirstError != "" {
+		fmt.Printf("\nfirst error: %s\n", rep.FirstError)
+	}
+}
+
+// checkThresholds fails the run on errors or when a limit is crossed.
+func checkThresholds(rep loadgen.Report, maxP99 time.Duration, minThroughput float64) error {
+	if rep.Failed > 0 {
+		return fmt.Errorf("%d of %d workflows failed: %s", rep.Failed, rep.Failed+rep.Completed, rep.FirstError)
+	}
+	if maxP99 > 0 {
+		limit := float64(maxP99.Microseconds()) / 1000
+		for _, op := range rep.Ops {
+			if op.P99MS > limit {
+				return fmt.Errorf("%s p99 %.2fms exceeds --max-p99 %s", op.Op, op.P99MS, maxP99)
+			}
+		}
+	}
+	if minThroughput > 0 && rep.TasksPerSecond < minThroughput {
+		return fmt.Errorf("throughput %.1f tasks/s is below --min-throughput %.1f", rep.TasksPerSecond, minThroughput)
+	}
+	return nil
+}
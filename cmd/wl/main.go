@@ -3,12 +3,20 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,13 +25,20 @@ import (
 	"github.com/spf13/viper"
 
 	"workline/internal/app"
+	"workline/internal/backup"
+	"workline/internal/blob"
 	"workline/internal/config"
 	"workline/internal/db"
 	"workline/internal/domain"
 	"workline/internal/engine"
+	"workline/internal/evidence"
+	"workline/internal/manifest"
 	"workline/internal/migrate"
+	"workline/internal/notify"
 	"workline/internal/repo"
+	"workline/internal/seed"
 	"workline/internal/server"
+	"workline/internal/telemetry"
 )
 
 var rootCmd = &cobra.Command{
@@ -82,15 +97,27 @@ func addPersistentFlags() {
 
 func registerCommands() {
 	rootCmd.AddCommand(projectCmd())
+	rootCmd.AddCommand(orgCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(statsCmd())
+	rootCmd.AddCommand(digestCmd())
+	rootCmd.AddCommand(escalationCmd())
+	rootCmd.AddCommand(inboxCmd())
+	rootCmd.AddCommand(secretCmd())
 	rootCmd.AddCommand(taskCmd())
 	rootCmd.AddCommand(iterationCmd())
+	rootCmd.AddCommand(viewCmd())
+	rootCmd.AddCommand(capabilitiesCmd())
 	rootCmd.AddCommand(decisionCmd())
 	rootCmd.AddCommand(attestCmd())
+	rootCmd.AddCommand(evidenceCmd())
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(artifactCmd())
 	rootCmd.AddCommand(logCmd())
 	rootCmd.AddCommand(serveCmd())
 	rootCmd.AddCommand(rbacCmd())
+	rootCmd.AddCommand(dbCmd())
 }
 
 func projectCmd() *cobra.Command {
@@ -102,6 +129,11 @@ func projectCmd() *cobra.Command {
 	prj.AddCommand(projectDeleteCmd())
 	prj.AddCommand(projectConfigCmd())
 	prj.AddCommand(projectUseCmd())
+	prj.AddCommand(projectSeedCmd())
+	prj.AddCommand(projectSyncCmd())
+	prj.AddCommand(projectVerifyCmd())
+	prj.AddCommand(projectSummaryCmd())
+	prj.AddCommand(projectFeaturesCmd())
 	return prj
 }
 
@@ -123,7 +155,7 @@ func projectListCmd() *cobra.Command {
 }
 
 func projectCreateCmd() *cobra.Command {
-	var id, desc string
+	var id, desc, seedPath, parent, org string
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create project",
@@ -131,6 +163,13 @@ func projectCreateCmd() *cobra.Command {
 			if id == "" {
 				return fmt.Errorf("--id required")
 			}
+			var sd seed.File
+			if seedPath != "" {
+				var err error
+				if sd, err = seed.FromFile(seedPath); err != nil {
+					return err
+				}
+			}
 			workspace := viper.GetString("workspace")
 			if _, err := db.EnsureWorkspace(workspace); err != nil {
 				return err
@@ -145,22 +184,219 @@ func projectCreateCmd() *cobra.Command {
 			}
 			cfg := config.Default(id)
 			e := engine.New(conn, cfg)
-			p, err := e.InitProject(cmd.Context(), id, desc, viper.GetString("actor-id"))
+			p, err := e.InitProjectWithOptions(cmd.Context(), engine.ProjectInitOptions{ProjectID: id, Description: desc, ActorID: viper.GetString("actor-id"), OrgID: org})
 			if err != nil {
 				return err
 			}
 			if err := e.Repo.UpsertProjectConfig(cmd.Context(), id, cfg); err != nil {
 				return err
 			}
+			if parent != "" {
+				if p, err = e.SetProjectParent(cmd.Context(), id, parent, viper.GetString("actor-id")); err != nil {
+					return err
+				}
+			}
+			if seedPath != "" {
+				if _, err := e.ApplySeed(cmd.Context(), id, viper.GetString("actor-id"), sd); err != nil {
+					return fmt.Errorf("seed: %w", err)
+				}
+			}
 			return printJSONOrTable(p)
 		},
 	}
 	cmd.Flags().StringVar(&id, "id", "", "project id")
 	cmd.Flags().StringVar(&desc, "description", "", "description")
+	cmd.Flags().StringVar(&seedPath, "seed", "", "YAML seed file applied after creation")
+	cmd.Flags().StringVar(&parent, "parent", "", "program (parent project) id")
+	cmd.Flags().StringVar(&org, "org", "", "org holding the project, which the actor must own (default org when empty)")
+	_ = cmd.MarkFlagRequired("id")
+	return cmd
+}
+
+func orgCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "org",
+		Short: "Manage orgs",
+		Long:  "Orgs group projects hosted for one team. Credentials bound to an org reach only its projects; org roles (owner, member) govern the org's membership and projects.",
+	}
+	cmd.AddCommand(orgCreateCmd())
+	cmd.AddCommand(orgShowCmd())
+	cmd.AddCommand(orgProjectsCmd())
+	cmd.AddCommand(orgMembersCmd())
+	cmd.AddCommand(orgSetRoleCmd())
+	cmd.AddCommand(orgRemoveCmd())
+	return cmd
+}
+
+func orgCreateCmd() *cobra.Command {
+	var id, name string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an org owned by the current actor",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				o, err := e.CreateOrg(ctx, id, name, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(o)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&id, "id", "", "org id")
+	cmd.Flags().StringVar(&name, "name", "", "display name (defaults to the id)")
 	_ = cmd.MarkFlagRequired("id")
 	return cmd
 }
 
+func orgShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <org>",
+		Short: "Show an org",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				o, err := e.GetOrg(ctx, args[0], viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(o)
+			})
+		},
+	}
+}
+
+func orgProjectsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "projects <org>",
+		Short: "List the projects of an org",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				items, err := e.ListOrgProjects(ctx, args[0], viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+}
+
+func orgMembersCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "members <org>",
+		Short: "List org members and their roles",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				items, err := e.ListOrgMembers(ctx, args[0], viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+}
+
+func orgSetRoleCmd() *cobra.Command {
+	var target, role string
+	cmd := &cobra.Command{
+		Use:   "set-role <org>",
+		Short: "Give an actor an org role (owner or member)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				m, err := e.SetOrgRole(ctx, args[0], target, role, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(m)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&target, "actor", "", "actor id")
+	cmd.Flags().StringVar(&role, "role", "member", "org role: owner or member")
+	_ = cmd.MarkFlagRequired("actor")
+	return cmd
+}
+
+func orgRemoveCmd() *cobra.Command {
+	var target string
+	cmd := &cobra.Command{
+		Use:   "remove <org>",
+		Short: "Remove an actor from an org",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.RemoveOrgMember(ctx, args[0], target, viper.GetString("actor-id"))
+			})
+		},
+	}
+	cmd.Flags().StringVar(&target, "actor", "", "actor id")
+	_ = cmd.MarkFlagRequired("actor")
+	return cmd
+}
+
+func projectSeedCmd() *cobra.Command {
+	var filePath string
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Load roles, actors, attestation kinds, presets and a task tree from a YAML seed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sd, err := seed.FromFile(filePath)
+			if err != nil {
+				return err
+			}
+			target := viper.GetString("project")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if target == "" {
+					target = e.Config.Project.ID
+				}
+				res, err := e.ApplySeed(ctx, target, viper.GetString("actor-id"), sd)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&filePath, "file", "", "path to YAML seed")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func projectSyncCmd() *cobra.Command {
+	var filePath string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Reconcile the project with a declarative YAML manifest (iterations, presets, tasks)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := manifest.FromFile(filePath)
+			if err != nil {
+				return err
+			}
+			target := viper.GetString("project")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if target == "" {
+					target = e.Config.Project.ID
+				}
+				plan, err := e.SyncProject(ctx, target, viper.GetString("actor-id"), m, dryRun)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(plan)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&filePath, "file", "", "path to YAML manifest")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan without applying it")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
+
 func projectShowCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "show",
@@ -182,35 +418,154 @@ func projectShowCmd() *cobra.Command {
 	return cmd
 }
 
+func projectVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check content hashes of tasks, decisions and attestations",
+		Long:  "Recompute the content hash of every task, decision and attestation and list those that no longer match the recorded hash. Exits with an error when any entity was altered.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := viper.GetString("project")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if target == "" {
+					target = e.Config.Project.ID
+				}
+				mismatches, err := e.VerifyContentHashes(ctx, target)
+				if err != nil {
+					return err
+				}
+				if len(mismatches) == 0 {
+					if viper.GetBool("json") {
+						return printJSON([]engine.HashMismatch{})
+					}
+					fmt.Println("All content hashes match.")
+					return nil
+				}
+				if err := printJSONOrTable(mismatches); err != nil {
+					return err
+				}
+				return fmt.Errorf("%d entities do not match their content hash", len(mismatches))
+			})
+		},
+	}
+	return cmd
+}
+
 func projectUpdateCmd() *cobra.Command {
 	var status string
-	var description string
+	var description, displayName, metadataJSON string
+	var tags []string
+	var parent string
 	cmd := &cobra.Command{
 		Use:   "update",
 		Short: "Update a project",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			target := viper.GetString("project")
+			u := repo.ProjectUpdate{Status: status}
+			if cmd.Flags().Changed("description") {
+				u.Description = &description
+			}
+			if cmd.Flags().Changed("display-name") {
+				u.DisplayName = &displayName
+			}
+			if cmd.Flags().Changed("tags") {
+				u.Tags = &tags
+			}
+			if cmd.Flags().Changed("metadata-json") {
+				metadata := map[string]any{}
+				if metadataJSON != "" {
+					if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+						return fmt.Errorf("invalid --metadata-json: %w", err)
+					}
+				}
+				u.Metadata = &metadata
+			}
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
 				if target == "" {
 					target = e.Config.Project.ID
 				}
-				var descPtr *string
-				if cmd.Flags().Changed("description") {
-					descPtr = &description
-				}
-				if err := e.Repo.UpdateProject(ctx, target, status, descPtr); err != nil {
-					return err
-				}
-				p, err := e.Repo.GetProject(ctx, target)
+				actorID := viper.GetString("actor-id")
+				p, err := e.UpdateProject(ctx, engine.ProjectUpdateOptions{ProjectID: target, ActorID: actorID, ProjectUpdate: u})
 				if err != nil {
 					return err
 				}
+				if cmd.Flags().Changed("parent") {
+					if p, err = e.SetProjectParent(ctx, target, parent, actorID); err != nil {
+						return err
+					}
+				}
 				return printJSONOrTable(p)
 			})
 		},
 	}
 	cmd.Flags().StringVar(&status, "status", "", "status (active, paused, archived)")
 	cmd.Flags().StringVar(&description, "description", "", "description")
+	cmd.Flags().StringVar(&displayName, "display-name", "", "display name; empty clears it")
+	cmd.Flags().StringSliceVar(&tags, "tags", nil, "comma-separated tags replacing the current ones; empty clears them")
+	cmd.Flags().StringVar(&metadataJSON, "metadata-json", "", "metadata JSON object replacing the current one; empty clears it")
+	cmd.Flags().StringVar(&parent, "parent", "", "program (parent project) id; empty moves to the top level")
+	return cmd
+}
+
+func projectSummaryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "summary",
+		Short: "Roll up a program and every project below it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := viper.GetString("project")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if target == "" {
+					target = e.Config.Project.ID
+				}
+				sum, err := e.SummarizeProgram(ctx, target, nil)
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(sum)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"Project", "Parent", "Status", "Open", "Done"})
+				for _, r := range sum.Projects {
+					tw.AppendRow(table.Row{r.ProjectID, r.ParentProjectID, r.Status, r.OpenTasks, r.DoneTasks})
+				}
+				tw.AppendFooter(table.Row{"Total", "", "", sum.Totals.OpenTasks, sum.Totals.DoneTasks})
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func projectFeaturesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "features",
+		Short: "Show which experimental features the project's flags enable",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := viper.GetString("project")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if target == "" {
+					target = e.Config.Project.ID
+				}
+				features, err := e.ProjectFeatures(ctx, target)
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(features)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"Feature", "Enabled", "Description"})
+				for _, f := range features {
+					tw.AppendRow(table.Row{f.Name, f.Enabled, f.Description})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
 	return cmd
 }
 
@@ -259,6 +614,7 @@ func projectConfigCmd() *cobra.Command {
 	}
 	cfg.AddCommand(projectConfigShowCmd())
 	cfg.AddCommand(projectConfigImportCmd())
+	cfg.AddCommand(projectConfigPlanCmd())
 	return cfg
 }
 
@@ -306,6 +662,38 @@ func projectConfigImportCmd() *cobra.Command {
 	return cmd
 }
 
+func projectConfigPlanCmd() *cobra.Command {
+	var filePath string
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show what importing a YAML config would change (presets, requirements, affected open tasks)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			cfg, err := config.FromYAML(data)
+			if err != nil {
+				return err
+			}
+			projectID := cfg.Project.ID
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if projectID == "" {
+					projectID = e.Config.Project.ID
+				}
+				plan, err := e.PlanConfigChange(ctx, projectID, cfg)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(plan)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&filePath, "file", "", "path to YAML config")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
+
 func statusCmd() *cobra.Command {
 	var projectID string
 	cmd := &cobra.Command{
@@ -369,8 +757,31 @@ func taskCmd() *cobra.Command {
 	task.AddCommand(taskUpdateCmd())
 	task.AddCommand(taskDoneCmd())
 	task.AddCommand(taskClaimCmd())
+	task.AddCommand(taskReadyCmd())
+	task.AddCommand(taskLeasesCmd())
+	task.AddCommand(taskClaimNextCmd())
 	task.AddCommand(taskReleaseCmd())
+	task.AddCommand(taskCancelCmd())
+	task.AddCommand(taskDeferCmd())
+	task.AddCommand(taskTransferCmd())
+	task.AddCommand(taskAssignCmd())
+	task.AddCommand(taskAssigneesCmd())
+	task.AddCommand(taskHandoffsCmd())
+	task.AddCommand(taskApproveCmd())
+	task.AddCommand(taskRejectCmd())
+	task.AddCommand(taskCompletionsCmd())
+	task.AddCommand(taskLinkCmd())
+	task.AddCommand(taskUnlinkCmd())
+	task.AddCommand(taskLinksCmd())
+	task.AddCommand(taskReviewCmd())
 	task.AddCommand(taskTreeCmd())
+	task.AddCommand(taskMoveCmd())
+	task.AddCommand(taskImportDepsCmd())
+	task.AddCommand(taskWaiveCmd())
+	task.AddCommand(taskEvidenceCmd())
+	task.AddCommand(taskExportCmd())
+	task.AddCommand(taskImportCmd())
+	task.AddCommand(taskTypesCmd())
 	return task
 }
 
@@ -379,6 +790,7 @@ func taskCreateCmd() *cobra.Command {
 	var requires []string
 	var dependsOn []string
 	var policy string
+	var customFields string
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create a task",
@@ -387,6 +799,11 @@ func taskCreateCmd() *cobra.Command {
 			opts.RequiredKinds = requires
 			opts.DependsOn = dependsOn
 			opts.PolicyPreset = policy
+			fields, err := parseCustomFields(customFields)
+			if err != nil {
+				return err
+			}
+			opts.CustomFields = fields
 			if cmd.Flags().Changed("require") {
 				opts.PolicyOverride = true
 			}
@@ -402,27 +819,41 @@ func taskCreateCmd() *cobra.Command {
 			})
 		},
 	}
-	cmd.Flags().StringVar(&opts.ID, "id", "", "task id (optional, deterministic UUID if omitted)")
+	cmd.Flags().StringVar(&opts.ID, "id", "", "task id (optional; generated per the project id scheme if omitted)")
 	cmd.Flags().StringVar(&opts.ProjectID, "project", "", "project id")
 	cmd.Flags().StringVar(&opts.IterationID, "iteration", "", "iteration id")
 	cmd.Flags().StringVar(&opts.ParentID, "parent", "", "parent task id")
-	cmd.Flags().StringVar(&opts.Type, "type", "technical", "task type")
+	cmd.Flags().StringVar(&opts.Type, "type", "technical", "task type (built-in or declared under task_types; see wl task types)")
 	cmd.Flags().StringVar(&opts.Title, "title", "", "title")
 	cmd.Flags().StringVar(&opts.Description, "description", "", "description")
 	cmd.Flags().StringArrayVar(&dependsOn, "depends-on", []string{}, "dependency task id (repeatable)")
 	cmd.Flags().StringVar(&opts.AssigneeID, "assignee-id", "", "assignee id")
 	cmd.Flags().StringVar(&opts.PolicyPreset, "policy", "", "policy preset to apply (defaults use config mapping by task type)")
-	cmd.Flags().StringArrayVar(&requires, "require", []string{}, "required attestation kind (repeatable)")
+	cmd.Flags().StringArrayVar(&requires, "require", []string{}, "required attestation kind, optionally with a payload predicate, e.g. \"coverage.report where payload.percent >= 80\" (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.RequiredCapabilities, "capability", []string{}, "capability the claiming actor must offer (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.Labels, "label", []string{}, "label weighed by the risk policy (repeatable)")
+	cmd.Flags().StringVar(&customFields, "custom-fields-json", "", "custom fields JSON object, checked against the task type schema")
+	cmd.Flags().StringVar(&opts.DeferUntil, "defer-until", "", "keep the task out of the ready queue until this RFC3339 time")
+	cmd.Flags().StringVar(&opts.DueAt, "due", "", "deadline (RFC3339) for the deadline scheduler")
+	cmd.Flags().StringVar(&opts.FreezeOverride, "freeze-override", "", "reason for adding the task to a frozen iteration (needs iteration.freeze.override)")
 	_ = cmd.MarkFlagRequired("title")
 	return cmd
 }
 
 func taskListCmd() *cobra.Command {
 	var f repo.TaskFilters
+	var fields []string
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List tasks",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, raw := range fields {
+				cf, err := repo.ParseCustomFieldFilter(raw)
+				if err != nil {
+					return err
+				}
+				f.CustomFields = append(f.CustomFields, cf)
+			}
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
 				if f.ProjectID == "" {
 					f.ProjectID = e.Config.Project.ID
@@ -455,9 +886,11 @@ func taskListCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVar(&f.ProjectID, "project", "", "project id")
 	cmd.Flags().StringVar(&f.Status, "status", "", "status filter")
+	cmd.Flags().StringVar(&f.Type, "type", "", "type filter")
 	cmd.Flags().StringVar(&f.Iteration, "iteration", "", "iteration filter")
 	cmd.Flags().StringVar(&f.Parent, "parent", "", "parent task id")
 	cmd.Flags().StringVar(&f.AssigneeID, "assignee-id", "", "assignee filter")
+	cmd.Flags().StringArrayVar(&fields, "field", []string{}, "custom field filter, e.g. component=billing or points>=3 (repeatable)")
 	return cmd
 }
 
@@ -487,6 +920,7 @@ func taskUpdateCmd() *cobra.Command {
 	var workOutcomes string
 	var assign string
 	var setPolicy string
+	var customFields string
 	cmd := &cobra.Command{
 		Use:   "update <id>",
 		Short: "Update task",
@@ -505,6 +939,15 @@ func taskUpdateCmd() *cobra.Command {
 			opts.ParentProvided = cmd.Flags().Changed("set-parent")
 			opts.WorkOutcomesSet = cmd.Flags().Changed("set-work-outcomes-json")
 			opts.RequiredKindsSet = cmd.Flags().Changed("require")
+			opts.RequiredCapabilitiesSet = cmd.Flags().Changed("capability")
+			opts.LabelsSet = cmd.Flags().Changed("label")
+			opts.CustomFieldsSet = cmd.Flags().Changed("set-custom-fields-json")
+			opts.DueAtSet = cmd.Flags().Changed("set-due")
+			fields, err := parseCustomFields(customFields)
+			if err != nil {
+				return err
+			}
+			opts.CustomFields = fields
 			if opts.WorkOutcomesSet && opts.SetWorkOutcomes == nil {
 				opts.ClearWorkOutcomes = true
 			}
@@ -523,24 +966,74 @@ func taskUpdateCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVar(&opts.Status, "status", "", "new status")
 	cmd.Flags().StringVar(&assign, "assign", "", "set assignee id (empty clears)")
+	cmd.Flags().StringVar(&opts.HandoffNote, "handoff-note", "", "context for the new assignee (with --assign)")
 	cmd.Flags().StringArrayVar(&addDeps, "add-depends-on", []string{}, "add dependency")
 	cmd.Flags().StringArrayVar(&removeDeps, "remove-depends-on", []string{}, "remove dependency")
 	cmd.Flags().StringVar(&setParent, "set-parent", "", "set parent task id (empty for none)")
 	cmd.Flags().StringVar(&workOutcomes, "set-work-outcomes-json", "", "set work outcomes JSON")
 	cmd.Flags().StringVar(&opts.PolicyPreset, "set-policy", "", "apply policy preset to task")
-	cmd.Flags().StringArrayVar(&requires, "require", []string{}, "required attestation kind")
+	cmd.Flags().StringArrayVar(&requires, "require", []string{}, "required attestation kind, optionally with a payload predicate (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.RequiredCapabilities, "capability", []string{}, "replace required capabilities (repeatable; --capability= clears)")
+	cmd.Flags().StringArrayVar(&opts.Labels, "label", []string{}, "replace labels (repeatable; --label= clears)")
+	cmd.Flags().StringVar(&customFields, "set-custom-fields-json", "", "replace custom fields JSON (empty clears)")
+	cmd.Flags().StringVar(&opts.DueAt, "set-due", "", "set the deadline, RFC3339 (empty clears)")
+	cmd.Flags().StringVar(&opts.FreezeOverride, "freeze-override", "", "reason for loosening the policy of a task in a frozen iteration (needs iteration.freeze.override)")
 	return cmd
 }
 
-func taskDoneCmd() *cobra.Command {
-	var workOutcomes string
-	cmd := &cobra.Command{
-		Use:   "done <id>",
-		Short: "Complete task",
-		Args:  cobra.ExactArgs(1),
+func taskTypesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "types",
+		Short: "List task types and their custom field schemas",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if workOutcomes == "" {
-				return fmt.Errorf("--work-outcomes-json required")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				types := engine.TaskTypes(e.Config)
+				if viper.GetBool("json") {
+					return printJSON(types)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"Type", "Builtin", "Description", "Fields"})
+				for _, tt := range types {
+					fields := ""
+					if props, ok := tt.Fields["properties"].(map[string]any); ok {
+						names := make([]string, 0, len(props))
+						for name := range props {
+							names = append(names, name)
+						}
+						sort.Strings(names)
+						fields = strings.Join(names, ", ")
+					}
+					tw.AppendRow(table.Row{tt.Name, tt.Builtin, tt.Description, fields})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+}
+
+// parseCustomFields decodes a --custom-fields-json value; empty means none.
+func parseCustomFields(s string) (map[string]any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(s), &fields); err != nil {
+		return nil, fmt.Errorf("invalid custom fields JSON: %w", err)
+	}
+	return fields, nil
+}
+
+func taskDoneCmd() *cobra.Command {
+	var workOutcomes string
+	cmd := &cobra.Command{
+		Use:   "done <id>",
+		Short: "Complete task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if workOutcomes == "" {
+				return fmt.Errorf("--work-outcomes-json required")
 			}
 			id := args[0]
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
@@ -558,22 +1051,167 @@ func taskDoneCmd() *cobra.Command {
 
 func taskClaimCmd() *cobra.Command {
 	var leaseSeconds int
+	var wait, leaveQueue, waiters, show bool
+	var plan string
+	var estimate time.Duration
 	cmd := &cobra.Command{
 		Use:   "claim <id>",
 		Short: "Claim task lease",
+		Long:  "With --wait, a lease held by another actor queues you instead of failing; queued actors are granted the lease in order when it is released or expires (lease.granted event). --plan declares your intended approach (and --estimate its duration) with the claim, for supervisors to review with --show.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id := args[0]
+			if (wait && leaveQueue) || (wait && waiters) || (leaveQueue && waiters) {
+				return fmt.Errorf("--wait, --leave-queue and --waiters are mutually exclusive")
+			}
+			if show && (wait || leaveQueue || waiters) {
+				return fmt.Errorf("--show cannot be combined with --wait, --leave-queue or --waiters")
+			}
+			if plan == "" && estimate != 0 {
+				return fmt.Errorf("--estimate requires --plan")
+			}
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				lease, err := e.ClaimLease(ctx, id, viper.GetString("actor-id"), leaseSeconds)
+				actorID := viper.GetString("actor-id")
+				switch {
+				case show:
+					lease, err := e.TaskLease(ctx, id, actorID)
+					if err != nil {
+						return err
+					}
+					return printJSONOrTable(lease)
+				case leaveQueue:
+					return e.LeaveLeaseQueue(ctx, id, actorID)
+				case waiters:
+					list, err := e.LeaseWaiters(ctx, id, actorID)
+					if err != nil {
+						return err
+					}
+					if viper.GetBool("json") {
+						if list == nil {
+							list = []domain.LeaseWaiter{}
+						}
+						return printJSON(list)
+					}
+					tw := table.NewWriter()
+					tw.SetOutputMirror(os.Stdout)
+					tw.AppendHeader(table.Row{"Position", "Actor", "Lease Seconds", "Enqueued At"})
+					for _, w := range list {
+						tw.AppendRow(table.Row{w.Position, w.ActorID, w.LeaseSeconds, w.EnqueuedAt})
+					}
+					tw.Render()
+					return nil
+				}
+				opts := engine.LeaseClaimOptions{TaskID: id, ActorID: actorID, LeaseSeconds: leaseSeconds, Wait: wait}
+				if plan != "" {
+					opts.Plan = &domain.LeasePlan{Approach: plan, EstimatedSeconds: int(estimate.Seconds())}
+				}
+				claim, err := e.ClaimLeaseWithOptions(ctx, opts)
 				if err != nil {
 					return err
 				}
-				return printJSONOrTable(lease)
+				if claim.Waiter != nil {
+					return printJSONOrTable(map[string]any{"lease": claim.Lease, "waiting": claim.Waiter})
+				}
+				return printJSONOrTable(claim.Lease)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&leaseSeconds, "lease-seconds", 0, "lease duration seconds (default config.leases.default_ttl)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "queue for the lease when another actor holds it")
+	cmd.Flags().BoolVar(&leaveQueue, "leave-queue", false, "leave the queue for the lease")
+	cmd.Flags().BoolVar(&waiters, "waiters", false, "list actors queued for the lease")
+	cmd.Flags().StringVar(&plan, "plan", "", "intended approach, stored on the lease")
+	cmd.Flags().DurationVar(&estimate, "estimate", 0, "estimated duration of the planned work")
+	cmd.Flags().BoolVar(&show, "show", false, "show the lease and its plan")
+	return cmd
+}
+
+func taskReadyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ready",
+		Short: "List tasks you can claim",
+		Long:  "Planned tasks whose dependencies are done, without an active lease or deferral, unassigned or assigned to you, and whose required capabilities you offer (see wl capabilities). Oldest first, or earliest deadline first when scheduler.mode is deadline; claim-next takes the first.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				tasks, err := e.ReadyTasks(ctx, e.Config.Project.ID, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(tasks)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"ID", "Title", "Type", "Due", "Capabilities"})
+				for _, t := range tasks {
+					due := ""
+					if t.DueAt != nil {
+						due = *t.DueAt
+					}
+					tw.AppendRow(table.Row{t.ID, t.Title, t.Type, due, strings.Join(t.RequiredCapabilities, ",")})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func taskLeasesCmd() *cobra.Command {
+	var f engine.LeaseFilter
+	cmd := &cobra.Command{
+		Use:   "leases",
+		Short: "List the active leases of the project",
+		Long:  "Leases on unfinished tasks that have not expired, soonest expiry first, with their owners and plans. Needs lease.plan.read.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				leases, err := e.ProjectLeases(ctx, e.Config.Project.ID, viper.GetString("actor-id"), f)
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					if leases == nil {
+						leases = []domain.Lease{}
+					}
+					return printJSON(leases)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"Task", "Owner", "Acquired At", "Expires At", "Plan"})
+				for _, l := range leases {
+					plan := ""
+					if l.Plan != nil {
+						plan = l.Plan.Approach
+					}
+					tw.AppendRow(table.Row{l.TaskID, l.OwnerID, l.AcquiredAt, l.ExpiresAt, plan})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&f.OwnerID, "owner", "", "only leases held by this actor")
+	cmd.Flags().DurationVar(&f.ExpiringWithin, "expiring-within", 0, "only leases expiring within this duration")
+	return cmd
+}
+
+func taskClaimNextCmd() *cobra.Command {
+	var leaseSeconds int
+	cmd := &cobra.Command{
+		Use:   "claim-next",
+		Short: "Claim the first task of your ready queue",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				t, lease, err := e.ClaimNext(ctx, e.Config.Project.ID, viper.GetString("actor-id"), leaseSeconds)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(map[string]any{"task": t, "lease": lease})
 			})
 		},
 	}
-	cmd.Flags().IntVar(&leaseSeconds, "lease-seconds", 900, "lease duration seconds")
+	cmd.Flags().IntVar(&leaseSeconds, "lease-seconds", 0, "lease duration seconds (default config.leases.default_ttl)")
 	return cmd
 }
 
@@ -592,6 +1230,258 @@ func taskReleaseCmd() *cobra.Command {
 	return cmd
 }
 
+func taskTransferCmd() *cobra.Command {
+	var to string
+	var leaseSeconds int
+	var requireConsent, accept, decline bool
+	cmd := &cobra.Command{
+		Use:   "transfer <id>",
+		Short: "Hand a held lease to another actor",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			if accept && decline {
+				return fmt.Errorf("--accept and --decline are mutually exclusive")
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				actorID := viper.GetString("actor-id")
+				var lease domain.Lease
+				var err error
+				switch {
+				case accept:
+					lease, err = e.AcceptLeaseTransfer(ctx, id, actorID, leaseSeconds)
+				case decline:
+					lease, err = e.DeclineLeaseTransfer(ctx, id, actorID)
+				default:
+					if to == "" {
+						return fmt.Errorf("--to is required")
+					}
+					lease, err = e.TransferLease(ctx, engine.LeaseTransferOptions{
+						TaskID:         id,
+						ActorID:        actorID,
+						ToActorID:      to,
+						LeaseSeconds:   leaseSeconds,
+						RequireConsent: requireConsent,
+					})
+				}
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(lease)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "actor receiving the lease")
+	cmd.Flags().BoolVar(&requireConsent, "require-consent", false, "offer the lease; the target must accept")
+	cmd.Flags().BoolVar(&accept, "accept", false, "accept a transfer offered to you")
+	cmd.Flags().BoolVar(&decline, "decline", false, "decline or withdraw a pending transfer")
+	cmd.Flags().IntVar(&leaseSeconds, "lease-seconds", 0, "new lease duration seconds (default keeps remaining term)")
+	return cmd
+}
+
+func taskAssignCmd() *cobra.Command {
+	var actor, role, note string
+	var remove bool
+	cmd := &cobra.Command{
+		Use:   "assign <id>",
+		Short: "Add (or --remove) a driver or reviewer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := engine.TaskAssignOptions{TaskID: args[0], AssigneeID: actor, Role: role, ActorID: viper.GetString("actor-id"), HandoffNote: note}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if remove {
+					return e.UnassignTask(ctx, opts)
+				}
+				a, err := e.AssignTask(ctx, opts)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(a)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&actor, "actor", "", "actor to assign")
+	cmd.Flags().StringVar(&role, "role", "driver", "driver or reviewer")
+	cmd.Flags().BoolVar(&remove, "remove", false, "remove the assignment instead")
+	cmd.Flags().StringVar(&note, "handoff-note", "", "context for the new assignee")
+	_ = cmd.MarkFlagRequired("actor")
+	return cmd
+}
+
+func taskAssigneesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "assignees <id>",
+		Short: "List task drivers and reviewers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				assignees, err := e.Repo.ListTaskAssignees(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(assignees)
+			})
+		},
+	}
+	return cmd
+}
+
+func taskHandoffsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "handoffs <id>",
+		Short: "Show assignee changes and handoff notes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				handoffs, err := e.Repo.ListTaskHandoffs(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(handoffs)
+			})
+		},
+	}
+	return cmd
+}
+
+func taskApproveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "approve <id>",
+		Short: "Approve the completion awaiting approval, making the task done",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				t, err := e.ApproveTaskCompletion(ctx, args[0], viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(t)
+			})
+		},
+	}
+	return cmd
+}
+
+func taskRejectCmd() *cobra.Command {
+	var reason string
+	cmd := &cobra.Command{
+		Use:   "reject <id>",
+		Short: "Reject the completion awaiting approval, moving the task to rejected",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				t, err := e.RejectTaskCompletion(ctx, args[0], viper.GetString("actor-id"), reason)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(t)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "why the completion is rejected")
+	_ = cmd.MarkFlagRequired("reason")
+	return cmd
+}
+
+func taskCompletionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completions <id>",
+		Short: "Show completions submitted for approval and their decisions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				completions, err := e.Repo.ListTaskCompletions(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(completions)
+			})
+		},
+	}
+	return cmd
+}
+
+func taskLinkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link <id> <commit|branch|pr> <target>",
+		Short: "Link a commit SHA, branch or pull request URL to a task",
+		Long:  "Link a commit SHA, branch or pull request URL to a task. Webhooks of the project's VCS integrations keep the link's CI and review status current.",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				l, err := e.AddTaskLink(ctx, args[0], args[1], args[2], viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(l)
+			})
+		},
+	}
+	return cmd
+}
+
+func taskUnlinkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unlink <id> <link-id>",
+		Short: "Remove a task link",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			linkID, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid link id %q", args[1])
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.RemoveTaskLink(ctx, args[0], linkID, viper.GetString("actor-id"))
+			})
+		},
+	}
+	return cmd
+}
+
+func taskLinksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "links <id>",
+		Short: "Show a task's linked commits, branches and pull requests with their CI and review status",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				links, err := e.Repo.ListTaskLinks(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(links)
+			})
+		},
+	}
+	return cmd
+}
+
+func taskReviewCmd() *cobra.Command {
+	var leaseSeconds int
+	var release bool
+	cmd := &cobra.Command{
+		Use:   "review <id>",
+		Short: "Claim (or --release) the review lease as an assigned reviewer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if release {
+					return e.ReleaseReviewLease(ctx, id, viper.GetString("actor-id"))
+				}
+				lease, err := e.ClaimReviewLease(ctx, id, viper.GetString("actor-id"), leaseSeconds)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(lease)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&leaseSeconds, "lease-seconds", 0, "lease duration seconds (default config.leases.default_ttl)")
+	cmd.Flags().BoolVar(&release, "release", false, "release the review lease")
+	return cmd
+}
+
 func taskTreeCmd() *cobra.Command {
 	var iteration, status string
 	cmd := &cobra.Command{
@@ -612,6 +1502,10 @@ func taskTreeCmd() *cobra.Command {
 						roots = append(roots, t)
 					}
 				}
+				engine.SortTasksByRank(roots)
+				for id := range nodes {
+					engine.SortTasksByRank(nodes[id])
+				}
 				if viper.GetBool("json") {
 					type Node struct {
 						Task     domain.Task `json:"task"`
@@ -644,52 +1538,2048 @@ func taskTreeCmd() *cobra.Command {
 	return cmd
 }
 
-func iterationCmd() *cobra.Command {
-	iter := &cobra.Command{
-		Use:   "iteration",
+func taskDeferCmd() *cobra.Command {
+	var until string
+	var snooze time.Duration
+	var undefer bool
+	cmd := &cobra.Command{
+		Use:   "defer <id>",
+		Short: "Keep a task out of the ready queue until a time",
+		Long:  "Deferred tasks are left out of wl task ready and claim-next until --until (RFC3339), or for --for (snooze). `wl serve` records a task.ready event once the time passes, every --defer-interval. --clear ends the deferral now.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set := 0
+			for _, on := range []bool{until != "", snooze > 0, undefer} {
+				if on {
+					set++
+				}
+			}
+			if set != 1 {
+				return fmt.Errorf("set one of --until, --for or --clear")
+			}
+			if snooze > 0 {
+				until = time.Now().UTC().Add(snooze).Format(time.RFC3339)
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				t, err := e.DeferTask(ctx, args[0], until, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(t)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&until, "until", "", "RFC3339 time the task becomes ready again")
+	cmd.Flags().DurationVar(&snooze, "for", 0, "snooze for a duration, e.g. 48h")
+	cmd.Flags().BoolVar(&undefer, "clear", false, "end the deferral now")
+	return cmd
+}
+
+func taskCancelCmd() *cobra.Command {
+	var cascade string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "cancel <id>",
+		Short: "Cancel a task, optionally with its descendants and dependents",
+		Long:  "--cascade children also cancels open descendants; --cascade dependents additionally cancels open tasks depending on any canceled task. Open dependents left behind are listed as blocked. Cascaded tasks whose transition is not allowed, or whose lease another actor holds, are skipped unless --force. --dry-run shows the report without changing anything.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.CancelTask(ctx, engine.TaskCancelOptions{
+					ID:      id,
+					ActorID: viper.GetString("actor-id"),
+					Cascade: cascade,
+					DryRun:  dryRun,
+					Force:   viper.GetBool("force"),
+				})
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(res)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"Outcome", "ID", "Title", "Status", "Via", "Of", "Reason"})
+				for _, group := range []struct {
+					outcome string
+					items   []engine.CancelItem
+				}{{"canceled", res.Canceled}, {"blocked", res.Blocked}, {"skipped", res.Skipped}} {
+					for _, it := range group.items {
+						tw.AppendRow(table.Row{group.outcome, it.TaskID, it.Title, it.Status, it.Via, it.Of, it.Reason})
+					}
+				}
+				tw.Render()
+				if dryRun {
+					fmt.Println("Dry run: nothing was changed.")
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&cascade, "cascade", engine.CascadeNone, "none, children or dependents")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "preview the affected tasks without canceling")
+	return cmd
+}
+
+func taskMoveCmd() *cobra.Command {
+	var before, after string
+	cmd := &cobra.Command{
+		Use:   "move <id>",
+		Short: "Reorder task among its siblings",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				t, err := e.MoveTask(ctx, engine.TaskMoveOptions{
+					ID:       id,
+					BeforeID: before,
+					AfterID:  after,
+					ActorID:  viper.GetString("actor-id"),
+				})
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(t)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&before, "before", "", "place before this sibling task")
+	cmd.Flags().StringVar(&after, "after", "", "place after this sibling task")
+	return cmd
+}
+
+func taskImportDepsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import-deps <file>",
+		Short: "Import dependency edges from a JSON adjacency list",
+		Long:  "Read a JSON list of {\"from\": ..., \"to\": ...} edges, where to depends on from, either bare or under \"edges\", and add them all at once. The whole batch is rejected if a task is missing or the edges would close a dependency cycle.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var edges []engine.DependencyEdge
+			if err := json.Unmarshal(data, &edges); err != nil {
+				var wrapped struct {
+					Edges []engine.DependencyEdge `json:"edges"`
+				}
+				if json.Unmarshal(data, &wrapped) != nil {
+					return fmt.Errorf("invalid dependency file %s: %w", args[0], err)
+				}
+				edges = wrapped.Edges
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.ImportDependencies(ctx, e.Config.Project.ID, edges, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(res)
+				}
+				fmt.Printf("%d dependencies added, %d already present\n", len(res.Added), len(res.Existing))
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func taskWaiveCmd() *cobra.Command {
+	var kind, justification, expires string
+	var ttl time.Duration
+	cmd := &cobra.Command{
+		Use:   "waive <id>",
+		Short: "Waive a missing attestation kind with a justification",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				expiresAt := expires
+				if expiresAt == "" && ttl > 0 {
+					expiresAt = time.Now().UTC().Add(ttl).Format(time.RFC3339)
+				}
+				w, err := e.WaiveValidation(ctx, engine.WaiverCreateOptions{
+					TaskID:        id,
+					Kind:          kind,
+					Justification: justification,
+					ExpiresAt:     expiresAt,
+					ActorID:       viper.GetString("actor-id"),
+				})
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(w)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&kind, "kind", "", "attestation kind to waive")
+	cmd.Flags().StringVar(&justification, "justification", "", "why the requirement is waived")
+	cmd.Flags().StringVar(&expires, "expires", "", "expiry as RFC3339 timestamp")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "expiry relative to now (e.g. 72h), used when --expires is empty")
+	return cmd
+}
+
+func taskEvidenceCmd() *cobra.Command {
+	var out, keyPath string
+	cmd := &cobra.Command{
+		Use:   "evidence <id>",
+		Short: "Export a signed evidence bundle for a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if keyPath == "" {
+					keyPath = evidence.DefaultKeyPath(viper.GetString("workspace"))
+				}
+				signer, err := evidence.LoadOrCreate(keyPath)
+				if err != nil {
+					return err
+				}
+				e.Evidence = signer
+				bundle, err := e.TaskEvidence(ctx, id)
+				if err != nil {
+					return err
+				}
+				if out == "" {
+					return printJSON(bundle)
+				}
+				data, err := json.MarshalIndent(bundle, "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(out, append(data, '\n'), 0o644); err != nil {
+					return err
+				}
+				fmt.Printf("Wrote evidence for %s to %s (key %s)\n", id, out, bundle.Signature.KeyID)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "write the bundle to this file instead of stdout")
+	cmd.Flags().StringVar(&keyPath, "key", "", "Ed25519 signing key (PKCS#8 PEM); defaults to .workline/evidence.key, created if missing")
+	return cmd
+}
+
+func taskExportCmd() *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "export <id>",
+		Short: "Export a task with its descendants, dependencies and attestations",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				snap, err := e.ExportSubtree(ctx, id)
+				if err != nil {
+					return err
+				}
+				if out == "" {
+					return printJSON(snap)
+				}
+				data, err := json.MarshalIndent(snap, "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(out, append(data, '\n'), 0o644); err != nil {
+					return err
+				}
+				fmt.Printf("Wrote %d tasks and %d attestations under %s to %s\n", len(snap.Tasks), len(snap.Attestations), id, out)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "write the snapshot to this file instead of stdout")
+	return cmd
+}
+
+func taskImportCmd() *cobra.Command {
+	var opts engine.SubtreeImportOptions
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Recreate a task subtree exported with wl task export",
+		Long:  "Create the snapshot's tasks under new IDs in the current project, with the dependencies among them. Tasks start planned and keep their assignee, work outcomes and attestations unless --template also leaves them unassigned, without outcomes or attestations.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var snap engine.SubtreeSnapshot
+			if err := json.Unmarshal(data, &snap); err != nil {
+				return fmt.Errorf("invalid snapshot file %s: %w", args[0], err)
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.ImportSubtree(ctx, e.Config.Project.ID, snap, opts, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(res)
+				}
+				fmt.Printf("Imported %s as %s: %d tasks, %d attestations\n", snap.RootID, res.RootID, len(res.Tasks), len(res.Attestations))
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&opts.ParentID, "parent", "", "place the subtree's root under this task")
+	cmd.Flags().BoolVar(&opts.Template, "template", false, "start every task as planned and unassigned, without work outcomes or attestations")
+	cmd.Flags().StringVar(&opts.IDPrefix, "id-prefix", "", "derive new IDs by prefixing the snapshot's")
+	return cmd
+}
+
+func statsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Daily project metrics",
+		Long:  "Record and read daily snapshots (open/done tasks, completions, attestations, lead time). `wl serve` records them automatically.",
+	}
+	cmd.AddCommand(statsSnapshotCmd())
+	cmd.AddCommand(statsSeriesCmd())
+	cmd.AddCommand(statsCycleTimeCmd())
+	return cmd
+}
+
+func statsCycleTimeCmd() *cobra.Command {
+	var from, to, taskType, iterationID string
+	cmd := &cobra.Command{
+		Use:   "cycle-time",
+		Short: "Lead time, cycle time, time in status and WIP age, from task events",
+		Long:  "Lead time runs from creation to completion and cycle time from the task first leaving planned to completion, over the tasks completed in the period, with p50, p85 and p95. WIP age covers the tasks in progress or in review now.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, end, err := engine.ParsePeriod(from, to, time.Now())
+			if err != nil {
+				return err
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				rep, err := e.CycleTime(ctx, engine.CycleTimeOptions{
+					ProjectID:   e.Config.Project.ID,
+					Type:        taskType,
+					IterationID: iterationID,
+					From:        start,
+					To:          end,
+				})
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(rep)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "period start (RFC3339 or YYYY-MM-DD); defaults to 30 days before --to")
+	cmd.Flags().StringVar(&to, "to", "", "period end (RFC3339, exclusive) or last day included (YYYY-MM-DD); defaults to today")
+	cmd.Flags().StringVar(&taskType, "type", "", "only tasks of this type")
+	cmd.Flags().StringVar(&iterationID, "iteration", "", "only tasks of this iteration")
+	return cmd
+}
+
+func statsSnapshotCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "snapshot",
+		Short: "Record today's snapshot for the current project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				snap, err := e.RecordDailyStats(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(snap)
+			})
+		},
+	}
+}
+
+func statsSeriesCmd() *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "series",
+		Short: "List recorded snapshots",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if to == "" {
+				to = time.Now().UTC().Format(time.DateOnly)
+			}
+			if from == "" {
+				end, err := time.Parse(time.DateOnly, to)
+				if err != nil {
+					return fmt.Errorf("invalid --to: %w", err)
+				}
+				from = end.AddDate(0, 0, -30).Format(time.DateOnly)
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				snaps, err := e.Repo.ListStatsSnapshots(ctx, e.Config.Project.ID, from, to)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(snaps)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "first day (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "last day (YYYY-MM-DD), defaults to today")
+	return cmd
+}
+
+// recordStatsLoop snapshots every project's daily stats each interval until ctx is done.
+func recordStatsLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		projects, err := e.Repo.ListProjects(ctx)
+		if err == nil {
+			for _, p := range projects {
+				if _, err := e.RecordDailyStats(ctx, p.ID); err != nil && ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "stats: project %s: %v\n", p.ID, err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// usageFlushLoop writes buffered API usage counts each interval until ctx is done.
+func usageFlushLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := e.FlushUsage(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "usage: %v\n", err)
+		}
+	}
+}
+
+func exportMetricsLoop(ctx context.Context, e engine.Engine, exporter *telemetry.Exporter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ms, err := e.WorkflowMetrics(ctx)
+		if err == nil {
+			err = exporter.Export(ctx, telemetry.WorkflowInstruments(ms), time.Now())
+		}
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "metrics: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func digestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Daily project digests",
+		Long:  "Generate and read daily digests: tasks completed, decisions recorded, refused validations and stuck leases. `wl serve` generates yesterday's digest of every project.",
+	}
+	cmd.AddCommand(digestGenerateCmd())
+	cmd.AddCommand(digestShowCmd())
+	cmd.AddCommand(digestListCmd())
+	return cmd
+}
+
+func digestGenerateCmd() *cobra.Command {
+	var day string
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate the digest of a day for the current project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := engine.DigestDay(day, time.Now())
+			if err != nil {
+				return err
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.GenerateDigest(ctx, e.Config.Project.ID, d, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&day, "day", "", "day to summarize (YYYY-MM-DD), defaults to yesterday (UTC)")
+	return cmd
+}
+
+func digestShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <day>",
+		Short: "Show a stored digest",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.GetDigest(ctx, e.Config.Project.ID, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+}
+
+func digestListCmd() *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List stored digests, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if to == "" {
+				to = time.Now().UTC().Format(time.DateOnly)
+			}
+			if from == "" {
+				end, err := time.Parse(time.DateOnly, to)
+				if err != nil {
+					return fmt.Errorf("invalid --to: %w", err)
+				}
+				from = end.AddDate(0, 0, -30).Format(time.DateOnly)
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.ListDigests(ctx, e.Config.Project.ID, from, to)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "first day (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "last day (YYYY-MM-DD), defaults to today")
+	return cmd
+}
+
+// digestLoop generates yesterday's digest of every project lacking one each interval until
+// ctx is done.
+func digestLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.GenerateDueDigests(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "digest: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func escalationCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "escalation",
+		Short: "Escalation rules for overdue tasks and blocked validations",
+		Long:  "Rules act on tasks left in their condition for their delay: task.overdue covers planned or in-progress tasks without an active lease, validation.blocked tasks in review with unmet requirements. notify records an escalation.triggered event naming a role and its holders, which notification channels can relay; follow_up also creates a chore task. `wl serve` applies the rules every --escalation-interval.",
+	}
+	cmd.AddCommand(escalationCreateCmd())
+	cmd.AddCommand(escalationListCmd())
+	cmd.AddCommand(escalationGetCmd())
+	cmd.AddCommand(escalationUpdateCmd())
+	cmd.AddCommand(escalationDeleteCmd())
+	cmd.AddCommand(escalationRunCmd())
+	return cmd
+}
+
+func escalationCreateCmd() *cobra.Command {
+	var rule domain.EscalationRule
+	var disabled bool
+	cmd := &cobra.Command{
+		Use:   "create <rule>",
+		Short: "Create an escalation rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				rule.ProjectID, rule.ID, rule.Enabled = e.Config.Project.ID, args[0], !disabled
+				res, err := e.CreateEscalationRule(ctx, rule, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&rule.Condition, "condition", "", "task.overdue or validation.blocked")
+	cmd.Flags().StringVar(&rule.After, "after", "", "how long a task stays in the condition before the rule acts, e.g. 48h")
+	cmd.Flags().StringVar(&rule.Action, "action", "notify", "notify or follow_up")
+	cmd.Flags().StringVar(&rule.RoleID, "role", "", "role to notify (required by notify)")
+	cmd.Flags().StringVar(&rule.TaskType, "task-type", "", "only escalate tasks of this type")
+	cmd.Flags().StringVar(&rule.Description, "description", "", "rule description")
+	cmd.Flags().BoolVar(&disabled, "disabled", false, "create the rule paused")
+	return cmd
+}
+
+func escalationListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the project's escalation rules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.Repo.ListEscalationRules(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+}
+
+func escalationGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <rule>",
+		Short: "Show an escalation rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.Repo.GetEscalationRule(ctx, e.Config.Project.ID, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+}
+
+func escalationUpdateCmd() *cobra.Command {
+	var condition, after, action, role, taskType, description string
+	var enabled bool
+	cmd := &cobra.Command{
+		Use:   "update <rule>",
+		Short: "Change an escalation rule; only the flags given are applied",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var u engine.EscalationRuleUpdate
+			for flag, field := range map[string]struct {
+				dst **string
+				val *string
+			}{
+				"condition":   {&u.Condition, &condition},
+				"after":       {&u.After, &after},
+				"action":      {&u.Action, &action},
+				"role":        {&u.RoleID, &role},
+				"task-type":   {&u.TaskType, &taskType},
+				"description": {&u.Description, &description},
+			} {
+				if cmd.Flags().Changed(flag) {
+					*field.dst = field.val
+				}
+			}
+			if cmd.Flags().Changed("enabled") {
+				u.Enabled = &enabled
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.UpdateEscalationRule(ctx, e.Config.Project.ID, args[0], u, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&condition, "condition", "", "task.overdue or validation.blocked")
+	cmd.Flags().StringVar(&after, "after", "", "how long a task stays in the condition before the rule acts")
+	cmd.Flags().StringVar(&action, "action", "", "notify or follow_up")
+	cmd.Flags().StringVar(&role, "role", "", "role to notify")
+	cmd.Flags().StringVar(&taskType, "task-type", "", "only escalate tasks of this type (empty for all)")
+	cmd.Flags().StringVar(&description, "description", "", "rule description")
+	cmd.Flags().BoolVar(&enabled, "enabled", true, "resume (true) or pause (false) the rule")
+	return cmd
+}
+
+func escalationDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <rule>",
+		Short: "Delete an escalation rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.DeleteEscalationRule(ctx, e.Config.Project.ID, args[0], viper.GetString("actor-id"))
+			})
+		},
+	}
+}
+
+func escalationRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "Apply the project's escalation rules once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				rep, err := e.RunEscalations(ctx, e.Config.Project.ID, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(rep)
+			})
+		},
+	}
+}
+
+// escalationLoop applies the escalation rules of every project each interval until ctx is done.
+func escalationLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.RunDueEscalations(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "escalations: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func inboxCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inbox",
+		Short: "Your notifications in the project",
+		Long:  "The inbox holds mentions (@actor in a task, a handoff note or a decision), leases about to expire, and tasks in review needing an attestation kind one of your roles may record. `wl serve` collects them every --inbox-interval.",
+	}
+	cmd.AddCommand(inboxListCmd())
+	cmd.AddCommand(inboxReadCmd())
+	cmd.AddCommand(inboxCollectCmd())
+	cmd.AddCommand(inboxEmailCmd())
+	return cmd
+}
+
+func inboxListCmd() *cobra.Command {
+	var unread bool
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List your notifications, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				items, err := e.Repo.ListNotifications(ctx, e.Config.Project.ID, viper.GetString("actor-id"), unread, limit, 0)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&unread, "unread", false, "only unread notifications")
+	cmd.Flags().IntVar(&limit, "limit", 50, "maximum notifications to list")
+	return cmd
+}
+
+func inboxReadCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "read [<id>]",
+		Short: "Mark a notification, or with --all every notification, read",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return fmt.Errorf("give a notification id or --all")
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				actorID := viper.GetString("actor-id")
+				if all {
+					marked, err := e.ReadAllNotifications(ctx, e.Config.Project.ID, actorID)
+					if err != nil {
+						return err
+					}
+					return printJSONOrTable(map[string]int{"marked": marked})
+				}
+				id, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid notification id %q", args[0])
+				}
+				n, err := e.ReadNotification(ctx, e.Config.Project.ID, id, actorID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(n)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "mark every unread notification read")
+	return cmd
+}
+
+func inboxEmailCmd() *cobra.Command {
+	var email string
+	var events []string
+	cmd := &cobra.Command{
+		Use:   "email",
+		Short: "Show or set where email notification channels mail you",
+		Long:  "Without flags, shows your email preferences. --email and --events replace them: email channels then mail you the events you opted in to (" + strings.Join(config.EmailEvents, ", ") + ") in the projects where you hold a role. --email \"\" stops the mails.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				actorID := viper.GetString("actor-id")
+				prefs, err := e.Repo.GetEmailPreferences(ctx, actorID)
+				if errors.Is(err, repo.ErrNotFound) {
+					prefs, err = domain.EmailPreferences{ActorID: actorID, Events: []string{}}, nil
+				}
+				if err != nil {
+					return err
+				}
+				if !cmd.Flags().Changed("email") && !cmd.Flags().Changed("events") {
+					return printJSONOrTable(prefs)
+				}
+				if cmd.Flags().Changed("email") {
+					prefs.Email = email
+				}
+				if cmd.Flags().Changed("events") {
+					prefs.Events = events
+				}
+				prefs, err = e.SetEmailPreferences(ctx, prefs)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(prefs)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "address to mail; empty stops the mails")
+	cmd.Flags().StringSliceVar(&events, "events", nil, "events to be mailed about (comma-separated)")
+	return cmd
+}
+
+func inboxCollectCmd() *cobra.Command {
+	var leaseWarning time.Duration
+	cmd := &cobra.Command{
+		Use:   "collect",
+		Short: "Collect the project's notifications once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				added, err := e.CollectNotifications(ctx, e.Config.Project.ID, leaseWarning)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(map[string]int{"added": added})
+			})
+		},
+	}
+	cmd.Flags().DurationVar(&leaseWarning, "lease-warning", engine.DefaultLeaseWarning, "notify lease holders this long before their lease expires (0 disables)")
+	return cmd
+}
+
+func secretCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Rotate the project's webhook secrets, API tokens and signing keys",
+		Long:  "Project secrets are versioned. Rotating one creates a new version and keeps the earlier live versions valid for --overlap, so webhook senders, API clients and evidence verifiers can switch over without downtime.",
+	}
+	cmd.AddCommand(secretListCmd())
+	cmd.AddCommand(secretRotateCmd())
+	cmd.AddCommand(secretRevokeCmd())
+	return cmd
+}
+
+func secretListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List secret versions, without their values",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				secrets, err := e.Repo.ListProjectSecrets(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(secrets)
+			})
+		},
+	}
+}
+
+func secretRotateCmd() *cobra.Command {
+	var kind, name string
+	var overlap time.Duration
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Create the next version of a secret; the value is printed once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.RotateSecret(ctx, e.Config.Project.ID, kind, name, overlap, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&kind, "kind", "", "webhook, api_token or signing")
+	cmd.Flags().StringVar(&name, "name", "", "provider for webhook secrets, actor for API tokens")
+	cmd.Flags().DurationVar(&overlap, "overlap", engine.DefaultSecretOverlap, "how long earlier versions stay valid (0 retires them at once)")
+	return cmd
+}
+
+func secretRevokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Retire a secret version immediately",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				s, err := e.RevokeSecret(ctx, e.Config.Project.ID, args[0], viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(s)
+			})
+		},
+	}
+}
+
+// inboxLoop collects the notifications of every project each interval until ctx is done.
+func inboxLoop(ctx context.Context, e engine.Engine, interval, leaseWarning time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.CollectAllNotifications(ctx, leaseWarning); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "inbox: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deferralLoop ends the deferrals that have passed each interval until ctx is done.
+func deferralLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.WakeDeferredTasks(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "deferrals: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func expireGrantsLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.ExpireRoleGrants(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "rbac: expire grants: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func grantQueuedLeasesLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.GrantQueuedLeases(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "lease: grant queued leases: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func iterationCmd() *cobra.Command {
+	iter := &cobra.Command{
+		Use:   "iteration",
 		Short: "Manage iterations",
-		Long:  "Iterations are mini-adventures for the project: pending -> running -> delivered -> validated/rejected. Validation can require a specific attestation kind from config.",
+		Long:  "Iterations are mini-adventures for the project: pending -> running -> delivered -> validated/rejected. Validation can require a specific attestation kind from config, and every key result of the iteration must be met.",
+	}
+	iter.AddCommand(iterationCreateCmd())
+	iter.AddCommand(iterationListCmd())
+	iter.AddCommand(iterationStatusCmd())
+	iter.AddCommand(iterationCarryOverCmd())
+	iter.AddCommand(iterationFreezeCmd(true))
+	iter.AddCommand(iterationFreezeCmd(false))
+	iter.AddCommand(iterationKeyResultCmd())
+	return iter
+}
+
+func iterationCreateCmd() *cobra.Command {
+	var it domain.Iteration
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create iteration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if it.ProjectID == "" {
+					it.ProjectID = e.Config.Project.ID
+				}
+				it.Status = "pending"
+				res, err := e.CreateIteration(ctx, it, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&it.ID, "id", "", "iteration id")
+	cmd.Flags().StringVar(&it.ProjectID, "project", "", "project id")
+	cmd.Flags().StringVar(&it.Goal, "goal", "", "goal")
+	_ = cmd.MarkFlagRequired("id")
+	_ = cmd.MarkFlagRequired("goal")
+	return cmd
+}
+
+func iterationListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List iterations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				items, err := e.Repo.ListIterations(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+	return cmd
+}
+
+func iterationStatusCmd() *cobra.Command {
+	var status string
+	cmd := &cobra.Command{
+		Use:   "set-status <id>",
+		Short: "Update iteration status",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				it, err := e.SetIterationStatus(ctx, id, status, viper.GetString("actor-id"), viper.GetBool("force"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(it)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&status, "status", "", "new status")
+	_ = cmd.MarkFlagRequired("status")
+	return cmd
+}
+
+func iterationCarryOverCmd() *cobra.Command {
+	var target, freezeOverride string
+	cmd := &cobra.Command{
+		Use:   "carry-over <id>",
+		Short: "Move unfinished tasks to another iteration or the backlog",
+		Long:  "Move every task of the iteration that is neither done nor canceled into --to, or to the backlog when --to is omitted. Both iterations record the carried-over counts.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := e.CarryOverIteration(ctx, args[0], target, freezeOverride, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&target, "to", "", "target iteration id (default: backlog)")
+	cmd.Flags().StringVar(&freezeOverride, "freeze-override", "", "reason for carrying tasks into a frozen iteration (needs iteration.freeze.override)")
+	return cmd
+}
+
+func iterationFreezeCmd(frozen bool) *cobra.Command {
+	use, short, long := "freeze <id>", "Freeze a running iteration's scope", "While a running iteration is frozen, tasks cannot be added to it and its tasks' validation policies cannot drop requirements, unless an actor holding iteration.freeze.override gives a --freeze-override reason."
+	if !frozen {
+		use, short, long = "unfreeze <id>", "Unfreeze an iteration's scope", ""
+	}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				it, err := e.SetIterationFrozen(ctx, args[0], frozen, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(it)
+			})
+		},
+	}
+	return cmd
+}
+
+func iterationKeyResultCmd() *cobra.Command {
+	var (
+		p                     engine.KeyResultPatch
+		target, current       float64
+		direction, sourceKind string
+	)
+	cmd := &cobra.Command{
+		Use:   "key-result <id>",
+		Short: "Add, change or remove an iteration key result",
+		Long:  "Key results measure the iteration goal: --metric names one, --target sets the value to reach and --direction whether current must rise to it (increase) or fall to it (decrease). With --source-kind, each attestation of that kind on the iteration sets current from its payload field named after the metric, else value. Validation requires every key result to be met.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			if flags.Changed("target") {
+				p.Target = &target
+			}
+			if flags.Changed("current") {
+				p.Current = &current
+			}
+			if flags.Changed("direction") {
+				p.Direction = &direction
+			}
+			if flags.Changed("source-kind") {
+				p.SourceKind = &sourceKind
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				it, err := e.UpdateIterationKeyResults(ctx, args[0], []engine.KeyResultPatch{p}, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(it)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&p.Metric, "metric", "", "metric name")
+	cmd.Flags().Float64Var(&target, "target", 0, "value to reach (required for a new metric)")
+	cmd.Flags().Float64Var(&current, "current", 0, "current value")
+	cmd.Flags().StringVar(&direction, "direction", "", "increase (default) or decrease")
+	cmd.Flags().StringVar(&sourceKind, "source-kind", "", "attestation kind that sets the current value")
+	cmd.Flags().BoolVar(&p.Remove, "remove", false, "remove the key result")
+	_ = cmd.MarkFlagRequired("metric")
+	return cmd
+}
+
+func capabilitiesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capabilities",
+		Short: "Register what an actor can work on",
+		Long:  "Capabilities (e.g. golang, frontend) route work: tasks declaring required capabilities only show up in the ready queue of actors offering all of them.",
+	}
+	cmd.AddCommand(capabilitiesSetCmd())
+	cmd.AddCommand(capabilitiesShowCmd())
+	return cmd
+}
+
+func capabilitiesSetCmd() *cobra.Command {
+	var target string
+	cmd := &cobra.Command{
+		Use:   "set [capability...]",
+		Short: "Replace an actor's capabilities (none clears them)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				caps, err := e.SetActorCapabilities(ctx, e.Config.Project.ID, target, args, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(caps)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&target, "actor", "", "actor to update (default: yourself)")
+	return cmd
+}
+
+func capabilitiesShowCmd() *cobra.Command {
+	var target string
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show an actor's capabilities",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if target == "" {
+					target = viper.GetString("actor-id")
+				}
+				caps, err := e.Repo.ListActorCapabilities(ctx, e.Config.Project.ID, target)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(caps)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&target, "actor", "", "actor to show (default: yourself)")
+	return cmd
+}
+
+func viewCmd() *cobra.Command {
+	view := &cobra.Command{
+		Use:   "view",
+		Short: "Manage saved task views",
+		Long:  "Views are named task filters saved in the project. Project views are shared with members (optionally only some roles); private views are visible to their owner only. Use $me as assignee to match whoever runs the view.",
+	}
+	view.AddCommand(viewCreateCmd())
+	view.AddCommand(viewListCmd())
+	view.AddCommand(viewRunCmd())
+	view.AddCommand(viewDeleteCmd())
+	return view
+}
+
+func viewCreateCmd() *cobra.Command {
+	var v domain.View
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Save a task view",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				v.Name = args[0]
+				v.ProjectID = e.Config.Project.ID
+				created, err := e.CreateView(ctx, v, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(created)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&v.Description, "description", "", "description")
+	cmd.Flags().StringVar(&v.Filters.Status, "status", "", "status filter")
+	cmd.Flags().StringVar(&v.Filters.Type, "type", "", "type filter")
+	cmd.Flags().StringVar(&v.Filters.IterationID, "iteration", "", "iteration filter")
+	cmd.Flags().StringVar(&v.Filters.ParentID, "parent", "", "parent task id")
+	cmd.Flags().StringVar(&v.Filters.AssigneeID, "assignee-id", "", "assignee filter ($me for the caller)")
+	cmd.Flags().StringVar(&v.Visibility, "visibility", "project", "project or private")
+	cmd.Flags().StringSliceVar(&v.Roles, "role", nil, "restrict a project view to these roles")
+	return cmd
+}
+
+func viewListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List views visible to you",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				views, err := e.VisibleViews(ctx, e.Config.Project.ID, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(views)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"ID", "Name", "Visibility", "Owner"})
+				for _, v := range views {
+					tw.AppendRow(table.Row{v.ID, v.Name, v.Visibility, v.OwnerID})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func viewRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <id>",
+		Short: "List tasks matching a view",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				actorID := viper.GetString("actor-id")
+				v, err := e.GetVisibleView(ctx, e.Config.Project.ID, args[0], actorID)
+				if err != nil {
+					return err
+				}
+				tasks, err := e.Repo.ListTasks(ctx, engine.ViewTaskFilters(v, actorID))
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(tasks)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"ID", "Title", "Status", "Type"})
+				for _, t := range tasks {
+					tw.AppendRow(table.Row{t.ID, t.Title, t.Status, t.Type})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func viewDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <id>",
+		Short: "Delete a view",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.DeleteView(ctx, e.Config.Project.ID, args[0], viper.GetString("actor-id"))
+			})
+		},
+	}
+	return cmd
+}
+
+func dbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Maintain the workspace database",
+		Long:  "Operator tools for long-lived workspace files: reclaim free pages, check the SQLite file for corruption, find and repair orphaned rows, and back it up to or restore it from the backup target of workline.yml. Vacuum, integrity and consistency are the same operations as POST /v0/admin/db/vacuum, GET /v0/admin/db/integrity and /v0/admin/consistency.",
+	}
+	cmd.AddCommand(dbVacuumCmd())
+	cmd.AddCommand(dbIntegrityCmd())
+	cmd.AddCommand(dbConsistencyCmd())
+	cmd.AddCommand(dbIndexFieldsCmd())
+	cmd.AddCommand(dbBackupCmd())
+	cmd.AddCommand(dbGenerationsCmd())
+	cmd.AddCommand(dbRestoreCmd())
+	return cmd
+}
+
+func dbBackupCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "backup",
+		Short: "Ship a full copy of the database to the backup target",
+		Long:  "Start a new backup generation from a copy of the database. wl serve does this by itself and then ships every transaction continuously; use this command for workspaces written only through the CLI.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				replicator, err := backup.New(e.DB, db.Path(viper.GetString("workspace")), e.Config.Backup)
+				if err != nil {
+					return err
+				}
+				pos, err := replicator.Sync(ctx)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(pos)
+			})
+		},
+	}
+}
+
+// backupConfig reads the backup target from a config file rather than the database, which
+// may be the thing being restored.
+func backupConfig(path string) (config.Backup, error) {
+	if path == "" {
+		path = config.Path(viper.GetString("workspace"))
+	}
+	cfg, err := config.FromFile(path)
+	if err != nil {
+		return config.Backup{}, err
+	}
+	return cfg.Backup, nil
+}
+
+func dbGenerationsCmd() *cobra.Command {
+	var configPath string
+	cmd := &cobra.Command{
+		Use:   "generations",
+		Short: "List backup generations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := backupConfig(configPath)
+			if err != nil {
+				return err
+			}
+			store, err := blob.OpenObjects(cfg)
+			if err != nil {
+				return err
+			}
+			gens, err := backup.Generations(cmd.Context(), store)
+			if err != nil {
+				return err
+			}
+			return printJSONOrTable(gens)
+		},
+	}
+	cmd.Flags().StringVar(&configPath, "config", "", "config file with the backup target (default workline.yml in the workspace)")
+	return cmd
+}
+
+func dbRestoreCmd() *cobra.Command {
+	var configPath, generation, to string
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Rebuild the database from the backup target",
+		Long:  "Download the latest backup generation (or --generation) and replay its transactions into a new database file, checked for integrity. Restores into the workspace by default and refuses to replace an existing file without --force.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := backupConfig(configPath)
+			if err != nil {
+				return err
+			}
+			store, err := blob.OpenObjects(cfg)
+			if err != nil {
+				return err
+			}
+			if to == "" {
+				workspace := viper.GetString("workspace")
+				if _, err := db.EnsureWorkspace(workspace); err != nil {
+					return err
+				}
+				to = db.Path(workspace)
+			}
+			res, err := backup.Restore(cmd.Context(), store, to, generation, viper.GetBool("force"))
+			if err != nil {
+				return err
+			}
+			if err := printJSONOrTable(res); err != nil {
+				return err
+			}
+			if !res.Complete {
+				return errors.New("a backup segment is missing; the database was restored up to the gap")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&configPath, "config", "", "config file with the backup target (default workline.yml in the workspace)")
+	cmd.Flags().StringVar(&generation, "generation", "", "generation to restore (default the latest)")
+	cmd.Flags().StringVar(&to, "to", "", "database file to create (default the workspace database)")
+	return cmd
+}
+
+func dbVacuumCmd() *cobra.Command {
+	var full bool
+	var pages int
+	cmd := &cobra.Command{
+		Use:   "vacuum",
+		Short: "Reclaim free pages",
+		Long:  "Release free pages incrementally (default), or rebuild the file with --full. A full vacuum also switches the database to incremental auto-vacuum, which incremental runs require.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := db.Vacuum(ctx, e.DB, full, pages)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&full, "full", false, "rebuild the whole file")
+	cmd.Flags().IntVar(&pages, "pages", 0, "maximum pages to release incrementally (0 = all)")
+	return cmd
+}
+
+func dbIndexFieldsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "index-fields",
+		Short: "Index the custom fields task types list as indexed",
+		Long:  "Add a generated column with an index for each custom field listed under task_types.<type>.indexed in the workspace config or any stored project config. Storing a config does not change the schema; run this after adding indexed fields. Columns are shared by all projects and capped, and existing ones are kept.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				fields, err := e.IndexCustomFields(ctx)
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(fields)
+				}
+				fmt.Printf("Indexed %d custom fields: %s\n", len(fields), strings.Join(fields, ", "))
+				return nil
+			})
+		},
+	}
+}
+
+func dbIntegrityCmd() *cobra.Command {
+	var quick bool
+	cmd := &cobra.Command{
+		Use:   "integrity",
+		Short: "Check the database for corruption",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				res, err := db.CheckIntegrity(ctx, e.DB, quick)
+				if err != nil {
+					return err
+				}
+				if err := printJSONOrTable(res); err != nil {
+					return err
+				}
+				if !res.OK {
+					return errors.New("integrity check failed")
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&quick, "quick", false, "run quick_check instead of the full integrity_check")
+	return cmd
+}
+
+func dbConsistencyCmd() *cobra.Command {
+	var repair bool
+	var checks []string
+	cmd := &cobra.Command{
+		Use:   "consistency",
+		Short: "Find orphaned and dangling rows",
+		Long:  "Report tasks whose parent or iteration is missing, dependencies on missing tasks, attestations on missing entities, and leases whose task is missing or finished. --repair applies the repair named by each issue (limited to --check when given) and records it as a consistency.repaired event.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				var (
+					res engine.ConsistencyReport
+					err error
+				)
+				if repair {
+					res, err = e.RepairConsistency(ctx, checks, viper.GetString("actor-id"))
+				} else {
+					res, err = e.CheckConsistency(ctx)
+				}
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(res)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				tw.AppendHeader(table.Row{"Check", "Table", "Row", "Ref", "Detail", "Repair"})
+				for _, is := range res.Repaired {
+					tw.AppendRow(table.Row{is.Check, is.Table, is.RowID, is.Ref, is.Detail, is.Repair + " (done)"})
+				}
+				for _, is := range res.Issues {
+					tw.AppendRow(table.Row{is.Check, is.Table, is.RowID, is.Ref, is.Detail, is.Repair})
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&repair, "repair", false, "repair the issues found")
+	cmd.Flags().StringSliceVar(&checks, "check", nil, "checks to repair (task_parent, task_iteration, task_dependency, attestation_entity, lease); all when omitted")
+	return cmd
+}
+
+func consistencyLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		res, err := e.CheckConsistency(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "consistency: check: %v\n", err)
+		} else if len(res.Issues) > 0 {
+			fmt.Fprintf(os.Stderr, "consistency: %d orphaned or dangling rows; see GET /v0/admin/consistency\n", len(res.Issues))
+		}
+	}
+}
+
+func evidenceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "evidence",
+		Short: "Check exported evidence bundles",
+	}
+	cmd.AddCommand(evidenceVerifyCmd())
+	return cmd
+}
+
+func evidenceVerifyCmd() *cobra.Command {
+	var keyID string
+	cmd := &cobra.Command{
+		Use:   "verify <file>",
+		Short: "Verify the signature of an evidence bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			sig, err := evidence.Verify(data)
+			if err != nil {
+				return err
+			}
+			if keyID != "" && sig.KeyID != keyID {
+				return fmt.Errorf("bundle signed by %s, expected %s", sig.KeyID, keyID)
+			}
+			fmt.Printf("Signature OK (%s key %s)\n", sig.Algorithm, sig.KeyID)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&keyID, "key-id", "", "require the bundle to be signed by this key id")
+	return cmd
+}
+
+func reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate project reports",
+	}
+	cmd.AddCommand(reportComplianceCmd())
+	cmd.AddCommand(reportUsageCmd())
+	return cmd
+}
+
+func reportUsageCmd() *cobra.Command {
+	var from, to, actor string
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show daily API calls per actor and the quotas applying to them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, end, err := engine.ParsePeriod(from, to, time.Now())
+			if err != nil {
+				return err
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				rep, err := e.Usage(ctx, e.Config.Project.ID, actor, start, end)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(rep)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "first day (RFC3339 or YYYY-MM-DD); defaults to 30 days before --to")
+	cmd.Flags().StringVar(&to, "to", "", "period end (RFC3339, exclusive) or last day included (YYYY-MM-DD); defaults to today")
+	cmd.Flags().StringVar(&actor, "actor", "", "only this actor")
+	return cmd
+}
+
+func reportComplianceCmd() *cobra.Command {
+	var from, to, format, out string
+	cmd := &cobra.Command{
+		Use:   "compliance",
+		Short: "Map attestations to the controls declared under compliance.controls",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "csv" {
+				return fmt.Errorf("--format must be json or csv")
+			}
+			start, end, err := engine.ParsePeriod(from, to, time.Now())
+			if err != nil {
+				return err
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				rep, err := e.ComplianceReport(ctx, e.Config.Project.ID, start, end)
+				if err != nil {
+					return err
+				}
+				w := io.Writer(os.Stdout)
+				if out != "" {
+					f, err := os.Create(out)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					w = f
+				}
+				if format == "csv" {
+					return rep.WriteCSV(w)
+				}
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(rep)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "period start (RFC3339 or YYYY-MM-DD); defaults to 30 days before --to")
+	cmd.Flags().StringVar(&to, "to", "", "period end (RFC3339, exclusive) or last day included (YYYY-MM-DD); defaults to today")
+	cmd.Flags().StringVar(&format, "format", "json", "output format: json or csv")
+	cmd.Flags().StringVar(&out, "out", "", "write the report to this file instead of stdout")
+	return cmd
+}
+
+func configCmd() *cobra.Command {
+	cfg := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect project config",
+		Long:  "Config is the rulebook (stored in DB): project id/kind, attestation catalog, and policy presets/defaults that decide which proof is needed. Import from workline.yml if desired.",
+	}
+	cfg.AddCommand(configShowCmd())
+	cfg.AddCommand(configValidateCmd())
+	return cfg
+}
+
+func configShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show loaded config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return printJSONOrTable(e.Config)
+			})
+		},
+	}
+	return cmd
+}
+
+func configValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate stored config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.Config.Validate()
+			})
+			if viper.GetBool("json") {
+				return printJSON(map[string]any{"ok": err == nil, "error": fmt.Sprint(err)})
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println("config OK")
+			return nil
+		},
+	}
+	return cmd
+}
+
+func decisionCmd() *cobra.Command {
+	dec := &cobra.Command{
+		Use:   "decision",
+		Short: "Manage decisions",
+		Long:  "Decisions capture the important choices, who decided, and why—so future you knows the reasoning.",
+	}
+	dec.AddCommand(decisionCreateCmd())
+	dec.AddCommand(decisionListCmd())
+	dec.AddCommand(decisionShowCmd())
+	return dec
+}
+
+func decisionListCmd() *cobra.Command {
+	var f repo.DecisionFilters
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List decisions, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if f.ProjectID == "" {
+					f.ProjectID = e.Config.Project.ID
+				}
+				items, err := e.Repo.ListDecisions(ctx, f)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&f.ProjectID, "project", "", "project id")
+	cmd.Flags().StringVar(&f.DeciderID, "decider-id", "", "filter by decider")
+	cmd.Flags().StringVar(&f.Status, "status", "", "filter by status (proposed, accepted, superseded, rejected)")
+	cmd.Flags().StringVar(&f.CreatedFrom, "from", "", "created at or after (RFC3339)")
+	cmd.Flags().StringVar(&f.CreatedTo, "to", "", "created before (RFC3339)")
+	cmd.Flags().StringVar(&f.Query, "search", "", "text search over title, decision, context, rationale and alternatives")
+	cmd.Flags().IntVar(&f.Limit, "limit", 50, "max results")
+	return cmd
+}
+
+func decisionShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a decision",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				d, err := e.Repo.GetDecision(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(d)
+			})
+		},
+	}
+	return cmd
+}
+
+func decisionCreateCmd() *cobra.Command {
+	var d domain.Decision
+	var rationale []string
+	var alternatives []string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create decision",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d.RationaleJSON = toJSONArray(rationale)
+			d.AlternativesJSON = toJSONArray(alternatives)
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if d.ProjectID == "" {
+					d.ProjectID = e.Config.Project.ID
+				}
+				res, err := e.CreateDecision(ctx, d, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&d.ID, "id", "", "decision id")
+	cmd.Flags().StringVar(&d.ProjectID, "project", "", "project id")
+	cmd.Flags().StringVar(&d.Title, "title", "", "title")
+	cmd.Flags().StringVar(&d.Decision, "decision", "", "decision text")
+	cmd.Flags().StringArrayVar(&rationale, "rationale", []string{}, "rationale entries")
+	cmd.Flags().StringArrayVar(&alternatives, "alternatives", []string{}, "alternative entries")
+	cmd.Flags().StringVar(&d.ContextJSON, "context-json", "", "context JSON")
+	cmd.Flags().StringVar(&d.DeciderID, "decider-id", "", "decider id")
+	cmd.Flags().StringVar(&d.Status, "status", "", "decision status (proposed, accepted, superseded, rejected; default accepted)")
+	_ = cmd.MarkFlagRequired("id")
+	_ = cmd.MarkFlagRequired("title")
+	_ = cmd.MarkFlagRequired("decision")
+	_ = cmd.MarkFlagRequired("decider-id")
+	return cmd
+}
+
+func attestCmd() *cobra.Command {
+	a := &cobra.Command{
+		Use:   "attest",
+		Short: "Manage attestations",
+		Long:  "Attestations are proof stickers (ci.passed, review.approved, acceptance.passed, etc.) attached to tasks or iterations. Policies check these before letting work finish.",
+	}
+	a.AddCommand(attestAddCmd())
+	a.AddCommand(attestListCmd())
+	a.AddCommand(attestRequiredByCmd())
+	a.AddCommand(attestFreshnessCmd())
+	a.AddCommand(attestBulkCmd())
+	a.AddCommand(attestBlobCmd())
+	return a
+}
+
+func artifactCmd() *cobra.Command {
+	a := &cobra.Command{
+		Use:   "artifact",
+		Short: "Manage evidence files",
+		Long:  "Artifacts are uploaded evidence files (logs, screenshots, coverage reports). Cite one from an attestation payload or work outcomes as {\"$artifact\": \"<id>\"}.",
+	}
+	a.AddCommand(artifactUploadCmd())
+	a.AddCommand(artifactListCmd())
+	a.AddCommand(artifactGetCmd())
+	return a
+}
+
+func artifactUploadCmd() *cobra.Command {
+	var opts engine.ArtifactUploadOptions
+	var file string
+	cmd := &cobra.Command{
+		Use:   "upload",
+		Short: "Upload an evidence file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			opts.Data = data
+			opts.ActorID = viper.GetString("actor-id")
+			if opts.Name == "" {
+				opts.Name = filepath.Base(file)
+			}
+			if opts.MediaType == "" {
+				opts.MediaType = mime.TypeByExtension(filepath.Ext(file))
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if opts.ProjectID == "" {
+					opts.ProjectID = e.Config.Project.ID
+				}
+				res, err := e.UploadArtifact(ctx, opts)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(res)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&opts.ProjectID, "project", "", "project id")
+	cmd.Flags().StringVar(&file, "file", "", "path of the file to upload")
+	cmd.Flags().StringVar(&opts.Name, "name", "", "artifact name (defaults to the file name)")
+	cmd.Flags().StringVar(&opts.MediaType, "media-type", "", "media type (defaults from the file extension)")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func artifactListCmd() *cobra.Command {
+	var projectID string
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List artifacts, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if projectID == "" {
+					projectID = e.Config.Project.ID
+				}
+				items, err := e.Repo.ListArtifacts(ctx, projectID, limit, "", "")
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&projectID, "project", "", "project id")
+	cmd.Flags().IntVar(&limit, "limit", 50, "max results")
+	return cmd
+}
+
+func artifactGetCmd() *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "get <id>",
+		Short: "Show artifact metadata, or write its content with --out (- for stdout)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				a, err := e.Repo.GetArtifact(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				if out == "" {
+					return printJSONOrTable(a)
+				}
+				data, err := e.ArtifactContent(ctx, a)
+				if err != nil {
+					return err
+				}
+				if out == "-" {
+					_, err = os.Stdout.Write(data)
+					return err
+				}
+				return os.WriteFile(out, data, 0o644)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "write content to this path")
+	return cmd
+}
+
+func attestBulkCmd() *cobra.Command {
+	var projectID, file string
+	var atomic bool
+	cmd := &cobra.Command{
+		Use:   "bulk",
+		Short: "Add many attestations in one transaction from a JSON array of {id, entity_kind, entity_id, kind, ts, payload}",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			var items []struct {
+				ID         string          `json:"id"`
+				EntityKind string          `json:"entity_kind"`
+				EntityID   string          `json:"entity_id"`
+				Kind       string          `json:"kind"`
+				TS         string          `json:"ts"`
+				Payload    json.RawMessage `json:"payload"`
+			}
+			if err := json.Unmarshal(data, &items); err != nil {
+				return fmt.Errorf("parse %s: %w", file, err)
+			}
+			atts := make([]domain.Attestation, 0, len(items))
+			for _, item := range items {
+				atts = append(atts, domain.Attestation{ID: item.ID, EntityKind: item.EntityKind, EntityID: item.EntityID, Kind: item.Kind, TS: item.TS, PayloadJSON: string(item.Payload)})
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if projectID == "" {
+					projectID = e.Config.Project.ID
+				}
+				out, err := e.AddAttestations(ctx, projectID, atts, viper.GetString("actor-id"), atomic)
+				if err != nil {
+					return err
+				}
+				for _, r := range out.Results {
+					switch {
+					case r.Err != nil:
+						fmt.Printf("%d\t%s\t%v\n", r.Index, r.Status, r.Err)
+					case r.Status == engine.BulkCreated:
+						fmt.Printf("%d\t%s\t%s\n", r.Index, r.Status, r.Attestation.ID)
+					default:
+						fmt.Printf("%d\t%s\n", r.Index, r.Status)
+					}
+				}
+				if out.Failed > 0 {
+					return fmt.Errorf("%d of %d attestations failed", out.Failed, len(out.Results))
+				}
+				return nil
+			})
+		},
 	}
-	iter.AddCommand(iterationCreateCmd())
-	iter.AddCommand(iterationListCmd())
-	iter.AddCommand(iterationStatusCmd())
-	return iter
+	cmd.Flags().StringVar(&projectID, "project", "", "project id")
+	cmd.Flags().StringVar(&file, "file", "", "JSON file with the attestations")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "roll back every attestation when any fails")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
 }
 
-func iterationCreateCmd() *cobra.Command {
-	var it domain.Iteration
+func attestBlobCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "create",
-		Short: "Create iteration",
+		Use:   "blob <digest>",
+		Short: "Print an offloaded attestation payload (sha256:<hex> from its $blob reference)",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				if it.ProjectID == "" {
-					it.ProjectID = e.Config.Project.ID
+				data, err := e.Blobs.Get(ctx, args[0])
+				if err != nil {
+					return err
 				}
-				it.Status = "pending"
-				res, err := e.CreateIteration(ctx, it, viper.GetString("actor-id"))
+				_, err = os.Stdout.Write(data)
+				return err
+			})
+		},
+	}
+	return cmd
+}
+
+func attestAddCmd() *cobra.Command {
+	var att domain.Attestation
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add attestation",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			att.ActorID = viper.GetString("actor-id")
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if att.ProjectID == "" {
+					att.ProjectID = e.Config.Project.ID
+				}
+				res, err := e.AddAttestation(ctx, att, viper.GetString("actor-id"))
 				if err != nil {
 					return err
 				}
+				if msg := e.DeprecatedKindWarning(res.Kind); msg != "" {
+					fmt.Fprintln(os.Stderr, "warning:", msg)
+				}
 				return printJSONOrTable(res)
 			})
 		},
 	}
-	cmd.Flags().StringVar(&it.ID, "id", "", "iteration id")
-	cmd.Flags().StringVar(&it.ProjectID, "project", "", "project id")
-	cmd.Flags().StringVar(&it.Goal, "goal", "", "goal")
-	_ = cmd.MarkFlagRequired("id")
-	_ = cmd.MarkFlagRequired("goal")
+	cmd.Flags().StringVar(&att.ID, "id", "", "attestation id (optional; generated per the project id scheme if omitted)")
+	cmd.Flags().StringVar(&att.ProjectID, "project", "", "project id")
+	cmd.Flags().StringVar(&att.EntityKind, "entity-kind", "", "entity kind")
+	cmd.Flags().StringVar(&att.EntityID, "entity-id", "", "entity id")
+	cmd.Flags().StringVar(&att.Kind, "kind", "", "attestation kind")
+	cmd.Flags().StringVar(&att.PayloadJSON, "payload-json", "", "payload JSON")
+	_ = cmd.MarkFlagRequired("entity-kind")
+	_ = cmd.MarkFlagRequired("entity-id")
+	_ = cmd.MarkFlagRequired("kind")
 	return cmd
 }
 
-func iterationListCmd() *cobra.Command {
+func attestListCmd() *cobra.Command {
+	var f repo.AttestationFilters
 	cmd := &cobra.Command{
 		Use:   "list",
-		Short: "List iterations",
+		Short: "List attestations",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				items, err := e.Repo.ListIterations(ctx, e.Config.Project.ID)
+				if f.ProjectID == "" {
+					f.ProjectID = e.Config.Project.ID
+				}
+				items, err := e.Repo.ListAttestations(ctx, f)
 				if err != nil {
 					return err
 				}
@@ -697,249 +3587,582 @@ func iterationListCmd() *cobra.Command {
 			})
 		},
 	}
+	cmd.Flags().StringVar(&f.ProjectID, "project", "", "project id")
+	cmd.Flags().StringVar(&f.EntityKind, "entity-kind", "", "entity kind filter")
+	cmd.Flags().StringVar(&f.EntityID, "entity-id", "", "entity id filter")
+	cmd.Flags().StringVar(&f.Kind, "kind", "", "kind filter")
 	return cmd
 }
 
-func iterationStatusCmd() *cobra.Command {
-	var status string
+func attestRequiredByCmd() *cobra.Command {
+	var projectID string
 	cmd := &cobra.Command{
-		Use:   "set-status <id>",
-		Short: "Update iteration status",
+		Use:   "required-by <kind>",
+		Short: "List open tasks and iterations still missing an attestation kind",
+		Long:  "Show the backlog of pending proofs for one kind: open tasks whose policy requires it (alone or countersigned) and iterations whose validation needs it, oldest first. Waived requirements are left out.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			id := args[0]
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				it, err := e.SetIterationStatus(ctx, id, status, viper.GetString("actor-id"), viper.GetBool("force"))
+				if projectID == "" {
+					projectID = e.Config.Project.ID
+				}
+				backlog, err := e.AttestationRequiredBy(ctx, projectID, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(backlog)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&projectID, "project", "", "project id")
+	return cmd
+}
+
+func attestFreshnessCmd() *cobra.Command {
+	var projectID string
+	cmd := &cobra.Command{
+		Use:   "freshness",
+		Short: "Flag tasks in review whose attestations are older than their freshness window",
+		Long:  "Check the tasks that have been in review for attestations.freshness.review_after against the windows under attestations.freshness.windows. Each newly stale set of attestations is recorded as a validation.stale event on its task. `wl serve` runs the check every --freshness-interval.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if projectID == "" {
+					projectID = e.Config.Project.ID
+				}
+				rep, err := e.CheckAttestationFreshness(ctx, projectID, viper.GetString("actor-id"))
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(rep)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&projectID, "project", "", "project id")
+	return cmd
+}
+
+// freshnessLoop checks attestation freshness in every project each interval until ctx is done.
+func freshnessLoop(ctx context.Context, e engine.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.CheckDueFreshness(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "freshness: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func logCmd() *cobra.Command {
+	log := &cobra.Command{
+		Use:   "log",
+		Short: "Event log",
+		Long:  "The diary of everything that happened: task changes, policy applications, leases, and more.",
+	}
+	log.AddCommand(logTailCmd())
+	log.AddCommand(logActivityCmd())
+	log.AddCommand(logAggregateCmd())
+	log.AddCommand(logVerifyCmd())
+	return log
+}
+
+func logVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the event hash chain",
+		Long:  "Walk the project's events oldest first and check every prev_hash/this_hash link. Exits with an error at the first broken link.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				report, err := e.VerifyEventChain(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				if err := printJSONOrTable(report); err != nil {
+					return err
+				}
+				if !report.Valid {
+					return fmt.Errorf("event chain broken at event %d: %s", report.BrokenAt, report.Reason)
+				}
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func logActivityCmd() *cobra.Command {
+	var n int
+	var since string
+	cmd := &cobra.Command{
+		Use:   "activity <actor-id>",
+		Short: "Show an actor's recent activity",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			actorID := args[0]
+			if since != "" {
+				ts, err := time.Parse(time.RFC3339, since)
+				if err != nil {
+					return fmt.Errorf("invalid --since: %w", err)
+				}
+				since = ts.UTC().Format(time.RFC3339)
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				events, err := e.Repo.ActorEventsFrom(ctx, n, 0, e.Config.Project.ID, actorID, since)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(events)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&n, "n", 20, "number of events")
+	cmd.Flags().StringVar(&since, "since", "", "only events at or after this RFC3339 timestamp")
+	return cmd
+}
+
+func logAggregateCmd() *cobra.Command {
+	var bucket, from, to string
+	var types []string
+	cmd := &cobra.Command{
+		Use:   "aggregate",
+		Short: "Count events per hour or day and type",
+		Long:  "Periods without events are listed with a zero total. --from and --to take RFC3339 timestamps or YYYY-MM-DD days; the default period is the last 30 days.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, end, err := engine.ParsePeriod(from, to, time.Now())
+			if err != nil {
+				return err
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				agg, err := e.AggregateEvents(ctx, e.Config.Project.ID, bucket, types, start, end)
+				if err != nil {
+					return err
+				}
+				if viper.GetBool("json") {
+					return printJSON(agg)
+				}
+				tw := table.NewWriter()
+				tw.SetOutputMirror(os.Stdout)
+				header := table.Row{"Start", "Total"}
+				for _, t := range agg.Types {
+					header = append(header, t)
+				}
+				tw.AppendHeader(header)
+				for _, b := range agg.Buckets {
+					if b.Total == 0 {
+						continue
+					}
+					row := table.Row{b.Start, b.Total}
+					for _, t := range agg.Types {
+						row = append(row, b.Counts[t])
+					}
+					tw.AppendRow(row)
+				}
+				tw.Render()
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&bucket, "bucket", "hour", "bucket size: hour or day")
+	cmd.Flags().StringSliceVar(&types, "type", nil, "only count this event type (repeatable)")
+	cmd.Flags().StringVar(&from, "from", "", "period start (RFC3339 or YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "period end, exclusive (RFC3339), or last day included (YYYY-MM-DD)")
+	return cmd
+}
+
+func rbacCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rbac",
+		Short: "RBAC management",
+	}
+	cmd.AddCommand(rbacWhoamiCmd())
+	cmd.AddCommand(rbacMembersCmd())
+	cmd.AddCommand(rbacAuthoritiesCmd())
+	cmd.AddCommand(rbacTeamCmd())
+	cmd.AddCommand(rbacGrantCmd())
+	cmd.AddCommand(rbacExpireGrantsCmd())
+	cmd.AddCommand(rbacRevokeCmd())
+	cmd.AddCommand(rbacAllowAttCmd())
+	cmd.AddCommand(rbacDenyAttCmd())
+	cmd.AddCommand(rbacBootstrapCmd())
+	cmd.AddCommand(rbacCertMapCmd())
+	cmd.AddCommand(rbacCertListCmd())
+	cmd.AddCommand(rbacCertUnmapCmd())
+	return cmd
+}
+
+// certIdentity turns the --cn/--san flags into a mapping kind and value. SANs are given as
+// dns:<name>, email:<address> or uri:<uri>.
+func certIdentity(cn, san string) (string, string, error) {
+	if (cn == "") == (san == "") {
+		return "", "", fmt.Errorf("exactly one of --cn or --san required")
+	}
+	if cn != "" {
+		return "cn", cn, nil
+	}
+	kind, value, ok := strings.Cut(san, ":")
+	if !ok || value == "" || (kind != "dns" && kind != "email" && kind != "uri") {
+		return "", "", fmt.Errorf("--san must be dns:<name>, email:<address> or uri:<uri>")
+	}
+	return kind, value, nil
+}
+
+func rbacCertMapCmd() *cobra.Command {
+	var cn, san, target, org string
+	cmd := &cobra.Command{
+		Use:   "cert-map",
+		Short: "Map a client certificate CN or SAN to an actor for mutual TLS",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, value, err := certIdentity(cn, san)
+			if err != nil {
+				return err
+			}
+			if target == "" {
+				return fmt.Errorf("--actor required")
+			}
+			return withRepo(cmd.Context(), func(ctx context.Context, r repo.Repo) error {
+				return r.UpsertClientCertMapping(ctx, domain.ClientCertMapping{Kind: kind, Value: value, ActorID: target, OrgID: org})
+			})
+		},
+	}
+	cmd.Flags().StringVar(&cn, "cn", "", "certificate subject common name")
+	cmd.Flags().StringVar(&san, "san", "", "subject alternative name as dns:<name>, email:<address> or uri:<uri>")
+	cmd.Flags().StringVar(&target, "actor", "", "actor id")
+	cmd.Flags().StringVar(&org, "org", "default-org", "organization id")
+	return cmd
+}
+
+func rbacCertListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cert-list",
+		Short: "List client certificate to actor mappings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withRepo(cmd.Context(), func(ctx context.Context, r repo.Repo) error {
+				items, err := r.ListClientCertMappings(ctx)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(items)
+			})
+		},
+	}
+}
+
+func rbacCertUnmapCmd() *cobra.Command {
+	var cn, san string
+	cmd := &cobra.Command{
+		Use:   "cert-unmap",
+		Short: "Remove a client certificate mapping",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, value, err := certIdentity(cn, san)
+			if err != nil {
+				return err
+			}
+			return withRepo(cmd.Context(), func(ctx context.Context, r repo.Repo) error {
+				ok, err := r.DeleteClientCertMapping(ctx, kind, value)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return repo.ErrNotFound
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&cn, "cn", "", "certificate subject common name")
+	cmd.Flags().StringVar(&san, "san", "", "subject alternative name as dns:<name>, email:<address> or uri:<uri>")
+	return cmd
+}
+
+func rbacWhoamiCmd() *cobra.Command {
+	var allProjects bool
+	cmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show current actor roles and permissions",
+		Long:  "--all-projects lists every project where the actor holds a role, directly or through a team, with its roles and permissions in each.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				if allProjects {
+					memberships, err := e.Repo.ListMemberships(ctx, viper.GetString("actor-id"), "", time.Now().UTC().Format(time.RFC3339))
+					if err != nil {
+						return err
+					}
+					return printJSONOrTable(memberships)
+				}
+				who, err := e.WhoAmI(ctx, e.Config.Project.ID, viper.GetString("actor-id"))
 				if err != nil {
 					return err
 				}
-				return printJSONOrTable(it)
+				return printJSONOrTable(who)
 			})
 		},
 	}
-	cmd.Flags().StringVar(&status, "status", "", "new status")
-	_ = cmd.MarkFlagRequired("status")
+	cmd.Flags().BoolVar(&allProjects, "all-projects", false, "list the actor's roles in every project")
 	return cmd
 }
 
-func configCmd() *cobra.Command {
-	cfg := &cobra.Command{
-		Use:   "config",
-		Short: "Inspect project config",
-		Long:  "Config is the rulebook (stored in DB): project id/kind, attestation catalog, and policy presets/defaults that decide which proof is needed. Import from workline.yml if desired.",
-	}
-	cfg.AddCommand(configShowCmd())
-	cfg.AddCommand(configValidateCmd())
-	return cfg
-}
-
-func configShowCmd() *cobra.Command {
+func rbacMembersCmd() *cobra.Command {
+	var limit int
+	var cursor string
 	cmd := &cobra.Command{
-		Use:   "show",
-		Short: "Show loaded config",
+		Use:   "members",
+		Short: "List actors with their active roles and effective permissions",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				return printJSONOrTable(e.Config)
+				members, err := e.Repo.ListMembers(ctx, e.Config.Project.ID, time.Now().UTC().Format(time.RFC3339), limit, cursor)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(members)
 			})
 		},
 	}
+	cmd.Flags().IntVar(&limit, "limit", 50, "max results")
+	cmd.Flags().StringVar(&cursor, "after", "", "list actors after this actor id")
 	return cmd
 }
 
-func configValidateCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "validate",
-		Short: "Validate stored config",
+func rbacAuthoritiesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "attestation-authorities",
+		Short: "Review who may record each attestation kind",
+		Long:  "List every attestation kind to role authority with the actors holding the role and the events that granted or took it away, followed by the attestations whose actor lacks authority for their kind under the current grants (expected to be empty).",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				return e.Config.Validate()
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				report, err := e.AttestationAuthorityReport(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(report)
 			})
-			if viper.GetBool("json") {
-				return printJSON(map[string]any{"ok": err == nil, "error": fmt.Sprint(err)})
-			}
-			if err != nil {
-				return err
-			}
-			fmt.Println("config OK")
-			return nil
 		},
 	}
-	return cmd
 }
 
-func decisionCmd() *cobra.Command {
-	dec := &cobra.Command{
-		Use:   "decision",
-		Short: "Manage decisions",
-		Long:  "Decisions capture the important choices, who decided, and why—so future you knows the reasoning.",
+func rbacTeamCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "team",
+		Short: "Manage teams, whose role grants apply to every member",
 	}
-	dec.AddCommand(decisionCreateCmd())
-	return dec
+	cmd.AddCommand(rbacTeamCreateCmd())
+	cmd.AddCommand(rbacTeamListCmd())
+	cmd.AddCommand(rbacTeamGetCmd())
+	cmd.AddCommand(rbacTeamUpdateCmd())
+	cmd.AddCommand(rbacTeamDeleteCmd())
+	cmd.AddCommand(rbacTeamAddMemberCmd())
+	cmd.AddCommand(rbacTeamRemoveMemberCmd())
+	cmd.AddCommand(rbacTeamGrantCmd())
+	cmd.AddCommand(rbacTeamRevokeCmd())
+	return cmd
 }
 
-func decisionCreateCmd() *cobra.Command {
-	var d domain.Decision
-	var rationale []string
-	var alternatives []string
+func rbacTeamCreateCmd() *cobra.Command {
+	var description string
 	cmd := &cobra.Command{
-		Use:   "create",
-		Short: "Create decision",
+		Use:   "create <team>",
+		Short: "Create team",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			d.RationaleJSON = toJSONArray(rationale)
-			d.AlternativesJSON = toJSONArray(alternatives)
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				if d.ProjectID == "" {
-					d.ProjectID = e.Config.Project.ID
-				}
-				res, err := e.CreateDecision(ctx, d, viper.GetString("actor-id"))
+				team, err := e.CreateTeam(ctx, e.Config.Project.ID, args[0], description, viper.GetString("actor-id"))
 				if err != nil {
 					return err
 				}
-				return printJSONOrTable(res)
+				return printJSONOrTable(team)
 			})
 		},
 	}
-	cmd.Flags().StringVar(&d.ID, "id", "", "decision id")
-	cmd.Flags().StringVar(&d.ProjectID, "project", "", "project id")
-	cmd.Flags().StringVar(&d.Title, "title", "", "title")
-	cmd.Flags().StringVar(&d.Decision, "decision", "", "decision text")
-	cmd.Flags().StringArrayVar(&rationale, "rationale", []string{}, "rationale entries")
-	cmd.Flags().StringArrayVar(&alternatives, "alternatives", []string{}, "alternative entries")
-	cmd.Flags().StringVar(&d.ContextJSON, "context-json", "", "context JSON")
-	cmd.Flags().StringVar(&d.DeciderID, "decider-id", "", "decider id")
-	_ = cmd.MarkFlagRequired("id")
-	_ = cmd.MarkFlagRequired("title")
-	_ = cmd.MarkFlagRequired("decision")
-	_ = cmd.MarkFlagRequired("decider-id")
+	cmd.Flags().StringVar(&description, "description", "", "what the team is for")
 	return cmd
 }
 
-func attestCmd() *cobra.Command {
-	a := &cobra.Command{
-		Use:   "attest",
-		Short: "Manage attestations",
-		Long:  "Attestations are proof stickers (ci.passed, review.approved, acceptance.passed, etc.) attached to tasks or iterations. Policies check these before letting work finish.",
+func rbacTeamListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List teams with their members and role grants",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				teams, err := e.Repo.ListTeams(ctx, e.Config.Project.ID)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(teams)
+			})
+		},
 	}
-	a.AddCommand(attestAddCmd())
-	a.AddCommand(attestListCmd())
-	return a
 }
 
-func attestAddCmd() *cobra.Command {
-	var att domain.Attestation
-	cmd := &cobra.Command{
-		Use:   "add",
-		Short: "Add attestation",
+func rbacTeamGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <team>",
+		Short: "Get team",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			att.ActorID = viper.GetString("actor-id")
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				if att.ProjectID == "" {
-					att.ProjectID = e.Config.Project.ID
-				}
-				res, err := e.AddAttestation(ctx, att, viper.GetString("actor-id"))
+				team, err := e.Repo.GetTeam(ctx, e.Config.Project.ID, args[0])
 				if err != nil {
 					return err
 				}
-				return printJSONOrTable(res)
+				return printJSONOrTable(team)
 			})
 		},
 	}
-	cmd.Flags().StringVar(&att.ProjectID, "project", "", "project id")
-	cmd.Flags().StringVar(&att.EntityKind, "entity-kind", "", "entity kind")
-	cmd.Flags().StringVar(&att.EntityID, "entity-id", "", "entity id")
-	cmd.Flags().StringVar(&att.Kind, "kind", "", "attestation kind")
-	cmd.Flags().StringVar(&att.PayloadJSON, "payload-json", "", "payload JSON")
-	_ = cmd.MarkFlagRequired("entity-kind")
-	_ = cmd.MarkFlagRequired("entity-id")
-	_ = cmd.MarkFlagRequired("kind")
-	return cmd
 }
 
-func attestListCmd() *cobra.Command {
-	var f repo.AttestationFilters
+func rbacTeamUpdateCmd() *cobra.Command {
+	var description string
 	cmd := &cobra.Command{
-		Use:   "list",
-		Short: "List attestations",
+		Use:   "update <team>",
+		Short: "Replace the team description",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				if f.ProjectID == "" {
-					f.ProjectID = e.Config.Project.ID
-				}
-				items, err := e.Repo.ListAttestations(ctx, f)
+				team, err := e.UpdateTeam(ctx, e.Config.Project.ID, args[0], description, viper.GetString("actor-id"))
 				if err != nil {
 					return err
 				}
-				return printJSONOrTable(items)
+				return printJSONOrTable(team)
 			})
 		},
 	}
-	cmd.Flags().StringVar(&f.ProjectID, "project", "", "project id")
-	cmd.Flags().StringVar(&f.EntityKind, "entity-kind", "", "entity kind filter")
-	cmd.Flags().StringVar(&f.EntityID, "entity-id", "", "entity id filter")
-	cmd.Flags().StringVar(&f.Kind, "kind", "", "kind filter")
+	cmd.Flags().StringVar(&description, "description", "", "what the team is for")
 	return cmd
 }
 
-func logCmd() *cobra.Command {
-	log := &cobra.Command{
-		Use:   "log",
-		Short: "Event log",
-		Long:  "The diary of everything that happened: task changes, policy applications, leases, and more.",
+func rbacTeamDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <team>",
+		Short: "Delete team; its members lose the roles held through it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.DeleteTeam(ctx, e.Config.Project.ID, args[0], viper.GetString("actor-id"))
+			})
+		},
 	}
-	log.AddCommand(logTailCmd())
-	return log
 }
 
-func rbacCmd() *cobra.Command {
+func rbacTeamAddMemberCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add-member <team> <actor>",
+		Short: "Add an actor to a team",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.AddTeamMember(ctx, e.Config.Project.ID, args[0], args[1], viper.GetString("actor-id"))
+			})
+		},
+	}
+}
+
+func rbacTeamRemoveMemberCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove-member <team> <actor>",
+		Short: "Remove an actor from a team",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.RemoveTeamMember(ctx, e.Config.Project.ID, args[0], args[1], viper.GetString("actor-id"))
+			})
+		},
+	}
+}
+
+func rbacTeamGrantCmd() *cobra.Command {
+	var role, expiresAt string
+	var ttl time.Duration
 	cmd := &cobra.Command{
-		Use:   "rbac",
-		Short: "RBAC management",
+		Use:   "grant-role <team>",
+		Short: "Grant role to every member of a team, optionally until --expires-at or for --ttl",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if role == "" {
+				return fmt.Errorf("--role required")
+			}
+			if ttl > 0 {
+				if expiresAt != "" {
+					return fmt.Errorf("--expires-at and --ttl are mutually exclusive")
+				}
+				expiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+			}
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				return e.GrantTeamRoleUntil(ctx, e.Config.Project.ID, viper.GetString("actor-id"), args[0], role, expiresAt)
+			})
+		},
 	}
-	cmd.AddCommand(rbacWhoamiCmd())
-	cmd.AddCommand(rbacGrantCmd())
-	cmd.AddCommand(rbacRevokeCmd())
-	cmd.AddCommand(rbacAllowAttCmd())
-	cmd.AddCommand(rbacDenyAttCmd())
-	cmd.AddCommand(rbacBootstrapCmd())
+	cmd.Flags().StringVar(&role, "role", "", "role id")
+	cmd.Flags().StringVar(&expiresAt, "expires-at", "", "grant lapses at this time (RFC3339)")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "grant lapses after this duration (e.g. 8h)")
 	return cmd
 }
 
-func rbacWhoamiCmd() *cobra.Command {
+func rbacTeamRevokeCmd() *cobra.Command {
+	var role string
 	cmd := &cobra.Command{
-		Use:   "whoami",
-		Short: "Show current actor roles and permissions",
+		Use:   "revoke-role <team>",
+		Short: "Revoke role from a team",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if role == "" {
+				return fmt.Errorf("--role required")
+			}
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				who, err := e.WhoAmI(ctx, e.Config.Project.ID, viper.GetString("actor-id"))
-				if err != nil {
-					return err
-				}
-				return printJSONOrTable(who)
+				return e.RevokeTeamRole(ctx, e.Config.Project.ID, viper.GetString("actor-id"), args[0], role)
 			})
 		},
 	}
+	cmd.Flags().StringVar(&role, "role", "", "role id")
 	return cmd
 }
 
 func rbacGrantCmd() *cobra.Command {
-	var target, role string
+	var target, role, expiresAt string
+	var ttl time.Duration
 	cmd := &cobra.Command{
 		Use:   "grant-role",
-		Short: "Grant role to actor",
+		Short: "Grant role to actor, optionally until --expires-at or for --ttl",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if target == "" || role == "" {
 				return fmt.Errorf("--actor and --role required")
 			}
+			if ttl > 0 {
+				if expiresAt != "" {
+					return fmt.Errorf("--expires-at and --ttl are mutually exclusive")
+				}
+				expiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+			}
 			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
-				return e.GrantRole(ctx, e.Config.Project.ID, viper.GetString("actor-id"), target, role)
+				return e.GrantRoleUntil(ctx, e.Config.Project.ID, viper.GetString("actor-id"), target, role, expiresAt)
 			})
 		},
 	}
 	cmd.Flags().StringVar(&target, "actor", "", "actor id")
 	cmd.Flags().StringVar(&role, "role", "", "role id")
+	cmd.Flags().StringVar(&expiresAt, "expires-at", "", "grant lapses at this time (RFC3339)")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "grant lapses after this duration (e.g. 8h)")
 	return cmd
 }
 
+func rbacExpireGrantsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "expire-grants",
+		Short: "Remove lapsed temporary role grants and record rbac.role_expired events",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEngine(cmd.Context(), func(ctx context.Context, e engine.Engine) error {
+				grants, err := e.ExpireRoleGrants(ctx)
+				if err != nil {
+					return err
+				}
+				return printJSONOrTable(grants)
+			})
+		},
+	}
+}
+
 func rbacRevokeCmd() *cobra.Command {
 	var target, role string
 	cmd := &cobra.Command{
@@ -974,7 +4197,7 @@ func rbacAllowAttCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&role, "role", "", "role id")
-	cmd.Flags().StringVar(&kind, "kind", "", "attestation kind")
+	cmd.Flags().StringVar(&kind, "kind", "", "attestation kind, or a pattern such as ci.* (exact kinds take precedence over patterns)")
 	return cmd
 }
 
@@ -993,7 +4216,7 @@ func rbacDenyAttCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&role, "role", "", "role id")
-	cmd.Flags().StringVar(&kind, "kind", "", "attestation kind")
+	cmd.Flags().StringVar(&kind, "kind", "", "attestation kind or pattern, as granted")
 	return cmd
 }
 
@@ -1059,7 +4282,12 @@ func rbacBootstrapCmd() *cobra.Command {
 }
 
 func serveCmd() *cobra.Command {
-	var addr, basePath string
+	var addr, basePath, tlsCert, tlsKey, clientCA, contract, jsonDecoding, messagesPath, evidenceKey, v0Sunset, otlpEndpoint, env string
+	var notifyInterval, statsInterval, metricsInterval, digestInterval, grantExpiryInterval, leaseQueueInterval, consistencyInterval, freshnessInterval, escalationInterval, deferInterval, inboxInterval, leaseWarning, cacheTTL, usageFlush, slowQuery, requestTimeout time.Duration
+	var rowBudget int
+	var readReplicas []string
+	var graphQL, requestLog, compress bool
+	var chaos server.ChaosConfig
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start HTTP API server",
@@ -1068,7 +4296,8 @@ func serveCmd() *cobra.Command {
 			if _, err := db.EnsureWorkspace(workspace); err != nil {
 				return err
 			}
-			conn, err := db.Open(db.Config{Workspace: workspace})
+			queryStats := db.NewQueryStats()
+			conn, err := db.Open(db.Config{Workspace: workspace, SlowQuery: slowQuery, Stats: queryStats})
 			if err != nil {
 				return err
 			}
@@ -1081,23 +4310,170 @@ func serveCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			e := engine.New(conn, cfg)
+			if err := cfg.UseEnvironment(env); err != nil {
+				return err
+			}
+			e := engine.New(conn, cfg).WithEnvironment(env)
+			if cacheTTL > 0 {
+				e = e.WithCache(cacheTTL)
+			}
+			if usageFlush > 0 {
+				e = e.WithUsageBuffer()
+			}
+			if len(readReplicas) > 0 {
+				version, err := migrate.Version(cmd.Context(), conn)
+				if err != nil {
+					return err
+				}
+				var replicas []*sql.DB
+				for _, path := range readReplicas {
+					replica, err := db.OpenReplica(db.Config{SlowQuery: slowQuery, Stats: queryStats}, path)
+					if err != nil {
+						return err
+					}
+					defer replica.Close()
+					replicaVersion, err := migrate.Version(cmd.Context(), replica)
+					if err != nil {
+						return fmt.Errorf("read replica %s: %w", path, err)
+					}
+					if replicaVersion != version {
+						return fmt.Errorf("read replica %s is at schema version %d but the primary is at %d; restore it again", path, replicaVersion, version)
+					}
+					replicas = append(replicas, replica)
+				}
+				e = e.WithReplicas(replicas...)
+			}
+			if e.Blobs, err = blob.Open(cfg.Blobs, workspace); err != nil {
+				return err
+			}
+			if cfg.Backup.Enabled() {
+				replicator, err := backup.New(conn, db.Path(workspace), cfg.Backup)
+				if err != nil {
+					return err
+				}
+				go replicator.Run(cmd.Context(), cfg.Backup.ShipEvery())
+				defer func() {
+					// Ship the last transactions before the database is closed.
+					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+					if _, err := replicator.Sync(ctx); err != nil {
+						fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+					}
+				}()
+			}
+			if evidenceKey == "" {
+				evidenceKey = evidence.DefaultKeyPath(workspace)
+			}
+			if e.Evidence, err = evidence.LoadOrCreate(evidenceKey); err != nil {
+				return err
+			}
 			authCfg := server.AuthConfig{JWTSecret: os.Getenv("WORKLINE_JWT_SECRET")}
 			if authCfg.JWTSecret == "" {
 				return fmt.Errorf("WORKLINE_JWT_SECRET is required for bearer auth")
 			}
-			handler, err := server.New(server.Config{Engine: e, BasePath: basePath, Auth: authCfg})
+			var messages server.MessageCatalog
+			if messagesPath != "" {
+				if messages, err = server.LoadMessageCatalog(messagesPath); err != nil {
+					return err
+				}
+			}
+			var sunset time.Time
+			if v0Sunset != "" {
+				if sunset, err = time.Parse(time.DateOnly, v0Sunset); err != nil {
+					return fmt.Errorf("invalid --v0-sunset: %w", err)
+				}
+			}
+			var requestLogger *slog.Logger
+			if requestLog {
+				requestLogger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+			}
+			handler, err := server.New(server.Config{Engine: e, BasePath: basePath, V0Sunset: sunset, Auth: authCfg, RowBudget: rowBudget, ContractValidation: contract, QueryStats: queryStats, SlowQuery: slowQuery, RequestTimeout: requestTimeout, JSONDecoding: jsonDecoding, Messages: messages, GraphQL: graphQL, RequestLog: requestLogger, Chaos: chaos, Compression: compress, CORS: cfg.HTTP.CORS, SecurityHeaders: cfg.HTTP.SecurityHeadersEnabled(), DisableDevLogin: !cfg.Effective().Auth.DevLoginEnabled()})
 			if err != nil {
 				return err
 			}
+			if chaos.Enabled() {
+				fmt.Fprintf(os.Stderr, "warning: chaos mode injects latency up to %s, failures at rate %v and lease races at rate %v; for testing clients only\n", chaos.Latency, chaos.ErrorRate, chaos.LeaseRaceRate)
+			}
 			srv := &http.Server{Addr: addr, Handler: handler}
+			if clientCA != "" {
+				if tlsCert == "" || tlsKey == "" {
+					return fmt.Errorf("--client-ca requires --tls-cert and --tls-key")
+				}
+				pem, err := os.ReadFile(clientCA)
+				if err != nil {
+					return err
+				}
+				pool := x509.NewCertPool()
+				if !pool.AppendCertsFromPEM(pem) {
+					return fmt.Errorf("no certificates found in %s", clientCA)
+				}
+				// Certificates are optional so bearer tokens and API keys keep working over TLS.
+				srv.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven, MinVersion: tls.VersionTLS12}
+			}
+			if usageFlush > 0 {
+				go usageFlushLoop(cmd.Context(), e, usageFlush)
+				defer func() {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					if err := e.FlushUsage(ctx); err != nil {
+						fmt.Fprintf(os.Stderr, "usage: %v\n", err)
+					}
+				}()
+			}
+			if statsInterval > 0 {
+				go recordStatsLoop(cmd.Context(), e, statsInterval)
+			}
+			if exporter := telemetry.FromEnv(os.Getenv); metricsInterval > 0 && (exporter != nil || otlpEndpoint != "") {
+				if exporter == nil {
+					exporter = &telemetry.Exporter{}
+				}
+				if otlpEndpoint != "" {
+					exporter.Endpoint = otlpEndpoint
+				}
+				go exportMetricsLoop(cmd.Context(), e, exporter, metricsInterval)
+			}
+			if digestInterval > 0 {
+				go digestLoop(cmd.Context(), e, digestInterval)
+			}
+			if grantExpiryInterval > 0 {
+				go expireGrantsLoop(cmd.Context(), e, grantExpiryInterval)
+			}
+			if leaseQueueInterval > 0 {
+				go grantQueuedLeasesLoop(cmd.Context(), e, leaseQueueInterval)
+			}
+			if consistencyInterval > 0 {
+				go consistencyLoop(cmd.Context(), e, consistencyInterval)
+			}
+			if freshnessInterval > 0 {
+				go freshnessLoop(cmd.Context(), e, freshnessInterval)
+			}
+			if escalationInterval > 0 {
+				go escalationLoop(cmd.Context(), e, escalationInterval)
+			}
+			if deferInterval > 0 {
+				go deferralLoop(cmd.Context(), e, deferInterval)
+			}
+			if inboxInterval > 0 {
+				go inboxLoop(cmd.Context(), e, inboxInterval, leaseWarning)
+			}
+			if notifyInterval > 0 {
+				dispatcher := &notify.Dispatcher{Repo: r, Client: &http.Client{Timeout: 10 * time.Second}}
+				go dispatcher.Run(cmd.Context(), notifyInterval)
+			}
 			go func() {
 				<-cmd.Context().Done()
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 				defer cancel()
 				srv.Shutdown(ctx)
 			}()
-			fmt.Printf("Serving Workline API on http://%s%s (OpenAPI at /openapi.json, Swagger UI at /docs)\n", addr, basePath)
+			if tlsCert != "" {
+				fmt.Printf("Serving Workline API on https://%s%s (v1 beside it, versions at /versions, Swagger UI at /docs)\n", addr, basePath)
+				if err := srv.ListenAndServeTLS(tlsCert, tlsKey); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return err
+				}
+				return nil
+			}
+			fmt.Printf("Serving Workline API on http://%s%s (v1 beside it, versions at /versions, Swagger UI at /docs)\n", addr, basePath)
 			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				return err
 			}
@@ -1105,7 +4481,42 @@ func serveCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "listen address")
-	cmd.Flags().StringVar(&basePath, "base-path", "/v0", "API base path")
+	cmd.Flags().StringVar(&basePath, "base-path", "/v0", "base path of the v0 API; v1 is served beside it (/v1 by default)")
+	cmd.Flags().StringVar(&v0Sunset, "v0-sunset", "", "date (YYYY-MM-DD) the v0 API is to be removed, announced in the Sunset header of v0 responses")
+	cmd.Flags().DurationVar(&statsInterval, "stats-interval", time.Hour, "interval for daily stats snapshots (0 disables)")
+	cmd.Flags().DurationVar(&metricsInterval, "metrics-interval", time.Minute, "interval for exporting workflow metrics (open tasks, active leases, validation-blocked tasks, attestations) over OTLP (0 disables)")
+	cmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP metrics endpoint, e.g. http://collector:4318/v1/metrics; defaults to OTEL_EXPORTER_OTLP_METRICS_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT, and no metrics are exported without one")
+	cmd.Flags().DurationVar(&digestInterval, "digest-interval", time.Hour, "interval for generating yesterday's digest of projects lacking one (0 disables)")
+	cmd.Flags().DurationVar(&notifyInterval, "notify-interval", 15*time.Second, "poll interval for notification channels (0 disables)")
+	cmd.Flags().DurationVar(&grantExpiryInterval, "grant-expiry-interval", time.Minute, "interval for sweeping expired role grants (0 disables)")
+	cmd.Flags().DurationVar(&leaseQueueInterval, "lease-queue-interval", 15*time.Second, "interval for granting expired leases to queued actors (0 disables)")
+	cmd.Flags().DurationVar(&consistencyInterval, "consistency-interval", 24*time.Hour, "interval for checking the database for orphaned rows, reported on stderr (0 disables)")
+	cmd.Flags().DurationVar(&freshnessInterval, "freshness-interval", time.Hour, "interval for flagging tasks in review whose attestations outlived their freshness window with validation.stale events (0 disables)")
+	cmd.Flags().DurationVar(&escalationInterval, "escalation-interval", 5*time.Minute, "interval for applying escalation rules to overdue tasks and blocked validations (0 disables)")
+	cmd.Flags().DurationVar(&inboxInterval, "inbox-interval", 30*time.Second, "interval for collecting mentions, expiring leases and attestation requests into actors' inboxes (0 disables)")
+	cmd.Flags().DurationVar(&leaseWarning, "lease-warning", engine.DefaultLeaseWarning, "notify lease holders this long before their lease expires (0 disables)")
+	cmd.Flags().DurationVar(&deferInterval, "defer-interval", time.Minute, "interval for ending passed task deferrals and recording task.ready (0 disables)")
+	cmd.Flags().DurationVar(&usageFlush, "usage-flush-interval", 10*time.Second, "how often API usage counts of actors without a quota are written; in between they are kept in memory (0 writes every call)")
+	cmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 0, "cache project config and RBAC lookups for this long; changes made through this server apply at once, changes from other processes (such as wl rbac) only after this delay (0 disables)")
+	cmd.Flags().StringArrayVar(&readReplicas, "read-replica", nil, "read-only copy of the database (e.g. kept current by litestream restore) serving GET requests; repeat for several")
+	cmd.Flags().IntVar(&rowBudget, "row-budget", repo.DefaultRowBudget, "maximum rows list queries may read per request")
+	cmd.Flags().DurationVar(&requestTimeout, "request-timeout", 30*time.Second, "fail requests still running after this long with 504 timeout, interrupting their database statements (0 disables)")
+	cmd.Flags().DurationVar(&slowQuery, "slow-query", 200*time.Millisecond, "log database statements taking at least this long, with their caller and shortened parameters (0 disables)")
+	cmd.Flags().StringVar(&jsonDecoding, "json-decoding", server.JSONStrict, "request bodies with undeclared fields: strict rejects them with 400 naming the fields, lenient ignores them")
+	cmd.Flags().StringVar(&messagesPath, "messages", "", "YAML or JSON catalog of localized error messages (language -> error code -> template), chosen by Accept-Language")
+	cmd.Flags().BoolVar(&requestLog, "request-log", true, "log each request as a JSON line on stderr with its X-Request-Id, method, path, actor, status and duration")
+	cmd.Flags().BoolVar(&compress, "compress", true, "gzip-encode responses of at least 1 KiB for clients that send Accept-Encoding: gzip")
+	cmd.Flags().BoolVar(&graphQL, "graphql", false, "serve the read-only GraphQL API at <base-path>/graphql, with its schema at <base-path>/graphql/schema")
+	cmd.Flags().DurationVar(&chaos.Latency, "chaos-latency", 0, "testing only: delay each API request by a random duration up to this long")
+	cmd.Flags().Float64Var(&chaos.ErrorRate, "chaos-error-rate", 0, "testing only: fail this fraction of API requests with 500 chaos_injected, half of them after the request was processed")
+	cmd.Flags().Float64Var(&chaos.LeaseRaceRate, "chaos-lease-race-rate", 0, "testing only: refuse this fraction of claims, releases, done and task updates with 409 lease_conflict")
+	cmd.Flags().Int64Var(&chaos.Seed, "chaos-seed", 0, "seed making injected faults reproducible (0 seeds from the clock)")
+	cmd.Flags().StringVar(&contract, "validate-contract", "", "check requests and responses against the OpenAPI spec: log or enforce (off when empty)")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "serve HTTPS with this certificate (PEM)")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "private key for --tls-cert (PEM)")
+	cmd.Flags().StringVar(&evidenceKey, "evidence-key", "", "Ed25519 key (PKCS#8 PEM) for signing evidence bundles; defaults to .workline/evidence.key, created if missing")
+	cmd.Flags().StringVar(&env, "env", os.Getenv("WORKLINE_ENV"), "environment profile from config profiles (e.g. dev, staging, prod) overriding lease terms, dev login and quotas; defaults to WORKLINE_ENV")
+	cmd.Flags().StringVar(&clientCA, "client-ca", "", "CA bundle (PEM) for verifying client certificates; mapped certificates authenticate as their actor")
 	return cmd
 }
 
@@ -1150,6 +4561,9 @@ func withEngine(ctx context.Context, fn func(context.Context, engine.Engine) err
 		return err
 	}
 	e := engine.New(conn, cfg)
+	if e.Blobs, err = blob.Open(cfg.Blobs, workspace); err != nil {
+		return err
+	}
 	return fn(ctx, e)
 }
 
@@ -0,0 +1,335 @@
+// Package backup ships the workspace database to a bucket or directory as it changes, and
+// restores it from there.
+//
+// A generation starts with a copy of the database file taken right after the WAL has been
+// checkpointed. From then on every committed transaction is shipped as WAL frames; once the
+// WAL grows past CheckpointBytes it is checkpointed and truncated, which starts the next
+// epoch of the generation. Restore replays the frames of every epoch over the copy. When the
+// replicator cannot follow the WAL, for instance because another process checkpointed it,
+// it starts a new generation.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"workline/internal/blob"
+	"workline/internal/config"
+	"workline/internal/db"
+)
+
+// DefaultCheckpointBytes is the WAL size past which the replicator checkpoints.
+const DefaultCheckpointBytes = 4 << 20
+
+// generationTime prefixes generation names so that they sort by creation.
+const generationTime = "20060102T150405.000000000Z"
+
+var (
+	// errWALReset means the WAL no longer continues what was shipped.
+	errWALReset       = errors.New("WAL was reset")
+	errCheckpointBusy = errors.New("checkpoint blocked by another connection")
+)
+
+// Position locates the end of what has been shipped: a byte offset in the WAL of an epoch.
+type Position struct {
+	Generation string `json:"generation"`
+	Epoch      int    `json:"epoch"`
+	Offset     int64  `json:"offset"`
+}
+
+// Replicator ships the database at Path, opened as DB, to Store. DB must be the primary
+// handle of the process so that shipping and checkpoints are serialized with its writes.
+type Replicator struct {
+	DB               *sql.DB
+	Path             string
+	Store            blob.Objects
+	SnapshotInterval time.Duration
+	Retain           int
+	CheckpointBytes  int64
+	Logger           *log.Logger
+	Now              func() time.Time
+
+	mu         sync.Mutex
+	generation string
+	snapshotAt time.Time
+	epoch      int
+	offset     int64
+	header     *walHeader
+	prevSeq    *uint32
+	ck         [2]uint32
+}
+
+// New configures a replicator for the database file at path from cfg.
+func New(conn *sql.DB, path string, cfg config.Backup) (*Replicator, error) {
+	store, err := blob.OpenObjects(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Replicator{DB: conn, Path: path, Store: store, SnapshotInterval: cfg.SnapshotEvery(), Retain: cfg.Generations()}, nil
+}
+
+func (r *Replicator) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *Replicator) logger() *log.Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return log.Default()
+}
+
+// Run ships new transactions every interval until ctx is done.
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Sync(ctx); err != nil && ctx.Err() == nil {
+			r.logger().Printf("backup: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync ships the transactions committed since the last call, first starting a generation
+// when none is running or SnapshotInterval has passed, and checkpoints a large WAL. A
+// database connection is only held for the pragmas and the local copy, never during
+// uploads.
+func (r *Replicator) Sync(ctx context.Context) (Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interval := r.SnapshotInterval
+	if interval <= 0 {
+		interval = config.DefaultBackupSnapshotInterval
+	}
+	if r.generation == "" || r.now().Sub(r.snapshotAt) >= interval {
+		if err := r.snapshot(ctx); err != nil {
+			return r.position(), err
+		}
+	}
+	err := r.ship(ctx)
+	if errors.Is(err, errWALReset) {
+		r.logger().Printf("backup: the WAL of generation %s was checkpointed elsewhere; starting a new generation", r.generation)
+		if err := r.snapshot(ctx); err != nil {
+			return r.position(), err
+		}
+		err = r.ship(ctx)
+	}
+	if err != nil {
+		return r.position(), err
+	}
+	return r.position(), r.checkpoint(ctx)
+}
+
+// withConn runs fn on a connection of its own, released as soon as fn returns.
+func (r *Replicator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(conn)
+}
+
+func (r *Replicator) position() Position {
+	return Position{Generation: r.generation, Epoch: r.epoch, Offset: r.offset}
+}
+
+// snapshot starts a generation: it switches the database to WAL mode without automatic
+// checkpoints, empties the WAL and uploads a page-for-page copy of the database, onto which
+// the frames shipped afterwards apply.
+func (r *Replicator) snapshot(ctx context.Context) error {
+	r.generation = ""
+	copyPath := r.Path + ".snapshot"
+	os.Remove(copyPath)
+	defer os.Remove(copyPath)
+	if err := r.withConn(ctx, func(conn *sql.Conn) error {
+		var mode string
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode=WAL").Scan(&mode); err != nil {
+			return err
+		}
+		if mode != "wal" {
+			return fmt.Errorf("backup: %s cannot use WAL mode (journal_mode is %s)", r.Path, mode)
+		}
+		if _, err := conn.ExecContext(ctx, "PRAGMA wal_autocheckpoint=0"); err != nil {
+			return err
+		}
+		if err := truncateWAL(ctx, conn); err != nil {
+			return err
+		}
+		return db.Copy(conn, copyPath)
+	}); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(copyPath)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	now := r.now()
+	generation := now.UTC().Format(generationTime) + "-" + hex.EncodeToString(suffix)
+	if err := r.put(ctx, snapshotKey(generation), data); err != nil {
+		return err
+	}
+	r.generation, r.snapshotAt = generation, now
+	r.epoch, r.offset, r.header, r.prevSeq = 0, 0, nil, nil
+	if err := r.prune(ctx); err != nil {
+		r.logger().Printf("backup: pruning old generations: %v", err)
+	}
+	return nil
+}
+
+// ship uploads the committed frames written to the WAL since the last call.
+func (r *Replicator) ship(ctx context.Context) error {
+	f, err := os.Open(r.Path + "-wal")
+	if os.IsNotExist(err) {
+		if r.header != nil {
+			return errWALReset
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hb := make([]byte, walHeaderSize)
+	if _, err := f.ReadAt(hb, 0); err != nil {
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		if r.header != nil {
+			return errWALReset
+		}
+		return nil
+	}
+	h, err := parseWALHeader(hb)
+	if err != nil {
+		if r.header != nil {
+			return errWALReset
+		}
+		// The first frame of the epoch is still being written.
+		return nil
+	}
+	start, ck := r.offset, r.ck
+	if r.header == nil {
+		// The WAL restarts with the next checkpoint sequence number after our own
+		// checkpoint; any other value means a checkpoint happened that we did not see.
+		if r.prevSeq != nil && h.seq != *r.prevSeq+1 {
+			return errWALReset
+		}
+		start, ck = 0, h.checksum
+	} else if h.salt != r.header.salt {
+		return errWALReset
+	}
+	pos := max(start, walHeaderSize)
+	frames, err := io.ReadAll(io.NewSectionReader(f, pos, 1<<62))
+	if err != nil {
+		return err
+	}
+	n, ck := committedFrames(frames, h, ck)
+	if n == 0 {
+		return nil
+	}
+	segment := frames[:n]
+	if start == 0 {
+		segment = append(hb, segment...)
+	}
+	if err := r.put(ctx, segmentKey(r.generation, r.epoch, start), segment); err != nil {
+		return err
+	}
+	r.header, r.offset, r.ck = &h, pos+int64(n), ck
+	return nil
+}
+
+// checkpoint moves the WAL into the database file and truncates it once everything in it
+// has been shipped and it is larger than CheckpointBytes.
+func (r *Replicator) checkpoint(ctx context.Context) error {
+	limit := r.CheckpointBytes
+	if limit <= 0 {
+		limit = DefaultCheckpointBytes
+	}
+	if r.header == nil || r.offset < limit {
+		return nil
+	}
+	info, err := os.Stat(r.Path + "-wal")
+	if err != nil {
+		return err
+	}
+	if info.Size() != r.offset {
+		// Frames of another process were appended since; ship them first.
+		return nil
+	}
+	if err := r.withConn(ctx, func(conn *sql.Conn) error { return truncateWAL(ctx, conn) }); errors.Is(err, errCheckpointBusy) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	seq := r.header.seq
+	r.epoch, r.offset, r.header, r.prevSeq = r.epoch+1, 0, nil, &seq
+	return nil
+}
+
+func truncateWAL(ctx context.Context, conn *sql.Conn) error {
+	var busy, walFrames, checkpointed int
+	if err := conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &walFrames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return errCheckpointBusy
+	}
+	return nil
+}
+
+func (r *Replicator) put(ctx context.Context, key string, data []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return r.Store.PutObject(ctx, key, buf.Bytes())
+}
+
+// prune deletes the generations older than the Retain most recent ones.
+func (r *Replicator) prune(ctx context.Context) error {
+	gens, err := Generations(ctx, r.Store)
+	if err != nil {
+		return err
+	}
+	keep := r.Retain
+	if keep <= 0 {
+		keep = config.DefaultBackupRetain
+	}
+	for len(gens) > keep {
+		for _, key := range gens[0].keys {
+			if err := r.Store.DeleteObject(ctx, key); err != nil {
+				return err
+			}
+		}
+		gens = gens[1:]
+	}
+	return nil
+}
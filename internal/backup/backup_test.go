@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"workline/internal/blob"
+	"workline/internal/db"
+)
+
+func TestReplicateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	conn, err := db.Open(db.Config{Workspace: workspace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := conn.ExecContext(ctx, query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(`CREATE TABLE proofs(id INTEGER PRIMARY KEY, body BLOB)`)
+	exec(`INSERT INTO proofs(body) VALUES (randomblob(100))`)
+	store := blob.FS{Dir: t.TempDir()}
+	r := &Replicator{DB: conn, Path: db.Path(workspace), Store: store, CheckpointBytes: 32 << 10}
+	first, err := r.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 30 {
+		exec(`INSERT INTO proofs(body) VALUES (randomblob(?))`, 2000+i)
+		if i%4 == 0 {
+			if _, err := r.Sync(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	pos, err := r.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Generation != first.Generation || pos.Epoch == 0 {
+		t.Fatalf("expected later epochs of generation %s, got %+v", first.Generation, pos)
+	}
+
+	digest := func(conn *sql.DB) string {
+		t.Helper()
+		var d string
+		if err := conn.QueryRowContext(ctx, `SELECT count(*) || ':' || sum(length(body)) || ':' || group_concat(hex(substr(body, 1, 8)), '') FROM proofs`).Scan(&d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	restore := func(generation string) (RestoreResult, string) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "restored.db")
+		res, err := Restore(ctx, store, path, generation, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := sql.Open("sqlite", "file:"+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer restored.Close()
+		return res, digest(restored)
+	}
+	res, got := restore("")
+	if want := digest(conn); got != want || !res.Complete || res.Epochs != pos.Epoch+1 {
+		t.Fatalf("restore %+v: got %s, want %s", res, got, want)
+	}
+
+	// A checkpoint the replicator did not make breaks the WAL chain: the next sync starts
+	// a new generation and the old one stays restorable.
+	exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	exec(`INSERT INTO proofs(body) VALUES (randomblob(10))`)
+	next, err := r.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Generation == pos.Generation {
+		t.Fatalf("expected a new generation after an outside checkpoint, got %+v", next)
+	}
+	if _, got := restore(""); got != digest(conn) {
+		t.Fatalf("restore of the new generation: got %s, want %s", got, digest(conn))
+	}
+	if res, _ := restore(pos.Generation); res.Generation != pos.Generation || !res.Complete {
+		t.Fatalf("restore of the old generation: %+v", res)
+	}
+	gens, err := Generations(ctx, store)
+	if err != nil || len(gens) != 2 || !gens[0].Snapshot || gens[0].Epochs != pos.Epoch+1 {
+		t.Fatalf("generations: %+v %v", gens, err)
+	}
+
+	// A missing segment stops the replay at the gap.
+	if err := store.DeleteObject(ctx, segmentKey(pos.Generation, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := restore(pos.Generation); res.Complete || res.Epochs != 1 {
+		t.Fatalf("expected a restore stopping after epoch 0, got %+v", res)
+	}
+	if _, err := Restore(ctx, store, db.Path(workspace), "", false); err == nil {
+		t.Fatalf("expected restore over an existing database to be refused")
+	}
+}
+
+// queryingStore runs a query on the database before each upload, which blocks while the
+// replicator holds the only connection.
+type queryingStore struct {
+	blob.FS
+	db *sql.DB
+}
+
+func (s queryingStore) PutObject(ctx context.Context, key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM proofs`).Scan(&n); err != nil {
+		return fmt.Errorf("database unavailable during upload of %s: %w", key, err)
+	}
+	return s.FS.PutObject(ctx, key, data)
+}
+
+func TestSyncReleasesConnectionDuringUploads(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	conn, err := db.Open(db.Config{Workspace: workspace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE proofs(id INTEGER PRIMARY KEY, body BLOB)`); err != nil {
+		t.Fatal(err)
+	}
+	r := &Replicator{DB: conn, Path: db.Path(workspace), Store: queryingStore{FS: blob.FS{Dir: t.TempDir()}, db: conn}}
+	if _, err := r.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO proofs(body) VALUES (randomblob(100))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,232 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"workline/internal/blob"
+	"workline/internal/db"
+)
+
+func snapshotKey(generation string) string {
+	return generation + "/snapshot.db.gz"
+}
+
+func segmentKey(generation string, epoch int, offset int64) string {
+	return fmt.Sprintf("%s/wal/%08d-%016x.wal.gz", generation, epoch, offset)
+}
+
+type segment struct {
+	key    string
+	epoch  int
+	offset int64
+}
+
+// Generation is one restorable history of the database in a backup target.
+type Generation struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Snapshot bool      `json:"snapshot"`
+	Segments int       `json:"segments"`
+	Epochs   int       `json:"epochs"`
+
+	keys     []string
+	segments []segment
+}
+
+// Generations lists the generations in store, oldest first.
+func Generations(ctx context.Context, store blob.Objects) ([]Generation, error) {
+	keys, err := store.ListObjects(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*Generation{}
+	var names []string
+	for _, key := range keys {
+		name, rest, ok := strings.Cut(key, "/")
+		if !ok {
+			continue
+		}
+		g := byName[name]
+		if g == nil {
+			g = &Generation{Name: name}
+			g.Created, _ = time.Parse(generationTime, strings.SplitN(name, "-", 2)[0])
+			byName[name] = g
+			names = append(names, name)
+		}
+		g.keys = append(g.keys, key)
+		var s segment
+		switch {
+		case rest == "snapshot.db.gz":
+			g.Snapshot = true
+		case strings.HasPrefix(rest, "wal/"):
+			if _, err := fmt.Sscanf(rest, "wal/%08d-%016x.wal.gz", &s.epoch, &s.offset); err == nil {
+				s.key = key
+				g.segments = append(g.segments, s)
+			}
+		}
+	}
+	sort.Strings(names)
+	gens := make([]Generation, 0, len(names))
+	for _, name := range names {
+		g := byName[name]
+		sort.Slice(g.segments, func(i, j int) bool {
+			a, b := g.segments[i], g.segments[j]
+			return a.epoch < b.epoch || a.epoch == b.epoch && a.offset < b.offset
+		})
+		g.Segments = len(g.segments)
+		if g.Segments > 0 {
+			g.Epochs = g.segments[g.Segments-1].epoch + 1
+		}
+		gens = append(gens, *g)
+	}
+	return gens, nil
+}
+
+// RestoreResult reports a restore. Complete is false when a segment was missing, in which
+// case the database holds the transactions shipped before the gap.
+type RestoreResult struct {
+	Path       string `json:"path"`
+	Generation string `json:"generation"`
+	Epochs     int    `json:"epochs"`
+	Segments   int    `json:"segments"`
+	Complete   bool   `json:"complete"`
+}
+
+// Restore rebuilds the database at path from generation, or from the latest generation
+// with a snapshot when empty. The result is checked for integrity and left in rollback
+// journal mode; an existing file at path is replaced only with overwrite.
+func Restore(ctx context.Context, store blob.Objects, path, generation string, overwrite bool) (RestoreResult, error) {
+	res := RestoreResult{Path: path}
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return res, fmt.Errorf("%s already exists", path)
+	}
+	gens, err := Generations(ctx, store)
+	if err != nil {
+		return res, err
+	}
+	var gen *Generation
+	for i := len(gens) - 1; i >= 0; i-- {
+		if gens[i].Snapshot && (generation == "" || gens[i].Name == generation) {
+			gen = &gens[i]
+			break
+		}
+	}
+	if gen == nil {
+		if generation != "" {
+			return res, fmt.Errorf("generation %s not found", generation)
+		}
+		return res, errors.New("no generation to restore")
+	}
+	res.Generation = gen.Name
+	tmp := path + ".restoring"
+	removeDB(tmp)
+	defer removeDB(tmp)
+	data, err := get(ctx, store, snapshotKey(gen.Name))
+	if err != nil {
+		return res, err
+	}
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return res, err
+	}
+	res.Complete = true
+	var wal []byte
+	epoch := 0
+	for _, s := range gen.segments {
+		if s.epoch != epoch {
+			if err := applyWAL(ctx, tmp, wal); err != nil {
+				return res, err
+			}
+			res.Epochs++
+			wal = nil
+			if s.epoch != epoch+1 {
+				res.Complete = false
+				break
+			}
+			epoch = s.epoch
+		}
+		if s.offset != int64(len(wal)) {
+			res.Complete = false
+			break
+		}
+		data, err := get(ctx, store, s.key)
+		if err != nil {
+			return res, err
+		}
+		wal = append(wal, data...)
+		res.Segments++
+	}
+	if len(wal) > 0 {
+		if err := applyWAL(ctx, tmp, wal); err != nil {
+			return res, err
+		}
+		res.Epochs++
+	}
+	conn, err := sql.Open("sqlite", "file:"+tmp)
+	if err != nil {
+		return res, err
+	}
+	check, err := db.CheckIntegrity(ctx, conn, false)
+	if err == nil && !check.OK {
+		err = fmt.Errorf("restored database failed the integrity check: %s", strings.Join(check.Problems, "; "))
+	}
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "PRAGMA journal_mode=DELETE")
+	}
+	if cerr := conn.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return res, err
+	}
+	removeDB(path)
+	return res, os.Rename(tmp, path)
+}
+
+// applyWAL lets SQLite recover wal as the WAL of the database at path and checkpoint it.
+func applyWAL(ctx context.Context, path string, wal []byte) error {
+	if len(wal) == 0 {
+		return nil
+	}
+	os.Remove(path + "-shm")
+	if err := os.WriteFile(path+"-wal", wal, 0o644); err != nil {
+		return err
+	}
+	conn, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	if cerr := conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func get(ctx context.Context, store blob.Objects, key string) ([]byte, error) {
+	data, err := store.GetObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func removeDB(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
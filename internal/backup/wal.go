@@ -0,0 +1,83 @@
+package backup
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// SQLite WAL layout (https://sqlite.org/fileformat2.html#walformat): a 32 byte header
+// followed by frames of a 24 byte header and one page. Header fields are big-endian; the
+// checksum words follow the byte order named by the magic number.
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+	walMagicLE         = 0x377f0682
+	walMagicBE         = 0x377f0683
+)
+
+var errBadWALHeader = errors.New("invalid WAL header")
+
+type walHeader struct {
+	bigEndian bool
+	pageSize  int64
+	seq       uint32
+	salt      [2]uint32
+	checksum  [2]uint32
+}
+
+func parseWALHeader(b []byte) (walHeader, error) {
+	if len(b) < walHeaderSize {
+		return walHeader{}, errBadWALHeader
+	}
+	magic := binary.BigEndian.Uint32(b[0:4])
+	if magic != walMagicLE && magic != walMagicBE {
+		return walHeader{}, errBadWALHeader
+	}
+	h := walHeader{
+		bigEndian: magic == walMagicBE,
+		pageSize:  int64(binary.BigEndian.Uint32(b[8:12])),
+		seq:       binary.BigEndian.Uint32(b[12:16]),
+		salt:      [2]uint32{binary.BigEndian.Uint32(b[16:20]), binary.BigEndian.Uint32(b[20:24])},
+	}
+	h.checksum = walChecksum(h.bigEndian, [2]uint32{}, b[:24])
+	if h.checksum != [2]uint32{binary.BigEndian.Uint32(b[24:28]), binary.BigEndian.Uint32(b[28:32])} || h.pageSize < 512 {
+		return walHeader{}, errBadWALHeader
+	}
+	return h, nil
+}
+
+func walChecksum(bigEndian bool, s [2]uint32, b []byte) [2]uint32 {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s[0] += order.Uint32(b[i:]) + s[1]
+		s[1] += order.Uint32(b[i+4:]) + s[0]
+	}
+	return s
+}
+
+// committedFrames scans frames continuing a checksum chain at ck and returns the length of
+// the prefix of b ending with the last valid commit frame, with the chain value there.
+// Frames of an unfinished transaction, torn writes and frames left over from before the
+// WAL was restarted (other salts) all end the scan.
+func committedFrames(b []byte, h walHeader, ck [2]uint32) (int, [2]uint32) {
+	frameSize := int(walFrameHeaderSize + h.pageSize)
+	end, endCk := 0, ck
+	for pos := 0; pos+frameSize <= len(b); pos += frameSize {
+		frame := b[pos : pos+frameSize]
+		if binary.BigEndian.Uint32(frame[8:12]) != h.salt[0] || binary.BigEndian.Uint32(frame[12:16]) != h.salt[1] {
+			break
+		}
+		ck = walChecksum(h.bigEndian, ck, frame[:8])
+		ck = walChecksum(h.bigEndian, ck, frame[walFrameHeaderSize:])
+		if ck != [2]uint32{binary.BigEndian.Uint32(frame[16:20]), binary.BigEndian.Uint32(frame[20:24])} {
+			break
+		}
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			end, endCk = pos+frameSize, ck
+		}
+	}
+	return end, endCk
+}
@@ -0,0 +1,104 @@
+// Package blob stores large payloads outside sqlite under content-addressed keys.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"workline/internal/config"
+)
+
+// ErrNotFound is returned when no blob exists for a digest.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps immutable blobs keyed by their "sha256:<hex>" digest.
+type Store interface {
+	Put(ctx context.Context, data []byte) (string, error)
+	Get(ctx context.Context, digest string) ([]byte, error)
+}
+
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// Digest returns the content address of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ValidDigest reports whether digest is a well-formed sha256 content address.
+func ValidDigest(digest string) bool {
+	return digestPattern.MatchString(digest)
+}
+
+// Open builds the store selected by cfg; the workspace store lives under workspace/.workline/blobs.
+func Open(cfg config.BlobStore, workspace string) (Store, error) {
+	switch cfg.Store {
+	case "", "workspace":
+		if workspace == "" {
+			workspace = "."
+		}
+		return FS{Dir: filepath.Join(workspace, ".workline", "blobs")}, nil
+	case "s3":
+		return newS3(cfg.S3, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "https://s3.%s.amazonaws.com", "us-east-1")
+	case "gcs":
+		// Cloud Storage accepts SigV4-signed XML API requests made with HMAC keys.
+		return newS3(cfg.GCS, "GCS_HMAC_ACCESS_ID", "GCS_HMAC_SECRET", "https://storage.googleapis.com", "auto")
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.Store)
+	}
+}
+
+// FS stores blobs as files fanned out by the first two hex digits of their digest.
+type FS struct {
+	Dir string
+}
+
+func (s FS) path(digest string) string {
+	hexDigest := strings.TrimPrefix(digest, "sha256:")
+	return filepath.Join(s.Dir, hexDigest[:2], hexDigest)
+}
+
+func (s FS) Put(_ context.Context, data []byte) (string, error) {
+	digest := Digest(data)
+	path := s.path(digest)
+	if _, err := os.Stat(path); err == nil {
+		return digest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+func (s FS) Get(_ context.Context, digest string) ([]byte, error) {
+	if !ValidDigest(digest) {
+		return nil, fmt.Errorf("invalid blob digest %q", digest)
+	}
+	data, err := os.ReadFile(s.path(digest))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
@@ -0,0 +1,32 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFSRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := FS{Dir: t.TempDir()}
+	digest, err := s.Put(ctx, []byte(`{"report":"ok"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidDigest(digest) || digest != Digest([]byte(`{"report":"ok"}`)) {
+		t.Fatalf("unexpected digest %s", digest)
+	}
+	if again, err := s.Put(ctx, []byte(`{"report":"ok"}`)); err != nil || again != digest {
+		t.Fatalf("re-put: %s %v", again, err)
+	}
+	data, err := s.Get(ctx, digest)
+	if err != nil || string(data) != `{"report":"ok"}` {
+		t.Fatalf("get: %q %v", data, err)
+	}
+	if _, err := s.Get(ctx, Digest([]byte("missing"))); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Get(ctx, "sha256:../../etc/passwd"); err == nil {
+		t.Fatalf("expected malformed digest to be rejected")
+	}
+}
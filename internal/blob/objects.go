@@ -0,0 +1,216 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"workline/internal/config"
+)
+
+// Objects keeps mutable objects under slash-separated keys, such as database backups.
+// Unlike Store, keys are chosen by the caller and objects may be listed and deleted.
+type Objects interface {
+	PutObject(ctx context.Context, key string, data []byte) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// ListObjects returns the keys starting with prefix in lexical order.
+	ListObjects(ctx context.Context, prefix string) ([]string, error)
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// OpenObjects builds the backup target selected by cfg: a local directory, or a bucket
+// configured and authenticated like the blob store.
+func OpenObjects(cfg config.Backup) (Objects, error) {
+	switch cfg.Store {
+	case "dir":
+		return FS{Dir: cfg.Dir}, nil
+	case "s3":
+		return newS3(cfg.S3, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "https://s3.%s.amazonaws.com", "us-east-1")
+	case "gcs":
+		return newS3(cfg.GCS, "GCS_HMAC_ACCESS_ID", "GCS_HMAC_SECRET", "https://storage.googleapis.com", "auto")
+	case "":
+		return nil, errors.New("no backup target configured (backup.store)")
+	default:
+		return nil, fmt.Errorf("unknown backup store %q", cfg.Store)
+	}
+}
+
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || slices.Contains(strings.Split(key, "/"), "..") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
+
+func (s FS) PutObject(_ context.Context, key string, data []byte) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s FS) GetObject(_ context.Context, key string) ([]byte, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s FS) ListObjects(_ context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	err := filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	slices.Sort(keys)
+	return keys, err
+}
+
+func (s FS) DeleteObject(_ context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s S3) PutObject(ctx context.Context, key string, data []byte) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 put %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (s S3) GetObject(ctx context.Context, key string) ([]byte, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("s3 get %s: %s", key, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// ListObjects pages through ListObjectsV2 and strips the configured prefix from the keys.
+func (s S3) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.Prefix + prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, s.Endpoint+"/"+s.Bucket+"?"+strings.ReplaceAll(q.Encode(), "+", "%20"), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if resp.StatusCode/100 != 2 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("s3 list %s: %s", prefix, resp.Status)
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list %s: %w", prefix, err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, s.Prefix))
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (s S3) DeleteObject(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 delete %s: %s", key, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"workline/internal/config"
+)
+
+// S3 stores blobs in an S3-compatible bucket using path-style requests signed with SigV4.
+type S3 struct {
+	Bucket       string
+	Region       string
+	Endpoint     string
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Client       *http.Client
+	Now          func() time.Time
+}
+
+// newS3 configures a bucket client; endpointFormat may contain %s for the region.
+func newS3(cfg config.ObjectBucket, accessEnv, secretEnv, endpointFormat, defaultRegion string) (S3, error) {
+	if cfg.AccessKeyEnv != "" {
+		accessEnv = cfg.AccessKeyEnv
+	}
+	if cfg.SecretKeyEnv != "" {
+		secretEnv = cfg.SecretKeyEnv
+	}
+	s := S3{
+		Bucket:    cfg.Bucket,
+		Region:    cfg.Region,
+		Endpoint:  strings.TrimSuffix(cfg.Endpoint, "/"),
+		Prefix:    cfg.Prefix,
+		AccessKey: os.Getenv(accessEnv),
+		SecretKey: os.Getenv(secretEnv),
+	}
+	if accessEnv == "AWS_ACCESS_KEY_ID" {
+		s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return s, fmt.Errorf("blob store: %s and %s must be set", accessEnv, secretEnv)
+	}
+	if s.Region == "" {
+		s.Region = defaultRegion
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = endpointFormat
+		if strings.Contains(endpointFormat, "%s") {
+			s.Endpoint = fmt.Sprintf(endpointFormat, s.Region)
+		}
+	}
+	return s, nil
+}
+
+func (s S3) objectURL(key string) string {
+	return s.Endpoint + "/" + s.Bucket + "/" + s.Prefix + key
+}
+
+func (s S3) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	sum := sha256.Sum256(body)
+	signV4(req, hex.EncodeToString(sum[:]), s.AccessKey, s.SecretKey, s.SessionToken, s.Region, now())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func (s S3) Put(ctx context.Context, data []byte) (string, error) {
+	digest := Digest(data)
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(strings.TrimPrefix(digest, "sha256:")), data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("s3 put %s: %s: %s", digest, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return digest, nil
+}
+
+func (s S3) Get(ctx context.Context, digest string) ([]byte, error) {
+	if !ValidDigest(digest) {
+		return nil, fmt.Errorf("invalid blob digest %q", digest)
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(strings.TrimPrefix(digest, "sha256:")), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("s3 get %s: %s", digest, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if Digest(data) != digest {
+		return nil, fmt.Errorf("s3 get %s: content does not match digest", digest)
+	}
+	return data, nil
+}
+
+// signV4 adds AWS Signature Version 4 headers for the s3 service, signing host and every
+// header already set on req.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, sessionToken, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("x-amz-security-token", sessionToken)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+	canonicalRequest := strings.Join([]string{req.Method, path, query, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,162 @@
+// Package canon serializes entities to a canonical JSON form and hashes it, so the proof
+// record can be checked for tampering independently of how rows happen to be stored.
+//
+// The canonical form has object keys sorted by code point, no insignificant whitespace, no
+// HTML escaping, and numbers in the shortest round-trip form. Columns holding JSON documents
+// are embedded as parsed values, so their formatting does not affect the hash.
+package canon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"workline/internal/domain"
+)
+
+// Marshal returns the canonical JSON encoding of v.
+func Marshal(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// Maps encode with sorted keys and float64 with the shortest round-trip form.
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Hash returns "sha256:<hex>" over the canonical JSON encoding of v.
+func Hash(v any) (string, error) {
+	b, err := Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// TaskHash hashes the content of a task. Rank and dependencies are excluded: rank is a
+// scheduling hint and dependencies are recorded separately.
+func TaskHash(t domain.Task) string {
+	doc := map[string]any{
+		"id":                    t.ID,
+		"project_id":            t.ProjectID,
+		"iteration_id":          t.IterationID,
+		"parent_id":             t.ParentID,
+		"type":                  t.Type,
+		"title":                 t.Title,
+		"description":           t.Description,
+		"status":                t.Status,
+		"assignee_id":           t.AssigneeID,
+		"work_outcomes":         document(deref(t.WorkOutcomesJSON)),
+		"required_attestations": document(deref(t.RequiredAttestationsJSON)),
+		"created_at":            t.CreatedAt,
+		"updated_at":            t.UpdatedAt,
+		"completed_at":          t.CompletedAt,
+	}
+	// Only tasks that declare capabilities hash them, so hashes recorded before
+	// capabilities existed stay valid.
+	if len(t.RequiredCapabilities) > 0 {
+		doc["required_capabilities"] = t.RequiredCapabilities
+	}
+	if t.CustomFieldsJSON != nil {
+		doc["custom_fields"] = document(*t.CustomFieldsJSON)
+	}
+	if len(t.Labels) > 0 {
+		doc["labels"] = t.Labels
+	}
+	return mustHash(doc)
+}
+
+// DecisionHash hashes the content of a decision.
+func DecisionHash(d domain.Decision) string {
+	return mustHash(map[string]any{
+		"id":           d.ID,
+		"project_id":   d.ProjectID,
+		"title":        d.Title,
+		"context":      document(d.ContextJSON),
+		"decision":     d.Decision,
+		"rationale":    document(d.RationaleJSON),
+		"alternatives": document(d.AlternativesJSON),
+		"decider_id":   d.DeciderID,
+		"status":       d.Status,
+		"created_at":   d.CreatedAt,
+	})
+}
+
+// AttestationHash hashes the content of an attestation.
+func AttestationHash(a domain.Attestation) string {
+	return mustHash(map[string]any{
+		"id":          a.ID,
+		"project_id":  a.ProjectID,
+		"entity_kind": a.EntityKind,
+		"entity_id":   a.EntityID,
+		"kind":        a.Kind,
+		"actor_id":    a.ActorID,
+		"ts":          a.TS,
+		"payload":     document(a.PayloadJSON),
+	})
+}
+
+// EventHash chains an event to its predecessor: the SHA-256 of prevHash followed by the
+// canonical form of the event. The database id is left out so the chain survives a copy.
+func EventHash(prevHash string, e domain.Event) string {
+	doc := map[string]any{
+		"ts":          e.TS,
+		"type":        e.Type,
+		"project_id":  e.ProjectID,
+		"entity_kind": e.EntityKind,
+		"entity_id":   e.EntityID,
+		"actor_id":    e.ActorID,
+		"payload":     document(e.Payload),
+	}
+	// Events recorded outside a request, or before request IDs, hash as they always did.
+	if e.RequestID != "" {
+		doc["request_id"] = e.RequestID
+	}
+	b, err := Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(append([]byte(prevHash), b...))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// document embeds a JSON column as its parsed value; text that is not valid JSON is kept
+// verbatim as a string.
+func document(s string) any {
+	if s == "" {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// mustHash hashes values built from strings and decoded JSON, which always encode.
+func mustHash(v map[string]any) string {
+	h, err := Hash(v)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,28 @@
+package canon
+
+import "testing"
+
+func TestMarshalSortsKeysAndKeepsHTML(t *testing.T) {
+	b, err := Marshal(map[string]any{"b": 1.5, "a": "<x>", "c": map[string]any{"z": nil, "y": []any{2, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":"<x>","b":1.5,"c":{"y":[2,1],"z":null}}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+}
+
+func TestDocumentFormattingDoesNotChangeHash(t *testing.T) {
+	h1, err := Hash(map[string]any{"payload": document(`{"b":1,"a":2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := Hash(map[string]any{"payload": document("{ \"a\": 2,\n \"b\": 1 }")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Fatalf("hashes differ: %s vs %s", h1, h2)
+	}
+}
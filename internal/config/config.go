@@ -2,11 +2,22 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"workline/internal/expr"
 )
 
 // Config models workline.yml.
@@ -16,9 +27,10 @@ type Config struct {
 		Kind string `yaml:"kind"`
 	} `yaml:"project"`
 	Attestations struct {
-		Catalog map[string]struct {
-			Description string `yaml:"description"`
-		} `yaml:"catalog"`
+		Catalog map[string]AttestationKind `yaml:"catalog"`
+		// OnDeprecated is warn (default) or reject for new attestations of deprecated kinds.
+		OnDeprecated string    `yaml:"on_deprecated"`
+		Freshness    Freshness `yaml:"freshness"`
 	} `yaml:"attestations"`
 	Policies struct {
 		Presets  map[string]PolicyPreset `yaml:"presets"`
@@ -30,17 +42,812 @@ type Config struct {
 				} `yaml:"validation"`
 			} `yaml:"iteration"`
 		} `yaml:"defaults"`
+		Risk RiskPolicy `yaml:"risk"`
 	} `yaml:"policies"`
 	RBAC struct {
 		Roles                  map[string]RBACRole `yaml:"roles"`
 		AttestationAuthorities map[string][]string `yaml:"attestation_authorities"`
 	} `yaml:"rbac"`
+	Integrations  map[string]Integration `yaml:"integrations"`
+	Notifications struct {
+		Channels map[string]NotificationChannel `yaml:"channels"`
+	} `yaml:"notifications"`
+	Transitions struct {
+		// Task maps a status to the statuses it may move to; Types overrides it per task type.
+		Task  map[string][]string            `yaml:"task"`
+		Types map[string]map[string][]string `yaml:"types"`
+	} `yaml:"transitions"`
+	IDs struct {
+		Tasks        IDScheme `yaml:"tasks"`
+		Attestations IDScheme `yaml:"attestations"`
+	} `yaml:"ids"`
+	Payloads   Payloads   `yaml:"payloads"`
+	Blobs      BlobStore  `yaml:"blobs"`
+	Compliance Compliance `yaml:"compliance"`
+	// TaskTypes adds project-specific task types and custom field schemas.
+	TaskTypes  map[string]TaskType `yaml:"task_types"`
+	Quotas     Quotas              `yaml:"quotas"`
+	Backup     Backup              `yaml:"backup"`
+	Rollup     Rollup              `yaml:"rollup"`
+	Completion Completion          `yaml:"completion"`
+	Digest     Digest              `yaml:"digest"`
+	Scheduler  Scheduler           `yaml:"scheduler"`
+	HTTP       HTTP                `yaml:"http"`
+	Leases     Leases              `yaml:"leases"`
+	Auth       Auth                `yaml:"auth"`
+	// Profiles override leases, auth and quotas per environment; see Effective.
+	Profiles map[string]Profile `yaml:"profiles"`
+	// Environment names the profile in effect. It is chosen at startup, not read from
+	// workline.yml, and never stored.
+	Environment string `yaml:"-" json:"-"`
+	// Flags switches experimental features on or off for the project; see Features.
+	Flags map[string]bool `yaml:"flags"`
+}
+
+var (
+	taskTypePattern    = regexp.MustCompile(`^[a-z][a-z0-9._-]*$`)
+	customFieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// BuiltinTaskTypes are available in every project.
+var BuiltinTaskTypes = []string{"technical", "feature", "bug", "docs", "chore", "workshop"}
+
+// TaskType declares a task type beyond the built-in ones, or attaches a custom field schema
+// to a built-in type. Fields is a JSON Schema (written as YAML) for the task's custom_fields
+// object; without it any object is accepted. Indexed names top-level custom fields that
+// list filters should serve from an index. RequiredOutcomes names top-level work outcome
+// fields a task of the type must report, with a non-empty value, to be completed.
+type TaskType struct {
+	Description      string         `yaml:"description"`
+	Fields           map[string]any `yaml:"fields"`
+	Indexed          []string       `yaml:"indexed"`
+	RequiredOutcomes []string       `yaml:"required_outcomes"`
+}
+
+// ValidCustomFieldName reports whether name can be filtered on and indexed.
+func ValidCustomFieldName(name string) bool {
+	return customFieldPattern.MatchString(name)
+}
+
+// MaxIndexedCustomFields caps how many custom fields one config may list as indexed.
+const MaxIndexedCustomFields = 8
+
+// IndexedCustomFields lists, in name order, the custom fields indexed by any task type.
+func (c *Config) IndexedCustomFields() []string {
+	var names []string
+	for _, tt := range c.TaskTypes {
+		for _, name := range tt.Indexed {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasTaskType reports whether name is a built-in or declared task type.
+func (c *Config) HasTaskType(name string) bool {
+	if slices.Contains(BuiltinTaskTypes, name) {
+		return true
+	}
+	_, ok := c.TaskTypes[name]
+	return ok
+}
+
+// TaskTypeNames lists built-in types followed by declared ones in name order.
+func (c *Config) TaskTypeNames() []string {
+	names := append([]string{}, BuiltinTaskTypes...)
+	var custom []string
+	for name := range c.TaskTypes {
+		if !slices.Contains(BuiltinTaskTypes, name) {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+const (
+	DefaultPayloadMaxBytes       = 8 << 20
+	DefaultPayloadInlineMaxBytes = 16 << 10
+	DefaultArtifactMaxBytes      = 32 << 20
+)
+
+// Payloads bounds attestation payloads and task work outcomes. Attestation payloads larger
+// than InlineMaxBytes are moved to the blob store and replaced by a content-addressed
+// reference; anything larger than MaxBytes is rejected. ArtifactMaxBytes caps uploaded
+// evidence files. Zero selects the defaults.
+type Payloads struct {
+	MaxBytes         int `yaml:"max_bytes"`
+	InlineMaxBytes   int `yaml:"inline_max_bytes"`
+	ArtifactMaxBytes int `yaml:"artifact_max_bytes"`
+}
+
+func (p Payloads) Max() int {
+	if p.MaxBytes > 0 {
+		return p.MaxBytes
+	}
+	return DefaultPayloadMaxBytes
+}
+
+func (p Payloads) InlineMax() int {
+	if p.InlineMaxBytes > 0 {
+		return p.InlineMaxBytes
+	}
+	return DefaultPayloadInlineMaxBytes
+}
+
+func (p Payloads) ArtifactMax() int {
+	if p.ArtifactMaxBytes > 0 {
+		return p.ArtifactMaxBytes
+	}
+	return DefaultArtifactMaxBytes
+}
+
+// BlobStore selects where offloaded payloads and artifacts live: workspace (default, files
+// under .workline/blobs), s3 (any S3-compatible endpoint) or gcs (Cloud Storage through its
+// XML API with HMAC keys). Credentials come from the environment variables named here,
+// defaulting to AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY and GCS_HMAC_ACCESS_ID/GCS_HMAC_SECRET.
+type BlobStore struct {
+	Store string       `yaml:"store"`
+	S3    ObjectBucket `yaml:"s3"`
+	GCS   ObjectBucket `yaml:"gcs"`
+}
+
+type ObjectBucket struct {
+	Bucket       string `yaml:"bucket"`
+	Region       string `yaml:"region"`
+	Endpoint     string `yaml:"endpoint"`
+	Prefix       string `yaml:"prefix"`
+	AccessKeyEnv string `yaml:"access_key_env"`
+	SecretKeyEnv string `yaml:"secret_key_env"`
+}
+
+// Backup ships the workspace database continuously to a bucket or directory so that a lost
+// host can be rebuilt with wl db restore. Store is s3, gcs or dir; buckets are configured and
+// authenticated like blobs. Interval is how often committed WAL frames are shipped (default
+// 10s), SnapshotInterval how often a new generation starts from a full copy (default 24h)
+// and Retain how many generations are kept (default 2).
+type Backup struct {
+	Store            string       `yaml:"store"`
+	S3               ObjectBucket `yaml:"s3"`
+	GCS              ObjectBucket `yaml:"gcs"`
+	Dir              string       `yaml:"dir"`
+	Interval         string       `yaml:"interval"`
+	SnapshotInterval string       `yaml:"snapshot_interval"`
+	Retain           int          `yaml:"retain"`
+}
+
+const (
+	DefaultBackupInterval         = 10 * time.Second
+	DefaultBackupSnapshotInterval = 24 * time.Hour
+	DefaultBackupRetain           = 2
+)
+
+// Enabled reports whether a backup target is configured.
+func (b Backup) Enabled() bool {
+	return b.Store != ""
+}
+
+func (b Backup) ShipEvery() time.Duration {
+	if d, err := time.ParseDuration(b.Interval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultBackupInterval
+}
+
+func (b Backup) SnapshotEvery() time.Duration {
+	if d, err := time.ParseDuration(b.SnapshotInterval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultBackupSnapshotInterval
+}
+
+func (b Backup) Generations() int {
+	if b.Retain > 0 {
+		return b.Retain
+	}
+	return DefaultBackupRetain
+}
+
+func (b Backup) validate() error {
+	switch b.Store {
+	case "", "s3", "gcs":
+	case "dir":
+		if b.Dir == "" {
+			return fmt.Errorf("config.backup: dir is required for store dir")
+		}
+	default:
+		return fmt.Errorf("config.backup: store must be s3, gcs or dir")
+	}
+	if b.Store == "s3" && b.S3.Bucket == "" || b.Store == "gcs" && b.GCS.Bucket == "" {
+		return fmt.Errorf("config.backup: %s.bucket is required", b.Store)
+	}
+	for name, v := range map[string]string{"interval": b.Interval, "snapshot_interval": b.SnapshotInterval} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("config.backup: invalid %s %q", name, v)
+		}
+	}
+	if b.Retain < 0 {
+		return fmt.Errorf("config.backup: retain must be positive")
+	}
+	return nil
+}
+
+// Compliance maps declared controls (for example SOC2 CC8.1) to the attestation kinds that
+// evidence them. Framework is a free-form label carried into reports.
+type Compliance struct {
+	Framework string                       `yaml:"framework"`
+	Controls  map[string]ComplianceControl `yaml:"controls"`
+}
+
+// ComplianceControl is satisfied by an entity once every kind in Kinds is attested on it.
+// Kinds use the policy requirement syntax, so "security.ok+security.countersign" asks for a
+// countersigned attestation. AppliesTo lists entity kinds (task, iteration); default task.
+type ComplianceControl struct {
+	Description string   `yaml:"description"`
+	Kinds       []string `yaml:"kinds"`
+	AppliesTo   []string `yaml:"applies_to"`
+}
+
+// Targets returns the entity kinds the control applies to.
+func (c ComplianceControl) Targets() []string {
+	if len(c.AppliesTo) == 0 {
+		return []string{"task"}
+	}
+	return c.AppliesTo
 }
 
 type PolicyPreset struct {
 	Require []string `yaml:"require"`
 }
 
+// AttestationKind describes a catalog kind. A deprecated kind may name the kind replacing
+// it; until the end of GraceUntil (YYYY-MM-DD, open-ended when empty) an attestation of the
+// replacement also satisfies requirements of the deprecated kind.
+type AttestationKind struct {
+	Description string `yaml:"description"`
+	Deprecated  bool   `yaml:"deprecated"`
+	ReplacedBy  string `yaml:"replaced_by"`
+	GraceUntil  string `yaml:"grace_until"`
+}
+
+// InGrace reports whether the replacement still stands in for the deprecated kind at now.
+func (k AttestationKind) InGrace(now time.Time) bool {
+	if k.GraceUntil == "" {
+		return true
+	}
+	end, err := time.Parse(time.DateOnly, k.GraceUntil)
+	return err == nil && now.Before(end.AddDate(0, 0, 1))
+}
+
+// DeprecatedKind returns the catalog entry of kind when it is deprecated.
+func (c *Config) DeprecatedKind(kind string) (AttestationKind, bool) {
+	k, ok := c.Attestations.Catalog[kind]
+	return k, ok && k.Deprecated
+}
+
+// RejectsDeprecatedKinds reports whether new attestations of deprecated kinds are refused
+// rather than accepted with a warning.
+func (c *Config) RejectsDeprecatedKinds() bool {
+	return c.Attestations.OnDeprecated == "reject"
+}
+
+// SatisfyingKinds lists the kinds whose attestations satisfy a requirement of kind at now:
+// kind itself, then its replacement while kind is deprecated and in its grace period.
+func (c *Config) SatisfyingKinds(kind string, now time.Time) []string {
+	if k, ok := c.DeprecatedKind(kind); ok && k.ReplacedBy != "" && k.InGrace(now) {
+		return []string{kind, k.ReplacedBy}
+	}
+	return []string{kind}
+}
+
+func (c *Config) validateKindDeprecations() error {
+	if m := c.Attestations.OnDeprecated; m != "" && m != "warn" && m != "reject" {
+		return fmt.Errorf("config.attestations.on_deprecated must be warn or reject")
+	}
+	for kind, k := range c.Attestations.Catalog {
+		if !k.Deprecated && (k.ReplacedBy != "" || k.GraceUntil != "") {
+			return fmt.Errorf("attestation kind %s: replaced_by and grace_until need deprecated: true", kind)
+		}
+		if k.ReplacedBy != "" {
+			if k.ReplacedBy == kind {
+				return fmt.Errorf("attestation kind %s cannot replace itself", kind)
+			}
+			if _, ok := c.Attestations.Catalog[k.ReplacedBy]; !ok {
+				return fmt.Errorf("attestation kind %s is replaced by unknown kind %s", kind, k.ReplacedBy)
+			}
+		}
+		if k.GraceUntil != "" {
+			if k.ReplacedBy == "" {
+				return fmt.Errorf("attestation kind %s: grace_until needs replaced_by", kind)
+			}
+			if _, err := time.Parse(time.DateOnly, k.GraceUntil); err != nil {
+				return fmt.Errorf("attestation kind %s: grace_until must be a YYYY-MM-DD date", kind)
+			}
+		}
+	}
+	return nil
+}
+
+// SplitRequirement splits a policy requirement. "security.ok+security.countersign"
+// requires a security.ok attestation that is itself attested with security.countersign.
+// A payload predicate on the kind is dropped; see ParseRequirement.
+func SplitRequirement(req string) (kind, countersign string) {
+	r := ParseRequirement(req)
+	return r.Kind, r.Countersign
+}
+
+// Requirement is a parsed policy requirement.
+type Requirement struct {
+	Kind string
+	// Predicate is an expression over the attestation (payload.*, actor_id, ts) that an
+	// attestation of Kind must satisfy, e.g. "payload.critical == 0". Empty when unset.
+	Predicate   string
+	Countersign string
+}
+
+// ParseRequirement reads "kind" and "kind+countersign", either with a payload predicate on
+// the kind, bracketed after it, "security.scan[payload.critical == 0]", or trailing after
+// where, "coverage.report where payload.percent >= 80".
+func ParseRequirement(req string) Requirement {
+	open := strings.Index(req, "[")
+	if where := strings.Index(req, " where "); where >= 0 && (open < 0 || where < open) {
+		kind, countersign, _ := strings.Cut(strings.TrimSpace(req[:where]), "+")
+		return Requirement{Kind: kind, Predicate: strings.TrimSpace(req[where+len(" where "):]), Countersign: countersign}
+	}
+	if open < 0 {
+		kind, countersign, _ := strings.Cut(req, "+")
+		return Requirement{Kind: kind, Countersign: countersign}
+	}
+	r := Requirement{Kind: req[:open]}
+	rest := req[open+1:]
+	end := strings.LastIndex(rest, "]")
+	if end < 0 {
+		r.Predicate = rest
+		return r
+	}
+	r.Predicate = strings.TrimSpace(rest[:end])
+	r.Countersign, _ = strings.CutPrefix(rest[end+1:], "+")
+	return r
+}
+
+// ValidateRequirement checks the syntax of a policy requirement, including that its payload
+// predicate parses.
+func ValidateRequirement(req string) error {
+	r := ParseRequirement(req)
+	if r.Kind == "" {
+		return errEmptyKind
+	}
+	outside := req
+	switch where := strings.Index(req, " where "); {
+	case where >= 0 && !strings.Contains(req[:where], "["):
+		if r.Predicate == "" {
+			return errors.New("where needs a payload predicate")
+		}
+		outside = req[:where]
+	case strings.Contains(req, "["):
+		end := strings.LastIndex(req, "]")
+		if end < 0 || r.Predicate == "" || (req[end+1:] != "" && !strings.HasPrefix(req[end+1:], "+")) {
+			return errors.New("the payload predicate must be a non-empty [..] right after the kind")
+		}
+		outside = req[end+1:]
+	}
+	if r.Predicate != "" {
+		if _, err := expr.Parse(r.Predicate); err != nil {
+			return fmt.Errorf("invalid payload predicate: %w", err)
+		}
+	}
+	if strings.Contains(outside, "+") && r.Countersign == "" {
+		return errEmptyKind
+	}
+	return nil
+}
+
+var errEmptyKind = errors.New("empty attestation kind")
+
+// ValidAuthorityKind checks the kind of an attestation authority: an exact kind, or a
+// pattern "<prefix>.*" covering every kind below prefix (ci.* covers ci.passed and
+// ci.nightly.passed).
+func ValidAuthorityKind(kind string) error {
+	if kind == "" {
+		return fmt.Errorf("invalid attestation authority: kind is required")
+	}
+	prefix, wildcard := strings.CutSuffix(kind, ".*")
+	if strings.Contains(prefix, "*") || (wildcard && prefix == "") {
+		return fmt.Errorf("invalid attestation authority kind %q: wildcards must be a trailing .* after a prefix, e.g. ci.*", kind)
+	}
+	return nil
+}
+
+// AuthorityFor picks, among granted authority kinds, the one governing kind: the exact
+// kind when granted, else the matching pattern with the longest prefix.
+func AuthorityFor(granted []string, kind string) (string, bool) {
+	best, found := "", false
+	for _, g := range granted {
+		if g == kind {
+			return g, true
+		}
+		prefix, wildcard := strings.CutSuffix(g, "*")
+		if wildcard && strings.HasPrefix(kind, prefix) && len(g) > len(best) {
+			best, found = g, true
+		}
+	}
+	return best, found
+}
+
+// Integration configures an inbound webhook provider (gitlab, bitbucket).
+type Integration struct {
+	// SecretEnv names the environment variable holding the webhook secret; secrets never live in
+	// config. Optional when webhook secrets are rotated in as project secrets.
+	SecretEnv string `yaml:"secret_env"`
+	// ActorID attests on behalf of the provider and needs authority for the mapped kinds.
+	ActorID        string            `yaml:"actor_id"`
+	TaskRefPattern string            `yaml:"task_ref_pattern"`
+	Kinds          map[string]string `yaml:"kinds"`
+}
+
+// NotificationChannel posts event summaries to Slack or a Matrix room, or mails them through
+// an SMTP server (type email) to the project members who opted in for the event.
+// Events lists event types plus the derived triggers iteration.validated, waiver.granted and
+// auth.denied.spike.
+// Filter optionally narrows those events with an expression over the event (type, entity_kind,
+// entity_id, actor_id, project_id, ts, payload.*) and, for task events, the task (task.type,
+// task.status, ...), e.g. `type == "task.done" && task.type == "feature"`.
+type NotificationChannel struct {
+	Type          string   `yaml:"type"`
+	WebhookURLEnv string   `yaml:"webhook_url_env"`
+	Homeserver    string   `yaml:"homeserver"`
+	RoomID        string   `yaml:"room_id"`
+	TokenEnv      string   `yaml:"token_env"`
+	Events        []string `yaml:"events"`
+	Filter        string   `yaml:"filter"`
+	// SpikeThreshold denials within SpikeWindow raise auth.denied.spike (defaults 5 within 10m).
+	SpikeThreshold int    `yaml:"spike_threshold"`
+	SpikeWindow    string `yaml:"spike_window"`
+	// SMTPAddr (host:port) and From configure email channels; credentials, when the server
+	// needs them, are read from the SMTPUsernameEnv and SMTPPasswordEnv variables.
+	SMTPAddr        string `yaml:"smtp_addr"`
+	From            string `yaml:"from"`
+	SMTPUsernameEnv string `yaml:"smtp_username_env"`
+	SMTPPasswordEnv string `yaml:"smtp_password_env"`
+}
+
+// EmailEvents are the events email channels relay and actors can opt in to: decisions made,
+// iterations validated and waivers granted.
+var EmailEvents = []string{"decision.created", "iteration.validated", "waiver.granted"}
+
+// TaskStatuses lists every task status in lifecycle order.
+var TaskStatuses = []string{"planned", "in_progress", "review", "done", "rejected", "canceled"}
+
+// DefaultTaskTransitions is the built-in task state machine used when config sets none.
+var DefaultTaskTransitions = map[string][]string{
+	"planned":     {"in_progress", "canceled", "review", "done"},
+	"in_progress": {"rejected", "canceled", "review", "done"},
+	"review":      {"done", "rejected"},
+	"rejected":    {"planned"},
+}
+
+// TaskTransitions returns the transition table for a task type.
+func (c *Config) TaskTransitions(taskType string) map[string][]string {
+	if c != nil {
+		if t, ok := c.Transitions.Types[taskType]; ok {
+			return t
+		}
+		if c.Transitions.Task != nil {
+			return c.Transitions.Task
+		}
+	}
+	return DefaultTaskTransitions
+}
+
+func validateTransitions(scope string, table map[string][]string) error {
+	known := map[string]bool{}
+	for _, st := range TaskStatuses {
+		known[st] = true
+	}
+	for from, targets := range table {
+		if !known[from] {
+			return fmt.Errorf("config.transitions.%s: unknown status %s", scope, from)
+		}
+		for _, to := range targets {
+			if !known[to] {
+				return fmt.Errorf("config.transitions.%s: %s lists unknown status %s", scope, from, to)
+			}
+			if to == from {
+				return fmt.Errorf("config.transitions.%s: %s cannot transition to itself", scope, from)
+			}
+		}
+	}
+	return nil
+}
+
+// IDScheme selects how new entity IDs are generated: uuid (default), ulid,
+// sequential (Prefix-N, e.g. PL-123) or client (callers must supply the ID).
+type IDScheme struct {
+	Strategy string `yaml:"strategy"`
+	Prefix   string `yaml:"prefix"`
+}
+
+var idPrefixPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+func (s IDScheme) validate(entity string) error {
+	switch s.Strategy {
+	case "", "uuid", "ulid", "client":
+		if s.Prefix != "" {
+			return fmt.Errorf("config.ids.%s: prefix only applies to the sequential strategy", entity)
+		}
+	case "sequential":
+		if !idPrefixPattern.MatchString(s.Prefix) {
+			return fmt.Errorf("config.ids.%s: sequential strategy requires an alphanumeric prefix", entity)
+		}
+	default:
+		return fmt.Errorf("config.ids.%s: strategy must be uuid, ulid, sequential or client", entity)
+	}
+	return nil
+}
+
+// Rollup sets how parent task statuses relate to their subtasks. ParentStatus manual, the
+// default, leaves them to callers; children computes them from the subtasks.
+type Rollup struct {
+	ParentStatus string `yaml:"parent_status"`
+}
+
+// FromChildren reports whether parent task statuses are computed from their subtasks.
+func (r Rollup) FromChildren() bool {
+	return r.ParentStatus == "children"
+}
+
+// Completion sets how tasks are completed. Mode direct, the default, lets done finish a
+// task; approval makes done submit the task for review, and only an actor holding
+// task.approve confirming the completion finishes it.
+type Completion struct {
+	Mode string `yaml:"mode"`
+}
+
+// RequiresApproval reports whether completions wait for an approver.
+func (c Completion) RequiresApproval() bool {
+	return c.Mode == "approval"
+}
+
+// Scheduler sets how the ready queue and claim-next order tasks. Mode fifo, the default,
+// serves the oldest task first; deadline serves the earliest effective deadline first. A
+// task's effective deadline is the earliest due_at among it and the open tasks waiting on
+// it, brought forward by ChainWeight (a duration, 1h by default) for each task on the
+// longest chain still waiting on it. Tasks without a deadline follow, oldest first.
+type Scheduler struct {
+	Mode        string `yaml:"mode"`
+	ChainWeight string `yaml:"chain_weight"`
+}
+
+// Deadline reports whether the ready queue is ordered by deadline.
+func (s Scheduler) Deadline() bool {
+	return s.Mode == "deadline"
+}
+
+// ChainStep returns how far each waiting task brings a deadline forward.
+func (s Scheduler) ChainStep() time.Duration {
+	if v, err := time.ParseDuration(s.ChainWeight); err == nil && v >= 0 {
+		return v
+	}
+	return time.Hour
+}
+
+// HTTP sets how wl serve answers browsers. CORS lets dashboards served from other origins
+// call the API directly; it is off until AllowedOrigins lists them. SecurityHeaders sends
+// standard security headers on every response and is on unless set to false.
+type HTTP struct {
+	CORS            CORS  `yaml:"cors"`
+	SecurityHeaders *bool `yaml:"security_headers"`
+}
+
+// SecurityHeadersEnabled reports whether responses carry the security headers.
+func (h HTTP) SecurityHeadersEnabled() bool {
+	return h.SecurityHeaders == nil || *h.SecurityHeaders
+}
+
+// CORS lists the origins (scheme://host[:port], or * for any) allowed to call the API from
+// a browser, with the methods and request headers they may use and the response headers
+// their scripts may read; empty lists use the defaults below. AllowCredentials lets
+// browsers send cookies and client certificates, and cannot be combined with *. MaxAge (a
+// duration, 10m by default) is how long browsers may cache a preflight answer.
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           string   `yaml:"max_age"`
+}
+
+var (
+	// DefaultCORSMethods are the methods the API serves.
+	DefaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	// DefaultCORSHeaders are the request headers the API reads.
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type", "Accept-Language", "If-None-Match", "X-Api-Key", "X-Project-Id", "X-Read-From", "X-Request-Id"}
+	// DefaultCORSExposedHeaders are the response headers the API sets beyond the safelisted ones.
+	DefaultCORSExposedHeaders = []string{"ETag", "Location", "Retry-After", "Warning", "Deprecation", "Link", "Sunset", "X-Request-Id"}
+)
+
+const DefaultCORSMaxAge = 10 * time.Minute
+
+// Enabled reports whether any origin may call the API from a browser.
+func (c CORS) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+func (c CORS) Methods() []string {
+	if len(c.AllowedMethods) > 0 {
+		return c.AllowedMethods
+	}
+	return DefaultCORSMethods
+}
+
+func (c CORS) Headers() []string {
+	if len(c.AllowedHeaders) > 0 {
+		return c.AllowedHeaders
+	}
+	return DefaultCORSHeaders
+}
+
+func (c CORS) Exposed() []string {
+	if len(c.ExposedHeaders) > 0 {
+		return c.ExposedHeaders
+	}
+	return DefaultCORSExposedHeaders
+}
+
+func (c CORS) PreflightMaxAge() time.Duration {
+	if d, err := time.ParseDuration(c.MaxAge); err == nil && d >= 0 {
+		return d
+	}
+	return DefaultCORSMaxAge
+}
+
+func (c CORS) validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("config.http.cors: allow_credentials cannot be used with the * origin")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("config.http.cors: invalid origin %q: use scheme://host[:port] or *", origin)
+		}
+	}
+	for _, m := range c.AllowedMethods {
+		if m == "" || strings.ToUpper(m) != m || strings.ContainsAny(m, " ,") {
+			return fmt.Errorf("config.http.cors: invalid method %q: use upper-case method names", m)
+		}
+	}
+	for _, list := range [][]string{c.AllowedHeaders, c.ExposedHeaders} {
+		for _, h := range list {
+			if h == "" || strings.ContainsAny(h, " ,:") {
+				return fmt.Errorf("config.http.cors: invalid header name %q", h)
+			}
+		}
+	}
+	if c.MaxAge != "" {
+		if d, err := time.ParseDuration(c.MaxAge); err != nil || d < 0 {
+			return fmt.Errorf("config.http.cors: invalid max_age %q", c.MaxAge)
+		}
+	}
+	return nil
+}
+
+// Experimental features a project can switch with flags.
+const (
+	FeatureGraphQL    = "graphql"
+	FeatureLeaseQueue = "lease_queue"
+)
+
+// Feature describes an experimental feature and whether it is on when flags leave it unset.
+type Feature struct {
+	Name        string
+	Description string
+	Default     bool
+}
+
+// Features lists the experimental features flags can switch, in name order.
+var Features = []Feature{
+	{Name: FeatureGraphQL, Description: "Read-only GraphQL API; the server must also run with --graphql", Default: true},
+	{Name: FeatureLeaseQueue, Description: "Queueing for task leases held by another actor (claim with wait, lease waiters)", Default: true},
+}
+
+// FeatureEnabled reports whether the project's flags leave the named feature on.
+func (c *Config) FeatureEnabled(name string) bool {
+	if on, ok := c.Flags[name]; ok {
+		return on
+	}
+	for _, f := range Features {
+		if f.Name == name {
+			return f.Default
+		}
+	}
+	return false
+}
+
+// Digest configures the daily project digest. A lease is reported as stuck once it expired
+// without being released, or when it has been held for StuckLeaseAfter (a duration, 24h by
+// default).
+type Digest struct {
+	StuckLeaseAfter string `yaml:"stuck_lease_after"`
+}
+
+// StuckAfter returns how long a lease may be held before the digest reports it.
+func (d Digest) StuckAfter() time.Duration {
+	if v, err := time.ParseDuration(d.StuckLeaseAfter); err == nil && v > 0 {
+		return v
+	}
+	return 24 * time.Hour
+}
+
+// Freshness flags tasks waiting in review on attestations older than their kind's window.
+// Windows maps attestation kinds to durations; kinds without a window never go stale. A
+// task is checked once it has been in review for ReviewAfter (a duration, 24h by default).
+type Freshness struct {
+	ReviewAfter string            `yaml:"review_after"`
+	Windows     map[string]string `yaml:"windows"`
+}
+
+// ReviewThreshold returns how long a task stays in review before its attestations are checked.
+func (f Freshness) ReviewThreshold() time.Duration {
+	if v, err := time.ParseDuration(f.ReviewAfter); err == nil && v > 0 {
+		return v
+	}
+	return 24 * time.Hour
+}
+
+// Window returns how long attestations of kind stay fresh, when kind has a window.
+func (f Freshness) Window(kind string) (time.Duration, bool) {
+	v, err := time.ParseDuration(f.Windows[kind])
+	return v, err == nil && v > 0
+}
+
+// Quotas caps the API calls an actor makes in the project per UTC day, by role. An actor
+// is limited only when every role it holds has a quota, and then by the most generous one.
+type Quotas struct {
+	Roles map[string]Quota `yaml:"roles"`
+}
+
+// Quota is a daily limit per actor. Zero leaves that count unlimited.
+type Quota struct {
+	Requests  int `yaml:"requests"`
+	Mutations int `yaml:"mutations"`
+}
+
+// DailyLimit returns the requests and mutations allowed per day to an actor holding roles,
+// zero meaning unlimited.
+func (q Quotas) DailyLimit(roles []string) (requests, mutations int) {
+	if len(roles) == 0 {
+		return 0, 0
+	}
+	unlimitedRequests, unlimitedMutations := false, false
+	for _, role := range roles {
+		quota, ok := q.Roles[role]
+		if !ok {
+			return 0, 0
+		}
+		unlimitedRequests = unlimitedRequests || quota.Requests == 0
+		unlimitedMutations = unlimitedMutations || quota.Mutations == 0
+		requests = max(requests, quota.Requests)
+		mutations = max(mutations, quota.Mutations)
+	}
+	if unlimitedRequests {
+		requests = 0
+	}
+	if unlimitedMutations {
+		mutations = 0
+	}
+	return requests, mutations
+}
+
 type RBACRole struct {
 	Description string   `yaml:"description"`
 	Permissions []string `yaml:"permissions"`
@@ -72,12 +879,20 @@ func (c *Config) Validate() error {
 	}
 	for name, preset := range c.Policies.Presets {
 		for _, req := range preset.Require {
-			if req == "" {
+			if err := ValidateRequirement(req); errors.Is(err, errEmptyKind) {
 				return fmt.Errorf("preset %s has empty attestation kind", name)
+			} else if err != nil {
+				return fmt.Errorf("preset %s: requirement %s: %w", name, req, err)
 			}
+			kind, countersign := SplitRequirement(req)
 			if len(c.Attestations.Catalog) > 0 {
-				if _, ok := c.Attestations.Catalog[req]; !ok {
-					return fmt.Errorf("preset %s requires unknown attestation kind %s", name, req)
+				for _, k := range []string{kind, countersign} {
+					if k == "" {
+						continue
+					}
+					if _, ok := c.Attestations.Catalog[k]; !ok {
+						return fmt.Errorf("preset %s requires unknown attestation kind %s", name, k)
+					}
 				}
 			}
 		}
@@ -93,6 +908,9 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("default task preset %s for type %s not defined", preset, taskType)
 		}
 	}
+	if err := c.validateRisk(); err != nil {
+		return err
+	}
 	requiredKind := c.Policies.Defaults.Iteration.Validation.Require
 	if requiredKind != "" && len(c.Attestations.Catalog) > 0 {
 		if _, ok := c.Attestations.Catalog[requiredKind]; !ok {
@@ -118,6 +936,9 @@ func (c *Config) Validate() error {
 		if kind == "" {
 			return fmt.Errorf("config.rbac.attestation_authorities has empty kind")
 		}
+		if err := ValidAuthorityKind(kind); err != nil {
+			return err
+		}
 		for _, roleID := range roles {
 			if roleID == "" {
 				return fmt.Errorf("attestation kind %s has empty role id", kind)
@@ -129,6 +950,219 @@ func (c *Config) Validate() error {
 			}
 		}
 	}
+	for provider, integ := range c.Integrations {
+		if integ.ActorID == "" {
+			return fmt.Errorf("integration %s: actor_id is required", provider)
+		}
+		if len(integ.Kinds) == 0 {
+			return fmt.Errorf("integration %s: kinds mapping is required", provider)
+		}
+		for event, kind := range integ.Kinds {
+			if event == "" || kind == "" {
+				return fmt.Errorf("integration %s has empty kind mapping", provider)
+			}
+			if len(c.Attestations.Catalog) > 0 {
+				if _, ok := c.Attestations.Catalog[kind]; !ok {
+					return fmt.Errorf("integration %s maps %s to unknown attestation kind %s", provider, event, kind)
+				}
+			}
+		}
+	}
+	for name, ch := range c.Notifications.Channels {
+		switch ch.Type {
+		case "slack":
+			if ch.WebhookURLEnv == "" {
+				return fmt.Errorf("notification channel %s: webhook_url_env is required for slack", name)
+			}
+		case "matrix":
+			if ch.Homeserver == "" || ch.RoomID == "" || ch.TokenEnv == "" {
+				return fmt.Errorf("notification channel %s: homeserver, room_id and token_env are required for matrix", name)
+			}
+		case "email":
+			if ch.SMTPAddr == "" || ch.From == "" {
+				return fmt.Errorf("notification channel %s: smtp_addr and from are required for email", name)
+			}
+			if _, _, err := net.SplitHostPort(ch.SMTPAddr); err != nil {
+				return fmt.Errorf("notification channel %s: smtp_addr must be host:port", name)
+			}
+			if _, err := mail.ParseAddress(ch.From); err != nil {
+				return fmt.Errorf("notification channel %s: invalid from address %q", name, ch.From)
+			}
+			for _, evt := range ch.Events {
+				if !slices.Contains(EmailEvents, evt) {
+					return fmt.Errorf("notification channel %s: email channels relay only %s", name, strings.Join(EmailEvents, ", "))
+				}
+			}
+		default:
+			return fmt.Errorf("notification channel %s: type must be slack, matrix or email", name)
+		}
+		if len(ch.Events) == 0 {
+			return fmt.Errorf("notification channel %s: events is required", name)
+		}
+		if ch.SpikeThreshold < 0 {
+			return fmt.Errorf("notification channel %s: spike_threshold must be positive", name)
+		}
+		if ch.Filter != "" {
+			if _, err := expr.Parse(ch.Filter); err != nil {
+				return fmt.Errorf("notification channel %s: filter: %w", name, err)
+			}
+		}
+		if ch.SpikeWindow != "" {
+			if d, err := time.ParseDuration(ch.SpikeWindow); err != nil || d <= 0 {
+				return fmt.Errorf("notification channel %s: invalid spike_window %q", name, ch.SpikeWindow)
+			}
+		}
+	}
+	if err := validateTransitions("task", c.Transitions.Task); err != nil {
+		return err
+	}
+	for taskType, table := range c.Transitions.Types {
+		if taskType == "" {
+			return fmt.Errorf("config.transitions.types contains empty task type")
+		}
+		if err := validateTransitions("types."+taskType, table); err != nil {
+			return err
+		}
+	}
+	if err := c.IDs.Tasks.validate("tasks"); err != nil {
+		return err
+	}
+	if err := c.IDs.Attestations.validate("attestations"); err != nil {
+		return err
+	}
+	if c.Payloads.MaxBytes < 0 || c.Payloads.InlineMaxBytes < 0 || c.Payloads.ArtifactMaxBytes < 0 {
+		return fmt.Errorf("config.payloads: sizes must be positive")
+	}
+	if c.Payloads.InlineMax() > c.Payloads.Max() {
+		return fmt.Errorf("config.payloads: inline_max_bytes cannot exceed max_bytes")
+	}
+	for role, quota := range c.Quotas.Roles {
+		if quota.Requests < 0 || quota.Mutations < 0 {
+			return fmt.Errorf("config.quotas.roles.%s: limits must be positive", role)
+		}
+	}
+	if err := c.validateProfiles(); err != nil {
+		return err
+	}
+	if err := c.Backup.validate(); err != nil {
+		return err
+	}
+	if err := c.HTTP.CORS.validate(); err != nil {
+		return err
+	}
+	if err := c.validateKindDeprecations(); err != nil {
+		return err
+	}
+	for name := range c.Flags {
+		if !slices.ContainsFunc(Features, func(f Feature) bool { return f.Name == name }) {
+			names := make([]string, 0, len(Features))
+			for _, f := range Features {
+				names = append(names, f.Name)
+			}
+			return fmt.Errorf("config.flags: unknown feature %q (known: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	if v := c.Attestations.Freshness.ReviewAfter; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("config.attestations.freshness.review_after must be a positive duration such as 48h")
+		}
+	}
+	for kind, v := range c.Attestations.Freshness.Windows {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("config.attestations.freshness.windows.%s must be a positive duration such as 168h", kind)
+		}
+		if len(c.Attestations.Catalog) > 0 {
+			if _, ok := c.Attestations.Catalog[kind]; !ok {
+				return fmt.Errorf("config.attestations.freshness.windows references unknown attestation kind %s", kind)
+			}
+		}
+	}
+	if v := c.Digest.StuckLeaseAfter; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("config.digest.stuck_lease_after: invalid duration %q", v)
+		}
+	}
+	if p := c.Rollup.ParentStatus; p != "" && p != "manual" && p != "children" {
+		return fmt.Errorf("config.rollup.parent_status must be manual or children")
+	}
+	if m := c.Completion.Mode; m != "" && m != "direct" && m != "approval" {
+		return fmt.Errorf("config.completion.mode must be direct or approval")
+	}
+	if m := c.Scheduler.Mode; m != "" && m != "fifo" && m != "deadline" {
+		return fmt.Errorf("config.scheduler.mode must be fifo or deadline")
+	}
+	if v := c.Scheduler.ChainWeight; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("config.scheduler.chain_weight: invalid duration %q", v)
+		}
+	}
+	for name, tt := range c.TaskTypes {
+		if !taskTypePattern.MatchString(name) {
+			return fmt.Errorf("task type %q must be lowercase letters, digits, '.', '_' or '-'", name)
+		}
+		if tt.Fields != nil {
+			if typ, ok := tt.Fields["type"]; ok && typ != "object" {
+				return fmt.Errorf("task type %s: fields schema must describe an object", name)
+			}
+		}
+		for _, field := range tt.Indexed {
+			if !ValidCustomFieldName(field) {
+				return fmt.Errorf("task type %s: indexed field %q must be lowercase letters, digits or '_'", name, field)
+			}
+		}
+		for _, field := range tt.RequiredOutcomes {
+			if !ValidCustomFieldName(field) {
+				return fmt.Errorf("task type %s: required outcome %q must be lowercase letters, digits or '_'", name, field)
+			}
+		}
+	}
+	if n := len(c.IndexedCustomFields()); n > MaxIndexedCustomFields {
+		return fmt.Errorf("task types index %d custom fields, above the limit of %d", n, MaxIndexedCustomFields)
+	}
+	for id, control := range c.Compliance.Controls {
+		if id == "" {
+			return fmt.Errorf("config.compliance.controls contains empty control id")
+		}
+		if len(control.Kinds) == 0 {
+			return fmt.Errorf("compliance control %s: kinds is required", id)
+		}
+		for _, req := range control.Kinds {
+			if err := ValidateRequirement(req); errors.Is(err, errEmptyKind) {
+				return fmt.Errorf("compliance control %s has empty attestation kind", id)
+			} else if err != nil {
+				return fmt.Errorf("compliance control %s: requirement %s: %w", id, req, err)
+			}
+			kind, countersign := SplitRequirement(req)
+			if len(c.Attestations.Catalog) > 0 {
+				for _, k := range []string{kind, countersign} {
+					if k == "" {
+						continue
+					}
+					if _, ok := c.Attestations.Catalog[k]; !ok {
+						return fmt.Errorf("compliance control %s maps unknown attestation kind %s", id, k)
+					}
+				}
+			}
+		}
+		for _, target := range control.AppliesTo {
+			if target != "task" && target != "iteration" {
+				return fmt.Errorf("compliance control %s: applies_to must be task or iteration", id)
+			}
+		}
+	}
+	switch c.Blobs.Store {
+	case "", "workspace":
+	case "s3":
+		if c.Blobs.S3.Bucket == "" {
+			return fmt.Errorf("config.blobs.s3.bucket is required for the s3 store")
+		}
+	case "gcs":
+		if c.Blobs.GCS.Bucket == "" {
+			return fmt.Errorf("config.blobs.gcs.bucket is required for the gcs store")
+		}
+	default:
+		return fmt.Errorf("config.blobs.store must be workspace, s3 or gcs")
+	}
 	return nil
 }
 
@@ -202,12 +1236,20 @@ attestations:
       description: "Task is sized, dependencies known"
     ci.passed:
       description: "CI pipeline completed successfully"
+    tests.passed:
+      description: "Test report ingested without failures"
+    tests.failed:
+      description: "Test report ingested with failures"
+    coverage.report:
+      description: "Coverage report; payload.percent holds the line coverage"
     review.approved:
       description: "Code review approved"
     acceptance.passed:
       description: "Acceptance criteria validated"
     security.ok:
       description: "Security checks passed"
+    security.scan:
+      description: "SARIF security scan ingested; the payload counts findings by severity"
     iteration.approved:
       description: "Iteration approved"
     workshop.discovery.completed:
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var profilePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Leases bounds task lease terms. DefaultTTL is the term of claims that ask for none
+// (default 15m); MaxTTL, when set, is the longest term a claim may ask for.
+type Leases struct {
+	DefaultTTL string `yaml:"default_ttl"`
+	MaxTTL     string `yaml:"max_ttl"`
+}
+
+const DefaultLeaseTTL = 15 * time.Minute
+
+func (l Leases) Default() time.Duration {
+	if d, err := time.ParseDuration(l.DefaultTTL); err == nil && d > 0 {
+		return d
+	}
+	return DefaultLeaseTTL
+}
+
+// Max returns the longest lease term allowed, zero meaning unlimited.
+func (l Leases) Max() time.Duration {
+	if d, err := time.ParseDuration(l.MaxTTL); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (l Leases) validate(scope string) error {
+	for name, v := range map[string]string{"default_ttl": l.DefaultTTL, "max_ttl": l.MaxTTL} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d < time.Second {
+			return fmt.Errorf("%s.leases: %s must be a duration of at least 1s", scope, name)
+		}
+	}
+	return nil
+}
+
+// Auth sets how strictly wl serve authenticates. DevLogin serves POST /auth/dev/login, which
+// mints a token for any actor without credentials; it is on unless set to false, and
+// production profiles should turn it off.
+type Auth struct {
+	DevLogin *bool `yaml:"dev_login"`
+}
+
+// DevLoginEnabled reports whether the dev login endpoint is served.
+func (a Auth) DevLoginEnabled() bool {
+	return a.DevLogin == nil || *a.DevLogin
+}
+
+// Profile overrides settings for one environment (dev, staging, prod, ...). Lease fields and
+// dev_login replace the base values when set; quotas replace the base quota of each role
+// they name and leave the other roles alone.
+type Profile struct {
+	Leases Leases `yaml:"leases"`
+	Auth   Auth   `yaml:"auth"`
+	Quotas Quotas `yaml:"quotas"`
+}
+
+// UseEnvironment selects the profile Effective applies; an empty env selects none. When the
+// config declares profiles, env must name one of them so that a mistyped environment does
+// not silently run with the base settings.
+func (c *Config) UseEnvironment(env string) error {
+	if env != "" && len(c.Profiles) > 0 {
+		if _, ok := c.Profiles[env]; !ok {
+			return fmt.Errorf("config.profiles: unknown environment %q (known: %s)", env, strings.Join(c.ProfileNames(), ", "))
+		}
+	}
+	c.Environment = env
+	return nil
+}
+
+// ProfileNames lists the declared profiles in name order.
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// Effective returns the config with the profile of its environment applied, or c itself
+// when no profile applies. The result shares unchanged fields with c and must not be
+// stored: project configs are persisted without overrides.
+func (c *Config) Effective() *Config {
+	p, ok := c.Profiles[c.Environment]
+	if c.Environment == "" || !ok {
+		return c
+	}
+	eff := *c
+	if p.Leases.DefaultTTL != "" {
+		eff.Leases.DefaultTTL = p.Leases.DefaultTTL
+	}
+	if p.Leases.MaxTTL != "" {
+		eff.Leases.MaxTTL = p.Leases.MaxTTL
+	}
+	if p.Auth.DevLogin != nil {
+		eff.Auth.DevLogin = p.Auth.DevLogin
+	}
+	if len(p.Quotas.Roles) > 0 {
+		eff.Quotas.Roles = maps.Clone(c.Quotas.Roles)
+		if eff.Quotas.Roles == nil {
+			eff.Quotas.Roles = map[string]Quota{}
+		}
+		maps.Copy(eff.Quotas.Roles, p.Quotas.Roles)
+	}
+	return &eff
+}
+
+// Overrides lists, in order, the settings the profile of the environment overrides, named
+// by their YAML path.
+func (c *Config) Overrides() []string {
+	p, ok := c.Profiles[c.Environment]
+	if c.Environment == "" || !ok {
+		return nil
+	}
+	var paths []string
+	if p.Leases.DefaultTTL != "" {
+		paths = append(paths, "leases.default_ttl")
+	}
+	if p.Leases.MaxTTL != "" {
+		paths = append(paths, "leases.max_ttl")
+	}
+	if p.Auth.DevLogin != nil {
+		paths = append(paths, "auth.dev_login")
+	}
+	for _, role := range slices.Sorted(maps.Keys(p.Quotas.Roles)) {
+		paths = append(paths, "quotas.roles."+role)
+	}
+	return paths
+}
+
+func (c *Config) validateProfiles() error {
+	if err := c.Leases.validate("config"); err != nil {
+		return err
+	}
+	if err := checkLeaseBounds("config", c.Leases); err != nil {
+		return err
+	}
+	for _, name := range c.ProfileNames() {
+		p := c.Profiles[name]
+		scope := "config.profiles." + name
+		if !profilePattern.MatchString(name) {
+			return fmt.Errorf("config.profiles: invalid name %q", name)
+		}
+		if err := p.Leases.validate(scope); err != nil {
+			return err
+		}
+		for role, quota := range p.Quotas.Roles {
+			if quota.Requests < 0 || quota.Mutations < 0 {
+				return fmt.Errorf("%s.quotas.roles.%s: limits must be positive", scope, role)
+			}
+		}
+		eff := *c
+		eff.Environment = name
+		if err := checkLeaseBounds(scope, eff.Effective().Leases); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkLeaseBounds(scope string, l Leases) error {
+	if max := l.Max(); max > 0 && l.Default() > max {
+		return fmt.Errorf("%s.leases: default_ttl cannot exceed max_ttl", scope)
+	}
+	return nil
+}
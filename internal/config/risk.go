@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RiskPolicy scores tasks by adding the weight of their type, of each of their labels and of
+// each attestation kind they touch (required or attested). The highest level whose Min the
+// score reaches applies: its preset replaces the type default at creation and its attesters
+// must each attest the task before it can be done.
+type RiskPolicy struct {
+	Types  map[string]int `yaml:"types"`
+	Labels map[string]int `yaml:"labels"`
+	Kinds  map[string]int `yaml:"kinds"`
+	Levels []RiskLevel    `yaml:"levels"`
+}
+
+// RiskLevel is a band of risk scores, listed in ascending Min order. Preset, when set,
+// names the policy preset tasks created at this level get; Attesters lists roles that must
+// each be held by the actor of at least one attestation on the task.
+type RiskLevel struct {
+	Name      string   `yaml:"name"`
+	Min       int      `yaml:"min"`
+	Preset    string   `yaml:"preset"`
+	Attesters []string `yaml:"attesters"`
+}
+
+// Enabled reports whether the policy declares any level to route tasks to.
+func (p RiskPolicy) Enabled() bool {
+	return len(p.Levels) > 0
+}
+
+// Level returns the highest level whose Min score reaches, or false when score is below
+// the first level.
+func (p RiskPolicy) Level(score int) (RiskLevel, bool) {
+	for i := len(p.Levels) - 1; i >= 0; i-- {
+		if score >= p.Levels[i].Min {
+			return p.Levels[i], true
+		}
+	}
+	return RiskLevel{}, false
+}
+
+func (c *Config) validateRisk() error {
+	risk := c.Policies.Risk
+	for kind := range risk.Kinds {
+		if _, ok := c.Attestations.Catalog[kind]; len(c.Attestations.Catalog) > 0 && !ok {
+			return fmt.Errorf("config.policies.risk.kinds: unknown attestation kind %s", kind)
+		}
+	}
+	var names []string
+	for i, level := range risk.Levels {
+		if level.Name == "" {
+			return fmt.Errorf("config.policies.risk.levels[%d]: name is required", i)
+		}
+		if slices.Contains(names, level.Name) {
+			return fmt.Errorf("config.policies.risk.levels: duplicate level %s", level.Name)
+		}
+		names = append(names, level.Name)
+		if i > 0 && level.Min <= risk.Levels[i-1].Min {
+			return fmt.Errorf("config.policies.risk.levels: %s must have a higher min than %s", level.Name, risk.Levels[i-1].Name)
+		}
+		if level.Preset != "" {
+			if _, ok := c.Policies.Presets[level.Preset]; !ok {
+				return fmt.Errorf("risk level %s uses undefined preset %s", level.Name, level.Preset)
+			}
+		}
+		for _, role := range level.Attesters {
+			if role == "" {
+				return fmt.Errorf("risk level %s has empty attester role", level.Name)
+			}
+			if _, ok := c.RBAC.Roles[role]; len(c.RBAC.Roles) > 0 && !ok {
+				return fmt.Errorf("risk level %s references unknown role %s", level.Name, role)
+			}
+		}
+	}
+	return nil
+}
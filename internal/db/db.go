@@ -3,8 +3,10 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -13,6 +15,11 @@ const defaultDBName = "workline.db"
 
 type Config struct {
 	Workspace string
+	// SlowQuery logs statements taking at least this long to Logger (0 disables).
+	SlowQuery time.Duration
+	Logger    *log.Logger
+	// Stats, when set, collects per-statement counts and latencies.
+	Stats *QueryStats
 }
 
 func dbPath(workspace string) string {
@@ -31,13 +38,13 @@ func EnsureWorkspace(workspace string) (string, error) {
 	return path, nil
 }
 
-// Open opens the SQLite database with foreign keys on.
+// Open opens the SQLite database with foreign keys on. Statements are timed when a slow
+// query threshold or a stats collector is configured.
 func Open(cfg Config) (*sql.DB, error) {
 	if _, err := EnsureWorkspace(cfg.Workspace); err != nil {
 		return nil, err
 	}
-	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", dbPath(cfg.Workspace))
-	conn, err := sql.Open("sqlite", dsn)
+	conn, err := open(cfg, fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", dbPath(cfg.Workspace)))
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +53,41 @@ func Open(cfg Config) (*sql.DB, error) {
 	return conn, nil
 }
 
+// maxReplicaConns bounds concurrent reads on one replica. Read-only connections never
+// contend for the write lock, so unlike the primary a replica is not limited to one.
+const maxReplicaConns = 4
+
+// OpenReplica opens a read-only copy of a workspace database, such as one kept current by
+// litestream restore, at path. Statements are timed like the primary's per cfg; the
+// workspace in cfg is ignored.
+func OpenReplica(cfg Config, path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("read replica: %w", err)
+	}
+	conn, err := open(cfg, fmt.Sprintf("file:%s?mode=ro&_pragma=query_only(1)&_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(maxReplicaConns)
+	conn.SetMaxIdleConns(maxReplicaConns)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read replica %s: %w", path, err)
+	}
+	return conn, nil
+}
+
+func open(cfg Config, dsn string) (*sql.DB, error) {
+	if cfg.SlowQuery > 0 || cfg.Stats != nil {
+		logger := cfg.Logger
+		if logger == nil {
+			logger = log.Default()
+		}
+		return openInstrumented("sqlite", dsn, &instrumentation{slowQuery: cfg.SlowQuery, logger: logger, stats: cfg.Stats})
+	}
+	return sql.Open("sqlite", dsn)
+}
+
 // Path returns the db path for the workspace.
 func Path(workspace string) string {
 	return dbPath(workspace)
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// latencySamples is how many recent durations each statement keeps for percentiles.
+	latencySamples = 1024
+	// maxStatements bounds the distinct statements tracked; later ones share otherStatement.
+	maxStatements  = 1000
+	otherStatement = "(other statements)"
+	// maxLoggedParam is how many characters of a string parameter a slow query log shows.
+	maxLoggedParam = 32
+)
+
+// QueryStats collects per-statement counts and latencies for an instrumented database.
+// A query is timed from the call until its rows are closed, so reading the results counts.
+type QueryStats struct {
+	mu         sync.Mutex
+	since      time.Time
+	statements map[string]*statementStats
+}
+
+type statementStats struct {
+	count   int64
+	errors  int64
+	slow    int64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+	next    int
+}
+
+// NewQueryStats returns an empty collector.
+func NewQueryStats() *QueryStats {
+	return &QueryStats{since: time.Now(), statements: map[string]*statementStats{}}
+}
+
+// StatementStats reports one statement. Percentiles cover its most recent executions.
+type StatementStats struct {
+	Statement string  `json:"statement"`
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors"`
+	Slow      int64   `json:"slow" doc:"Executions at or above the slow query threshold"`
+	TotalMS   float64 `json:"total_ms"`
+	MeanMS    float64 `json:"mean_ms"`
+	P50MS     float64 `json:"p50_ms"`
+	P95MS     float64 `json:"p95_ms"`
+	P99MS     float64 `json:"p99_ms"`
+	MaxMS     float64 `json:"max_ms"`
+}
+
+// QueryStatsReport lists statements by total time spent, highest first.
+type QueryStatsReport struct {
+	Since           time.Time        `json:"since"`
+	SlowThresholdMS float64          `json:"slow_threshold_ms" doc:"0 when slow query logging is off"`
+	Queries         int64            `json:"queries"`
+	Statements      []StatementStats `json:"statements"`
+}
+
+func (s *QueryStats) record(statement string, d time.Duration, failed, slow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.statements[statement]
+	if !ok {
+		if len(s.statements) >= maxStatements {
+			statement = otherStatement
+			st = s.statements[statement]
+		}
+		if st == nil {
+			st = &statementStats{}
+			s.statements[statement] = st
+		}
+	}
+	st.count++
+	st.total += d
+	st.max = max(st.max, d)
+	if failed {
+		st.errors++
+	}
+	if slow {
+		st.slow++
+	}
+	if len(st.samples) < latencySamples {
+		st.samples = append(st.samples, d)
+	} else {
+		st.samples[st.next] = d
+		st.next = (st.next + 1) % latencySamples
+	}
+}
+
+// Report summarizes the statements recorded so far.
+func (s *QueryStats) Report(slowThreshold time.Duration) QueryStatsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := QueryStatsReport{Since: s.since.UTC(), SlowThresholdMS: ms(slowThreshold), Statements: []StatementStats{}}
+	for statement, st := range s.statements {
+		sorted := slices.Clone(st.samples)
+		slices.Sort(sorted)
+		rep.Queries += st.count
+		rep.Statements = append(rep.Statements, StatementStats{
+			Statement: statement,
+			Count:     st.count,
+			Errors:    st.errors,
+			Slow:      st.slow,
+			TotalMS:   ms(st.total),
+			MeanMS:    ms(st.total / time.Duration(st.count)),
+			P50MS:     ms(percentile(sorted, 0.50)),
+			P95MS:     ms(percentile(sorted, 0.95)),
+			P99MS:     ms(percentile(sorted, 0.99)),
+			MaxMS:     ms(st.max),
+		})
+	}
+	slices.SortFunc(rep.Statements, func(a, b StatementStats) int {
+		if a.TotalMS != b.TotalMS {
+			if a.TotalMS > b.TotalMS {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Statement, b.Statement)
+	})
+	return rep
+}
+
+// percentile picks the nearest-rank value of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// instrumentation times statements on every connection of an instrumented database.
+type instrumentation struct {
+	slowQuery time.Duration
+	logger    *log.Logger
+	stats     *QueryStats
+}
+
+func (in *instrumentation) observe(query string, args []driver.NamedValue, start time.Time, err error) {
+	d := time.Since(start)
+	slow := in.slowQuery > 0 && d >= in.slowQuery
+	statement := normalizeStatement(query)
+	if in.stats != nil {
+		in.stats.record(statement, d, err != nil && err != driver.ErrSkip, slow)
+	}
+	if slow && in.logger != nil {
+		msg := fmt.Sprintf("slow query %s at %s: %s", d.Round(time.Microsecond), queryCaller(), statement)
+		if len(args) > 0 {
+			msg += " [args: " + sanitizeArgs(args) + "]"
+		}
+		in.logger.Print(msg)
+	}
+}
+
+// normalizeStatement collapses whitespace so one statement always reports under one key.
+func normalizeStatement(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// sanitizeArgs renders parameters for the log: strings are cut to maxLoggedParam
+// characters and byte slices are reduced to their length.
+func sanitizeArgs(args []driver.NamedValue) string {
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		switch v := a.Value.(type) {
+		case nil:
+			parts = append(parts, "NULL")
+		case string:
+			if n := utf8.RuneCountInString(v); n > maxLoggedParam {
+				v = string([]rune(v)[:maxLoggedParam]) + fmt.Sprintf("…(%d chars)", n)
+			}
+			parts = append(parts, fmt.Sprintf("%q", v))
+		case []byte:
+			parts = append(parts, fmt.Sprintf("<%d bytes>", len(v)))
+		case time.Time:
+			parts = append(parts, v.UTC().Format(time.RFC3339Nano))
+		default:
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// callerSkipPrefixes are the packages between a query call and its observation.
+var callerSkipPrefixes = []string{"database/sql.", "workline/internal/db.", "runtime.", "sync."}
+
+// queryCaller names the first function on the stack outside database/sql, this package
+// and the runtime, which is the repository or engine code that issued the query.
+func queryCaller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		f, more := frames.Next()
+		if !slices.ContainsFunc(callerSkipPrefixes, func(p string) bool { return strings.HasPrefix(f.Function, p) }) {
+			return fmt.Sprintf("%s (%s:%d)", f.Function[strings.LastIndex(f.Function, "/")+1:], filepath.Base(f.File), f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// instrumentedConnector opens driver connections that report to in.
+type instrumentedConnector struct {
+	dsn    string
+	driver driver.Driver
+	in     *instrumentation
+}
+
+func (c instrumentedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, in: c.in}, nil
+}
+
+func (c instrumentedConnector) Driver() driver.Driver { return c.driver }
+
+// instrumentedConn times ExecContext and QueryContext. The sqlite driver implements both,
+// so database/sql never falls back to prepared statements, which pass through untimed.
+type instrumentedConn struct {
+	driver.Conn
+	in *instrumentation
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	c.in.observe(query, args, start, err)
+	return res, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		c.in.observe(query, args, start, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, done: func(err error) { c.in.observe(query, args, start, err) }}, nil
+}
+
+// instrumentedRows reports its query once the rows are closed.
+type instrumentedRows struct {
+	driver.Rows
+	err  error
+	once sync.Once
+	done func(error)
+}
+
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	r.once.Do(func() { r.done(r.err) })
+	return err
+}
+
+// openInstrumented reopens dsn on the driver registered as driverName with every
+// statement reported to in.
+func openInstrumented(driverName, dsn string, in *instrumentation) (*sql.DB, error) {
+	probe, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := probe.Driver()
+	_ = probe.Close()
+	return sql.OpenDB(instrumentedConnector{dsn: dsn, driver: drv, in: in}), nil
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Auto-vacuum modes reported by PRAGMA auto_vacuum.
+const (
+	autoVacuumNone        = 0
+	autoVacuumIncremental = 2
+)
+
+// FileStats describes the page layout of the database file.
+type FileStats struct {
+	PageSize      int64  `json:"page_size"`
+	PageCount     int64  `json:"page_count"`
+	FreelistCount int64  `json:"freelist_count"`
+	SizeBytes     int64  `json:"size_bytes"`
+	AutoVacuum    string `json:"auto_vacuum"`
+}
+
+// VacuumResult reports a vacuum run.
+type VacuumResult struct {
+	Mode       string    `json:"mode"`
+	Before     FileStats `json:"before"`
+	After      FileStats `json:"after"`
+	PagesFreed int64     `json:"pages_freed"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// IntegrityResult reports PRAGMA integrity_check (or quick_check) and foreign key checks.
+type IntegrityResult struct {
+	OK                   bool                  `json:"ok"`
+	Quick                bool                  `json:"quick"`
+	Problems             []string              `json:"problems"`
+	ForeignKeyViolations []ForeignKeyViolation `json:"foreign_key_violations"`
+	DurationMS           int64                 `json:"duration_ms"`
+}
+
+// ForeignKeyViolation is one row of PRAGMA foreign_key_check.
+type ForeignKeyViolation struct {
+	Table  string `json:"table"`
+	RowID  int64  `json:"rowid"`
+	Parent string `json:"parent"`
+}
+
+// Stats reads the current page layout of the database.
+func Stats(ctx context.Context, conn *sql.DB) (FileStats, error) {
+	var s FileStats
+	var mode int
+	for _, p := range []struct {
+		pragma string
+		dest   any
+	}{
+		{"page_size", &s.PageSize},
+		{"page_count", &s.PageCount},
+		{"freelist_count", &s.FreelistCount},
+		{"auto_vacuum", &mode},
+	} {
+		if err := conn.QueryRowContext(ctx, "PRAGMA "+p.pragma).Scan(p.dest); err != nil {
+			return s, fmt.Errorf("read %s: %w", p.pragma, err)
+		}
+	}
+	s.SizeBytes = s.PageSize * s.PageCount
+	switch mode {
+	case autoVacuumNone:
+		s.AutoVacuum = "none"
+	case autoVacuumIncremental:
+		s.AutoVacuum = "incremental"
+	default:
+		s.AutoVacuum = "full"
+	}
+	return s, nil
+}
+
+// Vacuum reclaims free pages. Incremental mode releases up to pages free pages (all when
+// pages <= 0) and needs auto_vacuum=incremental; full mode rebuilds the file and switches
+// it to auto_vacuum=incremental so later runs can be incremental.
+func Vacuum(ctx context.Context, conn *sql.DB, full bool, pages int) (VacuumResult, error) {
+	res := VacuumResult{Mode: "incremental"}
+	if full {
+		res.Mode = "full"
+	}
+	before, err := Stats(ctx, conn)
+	if err != nil {
+		return res, err
+	}
+	res.Before = before
+	start := time.Now()
+	if full {
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return res, err
+		}
+		if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return res, err
+		}
+	} else {
+		if before.AutoVacuum != "incremental" {
+			return res, errors.New("invalid vacuum: database is not in incremental auto-vacuum mode; run a full vacuum once")
+		}
+		stmt := "PRAGMA incremental_vacuum"
+		if pages > 0 {
+			stmt = fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages)
+		}
+		// incremental_vacuum returns a row per step; drain them so every step runs.
+		rows, err := conn.QueryContext(ctx, stmt)
+		if err != nil {
+			return res, err
+		}
+		for rows.Next() {
+		}
+		if err := rows.Close(); err != nil {
+			return res, err
+		}
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	after, err := Stats(ctx, conn)
+	if err != nil {
+		return res, err
+	}
+	res.After = after
+	res.PagesFreed = before.PageCount - after.PageCount
+	return res, nil
+}
+
+// Copy writes a page-for-page copy of the database open on conn to the new file at path,
+// through SQLite's online backup API. Reading the file directly instead would drop the
+// locks this process holds on it as soon as the copy is closed.
+func Copy(conn *sql.Conn, path string) error {
+	return conn.Raw(func(dc any) error {
+		if ic, ok := dc.(*instrumentedConn); ok {
+			dc = ic.Conn
+		}
+		src, ok := dc.(interface {
+			NewBackup(dstURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("the database driver does not support online backups")
+		}
+		b, err := src.NewBackup(path)
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+		}
+		return b.Finish()
+	})
+}
+
+// CheckIntegrity runs PRAGMA integrity_check, or the cheaper quick_check, followed by
+// PRAGMA foreign_key_check.
+func CheckIntegrity(ctx context.Context, conn *sql.DB, quick bool) (IntegrityResult, error) {
+	res := IntegrityResult{Quick: quick, Problems: []string{}, ForeignKeyViolations: []ForeignKeyViolation{}}
+	start := time.Now()
+	pragma := "PRAGMA integrity_check"
+	if quick {
+		pragma = "PRAGMA quick_check"
+	}
+	rows, err := conn.QueryContext(ctx, pragma)
+	if err != nil {
+		return res, err
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return res, err
+		}
+		if msg != "ok" {
+			res.Problems = append(res.Problems, msg)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return res, err
+	}
+	fkRows, err := conn.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return res, err
+	}
+	defer fkRows.Close()
+	for fkRows.Next() {
+		var v ForeignKeyViolation
+		var rowID sql.NullInt64
+		var fkid int64
+		if err := fkRows.Scan(&v.Table, &rowID, &v.Parent, &fkid); err != nil {
+			return res, err
+		}
+		v.RowID = rowID.Int64
+		res.ForeignKeyViolations = append(res.ForeignKeyViolations, v)
+	}
+	if err := fkRows.Err(); err != nil {
+		return res, err
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	res.OK = len(res.Problems) == 0 && len(res.ForeignKeyViolations) == 0
+	return res, nil
+}
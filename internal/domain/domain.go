@@ -1,5 +1,20 @@
 package domain
 
+// Org groups projects hosted for one team. Tokens, API keys and client certificates are
+// bound to an org and reach only its projects.
+type Org struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at" format:"date-time"`
+}
+
+// OrgMember is an actor's role in an org: owner or member.
+type OrgMember struct {
+	OrgID   string `json:"org_id"`
+	ActorID string `json:"actor_id"`
+	Role    string `json:"role"`
+}
+
 type Project struct {
 	ID          string `json:"id"`
 	OrgID       string `json:"org_id"`
@@ -7,15 +22,41 @@ type Project struct {
 	Status      string `json:"status"`
 	Description string `json:"description,omitempty"`
 	CreatedAt   string `json:"created_at" format:"date-time"`
+	// ParentProjectID places the project under a program that rolls it up.
+	ParentProjectID string         `json:"parent_project_id,omitempty"`
+	DisplayName     string         `json:"display_name,omitempty"`
+	Tags            []string       `json:"tags,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
 }
 
 type Iteration struct {
-	ID        string `json:"id"`
-	OrgID     string `json:"org_id"`
-	ProjectID string `json:"project_id"`
-	Goal      string `json:"goal"`
-	Status    string `json:"status" enum:"pending,running,delivered,validated,rejected"`
-	CreatedAt string `json:"created_at" format:"date-time"`
+	ID         string `json:"id"`
+	OrgID      string `json:"org_id"`
+	ProjectID  string `json:"project_id"`
+	Goal       string `json:"goal"`
+	Status     string `json:"status" enum:"pending,running,delivered,validated,rejected"`
+	CreatedAt  string `json:"created_at" format:"date-time"`
+	CarriedOut int    `json:"carried_out"`
+	CarriedIn  int    `json:"carried_in"`
+	// KeyResults measure the goal; validation requires every one of them to be met.
+	KeyResults []KeyResult `json:"key_results,omitempty"`
+	// FrozenAt is set while the iteration is frozen: running, it refuses new tasks and
+	// loosened task policies without an override.
+	FrozenAt *string `json:"frozen_at,omitempty" format:"date-time"`
+	FrozenBy *string `json:"frozen_by,omitempty"`
+}
+
+// KeyResult is a measurable target of an iteration goal. Current is set by hand or, when
+// SourceKind is set, from the payload of the latest attestation of that kind on the
+// iteration.
+type KeyResult struct {
+	Metric        string  `json:"metric"`
+	Target        float64 `json:"target"`
+	Current       float64 `json:"current"`
+	Direction     string  `json:"direction" enum:"increase,decrease"`
+	SourceKind    string  `json:"source_kind,omitempty"`
+	AttestationID string  `json:"attestation_id,omitempty"`
+	Met           bool    `json:"met"`
 }
 
 type Task struct {
@@ -24,17 +65,29 @@ type Task struct {
 	ProjectID                string   `json:"project_id"`
 	IterationID              *string  `json:"iteration_id,omitempty"`
 	ParentID                 *string  `json:"parent_id,omitempty"`
-	Type                     string   `json:"type" enum:"technical,feature,bug,docs,chore,workshop"`
+	Type                     string   `json:"type" doc:"Built-in (technical, feature, bug, docs, chore, workshop) or declared under task_types"`
 	Title                    string   `json:"title"`
 	Description              string   `json:"description,omitempty"`
 	Status                   string   `json:"status" enum:"planned,in_progress,review,done,rejected,canceled"`
 	AssigneeID               *string  `json:"assignee_id,omitempty"`
 	WorkOutcomesJSON         *string  `json:"work_outcomes_json,omitempty"`
 	RequiredAttestationsJSON *string  `json:"required_attestations_json,omitempty"`
-	DependsOn                []string `json:"depends_on,omitempty"`
-	CreatedAt                string   `json:"created_at" format:"date-time"`
-	UpdatedAt                string   `json:"updated_at" format:"date-time"`
-	CompletedAt              *string  `json:"completed_at,omitempty" format:"date-time"`
+	RequiredCapabilities     []string `json:"required_capabilities,omitempty"`
+	CustomFieldsJSON         *string  `json:"custom_fields_json,omitempty"`
+	Labels                   []string `json:"labels,omitempty"`
+	// RiskScore and RiskLevel are computed by policies.risk; unset without a risk policy.
+	RiskScore *int    `json:"risk_score,omitempty"`
+	RiskLevel *string `json:"risk_level,omitempty"`
+	// DeferUntil keeps a planned task out of the ready queue until it passes.
+	DeferUntil *string `json:"defer_until,omitempty" format:"date-time"`
+	// DueAt is the task's deadline; the deadline scheduler orders the ready queue by it.
+	DueAt       *string  `json:"due_at,omitempty" format:"date-time"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+	Rank        int      `json:"rank"`
+	CreatedAt   string   `json:"created_at" format:"date-time"`
+	UpdatedAt   string   `json:"updated_at" format:"date-time"`
+	CompletedAt *string  `json:"completed_at,omitempty" format:"date-time"`
+	ContentHash string   `json:"content_hash,omitempty"`
 }
 
 type Decision struct {
@@ -47,14 +100,253 @@ type Decision struct {
 	RationaleJSON    string `json:"rationale_json,omitempty"`
 	AlternativesJSON string `json:"alternatives_json,omitempty"`
 	DeciderID        string `json:"decider_id"`
+	Status           string `json:"status"`
 	CreatedAt        string `json:"created_at"`
+	ContentHash      string `json:"content_hash,omitempty"`
+}
+
+// ViewFilters are the task filters a saved view applies. AssigneeID "$me" resolves to
+// whoever runs the view.
+type ViewFilters struct {
+	Status      string `json:"status,omitempty"`
+	Type        string `json:"type,omitempty"`
+	IterationID string `json:"iteration_id,omitempty"`
+	ParentID    string `json:"parent_id,omitempty"`
+	AssigneeID  string `json:"assignee_id,omitempty"`
+}
+
+// View is a named task query saved in a project. Private views are visible to their owner
+// only; project views are visible to every member, or to holders of Roles when set.
+type View struct {
+	ID          string      `json:"id"`
+	ProjectID   string      `json:"project_id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Filters     ViewFilters `json:"filters"`
+	Visibility  string      `json:"visibility"`
+	Roles       []string    `json:"roles,omitempty"`
+	OwnerID     string      `json:"owner_id"`
+	CreatedAt   string      `json:"created_at" format:"date-time"`
+	UpdatedAt   string      `json:"updated_at" format:"date-time"`
 }
 
 type Lease struct {
+	TaskID         string     `json:"task_id"`
+	OwnerID        string     `json:"owner_id"`
+	AcquiredAt     string     `json:"acquired_at" format:"date-time"`
+	ExpiresAt      string     `json:"expires_at" format:"date-time"`
+	PendingOwnerID *string    `json:"pending_owner_id,omitempty"`
+	Plan           *LeasePlan `json:"plan,omitempty"`
+}
+
+// LeasePlan is how an actor intends to work a task it claims, declared with the claim so
+// supervisors can review it before work starts.
+type LeasePlan struct {
+	Approach         string `json:"approach"`
+	EstimatedSeconds int    `json:"estimated_seconds,omitempty"`
+}
+
+// LeaseWaiter is an actor queued for a task lease. Position starts at 1 for the next
+// actor to be granted the lease.
+type LeaseWaiter struct {
+	TaskID       string     `json:"task_id"`
+	ActorID      string     `json:"actor_id"`
+	LeaseSeconds int        `json:"lease_seconds"`
+	EnqueuedAt   string     `json:"enqueued_at" format:"date-time"`
+	Position     int        `json:"position"`
+	Plan         *LeasePlan `json:"plan,omitempty"`
+}
+
+// TaskAssignee pairs an actor with a task in a driver or reviewer role.
+type TaskAssignee struct {
 	TaskID     string `json:"task_id"`
-	OwnerID    string `json:"owner_id"`
-	AcquiredAt string `json:"acquired_at" format:"date-time"`
-	ExpiresAt  string `json:"expires_at" format:"date-time"`
+	ActorID    string `json:"actor_id"`
+	Role       string `json:"role"`
+	AssignedAt string `json:"assigned_at" format:"date-time"`
+}
+
+// RoleGrant is a project role held by an actor or a team; ExpiresAt is set for temporary
+// grants.
+type RoleGrant struct {
+	ProjectID string  `json:"project_id"`
+	ActorID   string  `json:"actor_id,omitempty"`
+	RoleID    string  `json:"role_id"`
+	ExpiresAt *string `json:"expires_at,omitempty" format:"date-time"`
+	// TeamID is set for grants held through a team rather than by the actor directly.
+	TeamID *string `json:"team_id,omitempty"`
+}
+
+// Team groups actors of a project; roles granted to the team, and the attestation
+// authorities of those roles, apply to each member.
+type Team struct {
+	ProjectID   string      `json:"project_id"`
+	ID          string      `json:"id"`
+	Description string      `json:"description,omitempty"`
+	Members     []string    `json:"members"`
+	Roles       []RoleGrant `json:"roles"`
+	CreatedBy   string      `json:"created_by"`
+	CreatedAt   string      `json:"created_at" format:"date-time"`
+}
+
+// Member is an actor holding at least one active role in a project, with the permissions
+// those roles grant.
+type Member struct {
+	ActorID     string      `json:"actor_id"`
+	Roles       []RoleGrant `json:"roles"`
+	Permissions []string    `json:"permissions"`
+}
+
+// Membership is an actor's standing in one project: its active grants, direct or through
+// teams, and the permissions they give.
+type Membership struct {
+	ProjectID   string      `json:"project_id"`
+	OrgID       string      `json:"org_id"`
+	Roles       []RoleGrant `json:"roles"`
+	Permissions []string    `json:"permissions"`
+}
+
+// EscalationRule acts on tasks that stay in Condition for After (a duration): task.overdue
+// covers planned or in-progress tasks nobody holds a lease on and left unchanged, and
+// validation.blocked tasks in review with unmet requirements. The notify action records an
+// escalation.triggered event addressed to RoleID; follow_up also creates a task to unblock
+// the escalated one.
+type EscalationRule struct {
+	ProjectID   string `json:"project_id"`
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Condition   string `json:"condition" enum:"task.overdue,validation.blocked"`
+	After       string `json:"after" doc:"How long a task stays in the condition before the rule acts, e.g. 48h"`
+	TaskType    string `json:"task_type,omitempty" doc:"Only escalate tasks of this type"`
+	Action      string `json:"action" enum:"notify,follow_up"`
+	RoleID      string `json:"role_id,omitempty" doc:"Role notified of escalations; required by notify"`
+	Enabled     bool   `json:"enabled"`
+	CreatedBy   string `json:"created_by"`
+	CreatedAt   string `json:"created_at" format:"date-time"`
+	UpdatedAt   string `json:"updated_at" format:"date-time"`
+}
+
+// EscalationFiring records that a rule acted on a task for the spell of its condition
+// that began at Since.
+type EscalationFiring struct {
+	RuleID         string  `json:"rule_id"`
+	TaskID         string  `json:"task_id"`
+	Since          string  `json:"since" format:"date-time"`
+	FiredAt        string  `json:"fired_at" format:"date-time"`
+	FollowUpTaskID *string `json:"follow_up_task_id,omitempty"`
+}
+
+// Notification is an entry of an actor's inbox in a project: a mention of the actor, a
+// lease of theirs about to expire, or a task in review waiting for an attestation they can
+// record. ReadAt is set once the actor marks it read.
+type Notification struct {
+	ID         int64   `json:"id"`
+	ProjectID  string  `json:"project_id"`
+	ActorID    string  `json:"actor_id"`
+	Kind       string  `json:"kind" enum:"mention,lease_expiring,attestation_requested"`
+	EntityKind string  `json:"entity_kind"`
+	EntityID   string  `json:"entity_id"`
+	EventID    *int64  `json:"event_id,omitempty" doc:"Event that caused the notification, if any"`
+	Message    string  `json:"message"`
+	DedupeKey  string  `json:"-"`
+	CreatedAt  string  `json:"created_at" format:"date-time"`
+	ReadAt     *string `json:"read_at,omitempty" format:"date-time"`
+}
+
+// EmailPreferences is where, and for which events, an actor of the actor registry is
+// mailed by email notification channels. Events are names from config.EmailEvents.
+type EmailPreferences struct {
+	ActorID string   `json:"actor_id"`
+	Email   string   `json:"email,omitempty" example:"ada@example.com"`
+	Events  []string `json:"events"`
+}
+
+// ProjectSecret is one version of a project secret: a webhook secret for a provider, an
+// API token acting as an actor, or the key signing the project's evidence bundles. A version
+// is live until ExpiresAt, which rotation sets on the versions it supersedes, or until it is
+// revoked. Value never leaves the server.
+type ProjectSecret struct {
+	ID        string  `json:"id"`
+	ProjectID string  `json:"project_id"`
+	Kind      string  `json:"kind" enum:"webhook,api_token,signing"`
+	Name      string  `json:"name" doc:"Provider for webhook secrets, actor for API tokens, evidence for signing keys"`
+	Version   int     `json:"version"`
+	Value     string  `json:"-"`
+	KeyID     string  `json:"key_id" doc:"sha256 fingerprint of the secret, or of the public key for signing keys"`
+	PublicKey string  `json:"public_key,omitempty" doc:"Base64 Ed25519 public key of a signing key"`
+	CreatedBy string  `json:"created_by"`
+	CreatedAt string  `json:"created_at" format:"date-time"`
+	ExpiresAt *string `json:"expires_at,omitempty" format:"date-time"`
+	RevokedAt *string `json:"revoked_at,omitempty" format:"date-time"`
+}
+
+// TaskListSnapshot is a task list copied by a single query, so every page read from it
+// reflects the same point in time. Scope ties it to the list and actor that took it.
+type TaskListSnapshot struct {
+	ID         string `json:"id"`
+	Scope      string `json:"scope"`
+	ActorID    string `json:"actor_id"`
+	TakenAt    string `json:"taken_at" format:"date-time"`
+	LastReadAt string `json:"last_read_at" format:"date-time"`
+	Total      int    `json:"total"`
+}
+
+// TaskHandoff records one assignee change and the note left for the next assignee.
+type TaskHandoff struct {
+	ID             int64   `json:"id"`
+	TaskID         string  `json:"task_id"`
+	ProjectID      string  `json:"project_id"`
+	FromAssigneeID *string `json:"from_assignee_id,omitempty"`
+	ToAssigneeID   *string `json:"to_assignee_id,omitempty"`
+	ActorID        string  `json:"actor_id"`
+	Note           string  `json:"note,omitempty"`
+	CreatedAt      string  `json:"created_at" format:"date-time"`
+}
+
+// TaskCompletion is a completion submitted for approval. Status is pending until an
+// approver confirms (approved) or rejects it; a forced status change withdraws it.
+type TaskCompletion struct {
+	ID          int64   `json:"id"`
+	TaskID      string  `json:"task_id"`
+	ProjectID   string  `json:"project_id"`
+	Status      string  `json:"status"`
+	SubmittedBy string  `json:"submitted_by"`
+	SubmittedAt string  `json:"submitted_at" format:"date-time"`
+	DecidedBy   *string `json:"decided_by,omitempty"`
+	DecidedAt   *string `json:"decided_at,omitempty" format:"date-time"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// TaskLink associates a commit, branch or pull request with a task. Target is the commit
+// SHA, the branch name or the pull request URL. The status fields hold what the VCS
+// integrations last reported; HeadRef is the source branch of a pull request, learned
+// from its webhooks, whose builds report the pull request's CI status.
+type TaskLink struct {
+	ID              int64  `json:"id"`
+	TaskID          string `json:"task_id"`
+	ProjectID       string `json:"project_id"`
+	Kind            string `json:"kind"`
+	Target          string `json:"target"`
+	HeadRef         string `json:"head_ref,omitempty"`
+	CIStatus        string `json:"ci_status,omitempty"`
+	CIURL           string `json:"ci_url,omitempty"`
+	ReviewStatus    string `json:"review_status,omitempty"`
+	Provider        string `json:"provider,omitempty"`
+	StatusUpdatedAt string `json:"status_updated_at,omitempty" format:"date-time"`
+	CreatedBy       string `json:"created_by"`
+	CreatedAt       string `json:"created_at" format:"date-time"`
+}
+
+// Artifact is an uploaded evidence file; attestations and work outcomes cite it as
+// {"$artifact": "<id>"}. Digest addresses the content in the blob store.
+type Artifact struct {
+	ID         string `json:"id"`
+	ProjectID  string `json:"project_id"`
+	Name       string `json:"name"`
+	MediaType  string `json:"media_type"`
+	Size       int64  `json:"size"`
+	Digest     string `json:"digest"`
+	UploadedBy string `json:"uploaded_by"`
+	CreatedAt  string `json:"created_at" format:"date-time"`
 }
 
 type Attestation struct {
@@ -67,6 +359,31 @@ type Attestation struct {
 	ActorID     string `json:"actor_id"`
 	TS          string `json:"ts" format:"date-time"`
 	PayloadJSON string `json:"payload_json,omitempty"`
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+type Waiver struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	TaskID        string `json:"task_id"`
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+	ActorID       string `json:"actor_id"`
+	ExpiresAt     string `json:"expires_at" format:"date-time"`
+	CreatedAt     string `json:"created_at" format:"date-time"`
+}
+
+// StatsSnapshot holds one day of project metrics. Counts of open and done tasks are
+// point-in-time totals; completions, attestations and lead time cover that day only.
+type StatsSnapshot struct {
+	ProjectID          string   `json:"project_id"`
+	Day                string   `json:"day" format:"date"`
+	TasksOpen          int      `json:"tasks_open"`
+	TasksDone          int      `json:"tasks_done"`
+	TasksCompleted     int      `json:"tasks_completed"`
+	AttestationsIssued int      `json:"attestations_issued"`
+	LeadTimeSeconds    *float64 `json:"lead_time_seconds,omitempty"`
+	RecordedAt         string   `json:"recorded_at" format:"date-time"`
 }
 
 type Event struct {
@@ -79,6 +396,19 @@ type Event struct {
 	EntityID   string `json:"entity_id,omitempty"`
 	ActorID    string `json:"actor_id"`
 	Payload    string `json:"payload_json"`
+	PrevHash   string `json:"prev_hash,omitempty"`
+	ThisHash   string `json:"this_hash,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// ClientCertMapping maps a client certificate identity to an actor. Kind is "cn" for the
+// subject common name, or "dns", "email" or "uri" for a subject alternative name.
+type ClientCertMapping struct {
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	ActorID   string `json:"actor_id"`
+	OrgID     string `json:"org_id"`
+	CreatedAt string `json:"created_at" format:"date-time"`
 }
 
 type APIKey struct {
@@ -89,3 +419,27 @@ type APIKey struct {
 	KeyHash   string `json:"key_hash"`
 	CreatedAt string `json:"created_at" format:"date-time"`
 }
+
+// ActorUsage counts an actor's API calls in a project on one UTC day. Mutations are the
+// calls that were not reads; Rejected counts calls refused by a quota, which are not
+// included in Requests or Mutations.
+type ActorUsage struct {
+	ProjectID string `json:"project_id"`
+	ActorID   string `json:"actor_id"`
+	Day       string `json:"day" example:"2026-10-17"`
+	Requests  int64  `json:"requests"`
+	Mutations int64  `json:"mutations"`
+	Rejected  int64  `json:"rejected"`
+}
+
+// Inconsistency is a row that points at something that no longer exists, or a lease left on
+// a finished task, with the repair that resolves it.
+type Inconsistency struct {
+	Check     string `json:"check" enum:"task_parent,task_iteration,task_dependency,attestation_entity,lease"`
+	Table     string `json:"table"`
+	RowID     string `json:"row_id" doc:"Attestation id, or the task id for task and lease rows"`
+	ProjectID string `json:"project_id,omitempty"`
+	Ref       string `json:"ref" doc:"The missing parent, iteration, dependency or entity, or the leased task"`
+	Detail    string `json:"detail"`
+	Repair    string `json:"repair" enum:"clear_parent,clear_iteration,delete_dependency,delete_attestation,delete_lease"`
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+
+	"workline/internal/blob"
+	"workline/internal/config"
+	"workline/internal/db"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// Store returns the repository behind the engine, for reads that need no engine logic.
+func (e Engine) Store() repo.Store { return e.Repo }
+
+// DefaultConfig returns the config of the workspace's default project, which requests
+// naming no project fall back to.
+func (e Engine) DefaultConfig() *config.Config { return e.Config }
+
+// BlobStore returns where large attestation payloads are kept; nil when they stay inline.
+func (e Engine) BlobStore() blob.Store { return e.Blobs }
+
+type projectScopeKey struct{}
+
+// WithProjectScope limits the permission checks made under ctx to projectID: credentials
+// bound to one project, such as project API tokens, hold no permission anywhere else.
+func WithProjectScope(ctx context.Context, projectID string) context.Context {
+	return context.WithValue(ctx, projectScopeKey{}, projectID)
+}
+
+// outOfScope reports whether ctx is bound to a project other than projectID.
+func outOfScope(ctx context.Context, projectID string) bool {
+	scope, ok := ctx.Value(projectScopeKey{}).(string)
+	return ok && scope != projectID
+}
+
+// ActorHasPermission reports whether actorID holds perm in projectID through its roles.
+func (e Engine) ActorHasPermission(ctx context.Context, projectID, actorID, perm string) (bool, error) {
+	if outOfScope(ctx, projectID) {
+		return false, nil
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	return e.Auth.ActorHasPermission(ctx, tx, projectID, actorID, perm)
+}
+
+// RecordDenial records an auth.denied event for a request refused to actorID. Denials
+// found inside engine transactions are rolled back with the refused change, so the server
+// records them once the response is known. The project comes from the request, so
+// denials for a project that does not exist are dropped.
+func (e Engine) RecordDenial(ctx context.Context, projectID, actorID string, payload events.EventPayload) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := e.Repo.GetProjectTx(ctx, tx, projectID); errors.Is(err, repo.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := e.Events.Append(ctx, tx, "auth.denied", projectID, "rbac", projectID, actorID, payload); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Vacuum reclaims free pages of the database; see db.Vacuum.
+func (e Engine) Vacuum(ctx context.Context, full bool, pages int) (db.VacuumResult, error) {
+	return db.Vacuum(ctx, e.DB, full, pages)
+}
+
+// IndexCustomFields adds the generated columns and indexes for the custom fields the engine
+// config and the stored project configs list as indexed, and returns those fields.
+func (e Engine) IndexCustomFields(ctx context.Context) ([]string, error) {
+	fields := []string{}
+	add := func(cfg *config.Config) {
+		for _, f := range cfg.IndexedCustomFields() {
+			if !slices.Contains(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	if e.Config != nil {
+		add(e.Config)
+	}
+	projects, err := e.Repo.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		cfg, err := e.Repo.GetProjectConfig(ctx, p.ID)
+		if errors.Is(err, repo.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		add(cfg)
+	}
+	sort.Strings(fields)
+	return fields, e.Repo.EnsureCustomFieldIndexes(ctx, fields)
+}
+
+// CheckIntegrity checks the database file for corruption; see db.CheckIntegrity.
+func (e Engine) CheckIntegrity(ctx context.Context, quick bool) (db.IntegrityResult, error) {
+	return db.CheckIntegrity(ctx, e.DB, quick)
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"workline/internal/repo"
+)
+
+// MaxEventBuckets bounds how many buckets one aggregation may return.
+const MaxEventBuckets = 1000
+
+var eventBucketSizes = map[string]time.Duration{"hour": time.Hour, "day": 24 * time.Hour}
+
+// EventBucket counts the events of one period per type. Start is the beginning of the
+// period; periods without events are included with a zero total.
+type EventBucket struct {
+	Start  string         `json:"start" format:"date-time"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// EventAggregate is a project's event activity over [From, To) in fixed-size buckets.
+type EventAggregate struct {
+	ProjectID string        `json:"project_id"`
+	Bucket    string        `json:"bucket" enum:"hour,day"`
+	From      string        `json:"from" format:"date-time"`
+	To        string        `json:"to" format:"date-time"`
+	Types     []string      `json:"types" doc:"Event types counted, or every type seen when not filtered"`
+	Total     int           `json:"total"`
+	Buckets   []EventBucket `json:"buckets"`
+}
+
+// AggregateEvents counts project events per bucket and type over [from, to). from is
+// rounded down and to up to whole buckets (UTC). types optionally narrows the event types.
+func (e Engine) AggregateEvents(ctx context.Context, projectID, bucket string, types []string, from, to time.Time) (EventAggregate, error) {
+	size, ok := eventBucketSizes[bucket]
+	if !ok {
+		return EventAggregate{}, fmt.Errorf("invalid bucket %q: expected hour or day", bucket)
+	}
+	start := from.UTC().Truncate(size)
+	end := to.UTC().Truncate(size)
+	if end.Before(to.UTC()) {
+		end = end.Add(size)
+	}
+	if !start.Before(end) {
+		return EventAggregate{}, errors.New("invalid period: from must be before to")
+	}
+	if n := int(end.Sub(start) / size); n > MaxEventBuckets {
+		return EventAggregate{}, fmt.Errorf("invalid period: %d %s buckets exceed the limit of %d", n, bucket, MaxEventBuckets)
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return EventAggregate{}, err
+	}
+	fromTS, toTS := start.Format(time.RFC3339), end.Format(time.RFC3339)
+	counts, err := e.Repo.CountEventsByBucket(ctx, projectID, bucket, types, fromTS, toTS)
+	if err != nil {
+		return EventAggregate{}, err
+	}
+	agg := EventAggregate{
+		ProjectID: projectID,
+		Bucket:    bucket,
+		From:      fromTS,
+		To:        toTS,
+		Types:     []string{},
+		Buckets:   []EventBucket{},
+	}
+	byPrefix := map[string]int{}
+	for t := start; t.Before(end); t = t.Add(size) {
+		ts := t.Format(time.RFC3339)
+		byPrefix[ts[:repo.EventBuckets[bucket]]] = len(agg.Buckets)
+		agg.Buckets = append(agg.Buckets, EventBucket{Start: ts, Counts: map[string]int{}})
+	}
+	seen := map[string]bool{}
+	for _, c := range counts {
+		i, ok := byPrefix[c.Prefix]
+		if !ok {
+			continue
+		}
+		agg.Buckets[i].Counts[c.Type] += c.Count
+		agg.Buckets[i].Total += c.Count
+		agg.Total += c.Count
+		seen[c.Type] = true
+	}
+	if len(types) > 0 {
+		agg.Types = append(agg.Types, types...)
+	} else {
+		for t := range seen {
+			agg.Types = append(agg.Types, t)
+		}
+	}
+	slices.Sort(agg.Types)
+	return agg, nil
+}
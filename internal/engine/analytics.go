@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// CycleTimeOptions selects the tasks of a cycle-time report: those completed in
+// [From, To), and those in progress now, optionally of one type or iteration.
+type CycleTimeOptions struct {
+	ProjectID   string
+	Type        string
+	IterationID string
+	From        time.Time
+	To          time.Time
+}
+
+// DurationStats summarizes durations in seconds with nearest-rank percentiles.
+type DurationStats struct {
+	Count int   `json:"count"`
+	P50   int64 `json:"p50_seconds"`
+	P85   int64 `json:"p85_seconds"`
+	P95   int64 `json:"p95_seconds"`
+	Max   int64 `json:"max_seconds"`
+}
+
+// TaskFlow is the path of one task through the statuses, as recorded by its events.
+// StartedAt is when it first left planned; cycle time runs from there to completion and
+// lead time from creation. WIPAgeSeconds is how long an unfinished task has been started.
+type TaskFlow struct {
+	TaskID           string           `json:"task_id"`
+	Title            string           `json:"title"`
+	Type             string           `json:"type"`
+	Status           string           `json:"status"`
+	IterationID      *string          `json:"iteration_id,omitempty"`
+	CreatedAt        string           `json:"created_at" format:"date-time"`
+	StartedAt        *string          `json:"started_at,omitempty" format:"date-time"`
+	CompletedAt      *string          `json:"completed_at,omitempty" format:"date-time"`
+	LeadTimeSeconds  *int64           `json:"lead_time_seconds,omitempty"`
+	CycleTimeSeconds *int64           `json:"cycle_time_seconds,omitempty"`
+	WIPAgeSeconds    *int64           `json:"wip_age_seconds,omitempty"`
+	TimeInStatus     map[string]int64 `json:"time_in_status_seconds"`
+}
+
+// CycleTimeReport gives lead time, cycle time and time in each status over the tasks
+// completed in a period, and the age of the work in progress.
+type CycleTimeReport struct {
+	ProjectID    string                   `json:"project_id"`
+	Type         string                   `json:"type,omitempty"`
+	IterationID  string                   `json:"iteration_id,omitempty"`
+	From         string                   `json:"from" format:"date-time"`
+	To           string                   `json:"to" format:"date-time"`
+	GeneratedAt  string                   `json:"generated_at" format:"date-time"`
+	LeadTime     DurationStats            `json:"lead_time"`
+	CycleTime    DurationStats            `json:"cycle_time"`
+	TimeInStatus map[string]DurationStats `json:"time_in_status"`
+	WIPAge       DurationStats            `json:"wip_age"`
+	// Completed lists the tasks completed in the period, in completion order.
+	Completed []TaskFlow `json:"completed"`
+	// WIP lists the tasks in progress or in review, oldest start first.
+	WIP []TaskFlow `json:"wip"`
+}
+
+// statusEventTypes are the task events that can change a task's status.
+var statusEventTypes = []string{"task.created", "task.updated", "task.done", "task.completion.submitted"}
+
+// CycleTime measures how tasks flow through the project's statuses, from their events.
+func (e Engine) CycleTime(ctx context.Context, opts CycleTimeOptions) (CycleTimeReport, error) {
+	if !opts.From.Before(opts.To) {
+		return CycleTimeReport{}, errors.New("invalid period: from must be before to")
+	}
+	if _, err := e.Repo.GetProject(ctx, opts.ProjectID); err != nil {
+		return CycleTimeReport{}, err
+	}
+	if opts.IterationID != "" {
+		it, err := e.Repo.GetIteration(ctx, opts.IterationID)
+		if err != nil {
+			return CycleTimeReport{}, err
+		}
+		if it.ProjectID != opts.ProjectID {
+			return CycleTimeReport{}, repo.ErrNotFound
+		}
+	}
+	now := e.now().UTC()
+	rep := CycleTimeReport{
+		ProjectID:    opts.ProjectID,
+		Type:         opts.Type,
+		IterationID:  opts.IterationID,
+		From:         opts.From.UTC().Format(time.RFC3339),
+		To:           opts.To.UTC().Format(time.RFC3339),
+		GeneratedAt:  now.Format(time.RFC3339),
+		TimeInStatus: map[string]DurationStats{},
+		Completed:    []TaskFlow{},
+		WIP:          []TaskFlow{},
+	}
+	filters := repo.TaskFilters{ProjectID: opts.ProjectID, Type: opts.Type, Iteration: opts.IterationID}
+	done := filters
+	done.Status, done.CompletedFrom, done.CompletedTo = "done", rep.From, rep.To
+	tasks, err := e.Repo.ListTasks(ctx, done)
+	if err != nil {
+		return rep, err
+	}
+	for _, status := range []string{"in_progress", "review"} {
+		open := filters
+		open.Status = status
+		wip, err := e.Repo.ListTasks(ctx, open)
+		if err != nil {
+			return rep, err
+		}
+		tasks = append(tasks, wip...)
+	}
+	timelines, err := e.statusTimelines(ctx, opts.ProjectID, tasks)
+	if err != nil {
+		return rep, err
+	}
+	var lead, cycle, age []int64
+	inStatus := map[string][]int64{}
+	for _, t := range tasks {
+		f := taskFlow(t, timelines[t.ID], now)
+		if f.CompletedAt == nil {
+			if f.WIPAgeSeconds != nil {
+				age = append(age, *f.WIPAgeSeconds)
+			}
+			rep.WIP = append(rep.WIP, f)
+			continue
+		}
+		if f.LeadTimeSeconds != nil {
+			lead = append(lead, *f.LeadTimeSeconds)
+		}
+		if f.CycleTimeSeconds != nil {
+			cycle = append(cycle, *f.CycleTimeSeconds)
+		}
+		for status, secs := range f.TimeInStatus {
+			inStatus[status] = append(inStatus[status], secs)
+		}
+		rep.Completed = append(rep.Completed, f)
+	}
+	slices.SortStableFunc(rep.Completed, func(a, b TaskFlow) int { return cmp.Compare(*a.CompletedAt, *b.CompletedAt) })
+	slices.SortStableFunc(rep.WIP, func(a, b TaskFlow) int { return cmp.Compare(wipAge(b), wipAge(a)) })
+	rep.LeadTime, rep.CycleTime, rep.WIPAge = durationStats(lead), durationStats(cycle), durationStats(age)
+	for status, secs := range inStatus {
+		rep.TimeInStatus[status] = durationStats(secs)
+	}
+	return rep, nil
+}
+
+// statusChange is a task entering a status at a time.
+type statusChange struct {
+	status string
+	at     time.Time
+}
+
+// statusTimelines reads the status changes of tasks from their events, oldest first.
+func (e Engine) statusTimelines(ctx context.Context, projectID string, tasks []domain.Task) (map[string][]statusChange, error) {
+	res := map[string][]statusChange{}
+	ids := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	for chunk := range slices.Chunk(ids, 500) {
+		evts, err := e.Repo.EntityEvents(ctx, projectID, "task", chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range evts {
+			if !slices.Contains(statusEventTypes, evt.Type) {
+				continue
+			}
+			var payload struct {
+				Status   string `json:"status"`
+				ToStatus string `json:"to_status"`
+			}
+			_ = json.Unmarshal([]byte(evt.Payload), &payload)
+			status := payload.ToStatus
+			switch evt.Type {
+			case "task.created", "task.done":
+				status = payload.Status
+			case "task.completion.submitted":
+				status = "review"
+			}
+			if evt.Type == "task.created" && status == "" {
+				status = "planned"
+			}
+			at, err := time.Parse(time.RFC3339, evt.TS)
+			if err != nil || status == "" {
+				continue
+			}
+			timeline := res[evt.EntityID]
+			if n := len(timeline); n > 0 && timeline[n-1].status == status {
+				continue
+			}
+			res[evt.EntityID] = append(timeline, statusChange{status: status, at: at})
+		}
+	}
+	return res, nil
+}
+
+// taskFlow measures t from its status timeline, counting the current status up to now for
+// unfinished tasks. Without events for the creation, the timeline starts at t.CreatedAt.
+func taskFlow(t domain.Task, timeline []statusChange, now time.Time) TaskFlow {
+	f := TaskFlow{
+		TaskID:       t.ID,
+		Title:        t.Title,
+		Type:         t.Type,
+		Status:       t.Status,
+		IterationID:  t.IterationID,
+		CreatedAt:    t.CreatedAt,
+		TimeInStatus: map[string]int64{},
+	}
+	created, err := time.Parse(time.RFC3339, t.CreatedAt)
+	if err != nil {
+		return f
+	}
+	if len(timeline) == 0 || timeline[0].at.After(created) {
+		timeline = append([]statusChange{{status: "planned", at: created}}, timeline...)
+	}
+	end := now
+	if t.Status == "done" && t.CompletedAt != nil {
+		if completed, err := time.Parse(time.RFC3339, *t.CompletedAt); err == nil {
+			end = completed
+			f.CompletedAt = t.CompletedAt
+			f.LeadTimeSeconds = seconds(end.Sub(created))
+		}
+	}
+	var started *time.Time
+	for i, c := range timeline {
+		if started == nil && c.status != "planned" && !c.at.After(end) {
+			started = &c.at
+		}
+		if !c.at.Before(end) {
+			continue
+		}
+		next := end
+		if i+1 < len(timeline) && timeline[i+1].at.Before(end) {
+			next = timeline[i+1].at
+		}
+		f.TimeInStatus[c.status] += int64(next.Sub(c.at).Seconds())
+	}
+	if started == nil {
+		return f
+	}
+	ts := started.UTC().Format(time.RFC3339)
+	f.StartedAt = &ts
+	if f.CompletedAt != nil {
+		f.CycleTimeSeconds = seconds(end.Sub(*started))
+	} else {
+		f.WIPAgeSeconds = seconds(now.Sub(*started))
+	}
+	return f
+}
+
+func wipAge(f TaskFlow) int64 {
+	if f.WIPAgeSeconds == nil {
+		return 0
+	}
+	return *f.WIPAgeSeconds
+}
+
+func seconds(d time.Duration) *int64 {
+	s := max(int64(d.Seconds()), 0)
+	return &s
+}
+
+// durationStats summarizes values, which it sorts.
+func durationStats(values []int64) DurationStats {
+	if len(values) == 0 {
+		return DurationStats{}
+	}
+	slices.Sort(values)
+	rank := func(p float64) int64 {
+		return values[max(int(math.Ceil(p*float64(len(values))))-1, 0)]
+	}
+	return DurationStats{
+		Count: len(values),
+		P50:   rank(0.50),
+		P85:   rank(0.85),
+		P95:   rank(0.95),
+		Max:   values[len(values)-1],
+	}
+}
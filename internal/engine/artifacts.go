@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// ArtifactUploadOptions describes an evidence file to store for a project.
+type ArtifactUploadOptions struct {
+	ProjectID string
+	Name      string
+	MediaType string
+	Data      []byte
+	ActorID   string
+}
+
+// UploadArtifact stores an evidence file in the blob store and records it so attestations
+// and work outcomes can cite it as {"$artifact": "<id>"}.
+func (e Engine) UploadArtifact(ctx context.Context, opts ArtifactUploadOptions) (domain.Artifact, error) {
+	if e.Config == nil {
+		return domain.Artifact{}, errors.New("config not loaded")
+	}
+	if e.Blobs == nil {
+		return domain.Artifact{}, errors.New("artifact storage is not configured")
+	}
+	if err := validateArtifactName(opts.Name); err != nil {
+		return domain.Artifact{}, err
+	}
+	mediaType := opts.MediaType
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+		return domain.Artifact{}, fmt.Errorf("invalid media type %q", mediaType)
+	}
+	if len(opts.Data) == 0 {
+		return domain.Artifact{}, errors.New("artifact content required")
+	}
+	if max := e.Config.Payloads.ArtifactMax(); len(opts.Data) > max {
+		return domain.Artifact{}, PayloadTooLargeError{Field: "artifact", Size: len(opts.Data), Max: max}
+	}
+	if _, err := e.Repo.GetProject(ctx, opts.ProjectID); err != nil {
+		return domain.Artifact{}, err
+	}
+	if err := e.precheckPermissions(ctx, opts.ProjectID, opts.ActorID, "artifact.upload"); err != nil {
+		return domain.Artifact{}, err
+	}
+	// Store the content before the write transaction so blob I/O never holds the sqlite writer.
+	digest, err := e.Blobs.Put(ctx, opts.Data)
+	if err != nil {
+		return domain.Artifact{}, fmt.Errorf("store artifact: %w", err)
+	}
+	a := domain.Artifact{
+		ID:         uuid.New().String(),
+		ProjectID:  opts.ProjectID,
+		Name:       opts.Name,
+		MediaType:  mediaType,
+		Size:       int64(len(opts.Data)),
+		Digest:     digest,
+		UploadedBy: opts.ActorID,
+		CreatedAt:  e.now().UTC().Format(time.RFC3339),
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return a, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, a.ProjectID, opts.ActorID, "artifact.upload"); err != nil {
+		return a, err
+	}
+	if err := e.Repo.InsertArtifactTx(ctx, tx, a); err != nil {
+		return a, err
+	}
+	if err := e.Events.Append(ctx, tx, "artifact.uploaded", a.ProjectID, "artifact", a.ID, opts.ActorID, events.EventPayload{
+		"name":       a.Name,
+		"media_type": a.MediaType,
+		"size":       a.Size,
+		"digest":     a.Digest,
+	}); err != nil {
+		return a, err
+	}
+	if err := tx.Commit(); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// ArtifactContent loads the stored bytes of an artifact.
+func (e Engine) ArtifactContent(ctx context.Context, a domain.Artifact) ([]byte, error) {
+	if e.Blobs == nil {
+		return nil, errors.New("artifact storage is not configured")
+	}
+	return e.Blobs.Get(ctx, a.Digest)
+}
+
+func validateArtifactName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("artifact name required")
+	case len(name) > 255:
+		return errors.New("invalid artifact name: longer than 255 bytes")
+	case strings.ContainsAny(name, "/\\\x00"):
+		return errors.New("invalid artifact name: must not contain path separators")
+	}
+	return nil
+}
+
+// checkArtifactCitations rejects JSON that cites an artifact missing from the project.
+func (e Engine) checkArtifactCitations(ctx context.Context, tx *sql.Tx, projectID, payload string) error {
+	if payload == "" || !strings.Contains(payload, `"$artifact"`) {
+		return nil
+	}
+	var doc any
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		return nil
+	}
+	for _, id := range artifactCitations(doc, nil) {
+		a, err := e.Repo.GetArtifactTx(ctx, tx, id)
+		if errors.Is(err, repo.ErrNotFound) || (err == nil && a.ProjectID != projectID) {
+			return fmt.Errorf("invalid artifact reference: %s not found in project %s", id, projectID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func artifactCitations(doc any, ids []string) []string {
+	switch v := doc.(type) {
+	case map[string]any:
+		if id, ok := v["$artifact"].(string); ok {
+			ids = append(ids, id)
+		}
+		for _, child := range v {
+			ids = artifactCitations(child, ids)
+		}
+	case []any:
+		for _, child := range v {
+			ids = artifactCitations(child, ids)
+		}
+	}
+	return ids
+}
@@ -5,7 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
 	"time"
+
+	"workline/internal/config"
+	"workline/internal/repo"
 )
 
 // ForbiddenError indicates missing permission.
@@ -26,9 +32,20 @@ func (e ForbiddenAttestationError) Error() string {
 	return fmt.Sprintf("attestation authority required for kind %s", e.Kind)
 }
 
-// Service provides RBAC helpers backed by SQL.
+// Service provides RBAC helpers backed by SQL. Role grants whose expires_at has passed
+// are treated as absent.
 type Service struct {
-	DB *sql.DB
+	DB  *sql.DB
+	Now func() time.Time
+	// Cache, when set, is shared with the Repo performing RBAC writes so they invalidate it.
+	Cache *repo.Cache
+}
+
+func (s Service) now() string {
+	if s.Now != nil {
+		return s.Now().UTC().Format(time.RFC3339)
+	}
+	return time.Now().UTC().Format(time.RFC3339)
 }
 
 func (s Service) EnsureActor(ctx context.Context, tx *sql.Tx, actorID string) error {
@@ -40,68 +57,78 @@ func (s Service) EnsureActor(ctx context.Context, tx *sql.Tx, actorID string) er
 	return err
 }
 
+func (s Service) grants(ctx context.Context, tx *sql.Tx, projectID, actorID string) ([]repo.ActorGrant, error) {
+	return repo.Repo{DB: s.DB, Cache: s.Cache}.ActorGrantsTx(ctx, tx, projectID, actorID)
+}
+
 func (s Service) ActorHasPermission(ctx context.Context, tx *sql.Tx, projectID, actorID, perm string) (bool, error) {
-	row := tx.QueryRowContext(ctx, `
-SELECT 1 FROM actor_roles ar
-JOIN role_permissions rp ON rp.role_id=ar.role_id
-WHERE ar.project_id=? AND ar.actor_id=? AND rp.permission_id=? LIMIT 1`,
-		projectID, actorID, perm)
-	var n int
-	err := row.Scan(&n)
-	if err == sql.ErrNoRows {
-		return false, nil
+	grants, err := s.grants(ctx, tx, projectID, actorID)
+	if err != nil {
+		return false, err
 	}
-	return err == nil, err
+	now := s.now()
+	for _, g := range grants {
+		if g.Active(now) && g.Permissions[perm] {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (s Service) ActorRoles(ctx context.Context, tx *sql.Tx, projectID, actorID string) ([]string, error) {
-	rows, err := tx.QueryContext(ctx, `SELECT role_id FROM actor_roles WHERE project_id=? AND actor_id=?`, projectID, actorID)
+	grants, err := s.grants(ctx, tx, projectID, actorID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	now := s.now()
 	var roles []string
-	for rows.Next() {
-		var r string
-		if err := rows.Scan(&r); err != nil {
-			return nil, err
+	for _, g := range grants {
+		if g.Active(now) && !slices.Contains(roles, g.RoleID) {
+			roles = append(roles, g.RoleID)
 		}
-		roles = append(roles, r)
 	}
 	return roles, nil
 }
 
 func (s Service) ActorPermissions(ctx context.Context, tx *sql.Tx, projectID, actorID string) ([]string, error) {
-	rows, err := tx.QueryContext(ctx, `
-SELECT DISTINCT rp.permission_id
-FROM actor_roles ar
-JOIN role_permissions rp ON rp.role_id=ar.role_id
-WHERE ar.project_id=? AND ar.actor_id=?`, projectID, actorID)
+	grants, err := s.grants(ctx, tx, projectID, actorID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	now := s.now()
 	var perms []string
-	for rows.Next() {
-		var p string
-		if err := rows.Scan(&p); err != nil {
-			return nil, err
+	for _, g := range grants {
+		if !g.Active(now) {
+			continue
+		}
+		for p := range g.Permissions {
+			if !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
 		}
-		perms = append(perms, p)
 	}
+	sort.Strings(perms)
 	return perms, nil
 }
 
 func (s Service) ActorCanAttest(ctx context.Context, tx *sql.Tx, projectID, actorID, kind string) (bool, error) {
-	row := tx.QueryRowContext(ctx, `
-SELECT 1 FROM actor_roles ar
-JOIN attestation_authorities aa ON aa.role_id=ar.role_id
-WHERE ar.project_id=? AND ar.actor_id=? AND aa.project_id=? AND aa.kind=? LIMIT 1`,
-		projectID, actorID, projectID, kind)
-	var n int
-	err := row.Scan(&n)
-	if err == sql.ErrNoRows {
+	auths, err := repo.Repo{DB: s.DB, Cache: s.Cache}.AttestationAuthoritiesTx(ctx, tx, projectID)
+	if err != nil {
+		return false, err
+	}
+	granted, ok := config.AuthorityFor(slices.Collect(maps.Keys(auths)), kind)
+	if !ok {
 		return false, nil
 	}
-	return err == nil, err
+	grants, err := s.grants(ctx, tx, projectID, actorID)
+	if err != nil {
+		return false, err
+	}
+	now := s.now()
+	for _, g := range grants {
+		if g.Active(now) && slices.Contains(auths[granted], g.RoleID) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"workline/internal/domain"
+)
+
+// AttestationAuthority is a role allowed to record one attestation kind (or kind pattern),
+// the actors holding that role now, and the events that shaped the authority: the kind
+// allowed or denied to the role, and the role granted to, revoked from or expired for its
+// holders, oldest first.
+type AttestationAuthority struct {
+	Kind    string             `json:"kind"`
+	RoleID  string             `json:"role_id"`
+	Holders []domain.RoleGrant `json:"holders"`
+	History []domain.Event     `json:"history"`
+}
+
+// AttestationAuthorityReport is an access review of who may attest what in a project. Its
+// Unauthorized lists the attestations whose actor would not be allowed to record them
+// under the current authorities and grants; it is empty unless authority was narrowed
+// after the fact, or attestations were written around the engine.
+type AttestationAuthorityReport struct {
+	ProjectID    string                 `json:"project_id"`
+	GeneratedAt  string                 `json:"generated_at"`
+	Authorities  []AttestationAuthority `json:"authorities"`
+	Unauthorized []domain.Attestation   `json:"unauthorized"`
+}
+
+// authorityEventTypes are the events that change who holds an attestation authority.
+var authorityEventTypes = []string{
+	"rbac.attestation_allowed",
+	"rbac.attestation_denied",
+	"rbac.role_granted",
+	"rbac.role_revoked",
+	"rbac.role_expired",
+}
+
+// AttestationAuthorityReport builds the access review of projectID from one read
+// transaction. Callers check rbac.read.
+func (e Engine) AttestationAuthorityReport(ctx context.Context, projectID string) (AttestationAuthorityReport, error) {
+	now := e.now().UTC()
+	report := AttestationAuthorityReport{ProjectID: projectID, GeneratedAt: now.Format(time.RFC3339)}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+	auths, err := e.Repo.AttestationAuthoritiesTx(ctx, tx, projectID)
+	if err != nil {
+		return report, err
+	}
+	grants, err := e.Repo.ActiveGrantsTx(ctx, tx, projectID, now.Format(time.RFC3339))
+	if err != nil {
+		return report, err
+	}
+	history, err := e.Repo.EventsOfTypesTx(ctx, tx, projectID, authorityEventTypes)
+	if err != nil {
+		return report, err
+	}
+	kinds := make([]string, 0, len(auths))
+	for kind := range auths {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		for _, roleID := range auths[kind] {
+			a := AttestationAuthority{Kind: kind, RoleID: roleID, Holders: []domain.RoleGrant{}, History: []domain.Event{}}
+			for _, g := range grants {
+				if g.RoleID == roleID {
+					a.Holders = append(a.Holders, g)
+				}
+			}
+			for _, evt := range history {
+				if authorityEventConcerns(evt, kind, roleID) {
+					a.History = append(a.History, evt)
+				}
+			}
+			report.Authorities = append(report.Authorities, a)
+		}
+	}
+
+	atts, err := e.Repo.ListAttestationAuthorsTx(ctx, tx, projectID)
+	if err != nil {
+		return report, err
+	}
+	type attester struct{ actorID, kind string }
+	allowed := map[attester]bool{}
+	for _, att := range atts {
+		key := attester{att.ActorID, att.Kind}
+		ok, seen := allowed[key]
+		if !seen {
+			if ok, err = e.Auth.ActorCanAttest(ctx, tx, projectID, att.ActorID, att.Kind); err != nil {
+				return report, err
+			}
+			allowed[key] = ok
+		}
+		if !ok {
+			report.Unauthorized = append(report.Unauthorized, att)
+		}
+	}
+	return report, nil
+}
+
+// authorityEventConcerns reports whether evt changed the authority of roleID over kind.
+func authorityEventConcerns(evt domain.Event, kind, roleID string) bool {
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(evt.Payload), &payload); err != nil || payload["role_id"] != roleID {
+		return false
+	}
+	switch evt.Type {
+	case "rbac.attestation_allowed", "rbac.attestation_denied":
+		return payload["kind"] == kind
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"workline/internal/domain"
+)
+
+// MaxBulkAttestations caps how many attestations one bulk request may carry.
+const MaxBulkAttestations = 500
+
+// Bulk item outcomes.
+const (
+	BulkCreated    = "created"
+	BulkFailed     = "failed"
+	BulkRolledBack = "rolled_back"
+)
+
+// BulkAttestationResult reports what happened to one item of a bulk request, by position.
+type BulkAttestationResult struct {
+	Index       int
+	Status      string
+	Attestation domain.Attestation
+	Err         error
+}
+
+// BulkAttestationOutcome is the per-item report of AddAttestations. Committed is false when
+// an atomic request was rolled back because an item failed.
+type BulkAttestationOutcome struct {
+	Results   []BulkAttestationResult
+	Committed bool
+	Created   int
+	Failed    int
+}
+
+// AddAttestations records many attestations for one project in a single transaction. Each
+// item runs in its own savepoint: a failing item is reported and skipped while the others
+// commit together, unless atomic is set, in which case any failure rolls back the batch.
+func (e Engine) AddAttestations(ctx context.Context, projectID string, atts []domain.Attestation, actorID string, atomic bool) (BulkAttestationOutcome, error) {
+	var out BulkAttestationOutcome
+	if len(atts) == 0 {
+		return out, errors.New("attestations required")
+	}
+	if len(atts) > MaxBulkAttestations {
+		return out, fmt.Errorf("invalid bulk request: %d attestations exceeds the limit of %d", len(atts), MaxBulkAttestations)
+	}
+	out.Results = make([]BulkAttestationResult, len(atts))
+	blobRefs := make([]*BlobRef, len(atts))
+	for i, att := range atts {
+		att.ProjectID = projectID
+		prepared, ref, err := e.prepareAttestation(ctx, att, actorID)
+		out.Results[i] = BulkAttestationResult{Index: i, Attestation: prepared, Err: err}
+		blobRefs[i] = ref
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return out, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "attestation.add"); err != nil {
+		return out, err
+	}
+	for i := range out.Results {
+		res := &out.Results[i]
+		if res.Err != nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+			return out, err
+		}
+		res.Attestation, res.Err = e.addAttestationTx(ctx, tx, res.Attestation, actorID, blobRefs[i], nil)
+		if res.Err != nil {
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO bulk_item`); err != nil {
+				return out, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE bulk_item`); err != nil {
+			return out, err
+		}
+	}
+	for i := range out.Results {
+		if out.Results[i].Err != nil {
+			out.Results[i].Status = BulkFailed
+			out.Failed++
+		} else {
+			out.Results[i].Status = BulkCreated
+			out.Created++
+		}
+	}
+	if atomic && out.Failed > 0 {
+		for i := range out.Results {
+			if out.Results[i].Status == BulkCreated {
+				out.Results[i].Status = BulkRolledBack
+			}
+		}
+		out.Created = 0
+		return out, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return out, err
+	}
+	out.Committed = true
+	return out, nil
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// Cancellation cascades, from narrowest to widest. Children also cancels every open
+// descendant; dependents additionally cancels open tasks depending on any canceled task.
+const (
+	CascadeNone       = "none"
+	CascadeChildren   = "children"
+	CascadeDependents = "dependents"
+)
+
+// CancelItem is one task reached by a cancellation. Via tells how: the requested task
+// itself, a child or a dependent of the task Of.
+type CancelItem struct {
+	TaskID string `json:"task_id"`
+	Title  string `json:"title"`
+	Status string `json:"status" doc:"Status before the cancellation"`
+	Via    string `json:"via" enum:"requested,child,dependent"`
+	Of     string `json:"of,omitempty"`
+	Reason string `json:"reason,omitempty" doc:"Why a task was skipped"`
+}
+
+// TaskCancellation reports a cancellation. Blocked lists open tasks left depending on a
+// canceled task, which can no longer complete until that dependency is removed. Skipped
+// lists cascaded tasks left unchanged because the transition is not allowed or another
+// actor holds their lease; force cancels them anyway.
+type TaskCancellation struct {
+	TaskID   string       `json:"task_id"`
+	Cascade  string       `json:"cascade" enum:"none,children,dependents"`
+	DryRun   bool         `json:"dry_run"`
+	Canceled []CancelItem `json:"canceled"`
+	Blocked  []CancelItem `json:"blocked"`
+	Skipped  []CancelItem `json:"skipped"`
+}
+
+type TaskCancelOptions struct {
+	ID      string
+	ActorID string
+	Cascade string
+	// DryRun computes the report without changing anything.
+	DryRun bool
+	Force  bool
+}
+
+// CancelTask cancels a task and, depending on opts.Cascade, its descendants and dependents
+// in one transaction. Canceled tasks record task.updated; blocked ones record task.blocked.
+func (e Engine) CancelTask(ctx context.Context, opts TaskCancelOptions) (TaskCancellation, error) {
+	if e.Config == nil {
+		return TaskCancellation{}, errors.New("config not loaded")
+	}
+	if opts.Cascade == "" {
+		opts.Cascade = CascadeNone
+	}
+	if !slices.Contains([]string{CascadeNone, CascadeChildren, CascadeDependents}, opts.Cascade) {
+		return TaskCancellation{}, fmt.Errorf("invalid cascade %q: expected none, children or dependents", opts.Cascade)
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TaskCancellation{}, err
+	}
+	defer tx.Rollback()
+	root, err := e.Repo.GetTaskTx(ctx, tx, opts.ID)
+	if err != nil {
+		return TaskCancellation{}, err
+	}
+	if err := e.requirePermission(ctx, tx, root.ProjectID, opts.ActorID, "task.update"); err != nil {
+		return TaskCancellation{}, err
+	}
+	if opts.Force {
+		if err := e.requireForcePermission(ctx, tx, root.ProjectID, opts.ActorID); err != nil {
+			return TaskCancellation{}, err
+		}
+	}
+	if err := e.ensureTaskTransition(root.Type, root.Status, "canceled", opts.Force); err != nil {
+		return TaskCancellation{}, err
+	}
+	if err := e.requireStatusLease(ctx, tx, root, opts.ActorID, opts.Force); err != nil {
+		return TaskCancellation{}, err
+	}
+
+	res := TaskCancellation{TaskID: root.ID, Cascade: opts.Cascade, DryRun: opts.DryRun, Canceled: []CancelItem{}, Blocked: []CancelItem{}, Skipped: []CancelItem{}}
+	tasks := map[string]domain.Task{root.ID: root}
+	visited := map[string]bool{root.ID: true}
+	res.Canceled = append(res.Canceled, CancelItem{TaskID: root.ID, Title: root.Title, Status: root.Status, Via: "requested"})
+	blockedBy := map[string]string{}
+	var blockedOrder []string
+	reach := func(id, via, of string) error {
+		if visited[id] {
+			return nil
+		}
+		t, err := e.Repo.GetTaskTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if t.Status == "done" || t.Status == "canceled" {
+			return nil
+		}
+		visited[id] = true
+		item := CancelItem{TaskID: t.ID, Title: t.Title, Status: t.Status, Via: via, Of: of}
+		item.Reason, err = e.cascadeBlocker(ctx, tx, t, opts.ActorID, opts.Force)
+		if err != nil {
+			return err
+		}
+		if item.Reason != "" {
+			res.Skipped = append(res.Skipped, item)
+			return nil
+		}
+		tasks[t.ID] = t
+		res.Canceled = append(res.Canceled, item)
+		return nil
+	}
+	// res.Canceled grows while it is walked, so every canceled task is expanded in turn.
+	for i := 0; i < len(res.Canceled); i++ {
+		id := res.Canceled[i].TaskID
+		if opts.Cascade != CascadeNone {
+			children, err := e.Repo.ListChildrenTx(ctx, tx, id)
+			if err != nil {
+				return res, err
+			}
+			slices.Sort(children)
+			for _, child := range children {
+				if err := reach(child, "child", id); err != nil {
+					return res, err
+				}
+			}
+		}
+		dependents, err := e.Repo.ListOpenDependentsTx(ctx, tx, id)
+		if err != nil {
+			return res, err
+		}
+		for _, dep := range dependents {
+			if opts.Cascade == CascadeDependents {
+				if err := reach(dep, "dependent", id); err != nil {
+					return res, err
+				}
+			} else if _, ok := blockedBy[dep]; !ok {
+				blockedBy[dep] = id
+				blockedOrder = append(blockedOrder, dep)
+			}
+		}
+	}
+	for _, id := range blockedOrder {
+		if _, canceled := tasks[id]; canceled {
+			continue
+		}
+		t, err := e.Repo.GetTaskTx(ctx, tx, id)
+		if err != nil {
+			return res, err
+		}
+		res.Blocked = append(res.Blocked, CancelItem{TaskID: t.ID, Title: t.Title, Status: t.Status, Via: "dependent", Of: blockedBy[id]})
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	now := e.now().UTC().Format(time.RFC3339)
+	for _, item := range res.Canceled {
+		t := tasks[item.TaskID]
+		t.Status = "canceled"
+		t.UpdatedAt = now
+		if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+			return res, err
+		}
+		// Canceling settles a completion awaiting approval.
+		if err := e.checkPendingCompletion(ctx, tx, t, opts.ActorID, true); err != nil {
+			return res, err
+		}
+		payload := events.EventPayload{"from_status": item.Status, "to_status": t.Status}
+		if item.Via != "requested" {
+			payload["canceled_via"] = item.Via
+			payload["of"] = item.Of
+		}
+		if err := e.Events.Append(ctx, tx, "task.updated", t.ProjectID, "task", t.ID, opts.ActorID, payload); err != nil {
+			return res, err
+		}
+	}
+	for _, item := range res.Canceled {
+		if err := e.rollupTx(ctx, tx, tasks[item.TaskID].ParentID, opts.ActorID); err != nil {
+			return res, err
+		}
+	}
+	for _, item := range res.Blocked {
+		if err := e.Events.Append(ctx, tx, "task.blocked", root.ProjectID, "task", item.TaskID, opts.ActorID, events.EventPayload{
+			"canceled_dependency": item.Of,
+		}); err != nil {
+			return res, err
+		}
+	}
+	return res, tx.Commit()
+}
+
+// cascadeBlocker explains why a task reached by a cascade cannot be canceled, or returns "".
+func (e Engine) cascadeBlocker(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string, force bool) (string, error) {
+	if force {
+		return "", nil
+	}
+	if err := e.ensureTaskTransition(t.Type, t.Status, "canceled", false); err != nil {
+		return fmt.Sprintf("transition %s -> canceled not allowed", t.Status), nil
+	}
+	l, err := e.Repo.GetLeaseTx(ctx, tx, t.ID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	exp, _ := time.Parse(time.RFC3339, l.ExpiresAt)
+	if l.OwnerID != actorID && e.now().Before(exp) {
+		return "lease held by " + l.OwnerID, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+var capabilityPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._+-]*$`)
+
+// normalizeCapabilities lowercases, deduplicates and sorts capability names.
+func normalizeCapabilities(in []string) ([]string, error) {
+	var caps []string
+	for _, c := range in {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if !capabilityPattern.MatchString(c) {
+			return nil, fmt.Errorf("invalid capability %q", c)
+		}
+		if !slices.Contains(caps, c) {
+			caps = append(caps, c)
+		}
+	}
+	sort.Strings(caps)
+	return caps, nil
+}
+
+// SetActorCapabilities replaces the capabilities target offers in the project. Actors may
+// register their own capabilities when they can claim tasks; setting anyone else's
+// requires capability.manage.
+func (e Engine) SetActorCapabilities(ctx context.Context, projectID, target string, caps []string, actorID string) ([]string, error) {
+	if target == "" {
+		target = actorID
+	}
+	caps, err := normalizeCapabilities(caps)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	perm := "task.claim"
+	if target != actorID {
+		perm = "capability.manage"
+	}
+	if err := e.requirePermission(ctx, tx, projectID, actorID, perm); err != nil {
+		return nil, err
+	}
+	if err := e.Repo.SetActorCapabilitiesTx(ctx, tx, projectID, target, caps, e.now().UTC().Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+	if err := e.Events.Append(ctx, tx, "actor.capabilities_set", projectID, "rbac", target, actorID, events.EventPayload{
+		"capabilities": caps,
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return caps, nil
+}
+
+// ReadyTasks lists the project's claimable tasks for the actor: planned, with every
+// dependency done, no active lease, and required capabilities the actor offers. They come
+// oldest first, with siblings (same parent and iteration) swapped into rank order among the
+// places they hold, or earliest deadline first under scheduler.mode deadline.
+func (e Engine) ReadyTasks(ctx context.Context, projectID, actorID string) ([]domain.Task, error) {
+	sched, err := e.schedulerConfig(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	caps, err := e.Repo.ListActorCapabilities(ctx, projectID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{
+		ProjectID: projectID,
+		ReadyFor:  actorID,
+		Now:       e.now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ready []domain.Task
+	for _, t := range tasks {
+		if hasCapabilities(caps, t.RequiredCapabilities) {
+			ready = append(ready, t)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		if ready[i].CreatedAt != ready[j].CreatedAt {
+			return ready[i].CreatedAt < ready[j].CreatedAt
+		}
+		return ready[i].ID < ready[j].ID
+	})
+	rankWithinSiblings(ready)
+	if sched.Deadline() && len(ready) > 1 {
+		if err := e.sortByDeadline(ctx, projectID, ready, sched.ChainStep()); err != nil {
+			return nil, err
+		}
+	}
+	return ready, nil
+}
+
+// ClaimNext leases the first task of the actor's ready queue. It returns repo.ErrNotFound
+// when nothing is ready.
+func (e Engine) ClaimNext(ctx context.Context, projectID, actorID string, leaseSeconds int) (domain.Task, domain.Lease, error) {
+	ready, err := e.ReadyTasks(ctx, projectID, actorID)
+	if err != nil {
+		return domain.Task{}, domain.Lease{}, err
+	}
+	for _, t := range ready {
+		lease, err := e.ClaimLease(ctx, t.ID, actorID, leaseSeconds)
+		if err == nil {
+			return t, lease, nil
+		}
+		// Another actor won the race for this task; move on to the next one.
+		if strings.Contains(err.Error(), "lease already held") {
+			continue
+		}
+		return domain.Task{}, domain.Lease{}, err
+	}
+	return domain.Task{}, domain.Lease{}, fmt.Errorf("no ready task: %w", repo.ErrNotFound)
+}
+
+func hasCapabilities(offered, required []string) bool {
+	for _, c := range required {
+		if !slices.Contains(offered, c) {
+			return false
+		}
+	}
+	return true
+}
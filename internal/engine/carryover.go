@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"workline/internal/canon"
+	"workline/internal/domain"
+	"workline/internal/events"
+)
+
+// CarryOverResult describes a finished carry-over. Target is nil when tasks went to the backlog.
+type CarryOverResult struct {
+	Source  domain.Iteration
+	Target  *domain.Iteration
+	TaskIDs []string
+}
+
+// CarryOverIteration moves every task of the source iteration that is neither done nor
+// canceled into the target iteration, or to the backlog when targetID is empty. Moved tasks
+// keep their relative order after the target's existing tasks. Each move is recorded as
+// task.carried_over and the totals as iteration.carried_over. A frozen target needs
+// freezeOverride, the reason recorded for each task it takes in.
+func (e Engine) CarryOverIteration(ctx context.Context, sourceID, targetID, freezeOverride, actorID string) (CarryOverResult, error) {
+	if sourceID == targetID {
+		return CarryOverResult{}, errors.New("invalid carry-over: target iteration must differ from the source")
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return CarryOverResult{}, err
+	}
+	defer tx.Rollback()
+	src, err := e.Repo.GetIterationTx(ctx, tx, sourceID)
+	if err != nil {
+		return CarryOverResult{}, err
+	}
+	if err := e.requirePermission(ctx, tx, src.ProjectID, actorID, "iteration.carry_over"); err != nil {
+		return CarryOverResult{}, err
+	}
+	res := CarryOverResult{Source: src}
+	var targetPtr *string
+	if targetID != "" {
+		target, err := e.Repo.GetIterationTx(ctx, tx, targetID)
+		if err != nil {
+			return res, err
+		}
+		if target.ProjectID != src.ProjectID {
+			return res, fmt.Errorf("invalid carry-over: iteration %s not in project %s", targetID, src.ProjectID)
+		}
+		if target.Status != "pending" && target.Status != "running" {
+			return res, fmt.Errorf("invalid carry-over: target iteration %s is %s", targetID, target.Status)
+		}
+		res.Target = &target
+		targetPtr = &target.ID
+	}
+	ids, err := e.Repo.ListUnfinishedIterationTaskIDsTx(ctx, tx, src.ID)
+	if err != nil {
+		return res, err
+	}
+	now := e.now().UTC().Format(time.RFC3339)
+	for _, id := range ids {
+		t, err := e.Repo.GetTaskTx(ctx, tx, id)
+		if err != nil {
+			return res, err
+		}
+		if err := e.checkFreezeTx(ctx, tx, targetPtr, t.ID, FreezeAddTask, freezeOverride, actorID); err != nil {
+			return res, err
+		}
+		rank, err := e.Repo.NextTaskRankTx(ctx, tx, t.ProjectID, t.ParentID, targetPtr)
+		if err != nil {
+			return res, err
+		}
+		t.IterationID = targetPtr
+		t.UpdatedAt = now
+		if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+			return res, err
+		}
+		if err := e.Repo.SetTaskRank(ctx, tx, t.ID, rank); err != nil {
+			return res, err
+		}
+		t.ContentHash = canon.TaskHash(t)
+		if err := e.Events.Append(ctx, tx, "task.carried_over", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+			"from_iteration": src.ID,
+			"to_iteration":   targetID,
+			"status":         t.Status,
+		}); err != nil {
+			return res, err
+		}
+		res.TaskIDs = append(res.TaskIDs, t.ID)
+	}
+	n := len(res.TaskIDs)
+	if err := e.Repo.AddIterationCarryOverTx(ctx, tx, src.ID, n, 0); err != nil {
+		return res, err
+	}
+	res.Source.CarriedOut += n
+	if res.Target != nil {
+		if err := e.Repo.AddIterationCarryOverTx(ctx, tx, res.Target.ID, 0, n); err != nil {
+			return res, err
+		}
+		res.Target.CarriedIn += n
+	}
+	if err := e.Events.Append(ctx, tx, "iteration.carried_over", src.ProjectID, "iteration", src.ID, actorID, events.EventPayload{
+		"to_iteration": targetID,
+		"count":        n,
+	}); err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+	return res, nil
+}
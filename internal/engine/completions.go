@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"workline/internal/canon"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// submitCompletion moves t to review with a pending completion instead of finishing it,
+// for projects whose completions need approval. t carries the work outcomes to store.
+func (e Engine) submitCompletion(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) (domain.Task, error) {
+	from := t.Status
+	if from != "review" {
+		if err := e.ensureTaskTransition(t.Type, from, "review", false); err != nil {
+			return t, err
+		}
+	}
+	now := e.now().UTC().Format(time.RFC3339)
+	t.Status = "review"
+	t.UpdatedAt = now
+	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+		return t, err
+	}
+	t.ContentHash = canon.TaskHash(t)
+	id, err := e.Repo.InsertTaskCompletionTx(ctx, tx, domain.TaskCompletion{
+		TaskID:      t.ID,
+		ProjectID:   t.ProjectID,
+		Status:      "pending",
+		SubmittedBy: actorID,
+		SubmittedAt: now,
+	})
+	if err != nil {
+		return t, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.completion.submitted", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"completion_id": id,
+		"from_status":   from,
+	}); err != nil {
+		return t, err
+	}
+	if from != t.Status {
+		if err := e.rollupTx(ctx, tx, t.ParentID, actorID); err != nil {
+			return t, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return t, err
+	}
+	t.DependsOn, _ = e.Repo.ListTaskDependencies(ctx, t.ID)
+	return t, nil
+}
+
+// checkPendingCompletion refuses to change the status of a task whose completion awaits
+// approval. A forced change, like a cancellation, goes through and withdraws the
+// completion.
+func (e Engine) checkPendingCompletion(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string, force bool) error {
+	c, err := e.Repo.PendingTaskCompletionTx(ctx, tx, t.ID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !force {
+		return fmt.Errorf("invalid status change: completion %d of task %s awaits approval", c.ID, t.ID)
+	}
+	now := e.now().UTC().Format(time.RFC3339)
+	c.Status = "withdrawn"
+	c.DecidedBy = &actorID
+	c.DecidedAt = &now
+	if err := e.Repo.DecideTaskCompletionTx(ctx, tx, c); err != nil {
+		return err
+	}
+	return e.Events.Append(ctx, tx, "task.completion.withdrawn", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"completion_id": c.ID,
+	})
+}
+
+// pendingCompletion loads the completion of t awaiting a decision by actorID, who needs
+// task.approve.
+func (e Engine) pendingCompletion(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) (domain.TaskCompletion, error) {
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.approve"); err != nil {
+		return domain.TaskCompletion{}, err
+	}
+	c, err := e.Repo.PendingTaskCompletionTx(ctx, tx, t.ID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return c, fmt.Errorf("no completion of task %s awaits approval: %w", t.ID, err)
+	}
+	return c, err
+}
+
+// ApproveTaskCompletion confirms the completion pending on a task: the task becomes done,
+// and only then gets its completed_at. The task must still meet its validation policy.
+// The submitter cannot approve their own completion.
+func (e Engine) ApproveTaskCompletion(ctx context.Context, taskID, actorID string) (domain.Task, error) {
+	if e.Config == nil {
+		return domain.Task{}, errors.New("config not loaded")
+	}
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return t, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return t, err
+	}
+	defer tx.Rollback()
+	c, err := e.pendingCompletion(ctx, tx, t, actorID)
+	if err != nil {
+		return t, err
+	}
+	if c.SubmittedBy == actorID {
+		return t, fmt.Errorf("invalid approval: %s submitted completion %d and cannot approve it", actorID, c.ID)
+	}
+	if err := e.ensureDependenciesDone(ctx, tx, t.ID, t.ProjectID, false); err != nil {
+		return t, err
+	}
+	if err := e.ensureSubtasksDone(ctx, tx, t.ID, false); err != nil {
+		return t, err
+	}
+	unmet, err := e.unmetRequirements(ctx, tx, t)
+	if err != nil {
+		return t, err
+	}
+	if len(unmet) > 0 {
+		return t, e.rejectCompletion(ctx, tx, t, actorID, unmet)
+	}
+	if err := e.ensureTaskTransition(t.Type, t.Status, "done", false); err != nil {
+		return t, err
+	}
+	now := e.now().UTC().Format(time.RFC3339)
+	t.Status = "done"
+	t.UpdatedAt = now
+	t.CompletedAt = &now
+	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+		return t, err
+	}
+	t.ContentHash = canon.TaskHash(t)
+	c.Status = "approved"
+	c.DecidedBy = &actorID
+	c.DecidedAt = &now
+	if err := e.Repo.DecideTaskCompletionTx(ctx, tx, c); err != nil {
+		return t, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.completion.approved", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"completion_id": c.ID,
+		"submitted_by":  c.SubmittedBy,
+	}); err != nil {
+		return t, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.done", t.ProjectID, "task", t.ID, actorID, events.EventPayload{"status": t.Status}); err != nil {
+		return t, err
+	}
+	if err := e.emitUnblocked(ctx, tx, t, actorID); err != nil {
+		return t, err
+	}
+	if err := e.rollupTx(ctx, tx, t.ParentID, actorID); err != nil {
+		return t, err
+	}
+	if err := tx.Commit(); err != nil {
+		return t, err
+	}
+	t.DependsOn, _ = e.Repo.ListTaskDependencies(ctx, t.ID)
+	return t, nil
+}
+
+// RejectTaskCompletion turns down the completion pending on a task, which moves to
+// rejected with the reason recorded on the completion.
+func (e Engine) RejectTaskCompletion(ctx context.Context, taskID, actorID, reason string) (domain.Task, error) {
+	if e.Config == nil {
+		return domain.Task{}, errors.New("config not loaded")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return domain.Task{}, errors.New("reason is required")
+	}
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return t, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return t, err
+	}
+	defer tx.Rollback()
+	c, err := e.pendingCompletion(ctx, tx, t, actorID)
+	if err != nil {
+		return t, err
+	}
+	if err := e.ensureTaskTransition(t.Type, t.Status, "rejected", false); err != nil {
+		return t, err
+	}
+	now := e.now().UTC().Format(time.RFC3339)
+	t.Status = "rejected"
+	t.UpdatedAt = now
+	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+		return t, err
+	}
+	t.ContentHash = canon.TaskHash(t)
+	c.Status = "rejected"
+	c.DecidedBy = &actorID
+	c.DecidedAt = &now
+	c.Reason = reason
+	if err := e.Repo.DecideTaskCompletionTx(ctx, tx, c); err != nil {
+		return t, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.completion.rejected", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"completion_id": c.ID,
+		"submitted_by":  c.SubmittedBy,
+		"reason":        reason,
+	}); err != nil {
+		return t, err
+	}
+	if err := e.rollupTx(ctx, tx, t.ParentID, actorID); err != nil {
+		return t, err
+	}
+	if err := tx.Commit(); err != nil {
+		return t, err
+	}
+	t.DependsOn, _ = e.Repo.ListTaskDependencies(ctx, t.ID)
+	return t, nil
+}
@@ -0,0 +1,331 @@
+package engine
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/expr"
+	"workline/internal/repo"
+)
+
+// ControlSummary counts, for one declared control, the entities in scope for the period and
+// how many of them carried the full evidence.
+type ControlSummary struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description,omitempty"`
+	Kinds       []string `json:"kinds"`
+	AppliesTo   []string `json:"applies_to"`
+	Entities    int      `json:"entities"`
+	Satisfied   int      `json:"satisfied"`
+}
+
+// ComplianceRow is one entity checked against one control. Present and Missing split the
+// control's kinds; Attestations lists the evidencing attestation ids.
+type ComplianceRow struct {
+	Control      string   `json:"control"`
+	EntityKind   string   `json:"entity_kind"`
+	EntityID     string   `json:"entity_id"`
+	Title        string   `json:"title"`
+	Status       string   `json:"status"`
+	Satisfied    bool     `json:"satisfied"`
+	Present      []string `json:"present"`
+	Missing      []string `json:"missing"`
+	Attestations []string `json:"attestations"`
+	EvidencedAt  string   `json:"evidenced_at,omitempty" format:"date-time"`
+}
+
+// ComplianceReport maps declared controls to the tasks and iterations of one period. An
+// entity is in scope when it is a task completed in [From, To) or it received an attestation
+// of a mapped kind in that window; evidence counts when recorded before To.
+type ComplianceReport struct {
+	ProjectID   string           `json:"project_id"`
+	Framework   string           `json:"framework,omitempty"`
+	From        string           `json:"from" format:"date-time"`
+	To          string           `json:"to" format:"date-time"`
+	GeneratedAt string           `json:"generated_at" format:"date-time"`
+	Controls    []ControlSummary `json:"controls"`
+	Rows        []ComplianceRow  `json:"rows"`
+}
+
+// ParsePeriod reads report bounds given as RFC3339 timestamps or YYYY-MM-DD days. A day
+// used as to covers the whole day. Empty to means the end of today (UTC); empty from means
+// 30 days before to.
+func ParsePeriod(from, to string, now time.Time) (time.Time, time.Time, error) {
+	end := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if to != "" {
+		t, err := parsePeriodBound(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		if len(to) == len(time.DateOnly) {
+			t = t.AddDate(0, 0, 1)
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -30)
+	if from != "" {
+		t, err := parsePeriodBound(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("invalid period: from must be before to")
+	}
+	return start, end, nil
+}
+
+func parsePeriodBound(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC3339 nor YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// ComplianceReport builds the control report for a project over [from, to).
+func (e Engine) ComplianceReport(ctx context.Context, projectID string, from, to time.Time) (ComplianceReport, error) {
+	if !from.Before(to) {
+		return ComplianceReport{}, errors.New("invalid period: from must be before to")
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return ComplianceReport{}, err
+	}
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) && e.Config != nil {
+		cfg = e.Config
+	} else if err != nil {
+		return ComplianceReport{}, err
+	}
+	if len(cfg.Compliance.Controls) == 0 {
+		return ComplianceReport{}, fmt.Errorf("invalid compliance report: project %s declares no controls under compliance.controls", projectID)
+	}
+	fromTS, toTS := from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)
+	rep := ComplianceReport{
+		ProjectID:   projectID,
+		Framework:   cfg.Compliance.Framework,
+		From:        fromTS,
+		To:          toTS,
+		GeneratedAt: e.now().UTC().Format(time.RFC3339),
+		Controls:    []ControlSummary{},
+		Rows:        []ComplianceRow{},
+	}
+
+	ids := make([]string, 0, len(cfg.Compliance.Controls))
+	kindSet := map[string]bool{}
+	for id, control := range cfg.Compliance.Controls {
+		ids = append(ids, id)
+		for _, req := range control.Kinds {
+			kind, countersign := config.SplitRequirement(req)
+			kindSet[kind] = true
+			if countersign != "" {
+				kindSet[countersign] = true
+			}
+		}
+	}
+	sort.Strings(ids)
+	kinds := make([]string, 0, len(kindSet))
+	for k := range kindSet {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	atts, err := e.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: projectID, Kinds: kinds, Before: toTS})
+	if err != nil {
+		return rep, err
+	}
+	ev := newControlEvidence(atts)
+	predicates := map[string]*expr.Expr{}
+	matches := func(a domain.Attestation, predicate string) bool {
+		pred, ok := predicates[predicate]
+		if !ok {
+			pred, _ = expr.Parse(predicate)
+			predicates[predicate] = pred
+		}
+		if pred == nil {
+			return false
+		}
+		vars, err := e.attestationVars(ctx, a)
+		if err != nil {
+			return false
+		}
+		met, err := pred.Eval(vars)
+		return err == nil && met
+	}
+
+	type entityKey struct{ kind, id string }
+	titles := map[entityKey][2]string{}
+	inScope := map[entityKey]bool{}
+	completed, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, CompletedFrom: fromTS, CompletedTo: toTS})
+	if err != nil {
+		return rep, err
+	}
+	for _, t := range completed {
+		k := entityKey{"task", t.ID}
+		inScope[k] = true
+		titles[k] = [2]string{t.Title, t.Status}
+	}
+	for _, a := range atts {
+		if a.TS >= fromTS && (a.EntityKind == "task" || a.EntityKind == "iteration") {
+			inScope[entityKey{a.EntityKind, a.EntityID}] = true
+		}
+	}
+	keys := make([]entityKey, 0, len(inScope))
+	for k := range inScope {
+		if _, ok := titles[k]; !ok {
+			switch k.kind {
+			case "task":
+				t, err := e.Repo.GetTask(ctx, k.id)
+				if errors.Is(err, repo.ErrNotFound) {
+					continue
+				} else if err != nil {
+					return rep, err
+				}
+				titles[k] = [2]string{t.Title, t.Status}
+			case "iteration":
+				it, err := e.Repo.GetIteration(ctx, k.id)
+				if errors.Is(err, repo.ErrNotFound) {
+					continue
+				} else if err != nil {
+					return rep, err
+				}
+				titles[k] = [2]string{it.Goal, it.Status}
+			}
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind > keys[j].kind
+		}
+		return keys[i].id < keys[j].id
+	})
+
+	for _, id := range ids {
+		control := cfg.Compliance.Controls[id]
+		summary := ControlSummary{
+			ID:          id,
+			Description: control.Description,
+			Kinds:       control.Kinds,
+			AppliesTo:   control.Targets(),
+		}
+		for _, k := range keys {
+			if !slices.Contains(summary.AppliesTo, k.kind) {
+				continue
+			}
+			row := ComplianceRow{
+				Control:      id,
+				EntityKind:   k.kind,
+				EntityID:     k.id,
+				Title:        titles[k][0],
+				Status:       titles[k][1],
+				Present:      []string{},
+				Missing:      []string{},
+				Attestations: []string{},
+			}
+			for _, req := range control.Kinds {
+				att, ok := ev.find(k.kind, k.id, req, matches)
+				if !ok {
+					row.Missing = append(row.Missing, req)
+					continue
+				}
+				row.Present = append(row.Present, req)
+				row.Attestations = append(row.Attestations, att.ID)
+				if att.TS > row.EvidencedAt {
+					row.EvidencedAt = att.TS
+				}
+			}
+			row.Satisfied = len(row.Missing) == 0
+			if !row.Satisfied {
+				row.EvidencedAt = ""
+			}
+			summary.Entities++
+			if row.Satisfied {
+				summary.Satisfied++
+			}
+			rep.Rows = append(rep.Rows, row)
+		}
+		rep.Controls = append(rep.Controls, summary)
+	}
+	return rep, nil
+}
+
+// WriteCSV writes one line per report row. Multi-valued columns are joined with ";".
+func (r ComplianceReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"control", "entity_kind", "entity_id", "title", "status", "satisfied", "present", "missing", "attestations", "evidenced_at"}); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		if err := cw.Write([]string{
+			row.Control,
+			row.EntityKind,
+			row.EntityID,
+			row.Title,
+			row.Status,
+			strconv.FormatBool(row.Satisfied),
+			strings.Join(row.Present, ";"),
+			strings.Join(row.Missing, ";"),
+			strings.Join(row.Attestations, ";"),
+			row.EvidencedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// controlEvidence indexes attestations by their target so requirements, including
+// "kind+countersign" pairs, resolve without further queries.
+type controlEvidence struct {
+	byEntity map[string][]domain.Attestation
+}
+
+func newControlEvidence(atts []domain.Attestation) controlEvidence {
+	ev := controlEvidence{byEntity: map[string][]domain.Attestation{}}
+	// Oldest first, so find returns the earliest evidence.
+	for i := len(atts) - 1; i >= 0; i-- {
+		a := atts[i]
+		key := a.EntityKind + "/" + a.EntityID
+		ev.byEntity[key] = append(ev.byEntity[key], a)
+	}
+	return ev
+}
+
+// find returns the earliest attestation meeting req; matches checks a payload predicate.
+func (ev controlEvidence) find(entityKind, entityID, req string, matches func(a domain.Attestation, predicate string) bool) (domain.Attestation, bool) {
+	r := config.ParseRequirement(req)
+	kind, countersign := r.Kind, r.Countersign
+	for _, a := range ev.byEntity[entityKind+"/"+entityID] {
+		if a.Kind != kind {
+			continue
+		}
+		if r.Predicate != "" && !matches(a, r.Predicate) {
+			continue
+		}
+		if countersign == "" {
+			return a, true
+		}
+		for _, c := range ev.byEntity["attestation/"+a.ID] {
+			if c.Kind == countersign {
+				return c, true
+			}
+		}
+	}
+	return domain.Attestation{}, false
+}
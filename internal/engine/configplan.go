@@ -0,0 +1,314 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// ConfigChange is one semantic difference between the current and a submitted project
+// config. Added and Removed list what a preset or a transition gains and loses (attestation
+// kinds, target statuses); From and To are the old and new value of a single setting.
+type ConfigChange struct {
+	Section string   `json:"section" enum:"preset,task_default,iteration_validation,attestation_kind,task_type,transition,setting"`
+	Name    string   `json:"name" doc:"Preset, task type, kind, status (type/status for per-type transitions) or setting"`
+	Action  string   `json:"action" enum:"added,removed,changed"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	From    string   `json:"from,omitempty"`
+	To      string   `json:"to,omitempty"`
+}
+
+// ConfigAffectedTask is an open task the change concerns, with why. Tasks keep the
+// requirements they were created with, so a changed preset is reported, not applied.
+type ConfigAffectedTask struct {
+	TaskID  string   `json:"task_id"`
+	Title   string   `json:"title"`
+	Type    string   `json:"type"`
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+}
+
+// ConfigPlan is the semantic diff between a project's config and a submitted one.
+type ConfigPlan struct {
+	ProjectID     string               `json:"project_id"`
+	Applied       bool                 `json:"applied"`
+	Changes       []ConfigChange       `json:"changes"`
+	AffectedTasks []ConfigAffectedTask `json:"affected_tasks"`
+}
+
+// PlanConfigChange compares next with the project's stored config and lists the open
+// tasks the change concerns. next must be valid; its project id defaults to projectID.
+// Callers check permissions.
+func (e Engine) PlanConfigChange(ctx context.Context, projectID string, next *config.Config) (ConfigPlan, error) {
+	plan := ConfigPlan{ProjectID: projectID, Changes: []ConfigChange{}, AffectedTasks: []ConfigAffectedTask{}}
+	if next.Project.ID == "" {
+		next.Project.ID = projectID
+	}
+	if next.Project.ID != projectID {
+		return plan, fmt.Errorf("invalid config: project id %s does not match project %s", next.Project.ID, projectID)
+	}
+	if err := next.Validate(); err != nil {
+		return plan, fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return plan, err
+	}
+	current, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		current = config.Default(projectID)
+	} else if err != nil {
+		return plan, err
+	}
+	plan.Changes = diffConfig(current, next)
+	if len(plan.Changes) == 0 {
+		return plan, nil
+	}
+	tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID})
+	if err != nil {
+		return plan, err
+	}
+	slices.SortFunc(tasks, func(a, b domain.Task) int { return strings.Compare(a.ID, b.ID) })
+	for _, t := range tasks {
+		if t.Status == "done" || t.Status == "rejected" || t.Status == "canceled" {
+			continue
+		}
+		if reasons := configChangeReasons(current, next, t); len(reasons) > 0 {
+			plan.AffectedTasks = append(plan.AffectedTasks, ConfigAffectedTask{TaskID: t.ID, Title: t.Title, Type: t.Type, Status: t.Status, Reasons: reasons})
+		}
+	}
+	return plan, nil
+}
+
+// UpdateProjectConfig replaces the project's config with next and returns the plan it
+// applied. It records project.config.updated.
+func (e Engine) UpdateProjectConfig(ctx context.Context, projectID, actorID string, next *config.Config) (ConfigPlan, error) {
+	plan, err := e.PlanConfigChange(ctx, projectID, next)
+	if err != nil {
+		return plan, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return plan, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "project.update"); err != nil {
+		return plan, err
+	}
+	if err := e.Repo.UpsertProjectConfigTx(ctx, tx, projectID, next); err != nil {
+		return plan, err
+	}
+	if err := e.Events.Append(ctx, tx, "project.config.updated", projectID, "project", projectID, actorID, events.EventPayload{
+		"changes":        len(plan.Changes),
+		"affected_tasks": len(plan.AffectedTasks),
+	}); err != nil {
+		return plan, err
+	}
+	if err := tx.Commit(); err != nil {
+		return plan, err
+	}
+	plan.Applied = true
+	return plan, nil
+}
+
+// diffConfig lists what changes from before to after, section by section in name order.
+// Sections without a semantic diff of their own are compared whole as settings.
+func diffConfig(before, after *config.Config) []ConfigChange {
+	changes := []ConfigChange{}
+	for _, name := range unionKeys(before.Policies.Presets, after.Policies.Presets) {
+		b, inBefore := before.Policies.Presets[name]
+		a, inAfter := after.Policies.Presets[name]
+		if c, ok := diffList("preset", name, inBefore, inAfter, b.Require, a.Require); ok {
+			changes = append(changes, c)
+		}
+	}
+	for _, taskType := range unionKeys(before.Policies.Defaults.Task, after.Policies.Defaults.Task) {
+		if c, ok := diffValue("task_default", taskType, before.Policies.Defaults.Task, after.Policies.Defaults.Task); ok {
+			changes = append(changes, c)
+		}
+	}
+	if b, a := before.Policies.Defaults.Iteration.Validation.Require, after.Policies.Defaults.Iteration.Validation.Require; b != a {
+		changes = append(changes, ConfigChange{Section: "iteration_validation", Name: "require", Action: "changed", From: b, To: a})
+	}
+	for _, kind := range unionKeys(before.Attestations.Catalog, after.Attestations.Catalog) {
+		b, inBefore := before.Attestations.Catalog[kind]
+		a, inAfter := after.Attestations.Catalog[kind]
+		switch {
+		case !inBefore:
+			changes = append(changes, ConfigChange{Section: "attestation_kind", Name: kind, Action: "added"})
+		case !inAfter:
+			changes = append(changes, ConfigChange{Section: "attestation_kind", Name: kind, Action: "removed"})
+		case b != a:
+			changes = append(changes, ConfigChange{Section: "attestation_kind", Name: kind, Action: "changed", From: kindState(b), To: kindState(a)})
+		}
+	}
+	for _, taskType := range unionKeys(before.TaskTypes, after.TaskTypes) {
+		b, inBefore := before.TaskTypes[taskType]
+		a, inAfter := after.TaskTypes[taskType]
+		switch {
+		case !inBefore:
+			changes = append(changes, ConfigChange{Section: "task_type", Name: taskType, Action: "added"})
+		case !inAfter:
+			changes = append(changes, ConfigChange{Section: "task_type", Name: taskType, Action: "removed"})
+		case !sameYAML(b, a):
+			changes = append(changes, ConfigChange{Section: "task_type", Name: taskType, Action: "changed"})
+		}
+	}
+	changes = append(changes, diffTransitions("", before.TaskTransitions(""), after.TaskTransitions(""))...)
+	for _, taskType := range unionKeys(before.Transitions.Types, after.Transitions.Types) {
+		changes = append(changes, diffTransitions(taskType+"/", before.TaskTransitions(taskType), after.TaskTransitions(taskType))...)
+	}
+	// Everything else is compared whole, named by its YAML key.
+	handled := map[string]bool{"project": true, "attestations": true, "policies": true, "transitions": true, "task_types": true}
+	bv, av := reflect.ValueOf(*before), reflect.ValueOf(*after)
+	for i := 0; i < bv.NumField(); i++ {
+		key, _, _ := strings.Cut(bv.Type().Field(i).Tag.Get("yaml"), ",")
+		if handled[key] || sameYAML(bv.Field(i).Interface(), av.Field(i).Interface()) {
+			continue
+		}
+		changes = append(changes, ConfigChange{Section: "setting", Name: key, Action: "changed"})
+	}
+	if b, a := before.Attestations.OnDeprecated, after.Attestations.OnDeprecated; b != a {
+		changes = append(changes, ConfigChange{Section: "setting", Name: "attestations.on_deprecated", Action: "changed", From: b, To: a})
+	}
+	if !sameYAML(before.Attestations.Freshness, after.Attestations.Freshness) {
+		changes = append(changes, ConfigChange{Section: "setting", Name: "attestations.freshness", Action: "changed"})
+	}
+	return changes
+}
+
+// diffTransitions compares two transition tables status by status; prefix scopes the
+// status names of per-type tables.
+func diffTransitions(prefix string, before, after map[string][]string) []ConfigChange {
+	var changes []ConfigChange
+	for _, status := range unionKeys(before, after) {
+		b, inBefore := before[status]
+		a, inAfter := after[status]
+		if c, ok := diffList("transition", prefix+status, inBefore, inAfter, b, a); ok {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func diffList(section, name string, inBefore, inAfter bool, before, after []string) (ConfigChange, bool) {
+	c := ConfigChange{Section: section, Name: name}
+	for _, v := range after {
+		if !slices.Contains(before, v) {
+			c.Added = append(c.Added, v)
+		}
+	}
+	for _, v := range before {
+		if !slices.Contains(after, v) {
+			c.Removed = append(c.Removed, v)
+		}
+	}
+	switch {
+	case !inBefore:
+		c.Action = "added"
+	case !inAfter:
+		c.Action = "removed"
+	case len(c.Added) > 0 || len(c.Removed) > 0:
+		c.Action = "changed"
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+func diffValue(section, name string, before, after map[string]string) (ConfigChange, bool) {
+	b, inBefore := before[name]
+	a, inAfter := after[name]
+	c := ConfigChange{Section: section, Name: name, From: b, To: a}
+	switch {
+	case !inBefore:
+		c.Action = "added"
+	case !inAfter:
+		c.Action = "removed"
+	case b != a:
+		c.Action = "changed"
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+func kindState(k config.AttestationKind) string {
+	if !k.Deprecated {
+		return "active"
+	}
+	if k.ReplacedBy != "" {
+		return "deprecated, replaced by " + k.ReplacedBy
+	}
+	return "deprecated"
+}
+
+// configChangeReasons explains how the change from before to after concerns an open task.
+func configChangeReasons(before, after *config.Config, t domain.Task) []string {
+	var reasons []string
+	require := currentPolicy(t).Require
+	for _, name := range sortedKeys(before.Policies.Presets) {
+		preset := before.Policies.Presets[name]
+		if len(require) == 0 || !sameKinds(preset.Require, require) {
+			continue
+		}
+		next, ok := after.Policies.Presets[name]
+		switch {
+		case !ok:
+			reasons = append(reasons, fmt.Sprintf("requirements come from preset %s, which is removed", name))
+		case !sameKinds(next.Require, require):
+			reasons = append(reasons, fmt.Sprintf("requirements come from preset %s, which changes to [%s]; the task keeps its own", name, strings.Join(next.Require, ", ")))
+		}
+	}
+	for _, req := range require {
+		kind, _ := config.SplitRequirement(req)
+		b, inBefore := before.Attestations.Catalog[kind]
+		a, inAfter := after.Attestations.Catalog[kind]
+		switch {
+		case inBefore && !inAfter:
+			reasons = append(reasons, fmt.Sprintf("requires %s, which leaves the attestation catalog", kind))
+		case inAfter && a.Deprecated && !b.Deprecated:
+			reasons = append(reasons, fmt.Sprintf("requires %s, which becomes deprecated", kind))
+		}
+	}
+	if before.HasTaskType(t.Type) && !after.HasTaskType(t.Type) {
+		reasons = append(reasons, fmt.Sprintf("type %s is no longer declared", t.Type))
+	}
+	if len(before.TaskTransitions(t.Type)[t.Status]) > 0 && len(after.TaskTransitions(t.Type)[t.Status]) == 0 {
+		reasons = append(reasons, fmt.Sprintf("status %s has no transition left", t.Status))
+	}
+	return reasons
+}
+
+// sameYAML compares two config values as they are stored, so an empty and a missing
+// section are the same.
+func sameYAML(a, b any) bool {
+	ab, errA := yaml.Marshal(a)
+	bb, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && string(ab) == string(bb)
+}
+
+func sameKinds(a, b []string) bool {
+	return len(a) == len(b) && !slices.ContainsFunc(a, func(v string) bool { return !slices.Contains(b, v) })
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := sortedKeys(a)
+	for _, k := range sortedKeys(b) {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// ConsistencyReport lists the inconsistencies left in the database and, after a repair,
+// those that were fixed. A repair can leave new ones behind, such as countersignatures of
+// an attestation it removed; the next repair fixes them.
+type ConsistencyReport struct {
+	Issues   []domain.Inconsistency `json:"issues"`
+	Repaired []domain.Inconsistency `json:"repaired,omitempty"`
+}
+
+// CheckConsistency finds orphaned and dangling rows without changing anything.
+func (e Engine) CheckConsistency(ctx context.Context) (ConsistencyReport, error) {
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+	defer tx.Rollback()
+	issues, err := e.Repo.FindInconsistenciesTx(ctx, tx)
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+	return ConsistencyReport{Issues: issues}, nil
+}
+
+// RepairConsistency applies the repair of every inconsistency found by the named checks,
+// or by all checks when none are named, and records a consistency.repaired event for each.
+// Callers check permissions.
+func (e Engine) RepairConsistency(ctx context.Context, checks []string, actorID string) (ConsistencyReport, error) {
+	known := repo.ConsistencyCheckNames()
+	for _, c := range checks {
+		if !slices.Contains(known, c) {
+			return ConsistencyReport{}, fmt.Errorf("invalid consistency check %q: expected one of %v", c, known)
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+	defer tx.Rollback()
+	issues, err := e.Repo.FindInconsistenciesTx(ctx, tx)
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+	res := ConsistencyReport{Repaired: []domain.Inconsistency{}}
+	for _, inc := range issues {
+		if len(checks) > 0 && !slices.Contains(checks, inc.Check) {
+			continue
+		}
+		entityKind, err := e.repairInconsistencyTx(ctx, tx, inc)
+		if err != nil {
+			return ConsistencyReport{}, fmt.Errorf("repair %s %s: %w", inc.Check, inc.RowID, err)
+		}
+		if err := e.Events.Append(ctx, tx, "consistency.repaired", inc.ProjectID, entityKind, inc.RowID, actorID, events.EventPayload{
+			"check":  inc.Check,
+			"table":  inc.Table,
+			"ref":    inc.Ref,
+			"detail": inc.Detail,
+			"repair": inc.Repair,
+		}); err != nil {
+			return ConsistencyReport{}, err
+		}
+		res.Repaired = append(res.Repaired, inc)
+	}
+	if res.Issues, err = e.Repo.FindInconsistenciesTx(ctx, tx); err != nil {
+		return ConsistencyReport{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ConsistencyReport{}, err
+	}
+	return res, nil
+}
+
+// repairInconsistencyTx applies inc.Repair and returns the event entity kind of the row.
+func (e Engine) repairInconsistencyTx(ctx context.Context, tx *sql.Tx, inc domain.Inconsistency) (string, error) {
+	switch inc.Repair {
+	case "clear_parent", "clear_iteration":
+		t, err := e.Repo.GetTaskTx(ctx, tx, inc.RowID)
+		if err != nil {
+			return "", err
+		}
+		if inc.Repair == "clear_parent" {
+			t.ParentID = nil
+		} else {
+			t.IterationID = nil
+		}
+		return "task", e.Repo.UpdateTask(ctx, tx, t)
+	case "delete_dependency":
+		return "task", e.Repo.RemoveDependencies(ctx, tx, inc.RowID, []string{inc.Ref})
+	case "delete_attestation":
+		return "attestation", e.Repo.DeleteAttestationTx(ctx, tx, inc.RowID)
+	case "delete_lease":
+		switch inc.Table {
+		case "leases":
+			return "lease", e.Repo.DeleteLease(ctx, tx, inc.RowID)
+		case "review_leases":
+			return "lease", e.Repo.DeleteReviewLease(ctx, tx, inc.RowID)
+		default:
+			return "lease", e.Repo.DeleteLeaseWaitersTx(ctx, tx, inc.RowID)
+		}
+	}
+	return "", fmt.Errorf("unknown repair %s", inc.Repair)
+}
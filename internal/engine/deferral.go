@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"workline/internal/canon"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// parseDeferUntil normalizes an RFC3339 deferral to UTC; empty means not deferred.
+func parseDeferUntil(s string) (*string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid defer_until %q: use RFC3339", s)
+	}
+	out := at.UTC().Format(time.RFC3339)
+	return &out, nil
+}
+
+// DeferTask keeps an open task out of the ready queue and claim-next until the given
+// RFC3339 time, recording task.deferred; an empty until ends the deferral now and records
+// task.undeferred. WakeDeferredTasks records task.ready once the time passes.
+func (e Engine) DeferTask(ctx context.Context, taskID, until, actorID string) (domain.Task, error) {
+	deferUntil, err := parseDeferUntil(until)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	now := e.now().UTC()
+	if deferUntil != nil && *deferUntil <= now.Format(time.RFC3339) {
+		return domain.Task{}, fmt.Errorf("invalid defer_until %s: must be in the future", *deferUntil)
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	defer tx.Rollback()
+	t, err := e.Repo.GetTaskTx(ctx, tx, taskID)
+	if err != nil {
+		return t, err
+	}
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.update"); err != nil {
+		return t, err
+	}
+	switch t.Status {
+	case "done", "rejected", "canceled":
+		return t, fmt.Errorf("invalid status: task %s is %s", t.ID, t.Status)
+	}
+	previous := t.DeferUntil
+	if deferUntil == nil && previous == nil {
+		return t, nil
+	}
+	t.DeferUntil = deferUntil
+	t.UpdatedAt = now.Format(time.RFC3339)
+	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+		return t, err
+	}
+	t.ContentHash = canon.TaskHash(t)
+	evtType, payload := "task.deferred", events.EventPayload{"defer_until": deferUntil, "previous": previous}
+	if deferUntil == nil {
+		evtType, payload = "task.undeferred", events.EventPayload{"deferred_until": *previous}
+	}
+	if err := e.Events.Append(ctx, tx, evtType, t.ProjectID, "task", t.ID, actorID, payload); err != nil {
+		return t, err
+	}
+	if err := tx.Commit(); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// WakeDeferredTasks ends the deferrals that have passed and records task.ready for each
+// task that is now claimable, planned with every dependency done. Tasks still blocked get
+// task.unblocked when their last dependency completes.
+func (e Engine) WakeDeferredTasks(ctx context.Context) ([]repo.ElapsedDeferral, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	elapsed, err := e.Repo.ElapsedDeferralsTx(ctx, tx, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range elapsed {
+		if err := e.Repo.ClearTaskDeferralTx(ctx, tx, d.TaskID); err != nil {
+			return nil, err
+		}
+		if !d.Ready {
+			continue
+		}
+		if err := e.Events.Append(ctx, tx, "task.ready", d.ProjectID, "task", d.TaskID, systemActorID, events.EventPayload{
+			"deferred_until": d.DeferUntil,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return elapsed, nil
+}
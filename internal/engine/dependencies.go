@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// DependencyEdge says that To depends on From: From must be done before To.
+type DependencyEdge struct {
+	From string `json:"from" minLength:"1" doc:"Task that must be done first" example:"task-1"`
+	To   string `json:"to" minLength:"1" doc:"Task that depends on from" example:"task-2"`
+}
+
+// DependencyImport reports the edges ImportDependencies added and those already present.
+type DependencyImport struct {
+	Added    []DependencyEdge `json:"added"`
+	Existing []DependencyEdge `json:"existing"`
+}
+
+// ImportDependencies adds a batch of dependency edges between tasks of a project, as
+// generated by an external planner. The batch is checked as a whole: every task must exist
+// in the project and the project's dependencies, with the batch added, must stay acyclic.
+// Either every edge is applied or none is. Each task gaining dependencies records a
+// task.dependencies.added event.
+func (e Engine) ImportDependencies(ctx context.Context, projectID string, edges []DependencyEdge, actorID string) (DependencyImport, error) {
+	res := DependencyImport{Added: []DependencyEdge{}, Existing: []DependencyEdge{}}
+	if len(edges) == 0 {
+		return res, fmt.Errorf("invalid dependencies: at least one edge is required")
+	}
+	for i := range edges {
+		edges[i].From = strings.TrimSpace(edges[i].From)
+		edges[i].To = strings.TrimSpace(edges[i].To)
+		if edges[i].From == "" || edges[i].To == "" {
+			return res, fmt.Errorf("invalid dependency %d: from and to are required", i)
+		}
+		if edges[i].From == edges[i].To {
+			return res, fmt.Errorf("invalid dependency %d: task %s cannot depend on itself", i, edges[i].From)
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "task.update"); err != nil {
+		return res, err
+	}
+	checked := map[string]bool{}
+	for _, edge := range edges {
+		for _, id := range []string{edge.From, edge.To} {
+			if checked[id] {
+				continue
+			}
+			t, err := e.Repo.GetTaskTx(ctx, tx, id)
+			if err != nil {
+				return res, fmt.Errorf("task %s: %w", id, err)
+			}
+			if t.ProjectID != projectID {
+				return res, fmt.Errorf("task %s: %w", id, repo.ErrNotFound)
+			}
+			checked[id] = true
+		}
+	}
+	graph, err := e.Repo.ListProjectDependenciesTx(ctx, tx, projectID)
+	if err != nil {
+		return res, err
+	}
+	added := map[string][]string{}
+	for _, edge := range edges {
+		if slices.Contains(graph[edge.To], edge.From) {
+			if !slices.Contains(added[edge.To], edge.From) {
+				res.Existing = append(res.Existing, edge)
+			}
+			continue
+		}
+		graph[edge.To] = append(graph[edge.To], edge.From)
+		added[edge.To] = append(added[edge.To], edge.From)
+		res.Added = append(res.Added, edge)
+	}
+	if cycle := dependencyCycle(graph); cycle != nil {
+		return res, fmt.Errorf("invalid dependencies: cycle %s", strings.Join(cycle, " -> "))
+	}
+	tasks := make([]string, 0, len(added))
+	for id := range added {
+		tasks = append(tasks, id)
+	}
+	slices.Sort(tasks)
+	for _, id := range tasks {
+		if err := e.Repo.AddDependencies(ctx, tx, id, added[id]); err != nil {
+			return res, err
+		}
+		if err := e.Events.Append(ctx, tx, "task.dependencies.added", projectID, "task", id, actorID, events.EventPayload{
+			"depends_on": added[id],
+			"imported":   true,
+		}); err != nil {
+			return res, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return DependencyImport{Added: []DependencyEdge{}, Existing: []DependencyEdge{}}, err
+	}
+	return res, nil
+}
+
+// dependencyCycle returns the tasks of a dependency cycle in graph, which maps a task to
+// the tasks it depends on, starting and ending with the same task; nil when there is none.
+func dependencyCycle(graph map[string][]string) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case visiting:
+			start := slices.Index(path, id)
+			return append(slices.Clone(path[start:]), id)
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range graph[id] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+	ids := make([]string, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if cycle := visit(id); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
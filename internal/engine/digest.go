@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// Digest summarizes one UTC day of a project: the tasks completed and decisions recorded
+// that day, the task completions and iteration validations refused that day, and the
+// leases stuck when the digest was generated.
+type Digest struct {
+	ProjectID          string              `json:"project_id"`
+	Day                string              `json:"day" format:"date"`
+	GeneratedAt        string              `json:"generated_at" format:"date-time"`
+	CompletedTasks     []DigestTask        `json:"completed_tasks"`
+	Decisions          []DigestDecision    `json:"decisions"`
+	ValidationFailures []ValidationFailure `json:"validation_failures"`
+	StuckLeases        []StuckLease        `json:"stuck_leases"`
+}
+
+type DigestTask struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Type        string `json:"type"`
+	AssigneeID  string `json:"assignee_id,omitempty"`
+	CompletedAt string `json:"completed_at" format:"date-time"`
+}
+
+type DigestDecision struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Status    string `json:"status"`
+	DeciderID string `json:"decider_id"`
+	CreatedAt string `json:"created_at" format:"date-time"`
+}
+
+// ValidationFailure is a task completion or iteration validation refused because its
+// requirements were not met.
+type ValidationFailure struct {
+	EntityKind string   `json:"entity_kind" enum:"task,iteration"`
+	EntityID   string   `json:"entity_id"`
+	ActorID    string   `json:"actor_id"`
+	TS         string   `json:"ts" format:"date-time"`
+	Missing    []string `json:"missing,omitempty" doc:"Task requirements neither attested nor waived"`
+	Reason     string   `json:"reason,omitempty" doc:"Why the iteration validation was refused"`
+}
+
+// StuckLease is a lease on an unfinished task that expired without being released or has
+// been held longer than digest.stuck_lease_after.
+type StuckLease struct {
+	TaskID     string `json:"task_id"`
+	Title      string `json:"title"`
+	OwnerID    string `json:"owner_id"`
+	AcquiredAt string `json:"acquired_at" format:"date-time"`
+	ExpiresAt  string `json:"expires_at" format:"date-time"`
+	Reason     string `json:"reason" enum:"expired,held_too_long"`
+}
+
+// DigestDay parses a digest day (YYYY-MM-DD); empty means yesterday (UTC), the last
+// complete day.
+func DigestDay(day string, now time.Time) (time.Time, error) {
+	if day == "" {
+		return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1), nil
+	}
+	d, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day %q: use YYYY-MM-DD", day)
+	}
+	return d, nil
+}
+
+// GenerateDigest builds and stores the digest of a project for day, replacing one generated
+// earlier, and records a digest.generated event that notification channels can subscribe
+// to. Stuck leases reflect the time of generation.
+func (e Engine) GenerateDigest(ctx context.Context, projectID string, day time.Time, actorID string) (Digest, error) {
+	now := e.now().UTC()
+	start := day.UTC().Truncate(24 * time.Hour)
+	if start.After(now) {
+		return Digest{}, fmt.Errorf("invalid day %s: it has not started", start.Format(time.DateOnly))
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return Digest{}, err
+	}
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) && e.Config != nil {
+		cfg = e.Config
+	} else if err != nil {
+		return Digest{}, err
+	}
+	from, to := start.Format(time.RFC3339), start.AddDate(0, 0, 1).Format(time.RFC3339)
+	d := Digest{
+		ProjectID:          projectID,
+		Day:                start.Format(time.DateOnly),
+		GeneratedAt:        now.Format(time.RFC3339),
+		CompletedTasks:     []DigestTask{},
+		Decisions:          []DigestDecision{},
+		ValidationFailures: []ValidationFailure{},
+		StuckLeases:        []StuckLease{},
+	}
+
+	tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, CompletedFrom: from, CompletedTo: to})
+	if err != nil {
+		return d, err
+	}
+	for _, t := range tasks {
+		dt := DigestTask{ID: t.ID, Title: t.Title, Type: t.Type, CompletedAt: *t.CompletedAt}
+		if t.AssigneeID != nil {
+			dt.AssigneeID = *t.AssigneeID
+		}
+		d.CompletedTasks = append(d.CompletedTasks, dt)
+	}
+	sort.Slice(d.CompletedTasks, func(i, j int) bool {
+		a, b := d.CompletedTasks[i], d.CompletedTasks[j]
+		return a.CompletedAt < b.CompletedAt || (a.CompletedAt == b.CompletedAt && a.ID < b.ID)
+	})
+
+	decisions, err := e.Repo.ListDecisions(ctx, repo.DecisionFilters{ProjectID: projectID, CreatedFrom: from, CreatedTo: to})
+	if err != nil {
+		return d, err
+	}
+	// Oldest first, like the rest of the digest.
+	for i := len(decisions) - 1; i >= 0; i-- {
+		dec := decisions[i]
+		d.Decisions = append(d.Decisions, DigestDecision{ID: dec.ID, Title: dec.Title, Status: dec.Status, DeciderID: dec.DeciderID, CreatedAt: dec.CreatedAt})
+	}
+
+	evts, err := e.Repo.EventsBetween(ctx, projectID, []string{"task.validation.failed", "iteration.validation.checked"}, from, to)
+	if err != nil {
+		return d, err
+	}
+	for _, evt := range evts {
+		var payload struct {
+			Missing []string `json:"missing"`
+			Result  *bool    `json:"result"`
+			Reason  string   `json:"reason"`
+		}
+		_ = json.Unmarshal([]byte(evt.Payload), &payload)
+		if evt.Type == "iteration.validation.checked" && (payload.Result == nil || *payload.Result) {
+			continue
+		}
+		d.ValidationFailures = append(d.ValidationFailures, ValidationFailure{
+			EntityKind: evt.EntityKind,
+			EntityID:   evt.EntityID,
+			ActorID:    evt.ActorID,
+			TS:         evt.TS,
+			Missing:    payload.Missing,
+			Reason:     payload.Reason,
+		})
+	}
+
+	nowStr := now.Format(time.RFC3339)
+	leases, err := e.Repo.StuckLeases(ctx, projectID, nowStr, now.Add(-cfg.Digest.StuckAfter()).Format(time.RFC3339))
+	if err != nil {
+		return d, err
+	}
+	for _, l := range leases {
+		sl := StuckLease{TaskID: l.TaskID, OwnerID: l.OwnerID, AcquiredAt: l.AcquiredAt, ExpiresAt: l.ExpiresAt, Reason: "held_too_long"}
+		if l.ExpiresAt < nowStr {
+			sl.Reason = "expired"
+		}
+		if t, err := e.Repo.GetTask(ctx, l.TaskID); err == nil {
+			sl.Title = t.Title
+		}
+		d.StuckLeases = append(d.StuckLeases, sl)
+	}
+
+	body, err := json.Marshal(d)
+	if err != nil {
+		return d, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback()
+	if err := e.Repo.UpsertDigestTx(ctx, tx, projectID, d.Day, d.GeneratedAt, string(body)); err != nil {
+		return d, err
+	}
+	if err := e.Events.Append(ctx, tx, "digest.generated", projectID, "project", projectID, actorID, events.EventPayload{
+		"day":                 d.Day,
+		"completed_tasks":     len(d.CompletedTasks),
+		"decisions":           len(d.Decisions),
+		"validation_failures": len(d.ValidationFailures),
+		"stuck_leases":        len(d.StuckLeases),
+	}); err != nil {
+		return d, err
+	}
+	if err := tx.Commit(); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// GenerateDueDigests generates yesterday's digest for every project that does not have one
+// yet, so a digest is produced once per project and day however often it runs.
+func (e Engine) GenerateDueDigests(ctx context.Context) ([]Digest, error) {
+	day, _ := DigestDay("", e.now())
+	projects, err := e.Repo.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Digest
+	var errs []error
+	for _, p := range projects {
+		if _, err := e.Repo.GetDigest(ctx, p.ID, day.Format(time.DateOnly)); err == nil {
+			continue
+		} else if !errors.Is(err, repo.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+			continue
+		}
+		d, err := e.GenerateDigest(ctx, p.ID, day, systemActorID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+			continue
+		}
+		res = append(res, d)
+	}
+	return res, errors.Join(errs...)
+}
+
+// GetDigest returns the stored digest of a project for day (YYYY-MM-DD).
+func (e Engine) GetDigest(ctx context.Context, projectID, day string) (Digest, error) {
+	body, err := e.Repo.GetDigest(ctx, projectID, day)
+	if err != nil {
+		return Digest{}, err
+	}
+	var d Digest
+	err = json.Unmarshal([]byte(body), &d)
+	return d, err
+}
+
+// ListDigests returns the stored digests of a project with from <= day <= to, newest first.
+func (e Engine) ListDigests(ctx context.Context, projectID, from, to string) ([]Digest, error) {
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	bodies, err := e.Repo.ListDigests(ctx, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Digest, 0, len(bodies))
+	for _, body := range bodies {
+		var d Digest
+		if err := json.Unmarshal([]byte(body), &d); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
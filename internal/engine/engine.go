@@ -1,19 +1,27 @@
 package engine
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"workline/internal/blob"
+	"workline/internal/canon"
 	"workline/internal/config"
 	"workline/internal/domain"
 	"workline/internal/engine/auth"
 	"workline/internal/events"
+	"workline/internal/evidence"
+	"workline/internal/expr"
 	"workline/internal/repo"
 )
 
@@ -24,10 +32,19 @@ type Engine struct {
 	Config *config.Config
 	Now    func() time.Time
 	Auth   auth.Service
+	// Blobs receives attestation payloads above config.payloads.inline_max_bytes; nil keeps them inline.
+	Blobs blob.Store
+	// Evidence signs exported task evidence bundles; nil disables the export.
+	Evidence *evidence.Signer
+
+	usage *usageBuffer
 }
 
 const defaultOrgID = "default-org"
 
+// systemActorID attributes events the engine records on its own, such as grant expiry.
+const systemActorID = "system"
+
 func New(db *sql.DB, cfg *config.Config) Engine {
 	return Engine{
 		DB:     db,
@@ -39,6 +56,29 @@ func New(db *sql.DB, cfg *config.Config) Engine {
 	}
 }
 
+// WithCache returns a copy of e whose project config and RBAC lookups are served from
+// an in-memory cache, reloaded after ttl and invalidated by writes made through e.
+func (e Engine) WithCache(ttl time.Duration) Engine {
+	c := repo.NewCache(ttl)
+	e.Repo.Cache = c
+	e.Auth.Cache = c
+	return e
+}
+
+// WithReplicas returns a copy of e whose repository reads for requests marked with
+// repo.PreferReplica are served by the read-only replicas.
+func (e Engine) WithReplicas(replicas ...*sql.DB) Engine {
+	e.Repo.Replicas = repo.NewReplicas(replicas...)
+	return e
+}
+
+// WithEnvironment returns a copy of e that applies the env profile of the engine config and
+// of the project configs that declare one.
+func (e Engine) WithEnvironment(env string) Engine {
+	e.Repo.Environment = env
+	return e
+}
+
 func (e Engine) now() time.Time {
 	if e.Now != nil {
 		return e.Now()
@@ -46,15 +86,86 @@ func (e Engine) now() time.Time {
 	return time.Now()
 }
 
-// InitProject initializes a new project with migrations already run.
+// InitProject initializes a new project of the default org with migrations already run.
 func (e Engine) InitProject(ctx context.Context, projectID, description, actorID string) (domain.Project, error) {
+	return e.InitProjectWithOptions(ctx, ProjectInitOptions{ProjectID: projectID, Description: description, ActorID: actorID})
+}
+
+// ProjectInitOptions creates a project; see InitProjectWithOptions.
+type ProjectInitOptions struct {
+	ProjectID   string
+	Description string
+	ActorID     string
+	// OrgID is the org holding the project; empty is the default org.
+	OrgID string
+	// ParentProjectID places the project under a program, on which the actor needs
+	// project.update.
+	ParentProjectID string
+	// Config seeds the project config; nil copies the engine's.
+	Config *config.Config
+}
+
+// InitProjectWithOptions initializes a new project with migrations already run. Creating a
+// project in the default org makes the actor one of its owners; any other org must exist
+// and the actor must own it.
+func (e Engine) InitProjectWithOptions(ctx context.Context, opts ProjectInitOptions) (domain.Project, error) {
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return domain.Project{}, err
 	}
 	defer tx.Rollback()
+	p, err := e.initProjectTx(ctx, tx, opts)
+	if err != nil {
+		return domain.Project{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Project{}, err
+	}
+	return p, nil
+}
+
+// EnsureProject initializes the project like InitProjectWithOptions, or returns it unchanged
+// when it already exists in the same org and program, so bootstrap can be repeated. The
+// actor then needs project.read on it. The bool reports whether the project was created.
+func (e Engine) EnsureProject(ctx context.Context, opts ProjectInitOptions) (domain.Project, bool, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Project{}, false, err
+	}
+	defer tx.Rollback()
+	existing, err := e.Repo.GetProjectTx(ctx, tx, opts.ProjectID)
+	if err == nil {
+		orgID := cmp.Or(opts.OrgID, defaultOrgID)
+		if existing.OrgID != orgID {
+			return domain.Project{}, false, fmt.Errorf("invalid project id: project %s already exists in another org", opts.ProjectID)
+		}
+		if existing.ParentProjectID != opts.ParentProjectID {
+			return domain.Project{}, false, fmt.Errorf("invalid parent: project %s already exists under %q", opts.ProjectID, existing.ParentProjectID)
+		}
+		if err := e.requirePermission(ctx, tx, opts.ProjectID, opts.ActorID, "project.read"); err != nil {
+			return domain.Project{}, false, err
+		}
+		return existing, false, nil
+	}
+	if !errors.Is(err, repo.ErrNotFound) {
+		return domain.Project{}, false, err
+	}
+	p, err := e.initProjectTx(ctx, tx, opts)
+	if err != nil {
+		return domain.Project{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Project{}, false, err
+	}
+	return p, true, nil
+}
 
-	orgID := defaultOrgID
+func (e Engine) initProjectTx(ctx context.Context, tx *sql.Tx, opts ProjectInitOptions) (domain.Project, error) {
+	projectID, description, actorID := opts.ProjectID, opts.Description, opts.ActorID
+	orgID := opts.OrgID
+	if orgID == "" {
+		orgID = defaultOrgID
+	}
 	p := domain.Project{
 		ID:          projectID,
 		OrgID:       orgID,
@@ -63,16 +174,39 @@ func (e Engine) InitProject(ctx context.Context, projectID, description, actorID
 		Description: description,
 		CreatedAt:   e.now().UTC().Format(time.RFC3339),
 	}
-	if err := e.Repo.EnsureOrg(ctx, tx, orgID, "Default Org", p.CreatedAt); err != nil {
-		return domain.Project{}, fmt.Errorf("insert org: %w", err)
+	if orgID == defaultOrgID {
+		if err := e.Repo.EnsureOrg(ctx, tx, orgID, "Default Org", p.CreatedAt); err != nil {
+			return domain.Project{}, fmt.Errorf("insert org: %w", err)
+		}
+	} else {
+		if err := e.requireOrgPermission(ctx, tx, orgID, actorID, "org.project.create"); err != nil {
+			return domain.Project{}, err
+		}
+		if _, err := e.Repo.GetProjectTx(ctx, tx, projectID); err == nil {
+			return domain.Project{}, fmt.Errorf("invalid project id: project %s already exists", projectID)
+		}
+	}
+	if opts.ParentProjectID != "" {
+		parent, err := e.Repo.GetProjectTx(ctx, tx, opts.ParentProjectID)
+		if err != nil {
+			return domain.Project{}, err
+		}
+		if parent.OrgID != orgID {
+			return domain.Project{}, fmt.Errorf("invalid parent: project %s belongs to another org", parent.ID)
+		}
+		if err := e.requirePermission(ctx, tx, parent.ID, actorID, "project.update"); err != nil {
+			return domain.Project{}, err
+		}
 	}
 	if _, err := tx.ExecContext(ctx, `INSERT INTO projects(id,org_id,kind,status,description,created_at) VALUES (?,?,?,?,?,?)`,
 		p.ID, p.OrgID, p.Kind, p.Status, nullable(p.Description), p.CreatedAt); err != nil {
 		return domain.Project{}, fmt.Errorf("insert project: %w", err)
 	}
-	seedCfg := e.Config
-	if seedCfg == nil {
-		seedCfg = config.Default(p.ID)
+	seedCfg := config.Default(p.ID)
+	if src := cmp.Or(opts.Config, e.Config); src != nil {
+		// Seed from a copy so the source config stays as it was.
+		copied := *src
+		seedCfg = &copied
 	}
 	seedCfg.Project.ID = p.ID
 	if err := e.Repo.UpsertProjectConfigTx(ctx, tx, p.ID, seedCfg); err != nil {
@@ -84,13 +218,125 @@ func (e Engine) InitProject(ctx context.Context, projectID, description, actorID
 	if err := e.Repo.AssignOrgRole(ctx, tx, orgID, actorID, "owner"); err != nil {
 		return domain.Project{}, fmt.Errorf("assign org role: %w", err)
 	}
-	if err := e.Events.Append(ctx, tx, "project.init", p.ID, "project", p.ID, actorID, events.EventPayload{"status": p.Status}); err != nil {
+	payload := events.EventPayload{"status": p.Status}
+	if orgID != defaultOrgID {
+		payload["org_id"] = orgID
+	}
+	if err := e.Events.Append(ctx, tx, "project.init", p.ID, "project", p.ID, actorID, payload); err != nil {
+		return domain.Project{}, err
+	}
+	if opts.ParentProjectID != "" {
+		if err := e.Repo.SetProjectParentTx(ctx, tx, p.ID, opts.ParentProjectID); err != nil {
+			return domain.Project{}, err
+		}
+		if err := e.Events.Append(ctx, tx, "project.parent_set", p.ID, "project", p.ID, actorID, events.EventPayload{
+			"parent_project_id": opts.ParentProjectID,
+			"previous":          "",
+		}); err != nil {
+			return domain.Project{}, err
+		}
+		p.ParentProjectID = opts.ParentProjectID
+	}
+	return p, nil
+}
+
+// ProjectStatuses lists the statuses a project may take.
+var ProjectStatuses = []string{"active", "paused", "archived"}
+
+// ProjectUpdateOptions changes the descriptive fields of a project; see repo.ProjectUpdate.
+type ProjectUpdateOptions struct {
+	ProjectID string
+	ActorID   string
+	repo.ProjectUpdate
+}
+
+// UpdateProject applies opts and records a project.updated event listing the changed
+// fields and their previous values. Updates that change nothing record no event.
+func (e Engine) UpdateProject(ctx context.Context, opts ProjectUpdateOptions) (domain.Project, error) {
+	u, err := e.normalizeProjectUpdate(opts.ProjectUpdate)
+	if err != nil {
+		return domain.Project{}, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Project{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, opts.ProjectID, opts.ActorID, "project.update"); err != nil {
+		return domain.Project{}, err
+	}
+	before, err := e.Repo.GetProjectTx(ctx, tx, opts.ProjectID)
+	if err != nil {
+		return domain.Project{}, err
+	}
+	if err := e.Repo.UpdateProjectTx(ctx, tx, opts.ProjectID, u); err != nil {
+		return domain.Project{}, err
+	}
+	after, err := e.Repo.GetProjectTx(ctx, tx, opts.ProjectID)
+	if err != nil {
+		return domain.Project{}, err
+	}
+	payload, previous := events.EventPayload{}, map[string]any{}
+	changed := func(field string, from, to any) {
+		a, _ := json.Marshal(from)
+		b, _ := json.Marshal(to)
+		if string(a) != string(b) {
+			payload[field] = to
+			previous[field] = from
+		}
+	}
+	changed("status", before.Status, after.Status)
+	changed("description", before.Description, after.Description)
+	changed("display_name", before.DisplayName, after.DisplayName)
+	changed("tags", before.Tags, after.Tags)
+	changed("metadata", before.Metadata, after.Metadata)
+	if len(previous) == 0 {
+		return after, nil
+	}
+	payload["previous"] = previous
+	if err := e.Events.Append(ctx, tx, "project.updated", opts.ProjectID, "project", opts.ProjectID, opts.ActorID, payload); err != nil {
 		return domain.Project{}, err
 	}
 	if err := tx.Commit(); err != nil {
 		return domain.Project{}, err
 	}
-	return p, nil
+	return after, nil
+}
+
+func (e Engine) normalizeProjectUpdate(u repo.ProjectUpdate) (repo.ProjectUpdate, error) {
+	if u.Status != "" && !slices.Contains(ProjectStatuses, u.Status) {
+		return u, fmt.Errorf("invalid status %q: expected one of %s", u.Status, strings.Join(ProjectStatuses, ", "))
+	}
+	if u.DisplayName != nil {
+		name := strings.TrimSpace(*u.DisplayName)
+		if len(name) > 200 {
+			return u, errors.New("invalid display_name: longer than 200 characters")
+		}
+		u.DisplayName = &name
+	}
+	if u.Tags != nil {
+		tags := []string{}
+		for _, tag := range *u.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || len(tag) > 64 || strings.ContainsAny(tag, ", \t\n") {
+				return u, fmt.Errorf("invalid tag %q: tags are 1-64 characters without spaces or commas", tag)
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		u.Tags = &tags
+	}
+	if u.Metadata != nil && e.Config != nil {
+		data, err := json.Marshal(*u.Metadata)
+		if err != nil {
+			return u, fmt.Errorf("invalid metadata: %w", err)
+		}
+		if err := checkPayloadSize(e.Config.Payloads, "metadata", string(data)); err != nil {
+			return u, err
+		}
+	}
+	return u, nil
 }
 
 // TaskCreateOptions are parameters for creating a task.
@@ -107,8 +353,20 @@ type TaskCreateOptions struct {
 	WorkOutcomesJSON *string
 	PolicyPreset     string
 	RequiredKinds    []string
-	ActorID          string
-	PolicyOverride   bool
+	// RequiredCapabilities restrict the ready queue to actors offering all of them.
+	RequiredCapabilities []string
+	// Labels feed the risk score policies.risk computes.
+	Labels []string
+	// CustomFields must match the fields schema declared for Type under task_types.
+	CustomFields map[string]any
+	// DeferUntil (RFC3339) keeps the task out of the ready queue until it passes.
+	DeferUntil string
+	// DueAt (RFC3339) is the deadline the deadline scheduler orders the ready queue by.
+	DueAt          string
+	ActorID        string
+	PolicyOverride bool
+	// FreezeOverride is the reason for adding the task to a frozen iteration.
+	FreezeOverride string
 }
 
 func (e Engine) CreateTask(ctx context.Context, opts TaskCreateOptions) (domain.Task, error) {
@@ -154,18 +412,65 @@ func (e Engine) CreateTask(ctx context.Context, opts TaskCreateOptions) (domain.
 			return domain.Task{}, err
 		}
 	}
-	id := opts.ID
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, opts.ProjectID, opts.ActorID, "task.create"); err != nil {
+		return domain.Task{}, err
+	}
+	t, err := e.insertTaskTx(ctx, tx, cfg, opts)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := e.checkFreezeTx(ctx, tx, t.IterationID, t.ID, FreezeAddTask, opts.FreezeOverride, opts.ActorID); err != nil {
+		return domain.Task{}, err
+	}
+	if err := e.recordMentions(ctx, tx, t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{"source": "description"}, t.Title, t.Description); err != nil {
+		return domain.Task{}, err
+	}
+	if err := e.rollupTx(ctx, tx, t.ParentID, opts.ActorID); err != nil {
+		return domain.Task{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Task{}, err
+	}
+	return t, nil
+}
+
+// insertTaskTx resolves the task policy, assigns ID and rank, inserts the task with its
+// dependencies and records the creation events. Callers check permissions.
+func (e Engine) insertTaskTx(ctx context.Context, tx *sql.Tx, cfg *config.Config, opts TaskCreateOptions) (domain.Task, error) {
 	now := e.now().UTC().Format(time.RFC3339)
-	if id == "" {
-		id = uuid.NewSHA1(uuid.NameSpaceOID, []byte(opts.ProjectID+"|"+opts.Title+"|"+now)).String()
+	if err := checkTaskType(cfg, opts.Type); err != nil {
+		return domain.Task{}, err
+	}
+	customFields, err := customFieldsJSON(cfg, opts.Type, opts.CustomFields)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	labels, err := normalizeLabels(opts.Labels)
+	if err != nil {
+		return domain.Task{}, err
 	}
 	var reqJSON *string
 	presetName := opts.PolicyPreset
 	manualPolicy := opts.PolicyOverride
-	if !manualPolicy {
-		if presetName == "" {
-			presetName = cfg.Policies.Defaults.Task[opts.Type]
+	source := RequirementPreset
+	if !manualPolicy && presetName == "" {
+		presetName, source = cfg.Policies.Defaults.Task[opts.Type], RequirementDefault
+		// The risk of the task under its default requirements picks the preset when its
+		// level routes to one.
+		var kinds []string
+		if preset, ok := cfg.Policies.Presets[presetName]; ok {
+			kinds = requirementKinds(preset.Require)
+		}
+		if risk := assessRisk(cfg.Policies.Risk, opts.Type, labels, kinds); risk.Preset != "" {
+			presetName, source = risk.Preset, RequirementRisk
 		}
+	}
+	if !manualPolicy {
 		if presetName != "" {
 			preset, ok := cfg.Policies.Presets[presetName]
 			if !ok {
@@ -179,18 +484,35 @@ func (e Engine) CreateTask(ctx context.Context, opts TaskCreateOptions) (domain.
 		}
 	}
 	if manualPolicy || presetName == "" {
+		if err := checkRequirements(opts.RequiredKinds); err != nil {
+			return domain.Task{}, err
+		}
 		reqJSON, err = marshalStringSlice(opts.RequiredKinds)
 		if err != nil {
 			return domain.Task{}, err
 		}
 	}
+	caps, err := normalizeCapabilities(opts.RequiredCapabilities)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	deferUntil, err := parseDeferUntil(opts.DeferUntil)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	dueAt, err := parseDueAt(opts.DueAt)
+	if err != nil {
+		return domain.Task{}, err
+	}
 	if opts.WorkOutcomesJSON != nil {
 		if err := validateJSON(*opts.WorkOutcomesJSON); err != nil {
 			return domain.Task{}, fmt.Errorf("work-outcomes-json: %w", err)
 		}
+		if err := checkPayloadSize(cfg.Payloads, "work_outcomes", *opts.WorkOutcomesJSON); err != nil {
+			return domain.Task{}, err
+		}
 	}
 	t := domain.Task{
-		ID:                       id,
 		ProjectID:                opts.ProjectID,
 		IterationID:              optionalString(opts.IterationID),
 		ParentID:                 optionalString(opts.ParentID),
@@ -201,21 +523,36 @@ func (e Engine) CreateTask(ctx context.Context, opts TaskCreateOptions) (domain.
 		AssigneeID:               optionalString(opts.AssigneeID),
 		WorkOutcomesJSON:         opts.WorkOutcomesJSON,
 		RequiredAttestationsJSON: reqJSON,
+		RequiredCapabilities:     caps,
+		CustomFieldsJSON:         customFields,
+		DeferUntil:               deferUntil,
+		DueAt:                    dueAt,
+		Labels:                   labels,
 		CreatedAt:                now,
 		UpdatedAt:                now,
 	}
-	tx, err := e.DB.BeginTx(ctx, nil)
+	applyRisk(cfg, &t, assessRisk(cfg.Policies.Risk, t.Type, t.Labels, requirementKinds(currentPolicy(t).Require)))
+	t.ID, err = e.assignID(ctx, tx, cfg.IDs.Tasks, opts.ProjectID, "tasks", opts.ID, func() string {
+		return uuid.NewSHA1(uuid.NameSpaceOID, []byte(opts.ProjectID+"|"+opts.Title+"|"+now)).String()
+	})
 	if err != nil {
 		return domain.Task{}, err
 	}
-	defer tx.Rollback()
-	if err := e.requirePermission(ctx, tx, opts.ProjectID, opts.ActorID, "task.create"); err != nil {
+	rank, err := e.Repo.NextTaskRankTx(ctx, tx, t.ProjectID, t.ParentID, t.IterationID)
+	if err != nil {
 		return domain.Task{}, err
 	}
+	t.Rank = rank
+	if t.WorkOutcomesJSON != nil {
+		if err := e.checkArtifactCitations(ctx, tx, t.ProjectID, *t.WorkOutcomesJSON); err != nil {
+			return domain.Task{}, err
+		}
+	}
 
 	if err := e.Repo.InsertTask(ctx, tx, t); err != nil {
 		return domain.Task{}, err
 	}
+	t.ContentHash = canon.TaskHash(t)
 	if len(opts.DependsOn) > 0 {
 		if err := e.Repo.AddDependencies(ctx, tx, t.ID, opts.DependsOn); err != nil {
 			return domain.Task{}, err
@@ -228,19 +565,33 @@ func (e Engine) CreateTask(ctx context.Context, opts TaskCreateOptions) (domain.
 			return domain.Task{}, err
 		}
 	} else if presetName != "" {
-		if err := e.Events.Append(ctx, tx, "task.policy.applied", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
+		applied := events.EventPayload{
 			"preset_name": presetName,
 			"require":     opts.RequiredKinds,
-		}); err != nil {
+			"source":      source,
+		}
+		if source == RequirementRisk {
+			applied["risk_score"], applied["risk_level"] = t.RiskScore, t.RiskLevel
+		}
+		if err := e.Events.Append(ctx, tx, "task.policy.applied", t.ProjectID, "task", t.ID, opts.ActorID, applied); err != nil {
 			return domain.Task{}, err
 		}
 	}
-	if err := e.Events.Append(ctx, tx, "task.created", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{"title": t.Title, "status": t.Status}); err != nil {
-		return domain.Task{}, err
+	created := events.EventPayload{"title": t.Title, "status": t.Status}
+	if t.DeferUntil != nil {
+		created["defer_until"] = *t.DeferUntil
 	}
-	if err := tx.Commit(); err != nil {
+	if t.DueAt != nil {
+		created["due_at"] = *t.DueAt
+	}
+	if err := e.Events.Append(ctx, tx, "task.created", t.ProjectID, "task", t.ID, opts.ActorID, created); err != nil {
 		return domain.Task{}, err
 	}
+	if t.AssigneeID != nil {
+		if err := e.recordHandoff(ctx, tx, t, nil, t.AssigneeID, opts.ActorID, ""); err != nil {
+			return domain.Task{}, err
+		}
+	}
 	t.DependsOn = opts.DependsOn
 	return t, nil
 }
@@ -258,10 +609,27 @@ func marshalStringSlice(in []string) (*string, error) {
 }
 
 func (e Engine) ensureNoCycle(ctx context.Context, parentID, childID string) error {
+	return ensureNoParentCycle(parentID, childID, func(id string) (domain.Task, error) {
+		return e.Repo.GetTask(ctx, id)
+	})
+}
+
+// ensureNoCycleTx is ensureNoCycle within tx: the database has a single connection, so
+// reads outside a transaction that holds it would wait forever.
+func (e Engine) ensureNoCycleTx(ctx context.Context, tx *sql.Tx, parentID, childID string) error {
+	return ensureNoParentCycle(parentID, childID, func(id string) (domain.Task, error) {
+		return e.Repo.GetTaskTx(ctx, tx, id)
+	})
+}
+
+func ensureNoParentCycle(parentID, childID string, get func(string) (domain.Task, error)) error {
+	if parentID == childID {
+		return errors.New("task hierarchy cycle detected")
+	}
 	// climb up parent chain to ensure no cycle
 	cur := parentID
 	for cur != "" {
-		t, err := e.Repo.GetTask(ctx, cur)
+		t, err := get(cur)
 		if err != nil {
 			return err
 		}
@@ -284,6 +652,9 @@ func (e Engine) requirePermission(ctx context.Context, tx *sql.Tx, projectID, ac
 	if err := e.ensureActor(ctx, tx, actorID); err != nil {
 		return err
 	}
+	if outOfScope(ctx, projectID) {
+		return auth.ForbiddenError{Permission: perm}
+	}
 	ok, err := e.Auth.ActorHasPermission(ctx, tx, projectID, actorID, perm)
 	if err != nil {
 		return err
@@ -295,10 +666,30 @@ func (e Engine) requirePermission(ctx context.Context, tx *sql.Tx, projectID, ac
 	return nil
 }
 
+// precheckPermissions checks perms in a throwaway transaction, for callers that write blobs
+// before their main transaction: a refused caller leaves nothing behind. The main
+// transaction checks them again.
+func (e Engine) precheckPermissions(ctx context.Context, projectID, actorID string, perms ...string) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, perm := range perms {
+		if err := e.requirePermission(ctx, tx, projectID, actorID, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e Engine) requireAttestationAuthority(ctx context.Context, tx *sql.Tx, projectID, actorID, kind string) error {
 	if err := e.ensureActor(ctx, tx, actorID); err != nil {
 		return err
 	}
+	if outOfScope(ctx, projectID) {
+		return auth.ForbiddenAttestationError{Kind: kind}
+	}
 	ok, err := e.Auth.ActorCanAttest(ctx, tx, projectID, actorID, kind)
 	if err != nil {
 		return err
@@ -323,6 +714,7 @@ type TaskUpdateOptions struct {
 	Status            string
 	Assign            *string
 	AssignProvided    bool
+	HandoffNote       string
 	AddDeps           []string
 	RemoveDeps        []string
 	SetParent         *string
@@ -333,9 +725,23 @@ type TaskUpdateOptions struct {
 	PolicyPreset      string
 	RequiredKinds     []string
 	RequiredKindsSet  bool
-	ActorID           string
-	Force             bool
-	PolicyOverride    bool
+	// RequiredCapabilities replaces the task's capabilities when RequiredCapabilitiesSet.
+	RequiredCapabilities    []string
+	RequiredCapabilitiesSet bool
+	// CustomFields replaces the task's custom fields when CustomFieldsSet; nil clears them.
+	CustomFields    map[string]any
+	CustomFieldsSet bool
+	// DueAt (RFC3339) replaces the task's deadline when DueAtSet; empty clears it.
+	DueAt    string
+	DueAtSet bool
+	// Labels replaces the task's labels when LabelsSet.
+	Labels         []string
+	LabelsSet      bool
+	ActorID        string
+	Force          bool
+	PolicyOverride bool
+	// FreezeOverride is the reason for dropping requirements of a task in a frozen iteration.
+	FreezeOverride string
 }
 
 func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.Task, error) {
@@ -369,12 +775,18 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 		if opts.SetParent == nil || (opts.SetParent != nil && *opts.SetParent == "") {
 			t.ParentID = nil
 		} else {
-			if err := e.ensureNoCycle(ctx, *opts.SetParent, t.ID); err != nil {
+			if err := e.ensureNoCycleTx(ctx, tx, *opts.SetParent, t.ID); err != nil {
 				return t, err
 			}
 			t.ParentID = opts.SetParent
 		}
 	}
+	if !sameOptionalString(t.ParentID, original.ParentID) {
+		// A new sibling group gets the task after its existing members.
+		if t.Rank, err = e.Repo.NextTaskRankTx(ctx, tx, t.ProjectID, t.ParentID, t.IterationID); err != nil {
+			return t, err
+		}
+	}
 
 	if opts.AssignProvided {
 		if opts.Assign == nil || (opts.Assign != nil && *opts.Assign == "") {
@@ -383,6 +795,10 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 			t.AssigneeID = opts.Assign
 		}
 	}
+	reassigned := !sameOptionalString(original.AssigneeID, t.AssigneeID)
+	if opts.HandoffNote != "" && !reassigned {
+		return t, errors.New("invalid handoff note: it must accompany an assignee change")
+	}
 	if opts.WorkOutcomesSet {
 		if opts.ClearWorkOutcomes {
 			if !opts.Force {
@@ -395,6 +811,12 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 			if err := validateJSON(*opts.SetWorkOutcomes); err != nil {
 				return t, fmt.Errorf("work outcomes JSON: %w", err)
 			}
+			if err := checkPayloadSize(e.Config.Payloads, "work_outcomes", *opts.SetWorkOutcomes); err != nil {
+				return t, err
+			}
+			if err := e.checkArtifactCitations(ctx, tx, t.ProjectID, *opts.SetWorkOutcomes); err != nil {
+				return t, err
+			}
 			t.WorkOutcomesJSON = opts.SetWorkOutcomes
 			if !opts.Force {
 				if err := e.requireLeaseOrForce(ctx, tx, t.ID, opts.ActorID, opts.Force); err != nil {
@@ -415,24 +837,76 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 		t.RequiredAttestationsJSON = reqJSON
 	}
 	if opts.RequiredKindsSet || opts.PolicyOverride {
+		if err := checkRequirements(opts.RequiredKinds); err != nil {
+			return t, err
+		}
 		reqJSON, err := marshalStringSlice(opts.RequiredKinds)
 		if err != nil {
 			return t, err
 		}
 		t.RequiredAttestationsJSON = reqJSON
 	}
+	if loosened(oldPolicy.Require, currentPolicy(t).Require) {
+		if err := e.checkFreezeTx(ctx, tx, t.IterationID, t.ID, FreezeLoosenPolicy, opts.FreezeOverride, opts.ActorID); err != nil {
+			return t, err
+		}
+	}
+	if opts.RequiredCapabilitiesSet {
+		caps, err := normalizeCapabilities(opts.RequiredCapabilities)
+		if err != nil {
+			return t, err
+		}
+		t.RequiredCapabilities = caps
+	}
+	if opts.CustomFieldsSet {
+		fields, err := customFieldsJSON(e.Config, t.Type, opts.CustomFields)
+		if err != nil {
+			return t, err
+		}
+		t.CustomFieldsJSON = fields
+	}
+	if opts.DueAtSet {
+		dueAt, err := parseDueAt(opts.DueAt)
+		if err != nil {
+			return t, err
+		}
+		t.DueAt = dueAt
+	}
+	if opts.LabelsSet {
+		labels, err := normalizeLabels(opts.Labels)
+		if err != nil {
+			return t, err
+		}
+		t.Labels = labels
+	}
+	if e.Config.Policies.Risk.Enabled() {
+		risk, err := e.assessTaskRiskTx(ctx, tx, e.Config, t)
+		if err != nil {
+			return t, err
+		}
+		applyRisk(e.Config, &t, risk)
+	}
 	if opts.Status != "" && opts.Status != t.Status {
 		if opts.Status == "done" {
 			if err := e.requirePermission(ctx, tx, t.ProjectID, opts.ActorID, "task.done"); err != nil {
 				return t, err
 			}
 		}
+		if err := e.requireStatusOverride(ctx, tx, t, opts.ActorID); err != nil {
+			return t, err
+		}
+		if opts.Status == "done" && !opts.Force && e.Config.Completion.RequiresApproval() {
+			return t, errors.New("invalid status change: completions need approval; submit the task with done")
+		}
+		if err := e.checkPendingCompletion(ctx, tx, t, opts.ActorID, opts.Force); err != nil {
+			return t, err
+		}
 		if !opts.Force {
-			if err := e.requireLeaseOrForce(ctx, tx, t.ID, opts.ActorID, opts.Force); err != nil {
+			if err := e.requireStatusLease(ctx, tx, t, opts.ActorID, opts.Force); err != nil {
 				return t, err
 			}
 		}
-		if err := ensureTaskTransition(t.Status, opts.Status, opts.Force); err != nil {
+		if err := e.ensureTaskTransition(t.Type, t.Status, opts.Status, opts.Force); err != nil {
 			return t, err
 		}
 		if opts.Status == "done" && !opts.Force {
@@ -442,12 +916,12 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 			if err := e.ensureSubtasksDone(ctx, tx, t.ID, opts.Force); err != nil {
 				return t, err
 			}
-			ok, err := e.isTaskValidationSatisfied(ctx, tx, t, opts.ActorID)
+			unmet, err := e.unmetRequirements(ctx, tx, t)
 			if err != nil {
 				return t, err
 			}
-			if !ok {
-				return t, errors.New("validation policy not satisfied")
+			if len(unmet) > 0 {
+				return t, e.rejectCompletion(ctx, tx, t, opts.ActorID, unmet)
 			}
 		}
 		t.Status = opts.Status
@@ -471,6 +945,17 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
 		return t, err
 	}
+	if t.Rank != original.Rank {
+		if err := e.Repo.SetTaskRank(ctx, tx, t.ID, t.Rank); err != nil {
+			return t, err
+		}
+	}
+	t.ContentHash = canon.TaskHash(t)
+	if reassigned {
+		if err := e.recordHandoff(ctx, tx, original, original.AssigneeID, t.AssigneeID, opts.ActorID, opts.HandoffNote); err != nil {
+			return t, err
+		}
+	}
 	newPolicy := currentPolicy(t)
 	overrideEvent := opts.PolicyOverride || (opts.RequiredKindsSet && opts.PolicyPreset == "")
 	if opts.PolicyPreset != "" {
@@ -489,12 +974,31 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 			return t, err
 		}
 	}
-	if err := e.Events.Append(ctx, tx, "task.updated", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
-		"from_status": original.Status,
-		"to_status":   t.Status,
-	}); err != nil {
+	updated := events.EventPayload{"from_status": original.Status, "to_status": t.Status}
+	if !sameOptionalString(original.DueAt, t.DueAt) {
+		updated["due_at"] = t.DueAt
+	}
+	if !sameOptionalInt(original.RiskScore, t.RiskScore) || !sameOptionalString(original.RiskLevel, t.RiskLevel) {
+		updated["risk_score"], updated["risk_level"] = t.RiskScore, t.RiskLevel
+	}
+	if err := e.Events.Append(ctx, tx, "task.updated", t.ProjectID, "task", t.ID, opts.ActorID, updated); err != nil {
 		return t, err
 	}
+	if t.Status == "done" && original.Status != "done" {
+		if err := e.emitUnblocked(ctx, tx, t, opts.ActorID); err != nil {
+			return t, err
+		}
+	}
+	if t.Status != original.Status || !sameOptionalString(t.ParentID, original.ParentID) {
+		if err := e.rollupTx(ctx, tx, t.ParentID, opts.ActorID); err != nil {
+			return t, err
+		}
+	}
+	if !sameOptionalString(t.ParentID, original.ParentID) {
+		if err := e.rollupTx(ctx, tx, original.ParentID, opts.ActorID); err != nil {
+			return t, err
+		}
+	}
 	if err := tx.Commit(); err != nil {
 		return t, err
 	}
@@ -502,46 +1006,147 @@ func (e Engine) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (domain.
 	return t, nil
 }
 
-func ensureTaskTransition(oldStatus, newStatus string, force bool) error {
-	if force {
-		return nil
+// recordHandoff stores an assignee change of t, from one assignee to another (either may be
+// nil), with the note left for the new assignee.
+func (e Engine) recordHandoff(ctx context.Context, tx *sql.Tx, t domain.Task, from, to *string, actorID, note string) error {
+	h := domain.TaskHandoff{
+		TaskID:         t.ID,
+		ProjectID:      t.ProjectID,
+		FromAssigneeID: from,
+		ToAssigneeID:   to,
+		ActorID:        actorID,
+		Note:           note,
+		CreatedAt:      e.now().UTC().Format(time.RFC3339),
+	}
+	id, err := e.Repo.InsertTaskHandoffTx(ctx, tx, h)
+	if err != nil {
+		return err
 	}
-	switch oldStatus {
-	case "planned":
-		if newStatus == "in_progress" || newStatus == "canceled" || newStatus == "review" || newStatus == "done" {
-			return nil
-		}
-	case "in_progress":
-		if newStatus == "rejected" || newStatus == "canceled" || newStatus == "review" || newStatus == "done" {
-			return nil
-		}
-	case "review":
-		if newStatus == "done" || newStatus == "rejected" {
-			return nil
-		}
-	case "rejected":
-		if newStatus == "planned" {
-			return nil
-		}
+	payload := events.EventPayload{"handoff_id": id, "from": h.FromAssigneeID, "to": h.ToAssigneeID}
+	if note != "" {
+		payload["note"] = note
 	}
-	return fmt.Errorf("invalid task status transition %s -> %s", oldStatus, newStatus)
-}
-
-func validateJSON(in string) error {
-	var tmp any
-	if err := json.Unmarshal([]byte(in), &tmp); err != nil {
+	if err := e.Events.Append(ctx, tx, "task.reassigned", t.ProjectID, "task", t.ID, actorID, payload); err != nil {
 		return err
 	}
-	return nil
+	return e.recordMentions(ctx, tx, t.ProjectID, "task", t.ID, actorID, events.EventPayload{"source": "handoff_note", "handoff_id": id}, note)
 }
 
-func (e Engine) requireLeaseOrForce(ctx context.Context, tx *sql.Tx, taskID, actorID string, force bool) error {
-	if force {
-		return nil
+type TaskMoveOptions struct {
+	ID       string
+	BeforeID string
+	AfterID  string
+	ActorID  string
+}
+
+// MoveTask repositions a task before or after a sibling sharing its parent and iteration.
+// Siblings are renumbered so ranks stay dense.
+func (e Engine) MoveTask(ctx context.Context, opts TaskMoveOptions) (domain.Task, error) {
+	if (opts.BeforeID == "") == (opts.AfterID == "") {
+		return domain.Task{}, errors.New("exactly one of before or after is required")
 	}
-	l, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
-	if err != nil {
-		if errors.Is(err, repo.ErrNotFound) {
+	anchorID := opts.BeforeID
+	if anchorID == "" {
+		anchorID = opts.AfterID
+	}
+	if anchorID == opts.ID {
+		return domain.Task{}, errors.New("invalid move: task cannot be positioned relative to itself")
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	defer tx.Rollback()
+	t, err := e.Repo.GetTaskTx(ctx, tx, opts.ID)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := e.requirePermission(ctx, tx, t.ProjectID, opts.ActorID, "task.update"); err != nil {
+		return domain.Task{}, err
+	}
+	anchor, err := e.Repo.GetTaskTx(ctx, tx, anchorID)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if anchor.ProjectID != t.ProjectID || !sameOptionalString(anchor.ParentID, t.ParentID) || !sameOptionalString(anchor.IterationID, t.IterationID) {
+		return domain.Task{}, fmt.Errorf("invalid move: task %s is not a sibling of %s", anchorID, t.ID)
+	}
+	ids, err := e.Repo.ListSiblingIDsTx(ctx, tx, t.ProjectID, t.ParentID, t.IterationID)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	ordered := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == t.ID {
+			continue
+		}
+		if id == anchorID && opts.BeforeID != "" {
+			ordered = append(ordered, t.ID)
+		}
+		ordered = append(ordered, id)
+		if id == anchorID && opts.AfterID != "" {
+			ordered = append(ordered, t.ID)
+		}
+	}
+	fromRank := t.Rank
+	for i, id := range ordered {
+		if err := e.Repo.SetTaskRank(ctx, tx, id, i+1); err != nil {
+			return domain.Task{}, err
+		}
+		if id == t.ID {
+			t.Rank = i + 1
+		}
+	}
+	payload := events.EventPayload{"from_rank": fromRank, "to_rank": t.Rank}
+	if opts.BeforeID != "" {
+		payload["before"] = opts.BeforeID
+	} else {
+		payload["after"] = opts.AfterID
+	}
+	if err := e.Events.Append(ctx, tx, "task.moved", t.ProjectID, "task", t.ID, opts.ActorID, payload); err != nil {
+		return domain.Task{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Task{}, err
+	}
+	t.DependsOn, _ = e.Repo.ListTaskDependencies(ctx, t.ID)
+	return t, nil
+}
+
+func sameOptionalString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (e Engine) ensureTaskTransition(taskType, oldStatus, newStatus string, force bool) error {
+	if force {
+		return nil
+	}
+	for _, allowed := range e.Config.TaskTransitions(taskType)[oldStatus] {
+		if allowed == newStatus {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid task status transition %s -> %s", oldStatus, newStatus)
+}
+
+func validateJSON(in string) error {
+	var tmp any
+	if err := json.Unmarshal([]byte(in), &tmp); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e Engine) requireLeaseOrForce(ctx context.Context, tx *sql.Tx, taskID, actorID string, force bool) error {
+	if force {
+		return nil
+	}
+	l, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
 			return errors.New("lease required; none exists")
 		}
 		return err
@@ -565,6 +1170,11 @@ func (e Engine) TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID
 	if err := validateJSON(workOutcomesJSON); err != nil {
 		return domain.Task{}, fmt.Errorf("work-outcomes-json: %w", err)
 	}
+	if e.Config != nil {
+		if err := checkPayloadSize(e.Config.Payloads, "work_outcomes", workOutcomesJSON); err != nil {
+			return domain.Task{}, err
+		}
+	}
 	t, err := e.Repo.GetTask(ctx, taskID)
 	if err != nil {
 		return t, err
@@ -580,17 +1190,28 @@ func (e Engine) TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID
 	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.done"); err != nil {
 		return t, err
 	}
+	if err := e.checkArtifactCitations(ctx, tx, t.ProjectID, workOutcomesJSON); err != nil {
+		return t, err
+	}
 	if force {
 		if err := e.requireForcePermission(ctx, tx, t.ProjectID, actorID); err != nil {
 			return t, err
 		}
 	}
+	if t.Status != "done" {
+		if err := e.requireStatusOverride(ctx, tx, t, actorID); err != nil {
+			return t, err
+		}
+	}
+	if err := e.checkPendingCompletion(ctx, tx, t, actorID, force); err != nil {
+		return t, err
+	}
 
 	t.WorkOutcomesJSON = &workOutcomesJSON
 	targetStatus := "done"
 	if !force {
 		// gating checks
-		if err := e.requireLeaseOrForce(ctx, tx, t.ID, actorID, force); err != nil {
+		if err := e.requireStatusLease(ctx, tx, t, actorID, force); err != nil {
 			return t, err
 		}
 		if err := e.ensureDependenciesDone(ctx, tx, t.ID, t.ProjectID, force); err != nil {
@@ -599,15 +1220,21 @@ func (e Engine) TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID
 		if err := e.ensureSubtasksDone(ctx, tx, t.ID, force); err != nil {
 			return t, err
 		}
-		satisfied, err := e.isTaskValidationSatisfied(ctx, tx, t, actorID)
+		if err := checkRequiredOutcomes(e.Config, t.Type, workOutcomesJSON); err != nil {
+			return t, err
+		}
+		if e.Config.Completion.RequiresApproval() {
+			return e.submitCompletion(ctx, tx, t, actorID)
+		}
+		unmet, err := e.unmetRequirements(ctx, tx, t)
 		if err != nil {
 			return t, err
 		}
-		if !satisfied {
-			return t, errors.New("validation policy not satisfied")
+		if len(unmet) > 0 {
+			return t, e.rejectCompletion(ctx, tx, t, actorID, unmet)
 		}
 	}
-	if err := ensureTaskTransition(t.Status, targetStatus, force); err != nil {
+	if err := e.ensureTaskTransition(t.Type, t.Status, targetStatus, force); err != nil {
 		return t, err
 	}
 	t.Status = targetStatus
@@ -619,9 +1246,16 @@ func (e Engine) TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID
 	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
 		return t, err
 	}
+	t.ContentHash = canon.TaskHash(t)
 	if err := e.Events.Append(ctx, tx, "task.done", t.ProjectID, "task", t.ID, actorID, events.EventPayload{"status": t.Status}); err != nil {
 		return t, err
 	}
+	if err := e.emitUnblocked(ctx, tx, t, actorID); err != nil {
+		return t, err
+	}
+	if err := e.rollupTx(ctx, tx, t.ParentID, actorID); err != nil {
+		return t, err
+	}
 	if err := tx.Commit(); err != nil {
 		return t, err
 	}
@@ -629,6 +1263,23 @@ func (e Engine) TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID
 	return t, nil
 }
 
+// emitUnblocked records task.unblocked for every task whose last unfinished dependency was the
+// just-completed task, so schedulers can dispatch it without walking the graph.
+func (e Engine) emitUnblocked(ctx context.Context, tx *sql.Tx, done domain.Task, actorID string) error {
+	ids, err := e.Repo.ListUnblockedDependentsTx(ctx, tx, done.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := e.Events.Append(ctx, tx, "task.unblocked", done.ProjectID, "task", id, actorID, events.EventPayload{
+			"completed_dependency": done.ID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e Engine) ensureDependenciesDone(ctx context.Context, tx *sql.Tx, taskID, projectID string, force bool) error {
 	if force {
 		return nil
@@ -652,75 +1303,759 @@ func (e Engine) ensureDependenciesDone(ctx context.Context, tx *sql.Tx, taskID,
 	return nil
 }
 
-func (e Engine) ensureSubtasksDone(ctx context.Context, tx *sql.Tx, taskID string, force bool) error {
-	if force {
-		return nil
+func (e Engine) ensureSubtasksDone(ctx context.Context, tx *sql.Tx, taskID string, force bool) error {
+	if force {
+		return nil
+	}
+	children, err := e.Repo.ListChildrenTx(ctx, tx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		t, err := e.Repo.GetTaskTx(ctx, tx, c)
+		if err != nil {
+			return err
+		}
+		if t.Status != "done" {
+			return fmt.Errorf("subtask %s not done", c)
+		}
+		if err := e.ensureSubtasksDone(ctx, tx, t.ID, force); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e Engine) isTaskValidationSatisfied(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) (bool, error) {
+	unmet, err := e.unmetRequirements(ctx, tx, t)
+	if err != nil {
+		return false, err
+	}
+	return len(unmet) == 0, nil
+}
+
+// unmetRequirements lists the task's required entries neither attested nor waived, then
+// the attester roles its risk level requires that no attestation satisfies.
+func (e Engine) unmetRequirements(ctx context.Context, tx *sql.Tx, t domain.Task) ([]string, error) {
+	attesters, err := e.unmetAttestersTx(ctx, tx, t)
+	if err != nil {
+		return nil, err
+	}
+	if t.RequiredAttestationsJSON == nil {
+		return attesters, nil
+	}
+	var required []string
+	if err := json.Unmarshal([]byte(*t.RequiredAttestationsJSON), &required); err != nil {
+		return nil, err
+	}
+	if len(required) == 0 {
+		return attesters, nil
+	}
+	found, err := e.presentRequirements(ctx, tx, t.ID, required)
+	if err != nil {
+		return nil, err
+	}
+	waivers, err := e.Repo.ListActiveWaiversTx(ctx, tx, t.ID, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range waivers {
+		found[w.Kind] = true
+	}
+	var unmet []string
+	for _, req := range required {
+		if !found[req] {
+			unmet = append(unmet, req)
+		}
+	}
+	return append(unmet, attesters...), nil
+}
+
+// rejectCompletion abandons tx and records the refused completion of t as a
+// task.validation.failed event in a transaction of its own, so daily digests can report it.
+// Recording is best effort; the validation error is returned either way.
+func (e Engine) rejectCompletion(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string, unmet []string) error {
+	errUnsatisfied := errors.New("validation policy not satisfied")
+	_ = tx.Rollback()
+	ftx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errUnsatisfied
+	}
+	defer ftx.Rollback()
+	if err := e.Events.Append(ctx, ftx, "task.validation.failed", t.ProjectID, "task", t.ID, actorID, events.EventPayload{"missing": unmet}); err == nil {
+		_ = ftx.Commit()
+	}
+	return errUnsatisfied
+}
+
+// PresentRequirements reports which of the task's required entries are met by attestations (waivers aside).
+func (e Engine) PresentRequirements(ctx context.Context, taskID string, required []string) (map[string]bool, error) {
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return e.presentRequirements(ctx, tx, taskID, required)
+}
+
+// presentRequirements matches plain kinds directly and "kind+countersign" entries against
+// task attestations that carry a countersigning attestation of the second kind. Entries with
+// a payload predicate are checked against the attestations' payloads.
+func (e Engine) presentRequirements(ctx context.Context, tx *sql.Tx, taskID string, required []string) (map[string]bool, error) {
+	kinds, err := e.Repo.ListTaskAttestationKindsTx(ctx, tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	for _, req := range required {
+		r := config.ParseRequirement(req)
+		if r.Predicate != "" {
+			ok, err := e.predicateRequirementMet(ctx, tx, taskID, r)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				found[req] = true
+			}
+			continue
+		}
+		kind, countersign := r.Kind, r.Countersign
+		for _, k := range e.satisfyingKinds(kind) {
+			countersigns, ok := kinds[k]
+			if !ok {
+				continue
+			}
+			if countersign == "" {
+				found[req] = true
+				break
+			}
+			for _, cs := range e.satisfyingKinds(countersign) {
+				if slices.Contains(countersigns, cs) {
+					found[req] = true
+					break
+				}
+			}
+			if found[req] {
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// checkRequirements rejects task requirement entries that do not parse, such as a payload
+// predicate with a syntax error; presets are checked when the config is loaded.
+func checkRequirements(reqs []string) error {
+	for _, req := range reqs {
+		if err := config.ValidateRequirement(req); err != nil {
+			return fmt.Errorf("invalid requirement %s: %w", req, err)
+		}
+	}
+	return nil
+}
+
+// predicateRequirementMet reports whether an attestation on the task meets r's kind, payload
+// predicate and countersign. A predicate that does not parse or evaluate is never met.
+func (e Engine) predicateRequirementMet(ctx context.Context, tx *sql.Tx, taskID string, r config.Requirement) (bool, error) {
+	pred, err := expr.Parse(r.Predicate)
+	if err != nil {
+		return false, nil
+	}
+	atts, err := e.Repo.ListTaskAttestationsTx(ctx, tx, taskID, e.satisfyingKinds(r.Kind))
+	if err != nil {
+		return false, err
+	}
+	for _, a := range atts {
+		if r.Countersign != "" && !slices.ContainsFunc(e.satisfyingKinds(r.Countersign), func(cs string) bool {
+			return slices.Contains(a.Countersigns, cs)
+		}) {
+			continue
+		}
+		vars, err := e.attestationVars(ctx, a.Attestation)
+		if err != nil {
+			return false, err
+		}
+		if ok, err := pred.Eval(vars); err == nil && ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// satisfyingKinds lists the attestation kinds meeting a requirement of kind: the kind
+// itself and, during its grace period, the replacement of a deprecated kind.
+func (e Engine) satisfyingKinds(kind string) []string {
+	if e.Config == nil {
+		return []string{kind}
+	}
+	return e.Config.SatisfyingKinds(kind, e.now())
+}
+
+type WaiverCreateOptions struct {
+	TaskID        string
+	Kind          string
+	Justification string
+	ExpiresAt     string
+	ActorID       string
+}
+
+// WaiveValidation records a time-boxed waiver for one required attestation kind that the task is still missing.
+func (e Engine) WaiveValidation(ctx context.Context, opts WaiverCreateOptions) (domain.Waiver, error) {
+	if opts.Kind == "" {
+		return domain.Waiver{}, errors.New("kind is required")
+	}
+	if strings.TrimSpace(opts.Justification) == "" {
+		return domain.Waiver{}, errors.New("justification is required")
+	}
+	if opts.ExpiresAt == "" {
+		return domain.Waiver{}, errors.New("expires_at is required")
+	}
+	expires, err := time.Parse(time.RFC3339, opts.ExpiresAt)
+	if err != nil {
+		return domain.Waiver{}, fmt.Errorf("invalid expires_at: %w", err)
+	}
+	now := e.now().UTC()
+	if !expires.After(now) {
+		return domain.Waiver{}, errors.New("invalid expires_at: must be in the future")
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Waiver{}, err
+	}
+	defer tx.Rollback()
+	t, err := e.Repo.GetTaskTx(ctx, tx, opts.TaskID)
+	if err != nil {
+		return domain.Waiver{}, err
+	}
+	if err := e.requirePermission(ctx, tx, t.ProjectID, opts.ActorID, "task.waive"); err != nil {
+		return domain.Waiver{}, err
+	}
+	required := false
+	for _, k := range currentPolicy(t).Require {
+		if k == opts.Kind {
+			required = true
+		}
+	}
+	if !required {
+		return domain.Waiver{}, fmt.Errorf("invalid waiver: %s is not required for task %s", opts.Kind, t.ID)
+	}
+	present, err := e.presentRequirements(ctx, tx, t.ID, []string{opts.Kind})
+	if err != nil {
+		return domain.Waiver{}, err
+	}
+	if present[opts.Kind] {
+		return domain.Waiver{}, fmt.Errorf("invalid waiver: %s already attested for task %s", opts.Kind, t.ID)
+	}
+	w := domain.Waiver{
+		ID:            uuid.New().String(),
+		ProjectID:     t.ProjectID,
+		TaskID:        t.ID,
+		Kind:          opts.Kind,
+		Justification: opts.Justification,
+		ActorID:       opts.ActorID,
+		ExpiresAt:     expires.UTC().Format(time.RFC3339),
+		CreatedAt:     now.Format(time.RFC3339),
+	}
+	if err := e.Repo.InsertWaiverTx(ctx, tx, w); err != nil {
+		return domain.Waiver{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "validation.waived", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
+		"waiver_id":     w.ID,
+		"kind":          w.Kind,
+		"justification": w.Justification,
+		"expires_at":    w.ExpiresAt,
+	}); err != nil {
+		return domain.Waiver{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Waiver{}, err
+	}
+	return w, nil
+}
+
+// ActiveWaivers lists the task's waivers that have not yet expired.
+func (e Engine) ActiveWaivers(ctx context.Context, taskID string) ([]domain.Waiver, error) {
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return e.Repo.ListActiveWaiversTx(ctx, tx, taskID, e.now().UTC().Format(time.RFC3339))
+}
+
+// ClaimLease obtains a lease transactionally.
+func (e Engine) ClaimLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
+	claim, err := e.ClaimLeaseWithOptions(ctx, LeaseClaimOptions{TaskID: taskID, ActorID: actorID, LeaseSeconds: leaseSeconds})
+	return claim.Lease, err
+}
+
+type LeaseClaimOptions struct {
+	TaskID       string
+	ActorID      string
+	LeaseSeconds int
+	// Wait queues the actor instead of failing when another actor holds the lease.
+	Wait bool
+	// Plan is stored on the lease for supervisors to review; renewing a lease without one
+	// keeps the plan declared before.
+	Plan *domain.LeasePlan
+}
+
+// LeaseClaim is the outcome of ClaimOrQueueLease: the caller either holds Lease, or Waiter
+// places it in the queue behind the current owner of Lease.
+type LeaseClaim struct {
+	Lease  domain.Lease
+	Waiter *domain.LeaseWaiter
+}
+
+// ClaimOrQueueLease claims the lease like ClaimLease, but queues actorID instead of failing
+// when another actor holds it. Queued actors are granted the lease in order as it is
+// released or expires, rather than racing to reclaim it.
+func (e Engine) ClaimOrQueueLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (LeaseClaim, error) {
+	return e.ClaimLeaseWithOptions(ctx, LeaseClaimOptions{TaskID: taskID, ActorID: actorID, LeaseSeconds: leaseSeconds, Wait: true})
+}
+
+// ClaimLeaseWithOptions claims the lease like ClaimLease, or queues for it like
+// ClaimOrQueueLease with opts.Wait, declaring opts.Plan. A queued plan is kept with the
+// queue entry and comes with the lease when it is granted.
+func (e Engine) ClaimLeaseWithOptions(ctx context.Context, opts LeaseClaimOptions) (LeaseClaim, error) {
+	if e.Config == nil {
+		return LeaseClaim{}, errors.New("config not loaded")
+	}
+	taskID, actorID, leaseSeconds, wait := opts.TaskID, opts.ActorID, opts.LeaseSeconds, opts.Wait
+	plan, err := e.normalizeLeasePlan(opts.Plan)
+	if err != nil {
+		return LeaseClaim{}, err
+	}
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return LeaseClaim{}, err
+	}
+	if leaseSeconds, err = e.leaseTerm(ctx, t.ProjectID, leaseSeconds); err != nil {
+		return LeaseClaim{}, err
+	}
+	if wait {
+		if err := e.RequireFeature(ctx, t.ProjectID, config.FeatureLeaseQueue); err != nil {
+			return LeaseClaim{}, err
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return LeaseClaim{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.claim"); err != nil {
+		return LeaseClaim{}, err
+	}
+	if err := e.ensureDriver(ctx, tx, t.ID, actorID); err != nil {
+		return LeaseClaim{}, err
+	}
+
+	now := e.now().UTC()
+	// Queued actors come first when the lease is free.
+	if _, err := e.grantQueuedLease(ctx, tx, t, now); err != nil {
+		return LeaseClaim{}, err
+	}
+	expires := now.Add(time.Duration(leaseSeconds) * time.Second)
+	newLease := domain.Lease{
+		TaskID:     taskID,
+		OwnerID:    actorID,
+		AcquiredAt: now.Format(time.RFC3339),
+		ExpiresAt:  expires.Format(time.RFC3339),
+		Plan:       plan,
+	}
+	existing, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		return LeaseClaim{}, err
+	}
+	if err == nil {
+		exp, _ := time.Parse(time.RFC3339, existing.ExpiresAt)
+		if now.Before(exp) && existing.OwnerID != actorID {
+			if !wait {
+				return LeaseClaim{}, errors.New("lease already held")
+			}
+			w, err := e.enqueueLeaseWaiter(ctx, tx, t, actorID, leaseSeconds, plan, now)
+			if err != nil {
+				return LeaseClaim{}, err
+			}
+			if err := tx.Commit(); err != nil {
+				return LeaseClaim{}, err
+			}
+			return LeaseClaim{Lease: existing, Waiter: &w}, nil
+		}
+		if existing.OwnerID == actorID {
+			newLease.PendingOwnerID = existing.PendingOwnerID
+			if newLease.Plan == nil {
+				newLease.Plan = existing.Plan
+			}
+		}
+	}
+	if err := e.Repo.UpsertLease(ctx, tx, newLease); err != nil {
+		return LeaseClaim{}, err
+	}
+	payload := events.EventPayload{"expires_at": newLease.ExpiresAt}
+	if plan != nil {
+		payload["plan"] = plan
+	}
+	if err := e.Events.Append(ctx, tx, "lease.claimed", t.ProjectID, "task", taskID, actorID, payload); err != nil {
+		return LeaseClaim{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return LeaseClaim{}, err
+	}
+	return LeaseClaim{Lease: newLease}, nil
+}
+
+func (e Engine) ReleaseLease(ctx context.Context, taskID, actorID string) error {
+	if e.Config == nil {
+		return errors.New("config not loaded")
+	}
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.release"); err != nil {
+		return err
+	}
+	if err := e.Repo.DeleteLease(ctx, tx, taskID); err != nil {
+		return err
+	}
+	if err := e.Events.Append(ctx, tx, "lease.released", t.ProjectID, "task", taskID, actorID, events.EventPayload{}); err != nil {
+		return err
+	}
+	if _, err := e.grantQueuedLease(ctx, tx, t, e.now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type LeaseTransferOptions struct {
+	TaskID       string
+	ActorID      string
+	ToActorID    string
+	LeaseSeconds int
+	// RequireConsent records an offer the target must accept; the current owner keeps the lease until then.
+	RequireConsent bool
+}
+
+// TransferLease hands a held lease to another actor in one transaction, so no third party can claim in between.
+func (e Engine) TransferLease(ctx context.Context, opts LeaseTransferOptions) (domain.Lease, error) {
+	if opts.ToActorID == "" {
+		return domain.Lease{}, errors.New("to_actor_id is required")
+	}
+	if opts.ToActorID == opts.ActorID {
+		return domain.Lease{}, errors.New("invalid transfer: target already owns the lease")
+	}
+	t, err := e.Repo.GetTask(ctx, opts.TaskID)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	if opts.LeaseSeconds > 0 {
+		if _, err := e.leaseTerm(ctx, t.ProjectID, opts.LeaseSeconds); err != nil {
+			return domain.Lease{}, err
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, opts.ActorID, "task.release"); err != nil {
+		return domain.Lease{}, err
+	}
+	l, err := e.heldLease(ctx, tx, opts.TaskID, opts.ActorID)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	ok, err := e.Auth.ActorHasPermission(ctx, tx, t.ProjectID, opts.ToActorID, "task.claim")
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	if !ok {
+		return domain.Lease{}, fmt.Errorf("invalid transfer: %s lacks task.claim", opts.ToActorID)
+	}
+	if err := e.ensureDriver(ctx, tx, opts.TaskID, opts.ToActorID); err != nil {
+		return domain.Lease{}, err
+	}
+	if opts.RequireConsent {
+		l.PendingOwnerID = &opts.ToActorID
+		if err := e.Repo.UpsertLease(ctx, tx, l); err != nil {
+			return domain.Lease{}, err
+		}
+		if err := e.Events.Append(ctx, tx, "lease.transfer.offered", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
+			"from": opts.ActorID,
+			"to":   opts.ToActorID,
+		}); err != nil {
+			return domain.Lease{}, err
+		}
+		return l, tx.Commit()
+	}
+	l = e.transferredLease(l, opts.ToActorID, opts.LeaseSeconds)
+	if err := e.Repo.UpsertLease(ctx, tx, l); err != nil {
+		return domain.Lease{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "lease.transferred", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
+		"from":       opts.ActorID,
+		"to":         opts.ToActorID,
+		"expires_at": l.ExpiresAt,
+	}); err != nil {
+		return domain.Lease{}, err
+	}
+	return l, tx.Commit()
+}
+
+// AcceptLeaseTransfer completes a pending transfer offered to actorID.
+func (e Engine) AcceptLeaseTransfer(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	if leaseSeconds > 0 {
+		if _, err := e.leaseTerm(ctx, t.ProjectID, leaseSeconds); err != nil {
+			return domain.Lease{}, err
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.claim"); err != nil {
+		return domain.Lease{}, err
+	}
+	l, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return domain.Lease{}, errors.New("no lease transfer pending")
+	}
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	if l.PendingOwnerID == nil || *l.PendingOwnerID != actorID {
+		return domain.Lease{}, errors.New("no lease transfer pending for actor")
+	}
+	exp, _ := time.Parse(time.RFC3339, l.ExpiresAt)
+	if !e.now().Before(exp) {
+		return domain.Lease{}, errors.New("lease expired; transfer offer lapsed")
+	}
+	from := l.OwnerID
+	l = e.transferredLease(l, actorID, leaseSeconds)
+	if err := e.Repo.UpsertLease(ctx, tx, l); err != nil {
+		return domain.Lease{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "lease.transferred", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"from":       from,
+		"to":         actorID,
+		"consented":  true,
+		"expires_at": l.ExpiresAt,
+	}); err != nil {
+		return domain.Lease{}, err
+	}
+	return l, tx.Commit()
+}
+
+// DeclineLeaseTransfer withdraws a pending offer; either the owner or the offered actor may decline.
+func (e Engine) DeclineLeaseTransfer(ctx context.Context, taskID, actorID string) (domain.Lease, error) {
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	defer tx.Rollback()
+	l, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	if errors.Is(err, repo.ErrNotFound) || (err == nil && l.PendingOwnerID == nil) {
+		return domain.Lease{}, errors.New("no lease transfer pending")
+	}
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	if actorID != l.OwnerID && actorID != *l.PendingOwnerID {
+		return domain.Lease{}, errors.New("lease transfer not offered to actor")
+	}
+	if err := e.ensureActor(ctx, tx, actorID); err != nil {
+		return domain.Lease{}, err
+	}
+	to := *l.PendingOwnerID
+	l.PendingOwnerID = nil
+	if err := e.Repo.UpsertLease(ctx, tx, l); err != nil {
+		return domain.Lease{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "lease.transfer.declined", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"from": l.OwnerID,
+		"to":   to,
+	}); err != nil {
+		return domain.Lease{}, err
+	}
+	return l, tx.Commit()
+}
+
+// heldLease returns the unexpired lease on taskID owned by actorID.
+func (e Engine) heldLease(ctx context.Context, tx *sql.Tx, taskID, actorID string) (domain.Lease, error) {
+	l, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return l, errors.New("lease required; none exists")
+	}
+	if err != nil {
+		return l, err
+	}
+	exp, _ := time.Parse(time.RFC3339, l.ExpiresAt)
+	if !e.now().Before(exp) {
+		return l, errors.New("lease expired; reacquire")
+	}
+	if l.OwnerID != actorID {
+		return l, errors.New("lease owned by different actor")
+	}
+	return l, nil
+}
+
+// leaseTerm resolves a requested lease term in seconds against the effective leases config
+// of the project: zero or less takes the default term and terms above the maximum fail.
+func (e Engine) leaseTerm(ctx context.Context, projectID string, seconds int) (int, error) {
+	cfg, err := e.projectConfig(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	leases := cfg.Effective().Leases
+	if seconds <= 0 {
+		return int(leases.Default() / time.Second), nil
+	}
+	if max := leases.Max(); max > 0 && time.Duration(seconds)*time.Second > max {
+		return 0, fmt.Errorf("invalid lease_seconds: %d exceeds the maximum of %d", seconds, int(max/time.Second))
+	}
+	return seconds, nil
+}
+
+// transferredLease moves l to a new owner. With leaseSeconds <= 0 the remaining term carries over.
+func (e Engine) transferredLease(l domain.Lease, to string, leaseSeconds int) domain.Lease {
+	now := e.now().UTC()
+	l.OwnerID = to
+	l.AcquiredAt = now.Format(time.RFC3339)
+	if leaseSeconds > 0 {
+		l.ExpiresAt = now.Add(time.Duration(leaseSeconds) * time.Second).Format(time.RFC3339)
+	}
+	l.PendingOwnerID = nil
+	l.Plan = nil
+	return l
+}
+
+// AssigneeRoles lists the pairing roles a task assignee may take.
+var AssigneeRoles = []string{"driver", "reviewer"}
+
+type TaskAssignOptions struct {
+	TaskID     string
+	AssigneeID string
+	Role       string
+	ActorID    string
+	// HandoffNote is left for a new assignee; UnassignTask ignores it.
+	HandoffNote string
+}
+
+// AssignTask adds a driver or reviewer to a task. An actor cannot both drive and review the same task.
+func (e Engine) AssignTask(ctx context.Context, opts TaskAssignOptions) (domain.TaskAssignee, error) {
+	if opts.AssigneeID == "" {
+		return domain.TaskAssignee{}, errors.New("assignee required")
 	}
-	children, err := e.Repo.ListChildrenTx(ctx, tx, taskID)
+	if opts.Role != "driver" && opts.Role != "reviewer" {
+		return domain.TaskAssignee{}, fmt.Errorf("invalid assignee role %q: use driver or reviewer", opts.Role)
+	}
+	t, err := e.Repo.GetTask(ctx, opts.TaskID)
 	if err != nil {
-		return err
+		return domain.TaskAssignee{}, err
 	}
-	for _, c := range children {
-		t, err := e.Repo.GetTaskTx(ctx, tx, c)
-		if err != nil {
-			return err
-		}
-		if t.Status != "done" {
-			return fmt.Errorf("subtask %s not done", c)
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, opts.ActorID, "task.update"); err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	current, err := e.Repo.ListTaskAssigneesTx(ctx, tx, t.ID)
+	if err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	for _, a := range current {
+		if a.ActorID != opts.AssigneeID {
+			continue
 		}
-		if err := e.ensureSubtasksDone(ctx, tx, t.ID, force); err != nil {
-			return err
+		if a.Role == opts.Role {
+			return domain.TaskAssignee{}, fmt.Errorf("invalid assignment: %s is already a %s of task %s", opts.AssigneeID, opts.Role, t.ID)
 		}
+		return domain.TaskAssignee{}, fmt.Errorf("invalid assignment: %s is already the task's %s", opts.AssigneeID, a.Role)
 	}
-	return nil
+	if err := e.ensureActor(ctx, tx, opts.AssigneeID); err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	a := domain.TaskAssignee{
+		TaskID:     t.ID,
+		ActorID:    opts.AssigneeID,
+		Role:       opts.Role,
+		AssignedAt: e.now().UTC().Format(time.RFC3339),
+	}
+	if err := e.Repo.InsertTaskAssigneeTx(ctx, tx, a); err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.assignee.added", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
+		"assignee": a.ActorID,
+		"role":     a.Role,
+	}); err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	if err := e.recordHandoff(ctx, tx, t, nil, &a.ActorID, opts.ActorID, opts.HandoffNote); err != nil {
+		return domain.TaskAssignee{}, err
+	}
+	return a, tx.Commit()
 }
 
-func (e Engine) isTaskValidationSatisfied(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) (bool, error) {
-	if t.RequiredAttestationsJSON == nil {
-		return true, nil
+// UnassignTask removes one assignment from a task.
+func (e Engine) UnassignTask(ctx context.Context, opts TaskAssignOptions) error {
+	t, err := e.Repo.GetTask(ctx, opts.TaskID)
+	if err != nil {
+		return err
 	}
-	var required []string
-	if err := json.Unmarshal([]byte(*t.RequiredAttestationsJSON), &required); err != nil {
-		return false, err
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
-	if len(required) == 0 {
-		return true, nil
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, opts.ActorID, "task.update"); err != nil {
+		return err
 	}
-	rows, err := tx.QueryContext(ctx, `SELECT kind FROM attestations WHERE entity_kind='task' AND entity_id=?`, t.ID)
+	removed, err := e.Repo.DeleteTaskAssigneeTx(ctx, tx, t.ID, opts.AssigneeID, opts.Role)
 	if err != nil {
-		return false, err
+		return err
 	}
-	defer rows.Close()
-	found := map[string]bool{}
-	for rows.Next() {
-		var kind string
-		if err := rows.Scan(&kind); err != nil {
-			return false, err
-		}
-		for _, req := range required {
-			if kind == req {
-				found[kind] = true
-			}
-		}
+	if !removed {
+		return repo.ErrNotFound
 	}
-	for _, req := range required {
-		if !found[req] {
-			return false, nil
-		}
+	if err := e.Events.Append(ctx, tx, "task.assignee.removed", t.ProjectID, "task", t.ID, opts.ActorID, events.EventPayload{
+		"assignee": opts.AssigneeID,
+		"role":     opts.Role,
+	}); err != nil {
+		return err
+	}
+	if err := e.recordHandoff(ctx, tx, t, &opts.AssigneeID, nil, opts.ActorID, ""); err != nil {
+		return err
 	}
-	return true, nil
+	return tx.Commit()
 }
 
-// ClaimLease obtains a lease transactionally.
-func (e Engine) ClaimLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
-	if e.Config == nil {
-		return domain.Lease{}, errors.New("config not loaded")
-	}
+// ClaimReviewLease obtains the review lease, which an assigned reviewer holds alongside the driver's work lease.
+// While the task is in review its holder may move it to done or rejected without the work lease.
+func (e Engine) ClaimReviewLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
 	t, err := e.Repo.GetTask(ctx, taskID)
 	if err != nil {
 		return domain.Lease{}, err
 	}
-	_ = t // ensure task exists
+	if leaseSeconds, err = e.leaseTerm(ctx, t.ProjectID, leaseSeconds); err != nil {
+		return domain.Lease{}, err
+	}
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return domain.Lease{}, err
@@ -729,41 +2064,48 @@ func (e Engine) ClaimLease(ctx context.Context, taskID, actorID string, leaseSec
 	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.claim"); err != nil {
 		return domain.Lease{}, err
 	}
-
+	assignees, err := e.Repo.ListTaskAssigneesTx(ctx, tx, t.ID)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	if !hasAssignee(assignees, actorID, "reviewer") {
+		return domain.Lease{}, fmt.Errorf("invalid claim: %s is not an assigned reviewer of task %s", actorID, t.ID)
+	}
 	now := e.now().UTC()
-	expires := now.Add(time.Duration(leaseSeconds) * time.Second)
-	newLease := domain.Lease{
-		TaskID:     taskID,
-		OwnerID:    actorID,
-		AcquiredAt: now.Format(time.RFC3339),
-		ExpiresAt:  expires.Format(time.RFC3339),
+	if work, err := e.Repo.GetLeaseTx(ctx, tx, t.ID); err == nil {
+		exp, _ := time.Parse(time.RFC3339, work.ExpiresAt)
+		if now.Before(exp) && work.OwnerID == actorID {
+			return domain.Lease{}, errors.New("invalid claim: the work lease holder cannot also hold the review lease")
+		}
+	} else if !errors.Is(err, repo.ErrNotFound) {
+		return domain.Lease{}, err
 	}
-	existing, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	existing, err := e.Repo.GetReviewLeaseTx(ctx, tx, t.ID)
 	if err != nil && !errors.Is(err, repo.ErrNotFound) {
 		return domain.Lease{}, err
 	}
 	if err == nil {
 		exp, _ := time.Parse(time.RFC3339, existing.ExpiresAt)
 		if now.Before(exp) && existing.OwnerID != actorID {
-			return domain.Lease{}, errors.New("lease already held")
+			return domain.Lease{}, errors.New("review lease already held")
 		}
 	}
-	if err := e.Repo.UpsertLease(ctx, tx, newLease); err != nil {
-		return domain.Lease{}, err
+	l := domain.Lease{
+		TaskID:     t.ID,
+		OwnerID:    actorID,
+		AcquiredAt: now.Format(time.RFC3339),
+		ExpiresAt:  now.Add(time.Duration(leaseSeconds) * time.Second).Format(time.RFC3339),
 	}
-	if err := e.Events.Append(ctx, tx, "lease.claimed", t.ProjectID, "task", taskID, actorID, events.EventPayload{"expires_at": newLease.ExpiresAt}); err != nil {
+	if err := e.Repo.UpsertReviewLease(ctx, tx, l); err != nil {
 		return domain.Lease{}, err
 	}
-	if err := tx.Commit(); err != nil {
+	if err := e.Events.Append(ctx, tx, "review_lease.claimed", t.ProjectID, "task", t.ID, actorID, events.EventPayload{"expires_at": l.ExpiresAt}); err != nil {
 		return domain.Lease{}, err
 	}
-	return newLease, nil
+	return l, tx.Commit()
 }
 
-func (e Engine) ReleaseLease(ctx context.Context, taskID, actorID string) error {
-	if e.Config == nil {
-		return errors.New("config not loaded")
-	}
+func (e Engine) ReleaseReviewLease(ctx context.Context, taskID, actorID string) error {
 	t, err := e.Repo.GetTask(ctx, taskID)
 	if err != nil {
 		return err
@@ -776,15 +2118,62 @@ func (e Engine) ReleaseLease(ctx context.Context, taskID, actorID string) error
 	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.release"); err != nil {
 		return err
 	}
-	if err := e.Repo.DeleteLease(ctx, tx, taskID); err != nil {
+	if err := e.Repo.DeleteReviewLease(ctx, tx, t.ID); err != nil {
 		return err
 	}
-	if err := e.Events.Append(ctx, tx, "lease.released", t.ProjectID, "task", taskID, actorID, events.EventPayload{}); err != nil {
+	if err := e.Events.Append(ctx, tx, "review_lease.released", t.ProjectID, "task", t.ID, actorID, events.EventPayload{}); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
+// requireStatusLease checks the work lease, accepting the review lease instead while the task is in review.
+func (e Engine) requireStatusLease(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string, force bool) error {
+	err := e.requireLeaseOrForce(ctx, tx, t.ID, actorID, force)
+	if err == nil || t.Status != "review" {
+		return err
+	}
+	rl, rerr := e.Repo.GetReviewLeaseTx(ctx, tx, t.ID)
+	if rerr != nil {
+		if errors.Is(rerr, repo.ErrNotFound) {
+			return err
+		}
+		return rerr
+	}
+	exp, _ := time.Parse(time.RFC3339, rl.ExpiresAt)
+	if rl.OwnerID == actorID && e.now().Before(exp) {
+		return nil
+	}
+	return err
+}
+
+// ensureDriver restricts the work lease to assigned drivers once a task has any.
+func (e Engine) ensureDriver(ctx context.Context, tx *sql.Tx, taskID, actorID string) error {
+	assignees, err := e.Repo.ListTaskAssigneesTx(ctx, tx, taskID)
+	if err != nil {
+		return err
+	}
+	drivers := 0
+	for _, a := range assignees {
+		if a.Role == "driver" {
+			drivers++
+		}
+	}
+	if drivers > 0 && !hasAssignee(assignees, actorID, "driver") {
+		return fmt.Errorf("invalid claim: %s is not an assigned driver of task %s", actorID, taskID)
+	}
+	return nil
+}
+
+func hasAssignee(assignees []domain.TaskAssignee, actorID, role string) bool {
+	for _, a := range assignees {
+		if a.ActorID == actorID && a.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
 func (e Engine) CreateIteration(ctx context.Context, it domain.Iteration, actorID string) (domain.Iteration, error) {
 	if e.Config == nil {
 		return it, errors.New("config not loaded")
@@ -795,6 +2184,11 @@ func (e Engine) CreateIteration(ctx context.Context, it domain.Iteration, actorI
 	if it.Status == "" {
 		it.Status = "pending"
 	}
+	krs, err := normalizeKeyResults(it.KeyResults)
+	if err != nil {
+		return it, err
+	}
+	it.KeyResults = krs
 	it.CreatedAt = e.now().UTC().Format(time.RFC3339)
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -859,9 +2253,12 @@ func (e Engine) SetIterationStatus(ctx context.Context, id, status, actorID stri
 				return it, err
 			}
 			if !ok {
-				return it, fmt.Errorf("attestation %s required for iteration validation", requiredKind)
+				return it, e.rejectIterationValidation(ctx, it, requiredKind, actorID, fmt.Errorf("attestation %s required for iteration validation", requiredKind))
 			}
 		}
+		if unmet := UnmetKeyResults(it.KeyResults); len(unmet) > 0 {
+			return it, e.rejectIterationValidation(ctx, it, requiredKind, actorID, fmt.Errorf("key results %s not met for iteration validation", strings.Join(unmet, ", ")))
+		}
 	}
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -880,18 +2277,16 @@ func (e Engine) SetIterationStatus(ctx context.Context, id, status, actorID stri
 		return it, err
 	}
 	if status == "validated" {
-		result := true
-		if !force && requiredKind != "" {
-			ok, err := e.iterationValidated(ctx, id, requiredKind)
-			if err != nil {
-				return it, err
-			}
-			result = ok
-		}
-		if err := e.Events.Append(ctx, tx, "iteration.validation.checked", it.ProjectID, "iteration", id, actorID, events.EventPayload{
+		// The checks ran before the transaction, as querying e.DB here would wait on the
+		// connection tx holds; reaching this point without force means they passed.
+		checked := events.EventPayload{
 			"required_kind": requiredKind,
-			"result":        result,
-		}); err != nil {
+			"result":        true,
+		}
+		if len(it.KeyResults) > 0 {
+			checked["key_results"] = it.KeyResults
+		}
+		if err := e.Events.Append(ctx, tx, "iteration.validation.checked", it.ProjectID, "iteration", id, actorID, checked); err != nil {
 			return it, err
 		}
 	}
@@ -905,22 +2300,62 @@ func (e Engine) SetIterationStatus(ctx context.Context, id, status, actorID stri
 	return it, nil
 }
 
+// rejectIterationValidation records a failed validation of it as iteration.validation.checked
+// with result false, when actorID may set the iteration's status, and returns cause.
+// Recording is best effort.
+func (e Engine) rejectIterationValidation(ctx context.Context, it domain.Iteration, requiredKind, actorID string, cause error) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return cause
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, it.ProjectID, actorID, "iteration.set_status"); err != nil {
+		return cause
+	}
+	checked := events.EventPayload{
+		"required_kind": requiredKind,
+		"result":        false,
+		"reason":        cause.Error(),
+	}
+	if len(it.KeyResults) > 0 {
+		checked["key_results"] = it.KeyResults
+	}
+	if err := e.Events.Append(ctx, tx, "iteration.validation.checked", it.ProjectID, "iteration", it.ID, actorID, checked); err == nil {
+		_ = tx.Commit()
+	}
+	return cause
+}
+
 func (e Engine) iterationValidated(ctx context.Context, iterationID, kind string) (bool, error) {
 	if kind == "" {
 		return true, nil
 	}
-	rows, err := e.DB.QueryContext(ctx, `SELECT 1 FROM attestations WHERE entity_kind='iteration' AND entity_id=? AND kind=? LIMIT 1`, iterationID, kind)
-	if err != nil {
-		return false, err
+	for _, k := range e.satisfyingKinds(kind) {
+		var one int
+		err := e.DB.QueryRowContext(ctx, `SELECT 1 FROM attestations WHERE entity_kind='iteration' AND entity_id=? AND kind=? LIMIT 1`, iterationID, k).Scan(&one)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		}
 	}
-	defer rows.Close()
-	return rows.Next(), nil
+	return false, nil
 }
 
+// DecisionStatuses lists the lifecycle states a decision may be recorded in.
+var DecisionStatuses = []string{"proposed", "accepted", "superseded", "rejected"}
+
 func (e Engine) CreateDecision(ctx context.Context, d domain.Decision, actorID string) (domain.Decision, error) {
 	if e.Config == nil {
 		return d, errors.New("config not loaded")
 	}
+	if d.Status == "" {
+		d.Status = "accepted"
+	}
+	if !slices.Contains(DecisionStatuses, d.Status) {
+		return d, fmt.Errorf("invalid decision status %q: use one of %s", d.Status, strings.Join(DecisionStatuses, ", "))
+	}
 	if _, err := e.Repo.GetProject(ctx, d.ProjectID); err != nil {
 		return d, err
 	}
@@ -936,9 +2371,13 @@ func (e Engine) CreateDecision(ctx context.Context, d domain.Decision, actorID s
 	if err := e.Repo.InsertDecisionTx(ctx, tx, d); err != nil {
 		return d, err
 	}
+	d.ContentHash = canon.DecisionHash(d)
 	if err := e.Events.Append(ctx, tx, "decision.created", d.ProjectID, "decision", d.ID, actorID, events.EventPayload{"title": d.Title}); err != nil {
 		return d, err
 	}
+	if err := e.recordMentions(ctx, tx, d.ProjectID, "decision", d.ID, actorID, events.EventPayload{"source": "decision"}, d.Title, d.Decision, d.ContextJSON, d.RationaleJSON, d.AlternativesJSON); err != nil {
+		return d, err
+	}
 	if err := tx.Commit(); err != nil {
 		return d, err
 	}
@@ -947,46 +2386,163 @@ func (e Engine) CreateDecision(ctx context.Context, d domain.Decision, actorID s
 
 // AddAttestation inserts attestation and event.
 func (e Engine) AddAttestation(ctx context.Context, att domain.Attestation, actorID string) (domain.Attestation, error) {
+	att, blobRef, err := e.prepareAttestation(ctx, att, actorID)
+	if err != nil {
+		return att, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return att, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, att.ProjectID, actorID, "attestation.add"); err != nil {
+		return att, err
+	}
+	if att, err = e.addAttestationTx(ctx, tx, att, actorID, blobRef, nil); err != nil {
+		return att, err
+	}
+	if err := tx.Commit(); err != nil {
+		return att, err
+	}
+	return att, nil
+}
+
+// prepareAttestation validates att outside any transaction and offloads a large payload, so
+// blob I/O never holds the sqlite writer. Permissions are checked before a blob is written.
+func (e Engine) prepareAttestation(ctx context.Context, att domain.Attestation, actorID string) (domain.Attestation, *BlobRef, error) {
 	if e.Config == nil {
-		return att, errors.New("config not loaded")
+		return att, nil, errors.New("config not loaded")
 	}
 	if att.EntityKind == "" || att.EntityID == "" || att.Kind == "" {
-		return att, errors.New("entity-kind, entity-id and kind required")
+		return att, nil, errors.New("entity-kind, entity-id and kind required")
+	}
+	if att.ActorID == "" {
+		att.ActorID = actorID
 	}
-	att.ID = uuid.New().String()
 	if att.TS == "" {
 		att.TS = e.now().UTC().Format(time.RFC3339)
 	}
 	if att.ProjectID == "" {
-		return att, errors.New("project required")
+		return att, nil, errors.New("project required")
 	}
 	if _, err := e.Repo.GetProject(ctx, att.ProjectID); err != nil {
-		return att, err
+		return att, nil, err
 	}
-	tx, err := e.DB.BeginTx(ctx, nil)
+	if e.Config.RejectsDeprecatedKinds() {
+		if msg := e.DeprecatedKindWarning(att.Kind); msg != "" {
+			return att, nil, fmt.Errorf("invalid attestation kind: %s", msg)
+		}
+	}
+	if hasBlobMarker(att.PayloadJSON) {
+		return att, nil, errors.New("invalid attestation payload: $blob is reserved for offloaded payloads")
+	}
+	if err := checkPayloadSize(e.Config.Payloads, "attestation payload", att.PayloadJSON); err != nil {
+		return att, nil, err
+	}
+	if e.offloads(att.PayloadJSON) {
+		perms := []string{"attestation.add"}
+		if att.ActorID != actorID {
+			perms = append(perms, "attestation.on_behalf")
+		}
+		if err := e.precheckPermissions(ctx, att.ProjectID, actorID, perms...); err != nil {
+			return att, nil, err
+		}
+	}
+	payload, blobRef, err := e.offloadPayload(ctx, att.PayloadJSON)
 	if err != nil {
+		return att, nil, err
+	}
+	att.PayloadJSON = payload
+	return att, blobRef, nil
+}
+
+// DeprecatedKindWarning describes why new attestations of kind should use another kind; it
+// is empty unless the catalog marks kind deprecated.
+func (e Engine) DeprecatedKindWarning(kind string) string {
+	if e.Config == nil {
+		return ""
+	}
+	k, ok := e.Config.DeprecatedKind(kind)
+	if !ok {
+		return ""
+	}
+	if k.ReplacedBy == "" {
+		return fmt.Sprintf("attestation kind %s is deprecated", kind)
+	}
+	return fmt.Sprintf("attestation kind %s is deprecated, use %s", kind, k.ReplacedBy)
+}
+
+// addAttestationTx checks authority for a prepared attestation, inserts it and records the
+// event, with extra added to its payload. An attestation attributed to another actor than
+// actorID needs attestation.on_behalf, and the attributed actor must still hold authority
+// for the kind.
+func (e Engine) addAttestationTx(ctx context.Context, tx *sql.Tx, att domain.Attestation, actorID string, blobRef *BlobRef, extra events.EventPayload) (domain.Attestation, error) {
+	if att.ActorID != actorID {
+		if err := e.requirePermission(ctx, tx, att.ProjectID, actorID, "attestation.on_behalf"); err != nil {
+			return att, err
+		}
+	}
+	if err := e.requireAttestationAuthority(ctx, tx, att.ProjectID, att.ActorID, att.Kind); err != nil {
 		return att, err
 	}
-	defer tx.Rollback()
-	if err := e.requirePermission(ctx, tx, att.ProjectID, actorID, "attestation.add"); err != nil {
+	if err := e.checkArtifactCitations(ctx, tx, att.ProjectID, att.PayloadJSON); err != nil {
 		return att, err
 	}
-	if err := e.requireAttestationAuthority(ctx, tx, att.ProjectID, actorID, att.Kind); err != nil {
+	if att.EntityKind == "attestation" {
+		target, err := e.Repo.GetAttestationTx(ctx, tx, att.EntityID)
+		if err != nil {
+			return att, err
+		}
+		if target.ProjectID != att.ProjectID {
+			return att, fmt.Errorf("invalid countersign: attestation %s belongs to project %s", target.ID, target.ProjectID)
+		}
+		if target.ActorID == att.ActorID {
+			return att, errors.New("invalid countersign: actors cannot countersign their own attestation")
+		}
+	}
+	var err error
+	att.ID, err = e.assignID(ctx, tx, e.Config.IDs.Attestations, att.ProjectID, "attestations", att.ID, nil)
+	if err != nil {
 		return att, err
 	}
 	if err := e.Repo.InsertAttestationTx(ctx, tx, att); err != nil {
 		return att, err
 	}
-	if err := e.Events.Append(ctx, tx, "attestation.added", att.ProjectID, att.EntityKind, att.EntityID, actorID, events.EventPayload{
+	att.ContentHash = canon.AttestationHash(att)
+	evtPayload := events.EventPayload{
 		"kind":           att.Kind,
 		"entity":         att.EntityID,
 		"attestation_id": att.ID,
-	}); err != nil {
-		return att, err
 	}
-	if err := tx.Commit(); err != nil {
+	if blobRef != nil {
+		evtPayload["payload_blob"] = blobRef.Blob
+	}
+	if att.ActorID != actorID {
+		evtPayload["on_behalf_of"] = att.ActorID
+	}
+	maps.Copy(evtPayload, extra)
+	if k, ok := e.Config.DeprecatedKind(att.Kind); ok {
+		evtPayload["deprecated_kind"] = true
+		if k.ReplacedBy != "" {
+			evtPayload["replaced_by"] = k.ReplacedBy
+		}
+	}
+	if err := e.Events.Append(ctx, tx, "attestation.added", att.ProjectID, att.EntityKind, att.EntityID, actorID, evtPayload); err != nil {
 		return att, err
 	}
+	if att.EntityKind == "task" {
+		if err := e.rescoreTaskTx(ctx, tx, att.EntityID, actorID); err != nil {
+			return att, err
+		}
+		if err := e.rollupTx(ctx, tx, &att.EntityID, actorID); err != nil {
+			return att, err
+		}
+	}
+	if att.EntityKind == "iteration" && blobRef == nil {
+		if err := e.deriveKeyResultsTx(ctx, tx, att, actorID); err != nil {
+			return att, err
+		}
+	}
 	return att, nil
 }
 
@@ -999,6 +2555,44 @@ func (e Engine) ensureTaskPolicySatisfied(ctx context.Context, t domain.Task) (b
 	return e.isTaskValidationSatisfied(ctx, tx, t, "")
 }
 
+// RecordDailyStats snapshots today's (UTC) metrics for a project, replacing any earlier
+// snapshot taken the same day. The first snapshot after midnight also finalises the
+// previous day, so activity after its last snapshot still counts; older days are left
+// untouched so the series stays stable.
+func (e Engine) RecordDailyStats(ctx context.Context, projectID string) (domain.StatsSnapshot, error) {
+	now := e.now().UTC()
+	if err := e.finalizeDailyStats(ctx, projectID, now.AddDate(0, 0, -1).Format(time.DateOnly), now); err != nil {
+		return domain.StatsSnapshot{}, err
+	}
+	s, err := e.Repo.ComputeDailyStats(ctx, projectID, now.Format(time.DateOnly))
+	if err != nil {
+		return s, err
+	}
+	s.RecordedAt = now.Format(time.RFC3339)
+	if err := e.Repo.UpsertStatsSnapshot(ctx, s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// finalizeDailyStats recounts the completions, attestations and lead time of day's snapshot
+// over the whole day when it was last recorded during that day. Open and done counts are
+// point-in-time values, so they keep the last ones seen that day.
+func (e Engine) finalizeDailyStats(ctx context.Context, projectID, day string, now time.Time) error {
+	prev, err := e.Repo.ListStatsSnapshots(ctx, projectID, day, day)
+	if err != nil || len(prev) == 0 || !strings.HasPrefix(prev[0].RecordedAt, day) {
+		return err
+	}
+	full, err := e.Repo.ComputeDailyStats(ctx, projectID, day)
+	if err != nil {
+		return err
+	}
+	s := prev[0]
+	s.TasksCompleted, s.AttestationsIssued, s.LeadTimeSeconds = full.TasksCompleted, full.AttestationsIssued, full.LeadTimeSeconds
+	s.RecordedAt = now.Format(time.RFC3339)
+	return e.Repo.UpsertStatsSnapshot(ctx, s)
+}
+
 // RBAC operations
 
 type WhoAmI struct {
@@ -1031,6 +2625,20 @@ func (e Engine) WhoAmI(ctx context.Context, projectID, actorID string) (WhoAmI,
 }
 
 func (e Engine) GrantRole(ctx context.Context, projectID, actorID, targetActor, roleID string) error {
+	return e.GrantRoleUntil(ctx, projectID, actorID, targetActor, roleID, "")
+}
+
+// GrantRoleUntil grants a role that lapses at expiresAt (RFC3339); an empty expiresAt grants
+// it permanently. Granting an already held role replaces its expiry.
+func (e Engine) GrantRoleUntil(ctx context.Context, projectID, actorID, targetActor, roleID, expiresAt string) error {
+	payload := events.EventPayload{"actor_id": targetActor, "role_id": roleID}
+	expiresAt, err := e.grantExpiry(expiresAt)
+	if err != nil {
+		return err
+	}
+	if expiresAt != "" {
+		payload["expires_at"] = expiresAt
+	}
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -1042,15 +2650,65 @@ func (e Engine) GrantRole(ctx context.Context, projectID, actorID, targetActor,
 	if err := e.ensureActor(ctx, tx, targetActor); err != nil {
 		return err
 	}
-	if err := e.Repo.AssignRole(ctx, tx, projectID, targetActor, roleID); err != nil {
+	if err := e.Repo.AssignRoleUntil(ctx, tx, projectID, targetActor, roleID, expiresAt); err != nil {
 		return err
 	}
-	if err := e.Events.Append(ctx, tx, "rbac.role_granted", projectID, "rbac", projectID, actorID, events.EventPayload{"actor_id": targetActor, "role_id": roleID}); err != nil {
+	if err := e.Events.Append(ctx, tx, "rbac.role_granted", projectID, "rbac", projectID, actorID, payload); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
+// grantExpiry normalizes the expiry of a role grant to UTC; it must be in the future.
+func (e Engine) grantExpiry(expiresAt string) (string, error) {
+	if expiresAt == "" {
+		return "", nil
+	}
+	ts, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return "", fmt.Errorf("invalid expires_at %q: expected RFC3339", expiresAt)
+	}
+	if !ts.After(e.now()) {
+		return "", errors.New("invalid expires_at: must be in the future")
+	}
+	return ts.UTC().Format(time.RFC3339), nil
+}
+
+// ExpireRoleGrants removes actor and team grants whose expiry has passed and records an
+// rbac.role_expired event for each. Permission checks already ignore expired grants; this
+// sweep makes the expiry visible in the event log.
+func (e Engine) ExpireRoleGrants(ctx context.Context) ([]domain.RoleGrant, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	grants, err := e.Repo.ExpiredRoleGrantsTx(ctx, tx, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		payload := events.EventPayload{"role_id": g.RoleID, "expires_at": *g.ExpiresAt}
+		if g.TeamID != nil {
+			err = e.Repo.RevokeTeamRole(ctx, tx, g.ProjectID, *g.TeamID, g.RoleID)
+			payload["team_id"] = *g.TeamID
+		} else {
+			err = e.Repo.RevokeRole(ctx, tx, g.ProjectID, g.ActorID, g.RoleID)
+			payload["actor_id"] = g.ActorID
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := e.Events.Append(ctx, tx, "rbac.role_expired", g.ProjectID, "rbac", g.ProjectID, systemActorID, payload); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
 func (e Engine) RevokeRole(ctx context.Context, projectID, actorID, targetActor, roleID string) error {
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -1069,7 +2727,13 @@ func (e Engine) RevokeRole(ctx context.Context, projectID, actorID, targetActor,
 	return tx.Commit()
 }
 
+// AllowAttestationRole lets a role record kind, which may be a pattern such as ci.*. An
+// authority for an exact kind takes precedence over patterns, and longer patterns over
+// shorter ones.
 func (e Engine) AllowAttestationRole(ctx context.Context, projectID, actorID, kind, roleID string) error {
+	if err := config.ValidAuthorityKind(kind); err != nil {
+		return err
+	}
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -1148,31 +2812,58 @@ func (e Engine) seedRBAC(ctx context.Context, tx *sql.Tx, projectID, actorID str
 		return err
 	}
 	permDescs := map[string]string{
-		"project.create":       "Create project",
-		"project.list":         "List projects",
-		"project.read":         "Read project",
-		"project.update":       "Update project",
-		"project.delete":       "Delete project",
-		"project.config.read":  "Read project config",
-		"project.status.read":  "Read project status",
-		"project.events.read":  "Read project events",
-		"task.create":          "Create task",
-		"task.list":            "List tasks",
-		"task.read":            "Read task",
-		"task.tree":            "Read task tree",
-		"task.validation.read": "Read task validation",
-		"task.update":          "Update task",
-		"task.done":            "Complete task",
-		"task.claim":           "Claim task",
-		"task.release":         "Release task",
-		"iteration.create":     "Create iteration",
-		"iteration.list":       "List iterations",
-		"iteration.set_status": "Update iteration status",
-		"decision.create":      "Create decision",
-		"attestation.add":      "Add attestation",
-		"attestation.list":     "List attestations",
-		"rbac.manage":          "Manage RBAC",
-		"force.use":            "Use force flag",
+		"project.create":            "Create project",
+		"org.create":                "Create organizations",
+		"project.list":              "List projects",
+		"project.read":              "Read project",
+		"project.update":            "Update project",
+		"project.delete":            "Delete project",
+		"project.config.read":       "Read project config",
+		"project.status.read":       "Read project status",
+		"project.events.read":       "Read project events",
+		"task.create":               "Create task",
+		"task.list":                 "List tasks",
+		"task.read":                 "Read task",
+		"task.tree":                 "Read task tree",
+		"task.validation.read":      "Read task validation",
+		"task.update":               "Update task",
+		"task.done":                 "Complete task",
+		"task.claim":                "Claim task",
+		"task.release":              "Release task",
+		"task.status.override":      "Set the status of a parent task that rolls up from its subtasks",
+		"task.approve":              "Approve or reject task completions",
+		"iteration.create":          "Create iteration",
+		"iteration.list":            "List iterations",
+		"iteration.set_status":      "Update iteration status",
+		"iteration.update":          "Update iteration key results",
+		"iteration.carry_over":      "Carry unfinished tasks over to another iteration",
+		"iteration.freeze":          "Freeze and unfreeze running iterations",
+		"iteration.freeze.override": "Change the scope of a frozen iteration, with a recorded reason",
+		"decision.create":           "Create decision",
+		"decision.list":             "List decisions",
+		"decision.read":             "Read decision",
+		"attestation.add":           "Add attestation",
+		"attestation.on_behalf":     "Attribute attestations to another actor",
+		"attestation.list":          "List attestations",
+		"artifact.upload":           "Upload artifact",
+		"artifact.read":             "Read artifacts",
+		"rbac.manage":               "Manage RBAC",
+		"rbac.read":                 "List project members",
+		"force.use":                 "Use force flag",
+		"task.waive":                "Waive task validation requirement",
+		"view.read":                 "List and run saved views",
+		"view.manage":               "Save and delete views",
+		"capability.manage":         "Set other actors' capabilities",
+		"db.maintain":               "Vacuum and check the workspace database",
+		"compliance.read":           "Generate compliance control reports",
+		"usage.read":                "Read per-actor API usage",
+		"digest.read":               "Read daily project digests",
+		"digest.generate":           "Generate daily project digests",
+		"lease.plan.read":           "Read the plans declared with task leases",
+		"escalation.read":           "List escalation rules",
+		"escalation.manage":         "Create, change and delete escalation rules",
+		"notification.read":         "Read and mark your own notifications",
+		"secret.manage":             "Rotate, revoke and list project secrets",
 	}
 	for perm, desc := range permDescs {
 		if err := e.Repo.InsertPermission(ctx, tx, perm, desc); err != nil {
@@ -1201,17 +2892,25 @@ func (e Engine) seedRBAC(ctx context.Context, tx *sql.Tx, projectID, actorID str
 		"task.tree",
 		"task.validation.read",
 		"iteration.list",
+		"decision.list",
+		"decision.read",
 		"attestation.list",
+		"artifact.read",
+		"view.read",
+		"compliance.read",
+		"digest.read",
+		"escalation.read",
+		"notification.read",
 	}
 	rolePerms := map[string][]string{
 		"owner":    keys(permDescs),
-		"pm":       append(append([]string{}, readPerms...), "task.create", "task.update", "iteration.create", "iteration.set_status", "decision.create", "attestation.add"),
-		"po":       append(append([]string{}, readPerms...), "task.create", "task.update", "attestation.add"),
-		"dev":      append(append([]string{}, readPerms...), "task.claim", "task.update", "task.done", "task.release"),
-		"reviewer": append(append([]string{}, readPerms...), "attestation.add"),
-		"qa":       append(append([]string{}, readPerms...), "attestation.add"),
-		"security": append(append([]string{}, readPerms...), "attestation.add"),
-		"release":  append(append([]string{}, readPerms...), "iteration.set_status", "attestation.add", "force.use"),
+		"pm":       append(append([]string{}, readPerms...), "task.create", "task.update", "task.status.override", "iteration.create", "iteration.update", "iteration.set_status", "iteration.carry_over", "iteration.freeze", "decision.create", "attestation.add", "artifact.upload", "view.manage", "usage.read", "digest.generate", "lease.plan.read", "task.approve", "escalation.manage"),
+		"po":       append(append([]string{}, readPerms...), "task.create", "task.update", "attestation.add", "artifact.upload", "view.manage"),
+		"dev":      append(append([]string{}, readPerms...), "task.claim", "task.update", "task.done", "task.release", "artifact.upload", "view.manage"),
+		"reviewer": append(append([]string{}, readPerms...), "attestation.add", "artifact.upload", "task.approve"),
+		"qa":       append(append([]string{}, readPerms...), "attestation.add", "artifact.upload"),
+		"security": append(append([]string{}, readPerms...), "attestation.add", "artifact.upload"),
+		"release":  append(append([]string{}, readPerms...), "iteration.set_status", "attestation.add", "force.use", "task.waive", "artifact.upload"),
 		"observer": append([]string{}, readPerms...),
 	}
 	if cfg != nil && len(cfg.RBAC.Roles) > 0 {
@@ -1245,9 +2944,13 @@ func (e Engine) seedRBAC(ctx context.Context, tx *sql.Tx, projectID, actorID str
 	}
 	authorities := map[string][]string{
 		"ci.passed":          {"dev", "owner", "pm"},
+		"tests.passed":       {"dev", "owner", "pm"},
+		"tests.failed":       {"dev", "owner", "pm"},
+		"coverage.report":    {"dev", "owner", "pm"},
 		"review.approved":    {"reviewer", "owner"},
 		"acceptance.passed":  {"qa", "owner", "po"},
 		"security.ok":        {"security", "owner"},
+		"security.scan":      {"security", "dev", "owner"},
 		"iteration.approved": {"release", "owner"},
 		"init.check":         {"owner"},
 	}
@@ -2,6 +2,10 @@ package engine_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,7 +13,10 @@ import (
 	"workline/internal/db"
 	"workline/internal/domain"
 	"workline/internal/engine"
+	"workline/internal/engine/auth"
+	"workline/internal/manifest"
 	"workline/internal/migrate"
+	"workline/internal/repo"
 )
 
 type testEnv struct {
@@ -70,6 +77,57 @@ func TestTaskStatusTransitions(t *testing.T) {
 	}
 }
 
+func TestConfiguredTaskTransitions(t *testing.T) {
+	env := newTestEnv(t)
+	env.Engine.Config.Transitions.Task = map[string][]string{
+		"planned":     {"in_progress", "canceled"},
+		"in_progress": {"review", "done", "canceled"},
+		"review":      {"done", "rejected"},
+		"rejected":    {"planned"},
+	}
+	env.Engine.Config.Transitions.Types = map[string]map[string][]string{
+		"feature": {
+			"planned":     {"in_progress"},
+			"in_progress": {"review"},
+			"review":      {"done", "rejected"},
+		},
+	}
+	if err := env.Engine.Config.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	chore, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Type: "technical", Title: "chore", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, chore.ID, "tester", 900); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: chore.ID, Status: "done", ActorID: "tester"}); err == nil || !strings.Contains(err.Error(), "invalid task status transition") {
+		t.Fatalf("expected planned -> done to be forbidden, got %v", err)
+	}
+	feature, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Type: "feature", Title: "feature", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, feature.ID, "tester", 900); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: feature.ID, Status: "in_progress", ActorID: "tester"}); err != nil {
+		t.Fatalf("to in_progress: %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: feature.ID, Status: "done", ActorID: "tester"}); err == nil || !strings.Contains(err.Error(), "invalid task status transition") {
+		t.Fatalf("expected feature to require review before done, got %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: feature.ID, Status: "review", ActorID: "tester"}); err != nil {
+		t.Fatalf("to review: %v", err)
+	}
+
+	env.Engine.Config.Transitions.Task["planned"] = []string{"shipped"}
+	if err := env.Engine.Config.Validate(); err == nil {
+		t.Fatalf("expected unknown status to fail validation")
+	}
+}
+
 func TestDependencyGating(t *testing.T) {
 	env := newTestEnv(t)
 	dep, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "dep", ActorID: "tester"})
@@ -125,6 +183,117 @@ func TestSubtaskGating(t *testing.T) {
 	}
 }
 
+func TestMoveTaskReordersSiblings(t *testing.T) {
+	env := newTestEnv(t)
+	parent, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "epic", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, title := range []string{"a", "b", "c"} {
+		child, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester", ParentID: parent.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if child.Rank != len(ids)+1 {
+			t.Fatalf("expected rank %d for %s, got %d", len(ids)+1, title, child.Rank)
+		}
+		ids = append(ids, child.ID)
+	}
+	moved, err := env.Engine.MoveTask(env.Ctx, engine.TaskMoveOptions{ID: ids[2], BeforeID: ids[0], ActorID: "tester"})
+	if err != nil {
+		t.Fatalf("move before: %v", err)
+	}
+	if moved.Rank != 1 {
+		t.Fatalf("expected moved task rank 1, got %d", moved.Rank)
+	}
+	if _, err := env.Engine.MoveTask(env.Ctx, engine.TaskMoveOptions{ID: ids[2], AfterID: ids[1], ActorID: "tester"}); err != nil {
+		t.Fatalf("move after: %v", err)
+	}
+	want := map[string]int{ids[0]: 1, ids[1]: 2, ids[2]: 3}
+	for id, rank := range want {
+		got, err := env.Engine.Repo.GetTask(env.Ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Rank != rank {
+			t.Fatalf("expected %s at rank %d, got %d", id, rank, got.Rank)
+		}
+	}
+	if _, err := env.Engine.MoveTask(env.Ctx, engine.TaskMoveOptions{ID: ids[0], BeforeID: parent.ID, ActorID: "tester"}); err == nil {
+		t.Fatalf("expected move relative to non-sibling to fail")
+	}
+
+}
+
+func TestReadyQueueRanksSiblings(t *testing.T) {
+	env := newTestEnv(t)
+	// Every task is created in the same second, so the queue falls back to ID order.
+	create := func(id, parentID string) {
+		t.Helper()
+		if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ID: id, ProjectID: "proj-1", Title: id, ActorID: "tester", ParentID: parentID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create("epic-1", "")
+	create("epic-2", "")
+	for _, id := range []string{"e1-a", "e1-b", "e2-a", "e2-b"} {
+		create(id, "epic-"+id[1:2])
+	}
+	for _, move := range [][2]string{{"e1-b", "e1-a"}, {"e2-b", "e2-a"}} {
+		if _, err := env.Engine.MoveTask(env.Ctx, engine.TaskMoveOptions{ID: move[0], BeforeID: move[1], ActorID: "tester"}); err != nil {
+			t.Fatalf("move %s: %v", move[0], err)
+		}
+	}
+	ready, err := env.Engine.ReadyTasks(env.Ctx, "proj-1", "tester")
+	if err != nil {
+		t.Fatalf("ready: %v", err)
+	}
+	var queue []string
+	for _, task := range ready {
+		queue = append(queue, task.ID)
+	}
+	// Each epic's children follow their rank; the epics do not interleave.
+	if want := []string{"e1-b", "e1-a", "e2-b", "e2-a", "epic-1", "epic-2"}; !slices.Equal(queue, want) {
+		t.Fatalf("expected ready queue %v, got %v", want, queue)
+	}
+}
+
+func TestReparentedTaskRanksAfterNewSiblings(t *testing.T) {
+	env := newTestEnv(t)
+	var ids []string
+	for _, title := range []string{"epic-1", "epic-2"} {
+		epic, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, epic.ID)
+	}
+	for _, title := range []string{"a", "b"} {
+		if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester", ParentID: ids[1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "first", ActorID: "tester", ParentID: ids[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Rank != 1 {
+		t.Fatalf("expected rank 1 under the first epic, got %d", first.Rank)
+	}
+	moved, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: first.ID, ParentProvided: true, SetParent: &ids[1], ActorID: "tester"})
+	if err != nil {
+		t.Fatalf("reparent: %v", err)
+	}
+	stored, err := env.Engine.Repo.GetTask(env.Ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Rank != 3 || stored.Rank != 3 {
+		t.Fatalf("expected rank 3 after the new siblings, got %d (stored %d)", moved.Rank, stored.Rank)
+	}
+}
+
 func TestLeaseClaimRelease(t *testing.T) {
 	env := newTestEnv(t)
 	env.Engine.Now = time.Now
@@ -158,157 +327,1649 @@ func TestLeaseClaimRelease(t *testing.T) {
 	}
 }
 
-func TestPolicyEvaluation(t *testing.T) {
+func TestLeaseTransfer(t *testing.T) {
 	env := newTestEnv(t)
-	tk, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
-		ProjectID:      "proj-1",
-		Title:          "policy",
-		ActorID:        "tester",
-		RequiredKinds:  []string{"ci.passed", "review.approved"},
-		PolicyOverride: true,
-	})
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "handoff", ActorID: "tester"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	// try move to done without attestations should fail
-	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "in_progress", ActorID: "tester", Force: true})
-	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "review", ActorID: "tester", Force: true})
-	_, err = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "done", ActorID: "tester"})
-	if err == nil {
-		t.Fatalf("expected validation failure")
+	if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "tester", 900); err != nil {
+		t.Fatalf("claim: %v", err)
 	}
-	// add attestations
-	_, err = env.Engine.AddAttestation(env.Ctx, domain.Attestation{
-		ProjectID:  "proj-1",
-		EntityKind: "task",
-		EntityID:   tk.ID,
-		Kind:       "ci.passed",
-	}, "tester")
+	// target without task.claim is rejected
+	if _, err := env.Engine.TransferLease(env.Ctx, engine.LeaseTransferOptions{TaskID: task.ID, ActorID: "tester", ToActorID: "dev-2"}); err == nil {
+		t.Fatalf("expected transfer to unauthorized actor to fail")
+	}
+	for _, actor := range []string{"dev-2", "dev-3"} {
+		if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", actor, "dev"); err != nil {
+			t.Fatalf("grant role: %v", err)
+		}
+	}
+
+	// direct transfer moves ownership immediately
+	lease, err := env.Engine.TransferLease(env.Ctx, engine.LeaseTransferOptions{TaskID: task.ID, ActorID: "tester", ToActorID: "dev-2"})
 	if err != nil {
-		t.Fatalf("att1: %v", err)
+		t.Fatalf("transfer: %v", err)
 	}
-	_, err = env.Engine.AddAttestation(env.Ctx, domain.Attestation{
-		ProjectID:  "proj-1",
-		EntityKind: "task",
-		EntityID:   tk.ID,
-		Kind:       "review.approved",
-	}, "tester")
+	if lease.OwnerID != "dev-2" || lease.PendingOwnerID != nil {
+		t.Fatalf("unexpected lease after transfer: %+v", lease)
+	}
+	if _, err := env.Engine.TransferLease(env.Ctx, engine.LeaseTransferOptions{TaskID: task.ID, ActorID: "tester", ToActorID: "dev-3"}); err == nil {
+		t.Fatalf("expected former owner to lose transfer rights")
+	}
+
+	// consent flow keeps the lease with the owner until accepted
+	lease, err = env.Engine.TransferLease(env.Ctx, engine.LeaseTransferOptions{TaskID: task.ID, ActorID: "dev-2", ToActorID: "dev-3", RequireConsent: true})
 	if err != nil {
-		t.Fatalf("att2: %v", err)
+		t.Fatalf("offer: %v", err)
 	}
-	_, err = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "done", ActorID: "tester", Force: true})
+	if lease.OwnerID != "dev-2" || lease.PendingOwnerID == nil || *lease.PendingOwnerID != "dev-3" {
+		t.Fatalf("unexpected lease after offer: %+v", lease)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "tester", 900); err == nil {
+		t.Fatalf("expected third actor claim to fail while offer pending")
+	}
+	if _, err := env.Engine.AcceptLeaseTransfer(env.Ctx, task.ID, "tester", 0); err == nil {
+		t.Fatalf("expected accept by non-target to fail")
+	}
+	lease, err = env.Engine.AcceptLeaseTransfer(env.Ctx, task.ID, "dev-3", 0)
 	if err != nil {
-		t.Fatalf("expected done after attestations: %v", err)
+		t.Fatalf("accept: %v", err)
+	}
+	if lease.OwnerID != "dev-3" || lease.PendingOwnerID != nil {
+		t.Fatalf("unexpected lease after accept: %+v", lease)
+	}
+
+	// declined offers leave ownership unchanged
+	if _, err := env.Engine.TransferLease(env.Ctx, engine.LeaseTransferOptions{TaskID: task.ID, ActorID: "dev-3", ToActorID: "dev-2", RequireConsent: true}); err != nil {
+		t.Fatalf("offer: %v", err)
+	}
+	lease, err = env.Engine.DeclineLeaseTransfer(env.Ctx, task.ID, "dev-2")
+	if err != nil {
+		t.Fatalf("decline: %v", err)
+	}
+	if lease.OwnerID != "dev-3" || lease.PendingOwnerID != nil {
+		t.Fatalf("unexpected lease after decline: %+v", lease)
+	}
+	if _, err := env.Engine.AcceptLeaseTransfer(env.Ctx, task.ID, "dev-2", 0); err == nil {
+		t.Fatalf("expected accept after decline to fail")
 	}
 }
 
-func TestSeedRBACFromConfig(t *testing.T) {
-	dir := t.TempDir()
-	conn, err := db.Open(db.Config{Workspace: dir})
+func TestPairAssignment(t *testing.T) {
+	env := newTestEnv(t)
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "pair", ActorID: "tester"})
 	if err != nil {
-		t.Fatalf("open db: %v", err)
+		t.Fatal(err)
 	}
-	if err := migrate.Migrate(conn); err != nil {
-		t.Fatalf("migrate: %v", err)
+	for _, actor := range []string{"driver-1", "reviewer-1", "outsider"} {
+		if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", actor, "dev"); err != nil {
+			t.Fatalf("grant role: %v", err)
+		}
 	}
-	cfg := config.Default("proj-1")
-	cfg.RBAC.Roles = map[string]config.RBACRole{
-		"owner": {
-			Description: "Owner",
-			Permissions: []string{"project.read", "task.list"},
-		},
-		"observer": {
-			Description: "Observer",
-			Permissions: []string{"project.read"},
-		},
+	if _, err := env.Engine.AssignTask(env.Ctx, engine.TaskAssignOptions{TaskID: task.ID, AssigneeID: "driver-1", Role: "driver", ActorID: "tester"}); err != nil {
+		t.Fatalf("assign driver: %v", err)
 	}
-	cfg.RBAC.AttestationAuthorities = map[string][]string{
-		"ci.passed": {"owner"},
+	if _, err := env.Engine.AssignTask(env.Ctx, engine.TaskAssignOptions{TaskID: task.ID, AssigneeID: "reviewer-1", Role: "reviewer", ActorID: "tester"}); err != nil {
+		t.Fatalf("assign reviewer: %v", err)
 	}
-	eng := engine.New(conn, cfg)
-	eng.Now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
-	ctx := context.Background()
-	if _, err := eng.InitProject(ctx, "proj-1", "test", "tester"); err != nil {
-		t.Fatalf("init project: %v", err)
+	if _, err := env.Engine.AssignTask(env.Ctx, engine.TaskAssignOptions{TaskID: task.ID, AssigneeID: "driver-1", Role: "reviewer", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected driver to be rejected as reviewer")
 	}
-	tx, err := conn.BeginTx(ctx, nil)
+	assignees, err := env.Engine.Repo.ListTaskAssignees(env.Ctx, task.ID)
+	if err != nil || len(assignees) != 2 {
+		t.Fatalf("expected 2 assignees, got %v (%v)", assignees, err)
+	}
+
+	// only the driver may take the work lease, only the reviewer the review lease
+	if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "outsider", 900); err == nil {
+		t.Fatalf("expected non-driver work claim to fail")
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "driver-1", 900); err != nil {
+		t.Fatalf("driver claim: %v", err)
+	}
+	if _, err := env.Engine.ClaimReviewLease(env.Ctx, task.ID, "driver-1", 900); err == nil {
+		t.Fatalf("expected driver review claim to fail")
+	}
+	if _, err := env.Engine.ClaimReviewLease(env.Ctx, task.ID, "reviewer-1", 900); err != nil {
+		t.Fatalf("reviewer claim: %v", err)
+	}
+
+	// the reviewer cannot move the task until it is in review
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "in_progress", ActorID: "reviewer-1"}); err == nil {
+		t.Fatalf("expected reviewer to need the work lease outside review")
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "review", ActorID: "driver-1"}); err != nil {
+		t.Fatalf("driver to review: %v", err)
+	}
+	updated, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "rejected", ActorID: "reviewer-1"})
+	if err != nil || updated.Status != "rejected" {
+		t.Fatalf("reviewer reject: %v", err)
+	}
+
+	if err := env.Engine.UnassignTask(env.Ctx, engine.TaskAssignOptions{TaskID: task.ID, AssigneeID: "driver-1", Role: "driver", ActorID: "tester"}); err != nil {
+		t.Fatalf("unassign: %v", err)
+	}
+	if err := env.Engine.ReleaseLease(env.Ctx, task.ID, "driver-1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "outsider", 900); err != nil {
+		t.Fatalf("expected claim without drivers to succeed: %v", err)
+	}
+}
+
+func TestCountersignedRequirement(t *testing.T) {
+	env := newTestEnv(t)
+	req := "security.ok+review.approved"
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID: "proj-1", Title: "secure", ActorID: "tester",
+		RequiredKinds: []string{req}, PolicyOverride: true,
+	})
 	if err != nil {
-		t.Fatalf("begin tx: %v", err)
+		t.Fatal(err)
 	}
-	defer tx.Rollback()
-	perms, err := eng.Auth.ActorPermissions(ctx, tx, "proj-1", "tester")
+	if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", "sec-1", "security"); err != nil {
+		t.Fatalf("grant role: %v", err)
+	}
+	secOK, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "security.ok"}, "sec-1")
 	if err != nil {
-		t.Fatalf("perms: %v", err)
+		t.Fatalf("attest: %v", err)
 	}
-	got := map[string]bool{}
-	for _, p := range perms {
-		got[p] = true
+	present, err := env.Engine.PresentRequirements(env.Ctx, task.ID, []string{req, "security.ok"})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if len(got) != 2 || !got["project.read"] || !got["task.list"] {
-		t.Fatalf("unexpected permissions: %v", perms)
+	if present[req] || !present["security.ok"] {
+		t.Fatalf("expected only the plain kind to be present before countersign: %v", present)
 	}
-	ok, err := eng.Auth.ActorCanAttest(ctx, tx, "proj-1", "tester", "ci.passed")
+
+	own, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "ci.passed"}, "tester")
 	if err != nil {
-		t.Fatalf("attest check: %v", err)
+		t.Fatalf("attest: %v", err)
 	}
-	if !ok {
-		t.Fatalf("expected attestation allowed")
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "attestation", EntityID: own.ID, Kind: "review.approved"}, "tester"); err == nil {
+		t.Fatalf("expected self-countersign to fail")
 	}
-	ok, err = eng.Auth.ActorCanAttest(ctx, tx, "proj-1", "tester", "review.approved")
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "attestation", EntityID: secOK.ID, Kind: "review.approved"}, "tester"); err != nil {
+		t.Fatalf("countersign: %v", err)
+	}
+	present, err = env.Engine.PresentRequirements(env.Ctx, task.ID, []string{req})
 	if err != nil {
-		t.Fatalf("attest check: %v", err)
+		t.Fatal(err)
 	}
-	if ok {
-		t.Fatalf("expected attestation denied")
+	if !present[req] {
+		t.Fatalf("expected countersigned requirement to be present")
 	}
 }
 
-func TestAttestationEventLogged(t *testing.T) {
+func TestPayloadPredicateRequirement(t *testing.T) {
 	env := newTestEnv(t)
-	att, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{
-		ProjectID:  "proj-1",
-		EntityKind: "project",
-		EntityID:   "proj-1",
-		Kind:       "init.check",
-	}, "tester")
+	req := "security.scan[payload.critical == 0 && payload.high <= 1]"
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID: "proj-1", Title: "scanned", ActorID: "tester",
+		RequiredKinds: []string{req}, PolicyOverride: true,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if att.ID == "" {
-		t.Fatalf("expected attestation id")
+	attest := func(payload string) {
+		t.Helper()
+		if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "security.scan", PayloadJSON: payload}, "tester"); err != nil {
+			t.Fatalf("attest: %v", err)
+		}
 	}
-	rows, err := env.Engine.DB.QueryContext(env.Ctx, `SELECT count(*) FROM events WHERE type='attestation.added' AND entity_kind=? AND entity_id=?`, att.EntityKind, att.EntityID)
+	attest(`{"critical":1,"high":0}`)
+	present, err := env.Engine.PresentRequirements(env.Ctx, task.ID, []string{req, "security.scan"})
 	if err != nil {
-		t.Fatalf("query events: %v", err)
+		t.Fatal(err)
 	}
-	defer rows.Close()
-	var count int
-	rows.Next()
-	rows.Scan(&count)
-	if count == 0 {
-		t.Fatalf("expected event rows")
+	if present[req] || !present["security.scan"] {
+		t.Fatalf("expected a scan with critical findings to miss the predicate: %v", present)
+	}
+	backlog, err := env.Engine.AttestationRequiredBy(env.Ctx, "proj-1", "security.scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlog.Tasks) != 1 || backlog.Tasks[0].TaskID != task.ID || backlog.Tasks[0].Missing[0] != req {
+		t.Fatalf("expected the predicated entry in the kind's backlog: %+v", backlog.Tasks)
+	}
+	attest(`{"critical":0,"high":1}`)
+	present, err = env.Engine.PresentRequirements(env.Ctx, task.ID, []string{req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !present[req] {
+		t.Fatalf("expected a clean scan to meet the predicate")
+	}
+
+	cfg := config.Default("proj-1")
+	cfg.Policies.Presets["gated"] = config.PolicyPreset{Require: []string{"security.scan[payload.critical ==]"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid payload predicate") {
+		t.Fatalf("expected an invalid predicate to be rejected, got %v", err)
+	}
+	cfg.Policies.Presets["gated"] = config.PolicyPreset{Require: []string{"security.scan[payload.critical == 0]+review.approved"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a countersigned predicate to validate: %v", err)
 	}
 }
 
-func TestEventAppendOnStateChanges(t *testing.T) {
+func TestWherePredicateRequirement(t *testing.T) {
 	env := newTestEnv(t)
-	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "evented", ActorID: "tester"})
+	req := "coverage.report where payload.percent >= 80"
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID: "proj-1", Title: "covered", ActorID: "tester",
+		RequiredKinds: []string{req}, PolicyOverride: true,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "in_progress", ActorID: "tester", Force: true})
-	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "review", ActorID: "tester", Force: true})
-	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "done", ActorID: "tester", Force: true})
-	rows, err := env.Engine.DB.QueryContext(env.Ctx, `SELECT type FROM events WHERE entity_id=?`, task.ID)
+	for _, c := range []struct {
+		payload string
+		met     bool
+	}{
+		{`{"percent":72.4}`, false},
+		{`not json`, false},
+		{`{"percent":85.5}`, true},
+	} {
+		if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "coverage.report", PayloadJSON: c.payload}, "tester"); err != nil {
+			t.Fatalf("attest %s: %v", c.payload, err)
+		}
+		present, err := env.Engine.PresentRequirements(env.Ctx, task.ID, []string{req})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if present[req] != c.met {
+			t.Fatalf("after %s: expected met=%v", c.payload, c.met)
+		}
+	}
+
+	bad := []string{"coverage.report where payload.percent >="}
+	if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "typo", ActorID: "tester", RequiredKinds: bad, PolicyOverride: true}); err == nil || !strings.Contains(err.Error(), "invalid requirement") {
+		t.Fatalf("expected a malformed predicate to be rejected on create, got %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, ActorID: "tester", RequiredKinds: bad, RequiredKindsSet: true}); err == nil || !strings.Contains(err.Error(), "invalid requirement") {
+		t.Fatalf("expected a malformed predicate to be rejected on update, got %v", err)
+	}
+	if r := config.ParseRequirement("security.ok+security.countersign where payload.scope == 'full'"); r.Kind != "security.ok" || r.Countersign != "security.countersign" || r.Predicate != "payload.scope == 'full'" {
+		t.Fatalf("unexpected parse: %+v", r)
+	}
+}
+
+func TestAttestationFreshness(t *testing.T) {
+	env := newTestEnv(t)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.Engine.Now = func() time.Time { return clock }
+	env.Engine.Events.Now = env.Engine.Now
+	cfg, err := env.Engine.Repo.GetProjectConfig(env.Ctx, "proj-1")
 	if err != nil {
-		t.Fatalf("query events: %v", err)
+		t.Fatal(err)
 	}
-	defer rows.Close()
-	count := 0
-	for rows.Next() {
-		count++
+	cfg.Attestations.Freshness = config.Freshness{ReviewAfter: "24h", Windows: map[string]string{"ci.passed": "72h"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
 	}
-	if count < 3 {
-		t.Fatalf("expected multiple events, got %d", count)
+	if err := env.Engine.Repo.UpsertProjectConfig(env.Ctx, "proj-1", cfg); err != nil {
+		t.Fatal(err)
+	}
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID: "proj-1", Title: "slow review", ActorID: "tester",
+		RequiredKinds: []string{"ci.passed", "review.approved"}, PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestCI := func() domain.Attestation {
+		t.Helper()
+		att, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "ci.passed"}, "tester")
+		if err != nil {
+			t.Fatalf("attest: %v", err)
+		}
+		return att
+	}
+	ci := attestCI()
+	for _, status := range []string{"in_progress", "review"} {
+		if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: status, ActorID: "tester", Force: true}); err != nil {
+			t.Fatalf("to %s: %v", status, err)
+		}
+	}
+	check := func(at time.Time) engine.FreshnessReport {
+		t.Helper()
+		clock = at
+		rep, err := env.Engine.CheckAttestationFreshness(env.Ctx, "proj-1", "tester")
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		return rep
+	}
+	if rep := check(clock.Add(12 * time.Hour)); len(rep.Tasks) != 0 {
+		t.Fatalf("expected no check before review_after: %+v", rep.Tasks)
+	}
+	if rep := check(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)); len(rep.Tasks) != 0 {
+		t.Fatalf("expected ci.passed to be fresh within its window: %+v", rep.Tasks)
+	}
+	rep := check(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC))
+	if len(rep.Tasks) != 1 || !rep.Tasks[0].Notified || len(rep.Tasks[0].Stale) != 1 {
+		t.Fatalf("expected one newly stale task: %+v", rep.Tasks)
+	}
+	if st := rep.Tasks[0].Stale[0]; st.AttestationID != ci.ID || st.Requirement != "ci.passed" || st.FreshUntil != "2024-01-04T00:00:00Z" {
+		t.Fatalf("unexpected stale attestation: %+v", st)
+	}
+	if rep := check(clock.Add(time.Hour)); len(rep.Tasks) != 1 || rep.Tasks[0].Notified {
+		t.Fatalf("expected the same stale set not to be announced twice: %+v", rep.Tasks)
+	}
+	evts, err := env.Engine.Repo.LatestEvents(env.Ctx, 10, "proj-1", "validation.stale", "task", task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 1 || !strings.Contains(evts[0].Payload, ci.ID) {
+		t.Fatalf("expected one validation.stale event naming the attestation: %+v", evts)
+	}
+	attestCI()
+	if rep := check(clock.Add(time.Hour)); len(rep.Tasks) != 0 {
+		t.Fatalf("expected a fresh ci.passed to clear the task: %+v", rep.Tasks)
+	}
+}
+
+func TestEscalationRules(t *testing.T) {
+	env := newTestEnv(t)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.Engine.Now = func() time.Time { return clock }
+	env.Engine.Events.Now = env.Engine.Now
+	if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", "pm-1", "pm"); err != nil {
+		t.Fatal(err)
+	}
+	create := func(rule domain.EscalationRule) error {
+		rule.ProjectID, rule.Enabled = "proj-1", true
+		_, err := env.Engine.CreateEscalationRule(env.Ctx, rule, "tester")
+		return err
+	}
+	if err := create(domain.EscalationRule{ID: "idle", Condition: "task.overdue", After: "24h", Action: "notify"}); err == nil || !strings.Contains(err.Error(), "role_id is required") {
+		t.Fatalf("expected notify without a role refused, got %v", err)
+	}
+	if err := create(domain.EscalationRule{ID: "idle", Condition: "task.overdue", After: "soon", Action: "notify", RoleID: "pm"}); err == nil || !strings.Contains(err.Error(), "invalid after") {
+		t.Fatalf("expected a bad delay refused, got %v", err)
+	}
+	if err := create(domain.EscalationRule{ID: "idle", Condition: "task.overdue", After: "24h", Action: "notify", RoleID: "pm"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := create(domain.EscalationRule{ID: "idle", Condition: "task.overdue", After: "1h", Action: "notify", RoleID: "pm"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected a duplicate rule refused, got %v", err)
+	}
+	if err := create(domain.EscalationRule{ID: "stuck-review", Condition: "validation.blocked", After: "48h", Action: "follow_up"}); err != nil {
+		t.Fatal(err)
+	}
+
+	idle, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "idle", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leased, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "leased", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, leased.ID, "tester", 7*24*3600); err != nil {
+		t.Fatal(err)
+	}
+	blocked, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID: "proj-1", Title: "blocked", ActorID: "tester",
+		RequiredKinds: []string{"review.approved"}, PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []string{"in_progress", "review"} {
+		if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: blocked.ID, Status: status, ActorID: "tester", Force: true}); err != nil {
+			t.Fatalf("to %s: %v", status, err)
+		}
+	}
+	run := func(at time.Time) []engine.Escalation {
+		t.Helper()
+		clock = at
+		rep, err := env.Engine.RunEscalations(env.Ctx, "proj-1", "tester")
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		return rep.Escalations
+	}
+	start := clock
+	if escs := run(start.Add(12 * time.Hour)); len(escs) != 0 {
+		t.Fatalf("expected nothing before the delays: %+v", escs)
+	}
+	escs := run(start.Add(25 * time.Hour))
+	if len(escs) != 1 || escs[0].TaskID != idle.ID || escs[0].RuleID != "idle" || !slices.Equal(escs[0].Notified, []string{"pm-1"}) {
+		t.Fatalf("expected the unleased idle task escalated to pm: %+v", escs)
+	}
+	if escs := run(start.Add(26 * time.Hour)); len(escs) != 0 {
+		t.Fatalf("expected one escalation per spell: %+v", escs)
+	}
+	escs = run(start.Add(49 * time.Hour))
+	if len(escs) != 1 || escs[0].TaskID != blocked.ID || escs[0].FollowUpTaskID == "" || !slices.Equal(escs[0].Unmet, []string{"review.approved"}) {
+		t.Fatalf("expected a follow-up for the blocked review: %+v", escs)
+	}
+	followUp, err := env.Engine.Repo.GetTask(env.Ctx, escs[0].FollowUpTaskID)
+	if err != nil || followUp.Type != "chore" || !strings.Contains(followUp.Title, blocked.ID) {
+		t.Fatalf("unexpected follow-up task: %+v (%v)", followUp, err)
+	}
+	evts, err := env.Engine.Repo.LatestEvents(env.Ctx, 10, "proj-1", "escalation.triggered", "task", blocked.ID)
+	if err != nil || len(evts) != 1 || !strings.Contains(evts[0].Payload, followUp.ID) {
+		t.Fatalf("expected an escalation.triggered event naming the follow-up: %+v (%v)", evts, err)
+	}
+
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: idle.ID, Status: "in_progress", ActorID: "tester", Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	off := false
+	if _, err := env.Engine.UpdateEscalationRule(env.Ctx, "proj-1", "idle", engine.EscalationRuleUpdate{Enabled: &off}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if escs := run(start.Add(80 * time.Hour)); len(escs) != 0 {
+		t.Fatalf("expected a disabled rule to stay quiet: %+v", escs)
+	}
+	on := true
+	if _, err := env.Engine.UpdateEscalationRule(env.Ctx, "proj-1", "idle", engine.EscalationRuleUpdate{Enabled: &on}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	escs = run(start.Add(81 * time.Hour))
+	got := map[string]bool{}
+	for _, esc := range escs {
+		got[esc.TaskID] = true
+	}
+	if len(escs) != 2 || !got[idle.ID] || !got[followUp.ID] {
+		t.Fatalf("expected the touched task to escalate again along with the idle follow-up: %+v", escs)
+	}
+	if err := env.Engine.DeleteEscalationRule(env.Ctx, "proj-1", "idle", "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.Repo.GetEscalationRule(env.Ctx, "proj-1", "idle"); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected the rule deleted, got %v", err)
+	}
+}
+
+func TestCycleTime(t *testing.T) {
+	env := newTestEnv(t)
+	clock := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	env.Engine.Now = func() time.Time { return clock }
+	env.Engine.Events.Now = env.Engine.Now
+	create := func(title, typ string) domain.Task {
+		t.Helper()
+		task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, Type: typ, ActorID: "tester"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	move := func(task domain.Task, status string, at time.Duration) {
+		t.Helper()
+		clock = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Add(at)
+		if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: status, ActorID: "tester", Force: true}); err != nil {
+			t.Fatalf("%s to %s: %v", task.ID, status, err)
+		}
+	}
+	fast, slow, bug, open := create("fast", "technical"), create("slow", "technical"), create("bug", "bug"), create("open", "technical")
+	move(fast, "in_progress", time.Hour)
+	move(fast, "review", 2*time.Hour)
+	move(fast, "done", 3*time.Hour)
+	move(slow, "in_progress", 10*time.Hour)
+	move(slow, "review", 20*time.Hour)
+	move(slow, "in_progress", 22*time.Hour)
+	move(slow, "review", 30*time.Hour)
+	move(slow, "done", 34*time.Hour)
+	move(bug, "in_progress", time.Hour)
+	move(bug, "done", 2*time.Hour)
+	move(open, "in_progress", 40*time.Hour)
+	clock = time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	rep, err := env.Engine.CycleTime(env.Ctx, engine.CycleTimeOptions{
+		ProjectID: "proj-1", Type: "technical",
+		From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Completed) != 2 || rep.Completed[0].TaskID != fast.ID || rep.Completed[1].TaskID != slow.ID {
+		t.Fatalf("expected the two technical tasks completed in order: %+v", rep.Completed)
+	}
+	h := int64(3600)
+	if f := rep.Completed[1]; *f.LeadTimeSeconds != 34*h || *f.CycleTimeSeconds != 24*h ||
+		f.TimeInStatus["planned"] != 10*h || f.TimeInStatus["in_progress"] != 18*h || f.TimeInStatus["review"] != 6*h {
+		t.Fatalf("unexpected flow of the reworked task: %+v", f)
+	}
+	if rep.CycleTime.Count != 2 || rep.CycleTime.P50 != 2*h || rep.CycleTime.P95 != 24*h || rep.LeadTime.Max != 34*h {
+		t.Fatalf("unexpected cycle-time stats: %+v lead %+v", rep.CycleTime, rep.LeadTime)
+	}
+	if s := rep.TimeInStatus["review"]; s.Count != 2 || s.P50 != h || s.Max != 6*h {
+		t.Fatalf("unexpected time in review: %+v", s)
+	}
+	if len(rep.WIP) != 1 || rep.WIP[0].TaskID != open.ID || *rep.WIP[0].WIPAgeSeconds != 8*h || rep.WIPAge.P50 != 8*h {
+		t.Fatalf("expected the open task aged from its start: %+v", rep.WIP)
+	}
+
+	rep, err = env.Engine.CycleTime(env.Ctx, engine.CycleTimeOptions{
+		ProjectID: "proj-1", Type: "bug",
+		From: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil || len(rep.Completed) != 0 || rep.CycleTime.Count != 0 {
+		t.Fatalf("expected no bug completed after the first day: %+v (%v)", rep, err)
+	}
+	if _, err := env.Engine.CycleTime(env.Ctx, engine.CycleTimeOptions{ProjectID: "proj-1", IterationID: "nope", From: clock.Add(-time.Hour), To: clock}); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected an unknown iteration to be not found, got %v", err)
+	}
+}
+
+func TestIDStrategies(t *testing.T) {
+	env := newTestEnv(t)
+	env.Engine.Config.IDs.Tasks = config.IDScheme{Strategy: "sequential", Prefix: "PL"}
+	env.Engine.Config.IDs.Attestations = config.IDScheme{Strategy: "ulid"}
+	// a client-supplied ID that collides with the sequence is skipped
+	if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ID: "PL-2", ProjectID: "proj-1", Title: "manual", ActorID: "tester"}); err != nil {
+		t.Fatalf("create with id: %v", err)
+	}
+	var ids []string
+	for _, title := range []string{"one", "two"} {
+		tk, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester"})
+		if err != nil {
+			t.Fatalf("create %s: %v", title, err)
+		}
+		ids = append(ids, tk.ID)
+	}
+	if ids[0] != "PL-1" || ids[1] != "PL-3" {
+		t.Fatalf("unexpected sequential ids: %v", ids)
+	}
+	if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ID: "PL-1", ProjectID: "proj-1", Title: "dup", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected duplicate id to be rejected")
+	}
+
+	att, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: "PL-1", Kind: "ci.passed"}, "tester")
+	if err != nil {
+		t.Fatalf("attest: %v", err)
+	}
+	if len(att.ID) != 26 {
+		t.Fatalf("expected ulid attestation id, got %q", att.ID)
+	}
+
+	env.Engine.Config.IDs.Tasks = config.IDScheme{Strategy: "client"}
+	if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "anon", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected client strategy to require an id")
+	}
+}
+
+func TestPolicyEvaluation(t *testing.T) {
+	env := newTestEnv(t)
+	tk, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID:      "proj-1",
+		Title:          "policy",
+		ActorID:        "tester",
+		RequiredKinds:  []string{"ci.passed", "review.approved"},
+		PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// try move to done without attestations should fail
+	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "in_progress", ActorID: "tester", Force: true})
+	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "review", ActorID: "tester", Force: true})
+	_, err = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "done", ActorID: "tester"})
+	if err == nil {
+		t.Fatalf("expected validation failure")
+	}
+	// add attestations
+	_, err = env.Engine.AddAttestation(env.Ctx, domain.Attestation{
+		ProjectID:  "proj-1",
+		EntityKind: "task",
+		EntityID:   tk.ID,
+		Kind:       "ci.passed",
+	}, "tester")
+	if err != nil {
+		t.Fatalf("att1: %v", err)
+	}
+	_, err = env.Engine.AddAttestation(env.Ctx, domain.Attestation{
+		ProjectID:  "proj-1",
+		EntityKind: "task",
+		EntityID:   tk.ID,
+		Kind:       "review.approved",
+	}, "tester")
+	if err != nil {
+		t.Fatalf("att2: %v", err)
+	}
+	_, err = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "done", ActorID: "tester", Force: true})
+	if err != nil {
+		t.Fatalf("expected done after attestations: %v", err)
+	}
+}
+
+func TestValidationWaiver(t *testing.T) {
+	env := newTestEnv(t)
+	tk, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID:      "proj-1",
+		Title:          "waived",
+		ActorID:        "tester",
+		RequiredKinds:  []string{"ci.passed", "security.approved"},
+		PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: tk.ID, Kind: "ci.passed"}, "tester"); err != nil {
+		t.Fatalf("attest: %v", err)
+	}
+	if _, err := env.Engine.WaiveValidation(env.Ctx, engine.WaiverCreateOptions{TaskID: tk.ID, Kind: "security.approved", ExpiresAt: "2024-02-01T00:00:00Z", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected justification to be required")
+	}
+	if _, err := env.Engine.WaiveValidation(env.Ctx, engine.WaiverCreateOptions{TaskID: tk.ID, Kind: "ci.passed", Justification: "n/a", ExpiresAt: "2024-02-01T00:00:00Z", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected waiver of present kind to fail")
+	}
+	if _, err := env.Engine.WaiveValidation(env.Ctx, engine.WaiverCreateOptions{TaskID: tk.ID, Kind: "security.approved", Justification: "scanner down", ExpiresAt: "2023-12-01T00:00:00Z", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected past expiry to be rejected")
+	}
+	w, err := env.Engine.WaiveValidation(env.Ctx, engine.WaiverCreateOptions{TaskID: tk.ID, Kind: "security.approved", Justification: "scanner down", ExpiresAt: "2024-02-01T00:00:00Z", ActorID: "tester"})
+	if err != nil {
+		t.Fatalf("waive: %v", err)
+	}
+	if w.ActorID != "tester" || w.Kind != "security.approved" {
+		t.Fatalf("unexpected waiver %+v", w)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, tk.ID, "tester", 900); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "done", ActorID: "tester"}); err != nil {
+		t.Fatalf("expected waiver to satisfy validation: %v", err)
+	}
+
+	other, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID:      "proj-1",
+		Title:          "expired waiver",
+		ActorID:        "tester",
+		RequiredKinds:  []string{"security.approved"},
+		PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.WaiveValidation(env.Ctx, engine.WaiverCreateOptions{TaskID: other.ID, Kind: "security.approved", Justification: "short window", ExpiresAt: "2024-01-01T01:00:00Z", ActorID: "tester"}); err != nil {
+		t.Fatalf("waive: %v", err)
+	}
+	later := env.Engine
+	later.Now = func() time.Time { return time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC) }
+	if _, err := later.ClaimLease(env.Ctx, other.ID, "tester", 900); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := later.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: other.ID, Status: "done", ActorID: "tester"}); err == nil {
+		t.Fatalf("expected expired waiver to be ignored")
+	}
+}
+
+func TestRecordDailyStatsLeadTime(t *testing.T) {
+	env := newTestEnv(t)
+	tk, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "timed", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "open", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := env.Engine
+	later.Now = func() time.Time { return time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC) }
+	if _, err := later.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: tk.ID, Status: "done", ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("done: %v", err)
+	}
+	snap, err := later.RecordDailyStats(env.Ctx, "proj-1")
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if snap.Day != "2024-01-01" || snap.TasksOpen != 1 || snap.TasksDone != 1 || snap.TasksCompleted != 1 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	if snap.LeadTimeSeconds == nil || *snap.LeadTimeSeconds < 3599 || *snap.LeadTimeSeconds > 3601 {
+		t.Fatalf("expected one hour lead time, got %v", snap.LeadTimeSeconds)
+	}
+
+	// Work finished after the day's last snapshot is counted once the day rolls over.
+	later.Now = func() time.Time { return time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC) }
+	if _, err := later.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: open.ID, Status: "done", ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("done: %v", err)
+	}
+	later.Now = func() time.Time { return time.Date(2024, 1, 2, 0, 10, 0, 0, time.UTC) }
+	if _, err := later.RecordDailyStats(env.Ctx, "proj-1"); err != nil {
+		t.Fatalf("record after midnight: %v", err)
+	}
+	series, err := later.Repo.ListStatsSnapshots(env.Ctx, "proj-1", "2024-01-01", "2024-01-02")
+	if err != nil {
+		t.Fatalf("series: %v", err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("expected two days, got %+v", series)
+	}
+	if day := series[0]; day.TasksCompleted != 2 || day.TasksOpen != 1 || day.RecordedAt != "2024-01-02T00:10:00Z" {
+		t.Fatalf("expected the previous day to be finalised, got %+v", day)
+	}
+	if day := series[1]; day.TasksCompleted != 0 || day.TasksOpen != 0 || day.TasksDone != 2 {
+		t.Fatalf("unexpected snapshot for the new day %+v", day)
+	}
+}
+
+func TestWorkflowMetrics(t *testing.T) {
+	env := newTestEnv(t)
+	gated, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{
+		ProjectID:      "proj-1",
+		Title:          "gated",
+		ActorID:        "tester",
+		RequiredKinds:  []string{"ci.passed", "security.approved"},
+		PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "waiting", ActorID: "tester"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, gated.ID, "tester", 900); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: gated.ID, Status: "in_progress", ActorID: "tester"}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: gated.ID, Status: "review", ActorID: "tester"}); err != nil {
+		t.Fatalf("review: %v", err)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: gated.ID, Kind: "ci.passed"}, "tester"); err != nil {
+		t.Fatalf("attest: %v", err)
+	}
+	ms, err := env.Engine.WorkflowMetrics(env.Ctx)
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	if len(ms) != 1 || ms[0].ProjectID != "proj-1" {
+		t.Fatalf("expected one project, got %+v", ms)
+	}
+	m := ms[0]
+	if m.OpenTasks["review"] != 1 || m.OpenTasks["planned"] != 1 || m.ActiveLeases != 1 || m.ValidationBlocked != 1 || m.Attestations != 1 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+
+	later := env.Engine
+	later.Now = func() time.Time { return time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC) }
+	if _, err := later.WaiveValidation(env.Ctx, engine.WaiverCreateOptions{TaskID: gated.ID, Kind: "security.approved", Justification: "scanner down", ExpiresAt: "2024-02-01T00:00:00Z", ActorID: "tester"}); err != nil {
+		t.Fatalf("waive: %v", err)
+	}
+	if ms, err = later.WorkflowMetrics(env.Ctx); err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	if ms[0].ActiveLeases != 0 || ms[0].ValidationBlocked != 0 {
+		t.Fatalf("expected the lease expired and the waiver to unblock the task, got %+v", ms[0])
+	}
+}
+
+func TestSeedRBACFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	conn, err := db.Open(db.Config{Workspace: dir})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := migrate.Migrate(conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	cfg := config.Default("proj-1")
+	cfg.RBAC.Roles = map[string]config.RBACRole{
+		"owner": {
+			Description: "Owner",
+			Permissions: []string{"project.read", "task.list"},
+		},
+		"observer": {
+			Description: "Observer",
+			Permissions: []string{"project.read"},
+		},
+	}
+	cfg.RBAC.AttestationAuthorities = map[string][]string{
+		"ci.passed": {"owner"},
+	}
+	eng := engine.New(conn, cfg)
+	eng.Now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+	if _, err := eng.InitProject(ctx, "proj-1", "test", "tester"); err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer tx.Rollback()
+	perms, err := eng.Auth.ActorPermissions(ctx, tx, "proj-1", "tester")
+	if err != nil {
+		t.Fatalf("perms: %v", err)
+	}
+	got := map[string]bool{}
+	for _, p := range perms {
+		got[p] = true
+	}
+	if len(got) != 2 || !got["project.read"] || !got["task.list"] {
+		t.Fatalf("unexpected permissions: %v", perms)
+	}
+	ok, err := eng.Auth.ActorCanAttest(ctx, tx, "proj-1", "tester", "ci.passed")
+	if err != nil {
+		t.Fatalf("attest check: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected attestation allowed")
+	}
+	ok, err = eng.Auth.ActorCanAttest(ctx, tx, "proj-1", "tester", "review.approved")
+	if err != nil {
+		t.Fatalf("attest check: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected attestation denied")
+	}
+}
+
+func TestAttestationEventLogged(t *testing.T) {
+	env := newTestEnv(t)
+	att, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{
+		ProjectID:  "proj-1",
+		EntityKind: "project",
+		EntityID:   "proj-1",
+		Kind:       "init.check",
+	}, "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.ID == "" {
+		t.Fatalf("expected attestation id")
+	}
+	rows, err := env.Engine.DB.QueryContext(env.Ctx, `SELECT count(*) FROM events WHERE type='attestation.added' AND entity_kind=? AND entity_id=?`, att.EntityKind, att.EntityID)
+	if err != nil {
+		t.Fatalf("query events: %v", err)
+	}
+	defer rows.Close()
+	var count int
+	rows.Next()
+	rows.Scan(&count)
+	if count == 0 {
+		t.Fatalf("expected event rows")
+	}
+}
+
+func TestEventAppendOnStateChanges(t *testing.T) {
+	env := newTestEnv(t)
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "evented", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "in_progress", ActorID: "tester", Force: true})
+	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "review", ActorID: "tester", Force: true})
+	_, _ = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "done", ActorID: "tester", Force: true})
+	rows, err := env.Engine.DB.QueryContext(env.Ctx, `SELECT type FROM events WHERE entity_id=?`, task.ID)
+	if err != nil {
+		t.Fatalf("query events: %v", err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	if count < 3 {
+		t.Fatalf("expected multiple events, got %d", count)
+	}
+}
+
+func TestRowBudget(t *testing.T) {
+	env := newTestEnv(t)
+	for i := 0; i < 3; i++ {
+		if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: fmt.Sprintf("Task %d", i), ActorID: "tester"}); err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+	}
+	ctx := repo.WithRowBudget(env.Ctx, 4)
+	if _, err := env.Engine.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: "proj-1"}); err != nil {
+		t.Fatalf("first list within budget: %v", err)
+	}
+	_, err := env.Engine.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: "proj-1"})
+	var qe repo.QueryBudgetError
+	if !errors.As(err, &qe) || qe.Guard != "rows" {
+		t.Fatalf("expected row budget error, got %v", err)
+	}
+	if _, err := env.Engine.Repo.ListTasks(env.Ctx, repo.TaskFilters{ProjectID: "proj-1", Limit: repo.MaxPageSize + 2}); !errors.As(err, &qe) || qe.Guard != "limit" {
+		t.Fatalf("expected page limit error, got %v", err)
+	}
+}
+
+func TestRoleGrantExpiry(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.Engine.Now = func() time.Time { return now }
+	env.Engine.Auth.Now = env.Engine.Now
+
+	if err := env.Engine.GrantRoleUntil(env.Ctx, "proj-1", "tester", "contractor", "dev", "2023-12-31T00:00:00Z"); err == nil {
+		t.Fatal("expected past expiry to be rejected")
+	}
+	if err := env.Engine.GrantRoleUntil(env.Ctx, "proj-1", "tester", "contractor", "dev", "2024-01-01T01:00:00Z"); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	who, err := env.Engine.WhoAmI(env.Ctx, "proj-1", "contractor")
+	if err != nil || len(who.Roles) != 1 {
+		t.Fatalf("expected active temporary grant, got %+v (%v)", who, err)
+	}
+	if grants, err := env.Engine.ExpireRoleGrants(env.Ctx); err != nil || len(grants) != 0 {
+		t.Fatalf("expected nothing to expire yet, got %v (%v)", grants, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	who, err = env.Engine.WhoAmI(env.Ctx, "proj-1", "contractor")
+	if err != nil || len(who.Roles) != 0 || len(who.Permissions) != 0 {
+		t.Fatalf("expected expired grant to be ignored, got %+v (%v)", who, err)
+	}
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "contract work", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "contractor", 60); err == nil {
+		t.Fatal("expected claim with expired grant to be forbidden")
+	}
+	grants, err := env.Engine.ExpireRoleGrants(env.Ctx)
+	if err != nil || len(grants) != 1 || grants[0].ActorID != "contractor" || grants[0].RoleID != "dev" {
+		t.Fatalf("expected one expired grant, got %v (%v)", grants, err)
+	}
+	var n int
+	if err := env.Engine.DB.QueryRowContext(env.Ctx, `SELECT count(*) FROM events WHERE type='rbac.role_expired' AND payload_json LIKE '%contractor%'`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected one rbac.role_expired event, got %d (%v)", n, err)
+	}
+
+	// Re-granting without an expiry makes the grant permanent.
+	if err := env.Engine.GrantRoleUntil(env.Ctx, "proj-1", "tester", "contractor", "dev", "2024-01-01T03:00:00Z"); err != nil {
+		t.Fatalf("regrant: %v", err)
+	}
+	if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", "contractor", "dev"); err != nil {
+		t.Fatalf("permanent grant: %v", err)
+	}
+	now = now.Add(24 * time.Hour)
+	if who, _ := env.Engine.WhoAmI(env.Ctx, "proj-1", "contractor"); len(who.Roles) != 1 {
+		t.Fatalf("expected permanent grant, got %+v", who)
+	}
+}
+
+func TestTeamRoleGrants(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.Engine.Now = func() time.Time { return now }
+	env.Engine.Auth.Now = env.Engine.Now
+
+	if _, err := env.Engine.CreateTeam(env.Ctx, "proj-1", "qa", "Quality", "rita"); err == nil {
+		t.Fatal("expected team creation without rbac.manage to be forbidden")
+	}
+	team, err := env.Engine.CreateTeam(env.Ctx, "proj-1", "qa", "Quality", "tester")
+	if err != nil || team.ID != "qa" || len(team.Members) != 0 {
+		t.Fatalf("create team: %+v (%v)", team, err)
+	}
+	if _, err := env.Engine.CreateTeam(env.Ctx, "proj-1", "qa", "", "tester"); err == nil {
+		t.Fatal("expected duplicate team to be rejected")
+	}
+	if err := env.Engine.GrantTeamRoleUntil(env.Ctx, "proj-1", "tester", "qa", "no-such-role", ""); err == nil {
+		t.Fatal("expected unknown role to be rejected")
+	}
+	if err := env.Engine.GrantTeamRoleUntil(env.Ctx, "proj-1", "tester", "qa", "reviewer", ""); err != nil {
+		t.Fatalf("grant team role: %v", err)
+	}
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "reviewed work", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "review.approved"}
+	if _, err := env.Engine.AddAttestation(env.Ctx, review, "rita"); err == nil {
+		t.Fatal("expected attestation before joining the team to be forbidden")
+	}
+	if err := env.Engine.AddTeamMember(env.Ctx, "proj-1", "qa", "rita", "tester"); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, review, "rita"); err != nil {
+		t.Fatalf("expected the team's role to carry the attestation authority: %v", err)
+	}
+	who, err := env.Engine.WhoAmI(env.Ctx, "proj-1", "rita")
+	if err != nil || len(who.Roles) != 1 || who.Roles[0] != "reviewer" {
+		t.Fatalf("expected reviewer through the team, got %+v (%v)", who, err)
+	}
+	members, err := env.Engine.Repo.ListMembers(env.Ctx, "proj-1", now.Format(time.RFC3339), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, m := range members {
+		if m.ActorID == "rita" {
+			found = len(m.Roles) == 1 && m.Roles[0].TeamID != nil && *m.Roles[0].TeamID == "qa" && len(m.Permissions) > 0
+		}
+	}
+	if !found {
+		t.Fatalf("expected rita listed with the team grant, got %+v", members)
+	}
+
+	// A temporary team grant lapses for every member and is swept like actor grants.
+	if err := env.Engine.GrantTeamRoleUntil(env.Ctx, "proj-1", "tester", "qa", "reviewer", "2024-01-01T01:00:00Z"); err != nil {
+		t.Fatalf("regrant: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if who, _ := env.Engine.WhoAmI(env.Ctx, "proj-1", "rita"); len(who.Roles) != 0 {
+		t.Fatalf("expected expired team grant to be ignored, got %+v", who)
+	}
+	grants, err := env.Engine.ExpireRoleGrants(env.Ctx)
+	if err != nil || len(grants) != 1 || grants[0].TeamID == nil || *grants[0].TeamID != "qa" {
+		t.Fatalf("expected the team grant to expire, got %+v (%v)", grants, err)
+	}
+	if team, err := env.Engine.Repo.GetTeam(env.Ctx, "proj-1", "qa"); err != nil || len(team.Roles) != 0 || len(team.Members) != 1 {
+		t.Fatalf("expected members kept and grant removed, got %+v (%v)", team, err)
+	}
+
+	if err := env.Engine.GrantTeamRoleUntil(env.Ctx, "proj-1", "tester", "qa", "dev", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Engine.DeleteTeam(env.Ctx, "proj-1", "qa", "tester"); err != nil {
+		t.Fatalf("delete team: %v", err)
+	}
+	if who, _ := env.Engine.WhoAmI(env.Ctx, "proj-1", "rita"); len(who.Roles) != 0 {
+		t.Fatalf("expected no roles once the team is gone, got %+v", who)
+	}
+	if err := env.Engine.AddTeamMember(env.Ctx, "proj-1", "qa", "rita", "tester"); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected missing team, got %v", err)
+	}
+}
+
+func TestContentHashDetectsTampering(t *testing.T) {
+	env := newTestEnv(t)
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "Hashed", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(task.ContentHash, "sha256:") {
+		t.Fatalf("expected content hash, got %q", task.ContentHash)
+	}
+	got, err := env.Engine.Repo.GetTask(env.Ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ContentHash != task.ContentHash {
+		t.Fatalf("stored hash %q differs from returned %q", got.ContentHash, task.ContentHash)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "ci.passed"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err := env.Engine.VerifyContentHashes(env.Ctx, "proj-1")
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected clean record, got %v %v", mismatches, err)
+	}
+	if _, err := env.Engine.DB.ExecContext(env.Ctx, `UPDATE tasks SET title='Rewritten' WHERE id=?`, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err = env.Engine.VerifyContentHashes(env.Ctx, "proj-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 1 || mismatches[0].EntityKind != "task" || mismatches[0].EntityID != task.ID {
+		t.Fatalf("expected tampered task, got %+v", mismatches)
+	}
+
+	if _, err := env.Engine.DB.ExecContext(env.Ctx, `UPDATE tasks SET content_hash=NULL WHERE id=?`, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err = env.Engine.VerifyContentHashes(env.Ctx, "proj-1")
+	if err != nil || len(mismatches) != 1 || mismatches[0].Stored != "" {
+		t.Fatalf("expected a task without hash to be unverified, got %+v %v", mismatches, err)
+	}
+	tx, err := env.Engine.DB.BeginTx(env.Ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Engine.Repo.BackfillContentHashesTx(env.Ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err = env.Engine.VerifyContentHashes(env.Ctx, "proj-1")
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected backfilled hashes to verify, got %+v %v", mismatches, err)
+	}
+}
+
+func TestTaskUnblockedEvent(t *testing.T) {
+	env := newTestEnv(t)
+	a, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "a", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "b", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "c", ActorID: "tester", DependsOn: []string{a.ID, b.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unblocked := func() []string {
+		rows, err := env.Engine.DB.QueryContext(env.Ctx, `SELECT entity_id FROM events WHERE type='task.unblocked' ORDER BY id`)
+		if err != nil {
+			t.Fatalf("query events: %v", err)
+		}
+		defer rows.Close()
+		var ids []string
+		for rows.Next() {
+			var id string
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: a.ID, Status: "done", ActorID: "tester", Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := unblocked(); len(ids) != 0 {
+		t.Fatalf("c still waits on b, got unblocked %v", ids)
+	}
+	if _, err := env.Engine.TaskDone(env.Ctx, b.ID, `{}`, "tester", true); err != nil {
+		t.Fatal(err)
+	}
+	if ids := unblocked(); len(ids) != 1 || ids[0] != c.ID {
+		t.Fatalf("expected %s unblocked once, got %v", c.ID, ids)
+	}
+}
+
+func TestParentStatusRollup(t *testing.T) {
+	env := newTestEnv(t)
+	env.Engine.Config.Rollup.ParentStatus = "children"
+	parent, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "epic", ActorID: "tester", RequiredKinds: []string{"review.approved"}, PolicyOverride: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var children []domain.Task
+	for _, title := range []string{"a", "b", "c"} {
+		child, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester", ParentID: parent.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		children = append(children, child)
+	}
+	status := func(want string) {
+		t.Helper()
+		got, err := env.Engine.Repo.GetTask(env.Ctx, parent.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != want {
+			t.Fatalf("parent status %s, want %s", got.Status, want)
+		}
+	}
+	status("planned")
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: children[0].ID, Status: "in_progress", ActorID: "tester", Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	status("in_progress")
+	for _, c := range children[:2] {
+		if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: c.ID, Status: "done", ActorID: "tester", Force: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := env.Engine.CancelTask(env.Ctx, engine.TaskCancelOptions{ID: children[2].ID, ActorID: "tester", Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	status("in_progress")
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: parent.ID, Kind: "review.approved"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	status("done")
+	if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "late", ActorID: "tester", ParentID: parent.ID}); err != nil {
+		t.Fatal(err)
+	}
+	status("in_progress")
+
+	if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", "dev-1", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: parent.ID, Status: "review", ActorID: "dev-1"})
+	var fe auth.ForbiddenError
+	if !errors.As(err, &fe) || fe.Permission != "task.status.override" {
+		t.Fatalf("expected task.status.override to be required, got %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: parent.ID, Status: "review", ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("owner override: %v", err)
+	}
+	status("review")
+}
+
+func TestDeprecatedAttestationKind(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	env.Engine.Now = func() time.Time { return now }
+	cfg := env.Engine.Config
+	cfg.Attestations.Catalog["ci.passed"] = config.AttestationKind{Description: "CI pipeline completed successfully", Deprecated: true, ReplacedBy: "acceptance.passed", GraceUntil: "2026-03-31"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "legacy", ActorID: "tester", RequiredKinds: []string{"ci.passed"}, PolicyOverride: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "acceptance.passed"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	present, err := env.Engine.PresentRequirements(env.Ctx, task.ID, []string{"ci.passed"})
+	if err != nil || !present["ci.passed"] {
+		t.Fatalf("expected replacement to satisfy deprecated kind in grace, got %v (%v)", present, err)
+	}
+	now = time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	present, err = env.Engine.PresentRequirements(env.Ctx, task.ID, []string{"ci.passed"})
+	if err != nil || present["ci.passed"] {
+		t.Fatalf("expected grace period to be over, got %v (%v)", present, err)
+	}
+
+	if msg := env.Engine.DeprecatedKindWarning("ci.passed"); msg != "attestation kind ci.passed is deprecated, use acceptance.passed" {
+		t.Fatalf("unexpected warning %q", msg)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "ci.passed"}, "tester"); err != nil {
+		t.Fatalf("expected deprecated kind to be accepted with a warning: %v", err)
+	}
+	cfg.Attestations.OnDeprecated = "reject"
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "ci.passed"}, "tester"); err == nil || !strings.Contains(err.Error(), "use acceptance.passed") {
+		t.Fatalf("expected deprecated kind to be rejected, got %v", err)
+	}
+
+	cfg.Attestations.Catalog["ci.passed"] = config.AttestationKind{Deprecated: true, ReplacedBy: "ci.passed"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected a kind replacing itself to be rejected")
+	}
+	cfg.Attestations.Catalog["ci.passed"] = config.AttestationKind{Deprecated: true, ReplacedBy: "acceptance.passed", GraceUntil: "March"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected an invalid grace_until to be rejected")
+	}
+}
+
+func TestTwoPhaseCompletion(t *testing.T) {
+	env := newTestEnv(t)
+	env.Engine.Config.Completion.Mode = "approval"
+	for actor, role := range map[string]string{"rev-1": "reviewer", "dev-1": "dev"} {
+		if err := env.Engine.GrantRole(env.Ctx, "proj-1", "tester", actor, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	submit := func(title string) domain.Task {
+		t.Helper()
+		task, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester", RequiredKinds: []string{"ci.passed"}, PolicyOverride: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := env.Engine.ClaimLease(env.Ctx, task.ID, "tester", 600); err != nil {
+			t.Fatal(err)
+		}
+		submitted, err := env.Engine.TaskDone(env.Ctx, task.ID, `{"pr":"#12"}`, "tester", false)
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		if submitted.Status != "review" || submitted.CompletedAt != nil {
+			t.Fatalf("expected a task in review without completed_at, got %+v", submitted)
+		}
+		return submitted
+	}
+
+	task := submit("two-phase")
+	completions, err := env.Engine.Repo.ListTaskCompletions(env.Ctx, task.ID)
+	if err != nil || len(completions) != 1 || completions[0].Status != "pending" || completions[0].SubmittedBy != "tester" {
+		t.Fatalf("expected a pending completion, got %+v (%v)", completions, err)
+	}
+	if _, err := env.Engine.TaskDone(env.Ctx, task.ID, `{}`, "tester", false); err == nil || !strings.Contains(err.Error(), "awaits approval") {
+		t.Fatalf("expected a second submission to be refused, got %v", err)
+	}
+	if _, err := env.Engine.UpdateTask(env.Ctx, engine.TaskUpdateOptions{ID: task.ID, Status: "done", ActorID: "tester"}); err == nil || !strings.Contains(err.Error(), "need approval") {
+		t.Fatalf("expected setting done to need approval, got %v", err)
+	}
+	if _, err := env.Engine.ApproveTaskCompletion(env.Ctx, task.ID, "tester"); err == nil || !strings.Contains(err.Error(), "cannot approve") {
+		t.Fatalf("expected the submitter to be refused, got %v", err)
+	}
+	_, err = env.Engine.ApproveTaskCompletion(env.Ctx, task.ID, "dev-1")
+	var fe auth.ForbiddenError
+	if !errors.As(err, &fe) || fe.Permission != "task.approve" {
+		t.Fatalf("expected task.approve to be required, got %v", err)
+	}
+	if _, err := env.Engine.ApproveTaskCompletion(env.Ctx, task.ID, "rev-1"); err == nil || !strings.Contains(err.Error(), "validation") {
+		t.Fatalf("expected approval to check the policy, got %v", err)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: task.ID, Kind: "ci.passed"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	done, err := env.Engine.ApproveTaskCompletion(env.Ctx, task.ID, "rev-1")
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if done.Status != "done" || done.CompletedAt == nil {
+		t.Fatalf("expected approval to complete the task, got %+v", done)
+	}
+	completions, _ = env.Engine.Repo.ListTaskCompletions(env.Ctx, task.ID)
+	if completions[0].Status != "approved" || completions[0].DecidedBy == nil || *completions[0].DecidedBy != "rev-1" {
+		t.Fatalf("expected the completion to be approved by rev-1, got %+v", completions[0])
+	}
+	if _, err := env.Engine.ApproveTaskCompletion(env.Ctx, task.ID, "rev-1"); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected nothing left to approve, got %v", err)
+	}
+
+	rejected := submit("sent back")
+	if _, err := env.Engine.RejectTaskCompletion(env.Ctx, rejected.ID, "rev-1", " "); err == nil {
+		t.Fatal("expected a reason to be required")
+	}
+	got, err := env.Engine.RejectTaskCompletion(env.Ctx, rejected.ID, "rev-1", "missing migration")
+	if err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if got.Status != "rejected" || got.CompletedAt != nil {
+		t.Fatalf("expected a rejected task without completed_at, got %+v", got)
+	}
+	completions, _ = env.Engine.Repo.ListTaskCompletions(env.Ctx, rejected.ID)
+	if len(completions) != 1 || completions[0].Status != "rejected" || completions[0].Reason != "missing migration" {
+		t.Fatalf("unexpected completions %+v", completions)
+	}
+
+	withdrawn := submit("withdrawn")
+	if _, err := env.Engine.CancelTask(env.Ctx, engine.TaskCancelOptions{ID: withdrawn.ID, ActorID: "tester", Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	completions, _ = env.Engine.Repo.ListTaskCompletions(env.Ctx, withdrawn.ID)
+	if len(completions) != 1 || completions[0].Status != "withdrawn" {
+		t.Fatalf("expected canceling to withdraw the completion, got %+v", completions)
+	}
+}
+
+func TestDailyDigest(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now().UTC()
+	env.Engine.Now = func() time.Time { return now.Add(-48 * time.Hour) }
+	held, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "held", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, held.ID, "tester", 72*3600); err != nil {
+		t.Fatal(err)
+	}
+	abandoned, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "abandoned", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, abandoned.ID, "tester", 3600); err != nil {
+		t.Fatal(err)
+	}
+	env.Engine.Now = func() time.Time { return now }
+
+	gated, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "gated", ActorID: "tester", RequiredKinds: []string{"ci.passed", "review.approved"}, PolicyOverride: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.AddAttestation(env.Ctx, domain.Attestation{ProjectID: "proj-1", EntityKind: "task", EntityID: gated.ID, Kind: "ci.passed"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.ClaimLease(env.Ctx, gated.ID, "tester", 900); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.TaskDone(env.Ctx, gated.ID, "{}", "tester", false); err == nil || !strings.Contains(err.Error(), "validation policy not satisfied") {
+		t.Fatalf("expected refused completion, got %v", err)
+	}
+	shipped, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "shipped", ActorID: "tester", PolicyOverride: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.TaskDone(env.Ctx, shipped.ID, "{}", "tester", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.CreateDecision(env.Ctx, domain.Decision{ID: "dec-1", ProjectID: "proj-1", Title: "Use sqlite", Decision: "sqlite", DeciderID: "tester"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Engine.CreateIteration(env.Ctx, domain.Iteration{ID: "it-1", ProjectID: "proj-1", Goal: "ship"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []string{"running", "delivered"} {
+		if _, err := env.Engine.SetIterationStatus(env.Ctx, "it-1", status, "tester", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := env.Engine.SetIterationStatus(env.Ctx, "it-1", "validated", "tester", false); err == nil {
+		t.Fatal("expected iteration validation to be refused")
+	}
+
+	day, err := engine.DigestDay(now.Format(time.DateOnly), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := env.Engine.GenerateDigest(env.Ctx, "proj-1", day, "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.CompletedTasks) != 1 || d.CompletedTasks[0].ID != shipped.ID {
+		t.Fatalf("unexpected completed tasks %+v", d.CompletedTasks)
+	}
+	if len(d.Decisions) != 1 || d.Decisions[0].ID != "dec-1" {
+		t.Fatalf("unexpected decisions %+v", d.Decisions)
+	}
+	if len(d.ValidationFailures) != 2 {
+		t.Fatalf("expected task and iteration validation failures, got %+v", d.ValidationFailures)
+	}
+	if f := d.ValidationFailures[0]; f.EntityID != gated.ID || len(f.Missing) != 1 || f.Missing[0] != "review.approved" {
+		t.Fatalf("unexpected task failure %+v", f)
+	}
+	if f := d.ValidationFailures[1]; f.EntityKind != "iteration" || !strings.Contains(f.Reason, "iteration.approved") {
+		t.Fatalf("unexpected iteration failure %+v", f)
+	}
+	reasons := map[string]string{}
+	for _, l := range d.StuckLeases {
+		reasons[l.TaskID] = l.Reason
+	}
+	if len(reasons) != 2 || reasons[held.ID] != "held_too_long" || reasons[abandoned.ID] != "expired" {
+		t.Fatalf("unexpected stuck leases %+v", d.StuckLeases)
+	}
+
+	stored, err := env.Engine.GetDigest(env.Ctx, "proj-1", d.Day)
+	if err != nil || stored.GeneratedAt != d.GeneratedAt || len(stored.StuckLeases) != 2 {
+		t.Fatalf("unexpected stored digest %+v (%v)", stored, err)
+	}
+	evts, err := env.Engine.Repo.LatestEvents(env.Ctx, 1, "proj-1", "digest.generated", "", "")
+	if err != nil || len(evts) != 1 || !strings.Contains(evts[0].Payload, `"validation_failures":2`) {
+		t.Fatalf("expected digest.generated event, got %+v (%v)", evts, err)
+	}
+
+	due, err := env.Engine.GenerateDueDigests(env.Ctx)
+	if err != nil || len(due) != 1 || due[0].Day != now.AddDate(0, 0, -1).Format(time.DateOnly) {
+		t.Fatalf("expected yesterday's digest to be due, got %+v (%v)", due, err)
+	}
+	if due, err = env.Engine.GenerateDueDigests(env.Ctx); err != nil || len(due) != 0 {
+		t.Fatalf("expected no digest due after generation, got %+v (%v)", due, err)
+	}
+}
+
+func TestSyncProject(t *testing.T) {
+	env := newTestEnv(t)
+	doc := `
+iterations:
+  - id: it-1
+    goal: ship sign-in
+policies:
+  presets:
+    reviewed: [ci.passed, review.approved]
+tasks:
+  - id: auth
+    title: Authentication
+    type: feature
+    children:
+      - id: login
+        title: Login form
+        iteration: it-1
+        policy: reviewed
+      - id: tokens
+        title: Token storage
+        require: [security.ok]
+        depends_on: [login]
+`
+	m, err := manifest.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := env.Engine.SyncProject(env.Ctx, "proj-1", "tester", m, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if preview.Applied || len(preview.Changes) != 5 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	if _, err := env.Engine.Repo.GetTask(env.Ctx, "auth"); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("dry run created tasks: %v", err)
+	}
+	plan, err := env.Engine.SyncProject(env.Ctx, "proj-1", "tester", m, false)
+	if err != nil || !plan.Applied || len(plan.Changes) != 5 {
+		t.Fatalf("sync: %+v %v", plan, err)
+	}
+	tokens, err := env.Engine.Repo.GetTask(env.Ctx, "tokens")
+	if err != nil || tokens.ParentID == nil || *tokens.ParentID != "auth" || len(tokens.DependsOn) != 1 {
+		t.Fatalf("unexpected synced task: %+v %v", tokens, err)
+	}
+	login, _ := env.Engine.Repo.GetTask(env.Ctx, "login")
+	if login.IterationID == nil || login.RequiredAttestationsJSON == nil || *login.RequiredAttestationsJSON != `["ci.passed","review.approved"]` {
+		t.Fatalf("unexpected policy or iteration: %+v", login)
+	}
+	again, err := env.Engine.SyncProject(env.Ctx, "proj-1", "tester", m, false)
+	if err != nil || again.Applied || len(again.Changes) != 0 {
+		t.Fatalf("expected nothing left to sync, got %+v %v", again, err)
+	}
+
+	// A stray task is canceled by pruning; edits to the file are applied in place.
+	stray, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "stray", ActorID: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Tasks[0].Children[1].Title = "Token storage v2"
+	m.Tasks[0].Children[1].DependsOn = nil
+	m.Prune = true
+	plan, err = env.Engine.SyncProject(env.Ctx, "proj-1", "tester", m, false)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	var canceled, updated []string
+	for _, c := range plan.Changes {
+		switch {
+		case c.Kind == "task" && c.Action == "cancel":
+			canceled = append(canceled, c.ID)
+		case c.Kind == "task" && c.Action == "update":
+			updated = append(updated, c.ID+":"+strings.Join(c.Fields, ","))
+		}
+	}
+	if len(canceled) != 1 || canceled[0] != stray.ID || len(updated) != 1 || updated[0] != "tokens:title,depends_on" {
+		t.Fatalf("unexpected prune plan: %+v", plan.Changes)
+	}
+	if got, _ := env.Engine.Repo.GetTask(env.Ctx, stray.ID); got.Status != "canceled" {
+		t.Fatalf("expected stray task canceled, got %s", got.Status)
+	}
+	cfg, _ := env.Engine.Repo.GetProjectConfig(env.Ctx, "proj-1")
+	if len(cfg.Policies.Presets) != 1 || len(cfg.Policies.Defaults.Task) != 0 {
+		t.Fatalf("expected undeclared presets pruned: %+v", cfg.Policies)
+	}
+
+	// A dependency cycle rejects the whole sync.
+	m.Tasks[0].Children[0].DependsOn = []string{"tokens"}
+	m.Tasks[0].Children[1].DependsOn = []string{"login"}
+	m.Tasks[0].Title = "Auth"
+	if _, err := env.Engine.SyncProject(env.Ctx, "proj-1", "tester", m, false); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle rejected, got %v", err)
+	}
+	if got, _ := env.Engine.Repo.GetTask(env.Ctx, "auth"); got.Title != "Authentication" {
+		t.Fatalf("rejected sync was partly applied: %+v", got)
+	}
+}
+
+func TestUsageBuffer(t *testing.T) {
+	env := newTestEnv(t)
+	e := env.Engine.WithUsageBuffer()
+	for range 3 {
+		if err := e.RecordUsage(env.Ctx, "proj-1", "tester", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, err := e.Repo.ListActorUsage(env.Ctx, "proj-1", "2000-01-01", "2999-12-31", "tester")
+	if err != nil || len(stored) != 0 {
+		t.Fatalf("expected calls without a quota to stay in memory, got %+v %v", stored, err)
+	}
+	now := e.Now()
+	rep, err := e.Usage(env.Ctx, "proj-1", "tester", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil || len(rep.Usage) != 1 || rep.Usage[0].Requests != 3 || rep.Usage[0].Mutations != 3 {
+		t.Fatalf("expected the report to flush buffered calls, got %+v %v", rep, err)
+	}
+	if err := e.FlushUsage(env.Ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = e.Repo.ListActorUsage(env.Ctx, "proj-1", "2000-01-01", "2999-12-31", "tester")
+	if err != nil || len(stored) != 1 || stored[0].Requests != 3 {
+		t.Fatalf("expected a flush not to count calls twice, got %+v %v", stored, err)
+	}
+}
+
+func TestTaskSnapshots(t *testing.T) {
+	env := newTestEnv(t)
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := env.Engine.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: title, ActorID: "tester"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := env.Engine
+	e.Now = func() time.Time { return clock }
+	filter := repo.TaskFilters{ProjectID: "proj-1"}
+
+	// The capture copies the list in the database and reads no rows into the request.
+	snap, err := e.CaptureTaskSnapshot(repo.WithRowBudget(env.Ctx, 1), "tasks:proj-1", "tester", filter)
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if snap.Total != 3 {
+		t.Fatalf("expected 3 captured tasks, got %d", snap.Total)
+	}
+	if _, err := e.CreateTask(env.Ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "late", ActorID: "tester"}); err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for after := 0; after < snap.Total; after += 2 {
+		_, page, err := e.TaskSnapshotPage(env.Ctx, snap.ID, "tasks:proj-1", "tester", after, 2)
+		if err != nil {
+			t.Fatalf("page after %d: %v", after, err)
+		}
+		for _, task := range page {
+			titles = append(titles, task.Title)
+		}
+	}
+	slices.Sort(titles)
+	if !slices.Equal(titles, []string{"a", "b", "c"}) {
+		t.Fatalf("expected the captured tasks only, got %v", titles)
+	}
+	if _, _, err := e.TaskSnapshotPage(env.Ctx, snap.ID, "tasks:proj-1", "other", 0, 2); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected another actor's snapshot to be hidden, got %v", err)
+	}
+
+	// Each actor keeps its most recently read snapshots only.
+	other, err := e.CaptureTaskSnapshot(env.Ctx, "tasks:proj-1", "other", filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for range engine.MaxTaskSnapshotsPerActor {
+		clock = clock.Add(time.Second)
+		s, err := e.CaptureTaskSnapshot(env.Ctx, "tasks:proj-1", "tester", filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.ID)
+	}
+	if _, _, err := e.TaskSnapshotPage(env.Ctx, snap.ID, "tasks:proj-1", "tester", 0, 2); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected the oldest snapshot to be dropped, got %v", err)
+	}
+	for _, id := range append(ids, other.ID) {
+		actor := "tester"
+		if id == other.ID {
+			actor = "other"
+		}
+		if _, _, err := e.TaskSnapshotPage(env.Ctx, id, "tasks:proj-1", actor, 0, 2); err != nil {
+			t.Fatalf("expected snapshot %s to be kept: %v", id, err)
+		}
+	}
+
+	clock = clock.Add(engine.TaskSnapshotTTL + time.Second)
+	if _, _, err := e.TaskSnapshotPage(env.Ctx, ids[0], "tasks:proj-1", "tester", 0, 2); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected an expired snapshot to be gone, got %v", err)
 	}
 }
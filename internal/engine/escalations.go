@@ -0,0 +1,443 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+var (
+	// EscalationConditions lists what escalation rules watch for.
+	EscalationConditions = []string{"task.overdue", "validation.blocked"}
+	// EscalationActions lists what escalation rules do about it.
+	EscalationActions = []string{"notify", "follow_up"}
+)
+
+// EscalationRuleUpdate changes the fields of a rule that are set.
+type EscalationRuleUpdate struct {
+	Description *string
+	Condition   *string
+	After       *string
+	TaskType    *string
+	Action      *string
+	RoleID      *string
+	Enabled     *bool
+}
+
+// CreateEscalationRule adds a rule to the project, which needs escalation.manage.
+func (e Engine) CreateEscalationRule(ctx context.Context, rule domain.EscalationRule, actorID string) (domain.EscalationRule, error) {
+	rule.ID = strings.TrimSpace(rule.ID)
+	if rule.ID == "" {
+		return rule, errors.New("rule id is required")
+	}
+	if !suppliedIDPattern.MatchString(rule.ID) {
+		return rule, fmt.Errorf("invalid rule id %q: use letters, digits, '.', '_', ':' or '-'", rule.ID)
+	}
+	if _, err := e.Repo.GetProject(ctx, rule.ProjectID); err != nil {
+		return rule, err
+	}
+	cfg, err := e.projectConfig(ctx, rule.ProjectID)
+	if err != nil {
+		return rule, err
+	}
+	rule.CreatedBy = actorID
+	rule.CreatedAt = e.now().UTC().Format(time.RFC3339)
+	rule.UpdatedAt = rule.CreatedAt
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rule, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, rule.ProjectID, actorID, "escalation.manage"); err != nil {
+		return rule, err
+	}
+	if _, err := e.Repo.GetEscalationRuleTx(ctx, tx, rule.ProjectID, rule.ID); err == nil {
+		return rule, fmt.Errorf("invalid rule id: rule %s already exists", rule.ID)
+	} else if !errors.Is(err, repo.ErrNotFound) {
+		return rule, err
+	}
+	if rule, err = e.checkEscalationRule(ctx, tx, cfg, rule); err != nil {
+		return rule, err
+	}
+	if err := e.Repo.InsertEscalationRuleTx(ctx, tx, rule); err != nil {
+		return rule, err
+	}
+	if err := e.appendRuleEvent(ctx, tx, "escalation.rule_created", rule, actorID); err != nil {
+		return rule, err
+	}
+	return rule, tx.Commit()
+}
+
+// UpdateEscalationRule changes a rule of the project, which needs escalation.manage.
+func (e Engine) UpdateEscalationRule(ctx context.Context, projectID, ruleID string, u EscalationRuleUpdate, actorID string) (domain.EscalationRule, error) {
+	cfg, err := e.projectConfig(ctx, projectID)
+	if err != nil {
+		return domain.EscalationRule{}, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.EscalationRule{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "escalation.manage"); err != nil {
+		return domain.EscalationRule{}, err
+	}
+	rule, err := e.Repo.GetEscalationRuleTx(ctx, tx, projectID, ruleID)
+	if err != nil {
+		return rule, err
+	}
+	for _, f := range []struct {
+		to   *string
+		from *string
+	}{
+		{&rule.Description, u.Description},
+		{&rule.Condition, u.Condition},
+		{&rule.After, u.After},
+		{&rule.TaskType, u.TaskType},
+		{&rule.Action, u.Action},
+		{&rule.RoleID, u.RoleID},
+	} {
+		if f.from != nil {
+			*f.to = *f.from
+		}
+	}
+	if u.Enabled != nil {
+		rule.Enabled = *u.Enabled
+	}
+	rule.UpdatedAt = e.now().UTC().Format(time.RFC3339)
+	if rule, err = e.checkEscalationRule(ctx, tx, cfg, rule); err != nil {
+		return rule, err
+	}
+	if err := e.Repo.UpdateEscalationRuleTx(ctx, tx, rule); err != nil {
+		return rule, err
+	}
+	if err := e.appendRuleEvent(ctx, tx, "escalation.rule_updated", rule, actorID); err != nil {
+		return rule, err
+	}
+	return rule, tx.Commit()
+}
+
+// DeleteEscalationRule removes a rule of the project, which needs escalation.manage.
+func (e Engine) DeleteEscalationRule(ctx context.Context, projectID, ruleID, actorID string) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "escalation.manage"); err != nil {
+		return err
+	}
+	rule, err := e.Repo.GetEscalationRuleTx(ctx, tx, projectID, ruleID)
+	if err != nil {
+		return err
+	}
+	if err := e.Repo.DeleteEscalationRuleTx(ctx, tx, projectID, ruleID); err != nil {
+		return err
+	}
+	if err := e.appendRuleEvent(ctx, tx, "escalation.rule_deleted", rule, actorID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkEscalationRule trims rule and checks its condition, delay, action, role and task type.
+func (e Engine) checkEscalationRule(ctx context.Context, tx *sql.Tx, cfg *config.Config, rule domain.EscalationRule) (domain.EscalationRule, error) {
+	rule.Description = strings.TrimSpace(rule.Description)
+	rule.After = strings.TrimSpace(rule.After)
+	rule.TaskType = strings.TrimSpace(rule.TaskType)
+	rule.RoleID = strings.TrimSpace(rule.RoleID)
+	if !slices.Contains(EscalationConditions, rule.Condition) {
+		return rule, fmt.Errorf("invalid condition %q: use %s", rule.Condition, strings.Join(EscalationConditions, " or "))
+	}
+	if d, err := time.ParseDuration(rule.After); err != nil || d <= 0 {
+		return rule, fmt.Errorf("invalid after %q: use a positive duration such as 48h", rule.After)
+	}
+	if !slices.Contains(EscalationActions, rule.Action) {
+		return rule, fmt.Errorf("invalid action %q: use %s", rule.Action, strings.Join(EscalationActions, " or "))
+	}
+	if rule.Action == "notify" && rule.RoleID == "" {
+		return rule, errors.New("role_id is required by the notify action")
+	}
+	if rule.RoleID != "" {
+		ok, err := e.Repo.RoleExistsTx(ctx, tx, rule.RoleID)
+		if err != nil {
+			return rule, err
+		}
+		if !ok {
+			return rule, fmt.Errorf("invalid role %q: not defined", rule.RoleID)
+		}
+	}
+	if rule.TaskType != "" {
+		if err := checkTaskType(cfg, rule.TaskType); err != nil {
+			return rule, err
+		}
+	}
+	return rule, nil
+}
+
+func (e Engine) appendRuleEvent(ctx context.Context, tx *sql.Tx, evtType string, rule domain.EscalationRule, actorID string) error {
+	return e.Events.Append(ctx, tx, evtType, rule.ProjectID, "project", rule.ProjectID, actorID, events.EventPayload{
+		"rule_id":   rule.ID,
+		"condition": rule.Condition,
+		"after":     rule.After,
+		"action":    rule.Action,
+		"enabled":   rule.Enabled,
+	})
+}
+
+// Escalation is one rule acting on one task.
+type Escalation struct {
+	RuleID    string `json:"rule_id"`
+	TaskID    string `json:"task_id"`
+	Title     string `json:"title"`
+	Condition string `json:"condition"`
+	Since     string `json:"since" format:"date-time"`
+	Action    string `json:"action"`
+	RoleID    string `json:"role_id,omitempty"`
+	// Notified lists the actors holding RoleID when the rule fired.
+	Notified       []string `json:"notified,omitempty"`
+	Unmet          []string `json:"unmet,omitempty"`
+	FollowUpTaskID string   `json:"follow_up_task_id,omitempty"`
+}
+
+// EscalationReport lists the escalations of a project raised by one run.
+type EscalationReport struct {
+	ProjectID   string       `json:"project_id"`
+	CheckedAt   string       `json:"checked_at" format:"date-time"`
+	Escalations []Escalation `json:"escalations"`
+}
+
+// escalationCandidate is a task in a rule's condition since a given time, pending the
+// requirement check of validation.blocked.
+type escalationCandidate struct {
+	rule  domain.EscalationRule
+	task  domain.Task
+	since string
+}
+
+// RunEscalations applies the project's enabled rules. A rule acts on a task once it has
+// been in the rule's condition for the rule's delay, and once per spell: a task that leaves
+// the condition and comes back can escalate again. Overdue tasks are planned or in
+// progress, without an active lease, and unchanged since their last update or the expiry
+// of their last lease. Blocked tasks are in review with requirements neither attested nor
+// waived. Each escalation records an escalation.triggered event on its task, naming the
+// role to notify and its holders; follow_up rules also create a chore task for it.
+func (e Engine) RunEscalations(ctx context.Context, projectID, actorID string) (EscalationReport, error) {
+	now := e.now().UTC()
+	nowTS := now.Format(time.RFC3339)
+	rep := EscalationReport{ProjectID: projectID, CheckedAt: nowTS, Escalations: []Escalation{}}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return rep, err
+	}
+	rules, err := e.Repo.ListEscalationRules(ctx, projectID)
+	if err != nil {
+		return rep, err
+	}
+	rules = slices.DeleteFunc(rules, func(r domain.EscalationRule) bool { return !r.Enabled })
+	if len(rules) == 0 {
+		return rep, nil
+	}
+	cfg, err := e.projectConfig(ctx, projectID)
+	if err != nil {
+		return rep, err
+	}
+	firings, err := e.Repo.ListEscalationFirings(ctx, projectID)
+	if err != nil {
+		return rep, err
+	}
+	fired := map[[2]string]string{}
+	followUps := map[string]bool{}
+	for _, f := range firings {
+		fired[[2]string{f.RuleID, f.TaskID}] = f.Since
+		if f.FollowUpTaskID != nil {
+			followUps[*f.FollowUpTaskID] = true
+		}
+	}
+	spells := map[string][]escalationCandidate{}
+	for _, cond := range EscalationConditions {
+		if slices.ContainsFunc(rules, func(r domain.EscalationRule) bool { return r.Condition == cond }) {
+			if spells[cond], err = e.escalationSpells(ctx, projectID, cond, now); err != nil {
+				return rep, err
+			}
+		}
+	}
+	var due []escalationCandidate
+	holders := map[string][]string{}
+	for _, rule := range rules {
+		after, err := time.ParseDuration(rule.After)
+		if err != nil {
+			return rep, fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		for _, c := range spells[rule.Condition] {
+			if rule.TaskType != "" && c.task.Type != rule.TaskType {
+				continue
+			}
+			// Follow-ups never get follow-ups of their own, so an idle project stays bounded.
+			if rule.Action == "follow_up" && followUps[c.task.ID] {
+				continue
+			}
+			since, err := time.Parse(time.RFC3339, c.since)
+			if err != nil || now.Sub(since) < after || fired[[2]string{rule.ID, c.task.ID}] == c.since {
+				continue
+			}
+			c.rule = rule
+			due = append(due, c)
+		}
+		if _, ok := holders[rule.RoleID]; rule.RoleID != "" && !ok {
+			if holders[rule.RoleID], err = e.Repo.RoleHolders(ctx, projectID, rule.RoleID, nowTS); err != nil {
+				return rep, err
+			}
+		}
+	}
+	if len(due) == 0 {
+		return rep, nil
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rep, err
+	}
+	defer tx.Rollback()
+	for _, c := range due {
+		esc := Escalation{
+			RuleID:    c.rule.ID,
+			TaskID:    c.task.ID,
+			Title:     c.task.Title,
+			Condition: c.rule.Condition,
+			Since:     c.since,
+			Action:    c.rule.Action,
+			RoleID:    c.rule.RoleID,
+			Notified:  holders[c.rule.RoleID],
+		}
+		if c.rule.Condition == "validation.blocked" {
+			if esc.Unmet, err = e.unmetRequirements(ctx, tx, c.task); err != nil {
+				return rep, err
+			}
+			if len(esc.Unmet) == 0 {
+				continue
+			}
+		}
+		firing := domain.EscalationFiring{RuleID: c.rule.ID, TaskID: c.task.ID, Since: c.since, FiredAt: nowTS}
+		if c.rule.Action == "follow_up" {
+			followUp, err := e.insertTaskTx(ctx, tx, cfg, followUpTask(c.task, esc, actorID))
+			if err != nil {
+				return rep, fmt.Errorf("rule %s: follow-up of task %s: %w", c.rule.ID, c.task.ID, err)
+			}
+			esc.FollowUpTaskID = followUp.ID
+			firing.FollowUpTaskID = &followUp.ID
+		}
+		if err := e.Repo.RecordEscalationFiringTx(ctx, tx, projectID, firing); err != nil {
+			return rep, err
+		}
+		payload := events.EventPayload{
+			"rule_id":   esc.RuleID,
+			"condition": esc.Condition,
+			"after":     c.rule.After,
+			"since":     esc.Since,
+			"action":    esc.Action,
+		}
+		if esc.RoleID != "" {
+			payload["role_id"] = esc.RoleID
+			payload["notified"] = esc.Notified
+		}
+		if len(esc.Unmet) > 0 {
+			payload["unmet"] = esc.Unmet
+		}
+		if esc.FollowUpTaskID != "" {
+			payload["follow_up_task_id"] = esc.FollowUpTaskID
+		}
+		if err := e.Events.Append(ctx, tx, "escalation.triggered", projectID, "task", c.task.ID, actorID, payload); err != nil {
+			return rep, err
+		}
+		rep.Escalations = append(rep.Escalations, esc)
+	}
+	return rep, tx.Commit()
+}
+
+// escalationSpells returns the project's tasks in cond, each with when its spell began.
+func (e Engine) escalationSpells(ctx context.Context, projectID, cond string, now time.Time) ([]escalationCandidate, error) {
+	var res []escalationCandidate
+	if cond == "validation.blocked" {
+		tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, Status: "review"})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			if t.RequiredAttestationsJSON == nil {
+				continue
+			}
+			since, err := e.inReviewSince(ctx, t)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, escalationCandidate{task: t, since: since.UTC().Format(time.RFC3339)})
+		}
+		return res, nil
+	}
+	nowTS := now.Format(time.RFC3339)
+	for _, status := range []string{"planned", "in_progress"} {
+		tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, Status: status})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			since := t.UpdatedAt
+			l, err := e.Repo.GetLease(ctx, t.ID)
+			if err != nil && !errors.Is(err, repo.ErrNotFound) {
+				return nil, err
+			}
+			if err == nil {
+				if l.ExpiresAt > nowTS {
+					continue
+				}
+				since = max(since, l.ExpiresAt)
+			}
+			res = append(res, escalationCandidate{task: t, since: since})
+		}
+	}
+	return res, nil
+}
+
+// followUpTask describes the chore a follow_up rule creates for an escalated task, in the
+// task's iteration.
+func followUpTask(t domain.Task, esc Escalation, actorID string) TaskCreateOptions {
+	opts := TaskCreateOptions{ProjectID: t.ProjectID, Type: "chore", ActorID: actorID}
+	if t.IterationID != nil {
+		opts.IterationID = *t.IterationID
+	}
+	if esc.Condition == "validation.blocked" {
+		opts.Title = fmt.Sprintf("Unblock validation of %s: %s", t.ID, t.Title)
+		opts.Description = fmt.Sprintf("Task %s has been in review since %s without %s (escalation rule %s).", t.ID, esc.Since, strings.Join(esc.Unmet, ", "), esc.RuleID)
+	} else {
+		opts.Title = fmt.Sprintf("Follow up on overdue %s: %s", t.ID, t.Title)
+		opts.Description = fmt.Sprintf("Task %s has been %s without a lease since %s (escalation rule %s).", t.ID, t.Status, esc.Since, esc.RuleID)
+	}
+	return opts
+}
+
+// RunDueEscalations applies the escalation rules of every project, as the system actor.
+func (e Engine) RunDueEscalations(ctx context.Context) ([]EscalationReport, error) {
+	projects, err := e.Repo.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []EscalationReport
+	var errs []error
+	for _, p := range projects {
+		rep, err := e.RunEscalations(ctx, p.ID, systemActorID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+			continue
+		}
+		res = append(res, rep)
+	}
+	return res, errors.Join(errs...)
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"workline/internal/canon"
+	"workline/internal/domain"
+	"workline/internal/evidence"
+	"workline/internal/repo"
+)
+
+// EvidenceFormat identifies the layout of exported evidence bundles.
+const EvidenceFormat = "workline.evidence/v1"
+
+// EvidencePolicy is the task's attestation policy and how it stood when the bundle was built.
+type EvidencePolicy struct {
+	Required  []string `json:"required"`
+	Present   []string `json:"present"`
+	Waived    []string `json:"waived"`
+	Missing   []string `json:"missing"`
+	Satisfied bool     `json:"satisfied"`
+}
+
+// EvidenceAttestation carries an attestation with its payload resolved from the blob store,
+// so the bundle is self-contained.
+type EvidenceAttestation struct {
+	domain.Attestation
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	PayloadBlob string          `json:"payload_blob,omitempty"`
+}
+
+// EvidenceBundle is the signed proof record for one task. Events cover the task and its
+// attestations in log order, with their hash-chain links.
+type EvidenceBundle struct {
+	Format       string                `json:"format"`
+	GeneratedAt  string                `json:"generated_at" format:"date-time"`
+	ProjectID    string                `json:"project_id"`
+	Task         domain.Task           `json:"task"`
+	TaskHash     string                `json:"task_hash"`
+	Policy       EvidencePolicy        `json:"policy"`
+	Attestations []EvidenceAttestation `json:"attestations"`
+	Waivers      []domain.Waiver       `json:"waivers"`
+	Events       []domain.Event        `json:"events"`
+	Signature    *evidence.Signature   `json:"signature,omitempty"`
+}
+
+// ErrNoEvidenceSigner is returned when bundles are requested without a signing key.
+var ErrNoEvidenceSigner = errors.New("evidence signing key not configured")
+
+// TaskEvidence assembles and signs the evidence bundle for a task: the task, its policy
+// snapshot, every attestation including countersignatures, all waivers and the events
+// recorded against them. The project's signing key, when one was rotated in, signs
+// instead of the server key.
+func (e Engine) TaskEvidence(ctx context.Context, taskID string) (EvidenceBundle, error) {
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return EvidenceBundle{}, err
+	}
+	signer, err := e.evidenceSigner(ctx, t.ProjectID)
+	if err != nil {
+		return EvidenceBundle{}, err
+	}
+	b := EvidenceBundle{
+		Format:      EvidenceFormat,
+		GeneratedAt: e.now().UTC().Format(time.RFC3339),
+		ProjectID:   t.ProjectID,
+		Task:        t,
+		TaskHash:    canon.TaskHash(t),
+	}
+	if b.Policy, err = e.evidencePolicy(ctx, t); err != nil {
+		return b, err
+	}
+
+	atts, err := e.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: t.ProjectID, EntityKind: "task", EntityID: t.ID})
+	if err != nil {
+		return b, err
+	}
+	attIDs := make([]string, 0, len(atts))
+	for _, a := range atts {
+		attIDs = append(attIDs, a.ID)
+	}
+	for _, id := range attIDs {
+		countersigns, err := e.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: t.ProjectID, EntityKind: "attestation", EntityID: id})
+		if err != nil {
+			return b, err
+		}
+		atts = append(atts, countersigns...)
+	}
+	sort.SliceStable(atts, func(i, j int) bool {
+		if atts[i].TS != atts[j].TS {
+			return atts[i].TS < atts[j].TS
+		}
+		return atts[i].ID < atts[j].ID
+	})
+	b.Attestations = make([]EvidenceAttestation, 0, len(atts))
+	for _, a := range atts {
+		ea, err := e.evidenceAttestation(ctx, a)
+		if err != nil {
+			return b, err
+		}
+		b.Attestations = append(b.Attestations, ea)
+	}
+
+	waivers, err := e.Repo.ListWaivers(ctx, t.ID)
+	if err != nil {
+		return b, err
+	}
+	slices.Reverse(waivers)
+	b.Waivers = append([]domain.Waiver{}, waivers...)
+
+	taskEvents, err := e.Repo.EntityEvents(ctx, t.ProjectID, "task", []string{t.ID})
+	if err != nil {
+		return b, err
+	}
+	attEvents, err := e.Repo.EntityEvents(ctx, t.ProjectID, "attestation", attIDs)
+	if err != nil {
+		return b, err
+	}
+	b.Events = append(taskEvents, attEvents...)
+	sort.Slice(b.Events, func(i, j int) bool { return b.Events[i].ID < b.Events[j].ID })
+	if b.Events == nil {
+		b.Events = []domain.Event{}
+	}
+
+	sig, err := signer.Sign(b)
+	if err != nil {
+		return b, fmt.Errorf("sign evidence: %w", err)
+	}
+	b.Signature = &sig
+	return b, nil
+}
+
+func (e Engine) evidencePolicy(ctx context.Context, t domain.Task) (EvidencePolicy, error) {
+	p := EvidencePolicy{Required: []string{}, Present: []string{}, Waived: []string{}, Missing: []string{}}
+	if req := currentPolicy(t).Require; req != nil {
+		p.Required = req
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+	found, err := e.presentRequirements(ctx, tx, t.ID, p.Required)
+	if err != nil {
+		return p, err
+	}
+	active, err := e.Repo.ListActiveWaiversTx(ctx, tx, t.ID, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return p, err
+	}
+	waived := map[string]bool{}
+	for _, w := range active {
+		waived[w.Kind] = true
+	}
+	for _, req := range p.Required {
+		switch {
+		case found[req]:
+			p.Present = append(p.Present, req)
+		case waived[req]:
+			p.Waived = append(p.Waived, req)
+		default:
+			p.Missing = append(p.Missing, req)
+		}
+	}
+	p.Satisfied = len(p.Missing) == 0
+	return p, nil
+}
+
+// evidenceAttestation inlines the payload, fetching offloaded payloads from the blob store.
+// Payloads that are not JSON stay in payload_json as stored.
+func (e Engine) evidenceAttestation(ctx context.Context, a domain.Attestation) (EvidenceAttestation, error) {
+	ea := EvidenceAttestation{Attestation: a}
+	if a.PayloadJSON == "" || !json.Valid([]byte(a.PayloadJSON)) {
+		return ea, nil
+	}
+	if ref, ok := parseBlobRef(a.PayloadJSON); ok && e.Blobs != nil {
+		data, err := e.Blobs.Get(ctx, ref.Blob)
+		if err != nil {
+			return ea, fmt.Errorf("attestation %s payload: %w", a.ID, err)
+		}
+		ea.PayloadBlob = ref.Blob
+		if !json.Valid(data) {
+			ea.PayloadJSON = string(data)
+			return ea, nil
+		}
+		ea.Payload = data
+		ea.PayloadJSON = ""
+		return ea, nil
+	}
+	ea.Payload = json.RawMessage(a.PayloadJSON)
+	ea.PayloadJSON = ""
+	return ea, nil
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"workline/internal/config"
+	"workline/internal/repo"
+)
+
+// FeatureDisabledError reports an experimental feature switched off by a project's flags.
+type FeatureDisabledError struct {
+	Feature   string
+	ProjectID string
+}
+
+func (e FeatureDisabledError) Error() string {
+	return fmt.Sprintf("feature %s is disabled for project %s", e.Feature, e.ProjectID)
+}
+
+// FeatureState is an experimental feature as a project's flags leave it.
+type FeatureState struct {
+	config.Feature
+	Enabled bool
+}
+
+// ProjectFeatures lists every experimental feature with its state in the project, falling
+// back to the engine config for projects without a stored config.
+func (e Engine) ProjectFeatures(ctx context.Context, projectID string) ([]FeatureState, error) {
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	cfg, err := e.projectConfig(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]FeatureState, 0, len(config.Features))
+	for _, f := range config.Features {
+		res = append(res, FeatureState{Feature: f, Enabled: cfg.FeatureEnabled(f.Name)})
+	}
+	return res, nil
+}
+
+// RequireFeature returns FeatureDisabledError when the project's flags switch feature off.
+// It reads the project config, so it must not run inside a transaction.
+func (e Engine) RequireFeature(ctx context.Context, projectID, feature string) error {
+	cfg, err := e.projectConfig(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if !cfg.FeatureEnabled(feature) {
+		return FeatureDisabledError{Feature: feature, ProjectID: projectID}
+	}
+	return nil
+}
+
+func (e Engine) projectConfig(ctx context.Context, projectID string) (*config.Config, error) {
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		if e.Config == nil {
+			return &config.Config{}, nil
+		}
+		return e.Config, nil
+	}
+	return cfg, err
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+)
+
+// Changes a frozen iteration refuses without an override.
+const (
+	FreezeAddTask      = "add_task"
+	FreezeLoosenPolicy = "loosen_policy"
+)
+
+// IterationFrozenError refuses a change to the scope of a frozen iteration made without a
+// freeze override.
+type IterationFrozenError struct {
+	IterationID string
+	TaskID      string
+	Change      string
+}
+
+func (e IterationFrozenError) Error() string {
+	what := "adding tasks to it"
+	if e.Change == FreezeLoosenPolicy {
+		what = "loosening the policy of its tasks"
+	}
+	return fmt.Sprintf("iteration %s is frozen: %s needs a freeze override with a reason", e.IterationID, what)
+}
+
+// SetIterationFrozen freezes a running iteration, or unfreezes it. While it runs frozen, new
+// tasks cannot join it and its tasks' policies cannot drop requirements, unless an actor
+// holding iteration.freeze.override gives a reason. Records iteration.frozen or
+// iteration.unfrozen; needs iteration.freeze. Freezing a frozen iteration, or unfreezing an
+// unfrozen one, changes nothing.
+func (e Engine) SetIterationFrozen(ctx context.Context, id string, frozen bool, actorID string) (domain.Iteration, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Iteration{}, err
+	}
+	defer tx.Rollback()
+	it, err := e.Repo.GetIterationTx(ctx, tx, id)
+	if err != nil {
+		return it, err
+	}
+	if err := e.requirePermission(ctx, tx, it.ProjectID, actorID, "iteration.freeze"); err != nil {
+		return it, err
+	}
+	if frozen == (it.FrozenAt != nil) {
+		return it, nil
+	}
+	evtType := "iteration.unfrozen"
+	it.FrozenAt, it.FrozenBy = nil, nil
+	if frozen {
+		if it.Status != "running" {
+			return it, fmt.Errorf("invalid freeze: iteration %s is %s; only running iterations can be frozen", id, it.Status)
+		}
+		now := e.now().UTC().Format(time.RFC3339)
+		evtType = "iteration.frozen"
+		it.FrozenAt, it.FrozenBy = &now, &actorID
+	}
+	if err := e.Repo.SetIterationFrozenTx(ctx, tx, id, it.FrozenAt, it.FrozenBy); err != nil {
+		return it, err
+	}
+	if err := e.Events.Append(ctx, tx, evtType, it.ProjectID, "iteration", id, actorID, events.EventPayload{"status": it.Status}); err != nil {
+		return it, err
+	}
+	return it, tx.Commit()
+}
+
+// checkFreezeTx lets change to task taskID of iterationID through unless the iteration is
+// running frozen. A frozen iteration needs an override reason from an actor holding
+// iteration.freeze.override; the override is recorded as iteration.freeze.overridden.
+func (e Engine) checkFreezeTx(ctx context.Context, tx *sql.Tx, iterationID *string, taskID, change, reason, actorID string) error {
+	if iterationID == nil {
+		return nil
+	}
+	it, err := e.Repo.GetIterationTx(ctx, tx, *iterationID)
+	if err != nil {
+		return err
+	}
+	if it.FrozenAt == nil || it.Status != "running" {
+		return nil
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return IterationFrozenError{IterationID: it.ID, TaskID: taskID, Change: change}
+	}
+	if err := e.requirePermission(ctx, tx, it.ProjectID, actorID, "iteration.freeze.override"); err != nil {
+		return err
+	}
+	return e.Events.Append(ctx, tx, "iteration.freeze.overridden", it.ProjectID, "iteration", it.ID, actorID, events.EventPayload{
+		"task_id": taskID,
+		"change":  change,
+		"reason":  reason,
+	})
+}
+
+// loosened reports whether next drops any requirement of prev.
+func loosened(prev, next []string) bool {
+	for _, req := range prev {
+		if !slices.Contains(next, req) {
+			return true
+		}
+	}
+	return false
+}
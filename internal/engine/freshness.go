@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// StaleAttestation is a required attestation whose freshness window has passed.
+type StaleAttestation struct {
+	Requirement   string `json:"requirement"`
+	AttestationID string `json:"attestation_id"`
+	Kind          string `json:"kind"`
+	AttestedAt    string `json:"attested_at" format:"date-time"`
+	FreshUntil    string `json:"fresh_until" format:"date-time"`
+}
+
+// StaleTask is a task waiting in review on attestations that need re-validation.
+type StaleTask struct {
+	TaskID        string             `json:"task_id"`
+	Title         string             `json:"title"`
+	InReviewSince string             `json:"in_review_since" format:"date-time"`
+	Stale         []StaleAttestation `json:"stale"`
+	// Notified is false when an earlier check already reported the same attestations.
+	Notified bool `json:"notified"`
+}
+
+// FreshnessReport lists the stale tasks of a project found by one check.
+type FreshnessReport struct {
+	ProjectID string      `json:"project_id"`
+	CheckedAt string      `json:"checked_at" format:"date-time"`
+	Tasks     []StaleTask `json:"tasks"`
+}
+
+// CheckAttestationFreshness looks at the project's tasks that have been in review for
+// attestations.freshness.review_after and flags those whose newest attestation for a
+// required kind is older than the kind's window. Each newly stale set of attestations is
+// recorded once as a validation.stale event on the task; waived requirements and kinds
+// never attested are left out, they are missing rather than stale.
+func (e Engine) CheckAttestationFreshness(ctx context.Context, projectID, actorID string) (FreshnessReport, error) {
+	now := e.now().UTC()
+	rep := FreshnessReport{ProjectID: projectID, CheckedAt: now.Format(time.RFC3339), Tasks: []StaleTask{}}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return rep, err
+	}
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) && e.Config != nil {
+		cfg = e.Config
+	} else if err != nil {
+		return rep, err
+	}
+	fresh := cfg.Attestations.Freshness
+	if len(fresh.Windows) == 0 {
+		return rep, nil
+	}
+	tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, Status: "review"})
+	if err != nil {
+		return rep, err
+	}
+	for _, t := range tasks {
+		since, err := e.inReviewSince(ctx, t)
+		if err != nil {
+			return rep, err
+		}
+		if now.Sub(since) < fresh.ReviewThreshold() {
+			continue
+		}
+		stale, err := e.staleAttestations(ctx, cfg, t, now)
+		if err != nil {
+			return rep, err
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		st := StaleTask{TaskID: t.ID, Title: t.Title, InReviewSince: since.Format(time.RFC3339), Stale: stale}
+		if st.Notified, err = e.staleSetChanged(ctx, t, since, stale); err != nil {
+			return rep, err
+		}
+		rep.Tasks = append(rep.Tasks, st)
+	}
+	if !slices.ContainsFunc(rep.Tasks, func(st StaleTask) bool { return st.Notified }) {
+		return rep, nil
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rep, err
+	}
+	defer tx.Rollback()
+	for _, st := range rep.Tasks {
+		if !st.Notified {
+			continue
+		}
+		if err := e.Events.Append(ctx, tx, "validation.stale", projectID, "task", st.TaskID, actorID, events.EventPayload{
+			"in_review_since": st.InReviewSince,
+			"stale":           st.Stale,
+			"attestation_ids": staleIDs(st.Stale),
+		}); err != nil {
+			return rep, err
+		}
+	}
+	return rep, tx.Commit()
+}
+
+// CheckDueFreshness runs the freshness check on every project, as the system actor.
+func (e Engine) CheckDueFreshness(ctx context.Context) ([]FreshnessReport, error) {
+	projects, err := e.Repo.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []FreshnessReport
+	var errs []error
+	for _, p := range projects {
+		rep, err := e.CheckAttestationFreshness(ctx, p.ID, systemActorID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+			continue
+		}
+		res = append(res, rep)
+	}
+	return res, errors.Join(errs...)
+}
+
+// inReviewSince returns when the task last moved to review, falling back to its last
+// update when the move predates the retained events.
+func (e Engine) inReviewSince(ctx context.Context, t domain.Task) (time.Time, error) {
+	evts, err := e.Repo.LatestEvents(ctx, 100, t.ProjectID, "task.updated", "task", t.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ts := t.UpdatedAt
+	for _, evt := range evts {
+		var payload struct {
+			ToStatus string `json:"to_status"`
+		}
+		if json.Unmarshal([]byte(evt.Payload), &payload) == nil && payload.ToStatus == "review" {
+			ts = evt.TS
+			break
+		}
+	}
+	return time.Parse(time.RFC3339, ts)
+}
+
+// staleAttestations compares the newest attestation meeting each windowed requirement
+// kind of t with the kind's window.
+func (e Engine) staleAttestations(ctx context.Context, cfg *config.Config, t domain.Task, now time.Time) ([]StaleAttestation, error) {
+	if t.RequiredAttestationsJSON == nil {
+		return nil, nil
+	}
+	var required []string
+	if err := json.Unmarshal([]byte(*t.RequiredAttestationsJSON), &required); err != nil {
+		return nil, err
+	}
+	waivers, err := e.Repo.ListWaivers(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	nowTS := now.Format(time.RFC3339)
+	var stale []StaleAttestation
+	for _, req := range required {
+		kind, _ := config.SplitRequirement(req)
+		window, ok := cfg.Attestations.Freshness.Window(kind)
+		if !ok || slices.ContainsFunc(waivers, func(w domain.Waiver) bool { return w.Kind == req && w.ExpiresAt > nowTS }) {
+			continue
+		}
+		atts, err := e.Repo.ListAttestations(ctx, repo.AttestationFilters{EntityKind: "task", EntityID: t.ID, Kinds: cfg.SatisfyingKinds(kind, now), Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(atts) == 0 {
+			continue
+		}
+		attested, err := time.Parse(time.RFC3339, atts[0].TS)
+		if err != nil {
+			return nil, err
+		}
+		if until := attested.Add(window); until.Before(now) {
+			stale = append(stale, StaleAttestation{
+				Requirement:   req,
+				AttestationID: atts[0].ID,
+				Kind:          atts[0].Kind,
+				AttestedAt:    atts[0].TS,
+				FreshUntil:    until.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	return stale, nil
+}
+
+// staleSetChanged reports whether stale differs from the attestations the task's last
+// validation.stale event reported during its current review, so each stale set is
+// announced once per review.
+func (e Engine) staleSetChanged(ctx context.Context, t domain.Task, since time.Time, stale []StaleAttestation) (bool, error) {
+	evts, err := e.Repo.LatestEvents(ctx, 1, t.ProjectID, "validation.stale", "task", t.ID)
+	if err != nil || len(evts) == 0 {
+		return err == nil, err
+	}
+	if ts, err := time.Parse(time.RFC3339, evts[0].TS); err != nil || ts.Before(since) {
+		return true, nil
+	}
+	var payload struct {
+		AttestationIDs []string `json:"attestation_ids"`
+	}
+	if err := json.Unmarshal([]byte(evts[0].Payload), &payload); err != nil {
+		return true, nil
+	}
+	return !slices.Equal(payload.AttestationIDs, staleIDs(stale)), nil
+}
+
+func staleIDs(stale []StaleAttestation) []string {
+	ids := make([]string, 0, len(stale))
+	for _, s := range stale {
+		ids = append(ids, s.AttestationID)
+	}
+	slices.Sort(ids)
+	return ids
+}
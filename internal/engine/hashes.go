@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"context"
+
+	"workline/internal/canon"
+	"workline/internal/repo"
+)
+
+// HashMismatch is an entity whose stored content hash no longer matches its content.
+type HashMismatch struct {
+	EntityKind string `json:"entity_kind"`
+	EntityID   string `json:"entity_id"`
+	Stored     string `json:"stored"`
+	Computed   string `json:"computed"`
+}
+
+// VerifyContentHashes recomputes the content hash of every task, decision and attestation
+// in the project and returns those that differ from the recorded hash, i.e. rows edited
+// outside the engine. Rows without a recorded hash cannot be verified and are returned
+// with an empty Stored hash.
+func (e Engine) VerifyContentHashes(ctx context.Context, projectID string) ([]HashMismatch, error) {
+	var res []HashMismatch
+	check := func(kind, id, stored, computed string) {
+		if stored != computed {
+			res = append(res, HashMismatch{EntityKind: kind, EntityID: id, Stored: stored, Computed: computed})
+		}
+	}
+	tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		check("task", t.ID, t.ContentHash, canon.TaskHash(t))
+	}
+	decisions, err := e.Repo.ListDecisions(ctx, repo.DecisionFilters{ProjectID: projectID})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range decisions {
+		check("decision", d.ID, d.ContentHash, canon.DecisionHash(d))
+	}
+	atts, err := e.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: projectID})
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range atts {
+		check("attestation", a.ID, a.ContentHash, canon.AttestationHash(a))
+	}
+	return res, nil
+}
+
+// EventChainReport is the outcome of walking a project's event chain.
+type EventChainReport struct {
+	ProjectID string `json:"project_id"`
+	Valid     bool   `json:"valid"`
+	// Checked counts chained events verified before the walk ended.
+	Checked int `json:"checked"`
+	// Unchained counts events written before the chain existed.
+	Unchained int    `json:"unchained"`
+	HeadHash  string `json:"head_hash,omitempty"`
+	BrokenAt  int64  `json:"broken_at,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// verifyBatch is how many events VerifyEventChain reads per query.
+const verifyBatch = 500
+
+// VerifyEventChain walks the project's events oldest first and checks that each one links to
+// its predecessor and that its hash matches its content. The walk stops at the first broken
+// link, which is reported with its event id.
+func (e Engine) VerifyEventChain(ctx context.Context, projectID string) (EventChainReport, error) {
+	report := EventChainReport{ProjectID: projectID, Valid: true}
+	var afterID int64
+	prev := ""
+	chained := false
+	for {
+		batch, err := e.Repo.EventsAfter(ctx, projectID, afterID, verifyBatch)
+		if err != nil {
+			return report, err
+		}
+		for _, evt := range batch {
+			afterID = evt.ID
+			reason := ""
+			switch {
+			case evt.ThisHash == "" && !chained:
+				report.Unchained++
+				continue
+			case evt.ThisHash == "":
+				reason = "missing this_hash"
+			case evt.PrevHash != prev:
+				reason = "prev_hash does not match the previous event"
+			case canon.EventHash(prev, evt) != evt.ThisHash:
+				reason = "this_hash does not match the event content"
+			}
+			if reason != "" {
+				report.Valid = false
+				report.BrokenAt = evt.ID
+				report.Reason = reason
+				return report, nil
+			}
+			chained = true
+			prev = evt.ThisHash
+			report.Checked++
+			report.HeadHash = prev
+		}
+		if len(batch) < verifyBatch {
+			return report, nil
+		}
+	}
+}
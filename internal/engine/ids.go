@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/internal/config"
+)
+
+var suppliedIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+
+// assignID resolves the ID for a new row in table according to the project's
+// ID scheme. Supplied IDs are honoured for every strategy and required for
+// client; all IDs are checked for uniqueness inside tx.
+func (e Engine) assignID(ctx context.Context, tx *sql.Tx, scheme config.IDScheme, projectID, table, supplied string, fallback func() string) (string, error) {
+	entity := map[string]string{"tasks": "task", "attestations": "attestation"}[table]
+	if supplied != "" {
+		if !suppliedIDPattern.MatchString(supplied) {
+			return "", fmt.Errorf("invalid id %q: use letters, digits, '.', '_', ':' or '-'", supplied)
+		}
+		exists, err := e.Repo.IDExistsTx(ctx, tx, table, supplied)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "", fmt.Errorf("invalid id: %s %s already exists", entity, supplied)
+		}
+		return supplied, nil
+	}
+	switch scheme.Strategy {
+	case "client":
+		return "", fmt.Errorf("id required: project %s uses client-supplied %s ids", projectID, entity)
+	case "sequential":
+		for {
+			n, err := e.Repo.NextSequenceTx(ctx, tx, projectID, entity)
+			if err != nil {
+				return "", err
+			}
+			id := scheme.Prefix + "-" + strconv.FormatInt(n, 10)
+			exists, err := e.Repo.IDExistsTx(ctx, tx, table, id)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return id, nil
+			}
+		}
+	case "ulid":
+		return newULID(e.now()), nil
+	default:
+		if fallback != nil {
+			return fallback(), nil
+		}
+		return uuid.New().String(), nil
+	}
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a 26-character ULID: 48-bit millisecond timestamp followed by 80 random bits.
+func newULID(t time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	_, _ = rand.Read(b[6:])
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	// 128 bits encode as 26 base32 digits; the leading digit carries the top 3 bits.
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// NotificationKinds lists what lands in an actor's inbox.
+var NotificationKinds = []string{"mention", "lease_expiring", "attestation_requested"}
+
+// DefaultLeaseWarning is how long before a lease expires its holder is notified.
+const DefaultLeaseWarning = 5 * time.Minute
+
+const (
+	// inboxChannel is the notification cursor the inbox reads the event log with.
+	inboxChannel   = "@inbox"
+	inboxBatchSize = 500
+)
+
+// inboxReview is a task that entered review, checked for the attestations it still needs.
+type inboxReview struct {
+	evt  domain.Event
+	task domain.Task
+}
+
+// CollectNotifications fills the inboxes of a project's members from the events recorded
+// since the last collection and from its leases, and returns how many notifications it
+// added. An actor is notified when another actor mentions them as @actor in a task, a
+// handoff note or a decision (task.mentioned and decision.mentioned events); when a lease
+// they hold expires within leaseWarning, once per expiry; and when a task enters review
+// still needing an attestation kind one of their roles may record. Only actors holding a
+// role in the project are notified, and never of their own actions. The first collection
+// starts at the head of the event log instead of replaying history.
+func (e Engine) CollectNotifications(ctx context.Context, projectID string, leaseWarning time.Duration) (int, error) {
+	now := e.now().UTC()
+	nowTS := now.Format(time.RFC3339)
+	cursor, err := e.Repo.GetNotificationCursor(ctx, projectID, inboxChannel)
+	fresh := errors.Is(err, repo.ErrNotFound)
+	if fresh {
+		cursor, err = e.Repo.MaxEventID(ctx, projectID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var evts []domain.Event
+	if !fresh {
+		if evts, err = e.Repo.EventsAfter(ctx, projectID, cursor, inboxBatchSize); err != nil {
+			return 0, err
+		}
+	}
+	members, err := e.Repo.ListMembers(ctx, projectID, nowTS, 0, "")
+	if err != nil {
+		return 0, err
+	}
+	isMember := map[string]bool{}
+	for _, m := range members {
+		isMember[m.ActorID] = true
+	}
+	var pending []domain.Notification
+	var reviews []inboxReview
+	for _, evt := range evts {
+		for _, actorID := range mentionsOf(evt) {
+			if !isMember[actorID] || actorID == evt.ActorID {
+				continue
+			}
+			pending = append(pending, domain.Notification{
+				ActorID:    actorID,
+				Kind:       "mention",
+				EntityKind: evt.EntityKind,
+				EntityID:   evt.EntityID,
+				EventID:    &evt.ID,
+				Message:    mentionMessage(evt),
+				DedupeKey:  fmt.Sprintf("mention:%d", evt.ID),
+			})
+		}
+		if !entersReview(evt) {
+			continue
+		}
+		t, err := e.Repo.GetTask(ctx, evt.EntityID)
+		if errors.Is(err, repo.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if t.Status == "review" {
+			reviews = append(reviews, inboxReview{evt: evt, task: t})
+		}
+	}
+	if leaseWarning > 0 {
+		leases, err := e.Repo.ExpiringLeases(ctx, projectID, nowTS, now.Add(leaseWarning).Format(time.RFC3339))
+		if err != nil {
+			return 0, err
+		}
+		for _, l := range leases {
+			if !isMember[l.OwnerID] {
+				continue
+			}
+			pending = append(pending, domain.Notification{
+				ActorID:    l.OwnerID,
+				Kind:       "lease_expiring",
+				EntityKind: "task",
+				EntityID:   l.TaskID,
+				Message:    fmt.Sprintf("Your lease on task %s expires at %s", l.TaskID, l.ExpiresAt),
+				DedupeKey:  "lease_expiring:" + l.TaskID + ":" + l.ExpiresAt,
+			})
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if len(reviews) > 0 {
+		authorities, err := e.Repo.AttestationAuthoritiesTx(ctx, tx, projectID)
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range reviews {
+			unmet, err := e.unmetRequirements(ctx, tx, r.task)
+			if err != nil {
+				return 0, err
+			}
+			for _, m := range members {
+				if m.ActorID == r.evt.ActorID {
+					continue
+				}
+				var kinds []string
+				for _, kind := range unmet {
+					if slices.ContainsFunc(m.Roles, func(g domain.RoleGrant) bool { return slices.Contains(authorities[kind], g.RoleID) }) {
+						kinds = append(kinds, kind)
+					}
+				}
+				if len(kinds) == 0 {
+					continue
+				}
+				pending = append(pending, domain.Notification{
+					ActorID:    m.ActorID,
+					Kind:       "attestation_requested",
+					EntityKind: "task",
+					EntityID:   r.task.ID,
+					EventID:    &r.evt.ID,
+					Message:    fmt.Sprintf("Task %s is in review and needs %s", r.task.ID, strings.Join(kinds, ", ")),
+					DedupeKey:  fmt.Sprintf("attestation_requested:%s:%d", r.task.ID, r.evt.ID),
+				})
+			}
+		}
+	}
+	added := 0
+	for _, n := range pending {
+		n.ProjectID = projectID
+		n.CreatedAt = nowTS
+		ok, err := e.Repo.InsertNotificationTx(ctx, tx, n)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			added++
+		}
+	}
+	if len(evts) > 0 {
+		cursor = evts[len(evts)-1].ID
+	}
+	if fresh || len(evts) > 0 {
+		if err := e.Repo.SetNotificationCursorTx(ctx, tx, projectID, inboxChannel, cursor, nowTS); err != nil {
+			return 0, err
+		}
+	}
+	return added, tx.Commit()
+}
+
+// entersReview reports whether evt moved a task into review.
+func entersReview(evt domain.Event) bool {
+	if evt.EntityKind != "task" {
+		return false
+	}
+	switch evt.Type {
+	case "task.completion.submitted":
+		return true
+	case "task.updated":
+		var p struct {
+			From string `json:"from_status"`
+			To   string `json:"to_status"`
+		}
+		_ = json.Unmarshal([]byte(evt.Payload), &p)
+		return p.To == "review" && p.From != "review"
+	}
+	return false
+}
+
+// mentionsOf returns the actors a task.mentioned or decision.mentioned event names.
+func mentionsOf(evt domain.Event) []string {
+	if evt.Type != "task.mentioned" && evt.Type != "decision.mentioned" {
+		return nil
+	}
+	var p struct {
+		Mentioned []string `json:"mentioned"`
+	}
+	_ = json.Unmarshal([]byte(evt.Payload), &p)
+	return p.Mentioned
+}
+
+func mentionMessage(evt domain.Event) string {
+	var p struct {
+		Source string `json:"source"`
+	}
+	_ = json.Unmarshal([]byte(evt.Payload), &p)
+	if p.Source == "handoff_note" {
+		return fmt.Sprintf("%s mentioned you in a handoff note on task %s", evt.ActorID, evt.EntityID)
+	}
+	return fmt.Sprintf("%s mentioned you in %s %s", evt.ActorID, evt.EntityKind, evt.EntityID)
+}
+
+// CollectAllNotifications runs CollectNotifications for every project.
+func (e Engine) CollectAllNotifications(ctx context.Context, leaseWarning time.Duration) (int, error) {
+	projects, err := e.Repo.ListProjects(ctx)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	var errs []error
+	for _, p := range projects {
+		n, err := e.CollectNotifications(ctx, p.ID, leaseWarning)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+			continue
+		}
+		added += n
+	}
+	return added, errors.Join(errs...)
+}
+
+// ReadNotification marks one of the actor's notifications read; needs notification.read.
+func (e Engine) ReadNotification(ctx context.Context, projectID string, id int64, actorID string) (domain.Notification, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Notification{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "notification.read"); err != nil {
+		return domain.Notification{}, err
+	}
+	n, err := e.Repo.MarkNotificationReadTx(ctx, tx, projectID, actorID, id, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return n, err
+	}
+	return n, tx.Commit()
+}
+
+// ReadAllNotifications marks every unread notification of the actor in the project read
+// and returns how many it marked; needs notification.read.
+func (e Engine) ReadAllNotifications(ctx context.Context, projectID, actorID string) (int, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "notification.read"); err != nil {
+		return 0, err
+	}
+	n, err := e.Repo.MarkAllNotificationsReadTx(ctx, tx, projectID, actorID, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// SetEmailPreferences stores where, and for which of config.EmailEvents, email notification
+// channels mail the actor, registering the actor if needed. An empty email stops the mails
+// and keeps the events for later.
+func (e Engine) SetEmailPreferences(ctx context.Context, prefs domain.EmailPreferences) (domain.EmailPreferences, error) {
+	if prefs.Email != "" {
+		addr, err := mail.ParseAddress(prefs.Email)
+		if err != nil || addr.Address != prefs.Email {
+			return prefs, fmt.Errorf("invalid email %q: use a bare address such as ada@example.com", prefs.Email)
+		}
+	}
+	events := []string{}
+	for _, evt := range prefs.Events {
+		if !slices.Contains(config.EmailEvents, evt) {
+			return prefs, fmt.Errorf("invalid email event %q: use one of %s", evt, strings.Join(config.EmailEvents, ", "))
+		}
+		if !slices.Contains(events, evt) {
+			events = append(events, evt)
+		}
+	}
+	prefs.Events = events
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return prefs, err
+	}
+	defer tx.Rollback()
+	if err := e.Repo.EnsureActor(ctx, tx, prefs.ActorID, e.now().UTC().Format(time.RFC3339)); err != nil {
+		return prefs, err
+	}
+	if err := e.Repo.SetEmailPreferencesTx(ctx, tx, prefs); err != nil {
+		return prefs, err
+	}
+	return prefs, tx.Commit()
+}
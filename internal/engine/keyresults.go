@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// KeyResultPatch changes the key result named by Metric. Nil fields are kept; a new metric
+// needs Target. Setting Current by hand detaches the value from its last attestation.
+// Remove drops the key result.
+type KeyResultPatch struct {
+	Metric     string
+	Target     *float64
+	Current    *float64
+	Direction  *string
+	SourceKind *string
+	Remove     bool
+}
+
+// keyResultMet reports whether current reaches target in the key result's direction.
+func keyResultMet(kr domain.KeyResult) bool {
+	if kr.Direction == "decrease" {
+		return kr.Current <= kr.Target
+	}
+	return kr.Current >= kr.Target
+}
+
+// normalizeKeyResults checks metrics are named once and directions known, defaults the
+// direction to increase and refreshes Met.
+func normalizeKeyResults(krs []domain.KeyResult) ([]domain.KeyResult, error) {
+	seen := map[string]bool{}
+	for i := range krs {
+		kr := &krs[i]
+		kr.Metric = strings.TrimSpace(kr.Metric)
+		if kr.Metric == "" {
+			return nil, errors.New("invalid key result: metric is required")
+		}
+		if seen[kr.Metric] {
+			return nil, fmt.Errorf("invalid key result: metric %s is listed twice", kr.Metric)
+		}
+		seen[kr.Metric] = true
+		switch kr.Direction {
+		case "":
+			kr.Direction = "increase"
+		case "increase", "decrease":
+		default:
+			return nil, fmt.Errorf("invalid key result %s: direction must be increase or decrease", kr.Metric)
+		}
+		kr.Met = keyResultMet(*kr)
+	}
+	return krs, nil
+}
+
+// UnmetKeyResults returns the metrics of the key results that are not met.
+func UnmetKeyResults(krs []domain.KeyResult) []string {
+	var unmet []string
+	for _, kr := range krs {
+		if !keyResultMet(kr) {
+			unmet = append(unmet, kr.Metric)
+		}
+	}
+	return unmet
+}
+
+// UpdateIterationKeyResults applies patches to the key results of an iteration in order and
+// records iteration.key_results.updated.
+func (e Engine) UpdateIterationKeyResults(ctx context.Context, iterationID string, patches []KeyResultPatch, actorID string) (domain.Iteration, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Iteration{}, err
+	}
+	defer tx.Rollback()
+	it, err := e.Repo.GetIterationTx(ctx, tx, iterationID)
+	if err != nil {
+		return it, err
+	}
+	if err := e.requirePermission(ctx, tx, it.ProjectID, actorID, "iteration.update"); err != nil {
+		return it, err
+	}
+	krs := append([]domain.KeyResult{}, it.KeyResults...)
+	var changed []string
+	for _, p := range patches {
+		idx := -1
+		for i, kr := range krs {
+			if kr.Metric == p.Metric {
+				idx = i
+			}
+		}
+		if p.Remove {
+			if idx < 0 {
+				return it, fmt.Errorf("invalid key result: iteration %s has no metric %s", it.ID, p.Metric)
+			}
+			krs = append(krs[:idx], krs[idx+1:]...)
+			changed = append(changed, p.Metric)
+			continue
+		}
+		if idx < 0 {
+			if p.Target == nil {
+				return it, fmt.Errorf("invalid key result %s: target is required", p.Metric)
+			}
+			krs = append(krs, domain.KeyResult{Metric: p.Metric})
+			idx = len(krs) - 1
+		}
+		kr := &krs[idx]
+		if p.Target != nil {
+			kr.Target = *p.Target
+		}
+		if p.Current != nil {
+			kr.Current = *p.Current
+			kr.AttestationID = ""
+		}
+		if p.Direction != nil {
+			kr.Direction = *p.Direction
+		}
+		if p.SourceKind != nil {
+			kr.SourceKind = *p.SourceKind
+		}
+		changed = append(changed, p.Metric)
+	}
+	krs, err = normalizeKeyResults(krs)
+	if err != nil {
+		return it, err
+	}
+	if err := e.Repo.SetIterationKeyResultsTx(ctx, tx, it.ID, krs); err != nil {
+		return it, err
+	}
+	if err := e.Events.Append(ctx, tx, "iteration.key_results.updated", it.ProjectID, "iteration", it.ID, actorID, events.EventPayload{
+		"metrics":     changed,
+		"key_results": krs,
+	}); err != nil {
+		return it, err
+	}
+	if err := tx.Commit(); err != nil {
+		return it, err
+	}
+	it.KeyResults = krs
+	return it, nil
+}
+
+// deriveKeyResultsTx sets the current value of the iteration's key results sourced from
+// att.Kind. The value is the payload field named after the metric, else its value field;
+// payloads without a number, or offloaded to blob storage, leave the key result as is.
+func (e Engine) deriveKeyResultsTx(ctx context.Context, tx *sql.Tx, att domain.Attestation, actorID string) error {
+	it, err := e.Repo.GetIterationTx(ctx, tx, att.EntityID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var payload map[string]any
+	if att.PayloadJSON == "" || json.Unmarshal([]byte(att.PayloadJSON), &payload) != nil {
+		return nil
+	}
+	var changed []string
+	for i := range it.KeyResults {
+		kr := &it.KeyResults[i]
+		if kr.SourceKind != att.Kind {
+			continue
+		}
+		value, ok := payload[kr.Metric].(float64)
+		if !ok {
+			if value, ok = payload["value"].(float64); !ok {
+				continue
+			}
+		}
+		kr.Current = value
+		kr.AttestationID = att.ID
+		kr.Met = keyResultMet(*kr)
+		changed = append(changed, kr.Metric)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := e.Repo.SetIterationKeyResultsTx(ctx, tx, it.ID, it.KeyResults); err != nil {
+		return err
+	}
+	return e.Events.Append(ctx, tx, "iteration.key_results.updated", it.ProjectID, "iteration", it.ID, actorID, events.EventPayload{
+		"metrics":        changed,
+		"key_results":    it.KeyResults,
+		"attestation_id": att.ID,
+	})
+}
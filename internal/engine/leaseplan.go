@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// TaskLease returns the task lease with the plan its owner declared. The owner may read
+// it; anyone else needs lease.plan.read.
+func (e Engine) TaskLease(ctx context.Context, taskID, actorID string) (domain.Lease, error) {
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return domain.Lease{}, err
+	}
+	defer tx.Rollback()
+	l, err := e.Repo.GetLeaseTx(ctx, tx, taskID)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		return domain.Lease{}, err
+	}
+	if err != nil || l.OwnerID != actorID {
+		if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "lease.plan.read"); err != nil {
+			return domain.Lease{}, err
+		}
+	}
+	return l, err
+}
+
+// LeaseFilter narrows ProjectLeases to the leases of OwnerID and, when ExpiringWithin is
+// positive, to those expiring within it.
+type LeaseFilter struct {
+	OwnerID        string
+	ExpiringWithin time.Duration
+}
+
+// ProjectLeases lists the active leases on the project's unfinished tasks, soonest expiry
+// first, with the plans declared with them. It needs lease.plan.read.
+func (e Engine) ProjectLeases(ctx context.Context, projectID, actorID string, f LeaseFilter) ([]domain.Lease, error) {
+	if f.ExpiringWithin < 0 {
+		return nil, errors.New("invalid expiring_within: must be positive")
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "lease.plan.read"); err != nil {
+		return nil, err
+	}
+	now := e.now().UTC()
+	var until string
+	if f.ExpiringWithin > 0 {
+		until = now.Add(f.ExpiringWithin).Format(time.RFC3339)
+	}
+	return e.Repo.ActiveLeasesTx(ctx, tx, projectID, f.OwnerID, now.Format(time.RFC3339), until)
+}
+
+// normalizeLeasePlan trims a declared plan and checks it; a nil plan stays nil.
+func (e Engine) normalizeLeasePlan(p *domain.LeasePlan) (*domain.LeasePlan, error) {
+	if p == nil {
+		return nil, nil
+	}
+	plan := *p
+	plan.Approach = strings.TrimSpace(plan.Approach)
+	if plan.Approach == "" {
+		return nil, errors.New("invalid plan: approach is required")
+	}
+	if plan.EstimatedSeconds < 0 {
+		return nil, errors.New("invalid plan: estimated_seconds must not be negative")
+	}
+	if err := checkPayloadSize(e.Config.Payloads, "plan", plan.Approach); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
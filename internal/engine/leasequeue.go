@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// LeaseWaiters lists the actors queued for the task lease in grant order.
+func (e Engine) LeaseWaiters(ctx context.Context, taskID, actorID string) ([]domain.LeaseWaiter, error) {
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.RequireFeature(ctx, t.ProjectID, config.FeatureLeaseQueue); err != nil {
+		return nil, err
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.read"); err != nil {
+		return nil, err
+	}
+	return e.Repo.ListLeaseWaitersTx(ctx, tx, taskID)
+}
+
+// LeaveLeaseQueue removes actorID from the task queue.
+func (e Engine) LeaveLeaseQueue(ctx context.Context, taskID, actorID string) error {
+	t, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.claim"); err != nil {
+		return err
+	}
+	ok, err := e.Repo.DeleteLeaseWaiterTx(ctx, tx, taskID, actorID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not queued for task %s: %w", actorID, taskID, repo.ErrNotFound)
+	}
+	if err := e.Events.Append(ctx, tx, "lease.dequeued", t.ProjectID, "task", taskID, actorID, events.EventPayload{"actor_id": actorID, "reason": "left"}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GrantQueuedLeases hands every released or expired lease with a queue to its first
+// waiter. Claims and releases do this as they happen; the sweep covers leases that simply
+// run out.
+func (e Engine) GrantQueuedLeases(ctx context.Context) ([]domain.Lease, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	now := e.now().UTC()
+	ids, err := e.Repo.VacantQueuedLeasesTx(ctx, tx, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	var granted []domain.Lease
+	for _, id := range ids {
+		t, err := e.Repo.GetTaskTx(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		l, err := e.grantQueuedLease(ctx, tx, t, now)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			granted = append(granted, *l)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return granted, nil
+}
+
+func (e Engine) enqueueLeaseWaiter(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string, leaseSeconds int, plan *domain.LeasePlan, now time.Time) (domain.LeaseWaiter, error) {
+	if err := e.Repo.EnqueueLeaseWaiterTx(ctx, tx, domain.LeaseWaiter{
+		TaskID:       t.ID,
+		ActorID:      actorID,
+		LeaseSeconds: leaseSeconds,
+		EnqueuedAt:   now.Format(time.RFC3339),
+		Plan:         plan,
+	}); err != nil {
+		return domain.LeaseWaiter{}, err
+	}
+	waiters, err := e.Repo.ListLeaseWaitersTx(ctx, tx, t.ID)
+	if err != nil {
+		return domain.LeaseWaiter{}, err
+	}
+	for _, w := range waiters {
+		if w.ActorID != actorID {
+			continue
+		}
+		if err := e.Events.Append(ctx, tx, "lease.queued", t.ProjectID, "task", t.ID, actorID, events.EventPayload{"position": w.Position}); err != nil {
+			return domain.LeaseWaiter{}, err
+		}
+		return w, nil
+	}
+	return domain.LeaseWaiter{}, errors.New("lease queue entry vanished")
+}
+
+// grantQueuedLease gives a released or expired lease to the first queued actor still
+// allowed to claim the task, dropping the ones that no longer are. It returns nil when the
+// lease is held or nobody is queued.
+func (e Engine) grantQueuedLease(ctx context.Context, tx *sql.Tx, t domain.Task, now time.Time) (*domain.Lease, error) {
+	current, err := e.Repo.GetLeaseTx(ctx, tx, t.ID)
+	if err == nil {
+		exp, _ := time.Parse(time.RFC3339, current.ExpiresAt)
+		if now.Before(exp) {
+			return nil, nil
+		}
+	} else if !errors.Is(err, repo.ErrNotFound) {
+		return nil, err
+	}
+	waiters, err := e.Repo.ListLeaseWaitersTx(ctx, tx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range waiters {
+		if _, err := e.Repo.DeleteLeaseWaiterTx(ctx, tx, t.ID, w.ActorID); err != nil {
+			return nil, err
+		}
+		reason, err := e.leaseUngrantable(ctx, tx, t, w.ActorID)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			if err := e.Events.Append(ctx, tx, "lease.dequeued", t.ProjectID, "task", t.ID, systemActorID, events.EventPayload{"actor_id": w.ActorID, "reason": reason}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		l := domain.Lease{
+			TaskID:     t.ID,
+			OwnerID:    w.ActorID,
+			AcquiredAt: now.Format(time.RFC3339),
+			ExpiresAt:  now.Add(time.Duration(w.LeaseSeconds) * time.Second).Format(time.RFC3339),
+			Plan:       w.Plan,
+		}
+		if err := e.Repo.UpsertLease(ctx, tx, l); err != nil {
+			return nil, err
+		}
+		payload := events.EventPayload{
+			"owner_id":    w.ActorID,
+			"expires_at":  l.ExpiresAt,
+			"enqueued_at": w.EnqueuedAt,
+		}
+		if w.Plan != nil {
+			payload["plan"] = w.Plan
+		}
+		if err := e.Events.Append(ctx, tx, "lease.granted", t.ProjectID, "task", t.ID, systemActorID, payload); err != nil {
+			return nil, err
+		}
+		return &l, nil
+	}
+	return nil, nil
+}
+
+// leaseUngrantable explains why a queued actor may no longer take the lease, or returns "".
+func (e Engine) leaseUngrantable(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) (string, error) {
+	ok, err := e.Auth.ActorHasPermission(ctx, tx, t.ProjectID, actorID, "task.claim")
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "missing task.claim", nil
+	}
+	assignees, err := e.Repo.ListTaskAssigneesTx(ctx, tx, t.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range assignees {
+		if a.Role == "driver" && !hasAssignee(assignees, actorID, "driver") {
+			return "not an assigned driver", nil
+		}
+	}
+	return "", nil
+}
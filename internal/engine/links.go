@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/integrations"
+	"workline/internal/repo"
+)
+
+// TaskLinkKinds lists what a task can be linked to.
+var TaskLinkKinds = []string{"commit", "branch", "pr"}
+
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+// normalizeLinkTarget checks target for kind: commits are 7 to 64 hex digits, matched as
+// a prefix of the SHAs builds report; branches drop a refs/heads/ prefix; pull requests
+// are http(s) URLs, kept without a trailing slash.
+func normalizeLinkTarget(kind, target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", fmt.Errorf("invalid link: target is required")
+	}
+	switch kind {
+	case "commit":
+		target = strings.ToLower(target)
+		if !commitSHA.MatchString(target) {
+			return "", fmt.Errorf("invalid link: commit %q is not a hex SHA of at least 7 digits", target)
+		}
+	case "branch":
+		target = strings.TrimPrefix(target, "refs/heads/")
+		if target == "" || strings.ContainsAny(target, " \t\n") {
+			return "", fmt.Errorf("invalid link: branch %q is not a branch name", target)
+		}
+	case "pr":
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("invalid link: pull request %q is not an http(s) URL", target)
+		}
+		target = strings.TrimRight(target, "/")
+	default:
+		return "", fmt.Errorf("invalid link kind %q: use %s", kind, strings.Join(TaskLinkKinds, ", "))
+	}
+	return target, nil
+}
+
+// AddTaskLink links a commit, branch or pull request URL to a task, which needs
+// task.update. Linking the same target twice is refused.
+func (e Engine) AddTaskLink(ctx context.Context, taskID, kind, target, actorID string) (domain.TaskLink, error) {
+	target, err := normalizeLinkTarget(kind, target)
+	if err != nil {
+		return domain.TaskLink{}, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.TaskLink{}, err
+	}
+	defer tx.Rollback()
+	t, err := e.Repo.GetTaskTx(ctx, tx, taskID)
+	if err != nil {
+		return domain.TaskLink{}, err
+	}
+	if err := e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.update"); err != nil {
+		return domain.TaskLink{}, err
+	}
+	links, err := e.Repo.ListProjectTaskLinksTx(ctx, tx, t.ProjectID)
+	if err != nil {
+		return domain.TaskLink{}, err
+	}
+	for _, l := range links {
+		if l.TaskID == t.ID && l.Kind == kind && l.Target == target {
+			return domain.TaskLink{}, fmt.Errorf("invalid link: %s %s is already linked to task %s as link %d", kind, target, t.ID, l.ID)
+		}
+	}
+	l := domain.TaskLink{
+		TaskID:    t.ID,
+		ProjectID: t.ProjectID,
+		Kind:      kind,
+		Target:    target,
+		CreatedBy: actorID,
+		CreatedAt: e.now().UTC().Format(time.RFC3339),
+	}
+	if l.ID, err = e.Repo.InsertTaskLinkTx(ctx, tx, l); err != nil {
+		return domain.TaskLink{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.link.added", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"link_id": l.ID,
+		"kind":    l.Kind,
+		"target":  l.Target,
+	}); err != nil {
+		return domain.TaskLink{}, err
+	}
+	return l, tx.Commit()
+}
+
+// RemoveTaskLink deletes one of a task's links, which needs task.update.
+func (e Engine) RemoveTaskLink(ctx context.Context, taskID string, linkID int64, actorID string) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	l, err := e.Repo.GetTaskLinkTx(ctx, tx, linkID)
+	if err != nil {
+		return fmt.Errorf("link %d: %w", linkID, err)
+	}
+	if l.TaskID != taskID {
+		return fmt.Errorf("link %d of task %s: %w", linkID, taskID, repo.ErrNotFound)
+	}
+	if err := e.requirePermission(ctx, tx, l.ProjectID, actorID, "task.update"); err != nil {
+		return err
+	}
+	if err := e.Repo.DeleteTaskLinkTx(ctx, tx, l.ID); err != nil {
+		return err
+	}
+	if err := e.Events.Append(ctx, tx, "task.link.removed", l.ProjectID, "task", l.TaskID, actorID, events.EventPayload{
+		"link_id": l.ID,
+		"kind":    l.Kind,
+		"target":  l.Target,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SyncTaskLinks applies the link status of a verified provider webhook to the project's
+// links: builds update the CI status of the commit they ran on, of their branch, and of
+// pull requests whose source branch it is or that the provider ties them to; pull request
+// events update the review status of the link with their URL. Each changed link records a
+// task.link.status event on its task. It returns the changed links.
+func (e Engine) SyncTaskLinks(ctx context.Context, projectID string, evt integrations.Event, actorID string) ([]domain.TaskLink, error) {
+	s := evt.Link
+	if s.CI == "" && s.Review == "" && s.SourceBranch == "" {
+		return nil, nil
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	links, err := e.Repo.ListProjectTaskLinksTx(ctx, tx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	now := e.now().UTC().Format(time.RFC3339)
+	ref := strings.TrimPrefix(s.Ref, "refs/heads/")
+	prURL := strings.TrimRight(s.PullRequestURL, "/")
+	var changed []domain.TaskLink
+	for _, l := range links {
+		next := l
+		switch l.Kind {
+		case "commit":
+			if s.CI != "" && strings.HasPrefix(strings.ToLower(s.SHA), l.Target) {
+				next.CIStatus, next.CIURL = s.CI, s.CIURL
+			}
+		case "branch":
+			if s.CI != "" && ref != "" && ref == l.Target {
+				next.CIStatus, next.CIURL = s.CI, s.CIURL
+			}
+		case "pr":
+			samePR := prURL != "" && prURL == l.Target
+			if samePR && s.SourceBranch != "" {
+				next.HeadRef = s.SourceBranch
+			}
+			if samePR && s.Review != "" {
+				next.ReviewStatus = s.Review
+			}
+			if s.CI != "" && (samePR || (ref != "" && ref == next.HeadRef)) {
+				next.CIStatus, next.CIURL = s.CI, s.CIURL
+			}
+		}
+		if next == l {
+			continue
+		}
+		next.Provider = evt.Provider
+		next.StatusUpdatedAt = now
+		if err := e.Repo.UpdateTaskLinkStatusTx(ctx, tx, next); err != nil {
+			return nil, err
+		}
+		if next.CIStatus != l.CIStatus || next.ReviewStatus != l.ReviewStatus {
+			if err := e.Events.Append(ctx, tx, "task.link.status", next.ProjectID, "task", next.TaskID, actorID, events.EventPayload{
+				"link_id":       next.ID,
+				"kind":          next.Kind,
+				"target":        next.Target,
+				"provider":      evt.Provider,
+				"event":         evt.Key,
+				"ci_status":     next.CIStatus,
+				"review_status": next.ReviewStatus,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		changed = append(changed, next)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return changed, tx.Commit()
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"workline/internal/events"
+)
+
+// mentionPattern matches @actor where the @ does not follow a word character, so email
+// addresses are not mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.:-]*)`)
+
+// parseMentions returns the actors mentioned as @actor in texts, in order of first mention.
+// Trailing punctuation is not part of the id.
+func parseMentions(texts ...string) []string {
+	var res []string
+	for _, text := range texts {
+		for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+			if id := strings.TrimRight(m[1], ".:-"); !slices.Contains(res, id) {
+				res = append(res, id)
+			}
+		}
+	}
+	return res
+}
+
+// recordMentions checks that the actors mentioned in texts are in the actor registry and
+// records a <entityKind>.mentioned event naming them, with payload saying where they were
+// mentioned. Texts without mentions record nothing; an unknown actor fails the change.
+func (e Engine) recordMentions(ctx context.Context, tx *sql.Tx, projectID, entityKind, entityID, actorID string, payload events.EventPayload, texts ...string) error {
+	mentioned := parseMentions(texts...)
+	if len(mentioned) == 0 {
+		return nil
+	}
+	unknown, err := e.Repo.UnknownActorsTx(ctx, tx, mentioned)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("invalid mention @%s: no such actor", strings.Join(unknown, ", @"))
+	}
+	payload["mentioned"] = mentioned
+	return e.Events.Append(ctx, tx, entityKind+".mentioned", projectID, entityKind, entityID, actorID, payload)
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"workline/internal/repo"
+)
+
+// WorkflowMetrics is the workflow health of a project at one moment: the unfinished tasks
+// in each status, the unexpired task leases, the tasks in review whose requirements are
+// neither attested nor waived, and the attestations recorded so far.
+type WorkflowMetrics struct {
+	ProjectID         string
+	OpenTasks         map[string]int
+	ActiveLeases      int
+	ValidationBlocked int
+	Attestations      int
+}
+
+// WorkflowMetrics measures every project from one read transaction, so the figures of a
+// collection agree with each other.
+func (e Engine) WorkflowMetrics(ctx context.Context) ([]WorkflowMetrics, error) {
+	projects, err := e.Repo.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	open, err := e.Repo.CountOpenTasksTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := e.Repo.CountActiveLeasesTx(ctx, tx, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	attestations, err := e.Repo.CountAttestationsTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]WorkflowMetrics, 0, len(projects))
+	for _, p := range projects {
+		m := WorkflowMetrics{
+			ProjectID:    p.ID,
+			OpenTasks:    open[p.ID],
+			ActiveLeases: leases[p.ID],
+			Attestations: attestations[p.ID],
+		}
+		if m.OpenTasks == nil {
+			m.OpenTasks = map[string]int{}
+		}
+		review, err := e.Repo.ListTasksTx(ctx, tx, repo.TaskFilters{ProjectID: p.ID, Status: "review"})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range review {
+			unmet, err := e.unmetRequirements(ctx, tx, t)
+			if err != nil {
+				return nil, err
+			}
+			if len(unmet) > 0 {
+				m.ValidationBlocked++
+			}
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/engine/auth"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// OrgRoles lists the roles an actor can hold in an org. They govern the org itself: who
+// belongs to it and which projects it holds. They grant nothing inside its projects, whose
+// roles are granted per project.
+var OrgRoles = []string{"owner", "member"}
+
+// orgRolePerms is what each org role may do in its org.
+var orgRolePerms = map[string][]string{
+	"owner":  {"org.read", "org.members.manage", "org.project.create"},
+	"member": {"org.read"},
+}
+
+// CreateOrg creates an org with actorID as its owner. Org changes record org-scoped events
+// (org.created, org.role_set, org.member_removed) in the same transaction.
+func (e Engine) CreateOrg(ctx context.Context, id, name, actorID string) (domain.Org, error) {
+	id = strings.TrimSpace(id)
+	if !suppliedIDPattern.MatchString(id) {
+		return domain.Org{}, fmt.Errorf("invalid org id %q: use letters, digits, '.', '_', ':' or '-'", id)
+	}
+	o := domain.Org{ID: id, Name: strings.TrimSpace(name), CreatedAt: e.now().UTC().Format(time.RFC3339)}
+	if o.Name == "" {
+		o.Name = id
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Org{}, err
+	}
+	defer tx.Rollback()
+	if _, err := e.Repo.GetOrgTx(ctx, tx, id); err == nil {
+		return domain.Org{}, fmt.Errorf("invalid org id: org %s already exists", id)
+	} else if !errors.Is(err, repo.ErrNotFound) {
+		return domain.Org{}, err
+	}
+	if err := e.ensureActor(ctx, tx, actorID); err != nil {
+		return domain.Org{}, err
+	}
+	if err := e.Repo.InsertOrgTx(ctx, tx, o); err != nil {
+		return domain.Org{}, err
+	}
+	if err := e.Repo.SetOrgRoleTx(ctx, tx, id, actorID, "owner"); err != nil {
+		return domain.Org{}, err
+	}
+	if err := e.Events.AppendOrg(ctx, tx, "org.created", id, id, actorID, events.EventPayload{"name": o.Name, "owner": actorID}); err != nil {
+		return domain.Org{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Org{}, err
+	}
+	return o, nil
+}
+
+// requireOrgPermission fails unless the org exists and actorID's role in it allows perm.
+func (e Engine) requireOrgPermission(ctx context.Context, tx *sql.Tx, orgID, actorID, perm string) error {
+	if _, err := e.Repo.GetOrgTx(ctx, tx, orgID); err != nil {
+		return fmt.Errorf("org %s: %w", orgID, err)
+	}
+	role, err := e.Repo.GetOrgRoleTx(ctx, tx, orgID, actorID)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		return err
+	}
+	if !slices.Contains(orgRolePerms[role], perm) {
+		return auth.ForbiddenError{Permission: perm}
+	}
+	return nil
+}
+
+// checkOrgPermission is requireOrgPermission for reads made outside a transaction.
+func (e Engine) checkOrgPermission(ctx context.Context, orgID, actorID, perm string) error {
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return e.requireOrgPermission(ctx, tx, orgID, actorID, perm)
+}
+
+// GetOrg returns an org to one of its members.
+func (e Engine) GetOrg(ctx context.Context, orgID, actorID string) (domain.Org, error) {
+	if err := e.checkOrgPermission(ctx, orgID, actorID, "org.read"); err != nil {
+		return domain.Org{}, err
+	}
+	return e.Repo.GetOrg(ctx, orgID)
+}
+
+// ListOrgProjects returns the projects of an org to one of its members.
+func (e Engine) ListOrgProjects(ctx context.Context, orgID, actorID string) ([]domain.Project, error) {
+	if err := e.checkOrgPermission(ctx, orgID, actorID, "org.read"); err != nil {
+		return nil, err
+	}
+	return e.Repo.ListOrgProjects(ctx, orgID)
+}
+
+// ListOrgMembers returns the roles held in an org to one of its members.
+func (e Engine) ListOrgMembers(ctx context.Context, orgID, actorID string) ([]domain.OrgMember, error) {
+	if err := e.checkOrgPermission(ctx, orgID, actorID, "org.read"); err != nil {
+		return nil, err
+	}
+	return e.Repo.ListOrgMembers(ctx, orgID)
+}
+
+// SetOrgRole gives target a role in the org, replacing the one it had. Only owners manage
+// membership, and the last owner cannot step down.
+func (e Engine) SetOrgRole(ctx context.Context, orgID, target, role, actorID string) (domain.OrgMember, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return domain.OrgMember{}, fmt.Errorf("actor id required")
+	}
+	if !slices.Contains(OrgRoles, role) {
+		return domain.OrgMember{}, fmt.Errorf("invalid org role %q: use %s", role, strings.Join(OrgRoles, " or "))
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.OrgMember{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requireOrgPermission(ctx, tx, orgID, actorID, "org.members.manage"); err != nil {
+		return domain.OrgMember{}, err
+	}
+	if role != "owner" {
+		if err := e.keepOrgOwner(ctx, tx, orgID, target); err != nil {
+			return domain.OrgMember{}, err
+		}
+	}
+	from, err := e.Repo.GetOrgRoleTx(ctx, tx, orgID, target)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		return domain.OrgMember{}, err
+	}
+	if err := e.ensureActor(ctx, tx, target); err != nil {
+		return domain.OrgMember{}, err
+	}
+	if err := e.Repo.SetOrgRoleTx(ctx, tx, orgID, target, role); err != nil {
+		return domain.OrgMember{}, err
+	}
+	if err := e.Events.AppendOrg(ctx, tx, "org.role_set", orgID, orgID, actorID, events.EventPayload{"member": target, "role": role, "from_role": from}); err != nil {
+		return domain.OrgMember{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.OrgMember{}, err
+	}
+	return domain.OrgMember{OrgID: orgID, ActorID: target, Role: role}, nil
+}
+
+// RemoveOrgMember takes target's role in the org away.
+func (e Engine) RemoveOrgMember(ctx context.Context, orgID, target, actorID string) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := e.requireOrgPermission(ctx, tx, orgID, actorID, "org.members.manage"); err != nil {
+		return err
+	}
+	if err := e.keepOrgOwner(ctx, tx, orgID, target); err != nil {
+		return err
+	}
+	role, err := e.Repo.GetOrgRoleTx(ctx, tx, orgID, target)
+	if err != nil {
+		return fmt.Errorf("org member %s: %w", target, err)
+	}
+	if err := e.Repo.DeleteOrgRoleTx(ctx, tx, orgID, target); err != nil {
+		return fmt.Errorf("org member %s: %w", target, err)
+	}
+	if err := e.Events.AppendOrg(ctx, tx, "org.member_removed", orgID, orgID, actorID, events.EventPayload{"member": target, "role": role}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// keepOrgOwner refuses to let target's owner role go when it is the org's last owner.
+func (e Engine) keepOrgOwner(ctx context.Context, tx *sql.Tx, orgID, target string) error {
+	role, err := e.Repo.GetOrgRoleTx(ctx, tx, orgID, target)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil || role != "owner" {
+		return err
+	}
+	owners, err := e.Repo.CountOrgOwnersTx(ctx, tx, orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return fmt.Errorf("invalid org change: %s is the last owner of org %s", target, orgID)
+	}
+	return nil
+}
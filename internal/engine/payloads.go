@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"workline/internal/blob"
+	"workline/internal/config"
+	"workline/internal/domain"
+)
+
+// PayloadTooLargeError rejects an attestation payload or task work outcomes above
+// config.payloads.max_bytes.
+type PayloadTooLargeError struct {
+	Field string
+	Size  int
+	Max   int
+}
+
+func (e PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("%s is %d bytes, above the %d byte limit; store large artifacts as blobs and cite them", e.Field, e.Size, e.Max)
+}
+
+// BlobRef replaces an offloaded payload in sqlite; Blob is the sha256 content address.
+type BlobRef struct {
+	Blob  string `json:"$blob"`
+	Bytes int    `json:"bytes"`
+}
+
+// parseBlobRef reads payload as the reference document offloadPayload stores: an object
+// with exactly a valid $blob digest and its size.
+func parseBlobRef(payload string) (BlobRef, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil || len(fields) != 2 || fields["bytes"] == nil {
+		return BlobRef{}, false
+	}
+	var ref BlobRef
+	if err := json.Unmarshal([]byte(payload), &ref); err != nil || !blob.ValidDigest(ref.Blob) {
+		return BlobRef{}, false
+	}
+	return ref, true
+}
+
+// hasBlobMarker reports whether payload is an object with a top-level $blob key, which only
+// offloadPayload may write.
+func hasBlobMarker(payload string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+		return false
+	}
+	_, ok := fields["$blob"]
+	return ok
+}
+
+// offloads reports whether offloadPayload would move payload into the blob store.
+func (e Engine) offloads(payload string) bool {
+	return e.Blobs != nil && len(payload) > e.Config.Payloads.InlineMax()
+}
+
+func checkPayloadSize(limits config.Payloads, field, payload string) error {
+	if len(payload) > limits.Max() {
+		return PayloadTooLargeError{Field: field, Size: len(payload), Max: limits.Max()}
+	}
+	return nil
+}
+
+// offloadPayload moves a payload above the inline limit into the blob store and returns
+// the reference document to persist instead. Without a blob store payloads stay inline.
+func (e Engine) offloadPayload(ctx context.Context, payload string) (string, *BlobRef, error) {
+	if !e.offloads(payload) {
+		return payload, nil, nil
+	}
+	digest, err := e.Blobs.Put(ctx, []byte(payload))
+	if err != nil {
+		return "", nil, fmt.Errorf("offload payload: %w", err)
+	}
+	ref := BlobRef{Blob: digest, Bytes: len(payload)}
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(data), &ref, nil
+}
+
+// attestationVars exposes an attestation to payload predicates as kind, actor_id, ts and
+// payload.*, reading offloaded payloads back from the blob store. A payload that is not
+// JSON reads as null.
+func (e Engine) attestationVars(ctx context.Context, a domain.Attestation) (map[string]any, error) {
+	ea, err := e.evidenceAttestation(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	var payload any
+	if len(ea.Payload) > 0 {
+		_ = json.Unmarshal(ea.Payload, &payload)
+	}
+	return map[string]any{
+		"kind":     a.Kind,
+		"actor_id": a.ActorID,
+		"ts":       a.TS,
+		"payload":  payload,
+	}, nil
+}
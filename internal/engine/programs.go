@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+)
+
+// SetProjectParent places a project under a program, or back at the top level when parentID
+// is empty. The actor needs project.update on the project and on the new parent.
+func (e Engine) SetProjectParent(ctx context.Context, projectID, parentID, actorID string) (domain.Project, error) {
+	p, err := e.Repo.GetProject(ctx, projectID)
+	if err != nil {
+		return p, err
+	}
+	if parentID == projectID {
+		return p, fmt.Errorf("invalid parent: project %s cannot be its own parent", projectID)
+	}
+	if parentID != "" {
+		if _, err := e.Repo.GetProject(ctx, parentID); err != nil {
+			return p, err
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "project.update"); err != nil {
+		return p, err
+	}
+	if parentID != "" {
+		if err := e.requirePermission(ctx, tx, parentID, actorID, "project.update"); err != nil {
+			return p, err
+		}
+		ancestors, err := e.Repo.ProjectAncestorsTx(ctx, tx, parentID)
+		if err != nil {
+			return p, err
+		}
+		if slices.Contains(ancestors, projectID) {
+			return p, fmt.Errorf("invalid parent: %s is below %s", parentID, projectID)
+		}
+	}
+	if err := e.Repo.SetProjectParentTx(ctx, tx, projectID, parentID); err != nil {
+		return p, err
+	}
+	if err := e.Events.Append(ctx, tx, "project.parent_set", projectID, "project", projectID, actorID, events.EventPayload{
+		"parent_project_id": parentID,
+		"previous":          p.ParentProjectID,
+	}); err != nil {
+		return p, err
+	}
+	if err := tx.Commit(); err != nil {
+		return p, err
+	}
+	p.ParentProjectID = parentID
+	return p, nil
+}
+
+// ProjectRollup summarizes one project of a program.
+type ProjectRollup struct {
+	ProjectID        string            `json:"project_id"`
+	ParentProjectID  string            `json:"parent_project_id,omitempty"`
+	Status           string            `json:"status"`
+	TaskCounts       map[string]int    `json:"task_counts"`
+	OpenTasks        int               `json:"open_tasks"`
+	DoneTasks        int               `json:"done_tasks"`
+	RunningIteration *domain.Iteration `json:"running_iteration,omitempty"`
+}
+
+// ProgramTotals adds up the rollups of a program.
+type ProgramTotals struct {
+	Projects   int            `json:"projects"`
+	TaskCounts map[string]int `json:"task_counts"`
+	OpenTasks  int            `json:"open_tasks"`
+	DoneTasks  int            `json:"done_tasks"`
+	// Completion is done tasks over done and open tasks; canceled and rejected tasks are left out.
+	Completion float64 `json:"completion"`
+}
+
+// ProgramSummary rolls up a program project and every project below it.
+type ProgramSummary struct {
+	ProgramID string          `json:"program_id"`
+	Projects  []ProjectRollup `json:"projects"`
+	Totals    ProgramTotals   `json:"totals"`
+	// Hidden counts descendant projects left out because visible rejected them.
+	Hidden int `json:"hidden"`
+}
+
+// SummarizeProgram builds the rollup of programID, its own tasks first, then its descendants
+// breadth first. Descendants for which visible returns false are counted in Hidden only.
+func (e Engine) SummarizeProgram(ctx context.Context, programID string, visible func(projectID string) bool) (ProgramSummary, error) {
+	program, err := e.Repo.GetProject(ctx, programID)
+	if err != nil {
+		return ProgramSummary{}, err
+	}
+	descendants, err := e.Repo.ListDescendantProjects(ctx, programID)
+	if err != nil {
+		return ProgramSummary{}, err
+	}
+	sum := ProgramSummary{ProgramID: programID, Projects: []ProjectRollup{}, Totals: ProgramTotals{TaskCounts: map[string]int{}}}
+	for _, p := range append([]domain.Project{program}, descendants...) {
+		if p.ID != programID && visible != nil && !visible(p.ID) {
+			sum.Hidden++
+			continue
+		}
+		r, err := e.rollupProject(ctx, p)
+		if err != nil {
+			return ProgramSummary{}, err
+		}
+		sum.Projects = append(sum.Projects, r)
+		sum.Totals.Projects++
+		sum.Totals.OpenTasks += r.OpenTasks
+		sum.Totals.DoneTasks += r.DoneTasks
+		for status, n := range r.TaskCounts {
+			sum.Totals.TaskCounts[status] += n
+		}
+	}
+	if total := sum.Totals.DoneTasks + sum.Totals.OpenTasks; total > 0 {
+		sum.Totals.Completion = float64(sum.Totals.DoneTasks) / float64(total)
+	}
+	return sum, nil
+}
+
+func (e Engine) rollupProject(ctx context.Context, p domain.Project) (ProjectRollup, error) {
+	counts, err := e.Repo.CountTasksByStatus(ctx, p.ID)
+	if err != nil {
+		return ProjectRollup{}, err
+	}
+	running, err := e.Repo.LatestRunningIteration(ctx, p.ID)
+	if err != nil {
+		return ProjectRollup{}, err
+	}
+	return ProjectRollup{
+		ProjectID:        p.ID,
+		ParentProjectID:  p.ParentProjectID,
+		Status:           p.Status,
+		TaskCounts:       counts,
+		OpenTasks:        counts["planned"] + counts["in_progress"] + counts["review"],
+		DoneTasks:        counts["done"],
+		RunningIteration: running,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// Requirement sources.
+const (
+	RequirementOverride = "override"
+	RequirementPreset   = "preset"
+	RequirementDefault  = "default"
+	RequirementRisk     = "risk"
+	RequirementUnknown  = "unknown"
+)
+
+// RequirementSource tells where one of a task's required entries came from: an explicit
+// override of the task's requirements, a policy preset applied to the task, the preset
+// the project maps the task type to by default, or the preset its risk level routed it to. ActorID and SetAt name the change that
+// made the entry required.
+type RequirementSource struct {
+	Requirement string `json:"requirement" example:"security.ok"`
+	Source      string `json:"source" enum:"override,preset,default,risk,unknown" example:"preset"`
+	Preset      string `json:"preset,omitempty" example:"strict"`
+	ActorID     string `json:"actor_id,omitempty" example:"pm-1"`
+	SetAt       string `json:"set_at,omitempty" format:"date-time"`
+}
+
+// RequirementSources explains each of the task's required entries, in order, by replaying
+// its policy events. A preset applied or changed sources every entry it requires; an
+// override sources only the entries it adds, so entries it keeps stay with their earlier
+// source. Entries no recorded change explains are unknown.
+func (e Engine) RequirementSources(ctx context.Context, t domain.Task) ([]RequirementSource, error) {
+	required := currentPolicy(t).Require
+	res := make([]RequirementSource, 0, len(required))
+	if len(required) == 0 {
+		return res, nil
+	}
+	evts, err := e.Repo.EntityEvents(ctx, t.ProjectID, "task", []string{t.ID})
+	if err != nil {
+		return nil, err
+	}
+	var defaultPreset string
+	cfg, err := e.Repo.GetProjectConfig(ctx, t.ProjectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		cfg = e.Config
+	} else if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		defaultPreset = cfg.Policies.Defaults.Task[t.Type]
+	}
+	sources := map[string]RequirementSource{}
+	var previous []string
+	for _, evt := range evts {
+		var p struct {
+			Require    []string `json:"require"`
+			NewRequire []string `json:"new_require"`
+			Preset     string   `json:"preset_name"`
+			Source     string   `json:"source"`
+		}
+		switch evt.Type {
+		case "task.policy.applied", "task.policy.updated", "policy.override":
+		default:
+			continue
+		}
+		if err := json.Unmarshal([]byte(evt.Payload), &p); err != nil {
+			continue
+		}
+		// Creation events carry require, updates new_require.
+		list := p.Require
+		if list == nil {
+			list = p.NewRequire
+		}
+		src := RequirementSource{Source: RequirementPreset, Preset: p.Preset, ActorID: evt.ActorID, SetAt: evt.TS}
+		switch {
+		case evt.Type == "policy.override":
+			src.Source, src.Preset = RequirementOverride, ""
+		case evt.Type == "task.policy.applied" && p.Source == RequirementRisk:
+			src.Source = RequirementRisk
+		case evt.Type == "task.policy.applied" && isDefaultPreset(p.Source, p.Preset, defaultPreset):
+			src.Source = RequirementDefault
+		}
+		for _, req := range list {
+			if src.Source == RequirementOverride && slices.Contains(previous, req) {
+				continue
+			}
+			src.Requirement = req
+			sources[req] = src
+		}
+		previous = list
+	}
+	for _, req := range required {
+		src, ok := sources[req]
+		if !ok {
+			src = RequirementSource{Requirement: req, Source: RequirementUnknown}
+		}
+		res = append(res, src)
+	}
+	return res, nil
+}
+
+// isDefaultPreset reports whether a task.policy.applied event applied the project default.
+// Events recorded before the source was stored count as default when they name the preset
+// the project maps the task type to.
+func isDefaultPreset(source, preset, defaultPreset string) bool {
+	if source != "" {
+		return source == RequirementDefault
+	}
+	return preset != "" && preset == defaultPreset
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/repo"
+)
+
+// PendingTaskProof is an open task still missing a required attestation. Missing lists
+// the task's requirement entries for the kind, e.g. "review.approved+security.signoff".
+type PendingTaskProof struct {
+	TaskID      string   `json:"task_id"`
+	Title       string   `json:"title"`
+	Status      string   `json:"status"`
+	IterationID string   `json:"iteration_id,omitempty"`
+	AssigneeID  string   `json:"assignee_id,omitempty"`
+	Missing     []string `json:"missing"`
+	CreatedAt   string   `json:"created_at" format:"date-time"`
+}
+
+// PendingIterationProof is an iteration not yet validated whose validation policy requires
+// the kind and that has no attestation of it.
+type PendingIterationProof struct {
+	IterationID string `json:"iteration_id"`
+	Goal        string `json:"goal"`
+	Status      string `json:"status"`
+	CreatedAt   string `json:"created_at" format:"date-time"`
+}
+
+// AttestationBacklog lists the open work waiting for one attestation kind, oldest first.
+type AttestationBacklog struct {
+	ProjectID  string                  `json:"project_id"`
+	Kind       string                  `json:"kind"`
+	Tasks      []PendingTaskProof      `json:"tasks"`
+	Iterations []PendingIterationProof `json:"iterations"`
+}
+
+// AttestationRequiredBy finds the open tasks and iterations whose policies require kind and
+// that still miss it. Requirements covered by an active waiver are not pending.
+func (e Engine) AttestationRequiredBy(ctx context.Context, projectID, kind string) (AttestationBacklog, error) {
+	if kind == "" {
+		return AttestationBacklog{}, errors.New("kind is required")
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return AttestationBacklog{}, err
+	}
+	res := AttestationBacklog{ProjectID: projectID, Kind: kind, Tasks: []PendingTaskProof{}, Iterations: []PendingIterationProof{}}
+	if e.Config != nil && e.Config.Policies.Defaults.Iteration.Validation.Require == kind {
+		iterations, err := e.Repo.ListIterations(ctx, projectID)
+		if err != nil {
+			return res, err
+		}
+		slices.Reverse(iterations)
+		for _, it := range iterations {
+			if it.Status == "validated" || it.Status == "rejected" {
+				continue
+			}
+			ok, err := e.iterationValidated(ctx, it.ID, kind)
+			if err != nil {
+				return res, err
+			}
+			if !ok {
+				res.Iterations = append(res.Iterations, PendingIterationProof{IterationID: it.ID, Goal: it.Goal, Status: it.Status, CreatedAt: it.CreatedAt})
+			}
+		}
+	}
+	tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, RequiresAttestation: kind})
+	if err != nil {
+		return res, err
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+	now := e.now().UTC().Format(time.RFC3339)
+	slices.Reverse(tasks)
+	for _, t := range tasks {
+		var required []string
+		if err := json.Unmarshal([]byte(*t.RequiredAttestationsJSON), &required); err != nil {
+			return res, err
+		}
+		required = slices.DeleteFunc(required, func(req string) bool {
+			k, _ := config.SplitRequirement(req)
+			return k != kind
+		})
+		found, err := e.presentRequirements(ctx, tx, t.ID, required)
+		if err != nil {
+			return res, err
+		}
+		waivers, err := e.Repo.ListActiveWaiversTx(ctx, tx, t.ID, now)
+		if err != nil {
+			return res, err
+		}
+		for _, w := range waivers {
+			found[w.Kind] = true
+		}
+		missing := slices.DeleteFunc(required, func(req string) bool { return found[req] })
+		if len(missing) == 0 {
+			continue
+		}
+		item := PendingTaskProof{TaskID: t.ID, Title: t.Title, Status: t.Status, Missing: missing, CreatedAt: t.CreatedAt}
+		if t.IterationID != nil {
+			item.IterationID = *t.IterationID
+		}
+		if t.AssigneeID != nil {
+			item.AssigneeID = *t.AssigneeID
+		}
+		res.Tasks = append(res.Tasks, item)
+	}
+	return res, nil
+}
@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+)
+
+// Risk factor sources.
+const (
+	RiskFromType  = "type"
+	RiskFromLabel = "label"
+	RiskFromKind  = "kind"
+)
+
+// RiskFactor is one weight policies.risk added to a task's score.
+type RiskFactor struct {
+	Source string `json:"source" enum:"type,label,kind" example:"label"`
+	Value  string `json:"value" example:"security"`
+	Weight int    `json:"weight" example:"5"`
+}
+
+// TaskRisk explains a task's risk score: the factors summed into it, the level it reaches
+// and what that level routes to. Level is empty below the first level.
+type TaskRisk struct {
+	Score     int          `json:"score" example:"7"`
+	Level     string       `json:"level,omitempty" example:"high"`
+	Preset    string       `json:"preset,omitempty" example:"strict"`
+	Attesters []string     `json:"attesters,omitempty" doc:"Roles that must each attest the task at this level"`
+	Factors   []RiskFactor `json:"factors"`
+}
+
+// normalizeLabels lowercases, deduplicates and sorts task labels.
+func normalizeLabels(in []string) ([]string, error) {
+	var labels []string
+	for _, l := range in {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" {
+			continue
+		}
+		if !capabilityPattern.MatchString(l) {
+			return nil, fmt.Errorf("invalid label %q", l)
+		}
+		if !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// assessRisk sums the weights policy gives the task type, each label and each kind, and
+// resolves the level the score reaches.
+func assessRisk(policy config.RiskPolicy, taskType string, labels, kinds []string) TaskRisk {
+	risk := TaskRisk{Factors: []RiskFactor{}}
+	add := func(source, value string, weights map[string]int) {
+		if w := weights[value]; w != 0 {
+			risk.Score += w
+			risk.Factors = append(risk.Factors, RiskFactor{Source: source, Value: value, Weight: w})
+		}
+	}
+	add(RiskFromType, taskType, policy.Types)
+	for _, l := range labels {
+		add(RiskFromLabel, l, policy.Labels)
+	}
+	for _, k := range kinds {
+		add(RiskFromKind, k, policy.Kinds)
+	}
+	if level, ok := policy.Level(risk.Score); ok {
+		risk.Level, risk.Preset, risk.Attesters = level.Name, level.Preset, level.Attesters
+	}
+	return risk
+}
+
+// requirementKinds lists the attestation kinds requirements name, countersigns included.
+func requirementKinds(required []string) []string {
+	var kinds []string
+	for _, req := range required {
+		r := config.ParseRequirement(req)
+		for _, k := range []string{r.Kind, r.Countersign} {
+			if k != "" && !slices.Contains(kinds, k) {
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// assessTaskRiskTx scores t against the risk policy of cfg, counting the kinds t requires
+// and those attested on it.
+func (e Engine) assessTaskRiskTx(ctx context.Context, tx *sql.Tx, cfg *config.Config, t domain.Task) (TaskRisk, error) {
+	kinds := requirementKinds(currentPolicy(t).Require)
+	attested, err := e.Repo.ListTaskAttestationKindsTx(ctx, tx, t.ID)
+	if err != nil {
+		return TaskRisk{}, err
+	}
+	for k := range attested {
+		if !slices.Contains(kinds, k) {
+			kinds = append(kinds, k)
+		}
+	}
+	sort.Strings(kinds)
+	return assessRisk(cfg.Policies.Risk, t.Type, t.Labels, kinds), nil
+}
+
+// applyRisk sets the score and level of risk on t, or clears them when cfg has no risk
+// policy.
+func applyRisk(cfg *config.Config, t *domain.Task, risk TaskRisk) {
+	t.RiskScore, t.RiskLevel = nil, nil
+	if !cfg.Policies.Risk.Enabled() {
+		return
+	}
+	score := risk.Score
+	t.RiskScore = &score
+	if risk.Level != "" {
+		level := risk.Level
+		t.RiskLevel = &level
+	}
+}
+
+// rescoreTaskTx recomputes the risk of a task after its attestations changed and records a
+// task.risk.changed event when the score or level moved. Presets are routed once, at
+// creation; the attesters follow the current level.
+func (e Engine) rescoreTaskTx(ctx context.Context, tx *sql.Tx, taskID, actorID string) error {
+	if !e.Config.Policies.Risk.Enabled() {
+		return nil
+	}
+	t, err := e.Repo.GetTaskTx(ctx, tx, taskID)
+	if err != nil {
+		return err
+	}
+	risk, err := e.assessTaskRiskTx(ctx, tx, e.Config, t)
+	if err != nil {
+		return err
+	}
+	original := t
+	applyRisk(e.Config, &t, risk)
+	if sameOptionalInt(original.RiskScore, t.RiskScore) && sameOptionalString(original.RiskLevel, t.RiskLevel) {
+		return nil
+	}
+	if err := e.Repo.SetTaskRiskTx(ctx, tx, t.ID, t.RiskScore, t.RiskLevel); err != nil {
+		return err
+	}
+	return e.Events.Append(ctx, tx, "task.risk.changed", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"risk_score":     t.RiskScore,
+		"risk_level":     t.RiskLevel,
+		"previous_score": original.RiskScore,
+		"previous_level": original.RiskLevel,
+	})
+}
+
+// unmetAttestersTx lists, as "attester:<role>", the roles the risk level of t requires that
+// no actor who attested t holds.
+func (e Engine) unmetAttestersTx(ctx context.Context, tx *sql.Tx, t domain.Task) ([]string, error) {
+	if t.RiskLevel == nil {
+		return nil, nil
+	}
+	var attesters []string
+	for _, level := range e.Config.Policies.Risk.Levels {
+		if level.Name == *t.RiskLevel {
+			attesters = level.Attesters
+		}
+	}
+	if len(attesters) == 0 {
+		return nil, nil
+	}
+	held, err := e.Repo.TaskAttesterRolesTx(ctx, tx, t.ProjectID, t.ID, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	var unmet []string
+	for _, role := range attesters {
+		if !slices.Contains(held, role) {
+			unmet = append(unmet, AttesterRequirement(role))
+		}
+	}
+	return unmet, nil
+}
+
+// AttesterRequirement names the unmet requirement for an attestation by a holder of role.
+func AttesterRequirement(role string) string {
+	return "attester:" + role
+}
+
+// TaskRiskReport is the risk of a task as the validation status explains it.
+type TaskRiskReport struct {
+	TaskRisk
+	MissingAttesters []string `json:"missing_attesters" doc:"Attester roles no attestation on the task satisfies yet"`
+}
+
+// TaskRisk explains the risk score of t under the engine config, or returns nil when no
+// risk policy is configured.
+func (e Engine) TaskRisk(ctx context.Context, t domain.Task) (*TaskRiskReport, error) {
+	if e.Config == nil || !e.Config.Policies.Risk.Enabled() {
+		return nil, nil
+	}
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	risk, err := e.assessTaskRiskTx(ctx, tx, e.Config, t)
+	if err != nil {
+		return nil, err
+	}
+	applyRisk(e.Config, &t, risk)
+	unmet, err := e.unmetAttestersTx(ctx, tx, t)
+	if err != nil {
+		return nil, err
+	}
+	report := &TaskRiskReport{TaskRisk: risk, MissingAttesters: []string{}}
+	for _, u := range unmet {
+		report.MissingAttesters = append(report.MissingAttesters, strings.TrimPrefix(u, "attester:"))
+	}
+	return report, nil
+}
+
+func sameOptionalInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// requireStatusOverride guards a hand-set status on a parent task whose status rolls up
+// from its subtasks.
+func (e Engine) requireStatusOverride(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) error {
+	if !e.Config.Rollup.FromChildren() {
+		return nil
+	}
+	children, err := e.Repo.ListChildrenTx(ctx, tx, t.ID)
+	if err != nil || len(children) == 0 {
+		return err
+	}
+	return e.requirePermission(ctx, tx, t.ProjectID, actorID, "task.status.override")
+}
+
+// rollupTx recomputes the status of the task id and then of its ancestors, stopping at the
+// first one left unchanged. It does nothing unless config.rollup.parent_status is children.
+func (e Engine) rollupTx(ctx context.Context, tx *sql.Tx, id *string, actorID string) error {
+	if e.Config == nil || !e.Config.Rollup.FromChildren() {
+		return nil
+	}
+	for id != nil {
+		t, err := e.Repo.GetTaskTx(ctx, tx, *id)
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		changed, err := e.rollupTaskTx(ctx, tx, t, actorID)
+		if err != nil || !changed {
+			return err
+		}
+		id = t.ParentID
+	}
+	return nil
+}
+
+// rollupTaskTx moves a parent task to done once every subtask that is not canceled is done
+// and its own validation policy is met, and otherwise to in_progress as soon as a subtask is
+// started or done. A parent already in_progress or review stays there until it can be
+// done; a done parent reopens when a subtask is not. Rejected and canceled parents, and
+// tasks without subtasks, are left alone.
+func (e Engine) rollupTaskTx(ctx context.Context, tx *sql.Tx, t domain.Task, actorID string) (bool, error) {
+	if t.Status == "rejected" || t.Status == "canceled" {
+		return false, nil
+	}
+	ids, err := e.Repo.ListChildrenTx(ctx, tx, t.ID)
+	if err != nil {
+		return false, err
+	}
+	counted, done, started := 0, 0, 0
+	for _, id := range ids {
+		c, err := e.Repo.GetTaskTx(ctx, tx, id)
+		if err != nil {
+			return false, err
+		}
+		switch c.Status {
+		case "canceled":
+			continue
+		case "done":
+			done++
+			started++
+		case "in_progress", "review":
+			started++
+		}
+		counted++
+	}
+	if counted == 0 {
+		return false, nil
+	}
+	status := ""
+	if done == counted {
+		ok, err := e.isTaskValidationSatisfied(ctx, tx, t, actorID)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			status = "done"
+		}
+	}
+	if status == "" && started > 0 && (t.Status == "planned" || t.Status == "done") {
+		status = "in_progress"
+	}
+	if status == "" || status == t.Status {
+		return false, nil
+	}
+	from := t.Status
+	now := e.now().UTC().Format(time.RFC3339)
+	t.Status = status
+	t.UpdatedAt = now
+	t.CompletedAt = nil
+	if status == "done" {
+		t.CompletedAt = &now
+	}
+	if err := e.Repo.UpdateTask(ctx, tx, t); err != nil {
+		return false, err
+	}
+	if err := e.Events.Append(ctx, tx, "task.updated", t.ProjectID, "task", t.ID, actorID, events.EventPayload{
+		"from_status": from,
+		"to_status":   status,
+		"rolled_up":   true,
+	}); err != nil {
+		return false, err
+	}
+	if status == "done" {
+		if err := e.emitUnblocked(ctx, tx, t, actorID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// parseDueAt normalizes an RFC3339 deadline to UTC; empty means no deadline.
+func parseDueAt(s string) (*string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due_at %q: use RFC3339", s)
+	}
+	out := at.UTC().Format(time.RFC3339)
+	return &out, nil
+}
+
+// schedulerConfig returns the scheduler settings of the project, falling back to the
+// loaded config for projects without a stored one.
+func (e Engine) schedulerConfig(ctx context.Context, projectID string) (config.Scheduler, error) {
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		if e.Config == nil {
+			return config.Scheduler{}, nil
+		}
+		return e.Config.Scheduler, nil
+	}
+	if err != nil {
+		return config.Scheduler{}, err
+	}
+	return cfg.Scheduler, nil
+}
+
+// deadlineRank is where the deadline scheduler places a ready task: by effective deadline
+// when it or a task waiting on it has one, then by the length of the chain waiting on it.
+type deadlineRank struct {
+	deadline time.Time
+	hasDue   bool
+	chain    int
+}
+
+// sortByDeadline orders ready tasks earliest effective deadline first. A task's effective
+// deadline is the earliest of its own due_at and, for each unfinished task waiting on it,
+// that task's effective deadline brought forward by step. Ties and tasks without a
+// deadline go to the longest waiting chain, then keep their order in ready.
+func (e Engine) sortByDeadline(ctx context.Context, projectID string, ready []domain.Task, step time.Duration) error {
+	due, err := e.Repo.ListOpenDueDates(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	dependents, err := e.Repo.ListOpenDependents(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	ranks := map[string]deadlineRank{}
+	visiting := map[string]bool{}
+	var rank func(id string) deadlineRank
+	rank = func(id string) deadlineRank {
+		if r, ok := ranks[id]; ok {
+			return r
+		}
+		var r deadlineRank
+		if at, err := time.Parse(time.RFC3339, due[id]); err == nil {
+			r = deadlineRank{deadline: at, hasDue: true}
+		}
+		// Dependency cycles are rejected on write; the guard only keeps a corrupt graph
+		// from recursing forever.
+		visiting[id] = true
+		for _, d := range dependents[id] {
+			if visiting[d] {
+				continue
+			}
+			dr := rank(d)
+			r.chain = max(r.chain, dr.chain+1)
+			if dr.hasDue {
+				if at := dr.deadline.Add(-step); !r.hasDue || at.Before(r.deadline) {
+					r.deadline, r.hasDue = at, true
+				}
+			}
+		}
+		visiting[id] = false
+		ranks[id] = r
+		return r
+	}
+	sort.SliceStable(ready, func(i, j int) bool {
+		a, b := rank(ready[i].ID), rank(ready[j].ID)
+		if a.hasDue != b.hasDue {
+			return a.hasDue
+		}
+		if a.hasDue && !a.deadline.Equal(b.deadline) {
+			return a.deadline.Before(b.deadline)
+		}
+		return a.chain > b.chain
+	})
+	return nil
+}
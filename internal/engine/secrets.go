@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/evidence"
+	"workline/internal/integrations"
+	"workline/internal/repo"
+)
+
+// SecretKinds lists the project secrets that can be rotated.
+var SecretKinds = []string{"webhook", "api_token", "signing"}
+
+// DefaultSecretOverlap is how long superseded versions stay valid after a rotation.
+const DefaultSecretOverlap = 24 * time.Hour
+
+// evidenceSecretName is the name of the signing key for a project's evidence bundles.
+const evidenceSecretName = "evidence"
+
+// ServiceActorPrefix starts the names of the service actors API tokens may be issued for.
+const ServiceActorPrefix = "svc-"
+
+// SecretRotation is the version a rotation created. Value is the new webhook secret or API
+// token and is returned only here; signing keys stay on the server and publish PublicKey.
+type SecretRotation struct {
+	Secret     domain.ProjectSecret `json:"secret"`
+	Value      string               `json:"value,omitempty" doc:"The new webhook secret or API token; it cannot be read again"`
+	Superseded int                  `json:"superseded" doc:"Earlier live versions that now expire after the overlap"`
+}
+
+// RotateSecret creates the next version of a project secret and gives its live earlier
+// versions an expiry overlap from now, so clients switch over while both are accepted; a
+// zero overlap retires them at once. name is the provider for webhook secrets, the actor
+// an API token authenticates as, and empty or "evidence" for the signing key. An API token
+// authenticates as the caller, or as a service actor (named svc-...) the project's first
+// token of that name creates, and only within the project. Records secret.created for a
+// first version and secret.rotated after; needs secret.manage.
+func (e Engine) RotateSecret(ctx context.Context, projectID, kind, name string, overlap time.Duration, actorID string) (SecretRotation, error) {
+	name = strings.TrimSpace(name)
+	if !slices.Contains(SecretKinds, kind) {
+		return SecretRotation{}, fmt.Errorf("invalid secret kind %q: use %s", kind, strings.Join(SecretKinds, ", "))
+	}
+	if overlap < 0 {
+		return SecretRotation{}, errors.New("invalid overlap: must not be negative")
+	}
+	switch kind {
+	case "webhook":
+		if _, ok := integrations.Lookup(name); !ok {
+			return SecretRotation{}, fmt.Errorf("invalid webhook secret name %q: use a provider (%s)", name, strings.Join(integrations.Providers(), ", "))
+		}
+	case "api_token":
+		if name == "" {
+			return SecretRotation{}, errors.New("name is required: the actor the API token authenticates as")
+		}
+	case "signing":
+		if name == "" {
+			name = evidenceSecretName
+		}
+		if name != evidenceSecretName {
+			return SecretRotation{}, fmt.Errorf("invalid signing key name %q: use %s", name, evidenceSecretName)
+		}
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return SecretRotation{}, err
+	}
+	now := e.now().UTC()
+	nowTS := now.Format(time.RFC3339)
+	s := domain.ProjectSecret{
+		ID:        uuid.New().String(),
+		ProjectID: projectID,
+		Kind:      kind,
+		Name:      name,
+		CreatedBy: actorID,
+		CreatedAt: nowTS,
+	}
+	var out SecretRotation
+	switch kind {
+	case "signing":
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return out, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return out, err
+		}
+		s.Value = base64.StdEncoding.EncodeToString(der)
+		s.PublicKey = base64.StdEncoding.EncodeToString(pub)
+		s.KeyID = evidence.KeyID(pub)
+	default:
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return out, err
+		}
+		out.Value = hex.EncodeToString(raw)
+		s.Value = out.Value
+		if kind == "api_token" {
+			out.Value = "wlp_" + out.Value
+			s.Value = repo.HashAPIKey(out.Value)
+		}
+		s.KeyID = secretFingerprint(out.Value)
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return out, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "secret.manage"); err != nil {
+		return out, err
+	}
+	latest, err := e.Repo.LatestSecretVersionTx(ctx, tx, projectID, kind, name)
+	if err != nil {
+		return out, err
+	}
+	if kind == "api_token" && name != actorID {
+		if !strings.HasPrefix(name, ServiceActorPrefix) {
+			return out, fmt.Errorf("invalid API token actor %s: tokens authenticate as the caller or a %s service actor", name, ServiceActorPrefix)
+		}
+		unknown, err := e.Repo.UnknownActorsTx(ctx, tx, []string{name})
+		if err != nil {
+			return out, err
+		}
+		switch {
+		case len(unknown) > 0:
+			if err := e.Auth.EnsureActor(ctx, tx, name); err != nil {
+				return out, err
+			}
+		case latest == 0:
+			return out, fmt.Errorf("invalid API token actor %s: the actor exists and was not created for a token of this project", name)
+		}
+	}
+	s.Version = latest + 1
+	until := now.Add(overlap).Format(time.RFC3339)
+	if out.Superseded, err = e.Repo.ExpireSecretsTx(ctx, tx, projectID, kind, name, until, nowTS); err != nil {
+		return out, err
+	}
+	if err := e.Repo.InsertProjectSecretTx(ctx, tx, s); err != nil {
+		return out, err
+	}
+	evtType := "secret.rotated"
+	payload := events.EventPayload{"secret_id": s.ID, "kind": kind, "name": name, "version": s.Version, "key_id": s.KeyID}
+	if s.Version == 1 {
+		evtType = "secret.created"
+	} else {
+		payload["superseded"] = out.Superseded
+		payload["previous_valid_until"] = until
+	}
+	if err := e.Events.Append(ctx, tx, evtType, projectID, "project", projectID, actorID, payload); err != nil {
+		return out, err
+	}
+	if err := tx.Commit(); err != nil {
+		return SecretRotation{}, err
+	}
+	out.Secret = s
+	return out, nil
+}
+
+// RevokeSecret retires one version of a project secret immediately, recording
+// secret.revoked; needs secret.manage.
+func (e Engine) RevokeSecret(ctx context.Context, projectID, secretID, actorID string) (domain.ProjectSecret, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ProjectSecret{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "secret.manage"); err != nil {
+		return domain.ProjectSecret{}, err
+	}
+	s, err := e.Repo.RevokeSecretTx(ctx, tx, projectID, secretID, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return s, err
+	}
+	if err := e.Events.Append(ctx, tx, "secret.revoked", projectID, "project", projectID, actorID, events.EventPayload{
+		"secret_id": s.ID,
+		"kind":      s.Kind,
+		"name":      s.Name,
+		"version":   s.Version,
+		"key_id":    s.KeyID,
+	}); err != nil {
+		return s, err
+	}
+	return s, tx.Commit()
+}
+
+// evidenceSigner returns the signer for a project's evidence bundles: its newest live
+// signing key, or the server key when the project has none.
+func (e Engine) evidenceSigner(ctx context.Context, projectID string) (*evidence.Signer, error) {
+	keys, err := e.Repo.LiveSecrets(ctx, projectID, "signing", evidenceSecretName, e.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		if e.Evidence == nil {
+			return nil, ErrNoEvidenceSigner
+		}
+		return e.Evidence, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(keys[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", keys[0].ID, err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", keys[0].ID, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s: not an ed25519 key", keys[0].ID)
+	}
+	return evidence.NewSigner(key), nil
+}
+
+// secretFingerprint identifies a secret without revealing it, like evidence.KeyID.
+func secretFingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,345 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+	"workline/internal/seed"
+)
+
+// SeedResult summarizes what ApplySeed loaded.
+type SeedResult struct {
+	Kinds   []string      `json:"kinds"`
+	Presets []string      `json:"presets"`
+	Roles   []string      `json:"roles"`
+	Actors  []string      `json:"actors"`
+	Tasks   []domain.Task `json:"tasks"`
+}
+
+// ApplySeed loads a seed document into an existing project in one transaction.
+// Kinds, presets, roles, authorities and actor grants are merged idempotently;
+// tasks are created on every run, so seeds with explicit task IDs apply once.
+// Roles are shared by all projects, so a seed may create new ones but may not add
+// permissions to a role that already exists.
+func (e Engine) ApplySeed(ctx context.Context, projectID, actorID string, s seed.File) (SeedResult, error) {
+	var res SeedResult
+	if err := s.Validate(); err != nil {
+		return res, err
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return res, err
+	}
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		cfg = config.Default(projectID)
+	} else if err != nil {
+		return res, err
+	}
+	refs := map[string]bool{}
+	var external []string
+	var iterations []string
+	walkSeedTasks(s.Tasks, func(t seed.Task) {
+		if t.Ref != "" {
+			refs[t.Ref] = true
+		}
+		if t.Iteration != "" {
+			iterations = append(iterations, t.Iteration)
+		}
+	})
+	walkSeedTasks(s.Tasks, func(t seed.Task) {
+		for _, dep := range t.DependsOn {
+			if !refs[dep] {
+				external = append(external, dep)
+			}
+		}
+	})
+	for _, id := range iterations {
+		it, err := e.Repo.GetIteration(ctx, id)
+		if err != nil {
+			return res, fmt.Errorf("invalid seed: iteration %s: %w", id, err)
+		}
+		if it.ProjectID != projectID {
+			return res, fmt.Errorf("invalid seed: iteration %s not in project %s", id, projectID)
+		}
+	}
+	for _, id := range external {
+		t, err := e.Repo.GetTask(ctx, id)
+		if err != nil {
+			return res, fmt.Errorf("invalid seed: dependency %s: %w", id, err)
+		}
+		if t.ProjectID != projectID {
+			return res, fmt.Errorf("invalid seed: dependency %s not in project %s", id, projectID)
+		}
+	}
+	if err := seedDependencyCycle(s.Tasks); err != nil {
+		return res, err
+	}
+	mergeSeedConfig(cfg, s)
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "rbac.manage"); err != nil {
+		return res, err
+	}
+	if len(s.Tasks) > 0 {
+		if err := e.requirePermission(ctx, tx, projectID, actorID, "task.create"); err != nil {
+			return res, err
+		}
+	}
+	if err := e.Repo.UpsertProjectConfigTx(ctx, tx, projectID, cfg); err != nil {
+		return res, fmt.Errorf("invalid seed: %w", err)
+	}
+	res.Kinds = sortedKeys(s.Attestations)
+	res.Presets = sortedKeys(s.Presets)
+
+	for _, roleID := range sortedKeys(s.Roles) {
+		role := s.Roles[roleID]
+		exists, err := e.Repo.RoleExistsTx(ctx, tx, roleID)
+		if err != nil {
+			return res, err
+		}
+		if exists {
+			held, err := e.Repo.RolePermissionsTx(ctx, tx, roleID)
+			if err != nil {
+				return res, err
+			}
+			for _, perm := range role.Permissions {
+				if !slices.Contains(held, perm) {
+					return res, fmt.Errorf("invalid seed: role %s already exists without permission %s; roles are shared by all projects", roleID, perm)
+				}
+			}
+			res.Roles = append(res.Roles, roleID)
+			continue
+		}
+		if err := e.Repo.InsertRole(ctx, tx, roleID, role.Description); err != nil {
+			return res, err
+		}
+		for _, perm := range role.Permissions {
+			ok, err := e.Repo.PermissionExistsTx(ctx, tx, perm)
+			if err != nil {
+				return res, err
+			}
+			if !ok {
+				return res, fmt.Errorf("invalid seed: unknown permission %s for role %s", perm, roleID)
+			}
+			if err := e.Repo.AddRolePermission(ctx, tx, roleID, perm); err != nil {
+				return res, err
+			}
+		}
+		res.Roles = append(res.Roles, roleID)
+	}
+	for _, kind := range sortedKeys(s.AttestationAuthorities) {
+		for _, roleID := range s.AttestationAuthorities[kind] {
+			if err := e.requireSeedRole(ctx, tx, roleID); err != nil {
+				return res, err
+			}
+			if err := e.Repo.AllowAttestationRole(ctx, tx, projectID, kind, roleID); err != nil {
+				return res, err
+			}
+		}
+	}
+	for _, a := range s.Actors {
+		if err := e.ensureActor(ctx, tx, a.ID); err != nil {
+			return res, err
+		}
+		for _, roleID := range a.Roles {
+			if err := e.requireSeedRole(ctx, tx, roleID); err != nil {
+				return res, err
+			}
+			if err := e.Repo.AssignRole(ctx, tx, projectID, a.ID, roleID); err != nil {
+				return res, err
+			}
+			if err := e.Events.Append(ctx, tx, "rbac.role_granted", projectID, "rbac", projectID, actorID, events.EventPayload{"actor_id": a.ID, "role_id": roleID}); err != nil {
+				return res, err
+			}
+		}
+		res.Actors = append(res.Actors, a.ID)
+	}
+
+	ids := map[string]string{}
+	deps := map[string][]string{}
+	var create func(tasks []seed.Task, parentID string) error
+	create = func(tasks []seed.Task, parentID string) error {
+		for _, st := range tasks {
+			opts := TaskCreateOptions{
+				ID:             st.ID,
+				ProjectID:      projectID,
+				IterationID:    st.Iteration,
+				ParentID:       parentID,
+				Type:           st.Type,
+				Title:          st.Title,
+				Description:    st.Description,
+				AssigneeID:     st.Assignee,
+				PolicyPreset:   st.Policy,
+				RequiredKinds:  st.Require,
+				PolicyOverride: len(st.Require) > 0,
+				ActorID:        actorID,
+			}
+			if opts.Type == "" {
+				opts.Type = "technical"
+			}
+			t, err := e.insertTaskTx(ctx, tx, cfg, opts)
+			if err != nil {
+				return err
+			}
+			if err := e.checkFreezeTx(ctx, tx, t.IterationID, t.ID, FreezeAddTask, "", actorID); err != nil {
+				return err
+			}
+			if st.Ref != "" {
+				ids[st.Ref] = t.ID
+			}
+			if len(st.DependsOn) > 0 {
+				deps[t.ID] = st.DependsOn
+			}
+			res.Tasks = append(res.Tasks, t)
+			if err := create(st.Children, t.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := create(s.Tasks, ""); err != nil {
+		return res, err
+	}
+	for i, t := range res.Tasks {
+		var resolved []string
+		for _, dep := range deps[t.ID] {
+			if id, ok := ids[dep]; ok {
+				dep = id
+			}
+			resolved = append(resolved, dep)
+		}
+		if len(resolved) == 0 {
+			continue
+		}
+		if err := e.Repo.AddDependencies(ctx, tx, t.ID, resolved); err != nil {
+			return res, err
+		}
+		res.Tasks[i].DependsOn = resolved
+	}
+	if err := e.Events.Append(ctx, tx, "project.seeded", projectID, "project", projectID, actorID, events.EventPayload{
+		"kinds":   len(res.Kinds),
+		"presets": len(res.Presets),
+		"roles":   len(res.Roles),
+		"actors":  len(res.Actors),
+		"tasks":   len(res.Tasks),
+	}); err != nil {
+		return res, err
+	}
+	return res, tx.Commit()
+}
+
+func (e Engine) requireSeedRole(ctx context.Context, tx *sql.Tx, roleID string) error {
+	ok, err := e.Repo.RoleExistsTx(ctx, tx, roleID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid seed: unknown role %s", roleID)
+	}
+	return nil
+}
+
+// mergeSeedConfig adds seeded kinds and presets to cfg. Roles and authorities are
+// mirrored into the config only when it already declares its own RBAC.
+func mergeSeedConfig(cfg *config.Config, s seed.File) {
+	if len(s.Attestations) > 0 && cfg.Attestations.Catalog == nil {
+		cfg.Attestations.Catalog = map[string]config.AttestationKind{}
+	}
+	for kind, k := range s.Attestations {
+		// Keep a deprecation already declared for the kind.
+		entry := cfg.Attestations.Catalog[kind]
+		entry.Description = k.Description
+		cfg.Attestations.Catalog[kind] = entry
+	}
+	if len(s.Presets) > 0 && cfg.Policies.Presets == nil {
+		cfg.Policies.Presets = map[string]config.PolicyPreset{}
+	}
+	for name, require := range s.Presets {
+		cfg.Policies.Presets[name] = config.PolicyPreset{Require: require}
+	}
+	if len(cfg.RBAC.Roles) > 0 {
+		for roleID, role := range s.Roles {
+			cfg.RBAC.Roles[roleID] = config.RBACRole{Description: role.Description, Permissions: role.Permissions}
+		}
+	}
+	if len(cfg.RBAC.AttestationAuthorities) > 0 {
+		for kind, roles := range s.AttestationAuthorities {
+			existing := cfg.RBAC.AttestationAuthorities[kind]
+			for _, r := range roles {
+				if !slices.Contains(existing, r) {
+					existing = append(existing, r)
+				}
+			}
+			cfg.RBAC.AttestationAuthorities[kind] = existing
+		}
+	}
+}
+
+func walkSeedTasks(tasks []seed.Task, fn func(seed.Task)) {
+	for _, t := range tasks {
+		fn(t)
+		walkSeedTasks(t.Children, fn)
+	}
+}
+
+// seedDependencyCycle rejects depends_on cycles among the seed's own refs.
+func seedDependencyCycle(tasks []seed.Task) error {
+	graph := map[string][]string{}
+	walkSeedTasks(tasks, func(t seed.Task) {
+		if t.Ref != "" {
+			graph[t.Ref] = t.DependsOn
+		}
+	})
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(ref string) error
+	visit = func(ref string) error {
+		switch state[ref] {
+		case visiting:
+			return fmt.Errorf("invalid seed: dependency cycle through %s", ref)
+		case done:
+			return nil
+		}
+		state[ref] = visiting
+		for _, dep := range graph[ref] {
+			if _, ok := graph[dep]; !ok {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[ref] = done
+		return nil
+	}
+	for _, ref := range sortedKeys(graph) {
+		if err := visit(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
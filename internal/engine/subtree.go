@@ -0,0 +1,379 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// SubtreeFormat identifies the layout of exported task subtrees.
+const SubtreeFormat = "workline.subtree/v1"
+
+// taskStatuses are the statuses an imported task may carry.
+var taskStatuses = []string{"planned", "in_progress", "review", "done", "rejected", "canceled"}
+
+// SubtreeSnapshot is a task with all its descendants, parents before children, the
+// dependencies between them and their attestations with payloads inlined. Dependencies on
+// tasks outside the subtree are left out.
+type SubtreeSnapshot struct {
+	Format       string                `json:"format"`
+	GeneratedAt  string                `json:"generated_at" format:"date-time"`
+	ProjectID    string                `json:"project_id"`
+	RootID       string                `json:"root_id"`
+	Tasks        []domain.Task         `json:"tasks"`
+	Dependencies []DependencyEdge      `json:"dependencies"`
+	Attestations []EvidenceAttestation `json:"attestations"`
+}
+
+// SubtreeImportOptions says where a snapshot lands. ParentID places its root under an
+// existing task. Template starts every task over as planned and unassigned, without work
+// outcomes or attestations. IDPrefix derives each new ID from the snapshot's, which
+// projects using client-supplied IDs need; otherwise the project's ID scheme applies.
+type SubtreeImportOptions struct {
+	ParentID string
+	Template bool
+	IDPrefix string
+}
+
+// SubtreeImport maps the snapshot's task and attestation IDs to the ones created.
+type SubtreeImport struct {
+	ProjectID    string            `json:"project_id"`
+	RootID       string            `json:"root_id"`
+	Template     bool              `json:"template"`
+	Tasks        map[string]string `json:"tasks" doc:"New task ID per snapshot task ID"`
+	Attestations map[string]string `json:"attestations" doc:"New attestation ID per snapshot attestation ID"`
+}
+
+// ExportSubtree snapshots a task and its descendants with the dependencies among them and
+// every attestation and countersignature recorded on them.
+func (e Engine) ExportSubtree(ctx context.Context, taskID string) (SubtreeSnapshot, error) {
+	root, err := e.Repo.GetTask(ctx, taskID)
+	if err != nil {
+		return SubtreeSnapshot{}, err
+	}
+	s := SubtreeSnapshot{
+		Format:       SubtreeFormat,
+		GeneratedAt:  e.now().UTC().Format(time.RFC3339),
+		ProjectID:    root.ProjectID,
+		RootID:       root.ID,
+		Dependencies: []DependencyEdge{},
+		Attestations: []EvidenceAttestation{},
+	}
+	for queue := []domain.Task{root}; len(queue) > 0; queue = queue[1:] {
+		t := queue[0]
+		s.Tasks = append(s.Tasks, t)
+		childIDs, err := e.Repo.ListChildren(ctx, t.ID)
+		if err != nil {
+			return s, err
+		}
+		children := make([]domain.Task, 0, len(childIDs))
+		for _, id := range childIDs {
+			child, err := e.Repo.GetTask(ctx, id)
+			if err != nil {
+				return s, err
+			}
+			children = append(children, child)
+		}
+		SortTasksByRank(children)
+		queue = append(queue, children...)
+	}
+	inTree := map[string]bool{}
+	for _, t := range s.Tasks {
+		inTree[t.ID] = true
+	}
+	for i, t := range s.Tasks {
+		for _, dep := range t.DependsOn {
+			if inTree[dep] {
+				s.Dependencies = append(s.Dependencies, DependencyEdge{From: dep, To: t.ID})
+			}
+		}
+		s.Tasks[i].DependsOn = nil
+	}
+	var atts []domain.Attestation
+	entityKind, targets := "task", make([]string, 0, len(s.Tasks))
+	for _, t := range s.Tasks {
+		targets = append(targets, t.ID)
+	}
+	// Countersignatures attest attestations, and may themselves be countersigned.
+	for len(targets) > 0 {
+		var next []string
+		for _, id := range targets {
+			found, err := e.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: root.ProjectID, EntityKind: entityKind, EntityID: id})
+			if err != nil {
+				return s, err
+			}
+			for _, a := range found {
+				atts = append(atts, a)
+				next = append(next, a.ID)
+			}
+		}
+		entityKind, targets = "attestation", next
+	}
+	for _, a := range atts {
+		ea, err := e.evidenceAttestation(ctx, a)
+		if err != nil {
+			return s, err
+		}
+		s.Attestations = append(s.Attestations, ea)
+	}
+	return s, nil
+}
+
+// importedAttestation is a snapshot attestation prepared for storage.
+type importedAttestation struct {
+	source EvidenceAttestation
+	att    domain.Attestation
+	blob   *BlobRef
+}
+
+// ImportSubtree recreates a snapshot's tasks in a project under new IDs, in one
+// transaction, with the dependencies among them and, unless opts.Template is set, their
+// assignee, work outcomes and attestations. Every task starts planned: a finished source
+// task is completed again through the usual checks. Attestations keep their time but are
+// recorded as made by the importer, who needs authority for their kinds; their
+// attestation.added events name the source attestation and its original actor (imported_from,
+// original_actor_id). Iterations, leases and
+// waivers are not carried over. Each task records a task.imported event naming its source
+// and its source status.
+func (e Engine) ImportSubtree(ctx context.Context, projectID string, snap SubtreeSnapshot, opts SubtreeImportOptions, actorID string) (SubtreeImport, error) {
+	res := SubtreeImport{ProjectID: projectID, Template: opts.Template, Tasks: map[string]string{}, Attestations: map[string]string{}}
+	if err := snap.validate(); err != nil {
+		return res, err
+	}
+	opts.IDPrefix = strings.TrimSpace(opts.IDPrefix)
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return res, err
+	}
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		cfg = config.Default(projectID)
+	} else if err != nil {
+		return res, err
+	}
+	perms := []string{"task.create"}
+	if !opts.Template && len(snap.Attestations) > 0 {
+		perms = append(perms, "attestation.add")
+	}
+	// Payloads are offloaded before the import transaction; refused callers write no blob.
+	if err := e.precheckPermissions(ctx, projectID, actorID, perms...); err != nil {
+		return res, err
+	}
+	var atts []importedAttestation
+	if !opts.Template {
+		for _, a := range snap.Attestations {
+			att := a.Attestation
+			att.ProjectID = projectID
+			att.ActorID = actorID
+			if len(a.Payload) > 0 {
+				att.PayloadJSON = string(a.Payload)
+			}
+			if err := checkPayloadSize(cfg.Payloads, "attestation payload", att.PayloadJSON); err != nil {
+				return res, fmt.Errorf("attestation %s: %w", a.ID, err)
+			}
+			att, blob, err := e.prepareAttestation(ctx, att, actorID)
+			if err != nil {
+				return res, fmt.Errorf("attestation %s: %w", a.ID, err)
+			}
+			atts = append(atts, importedAttestation{source: a, att: att, blob: blob})
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+	for _, perm := range perms {
+		if err := e.requirePermission(ctx, tx, projectID, actorID, perm); err != nil {
+			return res, err
+		}
+	}
+	if opts.ParentID != "" {
+		parent, err := e.Repo.GetTaskTx(ctx, tx, opts.ParentID)
+		if err != nil {
+			return res, fmt.Errorf("parent %s: %w", opts.ParentID, err)
+		}
+		if parent.ProjectID != projectID {
+			return res, fmt.Errorf("parent %s: %w", opts.ParentID, repo.ErrNotFound)
+		}
+	}
+	for _, src := range snap.Tasks {
+		parentID := opts.ParentID
+		if src.ID != snap.RootID {
+			parentID = res.Tasks[*src.ParentID]
+		}
+		var custom map[string]any
+		if src.CustomFieldsJSON != nil {
+			if err := json.Unmarshal([]byte(*src.CustomFieldsJSON), &custom); err != nil {
+				return res, fmt.Errorf("invalid snapshot: task %s custom fields: %w", src.ID, err)
+			}
+		}
+		create := TaskCreateOptions{
+			ID:                   subtreeTaskID(opts.IDPrefix, src.ID, cfg.IDs.Tasks),
+			ProjectID:            projectID,
+			ParentID:             parentID,
+			Type:                 src.Type,
+			Title:                src.Title,
+			Description:          src.Description,
+			RequiredCapabilities: src.RequiredCapabilities,
+			CustomFields:         custom,
+			ActorID:              actorID,
+		}
+		if src.RequiredAttestationsJSON != nil {
+			create.RequiredKinds = currentPolicy(src).Require
+			create.PolicyOverride = true
+		}
+		if !opts.Template {
+			if src.AssigneeID != nil {
+				create.AssigneeID = *src.AssigneeID
+			}
+			if src.DeferUntil != nil {
+				create.DeferUntil = *src.DeferUntil
+			}
+			if src.DueAt != nil {
+				create.DueAt = *src.DueAt
+			}
+			create.WorkOutcomesJSON = src.WorkOutcomesJSON
+		}
+		t, err := e.insertTaskTx(ctx, tx, cfg, create)
+		if err != nil {
+			return res, fmt.Errorf("task %s: %w", src.ID, err)
+		}
+		res.Tasks[src.ID] = t.ID
+		if err := e.Events.Append(ctx, tx, "task.imported", projectID, "task", t.ID, actorID, events.EventPayload{
+			"source_project": snap.ProjectID,
+			"source_task":    src.ID,
+			"source_status":  src.Status,
+			"status":         t.Status,
+			"template":       opts.Template,
+		}); err != nil {
+			return res, err
+		}
+	}
+	res.RootID = res.Tasks[snap.RootID]
+	deps := map[string][]string{}
+	for _, edge := range snap.Dependencies {
+		to := res.Tasks[edge.To]
+		deps[to] = append(deps[to], res.Tasks[edge.From])
+	}
+	if cycle := dependencyCycle(deps); cycle != nil {
+		return res, fmt.Errorf("invalid snapshot: dependency cycle %s", strings.Join(cycle, " -> "))
+	}
+	for _, src := range snap.Tasks {
+		id := res.Tasks[src.ID]
+		if len(deps[id]) == 0 {
+			continue
+		}
+		if err := e.Repo.AddDependencies(ctx, tx, id, deps[id]); err != nil {
+			return res, err
+		}
+		if err := e.Events.Append(ctx, tx, "task.dependencies.added", projectID, "task", id, actorID, events.EventPayload{
+			"depends_on": deps[id],
+			"imported":   true,
+		}); err != nil {
+			return res, err
+		}
+	}
+	for _, ia := range atts {
+		a := ia.att
+		if a.EntityKind == "task" {
+			a.EntityID = res.Tasks[a.EntityID]
+		} else {
+			a.EntityID = res.Attestations[a.EntityID]
+		}
+		supplied := ""
+		if opts.IDPrefix != "" {
+			supplied = opts.IDPrefix + ia.source.ID
+		}
+		var err error
+		if a.ID, err = e.assignID(ctx, tx, cfg.IDs.Attestations, projectID, "attestations", supplied, nil); err != nil {
+			return res, fmt.Errorf("attestation %s: %w", ia.source.ID, err)
+		}
+		if a, err = e.addAttestationTx(ctx, tx, a, actorID, ia.blob, events.EventPayload{
+			"imported_from":     ia.source.ID,
+			"original_actor_id": ia.source.ActorID,
+		}); err != nil {
+			return res, fmt.Errorf("attestation %s: %w", ia.source.ID, err)
+		}
+		res.Attestations[ia.source.ID] = a.ID
+	}
+	if opts.ParentID != "" {
+		if err := e.rollupTx(ctx, tx, &opts.ParentID, actorID); err != nil {
+			return res, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return SubtreeImport{ProjectID: projectID, Template: opts.Template, Tasks: map[string]string{}, Attestations: map[string]string{}}, err
+	}
+	return res, nil
+}
+
+// validate checks that a snapshot is one tree listed parents first, that its dependencies
+// stay inside it and that its attestations target its tasks or earlier attestations.
+func (s SubtreeSnapshot) validate() error {
+	if s.Format != SubtreeFormat {
+		return fmt.Errorf("invalid snapshot: format %q, expected %s", s.Format, SubtreeFormat)
+	}
+	if len(s.Tasks) == 0 || s.Tasks[0].ID != s.RootID {
+		return errors.New("invalid snapshot: tasks must start with the root task")
+	}
+	seen := map[string]bool{}
+	for i, t := range s.Tasks {
+		switch {
+		case t.ID == "":
+			return fmt.Errorf("invalid snapshot: task %d has no id", i)
+		case seen[t.ID]:
+			return fmt.Errorf("invalid snapshot: task %s is listed twice", t.ID)
+		case i > 0 && (t.ParentID == nil || !seen[*t.ParentID]):
+			return fmt.Errorf("invalid snapshot: task %s must follow its parent", t.ID)
+		case t.Title == "":
+			return fmt.Errorf("invalid snapshot: task %s: title is required", t.ID)
+		case !slices.Contains(taskStatuses, t.Status):
+			return fmt.Errorf("invalid snapshot: task %s: unknown status %q", t.ID, t.Status)
+		}
+		seen[t.ID] = true
+	}
+	for _, edge := range s.Dependencies {
+		if !seen[edge.From] || !seen[edge.To] || edge.From == edge.To {
+			return fmt.Errorf("invalid snapshot: dependency %s -> %s must join two tasks of the snapshot", edge.From, edge.To)
+		}
+	}
+	attested := map[string]bool{}
+	for _, a := range s.Attestations {
+		switch {
+		case a.ID == "" || a.Kind == "" || a.ActorID == "":
+			return errors.New("invalid snapshot: attestations need an id, kind and actor_id")
+		case attested[a.ID]:
+			return fmt.Errorf("invalid snapshot: attestation %s is listed twice", a.ID)
+		case a.EntityKind == "task" && !seen[a.EntityID], a.EntityKind == "attestation" && !attested[a.EntityID]:
+			return fmt.Errorf("invalid snapshot: attestation %s targets %s %s outside the snapshot", a.ID, a.EntityKind, a.EntityID)
+		case a.EntityKind != "task" && a.EntityKind != "attestation":
+			return fmt.Errorf("invalid snapshot: attestation %s targets a %s", a.ID, a.EntityKind)
+		}
+		attested[a.ID] = true
+	}
+	return nil
+}
+
+// subtreeTaskID returns the ID to supply for an imported task: the prefixed source ID, or a
+// fresh UUID under the uuid scheme, whose default derives IDs from titles and the clock and
+// would collide between siblings imported together. Other schemes generate their own.
+func subtreeTaskID(prefix, sourceID string, scheme config.IDScheme) string {
+	if prefix != "" {
+		return prefix + sourceID
+	}
+	if scheme.Strategy == "" || scheme.Strategy == "uuid" {
+		return uuid.New().String()
+	}
+	return ""
+}
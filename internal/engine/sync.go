@@ -0,0 +1,578 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/manifest"
+	"workline/internal/repo"
+)
+
+// SyncChange is one step of a sync plan. Fields lists what an update changes.
+type SyncChange struct {
+	Kind   string   `json:"kind" enum:"preset,task_default,iteration,task"`
+	ID     string   `json:"id" doc:"Preset name, task type, iteration ID or task ID"`
+	Action string   `json:"action" enum:"create,update,remove,cancel"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// SyncPlan reports how a project differs from its manifest, in the order the changes are
+// applied. Applied is false for dry runs and when the project already matches.
+type SyncPlan struct {
+	ProjectID string       `json:"project_id"`
+	DryRun    bool         `json:"dry_run"`
+	Applied   bool         `json:"applied"`
+	Changes   []SyncChange `json:"changes"`
+}
+
+// projectSync carries one SyncProject run through its transaction.
+type projectSync struct {
+	e         Engine
+	ctx       context.Context
+	tx        *sql.Tx
+	cfg       *config.Config
+	projectID string
+	actorID   string
+	now       string
+	plan      *SyncPlan
+	// granted caches permission checks; rollups collects parents whose subtasks changed.
+	granted map[string]bool
+	rollups map[string]bool
+}
+
+// SyncProject reconciles a project with a manifest in one transaction: presets and task
+// defaults are written to the project config, iterations and tasks are created or updated
+// to match, and with m.Prune undeclared presets and defaults are removed and undeclared
+// open tasks canceled. Each change needs the permission its API counterpart needs. A dry
+// run applies the plan and rolls it back, so it fails exactly where the sync would.
+func (e Engine) SyncProject(ctx context.Context, projectID, actorID string, m manifest.File, dryRun bool) (SyncPlan, error) {
+	plan := SyncPlan{ProjectID: projectID, DryRun: dryRun, Changes: []SyncChange{}}
+	if err := m.Validate(); err != nil {
+		return plan, err
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return plan, err
+	}
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		cfg = config.Default(projectID)
+	} else if err != nil {
+		return plan, err
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return plan, err
+	}
+	defer tx.Rollback()
+	s := &projectSync{
+		e: e, ctx: ctx, tx: tx, cfg: cfg, projectID: projectID, actorID: actorID,
+		now: e.now().UTC().Format(time.RFC3339), plan: &plan,
+		granted: map[string]bool{}, rollups: map[string]bool{},
+	}
+	// Reading the plan reveals the project's tasks.
+	if err := s.require("task.list"); err != nil {
+		return plan, err
+	}
+	if err := s.syncPolicies(m.Policies, m.Prune); err != nil {
+		return plan, err
+	}
+	if err := s.syncIterations(m.Iterations); err != nil {
+		return plan, err
+	}
+	if err := s.syncTasks(m.Tasks, m.Prune); err != nil {
+		return plan, err
+	}
+	for _, id := range sortedKeys(s.rollups) {
+		if err := e.rollupTx(ctx, tx, &id, actorID); err != nil {
+			return plan, err
+		}
+	}
+	if dryRun || len(plan.Changes) == 0 {
+		return plan, nil
+	}
+	counts := map[string]int{}
+	for _, c := range plan.Changes {
+		counts[c.Action]++
+	}
+	if err := e.Events.Append(ctx, tx, "project.synced", projectID, "project", projectID, actorID, events.EventPayload{
+		"created":  counts["create"],
+		"updated":  counts["update"],
+		"removed":  counts["remove"],
+		"canceled": counts["cancel"],
+	}); err != nil {
+		return plan, err
+	}
+	if err := tx.Commit(); err != nil {
+		return plan, err
+	}
+	plan.Applied = true
+	return plan, nil
+}
+
+func (s *projectSync) require(perm string) error {
+	if s.granted[perm] {
+		return nil
+	}
+	if err := s.e.requirePermission(s.ctx, s.tx, s.projectID, s.actorID, perm); err != nil {
+		return err
+	}
+	s.granted[perm] = true
+	return nil
+}
+
+func (s *projectSync) record(kind, id, action string, fields ...string) {
+	s.plan.Changes = append(s.plan.Changes, SyncChange{Kind: kind, ID: id, Action: action, Fields: fields})
+}
+
+// syncPolicies brings the config's presets and task defaults in line with the manifest
+// and stores the config when anything changed.
+func (s *projectSync) syncPolicies(p manifest.Policies, prune bool) error {
+	before := len(s.plan.Changes)
+	presets := s.cfg.Policies.Presets
+	if presets == nil {
+		presets = map[string]config.PolicyPreset{}
+	}
+	for _, name := range sortedKeys(p.Presets) {
+		want := p.Presets[name]
+		cur, ok := presets[name]
+		switch {
+		case !ok:
+			s.record("preset", name, "create")
+		case !slices.Equal(cur.Require, want):
+			s.record("preset", name, "update", "require")
+		default:
+			continue
+		}
+		cur.Require = want
+		presets[name] = cur
+	}
+	defaults := s.cfg.Policies.Defaults.Task
+	if defaults == nil {
+		defaults = map[string]string{}
+	}
+	for _, taskType := range sortedKeys(p.Defaults) {
+		want := p.Defaults[taskType]
+		cur, ok := defaults[taskType]
+		switch {
+		case !ok:
+			s.record("task_default", taskType, "create")
+		case cur != want:
+			s.record("task_default", taskType, "update", "preset")
+		default:
+			continue
+		}
+		defaults[taskType] = want
+	}
+	if prune {
+		for _, name := range sortedKeys(presets) {
+			if _, ok := p.Presets[name]; !ok {
+				s.record("preset", name, "remove")
+				delete(presets, name)
+			}
+		}
+		for _, taskType := range sortedKeys(defaults) {
+			if _, ok := p.Defaults[taskType]; !ok {
+				s.record("task_default", taskType, "remove")
+				delete(defaults, taskType)
+			}
+		}
+	}
+	for _, taskType := range sortedKeys(defaults) {
+		if _, ok := presets[defaults[taskType]]; !ok {
+			return fmt.Errorf("invalid manifest: task default %s names unknown preset %s", taskType, defaults[taskType])
+		}
+	}
+	if len(s.plan.Changes) == before {
+		return nil
+	}
+	if err := s.require("project.update"); err != nil {
+		return err
+	}
+	s.cfg.Policies.Presets = presets
+	s.cfg.Policies.Defaults.Task = defaults
+	if err := s.e.Repo.UpsertProjectConfigTx(s.ctx, s.tx, s.projectID, s.cfg); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	return nil
+}
+
+// syncIterations creates missing iterations and updates goals. Statuses are left alone.
+func (s *projectSync) syncIterations(iterations []manifest.Iteration) error {
+	for _, mi := range iterations {
+		it, err := s.e.Repo.GetIterationTx(s.ctx, s.tx, mi.ID)
+		if errors.Is(err, repo.ErrNotFound) {
+			if err := s.require("iteration.create"); err != nil {
+				return err
+			}
+			it = domain.Iteration{ID: mi.ID, ProjectID: s.projectID, Goal: mi.Goal, Status: "pending", CreatedAt: s.now}
+			if err := s.e.Repo.InsertIterationTx(s.ctx, s.tx, it); err != nil {
+				return err
+			}
+			if err := s.e.Events.Append(s.ctx, s.tx, "iteration.created", s.projectID, "iteration", it.ID, s.actorID, events.EventPayload{"status": it.Status}); err != nil {
+				return err
+			}
+			s.record("iteration", it.ID, "create")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if it.ProjectID != s.projectID {
+			return fmt.Errorf("invalid manifest: iteration %s belongs to project %s", it.ID, it.ProjectID)
+		}
+		if it.Goal == mi.Goal {
+			continue
+		}
+		if err := s.require("iteration.update"); err != nil {
+			return err
+		}
+		if err := s.e.Repo.UpdateIterationGoalTx(s.ctx, s.tx, it.ID, mi.Goal); err != nil {
+			return err
+		}
+		if err := s.e.Events.Append(s.ctx, s.tx, "iteration.goal.updated", s.projectID, "iteration", it.ID, s.actorID, events.EventPayload{
+			"old_goal": it.Goal,
+			"new_goal": mi.Goal,
+		}); err != nil {
+			return err
+		}
+		s.record("iteration", it.ID, "update", "goal")
+	}
+	return nil
+}
+
+// syncTasks creates and updates the declared tasks, parents first, then their
+// dependencies once every task exists, then cancels undeclared open tasks when pruning.
+func (s *projectSync) syncTasks(tasks []manifest.Task, prune bool) error {
+	declared := map[string]bool{}
+	changes := map[string]int{}
+	var err error
+	manifest.Walk(tasks, func(mt manifest.Task, parentID string) {
+		if err != nil {
+			return
+		}
+		declared[mt.ID] = true
+		err = s.syncTask(mt, parentID, changes)
+	})
+	if err != nil {
+		return err
+	}
+	manifest.Walk(tasks, func(mt manifest.Task, _ string) {
+		if err != nil {
+			return
+		}
+		err = s.syncDependencies(mt, declared, changes)
+	})
+	if err != nil {
+		return err
+	}
+	graph, err := s.e.Repo.ListProjectDependenciesTx(s.ctx, s.tx, s.projectID)
+	if err != nil {
+		return err
+	}
+	if cycle := dependencyCycle(graph); cycle != nil {
+		return fmt.Errorf("invalid manifest: dependency cycle %s", strings.Join(cycle, " -> "))
+	}
+	if !prune {
+		return nil
+	}
+	open, err := s.e.Repo.ListOpenTaskIDsTx(s.ctx, s.tx, s.projectID)
+	if err != nil {
+		return err
+	}
+	for _, id := range open {
+		if declared[id] {
+			continue
+		}
+		if err := s.cancelTask(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncTask creates mt or updates the fields that differ from it. changes maps the task to
+// its entry in the plan, which dependency changes extend.
+func (s *projectSync) syncTask(mt manifest.Task, parentID string, changes map[string]int) error {
+	if mt.Iteration != "" {
+		it, err := s.e.Repo.GetIterationTx(s.ctx, s.tx, mt.Iteration)
+		if errors.Is(err, repo.ErrNotFound) {
+			return fmt.Errorf("invalid manifest: task %s: iteration %s does not exist", mt.ID, mt.Iteration)
+		}
+		if err != nil {
+			return err
+		}
+		if it.ProjectID != s.projectID {
+			return fmt.Errorf("invalid manifest: task %s: iteration %s not in project %s", mt.ID, mt.Iteration, s.projectID)
+		}
+	}
+	if mt.Type == "" {
+		mt.Type = "technical"
+	}
+	t, err := s.e.Repo.GetTaskTx(s.ctx, s.tx, mt.ID)
+	if errors.Is(err, repo.ErrNotFound) {
+		if err := s.require("task.create"); err != nil {
+			return err
+		}
+		t, err := s.e.insertTaskTx(s.ctx, s.tx, s.cfg, TaskCreateOptions{
+			ID:             mt.ID,
+			ProjectID:      s.projectID,
+			IterationID:    mt.Iteration,
+			ParentID:       parentID,
+			Type:           mt.Type,
+			Title:          mt.Title,
+			Description:    mt.Description,
+			AssigneeID:     mt.Assignee,
+			PolicyPreset:   mt.Policy,
+			RequiredKinds:  mt.Require,
+			PolicyOverride: len(mt.Require) > 0,
+			ActorID:        s.actorID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.e.checkFreezeTx(s.ctx, s.tx, t.IterationID, t.ID, FreezeAddTask, "", s.actorID); err != nil {
+			return err
+		}
+		if t.ParentID != nil {
+			s.rollups[*t.ParentID] = true
+		}
+		changes[t.ID] = len(s.plan.Changes)
+		s.record("task", t.ID, "create")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if t.ProjectID != s.projectID {
+		return fmt.Errorf("invalid manifest: task %s belongs to project %s", t.ID, t.ProjectID)
+	}
+	original := t
+	var fields []string
+	if mt.Type != t.Type {
+		if err := checkTaskType(s.cfg, mt.Type); err != nil {
+			return fmt.Errorf("task %s: %w", t.ID, err)
+		}
+		var custom map[string]any
+		if t.CustomFieldsJSON != nil {
+			_ = json.Unmarshal([]byte(*t.CustomFieldsJSON), &custom)
+		}
+		if err := validateCustomFields(s.cfg, mt.Type, custom); err != nil {
+			return fmt.Errorf("task %s: %w", t.ID, err)
+		}
+		t.Type = mt.Type
+		fields = append(fields, "type")
+	}
+	if mt.Title != t.Title {
+		t.Title = mt.Title
+		fields = append(fields, "title")
+	}
+	if mt.Description != t.Description {
+		t.Description = mt.Description
+		fields = append(fields, "description")
+	}
+	if want := optionalString(mt.Iteration); !sameOptionalString(want, t.IterationID) {
+		t.IterationID = want
+		fields = append(fields, "iteration")
+	}
+	if want := optionalString(parentID); !sameOptionalString(want, t.ParentID) {
+		for _, p := range []*string{t.ParentID, want} {
+			if p != nil {
+				s.rollups[*p] = true
+			}
+		}
+		t.ParentID = want
+		fields = append(fields, "parent")
+	}
+	if want := optionalString(mt.Assignee); !sameOptionalString(want, t.AssigneeID) {
+		t.AssigneeID = want
+		fields = append(fields, "assignee")
+	}
+	if mt.Policy != "" || len(mt.Require) > 0 {
+		require := mt.Require
+		if mt.Policy != "" {
+			preset, ok := s.cfg.Policies.Presets[mt.Policy]
+			if !ok {
+				return fmt.Errorf("policy preset %s not found", mt.Policy)
+			}
+			require = preset.Require
+		}
+		want, err := marshalStringSlice(require)
+		if err != nil {
+			return err
+		}
+		if !sameOptionalString(want, t.RequiredAttestationsJSON) {
+			t.RequiredAttestationsJSON = want
+			fields = append(fields, "policy")
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	// A manifest has no room for a freeze override reason, so it cannot change frozen scope.
+	if slices.Contains(fields, "iteration") {
+		if err := s.e.checkFreezeTx(s.ctx, s.tx, t.IterationID, t.ID, FreezeAddTask, "", s.actorID); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(fields, "policy") && loosened(currentPolicy(original).Require, currentPolicy(t).Require) {
+		if err := s.e.checkFreezeTx(s.ctx, s.tx, t.IterationID, t.ID, FreezeLoosenPolicy, "", s.actorID); err != nil {
+			return err
+		}
+	}
+	if err := s.require("task.update"); err != nil {
+		return err
+	}
+	if slices.Contains(fields, "parent") || slices.Contains(fields, "iteration") {
+		// A new sibling group gets the task after its existing members.
+		rank, err := s.e.Repo.NextTaskRankTx(s.ctx, s.tx, t.ProjectID, t.ParentID, t.IterationID)
+		if err != nil {
+			return err
+		}
+		if err := s.e.Repo.SetTaskRank(s.ctx, s.tx, t.ID, rank); err != nil {
+			return err
+		}
+	}
+	t.UpdatedAt = s.now
+	if err := s.e.Repo.UpdateTask(s.ctx, s.tx, t); err != nil {
+		return err
+	}
+	if slices.Contains(fields, "assignee") {
+		if err := s.e.recordHandoff(s.ctx, s.tx, original, original.AssigneeID, t.AssigneeID, s.actorID, ""); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(fields, "policy") {
+		payload := events.EventPayload{"old_require": currentPolicy(original).Require, "new_require": currentPolicy(t).Require}
+		eventType := "policy.override"
+		if mt.Policy != "" {
+			eventType = "task.policy.updated"
+			payload["preset_name"] = mt.Policy
+		}
+		if err := s.e.Events.Append(s.ctx, s.tx, eventType, s.projectID, "task", t.ID, s.actorID, payload); err != nil {
+			return err
+		}
+	}
+	if err := s.e.Events.Append(s.ctx, s.tx, "task.updated", s.projectID, "task", t.ID, s.actorID, events.EventPayload{
+		"from_status": t.Status,
+		"to_status":   t.Status,
+		"fields":      fields,
+	}); err != nil {
+		return err
+	}
+	changes[t.ID] = len(s.plan.Changes)
+	s.record("task", t.ID, "update", fields...)
+	return nil
+}
+
+// syncDependencies replaces the dependencies of a declared task with the declared ones.
+// Dependencies outside the manifest must be tasks of the project.
+func (s *projectSync) syncDependencies(mt manifest.Task, declared map[string]bool, changes map[string]int) error {
+	current, err := s.e.Repo.ListTaskDependenciesTx(s.ctx, s.tx, mt.ID)
+	if err != nil {
+		return err
+	}
+	var add, remove []string
+	for _, dep := range mt.DependsOn {
+		if slices.Contains(current, dep) || slices.Contains(add, dep) {
+			continue
+		}
+		if !declared[dep] {
+			t, err := s.e.Repo.GetTaskTx(s.ctx, s.tx, dep)
+			if errors.Is(err, repo.ErrNotFound) {
+				return fmt.Errorf("invalid manifest: task %s: dependency %s does not exist", mt.ID, dep)
+			}
+			if err != nil {
+				return err
+			}
+			if t.ProjectID != s.projectID {
+				return fmt.Errorf("invalid manifest: task %s: dependency %s not in project %s", mt.ID, dep, s.projectID)
+			}
+		}
+		add = append(add, dep)
+	}
+	for _, dep := range current {
+		if !slices.Contains(mt.DependsOn, dep) {
+			remove = append(remove, dep)
+		}
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	i, tracked := changes[mt.ID]
+	created := tracked && s.plan.Changes[i].Action == "create"
+	if created {
+		return s.e.Repo.AddDependencies(s.ctx, s.tx, mt.ID, add)
+	}
+	if err := s.require("task.update"); err != nil {
+		return err
+	}
+	if len(add) > 0 {
+		if err := s.e.Repo.AddDependencies(s.ctx, s.tx, mt.ID, add); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		if err := s.e.Repo.RemoveDependencies(s.ctx, s.tx, mt.ID, remove); err != nil {
+			return err
+		}
+	}
+	if err := s.e.Events.Append(s.ctx, s.tx, "task.dependencies.synced", s.projectID, "task", mt.ID, s.actorID, events.EventPayload{
+		"added":   add,
+		"removed": remove,
+	}); err != nil {
+		return err
+	}
+	if tracked {
+		s.plan.Changes[i].Fields = append(s.plan.Changes[i].Fields, "depends_on")
+		return nil
+	}
+	changes[mt.ID] = len(s.plan.Changes)
+	s.record("task", mt.ID, "update", "depends_on")
+	return nil
+}
+
+// cancelTask cancels an open task the manifest no longer declares. A task whose
+// transition to canceled is not allowed, or whose lease another actor holds, stops the
+// sync instead of being skipped.
+func (s *projectSync) cancelTask(id string) error {
+	t, err := s.e.Repo.GetTaskTx(s.ctx, s.tx, id)
+	if err != nil {
+		return err
+	}
+	reason, err := s.e.cascadeBlocker(s.ctx, s.tx, t, s.actorID, false)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("invalid sync: cannot cancel undeclared task %s: %s", t.ID, reason)
+	}
+	if err := s.require("task.update"); err != nil {
+		return err
+	}
+	from := t.Status
+	t.Status = "canceled"
+	t.UpdatedAt = s.now
+	if err := s.e.Repo.UpdateTask(s.ctx, s.tx, t); err != nil {
+		return err
+	}
+	if err := s.e.Events.Append(s.ctx, s.tx, "task.updated", s.projectID, "task", t.ID, s.actorID, events.EventPayload{
+		"from_status": from,
+		"to_status":   t.Status,
+	}); err != nil {
+		return err
+	}
+	if t.ParentID != nil {
+		s.rollups[*t.ParentID] = true
+	}
+	s.record("task", t.ID, "cancel")
+	return nil
+}
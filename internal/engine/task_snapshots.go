@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+const (
+	// TaskSnapshotTTL is how long a task list snapshot survives after its last page was read.
+	TaskSnapshotTTL = 10 * time.Minute
+	// MaxTaskSnapshotsPerActor bounds the snapshots one actor holds; taking another drops
+	// the least recently read.
+	MaxTaskSnapshotsPerActor = 4
+	// snapshotReadLayout records last reads at a fixed width, so they sort as text.
+	snapshotReadLayout = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+// CaptureTaskSnapshot copies the tasks f matches into a new snapshot owned by actorID for
+// the list named by scope. The snapshot lives in the database, so any server sharing it
+// can serve its pages. Expired snapshots are dropped on the way.
+func (e Engine) CaptureTaskSnapshot(ctx context.Context, scope, actorID string, f repo.TaskFilters) (domain.TaskListSnapshot, error) {
+	now := e.now().UTC()
+	s := domain.TaskListSnapshot{
+		ID:         strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Scope:      scope,
+		ActorID:    actorID,
+		TakenAt:    now.Format(time.RFC3339),
+		LastReadAt: now.Format(snapshotReadLayout),
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback()
+	readBefore := now.Add(-TaskSnapshotTTL).Format(snapshotReadLayout)
+	if err := e.Repo.DeleteTaskSnapshotsTx(ctx, tx, readBefore, actorID, MaxTaskSnapshotsPerActor-1); err != nil {
+		return s, err
+	}
+	if s, err = e.Repo.InsertTaskSnapshotTx(ctx, tx, s, f); err != nil {
+		return s, err
+	}
+	return s, tx.Commit()
+}
+
+// TaskSnapshotPage returns up to limit tasks of snapshot id after position after, with the
+// snapshot. A snapshot that expired, or belongs to another list or actor, is not found.
+func (e Engine) TaskSnapshotPage(ctx context.Context, id, scope, actorID string, after, limit int) (domain.TaskListSnapshot, []domain.Task, error) {
+	s, err := e.Repo.GetTaskSnapshot(ctx, id)
+	if err != nil {
+		return s, nil, err
+	}
+	now := e.now().UTC()
+	lastRead, err := time.Parse(snapshotReadLayout, s.LastReadAt)
+	if err != nil {
+		return s, nil, err
+	}
+	if s.Scope != scope || s.ActorID != actorID || now.Sub(lastRead) > TaskSnapshotTTL {
+		return s, nil, fmt.Errorf("task snapshot %s: %w", id, repo.ErrNotFound)
+	}
+	items, err := e.Repo.ListTaskSnapshotItems(ctx, id, after, limit)
+	if err != nil {
+		return s, nil, err
+	}
+	s.LastReadAt = now.Format(snapshotReadLayout)
+	return s, items, e.Repo.TouchTaskSnapshot(ctx, id, s.LastReadAt)
+}
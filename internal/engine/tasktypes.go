@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/config"
+)
+
+// TaskTypeInfo describes a task type available in a project. Fields is the JSON Schema
+// custom_fields must match, when the type declares one, and RequiredOutcomes the work
+// outcome fields completing a task of the type needs.
+type TaskTypeInfo struct {
+	Name             string         `json:"name"`
+	Builtin          bool           `json:"builtin"`
+	Description      string         `json:"description,omitempty"`
+	Fields           map[string]any `json:"fields,omitempty"`
+	RequiredOutcomes []string       `json:"required_outcomes,omitempty"`
+}
+
+// TaskTypes lists the built-in and declared task types of cfg.
+func TaskTypes(cfg *config.Config) []TaskTypeInfo {
+	var res []TaskTypeInfo
+	for _, name := range cfg.TaskTypeNames() {
+		tt := cfg.TaskTypes[name]
+		res = append(res, TaskTypeInfo{
+			Name:             name,
+			Builtin:          slices.Contains(config.BuiltinTaskTypes, name),
+			Description:      tt.Description,
+			Fields:           tt.Fields,
+			RequiredOutcomes: tt.RequiredOutcomes,
+		})
+	}
+	return res
+}
+
+// MissingOutcomesError refuses to complete a task whose work outcomes lack fields its type
+// requires under task_types.<type>.required_outcomes.
+type MissingOutcomesError struct {
+	TaskType string
+	Fields   []string
+}
+
+func (e MissingOutcomesError) Error() string {
+	return fmt.Sprintf("work_outcomes lack fields required for task type %s: %s", e.TaskType, strings.Join(e.Fields, ", "))
+}
+
+// checkRequiredOutcomes reports the fields required for taskType that workOutcomesJSON
+// leaves out, null or empty.
+func checkRequiredOutcomes(cfg *config.Config, taskType, workOutcomesJSON string) error {
+	required := cfg.TaskTypes[taskType].RequiredOutcomes
+	if len(required) == 0 {
+		return nil
+	}
+	var outcomes map[string]any
+	_ = json.Unmarshal([]byte(workOutcomesJSON), &outcomes)
+	var missing []string
+	for _, field := range required {
+		switch v := outcomes[field].(type) {
+		case nil:
+		case string:
+			if strings.TrimSpace(v) != "" {
+				continue
+			}
+		case []any:
+			if len(v) > 0 {
+				continue
+			}
+		case map[string]any:
+			if len(v) > 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		missing = append(missing, field)
+	}
+	if len(missing) > 0 {
+		return MissingOutcomesError{TaskType: taskType, Fields: missing}
+	}
+	return nil
+}
+
+func checkTaskType(cfg *config.Config, taskType string) error {
+	if !cfg.HasTaskType(taskType) {
+		return fmt.Errorf("invalid task type %q: declare it under task_types", taskType)
+	}
+	return nil
+}
+
+// customFieldsJSON validates fields against the schema declared for taskType and returns
+// the document to store. A nil map stores nothing but must still satisfy the schema.
+func customFieldsJSON(cfg *config.Config, taskType string, fields map[string]any) (*string, error) {
+	if err := validateCustomFields(cfg, taskType, fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayloadSize(cfg.Payloads, "custom_fields", string(data)); err != nil {
+		return nil, err
+	}
+	out := string(data)
+	return &out, nil
+}
+
+func validateCustomFields(cfg *config.Config, taskType string, fields map[string]any) error {
+	tt, ok := cfg.TaskTypes[taskType]
+	if !ok || tt.Fields == nil {
+		return nil
+	}
+	schema, err := fieldsSchema(tt.Fields)
+	if err != nil {
+		return fmt.Errorf("invalid custom fields schema for task type %s: %w", taskType, err)
+	}
+	// Round-trip through JSON so values match what the schema validator expects.
+	var value any = map[string]any{}
+	if fields != nil {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	registry := huma.NewMapRegistry("#/components/schemas/", huma.DefaultSchemaNamer)
+	path := huma.NewPathBuffer([]byte{}, 0)
+	path.Push("custom_fields")
+	res := &huma.ValidateResult{}
+	huma.Validate(registry, schema, path, huma.ModeWriteToServer, value, res)
+	if len(res.Errors) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(res.Errors))
+	for _, e := range res.Errors {
+		msgs = append(msgs, e.Error())
+	}
+	return fmt.Errorf("invalid custom_fields for task type %s: %s", taskType, strings.Join(msgs, "; "))
+}
+
+func fieldsSchema(fields map[string]any) (*huma.Schema, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var schema huma.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	if schema.Type == "" {
+		schema.Type = huma.TypeObject
+	}
+	schema.PrecomputeMessages()
+	return &schema, nil
+}
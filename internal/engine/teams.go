@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// CreateTeam adds an empty team to a project. Managing teams needs rbac.manage.
+func (e Engine) CreateTeam(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error) {
+	teamID = strings.TrimSpace(teamID)
+	if teamID == "" {
+		return domain.Team{}, fmt.Errorf("team id is required")
+	}
+	if !suppliedIDPattern.MatchString(teamID) {
+		return domain.Team{}, fmt.Errorf("invalid team id %q: use letters, digits, '.', '_', ':' or '-'", teamID)
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Team{}, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "rbac.manage"); err != nil {
+		return domain.Team{}, err
+	}
+	exists, err := e.Repo.TeamExistsTx(ctx, tx, projectID, teamID)
+	if err != nil {
+		return domain.Team{}, err
+	}
+	if exists {
+		return domain.Team{}, fmt.Errorf("invalid team id: team %s already exists", teamID)
+	}
+	if err := e.Repo.InsertTeamTx(ctx, tx, domain.Team{
+		ProjectID:   projectID,
+		ID:          teamID,
+		Description: strings.TrimSpace(description),
+		CreatedBy:   actorID,
+		CreatedAt:   e.now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		return domain.Team{}, err
+	}
+	if err := e.Events.Append(ctx, tx, "rbac.team_created", projectID, "rbac", projectID, actorID, events.EventPayload{"team_id": teamID}); err != nil {
+		return domain.Team{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Team{}, err
+	}
+	return e.Repo.GetTeam(ctx, projectID, teamID)
+}
+
+// UpdateTeam replaces the description of a team.
+func (e Engine) UpdateTeam(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error) {
+	err := e.changeTeam(ctx, projectID, teamID, actorID, "rbac.team_updated", events.EventPayload{"team_id": teamID}, func(tx *sql.Tx) error {
+		return e.Repo.UpdateTeamTx(ctx, tx, projectID, teamID, strings.TrimSpace(description))
+	})
+	if err != nil {
+		return domain.Team{}, err
+	}
+	return e.Repo.GetTeam(ctx, projectID, teamID)
+}
+
+// DeleteTeam removes a team; its members lose the roles they held through it.
+func (e Engine) DeleteTeam(ctx context.Context, projectID, teamID, actorID string) error {
+	return e.changeTeam(ctx, projectID, teamID, actorID, "rbac.team_deleted", events.EventPayload{"team_id": teamID}, func(tx *sql.Tx) error {
+		return e.Repo.DeleteTeamTx(ctx, tx, projectID, teamID)
+	})
+}
+
+// AddTeamMember adds an actor to a team. Adding a member again has no effect.
+func (e Engine) AddTeamMember(ctx context.Context, projectID, teamID, memberID, actorID string) error {
+	if strings.TrimSpace(memberID) == "" {
+		return fmt.Errorf("actor_id is required")
+	}
+	return e.changeTeam(ctx, projectID, teamID, actorID, "rbac.team_member_added", events.EventPayload{"team_id": teamID, "actor_id": memberID}, func(tx *sql.Tx) error {
+		if err := e.ensureActor(ctx, tx, memberID); err != nil {
+			return err
+		}
+		return e.Repo.AddTeamMemberTx(ctx, tx, projectID, teamID, memberID, e.now().UTC().Format(time.RFC3339))
+	})
+}
+
+// RemoveTeamMember takes an actor out of a team.
+func (e Engine) RemoveTeamMember(ctx context.Context, projectID, teamID, memberID, actorID string) error {
+	return e.changeTeam(ctx, projectID, teamID, actorID, "rbac.team_member_removed", events.EventPayload{"team_id": teamID, "actor_id": memberID}, func(tx *sql.Tx) error {
+		return e.Repo.RemoveTeamMemberTx(ctx, tx, projectID, teamID, memberID)
+	})
+}
+
+// GrantTeamRoleUntil grants a role to every member of a team, until expiresAt (RFC3339)
+// or permanently when it is empty. Granting an already held role replaces its expiry.
+func (e Engine) GrantTeamRoleUntil(ctx context.Context, projectID, actorID, teamID, roleID, expiresAt string) error {
+	expiresAt, err := e.grantExpiry(expiresAt)
+	if err != nil {
+		return err
+	}
+	payload := events.EventPayload{"team_id": teamID, "role_id": roleID}
+	if expiresAt != "" {
+		payload["expires_at"] = expiresAt
+	}
+	return e.changeTeam(ctx, projectID, teamID, actorID, "rbac.role_granted", payload, func(tx *sql.Tx) error {
+		ok, err := e.Repo.RoleExistsTx(ctx, tx, roleID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("invalid role %q: not defined", roleID)
+		}
+		return e.Repo.AssignTeamRoleUntil(ctx, tx, projectID, teamID, roleID, expiresAt)
+	})
+}
+
+// RevokeTeamRole takes a role back from a team.
+func (e Engine) RevokeTeamRole(ctx context.Context, projectID, actorID, teamID, roleID string) error {
+	return e.changeTeam(ctx, projectID, teamID, actorID, "rbac.role_revoked", events.EventPayload{"team_id": teamID, "role_id": roleID}, func(tx *sql.Tx) error {
+		return e.Repo.RevokeTeamRole(ctx, tx, projectID, teamID, roleID)
+	})
+}
+
+// changeTeam applies change to an existing team of the project as actorID, who needs
+// rbac.manage, and records evtType.
+func (e Engine) changeTeam(ctx context.Context, projectID, teamID, actorID, evtType string, payload events.EventPayload, change func(tx *sql.Tx) error) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "rbac.manage"); err != nil {
+		return err
+	}
+	exists, err := e.Repo.TeamExistsTx(ctx, tx, projectID, teamID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("team %s: %w", teamID, repo.ErrNotFound)
+	}
+	if err := change(tx); err != nil {
+		return err
+	}
+	if err := e.Events.Append(ctx, tx, evtType, projectID, "rbac", projectID, actorID, payload); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
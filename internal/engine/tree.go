@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// TaskTreeNode is a task with its subtasks, ordered by rank.
+type TaskTreeNode struct {
+	Task     domain.Task
+	Children []TaskTreeNode
+}
+
+// TaskTree builds the hierarchy of the tasks f selects, with their dependencies, from one
+// read transaction: a concurrent reparenting or move is seen whole or not at all, so no
+// task shows under two parents or under none. Tasks whose parent f leaves out are not
+// shown. Callers check permissions.
+func (e Engine) TaskTree(ctx context.Context, f repo.TaskFilters) ([]TaskTreeNode, error) {
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	tasks, err := e.Repo.ListTasksTx(ctx, tx, f)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := e.Repo.ListProjectDependenciesTx(ctx, tx, f.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	children := map[string][]domain.Task{}
+	var roots []domain.Task
+	for _, t := range tasks {
+		t.DependsOn = deps[t.ID]
+		if t.ParentID != nil {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+	var build func(domain.Task, int) (TaskTreeNode, error)
+	build = func(t domain.Task, depth int) (TaskTreeNode, error) {
+		if err := repo.CheckTreeDepth(depth); err != nil {
+			return TaskTreeNode{}, err
+		}
+		kids := children[t.ID]
+		SortTasksByRank(kids)
+		node := TaskTreeNode{Task: t, Children: []TaskTreeNode{}}
+		for _, c := range kids {
+			child, err := build(c, depth+1)
+			if err != nil {
+				return TaskTreeNode{}, err
+			}
+			node.Children = append(node.Children, child)
+		}
+		return node, nil
+	}
+	SortTasksByRank(roots)
+	res := []TaskTreeNode{}
+	for _, r := range roots {
+		node, err := build(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, node)
+	}
+	return res, nil
+}
+
+// SortTasksByRank orders siblings by rank, then creation time and ID. Ranks only compare
+// within one parent and iteration; see rankWithinSiblings for mixed lists.
+func SortTasksByRank(tasks []domain.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Rank != tasks[j].Rank {
+			return tasks[i].Rank < tasks[j].Rank
+		}
+		if tasks[i].CreatedAt != tasks[j].CreatedAt {
+			return tasks[i].CreatedAt < tasks[j].CreatedAt
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// rankWithinSiblings puts each sibling group of tasks (same parent and iteration) in rank
+// order, within the positions the group already holds, so unrelated tasks keep their order.
+func rankWithinSiblings(tasks []domain.Task) {
+	type siblings struct{ parent, iteration string }
+	positions := map[siblings][]int{}
+	for i, t := range tasks {
+		var k siblings
+		if t.ParentID != nil {
+			k.parent = *t.ParentID
+		}
+		if t.IterationID != nil {
+			k.iteration = *t.IterationID
+		}
+		positions[k] = append(positions[k], i)
+	}
+	for _, pos := range positions {
+		if len(pos) < 2 {
+			continue
+		}
+		group := make([]domain.Task, len(pos))
+		for j, i := range pos {
+			group[j] = tasks[i]
+		}
+		SortTasksByRank(group)
+		for j, i := range pos {
+			tasks[i] = group[j]
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// Usage quota kinds.
+const (
+	QuotaRequests  = "requests"
+	QuotaMutations = "mutations"
+)
+
+// QuotaExceededError rejects a call beyond the actor's daily quota. The counters reset
+// at ResetAt, the next UTC midnight.
+type QuotaExceededError struct {
+	Quota   string
+	Limit   int
+	ResetAt time.Time
+}
+
+func (e QuotaExceededError) Error() string {
+	return fmt.Sprintf("daily %s quota of %d exhausted; it resets at %s", e.Quota, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// ActorQuota is the daily limit applying to an actor through its roles; zero is unlimited.
+type ActorQuota struct {
+	Requests  int `json:"requests"`
+	Mutations int `json:"mutations"`
+}
+
+// UsageReport lists per-actor daily counters for a period, newest day first. Quotas holds
+// the limits currently applying to each listed actor that has any.
+type UsageReport struct {
+	ProjectID string                `json:"project_id"`
+	From      string                `json:"from" example:"2026-10-01"`
+	To        string                `json:"to" example:"2026-10-17"`
+	Usage     []domain.ActorUsage   `json:"usage"`
+	Quotas    map[string]ActorQuota `json:"quotas"`
+}
+
+// quotasFor returns the quota table of the project, falling back to the engine config for
+// projects without a stored config.
+func (e Engine) quotasFor(ctx context.Context, projectID string) (config.Quotas, error) {
+	cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		if e.Config == nil {
+			return config.Quotas{}, nil
+		}
+		return e.Config.Effective().Quotas, nil
+	}
+	if err != nil {
+		return config.Quotas{}, err
+	}
+	return cfg.Effective().Quotas, nil
+}
+
+// usageBuffer holds the calls of actors without a quota until the next FlushUsage.
+type usageBuffer struct {
+	mu      sync.Mutex
+	pending map[usageKey]domain.ActorUsage
+}
+
+type usageKey struct {
+	projectID, actorID, day string
+}
+
+func (b *usageBuffer) add(delta domain.ActorUsage) {
+	if delta.Requests == 0 && delta.Mutations == 0 && delta.Rejected == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := usageKey{delta.ProjectID, delta.ActorID, delta.Day}
+	u := b.pending[k]
+	u.ProjectID, u.ActorID, u.Day = delta.ProjectID, delta.ActorID, delta.Day
+	u.Requests += delta.Requests
+	u.Mutations += delta.Mutations
+	u.Rejected += delta.Rejected
+	b.pending[k] = u
+}
+
+// take removes and returns the buffered calls of one actor on one day.
+func (b *usageBuffer) take(projectID, actorID, day string) domain.ActorUsage {
+	if b == nil {
+		return domain.ActorUsage{ProjectID: projectID, ActorID: actorID, Day: day}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := usageKey{projectID, actorID, day}
+	u, ok := b.pending[k]
+	if !ok {
+		return domain.ActorUsage{ProjectID: projectID, ActorID: actorID, Day: day}
+	}
+	delete(b.pending, k)
+	return u
+}
+
+// drain removes and returns every buffered count.
+func (b *usageBuffer) drain() []domain.ActorUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var res []domain.ActorUsage
+	for _, u := range b.pending {
+		res = append(res, u)
+	}
+	clear(b.pending)
+	return res
+}
+
+// WithUsageBuffer returns a copy of e that keeps the calls of actors without a quota in
+// memory until FlushUsage writes them, instead of writing every call. Calls of actors with
+// a quota are still written at once, so the quota holds.
+func (e Engine) WithUsageBuffer() Engine {
+	e.usage = &usageBuffer{pending: map[usageKey]domain.ActorUsage{}}
+	return e
+}
+
+// FlushUsage writes the buffered usage counts in one transaction. Counts that fail to be
+// written are kept for the next flush.
+func (e Engine) FlushUsage(ctx context.Context) error {
+	if e.usage == nil {
+		return nil
+	}
+	pending := e.usage.drain()
+	if len(pending) == 0 {
+		return nil
+	}
+	err := e.flushUsage(ctx, pending)
+	if err != nil {
+		for _, u := range pending {
+			e.usage.add(u)
+		}
+	}
+	return err
+}
+
+func (e Engine) flushUsage(ctx context.Context, pending []domain.ActorUsage) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range pending {
+		if _, err := e.Repo.AddActorUsageTx(ctx, tx, u); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RecordUsage counts one API call by actorID in the project. Calls that are not reads also
+// count as mutations. When the actor's roles cap its daily calls and the cap is reached the
+// call is counted as rejected instead and QuotaExceededError is returned; the first
+// rejection of the day appends a quota.exceeded event. With a usage buffer, calls of
+// actors without a quota are only counted in memory.
+func (e Engine) RecordUsage(ctx context.Context, projectID, actorID string, mutation bool) error {
+	quotas, err := e.quotasFor(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	now := e.now().UTC()
+	day := now.Format(time.DateOnly)
+	delta := domain.ActorUsage{ProjectID: projectID, ActorID: actorID, Day: day, Requests: 1}
+	if mutation {
+		delta.Mutations = 1
+	}
+	var limit ActorQuota
+	if len(quotas.Roles) > 0 {
+		if limit, err = e.actorQuota(ctx, quotas, projectID, actorID, now); err != nil {
+			return err
+		}
+	}
+	if limit.Requests == 0 && limit.Mutations == 0 && e.usage != nil {
+		e.usage.add(delta)
+		return nil
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Calls buffered before the actor got a quota count towards it.
+	buffered := e.usage.take(projectID, actorID, day)
+	committed := false
+	defer func() {
+		if !committed && e.usage != nil {
+			e.usage.add(buffered)
+		}
+	}()
+	var exceeded *QuotaExceededError
+	if limit.Requests > 0 || limit.Mutations > 0 {
+		current, err := e.Repo.ActorUsageTx(ctx, tx, projectID, actorID, day)
+		if err != nil {
+			return err
+		}
+		current.Requests += buffered.Requests
+		current.Mutations += buffered.Mutations
+		resetAt := now.Truncate(24*time.Hour).AddDate(0, 0, 1)
+		switch {
+		case limit.Requests > 0 && current.Requests >= int64(limit.Requests):
+			exceeded = &QuotaExceededError{Quota: QuotaRequests, Limit: limit.Requests, ResetAt: resetAt}
+		case mutation && limit.Mutations > 0 && current.Mutations >= int64(limit.Mutations):
+			exceeded = &QuotaExceededError{Quota: QuotaMutations, Limit: limit.Mutations, ResetAt: resetAt}
+		}
+	}
+	if exceeded != nil {
+		delta.Requests, delta.Mutations, delta.Rejected = 0, 0, 1
+	}
+	delta.Requests += buffered.Requests
+	delta.Mutations += buffered.Mutations
+	delta.Rejected += buffered.Rejected
+	total, err := e.Repo.AddActorUsageTx(ctx, tx, delta)
+	if err != nil {
+		return err
+	}
+	if exceeded != nil && total.Rejected == 1 {
+		payload := events.EventPayload{"actor_id": actorID, "quota": exceeded.Quota, "limit": exceeded.Limit, "day": day}
+		if err := e.Events.Append(ctx, tx, "quota.exceeded", projectID, "rbac", projectID, actorID, payload); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	if exceeded != nil {
+		return *exceeded
+	}
+	return nil
+}
+
+// actorQuota resolves the daily limit of the actor in a read-only transaction.
+func (e Engine) actorQuota(ctx context.Context, quotas config.Quotas, projectID, actorID string, now time.Time) (ActorQuota, error) {
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return ActorQuota{}, err
+	}
+	defer tx.Rollback()
+	return e.actorQuotaTx(ctx, tx, quotas, projectID, actorID, now)
+}
+
+// actorQuotaTx resolves the daily limit of the actor from its active role grants.
+func (e Engine) actorQuotaTx(ctx context.Context, tx *sql.Tx, quotas config.Quotas, projectID, actorID string, now time.Time) (ActorQuota, error) {
+	grants, err := e.Repo.ActorGrantsTx(ctx, tx, projectID, actorID)
+	if err != nil {
+		return ActorQuota{}, err
+	}
+	at := now.Format(time.RFC3339)
+	var roles []string
+	for _, g := range grants {
+		if g.Active(at) {
+			roles = append(roles, g.RoleID)
+		}
+	}
+	requests, mutations := quotas.DailyLimit(roles)
+	return ActorQuota{Requests: requests, Mutations: mutations}, nil
+}
+
+// Usage reports daily per-actor counters over [from, to), optionally for one actor, after
+// writing the buffered counts.
+func (e Engine) Usage(ctx context.Context, projectID, actorID string, from, to time.Time) (UsageReport, error) {
+	if !from.Before(to) {
+		return UsageReport{}, errors.New("invalid period: from must be before to")
+	}
+	if _, err := e.Repo.GetProject(ctx, projectID); err != nil {
+		return UsageReport{}, err
+	}
+	if err := e.FlushUsage(ctx); err != nil {
+		return UsageReport{}, err
+	}
+	quotas, err := e.quotasFor(ctx, projectID)
+	if err != nil {
+		return UsageReport{}, err
+	}
+	first, last := from.UTC().Format(time.DateOnly), to.UTC().Add(-time.Nanosecond).Format(time.DateOnly)
+	usage, err := e.Repo.ListActorUsage(ctx, projectID, first, last, actorID)
+	if err != nil {
+		return UsageReport{}, err
+	}
+	rep := UsageReport{ProjectID: projectID, From: first, To: last, Usage: usage, Quotas: map[string]ActorQuota{}}
+	if len(quotas.Roles) == 0 {
+		return rep, nil
+	}
+	var actors []string
+	for _, u := range usage {
+		if !slices.Contains(actors, u.ActorID) {
+			actors = append(actors, u.ActorID)
+		}
+	}
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return UsageReport{}, err
+	}
+	defer tx.Rollback()
+	now := e.now().UTC()
+	for _, actor := range actors {
+		limit, err := e.actorQuotaTx(ctx, tx, quotas, projectID, actor, now)
+		if err != nil {
+			return UsageReport{}, err
+		}
+		if limit.Requests > 0 || limit.Mutations > 0 {
+			rep.Quotas[actor] = limit
+		}
+	}
+	return rep, nil
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/internal/domain"
+	"workline/internal/events"
+	"workline/internal/repo"
+)
+
+// ViewSelf is the assignee placeholder that resolves to the actor running a view.
+const ViewSelf = "$me"
+
+// CreateView saves a named task query in the project.
+func (e Engine) CreateView(ctx context.Context, v domain.View, actorID string) (domain.View, error) {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return v, errors.New("view name required")
+	}
+	if v.Visibility == "" {
+		v.Visibility = "project"
+	}
+	if v.Visibility != "project" && v.Visibility != "private" {
+		return v, errors.New("invalid visibility: must be project or private")
+	}
+	if v.Visibility == "private" && len(v.Roles) > 0 {
+		return v, errors.New("invalid view: roles apply to project views only")
+	}
+	if _, err := e.Repo.GetProject(ctx, v.ProjectID); err != nil {
+		return v, err
+	}
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	v.OwnerID = actorID
+	v.CreatedAt = e.now().UTC().Format(time.RFC3339)
+	v.UpdatedAt = v.CreatedAt
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return v, err
+	}
+	defer tx.Rollback()
+	if err := e.requirePermission(ctx, tx, v.ProjectID, actorID, "view.manage"); err != nil {
+		return v, err
+	}
+	if err := e.Repo.InsertViewTx(ctx, tx, v); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return v, errors.New("invalid view: name already used in project")
+		}
+		return v, err
+	}
+	if err := e.Events.Append(ctx, tx, "view.created", v.ProjectID, "project", v.ProjectID, actorID, events.EventPayload{
+		"view_id":    v.ID,
+		"name":       v.Name,
+		"visibility": v.Visibility,
+	}); err != nil {
+		return v, err
+	}
+	if err := tx.Commit(); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// VisibleViews lists the project's views the actor may see.
+func (e Engine) VisibleViews(ctx context.Context, projectID, actorID string) ([]domain.View, error) {
+	views, err := e.Repo.ListViews(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := e.actorRoles(ctx, projectID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	var res []domain.View
+	for _, v := range views {
+		if viewVisible(v, actorID, roles) {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+// GetVisibleView loads a view of the project, reporting views the actor may not see as not found.
+func (e Engine) GetVisibleView(ctx context.Context, projectID, id, actorID string) (domain.View, error) {
+	v, err := e.Repo.GetView(ctx, id)
+	if err != nil {
+		return v, err
+	}
+	if v.ProjectID != projectID {
+		return v, repo.ErrNotFound
+	}
+	roles, err := e.actorRoles(ctx, projectID, actorID)
+	if err != nil {
+		return v, err
+	}
+	if !viewVisible(v, actorID, roles) {
+		return v, repo.ErrNotFound
+	}
+	return v, nil
+}
+
+// DeleteView removes a view. Only its owner, or an actor with rbac.manage, may delete it.
+func (e Engine) DeleteView(ctx context.Context, projectID, id, actorID string) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	v, err := e.Repo.GetViewTx(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if v.ProjectID != projectID {
+		return repo.ErrNotFound
+	}
+	if err := e.requirePermission(ctx, tx, projectID, actorID, "view.manage"); err != nil {
+		return err
+	}
+	if v.OwnerID != actorID {
+		if err := e.requirePermission(ctx, tx, projectID, actorID, "rbac.manage"); err != nil {
+			return err
+		}
+	}
+	if err := e.Repo.DeleteViewTx(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := e.Events.Append(ctx, tx, "view.deleted", projectID, "project", projectID, actorID, events.EventPayload{
+		"view_id": v.ID,
+		"name":    v.Name,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ViewTaskFilters turns a view into repo filters for the actor running it.
+func ViewTaskFilters(v domain.View, actorID string) repo.TaskFilters {
+	assignee := v.Filters.AssigneeID
+	if assignee == ViewSelf {
+		assignee = actorID
+	}
+	return repo.TaskFilters{
+		ProjectID:  v.ProjectID,
+		Status:     v.Filters.Status,
+		Type:       v.Filters.Type,
+		Iteration:  v.Filters.IterationID,
+		Parent:     v.Filters.ParentID,
+		AssigneeID: assignee,
+	}
+}
+
+func (e Engine) actorRoles(ctx context.Context, projectID, actorID string) ([]string, error) {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return e.Auth.ActorRoles(ctx, tx, projectID, actorID)
+}
+
+func viewVisible(v domain.View, actorID string, roles []string) bool {
+	if v.OwnerID == actorID {
+		return true
+	}
+	if v.Visibility == "private" {
+		return false
+	}
+	if len(v.Roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if slices.Contains(v.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"workline/internal/canon"
+	"workline/internal/domain"
 )
 
 type Writer struct {
@@ -15,7 +18,33 @@ type Writer struct {
 
 type EventPayload map[string]any
 
+type requestIDKey struct{}
+
+// WithRequestID tags the events appended under ctx with the ID of the API request that
+// caused them.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID set by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Append records an event and links it to the previous event of the same project through
+// prev_hash/this_hash, so any later edit or deletion breaks the chain.
 func (w Writer) Append(ctx context.Context, tx *sql.Tx, evtType, projectID, entityKind, entityID, actorID string, payload EventPayload) error {
+	return w.append(ctx, tx, evtType, projectID, "", entityKind, entityID, actorID, payload)
+}
+
+// AppendOrg records an event about org orgID itself rather than one of its projects. It
+// has no project, carries orgID in org_id, and chains with the other project-less events.
+func (w Writer) AppendOrg(ctx context.Context, tx *sql.Tx, evtType, orgID, entityID, actorID string, payload EventPayload) error {
+	return w.append(ctx, tx, evtType, "", orgID, "rbac", entityID, actorID, payload)
+}
+
+func (w Writer) append(ctx context.Context, tx *sql.Tx, evtType, projectID, orgID, entityKind, entityID, actorID string, payload EventPayload) error {
 	if w.Now == nil {
 		w.Now = time.Now
 	}
@@ -27,8 +56,22 @@ func (w Writer) Append(ctx context.Context, tx *sql.Tx, evtType, projectID, enti
 	if err != nil {
 		return fmt.Errorf("marshal event payload: %w", err)
 	}
-	_, err = tx.ExecContext(ctx, `INSERT INTO events(ts,type,project_id,entity_kind,entity_id,actor_id,payload_json) VALUES (?,?,?,?,?,?,?)`,
-		ts, evtType, nullable(projectID), entityKind, nullable(entityID), actorID, string(data))
+	var prev sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT this_hash FROM events WHERE project_id IS ? ORDER BY id DESC LIMIT 1`, nullable(projectID)).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("read event chain head: %w", err)
+	}
+	requestID := RequestID(ctx)
+	hash := canon.EventHash(prev.String, domain.Event{
+		TS: ts, Type: evtType, ProjectID: projectID, EntityKind: entityKind, EntityID: entityID, ActorID: actorID, Payload: string(data), RequestID: requestID,
+	})
+	if orgID == "" {
+		_, err = tx.ExecContext(ctx, `INSERT INTO events(ts,type,project_id,entity_kind,entity_id,actor_id,payload_json,prev_hash,this_hash,request_id) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+			ts, evtType, nullable(projectID), entityKind, nullable(entityID), actorID, string(data), nullable(prev.String), hash, nullable(requestID))
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO events(ts,type,project_id,entity_kind,entity_id,actor_id,payload_json,prev_hash,this_hash,request_id,org_id) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		ts, evtType, nullable(projectID), entityKind, nullable(entityID), actorID, string(data), nullable(prev.String), hash, nullable(requestID), orgID)
 	return err
 }
 
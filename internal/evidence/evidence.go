@@ -0,0 +1,182 @@
+// Package evidence signs and verifies exported proof bundles with an Ed25519 key, so a
+// bundle attached to a release or compliance ticket can be checked without database access.
+//
+// The signature covers the canonical JSON encoding (see package canon) of the bundle with
+// its "signature" member and any "$schema" link added by the HTTP layer removed.
+package evidence
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"workline/internal/canon"
+)
+
+// Algorithm names the signature scheme recorded in bundles.
+const Algorithm = "ed25519"
+
+// Signature is embedded in a signed bundle. PublicKey is base64 so a bundle verifies on
+// its own; KeyID lets a verifier pin the key it expects.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	Value     string `json:"value"`
+}
+
+// Signer holds the private key used to sign bundles.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner wraps an existing private key.
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key}
+}
+
+// GenerateSigner returns a signer with a fresh random key.
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(key), nil
+}
+
+// DefaultKeyPath is where the workspace signing key lives unless configured otherwise.
+func DefaultKeyPath(workspace string) string {
+	if workspace == "" {
+		workspace = "."
+	}
+	return filepath.Join(workspace, ".workline", "evidence.key")
+}
+
+// LoadOrCreate reads a PKCS#8 PEM private key from path, generating and storing one with
+// mode 0600 if the file does not exist.
+func LoadOrCreate(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		s, err := GenerateSigner()
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(s.key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		out := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path, out, 0o600); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid evidence key %s: no PEM block", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid evidence key %s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid evidence key %s: not an ed25519 key", path)
+	}
+	return NewSigner(key), nil
+}
+
+// PublicKey returns the verification key.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID is "sha256:" plus the first 16 hex digits of the public key digest.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Sign returns the signature over v, which must marshal to a JSON object. Any existing
+// "signature" member is ignored.
+func (s *Signer) Sign(v any) (Signature, error) {
+	msg, err := signingInput(v)
+	if err != nil {
+		return Signature{}, err
+	}
+	pub := s.PublicKey()
+	return Signature{
+		Algorithm: Algorithm,
+		KeyID:     KeyID(pub),
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, msg)),
+	}, nil
+}
+
+// Verify checks the embedded signature of a bundle and returns it. Callers that trust a
+// specific key should compare the returned KeyID or PublicKey.
+func Verify(bundle []byte) (Signature, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(bundle, &doc); err != nil {
+		return Signature{}, fmt.Errorf("invalid bundle: %w", err)
+	}
+	raw, ok := doc["signature"]
+	if !ok {
+		return Signature{}, errors.New("invalid bundle: missing signature")
+	}
+	var sig Signature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return Signature{}, fmt.Errorf("invalid signature: %w", err)
+	}
+	if sig.Algorithm != Algorithm {
+		return sig, fmt.Errorf("invalid signature: unsupported algorithm %q", sig.Algorithm)
+	}
+	pub, err := base64.StdEncoding.DecodeString(sig.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return sig, errors.New("invalid signature: malformed public key")
+	}
+	if KeyID(pub) != sig.KeyID {
+		return sig, errors.New("invalid signature: key id does not match public key")
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return sig, errors.New("invalid signature: malformed value")
+	}
+	msg, err := signingInput(doc)
+	if err != nil {
+		return sig, err
+	}
+	if !ed25519.Verify(pub, msg, value) {
+		return sig, errors.New("signature does not match bundle contents")
+	}
+	return sig, nil
+}
+
+func signingInput(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	delete(doc, "signature")
+	delete(doc, "$schema")
+	return canon.Marshal(doc)
+}
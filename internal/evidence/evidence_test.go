@@ -0,0 +1,47 @@
+package evidence
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence.key")
+	s, err := LoadOrCreate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadOrCreate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(s.PublicKey()) != KeyID(again.PublicKey()) {
+		t.Fatalf("reloaded key differs")
+	}
+
+	doc := map[string]any{"task": map[string]any{"id": "t1", "title": "Ship"}, "events": []any{1, 2}}
+	sig, err := s.Sign(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc["signature"] = sig
+	doc["$schema"] = "http://localhost/schemas/EvidenceBundle.json"
+	data, _ := json.MarshalIndent(doc, "", "  ")
+	got, err := Verify(data)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got.KeyID != KeyID(s.PublicKey()) {
+		t.Fatalf("unexpected key id %s", got.KeyID)
+	}
+
+	doc["events"] = []any{1, 2, 3}
+	data, _ = json.Marshal(doc)
+	if _, err := Verify(data); err == nil {
+		t.Fatalf("expected tampered bundle to fail")
+	}
+	if _, err := Verify([]byte(`{"task":{}}`)); err == nil {
+		t.Fatalf("expected unsigned bundle to fail")
+	}
+}
@@ -0,0 +1,490 @@
+// Package expr evaluates the small CEL-style boolean expressions used to filter events,
+// e.g. `type == "task.done" && task.type == "feature"`.
+//
+// Supported syntax: string ('..' or ".."), number, true/false/null literals and [..] lists;
+// dotted field access on variables; == != < <= > >=; in (list membership or substring);
+// ! && || and parentheses. Missing fields evaluate to null.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse compiles src.
+func Parse(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q", src, p.peek().text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func (e *Expr) String() string { return e.src }
+
+// Eval evaluates the expression against vars and requires a boolean result.
+func (e *Expr) Eval(vars map[string]any) (bool, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q is not boolean", e.src)
+	}
+	return b, nil
+}
+
+// Uses reports whether the expression reads the named top-level variable, so callers can
+// skip loading variables an expression never touches.
+func (e *Expr) Uses(name string) bool {
+	return uses(e.root, name)
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+var (
+	operators   = map[string]bool{"==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true, "<": true, ">": true, "!": true, "(": true, ")": true, "[": true, "]": true, ",": true, ".": true}
+	comparisons = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("invalid expression %q: unterminated string", src)
+			}
+			toks = append(toks, token{tokString, sb.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]) && lastIsOperand(toks)):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, string(rs[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '$') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, string(rs[i:j])})
+			i = j
+		default:
+			op := string(r)
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !operators[op] {
+				return nil, fmt.Errorf("invalid expression %q: unexpected %q", src, op)
+			}
+			toks = append(toks, token{tokOp, op})
+			i += len([]rune(op))
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+// lastIsOperand reports whether a '-' would start a negative number rather than follow an operand.
+func lastIsOperand(toks []token) bool {
+	if len(toks) == 0 {
+		return true
+	}
+	last := toks[len(toks)-1]
+	return last.kind == tokOp && last.text != ")" && last.text != "]"
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == tokOp && comparisons[t.text]:
+		op = t.text
+	case t.kind == tokIdent && t.text == "in":
+		op = "in"
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return compare{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{inner: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return literal{f}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		path := []string{t.text}
+		for p.accept(".") {
+			field := p.next()
+			if field.kind != tokIdent {
+				return nil, fmt.Errorf("invalid expression: expected field name after %q", strings.Join(path, "."))
+			}
+			path = append(path, field.text)
+		}
+		return ident{path: path}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, fmt.Errorf("invalid expression: missing )")
+			}
+			return inner, nil
+		case "[":
+			var items []node
+			if p.accept("]") {
+				return list{}, nil
+			}
+			for {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.accept("]") {
+					return list{items: items}, nil
+				}
+				if !p.accept(",") {
+					return nil, fmt.Errorf("invalid expression: expected , or ] in list")
+				}
+			}
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("invalid expression: unexpected end")
+	}
+	return nil, fmt.Errorf("invalid expression: unexpected %q", t.text)
+}
+
+type node interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literal struct{ value any }
+
+func (n literal) eval(map[string]any) (any, error) { return n.value, nil }
+
+type ident struct{ path []string }
+
+func (n ident) eval(vars map[string]any) (any, error) {
+	var cur any = vars
+	for _, field := range n.path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		cur = m[field]
+	}
+	return cur, nil
+}
+
+type list struct{ items []node }
+
+func (n list) eval(vars map[string]any) (any, error) {
+	out := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type not struct{ inner node }
+
+func (n not) eval(vars map[string]any) (any, error) {
+	v, err := n.inner.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("! needs a boolean, got %v", v)
+	}
+	return !b, nil
+}
+
+type logical struct {
+	op          string
+	left, right node
+}
+
+func (n logical) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	lb, ok := l.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s needs booleans, got %v", n.op, l)
+	}
+	if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+		return lb, nil
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := r.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s needs booleans, got %v", n.op, r)
+	}
+	return rb, nil
+}
+
+type compare struct {
+	op          string
+	left, right node
+}
+
+func (n compare) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, item := range c {
+				if equal(l, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			s, ok := l.(string)
+			return ok && strings.Contains(c, s), nil
+		case map[string]any:
+			s, ok := l.(string)
+			_, found := c[s]
+			return ok && found, nil
+		}
+		return false, nil
+	}
+	if lf, ok := number(l); ok {
+		if rf, ok := number(r); ok {
+			return order(n.op, compareFloats(lf, rf)), nil
+		}
+	}
+	ls, lok := l.(string)
+	rs, rok := r.(string)
+	if lok && rok {
+		return order(n.op, strings.Compare(ls, rs)), nil
+	}
+	return false, nil
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func order(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	if af, ok := number(a); ok {
+		bf, ok := number(b)
+		return ok && af == bf
+	}
+	switch a.(type) {
+	case nil, string, bool:
+		return a == b
+	}
+	return false
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func uses(n node, name string) bool {
+	switch v := n.(type) {
+	case ident:
+		return v.path[0] == name
+	case list:
+		for _, item := range v.items {
+			if uses(item, name) {
+				return true
+			}
+		}
+	case not:
+		return uses(v.inner, name)
+	case logical:
+		return uses(v.left, name) || uses(v.right, name)
+	case compare:
+		return uses(v.left, name) || uses(v.right, name)
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package expr
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"type":    "task.done",
+		"payload": map[string]any{"status": "done", "points": float64(5), "labels": []any{"ui"}},
+	}
+	cases := map[string]bool{
+		`type == "task.done"`:                        true,
+		`type != 'task.done'`:                        false,
+		`payload.points >= 3 && payload.points < 8`:  true,
+		`payload.points > -1`:                        true,
+		`"ui" in payload.labels`:                     true,
+		`"task" in type`:                             true,
+		`payload.status in ["done", "review"]`:       true,
+		`payload.missing == null`:                    true,
+		`!(payload.status == "done") || type == "x"`: false,
+	}
+	for src, want := range cases {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("parse %s: %v", src, err)
+		}
+		got, err := e.Eval(vars)
+		if err != nil {
+			t.Fatalf("eval %s: %v", src, err)
+		}
+		if got != want {
+			t.Errorf("%s = %v, want %v", src, got, want)
+		}
+	}
+	for _, src := range []string{`type ==`, `type = "x"`, `(type == "x"`, `"open`} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("expected parse error for %s", src)
+		}
+	}
+	if e, _ := Parse(`payload.status`); e != nil {
+		if _, err := e.Eval(vars); err == nil {
+			t.Error("expected non-boolean result to fail")
+		}
+	}
+}
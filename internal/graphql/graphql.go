@@ -0,0 +1,669 @@
+// Package graphql executes read-only GraphQL queries over a schema of Go resolvers.
+//
+// It covers the parts of the query language a read API needs: operations with variables,
+// aliases, arguments, fragments, inline fragments, the @include and @skip directives and
+// __typename. Object types are concrete (no interfaces or unions), inputs are scalars or
+// lists of scalars, and mutations, subscriptions and introspection are not supported;
+// Schema.SDL prints the schema for client tooling instead.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxDepth bounds the nesting of selection sets when Schema.MaxDepth is zero.
+const DefaultMaxDepth = 10
+
+var scalars = map[string]string{
+	"ID":      "",
+	"String":  "",
+	"Int":     "",
+	"Float":   "",
+	"Boolean": "",
+	"JSON":    "Any JSON value.",
+}
+
+// Resolver computes a field of source, the Go value standing for the parent object.
+// Pointers returned for object types are dereferenced before they become a source.
+type Resolver func(ctx context.Context, source any, args map[string]any) (any, error)
+
+// Arg declares a field argument. Type is a scalar type reference such as "ID!" or "[String!]".
+type Arg struct {
+	Name        string
+	Type        string
+	Description string
+	Default     any
+}
+
+// Field declares a field of an object type. Type is a type reference such as "[Task!]!".
+// A nil Resolve reads the field by name from a map[string]any source.
+type Field struct {
+	Name        string
+	Type        string
+	Description string
+	Args        []Arg
+	Resolve     Resolver
+}
+
+// Object declares an object type.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Schema is a set of object types rooted at the query type.
+type Schema struct {
+	Query    *Object
+	MaxDepth int
+	// FormatError turns an error returned by a resolver into the message and extensions
+	// of the reported error. The default reports err.Error().
+	FormatError func(err error) *Error
+
+	types  []*Object
+	byName map[string]*Object
+}
+
+// NewSchema checks that every type reference of query and types resolves.
+func NewSchema(query *Object, types ...*Object) (*Schema, error) {
+	s := &Schema{Query: query, types: append([]*Object{query}, types...), byName: map[string]*Object{}}
+	for _, t := range s.types {
+		if _, dup := s.byName[t.Name]; dup {
+			return nil, fmt.Errorf("graphql: type %s is declared twice", t.Name)
+		}
+		if _, scalar := scalars[t.Name]; scalar {
+			return nil, fmt.Errorf("graphql: type %s shadows a scalar", t.Name)
+		}
+		s.byName[t.Name] = t
+	}
+	for _, t := range s.types {
+		for _, f := range t.Fields {
+			if _, ok := s.lookup(namedType(f.Type)); !ok {
+				return nil, fmt.Errorf("graphql: field %s.%s has unknown type %s", t.Name, f.Name, f.Type)
+			}
+			for _, a := range f.Args {
+				if _, scalar := scalars[namedType(a.Type)]; !scalar {
+					return nil, fmt.Errorf("graphql: argument %s of %s.%s must be a scalar, not %s", a.Name, t.Name, f.Name, a.Type)
+				}
+				if a.Default != nil {
+					if _, err := coerceInput(a.Default, a.Type); err != nil {
+						return nil, fmt.Errorf("graphql: default of argument %s of %s.%s: %w", a.Name, t.Name, f.Name, err)
+					}
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// lookup returns the object type called name, or nil for a scalar.
+func (s *Schema) lookup(name string) (*Object, bool) {
+	if _, ok := scalars[name]; ok {
+		return nil, true
+	}
+	t, ok := s.byName[name]
+	return t, ok
+}
+
+func nonNull(t string) (string, bool) {
+	if strings.HasSuffix(t, "!") {
+		return t[:len(t)-1], true
+	}
+	return t, false
+}
+
+func listOf(t string) (string, bool) {
+	if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") {
+		return t[1 : len(t)-1], true
+	}
+	return t, false
+}
+
+func namedType(t string) string {
+	return strings.Trim(t, "[]!")
+}
+
+// Error is a GraphQL error: a syntax or validation error of the query, or a field error.
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+
+	err error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Unwrap returns the resolver error a field error was made from.
+func (e *Error) Unwrap() error { return e.err }
+
+// Entry is a member of an OrderedMap.
+type Entry struct {
+	Key   string
+	Value any
+}
+
+// OrderedMap is a JSON object that keeps the order of its members, as GraphQL results
+// follow the order of the query.
+type OrderedMap []Entry
+
+// Get returns the value of key.
+func (m OrderedMap) Get(key string) (any, bool) {
+	for _, e := range m {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (m OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Request is a query with its variables, as posted by GraphQL clients.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is nil when the query could not be executed
+// at all; otherwise failed fields are null in Data and reported in Errors.
+type Response struct {
+	Data   *OrderedMap `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+type execution struct {
+	schema    *Schema
+	fragments map[string]*fragment
+	vars      map[string]any
+	errors    []*Error
+}
+
+// Execute runs the query of req.
+func (s *Schema) Execute(ctx context.Context, req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []*Error{asError(err)}}
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return Response{Errors: []*Error{asError(err)}}
+	}
+	e := &execution{schema: s, fragments: doc.fragments}
+	v := validator{execution: e, maxDepth: s.MaxDepth}
+	if v.maxDepth <= 0 {
+		v.maxDepth = DefaultMaxDepth
+	}
+	v.validate(op)
+	if len(e.errors) == 0 {
+		e.vars = e.coerceVariables(op, req.Variables)
+	}
+	if len(e.errors) > 0 {
+		return Response{Errors: e.errors}
+	}
+	data, ok := e.executeFields(ctx, s.Query, nil, op.selections, nil)
+	res := Response{Errors: e.errors}
+	if ok {
+		res.Data = &data
+	}
+	return res
+}
+
+func asError(err error) *Error {
+	var gerr *Error
+	if errors.As(err, &gerr) {
+		return gerr
+	}
+	return &Error{Message: err.Error()}
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	var op *operation
+	switch {
+	case name != "":
+		for _, o := range doc.operations {
+			if o.name == name {
+				op = o
+			}
+		}
+		if op == nil {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+	case len(doc.operations) > 1:
+		return nil, errors.New("the document has several operations; name one in operationName")
+	default:
+		op = doc.operations[0]
+	}
+	if op.kind != "query" {
+		return nil, &Error{Message: fmt.Sprintf("%s operations are not supported; this API is read-only", op.kind), Locations: []Location{op.loc}}
+	}
+	return op, nil
+}
+
+func (e *execution) errorf(loc Location, path []any, format string, args ...any) {
+	e.errors = append(e.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}, Path: path})
+}
+
+func (e *execution) coerceVariables(op *operation, given map[string]any) map[string]any {
+	vars := map[string]any{}
+	for _, d := range op.vars {
+		v, ok := given[d.name]
+		if !ok {
+			if d.hasDefault {
+				v, ok = d.def, true
+			} else if _, required := nonNull(d.typ); required {
+				e.errorf(d.loc, nil, "variable $%s of type %s is required", d.name, d.typ)
+				continue
+			}
+		}
+		if !ok {
+			continue
+		}
+		c, err := coerceInput(v, d.typ)
+		if err != nil {
+			e.errorf(d.loc, nil, "variable $%s: %v", d.name, err)
+			continue
+		}
+		vars[d.name] = c
+	}
+	return vars
+}
+
+// value substitutes variables into a literal. A variable that was not provided is
+// reported as absent.
+func (e *execution) value(lit any) (any, bool) {
+	switch v := lit.(type) {
+	case variable:
+		val, ok := e.vars[string(v)]
+		return val, ok
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, item := range v {
+			val, _ := e.value(item)
+			out = append(out, val)
+		}
+		return out, true
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			if val, ok := e.value(item); ok {
+				out[k] = val
+			}
+		}
+		return out, true
+	}
+	return lit, true
+}
+
+func (e *execution) args(def *Field, f *field) (map[string]any, error) {
+	args := make(map[string]any, len(def.Args))
+	for _, a := range def.Args {
+		lit, ok := f.args[a.Name]
+		var v any
+		if ok {
+			v, ok = e.value(lit)
+		}
+		if !ok {
+			v = a.Default
+		}
+		c, err := coerceInput(v, a.Type)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("argument %s: %v", a.Name, err)}
+		}
+		if c != nil {
+			args[a.Name] = c
+		}
+	}
+	return args, nil
+}
+
+func (e *execution) included(dirs []directive) bool {
+	for _, d := range dirs {
+		v, _ := e.value(d.args["if"])
+		cond, _ := v.(bool)
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFields groups the fields of sels that apply to t by response key, in query order.
+func (e *execution) collectFields(t *Object, sels []selection, keys []string, groups map[string][]*field) []string {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			if !e.included(s.directives) {
+				continue
+			}
+			key := s.responseKey()
+			if _, seen := groups[key]; !seen {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], s)
+		case *fragmentSpread:
+			if f := e.fragments[s.name]; f != nil && e.included(s.directives) && f.typeCondition == t.Name {
+				keys = e.collectFields(t, f.selections, keys, groups)
+			}
+		case *inlineFragment:
+			if e.included(s.directives) && (s.typeCondition == "" || s.typeCondition == t.Name) {
+				keys = e.collectFields(t, s.selections, keys, groups)
+			}
+		}
+	}
+	return keys
+}
+
+// executeFields resolves sels on source. It returns false when a non-null field failed,
+// in which case the whole object is null.
+func (e *execution) executeFields(ctx context.Context, t *Object, source any, sels []selection, path []any) (OrderedMap, bool) {
+	groups := map[string][]*field{}
+	keys := e.collectFields(t, sels, nil, groups)
+	out := make(OrderedMap, 0, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Path: path, err: err})
+			return nil, false
+		}
+		fields := groups[key]
+		f := fields[0]
+		if f.name == "__typename" {
+			out = append(out, Entry{key, t.Name})
+			continue
+		}
+		def := t.field(f.name)
+		fieldPath := append(append(make([]any, 0, len(path)+1), path...), key)
+		var sub []selection
+		for _, g := range fields {
+			sub = append(sub, g.selections...)
+		}
+		args, err := e.args(def, f)
+		var v any
+		if err == nil {
+			v, err = resolve(ctx, def, source, args)
+		}
+		if err != nil {
+			e.fieldError(err, f.loc, fieldPath)
+			if _, required := nonNull(def.Type); required {
+				return nil, false
+			}
+			out = append(out, Entry{key, nil})
+			continue
+		}
+		val, ok := e.complete(ctx, def.Type, sub, v, f.loc, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, Entry{key, val})
+	}
+	return out, true
+}
+
+func resolve(ctx context.Context, def *Field, source any, args map[string]any) (any, error) {
+	if def.Resolve != nil {
+		return def.Resolve(ctx, source, args)
+	}
+	m, _ := source.(map[string]any)
+	return m[def.Name], nil
+}
+
+func (e *execution) fieldError(err error, loc Location, path []any) {
+	gerr := &Error{Message: err.Error()}
+	var given *Error
+	switch {
+	case errors.As(err, &given):
+		cp := *given
+		gerr = &cp
+	case e.schema.FormatError != nil:
+		if f := e.schema.FormatError(err); f != nil {
+			cp := *f
+			gerr = &cp
+		}
+	}
+	gerr.Locations, gerr.Path, gerr.err = []Location{loc}, path, err
+	e.errors = append(e.errors, gerr)
+}
+
+// complete shapes v by its declared type t. The second result is false when a non-null
+// value is missing and the parent must become null in turn.
+func (e *execution) complete(ctx context.Context, t string, sels []selection, v any, loc Location, path []any) (any, bool) {
+	if inner, required := nonNull(t); required {
+		val, ok := e.complete(ctx, inner, sels, v, loc, path)
+		if ok && val == nil {
+			e.errorf(loc, path, "cannot return null for non-null field of type %s", t)
+		}
+		return val, ok && val != nil
+	}
+	if v == nil {
+		return nil, true
+	}
+	rv := reflect.ValueOf(v)
+	if item, list := listOf(t); list {
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.errorf(loc, path, "expected a list for type %s, got %T", t, v)
+			return nil, true
+		}
+		out := make([]any, 0, rv.Len())
+		for i := range rv.Len() {
+			val, ok := e.complete(ctx, item, sels, rv.Index(i).Interface(), loc, append(append(make([]any, 0, len(path)+1), path...), i))
+			if !ok {
+				return nil, true
+			}
+			out = append(out, val)
+		}
+		return out, true
+	}
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, true
+		}
+		rv = rv.Elem()
+	}
+	obj, _ := e.schema.lookup(t)
+	if obj == nil {
+		val, err := serialize(t, rv)
+		if err != nil {
+			e.errorf(loc, path, "%v", err)
+			return nil, true
+		}
+		return val, true
+	}
+	out, ok := e.executeFields(ctx, obj, rv.Interface(), sels, path)
+	if !ok {
+		return nil, true
+	}
+	return out, true
+}
+
+func serialize(scalar string, rv reflect.Value) (any, error) {
+	if t, ok := rv.Interface().(time.Time); ok && (scalar == "String" || scalar == "JSON") {
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	switch scalar {
+	case "JSON":
+		return rv.Interface(), nil
+	case "String", "ID":
+		switch rv.Kind() {
+		case reflect.String:
+			return rv.String(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if scalar == "ID" {
+				return strconv.FormatInt(rv.Int(), 10), nil
+			}
+		}
+	case "Int":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return rv.Uint(), nil
+		}
+	case "Float":
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		}
+	case "Boolean":
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot represent %s value %v as %s", rv.Type(), rv.Interface(), scalar)
+}
+
+// coerceInput checks an argument or variable value against the input type t. Integers
+// become int, floats float64 and IDs string; a single value is accepted for a list.
+func coerceInput(v any, t string) (any, error) {
+	if inner, required := nonNull(t); required {
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", inner)
+		}
+		return coerceInput(v, inner)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	if item, list := listOf(t); list {
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v}
+		}
+		out := make([]any, 0, len(items))
+		for _, it := range items {
+			c, err := coerceInput(it, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, c)
+		}
+		return out, nil
+	}
+	switch t {
+	case "JSON":
+		return plain(v), nil
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch n := v.(type) {
+		case string:
+			return n, nil
+		case int, int64:
+			return fmt.Sprint(n), nil
+		case float64:
+			if n == math.Trunc(n) {
+				return strconv.FormatFloat(n, 'f', -1, 64), nil
+			}
+		}
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case int64:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	case "Float":
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, found %s", t, describe(v))
+}
+
+// plain turns query literals into the values encoding/json would decode them to.
+func plain(v any) any {
+	switch n := v.(type) {
+	case enumValue:
+		return string(n)
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	case []any:
+		out := make([]any, len(n))
+		for i, item := range n {
+			out[i] = plain(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(n))
+		for k, item := range n {
+			out[k] = plain(item)
+		}
+		return out
+	}
+	return v
+}
+
+func describe(v any) string {
+	switch n := v.(type) {
+	case enumValue:
+		return string(n)
+	case string:
+		return strconv.Quote(n)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,153 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	ID     string
+	Title  string
+	Author *string
+	Pages  int
+}
+
+var errSecret = errors.New("secret shelf")
+
+func testSchema(t *testing.T) *Schema {
+	t.Helper()
+	ann := "Ann"
+	books := []book{{ID: "b1", Title: "One", Author: &ann, Pages: 10}, {ID: "b2", Title: "Two", Pages: 20}}
+	prop := func(get func(book) any) Resolver {
+		return func(_ context.Context, src any, _ map[string]any) (any, error) { return get(src.(book)), nil }
+	}
+	bookType := &Object{Name: "Book", Fields: []*Field{
+		{Name: "id", Type: "ID!", Resolve: prop(func(b book) any { return b.ID })},
+		{Name: "title", Type: "String!", Resolve: prop(func(b book) any { return b.Title })},
+		{Name: "author", Type: "String", Resolve: prop(func(b book) any { return b.Author })},
+		{Name: "pages", Type: "Int!", Resolve: prop(func(b book) any { return b.Pages })},
+		{Name: "sequel", Type: "Book", Resolve: func(_ context.Context, src any, _ map[string]any) (any, error) {
+			if src.(book).ID == "b1" {
+				return &books[1], nil
+			}
+			return nil, nil
+		}},
+		{Name: "broken", Type: "String!", Resolve: prop(func(b book) any { return nil })},
+		{Name: "secret", Type: "String", Resolve: func(context.Context, any, map[string]any) (any, error) { return nil, errSecret }},
+	}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "books", Type: "[Book!]!", Args: []Arg{{Name: "first", Type: "Int", Default: 10}, {Name: "ids", Type: "[ID!]"}},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				var out []book
+				for _, b := range books {
+					if ids, ok := args["ids"].([]any); ok {
+						found := false
+						for _, id := range ids {
+							found = found || id == b.ID
+						}
+						if !found {
+							continue
+						}
+					}
+					out = append(out, b)
+				}
+				return out[:min(len(out), args["first"].(int))], nil
+			}},
+		{Name: "book", Type: "Book", Args: []Arg{{Name: "id", Type: "ID!"}},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				for _, b := range books {
+					if b.ID == args["id"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			}},
+	}}
+	s, err := NewSchema(query, bookType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FormatError = func(err error) *Error {
+		return &Error{Message: "denied: " + err.Error(), Extensions: map[string]any{"code": "forbidden"}}
+	}
+	return s
+}
+
+func run(t *testing.T, s *Schema, req Request) string {
+	t.Helper()
+	b, err := json.Marshal(s.Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExecute(t *testing.T) {
+	s := testSchema(t)
+	cases := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"aliases keep query order", Request{Query: `{ b: books(first: 1) { title id } a: book(id: "b2") { __typename pages author } }`},
+			`{"data":{"b":[{"title":"One","id":"b1"}],"a":{"__typename":"Book","pages":20,"author":null}}}`},
+		{"variables, fragments and directives", Request{
+			Query: `query Q($ids: [ID!], $more: Boolean = false) { books(ids: $ids) { ...F ... @include(if: $more) { pages } sequel { title } } }
+				fragment F on Book { id title @skip(if: true) }`,
+			Variables: map[string]any{"ids": "b1", "more": true}},
+			`{"data":{"books":[{"id":"b1","pages":10,"sequel":{"title":"Two"}}]}}`},
+		{"named operation", Request{Query: `query A { book(id: 1) { id } } query B { books { id } }`, OperationName: "A"},
+			`{"data":{"book":null}}`},
+		{"field error is formatted with a path", Request{Query: `{ book(id: "b1") { id secret } }`},
+			`{"data":{"book":{"id":"b1","secret":null}},"errors":[{"message":"denied: secret shelf","locations":[{"line":1,"column":23}],"path":["book","secret"],"extensions":{"code":"forbidden"}}]}`},
+		{"null bubbles to the nearest nullable field", Request{Query: `{ book(id: "b1") { id broken } }`},
+			`{"data":{"book":null},"errors":[{"message":"cannot return null for non-null field of type String!","locations":[{"line":1,"column":23}],"path":["book","broken"]}]}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := run(t, s, tc.req); got != tc.want {
+				t.Fatalf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteRejects(t *testing.T) {
+	s := testSchema(t)
+	s.MaxDepth = 3
+	cases := []struct {
+		query, want string
+	}{
+		{`{ books { id `, "syntax error"},
+		{`{ books { isbn } }`, "isbn"},
+		{`{ books(order: "x") { id } }`, "order"},
+		{`{ books(first: "x") { id } }`, "expected Int"},
+		{`{ book { id } }`, "requires argument"},
+		{`{ books }`, "must select subfields"},
+		{`{ books { id { x } } }`, "has no subfields"},
+		{`{ books(ids: $ids) { id } }`, "variable $ids is not declared"},
+		{`{ books { ...Missing } }`, "Missing"},
+		{`{ books { ...A } } fragment A on Book { ...A }`, "spreads itself"},
+		{`{ books { sequel { sequel { id } } } }`, "nested deeper than 3"},
+		{`mutation { books { id } }`, "operations are not supported"},
+		{`query A { books { id } } query B { books { id } }`, "name one in operationName"},
+	}
+	for _, tc := range cases {
+		got := run(t, s, Request{Query: tc.query})
+		if !strings.Contains(got, tc.want) || strings.Contains(got, `"data"`) {
+			t.Errorf("%s: got %s, want an error containing %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestSDL(t *testing.T) {
+	sdl := testSchema(t).SDL()
+	for _, want := range []string{"scalar JSON", "type Query {", "  books(first: Int = 10, ids: [ID!]): [Book!]!", "  sequel: Book"} {
+		if !strings.Contains(sdl, want) {
+			t.Fatalf("SDL lacks %q:\n%s", want, sdl)
+		}
+	}
+}
@@ -0,0 +1,675 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Location is a 1-based line and column in the query text.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func (l *lexer) errorf(loc Location, format string, args ...any) error {
+	return &Error{Message: "syntax error: " + fmt.Sprintf(format, args...), Locations: []Location{loc}}
+}
+
+func (l *lexer) advance(n int) {
+	for _, r := range l.src[l.pos : l.pos+n] {
+		if r == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+	l.pos += n
+}
+
+// next skips whitespace, commas and comments and returns the following token.
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.advance(1)
+			continue
+		case c == '#':
+			end := strings.IndexAny(l.src[l.pos:], "\r\n")
+			if end < 0 {
+				end = len(l.src) - l.pos
+			}
+			l.advance(end)
+			continue
+		}
+		break
+	}
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, loc: loc}, nil
+	}
+	rest := l.src[l.pos:]
+	c := rest[0]
+	switch {
+	case strings.HasPrefix(rest, "..."):
+		l.advance(3)
+		return token{kind: tokPunct, value: "...", loc: loc}, nil
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.advance(1)
+		return token{kind: tokPunct, value: string(c), loc: loc}, nil
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		n := 1
+		for n < len(rest) && (rest[n] == '_' || rest[n] >= 'A' && rest[n] <= 'Z' || rest[n] >= 'a' && rest[n] <= 'z' || rest[n] >= '0' && rest[n] <= '9') {
+			n++
+		}
+		l.advance(n)
+		return token{kind: tokName, value: rest[:n], loc: loc}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		n, float := 0, false
+		if rest[0] == '-' {
+			n++
+		}
+		for n < len(rest) && (rest[n] >= '0' && rest[n] <= '9' || strings.IndexByte(".eE+-", rest[n]) >= 0 && n > 0) {
+			if rest[n] == '-' && rest[n-1] != 'e' && rest[n-1] != 'E' {
+				break
+			}
+			float = float || strings.IndexByte(".eE", rest[n]) >= 0
+			n++
+		}
+		l.advance(n)
+		if float {
+			if _, err := strconv.ParseFloat(rest[:n], 64); err != nil {
+				return token{}, l.errorf(loc, "invalid number %s", rest[:n])
+			}
+			return token{kind: tokFloat, value: rest[:n], loc: loc}, nil
+		}
+		if _, err := strconv.ParseInt(rest[:n], 10, 64); err != nil {
+			return token{}, l.errorf(loc, "invalid number %s", rest[:n])
+		}
+		return token{kind: tokInt, value: rest[:n], loc: loc}, nil
+	case strings.HasPrefix(rest, `"""`):
+		end := strings.Index(rest[3:], `"""`)
+		if end < 0 {
+			return token{}, l.errorf(loc, "unterminated string")
+		}
+		l.advance(end + 6)
+		return token{kind: tokString, value: blockString(rest[3 : 3+end]), loc: loc}, nil
+	case c == '"':
+		var b strings.Builder
+		for n := 1; n < len(rest); {
+			r, size := utf8.DecodeRuneInString(rest[n:])
+			switch r {
+			case '"':
+				l.advance(n + 1)
+				return token{kind: tokString, value: b.String(), loc: loc}, nil
+			case '\n', '\r':
+				return token{}, l.errorf(loc, "unterminated string")
+			case '\\':
+				if n+1 >= len(rest) {
+					return token{}, l.errorf(loc, "unterminated string")
+				}
+				esc := rest[n+1]
+				switch esc {
+				case '"', '\\', '/':
+					b.WriteByte(esc)
+				case 'b':
+					b.WriteByte('\b')
+				case 'f':
+					b.WriteByte('\f')
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case 'u':
+					if n+6 > len(rest) {
+						return token{}, l.errorf(loc, "invalid unicode escape")
+					}
+					code, err := strconv.ParseUint(rest[n+2:n+6], 16, 32)
+					if err != nil {
+						return token{}, l.errorf(loc, "invalid unicode escape")
+					}
+					b.WriteRune(rune(code))
+					n += 4
+				default:
+					return token{}, l.errorf(loc, "invalid escape \\%c", esc)
+				}
+				n += 2
+			default:
+				b.WriteRune(r)
+				n += size
+			}
+		}
+		return token{}, l.errorf(loc, "unterminated string")
+	}
+	return token{}, l.errorf(loc, "unexpected character %q", c)
+}
+
+// blockString strips the common indentation and surrounding blank lines of a """ string.
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Values of the query text: variables and enum names are typed, lists are []any and
+// input objects map[string]any. Other literals are int64, float64, string, bool or nil.
+type (
+	variable  string
+	enumValue string
+)
+
+type directive struct {
+	name string
+	args map[string]any
+	loc  Location
+}
+
+type selection interface{ isSelection() }
+
+type field struct {
+	alias, name string
+	args        map[string]any
+	argOrder    []string
+	directives  []directive
+	selections  []selection
+	loc         Location
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	typeCondition string
+	directives    []directive
+	selections    []selection
+	loc           Location
+}
+
+func (*field) isSelection()          {}
+func (*fragmentSpread) isSelection() {}
+func (*inlineFragment) isSelection() {}
+
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type varDef struct {
+	name       string
+	typ        string
+	def        any
+	hasDefault bool
+	loc        Location
+}
+
+type operation struct {
+	kind       string
+	name       string
+	vars       []varDef
+	selections []selection
+	loc        Location
+}
+
+type fragment struct {
+	name          string
+	typeCondition string
+	selections    []selection
+	loc           Location
+}
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func parse(src string) (*document, error) {
+	p := &parser{lex: &lexer{src: src, line: 1, col: 1}}
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.is("{"):
+			op := &operation{kind: "query", loc: p.tok.loc}
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			op.selections = sel
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokName && (p.tok.value == "query" || p.tok.value == "mutation" || p.tok.value == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokName && p.tok.value == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.fragments[f.name]; dup {
+				return nil, &Error{Message: fmt.Sprintf("fragment %q is defined twice", f.name), Locations: []Location{f.loc}}
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "the document contains no operation"}
+	}
+	return doc, nil
+}
+
+func (p *parser) read() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) is(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == punct
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return p.lex.errorf(p.tok.loc, "unexpected end of query")
+	}
+	return p.lex.errorf(p.tok.loc, "unexpected %q", p.tok.value)
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.is(punct) {
+		if p.tok.kind == tokEOF {
+			return p.lex.errorf(p.tok.loc, "expected %q, found end of query", punct)
+		}
+		return p.lex.errorf(p.tok.loc, "expected %q, found %q", punct, p.tok.value)
+	}
+	return p.read()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.read()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.value, loc: p.tok.loc}
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.name = p.tok.value
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("(") {
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		for !p.is(")") {
+			v := varDef{loc: p.tok.loc}
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			v.name = name
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if v.typ, err = p.typeRef(); err != nil {
+				return nil, err
+			}
+			if p.is("=") {
+				if err := p.read(); err != nil {
+					return nil, err
+				}
+				if v.def, err = p.value(true); err != nil {
+					return nil, err
+				}
+				v.hasDefault = true
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sel
+	return op, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	f := &fragment{loc: p.tok.loc}
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.lex.errorf(f.loc, "a fragment cannot be named \"on\"")
+	}
+	f.name = name
+	if p.tok.kind != tokName || p.tok.value != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+	if f.typeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	if f.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// typeRef reads a type reference such as [ID!]! back into its canonical text.
+func (p *parser) typeRef() (string, error) {
+	var t string
+	if p.is("[") {
+		if err := p.read(); err != nil {
+			return "", err
+		}
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		t = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		t = name
+	}
+	if p.is("!") {
+		if err := p.read(); err != nil {
+			return "", err
+		}
+		t += "!"
+	}
+	return t, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.is("}") {
+		if p.tok.kind == tokEOF {
+			return nil, p.unexpected()
+		}
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, p.lex.errorf(p.tok.loc, "empty selection set")
+	}
+	return sels, p.read()
+}
+
+func (p *parser) selection() (selection, error) {
+	loc := p.tok.loc
+	if p.is("...") {
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokName && p.tok.value != "on" {
+			spread := &fragmentSpread{name: p.tok.value, loc: loc}
+			if err := p.read(); err != nil {
+				return nil, err
+			}
+			var err error
+			spread.directives, err = p.directives()
+			return spread, err
+		}
+		inline := &inlineFragment{loc: loc}
+		if p.tok.kind == tokName {
+			if err := p.read(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			inline.typeCondition = name
+		}
+		var err error
+		if inline.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		inline.selections, err = p.selectionSet()
+		return inline, err
+	}
+	f := &field{loc: loc}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f.name = name
+	if p.is(":") {
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		f.alias = name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.args, f.argOrder, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.is("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments() (map[string]any, []string, error) {
+	if !p.is("(") {
+		return nil, nil, nil
+	}
+	if err := p.read(); err != nil {
+		return nil, nil, err
+	}
+	args := map[string]any{}
+	var order []string
+	for !p.is(")") {
+		loc := p.tok.loc
+		name, err := p.name()
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, dup := args[name]; dup {
+			return nil, nil, &Error{Message: fmt.Sprintf("argument %q is given twice", name), Locations: []Location{loc}}
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, nil, err
+		}
+		if args[name], err = p.value(false); err != nil {
+			return nil, nil, err
+		}
+		order = append(order, name)
+	}
+	return args, order, p.read()
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var dirs []directive
+	for p.is("@") {
+		d := directive{loc: p.tok.loc}
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d.name = name
+		if d.args, _, err = p.arguments(); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value reads a literal; constant values (variable defaults) may not use variables.
+func (p *parser) value(constant bool) (any, error) {
+	tok := p.tok
+	switch {
+	case p.is("$") && !constant:
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variable(name), err
+	case p.is("["):
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		list := []any{}
+		for !p.is("]") {
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.read()
+	case p.is("{"):
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+		obj := map[string]any{}
+		for !p.is("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.read()
+	case tok.kind == tokInt:
+		n, _ := strconv.ParseInt(tok.value, 10, 64)
+		return n, p.read()
+	case tok.kind == tokFloat:
+		f, _ := strconv.ParseFloat(tok.value, 64)
+		return f, p.read()
+	case tok.kind == tokString:
+		return tok.value, p.read()
+	case tok.kind == tokName:
+		var v any
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.read()
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,53 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SDL prints the schema in the GraphQL schema definition language.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n}\n")
+	writeDescription(&b, "", scalars["JSON"])
+	b.WriteString("scalar JSON\n")
+	for _, t := range s.types {
+		b.WriteString("\n")
+		writeDescription(&b, "", t.Description)
+		fmt.Fprintf(&b, "type %s {\n", t.Name)
+		for _, f := range t.Fields {
+			writeDescription(&b, "  ", f.Description)
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, len(f.Args))
+				for i, a := range f.Args {
+					args[i] = a.Name + ": " + a.Type
+					if a.Default != nil {
+						def, _ := json.Marshal(a.Default)
+						args[i] += " = " + string(def)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, desc string) {
+	switch {
+	case desc == "":
+	case strings.Contains(desc, "\n"):
+		b.WriteString(indent + `"""` + "\n")
+		for _, line := range strings.Split(desc, "\n") {
+			b.WriteString(indent + line + "\n")
+		}
+		b.WriteString(indent + `"""` + "\n")
+	default:
+		quoted, _ := json.Marshal(desc)
+		b.WriteString(indent + string(quoted) + "\n")
+	}
+}
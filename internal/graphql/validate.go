@@ -0,0 +1,193 @@
+package graphql
+
+import "fmt"
+
+// validator rejects queries that name unknown fields, arguments, fragments or variables,
+// misuse selection sets, or nest deeper than maxDepth, before anything is resolved.
+type validator struct {
+	*execution
+	maxDepth int
+	declared map[string]bool
+}
+
+func (v *validator) validate(op *operation) {
+	v.declared = map[string]bool{}
+	for _, d := range op.vars {
+		if v.declared[d.name] {
+			v.errorf(d.loc, nil, "variable $%s is declared twice", d.name)
+		}
+		v.declared[d.name] = true
+		if _, scalar := scalars[namedType(d.typ)]; !scalar {
+			v.errorf(d.loc, nil, "variable $%s must have a scalar type, not %s", d.name, d.typ)
+		}
+		if d.hasDefault {
+			if _, err := coerceInput(d.def, d.typ); err != nil {
+				v.errorf(d.loc, nil, "default of variable $%s: %v", d.name, err)
+			}
+		}
+	}
+	v.selections(v.schema.Query, op.selections, 1, map[string]bool{})
+}
+
+func (v *validator) selections(t *Object, sels []selection, depth int, spreading map[string]bool) {
+	if depth > v.maxDepth {
+		v.errorf(locationOf(sels[0]), nil, "the query is nested deeper than %d levels", v.maxDepth)
+		return
+	}
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			v.directives(s.directives)
+			v.field(t, s, depth, spreading)
+		case *fragmentSpread:
+			v.directives(s.directives)
+			f := v.fragments[s.name]
+			switch {
+			case f == nil:
+				v.errorf(s.loc, nil, "unknown fragment %q", s.name)
+			case spreading[s.name]:
+				v.errorf(s.loc, nil, "fragment %q spreads itself", s.name)
+			case f.typeCondition != t.Name:
+				v.errorf(s.loc, nil, "fragment %q on %s cannot be spread on %s", s.name, f.typeCondition, t.Name)
+			default:
+				spreading[s.name] = true
+				v.selections(t, f.selections, depth, spreading)
+				delete(spreading, s.name)
+			}
+		case *inlineFragment:
+			v.directives(s.directives)
+			if s.typeCondition != "" && s.typeCondition != t.Name {
+				v.errorf(s.loc, nil, "an inline fragment on %s cannot apply to %s", s.typeCondition, t.Name)
+				continue
+			}
+			v.selections(t, s.selections, depth, spreading)
+		}
+	}
+}
+
+func (v *validator) field(t *Object, f *field, depth int, spreading map[string]bool) {
+	if f.name == "__typename" {
+		if len(f.args) > 0 || f.selections != nil {
+			v.errorf(f.loc, nil, "__typename takes no arguments or selections")
+		}
+		return
+	}
+	def := t.field(f.name)
+	if def == nil {
+		v.errorf(f.loc, nil, "cannot query field %q on type %s", f.name, t.Name)
+		return
+	}
+	for _, name := range f.argOrder {
+		var arg *Arg
+		for i := range def.Args {
+			if def.Args[i].Name == name {
+				arg = &def.Args[i]
+			}
+		}
+		if arg == nil {
+			v.errorf(f.loc, nil, "unknown argument %q on field %s.%s", name, t.Name, f.name)
+			continue
+		}
+		v.literal(f.args[name], arg.Type, fmt.Sprintf("argument %q on field %s.%s", name, t.Name, f.name), f.loc)
+	}
+	for _, a := range def.Args {
+		if _, required := nonNull(a.Type); required && a.Default == nil {
+			if _, given := f.args[a.Name]; !given {
+				v.errorf(f.loc, nil, "field %s.%s requires argument %q of type %s", t.Name, f.name, a.Name, a.Type)
+			}
+		}
+	}
+	obj, _ := v.schema.lookup(namedType(def.Type))
+	switch {
+	case obj == nil && f.selections != nil:
+		v.errorf(f.loc, nil, "field %s.%s of type %s has no subfields to select", t.Name, f.name, def.Type)
+	case obj != nil && f.selections == nil:
+		v.errorf(f.loc, nil, "field %s.%s of type %s must select subfields", t.Name, f.name, def.Type)
+	case obj != nil:
+		v.selections(obj, f.selections, depth+1, spreading)
+	}
+}
+
+// literal checks a literal without variables against its type and that the variables in
+// it are declared.
+func (v *validator) literal(lit any, t, what string, loc Location) {
+	if !v.variablesDeclared(lit, loc) {
+		return
+	}
+	if hasVariable(lit) {
+		return
+	}
+	if _, err := coerceInput(lit, t); err != nil {
+		v.errorf(loc, nil, "%s: %v", what, err)
+	}
+}
+
+func (v *validator) variablesDeclared(lit any, loc Location) bool {
+	switch n := lit.(type) {
+	case variable:
+		if !v.declared[string(n)] {
+			v.errorf(loc, nil, "variable $%s is not declared", n)
+			return false
+		}
+	case []any:
+		for _, item := range n {
+			if !v.variablesDeclared(item, loc) {
+				return false
+			}
+		}
+	case map[string]any:
+		for _, item := range n {
+			if !v.variablesDeclared(item, loc) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hasVariable(lit any) bool {
+	switch n := lit.(type) {
+	case variable:
+		return true
+	case []any:
+		for _, item := range n {
+			if hasVariable(item) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, item := range n {
+			if hasVariable(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *validator) directives(dirs []directive) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, nil, "unknown directive @%s", d.name)
+			continue
+		}
+		lit, ok := d.args["if"]
+		if !ok || len(d.args) != 1 {
+			v.errorf(d.loc, nil, "@%s takes exactly the argument if: Boolean!", d.name)
+			continue
+		}
+		v.literal(lit, "Boolean!", "@"+d.name+"(if:)", d.loc)
+	}
+}
+
+func locationOf(sel selection) Location {
+	switch s := sel.(type) {
+	case *field:
+		return s.loc
+	case *fragmentSpread:
+		return s.loc
+	case *inlineFragment:
+		return s.loc
+	}
+	return Location{}
+}
@@ -0,0 +1,129 @@
+package integrations
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Bitbucket handles commit status (build) and pull request events.
+// Payloads are signed with HMAC-SHA256 in X-Hub-Signature as "sha256=<hex>".
+type Bitbucket struct{}
+
+func (Bitbucket) Name() string { return "bitbucket" }
+
+func (Bitbucket) Verify(h http.Header, body []byte, secret string) error {
+	sig, ok := strings.CutPrefix(h.Get("X-Hub-Signature"), "sha256=")
+	if secret == "" || !ok {
+		return ErrSignature
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrSignature
+	}
+	return nil
+}
+
+type bitbucketCommitStatusEvent struct {
+	CommitStatus struct {
+		State   string `json:"state"`
+		Key     string `json:"key"`
+		Name    string `json:"name"`
+		URL     string `json:"url"`
+		Refname string `json:"refname"`
+		Commit  struct {
+			Hash    string `json:"hash"`
+			Message string `json:"message"`
+		} `json:"commit"`
+	} `json:"commit_status"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+type bitbucketPullRequestEvent struct {
+	PullRequest struct {
+		ID          int64  `json:"id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Source      struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"source"`
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	} `json:"pullrequest"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func (Bitbucket) Parse(h http.Header, body []byte) (Event, error) {
+	eventKey := h.Get("X-Event-Key")
+	switch {
+	case eventKey == "repo:commit_status_created" || eventKey == "repo:commit_status_updated":
+		var evt bitbucketCommitStatusEvent
+		if err := json.Unmarshal(body, &evt); err != nil {
+			return Event{}, fmt.Errorf("invalid bitbucket commit status payload: %w", err)
+		}
+		cs := evt.CommitStatus
+		return Event{
+			Provider: "bitbucket",
+			Key:      "build." + strings.ToLower(cs.State),
+			Texts:    []string{cs.Refname, cs.Commit.Message},
+			Details: map[string]any{
+				"repository": evt.Repository.FullName,
+				"build_key":  cs.Key,
+				"name":       cs.Name,
+				"state":      cs.State,
+				"ref":        cs.Refname,
+				"sha":        cs.Commit.Hash,
+				"url":        cs.URL,
+			},
+			Link: LinkStatus{
+				SHA:   cs.Commit.Hash,
+				Ref:   cs.Refname,
+				CI:    CIStatus(cs.State),
+				CIURL: cs.URL,
+			},
+		}, nil
+	case strings.HasPrefix(eventKey, "pullrequest:"):
+		var evt bitbucketPullRequestEvent
+		if err := json.Unmarshal(body, &evt); err != nil {
+			return Event{}, fmt.Errorf("invalid bitbucket pull request payload: %w", err)
+		}
+		pr := evt.PullRequest
+		action := strings.TrimPrefix(eventKey, "pullrequest:")
+		return Event{
+			Provider: "bitbucket",
+			Key:      "pullrequest." + action,
+			Texts:    []string{pr.Title, pr.Description, pr.Source.Branch.Name},
+			Details: map[string]any{
+				"repository":      evt.Repository.FullName,
+				"pull_request_id": pr.ID,
+				"action":          action,
+				"url":             pr.Links.HTML.Href,
+			},
+			Link: LinkStatus{
+				PullRequestURL: pr.Links.HTML.Href,
+				SourceBranch:   pr.Source.Branch.Name,
+				Review:         ReviewStatus(action),
+			},
+		}, nil
+	default:
+		return Event{}, ErrUnsupportedEvent
+	}
+}
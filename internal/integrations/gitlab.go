@@ -0,0 +1,116 @@
+package integrations
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GitLab handles pipeline and merge request hooks. GitLab sends the shared secret verbatim in X-Gitlab-Token.
+type GitLab struct{}
+
+func (GitLab) Name() string { return "gitlab" }
+
+func (GitLab) Verify(h http.Header, body []byte, secret string) error {
+	token := h.Get("X-Gitlab-Token")
+	if secret == "" || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return ErrSignature
+	}
+	return nil
+}
+
+type gitlabPipelineHook struct {
+	ObjectAttributes struct {
+		ID     int64  `json:"id"`
+		Ref    string `json:"ref"`
+		SHA    string `json:"sha"`
+		Status string `json:"status"`
+		URL    string `json:"url"`
+	} `json:"object_attributes"`
+	MergeRequest *struct {
+		IID          int64  `json:"iid"`
+		Title        string `json:"title"`
+		SourceBranch string `json:"source_branch"`
+		URL          string `json:"url"`
+	} `json:"merge_request"`
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+type gitlabMergeRequestHook struct {
+	ObjectAttributes struct {
+		IID          int64  `json:"iid"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		SourceBranch string `json:"source_branch"`
+		Action       string `json:"action"`
+		URL          string `json:"url"`
+	} `json:"object_attributes"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+func (GitLab) Parse(h http.Header, body []byte) (Event, error) {
+	switch h.Get("X-Gitlab-Event") {
+	case "Pipeline Hook":
+		var hook gitlabPipelineHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return Event{}, fmt.Errorf("invalid gitlab pipeline payload: %w", err)
+		}
+		evt := Event{
+			Provider: "gitlab",
+			Key:      "pipeline." + hook.ObjectAttributes.Status,
+			Texts:    []string{hook.ObjectAttributes.Ref, hook.Commit.Message},
+			Details: map[string]any{
+				"project":     hook.Project.PathWithNamespace,
+				"pipeline_id": hook.ObjectAttributes.ID,
+				"ref":         hook.ObjectAttributes.Ref,
+				"sha":         hook.ObjectAttributes.SHA,
+				"status":      hook.ObjectAttributes.Status,
+				"url":         hook.ObjectAttributes.URL,
+			},
+			Link: LinkStatus{
+				SHA:   hook.ObjectAttributes.SHA,
+				Ref:   hook.ObjectAttributes.Ref,
+				CI:    CIStatus(hook.ObjectAttributes.Status),
+				CIURL: hook.ObjectAttributes.URL,
+			},
+		}
+		if mr := hook.MergeRequest; mr != nil {
+			evt.Texts = append(evt.Texts, mr.Title, mr.SourceBranch)
+			evt.Details["merge_request_iid"] = mr.IID
+			evt.Link.PullRequestURL = mr.URL
+		}
+		return evt, nil
+	case "Merge Request Hook":
+		var hook gitlabMergeRequestHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return Event{}, fmt.Errorf("invalid gitlab merge request payload: %w", err)
+		}
+		attrs := hook.ObjectAttributes
+		return Event{
+			Provider: "gitlab",
+			Key:      "merge_request." + attrs.Action,
+			Texts:    []string{attrs.Title, attrs.Description, attrs.SourceBranch},
+			Details: map[string]any{
+				"project":           hook.Project.PathWithNamespace,
+				"merge_request_iid": attrs.IID,
+				"action":            attrs.Action,
+				"url":               attrs.URL,
+			},
+			Link: LinkStatus{
+				PullRequestURL: attrs.URL,
+				SourceBranch:   attrs.SourceBranch,
+				Review:         ReviewStatus(attrs.Action),
+			},
+		}, nil
+	default:
+		return Event{}, ErrUnsupportedEvent
+	}
+}
@@ -0,0 +1,140 @@
+// Package integrations translates CI and code-review webhooks into attestation candidates.
+package integrations
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTaskRefPattern matches task references such as "wl:task-123" or "wl#task-123"
+// in titles, branch names and commit messages. The first capture group is the task id.
+const DefaultTaskRefPattern = `(?i)\bwl[:#]([A-Za-z0-9][A-Za-z0-9._-]*)`
+
+var (
+	// ErrSignature is returned when a webhook fails secret verification.
+	ErrSignature = errors.New("invalid webhook signature")
+	// ErrUnsupportedEvent is returned for provider events that have no attestation mapping.
+	ErrUnsupportedEvent = errors.New("unsupported webhook event")
+)
+
+// Event is a provider webhook normalized for kind mapping.
+type Event struct {
+	Provider string
+	// Key identifies the event for kind mapping, e.g. "pipeline.success" or "pullrequest.approved".
+	Key string
+	// Texts are scanned for task references (titles, descriptions, branches, commit messages).
+	Texts []string
+	// Details are recorded as the attestation payload.
+	Details map[string]any
+	// Link is what the event reports about linked commits, branches and pull requests.
+	Link LinkStatus
+}
+
+// LinkStatus is the status an event reports for the commits, branches and pull requests
+// linked to tasks. Build events set CI for the SHA and Ref they ran on, and PullRequestURL
+// when the provider ties the build to a pull request; pull request events set Review,
+// empty when the action leaves the review state unchanged, and SourceBranch.
+type LinkStatus struct {
+	SHA            string
+	Ref            string
+	PullRequestURL string
+	SourceBranch   string
+	CI             string
+	CIURL          string
+	Review         string
+}
+
+// CIStatus normalizes a provider's pipeline or build state to pending, running, success,
+// failed or canceled. Other states, like GitLab's skipped or manual, are kept as sent.
+func CIStatus(state string) string {
+	switch state = strings.ToLower(state); state {
+	case "created", "waiting_for_resource", "preparing", "scheduled":
+		return "pending"
+	case "inprogress":
+		return "running"
+	case "successful":
+		return "success"
+	case "stopped":
+		return "canceled"
+	default:
+		return state
+	}
+}
+
+// ReviewStatus maps a merge or pull request action to open, approved, changes_requested,
+// merged or closed. Actions that do not change the review, like updates and comments,
+// map to "".
+func ReviewStatus(action string) string {
+	switch strings.ToLower(action) {
+	case "open", "reopen", "created", "unapproved", "unapproval", "changes_request_removed":
+		return "open"
+	case "approved", "approval":
+		return "approved"
+	case "changes_request_created":
+		return "changes_requested"
+	case "merge", "fulfilled":
+		return "merged"
+	case "close", "rejected", "declined":
+		return "closed"
+	default:
+		return ""
+	}
+}
+
+// Adapter verifies and parses webhooks for one provider.
+type Adapter interface {
+	Name() string
+	Verify(h http.Header, body []byte, secret string) error
+	Parse(h http.Header, body []byte) (Event, error)
+}
+
+var adapters = map[string]Adapter{
+	"gitlab":    GitLab{},
+	"bitbucket": Bitbucket{},
+}
+
+// Lookup returns the adapter registered for provider.
+func Lookup(provider string) (Adapter, bool) {
+	a, ok := adapters[provider]
+	return a, ok
+}
+
+// Providers lists registered provider names.
+func Providers() []string {
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TaskRefs extracts distinct task ids referenced in texts using pattern (DefaultTaskRefPattern when empty).
+func TaskRefs(pattern string, texts []string) ([]string, error) {
+	if pattern == "" {
+		pattern = DefaultTaskRefPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task_ref_pattern: %w", err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, errors.New("invalid task_ref_pattern: a capture group for the task id is required")
+	}
+	seen := map[string]bool{}
+	var refs []string
+	for _, text := range texts {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if m[1] == "" || seen[m[1]] {
+				continue
+			}
+			seen[m[1]] = true
+			refs = append(refs, m[1])
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,222 @@
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KindSecurityScan is attested for every ingested SARIF report, whatever it found; policies
+// gate on its payload, e.g. security.scan[payload.critical == 0].
+const KindSecurityScan = "security.scan"
+
+// Severities orders the buckets SARIF findings are counted in, most severe first.
+var Severities = []string{"critical", "high", "medium", "low", "note"}
+
+// SecurityScan summarizes the findings of a SARIF log.
+type SecurityScan struct {
+	Tools      []string
+	Findings   int
+	BySeverity map[string]int
+	// Suppressed counts results suppressed in source or by the tool; they are not findings.
+	Suppressed int
+	// Top lists the most severe findings, capped like test report failures.
+	Top []ScanFinding
+}
+
+// ScanFinding is one reported result.
+type ScanFinding struct {
+	RuleID   string `json:"rule_id,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// Details is the attestation payload: the total, one count per severity, and the top findings.
+func (s SecurityScan) Details() map[string]any {
+	top := s.Top
+	if top == nil {
+		top = []ScanFinding{}
+	}
+	details := map[string]any{
+		"format":     "sarif",
+		"tools":      s.Tools,
+		"findings":   s.Findings,
+		"suppressed": s.Suppressed,
+		"top":        top,
+	}
+	for _, sev := range Severities {
+		details[sev] = s.BySeverity[sev]
+	}
+	return details
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name  string      `json:"name"`
+			Rules []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+	ID                   string `json:"id"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifProperties struct {
+	// SecuritySeverity is the CVSS-style score GitHub code scanning and most scanners set.
+	SecuritySeverity json.RawMessage `json:"security-severity"`
+}
+
+type sarifResult struct {
+	RuleID    string `json:"ruleId"`
+	RuleIndex *int   `json:"ruleIndex"`
+	Level     string `json:"level"`
+	Message   struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Locations []struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	} `json:"locations"`
+	Suppressions  []json.RawMessage `json:"suppressions"`
+	BaselineState string            `json:"baselineState"`
+	Properties    sarifProperties   `json:"properties"`
+}
+
+// ParseSARIF reads a SARIF 2.1 log. A result's severity comes from its security-severity
+// score (result, then rule: >= 9 critical, >= 7 high, >= 4 medium, else low) and otherwise
+// from its level (error high, warning medium, note note). Suppressed results and results
+// whose baseline state is "absent" are not counted as findings.
+func ParseSARIF(body []byte) (SecurityScan, error) {
+	var log sarifLog
+	if err := json.Unmarshal(body, &log); err != nil {
+		return SecurityScan{}, fmt.Errorf("invalid sarif report: %w", err)
+	}
+	if log.Version != "" && !strings.HasPrefix(log.Version, "2.") {
+		return SecurityScan{}, fmt.Errorf("invalid sarif report: version %s is not supported, use 2.1.0", log.Version)
+	}
+	if len(log.Runs) == 0 {
+		return SecurityScan{}, fmt.Errorf("invalid sarif report: no runs")
+	}
+	scan := SecurityScan{BySeverity: map[string]int{}}
+	var all []ScanFinding
+	for _, run := range log.Runs {
+		if name := run.Tool.Driver.Name; name != "" && !slices.Contains(scan.Tools, name) {
+			scan.Tools = append(scan.Tools, name)
+		}
+		rules := map[string]sarifRule{}
+		for _, rule := range run.Tool.Driver.Rules {
+			rules[rule.ID] = rule
+		}
+		for _, res := range run.Results {
+			if len(res.Suppressions) > 0 || res.BaselineState == "absent" {
+				scan.Suppressed++
+				continue
+			}
+			rule, ok := rules[res.RuleID]
+			if !ok && res.RuleIndex != nil && *res.RuleIndex >= 0 && *res.RuleIndex < len(run.Tool.Driver.Rules) {
+				rule = run.Tool.Driver.Rules[*res.RuleIndex]
+			}
+			sev := resultSeverity(res, rule)
+			scan.Findings++
+			scan.BySeverity[sev]++
+			f := ScanFinding{RuleID: res.RuleID, Severity: sev, Message: res.Message.Text}
+			if f.RuleID == "" {
+				f.RuleID = rule.ID
+			}
+			if len(f.Message) > maxFailureMessage {
+				f.Message = f.Message[:maxFailureMessage] + "..."
+			}
+			if len(res.Locations) > 0 {
+				loc := res.Locations[0].PhysicalLocation
+				f.Location = loc.ArtifactLocation.URI
+				if loc.Region.StartLine > 0 {
+					f.Location += ":" + strconv.Itoa(loc.Region.StartLine)
+				}
+			}
+			all = append(all, f)
+		}
+	}
+	if scan.Tools == nil {
+		scan.Tools = []string{}
+	}
+	rank := map[string]int{}
+	for i, sev := range Severities {
+		rank[sev] = i
+	}
+	sort.SliceStable(all, func(i, j int) bool { return rank[all[i].Severity] < rank[all[j].Severity] })
+	if len(all) > maxReportedFailures {
+		all = all[:maxReportedFailures]
+	}
+	scan.Top = all
+	return scan, nil
+}
+
+func resultSeverity(res sarifResult, rule sarifRule) string {
+	for _, raw := range []json.RawMessage{res.Properties.SecuritySeverity, rule.Properties.SecuritySeverity} {
+		if score, ok := securitySeverity(raw); ok {
+			switch {
+			case score >= 9:
+				return "critical"
+			case score >= 7:
+				return "high"
+			case score >= 4:
+				return "medium"
+			default:
+				return "low"
+			}
+		}
+	}
+	level := res.Level
+	if level == "" {
+		level = rule.DefaultConfiguration.Level
+	}
+	switch level {
+	case "error":
+		return "high"
+	case "note", "none":
+		return "note"
+	default:
+		// SARIF's default level is warning.
+		return "medium"
+	}
+}
+
+// securitySeverity reads a security-severity score, which tools emit as a string ("8.8")
+// or, less often, as a number.
+func securitySeverity(raw json.RawMessage) (float64, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		score, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return score, err == nil
+	}
+	var score float64
+	if err := json.Unmarshal(raw, &score); err == nil {
+		return score, true
+	}
+	return 0, false
+}
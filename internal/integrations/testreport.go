@@ -0,0 +1,252 @@
+package integrations
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// KindTestsPassed is attested for a report without failures or errors.
+	KindTestsPassed = "tests.passed"
+	// KindTestsFailed is attested for a report with at least one failure or error.
+	KindTestsFailed = "tests.failed"
+
+	// maxReportedFailures caps the failures kept in a report summary so a broken suite
+	// does not produce an oversized payload.
+	maxReportedFailures = 20
+	maxFailureMessage   = 500
+)
+
+// TestReport summarizes a JUnit XML or TAP test report.
+type TestReport struct {
+	Format   string
+	Tests    int
+	Passed   int
+	Failed   int
+	Errors   int
+	Skipped  int
+	Duration float64
+	Failures []TestFailure
+}
+
+// TestFailure is one failing or erroring test case.
+type TestFailure struct {
+	Suite   string `json:"suite,omitempty"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+// Kind is the attestation kind the report maps to.
+func (r TestReport) Kind() string {
+	if r.Failed > 0 || r.Errors > 0 {
+		return KindTestsFailed
+	}
+	return KindTestsPassed
+}
+
+// Details is the summarized attestation payload: counts and the first failures.
+func (r TestReport) Details() map[string]any {
+	failures := r.Failures
+	if failures == nil {
+		failures = []TestFailure{}
+	}
+	details := map[string]any{
+		"format":   r.Format,
+		"tests":    r.Tests,
+		"passed":   r.Passed,
+		"failed":   r.Failed,
+		"errors":   r.Errors,
+		"skipped":  r.Skipped,
+		"failures": failures,
+	}
+	if r.Duration > 0 {
+		details["duration_seconds"] = r.Duration
+	}
+	if n := r.Failed + r.Errors; n > len(r.Failures) {
+		details["failures_truncated"] = n - len(r.Failures)
+	}
+	return details
+}
+
+func (r *TestReport) addFailure(f TestFailure) {
+	if len(r.Failures) >= maxReportedFailures {
+		return
+	}
+	f.Message = strings.TrimSpace(f.Message)
+	if len(f.Message) > maxFailureMessage {
+		f.Message = f.Message[:maxFailureMessage] + "..."
+	}
+	r.Failures = append(r.Failures, f)
+}
+
+// DetectTestReportFormat guesses the format of body: JUnit reports are XML documents,
+// anything else is read as TAP.
+func DetectTestReportFormat(body []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")) {
+		return "junit"
+	}
+	return "tap"
+}
+
+// ParseTestReport parses body as format ("junit" or "tap"; detected when empty).
+func ParseTestReport(format string, body []byte) (TestReport, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return TestReport{}, fmt.Errorf("invalid test report: body is empty")
+	}
+	if format == "" {
+		format = DetectTestReportFormat(body)
+	}
+	var (
+		r   TestReport
+		err error
+	)
+	switch format {
+	case "junit":
+		r, err = parseJUnit(body)
+	case "tap":
+		r, err = parseTAP(body)
+	default:
+		return TestReport{}, fmt.Errorf("invalid test report format %q: use junit or tap", format)
+	}
+	if err != nil {
+		return TestReport{}, err
+	}
+	if r.Tests == 0 {
+		return TestReport{}, fmt.Errorf("invalid test report: no test cases found")
+	}
+	r.Format = format
+	return r, nil
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Time   string       `xml:"time,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failures  []junitResult `xml:"failure"`
+	Errors    []junitResult `xml:"error"`
+	Skipped   *junitResult  `xml:"skipped"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (res junitResult) summary() string {
+	if res.Message != "" {
+		return res.Message
+	}
+	return res.Text
+}
+
+// parseJUnit reads a <testsuites> or <testsuite> document. Counts come from the test
+// cases rather than the suite attributes, which not every reporter fills in.
+func parseJUnit(body []byte) (TestReport, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return TestReport{}, fmt.Errorf("invalid junit report: %w", err)
+	}
+	var r TestReport
+	caseTime := 0.0
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			r.Tests++
+			if secs, err := strconv.ParseFloat(c.Time, 64); err == nil {
+				caseTime += secs
+			}
+			suite := c.ClassName
+			if suite == "" {
+				suite = s.Name
+			}
+			switch {
+			case len(c.Failures) > 0:
+				r.Failed++
+				r.addFailure(TestFailure{Suite: suite, Name: c.Name, Message: c.Failures[0].summary()})
+			case len(c.Errors) > 0:
+				r.Errors++
+				r.addFailure(TestFailure{Suite: suite, Name: c.Name, Message: c.Errors[0].summary()})
+			case c.Skipped != nil:
+				r.Skipped++
+			default:
+				r.Passed++
+			}
+		}
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+	if secs, err := strconv.ParseFloat(root.Time, 64); err == nil {
+		r.Duration = secs
+	} else {
+		r.Duration = caseTime
+	}
+	return r, nil
+}
+
+var (
+	tapPlan   = regexp.MustCompile(`^1\.\.(\d+)`)
+	tapResult = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-\s*)?([^#]*)(?:#\s*(.*))?$`)
+)
+
+// parseTAP reads TAP 12/13 output. SKIP and TODO directives count as skipped; a plan
+// promising more tests than were reported, or a "Bail out!", counts as a failure.
+func parseTAP(body []byte) (TestReport, error) {
+	var r TestReport
+	planned := -1
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Indented lines are subtests and YAML diagnostics; only top-level results count.
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if m := tapPlan.FindStringSubmatch(line); m != nil {
+			planned, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if reason, ok := strings.CutPrefix(line, "Bail out!"); ok {
+			r.Errors++
+			r.addFailure(TestFailure{Name: "bail out", Message: reason})
+			continue
+		}
+		m := tapResult.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		r.Tests++
+		name := strings.TrimSpace(m[3])
+		if name == "" {
+			name = "test " + m[2]
+		}
+		directive := strings.ToUpper(strings.TrimSpace(m[4]))
+		switch {
+		case strings.HasPrefix(directive, "SKIP"), strings.HasPrefix(directive, "TODO"):
+			r.Skipped++
+		case m[1] == "ok":
+			r.Passed++
+		default:
+			r.Failed++
+			r.addFailure(TestFailure{Name: name, Message: m[4]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return TestReport{}, fmt.Errorf("invalid tap report: %w", err)
+	}
+	if planned > r.Tests {
+		r.Errors++
+		r.addFailure(TestFailure{Name: "plan", Message: fmt.Sprintf("planned %d tests, %d reported", planned, r.Tests)})
+	}
+	return r, nil
+}
@@ -0,0 +1,273 @@
+// Package loadgen drives agent-like workloads against Workline and measures them. Each
+// agent repeatedly creates a task, claims it, attests the proofs its policy requires and
+// completes it, either in-process through the engine or over the HTTP API.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workline/pkg/proofline"
+	worklinesdk "workline/sdk/go"
+)
+
+// Operations timed by Run, in workflow order.
+const (
+	OpCreate   = "create"
+	OpClaim    = "claim"
+	OpAttest   = "attest"
+	OpComplete = "complete"
+)
+
+// TaskType is the type of the tasks a workload creates; its default policy requires
+// the attestations in Proofs.
+const TaskType = "bug"
+
+// Proofs are the attestation kinds recorded on every task before completing it.
+var Proofs = []string{"ci.passed", "review.approved"}
+
+// Target is the system under load. Agent is the index of the agent making the call.
+type Target interface {
+	Name() string
+	CreateTask(ctx context.Context, agent int, title string) (string, error)
+	Claim(ctx context.Context, agent int, taskID string) error
+	Attest(ctx context.Context, agent int, taskID, kind string) error
+	Complete(ctx context.Context, agent int, taskID string) error
+}
+
+// Options shape a run.
+type Options struct {
+	// Agents run concurrently; each completes Tasks workflows. Both default to 1.
+	Agents int
+	Tasks  int
+}
+
+// OpStats summarizes one operation. Latencies are in milliseconds.
+type OpStats struct {
+	Op     string  `json:"op"`
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	MeanMS float64 `json:"mean_ms"`
+	P50MS  float64 `json:"p50_ms"`
+	P95MS  float64 `json:"p95_ms"`
+	P99MS  float64 `json:"p99_ms"`
+	MaxMS  float64 `json:"max_ms"`
+}
+
+// Report is the outcome of a run. Throughput counts completed workflows and successful
+// operations per second of wall time.
+type Report struct {
+	Target         string    `json:"target"`
+	Agents         int       `json:"agents"`
+	Tasks          int       `json:"tasks"`
+	Completed      int       `json:"completed"`
+	Failed         int       `json:"failed"`
+	DurationMS     float64   `json:"duration_ms"`
+	TasksPerSecond float64   `json:"tasks_per_second"`
+	OpsPerSecond   float64   `json:"ops_per_second"`
+	Ops            []OpStats `json:"ops"`
+	FirstError     string    `json:"first_error,omitempty"`
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	completed int
+	failed    int
+	firstErr  error
+}
+
+func (r *recorder) time(op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	d := time.Since(start)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors[op]++
+		if r.firstErr == nil {
+			r.firstErr = fmt.Errorf("%s: %w", op, err)
+		}
+		return err
+	}
+	r.latencies[op] = append(r.latencies[op], d)
+	return nil
+}
+
+func (r *recorder) done(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.failed++
+	} else {
+		r.completed++
+	}
+}
+
+// Run executes the workload and reports throughput and latency percentiles. A failed
+// step abandons that workflow and the agent moves on to its next task; Run only returns
+// an error when ctx ends first.
+func Run(ctx context.Context, target Target, opts Options) (Report, error) {
+	opts.Agents = max(opts.Agents, 1)
+	opts.Tasks = max(opts.Tasks, 1)
+	rec := &recorder{latencies: map[string][]time.Duration{}, errors: map[string]int{}}
+	// Default task ids hash the title and creation second, so titles must not repeat across runs.
+	run := uuid.NewString()[:8]
+	start := time.Now()
+	var wg sync.WaitGroup
+	for agent := range opts.Agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range opts.Tasks {
+				if ctx.Err() != nil {
+					return
+				}
+				rec.done(workflow(ctx, target, rec, agent, fmt.Sprintf("load %s agent %d task %d", run, agent, i)))
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	rep := Report{
+		Target:     target.Name(),
+		Agents:     opts.Agents,
+		Tasks:      opts.Tasks,
+		Completed:  rec.completed,
+		Failed:     rec.failed,
+		DurationMS: ms(elapsed),
+		Ops:        []OpStats{},
+	}
+	if rec.firstErr != nil {
+		rep.FirstError = rec.firstErr.Error()
+	}
+	ops := 0
+	for _, op := range []string{OpCreate, OpClaim, OpAttest, OpComplete} {
+		st := summarize(op, rec.latencies[op])
+		st.Errors = rec.errors[op]
+		ops += st.Count
+		rep.Ops = append(rep.Ops, st)
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		rep.TasksPerSecond = float64(rep.Completed) / secs
+		rep.OpsPerSecond = float64(ops) / secs
+	}
+	if err := ctx.Err(); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+// workflow runs one task from creation to completion.
+func workflow(ctx context.Context, target Target, rec *recorder, agent int, title string) error {
+	var id string
+	if err := rec.time(OpCreate, func() (err error) {
+		id, err = target.CreateTask(ctx, agent, title)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := rec.time(OpClaim, func() error { return target.Claim(ctx, agent, id) }); err != nil {
+		return err
+	}
+	for _, kind := range Proofs {
+		if err := rec.time(OpAttest, func() error { return target.Attest(ctx, agent, id, kind) }); err != nil {
+			return err
+		}
+	}
+	return rec.time(OpComplete, func() error { return target.Complete(ctx, agent, id) })
+}
+
+func summarize(op string, latencies []time.Duration) OpStats {
+	st := OpStats{Op: op, Count: len(latencies)}
+	if len(latencies) == 0 {
+		return st
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	st.MeanMS = ms(total / time.Duration(len(sorted)))
+	st.P50MS = ms(percentile(sorted, 0.50))
+	st.P95MS = ms(percentile(sorted, 0.95))
+	st.P99MS = ms(percentile(sorted, 0.99))
+	st.MaxMS = ms(sorted[len(sorted)-1])
+	return st
+}
+
+// percentile picks the nearest-rank value of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Engine drives an embedded instance in-process. Every agent acts as the instance actor.
+type Engine struct {
+	Local *proofline.Local
+}
+
+func (t Engine) Name() string { return "engine" }
+
+func (t Engine) CreateTask(ctx context.Context, _ int, title string) (string, error) {
+	task, err := t.Local.CreateTask(ctx, proofline.TaskCreateOptions{Title: title, Type: TaskType})
+	return task.ID, err
+}
+
+func (t Engine) Claim(ctx context.Context, _ int, taskID string) error {
+	_, err := t.Local.ClaimTask(ctx, taskID, 300)
+	return err
+}
+
+func (t Engine) Attest(ctx context.Context, _ int, taskID, kind string) error {
+	_, err := t.Local.Attest(ctx, "task", taskID, kind, `{"source":"loadgen"}`)
+	return err
+}
+
+func (t Engine) Complete(ctx context.Context, _ int, taskID string) error {
+	_, err := t.Local.CompleteTask(ctx, taskID, `{"notes":"load test"}`, false)
+	return err
+}
+
+// HTTP drives a server through the Go SDK. Clients must not be empty; agents share them
+// round-robin when there are fewer clients than agents.
+type HTTP struct {
+	Clients []*worklinesdk.Client
+}
+
+func (t HTTP) Name() string { return "http" }
+
+func (t HTTP) client(agent int) *worklinesdk.Client {
+	return t.Clients[agent%len(t.Clients)]
+}
+
+func (t HTTP) CreateTask(ctx context.Context, agent int, title string) (string, error) {
+	task, err := t.client(agent).CreateTask(ctx, title, TaskType)
+	return task.ID, err
+}
+
+func (t HTTP) Claim(ctx context.Context, agent int, taskID string) error {
+	_, err := t.client(agent).ClaimTask(ctx, taskID, 300)
+	return err
+}
+
+func (t HTTP) Attest(ctx context.Context, agent int, taskID, kind string) error {
+	_, err := t.client(agent).AddAttestation(ctx, "task", taskID, kind, map[string]any{"source": "loadgen"})
+	return err
+}
+
+func (t HTTP) Complete(ctx context.Context, agent int, taskID string) error {
+	_, err := t.client(agent).CompleteTask(ctx, taskID, map[string]any{"notes": "load test"})
+	return err
+}
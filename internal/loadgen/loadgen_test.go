@@ -0,0 +1,89 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+
+	"workline/pkg/proofline"
+	worklinesdk "workline/sdk/go"
+)
+
+func openLocal(t testing.TB) *proofline.Local {
+	t.Helper()
+	l, err := proofline.Open(context.Background(), proofline.Options{Workspace: t.TempDir(), ProjectID: "loadgen", ActorID: "loadgen"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func serveLocal(t testing.TB, l *proofline.Local) HTTP {
+	t.Helper()
+	s, err := Serve(context.Background(), l)
+	if err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return HTTP{Clients: []*worklinesdk.Client{s.Client(l.ProjectID())}}
+}
+
+func TestRunCompletesWorkflows(t *testing.T) {
+	l := openLocal(t)
+	for _, target := range []Target{Engine{Local: l}, serveLocal(t, l)} {
+		rep, err := Run(context.Background(), target, Options{Agents: 3, Tasks: 4})
+		if err != nil {
+			t.Fatalf("%s: run: %v", target.Name(), err)
+		}
+		if rep.Completed != 12 || rep.Failed != 0 || rep.FirstError != "" {
+			t.Fatalf("%s: unexpected report: %+v", target.Name(), rep)
+		}
+		counts := map[string]int{}
+		for _, op := range rep.Ops {
+			counts[op.Op] = op.Count
+			if op.P50MS > op.P99MS || op.P99MS > op.MaxMS {
+				t.Fatalf("%s: inconsistent percentiles: %+v", target.Name(), op)
+			}
+		}
+		if counts[OpCreate] != 12 || counts[OpAttest] != 12*len(Proofs) || counts[OpComplete] != 12 || rep.TasksPerSecond <= 0 {
+			t.Fatalf("%s: unexpected op counts: %+v", target.Name(), rep)
+		}
+	}
+	tasks, err := l.ListTasks(context.Background(), proofline.TaskFilters{Status: "done"})
+	if err != nil || len(tasks) != 24 {
+		t.Fatalf("expected 24 done tasks, got %d (%v)", len(tasks), err)
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	l := openLocal(t)
+	rep, err := Run(context.Background(), Engine{Local: l.As("stranger")}, Options{Tasks: 2})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rep.Completed != 0 || rep.Failed != 2 || rep.Ops[0].Errors != 2 || rep.FirstError == "" {
+		t.Fatalf("expected forbidden creates to fail, got %+v", rep)
+	}
+}
+
+func benchmarkWorkflow(b *testing.B, target Target) {
+	b.ResetTimer()
+	rep, err := Run(context.Background(), target, Options{Agents: 1, Tasks: b.N})
+	b.StopTimer()
+	if err != nil || rep.Failed > 0 {
+		b.Fatalf("run: %v %s", err, rep.FirstError)
+	}
+	b.ReportMetric(rep.TasksPerSecond, "tasks/s")
+	for _, op := range rep.Ops {
+		b.ReportMetric(op.P99MS, op.Op+"-p99-ms")
+	}
+}
+
+func BenchmarkEngineWorkflow(b *testing.B) {
+	benchmarkWorkflow(b, Engine{Local: openLocal(b)})
+}
+
+func BenchmarkHTTPWorkflow(b *testing.B) {
+	l := openLocal(b)
+	benchmarkWorkflow(b, serveLocal(b, l))
+}
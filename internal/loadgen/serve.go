@@ -0,0 +1,62 @@
+package loadgen
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+	"workline/internal/server"
+	"workline/pkg/proofline"
+	worklinesdk "workline/sdk/go"
+)
+
+// LocalServer is the API served over loopback for an embedded instance, so HTTP runs
+// need no separately started server.
+type LocalServer struct {
+	URL    string
+	APIKey string
+	srv    *http.Server
+}
+
+// Serve starts the API for l on a free loopback port with an API key for l's actor.
+func Serve(ctx context.Context, l *proofline.Local) (*LocalServer, error) {
+	e := l.Engine()
+	key := "loadgen-" + uuid.NewString()
+	if err := e.Repo.InsertAPIKey(ctx, nil, domain.APIKey{
+		ID:      uuid.NewString(),
+		ActorID: l.ActorID(),
+		OrgID:   "default-org",
+		Name:    "loadgen",
+		KeyHash: repo.HashAPIKey(key),
+	}); err != nil {
+		return nil, err
+	}
+	handler, err := server.New(server.Config{Engine: e, BasePath: "/v0", Auth: server.AuthConfig{JWTSecret: uuid.NewString()}})
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &LocalServer{URL: "http://" + ln.Addr().String(), APIKey: key, srv: &http.Server{Handler: handler}}
+	go func() { _ = s.srv.Serve(ln) }()
+	return s, nil
+}
+
+// Client returns an SDK client for the served project, safe to share between agents.
+func (s *LocalServer) Client(projectID string) *worklinesdk.Client {
+	c := worklinesdk.New(s.URL, projectID)
+	c.APIKey = s.APIKey
+	c.HTTPClient = &http.Client{Timeout: c.Timeout}
+	return c
+}
+
+// Close stops the server.
+func (s *LocalServer) Close() error {
+	return s.srv.Close()
+}
@@ -0,0 +1,145 @@
+// Package manifest parses the declarative project files kept in a git repository that a
+// project is synced against: the iterations, policy presets and task tree it should hold.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the manifest document. Tasks and iterations are matched to the project by ID;
+// sections left out leave the project unchanged unless Prune is set.
+type File struct {
+	Iterations []Iteration `yaml:"iterations"`
+	Policies   Policies    `yaml:"policies"`
+	Tasks      []Task      `yaml:"tasks"`
+	// Prune cancels open tasks and removes presets and task defaults the file does not
+	// declare. Iterations and closed tasks are never removed.
+	Prune bool `yaml:"prune"`
+}
+
+type Iteration struct {
+	ID   string `yaml:"id"`
+	Goal string `yaml:"goal"`
+}
+
+// Policies declares policy presets by name and, per task type, the preset new tasks get.
+type Policies struct {
+	Presets  map[string][]string `yaml:"presets"`
+	Defaults map[string]string   `yaml:"defaults"`
+}
+
+// Task is one node of the task tree; Children are its subtasks. Status is not declared:
+// it moves with the work, not with the file.
+type Task struct {
+	ID          string   `yaml:"id"`
+	Type        string   `yaml:"type"`
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	Iteration   string   `yaml:"iteration"`
+	Policy      string   `yaml:"policy"`
+	Require     []string `yaml:"require"`
+	Assignee    string   `yaml:"assignee"`
+	DependsOn   []string `yaml:"depends_on"`
+	Children    []Task   `yaml:"children"`
+}
+
+// Parse decodes a YAML (or JSON) manifest, rejecting unknown fields.
+func Parse(data []byte) (File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return File{}, fmt.Errorf("invalid manifest yaml: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// FromFile reads and parses a manifest file.
+func FromFile(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	return Parse(data)
+}
+
+// Validate checks the document is self-consistent; references to existing project state
+// are checked when the manifest is synced.
+func (f File) Validate() error {
+	iterations := map[string]bool{}
+	for _, it := range f.Iterations {
+		if it.ID == "" {
+			return fmt.Errorf("invalid manifest: iteration id is required")
+		}
+		if iterations[it.ID] {
+			return fmt.Errorf("invalid manifest: iteration %s listed twice", it.ID)
+		}
+		iterations[it.ID] = true
+	}
+	for name, require := range f.Policies.Presets {
+		if name == "" {
+			return fmt.Errorf("invalid manifest: empty preset name")
+		}
+		for _, req := range require {
+			if req == "" {
+				return fmt.Errorf("invalid manifest: preset %s has empty attestation kind", name)
+			}
+		}
+	}
+	for taskType, preset := range f.Policies.Defaults {
+		if taskType == "" || preset == "" {
+			return fmt.Errorf("invalid manifest: task default %q needs a type and a preset", taskType)
+		}
+	}
+	ids := map[string]bool{}
+	var walk func(tasks []Task) error
+	walk = func(tasks []Task) error {
+		for _, t := range tasks {
+			if t.ID == "" {
+				return fmt.Errorf("invalid manifest: task %q needs an id", t.Title)
+			}
+			if t.Title == "" {
+				return fmt.Errorf("invalid manifest: task %s: title is required", t.ID)
+			}
+			if ids[t.ID] {
+				return fmt.Errorf("invalid manifest: task %s listed twice", t.ID)
+			}
+			ids[t.ID] = true
+			if t.Policy != "" && len(t.Require) > 0 {
+				return fmt.Errorf("invalid manifest: task %s sets both policy and require", t.ID)
+			}
+			for _, dep := range t.DependsOn {
+				if dep == t.ID {
+					return fmt.Errorf("invalid manifest: task %s depends on itself", t.ID)
+				}
+			}
+			if err := walk(t.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(f.Tasks)
+}
+
+// Walk calls fn for every task of the tree, parents before their children, with the ID of
+// the task's parent ("" at the top level).
+func Walk(tasks []Task, fn func(t Task, parentID string)) {
+	var walk func(tasks []Task, parentID string)
+	walk = func(tasks []Task, parentID string) {
+		for _, t := range tasks {
+			fn(t, parentID)
+			walk(t.Children, t.ID)
+		}
+	}
+	walk(tasks, "")
+}
@@ -1,11 +1,14 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
 	"io/fs"
 	"sort"
+
+	"workline/internal/repo"
 )
 
 //go:embed sql/*.sql
@@ -17,6 +20,15 @@ type Migration struct {
 	UpSQL   string
 }
 
+// backfills compute what a SQL migration cannot. Each runs once, against the final schema,
+// after a run that applied its version.
+var backfills = []struct {
+	Version int
+	Run     func(ctx context.Context, tx *sql.Tx) error
+}{
+	{Version: 20, Run: repo.Repo{}.BackfillContentHashesTx},
+}
+
 func loadMigrations() ([]Migration, error) {
 	files, err := fs.ReadDir(migrationsFS, "sql")
 	if err != nil {
@@ -46,13 +58,35 @@ func loadMigrations() ([]Migration, error) {
 	return migrations, nil
 }
 
-// Migrate applies embedded migrations in order.
+// Version reports the schema version of a migrated database.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var v int
+	if err := db.QueryRowContext(ctx, `SELECT version FROM schema_version LIMIT 1`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return v, nil
+}
+
+// Migrate applies embedded migrations in order, in one transaction. Foreign key enforcement
+// is off while they run so migrations can rebuild tables; violations left behind fail the
+// migration instead.
 func Migrate(db *sql.DB) error {
 	migrations, err := loadMigrations()
 	if err != nil {
 		return err
 	}
-	tx, err := db.Begin()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// SQLite ignores this pragma inside a transaction, so it is set on the connection first.
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF`); err != nil {
+		return fmt.Errorf("disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys=ON`)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -73,10 +107,13 @@ func Migrate(db *sql.DB) error {
 		return fmt.Errorf("read schema_version: %w", err)
 	}
 
+	applied := 0
+	from := currentVersion
 	for _, m := range migrations {
 		if m.Version <= currentVersion {
 			continue
 		}
+		applied++
 		if _, err := tx.Exec(m.UpSQL); err != nil {
 			return fmt.Errorf("migration %s: %w", m.Name, err)
 		}
@@ -85,5 +122,35 @@ func Migrate(db *sql.DB) error {
 		}
 		currentVersion = m.Version
 	}
+	for _, b := range backfills {
+		if from < b.Version && b.Version <= currentVersion {
+			if err := b.Run(ctx, tx); err != nil {
+				return fmt.Errorf("backfill %d: %w", b.Version, err)
+			}
+		}
+	}
+	if applied > 0 {
+		if err := checkForeignKeys(tx); err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
+
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return fmt.Errorf("foreign key check: %w", err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return fmt.Errorf("foreign key check: %w", err)
+		}
+		return fmt.Errorf("migrations left a foreign key violation: %s row %d references missing %s", table, rowid.Int64, parent)
+	}
+	return rows.Err()
+}
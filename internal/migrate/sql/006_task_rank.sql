@@ -0,0 +1,4 @@
+-- Execution order of tasks among siblings (same parent and iteration)
+ALTER TABLE tasks ADD COLUMN rank INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_rank ON tasks(project_id, parent_id, iteration_id, rank);
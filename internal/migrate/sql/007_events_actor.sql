@@ -0,0 +1,2 @@
+-- Per-actor activity lookups
+CREATE INDEX IF NOT EXISTS idx_events_actor ON events(project_id, actor_id, id);
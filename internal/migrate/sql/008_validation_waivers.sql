@@ -0,0 +1,16 @@
+-- Time-boxed waivers for a single missing attestation kind on a task
+CREATE TABLE IF NOT EXISTS validation_waivers(
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  justification TEXT NOT NULL,
+  actor_id TEXT NOT NULL,
+  expires_at TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_waivers_task ON validation_waivers(task_id, kind);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('task.waive', 'Waive task validation requirement');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT id, 'task.waive' FROM roles WHERE id IN ('owner', 'release');
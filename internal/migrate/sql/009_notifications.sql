@@ -0,0 +1,8 @@
+-- Delivery position of each notification channel in the event log
+CREATE TABLE IF NOT EXISTS notification_cursors(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  channel TEXT NOT NULL,
+  last_event_id INTEGER NOT NULL,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY(project_id, channel)
+);
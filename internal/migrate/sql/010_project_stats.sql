@@ -0,0 +1,12 @@
+-- Daily per-project metric snapshots for velocity charts
+CREATE TABLE IF NOT EXISTS project_stats_daily(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  day TEXT NOT NULL,
+  tasks_open INTEGER NOT NULL,
+  tasks_done INTEGER NOT NULL,
+  tasks_completed INTEGER NOT NULL,
+  attestations_issued INTEGER NOT NULL,
+  lead_time_seconds REAL,
+  recorded_at TEXT NOT NULL,
+  PRIMARY KEY(project_id, day)
+);
@@ -0,0 +1,2 @@
+-- Actor offered the lease by its owner, pending their acceptance
+ALTER TABLE leases ADD COLUMN pending_owner_id TEXT;
@@ -0,0 +1,7 @@
+-- Counters backing the sequential ID strategy (e.g. PL-123)
+CREATE TABLE IF NOT EXISTS id_sequences(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  entity TEXT NOT NULL,
+  last_value INTEGER NOT NULL,
+  PRIMARY KEY(project_id, entity)
+);
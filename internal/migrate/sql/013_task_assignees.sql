@@ -0,0 +1,17 @@
+-- Multiple assignees per task, each with a pairing role
+CREATE TABLE IF NOT EXISTS task_assignees(
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  actor_id TEXT NOT NULL,
+  role TEXT NOT NULL CHECK(role IN ('driver','reviewer')),
+  assigned_at TEXT NOT NULL,
+  PRIMARY KEY(task_id, actor_id, role)
+);
+CREATE INDEX IF NOT EXISTS idx_task_assignees_actor ON task_assignees(actor_id);
+
+-- Review lease held by an assigned reviewer alongside the work lease
+CREATE TABLE IF NOT EXISTS review_leases(
+  task_id TEXT PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+  owner_id TEXT NOT NULL,
+  acquired_at TEXT NOT NULL,
+  expires_at TEXT NOT NULL
+);
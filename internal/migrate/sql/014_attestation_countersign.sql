@@ -0,0 +1,20 @@
+-- Allow attestations on attestations so a prior attestation can be countersigned
+PRAGMA foreign_keys=off;
+ALTER TABLE attestations RENAME TO attestations_old;
+CREATE TABLE attestations(
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  entity_kind TEXT CHECK(entity_kind IN ('project','iteration','task','decision','attestation')) NOT NULL,
+  entity_id TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  actor_id TEXT NOT NULL,
+  ts TEXT NOT NULL,
+  payload_json TEXT,
+  org_id TEXT NOT NULL DEFAULT 'default-org'
+);
+INSERT INTO attestations(id, project_id, entity_kind, entity_id, kind, actor_id, ts, payload_json, org_id)
+SELECT id, project_id, entity_kind, entity_id, kind, actor_id, ts, payload_json, org_id FROM attestations_old;
+DROP TABLE attestations_old;
+CREATE INDEX IF NOT EXISTS idx_attestations_entity ON attestations(entity_kind, entity_id);
+CREATE INDEX IF NOT EXISTS idx_attestations_kind ON attestations(kind);
+PRAGMA foreign_keys=on;
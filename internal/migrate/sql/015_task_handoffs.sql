@@ -0,0 +1,12 @@
+-- Assignee change history with optional handoff notes for the next assignee
+CREATE TABLE IF NOT EXISTS task_handoffs(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  project_id TEXT NOT NULL,
+  from_assignee_id TEXT,
+  to_assignee_id TEXT,
+  actor_id TEXT NOT NULL,
+  note TEXT,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_task_handoffs_task ON task_handoffs(task_id, id);
@@ -0,0 +1,8 @@
+-- Decision lifecycle status and read permissions for the decisions list/detail endpoints
+ALTER TABLE decisions ADD COLUMN status TEXT NOT NULL DEFAULT 'accepted';
+CREATE INDEX IF NOT EXISTS idx_decisions_project_created ON decisions(project_id, created_at, id);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('decision.list', 'List decisions');
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('decision.read', 'Read decision');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'decision.list' FROM role_permissions WHERE permission_id = 'task.list';
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'decision.read' FROM role_permissions WHERE permission_id = 'task.read';
@@ -0,0 +1,39 @@
+-- Evidence files (logs, screenshots, coverage reports) uploaded to the blob store and cited by attestations and work outcomes
+CREATE TABLE IF NOT EXISTS artifacts(
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  media_type TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  digest TEXT NOT NULL,
+  uploaded_by TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_artifacts_project_created ON artifacts(project_id, created_at, id);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('artifact.upload', 'Upload artifact');
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('artifact.read', 'Read artifacts');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT DISTINCT role_id, 'artifact.upload' FROM role_permissions WHERE permission_id IN ('attestation.add', 'task.update');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'artifact.read' FROM role_permissions WHERE permission_id = 'task.read';
+
+-- Expand events entity_kind to include artifact
+PRAGMA foreign_keys=off;
+ALTER TABLE events RENAME TO events_old;
+CREATE TABLE events(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  ts TEXT NOT NULL,
+  type TEXT NOT NULL,
+  project_id TEXT,
+  entity_kind TEXT CHECK(entity_kind IN ('project','iteration','task','decision','lease','attestation','rbac','artifact')) NOT NULL,
+  entity_id TEXT,
+  actor_id TEXT NOT NULL,
+  payload_json TEXT NOT NULL,
+  org_id TEXT NOT NULL DEFAULT 'default-org'
+);
+INSERT INTO events(id, ts, type, project_id, entity_kind, entity_id, actor_id, payload_json, org_id)
+SELECT id, ts, type, project_id, entity_kind, entity_id, actor_id, payload_json, org_id FROM events_old;
+DROP TABLE events_old;
+CREATE INDEX IF NOT EXISTS idx_events_project ON events(project_id);
+CREATE INDEX IF NOT EXISTS idx_events_ts ON events(ts);
+CREATE INDEX IF NOT EXISTS idx_events_actor ON events(project_id, actor_id, id);
+PRAGMA foreign_keys=on;
@@ -0,0 +1,3 @@
+-- Temporary role grants: expired grants are ignored by permission checks and swept with rbac.role_expired events
+ALTER TABLE actor_roles ADD COLUMN expires_at TEXT;
+CREATE INDEX IF NOT EXISTS idx_actor_roles_expires ON actor_roles(expires_at) WHERE expires_at IS NOT NULL;
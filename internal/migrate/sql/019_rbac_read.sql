@@ -0,0 +1,3 @@
+-- Read access to project membership (actors, role grants and effective permissions)
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('rbac.read', 'List project members');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'rbac.read' FROM role_permissions WHERE permission_id = 'rbac.manage';
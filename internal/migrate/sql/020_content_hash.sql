@@ -0,0 +1,5 @@
+-- Content hashes over the canonical JSON form of tasks, decisions and attestations, for tamper-evidence checks.
+-- Existing rows are hashed by the Go backfill registered for version 20 in migrations.go.
+ALTER TABLE tasks ADD COLUMN content_hash TEXT;
+ALTER TABLE decisions ADD COLUMN content_hash TEXT;
+ALTER TABLE attestations ADD COLUMN content_hash TEXT;
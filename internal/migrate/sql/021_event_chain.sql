@@ -0,0 +1,3 @@
+-- Hash chain over each project's events; rows written before this migration stay unchained
+ALTER TABLE events ADD COLUMN prev_hash TEXT;
+ALTER TABLE events ADD COLUMN this_hash TEXT;
@@ -0,0 +1,9 @@
+-- Client certificate identities (subject CN or SAN) mapped to actors for mutual TLS
+CREATE TABLE IF NOT EXISTS client_cert_actors(
+  kind TEXT NOT NULL CHECK(kind IN ('cn','dns','email','uri')),
+  value TEXT NOT NULL,
+  actor_id TEXT NOT NULL REFERENCES actors(id) ON DELETE CASCADE,
+  org_id TEXT NOT NULL DEFAULT 'default-org',
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(kind, value)
+);
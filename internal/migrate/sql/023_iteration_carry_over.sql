@@ -0,0 +1,6 @@
+-- Running totals of tasks carried out of and into each iteration
+ALTER TABLE iterations ADD COLUMN carried_out INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE iterations ADD COLUMN carried_in INTEGER NOT NULL DEFAULT 0;
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('iteration.carry_over', 'Carry unfinished tasks over to another iteration');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'iteration.carry_over' FROM role_permissions WHERE permission_id = 'iteration.create';
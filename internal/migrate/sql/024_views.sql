@@ -0,0 +1,20 @@
+-- Saved task views: named filter combinations shared within a project
+CREATE TABLE IF NOT EXISTS views(
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  description TEXT,
+  filters_json TEXT NOT NULL,
+  visibility TEXT NOT NULL DEFAULT 'project' CHECK(visibility IN ('project','private')),
+  roles_json TEXT,
+  owner_id TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  UNIQUE(project_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_views_project ON views(project_id, name);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('view.read', 'List and run saved views');
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('view.manage', 'Save and delete views');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'view.read' FROM role_permissions WHERE permission_id = 'task.list';
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'view.manage' FROM role_permissions WHERE permission_id = 'task.update';
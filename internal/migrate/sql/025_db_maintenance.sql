@@ -0,0 +1,3 @@
+-- Workspace database maintenance (vacuum, integrity check) for operators
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('db.maintain', 'Vacuum and check the workspace database');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'db.maintain' FROM role_permissions WHERE permission_id = 'rbac.manage';
@@ -0,0 +1,14 @@
+-- Capability-based routing: tasks declare required capabilities, actors register what they offer
+ALTER TABLE tasks ADD COLUMN required_capabilities_json TEXT;
+
+CREATE TABLE IF NOT EXISTS actor_capabilities(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  actor_id TEXT NOT NULL,
+  capability TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(project_id, actor_id, capability)
+);
+CREATE INDEX IF NOT EXISTS idx_actor_capabilities_capability ON actor_capabilities(project_id, capability);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('capability.manage', 'Set other actors'' capabilities');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'capability.manage' FROM role_permissions WHERE permission_id = 'rbac.manage';
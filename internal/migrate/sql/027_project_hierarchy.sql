@@ -0,0 +1,3 @@
+-- Programs: a project may sit under a parent project that rolls up its children
+ALTER TABLE projects ADD COLUMN parent_project_id TEXT REFERENCES projects(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_projects_parent ON projects(parent_project_id);
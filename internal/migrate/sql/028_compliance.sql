@@ -0,0 +1,3 @@
+-- Compliance reports mapping attestation kinds to declared controls
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('compliance.read', 'Generate compliance control reports');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'compliance.read' FROM role_permissions WHERE permission_id = 'attestation.list';
@@ -0,0 +1,33 @@
+-- Custom task types: drop the built-in type CHECK and store per-type custom fields.
+-- The table is rebuilt; child foreign keys keep referencing "tasks" by name.
+CREATE TABLE tasks_new(
+  id TEXT PRIMARY KEY,
+  project_id TEXT REFERENCES projects(id) ON DELETE CASCADE,
+  iteration_id TEXT REFERENCES iterations(id) ON DELETE SET NULL,
+  parent_id TEXT REFERENCES tasks(id) ON DELETE SET NULL,
+  type TEXT NOT NULL,
+  title TEXT NOT NULL,
+  description TEXT,
+  status TEXT CHECK(status IN ('planned','in_progress','review','done','rejected','canceled')) NOT NULL,
+  assignee_id TEXT,
+  work_outcomes_json TEXT,
+  required_attestations_json TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  completed_at TEXT,
+  org_id TEXT NOT NULL DEFAULT 'default-org',
+  rank INTEGER NOT NULL DEFAULT 0,
+  content_hash TEXT,
+  required_capabilities_json TEXT,
+  custom_fields_json TEXT
+);
+INSERT INTO tasks_new(id, project_id, iteration_id, parent_id, type, title, description, status, assignee_id, work_outcomes_json, required_attestations_json, created_at, updated_at, completed_at, org_id, rank, content_hash, required_capabilities_json)
+SELECT id, project_id, iteration_id, parent_id, type, title, description, status, assignee_id, work_outcomes_json, required_attestations_json, created_at, updated_at, completed_at, org_id, rank, content_hash, required_capabilities_json FROM tasks;
+DROP TABLE tasks;
+ALTER TABLE tasks_new RENAME TO tasks;
+CREATE INDEX IF NOT EXISTS idx_tasks_project ON tasks(project_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_iteration ON tasks(iteration_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
+CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks(parent_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_rank ON tasks(project_id, parent_id, iteration_id, rank);
+CREATE INDEX IF NOT EXISTS idx_tasks_type ON tasks(project_id, type);
@@ -0,0 +1,9 @@
+-- Actors queued for a task lease, granted in enqueue order when the lease frees up
+CREATE TABLE IF NOT EXISTS lease_waiters(
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  actor_id TEXT NOT NULL,
+  lease_seconds INTEGER NOT NULL,
+  enqueued_at TEXT NOT NULL,
+  PRIMARY KEY(task_id, actor_id)
+);
+CREATE INDEX IF NOT EXISTS idx_lease_waiters_order ON lease_waiters(task_id, enqueued_at);
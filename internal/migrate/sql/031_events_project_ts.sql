@@ -0,0 +1,2 @@
+-- Time-range scans of a project's events, e.g. bucketed activity counts
+CREATE INDEX IF NOT EXISTS idx_events_project_ts ON events(project_id, ts);
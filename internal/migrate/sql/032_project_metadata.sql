@@ -0,0 +1,4 @@
+-- Descriptive project fields editable after creation
+ALTER TABLE projects ADD COLUMN display_name TEXT;
+ALTER TABLE projects ADD COLUMN tags_json TEXT;
+ALTER TABLE projects ADD COLUMN metadata_json TEXT;
@@ -0,0 +1,12 @@
+-- Per-actor API calls per project and UTC day, for usage reports and daily quotas
+CREATE TABLE IF NOT EXISTS actor_usage(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  actor_id TEXT NOT NULL,
+  day TEXT NOT NULL,
+  requests INTEGER NOT NULL DEFAULT 0,
+  mutations INTEGER NOT NULL DEFAULT 0,
+  rejected INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY(project_id, day, actor_id)
+);
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('usage.read', 'Read per-actor API usage');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'usage.read' FROM role_permissions WHERE permission_id = 'iteration.create';
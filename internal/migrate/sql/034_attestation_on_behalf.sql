@@ -0,0 +1,3 @@
+-- Attributing an attestation to another actor than the caller
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('attestation.on_behalf', 'Attribute attestations to another actor');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'attestation.on_behalf' FROM role_permissions WHERE permission_id = 'rbac.manage';
@@ -0,0 +1,5 @@
+-- Measurable key results of an iteration goal
+ALTER TABLE iterations ADD COLUMN key_results_json TEXT;
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('iteration.update', 'Update iteration key results');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'iteration.update' FROM role_permissions WHERE permission_id = 'iteration.create';
@@ -0,0 +1,3 @@
+-- Setting the status of a parent task whose status rolls up from its subtasks
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('task.status.override', 'Set the status of a parent task that rolls up from its subtasks');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'task.status.override' FROM role_permissions WHERE permission_id = 'iteration.create';
@@ -0,0 +1,3 @@
+-- Correlates events with the API request that recorded them
+ALTER TABLE events ADD COLUMN request_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_events_request_id ON events(request_id) WHERE request_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Daily per-project digests of completions, decisions, refused validations and stuck leases
+CREATE TABLE IF NOT EXISTS digests(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  day TEXT NOT NULL,
+  generated_at TEXT NOT NULL,
+  body_json TEXT NOT NULL,
+  PRIMARY KEY(project_id, day)
+);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('digest.read', 'Read daily project digests');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'digest.read' FROM role_permissions WHERE permission_id = 'project.status.read';
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('digest.generate', 'Generate daily project digests');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'digest.generate' FROM role_permissions WHERE permission_id = 'iteration.create';
@@ -0,0 +1,6 @@
+-- Plan an actor declares when claiming a task, kept with the lease and with queued claims
+ALTER TABLE leases ADD COLUMN plan_json TEXT;
+ALTER TABLE lease_waiters ADD COLUMN plan_json TEXT;
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('lease.plan.read', 'Read the plans declared with task leases');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'lease.plan.read' FROM role_permissions WHERE permission_id = 'iteration.create';
@@ -0,0 +1,18 @@
+-- Completions submitted for approval, and how they were decided
+CREATE TABLE IF NOT EXISTS task_completions(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  project_id TEXT NOT NULL,
+  status TEXT NOT NULL,
+  submitted_by TEXT NOT NULL,
+  submitted_at TEXT NOT NULL,
+  decided_by TEXT,
+  decided_at TEXT,
+  reason TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_task_completions_task ON task_completions(task_id, id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_completions_pending ON task_completions(task_id) WHERE status = 'pending';
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('task.approve', 'Approve or reject task completions');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'task.approve' FROM role_permissions WHERE permission_id = 'iteration.create';
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT id, 'task.approve' FROM roles WHERE id = 'reviewer';
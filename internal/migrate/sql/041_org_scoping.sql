@@ -0,0 +1,5 @@
+-- Projects are listed per org, and org owners may create orgs
+CREATE INDEX IF NOT EXISTS idx_projects_org ON projects(org_id, id);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('org.create', 'Create organizations');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'org.create' FROM role_permissions WHERE permission_id = 'project.create';
@@ -0,0 +1,19 @@
+-- Commits, branches and pull requests linked to tasks, with the CI and review status last
+-- reported for them by the VCS integrations
+CREATE TABLE IF NOT EXISTS task_links(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  project_id TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  target TEXT NOT NULL,
+  head_ref TEXT,
+  ci_status TEXT,
+  ci_url TEXT,
+  review_status TEXT,
+  provider TEXT,
+  status_updated_at TEXT,
+  created_by TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_links_target ON task_links(task_id, kind, target);
+CREATE INDEX IF NOT EXISTS idx_task_links_project ON task_links(project_id, kind);
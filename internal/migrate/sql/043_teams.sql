@@ -0,0 +1,29 @@
+-- Teams: groups of actors in a project whose role grants apply to every member
+CREATE TABLE IF NOT EXISTS teams(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  id TEXT NOT NULL,
+  description TEXT,
+  created_by TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(project_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS team_members(
+  project_id TEXT NOT NULL,
+  team_id TEXT NOT NULL,
+  actor_id TEXT NOT NULL REFERENCES actors(id) ON DELETE CASCADE,
+  added_at TEXT NOT NULL,
+  PRIMARY KEY(project_id, team_id, actor_id),
+  FOREIGN KEY(project_id, team_id) REFERENCES teams(project_id, id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_team_members_actor ON team_members(project_id, actor_id);
+
+CREATE TABLE IF NOT EXISTS team_roles(
+  project_id TEXT NOT NULL,
+  team_id TEXT NOT NULL,
+  role_id TEXT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  expires_at TEXT,
+  PRIMARY KEY(project_id, team_id, role_id),
+  FOREIGN KEY(project_id, team_id) REFERENCES teams(project_id, id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_team_roles_expires ON team_roles(expires_at) WHERE expires_at IS NOT NULL;
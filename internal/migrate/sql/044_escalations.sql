@@ -0,0 +1,33 @@
+-- Escalation rules: act on tasks left overdue or blocked in validation for too long
+CREATE TABLE IF NOT EXISTS escalation_rules(
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  id TEXT NOT NULL,
+  description TEXT,
+  condition TEXT NOT NULL CHECK(condition IN ('task.overdue','validation.blocked')),
+  after TEXT NOT NULL,
+  task_type TEXT,
+  action TEXT NOT NULL CHECK(action IN ('notify','follow_up')),
+  role_id TEXT,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  created_by TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY(project_id, id)
+);
+
+-- One firing per rule and task for each spell of the condition, keyed by when it began
+CREATE TABLE IF NOT EXISTS escalation_firings(
+  project_id TEXT NOT NULL,
+  rule_id TEXT NOT NULL,
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  since TEXT NOT NULL,
+  fired_at TEXT NOT NULL,
+  follow_up_task_id TEXT,
+  PRIMARY KEY(project_id, rule_id, task_id),
+  FOREIGN KEY(project_id, rule_id) REFERENCES escalation_rules(project_id, id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('escalation.read', 'List escalation rules');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'escalation.read' FROM role_permissions WHERE permission_id = 'project.status.read';
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('escalation.manage', 'Create, change and delete escalation rules');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'escalation.manage' FROM role_permissions WHERE permission_id = 'iteration.create';
@@ -0,0 +1,3 @@
+-- Deferred tasks stay out of the ready queue until defer_until passes
+ALTER TABLE tasks ADD COLUMN defer_until TEXT;
+CREATE INDEX IF NOT EXISTS idx_tasks_defer_until ON tasks(defer_until) WHERE defer_until IS NOT NULL;
@@ -0,0 +1,20 @@
+-- In-app notifications addressed to one actor of a project; dedupe_key keeps one per cause
+CREATE TABLE IF NOT EXISTS notifications(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  actor_id TEXT NOT NULL,
+  kind TEXT NOT NULL CHECK(kind IN ('mention','lease_expiring','attestation_requested')),
+  entity_kind TEXT NOT NULL,
+  entity_id TEXT NOT NULL,
+  event_id INTEGER,
+  message TEXT NOT NULL,
+  dedupe_key TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  read_at TEXT,
+  UNIQUE(project_id, actor_id, dedupe_key)
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_inbox ON notifications(project_id, actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(project_id, actor_id) WHERE read_at IS NULL;
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('notification.read', 'Read and mark your own notifications');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'notification.read' FROM role_permissions WHERE permission_id = 'task.read';
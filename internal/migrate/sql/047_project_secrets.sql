@@ -0,0 +1,22 @@
+-- Versioned project secrets: webhook secrets (value kept to verify signatures), API tokens
+-- (value is the SHA-256 of the token) and evidence signing keys (value is the base64
+-- PKCS#8 private key). Rotation gives the versions it supersedes an expires_at.
+CREATE TABLE IF NOT EXISTS project_secrets(
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK(kind IN ('webhook','api_token','signing')),
+  name TEXT NOT NULL,
+  version INTEGER NOT NULL,
+  value TEXT NOT NULL,
+  key_id TEXT NOT NULL,
+  public_key TEXT,
+  created_by TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  expires_at TEXT,
+  revoked_at TEXT,
+  UNIQUE(project_id, kind, name, version)
+);
+CREATE INDEX IF NOT EXISTS idx_project_secrets_token ON project_secrets(value) WHERE kind = 'api_token';
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('secret.manage', 'Rotate, revoke and list project secrets');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'secret.manage' FROM role_permissions WHERE permission_id = 'rbac.manage';
@@ -0,0 +1,3 @@
+-- Due dates let the deadline scheduler float near-deadline tasks to the front of the ready queue
+ALTER TABLE tasks ADD COLUMN due_at TEXT;
+CREATE INDEX IF NOT EXISTS idx_tasks_due_at ON tasks(project_id, due_at) WHERE due_at IS NOT NULL;
@@ -0,0 +1,9 @@
+-- A frozen running iteration refuses new tasks and loosened task policies unless an actor
+-- holding iteration.freeze.override gives a reason.
+ALTER TABLE iterations ADD COLUMN frozen_at TEXT;
+ALTER TABLE iterations ADD COLUMN frozen_by TEXT;
+
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('iteration.freeze', 'Freeze and unfreeze running iterations');
+INSERT OR IGNORE INTO permissions(id, description) VALUES ('iteration.freeze.override', 'Change the scope of a frozen iteration, with a recorded reason');
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'iteration.freeze' FROM role_permissions WHERE permission_id = 'iteration.carry_over';
+INSERT OR IGNORE INTO role_permissions(role_id, permission_id) SELECT role_id, 'iteration.freeze.override' FROM role_permissions WHERE permission_id = 'rbac.manage';
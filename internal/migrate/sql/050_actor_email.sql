@@ -0,0 +1,3 @@
+-- Email address of an actor and the events email notification channels mail it about
+ALTER TABLE actors ADD COLUMN email TEXT;
+ALTER TABLE actors ADD COLUMN email_events_json TEXT NOT NULL DEFAULT '[]';
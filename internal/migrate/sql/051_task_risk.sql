@@ -0,0 +1,5 @@
+-- Task labels, and the risk score and level policies.risk computes from type, labels and
+-- attestation kinds
+ALTER TABLE tasks ADD COLUMN labels_json TEXT;
+ALTER TABLE tasks ADD COLUMN risk_score INTEGER;
+ALTER TABLE tasks ADD COLUMN risk_level TEXT;
@@ -0,0 +1,41 @@
+-- Point-in-time copies of task lists behind ?snapshot=true cursors. Items keep the task
+-- columns as they were when the snapshot was taken, numbered in list order.
+CREATE TABLE IF NOT EXISTS task_list_snapshots(
+  id TEXT PRIMARY KEY,
+  scope TEXT NOT NULL,
+  actor_id TEXT NOT NULL,
+  taken_at TEXT NOT NULL,
+  last_read_at TEXT NOT NULL,
+  total INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_list_snapshots_actor ON task_list_snapshots(actor_id, last_read_at);
+
+CREATE TABLE IF NOT EXISTS task_list_snapshot_items(
+  snapshot_id TEXT NOT NULL REFERENCES task_list_snapshots(id) ON DELETE CASCADE,
+  pos INTEGER NOT NULL,
+  id TEXT NOT NULL,
+  project_id TEXT NOT NULL,
+  iteration_id TEXT,
+  parent_id TEXT,
+  type TEXT NOT NULL,
+  title TEXT NOT NULL,
+  description TEXT,
+  status TEXT NOT NULL,
+  assignee_id TEXT,
+  work_outcomes_json TEXT,
+  required_attestations_json TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  completed_at TEXT,
+  rank INTEGER NOT NULL DEFAULT 0,
+  content_hash TEXT,
+  required_capabilities_json TEXT,
+  custom_fields_json TEXT,
+  defer_until TEXT,
+  due_at TEXT,
+  labels_json TEXT,
+  risk_score INTEGER,
+  risk_level TEXT,
+  PRIMARY KEY(snapshot_id, pos)
+);
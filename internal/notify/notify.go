@@ -0,0 +1,360 @@
+// Package notify relays project events to chat channels and email recipients configured
+// under notifications.channels.
+//
+// The dispatcher tails the event log per project and channel, so delivery survives restarts
+// and never runs inside the transaction that produced the event.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/expr"
+	"workline/internal/repo"
+)
+
+const (
+	// TriggerIterationValidated fires on iteration.updated events moving to validated.
+	TriggerIterationValidated = "iteration.validated"
+	// TriggerWaiverGranted fires on validation.waived events.
+	TriggerWaiverGranted = "waiver.granted"
+	// TriggerAuthDeniedSpike fires when auth.denied events reach the channel's spike threshold.
+	TriggerAuthDeniedSpike = "auth.denied.spike"
+
+	defaultSpikeThreshold = 5
+	defaultSpikeWindow    = 10 * time.Minute
+	batchSize             = 200
+)
+
+type Dispatcher struct {
+	Repo   repo.Repo
+	Client *http.Client
+	Logger *log.Logger
+	Now    func() time.Time
+
+	mu        sync.Mutex
+	lastSpike map[string]time.Time
+}
+
+func (d *Dispatcher) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+func (d *Dispatcher) logger() *log.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return log.Default()
+}
+
+// Run polls for new events every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			d.logger().Printf("notify: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce delivers pending events for every project with notification channels.
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+	projects, err := d.Repo.ListProjects(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range projects {
+		cfg, err := d.Repo.GetProjectConfig(ctx, p.ID)
+		if errors.Is(err, repo.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+			continue
+		}
+		names := make([]string, 0, len(cfg.Notifications.Channels))
+		for name := range cfg.Notifications.Channels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := d.deliver(ctx, p.ID, name, cfg.Notifications.Channels[name]); err != nil {
+				errs = append(errs, fmt.Errorf("project %s channel %s: %w", p.ID, name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, projectID, name string, ch config.NotificationChannel) error {
+	cursor, err := d.Repo.GetNotificationCursor(ctx, projectID, name)
+	if errors.Is(err, repo.ErrNotFound) {
+		// New channels start at the head of the log instead of replaying history.
+		head, err := d.Repo.MaxEventID(ctx, projectID)
+		if err != nil {
+			return err
+		}
+		return d.Repo.SetNotificationCursor(ctx, projectID, name, head, d.now().UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return err
+	}
+	evts, err := d.Repo.EventsAfter(ctx, projectID, cursor, batchSize)
+	if err != nil || len(evts) == 0 {
+		return err
+	}
+	var sender Sender
+	var mailer Email
+	if ch.Type == "email" {
+		mailer = d.mailer(ch)
+	} else if sender, err = d.sender(name, ch); err != nil {
+		return err
+	}
+	var filter *expr.Expr
+	if ch.Filter != "" {
+		if filter, err = expr.Parse(ch.Filter); err != nil {
+			return err
+		}
+	}
+	subscribed := map[string]bool{}
+	for _, e := range ch.Events {
+		subscribed[e] = true
+	}
+	for _, evt := range evts {
+		text, ok, err := d.render(ctx, projectID, name, ch, subscribed, evt)
+		if err != nil {
+			return err
+		}
+		if ok && filter != nil {
+			if ok, err = d.matches(ctx, filter, evt); err != nil {
+				return err
+			}
+		}
+		if ok {
+			if ch.Type == "email" {
+				err = d.mail(ctx, mailer, projectID, evt, text)
+			} else {
+				err = sender.Send(ctx, text)
+			}
+			if err != nil {
+				// Keep the cursor on the failed event so it is retried on the next poll.
+				return err
+			}
+		}
+		if err := d.Repo.SetNotificationCursor(ctx, projectID, name, evt.ID, d.now().UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches evaluates a channel filter against evt. Evaluation errors (say, comparing a
+// missing field with &&) drop the event with a log line rather than stalling the channel.
+func (d *Dispatcher) matches(ctx context.Context, filter *expr.Expr, evt domain.Event) (bool, error) {
+	payload := map[string]any{}
+	_ = json.Unmarshal([]byte(evt.Payload), &payload)
+	vars := map[string]any{
+		"type":        evt.Type,
+		"entity_kind": evt.EntityKind,
+		"entity_id":   evt.EntityID,
+		"actor_id":    evt.ActorID,
+		"project_id":  evt.ProjectID,
+		"ts":          evt.TS,
+		"payload":     payload,
+	}
+	if evt.EntityKind == "task" && filter.Uses("task") {
+		t, err := d.Repo.GetTask(ctx, evt.EntityID)
+		switch {
+		case err == nil:
+			vars["task"] = map[string]any{
+				"id":           t.ID,
+				"type":         t.Type,
+				"status":       t.Status,
+				"title":        t.Title,
+				"assignee_id":  optional(t.AssigneeID),
+				"parent_id":    optional(t.ParentID),
+				"iteration_id": optional(t.IterationID),
+			}
+		case !errors.Is(err, repo.ErrNotFound):
+			return false, err
+		}
+	}
+	ok, err := filter.Eval(vars)
+	if err != nil {
+		d.logger().Printf("notify: filter %q on event %d: %v", filter, evt.ID, err)
+		return false, nil
+	}
+	return ok, nil
+}
+
+func optional(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func (d *Dispatcher) sender(name string, ch config.NotificationChannel) (Sender, error) {
+	switch ch.Type {
+	case "slack":
+		u := os.Getenv(ch.WebhookURLEnv)
+		if u == "" {
+			return nil, fmt.Errorf("%s is not set", ch.WebhookURLEnv)
+		}
+		return Slack{URL: u, Client: d.Client}, nil
+	case "matrix":
+		token := os.Getenv(ch.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("%s is not set", ch.TokenEnv)
+		}
+		return Matrix{Homeserver: ch.Homeserver, RoomID: ch.RoomID, Token: token, Client: d.Client}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type %s", ch.Type)
+	}
+}
+
+func (d *Dispatcher) mailer(ch config.NotificationChannel) Email {
+	m := Email{Addr: ch.SMTPAddr, From: ch.From}
+	if ch.SMTPUsernameEnv != "" {
+		m.Username = os.Getenv(ch.SMTPUsernameEnv)
+	}
+	if ch.SMTPPasswordEnv != "" {
+		m.Password = os.Getenv(ch.SMTPPasswordEnv)
+	}
+	return m
+}
+
+// mail sends text to the project members who opted in to the event; events nobody opted in
+// to are skipped.
+func (d *Dispatcher) mail(ctx context.Context, m Email, projectID string, evt domain.Event, text string) error {
+	trigger := evt.Type
+	switch evt.Type {
+	case "iteration.updated":
+		trigger = TriggerIterationValidated
+	case "validation.waived":
+		trigger = TriggerWaiverGranted
+	}
+	recipients, err := d.Repo.EmailRecipients(ctx, projectID, trigger, d.now().UTC().Format(time.RFC3339))
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+	to := make([]string, 0, len(recipients))
+	for _, r := range recipients {
+		to = append(to, r.Email)
+	}
+	body := text + "\n\nYou receive this because you opted in to " + trigger + " emails; change it with wl inbox email or PUT /v0/me/email-preferences.\n"
+	return m.Mail(ctx, to, text, body)
+}
+
+func (d *Dispatcher) render(ctx context.Context, projectID, name string, ch config.NotificationChannel, subscribed map[string]bool, evt domain.Event) (string, bool, error) {
+	payload := map[string]any{}
+	_ = json.Unmarshal([]byte(evt.Payload), &payload)
+	switch {
+	case subscribed[evt.Type]:
+		return Format(evt, payload), true, nil
+	case evt.Type == "iteration.updated" && subscribed[TriggerIterationValidated] && payload["to"] == "validated":
+		return fmt.Sprintf("[%s] iteration %s validated by %s", projectID, evt.EntityID, evt.ActorID), true, nil
+	case evt.Type == "validation.waived" && subscribed[TriggerWaiverGranted]:
+		return fmt.Sprintf("[%s] %s waived on task %s by %s until %v: %v", projectID, payload["kind"], evt.EntityID, evt.ActorID, payload["expires_at"], payload["justification"]), true, nil
+	case evt.Type == "auth.denied" && subscribed[TriggerAuthDeniedSpike]:
+		return d.spike(ctx, projectID, name, ch, evt)
+	}
+	return "", false, nil
+}
+
+func (d *Dispatcher) spike(ctx context.Context, projectID, name string, ch config.NotificationChannel, evt domain.Event) (string, bool, error) {
+	threshold := ch.SpikeThreshold
+	if threshold == 0 {
+		threshold = defaultSpikeThreshold
+	}
+	window := defaultSpikeWindow
+	if ch.SpikeWindow != "" {
+		if w, err := time.ParseDuration(ch.SpikeWindow); err == nil {
+			window = w
+		}
+	}
+	at, err := time.Parse(time.RFC3339, evt.TS)
+	if err != nil {
+		return "", false, err
+	}
+	from := at.Add(-window).UTC().Format(time.RFC3339)
+	n, err := d.Repo.CountEventsBetween(ctx, projectID, "auth.denied", from, evt.TS)
+	if err != nil || n < threshold {
+		return "", false, err
+	}
+	key := projectID + "|" + name
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastSpike == nil {
+		d.lastSpike = map[string]time.Time{}
+	}
+	// One alert per window, however long the spike lasts.
+	if last, ok := d.lastSpike[key]; ok && at.Sub(last) < window {
+		return "", false, nil
+	}
+	d.lastSpike[key] = at
+	return fmt.Sprintf("[%s] %d authorization denials in the last %s (latest: %s)", projectID, n, window, evt.ActorID), true, nil
+}
+
+// Format renders a one-line summary for an event.
+func Format(evt domain.Event, payload map[string]any) string {
+	subject := evt.EntityKind
+	if evt.EntityID != "" {
+		subject += " " + evt.EntityID
+	}
+	text := fmt.Sprintf("[%s] %s: %s by %s", evt.ProjectID, evt.Type, subject, evt.ActorID)
+	switch evt.Type {
+	case "task.updated":
+		if from, to := payload["from_status"], payload["to_status"]; from != nil && to != nil && from != to {
+			text += fmt.Sprintf(" (%v -> %v)", from, to)
+		}
+	case "iteration.updated":
+		text += fmt.Sprintf(" (%v -> %v)", payload["from"], payload["to"])
+	case "attestation.added":
+		text += fmt.Sprintf(" (%v)", payload["kind"])
+	case "lease.claimed":
+		if plan, ok := payload["plan"].(map[string]any); ok {
+			text += fmt.Sprintf(" (plan: %v)", plan["approach"])
+		}
+	case "lease.granted":
+		text += fmt.Sprintf(" (to %v)", payload["owner_id"])
+	case "digest.generated":
+		return fmt.Sprintf("[%s] digest for %v: %v tasks completed, %v decisions, %v validation failures, %v stuck leases",
+			evt.ProjectID, payload["day"], payload["completed_tasks"], payload["decisions"], payload["validation_failures"], payload["stuck_leases"])
+	case "escalation.triggered":
+		text = fmt.Sprintf("[%s] escalation %v: task %s %v since %v", evt.ProjectID, payload["rule_id"], evt.EntityID, payload["condition"], payload["since"])
+		if role, ok := payload["role_id"]; ok {
+			text += fmt.Sprintf(", notifying %v %v", role, payload["notified"])
+		}
+		if followUp, ok := payload["follow_up_task_id"]; ok {
+			text += fmt.Sprintf(", follow-up %v", followUp)
+		}
+	case "auth.denied":
+		if perm, ok := payload["permission"]; ok {
+			text += fmt.Sprintf(" (missing %v)", perm)
+		} else if kind, ok := payload["kind"]; ok {
+			text += fmt.Sprintf(" (no authority for %v)", kind)
+		}
+	}
+	return text
+}
@@ -0,0 +1,311 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"workline/internal/config"
+	"workline/internal/db"
+	"workline/internal/domain"
+	"workline/internal/engine"
+	"workline/internal/events"
+	"workline/internal/migrate"
+	"workline/internal/notify"
+)
+
+func TestDispatcherSlackDelivery(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		got = append(got, msg.Text)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	t.Setenv("WL_TEST_SLACK_URL", hook.URL)
+
+	conn, err := db.Open(db.Config{Workspace: t.TempDir()})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer conn.Close()
+	if err := migrate.Migrate(conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := config.Default("proj-1")
+	cfg.Notifications.Channels = map[string]config.NotificationChannel{
+		"team": {
+			Type:           "slack",
+			WebhookURLEnv:  "WL_TEST_SLACK_URL",
+			Events:         []string{"task.created", notify.TriggerAuthDeniedSpike},
+			SpikeThreshold: 2,
+		},
+	}
+	eng := engine.New(conn, cfg)
+	eng.Now = func() time.Time { return now }
+	eng.Events.Now = eng.Now
+	if _, err := eng.InitProject(ctx, "proj-1", "test", "tester"); err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	if err := eng.Repo.UpsertProjectConfig(ctx, "proj-1", cfg); err != nil {
+		t.Fatalf("seed config: %v", err)
+	}
+	d := &notify.Dispatcher{Repo: eng.Repo, Now: eng.Now}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected history to be skipped, got %v", got)
+	}
+
+	if _, err := eng.CreateTask(ctx, engine.TaskCreateOptions{ProjectID: "proj-1", Title: "notify me", ActorID: "tester"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := eng.Events.Append(ctx, tx, "auth.denied", "proj-1", "rbac", "proj-1", "intruder", events.EventPayload{"permission": "task.create"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected task.created and one spike alert, got %v", got)
+	}
+	if !strings.Contains(got[0], "task.created") || !strings.Contains(got[1], "authorization denials") {
+		t.Fatalf("unexpected messages %v", got)
+	}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("rerun: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected no redelivery, got %v", got)
+	}
+}
+
+func TestDispatcherFilter(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		got = append(got, msg.Text)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	t.Setenv("WL_TEST_SLACK_URL", hook.URL)
+
+	conn, err := db.Open(db.Config{Workspace: t.TempDir()})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer conn.Close()
+	if err := migrate.Migrate(conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	cfg := config.Default("proj-1")
+	cfg.Notifications.Channels = map[string]config.NotificationChannel{
+		"features": {
+			Type:          "slack",
+			WebhookURLEnv: "WL_TEST_SLACK_URL",
+			Events:        []string{"task.created"},
+			Filter:        `task.type == "feature" && !(payload.title in ["skip me"])`,
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	eng := engine.New(conn, cfg)
+	if _, err := eng.InitProject(ctx, "proj-1", "test", "tester"); err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	if err := eng.Repo.UpsertProjectConfig(ctx, "proj-1", cfg); err != nil {
+		t.Fatalf("seed config: %v", err)
+	}
+	d := &notify.Dispatcher{Repo: eng.Repo}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	for _, opts := range []engine.TaskCreateOptions{
+		{Title: "ship it", Type: "feature"},
+		{Title: "refactor", Type: "technical"},
+		{Title: "skip me", Type: "feature"},
+	} {
+		opts.ProjectID = "proj-1"
+		opts.ActorID = "tester"
+		if _, err := eng.CreateTask(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "task.created") {
+		t.Fatalf("expected only the feature task, got %v", got)
+	}
+
+	cfg.Notifications.Channels["features"] = config.NotificationChannel{Type: "slack", WebhookURLEnv: "WL_TEST_SLACK_URL", Events: []string{"task.created"}, Filter: `type == `}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected invalid filter to fail validation")
+	}
+}
+
+// smtpSink accepts SMTP sessions and records each message's recipient and data.
+type smtpSink struct {
+	mu   sync.Mutex
+	msgs map[string]string
+}
+
+func (s *smtpSink) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+			var rcpt string
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				cmd := strings.ToUpper(strings.TrimSpace(line))
+				switch {
+				case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+					fmt.Fprint(conn, "250-localhost\r\n250 8BITMIME\r\n")
+				case strings.HasPrefix(cmd, "RCPT TO:"):
+					rcpt = strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+					fmt.Fprint(conn, "250 OK\r\n")
+				case cmd == "DATA":
+					fmt.Fprint(conn, "354 go ahead\r\n")
+					var data strings.Builder
+					for {
+						l, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+						if l == ".\r\n" {
+							break
+						}
+						data.WriteString(l)
+					}
+					s.mu.Lock()
+					s.msgs[rcpt] = data.String()
+					s.mu.Unlock()
+					fmt.Fprint(conn, "250 OK\r\n")
+				case cmd == "QUIT":
+					fmt.Fprint(conn, "221 bye\r\n")
+					return
+				default:
+					fmt.Fprint(conn, "250 OK\r\n")
+				}
+			}
+		}()
+	}
+}
+
+func TestDispatcherEmailDelivery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer ln.Close()
+	sink := &smtpSink{msgs: map[string]string{}}
+	go sink.serve(ln)
+
+	conn, err := db.Open(db.Config{Workspace: t.TempDir()})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer conn.Close()
+	if err := migrate.Migrate(conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	cfg := config.Default("proj-1")
+	cfg.Notifications.Channels = map[string]config.NotificationChannel{
+		"stakeholders": {
+			Type:     "email",
+			SMTPAddr: ln.Addr().String(),
+			From:     "workline@example.com",
+			Events:   []string{"decision.created", notify.TriggerIterationValidated},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	eng := engine.New(conn, cfg)
+	if _, err := eng.InitProject(ctx, "proj-1", "test", "tester"); err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	if err := eng.Repo.UpsertProjectConfig(ctx, "proj-1", cfg); err != nil {
+		t.Fatalf("seed config: %v", err)
+	}
+	if err := eng.GrantRole(ctx, "proj-1", "tester", "ada", "reviewer"); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	for _, p := range []domain.EmailPreferences{
+		{ActorID: "ada", Email: "ada@example.com", Events: []string{"decision.created"}},
+		{ActorID: "tester", Email: "tester@example.com", Events: []string{"waiver.granted"}},
+		{ActorID: "outsider", Email: "outsider@example.com", Events: []string{"decision.created"}},
+	} {
+		if _, err := eng.SetEmailPreferences(ctx, p); err != nil {
+			t.Fatalf("set preferences: %v", err)
+		}
+	}
+	if _, err := eng.SetEmailPreferences(ctx, domain.EmailPreferences{ActorID: "ada", Email: "Ada <ada@example.com>"}); err == nil {
+		t.Fatal("expected a display name address to be rejected")
+	}
+	if _, err := eng.SetEmailPreferences(ctx, domain.EmailPreferences{ActorID: "ada", Events: []string{"task.created"}}); err == nil {
+		t.Fatal("expected an event outside config.EmailEvents to be rejected")
+	}
+
+	d := &notify.Dispatcher{Repo: eng.Repo}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	if _, err := eng.CreateDecision(ctx, domain.Decision{ID: "dec-1", ProjectID: "proj-1", Title: "Adopt SQLite", Decision: "yes"}, "tester"); err != nil {
+		t.Fatalf("create decision: %v", err)
+	}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.msgs) != 1 {
+		t.Fatalf("expected one mail to the opted-in member, got %v", sink.msgs)
+	}
+	msg := sink.msgs["ada@example.com"]
+	if !strings.Contains(msg, "To: ada@example.com") || !strings.Contains(msg, "Subject: [proj-1] decision.created") {
+		t.Fatalf("unexpected message:\n%s", msg)
+	}
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Sender delivers a rendered message to one destination.
+type Sender interface {
+	Send(ctx context.Context, text string) error
+}
+
+// Slack posts to an incoming webhook URL.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s Slack) Send(ctx context.Context, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(s.Client, req, "slack")
+}
+
+// Matrix sends m.text messages to a room using the client-server API.
+type Matrix struct {
+	Homeserver string
+	RoomID     string
+	Token      string
+	Client     *http.Client
+}
+
+var matrixTxn atomic.Int64
+
+func (m Matrix) Send(ctx context.Context, text string) error {
+	body, err := json.Marshal(map[string]string{"msgtype": "m.text", "body": text})
+	if err != nil {
+		return err
+	}
+	txnID := fmt.Sprintf("wl-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(m.Homeserver, "/"), url.PathEscape(m.RoomID), txnID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	return do(m.Client, req, "matrix")
+}
+
+func do(client *http.Client, req *http.Request, name string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: unexpected status %d", name, res.StatusCode)
+	}
+	return nil
+}
+
+// Email mails plain text messages through an SMTP server, upgrading to TLS when the server
+// offers STARTTLS. Each recipient gets its own message so addresses are not shared.
+type Email struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (m Email) Mail(ctx context.Context, to []string, subject, text string) error {
+	for _, rcpt := range to {
+		if err := m.mailOne(ctx, rcpt, subject, text); err != nil {
+			return fmt.Errorf("email to %s: %w", rcpt, err)
+		}
+	}
+	return nil
+}
+
+func (m Email) mailOne(ctx context.Context, to, subject, text string) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(text, "\n", "\r\n") + "\r\n")
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"workline/internal/domain"
+)
+
+// ActiveGrantsTx returns the unexpired grants of the project at now, those held through
+// teams included, ordered by role, actor and team.
+func (r Repo) ActiveGrantsTx(ctx context.Context, tx *sql.Tx, projectID, now string) ([]domain.RoleGrant, error) {
+	rows, err := tx.QueryContext(ctx, activeGrants+`SELECT actor_id, role_id, expires_at, team_id FROM grants
+ORDER BY role_id, actor_id, team_id IS NOT NULL, team_id`, projectID, now, projectID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var grants []domain.RoleGrant
+	for rows.Next() {
+		g := domain.RoleGrant{ProjectID: projectID}
+		var expiresAt, teamID sql.NullString
+		if err := rows.Scan(&g.ActorID, &g.RoleID, &expiresAt, &teamID); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			g.ExpiresAt = &expiresAt.String
+		}
+		if teamID.Valid {
+			g.TeamID = &teamID.String
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// EventsOfTypesTx returns the project's events of the given types, oldest first.
+func (r Repo) EventsOfTypesTx(ctx context.Context, tx *sql.Tx, projectID string, types []string) ([]domain.Event, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	args := []any{projectID}
+	for _, t := range types {
+		args = append(args, t)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(types)), ",")
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM events WHERE project_id=? AND type IN (%s) ORDER BY id`, eventColumns, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+// ListAttestationAuthorsTx returns who recorded each attestation of the project, oldest
+// first, without payloads.
+func (r Repo) ListAttestationAuthorsTx(ctx context.Context, tx *sql.Tx, projectID string) ([]domain.Attestation, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, entity_kind, entity_id, kind, actor_id, ts FROM attestations WHERE project_id=? ORDER BY ts, id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.Attestation
+	for rows.Next() {
+		a := domain.Attestation{ProjectID: projectID}
+		if err := rows.Scan(&a.ID, &a.EntityKind, &a.EntityID, &a.Kind, &a.ActorID, &a.TS); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// MaxPageSize is the largest page a list query accepts. Cursor pagination reads one
+	// look-ahead row to detect a next page, so list functions accept MaxPageSize+1.
+	MaxPageSize = 200
+	// MaxTreeDepth bounds the parent/child nesting a tree read walks.
+	MaxTreeDepth = 32
+	// DefaultRowBudget is how many rows a single API request may read across list queries.
+	DefaultRowBudget = 5000
+)
+
+// QueryBudgetError reports a read rejected by a cost guard: an oversized page, a tree
+// nested too deeply, or a request that exhausted its row budget.
+type QueryBudgetError struct {
+	Guard string
+	Max   int
+	Msg   string
+}
+
+func (e QueryBudgetError) Error() string {
+	return "query budget exceeded: " + e.Msg
+}
+
+// CheckPageLimit rejects page sizes above MaxPageSize.
+func CheckPageLimit(limit int) error {
+	if limit > MaxPageSize {
+		return QueryBudgetError{Guard: "limit", Max: MaxPageSize, Msg: fmt.Sprintf("limit %d exceeds maximum page size %d", limit, MaxPageSize)}
+	}
+	return nil
+}
+
+// CheckTreeDepth rejects trees nested deeper than MaxTreeDepth.
+func CheckTreeDepth(depth int) error {
+	if depth > MaxTreeDepth {
+		return QueryBudgetError{Guard: "depth", Max: MaxTreeDepth, Msg: fmt.Sprintf("task tree deeper than %d levels", MaxTreeDepth)}
+	}
+	return nil
+}
+
+func checkQueryLimit(limit int) error {
+	if limit > MaxPageSize+1 {
+		return CheckPageLimit(limit)
+	}
+	return nil
+}
+
+type rowBudgetKey struct{}
+
+type rowBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// WithRowBudget caps the rows list queries may read while serving ctx.
+func WithRowBudget(ctx context.Context, rows int) context.Context {
+	return context.WithValue(ctx, rowBudgetKey{}, &rowBudget{max: int64(rows)})
+}
+
+// chargeRow counts one scanned row against the request's budget, if any.
+func chargeRow(ctx context.Context, table string) error {
+	b, ok := ctx.Value(rowBudgetKey{}).(*rowBudget)
+	if !ok {
+		return nil
+	}
+	if b.used.Add(1) > b.max {
+		return QueryBudgetError{Guard: "rows", Max: int(b.max), Msg: fmt.Sprintf("request read more than %d rows (while listing %s); narrow the filters or paginate", b.max, table)}
+	}
+	return nil
+}
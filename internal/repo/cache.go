@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Cache keeps project configs and RBAC rows in memory for a long-running process such
+// as the API server, where every request would otherwise reload them. Writes made
+// through a Repo sharing the cache invalidate it; writes from other processes become
+// visible once entries are older than TTL.
+//
+// Loads are only stored when no invalidation happened while they ran, and never from
+// the transaction that performed the last RBAC or config write, so a rolled back write
+// cannot leave entries behind. This relies on the workspace database serving one
+// connection at a time (see db.Open).
+type Cache struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	gen         uint64
+	writer      *sql.Tx
+	configs     map[string]cacheEntry[string]
+	grants      map[grantKey]cacheEntry[[]ActorGrant]
+	authorities map[string]cacheEntry[map[string][]string]
+}
+
+type cacheEntry[T any] struct {
+	value    T
+	loadedAt time.Time
+}
+
+type grantKey struct{ projectID, actorID string }
+
+// NewCache returns a cache whose entries are reloaded after ttl.
+func NewCache(ttl time.Duration) *Cache {
+	c := &Cache{ttl: ttl}
+	c.reset()
+	return c
+}
+
+func (c *Cache) reset() {
+	c.configs = map[string]cacheEntry[string]{}
+	c.grants = map[grantKey]cacheEntry[[]ActorGrant]{}
+	c.authorities = map[string]cacheEntry[map[string][]string]{}
+}
+
+// Invalidate drops every entry. tx is the transaction performing the write, or nil.
+func (c *Cache) Invalidate(tx *sql.Tx) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.writer = tx
+	c.reset()
+}
+
+// snapshot returns the generation a load starts from.
+func (c *Cache) snapshot() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// storable reports whether a load that started at gen through tx may be kept. Callers
+// hold c.mu.
+func (c *Cache) storable(tx *sql.Tx, gen uint64) bool {
+	return gen == c.gen && (tx == nil || tx != c.writer)
+}
+
+// lookup and store take the map through pick so it is read under c.mu, as Invalidate
+// replaces the maps.
+func lookup[K comparable, T any](c *Cache, pick func(*Cache) map[K]cacheEntry[T], key K) (T, bool) {
+	var zero T
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := pick(c)[key]
+	if !ok || time.Since(e.loadedAt) >= c.ttl {
+		return zero, false
+	}
+	return e.value, true
+}
+
+func store[K comparable, T any](c *Cache, pick func(*Cache) map[K]cacheEntry[T], key K, tx *sql.Tx, gen uint64, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.storable(tx, gen) {
+		pick(c)[key] = cacheEntry[T]{value: value, loadedAt: time.Now()}
+	}
+}
+
+func cachedConfigs(c *Cache) map[string]cacheEntry[string]                  { return c.configs }
+func cachedGrants(c *Cache) map[grantKey]cacheEntry[[]ActorGrant]           { return c.grants }
+func cachedAuthorities(c *Cache) map[string]cacheEntry[map[string][]string] { return c.authorities }
+
+// ActorGrant is a role an actor holds in a project, directly or through the team TeamID,
+// with the permissions of the role. ExpiresAt is empty for permanent grants.
+type ActorGrant struct {
+	RoleID      string
+	TeamID      string
+	ExpiresAt   string
+	Permissions map[string]bool
+}
+
+// Active reports whether the grant is unexpired at now (RFC3339).
+func (g ActorGrant) Active(now string) bool {
+	return g.ExpiresAt == "" || g.ExpiresAt > now
+}
+
+// ActorGrantsTx lists the actor's role grants in the project, direct ones first and then
+// those held through its teams, expired ones included, so cached results stay correct as
+// grants lapse.
+func (r Repo) ActorGrantsTx(ctx context.Context, tx *sql.Tx, projectID, actorID string) ([]ActorGrant, error) {
+	c := r.Cache
+	key := grantKey{projectID, actorID}
+	var gen uint64
+	if c != nil {
+		if v, ok := lookup(c, cachedGrants, key); ok {
+			return v, nil
+		}
+		gen = c.snapshot()
+	}
+	rows, err := tx.QueryContext(ctx, `
+SELECT g.role_id, g.team_id, g.expires_at, rp.permission_id
+FROM (
+  SELECT role_id, '' AS team_id, expires_at FROM actor_roles WHERE project_id=? AND actor_id=?
+  UNION ALL
+  SELECT tr.role_id, tr.team_id, tr.expires_at FROM team_members tm
+  JOIN team_roles tr ON tr.project_id=tm.project_id AND tr.team_id=tm.team_id
+  WHERE tm.project_id=? AND tm.actor_id=?
+) g
+LEFT JOIN role_permissions rp ON rp.role_id=g.role_id
+ORDER BY g.team_id, g.role_id`, projectID, actorID, projectID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var grants []ActorGrant
+	for rows.Next() {
+		var roleID, teamID string
+		var expiresAt, perm sql.NullString
+		if err := rows.Scan(&roleID, &teamID, &expiresAt, &perm); err != nil {
+			return nil, err
+		}
+		if last := len(grants) - 1; last < 0 || grants[last].RoleID != roleID || grants[last].TeamID != teamID {
+			grants = append(grants, ActorGrant{RoleID: roleID, TeamID: teamID, ExpiresAt: expiresAt.String, Permissions: map[string]bool{}})
+		}
+		if perm.Valid {
+			grants[len(grants)-1].Permissions[perm.String] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if c != nil {
+		store(c, cachedGrants, key, tx, gen, grants)
+	}
+	return grants, nil
+}
+
+// AttestationAuthoritiesTx maps each attestation kind of the project to the roles allowed
+// to record it.
+func (r Repo) AttestationAuthoritiesTx(ctx context.Context, tx *sql.Tx, projectID string) (map[string][]string, error) {
+	c := r.Cache
+	var gen uint64
+	if c != nil {
+		if v, ok := lookup(c, cachedAuthorities, projectID); ok {
+			return v, nil
+		}
+		gen = c.snapshot()
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT kind, role_id FROM attestation_authorities WHERE project_id=? ORDER BY kind, role_id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	auths := map[string][]string{}
+	for rows.Next() {
+		var kind, roleID string
+		if err := rows.Scan(&kind, &roleID); err != nil {
+			return nil, err
+		}
+		auths[kind] = append(auths[kind], roleID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if c != nil {
+		store(c, cachedAuthorities, projectID, tx, gen, auths)
+	}
+	return auths, nil
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+func capabilitiesJSON(caps []string) any {
+	if len(caps) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(caps)
+	return string(b)
+}
+
+func parseCapabilities(raw sql.NullString) []string {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var caps []string
+	_ = json.Unmarshal([]byte(raw.String), &caps)
+	return caps
+}
+
+// SetActorCapabilitiesTx replaces the capabilities an actor offers in the project.
+func (r Repo) SetActorCapabilitiesTx(ctx context.Context, tx *sql.Tx, projectID, actorID string, caps []string, createdAt string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM actor_capabilities WHERE project_id=? AND actor_id=?`, projectID, actorID); err != nil {
+		return err
+	}
+	for _, c := range caps {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO actor_capabilities(project_id,actor_id,capability,created_at) VALUES (?,?,?,?)`, projectID, actorID, c, createdAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListActorCapabilities returns the capabilities an actor offers in the project, sorted.
+func (r Repo) ListActorCapabilities(ctx context.Context, projectID, actorID string) ([]string, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT capability FROM actor_capabilities WHERE project_id=? AND actor_id=? ORDER BY capability`, projectID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var caps []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		caps = append(caps, c)
+	}
+	return caps, rows.Err()
+}
@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"workline/internal/domain"
+)
+
+// ClientCertKinds lists the certificate identity kinds a mapping may use, in lookup order.
+var ClientCertKinds = []string{"cn", "dns", "email", "uri"}
+
+// UpsertClientCertMapping maps a certificate identity to an actor, replacing any previous mapping.
+func (r Repo) UpsertClientCertMapping(ctx context.Context, m domain.ClientCertMapping) error {
+	if m.Value == "" {
+		return errors.New("value required")
+	}
+	if m.ActorID == "" {
+		return errors.New("actor_id required")
+	}
+	if m.OrgID == "" {
+		m.OrgID = "default-org"
+	}
+	if m.CreatedAt == "" {
+		m.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := r.EnsureActor(ctx, tx, m.ActorID, m.CreatedAt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO client_cert_actors(kind, value, actor_id, org_id, created_at) VALUES (?,?,?,?,?)
+ON CONFLICT(kind, value) DO UPDATE SET actor_id=excluded.actor_id, org_id=excluded.org_id, created_at=excluded.created_at`,
+		m.Kind, m.Value, m.ActorID, m.OrgID, m.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetClientCertMapping returns the actor mapped to a certificate identity.
+func (r Repo) GetClientCertMapping(ctx context.Context, kind, value string) (domain.ClientCertMapping, error) {
+	var m domain.ClientCertMapping
+	err := r.DB.QueryRowContext(ctx, `SELECT kind, value, actor_id, org_id, created_at FROM client_cert_actors WHERE kind=? AND value=?`, kind, value).
+		Scan(&m.Kind, &m.Value, &m.ActorID, &m.OrgID, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return m, ErrNotFound
+	}
+	return m, err
+}
+
+func (r Repo) ListClientCertMappings(ctx context.Context) ([]domain.ClientCertMapping, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT kind, value, actor_id, org_id, created_at FROM client_cert_actors ORDER BY kind, value`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.ClientCertMapping
+	for rows.Next() {
+		var m domain.ClientCertMapping
+		if err := rows.Scan(&m.Kind, &m.Value, &m.ActorID, &m.OrgID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+// DeleteClientCertMapping removes a mapping and reports whether it existed.
+func (r Repo) DeleteClientCertMapping(ctx context.Context, kind, value string) (bool, error) {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM client_cert_actors WHERE kind=? AND value=?`, kind, value)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"workline/internal/domain"
+)
+
+// consistencyChecks select check, table, row id, project, ref and detail for each kind of
+// inconsistency. They run in order, so repairs that remove rows come after those that only
+// clear references.
+var consistencyChecks = []struct {
+	check, repair, query string
+}{
+	{"task_parent", "clear_parent", `
+SELECT 'tasks', t.id, t.project_id, t.parent_id, 'parent task does not exist'
+FROM tasks t LEFT JOIN tasks p ON p.id = t.parent_id
+WHERE t.parent_id IS NOT NULL AND p.id IS NULL`},
+	{"task_iteration", "clear_iteration", `
+SELECT 'tasks', t.id, t.project_id, t.iteration_id, 'iteration does not exist'
+FROM tasks t LEFT JOIN iterations i ON i.id = t.iteration_id
+WHERE t.iteration_id IS NOT NULL AND i.id IS NULL`},
+	{"task_dependency", "delete_dependency", `
+SELECT 'task_deps', d.task_id, COALESCE(t.project_id, ''), d.depends_on_task_id,
+  CASE WHEN t.id IS NULL THEN 'dependent task does not exist' ELSE 'dependency does not exist' END
+FROM task_deps d LEFT JOIN tasks t ON t.id = d.task_id LEFT JOIN tasks x ON x.id = d.depends_on_task_id
+WHERE t.id IS NULL OR x.id IS NULL`},
+	{"attestation_entity", "delete_attestation", `
+SELECT 'attestations', a.id, a.project_id, a.entity_id, a.entity_kind || ' does not exist'
+FROM attestations a
+WHERE (a.entity_kind = 'task' AND NOT EXISTS (SELECT 1 FROM tasks WHERE id = a.entity_id))
+   OR (a.entity_kind = 'iteration' AND NOT EXISTS (SELECT 1 FROM iterations WHERE id = a.entity_id))
+   OR (a.entity_kind = 'decision' AND NOT EXISTS (SELECT 1 FROM decisions WHERE id = a.entity_id))
+   OR (a.entity_kind = 'project' AND NOT EXISTS (SELECT 1 FROM projects WHERE id = a.entity_id))
+   OR (a.entity_kind = 'attestation' AND NOT EXISTS (SELECT 1 FROM attestations x WHERE x.id = a.entity_id))`},
+	{"lease", "delete_lease", `
+SELECT l.tbl, l.task_id, COALESCE(t.project_id, ''), l.task_id,
+  CASE WHEN t.id IS NULL THEN 'task does not exist' ELSE 'task is ' || t.status END
+FROM (SELECT 'leases' AS tbl, task_id FROM leases
+      UNION ALL SELECT 'review_leases', task_id FROM review_leases
+      UNION ALL SELECT DISTINCT 'lease_waiters', task_id FROM lease_waiters) l
+LEFT JOIN tasks t ON t.id = l.task_id
+WHERE t.id IS NULL OR t.status IN ('done', 'canceled', 'rejected')`},
+}
+
+// FindInconsistenciesTx lists tasks pointing at missing parents or iterations, dependencies
+// on missing tasks, attestations on missing entities, and leases, review leases and lease
+// queues whose task is missing or finished. Lease queues are reported once per task.
+func (r Repo) FindInconsistenciesTx(ctx context.Context, tx *sql.Tx) ([]domain.Inconsistency, error) {
+	res := []domain.Inconsistency{}
+	for _, c := range consistencyChecks {
+		rows, err := tx.QueryContext(ctx, c.query)
+		if err != nil {
+			return nil, fmt.Errorf("consistency check %s: %w", c.check, err)
+		}
+		for rows.Next() {
+			inc := domain.Inconsistency{Check: c.check, Repair: c.repair}
+			if err := rows.Scan(&inc.Table, &inc.RowID, &inc.ProjectID, &inc.Ref, &inc.Detail); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			res = append(res, inc)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// ConsistencyCheckNames lists the checks FindInconsistenciesTx runs, in order.
+func ConsistencyCheckNames() []string {
+	names := make([]string, 0, len(consistencyChecks))
+	for _, c := range consistencyChecks {
+		names = append(names, c.check)
+	}
+	return names
+}
+
+// DeleteAttestationTx removes an attestation.
+func (r Repo) DeleteAttestationTx(ctx context.Context, tx *sql.Tx, id string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM attestations WHERE id=?`, id)
+	return err
+}
+
+// DeleteLeaseWaitersTx empties the lease queue of a task.
+func (r Repo) DeleteLeaseWaitersTx(ctx context.Context, tx *sql.Tx, taskID string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM lease_waiters WHERE task_id=?`, taskID)
+	return err
+}
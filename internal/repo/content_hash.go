@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"workline/internal/canon"
+)
+
+// BackfillContentHashesTx records the content hash of the tasks, decisions and attestations
+// written before hashes were stored.
+func (r Repo) BackfillContentHashesTx(ctx context.Context, tx *sql.Tx) error {
+	for _, table := range []string{"tasks", "decisions", "attestations"} {
+		ids, err := missingHashIDs(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			var hash string
+			switch table {
+			case "tasks":
+				t, err := r.GetTaskTx(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				hash = canon.TaskHash(t)
+			case "decisions":
+				d, err := scanDecision(tx.QueryRowContext(ctx, `SELECT `+decisionColumns+` FROM decisions WHERE id=?`, id))
+				if err != nil {
+					return err
+				}
+				hash = canon.DecisionHash(d)
+			default:
+				a, err := r.GetAttestationTx(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				hash = canon.AttestationHash(a)
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET content_hash=? WHERE id=?`, hash, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func missingHashIDs(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM `+table+` WHERE content_hash IS NULL OR content_hash = ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
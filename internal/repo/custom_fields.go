@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"workline/internal/config"
+)
+
+// CustomFieldOps are the comparisons a custom field filter may use, longest first so
+// parsing prefers "<=" over "<".
+var CustomFieldOps = []string{">=", "<=", "=", ">", "<"}
+
+// CustomFieldFilter compares one top-level custom field of a task with Value, which is a
+// string, int64, float64 or bool.
+type CustomFieldFilter struct {
+	Field string
+	Op    string
+	Value any
+}
+
+// ParseCustomFieldFilter reads "<field><op><value>", e.g. component=billing or points>=3.
+// Values that parse as numbers or true/false compare as such; wrap a value in double quotes
+// to compare it as a string.
+func ParseCustomFieldFilter(s string) (CustomFieldFilter, error) {
+	i := strings.IndexAny(s, "<>=")
+	if i <= 0 {
+		return CustomFieldFilter{}, fmt.Errorf("invalid custom field filter %q: expected <field><op><value> with op one of %s", s, strings.Join(CustomFieldOps, " "))
+	}
+	f := CustomFieldFilter{Field: s[:i]}
+	if !config.ValidCustomFieldName(f.Field) {
+		return f, fmt.Errorf("invalid custom field filter %q: field must be lowercase letters, digits or '_'", s)
+	}
+	rest := s[i:]
+	for _, op := range CustomFieldOps {
+		if strings.HasPrefix(rest, op) {
+			f.Op = op
+			break
+		}
+	}
+	if f.Op == "" {
+		return f, fmt.Errorf("invalid custom field filter %q: unknown operator", s)
+	}
+	f.Value = customFieldValue(rest[len(f.Op):])
+	return f, nil
+}
+
+func customFieldValue(raw string) any {
+	if len(raw) >= 2 && strings.HasPrefix(raw, `"`) && strings.HasSuffix(raw, `"`) {
+		return raw[1 : len(raw)-1]
+	}
+	switch raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseFloat(raw, 64); err == nil {
+		return n
+	}
+	return raw
+}
+
+func customFieldColumn(field string) string {
+	return "cf_" + field
+}
+
+// MaxCustomFieldColumns caps the generated custom field columns of the tasks table, which
+// all projects share.
+const MaxCustomFieldColumns = 32
+
+// EnsureCustomFieldIndexes adds a virtual generated column extracting each field from
+// custom_fields_json, with an index on (project_id, column). Existing columns are kept.
+// It changes the schema, so it runs as an operator step rather than on config writes.
+func (r Repo) EnsureCustomFieldIndexes(ctx context.Context, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	existing, err := customFieldColumns(func(q string, args ...any) (*sql.Rows, error) {
+		return r.DB.QueryContext(ctx, q, args...)
+	})
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, field := range fields {
+		if !config.ValidCustomFieldName(field) {
+			return fmt.Errorf("invalid custom field name %q", field)
+		}
+		if !existing[customFieldColumn(field)] {
+			missing = append(missing, field)
+		}
+	}
+	if n := len(existing) + len(missing); n > MaxCustomFieldColumns {
+		return fmt.Errorf("invalid custom field indexes: %d columns exceed the limit of %d", n, MaxCustomFieldColumns)
+	}
+	for _, field := range fields {
+		col := customFieldColumn(field)
+		if !existing[col] {
+			if _, err := r.DB.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE tasks ADD COLUMN %s GENERATED ALWAYS AS (json_extract(custom_fields_json, '$.%s')) VIRTUAL`, col, field)); err != nil {
+				return fmt.Errorf("index custom field %s: %w", field, err)
+			}
+		}
+		if _, err := r.DB.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_tasks_%s ON tasks(project_id, %s)`, col, col)); err != nil {
+			return fmt.Errorf("index custom field %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+func customFieldColumns(query func(string, ...any) (*sql.Rows, error)) (map[string]bool, error) {
+	rows, err := query(`SELECT name FROM pragma_table_xinfo('tasks') WHERE name LIKE 'cf\_%' ESCAPE '\'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+// customFieldClauses turns filters into WHERE clauses, reading indexed fields from their
+// generated column and the rest from custom_fields_json directly.
+func (r Repo) customFieldClauses(ctx context.Context, q queryer, filters []CustomFieldFilter) ([]string, []any, error) {
+	if len(filters) == 0 {
+		return nil, nil, nil
+	}
+	indexed, err := customFieldColumns(func(query string, args ...any) (*sql.Rows, error) {
+		return q.QueryContext(ctx, query, args...)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var clauses []string
+	var args []any
+	for _, f := range filters {
+		if !config.ValidCustomFieldName(f.Field) || !slices.Contains(CustomFieldOps, f.Op) {
+			return nil, nil, fmt.Errorf("invalid custom field filter %s%s", f.Field, f.Op)
+		}
+		value := f.Value
+		if b, ok := value.(bool); ok {
+			// json_extract reports JSON booleans as 1 and 0.
+			value = 0
+			if b {
+				value = 1
+			}
+		}
+		if col := customFieldColumn(f.Field); indexed[col] {
+			clauses = append(clauses, col+f.Op+"?")
+			args = append(args, value)
+		} else {
+			clauses = append(clauses, "json_extract(custom_fields_json, ?)"+f.Op+"?")
+			args = append(args, "$."+f.Field, value)
+		}
+	}
+	return clauses, args, nil
+}
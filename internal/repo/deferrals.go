@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ElapsedDeferral is a task whose defer_until has passed. Ready tells whether it is planned
+// with every dependency done.
+type ElapsedDeferral struct {
+	TaskID     string
+	ProjectID  string
+	DeferUntil string
+	Ready      bool
+}
+
+// ElapsedDeferralsTx lists the tasks deferred until now or earlier, oldest deferral first.
+func (r Repo) ElapsedDeferralsTx(ctx context.Context, tx *sql.Tx, now string) ([]ElapsedDeferral, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, project_id, defer_until,
+  status='planned' AND NOT EXISTS (SELECT 1 FROM task_deps d JOIN tasks dt ON dt.id=d.depends_on_task_id WHERE d.task_id=tasks.id AND dt.status<>'done')
+FROM tasks WHERE defer_until IS NOT NULL AND defer_until<=? ORDER BY defer_until, id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []ElapsedDeferral
+	for rows.Next() {
+		var d ElapsedDeferral
+		if err := rows.Scan(&d.TaskID, &d.ProjectID, &d.DeferUntil, &d.Ready); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// ClearTaskDeferralTx ends the deferral of a task without touching its content.
+func (r Repo) ClearTaskDeferralTx(ctx context.Context, tx *sql.Tx, taskID string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE tasks SET defer_until=NULL WHERE id=?`, taskID)
+	return err
+}
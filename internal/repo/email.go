@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"workline/internal/domain"
+)
+
+func scanEmailPreferences(row rowScanner) (domain.EmailPreferences, error) {
+	var p domain.EmailPreferences
+	var email sql.NullString
+	var events string
+	if err := row.Scan(&p.ActorID, &email, &events); err != nil {
+		return p, err
+	}
+	p.Email = email.String
+	p.Events = []string{}
+	if err := json.Unmarshal([]byte(events), &p.Events); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// GetEmailPreferences returns the email preferences of a registered actor.
+func (r Repo) GetEmailPreferences(ctx context.Context, actorID string) (domain.EmailPreferences, error) {
+	p, err := scanEmailPreferences(r.reader(ctx).QueryRowContext(ctx, `SELECT id, email, email_events_json FROM actors WHERE id=?`, actorID))
+	if err == sql.ErrNoRows {
+		return p, ErrNotFound
+	}
+	return p, err
+}
+
+// SetEmailPreferencesTx stores p on its actor, which must be registered; an empty email
+// clears the address.
+func (r Repo) SetEmailPreferencesTx(ctx context.Context, tx *sql.Tx, p domain.EmailPreferences) error {
+	events, err := json.Marshal(p.Events)
+	if err != nil {
+		return err
+	}
+	var email any
+	if p.Email != "" {
+		email = p.Email
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE actors SET email=?, email_events_json=? WHERE id=?`, email, string(events), p.ActorID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EmailRecipients returns the actors with an unexpired grant in the project at now that
+// have an email address and opted in to event, ordered by actor id.
+func (r Repo) EmailRecipients(ctx context.Context, projectID, event, now string) ([]domain.EmailPreferences, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, activeGrants+`SELECT a.id, a.email, a.email_events_json FROM actors a
+		WHERE a.email IS NOT NULL AND a.id IN (SELECT actor_id FROM grants)
+		AND EXISTS (SELECT 1 FROM json_each(a.email_events_json) WHERE value=?)
+		ORDER BY a.id`, projectID, now, projectID, now, event)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipients []domain.EmailPreferences
+	for rows.Next() {
+		p, err := scanEmailPreferences(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, p)
+	}
+	return recipients, rows.Err()
+}
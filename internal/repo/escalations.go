@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"workline/internal/domain"
+)
+
+const escalationRuleColumns = `project_id,id,description,condition,after,task_type,action,role_id,enabled,created_by,created_at,updated_at`
+
+func (r Repo) InsertEscalationRuleTx(ctx context.Context, tx *sql.Tx, rule domain.EscalationRule) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO escalation_rules(`+escalationRuleColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		rule.ProjectID, rule.ID, nullable(rule.Description), rule.Condition, rule.After, nullable(rule.TaskType), rule.Action, nullable(rule.RoleID),
+		rule.Enabled, rule.CreatedBy, rule.CreatedAt, rule.UpdatedAt)
+	return err
+}
+
+// UpdateEscalationRuleTx replaces the settings of a rule, keeping its author and creation time.
+func (r Repo) UpdateEscalationRuleTx(ctx context.Context, tx *sql.Tx, rule domain.EscalationRule) error {
+	_, err := tx.ExecContext(ctx, `UPDATE escalation_rules SET description=?, condition=?, after=?, task_type=?, action=?, role_id=?, enabled=?, updated_at=?
+WHERE project_id=? AND id=?`,
+		nullable(rule.Description), rule.Condition, rule.After, nullable(rule.TaskType), rule.Action, nullable(rule.RoleID), rule.Enabled, rule.UpdatedAt,
+		rule.ProjectID, rule.ID)
+	return err
+}
+
+// DeleteEscalationRuleTx removes a rule with its firings.
+func (r Repo) DeleteEscalationRuleTx(ctx context.Context, tx *sql.Tx, projectID, ruleID string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM escalation_rules WHERE project_id=? AND id=?`, projectID, ruleID)
+	return err
+}
+
+func scanEscalationRule(row rowScanner) (domain.EscalationRule, error) {
+	var rule domain.EscalationRule
+	var description, taskType, roleID sql.NullString
+	err := row.Scan(&rule.ProjectID, &rule.ID, &description, &rule.Condition, &rule.After, &taskType, &rule.Action, &roleID,
+		&rule.Enabled, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+	rule.Description, rule.TaskType, rule.RoleID = description.String, taskType.String, roleID.String
+	return rule, err
+}
+
+func (r Repo) GetEscalationRule(ctx context.Context, projectID, ruleID string) (domain.EscalationRule, error) {
+	rule, err := scanEscalationRule(r.reader(ctx).QueryRowContext(ctx, `SELECT `+escalationRuleColumns+` FROM escalation_rules WHERE project_id=? AND id=?`, projectID, ruleID))
+	if err == sql.ErrNoRows {
+		return rule, ErrNotFound
+	}
+	return rule, err
+}
+
+func (r Repo) GetEscalationRuleTx(ctx context.Context, tx *sql.Tx, projectID, ruleID string) (domain.EscalationRule, error) {
+	rule, err := scanEscalationRule(tx.QueryRowContext(ctx, `SELECT `+escalationRuleColumns+` FROM escalation_rules WHERE project_id=? AND id=?`, projectID, ruleID))
+	if err == sql.ErrNoRows {
+		return rule, ErrNotFound
+	}
+	return rule, err
+}
+
+// ListEscalationRules returns the project's rules ordered by id.
+func (r Repo) ListEscalationRules(ctx context.Context, projectID string) ([]domain.EscalationRule, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+escalationRuleColumns+` FROM escalation_rules WHERE project_id=? ORDER BY id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.EscalationRule{}
+	for rows.Next() {
+		rule, err := scanEscalationRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rule)
+	}
+	return res, rows.Err()
+}
+
+// ListEscalationFirings returns the latest firing of each rule and task of the project.
+func (r Repo) ListEscalationFirings(ctx context.Context, projectID string) ([]domain.EscalationFiring, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT rule_id, task_id, since, fired_at, follow_up_task_id FROM escalation_firings WHERE project_id=? ORDER BY rule_id, task_id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.EscalationFiring
+	for rows.Next() {
+		var f domain.EscalationFiring
+		if err := rows.Scan(&f.RuleID, &f.TaskID, &f.Since, &f.FiredAt, &f.FollowUpTaskID); err != nil {
+			return nil, err
+		}
+		res = append(res, f)
+	}
+	return res, rows.Err()
+}
+
+// RecordEscalationFiringTx stores the firing of a rule on a task, replacing the one of an
+// earlier spell.
+func (r Repo) RecordEscalationFiringTx(ctx context.Context, tx *sql.Tx, projectID string, f domain.EscalationFiring) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO escalation_firings(project_id, rule_id, task_id, since, fired_at, follow_up_task_id) VALUES (?,?,?,?,?,?)
+ON CONFLICT(project_id, rule_id, task_id) DO UPDATE SET since=excluded.since, fired_at=excluded.fired_at, follow_up_task_id=excluded.follow_up_task_id`,
+		projectID, f.RuleID, f.TaskID, f.Since, f.FiredAt, nullableStringPtr(f.FollowUpTaskID))
+	return err
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+// CountOpenTasksTx returns, per project, how many unfinished tasks are in each status.
+func (r Repo) CountOpenTasksTx(ctx context.Context, tx *sql.Tx) (map[string]map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT project_id, status, COUNT(*) FROM tasks WHERE status NOT IN ('done','canceled','rejected') GROUP BY project_id, status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[string]map[string]int{}
+	for rows.Next() {
+		var projectID, status string
+		var n int
+		if err := rows.Scan(&projectID, &status, &n); err != nil {
+			return nil, err
+		}
+		if res[projectID] == nil {
+			res[projectID] = map[string]int{}
+		}
+		res[projectID][status] = n
+	}
+	return res, rows.Err()
+}
+
+// CountActiveLeasesTx returns, per project, how many task leases are unexpired at now.
+func (r Repo) CountActiveLeasesTx(ctx context.Context, tx *sql.Tx, now string) (map[string]int, error) {
+	return countByProject(tx.QueryContext(ctx, `SELECT t.project_id, COUNT(*) FROM leases l JOIN tasks t ON t.id=l.task_id WHERE l.expires_at>? GROUP BY t.project_id`, now))
+}
+
+// CountAttestationsTx returns, per project, how many attestations were ever recorded.
+func (r Repo) CountAttestationsTx(ctx context.Context, tx *sql.Tx) (map[string]int, error) {
+	return countByProject(tx.QueryContext(ctx, `SELECT project_id, COUNT(*) FROM attestations GROUP BY project_id`))
+}
+
+func countByProject(rows *sql.Rows, err error) (map[string]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[string]int{}
+	for rows.Next() {
+		var projectID string
+		var n int
+		if err := rows.Scan(&projectID, &n); err != nil {
+			return nil, err
+		}
+		res[projectID] = n
+	}
+	return res, rows.Err()
+}
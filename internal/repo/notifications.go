@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"workline/internal/domain"
+)
+
+const notificationColumns = `id,project_id,actor_id,kind,entity_kind,entity_id,event_id,message,dedupe_key,created_at,read_at`
+
+func scanNotification(row rowScanner) (domain.Notification, error) {
+	var n domain.Notification
+	err := row.Scan(&n.ID, &n.ProjectID, &n.ActorID, &n.Kind, &n.EntityKind, &n.EntityID, &n.EventID, &n.Message, &n.DedupeKey, &n.CreatedAt, &n.ReadAt)
+	return n, err
+}
+
+// InsertNotificationTx adds n to its actor's inbox unless one with the same dedupe key is
+// already there, and reports whether it was added.
+func (r Repo) InsertNotificationTx(ctx context.Context, tx *sql.Tx, n domain.Notification) (bool, error) {
+	res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO notifications(project_id,actor_id,kind,entity_kind,entity_id,event_id,message,dedupe_key,created_at)
+VALUES (?,?,?,?,?,?,?,?,?)`, n.ProjectID, n.ActorID, n.Kind, n.EntityKind, n.EntityID, n.EventID, n.Message, n.DedupeKey, n.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	added, err := res.RowsAffected()
+	return added > 0, err
+}
+
+// ListNotifications returns the actor's notifications in the project, newest first,
+// starting below beforeID when it is positive.
+func (r Repo) ListNotifications(ctx context.Context, projectID, actorID string, unreadOnly bool, limit int, beforeID int64) ([]domain.Notification, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE project_id=? AND actor_id=?`
+	args := []any{projectID, actorID}
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	if beforeID > 0 {
+		query += ` AND id<?`
+		args = append(args, beforeID)
+	}
+	query += ` ORDER BY id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, rows.Err()
+}
+
+// CountUnreadNotifications counts the actor's unread notifications in the project.
+func (r Repo) CountUnreadNotifications(ctx context.Context, projectID, actorID string) (int, error) {
+	var n int
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM notifications WHERE project_id=? AND actor_id=? AND read_at IS NULL`, projectID, actorID).Scan(&n)
+	return n, err
+}
+
+// MarkNotificationReadTx marks one of the actor's notifications read at now, keeping the
+// time of an earlier read.
+func (r Repo) MarkNotificationReadTx(ctx context.Context, tx *sql.Tx, projectID, actorID string, id int64, now string) (domain.Notification, error) {
+	if _, err := tx.ExecContext(ctx, `UPDATE notifications SET read_at=COALESCE(read_at, ?) WHERE id=? AND project_id=? AND actor_id=?`, now, id, projectID, actorID); err != nil {
+		return domain.Notification{}, err
+	}
+	n, err := scanNotification(tx.QueryRowContext(ctx, `SELECT `+notificationColumns+` FROM notifications WHERE id=? AND project_id=? AND actor_id=?`, id, projectID, actorID))
+	if err == sql.ErrNoRows {
+		return n, ErrNotFound
+	}
+	return n, err
+}
+
+// MarkAllNotificationsReadTx marks every unread notification of the actor in the project
+// read at now and returns how many it marked.
+func (r Repo) MarkAllNotificationsReadTx(ctx context.Context, tx *sql.Tx, projectID, actorID, now string) (int, error) {
+	res, err := tx.ExecContext(ctx, `UPDATE notifications SET read_at=? WHERE project_id=? AND actor_id=? AND read_at IS NULL`, now, projectID, actorID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ExpiringLeases returns the leases on unfinished tasks of a project that are still active
+// at now and expire by until, soonest first.
+func (r Repo) ExpiringLeases(ctx context.Context, projectID, now, until string) ([]domain.Lease, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+leaseColumns+` FROM leases l JOIN tasks t ON t.id=l.task_id
+WHERE t.project_id=? AND t.status NOT IN ('done','canceled','rejected') AND l.expires_at>? AND l.expires_at<=? ORDER BY l.expires_at ASC, l.task_id ASC`, projectID, now, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.Lease
+	for rows.Next() {
+		l, err := scanLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) SetNotificationCursorTx(ctx context.Context, tx *sql.Tx, projectID, channel string, lastEventID int64, now string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO notification_cursors(project_id,channel,last_event_id,updated_at) VALUES (?,?,?,?)
+ON CONFLICT(project_id,channel) DO UPDATE SET last_event_id=excluded.last_event_id, updated_at=excluded.updated_at`, projectID, channel, lastEventID, now)
+	return err
+}
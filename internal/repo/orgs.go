@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"workline/internal/domain"
+)
+
+func scanOrg(row rowScanner) (domain.Org, error) {
+	var o domain.Org
+	var name sql.NullString
+	err := row.Scan(&o.ID, &name, &o.CreatedAt)
+	if err == sql.ErrNoRows {
+		return o, ErrNotFound
+	}
+	o.Name = name.String
+	return o, err
+}
+
+func (r Repo) GetOrg(ctx context.Context, id string) (domain.Org, error) {
+	return scanOrg(r.reader(ctx).QueryRowContext(ctx, `SELECT id,name,created_at FROM organizations WHERE id=?`, id))
+}
+
+func (r Repo) GetOrgTx(ctx context.Context, tx *sql.Tx, id string) (domain.Org, error) {
+	return scanOrg(tx.QueryRowContext(ctx, `SELECT id,name,created_at FROM organizations WHERE id=?`, id))
+}
+
+// InsertOrgTx creates an org; unlike EnsureOrg it fails when the id is taken.
+func (r Repo) InsertOrgTx(ctx context.Context, tx *sql.Tx, o domain.Org) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO organizations(id, name, created_at) VALUES (?,?,?)`, o.ID, o.Name, o.CreatedAt)
+	return err
+}
+
+// ListOrgProjects returns the projects of an org, newest first like ListProjects.
+func (r Repo) ListOrgProjects(ctx context.Context, orgID string) ([]domain.Project, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+projectColumns+` FROM projects WHERE org_id=? ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// GetOrgRoleTx returns the actor's role in the org, or ErrNotFound when it has none.
+func (r Repo) GetOrgRoleTx(ctx context.Context, tx *sql.Tx, orgID, actorID string) (string, error) {
+	var role string
+	err := tx.QueryRowContext(ctx, `SELECT role FROM org_roles WHERE org_id=? AND actor_id=?`, orgID, actorID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return role, err
+}
+
+// SetOrgRoleTx gives the actor role in the org, replacing the role it had.
+func (r Repo) SetOrgRoleTx(ctx context.Context, tx *sql.Tx, orgID, actorID, role string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO org_roles(org_id, actor_id, role) VALUES (?,?,?)
+ON CONFLICT(org_id, actor_id) DO UPDATE SET role=excluded.role`, orgID, actorID, role)
+	return err
+}
+
+func (r Repo) DeleteOrgRoleTx(ctx context.Context, tx *sql.Tx, orgID, actorID string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM org_roles WHERE org_id=? AND actor_id=?`, orgID, actorID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r Repo) CountOrgOwnersTx(ctx context.Context, tx *sql.Tx, orgID string) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM org_roles WHERE org_id=? AND role='owner'`, orgID).Scan(&n)
+	return n, err
+}
+
+func (r Repo) ListOrgMembers(ctx context.Context, orgID string) ([]domain.OrgMember, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT org_id, actor_id, role FROM org_roles WHERE org_id=? ORDER BY actor_id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.OrgMember{}
+	for rows.Next() {
+		var m domain.OrgMember
+		if err := rows.Scan(&m.OrgID, &m.ActorID, &m.Role); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
@@ -3,6 +3,8 @@ package repo
 import (
 	"context"
 	"database/sql"
+
+	"workline/internal/domain"
 )
 
 func (r Repo) EnsureActor(ctx context.Context, tx *sql.Tx, actorID string, now string) error {
@@ -10,6 +12,21 @@ func (r Repo) EnsureActor(ctx context.Context, tx *sql.Tx, actorID string, now s
 	return err
 }
 
+// UnknownActorsTx returns the ids among ids that are not in the actor registry, in order.
+func (r Repo) UnknownActorsTx(ctx context.Context, tx *sql.Tx, ids []string) ([]string, error) {
+	var unknown []string
+	for _, id := range ids {
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM actors WHERE id=?`, id).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown, nil
+}
+
 func (r Repo) EnsureOrg(ctx context.Context, tx *sql.Tx, orgID, name, now string) error {
 	if name == "" {
 		name = orgID
@@ -24,6 +41,7 @@ func (r Repo) AssignOrgRole(ctx context.Context, tx *sql.Tx, orgID, actorID, rol
 }
 
 func (r Repo) InsertRole(ctx context.Context, tx *sql.Tx, id, desc string) error {
+	r.Cache.Invalidate(tx)
 	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO roles(id, description) VALUES (?,?)`, id, desc)
 	return err
 }
@@ -34,26 +52,249 @@ func (r Repo) InsertPermission(ctx context.Context, tx *sql.Tx, id, desc string)
 }
 
 func (r Repo) AddRolePermission(ctx context.Context, tx *sql.Tx, roleID, permID string) error {
+	r.Cache.Invalidate(tx)
 	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO role_permissions(role_id, permission_id) VALUES (?,?)`, roleID, permID)
 	return err
 }
 
 func (r Repo) AssignRole(ctx context.Context, tx *sql.Tx, projectID, actorID, roleID string) error {
+	r.Cache.Invalidate(tx)
 	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO actor_roles(project_id, actor_id, role_id) VALUES (?,?,?)`, projectID, actorID, roleID)
 	return err
 }
 
+// AssignRoleUntil grants a role, replacing the expiry of an existing grant; an empty
+// expiresAt makes the grant permanent.
+func (r Repo) AssignRoleUntil(ctx context.Context, tx *sql.Tx, projectID, actorID, roleID, expiresAt string) error {
+	r.Cache.Invalidate(tx)
+	_, err := tx.ExecContext(ctx, `INSERT INTO actor_roles(project_id, actor_id, role_id, expires_at) VALUES (?,?,?,?)
+ON CONFLICT(project_id, actor_id, role_id) DO UPDATE SET expires_at=excluded.expires_at`, projectID, actorID, roleID, nullable(expiresAt))
+	return err
+}
+
+// ExpiredRoleGrantsTx lists actor and team grants whose expires_at is at or before now.
+func (r Repo) ExpiredRoleGrantsTx(ctx context.Context, tx *sql.Tx, now string) ([]domain.RoleGrant, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT project_id, actor_id, '', role_id, expires_at FROM actor_roles WHERE expires_at IS NOT NULL AND expires_at <= ?
+UNION ALL
+SELECT project_id, '', team_id, role_id, expires_at FROM team_roles WHERE expires_at IS NOT NULL AND expires_at <= ?
+ORDER BY 5, 1, 2, 3, 4`, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var grants []domain.RoleGrant
+	for rows.Next() {
+		var g domain.RoleGrant
+		var teamID, expiresAt string
+		if err := rows.Scan(&g.ProjectID, &g.ActorID, &teamID, &g.RoleID, &expiresAt); err != nil {
+			return nil, err
+		}
+		g.ExpiresAt = &expiresAt
+		if teamID != "" {
+			g.TeamID = &teamID
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// activeGrants selects (actor_id, role_id, expires_at, team_id) for the unexpired grants of
+// a project, those held through teams included; it takes the project id and now twice.
+const activeGrants = `WITH grants(actor_id, role_id, expires_at, team_id) AS (
+  SELECT actor_id, role_id, expires_at, NULL FROM actor_roles
+  WHERE project_id=? AND (expires_at IS NULL OR expires_at > ?)
+  UNION ALL
+  SELECT tm.actor_id, tr.role_id, tr.expires_at, tr.team_id FROM team_members tm
+  JOIN team_roles tr ON tr.project_id=tm.project_id AND tr.team_id=tm.team_id
+  WHERE tm.project_id=? AND (tr.expires_at IS NULL OR tr.expires_at > ?)
+) `
+
+// RoleHolders returns the actors holding roleID in the project at now, directly or through
+// a team, ordered by id.
+func (r Repo) RoleHolders(ctx context.Context, projectID, roleID, now string) ([]string, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, activeGrants+`SELECT DISTINCT actor_id FROM grants WHERE role_id=? ORDER BY actor_id`,
+		projectID, now, projectID, now, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holders := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		holders = append(holders, id)
+	}
+	return holders, rows.Err()
+}
+
+// ListMembers returns actors with an unexpired grant in the project, directly or through a
+// team, ordered by actor id, starting after cursorActorID, with their active grants and
+// effective permissions.
+func (r Repo) ListMembers(ctx context.Context, projectID, now string, limit int, cursorActorID string) ([]domain.Member, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	scope := []any{projectID, now, projectID, now}
+	query := activeGrants + `SELECT DISTINCT actor_id FROM grants WHERE actor_id > ? ORDER BY actor_id`
+	args := append(scope, cursorActorID)
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var members []domain.Member
+	index := map[string]int{}
+	for rows.Next() {
+		if err := chargeRow(ctx, "members"); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var m domain.Member
+		if err := rows.Scan(&m.ActorID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[m.ActorID] = len(members)
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(members) == 0 {
+		return members, err
+	}
+	last := members[len(members)-1].ActorID
+	grants, err := r.reader(ctx).QueryContext(ctx, activeGrants+`SELECT actor_id, role_id, expires_at, team_id FROM grants
+WHERE actor_id > ? AND actor_id <= ? ORDER BY actor_id, team_id IS NOT NULL, team_id, role_id`,
+		append(scope, cursorActorID, last)...)
+	if err != nil {
+		return nil, err
+	}
+	defer grants.Close()
+	for grants.Next() {
+		g := domain.RoleGrant{ProjectID: projectID}
+		var expiresAt, teamID sql.NullString
+		if err := grants.Scan(&g.ActorID, &g.RoleID, &expiresAt, &teamID); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			g.ExpiresAt = &expiresAt.String
+		}
+		if teamID.Valid {
+			g.TeamID = &teamID.String
+		}
+		m := &members[index[g.ActorID]]
+		m.Roles = append(m.Roles, g)
+	}
+	if err := grants.Err(); err != nil {
+		return nil, err
+	}
+	perms, err := r.reader(ctx).QueryContext(ctx, activeGrants+`SELECT DISTINCT g.actor_id, rp.permission_id FROM grants g
+JOIN role_permissions rp ON rp.role_id=g.role_id
+WHERE g.actor_id > ? AND g.actor_id <= ? ORDER BY g.actor_id, rp.permission_id`,
+		append(scope, cursorActorID, last)...)
+	if err != nil {
+		return nil, err
+	}
+	defer perms.Close()
+	for perms.Next() {
+		var actorID, perm string
+		if err := perms.Scan(&actorID, &perm); err != nil {
+			return nil, err
+		}
+		m := &members[index[actorID]]
+		m.Permissions = append(m.Permissions, perm)
+	}
+	return members, perms.Err()
+}
+
+// actorGrants selects (project_id, role_id, expires_at, team_id) for the unexpired grants
+// of an actor across projects, those held through teams included; it takes the actor id
+// and now twice.
+const actorGrants = `WITH grants(project_id, role_id, expires_at, team_id) AS (
+  SELECT project_id, role_id, expires_at, NULL FROM actor_roles
+  WHERE actor_id=? AND (expires_at IS NULL OR expires_at > ?)
+  UNION ALL
+  SELECT tm.project_id, tr.role_id, tr.expires_at, tr.team_id FROM team_members tm
+  JOIN team_roles tr ON tr.project_id=tm.project_id AND tr.team_id=tm.team_id
+  WHERE tm.actor_id=? AND (tr.expires_at IS NULL OR tr.expires_at > ?)
+) `
+
+// ListMemberships returns the projects of orgID (any org when empty) where actorID holds
+// an unexpired grant at now, ordered by project id, with the grants and the permissions
+// they give.
+func (r Repo) ListMemberships(ctx context.Context, actorID, orgID, now string) ([]domain.Membership, error) {
+	scope := []any{actorID, now, actorID, now, orgID, orgID}
+	rows, err := r.reader(ctx).QueryContext(ctx, actorGrants+`SELECT g.project_id, p.org_id, g.role_id, g.expires_at, g.team_id FROM grants g
+JOIN projects p ON p.id=g.project_id
+WHERE (?='' OR p.org_id=?) ORDER BY g.project_id, g.team_id IS NOT NULL, g.team_id, g.role_id`, scope...)
+	if err != nil {
+		return nil, err
+	}
+	memberships := []domain.Membership{}
+	index := map[string]int{}
+	for rows.Next() {
+		g := domain.RoleGrant{ActorID: actorID}
+		var orgID string
+		var expiresAt, teamID sql.NullString
+		if err := rows.Scan(&g.ProjectID, &orgID, &g.RoleID, &expiresAt, &teamID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if expiresAt.Valid {
+			g.ExpiresAt = &expiresAt.String
+		}
+		if teamID.Valid {
+			g.TeamID = &teamID.String
+		}
+		i, ok := index[g.ProjectID]
+		if !ok {
+			i = len(memberships)
+			index[g.ProjectID] = i
+			memberships = append(memberships, domain.Membership{ProjectID: g.ProjectID, OrgID: orgID, Permissions: []string{}})
+		}
+		memberships[i].Roles = append(memberships[i].Roles, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(memberships) == 0 {
+		return memberships, err
+	}
+	perms, err := r.reader(ctx).QueryContext(ctx, actorGrants+`SELECT DISTINCT g.project_id, rp.permission_id FROM grants g
+JOIN role_permissions rp ON rp.role_id=g.role_id
+JOIN projects p ON p.id=g.project_id
+WHERE (?='' OR p.org_id=?) ORDER BY g.project_id, rp.permission_id`, scope...)
+	if err != nil {
+		return nil, err
+	}
+	defer perms.Close()
+	for perms.Next() {
+		var projectID, perm string
+		if err := perms.Scan(&projectID, &perm); err != nil {
+			return nil, err
+		}
+		m := &memberships[index[projectID]]
+		m.Permissions = append(m.Permissions, perm)
+	}
+	return memberships, perms.Err()
+}
+
 func (r Repo) RevokeRole(ctx context.Context, tx *sql.Tx, projectID, actorID, roleID string) error {
+	r.Cache.Invalidate(tx)
 	_, err := tx.ExecContext(ctx, `DELETE FROM actor_roles WHERE project_id=? AND actor_id=? AND role_id=?`, projectID, actorID, roleID)
 	return err
 }
 
 func (r Repo) AllowAttestationRole(ctx context.Context, tx *sql.Tx, projectID, kind, roleID string) error {
+	r.Cache.Invalidate(tx)
 	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO attestation_authorities(project_id, kind, role_id) VALUES (?,?,?)`, projectID, kind, roleID)
 	return err
 }
 
 func (r Repo) DenyAttestationRole(ctx context.Context, tx *sql.Tx, projectID, kind, roleID string) error {
+	r.Cache.Invalidate(tx)
 	_, err := tx.ExecContext(ctx, `DELETE FROM attestation_authorities WHERE project_id=? AND kind=? AND role_id=?`, projectID, kind, roleID)
 	return err
 }
@@ -72,7 +313,7 @@ func (r Repo) actorRoles(ctx context.Context, tx *sql.Tx, projectID, actorID str
 		}
 		roles = append(roles, role)
 	}
-	return roles, nil
+	return roles, rows.Err()
 }
 
 func (r Repo) rolePermissions(ctx context.Context, tx *sql.Tx, roleID string) ([]string, error) {
@@ -89,5 +330,22 @@ func (r Repo) rolePermissions(ctx context.Context, tx *sql.Tx, roleID string) ([
 		}
 		perms = append(perms, p)
 	}
-	return perms, nil
+	return perms, rows.Err()
+}
+
+// RolePermissionsTx lists the permissions granted to a role.
+func (r Repo) RolePermissionsTx(ctx context.Context, tx *sql.Tx, roleID string) ([]string, error) {
+	return r.rolePermissions(ctx, tx, roleID)
+}
+
+func (r Repo) RoleExistsTx(ctx context.Context, tx *sql.Tx, roleID string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM roles WHERE id=?`, roleID).Scan(&n)
+	return n > 0, err
+}
+
+func (r Repo) PermissionExistsTx(ctx context.Context, tx *sql.Tx, permID string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM permissions WHERE id=?`, permID).Scan(&n)
+	return n > 0, err
 }
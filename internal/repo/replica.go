@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// Replicas are read-only copies of the database, such as files kept current by
+// litestream restore, that serve reads of requests marked with PreferReplica. Replicas
+// lag the primary, so a read following a write may not see it yet.
+type Replicas struct {
+	dbs  []*sql.DB
+	next atomic.Uint64
+}
+
+// NewReplicas routes replica reads across dbs in turn. It returns nil without dbs.
+func NewReplicas(dbs ...*sql.DB) *Replicas {
+	if len(dbs) == 0 {
+		return nil
+	}
+	return &Replicas{dbs: dbs}
+}
+
+func (r *Replicas) pick() *sql.DB {
+	return r.dbs[(r.next.Add(1)-1)%uint64(len(r.dbs))]
+}
+
+type replicaKey struct{}
+
+// PreferReplica marks ctx as serving a read-only request, so repository reads outside a
+// transaction may go to a replica. Transactions, authentication lookups and project
+// configs always use the primary.
+func PreferReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// reader returns the database a non-transactional read should use.
+func (r Repo) reader(ctx context.Context) *sql.DB {
+	if r.Replicas != nil {
+		if ok, _ := ctx.Value(replicaKey{}).(bool); ok {
+			return r.Replicas.pick()
+		}
+	}
+	return r.DB
+}
@@ -9,27 +9,49 @@ import (
 	"strings"
 	"time"
 
+	"workline/internal/canon"
 	"workline/internal/config"
 	"workline/internal/domain"
 )
 
 type Repo struct {
 	DB *sql.DB
+	// Cache, when set, serves project configs and RBAC lookups from memory.
+	Cache *Cache
+	// Replicas, when set, serve reads of requests marked with PreferReplica.
+	Replicas *Replicas
+	// Environment is set on the project configs read, selecting their profile.
+	Environment string
 }
 
 var ErrNotFound = errors.New("not found")
 
-func scanProject(row *sql.Row) (domain.Project, error) {
+const projectColumns = `id,org_id,kind,status,description,created_at,parent_project_id,display_name,tags_json,metadata_json`
+
+func scanProject(row rowScanner) (domain.Project, error) {
 	var p domain.Project
-	var desc sql.NullString
-	err := row.Scan(&p.ID, &p.OrgID, &p.Kind, &p.Status, &desc, &p.CreatedAt)
+	var desc, parent, displayName, tags, metadata sql.NullString
+	err := row.Scan(&p.ID, &p.OrgID, &p.Kind, &p.Status, &desc, &p.CreatedAt, &parent, &displayName, &tags, &metadata)
 	if err == sql.ErrNoRows {
 		return p, ErrNotFound
 	}
-	if desc.Valid {
-		p.Description = desc.String
+	if err != nil {
+		return p, err
+	}
+	p.Description = desc.String
+	p.ParentProjectID = parent.String
+	p.DisplayName = displayName.String
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &p.Tags); err != nil {
+			return p, fmt.Errorf("project %s tags: %w", p.ID, err)
+		}
+	}
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &p.Metadata); err != nil {
+			return p, fmt.Errorf("project %s metadata: %w", p.ID, err)
+		}
 	}
-	return p, err
+	return p, nil
 }
 
 func (r Repo) InsertProject(ctx context.Context, p domain.Project) error {
@@ -39,23 +61,18 @@ func (r Repo) InsertProject(ctx context.Context, p domain.Project) error {
 }
 
 func (r Repo) GetProject(ctx context.Context, id string) (domain.Project, error) {
-	return scanProject(r.DB.QueryRowContext(ctx, `SELECT id,org_id,kind,status,COALESCE(description,'') AS description,created_at FROM projects WHERE id=?`, id))
+	return scanProject(r.reader(ctx).QueryRowContext(ctx, `SELECT `+projectColumns+` FROM projects WHERE id=?`, id))
+}
+
+func (r Repo) GetProjectTx(ctx context.Context, tx *sql.Tx, id string) (domain.Project, error) {
+	return scanProject(tx.QueryRowContext(ctx, `SELECT `+projectColumns+` FROM projects WHERE id=?`, id))
 }
 
 func (r Repo) SingleProject(ctx context.Context) (domain.Project, error) {
-	rows, err := r.DB.QueryContext(ctx, `SELECT id,org_id,kind,status,COALESCE(description,'') AS description,created_at FROM projects`)
+	projects, err := r.ListProjects(ctx)
 	if err != nil {
 		return domain.Project{}, err
 	}
-	defer rows.Close()
-	var projects []domain.Project
-	for rows.Next() {
-		var p domain.Project
-		if err := rows.Scan(&p.ID, &p.OrgID, &p.Kind, &p.Status, &p.Description, &p.CreatedAt); err != nil {
-			return domain.Project{}, err
-		}
-		projects = append(projects, p)
-	}
 	if len(projects) == 0 {
 		return domain.Project{}, ErrNotFound
 	}
@@ -66,52 +83,127 @@ func (r Repo) SingleProject(ctx context.Context) (domain.Project, error) {
 }
 
 func (r Repo) ListProjects(ctx context.Context) ([]domain.Project, error) {
-	rows, err := r.DB.QueryContext(ctx, `SELECT id,org_id,kind,status,COALESCE(description,'') AS description,created_at FROM projects ORDER BY created_at DESC`)
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+projectColumns+` FROM projects ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var res []domain.Project
 	for rows.Next() {
-		var p domain.Project
-		if err := rows.Scan(&p.ID, &p.OrgID, &p.Kind, &p.Status, &p.Description, &p.CreatedAt); err != nil {
+		p, err := scanProject(rows)
+		if err != nil {
 			return nil, err
 		}
 		res = append(res, p)
 	}
-	return res, nil
+	return res, rows.Err()
+}
+
+const iterationColumns = `id,project_id,goal,status,created_at,carried_out,carried_in,key_results_json,frozen_at,frozen_by`
+
+func scanIteration(row rowScanner) (domain.Iteration, error) {
+	var it domain.Iteration
+	var krs sql.NullString
+	err := row.Scan(&it.ID, &it.ProjectID, &it.Goal, &it.Status, &it.CreatedAt, &it.CarriedOut, &it.CarriedIn, &krs, &it.FrozenAt, &it.FrozenBy)
+	if err == sql.ErrNoRows {
+		return it, ErrNotFound
+	}
+	if err != nil {
+		return it, err
+	}
+	if krs.Valid {
+		if err := json.Unmarshal([]byte(krs.String), &it.KeyResults); err != nil {
+			return it, fmt.Errorf("iteration %s key results: %w", it.ID, err)
+		}
+	}
+	return it, nil
+}
+
+func keyResultsJSON(krs []domain.KeyResult) (any, error) {
+	if len(krs) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(krs)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
 }
 
 func (r Repo) InsertIteration(ctx context.Context, it domain.Iteration) error {
-	_, err := r.DB.ExecContext(ctx, `INSERT INTO iterations(id,org_id,project_id,goal,status,created_at) VALUES (?,?,?,?,?,?)`,
-		it.ID, it.OrgID, it.ProjectID, it.Goal, it.Status, it.CreatedAt)
+	krs, err := keyResultsJSON(it.KeyResults)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.ExecContext(ctx, `INSERT INTO iterations(id,org_id,project_id,goal,status,created_at,key_results_json) VALUES (?,?,?,?,?,?,?)`,
+		it.ID, it.OrgID, it.ProjectID, it.Goal, it.Status, it.CreatedAt, krs)
 	return err
 }
 
 func (r Repo) InsertIterationTx(ctx context.Context, tx *sql.Tx, it domain.Iteration) error {
-	_, err := tx.ExecContext(ctx, `INSERT INTO iterations(id,org_id,project_id,goal,status,created_at) VALUES (?,?,?,?,?,?)`,
-		it.ID, it.OrgID, it.ProjectID, it.Goal, it.Status, it.CreatedAt)
+	krs, err := keyResultsJSON(it.KeyResults)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO iterations(id,org_id,project_id,goal,status,created_at,key_results_json) VALUES (?,?,?,?,?,?,?)`,
+		it.ID, it.OrgID, it.ProjectID, it.Goal, it.Status, it.CreatedAt, krs)
 	return err
 }
 
-func (r Repo) UpdateProject(ctx context.Context, id, status string, description *string) error {
+// ProjectUpdate lists the project fields to change; nil fields are kept. Empty Tags or
+// Metadata clear them.
+type ProjectUpdate struct {
+	Status      string
+	Description *string
+	DisplayName *string
+	Tags        *[]string
+	Metadata    *map[string]any
+}
+
+func (r Repo) UpdateProjectTx(ctx context.Context, tx *sql.Tx, id string, u ProjectUpdate) error {
 	var (
 		fields []string
 		args   []any
 	)
-	if status != "" {
+	if u.Status != "" {
 		fields = append(fields, "status=?")
-		args = append(args, status)
+		args = append(args, u.Status)
 	}
-	if description != nil {
+	if u.Description != nil {
 		fields = append(fields, "description=?")
-		args = append(args, nullable(*description))
+		args = append(args, nullable(*u.Description))
+	}
+	if u.DisplayName != nil {
+		fields = append(fields, "display_name=?")
+		args = append(args, nullable(*u.DisplayName))
+	}
+	if u.Tags != nil {
+		fields = append(fields, "tags_json=?")
+		args = append(args, nil)
+		if len(*u.Tags) > 0 {
+			data, err := json.Marshal(*u.Tags)
+			if err != nil {
+				return err
+			}
+			args[len(args)-1] = string(data)
+		}
+	}
+	if u.Metadata != nil {
+		fields = append(fields, "metadata_json=?")
+		args = append(args, nil)
+		if len(*u.Metadata) > 0 {
+			data, err := json.Marshal(*u.Metadata)
+			if err != nil {
+				return err
+			}
+			args[len(args)-1] = string(data)
+		}
 	}
 	if len(fields) == 0 {
 		return nil
 	}
 	args = append(args, id)
-	res, err := r.DB.ExecContext(ctx, fmt.Sprintf(`UPDATE projects SET %s WHERE id=?`, strings.Join(fields, ",")), args...)
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE projects SET %s WHERE id=?`, strings.Join(fields, ",")), args...)
 	if err != nil {
 		return err
 	}
@@ -122,11 +214,71 @@ func (r Repo) UpdateProject(ctx context.Context, id, status string, description
 	return nil
 }
 
+// SetProjectParentTx moves a project under parentID, or to the top level when parentID is empty.
+func (r Repo) SetProjectParentTx(ctx context.Context, tx *sql.Tx, id, parentID string) error {
+	res, err := tx.ExecContext(ctx, `UPDATE projects SET parent_project_id=? WHERE id=?`, nullable(parentID), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ProjectAncestorsTx returns the chain of parents above a project, nearest first.
+func (r Repo) ProjectAncestorsTx(ctx context.Context, tx *sql.Tx, id string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `WITH RECURSIVE up(id, depth) AS (
+  SELECT parent_project_id, 1 FROM projects WHERE id=? AND parent_project_id IS NOT NULL
+  UNION ALL
+  SELECT p.parent_project_id, up.depth+1 FROM projects p JOIN up ON p.id=up.id WHERE p.parent_project_id IS NOT NULL AND up.depth < 64
+)
+SELECT id FROM up ORDER BY depth`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var pid string
+		if err := rows.Scan(&pid); err != nil {
+			return nil, err
+		}
+		ids = append(ids, pid)
+	}
+	return ids, rows.Err()
+}
+
+// ListDescendantProjects returns every project below id, breadth first.
+func (r Repo) ListDescendantProjects(ctx context.Context, id string) ([]domain.Project, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `WITH RECURSIVE down(id, depth) AS (
+  SELECT id, 1 FROM projects WHERE parent_project_id=?
+  UNION ALL
+  SELECT p.id, down.depth+1 FROM projects p JOIN down ON p.parent_project_id=down.id WHERE down.depth < 64
+)
+SELECT p.id,p.org_id,p.kind,p.status,COALESCE(p.description,''),p.created_at,COALESCE(p.parent_project_id,'')
+FROM down JOIN projects p ON p.id=down.id ORDER BY down.depth, p.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.Project
+	for rows.Next() {
+		var p domain.Project
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.Kind, &p.Status, &p.Description, &p.CreatedAt, &p.ParentProjectID); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
 func (r Repo) DeleteProject(ctx context.Context, id string) error {
 	res, err := r.DB.ExecContext(ctx, `DELETE FROM projects WHERE id=?`, id)
 	if err != nil {
 		return err
 	}
+	r.Cache.Invalidate(nil)
 	if n, _ := res.RowsAffected(); n == 0 {
 		return ErrNotFound
 	}
@@ -134,10 +286,12 @@ func (r Repo) DeleteProject(ctx context.Context, id string) error {
 }
 
 func (r Repo) UpsertProjectConfig(ctx context.Context, projectID string, cfg *config.Config) error {
+	defer r.Cache.Invalidate(nil)
 	return upsertProjectConfig(ctx, r.DB, nil, projectID, cfg)
 }
 
 func (r Repo) UpsertProjectConfigTx(ctx context.Context, tx *sql.Tx, projectID string, cfg *config.Config) error {
+	r.Cache.Invalidate(tx)
 	return upsertProjectConfig(ctx, nil, tx, projectID, cfg)
 }
 
@@ -165,12 +319,9 @@ ON CONFLICT(project_id) DO UPDATE SET config_json=excluded.config_json, updated_
 	return err
 }
 
+// GetProjectConfig returns a fresh copy of the stored config, which callers may modify.
 func (r Repo) GetProjectConfig(ctx context.Context, projectID string) (*config.Config, error) {
-	var payload string
-	err := r.DB.QueryRowContext(ctx, `SELECT config_json FROM project_configs WHERE project_id=?`, projectID).Scan(&payload)
-	if err == sql.ErrNoRows {
-		return nil, ErrNotFound
-	}
+	payload, err := r.projectConfigPayload(ctx, projectID)
 	if err != nil {
 		return nil, err
 	}
@@ -181,14 +332,41 @@ func (r Repo) GetProjectConfig(ctx context.Context, projectID string) (*config.C
 	if cfg.Project.ID == "" {
 		cfg.Project.ID = projectID
 	}
+	cfg.Environment = r.Environment
 	return &cfg, cfg.Validate()
 }
 
+func (r Repo) projectConfigPayload(ctx context.Context, projectID string) (string, error) {
+	c := r.Cache
+	var gen uint64
+	if c != nil {
+		if v, ok := lookup(c, cachedConfigs, projectID); ok {
+			return v, nil
+		}
+		gen = c.snapshot()
+	}
+	var payload string
+	err := r.DB.QueryRowContext(ctx, `SELECT config_json FROM project_configs WHERE project_id=?`, projectID).Scan(&payload)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if c != nil {
+		store(c, cachedConfigs, projectID, nil, gen, payload)
+	}
+	return payload, nil
+}
+
 func (r Repo) ListIterations(ctx context.Context, projectID string) ([]domain.Iteration, error) {
 	return r.ListIterationsWithCursor(ctx, projectID, 0, "", "")
 }
 
 func (r Repo) ListIterationsWithCursor(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Iteration, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
 	clauses := []string{"project_id=?"}
 	args := []any{projectID}
 	if cursorCreatedAt != "" && cursorID != "" {
@@ -196,35 +374,109 @@ func (r Repo) ListIterationsWithCursor(ctx context.Context, projectID string, li
 		args = append(args, cursorCreatedAt, cursorCreatedAt, cursorID)
 	}
 	where := "WHERE " + strings.Join(clauses, " AND ")
-	query := `SELECT id,project_id,goal,status,created_at FROM iterations ` + where + ` ORDER BY created_at DESC, id DESC`
+	query := `SELECT ` + iterationColumns + ` FROM iterations ` + where + ` ORDER BY created_at DESC, id DESC`
 	if limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, limit)
 	}
-	rows, err := r.DB.QueryContext(ctx, query, args...)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var res []domain.Iteration
 	for rows.Next() {
-		var it domain.Iteration
-		if err := rows.Scan(&it.ID, &it.ProjectID, &it.Goal, &it.Status, &it.CreatedAt); err != nil {
+		if err := chargeRow(ctx, "iterations"); err != nil {
+			return nil, err
+		}
+		it, err := scanIteration(rows)
+		if err != nil {
 			return nil, err
 		}
 		res = append(res, it)
 	}
-	return res, nil
+	return res, rows.Err()
 }
 
 func (r Repo) GetIteration(ctx context.Context, id string) (domain.Iteration, error) {
-	var it domain.Iteration
-	err := r.DB.QueryRowContext(ctx, `SELECT id,project_id,goal,status,created_at FROM iterations WHERE id=?`, id).
-		Scan(&it.ID, &it.ProjectID, &it.Goal, &it.Status, &it.CreatedAt)
-	if err == sql.ErrNoRows {
-		return it, ErrNotFound
+	return scanIteration(r.reader(ctx).QueryRowContext(ctx, `SELECT `+iterationColumns+` FROM iterations WHERE id=?`, id))
+}
+
+func (r Repo) GetIterationTx(ctx context.Context, tx *sql.Tx, id string) (domain.Iteration, error) {
+	return scanIteration(tx.QueryRowContext(ctx, `SELECT `+iterationColumns+` FROM iterations WHERE id=?`, id))
+}
+
+// SetIterationKeyResultsTx replaces the key results of an iteration.
+func (r Repo) SetIterationKeyResultsTx(ctx context.Context, tx *sql.Tx, id string, krs []domain.KeyResult) error {
+	value, err := keyResultsJSON(krs)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE iterations SET key_results_json=? WHERE id=?`, value, id)
+	if err != nil {
+		return err
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddIterationCarryOverTx adds to the carried-out and carried-in totals of an iteration.
+func (r Repo) AddIterationCarryOverTx(ctx context.Context, tx *sql.Tx, id string, out, in int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE iterations SET carried_out=carried_out+?, carried_in=carried_in+? WHERE id=?`, out, in, id)
+	return err
+}
+
+// ListUnfinishedIterationTaskIDsTx returns the iteration's tasks that are neither done nor
+// canceled, in rank order.
+func (r Repo) ListUnfinishedIterationTaskIDsTx(ctx context.Context, tx *sql.Tx, iterationID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM tasks WHERE iteration_id=? AND status NOT IN ('done','canceled') ORDER BY rank ASC, created_at ASC, id ASC`, iterationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateIterationGoalTx replaces the goal of an iteration.
+func (r Repo) UpdateIterationGoalTx(ctx context.Context, tx *sql.Tx, id, goal string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE iterations SET goal=? WHERE id=?`, goal, id)
+	return err
+}
+
+// ListOpenTaskIDsTx returns the project's tasks that are not done, rejected or canceled,
+// by ID.
+func (r Repo) ListOpenTaskIDsTx(ctx context.Context, tx *sql.Tx, projectID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM tasks WHERE project_id=? AND status NOT IN ('done','rejected','canceled') ORDER BY id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
-	return it, err
+	return ids, rows.Err()
+}
+
+// SetIterationFrozenTx freezes an iteration at by actor, or unfreezes it when at is nil.
+func (r Repo) SetIterationFrozenTx(ctx context.Context, tx *sql.Tx, id string, at, actorID *string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE iterations SET frozen_at=?, frozen_by=? WHERE id=?`, at, actorID, id)
+	return err
 }
 
 func (r Repo) UpdateIterationStatus(ctx context.Context, tx *sql.Tx, id, status string) error {
@@ -250,27 +502,28 @@ func nullableStringPtr(v *string) any {
 }
 
 func (r Repo) InsertTask(ctx context.Context, tx *sql.Tx, t domain.Task) error {
-	_, err := tx.ExecContext(ctx, `INSERT INTO tasks(id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at)
-VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+	_, err := tx.ExecContext(ctx, `INSERT INTO tasks(id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at,rank,content_hash,required_capabilities_json,custom_fields_json,defer_until,due_at,labels_json,risk_score,risk_level)
+VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		t.ID, t.ProjectID, nullableStringPtr(t.IterationID), nullableStringPtr(t.ParentID), t.Type, t.Title, nullable(t.Description),
 		t.Status, nullableStringPtr(t.AssigneeID), nullableStringPtr(t.WorkOutcomesJSON), nullableStringPtr(t.RequiredAttestationsJSON),
-		t.CreatedAt, t.UpdatedAt, nullableStringPtr(t.CompletedAt))
+		t.CreatedAt, t.UpdatedAt, nullableStringPtr(t.CompletedAt), t.Rank, canon.TaskHash(t), capabilitiesJSON(t.RequiredCapabilities), nullableStringPtr(t.CustomFieldsJSON), nullableStringPtr(t.DeferUntil), nullableStringPtr(t.DueAt), capabilitiesJSON(t.Labels), t.RiskScore, nullableStringPtr(t.RiskLevel))
 	return err
 }
 
 func (r Repo) UpdateTask(ctx context.Context, tx *sql.Tx, t domain.Task) error {
-	_, err := tx.ExecContext(ctx, `UPDATE tasks SET iteration_id=?, parent_id=?, type=?, title=?, description=?, status=?, assignee_id=?, work_outcomes_json=?, required_attestations_json=?, updated_at=?, completed_at=? WHERE id=?`,
+	_, err := tx.ExecContext(ctx, `UPDATE tasks SET iteration_id=?, parent_id=?, type=?, title=?, description=?, status=?, assignee_id=?, work_outcomes_json=?, required_attestations_json=?, required_capabilities_json=?, custom_fields_json=?, defer_until=?, due_at=?, labels_json=?, risk_score=?, risk_level=?, updated_at=?, completed_at=?, content_hash=? WHERE id=?`,
 		nullableStringPtr(t.IterationID), nullableStringPtr(t.ParentID), t.Type, t.Title, nullable(t.Description), t.Status,
-		nullableStringPtr(t.AssigneeID), nullableStringPtr(t.WorkOutcomesJSON), nullableStringPtr(t.RequiredAttestationsJSON),
-		t.UpdatedAt, nullableStringPtr(t.CompletedAt), t.ID)
+		nullableStringPtr(t.AssigneeID), nullableStringPtr(t.WorkOutcomesJSON), nullableStringPtr(t.RequiredAttestationsJSON), capabilitiesJSON(t.RequiredCapabilities), nullableStringPtr(t.CustomFieldsJSON), nullableStringPtr(t.DeferUntil), nullableStringPtr(t.DueAt),
+		capabilitiesJSON(t.Labels), t.RiskScore, nullableStringPtr(t.RiskLevel), t.UpdatedAt, nullableStringPtr(t.CompletedAt), canon.TaskHash(t), t.ID)
 	return err
 }
 
 func (r Repo) GetTask(ctx context.Context, id string) (domain.Task, error) {
 	var t domain.Task
-	var iterationID, parentID, assigneeID, workOutcomes, requiredAtt, completedAt, description sql.NullString
-	err := r.DB.QueryRowContext(ctx, `SELECT id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at FROM tasks WHERE id=?`, id).
-		Scan(&t.ID, &t.ProjectID, &iterationID, &parentID, &t.Type, &t.Title, &description, &t.Status, &assigneeID, &workOutcomes, &requiredAtt, &t.CreatedAt, &t.UpdatedAt, &completedAt)
+	var iterationID, parentID, assigneeID, workOutcomes, requiredAtt, completedAt, description, contentHash, requiredCaps, customFields, deferUntil, dueAt, labels, riskLevel sql.NullString
+	var riskScore sql.NullInt64
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at,rank,content_hash,required_capabilities_json,custom_fields_json,defer_until,due_at,labels_json,risk_score,risk_level FROM tasks WHERE id=?`, id).
+		Scan(&t.ID, &t.ProjectID, &iterationID, &parentID, &t.Type, &t.Title, &description, &t.Status, &assigneeID, &workOutcomes, &requiredAtt, &t.CreatedAt, &t.UpdatedAt, &completedAt, &t.Rank, &contentHash, &requiredCaps, &customFields, &deferUntil, &dueAt, &labels, &riskScore, &riskLevel)
 	if err == sql.ErrNoRows {
 		return t, ErrNotFound
 	}
@@ -298,6 +551,25 @@ func (r Repo) GetTask(ctx context.Context, id string) (domain.Task, error) {
 	if completedAt.Valid {
 		t.CompletedAt = &completedAt.String
 	}
+	t.RequiredCapabilities = parseCapabilities(requiredCaps)
+	if customFields.Valid {
+		t.CustomFieldsJSON = &customFields.String
+	}
+	if deferUntil.Valid {
+		t.DeferUntil = &deferUntil.String
+	}
+	if dueAt.Valid {
+		t.DueAt = &dueAt.String
+	}
+	t.Labels = parseCapabilities(labels)
+	if riskScore.Valid {
+		score := int(riskScore.Int64)
+		t.RiskScore = &score
+	}
+	if riskLevel.Valid {
+		t.RiskLevel = &riskLevel.String
+	}
+	t.ContentHash = contentHash.String
 	deps, err := r.ListTaskDependencies(ctx, t.ID)
 	if err != nil {
 		return t, err
@@ -308,9 +580,10 @@ func (r Repo) GetTask(ctx context.Context, id string) (domain.Task, error) {
 
 func (r Repo) GetTaskTx(ctx context.Context, tx *sql.Tx, id string) (domain.Task, error) {
 	var t domain.Task
-	var iterationID, parentID, assigneeID, workOutcomes, requiredAtt, completedAt, description sql.NullString
-	err := tx.QueryRowContext(ctx, `SELECT id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at FROM tasks WHERE id=?`, id).
-		Scan(&t.ID, &t.ProjectID, &iterationID, &parentID, &t.Type, &t.Title, &description, &t.Status, &assigneeID, &workOutcomes, &requiredAtt, &t.CreatedAt, &t.UpdatedAt, &completedAt)
+	var iterationID, parentID, assigneeID, workOutcomes, requiredAtt, completedAt, description, contentHash, requiredCaps, customFields, deferUntil, dueAt, labels, riskLevel sql.NullString
+	var riskScore sql.NullInt64
+	err := tx.QueryRowContext(ctx, `SELECT id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at,rank,content_hash,required_capabilities_json,custom_fields_json,defer_until,due_at,labels_json,risk_score,risk_level FROM tasks WHERE id=?`, id).
+		Scan(&t.ID, &t.ProjectID, &iterationID, &parentID, &t.Type, &t.Title, &description, &t.Status, &assigneeID, &workOutcomes, &requiredAtt, &t.CreatedAt, &t.UpdatedAt, &completedAt, &t.Rank, &contentHash, &requiredCaps, &customFields, &deferUntil, &dueAt, &labels, &riskScore, &riskLevel)
 	if err == sql.ErrNoRows {
 		return t, ErrNotFound
 	}
@@ -338,6 +611,25 @@ func (r Repo) GetTaskTx(ctx context.Context, tx *sql.Tx, id string) (domain.Task
 	if completedAt.Valid {
 		t.CompletedAt = &completedAt.String
 	}
+	t.RequiredCapabilities = parseCapabilities(requiredCaps)
+	if customFields.Valid {
+		t.CustomFieldsJSON = &customFields.String
+	}
+	if deferUntil.Valid {
+		t.DeferUntil = &deferUntil.String
+	}
+	if dueAt.Valid {
+		t.DueAt = &dueAt.String
+	}
+	t.Labels = parseCapabilities(labels)
+	if riskScore.Valid {
+		score := int(riskScore.Int64)
+		t.RiskScore = &score
+	}
+	if riskLevel.Valid {
+		t.RiskLevel = &riskLevel.String
+	}
+	t.ContentHash = contentHash.String
 	deps, err := r.ListTaskDependenciesTx(ctx, tx, t.ID)
 	if err != nil {
 		return t, err
@@ -347,17 +639,72 @@ func (r Repo) GetTaskTx(ctx context.Context, tx *sql.Tx, id string) (domain.Task
 }
 
 type TaskFilters struct {
-	ProjectID       string
-	Status          string
-	Iteration       string
-	Parent          string
-	AssigneeID      string
-	Limit           int
-	CursorCreatedAt string
-	CursorID        string
+	ProjectID  string
+	Status     string
+	Iteration  string
+	Parent     string
+	AssigneeID string
+	Type       string
+	// ReadyFor keeps planned tasks whose dependencies are done, that hold no active
+	// lease and are not deferred at Now, and that the actor may claim (unassigned or
+	// assigned to it).
+	ReadyFor string
+	Now      string
+	// CompletedFrom and CompletedTo bound completed_at to [CompletedFrom, CompletedTo).
+	CompletedFrom string
+	CompletedTo   string
+	// CustomFields must all match; see ParseCustomFieldFilter.
+	CustomFields []CustomFieldFilter
+	// RequiresAttestation keeps open tasks (not done, rejected or canceled) whose required
+	// attestations name this kind, alone, with a countersign or with a payload predicate.
+	RequiresAttestation string
+	Limit               int
+	CursorCreatedAt     string
+	CursorID            string
 }
 
 func (r Repo) ListTasks(ctx context.Context, f TaskFilters) ([]domain.Task, error) {
+	return r.listTasks(ctx, r.reader(ctx), f)
+}
+
+// ListTasksTx is ListTasks within tx, so that reads made alongside it see the same state.
+func (r Repo) ListTasksTx(ctx context.Context, tx *sql.Tx, f TaskFilters) ([]domain.Task, error) {
+	return r.listTasks(ctx, tx, f)
+}
+
+func (r Repo) listTasks(ctx context.Context, q queryer, f TaskFilters) ([]domain.Task, error) {
+	if err := checkQueryLimit(f.Limit); err != nil {
+		return nil, err
+	}
+	clauses, args, err := r.taskFilterClauses(ctx, q, f)
+	if err != nil {
+		return nil, err
+	}
+	if f.CursorCreatedAt != "" && f.CursorID != "" {
+		clauses = append(clauses, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, f.CursorCreatedAt, f.CursorCreatedAt, f.CursorID)
+	}
+	where := ""
+	if len(clauses) > 0 {
+		where = "WHERE " + strings.Join(clauses, " AND ")
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks ` + where + ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(ctx, rows)
+}
+
+// taskColumns are the task columns list reads select, in the order scanTasks reads them.
+const taskColumns = `id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at,rank,content_hash,required_capabilities_json,custom_fields_json,defer_until,due_at,labels_json,risk_score,risk_level`
+
+// taskFilterClauses builds the WHERE clauses of f over the tasks table, without paging.
+func (r Repo) taskFilterClauses(ctx context.Context, q queryer, f TaskFilters) ([]string, []any, error) {
 	var clauses []string
 	var args []any
 	if f.ProjectID != "" {
@@ -368,6 +715,10 @@ func (r Repo) ListTasks(ctx context.Context, f TaskFilters) ([]domain.Task, erro
 		clauses = append(clauses, "status=?")
 		args = append(args, f.Status)
 	}
+	if f.Type != "" {
+		clauses = append(clauses, "type=?")
+		args = append(args, f.Type)
+	}
 	if f.Iteration != "" {
 		clauses = append(clauses, "iteration_id=?")
 		args = append(args, f.Iteration)
@@ -380,29 +731,48 @@ func (r Repo) ListTasks(ctx context.Context, f TaskFilters) ([]domain.Task, erro
 		clauses = append(clauses, "assignee_id=?")
 		args = append(args, f.AssigneeID)
 	}
-	if f.CursorCreatedAt != "" && f.CursorID != "" {
-		clauses = append(clauses, "(created_at < ? OR (created_at = ? AND id < ?))")
-		args = append(args, f.CursorCreatedAt, f.CursorCreatedAt, f.CursorID)
-	}
-	where := ""
-	if len(clauses) > 0 {
-		where = "WHERE " + strings.Join(clauses, " AND ")
-	}
-	query := `SELECT id,project_id,iteration_id,parent_id,type,title,description,status,assignee_id,work_outcomes_json,required_attestations_json,created_at,updated_at,completed_at FROM tasks ` + where + ` ORDER BY created_at DESC, id DESC`
-	if f.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, f.Limit)
-	}
-	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if f.ReadyFor != "" {
+		clauses = append(clauses, `status='planned'`,
+			`(assignee_id IS NULL OR assignee_id=?)`,
+			`NOT EXISTS (SELECT 1 FROM task_deps d JOIN tasks dt ON dt.id=d.depends_on_task_id WHERE d.task_id=tasks.id AND dt.status<>'done')`,
+			`NOT EXISTS (SELECT 1 FROM leases l WHERE l.task_id=tasks.id AND l.expires_at>?)`,
+			`(defer_until IS NULL OR defer_until<=?)`,
+			`(NOT EXISTS (SELECT 1 FROM task_assignees a WHERE a.task_id=tasks.id AND a.role='driver') OR EXISTS (SELECT 1 FROM task_assignees a WHERE a.task_id=tasks.id AND a.role='driver' AND a.actor_id=?))`)
+		args = append(args, f.ReadyFor, f.Now, f.Now, f.ReadyFor)
+	}
+	if f.CompletedFrom != "" {
+		clauses = append(clauses, "completed_at>=?")
+		args = append(args, f.CompletedFrom)
+	}
+	if f.CompletedTo != "" {
+		clauses = append(clauses, "completed_at<?")
+		args = append(args, f.CompletedTo)
+	}
+	if f.RequiresAttestation != "" {
+		clauses = append(clauses, `status NOT IN ('done','rejected','canceled')`,
+			`EXISTS (SELECT 1 FROM json_each(tasks.required_attestations_json) j WHERE j.value=? OR substr(j.value,1,?) IN (?,?) OR substr(j.value,1,?)=?)`)
+		args = append(args, f.RequiresAttestation, len(f.RequiresAttestation)+1, f.RequiresAttestation+"+", f.RequiresAttestation+"[",
+			len(f.RequiresAttestation)+7, f.RequiresAttestation+" where ")
+	}
+	cfClauses, cfArgs, err := r.customFieldClauses(ctx, q, f.CustomFields)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return append(clauses, cfClauses...), append(args, cfArgs...), nil
+}
+
+// scanTasks reads rows of taskColumns, counting each against the request's row budget.
+func scanTasks(ctx context.Context, rows *sql.Rows) ([]domain.Task, error) {
 	defer rows.Close()
 	var res []domain.Task
 	for rows.Next() {
+		if err := chargeRow(ctx, "tasks"); err != nil {
+			return nil, err
+		}
 		var t domain.Task
-		var iterationID, parentID, assigneeID, workOutcomes, requiredAtt, completedAt, description sql.NullString
-		if err := rows.Scan(&t.ID, &t.ProjectID, &iterationID, &parentID, &t.Type, &t.Title, &description, &t.Status, &assigneeID, &workOutcomes, &requiredAtt, &t.CreatedAt, &t.UpdatedAt, &completedAt); err != nil {
+		var iterationID, parentID, assigneeID, workOutcomes, requiredAtt, completedAt, description, contentHash, requiredCaps, customFields, deferUntil, dueAt, labels, riskLevel sql.NullString
+		var riskScore sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.ProjectID, &iterationID, &parentID, &t.Type, &t.Title, &description, &t.Status, &assigneeID, &workOutcomes, &requiredAtt, &t.CreatedAt, &t.UpdatedAt, &completedAt, &t.Rank, &contentHash, &requiredCaps, &customFields, &deferUntil, &dueAt, &labels, &riskScore, &riskLevel); err != nil {
 			return nil, err
 		}
 		if description.Valid {
@@ -426,13 +796,32 @@ func (r Repo) ListTasks(ctx context.Context, f TaskFilters) ([]domain.Task, erro
 		if completedAt.Valid {
 			t.CompletedAt = &completedAt.String
 		}
+		t.RequiredCapabilities = parseCapabilities(requiredCaps)
+		if customFields.Valid {
+			t.CustomFieldsJSON = &customFields.String
+		}
+		if deferUntil.Valid {
+			t.DeferUntil = &deferUntil.String
+		}
+		if dueAt.Valid {
+			t.DueAt = &dueAt.String
+		}
+		t.Labels = parseCapabilities(labels)
+		if riskScore.Valid {
+			score := int(riskScore.Int64)
+			t.RiskScore = &score
+		}
+		if riskLevel.Valid {
+			t.RiskLevel = &riskLevel.String
+		}
+		t.ContentHash = contentHash.String
 		res = append(res, t)
 	}
-	return res, nil
+	return res, rows.Err()
 }
 
 func (r Repo) ListTaskDependencies(ctx context.Context, taskID string) ([]string, error) {
-	rows, err := r.DB.QueryContext(ctx, `SELECT depends_on_task_id FROM task_deps WHERE task_id=?`, taskID)
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT depends_on_task_id FROM task_deps WHERE task_id=?`, taskID)
 	if err != nil {
 		return nil, err
 	}
@@ -445,7 +834,7 @@ func (r Repo) ListTaskDependencies(ctx context.Context, taskID string) ([]string
 		}
 		deps = append(deps, dep)
 	}
-	return deps, nil
+	return deps, rows.Err()
 }
 
 func (r Repo) ListTaskDependenciesTx(ctx context.Context, tx *sql.Tx, taskID string) ([]string, error) {
@@ -462,7 +851,27 @@ func (r Repo) ListTaskDependenciesTx(ctx context.Context, tx *sql.Tx, taskID str
 		}
 		deps = append(deps, dep)
 	}
-	return deps, nil
+	return deps, rows.Err()
+}
+
+// ListProjectDependenciesTx maps each task of the project that has dependencies to the
+// tasks it depends on.
+func (r Repo) ListProjectDependenciesTx(ctx context.Context, tx *sql.Tx, projectID string) (map[string][]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT d.task_id, d.depends_on_task_id FROM task_deps d JOIN tasks t ON t.id=d.task_id
+WHERE t.project_id=? ORDER BY d.task_id, d.depends_on_task_id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deps := map[string][]string{}
+	for rows.Next() {
+		var taskID, dep string
+		if err := rows.Scan(&taskID, &dep); err != nil {
+			return nil, err
+		}
+		deps[taskID] = append(deps[taskID], dep)
+	}
+	return deps, rows.Err()
 }
 
 func (r Repo) AddDependencies(ctx context.Context, tx *sql.Tx, taskID string, deps []string) error {
@@ -483,8 +892,50 @@ func (r Repo) RemoveDependencies(ctx context.Context, tx *sql.Tx, taskID string,
 	return nil
 }
 
+// ListUnblockedDependentsTx returns the unfinished tasks that depend on depID and have no
+// other dependency left that is not done.
+func (r Repo) ListUnblockedDependentsTx(ctx context.Context, tx *sql.Tx, depID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT t.id FROM task_deps d JOIN tasks t ON t.id=d.task_id
+WHERE d.depends_on_task_id=? AND t.status NOT IN ('done','canceled')
+AND NOT EXISTS (SELECT 1 FROM task_deps o JOIN tasks ot ON ot.id=o.depends_on_task_id WHERE o.task_id=t.id AND ot.status<>'done')
+ORDER BY t.id`, depID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListOpenDependentsTx returns the unfinished tasks that depend on depID.
+func (r Repo) ListOpenDependentsTx(ctx context.Context, tx *sql.Tx, depID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT t.id FROM task_deps d JOIN tasks t ON t.id=d.task_id
+WHERE d.depends_on_task_id=? AND t.status NOT IN ('done','canceled')
+ORDER BY t.id`, depID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 func (r Repo) ListChildren(ctx context.Context, taskID string) ([]string, error) {
-	rows, err := r.DB.QueryContext(ctx, `SELECT id FROM tasks WHERE parent_id=?`, taskID)
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT id FROM tasks WHERE parent_id=?`, taskID)
 	if err != nil {
 		return nil, err
 	}
@@ -497,7 +948,7 @@ func (r Repo) ListChildren(ctx context.Context, taskID string) ([]string, error)
 		}
 		ids = append(ids, id)
 	}
-	return ids, nil
+	return ids, rows.Err()
 }
 
 func (r Repo) ListChildrenTx(ctx context.Context, tx *sql.Tx, taskID string) ([]string, error) {
@@ -514,12 +965,74 @@ func (r Repo) ListChildrenTx(ctx context.Context, tx *sql.Tx, taskID string) ([]
 		}
 		ids = append(ids, id)
 	}
-	return ids, nil
+	return ids, rows.Err()
 }
 
-func (r Repo) UpsertLease(ctx context.Context, tx *sql.Tx, lease domain.Lease) error {
-	_, err := tx.ExecContext(ctx, `INSERT INTO leases(task_id,owner_id,acquired_at,expires_at) VALUES (?,?,?,?)
-ON CONFLICT(task_id) DO UPDATE SET owner_id=excluded.owner_id, acquired_at=excluded.acquired_at, expires_at=excluded.expires_at`, lease.TaskID, lease.OwnerID, lease.AcquiredAt, lease.ExpiresAt)
+// NextSequenceTx increments and returns the per-project counter for entity.
+func (r Repo) NextSequenceTx(ctx context.Context, tx *sql.Tx, projectID, entity string) (int64, error) {
+	var next int64
+	err := tx.QueryRowContext(ctx, `INSERT INTO id_sequences(project_id, entity, last_value) VALUES(?,?,1)
+		ON CONFLICT(project_id, entity) DO UPDATE SET last_value=last_value+1
+		RETURNING last_value`, projectID, entity).Scan(&next)
+	return next, err
+}
+
+// IDExistsTx reports whether table already holds a row with id.
+func (r Repo) IDExistsTx(ctx context.Context, tx *sql.Tx, table, id string) (bool, error) {
+	switch table {
+	case "tasks", "attestations":
+	default:
+		return false, fmt.Errorf("unsupported id table %s", table)
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE id=?`, id).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// NextTaskRankTx returns the rank placing a new task after its existing siblings.
+func (r Repo) NextTaskRankTx(ctx context.Context, tx *sql.Tx, projectID string, parentID, iterationID *string) (int, error) {
+	var maxRank sql.NullInt64
+	err := tx.QueryRowContext(ctx, `SELECT MAX(rank) FROM tasks WHERE project_id=? AND parent_id IS ? AND iteration_id IS ?`,
+		projectID, nullableStringPtr(parentID), nullableStringPtr(iterationID)).Scan(&maxRank)
+	if err != nil {
+		return 0, err
+	}
+	if !maxRank.Valid {
+		return 1, nil
+	}
+	return int(maxRank.Int64) + 1, nil
+}
+
+// ListSiblingIDsTx returns task ids sharing the given parent and iteration, in rank order.
+func (r Repo) ListSiblingIDsTx(ctx context.Context, tx *sql.Tx, projectID string, parentID, iterationID *string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM tasks WHERE project_id=? AND parent_id IS ? AND iteration_id IS ? ORDER BY rank ASC, created_at ASC, id ASC`,
+		projectID, nullableStringPtr(parentID), nullableStringPtr(iterationID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r Repo) SetTaskRank(ctx context.Context, tx *sql.Tx, taskID string, rank int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE tasks SET rank=? WHERE id=?`, rank, taskID)
+	return err
+}
+
+func (r Repo) UpsertLease(ctx context.Context, tx *sql.Tx, lease domain.Lease) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO leases(task_id,owner_id,acquired_at,expires_at,pending_owner_id,plan_json) VALUES (?,?,?,?,?,?)
+ON CONFLICT(task_id) DO UPDATE SET owner_id=excluded.owner_id, acquired_at=excluded.acquired_at, expires_at=excluded.expires_at, pending_owner_id=excluded.pending_owner_id, plan_json=excluded.plan_json`,
+		lease.TaskID, lease.OwnerID, lease.AcquiredAt, lease.ExpiresAt, nullableStringPtr(lease.PendingOwnerID), leasePlanJSON(lease.Plan))
 	return err
 }
 
@@ -529,9 +1042,7 @@ func (r Repo) DeleteLease(ctx context.Context, tx *sql.Tx, taskID string) error
 }
 
 func (r Repo) GetLeaseTx(ctx context.Context, tx *sql.Tx, taskID string) (domain.Lease, error) {
-	var l domain.Lease
-	err := tx.QueryRowContext(ctx, `SELECT task_id,owner_id,acquired_at,expires_at FROM leases WHERE task_id=?`, taskID).
-		Scan(&l.TaskID, &l.OwnerID, &l.AcquiredAt, &l.ExpiresAt)
+	l, err := scanLease(tx.QueryRowContext(ctx, `SELECT `+leaseColumns+` FROM leases l WHERE task_id=?`, taskID))
 	if err == sql.ErrNoRows {
 		return l, ErrNotFound
 	}
@@ -539,8 +1050,151 @@ func (r Repo) GetLeaseTx(ctx context.Context, tx *sql.Tx, taskID string) (domain
 }
 
 func (r Repo) GetLease(ctx context.Context, taskID string) (domain.Lease, error) {
+	l, err := scanLease(r.reader(ctx).QueryRowContext(ctx, `SELECT `+leaseColumns+` FROM leases l WHERE task_id=?`, taskID))
+	if err == sql.ErrNoRows {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+// ActiveLeasesTx returns the leases on unfinished tasks of a project still active at now,
+// soonest expiry first. A non-empty ownerID keeps that owner's leases and a non-empty until
+// those expiring by then.
+func (r Repo) ActiveLeasesTx(ctx context.Context, tx *sql.Tx, projectID, ownerID, now, until string) ([]domain.Lease, error) {
+	query := `SELECT ` + leaseColumns + ` FROM leases l JOIN tasks t ON t.id=l.task_id
+WHERE t.project_id=? AND t.status NOT IN ('done','canceled','rejected') AND l.expires_at>?`
+	args := []any{projectID, now}
+	if ownerID != "" {
+		query += ` AND l.owner_id=?`
+		args = append(args, ownerID)
+	}
+	if until != "" {
+		query += ` AND l.expires_at<=?`
+		args = append(args, until)
+	}
+	rows, err := tx.QueryContext(ctx, query+` ORDER BY l.expires_at ASC, l.task_id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.Lease
+	for rows.Next() {
+		l, err := scanLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+const leaseColumns = `l.task_id,l.owner_id,l.acquired_at,l.expires_at,l.pending_owner_id,l.plan_json`
+
+func scanLease(row rowScanner) (domain.Lease, error) {
 	var l domain.Lease
-	err := r.DB.QueryRowContext(ctx, `SELECT task_id,owner_id,acquired_at,expires_at FROM leases WHERE task_id=?`, taskID).
+	var plan sql.NullString
+	err := row.Scan(&l.TaskID, &l.OwnerID, &l.AcquiredAt, &l.ExpiresAt, &l.PendingOwnerID, &plan)
+	l.Plan = parseLeasePlan(plan)
+	return l, err
+}
+
+func leasePlanJSON(p *domain.LeasePlan) any {
+	if p == nil {
+		return nil
+	}
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func parseLeasePlan(raw sql.NullString) *domain.LeasePlan {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var p domain.LeasePlan
+	if err := json.Unmarshal([]byte(raw.String), &p); err != nil {
+		return nil
+	}
+	return &p
+}
+
+// EnqueueLeaseWaiterTx queues actorID for the task lease. An actor already queued keeps
+// its place and only updates the requested lease duration and plan.
+func (r Repo) EnqueueLeaseWaiterTx(ctx context.Context, tx *sql.Tx, w domain.LeaseWaiter) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO lease_waiters(task_id,actor_id,lease_seconds,enqueued_at,plan_json) VALUES (?,?,?,?,?)
+ON CONFLICT(task_id,actor_id) DO UPDATE SET lease_seconds=excluded.lease_seconds, plan_json=excluded.plan_json`,
+		w.TaskID, w.ActorID, w.LeaseSeconds, w.EnqueuedAt, leasePlanJSON(w.Plan))
+	return err
+}
+
+// DeleteLeaseWaiterTx removes actorID from the task queue and reports whether it was queued.
+func (r Repo) DeleteLeaseWaiterTx(ctx context.Context, tx *sql.Tx, taskID, actorID string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM lease_waiters WHERE task_id=? AND actor_id=?`, taskID, actorID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListLeaseWaitersTx returns the task queue in grant order.
+func (r Repo) ListLeaseWaitersTx(ctx context.Context, tx *sql.Tx, taskID string) ([]domain.LeaseWaiter, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT task_id,actor_id,lease_seconds,enqueued_at,plan_json FROM lease_waiters WHERE task_id=? ORDER BY enqueued_at, rowid`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.LeaseWaiter
+	for rows.Next() {
+		var w domain.LeaseWaiter
+		var plan sql.NullString
+		if err := rows.Scan(&w.TaskID, &w.ActorID, &w.LeaseSeconds, &w.EnqueuedAt, &plan); err != nil {
+			return nil, err
+		}
+		w.Plan = parseLeasePlan(plan)
+		w.Position = len(res) + 1
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
+// VacantQueuedLeasesTx lists tasks with queued actors whose lease is released or expired at now.
+func (r Repo) VacantQueuedLeasesTx(ctx context.Context, tx *sql.Tx, now string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+SELECT DISTINCT w.task_id
+FROM lease_waiters w
+LEFT JOIN leases l ON l.task_id=w.task_id
+WHERE l.task_id IS NULL OR l.expires_at <= ?
+ORDER BY w.task_id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r Repo) UpsertReviewLease(ctx context.Context, tx *sql.Tx, lease domain.Lease) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO review_leases(task_id,owner_id,acquired_at,expires_at) VALUES (?,?,?,?)
+ON CONFLICT(task_id) DO UPDATE SET owner_id=excluded.owner_id, acquired_at=excluded.acquired_at, expires_at=excluded.expires_at`,
+		lease.TaskID, lease.OwnerID, lease.AcquiredAt, lease.ExpiresAt)
+	return err
+}
+
+func (r Repo) DeleteReviewLease(ctx context.Context, tx *sql.Tx, taskID string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM review_leases WHERE task_id=?`, taskID)
+	return err
+}
+
+func (r Repo) GetReviewLeaseTx(ctx context.Context, tx *sql.Tx, taskID string) (domain.Lease, error) {
+	var l domain.Lease
+	err := tx.QueryRowContext(ctx, `SELECT task_id,owner_id,acquired_at,expires_at FROM review_leases WHERE task_id=?`, taskID).
 		Scan(&l.TaskID, &l.OwnerID, &l.AcquiredAt, &l.ExpiresAt)
 	if err == sql.ErrNoRows {
 		return l, ErrNotFound
@@ -548,29 +1202,452 @@ func (r Repo) GetLease(ctx context.Context, taskID string) (domain.Lease, error)
 	return l, err
 }
 
+func (r Repo) InsertTaskAssigneeTx(ctx context.Context, tx *sql.Tx, a domain.TaskAssignee) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO task_assignees(task_id,actor_id,role,assigned_at) VALUES (?,?,?,?)`,
+		a.TaskID, a.ActorID, a.Role, a.AssignedAt)
+	return err
+}
+
+// DeleteTaskAssigneeTx removes one assignment and reports whether it existed.
+func (r Repo) DeleteTaskAssigneeTx(ctx context.Context, tx *sql.Tx, taskID, actorID, role string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM task_assignees WHERE task_id=? AND actor_id=? AND role=?`, taskID, actorID, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r Repo) ListTaskAssignees(ctx context.Context, taskID string) ([]domain.TaskAssignee, error) {
+	return listTaskAssignees(ctx, r.reader(ctx), nil, taskID)
+}
+
+func (r Repo) ListTaskAssigneesTx(ctx context.Context, tx *sql.Tx, taskID string) ([]domain.TaskAssignee, error) {
+	return listTaskAssignees(ctx, nil, tx, taskID)
+}
+
+func listTaskAssignees(ctx context.Context, db *sql.DB, tx *sql.Tx, taskID string) ([]domain.TaskAssignee, error) {
+	query := `SELECT task_id,actor_id,role,assigned_at FROM task_assignees WHERE task_id=? ORDER BY role, assigned_at, actor_id`
+	var rows *sql.Rows
+	var err error
+	if tx != nil {
+		rows, err = tx.QueryContext(ctx, query, taskID)
+	} else {
+		rows, err = db.QueryContext(ctx, query, taskID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.TaskAssignee
+	for rows.Next() {
+		var a domain.TaskAssignee
+		if err := rows.Scan(&a.TaskID, &a.ActorID, &a.Role, &a.AssignedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) InsertTaskHandoffTx(ctx context.Context, tx *sql.Tx, h domain.TaskHandoff) (int64, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO task_handoffs(task_id,project_id,from_assignee_id,to_assignee_id,actor_id,note,created_at) VALUES (?,?,?,?,?,?,?)`,
+		h.TaskID, h.ProjectID, nullableStringPtr(h.FromAssigneeID), nullableStringPtr(h.ToAssigneeID), h.ActorID, nullable(h.Note), h.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListTaskHandoffs returns a task's assignee changes, oldest first.
+func (r Repo) ListTaskHandoffs(ctx context.Context, taskID string) ([]domain.TaskHandoff, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT id,task_id,project_id,from_assignee_id,to_assignee_id,actor_id,note,created_at FROM task_handoffs WHERE task_id=? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.TaskHandoff
+	for rows.Next() {
+		var h domain.TaskHandoff
+		var from, to, note sql.NullString
+		if err := rows.Scan(&h.ID, &h.TaskID, &h.ProjectID, &from, &to, &h.ActorID, &note, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			h.FromAssigneeID = &from.String
+		}
+		if to.Valid {
+			h.ToAssigneeID = &to.String
+		}
+		h.Note = note.String
+		res = append(res, h)
+	}
+	return res, rows.Err()
+}
+
+const taskCompletionColumns = `id,task_id,project_id,status,submitted_by,submitted_at,decided_by,decided_at,reason`
+
+func scanTaskCompletion(row rowScanner) (domain.TaskCompletion, error) {
+	var c domain.TaskCompletion
+	var decidedBy, decidedAt, reason sql.NullString
+	if err := row.Scan(&c.ID, &c.TaskID, &c.ProjectID, &c.Status, &c.SubmittedBy, &c.SubmittedAt, &decidedBy, &decidedAt, &reason); err != nil {
+		return c, err
+	}
+	if decidedBy.Valid {
+		c.DecidedBy = &decidedBy.String
+	}
+	if decidedAt.Valid {
+		c.DecidedAt = &decidedAt.String
+	}
+	c.Reason = reason.String
+	return c, nil
+}
+
+func (r Repo) InsertTaskCompletionTx(ctx context.Context, tx *sql.Tx, c domain.TaskCompletion) (int64, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO task_completions(task_id,project_id,status,submitted_by,submitted_at) VALUES (?,?,?,?,?)`,
+		c.TaskID, c.ProjectID, c.Status, c.SubmittedBy, c.SubmittedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// PendingTaskCompletionTx returns the completion of a task awaiting approval.
+func (r Repo) PendingTaskCompletionTx(ctx context.Context, tx *sql.Tx, taskID string) (domain.TaskCompletion, error) {
+	c, err := scanTaskCompletion(tx.QueryRowContext(ctx, `SELECT `+taskCompletionColumns+` FROM task_completions WHERE task_id=? AND status='pending'`, taskID))
+	if err == sql.ErrNoRows {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+// DecideTaskCompletionTx records how a pending completion was decided.
+func (r Repo) DecideTaskCompletionTx(ctx context.Context, tx *sql.Tx, c domain.TaskCompletion) error {
+	_, err := tx.ExecContext(ctx, `UPDATE task_completions SET status=?, decided_by=?, decided_at=?, reason=? WHERE id=? AND status='pending'`,
+		c.Status, nullableStringPtr(c.DecidedBy), nullableStringPtr(c.DecidedAt), nullable(c.Reason), c.ID)
+	return err
+}
+
+// ListTaskCompletions returns a task's submitted completions, oldest first.
+func (r Repo) ListTaskCompletions(ctx context.Context, taskID string) ([]domain.TaskCompletion, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+taskCompletionColumns+` FROM task_completions WHERE task_id=? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.TaskCompletion
+	for rows.Next() {
+		c, err := scanTaskCompletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+const taskLinkColumns = `id,task_id,project_id,kind,target,head_ref,ci_status,ci_url,review_status,provider,status_updated_at,created_by,created_at`
+
+func scanTaskLink(row rowScanner) (domain.TaskLink, error) {
+	var l domain.TaskLink
+	var headRef, ciStatus, ciURL, reviewStatus, provider, updatedAt sql.NullString
+	if err := row.Scan(&l.ID, &l.TaskID, &l.ProjectID, &l.Kind, &l.Target, &headRef, &ciStatus, &ciURL, &reviewStatus, &provider, &updatedAt, &l.CreatedBy, &l.CreatedAt); err != nil {
+		return l, err
+	}
+	l.HeadRef = headRef.String
+	l.CIStatus = ciStatus.String
+	l.CIURL = ciURL.String
+	l.ReviewStatus = reviewStatus.String
+	l.Provider = provider.String
+	l.StatusUpdatedAt = updatedAt.String
+	return l, nil
+}
+
+func (r Repo) InsertTaskLinkTx(ctx context.Context, tx *sql.Tx, l domain.TaskLink) (int64, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO task_links(task_id,project_id,kind,target,head_ref,created_by,created_at) VALUES (?,?,?,?,?,?,?)`,
+		l.TaskID, l.ProjectID, l.Kind, l.Target, nullable(l.HeadRef), l.CreatedBy, l.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r Repo) GetTaskLinkTx(ctx context.Context, tx *sql.Tx, id int64) (domain.TaskLink, error) {
+	l, err := scanTaskLink(tx.QueryRowContext(ctx, `SELECT `+taskLinkColumns+` FROM task_links WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+func (r Repo) DeleteTaskLinkTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM task_links WHERE id=?`, id)
+	return err
+}
+
+// UpdateTaskLinkStatusTx records the status reported for a link.
+func (r Repo) UpdateTaskLinkStatusTx(ctx context.Context, tx *sql.Tx, l domain.TaskLink) error {
+	_, err := tx.ExecContext(ctx, `UPDATE task_links SET head_ref=?, ci_status=?, ci_url=?, review_status=?, provider=?, status_updated_at=? WHERE id=?`,
+		nullable(l.HeadRef), nullable(l.CIStatus), nullable(l.CIURL), nullable(l.ReviewStatus), nullable(l.Provider), nullable(l.StatusUpdatedAt), l.ID)
+	return err
+}
+
+// ListTaskLinks returns a task's links, oldest first.
+func (r Repo) ListTaskLinks(ctx context.Context, taskID string) ([]domain.TaskLink, error) {
+	return scanTaskLinks(r.reader(ctx).QueryContext(ctx, `SELECT `+taskLinkColumns+` FROM task_links WHERE task_id=? ORDER BY id`, taskID))
+}
+
+// ListProjectTaskLinksTx returns the links of every task in a project, oldest first.
+func (r Repo) ListProjectTaskLinksTx(ctx context.Context, tx *sql.Tx, projectID string) ([]domain.TaskLink, error) {
+	return scanTaskLinks(tx.QueryContext(ctx, `SELECT `+taskLinkColumns+` FROM task_links WHERE project_id=? ORDER BY id`, projectID))
+}
+
+func scanTaskLinks(rows *sql.Rows, err error) ([]domain.TaskLink, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.TaskLink
+	for rows.Next() {
+		l, err := scanTaskLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+// BlobReferenced reports whether an attestation in the project offloaded its payload to digest.
+func (r Repo) BlobReferenced(ctx context.Context, projectID, digest string) (bool, error) {
+	var n int
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM attestations WHERE project_id=? AND CASE WHEN json_valid(payload_json) AND json_type(payload_json) = 'object' THEN json_extract(payload_json, '$."$blob"') END = ?`, projectID, digest).Scan(&n)
+	return n > 0, err
+}
+
+func (r Repo) InsertArtifactTx(ctx context.Context, tx *sql.Tx, a domain.Artifact) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO artifacts(id,project_id,name,media_type,size,digest,uploaded_by,created_at) VALUES (?,?,?,?,?,?,?,?)`,
+		a.ID, a.ProjectID, a.Name, a.MediaType, a.Size, a.Digest, a.UploadedBy, a.CreatedAt)
+	return err
+}
+
+const artifactColumns = `id,project_id,name,media_type,size,digest,uploaded_by,created_at`
+
+func scanArtifact(row rowScanner) (domain.Artifact, error) {
+	var a domain.Artifact
+	err := row.Scan(&a.ID, &a.ProjectID, &a.Name, &a.MediaType, &a.Size, &a.Digest, &a.UploadedBy, &a.CreatedAt)
+	return a, err
+}
+
+func (r Repo) GetArtifact(ctx context.Context, id string) (domain.Artifact, error) {
+	a, err := scanArtifact(r.reader(ctx).QueryRowContext(ctx, `SELECT `+artifactColumns+` FROM artifacts WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+func (r Repo) GetArtifactTx(ctx context.Context, tx *sql.Tx, id string) (domain.Artifact, error) {
+	a, err := scanArtifact(tx.QueryRowContext(ctx, `SELECT `+artifactColumns+` FROM artifacts WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// ListArtifacts returns a project's artifacts newest first, after the (createdAt, id) cursor when set.
+func (r Repo) ListArtifacts(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Artifact, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + artifactColumns + ` FROM artifacts WHERE project_id=?`
+	args := []any{projectID}
+	if cursorCreatedAt != "" && cursorID != "" {
+		query += ` AND (created_at < ? OR (created_at = ? AND id < ?))`
+		args = append(args, cursorCreatedAt, cursorCreatedAt, cursorID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.Artifact
+	for rows.Next() {
+		if err := chargeRow(ctx, "artifacts"); err != nil {
+			return nil, err
+		}
+		a, err := scanArtifact(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
 func (r Repo) InsertAttestation(ctx context.Context, att domain.Attestation) error {
-	_, err := r.DB.ExecContext(ctx, `INSERT INTO attestations(id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json) VALUES (?,?,?,?,?,?,?,?)`,
-		att.ID, att.ProjectID, att.EntityKind, att.EntityID, att.Kind, att.ActorID, att.TS, nullable(att.PayloadJSON))
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO attestations(id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json,content_hash) VALUES (?,?,?,?,?,?,?,?,?)`,
+		att.ID, att.ProjectID, att.EntityKind, att.EntityID, att.Kind, att.ActorID, att.TS, nullable(att.PayloadJSON), canon.AttestationHash(att))
 	return err
 }
 
 func (r Repo) InsertAttestationTx(ctx context.Context, tx *sql.Tx, att domain.Attestation) error {
-	_, err := tx.ExecContext(ctx, `INSERT INTO attestations(id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json) VALUES (?,?,?,?,?,?,?,?)`,
-		att.ID, att.ProjectID, att.EntityKind, att.EntityID, att.Kind, att.ActorID, att.TS, nullable(att.PayloadJSON))
+	_, err := tx.ExecContext(ctx, `INSERT INTO attestations(id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json,content_hash) VALUES (?,?,?,?,?,?,?,?,?)`,
+		att.ID, att.ProjectID, att.EntityKind, att.EntityID, att.Kind, att.ActorID, att.TS, nullable(att.PayloadJSON), canon.AttestationHash(att))
+	return err
+}
+
+func (r Repo) GetAttestationTx(ctx context.Context, tx *sql.Tx, id string) (domain.Attestation, error) {
+	var a domain.Attestation
+	var payload, contentHash sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json,content_hash FROM attestations WHERE id=?`, id).
+		Scan(&a.ID, &a.ProjectID, &a.EntityKind, &a.EntityID, &a.Kind, &a.ActorID, &a.TS, &payload, &contentHash)
+	if err == sql.ErrNoRows {
+		return a, ErrNotFound
+	}
+	if err != nil {
+		return a, err
+	}
+	if payload.Valid {
+		a.PayloadJSON = payload.String
+	}
+	a.ContentHash = contentHash.String
+	return a, nil
+}
+
+// ListTaskAttestationKindsTx returns the attestation kinds recorded on a task and,
+// per kind, the kinds of attestations countersigning them.
+func (r Repo) ListTaskAttestationKindsTx(ctx context.Context, tx *sql.Tx, taskID string) (map[string][]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT a.kind, c.kind FROM attestations a
+		LEFT JOIN attestations c ON c.entity_kind='attestation' AND c.entity_id=a.id
+		WHERE a.entity_kind='task' AND a.entity_id=?`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[string][]string{}
+	for rows.Next() {
+		var kind string
+		var countersign sql.NullString
+		if err := rows.Scan(&kind, &countersign); err != nil {
+			return nil, err
+		}
+		if _, ok := res[kind]; !ok {
+			res[kind] = nil
+		}
+		if countersign.Valid {
+			res[kind] = append(res[kind], countersign.String)
+		}
+	}
+	return res, rows.Err()
+}
+
+// CountersignedAttestation is an attestation with the kinds of the attestations countersigning it.
+type CountersignedAttestation struct {
+	domain.Attestation
+	Countersigns []string
+}
+
+// ListTaskAttestationsTx returns the task's attestations of the given kinds, newest first,
+// with payloads and countersigning kinds, for requirements that look into payloads.
+func (r Repo) ListTaskAttestationsTx(ctx context.Context, tx *sql.Tx, taskID string, kinds []string) ([]CountersignedAttestation, error) {
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	args := []any{taskID}
+	for _, k := range kinds {
+		args = append(args, k)
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT a.id,a.project_id,a.entity_kind,a.entity_id,a.kind,a.actor_id,a.ts,a.payload_json,a.content_hash,c.kind FROM attestations a
+		LEFT JOIN attestations c ON c.entity_kind='attestation' AND c.entity_id=a.id
+		WHERE a.entity_kind='task' AND a.entity_id=? AND a.kind IN (`+strings.TrimSuffix(strings.Repeat("?,", len(kinds)), ",")+`)
+		ORDER BY a.ts DESC, a.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []CountersignedAttestation
+	for rows.Next() {
+		var a domain.Attestation
+		var payload, contentHash, countersign sql.NullString
+		if err := rows.Scan(&a.ID, &a.ProjectID, &a.EntityKind, &a.EntityID, &a.Kind, &a.ActorID, &a.TS, &payload, &contentHash, &countersign); err != nil {
+			return nil, err
+		}
+		if n := len(res); n == 0 || res[n-1].ID != a.ID {
+			if payload.Valid {
+				a.PayloadJSON = payload.String
+			}
+			a.ContentHash = contentHash.String
+			res = append(res, CountersignedAttestation{Attestation: a})
+		}
+		if countersign.Valid {
+			res[len(res)-1].Countersigns = append(res[len(res)-1].Countersigns, countersign.String)
+		}
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) InsertWaiverTx(ctx context.Context, tx *sql.Tx, w domain.Waiver) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO validation_waivers(id,project_id,task_id,kind,justification,actor_id,expires_at,created_at) VALUES (?,?,?,?,?,?,?,?)`,
+		w.ID, w.ProjectID, w.TaskID, w.Kind, w.Justification, w.ActorID, w.ExpiresAt, w.CreatedAt)
 	return err
 }
 
+// ListWaivers returns every waiver recorded for a task, newest first, including expired ones.
+func (r Repo) ListWaivers(ctx context.Context, taskID string) ([]domain.Waiver, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT id,project_id,task_id,kind,justification,actor_id,expires_at,created_at FROM validation_waivers WHERE task_id=? ORDER BY created_at DESC, id DESC`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWaivers(rows)
+}
+
+// ListActiveWaiversTx returns waivers for a task that have not expired at now.
+func (r Repo) ListActiveWaiversTx(ctx context.Context, tx *sql.Tx, taskID, now string) ([]domain.Waiver, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id,project_id,task_id,kind,justification,actor_id,expires_at,created_at FROM validation_waivers WHERE task_id=? AND expires_at>? ORDER BY created_at DESC, id DESC`, taskID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWaivers(rows)
+}
+
+func scanWaivers(rows *sql.Rows) ([]domain.Waiver, error) {
+	var res []domain.Waiver
+	for rows.Next() {
+		var w domain.Waiver
+		if err := rows.Scan(&w.ID, &w.ProjectID, &w.TaskID, &w.Kind, &w.Justification, &w.ActorID, &w.ExpiresAt, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
 type AttestationFilters struct {
 	EntityKind string
 	EntityID   string
 	Kind       string
-	ProjectID  string
-	Limit      int
-	CursorTS   string
-	CursorID   string
+	// Kinds matches any of the listed kinds, in addition to Kind.
+	Kinds     []string
+	ProjectID string
+	// Before keeps attestations recorded strictly before this timestamp.
+	Before   string
+	Limit    int
+	CursorTS string
+	CursorID string
 }
 
 func (r Repo) ListAttestations(ctx context.Context, f AttestationFilters) ([]domain.Attestation, error) {
+	if err := checkQueryLimit(f.Limit); err != nil {
+		return nil, err
+	}
 	var clauses []string
 	var args []any
 	if f.ProjectID != "" {
@@ -589,6 +1666,16 @@ func (r Repo) ListAttestations(ctx context.Context, f AttestationFilters) ([]dom
 		clauses = append(clauses, "kind=?")
 		args = append(args, f.Kind)
 	}
+	if len(f.Kinds) > 0 {
+		clauses = append(clauses, "kind IN ("+strings.TrimSuffix(strings.Repeat("?,", len(f.Kinds)), ",")+")")
+		for _, k := range f.Kinds {
+			args = append(args, k)
+		}
+	}
+	if f.Before != "" {
+		clauses = append(clauses, "ts<?")
+		args = append(args, f.Before)
+	}
 	if f.CursorTS != "" && f.CursorID != "" {
 		clauses = append(clauses, "(ts < ? OR (ts = ? AND id < ?))")
 		args = append(args, f.CursorTS, f.CursorTS, f.CursorID)
@@ -597,33 +1684,37 @@ func (r Repo) ListAttestations(ctx context.Context, f AttestationFilters) ([]dom
 	if len(clauses) > 0 {
 		where = "WHERE " + strings.Join(clauses, " AND ")
 	}
-	query := `SELECT id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json FROM attestations ` + where + ` ORDER BY ts DESC, id DESC`
+	query := `SELECT id,project_id,entity_kind,entity_id,kind,actor_id,ts,payload_json,content_hash FROM attestations ` + where + ` ORDER BY ts DESC, id DESC`
 	if f.Limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, f.Limit)
 	}
-	rows, err := r.DB.QueryContext(ctx, query, args...)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var res []domain.Attestation
 	for rows.Next() {
+		if err := chargeRow(ctx, "attestations"); err != nil {
+			return nil, err
+		}
 		var a domain.Attestation
-		var payload sql.NullString
-		if err := rows.Scan(&a.ID, &a.ProjectID, &a.EntityKind, &a.EntityID, &a.Kind, &a.ActorID, &a.TS, &payload); err != nil {
+		var payload, contentHash sql.NullString
+		if err := rows.Scan(&a.ID, &a.ProjectID, &a.EntityKind, &a.EntityID, &a.Kind, &a.ActorID, &a.TS, &payload, &contentHash); err != nil {
 			return nil, err
 		}
 		if payload.Valid {
 			a.PayloadJSON = payload.String
 		}
+		a.ContentHash = contentHash.String
 		res = append(res, a)
 	}
-	return res, nil
+	return res, rows.Err()
 }
 
 func (r Repo) CountTasksByStatus(ctx context.Context, projectID string) (map[string]int, error) {
-	rows, err := r.DB.QueryContext(ctx, `SELECT status, count(*) FROM tasks WHERE project_id=? GROUP BY status`, projectID)
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT status, count(*) FROM tasks WHERE project_id=? GROUP BY status`, projectID)
 	if err != nil {
 		return nil, err
 	}
@@ -637,14 +1728,13 @@ func (r Repo) CountTasksByStatus(ctx context.Context, projectID string) (map[str
 		}
 		res[status] = count
 	}
-	return res, nil
+	return res, rows.Err()
 }
 
 func (r Repo) LatestRunningIteration(ctx context.Context, projectID string) (*domain.Iteration, error) {
-	row := r.DB.QueryRowContext(ctx, `SELECT id,project_id,goal,status,created_at FROM iterations WHERE project_id=? AND status='running' ORDER BY created_at DESC LIMIT 1`, projectID)
-	var it domain.Iteration
-	err := row.Scan(&it.ID, &it.ProjectID, &it.Goal, &it.Status, &it.CreatedAt)
-	if err == sql.ErrNoRows {
+	row := r.reader(ctx).QueryRowContext(ctx, `SELECT `+iterationColumns+` FROM iterations WHERE project_id=? AND status='running' ORDER BY created_at DESC LIMIT 1`, projectID)
+	it, err := scanIteration(row)
+	if err == ErrNotFound {
 		return nil, nil
 	}
 	if err != nil {
@@ -654,10 +1744,47 @@ func (r Repo) LatestRunningIteration(ctx context.Context, projectID string) (*do
 }
 
 func (r Repo) LatestEvents(ctx context.Context, limit int, projectID, evtType, entityKind, entityID string) ([]domain.Event, error) {
-	return r.LatestEventsFrom(ctx, limit, 0, projectID, evtType, entityKind, entityID)
+	return r.LatestEventsFrom(ctx, limit, 0, projectID, evtType, entityKind, entityID, "")
 }
 
-func (r Repo) LatestEventsFrom(ctx context.Context, limit int, cursor int64, projectID, evtType, entityKind, entityID string) ([]domain.Event, error) {
+func (r Repo) LatestEventsFrom(ctx context.Context, limit int, cursor int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	clauses, args := eventFilters(projectID, evtType, entityKind, entityID, requestID)
+	if cursor > 0 {
+		clauses = append(clauses, "id<?")
+		args = append(args, cursor)
+	}
+	where := "WHERE " + strings.Join(clauses, " AND ")
+	query := fmt.Sprintf(`SELECT %s FROM events %s ORDER BY id DESC LIMIT ?`, eventColumns, where)
+	args = append(args, limit)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+// EventsSince returns the events matching the filters recorded after afterID, oldest first.
+func (r Repo) EventsSince(ctx context.Context, limit int, afterID int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	clauses, args := eventFilters(projectID, evtType, entityKind, entityID, requestID)
+	clauses = append(clauses, "id>?")
+	args = append(args, afterID, limit)
+	query := fmt.Sprintf(`SELECT %s FROM events WHERE %s ORDER BY id LIMIT ?`, eventColumns, strings.Join(clauses, " AND "))
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+func eventFilters(projectID, evtType, entityKind, entityID, requestID string) ([]string, []any) {
 	clauses := []string{"1=1"}
 	var args []any
 	if projectID != "" {
@@ -676,31 +1803,337 @@ func (r Repo) LatestEventsFrom(ctx context.Context, limit int, cursor int64, pro
 		clauses = append(clauses, "entity_id=?")
 		args = append(args, entityID)
 	}
+	if requestID != "" {
+		clauses = append(clauses, "request_id=?")
+		args = append(args, requestID)
+	}
+	return clauses, args
+}
+
+// EntityEvents returns every event recorded against the given entities, oldest first.
+func (r Repo) EntityEvents(ctx context.Context, projectID, entityKind string, entityIDs []string) ([]domain.Event, error) {
+	if len(entityIDs) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(entityIDs)), ",")
+	args := []any{projectID, entityKind}
+	for _, id := range entityIDs {
+		args = append(args, id)
+	}
+	query := fmt.Sprintf(`SELECT %s FROM events WHERE project_id=? AND entity_kind=? AND entity_id IN (%s) ORDER BY id`, eventColumns, placeholders)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+// ActorEventsFrom returns events authored by actorID, newest first, starting at cursor (inclusive)
+// and optionally bounded below by since.
+func (r Repo) ActorEventsFrom(ctx context.Context, limit int, cursor int64, projectID, actorID, since string) ([]domain.Event, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	clauses := []string{"project_id=?", "actor_id=?"}
+	args := []any{projectID, actorID}
+	if since != "" {
+		clauses = append(clauses, "ts>=?")
+		args = append(args, since)
+	}
 	if cursor > 0 {
-		clauses = append(clauses, "id<?")
+		clauses = append(clauses, "id<=?")
 		args = append(args, cursor)
 	}
-	where := "WHERE " + strings.Join(clauses, " AND ")
-	query := fmt.Sprintf(`SELECT id,ts,type,project_id,entity_kind,entity_id,actor_id,payload_json FROM events %s ORDER BY id DESC LIMIT ?`, where)
+	query := fmt.Sprintf(`SELECT %s FROM events WHERE %s ORDER BY id DESC LIMIT ?`, eventColumns, strings.Join(clauses, " AND "))
 	args = append(args, limit)
-	rows, err := r.DB.QueryContext(ctx, query, args...)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+// CountActorEventsByType tallies events authored by actorID per event type.
+func (r Repo) CountActorEventsByType(ctx context.Context, projectID, actorID, since string) (map[string]int, error) {
+	query := `SELECT type, COUNT(*) FROM events WHERE project_id=? AND actor_id=?`
+	args := []any{projectID, actorID}
+	if since != "" {
+		query += ` AND ts>=?`
+		args = append(args, since)
+	}
+	query += ` GROUP BY type`
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var typ string
+		var n int
+		if err := rows.Scan(&typ, &n); err != nil {
+			return nil, err
+		}
+		counts[typ] = n
+	}
+	return counts, rows.Err()
+}
+
+// CountActorCompletions counts the tasks actorID completed: task.done events, and
+// task.updated events that moved a task to done.
+func (r Repo) CountActorCompletions(ctx context.Context, projectID, actorID, since string) (int, error) {
+	query := `SELECT COUNT(*) FROM events WHERE project_id=? AND actor_id=?
+  AND (type='task.done' OR (type='task.updated' AND json_extract(payload_json,'$.to_status')='done' AND json_extract(payload_json,'$.from_status')<>'done'))`
+	args := []any{projectID, actorID}
+	if since != "" {
+		query += ` AND ts>=?`
+		args = append(args, since)
+	}
+	var n int
+	err := r.reader(ctx).QueryRowContext(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+// EventsAfter returns project events with id greater than afterID, oldest first.
+func (r Repo) EventsAfter(ctx context.Context, projectID string, afterID int64, limit int) ([]domain.Event, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+eventColumns+` FROM events WHERE project_id=? AND id>? ORDER BY id ASC LIMIT ?`, projectID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+func (r Repo) MaxEventID(ctx context.Context, projectID string) (int64, error) {
+	var id sql.NullInt64
+	if err := r.reader(ctx).QueryRowContext(ctx, `SELECT MAX(id) FROM events WHERE project_id=?`, projectID).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id.Int64, nil
+}
+
+// CountEventsBetween counts events of evtType with from <= ts <= to.
+func (r Repo) CountEventsBetween(ctx context.Context, projectID, evtType, from, to string) (int, error) {
+	var n int
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM events WHERE project_id=? AND type=? AND ts>=? AND ts<=?`, projectID, evtType, from, to).Scan(&n)
+	return n, err
+}
+
+// EventBuckets maps each aggregation bucket to the length of the RFC3339 timestamp prefix
+// shared by events in the same bucket.
+var EventBuckets = map[string]int{"hour": len("2006-01-02T15"), "day": len("2006-01-02")}
+
+// EventBucketCount is the number of events of one type within one bucket, identified by
+// its timestamp prefix.
+type EventBucketCount struct {
+	Prefix string
+	Type   string
+	Count  int
+}
+
+// CountEventsByBucket tallies project events with from <= ts < to per bucket and type,
+// oldest bucket first. Types optionally narrows the event types counted.
+func (r Repo) CountEventsByBucket(ctx context.Context, projectID, bucket string, types []string, from, to string) ([]EventBucketCount, error) {
+	size, ok := EventBuckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("invalid bucket %q", bucket)
+	}
+	clauses := []string{"project_id=?", "ts>=?", "ts<?"}
+	args := []any{size, projectID, from, to}
+	if len(types) > 0 {
+		clauses = append(clauses, "type IN ("+strings.TrimSuffix(strings.Repeat("?,", len(types)), ",")+")")
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	query := fmt.Sprintf(`SELECT substr(ts, 1, ?) AS bucket, type, COUNT(*) FROM events WHERE %s GROUP BY bucket, type ORDER BY bucket, type`, strings.Join(clauses, " AND "))
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []EventBucketCount
+	for rows.Next() {
+		var c EventBucketCount
+		if err := rows.Scan(&c.Prefix, &c.Type, &c.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) GetNotificationCursor(ctx context.Context, projectID, channel string) (int64, error) {
+	var id int64
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT last_event_id FROM notification_cursors WHERE project_id=? AND channel=?`, projectID, channel).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
+
+func (r Repo) SetNotificationCursor(ctx context.Context, projectID, channel string, lastEventID int64, now string) error {
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO notification_cursors(project_id,channel,last_event_id,updated_at) VALUES (?,?,?,?)
+ON CONFLICT(project_id,channel) DO UPDATE SET last_event_id=excluded.last_event_id, updated_at=excluded.updated_at`, projectID, channel, lastEventID, now)
+	return err
+}
+
+// ComputeDailyStats derives the snapshot for day (YYYY-MM-DD, UTC) from current task and attestation state.
+func (r Repo) ComputeDailyStats(ctx context.Context, projectID, day string) (domain.StatsSnapshot, error) {
+	s := domain.StatsSnapshot{ProjectID: projectID, Day: day}
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT
+  COALESCE(SUM(CASE WHEN status NOT IN ('done','canceled','rejected') THEN 1 ELSE 0 END),0),
+  COALESCE(SUM(CASE WHEN status='done' THEN 1 ELSE 0 END),0),
+  COALESCE(SUM(CASE WHEN substr(completed_at,1,10)=? THEN 1 ELSE 0 END),0)
+FROM tasks WHERE project_id=?`, day, projectID).Scan(&s.TasksOpen, &s.TasksDone, &s.TasksCompleted)
+	if err != nil {
+		return s, err
+	}
+	if err := r.reader(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM attestations WHERE project_id=? AND substr(ts,1,10)=?`, projectID, day).Scan(&s.AttestationsIssued); err != nil {
+		return s, err
+	}
+	var lead sql.NullFloat64
+	if err := r.reader(ctx).QueryRowContext(ctx, `SELECT AVG((julianday(completed_at)-julianday(created_at))*86400) FROM tasks WHERE project_id=? AND completed_at IS NOT NULL AND substr(completed_at,1,10)=?`, projectID, day).Scan(&lead); err != nil {
+		return s, err
+	}
+	if lead.Valid {
+		v := lead.Float64
+		s.LeadTimeSeconds = &v
+	}
+	return s, nil
+}
+
+func (r Repo) UpsertStatsSnapshot(ctx context.Context, s domain.StatsSnapshot) error {
+	var lead any
+	if s.LeadTimeSeconds != nil {
+		lead = *s.LeadTimeSeconds
+	}
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO project_stats_daily(project_id,day,tasks_open,tasks_done,tasks_completed,attestations_issued,lead_time_seconds,recorded_at)
+VALUES (?,?,?,?,?,?,?,?)
+ON CONFLICT(project_id,day) DO UPDATE SET tasks_open=excluded.tasks_open, tasks_done=excluded.tasks_done, tasks_completed=excluded.tasks_completed,
+  attestations_issued=excluded.attestations_issued, lead_time_seconds=excluded.lead_time_seconds, recorded_at=excluded.recorded_at`,
+		s.ProjectID, s.Day, s.TasksOpen, s.TasksDone, s.TasksCompleted, s.AttestationsIssued, lead, s.RecordedAt)
+	return err
+}
+
+// ListStatsSnapshots returns snapshots with from <= day <= to, oldest first.
+func (r Repo) ListStatsSnapshots(ctx context.Context, projectID, from, to string) ([]domain.StatsSnapshot, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT project_id,day,tasks_open,tasks_done,tasks_completed,attestations_issued,lead_time_seconds,recorded_at
+FROM project_stats_daily WHERE project_id=? AND day>=? AND day<=? ORDER BY day ASC`, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.StatsSnapshot
+	for rows.Next() {
+		var s domain.StatsSnapshot
+		var lead sql.NullFloat64
+		if err := rows.Scan(&s.ProjectID, &s.Day, &s.TasksOpen, &s.TasksDone, &s.TasksCompleted, &s.AttestationsIssued, &lead, &s.RecordedAt); err != nil {
+			return nil, err
+		}
+		if lead.Valid {
+			v := lead.Float64
+			s.LeadTimeSeconds = &v
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// UpsertDigestTx stores the digest of a project day, replacing one generated earlier.
+func (r Repo) UpsertDigestTx(ctx context.Context, tx *sql.Tx, projectID, day, generatedAt, bodyJSON string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO digests(project_id,day,generated_at,body_json) VALUES (?,?,?,?)
+ON CONFLICT(project_id,day) DO UPDATE SET generated_at=excluded.generated_at, body_json=excluded.body_json`, projectID, day, generatedAt, bodyJSON)
+	return err
+}
+
+// GetDigest returns the stored body of the project's digest for day.
+func (r Repo) GetDigest(ctx context.Context, projectID, day string) (string, error) {
+	var body string
+	err := r.reader(ctx).QueryRowContext(ctx, `SELECT body_json FROM digests WHERE project_id=? AND day=?`, projectID, day).Scan(&body)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return body, err
+}
+
+// ListDigests returns the bodies of digests with from <= day <= to, newest first.
+func (r Repo) ListDigests(ctx context.Context, projectID, from, to string) ([]string, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT body_json FROM digests WHERE project_id=? AND day>=? AND day<=? ORDER BY day DESC`, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []string
+	for rows.Next() {
+		if err := chargeRow(ctx, "digests"); err != nil {
+			return nil, err
+		}
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		res = append(res, body)
+	}
+	return res, rows.Err()
+}
+
+// EventsBetween returns project events of the given types with from <= ts < to, oldest first.
+func (r Repo) EventsBetween(ctx context.Context, projectID string, types []string, from, to string) ([]domain.Event, error) {
+	args := []any{projectID, from, to}
+	for _, t := range types {
+		args = append(args, t)
+	}
+	query := fmt.Sprintf(`SELECT %s FROM events WHERE project_id=? AND ts>=? AND ts<? AND type IN (%s) ORDER BY id ASC`, eventColumns, strings.TrimSuffix(strings.Repeat("?,", len(types)), ","))
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+// StuckLeases returns the leases on unfinished tasks of a project that expired before now
+// or were acquired before acquiredBefore, oldest first.
+func (r Repo) StuckLeases(ctx context.Context, projectID, now, acquiredBefore string) ([]domain.Lease, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+leaseColumns+` FROM leases l JOIN tasks t ON t.id=l.task_id
+WHERE t.project_id=? AND t.status NOT IN ('done','canceled','rejected') AND (l.expires_at<? OR l.acquired_at<?) ORDER BY l.acquired_at ASC, l.task_id ASC`, projectID, now, acquiredBefore)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	var res []domain.Lease
+	for rows.Next() {
+		l, err := scanLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+const eventColumns = `id,ts,type,project_id,entity_kind,entity_id,actor_id,payload_json,prev_hash,this_hash,request_id`
+
+func scanEvents(rows *sql.Rows) ([]domain.Event, error) {
 	var res []domain.Event
 	for rows.Next() {
 		var e domain.Event
-		var payload sql.NullString
-		if err := rows.Scan(&e.ID, &e.TS, &e.Type, &e.ProjectID, &e.EntityKind, &e.EntityID, &e.ActorID, &payload); err != nil {
+		var payload, prevHash, thisHash, requestID sql.NullString
+		if err := rows.Scan(&e.ID, &e.TS, &e.Type, &e.ProjectID, &e.EntityKind, &e.EntityID, &e.ActorID, &payload, &prevHash, &thisHash, &requestID); err != nil {
 			return nil, err
 		}
+		e.RequestID = requestID.String
 		if payload.Valid {
 			e.Payload = payload.String
 		}
+		e.PrevHash = prevHash.String
+		e.ThisHash = thisHash.String
 		res = append(res, e)
 	}
-	return res, nil
+	return res, rows.Err()
 }
 
 func nullableIntPtr(v *int) any {
@@ -711,13 +2144,138 @@ func nullableIntPtr(v *int) any {
 }
 
 func (r Repo) InsertDecision(ctx context.Context, d domain.Decision) error {
-	_, err := r.DB.ExecContext(ctx, `INSERT INTO decisions(id,project_id,title,context_json,decision,rationale_json,alternatives_json,decider_id,created_at) VALUES (?,?,?,?,?,?,?,?,?)`,
-		d.ID, d.ProjectID, d.Title, nullable(d.ContextJSON), d.Decision, nullable(d.RationaleJSON), nullable(d.AlternativesJSON), d.DeciderID, d.CreatedAt)
+	d.Status = decisionStatus(d.Status)
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO decisions(id,project_id,title,context_json,decision,rationale_json,alternatives_json,decider_id,status,created_at,content_hash) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		d.ID, d.ProjectID, d.Title, nullable(d.ContextJSON), d.Decision, nullable(d.RationaleJSON), nullable(d.AlternativesJSON), d.DeciderID, d.Status, d.CreatedAt, canon.DecisionHash(d))
 	return err
 }
 
 func (r Repo) InsertDecisionTx(ctx context.Context, tx *sql.Tx, d domain.Decision) error {
-	_, err := tx.ExecContext(ctx, `INSERT INTO decisions(id,project_id,title,context_json,decision,rationale_json,alternatives_json,decider_id,created_at) VALUES (?,?,?,?,?,?,?,?,?)`,
-		d.ID, d.ProjectID, d.Title, nullable(d.ContextJSON), d.Decision, nullable(d.RationaleJSON), nullable(d.AlternativesJSON), d.DeciderID, d.CreatedAt)
+	d.Status = decisionStatus(d.Status)
+	_, err := tx.ExecContext(ctx, `INSERT INTO decisions(id,project_id,title,context_json,decision,rationale_json,alternatives_json,decider_id,status,created_at,content_hash) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		d.ID, d.ProjectID, d.Title, nullable(d.ContextJSON), d.Decision, nullable(d.RationaleJSON), nullable(d.AlternativesJSON), d.DeciderID, d.Status, d.CreatedAt, canon.DecisionHash(d))
 	return err
 }
+
+func decisionStatus(status string) string {
+	if status == "" {
+		return "accepted"
+	}
+	return status
+}
+
+const decisionColumns = `id,org_id,project_id,title,context_json,decision,rationale_json,alternatives_json,decider_id,status,created_at,content_hash`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryer runs reads against the database, a replica or a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func scanDecision(row rowScanner) (domain.Decision, error) {
+	var d domain.Decision
+	var projectID, contextJSON, rationale, alternatives, contentHash sql.NullString
+	if err := row.Scan(&d.ID, &d.OrgID, &projectID, &d.Title, &contextJSON, &d.Decision, &rationale, &alternatives, &d.DeciderID, &d.Status, &d.CreatedAt, &contentHash); err != nil {
+		return d, err
+	}
+	d.ProjectID = projectID.String
+	d.ContextJSON = contextJSON.String
+	d.RationaleJSON = rationale.String
+	d.AlternativesJSON = alternatives.String
+	d.ContentHash = contentHash.String
+	return d, nil
+}
+
+func (r Repo) GetDecision(ctx context.Context, id string) (domain.Decision, error) {
+	d, err := scanDecision(r.reader(ctx).QueryRowContext(ctx, `SELECT `+decisionColumns+` FROM decisions WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return d, ErrNotFound
+	}
+	return d, err
+}
+
+type DecisionFilters struct {
+	ProjectID       string
+	DeciderID       string
+	Status          string
+	CreatedFrom     string
+	CreatedTo       string
+	Query           string
+	Limit           int
+	CursorCreatedAt string
+	CursorID        string
+}
+
+// ListDecisions returns decisions newest first. Query matches title, decision text,
+// context, rationale and alternatives case-insensitively.
+func (r Repo) ListDecisions(ctx context.Context, f DecisionFilters) ([]domain.Decision, error) {
+	if err := checkQueryLimit(f.Limit); err != nil {
+		return nil, err
+	}
+	var clauses []string
+	var args []any
+	if f.ProjectID != "" {
+		clauses = append(clauses, "project_id=?")
+		args = append(args, f.ProjectID)
+	}
+	if f.DeciderID != "" {
+		clauses = append(clauses, "decider_id=?")
+		args = append(args, f.DeciderID)
+	}
+	if f.Status != "" {
+		clauses = append(clauses, "status=?")
+		args = append(args, f.Status)
+	}
+	if f.CreatedFrom != "" {
+		clauses = append(clauses, "created_at>=?")
+		args = append(args, f.CreatedFrom)
+	}
+	if f.CreatedTo != "" {
+		clauses = append(clauses, "created_at<?")
+		args = append(args, f.CreatedTo)
+	}
+	if f.Query != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(f.Query)) + "%"
+		var fields []string
+		for _, col := range []string{"title", "decision", "context_json", "rationale_json", "alternatives_json"} {
+			fields = append(fields, "lower(coalesce("+col+",'')) LIKE ? ESCAPE '\\'")
+			args = append(args, pattern)
+		}
+		clauses = append(clauses, "("+strings.Join(fields, " OR ")+")")
+	}
+	if f.CursorCreatedAt != "" && f.CursorID != "" {
+		clauses = append(clauses, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, f.CursorCreatedAt, f.CursorCreatedAt, f.CursorID)
+	}
+	where := ""
+	if len(clauses) > 0 {
+		where = "WHERE " + strings.Join(clauses, " AND ")
+	}
+	query := `SELECT ` + decisionColumns + ` FROM decisions ` + where + ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.Decision
+	for rows.Next() {
+		if err := chargeRow(ctx, "decisions"); err != nil {
+			return nil, err
+		}
+		d, err := scanDecision(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
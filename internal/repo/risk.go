@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetTaskRiskTx stores the risk score and level of a task; a nil score clears both. Risk is
+// derived from the task, so its content hash is left alone.
+func (r Repo) SetTaskRiskTx(ctx context.Context, tx *sql.Tx, taskID string, score *int, level *string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE tasks SET risk_score=?, risk_level=? WHERE id=?`, score, nullableStringPtr(level), taskID)
+	return err
+}
+
+// TaskAttesterRolesTx returns the roles held at now, directly or through a team, by the
+// actors of the task's attestations, ordered by role.
+func (r Repo) TaskAttesterRolesTx(ctx context.Context, tx *sql.Tx, projectID, taskID, now string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, activeGrants+`SELECT DISTINCT role_id FROM grants
+		WHERE actor_id IN (SELECT actor_id FROM attestations WHERE entity_kind='task' AND entity_id=?)
+		ORDER BY role_id`, projectID, now, projectID, now, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
@@ -0,0 +1,43 @@
+package repo
+
+import "context"
+
+// ListOpenDueDates maps each unfinished task of the project that has a deadline to its
+// due_at.
+func (r Repo) ListOpenDueDates(ctx context.Context, projectID string) (map[string]string, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT id, due_at FROM tasks
+WHERE project_id=? AND due_at IS NOT NULL AND status NOT IN ('done','rejected','canceled')`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	due := map[string]string{}
+	for rows.Next() {
+		var id, at string
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		due[id] = at
+	}
+	return due, rows.Err()
+}
+
+// ListOpenDependents maps each task of the project to the unfinished tasks that depend
+// on it.
+func (r Repo) ListOpenDependents(ctx context.Context, projectID string) (map[string][]string, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT d.depends_on_task_id, d.task_id FROM task_deps d JOIN tasks t ON t.id=d.task_id
+WHERE t.project_id=? AND t.status NOT IN ('done','rejected','canceled') ORDER BY d.depends_on_task_id, d.task_id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dependents := map[string][]string{}
+	for rows.Next() {
+		var dep, taskID string
+		if err := rows.Scan(&dep, &taskID); err != nil {
+			return nil, err
+		}
+		dependents[dep] = append(dependents[dep], taskID)
+	}
+	return dependents, rows.Err()
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"workline/internal/domain"
+)
+
+const projectSecretColumns = `id,project_id,kind,name,version,value,key_id,public_key,created_by,created_at,expires_at,revoked_at`
+
+// liveSecret keeps versions that are neither revoked nor expired at the bound time.
+const liveSecret = `revoked_at IS NULL AND (expires_at IS NULL OR expires_at>?)`
+
+func scanProjectSecret(row rowScanner) (domain.ProjectSecret, error) {
+	var s domain.ProjectSecret
+	var publicKey sql.NullString
+	err := row.Scan(&s.ID, &s.ProjectID, &s.Kind, &s.Name, &s.Version, &s.Value, &s.KeyID, &publicKey, &s.CreatedBy, &s.CreatedAt, &s.ExpiresAt, &s.RevokedAt)
+	s.PublicKey = publicKey.String
+	return s, err
+}
+
+func scanProjectSecrets(rows *sql.Rows, err error) ([]domain.ProjectSecret, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.ProjectSecret{}
+	for rows.Next() {
+		s, err := scanProjectSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) InsertProjectSecretTx(ctx context.Context, tx *sql.Tx, s domain.ProjectSecret) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO project_secrets(`+projectSecretColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		s.ID, s.ProjectID, s.Kind, s.Name, s.Version, s.Value, s.KeyID, nullable(s.PublicKey), s.CreatedBy, s.CreatedAt, s.ExpiresAt, s.RevokedAt)
+	return err
+}
+
+// LatestSecretVersionTx returns the highest version of a project secret, 0 if there is none.
+func (r Repo) LatestSecretVersionTx(ctx context.Context, tx *sql.Tx, projectID, kind, name string) (int, error) {
+	var v int
+	err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version),0) FROM project_secrets WHERE project_id=? AND kind=? AND name=?`, projectID, kind, name).Scan(&v)
+	return v, err
+}
+
+// ExpireSecretsTx makes the live versions of a project secret expire by until and returns
+// how many it shortened; versions already expiring earlier keep their expiry.
+func (r Repo) ExpireSecretsTx(ctx context.Context, tx *sql.Tx, projectID, kind, name, until, now string) (int, error) {
+	res, err := tx.ExecContext(ctx, `UPDATE project_secrets SET expires_at=? WHERE project_id=? AND kind=? AND name=? AND `+liveSecret+` AND (expires_at IS NULL OR expires_at>?)`,
+		until, projectID, kind, name, now, until)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// RevokeSecretTx revokes a version of a project secret at now, keeping an earlier revocation.
+func (r Repo) RevokeSecretTx(ctx context.Context, tx *sql.Tx, projectID, id, now string) (domain.ProjectSecret, error) {
+	if _, err := tx.ExecContext(ctx, `UPDATE project_secrets SET revoked_at=COALESCE(revoked_at, ?) WHERE id=? AND project_id=?`, now, id, projectID); err != nil {
+		return domain.ProjectSecret{}, err
+	}
+	s, err := scanProjectSecret(tx.QueryRowContext(ctx, `SELECT `+projectSecretColumns+` FROM project_secrets WHERE id=? AND project_id=?`, id, projectID))
+	if err == sql.ErrNoRows {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+// ListProjectSecrets returns every version of the project's secrets, by kind and name,
+// newest version first.
+func (r Repo) ListProjectSecrets(ctx context.Context, projectID string) ([]domain.ProjectSecret, error) {
+	return scanProjectSecrets(r.reader(ctx).QueryContext(ctx, `SELECT `+projectSecretColumns+` FROM project_secrets WHERE project_id=? ORDER BY kind, name, version DESC`, projectID))
+}
+
+// LiveSecrets returns the versions of a project secret that are live at now, newest first.
+func (r Repo) LiveSecrets(ctx context.Context, projectID, kind, name, now string) ([]domain.ProjectSecret, error) {
+	return scanProjectSecrets(r.reader(ctx).QueryContext(ctx, `SELECT `+projectSecretColumns+` FROM project_secrets WHERE project_id=? AND kind=? AND name=? AND `+liveSecret+` ORDER BY version DESC`,
+		projectID, kind, name, now))
+}
+
+// GetLiveAPIToken returns the live API token version whose value is hash.
+func (r Repo) GetLiveAPIToken(ctx context.Context, hash, now string) (domain.ProjectSecret, error) {
+	s, err := scanProjectSecret(r.reader(ctx).QueryRowContext(ctx, `SELECT `+projectSecretColumns+` FROM project_secrets WHERE kind='api_token' AND value=? AND `+liveSecret+` LIMIT 1`, hash, now))
+	if err == sql.ErrNoRows {
+		return s, ErrNotFound
+	}
+	return s, err
+}
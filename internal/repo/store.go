@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+)
+
+// Store is the part of Repo the API server uses directly: its reads, the project config
+// written on project creation, and the credential lookups of authentication. Repo
+// implements it; server tests stub it to run handlers without a workspace.
+type Store interface {
+	GetProject(ctx context.Context, id string) (domain.Project, error)
+	ListProjects(ctx context.Context) ([]domain.Project, error)
+	DeleteProject(ctx context.Context, id string) error
+	GetProjectConfig(ctx context.Context, projectID string) (*config.Config, error)
+	UpsertProjectConfig(ctx context.Context, projectID string, cfg *config.Config) error
+	ListMembers(ctx context.Context, projectID, now string, limit int, cursorActorID string) ([]domain.Member, error)
+	ListMemberships(ctx context.Context, actorID, orgID, now string) ([]domain.Membership, error)
+	ListActorCapabilities(ctx context.Context, projectID, actorID string) ([]string, error)
+	GetTeam(ctx context.Context, projectID, teamID string) (domain.Team, error)
+	ListTeams(ctx context.Context, projectID string) ([]domain.Team, error)
+	GetEscalationRule(ctx context.Context, projectID, ruleID string) (domain.EscalationRule, error)
+	ListEscalationRules(ctx context.Context, projectID string) ([]domain.EscalationRule, error)
+	ListNotifications(ctx context.Context, projectID, actorID string, unreadOnly bool, limit int, beforeID int64) ([]domain.Notification, error)
+	CountUnreadNotifications(ctx context.Context, projectID, actorID string) (int, error)
+	GetEmailPreferences(ctx context.Context, actorID string) (domain.EmailPreferences, error)
+	ListProjectSecrets(ctx context.Context, projectID string) ([]domain.ProjectSecret, error)
+	LiveSecrets(ctx context.Context, projectID, kind, name, now string) ([]domain.ProjectSecret, error)
+
+	GetTask(ctx context.Context, id string) (domain.Task, error)
+	ListTasks(ctx context.Context, f TaskFilters) ([]domain.Task, error)
+	CountTasksByStatus(ctx context.Context, projectID string) (map[string]int, error)
+	ListTaskDependencies(ctx context.Context, taskID string) ([]string, error)
+	ListTaskAssignees(ctx context.Context, taskID string) ([]domain.TaskAssignee, error)
+	ListTaskHandoffs(ctx context.Context, taskID string) ([]domain.TaskHandoff, error)
+	ListTaskCompletions(ctx context.Context, taskID string) ([]domain.TaskCompletion, error)
+	ListTaskLinks(ctx context.Context, taskID string) ([]domain.TaskLink, error)
+	ListWaivers(ctx context.Context, taskID string) ([]domain.Waiver, error)
+
+	GetIteration(ctx context.Context, id string) (domain.Iteration, error)
+	ListIterationsWithCursor(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Iteration, error)
+	LatestRunningIteration(ctx context.Context, projectID string) (*domain.Iteration, error)
+
+	GetDecision(ctx context.Context, id string) (domain.Decision, error)
+	ListDecisions(ctx context.Context, f DecisionFilters) ([]domain.Decision, error)
+	ListAttestations(ctx context.Context, f AttestationFilters) ([]domain.Attestation, error)
+	BlobReferenced(ctx context.Context, projectID, digest string) (bool, error)
+	GetArtifact(ctx context.Context, id string) (domain.Artifact, error)
+	ListArtifacts(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Artifact, error)
+
+	LatestEventsFrom(ctx context.Context, limit int, cursor int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error)
+	EventsSince(ctx context.Context, limit int, afterID int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error)
+	MaxEventID(ctx context.Context, projectID string) (int64, error)
+	ActorEventsFrom(ctx context.Context, limit int, cursor int64, projectID, actorID, since string) ([]domain.Event, error)
+	CountActorEventsByType(ctx context.Context, projectID, actorID, since string) (map[string]int, error)
+	CountActorCompletions(ctx context.Context, projectID, actorID, since string) (int, error)
+	ListStatsSnapshots(ctx context.Context, projectID, from, to string) ([]domain.StatsSnapshot, error)
+
+	GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error)
+	GetLiveAPIToken(ctx context.Context, hash, now string) (domain.ProjectSecret, error)
+	GetClientCertMapping(ctx context.Context, kind, value string) (domain.ClientCertMapping, error)
+}
+
+var _ Store = Repo{}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"workline/internal/domain"
+)
+
+// InsertTaskSnapshotTx stores s and copies every task f matches into it, numbered in list
+// order (newest first). The copy is a single statement, so it sees one state of the
+// database however many tasks it holds. It returns s with its Total set.
+func (r Repo) InsertTaskSnapshotTx(ctx context.Context, tx *sql.Tx, s domain.TaskListSnapshot, f TaskFilters) (domain.TaskListSnapshot, error) {
+	if _, err := tx.ExecContext(ctx, `INSERT INTO task_list_snapshots(id,scope,actor_id,taken_at,last_read_at) VALUES(?,?,?,?,?)`,
+		s.ID, s.Scope, s.ActorID, s.TakenAt, s.LastReadAt); err != nil {
+		return s, err
+	}
+	clauses, args, err := r.taskFilterClauses(ctx, tx, f)
+	if err != nil {
+		return s, err
+	}
+	where := ""
+	if len(clauses) > 0 {
+		where = "WHERE " + strings.Join(clauses, " AND ")
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO task_list_snapshot_items(snapshot_id,pos,`+taskColumns+`)
+SELECT ?, ROW_NUMBER() OVER (ORDER BY created_at DESC, id DESC), `+taskColumns+` FROM tasks `+where,
+		append([]any{s.ID}, args...)...)
+	if err != nil {
+		return s, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return s, err
+	}
+	s.Total = int(n)
+	_, err = tx.ExecContext(ctx, `UPDATE task_list_snapshots SET total=? WHERE id=?`, s.Total, s.ID)
+	return s, err
+}
+
+// DeleteTaskSnapshotsTx drops the snapshots last read before readBefore, and all but the
+// keep most recently read snapshots of actorID.
+func (r Repo) DeleteTaskSnapshotsTx(ctx context.Context, tx *sql.Tx, readBefore, actorID string, keep int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_list_snapshots WHERE last_read_at<?`, readBefore); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM task_list_snapshots WHERE actor_id=? AND id NOT IN (
+  SELECT id FROM task_list_snapshots WHERE actor_id=? ORDER BY last_read_at DESC LIMIT ?)`, actorID, actorID, keep)
+	return err
+}
+
+// GetTaskSnapshot reads a snapshot from the primary database, where it was written.
+func (r Repo) GetTaskSnapshot(ctx context.Context, id string) (domain.TaskListSnapshot, error) {
+	var s domain.TaskListSnapshot
+	err := r.DB.QueryRowContext(ctx, `SELECT id,scope,actor_id,taken_at,last_read_at,total FROM task_list_snapshots WHERE id=?`, id).
+		Scan(&s.ID, &s.Scope, &s.ActorID, &s.TakenAt, &s.LastReadAt, &s.Total)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+// ListTaskSnapshotItems returns up to limit tasks of snapshot id placed after position
+// after, in list order.
+func (r Repo) ListTaskSnapshotItems(ctx context.Context, id string, after, limit int) ([]domain.Task, error) {
+	if err := checkQueryLimit(limit); err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+taskColumns+` FROM task_list_snapshot_items WHERE snapshot_id=? AND pos>? ORDER BY pos LIMIT ?`, id, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(ctx, rows)
+}
+
+// TouchTaskSnapshot records that snapshot id was read at.
+func (r Repo) TouchTaskSnapshot(ctx context.Context, id, at string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE task_list_snapshots SET last_read_at=? WHERE id=?`, at, id)
+	return err
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"workline/internal/domain"
+)
+
+func (r Repo) InsertTeamTx(ctx context.Context, tx *sql.Tx, t domain.Team) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO teams(project_id, id, description, created_by, created_at) VALUES (?,?,?,?,?)`,
+		t.ProjectID, t.ID, nullable(t.Description), t.CreatedBy, t.CreatedAt)
+	return err
+}
+
+func (r Repo) UpdateTeamTx(ctx context.Context, tx *sql.Tx, projectID, teamID, description string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE teams SET description=? WHERE project_id=? AND id=?`, nullable(description), projectID, teamID)
+	return err
+}
+
+// DeleteTeamTx removes a team with its memberships and role grants.
+func (r Repo) DeleteTeamTx(ctx context.Context, tx *sql.Tx, projectID, teamID string) error {
+	r.Cache.Invalidate(tx)
+	_, err := tx.ExecContext(ctx, `DELETE FROM teams WHERE project_id=? AND id=?`, projectID, teamID)
+	return err
+}
+
+func (r Repo) TeamExistsTx(ctx context.Context, tx *sql.Tx, projectID, teamID string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM teams WHERE project_id=? AND id=?`, projectID, teamID).Scan(&n)
+	return n > 0, err
+}
+
+func (r Repo) AddTeamMemberTx(ctx context.Context, tx *sql.Tx, projectID, teamID, actorID, now string) error {
+	r.Cache.Invalidate(tx)
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO team_members(project_id, team_id, actor_id, added_at) VALUES (?,?,?,?)`, projectID, teamID, actorID, now)
+	return err
+}
+
+func (r Repo) RemoveTeamMemberTx(ctx context.Context, tx *sql.Tx, projectID, teamID, actorID string) error {
+	r.Cache.Invalidate(tx)
+	_, err := tx.ExecContext(ctx, `DELETE FROM team_members WHERE project_id=? AND team_id=? AND actor_id=?`, projectID, teamID, actorID)
+	return err
+}
+
+// AssignTeamRoleUntil grants a role to a team, replacing the expiry of an existing grant;
+// an empty expiresAt makes the grant permanent.
+func (r Repo) AssignTeamRoleUntil(ctx context.Context, tx *sql.Tx, projectID, teamID, roleID, expiresAt string) error {
+	r.Cache.Invalidate(tx)
+	_, err := tx.ExecContext(ctx, `INSERT INTO team_roles(project_id, team_id, role_id, expires_at) VALUES (?,?,?,?)
+ON CONFLICT(project_id, team_id, role_id) DO UPDATE SET expires_at=excluded.expires_at`, projectID, teamID, roleID, nullable(expiresAt))
+	return err
+}
+
+func (r Repo) RevokeTeamRole(ctx context.Context, tx *sql.Tx, projectID, teamID, roleID string) error {
+	r.Cache.Invalidate(tx)
+	_, err := tx.ExecContext(ctx, `DELETE FROM team_roles WHERE project_id=? AND team_id=? AND role_id=?`, projectID, teamID, roleID)
+	return err
+}
+
+// GetTeam returns a team with its members and role grants, expired grants included.
+func (r Repo) GetTeam(ctx context.Context, projectID, teamID string) (domain.Team, error) {
+	teams, err := r.listTeams(ctx, projectID, teamID)
+	if err != nil {
+		return domain.Team{}, err
+	}
+	if len(teams) == 0 {
+		return domain.Team{}, ErrNotFound
+	}
+	return teams[0], nil
+}
+
+// ListTeams returns the project's teams ordered by id, with their members and role grants.
+func (r Repo) ListTeams(ctx context.Context, projectID string) ([]domain.Team, error) {
+	return r.listTeams(ctx, projectID, "")
+}
+
+// listTeams loads the teams of a project, or only teamID when set.
+func (r Repo) listTeams(ctx context.Context, projectID, teamID string) ([]domain.Team, error) {
+	teamFilter, filter := ``, ``
+	args := []any{projectID}
+	if teamID != "" {
+		teamFilter, filter = ` AND id=?`, ` AND team_id=?`
+		args = append(args, teamID)
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT id, description, created_by, created_at FROM teams WHERE project_id=?`+teamFilter+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	teams := []domain.Team{}
+	index := map[string]int{}
+	for rows.Next() {
+		t := domain.Team{ProjectID: projectID, Members: []string{}, Roles: []domain.RoleGrant{}}
+		var desc sql.NullString
+		if err := rows.Scan(&t.ID, &desc, &t.CreatedBy, &t.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.Description = desc.String
+		index[t.ID] = len(teams)
+		teams = append(teams, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(teams) == 0 {
+		return teams, err
+	}
+	members, err := r.reader(ctx).QueryContext(ctx, `SELECT team_id, actor_id FROM team_members WHERE project_id=?`+filter+` ORDER BY team_id, actor_id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	for members.Next() {
+		var team, actor string
+		if err := members.Scan(&team, &actor); err != nil {
+			members.Close()
+			return nil, err
+		}
+		if i, ok := index[team]; ok {
+			teams[i].Members = append(teams[i].Members, actor)
+		}
+	}
+	members.Close()
+	if err := members.Err(); err != nil {
+		return nil, err
+	}
+	roles, err := r.reader(ctx).QueryContext(ctx, `SELECT team_id, role_id, expires_at FROM team_roles WHERE project_id=?`+filter+` ORDER BY team_id, role_id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer roles.Close()
+	for roles.Next() {
+		var team string
+		var expiresAt sql.NullString
+		g := domain.RoleGrant{ProjectID: projectID}
+		if err := roles.Scan(&team, &g.RoleID, &expiresAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			g.ExpiresAt = &expiresAt.String
+		}
+		if i, ok := index[team]; ok {
+			g.TeamID = &team
+			teams[i].Roles = append(teams[i].Roles, g)
+		}
+	}
+	return teams, roles.Err()
+}
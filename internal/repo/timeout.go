@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"context"
+	"errors"
+)
+
+// sqliteInterrupt is SQLITE_INTERRUPT, the result of a statement stopped by
+// sqlite3_interrupt. The driver interrupts a running statement when its context ends.
+const sqliteInterrupt = 9
+
+// IsTimeout reports whether err comes from a query abandoned because its deadline passed,
+// or a statement SQLite interrupted. A caller that went away (context.Canceled) is not a
+// timeout. The interrupted statement releases the connection, and any write lock its
+// transaction held, as soon as it stops.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var coded interface{ Code() int }
+	return errors.As(err, &coded) && coded.Code()&0xff == sqliteInterrupt
+}
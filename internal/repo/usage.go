@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"workline/internal/domain"
+)
+
+// ActorUsageTx returns the actor's counters for day, zero when it made no calls.
+func (r Repo) ActorUsageTx(ctx context.Context, tx *sql.Tx, projectID, actorID, day string) (domain.ActorUsage, error) {
+	u := domain.ActorUsage{ProjectID: projectID, ActorID: actorID, Day: day}
+	err := tx.QueryRowContext(ctx, `SELECT requests, mutations, rejected FROM actor_usage WHERE project_id=? AND day=? AND actor_id=?`, projectID, day, actorID).
+		Scan(&u.Requests, &u.Mutations, &u.Rejected)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, nil
+	}
+	return u, err
+}
+
+// AddActorUsageTx adds the counts of delta to the actor's counters for delta.Day and
+// returns the new totals.
+func (r Repo) AddActorUsageTx(ctx context.Context, tx *sql.Tx, delta domain.ActorUsage) (domain.ActorUsage, error) {
+	u := domain.ActorUsage{ProjectID: delta.ProjectID, ActorID: delta.ActorID, Day: delta.Day}
+	err := tx.QueryRowContext(ctx, `
+INSERT INTO actor_usage(project_id, actor_id, day, requests, mutations, rejected) VALUES (?,?,?,?,?,?)
+ON CONFLICT(project_id, day, actor_id) DO UPDATE SET
+  requests=requests+excluded.requests,
+  mutations=mutations+excluded.mutations,
+  rejected=rejected+excluded.rejected
+RETURNING requests, mutations, rejected`, delta.ProjectID, delta.ActorID, delta.Day, delta.Requests, delta.Mutations, delta.Rejected).
+		Scan(&u.Requests, &u.Mutations, &u.Rejected)
+	return u, err
+}
+
+// ListActorUsage returns daily counters of the project between from and to (YYYY-MM-DD,
+// inclusive), newest day first and busiest actor first within a day. An empty actorID
+// lists every actor.
+func (r Repo) ListActorUsage(ctx context.Context, projectID, from, to, actorID string) ([]domain.ActorUsage, error) {
+	query := `SELECT actor_id, day, requests, mutations, rejected FROM actor_usage WHERE project_id=? AND day>=? AND day<=?`
+	args := []any{projectID, from, to}
+	if actorID != "" {
+		query += ` AND actor_id=?`
+		args = append(args, actorID)
+	}
+	query += ` ORDER BY day DESC, requests DESC, actor_id`
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.ActorUsage{}
+	for rows.Next() {
+		if err := chargeRow(ctx, "actor usage"); err != nil {
+			return nil, err
+		}
+		u := domain.ActorUsage{ProjectID: projectID}
+		if err := rows.Scan(&u.ActorID, &u.Day, &u.Requests, &u.Mutations, &u.Rejected); err != nil {
+			return nil, err
+		}
+		res = append(res, u)
+	}
+	return res, rows.Err()
+}
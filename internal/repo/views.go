@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"workline/internal/domain"
+)
+
+const viewColumns = `id,project_id,name,description,filters_json,visibility,roles_json,owner_id,created_at,updated_at`
+
+func (r Repo) InsertViewTx(ctx context.Context, tx *sql.Tx, v domain.View) error {
+	filters, err := json.Marshal(v.Filters)
+	if err != nil {
+		return err
+	}
+	var roles any
+	if len(v.Roles) > 0 {
+		b, err := json.Marshal(v.Roles)
+		if err != nil {
+			return err
+		}
+		roles = string(b)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO views(`+viewColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+		v.ID, v.ProjectID, v.Name, nullable(v.Description), string(filters), v.Visibility, roles, v.OwnerID, v.CreatedAt, v.UpdatedAt)
+	return err
+}
+
+func scanView(row rowScanner) (domain.View, error) {
+	var v domain.View
+	var description, roles sql.NullString
+	var filters string
+	if err := row.Scan(&v.ID, &v.ProjectID, &v.Name, &description, &filters, &v.Visibility, &roles, &v.OwnerID, &v.CreatedAt, &v.UpdatedAt); err != nil {
+		return v, err
+	}
+	v.Description = description.String
+	if err := json.Unmarshal([]byte(filters), &v.Filters); err != nil {
+		return v, err
+	}
+	if roles.Valid {
+		if err := json.Unmarshal([]byte(roles.String), &v.Roles); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+func (r Repo) GetView(ctx context.Context, id string) (domain.View, error) {
+	v, err := scanView(r.reader(ctx).QueryRowContext(ctx, `SELECT `+viewColumns+` FROM views WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return v, ErrNotFound
+	}
+	return v, err
+}
+
+func (r Repo) GetViewTx(ctx context.Context, tx *sql.Tx, id string) (domain.View, error) {
+	v, err := scanView(tx.QueryRowContext(ctx, `SELECT `+viewColumns+` FROM views WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return v, ErrNotFound
+	}
+	return v, err
+}
+
+// ListViews returns every view saved in the project, by name. Callers filter by visibility.
+func (r Repo) ListViews(ctx context.Context, projectID string) ([]domain.View, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+viewColumns+` FROM views WHERE project_id=? ORDER BY name, id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.View
+	for rows.Next() {
+		if err := chargeRow(ctx, "views"); err != nil {
+			return nil, err
+		}
+		v, err := scanView(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) DeleteViewTx(ctx context.Context, tx *sql.Tx, id string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM views WHERE id=?`, id)
+	return err
+}
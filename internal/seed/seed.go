@@ -0,0 +1,150 @@
+// Package seed parses declarative bootstrap files that load roles, actors,
+// attestation kinds, policy presets and an initial task tree into a project.
+package seed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"workline/internal/config"
+)
+
+// File is the seed document. Every section is optional.
+type File struct {
+	Attestations           map[string]Kind     `yaml:"attestations"`
+	Presets                map[string][]string `yaml:"presets"`
+	Roles                  map[string]Role     `yaml:"roles"`
+	AttestationAuthorities map[string][]string `yaml:"attestation_authorities"`
+	Actors                 []Actor             `yaml:"actors"`
+	Tasks                  []Task              `yaml:"tasks"`
+}
+
+type Kind struct {
+	Description string `yaml:"description"`
+}
+
+type Role struct {
+	Description string   `yaml:"description"`
+	Permissions []string `yaml:"permissions"`
+}
+
+type Actor struct {
+	ID    string   `yaml:"id"`
+	Roles []string `yaml:"roles"`
+}
+
+// Task is one node of the task tree. Ref names the task inside the seed so
+// depends_on can point at it before its ID is known; depends_on entries that
+// match no ref are treated as existing task IDs.
+type Task struct {
+	Ref         string   `yaml:"ref"`
+	ID          string   `yaml:"id"`
+	Type        string   `yaml:"type"`
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	Iteration   string   `yaml:"iteration"`
+	Policy      string   `yaml:"policy"`
+	Require     []string `yaml:"require"`
+	Assignee    string   `yaml:"assignee"`
+	DependsOn   []string `yaml:"depends_on"`
+	Children    []Task   `yaml:"children"`
+}
+
+// Parse decodes a YAML (or JSON) seed document, rejecting unknown fields.
+func Parse(data []byte) (File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return File{}, fmt.Errorf("invalid seed yaml: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// FromFile reads and parses a seed file.
+func FromFile(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	return Parse(data)
+}
+
+// Validate checks the document is self-consistent; references to existing
+// project state are checked when the seed is applied.
+func (f File) Validate() error {
+	for kind := range f.Attestations {
+		if kind == "" {
+			return fmt.Errorf("invalid seed: empty attestation kind")
+		}
+	}
+	for name, require := range f.Presets {
+		if name == "" {
+			return fmt.Errorf("invalid seed: empty preset name")
+		}
+		for _, req := range require {
+			if req == "" {
+				return fmt.Errorf("invalid seed: preset %s has empty attestation kind", name)
+			}
+		}
+	}
+	for roleID, role := range f.Roles {
+		if roleID == "" {
+			return fmt.Errorf("invalid seed: empty role id")
+		}
+		for _, perm := range role.Permissions {
+			if perm == "" {
+				return fmt.Errorf("invalid seed: role %s has empty permission", roleID)
+			}
+		}
+	}
+	for kind, roles := range f.AttestationAuthorities {
+		if kind == "" || len(roles) == 0 {
+			return fmt.Errorf("invalid seed: attestation authority %q needs a kind and roles", kind)
+		}
+		if err := config.ValidAuthorityKind(kind); err != nil {
+			return fmt.Errorf("invalid seed: %w", err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, a := range f.Actors {
+		if a.ID == "" {
+			return fmt.Errorf("invalid seed: actor id is required")
+		}
+		if seen[a.ID] {
+			return fmt.Errorf("invalid seed: actor %s listed twice", a.ID)
+		}
+		seen[a.ID] = true
+	}
+	refs := map[string]bool{}
+	var walk func(tasks []Task) error
+	walk = func(tasks []Task) error {
+		for _, t := range tasks {
+			if t.Title == "" {
+				return fmt.Errorf("invalid seed: task title is required")
+			}
+			if t.Ref != "" {
+				if refs[t.Ref] {
+					return fmt.Errorf("invalid seed: task ref %s used twice", t.Ref)
+				}
+				refs[t.Ref] = true
+			}
+			if t.Policy != "" && len(t.Require) > 0 {
+				return fmt.Errorf("invalid seed: task %q sets both policy and require", t.Title)
+			}
+			if err := walk(t.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(f.Tasks)
+}
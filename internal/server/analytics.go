@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/engine"
+)
+
+func registerAnalytics(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "cycle-time-analytics",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/analytics/cycle-time",
+		Summary:     "Lead time, cycle time, time in status and WIP age",
+		Description: "Rebuilds each task's status history from its events. Lead time runs from creation to completion, cycle time from the task first leaving planned to completion; both cover the tasks completed in the period, with p50, p85 and p95. WIP age covers the tasks in progress or in review now. Requires project.status.read.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID   string `path:"project_id"`
+		From        string `query:"from" doc:"Period start (RFC3339 or YYYY-MM-DD), defaults to 30 days before to"`
+		To          string `query:"to" doc:"Period end, exclusive (RFC3339), or last day included (YYYY-MM-DD); defaults to today (UTC)"`
+		Type        string `query:"type" doc:"Only tasks of this type"`
+		IterationID string `query:"iteration_id" doc:"Only tasks of this iteration"`
+	}) (*struct {
+		Body engine.CycleTimeReport `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.status.read"); err != nil {
+			return nil, handleError(err)
+		}
+		from, to, err := engine.ParsePeriod(input.From, input.To, time.Now())
+		if err != nil {
+			return nil, handleError(err)
+		}
+		rep, err := e.CycleTime(ctx, engine.CycleTimeOptions{
+			ProjectID:   projectID,
+			Type:        input.Type,
+			IterationID: input.IterationID,
+			From:        from,
+			To:          to,
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.CycleTimeReport `json:"body"`
+		}{Body: rep}, nil
+	})
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"log"
@@ -13,6 +14,8 @@ import (
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/golang-jwt/jwt/v5"
 
+	"workline/internal/engine"
+	"workline/internal/events"
 	"workline/internal/repo"
 )
 
@@ -27,6 +30,9 @@ type Principal struct {
 	Roles       []string
 	Permissions []string
 	Source      string
+	// ProjectID binds credentials issued by a project, such as project API tokens, to it:
+	// they hold no permission in other projects nor any global one.
+	ProjectID string
 }
 
 type principalKey struct{}
@@ -39,6 +45,10 @@ func (c AuthConfig) logger() *log.Logger {
 }
 
 func withPrincipal(ctx context.Context, p Principal) context.Context {
+	noteRequestActor(ctx, p.ActorID)
+	if p.ProjectID != "" {
+		ctx = engine.WithProjectScope(ctx, p.ProjectID)
+	}
 	return context.WithValue(ctx, principalKey{}, p)
 }
 
@@ -99,12 +109,15 @@ func authenticateJWT(token string, secret string) (Principal, error) {
 	}, nil
 }
 
-func authenticateAPIKey(ctx context.Context, r repo.Repo, key string) (Principal, error) {
+func authenticateAPIKey(ctx context.Context, r repo.Store, key string) (Principal, error) {
 	if strings.TrimSpace(key) == "" {
 		return Principal{}, errors.New("api key required")
 	}
 	hash := repo.HashAPIKey(key)
 	apiKey, err := r.GetAPIKeyByHash(ctx, hash)
+	if errors.Is(err, repo.ErrNotFound) {
+		return authenticateProjectToken(ctx, r, hash)
+	}
 	if err != nil {
 		return Principal{}, err
 	}
@@ -118,6 +131,59 @@ func authenticateAPIKey(ctx context.Context, r repo.Repo, key string) (Principal
 	}, nil
 }
 
+// authenticateProjectToken accepts a live API token rotated in through project secrets; it
+// authenticates as the actor the token is named after, in the project's org, and is bound
+// to the project.
+func authenticateProjectToken(ctx context.Context, r repo.Store, hash string) (Principal, error) {
+	token, err := r.GetLiveAPIToken(ctx, hash, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return Principal{}, err
+	}
+	project, err := r.GetProject(ctx, token.ProjectID)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{
+		ActorID:   token.Name,
+		OrgID:     project.OrgID,
+		Source:    "project_token",
+		ProjectID: token.ProjectID,
+	}, nil
+}
+
+// authenticateClientCert maps a verified client certificate to an actor: the subject CN is
+// looked up first, then DNS, email and URI SANs in certificate order.
+func authenticateClientCert(ctx context.Context, r repo.Store, cert *x509.Certificate) (Principal, error) {
+	identities := map[string][]string{
+		"cn":    {cert.Subject.CommonName},
+		"dns":   cert.DNSNames,
+		"email": cert.EmailAddresses,
+	}
+	for _, u := range cert.URIs {
+		identities["uri"] = append(identities["uri"], u.String())
+	}
+	for _, kind := range repo.ClientCertKinds {
+		for _, value := range identities[kind] {
+			if value == "" {
+				continue
+			}
+			m, err := r.GetClientCertMapping(ctx, kind, value)
+			if errors.Is(err, repo.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return Principal{}, err
+			}
+			return Principal{
+				ActorID: m.ActorID,
+				OrgID:   m.OrgID,
+				Source:  "mtls",
+			}, nil
+		}
+	}
+	return Principal{}, errors.New("client certificate not mapped to an actor")
+}
+
 func bearerToken(authz string) (string, bool) {
 	parts := strings.Fields(authz)
 	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
@@ -126,7 +192,7 @@ func bearerToken(authz string) (string, bool) {
 	return parts[1], true
 }
 
-func newAuthMiddleware(basePath string, cfg AuthConfig, r repo.Repo) func(http.Handler) http.Handler {
+func newAuthMiddleware(basePath string, cfg AuthConfig, r repo.Store) func(http.Handler) http.Handler {
 	healthPath := path.Join(basePath, "health")
 	openapiPath := path.Join(basePath, "openapi.json")
 	devLoginPath := path.Join(basePath, "auth/dev/login")
@@ -149,6 +215,11 @@ func newAuthMiddleware(basePath string, cfg AuthConfig, r repo.Repo) func(http.H
 				next.ServeHTTP(w, req)
 				return
 			}
+			// Provider webhooks authenticate with their shared secret in the handler.
+			if isWebhookPath(basePath, req.URL.Path) {
+				next.ServeHTTP(w, req)
+				return
+			}
 
 			authz := strings.TrimSpace(req.Header.Get("Authorization"))
 			apiKeyHeader := strings.TrimSpace(req.Header.Get("X-Api-Key"))
@@ -156,12 +227,12 @@ func newAuthMiddleware(basePath string, cfg AuthConfig, r repo.Repo) func(http.H
 			if authz != "" {
 				token, ok := bearerToken(authz)
 				if !ok {
-					respondStatusError(w, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
+					respondStatusError(w, req, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
 					return
 				}
 				principal, err := authenticateJWT(token, cfg.JWTSecret)
 				if err != nil {
-					respondStatusError(w, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
+					respondStatusError(w, req, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
 					return
 				}
 				ctx := withPrincipal(req.Context(), principal)
@@ -172,7 +243,19 @@ func newAuthMiddleware(basePath string, cfg AuthConfig, r repo.Repo) func(http.H
 			if apiKeyHeader != "" {
 				principal, err := authenticateAPIKey(req.Context(), r, apiKeyHeader)
 				if err != nil {
-					respondStatusError(w, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
+					respondStatusError(w, req, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
+					return
+				}
+				ctx := withPrincipal(req.Context(), principal)
+				next.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+
+			// Only chains verified against the server's client CA pool identify an actor.
+			if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+				principal, err := authenticateClientCert(req.Context(), r, req.TLS.VerifiedChains[0][0])
+				if err != nil {
+					respondStatusError(w, req, newAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil))
 					return
 				}
 				ctx := withPrincipal(req.Context(), principal)
@@ -180,12 +263,105 @@ func newAuthMiddleware(basePath string, cfg AuthConfig, r repo.Repo) func(http.H
 				return
 			}
 
-			respondStatusError(w, newAPIError(http.StatusUnauthorized, "unauthorized", "authentication required", nil))
+			respondStatusError(w, req, newAPIError(http.StatusUnauthorized, "unauthorized", "authentication required", nil))
 		})
 	}
 }
 
-func respondStatusError(w http.ResponseWriter, err huma.StatusError) {
+// isWebhookPath matches {base}/projects/{project_id}/integrations/{provider}/webhook.
+func isWebhookPath(basePath, p string) bool {
+	rest, ok := strings.CutPrefix(p, strings.TrimSuffix(basePath, "/")+"/projects/")
+	if !ok {
+		return false
+	}
+	parts := strings.Split(rest, "/")
+	return len(parts) == 4 && parts[0] != "" && parts[1] == "integrations" && parts[2] != "" && parts[3] == "webhook"
+}
+
+// statusRecorder captures the status and body of a response for post-processing.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.status == http.StatusForbidden {
+		r.body = append(r.body, b...)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data to the client when the underlying writer supports it, so
+// streamed responses keep streaming through the recorder.
+func (r *statusRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// newDenialRecorder persists an auth.denied event for every 403 answered to an authenticated caller.
+// Denials detected inside engine transactions are rolled back with the rejected mutation,
+// so this is where they become durable and visible to the event log and notifications.
+func newDenialRecorder(basePath string, e Engine) func(http.Handler) http.Handler {
+	projectsPrefix := strings.TrimSuffix(basePath, "/") + "/projects/"
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, req)
+			if rec.status != http.StatusForbidden {
+				return
+			}
+			principal, ok := principalFromContext(req.Context())
+			if !ok || principal.ActorID == "" {
+				return
+			}
+			projectID := ""
+			if rest, ok := strings.CutPrefix(req.URL.Path, projectsPrefix); ok {
+				projectID, _, _ = strings.Cut(rest, "/")
+			}
+			if projectID == "" {
+				projectID = strings.TrimSpace(req.Header.Get("X-Project-Id"))
+			}
+			if projectID == "" && e.DefaultConfig() != nil {
+				projectID = e.DefaultConfig().Project.ID
+			}
+			payload := events.EventPayload{"method": req.Method, "path": req.URL.Path}
+			var envelope struct {
+				Error apiErrorBody `json:"error"`
+			}
+			if err := json.Unmarshal(rec.body, &envelope); err == nil {
+				payload["reason"] = envelope.Error.Code
+				for k, v := range envelope.Error.Details {
+					payload[k] = v
+				}
+			}
+			_ = e.RecordDenial(context.WithoutCancel(req.Context()), projectID, principal.ActorID, payload)
+		})
+	}
+}
+
+func respondStatusError(w http.ResponseWriter, r *http.Request, err huma.StatusError) {
+	err = localizeError(w, r, err)
+	if ae, ok := err.(*apiError); ok {
+		err = withRequestID(r.Context(), ae)
+	}
 	status := http.StatusInternalServerError
 	if e, ok := err.(interface{ GetStatus() int }); ok {
 		status = e.GetStatus()
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChaosHeader names the faults injected into a response, so clients under test can tell
+// them from real ones.
+const ChaosHeader = "X-Chaos-Injected"
+
+// ChaosConfig injects faults into API responses so agent frameworks can exercise their
+// retry and idempotency handling against a real server. It is meant for test deployments
+// only; the zero value injects nothing.
+type ChaosConfig struct {
+	// Latency delays each request by a random duration up to Latency.
+	Latency time.Duration
+	// ErrorRate is the fraction of requests failing with 500 chaos_injected. Half of them
+	// fail before the request runs; the other half after it ran, with its response dropped,
+	// so a retry repeats a write that already happened.
+	ErrorRate float64
+	// LeaseRaceRate is the fraction of lease-sensitive writes (claims, releases, lease
+	// transfers, done and task updates) refused with 409 lease_conflict, as if another actor
+	// had taken the lease first. The request does not run.
+	LeaseRaceRate float64
+	// Seed makes the injected faults reproducible; 0 seeds from the clock.
+	Seed int64
+}
+
+// Enabled reports whether any fault is injected.
+func (c ChaosConfig) Enabled() bool {
+	return c.Latency > 0 || c.ErrorRate > 0 || c.LeaseRaceRate > 0
+}
+
+func (c ChaosConfig) validate() error {
+	if c.Latency < 0 {
+		return fmt.Errorf("invalid chaos latency %s: must not be negative", c.Latency)
+	}
+	for name, rate := range map[string]float64{"error rate": c.ErrorRate, "lease race rate": c.LeaseRaceRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid chaos %s %v: must be between 0 and 1", name, rate)
+		}
+	}
+	return nil
+}
+
+// chaosRand is a random source shared by concurrent requests.
+type chaosRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (r *chaosRand) float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+func (r *chaosRand) duration(max time.Duration) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(r.rnd.Int63n(int64(max) + 1))
+}
+
+// newChaosMiddleware injects the faults of cfg into operations below basePath. The OpenAPI
+// document, docs and health check are left alone so clients can still discover the API.
+func newChaosMiddleware(basePath string, cfg ChaosConfig) func(http.Handler) http.Handler {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := &chaosRand{rnd: rand.New(rand.NewSource(seed))}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rest, ok := strings.CutPrefix(r.URL.Path, basePath)
+			if !ok || !strings.HasPrefix(rest, "/") || chaosExempt(rest) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.Latency > 0 {
+				delay := rnd.duration(cfg.Latency)
+				w.Header().Add(ChaosHeader, "latency="+delay.String())
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if cfg.LeaseRaceRate > 0 && leaseSensitive(r.Method, rest) && rnd.float64() < cfg.LeaseRaceRate {
+				w.Header().Add(ChaosHeader, "lease_race")
+				respondStatusError(w, r, newAPIError(http.StatusConflict, "lease_conflict", "lease already held by another actor", map[string]any{"chaos": "lease_race"}))
+				return
+			}
+			if cfg.ErrorRate > 0 && rnd.float64() < cfg.ErrorRate {
+				if rnd.float64() < 0.5 {
+					w.Header().Add(ChaosHeader, "error")
+					respondStatusError(w, r, newAPIError(http.StatusInternalServerError, "chaos_injected", "injected failure; the request was not processed", map[string]any{"chaos": "error", "processed": false}))
+					return
+				}
+				buf := &bufferedResponse{ResponseWriter: w}
+				next.ServeHTTP(buf, r)
+				for _, h := range []string{"ETag", "Cache-Control", "Location"} {
+					w.Header().Del(h)
+				}
+				w.Header().Add(ChaosHeader, "error_after")
+				respondStatusError(w, r, newAPIError(http.StatusInternalServerError, "chaos_injected", "injected failure; the request was processed but its response dropped", map[string]any{"chaos": "error_after", "processed": true}))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// chaosExempt reports whether the path below the base path is left free of faults.
+func chaosExempt(rest string) bool {
+	switch rest {
+	case "/health", "/openapi.json", "/openapi.yaml", "/docs":
+		return true
+	}
+	return false
+}
+
+// leaseSensitive reports whether the request writes something guarded by a task lease.
+func leaseSensitive(method, rest string) bool {
+	if !strings.HasPrefix(rest, "/projects/") {
+		return false
+	}
+	switch method {
+	case http.MethodPatch:
+		parts := strings.Split(strings.Trim(rest, "/"), "/")
+		return len(parts) == 4 && parts[2] == "tasks"
+	case http.MethodPost:
+		for _, suffix := range []string{"/claim", "/claim-next", "/release", "/done", "/lease/transfer", "/lease/transfer/accept"} {
+			if strings.HasSuffix(rest, suffix) && !strings.HasSuffix(rest, "/review-lease"+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressMinSize is the smallest body worth gzip-encoding; shorter ones go out as is.
+const compressMinSize = 1024
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// newCompressionMiddleware gzip-encodes responses for clients that accept it. Bodies shorter
+// than compressMinSize, HEAD requests, event streams and responses that already carry a
+// Content-Encoding are sent unchanged. ETags become weak for clients accepting gzip, whether
+// or not a given body was encoded, so a 304 repeats the tag of the 200 it revalidates.
+func newCompressionMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, directly or through *.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressWriter holds the body back until it reaches compressMinSize, then commits the
+// headers and switches to gzip; a response that ends or flushes first is written plain.
+type compressWriter struct {
+	http.ResponseWriter
+	status  int
+	pending []byte
+	gz      *gzip.Writer
+	plain   bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	switch {
+	case c.gz != nil:
+		return c.gz.Write(b)
+	case c.plain:
+		return c.ResponseWriter.Write(b)
+	case !c.compressible():
+		c.commit(false)
+		return c.ResponseWriter.Write(b)
+	}
+	c.pending = append(c.pending, b...)
+	if len(c.pending) < compressMinSize {
+		return len(b), nil
+	}
+	c.commit(true)
+	if _, err := c.gz.Write(c.pending); err != nil {
+		return 0, err
+	}
+	c.pending = nil
+	return len(b), nil
+}
+
+// Flush sends what has been written so far, plain if the encoding was not chosen yet.
+func (c *compressWriter) Flush() {
+	if c.gz == nil && !c.plain && c.status != 0 {
+		c.commit(false)
+		_, _ = c.ResponseWriter.Write(c.pending)
+		c.pending = nil
+	}
+	if c.gz != nil {
+		_ = c.gz.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+func (c *compressWriter) compressible() bool {
+	h := c.Header()
+	return c.status >= http.StatusOK && c.status != http.StatusNoContent && c.status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && !strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")
+}
+
+func (c *compressWriter) commit(compress bool) {
+	h := c.Header()
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		c.gz = gzipWriters.Get().(*gzip.Writer)
+		c.gz.Reset(c.ResponseWriter)
+	} else {
+		c.plain = true
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+}
+
+func (c *compressWriter) close() {
+	switch {
+	case c.gz != nil:
+		_ = c.gz.Close()
+		c.gz.Reset(nil)
+		gzipWriters.Put(c.gz)
+	case c.plain || c.status == 0:
+	default:
+		c.commit(false)
+		_, _ = c.ResponseWriter.Write(c.pending)
+	}
+}
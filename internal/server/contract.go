@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Contract validation modes for Config.ContractValidation.
+const (
+	ContractOff     = ""
+	ContractLog     = "log"
+	ContractEnforce = "enforce"
+)
+
+// contractRoute is one documented operation with its path split into segments; segments
+// wrapped in braces are parameters.
+type contractRoute struct {
+	method   string
+	segments []string
+	op       *huma.Operation
+}
+
+// contractValidator checks traffic against the generated OpenAPI document. The document is
+// read on first use, once every operation has been registered.
+type contractValidator struct {
+	mode   string
+	logger *log.Logger
+	spec   func() *huma.OpenAPI
+
+	once     sync.Once
+	registry huma.Registry
+	routes   []contractRoute
+}
+
+// newContractMiddleware validates request bodies and responses of documented operations.
+// A request that violates its schema must not succeed, and a response must use a documented
+// status whose JSON schema its body satisfies. Violations are logged; in enforce mode the
+// response is replaced by 500 contract_violation so tests fail when handlers drift.
+func newContractMiddleware(mode string, logger *log.Logger, spec func() *huma.OpenAPI) func(http.Handler) http.Handler {
+	v := &contractValidator{mode: mode, logger: logger, spec: spec}
+	return func(next http.Handler) http.Handler {
+		if mode == ContractOff {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			v.once.Do(v.load)
+			op := v.match(req.Method, req.URL.Path)
+			if op == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			body, _ := req.Context().Value(bodyBytesKey{}).([]byte)
+			requestErrs := v.checkRequest(op, req.Header.Get("Content-Type"), body)
+			buf := &bufferedResponse{ResponseWriter: w}
+			next.ServeHTTP(buf, req)
+			status := buf.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			var violations []string
+			if status < 400 {
+				for _, err := range requestErrs {
+					violations = append(violations, "request accepted despite: "+err)
+				}
+			}
+			violations = append(violations, v.checkResponse(op, status, w.Header().Get("Content-Type"), buf.body.Bytes())...)
+			if len(violations) > 0 {
+				v.logger.Printf("contract violation: %s %s (%s): %s", req.Method, req.URL.Path, op.OperationID, strings.Join(violations, "; "))
+				if v.mode == ContractEnforce {
+					w.Header().Del("Content-Length")
+					w.Header().Del("ETag")
+					respondStatusError(w, req, newAPIError(http.StatusInternalServerError, "contract_violation", "response does not match the OpenAPI contract", map[string]any{
+						"operation":  op.OperationID,
+						"status":     status,
+						"violations": violations,
+					}))
+					return
+				}
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write(buf.body.Bytes())
+		})
+	}
+}
+
+func (v *contractValidator) load() {
+	oas := v.spec()
+	if oas == nil {
+		return
+	}
+	ensureDefaultErrorResponses(oas)
+	if oas.Components != nil {
+		v.registry = oas.Components.Schemas
+	}
+	for p, item := range oas.Paths {
+		segments := strings.Split(strings.Trim(p, "/"), "/")
+		for method, op := range map[string]*huma.Operation{
+			http.MethodGet: item.Get, http.MethodPut: item.Put, http.MethodPost: item.Post, http.MethodDelete: item.Delete,
+			http.MethodOptions: item.Options, http.MethodHead: item.Head, http.MethodPatch: item.Patch, http.MethodTrace: item.Trace,
+		} {
+			if op != nil {
+				v.routes = append(v.routes, contractRoute{method: method, segments: segments, op: op})
+			}
+		}
+	}
+}
+
+// match returns the operation for a request path, preferring the route with the most literal
+// segments so /events/verify wins over /events/{id}.
+func (v *contractValidator) match(method, p string) *huma.Operation {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	var best *huma.Operation
+	bestScore := -1
+	for _, r := range v.routes {
+		if r.method != method || len(r.segments) != len(segments) {
+			continue
+		}
+		score := 0
+		for i, s := range r.segments {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				if segments[i] == "" {
+					score = -1
+					break
+				}
+				continue
+			}
+			if s != segments[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r.op, score
+		}
+	}
+	return best
+}
+
+func (v *contractValidator) checkRequest(op *huma.Operation, contentType string, body []byte) []string {
+	if op.RequestBody == nil || len(body) == 0 || !isJSONMediaType(contentType) {
+		return nil
+	}
+	media := op.RequestBody.Content["application/json"]
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+	return v.validate(media.Schema, huma.ModeWriteToServer, body)
+}
+
+func (v *contractValidator) checkResponse(op *huma.Operation, status int, contentType string, body []byte) []string {
+	resp := op.Responses[strconv.Itoa(status)]
+	// The default response documents the error envelope only.
+	if resp == nil && status >= 400 {
+		resp = op.Responses["default"]
+	}
+	if resp == nil {
+		return []string{fmt.Sprintf("status %d is not documented", status)}
+	}
+	if len(body) == 0 || !isJSONMediaType(contentType) {
+		return nil
+	}
+	var schema *huma.Schema
+	for mt, media := range resp.Content {
+		if isJSONMediaType(mt) && media != nil && media.Schema != nil {
+			schema = media.Schema
+			break
+		}
+	}
+	if schema == nil {
+		return []string{fmt.Sprintf("status %d documents no JSON body", status)}
+	}
+	return v.validate(schema, huma.ModeReadFromServer, body)
+}
+
+func (v *contractValidator) validate(schema *huma.Schema, mode huma.ValidateMode, body []byte) []string {
+	if !v.resolvable(schema) {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{"body is not valid JSON: " + err.Error()}
+	}
+	res := &huma.ValidateResult{}
+	huma.Validate(v.registry, schema, huma.NewPathBuffer([]byte{}, 0), mode, value, res)
+	var errs []string
+	for _, err := range res.Errors {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+// resolvable reports whether a top-level schema reference exists in the registry.
+func (v *contractValidator) resolvable(schema *huma.Schema) bool {
+	if schema.Ref == "" {
+		return true
+	}
+	return v.registry != nil && v.registry.SchemaFromRef(schema.Ref) != nil
+}
+
+func isJSONMediaType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/validation"
+)
+
+// JSON decoding modes for request bodies.
+const (
+	// JSONStrict rejects fields a request schema does not declare with 400, naming them,
+	// so a typo such as "dependson" is not silently dropped.
+	JSONStrict = "strict"
+	// JSONLenient ignores undeclared fields, for clients that send extra data.
+	JSONLenient = "lenient"
+)
+
+// allowUnknownFields opens every closed object schema of the spec to undeclared
+// properties. Map schemas, whose additional properties are typed, are left alone.
+func allowUnknownFields(oapi *huma.OpenAPI) {
+	seen := map[*huma.Schema]bool{}
+	var walk func(s *huma.Schema)
+	walk = func(s *huma.Schema) {
+		if s == nil || seen[s] {
+			return
+		}
+		seen[s] = true
+		if closed, ok := s.AdditionalProperties.(bool); ok && !closed {
+			s.AdditionalProperties = true
+		}
+		for _, p := range s.Properties {
+			walk(p)
+		}
+		if addl, ok := s.AdditionalProperties.(*huma.Schema); ok {
+			walk(addl)
+		}
+		walk(s.Items)
+		for _, group := range [][]*huma.Schema{s.OneOf, s.AnyOf, s.AllOf} {
+			for _, sub := range group {
+				walk(sub)
+			}
+		}
+	}
+	if oapi.Components != nil && oapi.Components.Schemas != nil {
+		for _, s := range oapi.Components.Schemas.Map() {
+			walk(s)
+		}
+	}
+	for _, item := range oapi.Paths {
+		for _, op := range []*huma.Operation{item.Get, item.Put, item.Post, item.Delete, item.Patch} {
+			if op == nil || op.RequestBody == nil {
+				continue
+			}
+			for _, mt := range op.RequestBody.Content {
+				walk(mt.Schema)
+			}
+		}
+	}
+}
+
+// unknownFields lists the body fields, as dotted paths, that validation rejected as
+// undeclared.
+func unknownFields(errs []error) []string {
+	var fields []string
+	for _, err := range errs {
+		var detail *huma.ErrorDetail
+		if errors.As(err, &detail) && detail.Message == validation.MsgUnexpectedProperty && strings.HasPrefix(detail.Location, "body.") {
+			fields = append(fields, strings.TrimPrefix(detail.Location, "body."))
+		}
+	}
+	return fields
+}
+
+// unknownFieldsMessage names the undeclared fields of a rejected request body.
+func unknownFieldsMessage(fields []string) string {
+	if len(fields) == 1 {
+		return fmt.Sprintf("unknown field %q", fields[0])
+	}
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = fmt.Sprintf("%q", f)
+	}
+	return "unknown fields " + strings.Join(quoted, ", ")
+}
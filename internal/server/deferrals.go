@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func registerDeferrals(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "defer-task",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/defer",
+		Summary:     "Defer a task until a time",
+		Description: "Keeps the task out of the ready queue and claim-next until `until`, or for the duration `for` (snooze). Deferring again replaces the time. A task.ready event is recorded once it passes. Requires task.update.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string           `path:"project_id"`
+		ID        string           `path:"id"`
+		Body      DeferTaskRequest `json:"body"`
+	}) (*struct {
+		Body TaskResponse `json:"body"`
+	}, error) {
+		until := input.Body.Until
+		switch {
+		case until != "" && input.Body.For != "":
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "set until or for, not both", nil)
+		case input.Body.For != "":
+			d, err := time.ParseDuration(input.Body.For)
+			if err != nil || d <= 0 {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid for: use a positive duration such as 48h", map[string]any{"for": input.Body.For})
+			}
+			until = time.Now().UTC().Add(d).Format(time.RFC3339)
+		case until == "":
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "until or for is required", nil)
+		}
+		return deferTask(ctx, e, input.ProjectID, input.ID, until)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "undefer-task",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/tasks/{id}/defer",
+		Summary:     "End a task's deferral now",
+		Description: "Returns the task to the ready queue and records task.undeferred. Requires task.update.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body TaskResponse `json:"body"`
+	}, error) {
+		return deferTask(ctx, e, input.ProjectID, input.ID, "")
+	})
+}
+
+func deferTask(ctx context.Context, e Engine, projectID, taskID, until string) (*struct {
+	Body TaskResponse `json:"body"`
+}, error) {
+	actorID, authErr := actorIDFromContext(ctx)
+	if authErr != nil {
+		return nil, authErr
+	}
+	t, err := e.Store().GetTask(ctx, taskID)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	if !projectMatches(projectID, t.ProjectID) {
+		return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+	}
+	t, err = e.DeferTask(ctx, t.ID, until, actorID)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return &struct {
+		Body TaskResponse `json:"body"`
+	}{Body: taskResponse(t)}, nil
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/engine"
+)
+
+func registerDigests(api huma.API, e Engine) {
+	registerList(api, huma.Operation{
+		OperationID: "list-digests",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/digests",
+		Summary:     "List daily project digests",
+		Description: "Digests stored for days in the period, newest first. Requires digest.read.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		From      string `query:"from" doc:"First day (YYYY-MM-DD), defaults to 30 days before to"`
+		To        string `query:"to" doc:"Last day (YYYY-MM-DD), defaults to today (UTC)"`
+	}) ([]engine.Digest, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "digest.read"); err != nil {
+			return nil, handleError(err)
+		}
+		to := time.Now().UTC()
+		if input.To != "" {
+			parsed, err := time.Parse(time.DateOnly, input.To)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid to", map[string]any{"to": input.To})
+			}
+			to = parsed
+		}
+		from := to.AddDate(0, 0, -30)
+		if input.From != "" {
+			parsed, err := time.Parse(time.DateOnly, input.From)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid from", map[string]any{"from": input.From})
+			}
+			from = parsed
+		}
+		if from.After(to) {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "from must not be after to", nil)
+		}
+		digests, err := e.ListDigests(ctx, projectID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return digests, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-digest",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/digests/{day}",
+		Summary:     "Read the digest of a day",
+		Description: "Requires digest.read.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Day       string `path:"day" doc:"Day (YYYY-MM-DD)"`
+	}) (*struct {
+		Body engine.Digest `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "digest.read"); err != nil {
+			return nil, handleError(err)
+		}
+		d, err := e.GetDigest(ctx, projectID, input.Day)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.Digest `json:"body"`
+		}{Body: d}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "generate-digest",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/digests",
+		Summary:     "Generate the digest of a day",
+		Description: "Builds the digest of a day, yesterday (UTC) by default, replacing one generated earlier, and records a digest.generated event. `wl serve` generates yesterday's digest of every project on its own. Requires digest.generate.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                 `path:"project_id"`
+		Body      *GenerateDigestRequest `json:"body"`
+	}) (*struct {
+		Body engine.Digest `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "digest.generate"); err != nil {
+			return nil, handleError(err)
+		}
+		dayParam := ""
+		if input.Body != nil {
+			dayParam = input.Body.Day
+		}
+		day, err := engine.DigestDay(dayParam, time.Now())
+		if err != nil {
+			return nil, handleError(err)
+		}
+		d, err := e.GenerateDigest(ctx, projectID, day, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.Digest `json:"body"`
+		}{Body: d}, nil
+	})
+}
@@ -5,13 +5,16 @@ import (
 
 	"workline/internal/config"
 	"workline/internal/domain"
+	"workline/internal/engine"
+	"workline/internal/integrations"
 )
 
 // Request payloads
 
 type CreateProjectRequest struct {
-	ID          string  `json:"id"`
-	Description *string `json:"description,omitempty"`
+	ID              string  `json:"id"`
+	Description     *string `json:"description,omitempty"`
+	ParentProjectID *string `json:"parent_project_id,omitempty" doc:"Program the project belongs to" example:"platform-program"`
 }
 
 type TaskValidationRequest struct {
@@ -26,7 +29,7 @@ type CreateTaskRequest struct {
 	ID           *string                `json:"id,omitempty" example:"task-auth-1"`
 	IterationID  *string                `json:"iteration_id,omitempty" example:"iter-1"`
 	ParentID     *string                `json:"parent_id,omitempty" example:"task-epic"`
-	Type         string                 `json:"type" enum:"technical,feature,bug,docs,chore,workshop" example:"feature"`
+	Type         string                 `json:"type" doc:"Built-in type (technical, feature, bug, docs, chore, workshop) or one declared under task_types" example:"feature"`
 	Title        string                 `json:"title" example:"Ship authentication"`
 	Description  *string                `json:"description,omitempty" example:"Implement login and SSO flows"`
 	AssigneeID   *string                `json:"assignee_id,omitempty" example:"dev-1"`
@@ -34,6 +37,18 @@ type CreateTaskRequest struct {
 	Policy       *TaskPolicyRequest     `json:"policy,omitempty"`
 	Validation   *TaskValidationRequest `json:"validation,omitempty"`
 	WorkOutcomes map[string]any         `json:"work_outcomes,omitempty" example:"{\"pr\":123}"`
+	CustomFields map[string]any         `json:"custom_fields,omitempty" doc:"Validated against the fields schema of the task type" example:"{\"severity\":\"sev2\"}"`
+	// RequiredCapabilities limit the ready queue to actors offering all of them.
+	RequiredCapabilities []string `json:"required_capabilities,omitempty" example:"[\"golang\"]"`
+	Labels               []string `json:"labels,omitempty" doc:"Labels weighed by the risk policy" example:"[\"security\"]"`
+	DeferUntil           *string  `json:"defer_until,omitempty" format:"date-time" doc:"Keep the task out of the ready queue until this time" example:"2024-06-01T09:00:00Z"`
+	DueAt                *string  `json:"due_at,omitempty" format:"date-time" doc:"Deadline the deadline scheduler orders the ready queue by" example:"2024-06-14T17:00:00Z"`
+	FreezeOverride       string   `json:"freeze_override,omitempty" doc:"Reason for adding the task to a frozen iteration; needs iteration.freeze.override"`
+}
+
+type DeferTaskRequest struct {
+	Until string `json:"until,omitempty" format:"date-time" doc:"Time the task becomes ready again" example:"2024-06-01T09:00:00Z"`
+	For   string `json:"for,omitempty" doc:"Snooze for a Go duration instead of until a time" example:"48h"`
 }
 
 type UpdateTaskValidationRequest struct {
@@ -41,19 +56,43 @@ type UpdateTaskValidationRequest struct {
 }
 
 type UpdateTaskRequest struct {
-	Status          *string                      `json:"status,omitempty" enum:"planned,in_progress,review,done,rejected,canceled"`
-	AssigneeID      *string                      `json:"assignee_id,omitempty"`
-	AddDependsOn    []string                     `json:"add_depends_on,omitempty"`
-	RemoveDependsOn []string                     `json:"remove_depends_on,omitempty"`
-	ParentID        *string                      `json:"parent_id,omitempty"`
-	WorkOutcomes    *map[string]any              `json:"work_outcomes,omitempty"`
-	Validation      *UpdateTaskValidationRequest `json:"validation,omitempty"`
+	Status               *string                      `json:"status,omitempty" enum:"planned,in_progress,review,done,rejected,canceled"`
+	AssigneeID           *string                      `json:"assignee_id,omitempty"`
+	HandoffNote          *string                      `json:"handoff_note,omitempty" doc:"Context for the next assignee; only valid with an assignee_id change"`
+	AddDependsOn         []string                     `json:"add_depends_on,omitempty"`
+	RemoveDependsOn      []string                     `json:"remove_depends_on,omitempty"`
+	ParentID             *string                      `json:"parent_id,omitempty"`
+	WorkOutcomes         *map[string]any              `json:"work_outcomes,omitempty"`
+	Validation           *UpdateTaskValidationRequest `json:"validation,omitempty"`
+	RequiredCapabilities []string                     `json:"required_capabilities,omitempty" doc:"Replaces the task's required capabilities; send [] to clear"`
+	CustomFields         *map[string]any              `json:"custom_fields,omitempty" doc:"Replaces the task's custom fields; send null to clear"`
+	DueAt                *string                      `json:"due_at,omitempty" format:"date-time" doc:"Replaces the task's deadline; send null to clear"`
+	Labels               []string                     `json:"labels,omitempty" doc:"Replaces the task's labels; send [] to clear"`
+	FreezeOverride       string                       `json:"freeze_override,omitempty" doc:"Reason for loosening the policy of a task in a frozen iteration; needs iteration.freeze.override"`
+}
+
+type TaskTypeResponse struct {
+	Name             string         `json:"name" example:"incident"`
+	Builtin          bool           `json:"builtin" example:"false"`
+	Description      string         `json:"description,omitempty" example:"Production incident follow-up"`
+	Fields           map[string]any `json:"fields,omitempty" doc:"JSON Schema that custom_fields must match"`
+	RequiredOutcomes []string       `json:"required_outcomes,omitempty" doc:"Work outcome fields a task of this type must report to be completed" example:"[\"pr\",\"demo_url\"]"`
+}
+
+type TaskTypesResponse struct {
+	ProjectID string             `json:"project_id" example:"workline"`
+	Types     []TaskTypeResponse `json:"types"`
 }
 
 type CompleteTaskRequest struct {
 	WorkOutcomes map[string]any `json:"work_outcomes"`
 }
 
+type MoveTaskRequest struct {
+	Before *string `json:"before,omitempty" doc:"Place the task immediately before this sibling"`
+	After  *string `json:"after,omitempty" doc:"Place the task immediately after this sibling"`
+}
+
 type WorkOutcomesAppendRequest struct {
 	Path  string `json:"path"`
 	Value any    `json:"value"`
@@ -70,14 +109,53 @@ type WorkOutcomesMergeRequest struct {
 }
 
 type CreateIterationRequest struct {
-	ID   string `json:"id"`
-	Goal string `json:"goal"`
+	ID         string             `json:"id"`
+	Goal       string             `json:"goal"`
+	KeyResults []KeyResultRequest `json:"key_results,omitempty" doc:"Measurable targets; validation requires all of them to be met"`
+}
+
+type KeyResultRequest struct {
+	Metric     string   `json:"metric" example:"p95_latency_ms"`
+	Target     *float64 `json:"target,omitempty" doc:"Required for a new metric" example:"200"`
+	Current    *float64 `json:"current,omitempty" doc:"Set by hand; detaches the value from its last attestation"`
+	Direction  *string  `json:"direction,omitempty" enum:"increase,decrease" doc:"increase (default) is met when current >= target, decrease when current <= target"`
+	SourceKind *string  `json:"source_kind,omitempty" doc:"Attestation kind on the iteration whose payload field named after the metric, else value, sets current" example:"perf.measured"`
+	Remove     bool     `json:"remove,omitempty" doc:"Drop the key result"`
+}
+
+type UpdateKeyResultsRequest struct {
+	KeyResults []KeyResultRequest `json:"key_results" doc:"Changes applied in order, matched by metric; omitted fields are kept"`
 }
 
 type SetIterationStatusRequest struct {
 	Status string `json:"status" enum:"pending,running,delivered,validated,rejected"`
 }
 
+type RepairConsistencyRequest struct {
+	Checks []string `json:"checks,omitempty" doc:"Checks to repair (task_parent, task_iteration, task_dependency, attestation_entity, lease); all when omitted"`
+}
+
+type CarryOverRequest struct {
+	TargetIterationID string `json:"target_iteration_id,omitempty" doc:"Iteration receiving the tasks; omit to move them to the backlog" example:"iter-2"`
+	FreezeOverride    string `json:"freeze_override,omitempty" doc:"Reason for adding tasks to a frozen target iteration; needs iteration.freeze.override"`
+}
+
+type ViewFiltersRequest struct {
+	Status      string `json:"status,omitempty" example:"ready"`
+	Type        string `json:"type,omitempty" example:"feature"`
+	IterationID string `json:"iteration_id,omitempty"`
+	ParentID    string `json:"parent_id,omitempty"`
+	AssigneeID  string `json:"assignee_id,omitempty" doc:"Actor ID, or $me for the actor running the view" example:"$me"`
+}
+
+type CreateViewRequest struct {
+	Name        string             `json:"name" example:"my ready features"`
+	Description string             `json:"description,omitempty"`
+	Filters     ViewFiltersRequest `json:"filters"`
+	Visibility  string             `json:"visibility,omitempty" enum:"project,private" doc:"Defaults to project"`
+	Roles       []string           `json:"roles,omitempty" doc:"Restrict a project view to these roles; the owner always sees it" example:"[\"dev\"]"`
+}
+
 type CreateDecisionRequest struct {
 	ID           string         `json:"id" example:"dec-1"`
 	Title        string         `json:"title" example:"Choose runtime"`
@@ -86,54 +164,172 @@ type CreateDecisionRequest struct {
 	Context      map[string]any `json:"context,omitempty"`
 	Rationale    []string       `json:"rationale,omitempty" example:"[\"Team experience\",\"Ecosystem support\"]"`
 	Alternatives []string       `json:"alternatives,omitempty" example:"[\"Rust\",\"NodeJS\"]"`
+	Status       string         `json:"status,omitempty" enum:"proposed,accepted,superseded,rejected" doc:"Defaults to accepted"`
 }
 
 type CreateAttestationRequest struct {
 	ID         *string        `json:"id,omitempty" example:"att-1"`
-	EntityKind string         `json:"entity_kind" enum:"project,iteration,task,decision" example:"task"`
+	EntityKind string         `json:"entity_kind" enum:"project,iteration,task,decision,attestation" example:"task"`
 	EntityID   string         `json:"entity_id" example:"task-auth-1"`
 	Kind       string         `json:"kind" example:"review.approved"`
 	TS         *string        `json:"ts,omitempty" format:"date-time" example:"2024-05-01T10:00:00Z"`
 	Payload    map[string]any `json:"payload,omitempty" example:"{\"note\":\"LGTM\"}"`
+	ActorID    *string        `json:"actor_id,omitempty" doc:"Actor the attestation is attributed to; defaults to the caller. Another actor requires attestation.on_behalf and must hold authority for the kind" example:"ci-bot"`
+}
+
+// DependencyEdge is shared with subtree snapshots, which carry the engine type.
+type DependencyEdge = engine.DependencyEdge
+
+type ImportDependenciesRequest struct {
+	Edges []DependencyEdge `json:"edges" minItems:"1" maxItems:"5000"`
+}
+
+// subtreeMaxBytes bounds an imported snapshot, which inlines attestation payloads.
+const subtreeMaxBytes = 64 << 20
+
+type ImportSubtreeRequest struct {
+	Snapshot engine.SubtreeSnapshot `json:"snapshot" doc:"As returned by GET .../tasks/{id}/subtree"`
+	ParentID string                 `json:"parent_id,omitempty" doc:"Existing task to place the subtree's root under"`
+	Template bool                   `json:"template,omitempty" doc:"Start every task as planned and unassigned, without work outcomes or attestations"`
+	IDPrefix string                 `json:"id_prefix,omitempty" doc:"Derive new IDs by prefixing the snapshot's; required when the project uses client-supplied IDs"`
+}
+
+type BulkAttestationRequest struct {
+	Atomic bool                       `json:"atomic,omitempty" doc:"Roll back every item when any item fails"`
+	Items  []CreateAttestationRequest `json:"items" minItems:"1" maxItems:"500"`
+}
+
+type GenerateDigestRequest struct {
+	Day string `json:"day,omitempty" format:"date" doc:"Day to summarize (YYYY-MM-DD), defaults to yesterday (UTC)"`
+}
+
+type CreateEscalationRuleRequest struct {
+	ID          string `json:"id" example:"stale-work"`
+	Description string `json:"description,omitempty"`
+	Condition   string `json:"condition" enum:"task.overdue,validation.blocked"`
+	After       string `json:"after" example:"48h" doc:"How long a task stays in the condition before the rule acts"`
+	TaskType    string `json:"task_type,omitempty" doc:"Only escalate tasks of this type"`
+	Action      string `json:"action" enum:"notify,follow_up"`
+	RoleID      string `json:"role_id,omitempty" example:"pm" doc:"Role notified of escalations; required by notify"`
+	Enabled     *bool  `json:"enabled,omitempty" doc:"Defaults to true"`
+}
+
+type RotateSecretRequest struct {
+	Kind    string `json:"kind" enum:"webhook,api_token,signing"`
+	Name    string `json:"name,omitempty" example:"gitlab" doc:"Provider for webhook secrets, actor for API tokens; signing keys are named evidence"`
+	Overlap string `json:"overlap,omitempty" example:"24h" doc:"How long earlier versions stay valid; defaults to 24h, 0s retires them at once"`
+}
+
+type UpdateEscalationRuleRequest struct {
+	Description *string `json:"description,omitempty"`
+	Condition   *string `json:"condition,omitempty" enum:"task.overdue,validation.blocked"`
+	After       *string `json:"after,omitempty"`
+	TaskType    *string `json:"task_type,omitempty"`
+	Action      *string `json:"action,omitempty" enum:"notify,follow_up"`
+	RoleID      *string `json:"role_id,omitempty"`
+	Enabled     *bool   `json:"enabled,omitempty"`
 }
 
 // Response payloads
 
 type ProjectResponse struct {
-	ID          string `json:"id"`
-	OrgID       string `json:"org_id"`
-	Kind        string `json:"kind"`
-	Status      string `json:"status"`
-	Description string `json:"description,omitempty"`
-	CreatedAt   string `json:"created_at" format:"date-time"`
+	ID              string         `json:"id"`
+	OrgID           string         `json:"org_id"`
+	Kind            string         `json:"kind"`
+	Status          string         `json:"status"`
+	Description     string         `json:"description,omitempty"`
+	CreatedAt       string         `json:"created_at" format:"date-time"`
+	ParentProjectID string         `json:"parent_project_id,omitempty" doc:"Program rolling this project up"`
+	DisplayName     string         `json:"display_name,omitempty"`
+	Tags            []string       `json:"tags,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
 }
 
 type IterationResponse struct {
-	ID        string `json:"id"`
-	OrgID     string `json:"org_id"`
-	ProjectID string `json:"project_id"`
-	Goal      string `json:"goal"`
-	Status    string `json:"status" enum:"pending,running,delivered,validated,rejected"`
-	CreatedAt string `json:"created_at" format:"date-time"`
+	ID         string              `json:"id"`
+	OrgID      string              `json:"org_id"`
+	ProjectID  string              `json:"project_id"`
+	Goal       string              `json:"goal"`
+	Status     string              `json:"status" enum:"pending,running,delivered,validated,rejected"`
+	CreatedAt  string              `json:"created_at" format:"date-time"`
+	CarriedOut int                 `json:"carried_out" doc:"Tasks carried over out of this iteration"`
+	CarriedIn  int                 `json:"carried_in" doc:"Tasks carried over into this iteration"`
+	KeyResults []KeyResultResponse `json:"key_results,omitempty"`
+	FrozenAt   *string             `json:"frozen_at,omitempty" format:"date-time" doc:"When the iteration's scope was frozen"`
+	FrozenBy   *string             `json:"frozen_by,omitempty"`
+}
+
+type KeyResultResponse struct {
+	Metric        string  `json:"metric"`
+	Target        float64 `json:"target"`
+	Current       float64 `json:"current"`
+	Direction     string  `json:"direction" enum:"increase,decrease"`
+	SourceKind    string  `json:"source_kind,omitempty"`
+	AttestationID string  `json:"attestation_id,omitempty" doc:"Attestation that last set current"`
+	Met           bool    `json:"met"`
+}
+
+type ViewResponse struct {
+	ID          string             `json:"id"`
+	ProjectID   string             `json:"project_id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Filters     ViewFiltersRequest `json:"filters"`
+	Visibility  string             `json:"visibility" enum:"project,private"`
+	Roles       []string           `json:"roles,omitempty"`
+	OwnerID     string             `json:"owner_id"`
+	CreatedAt   string             `json:"created_at" format:"date-time"`
+	UpdatedAt   string             `json:"updated_at" format:"date-time"`
+}
+
+type CapabilitiesRequest struct {
+	Capabilities []string `json:"capabilities" example:"[\"golang\",\"frontend\"]"`
+}
+
+type CapabilitiesResponse struct {
+	ProjectID    string   `json:"project_id"`
+	ActorID      string   `json:"actor_id"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type ClaimNextResponse struct {
+	Task  TaskResponse  `json:"task"`
+	Lease LeaseResponse `json:"lease"`
+}
+
+type CarryOverResponse struct {
+	Source  IterationResponse  `json:"source"`
+	Target  *IterationResponse `json:"target,omitempty" doc:"Absent when tasks went to the backlog"`
+	TaskIDs []string           `json:"task_ids"`
 }
 
 type TaskResponse struct {
-	ID                   string         `json:"id" example:"task-auth-1"`
-	OrgID                string         `json:"org_id" example:"org-1"`
-	ProjectID            string         `json:"project_id" example:"workline"`
-	IterationID          *string        `json:"iteration_id,omitempty" example:"iter-1"`
-	ParentID             *string        `json:"parent_id,omitempty" example:"task-epic"`
-	Type                 string         `json:"type" enum:"technical,feature,bug,docs,chore,workshop" example:"feature"`
-	Title                string         `json:"title" example:"Ship authentication"`
-	Description          string         `json:"description,omitempty" example:"Implement login and SSO flows"`
-	Status               string         `json:"status" enum:"planned,in_progress,review,done,rejected,canceled" example:"planned"`
-	AssigneeID           *string        `json:"assignee_id,omitempty" example:"dev-1"`
-	WorkOutcomes         map[string]any `json:"work_outcomes,omitempty" example:"{\"pr\":123}"`
-	RequiredAttestations []string       `json:"required_attestations" example:"[\"ci.passed\",\"review.approved\"]"`
-	DependsOn            []string       `json:"depends_on" example:"[]"`
-	CreatedAt            string         `json:"created_at" format:"date-time" example:"2024-05-01T09:00:00Z"`
-	UpdatedAt            string         `json:"updated_at" format:"date-time" example:"2024-05-01T09:05:00Z"`
-	CompletedAt          *string        `json:"completed_at" format:"date-time" example:"2024-05-02T10:00:00Z"`
+	ID                   string             `json:"id" example:"task-auth-1"`
+	OrgID                string             `json:"org_id" example:"org-1"`
+	ProjectID            string             `json:"project_id" example:"workline"`
+	IterationID          *string            `json:"iteration_id,omitempty" example:"iter-1"`
+	ParentID             *string            `json:"parent_id,omitempty" example:"task-epic"`
+	Type                 string             `json:"type" example:"feature"`
+	Title                string             `json:"title" example:"Ship authentication"`
+	Description          string             `json:"description,omitempty" example:"Implement login and SSO flows"`
+	Status               string             `json:"status" enum:"planned,in_progress,review,done,rejected,canceled" example:"planned"`
+	AssigneeID           *string            `json:"assignee_id,omitempty" example:"dev-1"`
+	WorkOutcomes         map[string]any     `json:"work_outcomes,omitempty" example:"{\"pr\":123}"`
+	CustomFields         map[string]any     `json:"custom_fields,omitempty" example:"{\"severity\":\"sev2\"}"`
+	RequiredAttestations []string           `json:"required_attestations" example:"[\"ci.passed\",\"review.approved\"]"`
+	RequiredCapabilities []string           `json:"required_capabilities" example:"[\"golang\"]"`
+	Labels               []string           `json:"labels" example:"[\"security\"]"`
+	RiskScore            *int               `json:"risk_score,omitempty" doc:"Score computed by policies.risk" example:"7"`
+	RiskLevel            *string            `json:"risk_level,omitempty" doc:"Risk level the score reaches" example:"high"`
+	DependsOn            []string           `json:"depends_on" example:"[]"`
+	DeferUntil           *string            `json:"defer_until,omitempty" format:"date-time" doc:"The task stays out of the ready queue until then" example:"2024-06-01T09:00:00Z"`
+	DueAt                *string            `json:"due_at,omitempty" format:"date-time" doc:"Deadline of the task" example:"2024-06-14T17:00:00Z"`
+	Rank                 int                `json:"rank" example:"1"`
+	CreatedAt            string             `json:"created_at" format:"date-time" example:"2024-05-01T09:00:00Z"`
+	UpdatedAt            string             `json:"updated_at" format:"date-time" example:"2024-05-01T09:05:00Z"`
+	CompletedAt          *string            `json:"completed_at" format:"date-time" example:"2024-05-02T10:00:00Z"`
+	ContentHash          string             `json:"content_hash" doc:"SHA-256 over the canonical JSON form of the task" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Links                []TaskLinkResponse `json:"links,omitempty" doc:"Linked commits, branches and pull requests with their CI and review status (get-task only)"`
 }
 
 type DecisionResponse struct {
@@ -143,17 +339,134 @@ type DecisionResponse struct {
 	Title        string         `json:"title"`
 	Decision     string         `json:"decision"`
 	DeciderID    string         `json:"decider_id"`
+	Status       string         `json:"status"`
 	Context      map[string]any `json:"context,omitempty"`
 	Rationale    []string       `json:"rationale"`
 	Alternatives []string       `json:"alternatives"`
 	CreatedAt    string         `json:"created_at" format:"date-time"`
+	ContentHash  string         `json:"content_hash" doc:"SHA-256 over the canonical JSON form of the decision"`
+}
+
+type ArtifactResponse struct {
+	ID         string            `json:"id"`
+	ProjectID  string            `json:"project_id"`
+	Name       string            `json:"name"`
+	MediaType  string            `json:"media_type"`
+	Size       int64             `json:"size"`
+	Digest     string            `json:"digest"`
+	UploadedBy string            `json:"uploaded_by"`
+	CreatedAt  string            `json:"created_at" format:"date-time"`
+	Ref        map[string]string `json:"ref" doc:"Attachment reference to embed in attestation payloads or work outcomes"`
 }
 
 type LeaseResponse struct {
+	TaskID         string     `json:"task_id"`
+	OwnerID        string     `json:"owner_id"`
+	AcquiredAt     string     `json:"acquired_at" format:"date-time"`
+	ExpiresAt      string     `json:"expires_at" format:"date-time"`
+	PendingOwnerID *string    `json:"pending_owner_id,omitempty" doc:"Actor offered the lease, awaiting acceptance"`
+	Plan           *LeasePlan `json:"plan,omitempty" doc:"Plan the owner declared with the claim"`
+}
+
+// ProjectLeasesResponse lists the active leases of a project.
+type ProjectLeasesResponse struct {
+	ProjectID string          `json:"project_id" example:"workline"`
+	Leases    []LeaseResponse `json:"leases"`
+}
+
+// LeasePlan is how the claimant intends to work the task.
+type LeasePlan struct {
+	Approach         string `json:"approach" minLength:"1" doc:"Intended approach"`
+	EstimatedSeconds int    `json:"estimated_seconds,omitempty" minimum:"0" doc:"Estimated duration of the work"`
+}
+
+// ClaimLeaseRequest declares a plan with a claim; the body is optional.
+type ClaimLeaseRequest struct {
+	Plan *LeasePlan `json:"plan,omitempty" doc:"Stored on the lease for supervisors to review before work starts"`
+}
+
+// ClaimLeaseResponse is the lease as it stands after a claim. With wait=true and the lease
+// held by another actor, Waiting places the caller in the queue.
+type ClaimLeaseResponse struct {
+	LeaseResponse
+	Waiting *LeaseWaiterResponse `json:"waiting,omitempty" doc:"Queue entry of the caller, granted the lease when it is released or expires"`
+}
+
+type LeaseWaiterResponse struct {
+	TaskID       string `json:"task_id"`
+	ActorID      string `json:"actor_id"`
+	Position     int    `json:"position" doc:"1 for the next actor to be granted the lease"`
+	LeaseSeconds int    `json:"lease_seconds"`
+	EnqueuedAt   string `json:"enqueued_at" format:"date-time"`
+}
+
+type LeaseWaitersResponse struct {
+	TaskID  string                `json:"task_id"`
+	Waiters []LeaseWaiterResponse `json:"waiters"`
+}
+
+type AssignTaskRequest struct {
+	ActorID     string `json:"actor_id" example:"dev-2"`
+	Role        string `json:"role" enum:"driver,reviewer" example:"reviewer"`
+	HandoffNote string `json:"handoff_note,omitempty" doc:"Context for the new assignee"`
+}
+
+type TaskAssigneeResponse struct {
 	TaskID     string `json:"task_id"`
-	OwnerID    string `json:"owner_id"`
-	AcquiredAt string `json:"acquired_at" format:"date-time"`
-	ExpiresAt  string `json:"expires_at" format:"date-time"`
+	ActorID    string `json:"actor_id"`
+	Role       string `json:"role"`
+	AssignedAt string `json:"assigned_at" format:"date-time"`
+}
+
+type TaskHandoffResponse struct {
+	ID             int64   `json:"id"`
+	TaskID         string  `json:"task_id"`
+	FromAssigneeID *string `json:"from_assignee_id,omitempty"`
+	ToAssigneeID   *string `json:"to_assignee_id,omitempty"`
+	ActorID        string  `json:"actor_id"`
+	Note           string  `json:"note,omitempty"`
+	CreatedAt      string  `json:"created_at" format:"date-time"`
+}
+
+type TaskCompletionResponse struct {
+	ID          int64   `json:"id"`
+	TaskID      string  `json:"task_id"`
+	Status      string  `json:"status" enum:"pending,approved,rejected,withdrawn"`
+	SubmittedBy string  `json:"submitted_by"`
+	SubmittedAt string  `json:"submitted_at" format:"date-time"`
+	DecidedBy   *string `json:"decided_by,omitempty"`
+	DecidedAt   *string `json:"decided_at,omitempty" format:"date-time"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+type TaskLinkResponse struct {
+	ID              int64  `json:"id" example:"1"`
+	TaskID          string `json:"task_id" example:"task-123"`
+	Kind            string `json:"kind" enum:"commit,branch,pr" example:"pr"`
+	Target          string `json:"target" example:"https://gitlab.example.com/team/app/-/merge_requests/42"`
+	HeadRef         string `json:"head_ref,omitempty" doc:"Source branch of a pull request, learned from its webhooks" example:"feature/login"`
+	CIStatus        string `json:"ci_status,omitempty" example:"success"`
+	CIURL           string `json:"ci_url,omitempty"`
+	ReviewStatus    string `json:"review_status,omitempty" enum:"open,approved,changes_requested,merged,closed" example:"approved"`
+	Provider        string `json:"provider,omitempty" example:"gitlab"`
+	StatusUpdatedAt string `json:"status_updated_at,omitempty" format:"date-time"`
+	CreatedBy       string `json:"created_by"`
+	CreatedAt       string `json:"created_at" format:"date-time"`
+}
+
+type AddTaskLinkRequest struct {
+	Kind   string `json:"kind" enum:"commit,branch,pr" doc:"What the target is"`
+	Target string `json:"target" doc:"Commit SHA (7+ hex digits), branch name, or pull request URL"`
+}
+
+type RejectCompletionRequest struct {
+	Reason string `json:"reason" doc:"Why the completion is rejected"`
+}
+
+type LeaseTransferRequest struct {
+	ToActorID      string `json:"to_actor_id" example:"dev-2"`
+	RequireConsent bool   `json:"require_consent,omitempty" doc:"Offer the lease; the target must accept before ownership moves"`
+	LeaseSeconds   int    `json:"lease_seconds,omitempty" doc:"New lease term; the remaining term carries over when omitted"`
 }
 
 type WorkOutcomesUpdateResponse struct {
@@ -163,15 +476,16 @@ type WorkOutcomesUpdateResponse struct {
 }
 
 type AttestationResponse struct {
-	ID         string         `json:"id"`
-	OrgID      string         `json:"org_id"`
-	ProjectID  string         `json:"project_id"`
-	EntityKind string         `json:"entity_kind" enum:"project,iteration,task,decision"`
-	EntityID   string         `json:"entity_id"`
-	Kind       string         `json:"kind"`
-	ActorID    string         `json:"actor_id"`
-	TS         string         `json:"ts" format:"date-time"`
-	Payload    map[string]any `json:"payload,omitempty"`
+	ID          string         `json:"id"`
+	OrgID       string         `json:"org_id"`
+	ProjectID   string         `json:"project_id"`
+	EntityKind  string         `json:"entity_kind" enum:"project,iteration,task,decision,attestation"`
+	EntityID    string         `json:"entity_id"`
+	Kind        string         `json:"kind"`
+	ActorID     string         `json:"actor_id"`
+	TS          string         `json:"ts" format:"date-time"`
+	Payload     map[string]any `json:"payload,omitempty"`
+	ContentHash string         `json:"content_hash" doc:"SHA-256 over the canonical JSON form of the attestation"`
 }
 
 type EventResponse struct {
@@ -180,23 +494,73 @@ type EventResponse struct {
 	TS         string         `json:"ts" format:"date-time"`
 	Type       string         `json:"type"`
 	ProjectID  string         `json:"project_id,omitempty"`
-	EntityKind string         `json:"entity_kind" enum:"project,iteration,task,decision,rbac"`
+	EntityKind string         `json:"entity_kind" enum:"project,iteration,task,decision,attestation,rbac"`
 	EntityID   string         `json:"entity_id,omitempty"`
 	ActorID    string         `json:"actor_id"`
 	Payload    map[string]any `json:"payload"`
+	PrevHash   string         `json:"prev_hash,omitempty" doc:"this_hash of the previous event in the project chain"`
+	ThisHash   string         `json:"this_hash,omitempty" doc:"SHA-256 over prev_hash and the canonical JSON form of this event"`
+	RequestID  string         `json:"request_id,omitempty" doc:"X-Request-Id of the API request that recorded the event"`
+}
+
+type EventChainResponse struct {
+	ProjectID string `json:"project_id" example:"workline"`
+	Valid     bool   `json:"valid"`
+	Checked   int    `json:"checked" doc:"Chained events verified"`
+	Unchained int    `json:"unchained" doc:"Events recorded before the chain was introduced"`
+	HeadHash  string `json:"head_hash,omitempty" doc:"this_hash of the last verified event"`
+	BrokenAt  int64  `json:"broken_at,omitempty" doc:"Id of the first event that fails verification"`
+	Reason    string `json:"reason,omitempty"`
 }
 
 type ValidationStatusResponse struct {
-	Required  []string `json:"required" example:"[\"ci.passed\",\"review.approved\"]"`
-	Present   []string `json:"present" example:"[\"ci.passed\"]"`
-	Missing   []string `json:"missing" example:"[\"review.approved\"]"`
-	Satisfied bool     `json:"satisfied" example:"false"`
+	Required []string `json:"required" example:"[\"ci.passed\",\"review.approved\"]"`
+	// Sources explains each required entry, in the order of Required.
+	Sources []engine.RequirementSource `json:"sources"`
+	Present []string                   `json:"present" example:"[\"ci.passed\"]"`
+	Missing []string                   `json:"missing" example:"[\"review.approved\"]"`
+	Waived  []string                   `json:"waived" example:"[]"`
+	Waivers []WaiverResponse           `json:"waivers"`
+	// Risk explains the risk score when policies.risk is configured; its missing attesters
+	// keep the task unsatisfied.
+	Risk      *engine.TaskRiskReport `json:"risk,omitempty"`
+	Satisfied bool                   `json:"satisfied" example:"false"`
+}
+
+type CreateWaiverRequest struct {
+	Kind          string `json:"kind" example:"security.approved"`
+	Justification string `json:"justification" example:"Scanner outage; manual review done in #412"`
+	ExpiresAt     string `json:"expires_at" format:"date-time" example:"2024-06-01T00:00:00Z"`
+}
+
+type WaiverResponse struct {
+	ID            string `json:"id"`
+	TaskID        string `json:"task_id"`
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+	ActorID       string `json:"actor_id"`
+	ExpiresAt     string `json:"expires_at" format:"date-time"`
+	CreatedAt     string `json:"created_at" format:"date-time"`
+}
+
+type SeedResponse struct {
+	Kinds   []string       `json:"kinds"`
+	Presets []string       `json:"presets"`
+	Roles   []string       `json:"roles"`
+	Actors  []string       `json:"actors"`
+	Tasks   []TaskResponse `json:"tasks"`
 }
 
 type ProjectConfigResponse struct {
 	Project      projectConfigSection     `json:"project"`
 	Attestations attestationConfigSection `json:"attestations"`
 	Policies     policyConfigSection      `json:"policies"`
+	Transitions  transitionConfigSection  `json:"transitions"`
+}
+
+type transitionConfigSection struct {
+	Task  map[string][]string            `json:"task" doc:"Allowed task status transitions (from -> to list)"`
+	Types map[string]map[string][]string `json:"types,omitempty" doc:"Per task type overrides of the task transitions"`
 }
 
 type projectConfigSection struct {
@@ -205,9 +569,15 @@ type projectConfigSection struct {
 }
 
 type attestationConfigSection struct {
-	Catalog map[string]struct {
-		Description string `json:"description"`
-	} `json:"catalog"`
+	Catalog      map[string]attestationKindConfig `json:"catalog"`
+	OnDeprecated string                           `json:"on_deprecated,omitempty" enum:"warn,reject" doc:"What happens to new attestations of deprecated kinds; warn when empty"`
+}
+
+type attestationKindConfig struct {
+	Description string `json:"description"`
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	ReplacedBy  string `json:"replaced_by,omitempty" doc:"Kind replacing this deprecated kind"`
+	GraceUntil  string `json:"grace_until,omitempty" format:"date" doc:"Last day the replacement satisfies requirements of this kind; open-ended when empty"`
 }
 
 type policyConfigSection struct {
@@ -229,6 +599,8 @@ type policyPresetResponse struct {
 type paginatedTasks struct {
 	Items      []TaskResponse `json:"items"`
 	NextCursor string         `json:"next_cursor,omitempty"`
+	// SnapshotAt is when the list was captured, for pages served from a snapshot.
+	SnapshotAt string `json:"snapshot_at,omitempty" format:"date-time"`
 }
 
 type paginatedIterations struct {
@@ -241,14 +613,120 @@ type paginatedAttestations struct {
 	NextCursor string                `json:"next_cursor,omitempty"`
 }
 
+type paginatedDecisions struct {
+	Items      []DecisionResponse `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type paginatedArtifacts struct {
+	Items      []ArtifactResponse `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
 type paginatedEvents struct {
 	Items      []EventResponse `json:"items"`
 	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
+type ActivitySummary struct {
+	TasksCreated       int `json:"tasks_created"`
+	TasksClaimed       int `json:"tasks_claimed"`
+	TasksCompleted     int `json:"tasks_completed"`
+	AttestationsIssued int `json:"attestations_issued"`
+	DecisionsMade      int `json:"decisions_made"`
+}
+
+type ActorActivityResponse struct {
+	ActorID    string          `json:"actor_id" example:"dev-1"`
+	ProjectID  string          `json:"project_id" example:"workline"`
+	Since      string          `json:"since,omitempty" format:"date-time"`
+	Summary    ActivitySummary `json:"summary"`
+	Counts     map[string]int  `json:"counts" doc:"Event counts per type for the actor"`
+	Items      []EventResponse `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type WebhookResponse struct {
+	Provider     string                `json:"provider" example:"gitlab"`
+	Event        string                `json:"event" example:"pipeline.success"`
+	Kind         string                `json:"kind,omitempty" example:"ci.passed"`
+	Ignored      bool                  `json:"ignored" doc:"True when the event neither recorded attestations nor updated task links"`
+	Reason       string                `json:"reason,omitempty" example:"no kind mapping for event"`
+	TaskIDs      []string              `json:"task_ids"`
+	Attestations []AttestationResponse `json:"attestations"`
+	Links        []TaskLinkResponse    `json:"links" doc:"Task links whose CI or review status the event updated"`
+}
+
+type TestReportResponse struct {
+	TaskID      string                     `json:"task_id" example:"task-123"`
+	Format      string                     `json:"format" enum:"junit,tap" example:"junit"`
+	Kind        string                     `json:"kind" example:"tests.passed"`
+	Tests       int                        `json:"tests" example:"42"`
+	Passed      int                        `json:"passed" example:"40"`
+	Failed      int                        `json:"failed" example:"1"`
+	Errors      int                        `json:"errors" example:"0"`
+	Skipped     int                        `json:"skipped" example:"1"`
+	Failures    []integrations.TestFailure `json:"failures"`
+	Attestation AttestationResponse        `json:"attestation"`
+}
+
+type SARIFResponse struct {
+	TaskID      string                     `json:"task_id" example:"task-123"`
+	Kind        string                     `json:"kind" example:"security.scan"`
+	Tools       []string                   `json:"tools" example:"[\"CodeQL\"]"`
+	Findings    int                        `json:"findings" example:"3"`
+	BySeverity  map[string]int             `json:"by_severity" example:"{\"critical\":0,\"high\":1,\"medium\":2,\"low\":0,\"note\":0}"`
+	Suppressed  int                        `json:"suppressed" example:"1"`
+	Top         []integrations.ScanFinding `json:"top"`
+	Attestation AttestationResponse        `json:"attestation"`
+}
+
+type TimeseriesPoint struct {
+	Day   string   `json:"day" format:"date" example:"2024-05-01"`
+	Value *float64 `json:"value" example:"12"`
+}
+
+type TimeseriesResponse struct {
+	ProjectID string            `json:"project_id" example:"workline"`
+	Metric    string            `json:"metric" example:"tasks_done"`
+	From      string            `json:"from" format:"date" example:"2024-04-01"`
+	To        string            `json:"to" format:"date" example:"2024-05-01"`
+	Points    []TimeseriesPoint `json:"points"`
+}
+
 type RoleChangeRequest struct {
-	ActorID string `json:"actor_id"`
-	RoleID  string `json:"role_id"`
+	ActorID   string `json:"actor_id"`
+	RoleID    string `json:"role_id"`
+	ExpiresAt string `json:"expires_at,omitempty" format:"date-time" doc:"Grant only: the role lapses at this time; omit for a permanent grant"`
+}
+
+type CreateTeamRequest struct {
+	ID          string `json:"id" example:"qa"`
+	Description string `json:"description,omitempty" example:"Quality assurance"`
+}
+
+type UpdateTeamRequest struct {
+	Description string `json:"description"`
+}
+
+type TeamRoleRequest struct {
+	RoleID    string `json:"role_id" example:"reviewer"`
+	ExpiresAt string `json:"expires_at,omitempty" format:"date-time" doc:"The role lapses at this time; omit for a permanent grant"`
+}
+
+type TeamRoleResponse struct {
+	RoleID    string  `json:"role_id"`
+	ExpiresAt *string `json:"expires_at,omitempty" format:"date-time"`
+}
+
+type TeamResponse struct {
+	ID          string             `json:"id" example:"qa"`
+	ProjectID   string             `json:"project_id" example:"workline"`
+	Description string             `json:"description,omitempty"`
+	Members     []string           `json:"members" example:"[\"dev-1\",\"dev-2\"]"`
+	Roles       []TeamRoleResponse `json:"roles" doc:"Roles granted to every member, with the attestation authorities of those roles"`
+	CreatedBy   string             `json:"created_by"`
+	CreatedAt   string             `json:"created_at" format:"date-time"`
 }
 
 type AttestationAuthorityRequest struct {
@@ -256,11 +734,103 @@ type AttestationAuthorityRequest struct {
 	RoleID string `json:"role_id"`
 }
 
+type BulkAttestationItemResponse struct {
+	Index       int                  `json:"index"`
+	Status      string               `json:"status" enum:"created,failed,rolled_back"`
+	Attestation *AttestationResponse `json:"attestation,omitempty"`
+	Error       *apiErrorBody        `json:"error,omitempty"`
+	HTTPStatus  int                  `json:"http_status,omitempty" doc:"Status the item would have received from the single-item endpoint"`
+	Warning     string               `json:"warning,omitempty" doc:"Set when the attestation kind is deprecated"`
+}
+
+type ImportDependenciesResponse struct {
+	Added    []DependencyEdge `json:"added" doc:"Edges created by this import"`
+	Existing []DependencyEdge `json:"existing" doc:"Edges that were already present"`
+}
+
+type BulkAttestationResponse struct {
+	Committed bool                          `json:"committed"`
+	Created   int                           `json:"created"`
+	Failed    int                           `json:"failed"`
+	Items     []BulkAttestationItemResponse `json:"items"`
+}
+
+type MemberRoleResponse struct {
+	RoleID    string  `json:"role_id"`
+	ExpiresAt *string `json:"expires_at,omitempty" format:"date-time"`
+	TeamID    *string `json:"team_id,omitempty" doc:"Set when the role is held through a team"`
+}
+
+type MemberResponse struct {
+	ActorID     string               `json:"actor_id"`
+	Roles       []MemberRoleResponse `json:"roles"`
+	Permissions []string             `json:"permissions"`
+}
+
+type paginatedMembers struct {
+	Items      []MemberResponse `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type AuthorityHolderResponse struct {
+	ActorID   string  `json:"actor_id"`
+	ExpiresAt *string `json:"expires_at,omitempty" format:"date-time"`
+	TeamID    *string `json:"team_id,omitempty" doc:"Set when the role is held through a team"`
+}
+
+type AttestationAuthorityResponse struct {
+	Kind    string                    `json:"kind" doc:"Attestation kind, or a pattern ending in *" example:"review.approved"`
+	RoleID  string                    `json:"role_id" example:"reviewer"`
+	Holders []AuthorityHolderResponse `json:"holders" doc:"Actors holding the role now"`
+	History []EventResponse           `json:"history" doc:"Events that allowed or denied the kind to the role, or granted, revoked or expired the role, oldest first"`
+}
+
+type UnauthorizedAttestationResponse struct {
+	ID         string `json:"id"`
+	EntityKind string `json:"entity_kind" enum:"project,iteration,task,decision,attestation"`
+	EntityID   string `json:"entity_id"`
+	Kind       string `json:"kind"`
+	ActorID    string `json:"actor_id"`
+	TS         string `json:"ts" format:"date-time"`
+}
+
+type AttestationAuthorityReportResponse struct {
+	ProjectID    string                            `json:"project_id"`
+	GeneratedAt  string                            `json:"generated_at" format:"date-time"`
+	Authorities  []AttestationAuthorityResponse    `json:"authorities"`
+	Unauthorized []UnauthorizedAttestationResponse `json:"unauthorized" doc:"Attestations whose actor lacks authority for their kind under the current grants; expected to be empty"`
+}
+
+// paginatedNotifications is a page of the caller's inbox, newest first.
+type paginatedNotifications struct {
+	Items      []domain.Notification `json:"items"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	Unread     int                   `json:"unread" doc:"Unread notifications of the caller in the project"`
+}
+
+// EmailPreferencesRequest replaces the caller's email preferences.
+type EmailPreferencesRequest struct {
+	Email  string   `json:"email,omitempty" example:"ada@example.com" doc:"Bare address; empty stops the mails"`
+	Events []string `json:"events" doc:"Events to be mailed about: decision.created, iteration.validated or waiver.granted"`
+}
+
+type ReadAllNotificationsResponse struct {
+	Marked int `json:"marked" doc:"Notifications marked read by this request"`
+}
+
 type WhoAmIResponse struct {
-	ActorID     string   `json:"actor_id"`
-	OrgID       string   `json:"org_id"`
-	Roles       []string `json:"roles"`
-	Permissions []string `json:"permissions"`
+	ActorID     string               `json:"actor_id"`
+	OrgID       string               `json:"org_id"`
+	Roles       []string             `json:"roles"`
+	Permissions []string             `json:"permissions"`
+	Projects    []MembershipResponse `json:"projects,omitempty" doc:"Projects of the org where the actor holds a role, with its roles and permissions in each (GET /me only)"`
+}
+
+type MembershipResponse struct {
+	ProjectID   string               `json:"project_id"`
+	OrgID       string               `json:"org_id"`
+	Roles       []MemberRoleResponse `json:"roles"`
+	Permissions []string             `json:"permissions"`
 }
 
 type DevLoginRequest struct {
@@ -278,24 +848,64 @@ type DevLoginResponse struct {
 
 func projectResponse(p domain.Project) ProjectResponse {
 	return ProjectResponse{
-		ID:          p.ID,
-		OrgID:       p.OrgID,
-		Kind:        p.Kind,
-		Status:      p.Status,
-		Description: p.Description,
-		CreatedAt:   p.CreatedAt,
+		ID:              p.ID,
+		OrgID:           p.OrgID,
+		Kind:            p.Kind,
+		Status:          p.Status,
+		Description:     p.Description,
+		CreatedAt:       p.CreatedAt,
+		ParentProjectID: p.ParentProjectID,
+		DisplayName:     p.DisplayName,
+		Tags:            p.Tags,
+		Metadata:        p.Metadata,
+	}
+}
+
+func viewResponse(v domain.View) ViewResponse {
+	return ViewResponse{
+		ID:          v.ID,
+		ProjectID:   v.ProjectID,
+		Name:        v.Name,
+		Description: v.Description,
+		Filters:     ViewFiltersRequest(v.Filters),
+		Visibility:  v.Visibility,
+		Roles:       v.Roles,
+		OwnerID:     v.OwnerID,
+		CreatedAt:   v.CreatedAt,
+		UpdatedAt:   v.UpdatedAt,
 	}
 }
 
 func iterationResponse(it domain.Iteration) IterationResponse {
 	return IterationResponse{
-		ID:        it.ID,
-		OrgID:     it.OrgID,
-		ProjectID: it.ProjectID,
-		Goal:      it.Goal,
-		Status:    it.Status,
-		CreatedAt: it.CreatedAt,
+		ID:         it.ID,
+		OrgID:      it.OrgID,
+		ProjectID:  it.ProjectID,
+		Goal:       it.Goal,
+		Status:     it.Status,
+		CreatedAt:  it.CreatedAt,
+		CarriedOut: it.CarriedOut,
+		CarriedIn:  it.CarriedIn,
+		KeyResults: keyResultResponses(it.KeyResults),
+		FrozenAt:   it.FrozenAt,
+		FrozenBy:   it.FrozenBy,
+	}
+}
+
+func keyResultResponses(krs []domain.KeyResult) []KeyResultResponse {
+	var out []KeyResultResponse
+	for _, kr := range krs {
+		out = append(out, KeyResultResponse{
+			Metric:        kr.Metric,
+			Target:        kr.Target,
+			Current:       kr.Current,
+			Direction:     kr.Direction,
+			SourceKind:    kr.SourceKind,
+			AttestationID: kr.AttestationID,
+			Met:           kr.Met,
+		})
 	}
+	return out
 }
 
 func taskResponse(t domain.Task) TaskResponse {
@@ -313,11 +923,96 @@ func taskResponse(t domain.Task) TaskResponse {
 		Status:               t.Status,
 		AssigneeID:           t.AssigneeID,
 		WorkOutcomes:         workOutcomes,
+		CustomFields:         decodeJSONMap(t.CustomFieldsJSON),
 		RequiredAttestations: nonNilSlice(req),
+		RequiredCapabilities: nonNilSlice(t.RequiredCapabilities),
+		Labels:               nonNilSlice(t.Labels),
+		RiskScore:            t.RiskScore,
+		RiskLevel:            t.RiskLevel,
 		DependsOn:            nonNilSlice(t.DependsOn),
+		DeferUntil:           t.DeferUntil,
+		DueAt:                t.DueAt,
+		Rank:                 t.Rank,
 		CreatedAt:            t.CreatedAt,
 		UpdatedAt:            t.UpdatedAt,
 		CompletedAt:          t.CompletedAt,
+		ContentHash:          t.ContentHash,
+	}
+}
+
+func membershipResponse(m domain.Membership) MembershipResponse {
+	resp := MembershipResponse{ProjectID: m.ProjectID, OrgID: m.OrgID, Roles: []MemberRoleResponse{}, Permissions: nonNilSlice(m.Permissions)}
+	for _, g := range m.Roles {
+		resp.Roles = append(resp.Roles, MemberRoleResponse{RoleID: g.RoleID, ExpiresAt: g.ExpiresAt, TeamID: g.TeamID})
+	}
+	return resp
+}
+
+func memberResponse(m domain.Member) MemberResponse {
+	resp := MemberResponse{ActorID: m.ActorID, Roles: []MemberRoleResponse{}, Permissions: nonNilSlice(m.Permissions)}
+	for _, g := range m.Roles {
+		resp.Roles = append(resp.Roles, MemberRoleResponse{RoleID: g.RoleID, ExpiresAt: g.ExpiresAt, TeamID: g.TeamID})
+	}
+	return resp
+}
+
+func attestationAuthorityReportResponse(r engine.AttestationAuthorityReport) AttestationAuthorityReportResponse {
+	resp := AttestationAuthorityReportResponse{
+		ProjectID:    r.ProjectID,
+		GeneratedAt:  r.GeneratedAt,
+		Authorities:  []AttestationAuthorityResponse{},
+		Unauthorized: []UnauthorizedAttestationResponse{},
+	}
+	for _, a := range r.Authorities {
+		out := AttestationAuthorityResponse{Kind: a.Kind, RoleID: a.RoleID, Holders: []AuthorityHolderResponse{}, History: []EventResponse{}}
+		for _, g := range a.Holders {
+			out.Holders = append(out.Holders, AuthorityHolderResponse{ActorID: g.ActorID, ExpiresAt: g.ExpiresAt, TeamID: g.TeamID})
+		}
+		for _, evt := range a.History {
+			out.History = append(out.History, eventResponse(evt))
+		}
+		resp.Authorities = append(resp.Authorities, out)
+	}
+	for _, att := range r.Unauthorized {
+		resp.Unauthorized = append(resp.Unauthorized, UnauthorizedAttestationResponse{
+			ID:         att.ID,
+			EntityKind: att.EntityKind,
+			EntityID:   att.EntityID,
+			Kind:       att.Kind,
+			ActorID:    att.ActorID,
+			TS:         att.TS,
+		})
+	}
+	return resp
+}
+
+func teamResponse(t domain.Team) TeamResponse {
+	resp := TeamResponse{
+		ID:          t.ID,
+		ProjectID:   t.ProjectID,
+		Description: t.Description,
+		Members:     nonNilSlice(t.Members),
+		Roles:       []TeamRoleResponse{},
+		CreatedBy:   t.CreatedBy,
+		CreatedAt:   t.CreatedAt,
+	}
+	for _, g := range t.Roles {
+		resp.Roles = append(resp.Roles, TeamRoleResponse{RoleID: g.RoleID, ExpiresAt: g.ExpiresAt})
+	}
+	return resp
+}
+
+func artifactResponse(a domain.Artifact) ArtifactResponse {
+	return ArtifactResponse{
+		ID:         a.ID,
+		ProjectID:  a.ProjectID,
+		Name:       a.Name,
+		MediaType:  a.MediaType,
+		Size:       a.Size,
+		Digest:     a.Digest,
+		UploadedBy: a.UploadedBy,
+		CreatedAt:  a.CreatedAt,
+		Ref:        map[string]string{"$artifact": a.ID},
 	}
 }
 
@@ -329,27 +1024,74 @@ func decisionResponse(d domain.Decision) DecisionResponse {
 		Title:        d.Title,
 		Decision:     d.Decision,
 		DeciderID:    d.DeciderID,
+		Status:       d.Status,
 		Context:      decodeJSONMap(strPtr(d.ContextJSON)),
 		Rationale:    nonNilSlice(decodeStringSlice(strPtr(d.RationaleJSON))),
 		Alternatives: nonNilSlice(decodeStringSlice(strPtr(d.AlternativesJSON))),
 		CreatedAt:    d.CreatedAt,
+		ContentHash:  d.ContentHash,
 	}
 }
 
 func attestationResponse(a domain.Attestation) AttestationResponse {
 	return AttestationResponse{
-		ID:         a.ID,
-		OrgID:      a.OrgID,
-		ProjectID:  a.ProjectID,
-		EntityKind: a.EntityKind,
-		EntityID:   a.EntityID,
-		Kind:       a.Kind,
-		ActorID:    a.ActorID,
-		TS:         a.TS,
-		Payload:    decodeJSONMap(strPtr(a.PayloadJSON)),
+		ID:          a.ID,
+		OrgID:       a.OrgID,
+		ProjectID:   a.ProjectID,
+		EntityKind:  a.EntityKind,
+		EntityID:    a.EntityID,
+		Kind:        a.Kind,
+		ActorID:     a.ActorID,
+		TS:          a.TS,
+		Payload:     decodeJSONMap(strPtr(a.PayloadJSON)),
+		ContentHash: a.ContentHash,
+	}
+}
+
+// activitySummary condenses per-type event counts; completed counts task.done events and
+// updates that moved a task to done, which record only task.updated.
+func activitySummary(counts map[string]int, completed int) ActivitySummary {
+	return ActivitySummary{
+		TasksCreated:       counts["task.created"],
+		TasksClaimed:       counts["lease.claimed"],
+		TasksCompleted:     completed,
+		AttestationsIssued: counts["attestation.added"],
+		DecisionsMade:      counts["decision.created"],
+	}
+}
+
+func waiverResponse(w domain.Waiver) WaiverResponse {
+	return WaiverResponse{
+		ID:            w.ID,
+		TaskID:        w.TaskID,
+		Kind:          w.Kind,
+		Justification: w.Justification,
+		ActorID:       w.ActorID,
+		ExpiresAt:     w.ExpiresAt,
+		CreatedAt:     w.CreatedAt,
 	}
 }
 
+// statsMetric extracts one metric from a snapshot; lead time is nil on days without completions.
+func statsMetric(s domain.StatsSnapshot, metric string) *float64 {
+	var v float64
+	switch metric {
+	case "tasks_open":
+		v = float64(s.TasksOpen)
+	case "tasks_done":
+		v = float64(s.TasksDone)
+	case "tasks_completed":
+		v = float64(s.TasksCompleted)
+	case "attestations_issued":
+		v = float64(s.AttestationsIssued)
+	case "lead_time_seconds":
+		return s.LeadTimeSeconds
+	default:
+		return nil
+	}
+	return &v
+}
+
 func eventResponse(e domain.Event) EventResponse {
 	return EventResponse{
 		ID:         e.ID,
@@ -361,6 +1103,60 @@ func eventResponse(e domain.Event) EventResponse {
 		EntityID:   e.EntityID,
 		ActorID:    e.ActorID,
 		Payload:    decodeJSONMap(strPtr(e.Payload)),
+		PrevHash:   e.PrevHash,
+		ThisHash:   e.ThisHash,
+		RequestID:  e.RequestID,
+	}
+}
+
+func taskAssigneeResponse(a domain.TaskAssignee) TaskAssigneeResponse {
+	return TaskAssigneeResponse{
+		TaskID:     a.TaskID,
+		ActorID:    a.ActorID,
+		Role:       a.Role,
+		AssignedAt: a.AssignedAt,
+	}
+}
+
+func taskCompletionResponse(c domain.TaskCompletion) TaskCompletionResponse {
+	return TaskCompletionResponse{
+		ID:          c.ID,
+		TaskID:      c.TaskID,
+		Status:      c.Status,
+		SubmittedBy: c.SubmittedBy,
+		SubmittedAt: c.SubmittedAt,
+		DecidedBy:   c.DecidedBy,
+		DecidedAt:   c.DecidedAt,
+		Reason:      c.Reason,
+	}
+}
+
+func taskLinkResponse(l domain.TaskLink) TaskLinkResponse {
+	return TaskLinkResponse{
+		ID:              l.ID,
+		TaskID:          l.TaskID,
+		Kind:            l.Kind,
+		Target:          l.Target,
+		HeadRef:         l.HeadRef,
+		CIStatus:        l.CIStatus,
+		CIURL:           l.CIURL,
+		ReviewStatus:    l.ReviewStatus,
+		Provider:        l.Provider,
+		StatusUpdatedAt: l.StatusUpdatedAt,
+		CreatedBy:       l.CreatedBy,
+		CreatedAt:       l.CreatedAt,
+	}
+}
+
+func taskHandoffResponse(h domain.TaskHandoff) TaskHandoffResponse {
+	return TaskHandoffResponse{
+		ID:             h.ID,
+		TaskID:         h.TaskID,
+		FromAssigneeID: h.FromAssigneeID,
+		ToAssigneeID:   h.ToAssigneeID,
+		ActorID:        h.ActorID,
+		Note:           h.Note,
+		CreatedAt:      h.CreatedAt,
 	}
 }
 
@@ -370,6 +1166,26 @@ func leaseResponse(l domain.Lease) LeaseResponse {
 		OwnerID:    l.OwnerID,
 		AcquiredAt: l.AcquiredAt,
 		ExpiresAt:  l.ExpiresAt,
+
+		PendingOwnerID: l.PendingOwnerID,
+		Plan:           leasePlanResponse(l.Plan),
+	}
+}
+
+func leasePlanResponse(p *domain.LeasePlan) *LeasePlan {
+	if p == nil {
+		return nil
+	}
+	return &LeasePlan{Approach: p.Approach, EstimatedSeconds: p.EstimatedSeconds}
+}
+
+func leaseWaiterResponse(w domain.LeaseWaiter) LeaseWaiterResponse {
+	return LeaseWaiterResponse{
+		TaskID:       w.TaskID,
+		ActorID:      w.ActorID,
+		Position:     w.Position,
+		LeaseSeconds: w.LeaseSeconds,
+		EnqueuedAt:   w.EnqueuedAt,
 	}
 }
 
@@ -380,18 +1196,15 @@ func configResponse(cfg *config.Config) ProjectConfigResponse {
 			Kind: cfg.Project.Kind,
 		},
 		Attestations: attestationConfigSection{
-			Catalog: map[string]struct {
-				Description string `json:"description"`
-			}{},
+			Catalog:      map[string]attestationKindConfig{},
+			OnDeprecated: cfg.Attestations.OnDeprecated,
 		},
 		Policies: policyConfigSection{
 			Presets: map[string]policyPresetResponse{},
 		},
 	}
 	for k, v := range cfg.Attestations.Catalog {
-		res.Attestations.Catalog[k] = struct {
-			Description string `json:"description"`
-		}{Description: v.Description}
+		res.Attestations.Catalog[k] = attestationKindConfig{Description: v.Description, Deprecated: v.Deprecated, ReplacedBy: v.ReplacedBy, GraceUntil: v.GraceUntil}
 	}
 	for name, preset := range cfg.Policies.Presets {
 		res.Policies.Presets[name] = policyPresetResponse{
@@ -400,6 +1213,8 @@ func configResponse(cfg *config.Config) ProjectConfigResponse {
 	}
 	res.Policies.Defaults.Task = cfg.Policies.Defaults.Task
 	res.Policies.Defaults.Iteration.Validation.Require = cfg.Policies.Defaults.Iteration.Validation.Require
+	res.Transitions.Task = cfg.TaskTransitions("")
+	res.Transitions.Types = cfg.Transitions.Types
 	return res
 }
 
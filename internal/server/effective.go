@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/config"
+	"workline/internal/engine"
+	"workline/internal/repo"
+)
+
+// EffectiveLeasesResponse is the lease term policy in effect.
+type EffectiveLeasesResponse struct {
+	DefaultSeconds int `json:"default_seconds" example:"900" doc:"Term of claims that ask for none"`
+	MaxSeconds     int `json:"max_seconds" example:"3600" doc:"Longest term a claim may ask for; 0 when unlimited"`
+}
+
+// EffectiveAuthResponse is the authentication policy in effect.
+type EffectiveAuthResponse struct {
+	DevLogin bool `json:"dev_login" doc:"Whether POST /auth/dev/login mints tokens"`
+}
+
+// EffectiveConfigResponse reports the environment profile selected at startup and the
+// settings it resolves to for a project.
+type EffectiveConfigResponse struct {
+	ProjectID   string                       `json:"project_id"`
+	Environment string                       `json:"environment,omitempty" example:"prod"`
+	Profiles    []string                     `json:"profiles" doc:"Profiles the project config declares"`
+	Overrides   []string                     `json:"overrides" doc:"Settings the environment profile overrides, by YAML path"`
+	Leases      EffectiveLeasesResponse      `json:"leases"`
+	Auth        EffectiveAuthResponse        `json:"auth"`
+	Quotas      map[string]engine.ActorQuota `json:"quotas" doc:"Daily limits by role"`
+}
+
+func effectiveConfigResponse(cfg *config.Config) EffectiveConfigResponse {
+	eff := cfg.Effective()
+	res := EffectiveConfigResponse{
+		ProjectID:   cfg.Project.ID,
+		Environment: cfg.Environment,
+		Profiles:    cfg.ProfileNames(),
+		Overrides:   cfg.Overrides(),
+		Leases: EffectiveLeasesResponse{
+			DefaultSeconds: int(eff.Leases.Default() / time.Second),
+			MaxSeconds:     int(eff.Leases.Max() / time.Second),
+		},
+		Auth:   EffectiveAuthResponse{DevLogin: eff.Auth.DevLoginEnabled()},
+		Quotas: map[string]engine.ActorQuota{},
+	}
+	if res.Profiles == nil {
+		res.Profiles = []string{}
+	}
+	if res.Overrides == nil {
+		res.Overrides = []string{}
+	}
+	for role, q := range eff.Quotas.Roles {
+		res.Quotas[role] = engine.ActorQuota{Requests: q.Requests, Mutations: q.Mutations}
+	}
+	return res
+}
+
+func registerEffectiveConfig(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-effective-config",
+		Method:      http.MethodGet,
+		Path:        "/admin/config/effective",
+		Summary:     "Show the settings in effect after applying the environment profile",
+		Description: "Reports the environment selected at startup (wl serve --env or WORKLINE_ENV), the profiles the project config declares, which settings the selected profile overrides, and the resulting lease terms, dev login switch and quotas. The project is X-Project-Id or the workspace project. Dev login is decided once at startup from the workspace project.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct{}) (*struct {
+		Body EffectiveConfigResponse `json:"body"`
+	}, error) {
+		projectID := projectFromHeader(ctx, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.config.read"); err != nil {
+			return nil, handleError(err)
+		}
+		cfg, err := e.Store().GetProjectConfig(ctx, projectID)
+		if errors.Is(err, repo.ErrNotFound) && projectID == e.DefaultConfig().Project.ID {
+			cfg, err = e.DefaultConfig(), nil
+		}
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body EffectiveConfigResponse `json:"body"`
+		}{Body: effectiveConfigResponse(cfg)}, nil
+	})
+}
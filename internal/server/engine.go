@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"workline/internal/blob"
+	"workline/internal/config"
+	"workline/internal/db"
+	"workline/internal/domain"
+	"workline/internal/engine"
+	"workline/internal/events"
+	"workline/internal/integrations"
+	"workline/internal/manifest"
+	"workline/internal/repo"
+	"workline/internal/seed"
+)
+
+// Engine is what the handlers and middleware need from the workflow engine. engine.Engine
+// implements it; servertest.Engine stubs it, so custom middleware around the handlers can
+// be tested without a SQLite workspace.
+type Engine interface {
+	// Store serves the reads handlers make without engine logic, and the credential
+	// lookups of authentication.
+	Store() repo.Store
+	// DefaultConfig is the config of the default project, used when a request names none.
+	DefaultConfig() *config.Config
+	// BlobStore holds large attestation payloads; nil when they stay inline.
+	BlobStore() blob.Store
+	ActorHasPermission(ctx context.Context, projectID, actorID, perm string) (bool, error)
+	RecordDenial(ctx context.Context, projectID, actorID string, payload events.EventPayload) error
+
+	// Projects, programs and status.
+	InitProject(ctx context.Context, projectID, description, actorID string) (domain.Project, error)
+	InitProjectWithOptions(ctx context.Context, opts engine.ProjectInitOptions) (domain.Project, error)
+	EnsureProject(ctx context.Context, opts engine.ProjectInitOptions) (domain.Project, bool, error)
+	UpdateProject(ctx context.Context, opts engine.ProjectUpdateOptions) (domain.Project, error)
+	SetProjectParent(ctx context.Context, projectID, parentID, actorID string) (domain.Project, error)
+	ProjectFeatures(ctx context.Context, projectID string) ([]engine.FeatureState, error)
+	RequireFeature(ctx context.Context, projectID, feature string) error
+	SummarizeProgram(ctx context.Context, programID string, visible func(projectID string) bool) (engine.ProgramSummary, error)
+	ApplySeed(ctx context.Context, projectID, actorID string, s seed.File) (engine.SeedResult, error)
+	SyncProject(ctx context.Context, projectID, actorID string, m manifest.File, dryRun bool) (engine.SyncPlan, error)
+	PlanConfigChange(ctx context.Context, projectID string, next *config.Config) (engine.ConfigPlan, error)
+	UpdateProjectConfig(ctx context.Context, projectID, actorID string, next *config.Config) (engine.ConfigPlan, error)
+	WhoAmI(ctx context.Context, projectID, actorID string) (engine.WhoAmI, error)
+
+	// Orgs.
+	CreateOrg(ctx context.Context, id, name, actorID string) (domain.Org, error)
+	GetOrg(ctx context.Context, orgID, actorID string) (domain.Org, error)
+	ListOrgProjects(ctx context.Context, orgID, actorID string) ([]domain.Project, error)
+	ListOrgMembers(ctx context.Context, orgID, actorID string) ([]domain.OrgMember, error)
+	SetOrgRole(ctx context.Context, orgID, target, role, actorID string) (domain.OrgMember, error)
+	RemoveOrgMember(ctx context.Context, orgID, target, actorID string) error
+
+	// Tasks.
+	CreateTask(ctx context.Context, opts engine.TaskCreateOptions) (domain.Task, error)
+	UpdateTask(ctx context.Context, opts engine.TaskUpdateOptions) (domain.Task, error)
+	MoveTask(ctx context.Context, opts engine.TaskMoveOptions) (domain.Task, error)
+	CancelTask(ctx context.Context, opts engine.TaskCancelOptions) (engine.TaskCancellation, error)
+	TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID string, force bool) (domain.Task, error)
+	ApproveTaskCompletion(ctx context.Context, taskID, actorID string) (domain.Task, error)
+	RejectTaskCompletion(ctx context.Context, taskID, actorID, reason string) (domain.Task, error)
+	AddTaskLink(ctx context.Context, taskID, kind, target, actorID string) (domain.TaskLink, error)
+	RemoveTaskLink(ctx context.Context, taskID string, linkID int64, actorID string) error
+	SyncTaskLinks(ctx context.Context, projectID string, evt integrations.Event, actorID string) ([]domain.TaskLink, error)
+	ReadyTasks(ctx context.Context, projectID, actorID string) ([]domain.Task, error)
+	CaptureTaskSnapshot(ctx context.Context, scope, actorID string, f repo.TaskFilters) (domain.TaskListSnapshot, error)
+	TaskSnapshotPage(ctx context.Context, id, scope, actorID string, after, limit int) (domain.TaskListSnapshot, []domain.Task, error)
+	ImportDependencies(ctx context.Context, projectID string, edges []engine.DependencyEdge, actorID string) (engine.DependencyImport, error)
+	AssignTask(ctx context.Context, opts engine.TaskAssignOptions) (domain.TaskAssignee, error)
+	UnassignTask(ctx context.Context, opts engine.TaskAssignOptions) error
+	WaiveValidation(ctx context.Context, opts engine.WaiverCreateOptions) (domain.Waiver, error)
+	ActiveWaivers(ctx context.Context, taskID string) ([]domain.Waiver, error)
+	PresentRequirements(ctx context.Context, taskID string, required []string) (map[string]bool, error)
+	RequirementSources(ctx context.Context, t domain.Task) ([]engine.RequirementSource, error)
+	TaskRisk(ctx context.Context, t domain.Task) (*engine.TaskRiskReport, error)
+	TaskEvidence(ctx context.Context, taskID string) (engine.EvidenceBundle, error)
+	ExportSubtree(ctx context.Context, taskID string) (engine.SubtreeSnapshot, error)
+	TaskTree(ctx context.Context, f repo.TaskFilters) ([]engine.TaskTreeNode, error)
+	ImportSubtree(ctx context.Context, projectID string, snap engine.SubtreeSnapshot, opts engine.SubtreeImportOptions, actorID string) (engine.SubtreeImport, error)
+
+	// Leases.
+	ClaimLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error)
+	ClaimLeaseWithOptions(ctx context.Context, opts engine.LeaseClaimOptions) (engine.LeaseClaim, error)
+	ClaimNext(ctx context.Context, projectID, actorID string, leaseSeconds int) (domain.Task, domain.Lease, error)
+	DeferTask(ctx context.Context, taskID, until, actorID string) (domain.Task, error)
+	ReleaseLease(ctx context.Context, taskID, actorID string) error
+	TaskLease(ctx context.Context, taskID, actorID string) (domain.Lease, error)
+	ProjectLeases(ctx context.Context, projectID, actorID string, f engine.LeaseFilter) ([]domain.Lease, error)
+	LeaseWaiters(ctx context.Context, taskID, actorID string) ([]domain.LeaseWaiter, error)
+	LeaveLeaseQueue(ctx context.Context, taskID, actorID string) error
+	TransferLease(ctx context.Context, opts engine.LeaseTransferOptions) (domain.Lease, error)
+	AcceptLeaseTransfer(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error)
+	DeclineLeaseTransfer(ctx context.Context, taskID, actorID string) (domain.Lease, error)
+	ClaimReviewLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error)
+	ReleaseReviewLease(ctx context.Context, taskID, actorID string) error
+
+	// Iterations and decisions.
+	CreateIteration(ctx context.Context, it domain.Iteration, actorID string) (domain.Iteration, error)
+	SetIterationStatus(ctx context.Context, id, status, actorID string, force bool) (domain.Iteration, error)
+	UpdateIterationKeyResults(ctx context.Context, iterationID string, patches []engine.KeyResultPatch, actorID string) (domain.Iteration, error)
+	CarryOverIteration(ctx context.Context, sourceID, targetID, freezeOverride, actorID string) (engine.CarryOverResult, error)
+	SetIterationFrozen(ctx context.Context, id string, frozen bool, actorID string) (domain.Iteration, error)
+	CreateDecision(ctx context.Context, d domain.Decision, actorID string) (domain.Decision, error)
+
+	// Attestations and artifacts.
+	AddAttestation(ctx context.Context, att domain.Attestation, actorID string) (domain.Attestation, error)
+	AddAttestations(ctx context.Context, projectID string, atts []domain.Attestation, actorID string, atomic bool) (engine.BulkAttestationOutcome, error)
+	AttestationRequiredBy(ctx context.Context, projectID, kind string) (engine.AttestationBacklog, error)
+	DeprecatedKindWarning(kind string) string
+	AllowAttestationRole(ctx context.Context, projectID, actorID, kind, roleID string) error
+	DenyAttestationRole(ctx context.Context, projectID, actorID, kind, roleID string) error
+	AttestationAuthorityReport(ctx context.Context, projectID string) (engine.AttestationAuthorityReport, error)
+	UploadArtifact(ctx context.Context, opts engine.ArtifactUploadOptions) (domain.Artifact, error)
+	ArtifactContent(ctx context.Context, a domain.Artifact) ([]byte, error)
+
+	// Views.
+	CreateView(ctx context.Context, v domain.View, actorID string) (domain.View, error)
+	DeleteView(ctx context.Context, projectID, id, actorID string) error
+	GetVisibleView(ctx context.Context, projectID, id, actorID string) (domain.View, error)
+	VisibleViews(ctx context.Context, projectID, actorID string) ([]domain.View, error)
+
+	// Access control.
+	GrantRoleUntil(ctx context.Context, projectID, actorID, targetActor, roleID, expiresAt string) error
+	RevokeRole(ctx context.Context, projectID, actorID, targetActor, roleID string) error
+	CreateTeam(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error)
+	UpdateTeam(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error)
+	DeleteTeam(ctx context.Context, projectID, teamID, actorID string) error
+	AddTeamMember(ctx context.Context, projectID, teamID, memberID, actorID string) error
+	RemoveTeamMember(ctx context.Context, projectID, teamID, memberID, actorID string) error
+	GrantTeamRoleUntil(ctx context.Context, projectID, actorID, teamID, roleID, expiresAt string) error
+	RevokeTeamRole(ctx context.Context, projectID, actorID, teamID, roleID string) error
+	CreateEscalationRule(ctx context.Context, rule domain.EscalationRule, actorID string) (domain.EscalationRule, error)
+	UpdateEscalationRule(ctx context.Context, projectID, ruleID string, u engine.EscalationRuleUpdate, actorID string) (domain.EscalationRule, error)
+	DeleteEscalationRule(ctx context.Context, projectID, ruleID, actorID string) error
+	RotateSecret(ctx context.Context, projectID, kind, name string, overlap time.Duration, actorID string) (engine.SecretRotation, error)
+	RevokeSecret(ctx context.Context, projectID, secretID, actorID string) (domain.ProjectSecret, error)
+	SetActorCapabilities(ctx context.Context, projectID, target string, caps []string, actorID string) ([]string, error)
+	ReadNotification(ctx context.Context, projectID string, id int64, actorID string) (domain.Notification, error)
+	ReadAllNotifications(ctx context.Context, projectID, actorID string) (int, error)
+	SetEmailPreferences(ctx context.Context, prefs domain.EmailPreferences) (domain.EmailPreferences, error)
+
+	// Reports, events and usage.
+	ComplianceReport(ctx context.Context, projectID string, from, to time.Time) (engine.ComplianceReport, error)
+	AggregateEvents(ctx context.Context, projectID, bucket string, types []string, from, to time.Time) (engine.EventAggregate, error)
+	VerifyEventChain(ctx context.Context, projectID string) (engine.EventChainReport, error)
+	GenerateDigest(ctx context.Context, projectID string, day time.Time, actorID string) (engine.Digest, error)
+	GetDigest(ctx context.Context, projectID, day string) (engine.Digest, error)
+	ListDigests(ctx context.Context, projectID, from, to string) ([]engine.Digest, error)
+	RecordUsage(ctx context.Context, projectID, actorID string, mutation bool) error
+	Usage(ctx context.Context, projectID, actorID string, from, to time.Time) (engine.UsageReport, error)
+	CycleTime(ctx context.Context, opts engine.CycleTimeOptions) (engine.CycleTimeReport, error)
+
+	// Maintenance.
+	CheckConsistency(ctx context.Context) (engine.ConsistencyReport, error)
+	RepairConsistency(ctx context.Context, checks []string, actorID string) (engine.ConsistencyReport, error)
+	Vacuum(ctx context.Context, full bool, pages int) (db.VacuumResult, error)
+	CheckIntegrity(ctx context.Context, quick bool) (db.IntegrityResult, error)
+}
+
+var _ Engine = engine.Engine{}
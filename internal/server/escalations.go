@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/domain"
+	"workline/internal/engine"
+)
+
+func registerEscalations(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-escalation-rule",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/escalation-rules",
+		Summary:       "Create an escalation rule",
+		Description:   "Rules act on tasks left in their condition for their `after` delay: task.overdue covers planned or in-progress tasks without an active lease, validation.blocked tasks in review with unmet requirements. notify records an escalation.triggered event naming role_id and its holders; follow_up also creates a chore task. `wl serve` applies the rules every --escalation-interval. Requires escalation.manage.",
+		DefaultStatus: http.StatusCreated,
+		Errors:        []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                      `path:"project_id"`
+		Body      CreateEscalationRuleRequest `json:"body"`
+	}) (*struct {
+		Body domain.EscalationRule `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "escalation.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		b := input.Body
+		rule, err := e.CreateEscalationRule(ctx, domain.EscalationRule{
+			ProjectID:   projectID,
+			ID:          b.ID,
+			Description: b.Description,
+			Condition:   b.Condition,
+			After:       b.After,
+			TaskType:    b.TaskType,
+			Action:      b.Action,
+			RoleID:      b.RoleID,
+			Enabled:     b.Enabled == nil || *b.Enabled,
+		}, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.EscalationRule `json:"body"`
+		}{Body: rule}, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-escalation-rules",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/escalation-rules",
+		Summary:     "List escalation rules",
+		Description: "Requires escalation.read.",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) ([]domain.EscalationRule, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "escalation.read"); err != nil {
+			return nil, handleError(err)
+		}
+		rules, err := e.Store().ListEscalationRules(ctx, projectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return nonNilSlice(rules), nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-escalation-rule",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/escalation-rules/{rule_id}",
+		Summary:     "Get an escalation rule",
+		Description: "Requires escalation.read.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		RuleID    string `path:"rule_id"`
+	}) (*struct {
+		Body domain.EscalationRule `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "escalation.read"); err != nil {
+			return nil, handleError(err)
+		}
+		rule, err := e.Store().GetEscalationRule(ctx, projectID, input.RuleID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.EscalationRule `json:"body"`
+		}{Body: rule}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "update-escalation-rule",
+		Method:      http.MethodPatch,
+		Path:        "/projects/{project_id}/escalation-rules/{rule_id}",
+		Summary:     "Update an escalation rule",
+		Description: "Changes the fields present in the body; set enabled to false to pause the rule. Requires escalation.manage.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                      `path:"project_id"`
+		RuleID    string                      `path:"rule_id"`
+		Body      UpdateEscalationRuleRequest `json:"body"`
+	}) (*struct {
+		Body domain.EscalationRule `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "escalation.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		b := input.Body
+		rule, err := e.UpdateEscalationRule(ctx, projectID, input.RuleID, engine.EscalationRuleUpdate{
+			Description: b.Description,
+			Condition:   b.Condition,
+			After:       b.After,
+			TaskType:    b.TaskType,
+			Action:      b.Action,
+			RoleID:      b.RoleID,
+			Enabled:     b.Enabled,
+		}, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.EscalationRule `json:"body"`
+		}{Body: rule}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-escalation-rule",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/escalation-rules/{rule_id}",
+		Summary:     "Delete an escalation rule",
+		Description: "Requires escalation.manage.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		RuleID    string `path:"rule_id"`
+	}) (*struct{}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "escalation.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.DeleteEscalationRule(ctx, projectID, input.RuleID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+}
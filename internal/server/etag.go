@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// newETagMiddleware tags task, task tree and project config reads with a strong ETag derived
+// from the rendered representation, which carries each entity's revision (updated_at). A
+// matching If-None-Match is answered with 304 and no body.
+func newETagMiddleware(basePath string) func(http.Handler) http.Handler {
+	projectsPrefix := strings.TrimSuffix(basePath, "/") + "/projects/"
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rest, ok := strings.CutPrefix(req.URL.Path, projectsPrefix)
+			if req.Method != http.MethodGet || !ok || !etagCacheable(rest) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			buf := &bufferedResponse{ResponseWriter: w}
+			next.ServeHTTP(buf, req)
+			status := buf.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				_, _ = w.Write(buf.body.Bytes())
+				return
+			}
+			sum := sha256.Sum256(buf.body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+			if etagMatches(req.Header.Get("If-None-Match"), etag) {
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write(buf.body.Bytes())
+		})
+	}
+}
+
+// etagCacheable reports whether a path below /projects/ is one of the conditional GETs:
+// {project}/config, {project}/tasks/tree or {project}/tasks/{id}.
+func etagCacheable(rest string) bool {
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "config":
+		return true
+	case len(parts) == 3 && parts[1] == "tasks" && parts[2] != "":
+		return true
+	}
+	return false
+}
+
+// etagMatches applies the weak comparison If-None-Match requires for GET.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bufferedResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *bufferedResponse) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
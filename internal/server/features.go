@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// ProjectFeature is an experimental feature as a project can use it.
+type ProjectFeature struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Reason      string `json:"reason,omitempty" doc:"Why the feature is unavailable"`
+}
+
+// ProjectFeaturesResponse lists the experimental features of a project.
+type ProjectFeaturesResponse struct {
+	ProjectID string           `json:"project_id"`
+	Features  []ProjectFeature `json:"features"`
+}
+
+// registerFeatures serves the features a project can use. served reports which features
+// this server exposes at all: a feature is enabled only when both the server and the
+// project's flags allow it.
+func registerFeatures(api huma.API, e Engine, served map[string]bool) {
+	huma.Register(api, huma.Operation{
+		OperationID: "list-project-features",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/features",
+		Summary:     "List experimental features of a project",
+		Description: "Whether each experimental feature is available to the project, from its flags and the server's options. Requires project.config.read.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) (*struct {
+		Body ProjectFeaturesResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.config.read"); err != nil {
+			return nil, handleError(err)
+		}
+		states, err := e.ProjectFeatures(ctx, projectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		res := ProjectFeaturesResponse{ProjectID: projectID, Features: make([]ProjectFeature, 0, len(states))}
+		for _, s := range states {
+			f := ProjectFeature{Name: s.Name, Description: s.Description, Enabled: s.Enabled && served[s.Name]}
+			switch {
+			case !s.Enabled:
+				f.Reason = "disabled by the project's flags"
+			case !served[s.Name]:
+				f.Reason = "not served by this server"
+			}
+			res.Features = append(res.Features, f)
+		}
+		return &struct {
+			Body ProjectFeaturesResponse `json:"body"`
+		}{Body: res}, nil
+	})
+}
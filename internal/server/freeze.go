@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func registerIterationFreeze(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "freeze-iteration",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/iterations/{id}/freeze",
+		Summary:     "Freeze a running iteration's scope",
+		Description: "While the iteration runs frozen, tasks cannot be created in it, carried or synced into it, and its tasks' validation policies cannot drop requirements; such changes answer 409 iteration_frozen. An actor holding iteration.freeze.override may still make them by sending freeze_override with a reason, recorded as iteration.freeze.overridden. Records iteration.frozen. Requires iteration.freeze.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body IterationResponse `json:"body"`
+	}, error) {
+		return setIterationFrozen(ctx, e, input.ProjectID, input.ID, true)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "unfreeze-iteration",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/iterations/{id}/freeze",
+		Summary:     "Unfreeze an iteration's scope",
+		Description: "Records iteration.unfrozen. Requires iteration.freeze.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body IterationResponse `json:"body"`
+	}, error) {
+		return setIterationFrozen(ctx, e, input.ProjectID, input.ID, false)
+	})
+}
+
+func setIterationFrozen(ctx context.Context, e Engine, projectID, id string, frozen bool) (*struct {
+	Body IterationResponse `json:"body"`
+}, error) {
+	actorID, authErr := actorIDFromContext(ctx)
+	if authErr != nil {
+		return nil, authErr
+	}
+	it, err := e.Store().GetIteration(ctx, id)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	if !projectMatches(projectID, it.ProjectID) {
+		return nil, newAPIError(http.StatusNotFound, "not_found", "iteration not found in project", nil)
+	}
+	it, err = e.SetIterationFrozen(ctx, id, frozen, actorID)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return &struct {
+		Body IterationResponse `json:"body"`
+	}{Body: iterationResponse(it)}, nil
+}
@@ -0,0 +1,516 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/graphql"
+	"workline/internal/repo"
+)
+
+// GraphQLRequest is a GraphQL query posted to /graphql.
+type GraphQLRequest struct {
+	Query         string         `json:"query" minLength:"1"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty" doc:"Accepted for client compatibility and ignored"`
+}
+
+// GraphQLResponse is the standard GraphQL result: data is absent when the query could not
+// run, and fields that failed are null in data and listed in errors. Declaring $schema keeps
+// huma from adding its schema link, which GraphQL results do not carry.
+type GraphQLResponse struct {
+	Schema string           `json:"$schema,omitempty" readOnly:"true" doc:"Never set"`
+	Data   any              `json:"data,omitempty" doc:"The requested fields, in query order"`
+	Errors []*graphql.Error `json:"errors,omitempty"`
+}
+
+// gqlActor is an actor seen from a project, where its capabilities apply.
+type gqlActor struct {
+	ProjectID string
+	ID        string
+}
+
+type gqlPermissionsKey struct{}
+
+// registerGraphQL exposes tasks, iterations, attestations, decisions and their relations as
+// a read-only GraphQL API. Each field checks the permission its REST counterpart checks, in
+// the project of the object it is read from; a denied field is null with a forbidden error.
+func registerGraphQL(api huma.API, e Engine) error {
+	schema, err := newGraphQLSchema(e)
+	if err != nil {
+		return err
+	}
+	execute := func(ctx context.Context, req graphql.Request) GraphQLResponse {
+		ctx = context.WithValue(ctx, gqlPermissionsKey{}, map[string]error{})
+		res := schema.Execute(ctx, req)
+		out := GraphQLResponse{Errors: res.Errors}
+		if res.Data != nil {
+			out.Data = res.Data
+		}
+		return out
+	}
+
+	huma.Register(api, huma.Operation{
+		OperationID: "graphql",
+		Method:      http.MethodPost,
+		Path:        "/graphql",
+		Summary:     "Run a read-only GraphQL query",
+		Description: "Query errors are reported in the errors member with status 200; GET /graphql/schema returns the schema.",
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	}, func(ctx context.Context, input *struct {
+		Body GraphQLRequest `json:"body"`
+	}) (*struct {
+		Body GraphQLResponse `json:"body"`
+	}, error) {
+		if _, authErr := actorIDFromContext(ctx); authErr != nil {
+			return nil, authErr
+		}
+		req := graphql.Request{Query: input.Body.Query, OperationName: input.Body.OperationName, Variables: input.Body.Variables}
+		return &struct {
+			Body GraphQLResponse `json:"body"`
+		}{Body: execute(ctx, req)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "graphql-get",
+		Method:      http.MethodGet,
+		Path:        "/graphql",
+		Summary:     "Run a read-only GraphQL query given in the URL",
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	}, func(ctx context.Context, input *struct {
+		Query         string `query:"query" required:"true"`
+		OperationName string `query:"operationName"`
+		Variables     string `query:"variables" doc:"Variables as a JSON object"`
+	}) (*struct {
+		Body GraphQLResponse `json:"body"`
+	}, error) {
+		if _, authErr := actorIDFromContext(ctx); authErr != nil {
+			return nil, authErr
+		}
+		req := graphql.Request{Query: input.Query, OperationName: input.OperationName}
+		if input.Variables != "" {
+			if err := json.Unmarshal([]byte(input.Variables), &req.Variables); err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid variables: "+err.Error(), map[string]any{"variables": input.Variables})
+			}
+		}
+		return &struct {
+			Body GraphQLResponse `json:"body"`
+		}{Body: execute(ctx, req)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "graphql-schema",
+		Method:      http.MethodGet,
+		Path:        "/graphql/schema",
+		Summary:     "Get the GraphQL schema in SDL",
+	}, func(ctx context.Context, input *struct{}) (*struct {
+		ContentType string `header:"Content-Type"`
+		Body        []byte
+	}, error) {
+		return &struct {
+			ContentType string `header:"Content-Type"`
+			Body        []byte
+		}{ContentType: "text/plain; charset=utf-8", Body: []byte(schema.SDL())}, nil
+	})
+	return nil
+}
+
+// gqlRequire is requirePermission remembered for the rest of the query, so that a list
+// of a hundred tasks checks attestation.list once. Objects of projects whose flags switch
+// GraphQL off are refused like denied ones.
+func gqlRequire(ctx context.Context, e Engine, projectID, perm string) error {
+	check := func() error {
+		if err := e.RequireFeature(ctx, projectID, config.FeatureGraphQL); err != nil {
+			return err
+		}
+		return requirePermission(ctx, e, projectID, perm)
+	}
+	seen, ok := ctx.Value(gqlPermissionsKey{}).(map[string]error)
+	if !ok {
+		return check()
+	}
+	key := projectID + "\x00" + perm
+	if err, done := seen[key]; done {
+		return err
+	}
+	err := check()
+	seen[key] = err
+	return err
+}
+
+// gqlProject is the project argument of a root field, defaulting like REST paths to the
+// X-Project-Id header and then the workspace project.
+func gqlProject(ctx context.Context, e Engine, args map[string]any) string {
+	id, _ := args["project"].(string)
+	return projectFromPathOrHeader(ctx, id, e.DefaultConfig().Project.ID)
+}
+
+func gqlLimit(args map[string]any) (int, error) {
+	first, _ := args["first"].(int)
+	return normalizeLimit(first)
+}
+
+func gqlString(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// gqlFound turns a missing object into null rather than an error.
+func gqlFound[T any](v T, err error) (any, error) {
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func gqlProp[S any](get func(S) any) graphql.Resolver {
+	return func(_ context.Context, src any, _ map[string]any) (any, error) {
+		return get(src.(S)), nil
+	}
+}
+
+func gqlRelation[S any](resolve func(ctx context.Context, src S, args map[string]any) (any, error)) graphql.Resolver {
+	return func(ctx context.Context, src any, args map[string]any) (any, error) {
+		return resolve(ctx, src.(S), args)
+	}
+}
+
+// gqlFormatError reports a resolver error with the code and details of the REST error.
+func gqlFormatError(err error) *graphql.Error {
+	var ae *apiError
+	if !errors.As(handleError(err), &ae) {
+		return &graphql.Error{Message: err.Error()}
+	}
+	ext := map[string]any{"code": ae.Body.Code}
+	for k, v := range ae.Body.Details {
+		ext[k] = v
+	}
+	return &graphql.Error{Message: ae.Body.Message, Extensions: ext}
+}
+
+func newGraphQLSchema(e Engine) (*graphql.Schema, error) {
+	first := graphql.Arg{Name: "first", Type: "Int", Default: 50, Description: "Maximum number of items"}
+	projectArg := graphql.Arg{Name: "project", Type: "ID", Description: "Project; defaults to X-Project-Id or the workspace project"}
+
+	listTasks := func(ctx context.Context, f repo.TaskFilters, args map[string]any) (any, error) {
+		if err := gqlRequire(ctx, e, f.ProjectID, "task.list"); err != nil {
+			return nil, err
+		}
+		limit, err := gqlLimit(args)
+		if err != nil {
+			return nil, err
+		}
+		f.Status, f.Type, f.Limit = gqlString(args, "status"), gqlString(args, "type"), limit
+		return e.Store().ListTasks(ctx, f)
+	}
+	listAttestations := func(ctx context.Context, f repo.AttestationFilters, args map[string]any) (any, error) {
+		if err := gqlRequire(ctx, e, f.ProjectID, "attestation.list"); err != nil {
+			return nil, err
+		}
+		limit, err := gqlLimit(args)
+		if err != nil {
+			return nil, err
+		}
+		f.Kind, f.Limit = gqlString(args, "kind"), limit
+		return e.Store().ListAttestations(ctx, f)
+	}
+	listIterations := func(ctx context.Context, projectID string, args map[string]any) (any, error) {
+		if err := gqlRequire(ctx, e, projectID, "iteration.list"); err != nil {
+			return nil, err
+		}
+		limit, err := gqlLimit(args)
+		if err != nil {
+			return nil, err
+		}
+		return e.Store().ListIterationsWithCursor(ctx, projectID, limit, "", "")
+	}
+	listDecisions := func(ctx context.Context, f repo.DecisionFilters, args map[string]any) (any, error) {
+		if err := gqlRequire(ctx, e, f.ProjectID, "decision.list"); err != nil {
+			return nil, err
+		}
+		limit, err := gqlLimit(args)
+		if err != nil {
+			return nil, err
+		}
+		f.Status, f.Limit = gqlString(args, "status"), limit
+		return e.Store().ListDecisions(ctx, f)
+	}
+	getTask := func(ctx context.Context, projectID, id string) (any, error) {
+		if err := gqlRequire(ctx, e, projectID, "task.read"); err != nil {
+			return nil, err
+		}
+		t, err := e.Store().GetTask(ctx, id)
+		if err == nil && t.ProjectID != projectID {
+			err = repo.ErrNotFound
+		}
+		return gqlFound(t, err)
+	}
+	getIteration := func(ctx context.Context, projectID, id string) (any, error) {
+		if err := gqlRequire(ctx, e, projectID, "iteration.list"); err != nil {
+			return nil, err
+		}
+		it, err := e.Store().GetIteration(ctx, id)
+		if err == nil && it.ProjectID != projectID {
+			err = repo.ErrNotFound
+		}
+		return gqlFound(it, err)
+	}
+	getDecision := func(ctx context.Context, projectID, id string) (any, error) {
+		if err := gqlRequire(ctx, e, projectID, "decision.read"); err != nil {
+			return nil, err
+		}
+		d, err := e.Store().GetDecision(ctx, id)
+		if err == nil && d.ProjectID != projectID {
+			err = repo.ErrNotFound
+		}
+		return gqlFound(d, err)
+	}
+	statusArg := graphql.Arg{Name: "status", Type: "String"}
+	kindArg := graphql.Arg{Name: "kind", Type: "String", Description: "Attestation kind"}
+
+	actor := &graphql.Object{Name: "Actor", Fields: []*graphql.Field{
+		{Name: "id", Type: "ID!", Resolve: gqlProp(func(a gqlActor) any { return a.ID })},
+		{Name: "capabilities", Type: "[String!]!", Description: "Capabilities the actor offers in the project; rbac.read unless it is the caller",
+			Resolve: gqlRelation(func(ctx context.Context, a gqlActor, _ map[string]any) (any, error) {
+				if caller, _ := actorIDFromContext(ctx); caller != a.ID {
+					if err := gqlRequire(ctx, e, a.ProjectID, "rbac.read"); err != nil {
+						return nil, err
+					}
+				}
+				return e.Store().ListActorCapabilities(ctx, a.ProjectID, a.ID)
+			})},
+	}}
+
+	task := &graphql.Object{Name: "Task"}
+	iteration := &graphql.Object{Name: "Iteration"}
+	keyResult := &graphql.Object{Name: "KeyResult", Fields: []*graphql.Field{
+		{Name: "metric", Type: "String!", Resolve: gqlProp(func(kr domain.KeyResult) any { return kr.Metric })},
+		{Name: "target", Type: "Float!", Resolve: gqlProp(func(kr domain.KeyResult) any { return kr.Target })},
+		{Name: "current", Type: "Float!", Resolve: gqlProp(func(kr domain.KeyResult) any { return kr.Current })},
+		{Name: "direction", Type: "String!", Resolve: gqlProp(func(kr domain.KeyResult) any { return kr.Direction })},
+		{Name: "sourceKind", Type: "String!", Resolve: gqlProp(func(kr domain.KeyResult) any { return kr.SourceKind })},
+		{Name: "met", Type: "Boolean!", Resolve: gqlProp(func(kr domain.KeyResult) any { return kr.Met })},
+	}}
+	attestation := &graphql.Object{Name: "Attestation"}
+	decision := &graphql.Object{Name: "Decision"}
+	project := &graphql.Object{Name: "Project"}
+
+	task.Fields = []*graphql.Field{
+		{Name: "id", Type: "ID!", Resolve: gqlProp(func(t domain.Task) any { return t.ID })},
+		{Name: "projectId", Type: "ID!", Resolve: gqlProp(func(t domain.Task) any { return t.ProjectID })},
+		{Name: "type", Type: "String!", Resolve: gqlProp(func(t domain.Task) any { return t.Type })},
+		{Name: "title", Type: "String!", Resolve: gqlProp(func(t domain.Task) any { return t.Title })},
+		{Name: "description", Type: "String!", Resolve: gqlProp(func(t domain.Task) any { return t.Description })},
+		{Name: "status", Type: "String!", Resolve: gqlProp(func(t domain.Task) any { return t.Status })},
+		{Name: "rank", Type: "Int!", Resolve: gqlProp(func(t domain.Task) any { return t.Rank })},
+		{Name: "requiredAttestations", Type: "[String!]!", Resolve: gqlProp(func(t domain.Task) any { return decodeStringSlice(t.RequiredAttestationsJSON) })},
+		{Name: "requiredCapabilities", Type: "[String!]!", Resolve: gqlProp(func(t domain.Task) any { return t.RequiredCapabilities })},
+		{Name: "workOutcomes", Type: "JSON", Resolve: gqlProp(func(t domain.Task) any { return decodeJSONMap(t.WorkOutcomesJSON) })},
+		{Name: "customFields", Type: "JSON", Resolve: gqlProp(func(t domain.Task) any { return decodeJSONMap(t.CustomFieldsJSON) })},
+		{Name: "createdAt", Type: "String!", Resolve: gqlProp(func(t domain.Task) any { return t.CreatedAt })},
+		{Name: "updatedAt", Type: "String!", Resolve: gqlProp(func(t domain.Task) any { return t.UpdatedAt })},
+		{Name: "completedAt", Type: "String", Resolve: gqlProp(func(t domain.Task) any { return t.CompletedAt })},
+		{Name: "iteration", Type: "Iteration", Resolve: gqlRelation(func(ctx context.Context, t domain.Task, _ map[string]any) (any, error) {
+			if t.IterationID == nil {
+				return nil, nil
+			}
+			return getIteration(ctx, t.ProjectID, *t.IterationID)
+		})},
+		{Name: "parent", Type: "Task", Resolve: gqlRelation(func(ctx context.Context, t domain.Task, _ map[string]any) (any, error) {
+			if t.ParentID == nil {
+				return nil, nil
+			}
+			return getTask(ctx, t.ProjectID, *t.ParentID)
+		})},
+		{Name: "children", Type: "[Task!]!", Args: []graphql.Arg{statusArg, first},
+			Resolve: gqlRelation(func(ctx context.Context, t domain.Task, args map[string]any) (any, error) {
+				return listTasks(ctx, repo.TaskFilters{ProjectID: t.ProjectID, Parent: t.ID}, args)
+			})},
+		{Name: "dependsOn", Type: "[Task!]!", Description: "Tasks this task waits for",
+			Resolve: gqlRelation(func(ctx context.Context, t domain.Task, _ map[string]any) (any, error) {
+				ids, err := e.Store().ListTaskDependencies(ctx, t.ID)
+				if err != nil {
+					return nil, err
+				}
+				deps := []domain.Task{}
+				for _, id := range ids {
+					dep, err := getTask(ctx, t.ProjectID, id)
+					if err != nil {
+						return nil, err
+					}
+					if dep != nil {
+						deps = append(deps, dep.(domain.Task))
+					}
+				}
+				return deps, nil
+			})},
+		{Name: "assignee", Type: "Actor", Resolve: gqlProp(func(t domain.Task) any {
+			if t.AssigneeID == nil {
+				return nil
+			}
+			return gqlActor{ProjectID: t.ProjectID, ID: *t.AssigneeID}
+		})},
+		{Name: "attestations", Type: "[Attestation!]!", Args: []graphql.Arg{kindArg, first},
+			Resolve: gqlRelation(func(ctx context.Context, t domain.Task, args map[string]any) (any, error) {
+				return listAttestations(ctx, repo.AttestationFilters{ProjectID: t.ProjectID, EntityKind: "task", EntityID: t.ID}, args)
+			})},
+	}
+
+	iteration.Fields = []*graphql.Field{
+		{Name: "id", Type: "ID!", Resolve: gqlProp(func(it domain.Iteration) any { return it.ID })},
+		{Name: "projectId", Type: "ID!", Resolve: gqlProp(func(it domain.Iteration) any { return it.ProjectID })},
+		{Name: "goal", Type: "String!", Resolve: gqlProp(func(it domain.Iteration) any { return it.Goal })},
+		{Name: "status", Type: "String!", Resolve: gqlProp(func(it domain.Iteration) any { return it.Status })},
+		{Name: "createdAt", Type: "String!", Resolve: gqlProp(func(it domain.Iteration) any { return it.CreatedAt })},
+		{Name: "keyResults", Type: "[KeyResult!]!", Resolve: gqlProp(func(it domain.Iteration) any { return it.KeyResults })},
+		{Name: "tasks", Type: "[Task!]!", Args: []graphql.Arg{statusArg, {Name: "type", Type: "String"}, first},
+			Resolve: gqlRelation(func(ctx context.Context, it domain.Iteration, args map[string]any) (any, error) {
+				return listTasks(ctx, repo.TaskFilters{ProjectID: it.ProjectID, Iteration: it.ID}, args)
+			})},
+		{Name: "attestations", Type: "[Attestation!]!", Args: []graphql.Arg{kindArg, first},
+			Resolve: gqlRelation(func(ctx context.Context, it domain.Iteration, args map[string]any) (any, error) {
+				return listAttestations(ctx, repo.AttestationFilters{ProjectID: it.ProjectID, EntityKind: "iteration", EntityID: it.ID}, args)
+			})},
+	}
+
+	attestation.Fields = []*graphql.Field{
+		{Name: "id", Type: "ID!", Resolve: gqlProp(func(a domain.Attestation) any { return a.ID })},
+		{Name: "projectId", Type: "ID!", Resolve: gqlProp(func(a domain.Attestation) any { return a.ProjectID })},
+		{Name: "entityKind", Type: "String!", Resolve: gqlProp(func(a domain.Attestation) any { return a.EntityKind })},
+		{Name: "entityId", Type: "ID!", Resolve: gqlProp(func(a domain.Attestation) any { return a.EntityID })},
+		{Name: "kind", Type: "String!", Resolve: gqlProp(func(a domain.Attestation) any { return a.Kind })},
+		{Name: "ts", Type: "String!", Resolve: gqlProp(func(a domain.Attestation) any { return a.TS })},
+		{Name: "payload", Type: "JSON", Resolve: gqlProp(func(a domain.Attestation) any { return decodeJSONMap(strPtr(a.PayloadJSON)) })},
+		{Name: "actor", Type: "Actor!", Resolve: gqlProp(func(a domain.Attestation) any { return gqlActor{ProjectID: a.ProjectID, ID: a.ActorID} })},
+		{Name: "task", Type: "Task", Description: "The attested task, when entityKind is task",
+			Resolve: gqlRelation(func(ctx context.Context, a domain.Attestation, _ map[string]any) (any, error) {
+				if a.EntityKind != "task" {
+					return nil, nil
+				}
+				return getTask(ctx, a.ProjectID, a.EntityID)
+			})},
+		{Name: "iteration", Type: "Iteration", Description: "The attested iteration, when entityKind is iteration",
+			Resolve: gqlRelation(func(ctx context.Context, a domain.Attestation, _ map[string]any) (any, error) {
+				if a.EntityKind != "iteration" {
+					return nil, nil
+				}
+				return getIteration(ctx, a.ProjectID, a.EntityID)
+			})},
+		{Name: "decision", Type: "Decision", Description: "The attested decision, when entityKind is decision",
+			Resolve: gqlRelation(func(ctx context.Context, a domain.Attestation, _ map[string]any) (any, error) {
+				if a.EntityKind != "decision" {
+					return nil, nil
+				}
+				return getDecision(ctx, a.ProjectID, a.EntityID)
+			})},
+	}
+
+	decision.Fields = []*graphql.Field{
+		{Name: "id", Type: "ID!", Resolve: gqlProp(func(d domain.Decision) any { return d.ID })},
+		{Name: "projectId", Type: "ID!", Resolve: gqlProp(func(d domain.Decision) any { return d.ProjectID })},
+		{Name: "title", Type: "String!", Resolve: gqlProp(func(d domain.Decision) any { return d.Title })},
+		{Name: "decision", Type: "String!", Resolve: gqlProp(func(d domain.Decision) any { return d.Decision })},
+		{Name: "status", Type: "String!", Resolve: gqlProp(func(d domain.Decision) any { return d.Status })},
+		{Name: "context", Type: "JSON", Resolve: gqlProp(func(d domain.Decision) any { return decodeJSONMap(strPtr(d.ContextJSON)) })},
+		{Name: "rationale", Type: "[String!]!", Resolve: gqlProp(func(d domain.Decision) any { return decodeStringSlice(strPtr(d.RationaleJSON)) })},
+		{Name: "alternatives", Type: "[String!]!", Resolve: gqlProp(func(d domain.Decision) any { return decodeStringSlice(strPtr(d.AlternativesJSON)) })},
+		{Name: "createdAt", Type: "String!", Resolve: gqlProp(func(d domain.Decision) any { return d.CreatedAt })},
+		{Name: "decider", Type: "Actor!", Resolve: gqlProp(func(d domain.Decision) any { return gqlActor{ProjectID: d.ProjectID, ID: d.DeciderID} })},
+		{Name: "attestations", Type: "[Attestation!]!", Args: []graphql.Arg{kindArg, first},
+			Resolve: gqlRelation(func(ctx context.Context, d domain.Decision, args map[string]any) (any, error) {
+				return listAttestations(ctx, repo.AttestationFilters{ProjectID: d.ProjectID, EntityKind: "decision", EntityID: d.ID}, args)
+			})},
+	}
+
+	project.Fields = []*graphql.Field{
+		{Name: "id", Type: "ID!", Resolve: gqlProp(func(p domain.Project) any { return p.ID })},
+		{Name: "kind", Type: "String!", Resolve: gqlProp(func(p domain.Project) any { return p.Kind })},
+		{Name: "status", Type: "String!", Resolve: gqlProp(func(p domain.Project) any { return p.Status })},
+		{Name: "displayName", Type: "String!", Resolve: gqlProp(func(p domain.Project) any { return p.DisplayName })},
+		{Name: "description", Type: "String!", Resolve: gqlProp(func(p domain.Project) any { return p.Description })},
+		{Name: "tags", Type: "[String!]!", Resolve: gqlProp(func(p domain.Project) any { return p.Tags })},
+		{Name: "createdAt", Type: "String!", Resolve: gqlProp(func(p domain.Project) any { return p.CreatedAt })},
+		{Name: "tasks", Type: "[Task!]!", Args: []graphql.Arg{statusArg, {Name: "type", Type: "String"}, first},
+			Resolve: gqlRelation(func(ctx context.Context, p domain.Project, args map[string]any) (any, error) {
+				return listTasks(ctx, repo.TaskFilters{ProjectID: p.ID}, args)
+			})},
+		{Name: "iterations", Type: "[Iteration!]!", Args: []graphql.Arg{first},
+			Resolve: gqlRelation(func(ctx context.Context, p domain.Project, args map[string]any) (any, error) {
+				return listIterations(ctx, p.ID, args)
+			})},
+		{Name: "decisions", Type: "[Decision!]!", Args: []graphql.Arg{statusArg, first},
+			Resolve: gqlRelation(func(ctx context.Context, p domain.Project, args map[string]any) (any, error) {
+				return listDecisions(ctx, repo.DecisionFilters{ProjectID: p.ID}, args)
+			})},
+	}
+
+	idArg := graphql.Arg{Name: "id", Type: "ID!"}
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{Name: "project", Type: "Project", Args: []graphql.Arg{{Name: "id", Type: "ID", Description: "Defaults to X-Project-Id or the workspace project"}},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				id, _ := args["id"].(string)
+				projectID := projectFromPathOrHeader(ctx, id, e.DefaultConfig().Project.ID)
+				if err := gqlRequire(ctx, e, projectID, "project.read"); err != nil {
+					return nil, err
+				}
+				return gqlFound(e.Store().GetProject(ctx, projectID))
+			}},
+		{Name: "task", Type: "Task", Args: []graphql.Arg{idArg, projectArg},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return getTask(ctx, gqlProject(ctx, e, args), args["id"].(string))
+			}},
+		{Name: "tasks", Type: "[Task!]!", Args: []graphql.Arg{projectArg, statusArg, {Name: "type", Type: "String"}, {Name: "iteration", Type: "ID"}, {Name: "parent", Type: "ID"}, {Name: "assignee", Type: "ID"}, first},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return listTasks(ctx, repo.TaskFilters{
+					ProjectID:  gqlProject(ctx, e, args),
+					Iteration:  gqlString(args, "iteration"),
+					Parent:     gqlString(args, "parent"),
+					AssigneeID: gqlString(args, "assignee"),
+				}, args)
+			}},
+		{Name: "iteration", Type: "Iteration", Args: []graphql.Arg{idArg, projectArg},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return getIteration(ctx, gqlProject(ctx, e, args), args["id"].(string))
+			}},
+		{Name: "iterations", Type: "[Iteration!]!", Args: []graphql.Arg{projectArg, first},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return listIterations(ctx, gqlProject(ctx, e, args), args)
+			}},
+		{Name: "attestations", Type: "[Attestation!]!", Args: []graphql.Arg{projectArg, {Name: "entityKind", Type: "String"}, {Name: "entityId", Type: "ID"}, kindArg, first},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return listAttestations(ctx, repo.AttestationFilters{
+					ProjectID:  gqlProject(ctx, e, args),
+					EntityKind: gqlString(args, "entityKind"),
+					EntityID:   gqlString(args, "entityId"),
+				}, args)
+			}},
+		{Name: "decision", Type: "Decision", Args: []graphql.Arg{idArg, projectArg},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return getDecision(ctx, gqlProject(ctx, e, args), args["id"].(string))
+			}},
+		{Name: "decisions", Type: "[Decision!]!", Args: []graphql.Arg{projectArg, statusArg, {Name: "decider", Type: "ID"}, {Name: "query", Type: "String", Description: "Text search over title, decision, context, rationale and alternatives"}, first},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				return listDecisions(ctx, repo.DecisionFilters{
+					ProjectID: gqlProject(ctx, e, args),
+					DeciderID: gqlString(args, "decider"),
+					Query:     gqlString(args, "query"),
+				}, args)
+			}},
+	}}
+	schema, err := graphql.NewSchema(query, project, task, iteration, keyResult, attestation, decision, actor)
+	if err != nil {
+		return nil, err
+	}
+	schema.FormatError = gqlFormatError
+	return schema, nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"workline/internal/domain"
+)
+
+// maxEventWait bounds how long a long-polling events request is held.
+const maxEventWait = 60 * time.Second
+
+// eventPollInterval is how often a held events request looks for new events. Checking
+// the database from the server is far cheaper than clients polling over HTTP, and also
+// sees events recorded by other server processes.
+var eventPollInterval = 250 * time.Millisecond
+
+// waitForEvents calls fetch until it returns events or wait passes, then returns the
+// last result. A request deadline ends the wait early so the answer still goes out.
+func waitForEvents(ctx context.Context, wait time.Duration, fetch func(context.Context) ([]domain.Event, error)) ([]domain.Event, error) {
+	until := time.Now().Add(wait)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Add(-eventPollInterval).Before(until) {
+		until = deadline.Add(-eventPollInterval)
+	}
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		items, err := fetch(ctx)
+		if err != nil || len(items) > 0 || !time.Now().Before(until) {
+			return items, err
+		}
+		select {
+		case <-ctx.Done():
+			// The client went away; nobody reads the answer.
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// MessageCatalog localizes error messages: language tag, then error code, then message
+// template. Templates may reference {message}, the English message, and any detail key,
+// e.g. {permission}. Codes never change; only the message does.
+type MessageCatalog map[string]map[string]string
+
+type messageCatalogKey struct{}
+
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// LoadMessageCatalog reads a catalog from a YAML or JSON file.
+func LoadMessageCatalog(path string) (MessageCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid message catalog %s: %w", path, err)
+	}
+	catalog := MessageCatalog{}
+	for lang, messages := range raw {
+		tag := strings.ToLower(strings.TrimSpace(lang))
+		if tag == "" || tag == "*" {
+			return nil, fmt.Errorf("invalid message catalog %s: invalid language tag %q", path, lang)
+		}
+		for code, msg := range messages {
+			if strings.TrimSpace(msg) == "" {
+				return nil, fmt.Errorf("invalid message catalog %s: empty message for %s in %s", path, code, lang)
+			}
+		}
+		catalog[tag] = messages
+	}
+	return catalog, nil
+}
+
+// negotiate picks the catalog language best matching an Accept-Language header, trying
+// each range by quality and then its primary subtag ("pt-BR" falls back to "pt").
+func (c MessageCatalog) negotiate(acceptLanguage string) (string, bool) {
+	type langRange struct {
+		tag string
+		q   float64
+	}
+	var ranges []langRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		ranges = append(ranges, langRange{tag: tag, q: q})
+	}
+	slices.SortStableFunc(ranges, func(a, b langRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	for _, r := range ranges {
+		if _, ok := c[r.tag]; ok {
+			return r.tag, true
+		}
+		if primary, _, ok := strings.Cut(r.tag, "-"); ok {
+			if _, ok := c[primary]; ok {
+				return primary, true
+			}
+		}
+	}
+	return "", false
+}
+
+// localize returns err with its message translated for acceptLanguage, and the language
+// used. Errors whose code the negotiated language does not cover keep their message.
+func (c MessageCatalog) localize(acceptLanguage string, err *apiError) (*apiError, string) {
+	if len(c) == 0 || acceptLanguage == "" {
+		return err, ""
+	}
+	lang, ok := c.negotiate(acceptLanguage)
+	if !ok {
+		return err, ""
+	}
+	tmpl, ok := c[lang][err.Body.Code]
+	if !ok {
+		return err, ""
+	}
+	msg := placeholderPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		key := m[1 : len(m)-1]
+		if key == "message" {
+			return err.Body.Message
+		}
+		if v, ok := err.Body.Details[key]; ok {
+			return fmt.Sprint(v)
+		}
+		return m
+	})
+	localized := *err
+	localized.Body.Message = msg
+	return &localized, lang
+}
+
+// localizeError translates an error written outside an operation, such as by the auth
+// middleware, setting Content-Language when it did.
+func localizeError(w http.ResponseWriter, r *http.Request, err huma.StatusError) huma.StatusError {
+	ae, ok := err.(*apiError)
+	if !ok {
+		return err
+	}
+	catalog, _ := r.Context().Value(messageCatalogKey{}).(MessageCatalog)
+	localized, lang := catalog.localize(r.Header.Get("Accept-Language"), ae)
+	if lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+	return localized
+}
+
+// newMessageTransformer localizes error bodies returned by operations.
+func newMessageTransformer() huma.Transformer {
+	return func(ctx huma.Context, _ string, v any) (any, error) {
+		ae, ok := v.(*apiError)
+		if !ok {
+			return v, nil
+		}
+		catalog, _ := ctx.Context().Value(messageCatalogKey{}).(MessageCatalog)
+		localized, lang := catalog.localize(ctx.Header("Accept-Language"), ae)
+		if lang != "" {
+			ctx.SetHeader("Content-Language", lang)
+		}
+		return localized, nil
+	}
+}
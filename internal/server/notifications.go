@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+func registerNotifications(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "list-notifications",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/notifications",
+		Summary:     "List the caller's notifications",
+		Description: "The caller's inbox in the project, newest first: mentions (@actor in a task, a decision or an event), leases about to expire, and tasks in review needing an attestation kind the caller may record. `wl serve` collects them every --inbox-interval. Requires notification.read.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Unread    bool   `query:"unread" doc:"Only unread notifications"`
+		Limit     int    `query:"limit" default:"50"`
+		Cursor    string `query:"cursor"`
+	}) (*struct {
+		Body paginatedNotifications `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "notification.read"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		var before int64
+		if input.Cursor != "" {
+			if before, err = strconv.ParseInt(input.Cursor, 10, 64); err != nil || before <= 0 {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
+			}
+		}
+		items, err := e.Store().ListNotifications(ctx, projectID, actorID, input.Unread, limit+1, before)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := paginatedNotifications{Items: items}
+		if len(items) > limit {
+			resp.Items = items[:limit]
+			resp.NextCursor = strconv.FormatInt(items[limit-1].ID, 10)
+		}
+		if resp.Unread, err = e.Store().CountUnreadNotifications(ctx, projectID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body paginatedNotifications `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "read-notification",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/notifications/{id}/read",
+		Summary:     "Mark a notification read",
+		Description: "Marking a notification read again keeps the time of the first read. Requires notification.read.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        int64  `path:"id"`
+	}) (*struct {
+		Body domain.Notification `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		n, err := e.ReadNotification(ctx, projectID, input.ID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.Notification `json:"body"`
+		}{Body: n}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "read-all-notifications",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/notifications/read",
+		Summary:     "Mark all notifications read",
+		Description: "Marks every unread notification of the caller in the project read. Requires notification.read.",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) (*struct {
+		Body ReadAllNotificationsResponse `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		marked, err := e.ReadAllNotifications(ctx, projectID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ReadAllNotificationsResponse `json:"body"`
+		}{Body: ReadAllNotificationsResponse{Marked: marked}}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-email-preferences",
+		Method:      http.MethodGet,
+		Path:        "/me/email-preferences",
+		Summary:     "Get the caller's email preferences",
+		Description: "The address email notification channels mail the caller at and the events it opted in to.",
+		Errors:      []int{http.StatusUnauthorized},
+	}, func(ctx context.Context, _ *struct{}) (*struct {
+		Body domain.EmailPreferences `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		prefs, err := e.Store().GetEmailPreferences(ctx, actorID)
+		if errors.Is(err, repo.ErrNotFound) {
+			prefs, err = domain.EmailPreferences{ActorID: actorID, Events: []string{}}, nil
+		}
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.EmailPreferences `json:"body"`
+		}{Body: prefs}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-email-preferences",
+		Method:      http.MethodPut,
+		Path:        "/me/email-preferences",
+		Summary:     "Set the caller's email preferences",
+		Description: "Email notification channels (type email under notifications.channels) mail an event to the project members who opted in to it and have an address. Events are decision.created, iteration.validated and waiver.granted.",
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	}, func(ctx context.Context, input *struct {
+		Body EmailPreferencesRequest `json:"body"`
+	}) (*struct {
+		Body domain.EmailPreferences `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		prefs, err := e.SetEmailPreferences(ctx, domain.EmailPreferences{ActorID: actorID, Email: input.Body.Email, Events: input.Body.Events})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.EmailPreferences `json:"body"`
+		}{Body: prefs}, nil
+	})
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+type CreateOrgRequest struct {
+	ID   string `json:"id" example:"team-payments"`
+	Name string `json:"name,omitempty" example:"Payments team"`
+}
+
+type SetOrgMemberRequest struct {
+	Role string `json:"role" enum:"owner,member" example:"member"`
+}
+
+// requireOrgCredentials hides other orgs, like their projects, from credentials bound to
+// another org.
+func requireOrgCredentials(ctx context.Context, orgID string) (string, error) {
+	principal, authErr := principalFromRequest(ctx)
+	if authErr != nil {
+		return "", authErr
+	}
+	if principal.OrgID != orgID {
+		return "", handleError(fmt.Errorf("org %s: %w", orgID, repo.ErrNotFound))
+	}
+	return principal.ActorID, nil
+}
+
+// registerOrgs exposes the orgs projects are grouped in. Org roles (owner, member) govern
+// an org's membership and projects; roles inside projects are granted per project.
+func registerOrgs(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-org",
+		Method:        http.MethodPost,
+		Path:          "/orgs",
+		Summary:       "Create org",
+		Description:   "Creates an org owned by the caller. Requires org.create. Credentials for the new org reach its projects.",
+		DefaultStatus: http.StatusCreated,
+		Errors:        []int{http.StatusBadRequest, http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		Body CreateOrgRequest `json:"body"`
+	}) (*struct {
+		Body domain.Org `json:"body"`
+	}, error) {
+		if err := requireGlobalPermission(ctx, e, "org.create"); err != nil {
+			return nil, handleError(err)
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		o, err := e.CreateOrg(ctx, input.Body.ID, input.Body.Name, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.Org `json:"body"`
+		}{Body: o}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-org",
+		Method:      http.MethodGet,
+		Path:        "/orgs/{org_id}",
+		Summary:     "Get org",
+		Description: "Requires credentials of the org and a role in it.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		OrgID string `path:"org_id"`
+	}) (*struct {
+		Body domain.Org `json:"body"`
+	}, error) {
+		actorID, err := requireOrgCredentials(ctx, input.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		o, err := e.GetOrg(ctx, input.OrgID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.Org `json:"body"`
+		}{Body: o}, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-org-projects",
+		Method:      http.MethodGet,
+		Path:        "/orgs/{org_id}/projects",
+		Summary:     "List the projects of an org",
+		Description: "Newest first. Requires credentials of the org and a role in it.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		OrgID string `path:"org_id"`
+	}) ([]ProjectResponse, error) {
+		actorID, err := requireOrgCredentials(ctx, input.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		items, err := e.ListOrgProjects(ctx, input.OrgID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return mapProjects(items), nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-org-project",
+		Method:        http.MethodPost,
+		Path:          "/orgs/{org_id}/projects",
+		Summary:       "Create a project in an org",
+		Description:   "Requires credentials of the org and its owner role. The caller owns the new project; a parent program must belong to the same org. Repeating the request returns the existing project with 200.",
+		DefaultStatus: http.StatusCreated,
+		Responses:     createProjectResponses(api),
+		Errors:        []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		OrgID string               `path:"org_id"`
+		Body  CreateProjectRequest `json:"body"`
+	}) (*struct {
+		Status int
+		Body   ProjectResponse `json:"body"`
+	}, error) {
+		if input.Body.ID == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "id is required", nil)
+		}
+		if _, err := requireOrgCredentials(ctx, input.OrgID); err != nil {
+			return nil, err
+		}
+		p, created, err := createProject(ctx, e, input.OrgID, input.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &struct {
+			Status int
+			Body   ProjectResponse `json:"body"`
+		}{Status: createdStatus(created), Body: projectResponse(p)}, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-org-members",
+		Method:      http.MethodGet,
+		Path:        "/orgs/{org_id}/members",
+		Summary:     "List org members",
+		Description: "Requires credentials of the org and a role in it.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		OrgID string `path:"org_id"`
+	}) ([]domain.OrgMember, error) {
+		actorID, err := requireOrgCredentials(ctx, input.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		members, err := e.ListOrgMembers(ctx, input.OrgID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return members, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-org-member",
+		Method:      http.MethodPut,
+		Path:        "/orgs/{org_id}/members/{actor_id}",
+		Summary:     "Set an actor's org role",
+		Description: "Requires credentials of the org and its owner role. The last owner cannot step down.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		OrgID   string              `path:"org_id"`
+		ActorID string              `path:"actor_id"`
+		Body    SetOrgMemberRequest `json:"body"`
+	}) (*struct {
+		Body domain.OrgMember `json:"body"`
+	}, error) {
+		actorID, err := requireOrgCredentials(ctx, input.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		m, err := e.SetOrgRole(ctx, input.OrgID, input.ActorID, input.Body.Role, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.OrgMember `json:"body"`
+		}{Body: m}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "remove-org-member",
+		Method:      http.MethodDelete,
+		Path:        "/orgs/{org_id}/members/{actor_id}",
+		Summary:     "Remove an actor from an org",
+		Description: "Requires credentials of the org and its owner role. The last owner cannot be removed. Project roles the actor holds are kept.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		OrgID   string `path:"org_id"`
+		ActorID string `path:"actor_id"`
+	}) (*struct{}, error) {
+		actorID, err := requireOrgCredentials(ctx, input.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.RemoveOrgMember(ctx, input.OrgID, input.ActorID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+}
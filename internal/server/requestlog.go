@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/events"
+)
+
+// RequestIDHeader carries the ID that correlates a request with its log line, its error
+// body and the events it records.
+const RequestIDHeader = "X-Request-Id"
+
+type requestLogKey struct{}
+
+// requestLogEntry collects what the request log line reports but only inner handlers know.
+type requestLogEntry struct {
+	id      string
+	actorID string
+}
+
+// newRequestLogger gives every request an ID, keeping a usable X-Request-Id from the caller
+// and echoing it in the response. Events the request records carry it. When logger is set,
+// each request is logged once it completes.
+func newRequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &requestLogEntry{id: incomingRequestID(r.Header.Get(RequestIDHeader))}
+			if entry.id == "" {
+				entry.id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, entry.id)
+			ctx := context.WithValue(r.Context(), requestLogKey{}, entry)
+			ctx = events.WithRequestID(ctx, entry.id)
+			r = r.WithContext(ctx)
+			if logger == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "request",
+				slog.String("request_id", entry.id),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("actor", entry.actorID),
+				slog.Int("status", status),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			)
+		})
+	}
+}
+
+// incomingRequestID keeps a caller-supplied request ID of at most 128 printable ASCII
+// characters so it can be logged and stored verbatim; anything else is replaced.
+func incomingRequestID(id string) string {
+	if len(id) > 128 {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return ""
+		}
+	}
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestIDFromContext returns the ID newRequestLogger assigned to the request.
+func requestIDFromContext(ctx context.Context) string {
+	if entry, ok := ctx.Value(requestLogKey{}).(*requestLogEntry); ok {
+		return entry.id
+	}
+	return ""
+}
+
+// noteRequestActor records the authenticated actor for the request log line.
+func noteRequestActor(ctx context.Context, actorID string) {
+	if entry, ok := ctx.Value(requestLogKey{}).(*requestLogEntry); ok {
+		entry.actorID = actorID
+	}
+}
+
+// withRequestID stamps an error body with the request's ID.
+func withRequestID(ctx context.Context, err *apiError) *apiError {
+	id := requestIDFromContext(ctx)
+	if id == "" || err.Body.RequestID == id {
+		return err
+	}
+	stamped := *err
+	stamped.Body.RequestID = id
+	return &stamped
+}
+
+// newRequestIDTransformer stamps error bodies returned by operations with the request ID.
+func newRequestIDTransformer() huma.Transformer {
+	return func(ctx huma.Context, _ string, v any) (any, error) {
+		ae, ok := v.(*apiError)
+		if !ok {
+			return v, nil
+		}
+		return withRequestID(ctx.Context(), ae), nil
+	}
+}
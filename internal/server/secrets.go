@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/domain"
+	"workline/internal/engine"
+	"workline/internal/integrations"
+	"workline/internal/repo"
+)
+
+func registerSecrets(api huma.API, e Engine) {
+	registerList(api, huma.Operation{
+		OperationID: "list-project-secrets",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/secrets",
+		Summary:     "List project secret versions",
+		Description: "Every version of the project's webhook secrets, API tokens and signing keys, newest first, without their values. Requires secret.manage.",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) ([]domain.ProjectSecret, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "secret.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		secrets, err := e.Store().ListProjectSecrets(ctx, projectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return nonNilSlice(secrets), nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "rotate-project-secret",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/secrets/rotate",
+		Summary:       "Rotate a project secret",
+		Description:   "Creates the next version of a webhook secret, API token or evidence signing key. Earlier live versions stay valid for the overlap, so senders and clients can switch over. The new webhook secret or API token is returned once. Records secret.created or secret.rotated. Requires secret.manage.",
+		DefaultStatus: http.StatusCreated,
+		Errors:        []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string              `path:"project_id"`
+		Body      RotateSecretRequest `json:"body"`
+	}) (*struct {
+		Body engine.SecretRotation `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		overlap := engine.DefaultSecretOverlap
+		if input.Body.Overlap != "" {
+			d, err := time.ParseDuration(input.Body.Overlap)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", fmt.Sprintf("invalid overlap %q: use a duration such as 24h", input.Body.Overlap), nil)
+			}
+			overlap = d
+		}
+		res, err := e.RotateSecret(ctx, projectID, input.Body.Kind, input.Body.Name, overlap, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.SecretRotation `json:"body"`
+		}{Body: res}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "revoke-project-secret",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/secrets/{secret_id}/revoke",
+		Summary:     "Revoke a project secret version",
+		Description: "Retires one version at once, without waiting for its overlap to end. Records secret.revoked. Requires secret.manage.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		SecretID  string `path:"secret_id"`
+	}) (*struct {
+		Body domain.ProjectSecret `json:"body"`
+	}, error) {
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		s, err := e.RevokeSecret(ctx, projectID, input.SecretID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body domain.ProjectSecret `json:"body"`
+		}{Body: s}, nil
+	})
+}
+
+// webhookSecrets returns the secrets a provider webhook may be signed with: the live
+// versions rotated in for the project, newest first, then the one in secretEnv, if set.
+func webhookSecrets(ctx context.Context, r repo.Store, projectID, provider, secretEnv string) ([]string, error) {
+	live, err := r.LiveSecrets(ctx, projectID, "webhook", provider, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	var secrets []string
+	for _, s := range live {
+		secrets = append(secrets, s.Value)
+	}
+	if secretEnv != "" {
+		if v := os.Getenv(secretEnv); v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets, nil
+}
+
+// verifyWebhook accepts a webhook signed with any of secrets.
+func verifyWebhook(adapter integrations.Adapter, h http.Header, body []byte, secrets []string) error {
+	err := integrations.ErrSignature
+	for _, secret := range secrets {
+		if err = adapter.Verify(h, body, secret); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -7,33 +7,82 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"path"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	humachi "github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/v5"
 
+	"workline/internal/blob"
 	"workline/internal/config"
+	"workline/internal/db"
 	"workline/internal/domain"
 	"workline/internal/engine"
 	"workline/internal/engine/auth"
+	"workline/internal/integrations"
+	"workline/internal/manifest"
 	"workline/internal/repo"
+	"workline/internal/seed"
 )
 
 // Config for the HTTP API handler.
 type Config struct {
-	Engine   engine.Engine
+	Engine Engine
+	// BasePath is where the v0 API is served (default /v0); v1 is served beside it, at /v1
+	// for the default.
 	BasePath string
 	Auth     AuthConfig
+	// V0Sunset, when set, is announced in the Sunset header of v0 responses.
+	V0Sunset time.Time
+	// RowBudget caps rows read by list queries per request; 0 uses repo.DefaultRowBudget.
+	RowBudget int
+	// ContractValidation checks requests and responses against the OpenAPI spec:
+	// ContractOff (default), ContractLog or ContractEnforce.
+	ContractValidation string
+	// QueryStats backs GET /admin/db/stats when the database is instrumented; SlowQuery is
+	// the slow query threshold the report shows.
+	QueryStats *db.QueryStats
+	SlowQuery  time.Duration
+	// JSONDecoding is JSONStrict (default) or JSONLenient.
+	JSONDecoding string
+	// Messages localizes error messages by Accept-Language; nil keeps them in English.
+	Messages MessageCatalog
+	// RequestTimeout bounds each request; database statements still running when it passes
+	// are interrupted and the request fails with 504 timeout. 0 leaves only client disconnects.
+	RequestTimeout time.Duration
+	// GraphQL serves the read-only GraphQL API at /graphql.
+	GraphQL bool
+	// RequestLog receives one record per request with its ID, method, path, actor, status and
+	// duration; nil disables request logging. Request IDs are assigned either way.
+	RequestLog *slog.Logger
+	// Chaos injects latency, failures and lease races for testing clients; never set it in
+	// production.
+	Chaos ChaosConfig
+	// Compression gzip-encodes responses of at least 1 KiB for clients that accept it.
+	Compression bool
+	// CORS lets browser code on the allowed origins call the API; the zero value allows none.
+	CORS config.CORS
+	// SecurityHeaders sends nosniff, framing, referrer and content security policy headers,
+	// and HSTS over TLS, on every response.
+	SecurityHeaders bool
+	// DisableDevLogin stops serving POST /auth/dev/login, which mints tokens for any actor.
+	DisableDevLogin bool
 }
 
 type apiErrorBody struct {
 	Code    string         `json:"code" example:"forbidden_attestation_kind"`
 	Message string         `json:"message" example:"actor cannot attest to this kind"`
 	Details map[string]any `json:"details,omitempty" jsonschema:"type=object,additionalProperties=true" example:"{\"kind\":\"security.ok\"}"`
+	// RequestID echoes X-Request-Id so a reported error can be matched to logs and events.
+	RequestID string `json:"request_id,omitempty" example:"4f1c2a9e0b7d4c3a8e6f5d2b1a0c9e8f"`
 }
 
 type requestKey struct{}
@@ -57,6 +106,19 @@ func New(cfg Config) (http.Handler, error) {
 	if !strings.HasPrefix(basePath, "/") {
 		basePath = "/" + basePath
 	}
+	switch cfg.ContractValidation {
+	case ContractOff, ContractLog, ContractEnforce:
+	default:
+		return nil, fmt.Errorf("unknown contract validation mode %q", cfg.ContractValidation)
+	}
+	switch cfg.JSONDecoding {
+	case "", JSONStrict, JSONLenient:
+	default:
+		return nil, fmt.Errorf("unknown JSON decoding mode %q", cfg.JSONDecoding)
+	}
+	if err := cfg.Chaos.validate(); err != nil {
+		return nil, err
+	}
 	huma.DefaultArrayNullable = false
 	// Override Huma errors to use the requested envelope.
 	huma.NewError = func(status int, msg string, errs ...error) huma.StatusError {
@@ -71,39 +133,131 @@ func New(cfg Config) (http.Handler, error) {
 		if len(errs) > 0 {
 			details = map[string]any{"errors": errs}
 		}
+		if fields := unknownFields(errs); status == http.StatusBadRequest && len(fields) > 0 {
+			msg = unknownFieldsMessage(fields)
+			details["unknown_fields"] = fields
+		}
 		return newAPIError(status, "", msg, details)
 	}
 
+	v1Path := path.Join(path.Dir(basePath), APIVersionV1)
+	if v1Path == basePath {
+		return nil, fmt.Errorf("base path %s is where the v1 API is served; choose the v0 base path", basePath)
+	}
+	v0, err := newVersionHandler(cfg, APIVersionV0, basePath, v1Path)
+	if err != nil {
+		return nil, err
+	}
+	v1, err := newVersionHandler(cfg, APIVersionV1, v1Path, "")
+	if err != nil {
+		return nil, err
+	}
+	handler := newVersionRouter(cfg, basePath, v1Path, v0, v1)
+	if cfg.CORS.Enabled() {
+		handler = newCORSMiddleware(cfg.CORS)(handler)
+	}
+	if cfg.SecurityHeaders {
+		handler = newSecurityHeadersMiddleware()(handler)
+	}
+	return handler, nil
+}
+
+// newVersionHandler serves one API version below basePath. A successor marks the version
+// deprecated in favour of the one served there.
+func newVersionHandler(cfg Config, version, basePath, successor string) (http.Handler, error) {
+	rowBudget := cfg.RowBudget
+	if rowBudget <= 0 {
+		rowBudget = repo.DefaultRowBudget
+	}
+	graphQLPath := path.Join(basePath, "graphql")
 	router := chi.NewRouter()
+	router.Use(newRequestLogger(cfg.RequestLog))
+	if cfg.Compression {
+		router.Use(newCompressionMiddleware())
+	}
+	if cfg.Chaos.Enabled() {
+		router.Use(newChaosMiddleware(basePath, cfg.Chaos))
+	}
+	if successor != "" {
+		router.Use(newDeprecationMiddleware(basePath, successor, cfg.V0Sunset))
+	}
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			bodyBytes, _ := io.ReadAll(r.Body)
 			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 			ctx := context.WithValue(r.Context(), requestKey{}, r)
 			ctx = context.WithValue(ctx, bodyBytesKey{}, bodyBytes)
+			ctx = repo.WithRowBudget(ctx, rowBudget)
+			ctx = context.WithValue(ctx, messageCatalogKey{}, cfg.Messages)
+			read := r.Method == http.MethodGet || r.Method == http.MethodHead || cfg.GraphQL && r.Method == http.MethodPost && r.URL.Path == graphQLPath
+			if read && !strings.EqualFold(r.Header.Get("X-Read-From"), "primary") {
+				ctx = repo.PreferReplica(ctx)
+			}
+			if cfg.RequestTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, cfg.RequestTimeout)
+				defer cancel()
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
-	router.Use(newAuthMiddleware(basePath, cfg.Auth, cfg.Engine.Repo))
-	hcfg := huma.DefaultConfig("Workline API", "0.1.1")
+	router.Use(newAuthMiddleware(basePath, cfg.Auth, cfg.Engine.Store()))
+	router.Use(newUsageRecorder(basePath, cfg.Engine))
+	router.Use(newDenialRecorder(basePath, cfg.Engine))
+	router.Use(newETagMiddleware(basePath))
+	var api huma.API
+	router.Use(newContractMiddleware(cfg.ContractValidation, cfg.Auth.logger(), func() *huma.OpenAPI { return api.OpenAPI() }))
+	hcfg := huma.DefaultConfig("Workline API", specVersions[version])
+	if successor != "" {
+		hcfg.Info.Description = fmt.Sprintf("Deprecated: %s serves the current version. Responses carry Deprecation and Link: rel=\"successor-version\" headers.", successor)
+	}
 	hcfg.OpenAPIPath = "/openapi"
 	hcfg.DocsPath = "" // custom Swagger UI below
-	api := humachi.New(router, hcfg)
-	group := huma.NewGroup(api, basePath)
+	hcfg.Formats = map[string]huma.Format{"application/json": streamingJSONFormat, "json": streamingJSONFormat}
+	hcfg.Transformers = append(hcfg.Transformers, newMessageTransformer(), newRequestIDTransformer())
+	api = humachi.New(router, hcfg)
+	group := versionedAPI{Group: huma.NewGroup(api, basePath), version: version}
 
-	registerDocs(router, basePath)
+	registerDocs(router, basePath, successor)
 	registerHealth(group)
 	registerStatus(group, cfg.Engine)
 	registerProjects(group, cfg.Engine)
+	registerPrograms(group, cfg.Engine)
+	registerOrgs(group, cfg.Engine)
+	registerCompliance(group, cfg.Engine)
+	registerUsage(group, cfg.Engine)
+	registerAnalytics(group, cfg.Engine)
+	registerDigests(group, cfg.Engine)
+	registerEscalations(group, cfg.Engine)
+	registerNotifications(group, cfg.Engine)
+	registerSecrets(group, cfg.Engine)
+	registerFeatures(group, cfg.Engine, map[string]bool{config.FeatureGraphQL: cfg.GraphQL, config.FeatureLeaseQueue: true})
 	registerTasks(group, cfg.Engine)
 	registerIterations(group, cfg.Engine)
+	registerIterationFreeze(group, cfg.Engine)
 	registerDecisions(group, cfg.Engine)
 	registerAttestations(group, cfg.Engine)
+	registerBlobs(group, cfg.Engine)
+	registerArtifacts(group, cfg.Engine)
 	registerEvents(group, cfg.Engine)
+	registerIntegrations(group, cfg.Engine)
+	registerViews(group, cfg.Engine)
+	registerAdmin(group, cfg.Engine, cfg.QueryStats, cfg.SlowQuery)
+	registerEffectiveConfig(group, cfg.Engine)
 	registerRBAC(group, cfg.Engine)
 	registerMe(group, cfg.Engine)
-	registerDevAuth(group, cfg.Engine, cfg.Auth)
+	if !cfg.DisableDevLogin {
+		registerDevAuth(group, cfg.Engine, cfg.Auth)
+	}
+	if cfg.GraphQL {
+		if err := registerGraphQL(group, cfg.Engine); err != nil {
+			return nil, err
+		}
+	}
 	registerOpenAPI(router, api, basePath)
+	if cfg.JSONDecoding == JSONLenient {
+		allowUnknownFields(api.OpenAPI())
+	}
 
 	return router, nil
 }
@@ -134,7 +288,37 @@ func handleError(err error) huma.StatusError {
 	if errors.As(err, &ae) {
 		return newAPIError(http.StatusForbidden, "forbidden_attestation_kind", err.Error(), map[string]any{"kind": ae.Kind})
 	}
-	if errors.Is(err, repo.ErrNotFound) {
+	var qe repo.QueryBudgetError
+	if errors.As(err, &qe) {
+		return newAPIError(http.StatusUnprocessableEntity, "query_budget_exceeded", err.Error(), map[string]any{"guard": qe.Guard, "max": qe.Max})
+	}
+	if repo.IsTimeout(err) {
+		return newAPIError(http.StatusGatewayTimeout, "timeout", "request timed out before the database finished", map[string]any{"error": err.Error()})
+	}
+	var pe engine.PayloadTooLargeError
+	if errors.As(err, &pe) {
+		return newAPIError(http.StatusRequestEntityTooLarge, "payload_too_large", err.Error(), map[string]any{"field": pe.Field, "size": pe.Size, "max": pe.Max})
+	}
+	var moe engine.MissingOutcomesError
+	if errors.As(err, &moe) {
+		fields := make([]map[string]any, 0, len(moe.Fields))
+		for _, field := range moe.Fields {
+			fields = append(fields, map[string]any{"field": "work_outcomes." + field, "error": "required"})
+		}
+		return newAPIError(http.StatusUnprocessableEntity, "missing_work_outcomes", err.Error(), map[string]any{"task_type": moe.TaskType, "fields": fields})
+	}
+	var ife engine.IterationFrozenError
+	if errors.As(err, &ife) {
+		return newAPIError(http.StatusConflict, "iteration_frozen", err.Error(), map[string]any{"iteration_id": ife.IterationID, "task_id": ife.TaskID, "change": ife.Change})
+	}
+	var fde engine.FeatureDisabledError
+	if errors.As(err, &fde) {
+		return newAPIError(http.StatusNotFound, "feature_disabled", err.Error(), map[string]any{"feature": fde.Feature, "project_id": fde.ProjectID})
+	}
+	if errors.Is(err, engine.ErrNoEvidenceSigner) {
+		return newAPIError(http.StatusServiceUnavailable, "evidence_unavailable", err.Error(), nil)
+	}
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, blob.ErrNotFound) {
 		return newAPIError(http.StatusNotFound, "not_found", err.Error(), nil)
 	}
 	msg := err.Error()
@@ -150,6 +334,8 @@ func handleError(err error) huma.StatusError {
 		return newAPIError(http.StatusUnprocessableEntity, "validation_failed", msg, nil)
 	case strings.Contains(lowered, "invalid") || strings.Contains(lowered, "missing") || strings.Contains(lowered, "required"):
 		return newAPIError(http.StatusBadRequest, "bad_request", msg, nil)
+	case strings.Contains(lowered, "lease") && (strings.Contains(lowered, "expired") || strings.Contains(lowered, "transfer")):
+		return newAPIError(http.StatusConflict, "lease_conflict", msg, nil)
 	default:
 		return newAPIError(http.StatusInternalServerError, "internal_error", "internal error", map[string]any{"error": msg})
 	}
@@ -167,6 +353,8 @@ func defaultCodeForStatus(status int) string {
 		return "validation_failed"
 	case http.StatusForbidden:
 		return "forbidden"
+	case http.StatusGatewayTimeout:
+		return "timeout"
 	case http.StatusInternalServerError:
 		return "internal_error"
 	default:
@@ -183,20 +371,21 @@ func hasPermission(perms []string, perm string) bool {
 	return false
 }
 
-func requirePermission(ctx context.Context, e engine.Engine, projectID, perm string) error {
+func requirePermission(ctx context.Context, e Engine, projectID, perm string) error {
 	principal, authErr := principalFromRequest(ctx)
 	if authErr != nil {
 		return authErr
 	}
+	if err := requireProjectOrg(ctx, e, principal, projectID); err != nil {
+		return err
+	}
+	if principal.ProjectID != "" && principal.ProjectID != projectID {
+		return auth.ForbiddenError{Permission: perm}
+	}
 	if hasPermission(principal.Permissions, perm) {
 		return nil
 	}
-	tx, err := e.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	ok, err := e.Auth.ActorHasPermission(ctx, tx, projectID, principal.ActorID, perm)
+	ok, err := e.ActorHasPermission(ctx, projectID, principal.ActorID, perm)
 	if err != nil {
 		return err
 	}
@@ -206,24 +395,54 @@ func requirePermission(ctx context.Context, e engine.Engine, projectID, perm str
 	return nil
 }
 
-func requireGlobalPermission(ctx context.Context, e engine.Engine, perm string) error {
+func requireGlobalPermission(ctx context.Context, e Engine, perm string) error {
 	principal, authErr := principalFromRequest(ctx)
 	if authErr != nil {
 		return authErr
 	}
+	if principal.ProjectID != "" {
+		return auth.ForbiddenError{Permission: perm}
+	}
 	if hasPermission(principal.Permissions, perm) {
 		return nil
 	}
-	if e.Config == nil {
+	if e.DefaultConfig() == nil {
+		return auth.ForbiddenError{Permission: perm}
+	}
+	err := requirePermission(ctx, e, e.DefaultConfig().Project.ID, perm)
+	if errors.Is(err, repo.ErrNotFound) {
+		// Credentials of another org do not reach the default project.
 		return auth.ForbiddenError{Permission: perm}
 	}
-	return requirePermission(ctx, e, e.Config.Project.ID, perm)
+	return err
 }
 
-func registerDocs(r chi.Router, basePath string) {
+// requireProjectOrg hides the projects of other orgs: credentials reach only the projects
+// of the org they are bound to. Projects that do not exist, such as one being created, pass.
+func requireProjectOrg(ctx context.Context, e Engine, principal Principal, projectID string) error {
+	p, err := e.Store().GetProject(ctx, projectID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if p.OrgID != principal.OrgID {
+		return fmt.Errorf("project %s: %w", projectID, repo.ErrNotFound)
+	}
+	return nil
+}
+
+// registerDocs serves Swagger UI at /docs. The v0 handler, which answers /docs, lists its
+// successor's spec first.
+func registerDocs(r chi.Router, basePath, successor string) {
+	bases := []string{basePath}
+	if successor != "" {
+		bases = []string{successor, basePath}
+	}
 	r.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
-		io.WriteString(w, swaggerHTML(basePath))
+		io.WriteString(w, swaggerHTML(bases))
 	})
 }
 
@@ -321,8 +540,12 @@ func applyAuthSecurity(oas *huma.OpenAPI, basePath string) {
 	}
 }
 
-func swaggerHTML(basePath string) string {
-	specURL := path.Join("/", path.Join(basePath, "openapi.json"))
+func swaggerHTML(basePaths []string) string {
+	specs := make([]string, 0, len(basePaths))
+	for _, basePath := range basePaths {
+		specURL := path.Join("/", path.Join(basePath, "openapi.json"))
+		specs = append(specs, fmt.Sprintf("{url: '%s', name: '%s'}", specURL, path.Base(basePath)))
+	}
 	return fmt.Sprintf(`<!doctype html>
 <html lang="en">
   <head>
@@ -334,11 +557,14 @@ func swaggerHTML(basePath string) string {
   <body>
     <div id="swagger-ui"></div>
     <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-standalone-preset.js" crossorigin></script>
     <script>
       window.onload = () => {
         SwaggerUIBundle({
-          url: '%s',
-          dom_id: '#swagger-ui'
+          urls: [%s],
+          dom_id: '#swagger-ui',
+          presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+          layout: 'StandaloneLayout'
         });
       };
     </script>
@@ -346,7 +572,7 @@ func swaggerHTML(basePath string) string {
       Authenticate with Authorization: Bearer &lt;token&gt; or X-Api-Key.
     </p>
   </body>
-</html>`, specURL)
+</html>`, strings.Join(specs, ", "))
 }
 
 func registerHealth(api huma.API) {
@@ -364,7 +590,7 @@ func registerHealth(api huma.API) {
 	})
 }
 
-func registerStatus(api huma.API, e engine.Engine) {
+func registerStatus(api huma.API, e Engine) {
 	type projectPath struct {
 		ProjectID string `path:"project_id"`
 	}
@@ -373,43 +599,218 @@ func registerStatus(api huma.API, e engine.Engine) {
 		Method:      http.MethodGet,
 		Path:        "/projects/{project_id}/status",
 		Summary:     "Project status",
+		Description: "Task counts by status, the running iteration and the caller's unread notifications (unread_notifications).",
 	}, func(ctx context.Context, input *projectPath) (*struct {
 		Body map[string]any `json:"body"`
 	}, error) {
-		activeProject := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		activeProject := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, activeProject, "project.status.read"); err != nil {
 			return nil, handleError(err)
 		}
-		p, err := e.Repo.GetProject(ctx, activeProject)
+		p, err := e.Store().GetProject(ctx, activeProject)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		counts, err := e.Repo.CountTasksByStatus(ctx, p.ID)
+		counts, err := e.Store().CountTasksByStatus(ctx, p.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		running, err := e.Repo.LatestRunningIteration(ctx, p.ID)
+		running, err := e.Store().LatestRunningIteration(ctx, p.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		unread, err := e.Store().CountUnreadNotifications(ctx, p.ID, actorID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		return &struct {
 			Body map[string]any `json:"body"`
 		}{Body: map[string]any{
-			"project_id":  p.ID,
-			"status":      p.Status,
-			"iteration":   running,
-			"task_counts": counts,
+			"project_id":           p.ID,
+			"status":               p.Status,
+			"iteration":            running,
+			"task_counts":          counts,
+			"unread_notifications": unread,
 		}}, nil
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "stats-timeseries",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/stats/timeseries",
+		Summary:     "Daily project metric series",
+		Description: "Reads recorded daily snapshots; days without a snapshot are omitted.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Metric    string `query:"metric" required:"true" enum:"tasks_open,tasks_done,tasks_completed,attestations_issued,lead_time_seconds"`
+		From      string `query:"from" doc:"First day (YYYY-MM-DD), defaults to 30 days before to"`
+		To        string `query:"to" doc:"Last day (YYYY-MM-DD), defaults to today (UTC)"`
+	}) (*struct {
+		Body TimeseriesResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.status.read"); err != nil {
+			return nil, handleError(err)
+		}
+		if _, err := e.Store().GetProject(ctx, projectID); err != nil {
+			return nil, handleError(err)
+		}
+		to := time.Now().UTC()
+		if input.To != "" {
+			parsed, err := time.Parse(time.DateOnly, input.To)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid to", map[string]any{"to": input.To})
+			}
+			to = parsed
+		}
+		from := to.AddDate(0, 0, -30)
+		if input.From != "" {
+			parsed, err := time.Parse(time.DateOnly, input.From)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid from", map[string]any{"from": input.From})
+			}
+			from = parsed
+		}
+		if from.After(to) {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "from must not be after to", nil)
+		}
+		resp := TimeseriesResponse{
+			ProjectID: projectID,
+			Metric:    input.Metric,
+			From:      from.Format(time.DateOnly),
+			To:        to.Format(time.DateOnly),
+			Points:    []TimeseriesPoint{},
+		}
+		snaps, err := e.Store().ListStatsSnapshots(ctx, projectID, resp.From, resp.To)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		for _, s := range snaps {
+			resp.Points = append(resp.Points, TimeseriesPoint{Day: s.Day, Value: statsMetric(s, input.Metric)})
+		}
+		return &struct {
+			Body TimeseriesResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+}
+
+func registerCompliance(api huma.API, e Engine) {
+	reportSchema := api.OpenAPI().Components.Schemas.Schema(reflect.TypeOf(engine.ComplianceReport{}), true, "ComplianceReport")
+	huma.Register(api, huma.Operation{
+		OperationID: "compliance-report",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/reports/compliance",
+		Summary:     "Map attestations to declared compliance controls for a period",
+		Description: "Checks each control in config.compliance.controls against the tasks completed in the period and the tasks and iterations attested in it. format=csv returns one line per entity and control.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+		Responses: map[string]*huma.Response{
+			"200": {
+				Description: "Compliance report",
+				Content: map[string]*huma.MediaType{
+					"application/json": {Schema: reportSchema},
+					"text/csv":         {Schema: &huma.Schema{Type: "string"}},
+				},
+			},
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		From      string `query:"from" doc:"Period start (RFC3339 or YYYY-MM-DD), defaults to 30 days before to"`
+		To        string `query:"to" doc:"Period end, exclusive (RFC3339), or last day included (YYYY-MM-DD); defaults to today (UTC)"`
+		Format    string `query:"format" enum:"json,csv" default:"json"`
+	}) (*struct {
+		ContentType string `header:"Content-Type"`
+		Body        []byte
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "compliance.read"); err != nil {
+			return nil, handleError(err)
+		}
+		from, to, err := engine.ParsePeriod(input.From, input.To, time.Now())
+		if err != nil {
+			return nil, handleError(err)
+		}
+		rep, err := e.ComplianceReport(ctx, projectID, from, to)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		out := &struct {
+			ContentType string `header:"Content-Type"`
+			Body        []byte
+		}{ContentType: "application/json"}
+		if input.Format == "csv" {
+			var buf bytes.Buffer
+			if err := rep.WriteCSV(&buf); err != nil {
+				return nil, handleError(err)
+			}
+			out.ContentType = "text/csv"
+			out.Body = buf.Bytes()
+			return out, nil
+		}
+		if out.Body, err = json.Marshal(rep); err != nil {
+			return nil, handleError(err)
+		}
+		return out, nil
+	})
+}
+
+func registerPrograms(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "program-summary",
+		Method:      http.MethodGet,
+		Path:        "/programs/{id}/summary",
+		Summary:     "Roll up a program and the projects below it",
+		Description: "A program is any project with child projects (parent_project_id). Totals cover the program's own tasks and every descendant project the caller may read; the others are only counted in hidden.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ID string `path:"id"`
+	}) (*struct {
+		Body engine.ProgramSummary `json:"body"`
+	}, error) {
+		if err := requirePermission(ctx, e, input.ID, "project.status.read"); err != nil {
+			return nil, handleError(err)
+		}
+		sum, err := e.SummarizeProgram(ctx, input.ID, func(projectID string) bool {
+			return requirePermission(ctx, e, projectID, "project.status.read") == nil
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.ProgramSummary `json:"body"`
+		}{Body: sum}, nil
+	})
+}
+
+// createProjectResponses documents the 201 of a new project and the 200 of a repeated
+// creation.
+func createProjectResponses(api huma.API) map[string]*huma.Response {
+	schema := api.OpenAPI().Components.Schemas.Schema(reflect.TypeOf(ProjectResponse{}), true, "ProjectResponse")
+	return map[string]*huma.Response{
+		"201": {
+			Description: "Project created",
+			Content:     map[string]*huma.MediaType{"application/json": {Schema: schema}},
+		},
+		"200": {
+			Description: "Project already created by an identical request",
+			Content:     map[string]*huma.MediaType{"application/json": {Schema: schema}},
+		},
+	}
 }
 
-func registerProjects(api huma.API, e engine.Engine) {
+func registerProjects(api huma.API, e Engine) {
 	huma.Register(api, huma.Operation{
 		OperationID:   "create-project",
 		Method:        http.MethodPost,
 		Path:          "/projects",
 		Summary:       "Create project",
+		Description:   "Creates the project with its default config and roles in one transaction. Repeating the request returns the existing project with 200 when it is in the same org and program and the caller may read it.",
 		DefaultStatus: http.StatusCreated,
+		Responses:     createProjectResponses(api),
 		Errors: []int{
 			http.StatusBadRequest,
 			http.StatusForbidden,
@@ -421,7 +822,8 @@ func registerProjects(api huma.API, e engine.Engine) {
 	}, func(ctx context.Context, input *struct {
 		Body CreateProjectRequest `json:"body"`
 	}) (*struct {
-		Body ProjectResponse `json:"body"`
+		Status int
+		Body   ProjectResponse `json:"body"`
 	}, error) {
 		if len(bodyBytes(ctx)) == 0 {
 			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
@@ -432,45 +834,36 @@ func registerProjects(api huma.API, e engine.Engine) {
 		if err := requireGlobalPermission(ctx, e, "project.create"); err != nil {
 			return nil, handleError(err)
 		}
-		actorID, authErr := actorIDFromContext(ctx)
-		if authErr != nil {
-			return nil, authErr
-		}
-		desc := ""
-		if input.Body.Description != nil {
-			desc = *input.Body.Description
-		}
-		p, err := e.InitProject(ctx, input.Body.ID, desc, actorID)
+		p, created, err := createProject(ctx, e, "", input.Body)
 		if err != nil {
-			return nil, handleError(err)
-		}
-		if err := e.Repo.UpsertProjectConfig(ctx, p.ID, config.Default(p.ID)); err != nil {
-			return nil, handleError(err)
+			return nil, err
 		}
 		return &struct {
-			Body ProjectResponse `json:"body"`
-		}{Body: projectResponse(p)}, nil
+			Status int
+			Body   ProjectResponse `json:"body"`
+		}{Status: createdStatus(created), Body: projectResponse(p)}, nil
 	})
 
-	huma.Register(api, huma.Operation{
+	registerList(api, huma.Operation{
 		OperationID: "list-projects",
 		Method:      http.MethodGet,
 		Path:        "/projects",
 		Summary:     "List projects",
 		Errors:      []int{http.StatusBadRequest},
-	}, func(ctx context.Context, _ *struct{}) (*struct {
-		Body []ProjectResponse `json:"body"`
-	}, error) {
+	}, func(ctx context.Context, input *struct {
+		ParentProjectID string `query:"parent_project_id" doc:"Only direct children of this program"`
+	}) ([]ProjectResponse, error) {
 		if err := requireGlobalPermission(ctx, e, "project.list"); err != nil {
 			return nil, handleError(err)
 		}
-		items, err := e.Repo.ListProjects(ctx)
+		items, err := e.Store().ListProjects(ctx)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		return &struct {
-			Body []ProjectResponse `json:"body"`
-		}{Body: mapProjects(items)}, nil
+		if input.ParentProjectID != "" {
+			items = slices.DeleteFunc(items, func(p domain.Project) bool { return p.ParentProjectID != input.ParentProjectID })
+		}
+		return mapProjects(items), nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -484,11 +877,11 @@ func registerProjects(api huma.API, e engine.Engine) {
 	}) (*struct {
 		Body ProjectResponse `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "project.read"); err != nil {
 			return nil, handleError(err)
 		}
-		p, err := e.Repo.GetProject(ctx, projectID)
+		p, err := e.Store().GetProject(ctx, projectID)
 		if err != nil {
 			return nil, handleError(err)
 		}
@@ -512,8 +905,12 @@ func registerProjects(api huma.API, e engine.Engine) {
 	}, func(ctx context.Context, input *struct {
 		ProjectID string `path:"project_id"`
 		Body      struct {
-			Status      string  `json:"status,omitempty"`
-			Description *string `json:"description,omitempty"`
+			Status          string          `json:"status,omitempty" enum:"active,paused,archived"`
+			Description     *string         `json:"description,omitempty"`
+			DisplayName     *string         `json:"display_name,omitempty" maxLength:"200" doc:"Human-friendly name; empty string clears it"`
+			Tags            *[]string       `json:"tags,omitempty" doc:"Replaces the tags; [] clears them"`
+			Metadata        *map[string]any `json:"metadata,omitempty" doc:"Replaces the metadata map; {} clears it"`
+			ParentProjectID *string         `json:"parent_project_id,omitempty" doc:"Move under this program; empty string moves to the top level"`
 		} `json:"body"`
 	}) (*struct {
 		Body ProjectResponse `json:"body"`
@@ -521,17 +918,33 @@ func registerProjects(api huma.API, e engine.Engine) {
 		if len(bodyBytes(ctx)) == 0 {
 			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "project.update"); err != nil {
 			return nil, handleError(err)
 		}
-		if _, authErr := actorIDFromContext(ctx); authErr != nil {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
 			return nil, authErr
 		}
-		if err := e.Repo.UpdateProject(ctx, projectID, input.Body.Status, input.Body.Description); err != nil {
+		if _, err := e.UpdateProject(ctx, engine.ProjectUpdateOptions{
+			ProjectID: projectID,
+			ActorID:   actorID,
+			ProjectUpdate: repo.ProjectUpdate{
+				Status:      input.Body.Status,
+				Description: input.Body.Description,
+				DisplayName: input.Body.DisplayName,
+				Tags:        input.Body.Tags,
+				Metadata:    input.Body.Metadata,
+			},
+		}); err != nil {
 			return nil, handleError(err)
 		}
-		p, err := e.Repo.GetProject(ctx, projectID)
+		if input.Body.ParentProjectID != nil {
+			if _, err := e.SetProjectParent(ctx, projectID, *input.Body.ParentProjectID, actorID); err != nil {
+				return nil, handleError(err)
+			}
+		}
+		p, err := e.Store().GetProject(ctx, projectID)
 		if err != nil {
 			return nil, handleError(err)
 		}
@@ -554,14 +967,14 @@ func registerProjects(api huma.API, e engine.Engine) {
 	}, func(ctx context.Context, input *struct {
 		ProjectID string `path:"project_id"`
 	}) (*struct{}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "project.delete"); err != nil {
 			return nil, handleError(err)
 		}
 		if _, authErr := actorIDFromContext(ctx); authErr != nil {
 			return nil, authErr
 		}
-		if err := e.Repo.DeleteProject(ctx, projectID); err != nil {
+		if err := e.Store().DeleteProject(ctx, projectID); err != nil {
 			return nil, handleError(err)
 		}
 		return &struct{}{}, nil
@@ -578,11 +991,11 @@ func registerProjects(api huma.API, e engine.Engine) {
 	}) (*struct {
 		Body ProjectConfigResponse `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "project.config.read"); err != nil {
 			return nil, handleError(err)
 		}
-		cfg, err := e.Repo.GetProjectConfig(ctx, projectID)
+		cfg, err := e.Store().GetProjectConfig(ctx, projectID)
 		if err != nil {
 			return nil, handleError(err)
 		}
@@ -590,142 +1003,371 @@ func registerProjects(api huma.API, e engine.Engine) {
 			Body ProjectConfigResponse `json:"body"`
 		}{Body: configResponse(cfg)}, nil
 	})
-}
 
-func registerTasks(api huma.API, e engine.Engine) {
 	huma.Register(api, huma.Operation{
-		OperationID:   "create-task",
-		Method:        http.MethodPost,
-		Path:          "/projects/{project_id}/tasks",
-		Summary:       "Create task",
-		DefaultStatus: http.StatusCreated,
-		Errors: []int{
-			http.StatusBadRequest,
-			http.StatusForbidden,
-			http.StatusNotFound,
-			http.StatusUnprocessableEntity,
-			http.StatusConflict,
-			http.StatusInternalServerError,
-		},
+		OperationID: "plan-project-config",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/config/plan",
+		Summary:     "Diff a config against the project's current one",
+		Description: "Takes a full config as YAML and returns the semantic diff PUT /projects/{project_id}/config would apply: presets added, removed or with changed requirements, task defaults, attestation kinds, task types, transitions and other settings, plus the open tasks the change concerns and why. Nothing is stored.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string            `path:"project_id"`
-		Body      CreateTaskRequest `json:"body"`
+		ProjectID string `path:"project_id"`
+		RawBody   []byte `contentType:"application/yaml"`
 	}) (*struct {
-		Body TaskResponse `json:"body"`
+		Body engine.ConfigPlan `json:"body"`
 	}, error) {
-		bodyMap := rawBodyMap(ctx)
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		if err := requirePermission(ctx, e, input.ProjectID, "project.config.read"); err != nil {
+			return nil, handleError(err)
 		}
-		if input.Body.Title == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "title is required", nil)
+		next, err := parseConfigBody(input.RawBody)
+		if err != nil {
+			return nil, err
 		}
-		if input.Body.Type == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "type is required", nil)
+		plan, err := e.PlanConfigChange(ctx, input.ProjectID, next)
+		if err != nil {
+			return nil, handleError(err)
 		}
-		if isNullRaw(bodyMap["depends_on"]) {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "depends_on must be array", map[string]any{"field": "depends_on", "reason": "must be array"})
+		return &struct {
+			Body engine.ConfigPlan `json:"body"`
+		}{Body: plan}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "update-project-config",
+		Method:      http.MethodPut,
+		Path:        "/projects/{project_id}/config",
+		Summary:     "Replace the project config",
+		Description: "Takes a full config as YAML, stores it and returns the diff applied, as POST /projects/{project_id}/config/plan reports it. Existing tasks keep their requirements. Requires project.update.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		RawBody   []byte `contentType:"application/yaml"`
+	}) (*struct {
+		Body engine.ConfigPlan `json:"body"`
+	}, error) {
+		if err := requirePermission(ctx, e, input.ProjectID, "project.update"); err != nil {
+			return nil, handleError(err)
 		}
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		opts := engine.TaskCreateOptions{
-			ProjectID:   projectID,
-			Type:        input.Body.Type,
-			Title:       input.Body.Title,
-			ActorID:     actorID,
-			Description: stringOrEmpty(input.Body.Description),
-			DependsOn:   input.Body.DependsOn,
-		}
-		if input.Body.ID != nil {
-			opts.ID = *input.Body.ID
-		}
-		if input.Body.IterationID != nil {
-			opts.IterationID = *input.Body.IterationID
-		}
-		if input.Body.ParentID != nil {
-			opts.ParentID = *input.Body.ParentID
-		}
-		if input.Body.AssigneeID != nil {
-			opts.AssigneeID = *input.Body.AssigneeID
-		}
-		if input.Body.Policy != nil {
-			opts.PolicyPreset = input.Body.Policy.Preset
-		} else if rawPolicy, ok := bodyMap["policy"]; ok {
-			var policy TaskPolicyRequest
-			if err := json.Unmarshal(rawPolicy, &policy); err == nil && policy.Preset != "" {
-				opts.PolicyPreset = policy.Preset
-			}
-		}
-		if rawValidation, ok := bodyMap["validation"]; ok {
-			var validationMap map[string]json.RawMessage
-			if len(rawValidation) > 0 {
-				_ = json.Unmarshal(rawValidation, &validationMap)
-				if isNullRaw(validationMap["require"]) {
-					return nil, newAPIError(http.StatusBadRequest, "bad_request", "validation.require must be array", map[string]any{"field": "validation.require", "reason": "must be array"})
-				}
-			}
-			if input.Body.Validation != nil {
-				opts.PolicyOverride = true
-				opts.RequiredKinds = input.Body.Validation.Require
-			}
-		}
-		if input.Body.WorkOutcomes != nil {
-			b, err := json.Marshal(input.Body.WorkOutcomes)
-			if err != nil {
-				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid work_outcomes", map[string]any{"error": err.Error()})
-			}
-			asStr := string(b)
-			opts.WorkOutcomesJSON = &asStr
+		next, err := parseConfigBody(input.RawBody)
+		if err != nil {
+			return nil, err
 		}
-		t, err := e.CreateTask(ctx, opts)
+		plan, err := e.UpdateProjectConfig(ctx, input.ProjectID, actorID, next)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		return &struct {
-			Body TaskResponse `json:"body"`
-		}{Body: taskResponse(t)}, nil
+			Body engine.ConfigPlan `json:"body"`
+		}{Body: plan}, nil
 	})
 
 	huma.Register(api, huma.Operation{
-		OperationID: "list-tasks",
+		OperationID: "list-task-types",
 		Method:      http.MethodGet,
-		Path:        "/projects/{project_id}/tasks",
-		Summary:     "List tasks",
-		Errors:      []int{http.StatusBadRequest},
+		Path:        "/projects/{project_id}/task-types",
+		Summary:     "List built-in and declared task types with their custom field schemas",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
 	}, func(ctx context.Context, input *struct {
-		ProjectID   string `path:"project_id"`
-		Status      string `query:"status"`
-		IterationID string `query:"iteration_id"`
-		ParentID    string `query:"parent_id"`
-		AssigneeID  string `query:"assignee_id"`
-		Limit       int    `query:"limit" default:"50"`
-		Cursor      string `query:"cursor"`
+		ProjectID string `path:"project_id"`
 	}) (*struct {
-		Body paginatedTasks `json:"body"`
+		Body TaskTypesResponse `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		if err := requirePermission(ctx, e, projectID, "task.list"); err != nil {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.config.read"); err != nil {
 			return nil, handleError(err)
 		}
-		limit := normalizeLimit(input.Limit)
-		cursorCreated, cursorID, err := parseCompositeCursor(input.Cursor)
+		resp := TaskTypesResponse{ProjectID: projectID, Types: []TaskTypeResponse{}}
+		for _, tt := range engine.TaskTypes(e.DefaultConfig()) {
+			resp.Types = append(resp.Types, TaskTypeResponse(tt))
+		}
+		return &struct {
+			Body TaskTypesResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "seed-project",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/seed",
+		Summary:     "Load roles, actors, attestation kinds, presets and a task tree from a YAML seed",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		RawBody   []byte `contentType:"application/yaml"`
+	}) (*struct {
+		Body SeedResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if len(input.RawBody) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		s, err := seed.Parse(input.RawBody)
 		if err != nil {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", err.Error(), nil)
+		}
+		res, err := e.ApplySeed(ctx, input.ProjectID, actorID, s)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body SeedResponse `json:"body"`
+		}{Body: seedResponse(res)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "sync-project",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/sync",
+		Summary:     "Reconcile a project with a declarative YAML manifest",
+		Description: "Creates and updates the iterations, policy presets and tasks the manifest declares, matched by ID, in one transaction and returns the plan applied. With prune: true, undeclared presets are removed and undeclared open tasks canceled. dry_run returns the plan without applying it. Each change needs the permission of its API counterpart.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		DryRun    bool   `query:"dry_run" doc:"Report the plan without applying it"`
+		RawBody   []byte `contentType:"application/yaml"`
+	}) (*struct {
+		Body engine.SyncPlan `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if len(input.RawBody) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		if err := requirePermission(ctx, e, input.ProjectID, "task.list"); err != nil {
+			return nil, handleError(err)
+		}
+		m, err := manifest.Parse(input.RawBody)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", err.Error(), nil)
+		}
+		plan, err := e.SyncProject(ctx, input.ProjectID, actorID, m, input.DryRun)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.SyncPlan `json:"body"`
+		}{Body: plan}, nil
+	})
+}
+
+// parseConfigBody reads a full project config sent as YAML.
+func parseConfigBody(body []byte) (*config.Config, error) {
+	if len(body) == 0 {
+		return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+	}
+	cfg, err := config.FromYAML(body)
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "bad_request", err.Error(), nil)
+	}
+	return cfg, nil
+}
+
+func seedResponse(res engine.SeedResult) SeedResponse {
+	out := SeedResponse{
+		Kinds:   nonNilSlice(res.Kinds),
+		Presets: nonNilSlice(res.Presets),
+		Roles:   nonNilSlice(res.Roles),
+		Actors:  nonNilSlice(res.Actors),
+		Tasks:   []TaskResponse{},
+	}
+	for _, t := range res.Tasks {
+		out.Tasks = append(out.Tasks, taskResponse(t))
+	}
+	return out
+}
+
+func registerTasks(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-task",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/tasks",
+		Summary:       "Create task",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusUnprocessableEntity,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string            `path:"project_id"`
+		Body      CreateTaskRequest `json:"body"`
+	}) (*struct {
+		Body TaskResponse `json:"body"`
+	}, error) {
+		bodyMap := rawBodyMap(ctx)
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		if input.Body.Title == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "title is required", nil)
+		}
+		if input.Body.Type == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "type is required", nil)
+		}
+		if isNullRaw(bodyMap["depends_on"]) {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "depends_on must be array", map[string]any{"field": "depends_on", "reason": "must be array"})
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		opts := engine.TaskCreateOptions{
+			ProjectID:            projectID,
+			Type:                 input.Body.Type,
+			Title:                input.Body.Title,
+			ActorID:              actorID,
+			Description:          stringOrEmpty(input.Body.Description),
+			DependsOn:            input.Body.DependsOn,
+			RequiredCapabilities: input.Body.RequiredCapabilities,
+			Labels:               input.Body.Labels,
+			CustomFields:         input.Body.CustomFields,
+			DeferUntil:           stringOrEmpty(input.Body.DeferUntil),
+			DueAt:                stringOrEmpty(input.Body.DueAt),
+			FreezeOverride:       input.Body.FreezeOverride,
+		}
+		if input.Body.ID != nil {
+			opts.ID = *input.Body.ID
+		}
+		if input.Body.IterationID != nil {
+			opts.IterationID = *input.Body.IterationID
+		}
+		if input.Body.ParentID != nil {
+			opts.ParentID = *input.Body.ParentID
+		}
+		if input.Body.AssigneeID != nil {
+			opts.AssigneeID = *input.Body.AssigneeID
+		}
+		if input.Body.Policy != nil {
+			opts.PolicyPreset = input.Body.Policy.Preset
+		} else if rawPolicy, ok := bodyMap["policy"]; ok {
+			var policy TaskPolicyRequest
+			if err := json.Unmarshal(rawPolicy, &policy); err == nil && policy.Preset != "" {
+				opts.PolicyPreset = policy.Preset
+			}
+		}
+		if rawValidation, ok := bodyMap["validation"]; ok {
+			var validationMap map[string]json.RawMessage
+			if len(rawValidation) > 0 {
+				_ = json.Unmarshal(rawValidation, &validationMap)
+				if isNullRaw(validationMap["require"]) {
+					return nil, newAPIError(http.StatusBadRequest, "bad_request", "validation.require must be array", map[string]any{"field": "validation.require", "reason": "must be array"})
+				}
+			}
+			if input.Body.Validation != nil {
+				opts.PolicyOverride = true
+				opts.RequiredKinds = input.Body.Validation.Require
+			}
+		}
+		if input.Body.WorkOutcomes != nil {
+			b, err := json.Marshal(input.Body.WorkOutcomes)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid work_outcomes", map[string]any{"error": err.Error()})
+			}
+			asStr := string(b)
+			opts.WorkOutcomesJSON = &asStr
+		}
+		t, err := e.CreateTask(ctx, opts)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body TaskResponse `json:"body"`
+		}{Body: taskResponse(t)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-tasks",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks",
+		Summary:     "List tasks",
+		Errors:      []int{http.StatusBadRequest},
+	}, func(ctx context.Context, input *struct {
+		ProjectID   string   `path:"project_id"`
+		Status      string   `query:"status"`
+		Type        string   `query:"type"`
+		IterationID string   `query:"iteration_id"`
+		ParentID    string   `query:"parent_id"`
+		AssigneeID  string   `query:"assignee_id"`
+		Field       []string `query:"field,explode" doc:"Custom field filter <name><op><value> with op one of = < <= > >=, e.g. component=billing or points>=3; repeat to combine"`
+		Limit       int      `query:"limit" default:"50"`
+		Cursor      string   `query:"cursor"`
+		Snapshot    bool     `query:"snapshot" doc:"Page through a point-in-time copy of the list; next_cursor then keeps serving that copy and the filters are ignored"`
+	}) (*struct {
+		Body paginatedTasks `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.list"); err != nil {
+			return nil, handleError(err)
+		}
+		useSnapshot := input.Snapshot || isSnapshotCursor(input.Cursor)
+		var fields []repo.CustomFieldFilter
+		for _, raw := range input.Field {
+			f, err := repo.ParseCustomFieldFilter(raw)
+			if err != nil {
+				return nil, handleError(err)
+			}
+			fields = append(fields, f)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
 		}
 		filter := repo.TaskFilters{
-			ProjectID:       projectID,
-			Status:          input.Status,
-			Iteration:       input.IterationID,
-			Parent:          input.ParentID,
-			AssigneeID:      input.AssigneeID,
-			Limit:           limit + 1,
-			CursorCreatedAt: cursorCreated,
-			CursorID:        cursorID,
+			ProjectID:    projectID,
+			Status:       input.Status,
+			Type:         input.Type,
+			Iteration:    input.IterationID,
+			Parent:       input.ParentID,
+			AssigneeID:   input.AssigneeID,
+			CustomFields: fields,
+		}
+		if useSnapshot {
+			actorID, authErr := actorIDFromContext(ctx)
+			if authErr != nil {
+				return nil, authErr
+			}
+			resp, err := listTasksSnapshot(ctx, e, "tasks:"+projectID, actorID, input.Cursor, limit, filter)
+			if err != nil {
+				return nil, err
+			}
+			return &struct {
+				Body paginatedTasks `json:"body"`
+			}{Body: resp}, nil
+		}
+		cursorCreated, cursorID, err := parseCompositeCursor(input.Cursor)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
 		}
-		tasks, err := e.Repo.ListTasks(ctx, filter)
+		filter.Limit = limit + 1
+		filter.CursorCreatedAt = cursorCreated
+		filter.CursorID = cursorID
+		tasks, err := e.Store().ListTasks(ctx, filter)
 		if err != nil {
 			return nil, handleError(err)
 		}
@@ -752,20 +1394,28 @@ func registerTasks(api huma.API, e engine.Engine) {
 	}) (*struct {
 		Body TaskResponse `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "task.read"); err != nil {
 			return nil, handleError(err)
 		}
-		t, err := e.Repo.GetTask(ctx, input.ID)
+		t, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		if !projectMatches(input.ProjectID, t.ProjectID) {
 			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
+		links, err := e.Store().ListTaskLinks(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := taskResponse(t)
+		for _, l := range links {
+			resp.Links = append(resp.Links, taskLinkResponse(l))
+		}
 		return &struct {
 			Body TaskResponse `json:"body"`
-		}{Body: taskResponse(t)}, nil
+		}{Body: resp}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -804,9 +1454,10 @@ func registerTasks(api huma.API, e engine.Engine) {
 			return nil, authErr
 		}
 		opts := engine.TaskUpdateOptions{
-			ID:      input.ID,
-			ActorID: actorID,
-			Force:   input.Force,
+			ID:             input.ID,
+			ActorID:        actorID,
+			Force:          input.Force,
+			FreezeOverride: input.Body.FreezeOverride,
 		}
 		if input.Body.Status != nil {
 			opts.Status = *input.Body.Status
@@ -815,6 +1466,9 @@ func registerTasks(api huma.API, e engine.Engine) {
 			opts.AssignProvided = true
 			opts.Assign = input.Body.AssigneeID
 		}
+		if input.Body.HandoffNote != nil {
+			opts.HandoffNote = *input.Body.HandoffNote
+		}
 		if _, ok := bodyMap["parent_id"]; ok {
 			opts.ParentProvided = true
 			opts.SetParent = input.Body.ParentID
@@ -875,6 +1529,24 @@ func registerTasks(api huma.API, e engine.Engine) {
 			opts.RequiredKindsSet = true
 			opts.RequiredKinds = input.Body.Validation.Require
 		}
+		if _, ok := bodyMap["required_capabilities"]; ok {
+			opts.RequiredCapabilitiesSet = true
+			opts.RequiredCapabilities = input.Body.RequiredCapabilities
+		}
+		if _, ok := bodyMap["labels"]; ok {
+			opts.LabelsSet = true
+			opts.Labels = input.Body.Labels
+		}
+		if _, ok := bodyMap["custom_fields"]; ok {
+			opts.CustomFieldsSet = true
+			if input.Body.CustomFields != nil {
+				opts.CustomFields = *input.Body.CustomFields
+			}
+		}
+		if _, ok := bodyMap["due_at"]; ok {
+			opts.DueAtSet = true
+			opts.DueAt = stringOrEmpty(input.Body.DueAt)
+		}
 		t, err := e.UpdateTask(ctx, opts)
 		if err != nil {
 			return nil, handleError(err)
@@ -888,12 +1560,17 @@ func registerTasks(api huma.API, e engine.Engine) {
 	})
 
 	registerWorkOutcomesUpdates(api, e)
+	registerWaivers(api, e)
+	registerAssignees(api, e)
+	registerDeferrals(api, e)
+	registerCapabilities(api, e)
 
 	huma.Register(api, huma.Operation{
 		OperationID: "complete-task",
 		Method:      http.MethodPost,
 		Path:        "/projects/{project_id}/tasks/{id}/done",
 		Summary:     "Complete task",
+		Description: "When the project's completion.mode is approval, the task moves to review with a pending completion instead, and becomes done once the completion is approved.",
 		Errors: []int{
 			http.StatusBadRequest,
 			http.StatusForbidden,
@@ -938,546 +1615,2530 @@ func registerTasks(api huma.API, e engine.Engine) {
 	})
 
 	huma.Register(api, huma.Operation{
-		OperationID: "claim-task",
+		OperationID: "approve-task-completion",
 		Method:      http.MethodPost,
-		Path:        "/projects/{project_id}/tasks/{id}/claim",
-		Summary:     "Claim task lease",
+		Path:        "/projects/{project_id}/tasks/{id}/completion/approve",
+		Summary:     "Approve the completion awaiting approval",
+		Description: "Moves the task to done and sets completed_at. Requires task.approve; the submitter cannot approve their own completion.",
 		Errors: []int{
 			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusConflict,
 			http.StatusUnprocessableEntity,
-			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID    string `path:"project_id"`
-		ID           string `path:"id"`
-		LeaseSeconds int    `query:"lease_seconds" default:"900"`
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
 	}) (*struct {
-		Body LeaseResponse `json:"body"`
+		Body TaskResponse `json:"body"`
 	}, error) {
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		task, err := e.Repo.GetTask(ctx, input.ID)
+		task, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		if !projectMatches(input.ProjectID, task.ProjectID) {
 			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
-		lease, err := e.ClaimLease(ctx, input.ID, actorID, input.LeaseSeconds)
+		t, err := e.ApproveTaskCompletion(ctx, input.ID, actorID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		return &struct {
-			Body LeaseResponse `json:"body"`
-		}{Body: leaseResponse(lease)}, nil
+			Body TaskResponse `json:"body"`
+		}{Body: taskResponse(t)}, nil
 	})
 
 	huma.Register(api, huma.Operation{
-		OperationID: "release-task",
+		OperationID: "reject-task-completion",
 		Method:      http.MethodPost,
-		Path:        "/projects/{project_id}/tasks/{id}/release",
-		Summary:     "Release task lease",
+		Path:        "/projects/{project_id}/tasks/{id}/completion/reject",
+		Summary:     "Reject the completion awaiting approval",
+		Description: "Moves the task to rejected and records the reason on the completion. Requires task.approve.",
 		Errors: []int{
+			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusConflict,
-			http.StatusUnprocessableEntity,
-			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string `path:"project_id"`
-		ID        string `path:"id"`
-	}) (*struct{}, error) {
+		ProjectID string                  `path:"project_id"`
+		ID        string                  `path:"id"`
+		Body      RejectCompletionRequest `json:"body"`
+	}) (*struct {
+		Body TaskResponse `json:"body"`
+	}, error) {
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		task, err := e.Repo.GetTask(ctx, input.ID)
+		task, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		if !projectMatches(input.ProjectID, task.ProjectID) {
 			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
-		if err := e.ReleaseLease(ctx, input.ID, actorID); err != nil {
+		t, err := e.RejectTaskCompletion(ctx, input.ID, actorID, input.Body.Reason)
+		if err != nil {
 			return nil, handleError(err)
 		}
-		return &struct{}{}, nil
+		return &struct {
+			Body TaskResponse `json:"body"`
+		}{Body: taskResponse(t)}, nil
 	})
 
-	type treeInput struct {
-		ProjectID string `path:"project_id"`
-		Iteration string `query:"iteration_id"`
-		Status    string `query:"status"`
-	}
-	type treeNode struct {
-		Task     TaskResponse `json:"task"`
-		Children []treeNode   `json:"children"`
-	}
-	huma.Register(api, huma.Operation{
-		OperationID: "task-tree",
+	registerList(api, huma.Operation{
+		OperationID: "list-task-completions",
 		Method:      http.MethodGet,
-		Path:        "/projects/{project_id}/tasks/tree",
-		Summary:     "Task tree",
-		Errors:      []int{http.StatusBadRequest},
-	}, func(ctx context.Context, input *treeInput) (*struct {
-		Body []treeNode `json:"body"`
-	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		if err := requirePermission(ctx, e, projectID, "task.tree"); err != nil {
-			return nil, handleError(err)
-		}
-		tasks, err := e.Repo.ListTasks(ctx, repo.TaskFilters{ProjectID: projectID, Iteration: input.Iteration, Status: input.Status})
+		Path:        "/projects/{project_id}/tasks/{id}/completions",
+		Summary:     "List completions submitted for approval, oldest first",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) ([]TaskCompletionResponse, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.read"); err != nil {
+			return nil, handleError(err)
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		children := map[string][]domain.Task{}
-		var roots []domain.Task
-		for _, t := range tasks {
-			if t.ParentID != nil {
-				children[*t.ParentID] = append(children[*t.ParentID], t)
-			} else {
-				roots = append(roots, t)
-			}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
-		var build func(domain.Task) treeNode
-		build = func(t domain.Task) treeNode {
-			kid := []treeNode{}
-			for _, c := range children[t.ID] {
-				kid = append(kid, build(c))
-			}
-			return treeNode{Task: taskResponse(t), Children: kid}
+		completions, err := e.Store().ListTaskCompletions(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
 		}
-		res := []treeNode{}
-		for _, r := range roots {
-			res = append(res, build(r))
+		res := []TaskCompletionResponse{}
+		for _, c := range completions {
+			res = append(res, taskCompletionResponse(c))
 		}
-		return &struct {
-			Body []treeNode `json:"body"`
-		}{Body: res}, nil
+		return res, nil
 	})
 
 	huma.Register(api, huma.Operation{
-		OperationID: "task-validation-status",
-		Method:      http.MethodGet,
-		Path:        "/projects/{project_id}/tasks/{id}/validation",
-		Summary:     "Task validation status",
+		OperationID:   "add-task-link",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/tasks/{id}/links",
+		Summary:       "Link a commit, branch or pull request to a task",
+		Description:   "Webhooks of the project's VCS integrations then keep the link's CI and review status current.",
+		DefaultStatus: http.StatusCreated,
 		Errors: []int{
 			http.StatusBadRequest,
+			http.StatusForbidden,
 			http.StatusNotFound,
 		},
 	}, func(ctx context.Context, input *struct {
 		ProjectID string `path:"project_id"`
 		ID        string `path:"id"`
+		Body      AddTaskLinkRequest
 	}) (*struct {
-		Body ValidationStatusResponse `json:"body"`
+		Body TaskLinkResponse `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		if err := requirePermission(ctx, e, projectID, "task.validation.read"); err != nil {
-			return nil, handleError(err)
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
 		}
-		t, err := e.Repo.GetTask(ctx, input.ID)
+		t, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		if !projectMatches(input.ProjectID, t.ProjectID) {
 			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
-		status, err := taskValidationStatus(ctx, e.Repo, t)
+		if err := requirePermission(ctx, e, t.ProjectID, "task.update"); err != nil {
+			return nil, handleError(err)
+		}
+		l, err := e.AddTaskLink(ctx, t.ID, input.Body.Kind, input.Body.Target, actorID)
 		if err != nil {
 			return nil, handleError(err)
 		}
 		return &struct {
-			Body ValidationStatusResponse `json:"body"`
-		}{Body: status}, nil
+			Body TaskLinkResponse `json:"body"`
+		}{Body: taskLinkResponse(l)}, nil
 	})
-}
 
-func registerWorkOutcomesUpdates(api huma.API, e engine.Engine) {
-	registerWorkOutcomesAppend(api, e)
-	registerWorkOutcomesPut(api, e)
-	registerWorkOutcomesMerge(api, e)
-}
+	registerList(api, huma.Operation{
+		OperationID: "list-task-links",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/links",
+		Summary:     "List the commits, branches and pull requests linked to a task, with their CI and review status",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) ([]TaskLinkResponse, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.read"); err != nil {
+			return nil, handleError(err)
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		links, err := e.Store().ListTaskLinks(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		res := []TaskLinkResponse{}
+		for _, l := range links {
+			res = append(res, taskLinkResponse(l))
+		}
+		return res, nil
+	})
 
-func registerWorkOutcomesAppend(api huma.API, e engine.Engine) {
 	huma.Register(api, huma.Operation{
-		OperationID: "append-task-work-outcomes",
+		OperationID: "remove-task-link",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/tasks/{id}/links/{link_id}",
+		Summary:     "Remove a task link",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+		LinkID    int64  `path:"link_id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		if err := requirePermission(ctx, e, t.ProjectID, "task.update"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.RemoveTaskLink(ctx, t.ID, input.LinkID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	claimSchema := api.OpenAPI().Components.Schemas.Schema(reflect.TypeOf(ClaimLeaseResponse{}), true, "ClaimLeaseResponse")
+	huma.Register(api, huma.Operation{
+		OperationID: "claim-task",
 		Method:      http.MethodPost,
-		Path:        "/projects/{project_id}/tasks/{id}/work-outcomes/append",
-		Summary:     "Append work outcomes entry",
+		Path:        "/projects/{project_id}/tasks/{id}/claim",
+		Summary:     "Claim task lease",
+		Responses: map[string]*huma.Response{
+			"200": {
+				Description: "Lease held by the caller",
+				Content:     map[string]*huma.MediaType{"application/json": {Schema: claimSchema}},
+			},
+			"202": {
+				Description: "Lease held by another actor; the caller is queued for it",
+				Content:     map[string]*huma.MediaType{"application/json": {Schema: claimSchema}},
+			},
+		},
 		Errors: []int{
 			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
 			http.StatusConflict,
+			http.StatusUnprocessableEntity,
 			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string                    `path:"project_id"`
-		ID        string                    `path:"id"`
-		Body      WorkOutcomesAppendRequest `json:"body"`
+		ProjectID    string `path:"project_id"`
+		ID           string `path:"id"`
+		LeaseSeconds int    `query:"lease_seconds" doc:"Lease term; omitted or 0 uses config.leases.default_ttl"`
+		Wait         bool   `query:"wait" doc:"Queue for the lease instead of failing when another actor holds it (202)"`
+		Body         *ClaimLeaseRequest
 	}) (*struct {
-		Body WorkOutcomesUpdateResponse `json:"body"`
+		Status int
+		Body   ClaimLeaseResponse `json:"body"`
 	}, error) {
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
-		}
-		path := strings.TrimSpace(input.Body.Path)
-		if path == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "path is required", map[string]any{"field": "path"})
-		}
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		task, length, err := mutateWorkOutcomes(ctx, e, projectID, input.ID, actorID, func(workOutcomes map[string]any) (*int, error) {
-			existing, ok := workOutcomes[path]
-			if !ok || existing == nil {
-				workOutcomes[path] = []any{input.Body.Value}
-				l := 1
-				return &l, nil
-			}
-			list, ok := existing.([]any)
-			if !ok {
-				return nil, fmt.Errorf("invalid work_outcomes.%s: must be array", path)
-			}
-			list = append(list, input.Body.Value)
-			workOutcomes[path] = list
-			l := len(list)
-			return &l, nil
-		})
+		task, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		resp := WorkOutcomesUpdateResponse{
-			Path:         path,
-			WorkOutcomes: taskResponse(task).WorkOutcomes,
-			Length:       length,
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
-		return &struct {
-			Body WorkOutcomesUpdateResponse `json:"body"`
-		}{Body: resp}, nil
+		opts := engine.LeaseClaimOptions{TaskID: input.ID, ActorID: actorID, LeaseSeconds: input.LeaseSeconds, Wait: input.Wait}
+		if input.Body != nil && input.Body.Plan != nil {
+			opts.Plan = &domain.LeasePlan{Approach: input.Body.Plan.Approach, EstimatedSeconds: input.Body.Plan.EstimatedSeconds}
+		}
+		claim, err := e.ClaimLeaseWithOptions(ctx, opts)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := &struct {
+			Status int
+			Body   ClaimLeaseResponse `json:"body"`
+		}{Status: http.StatusOK, Body: ClaimLeaseResponse{LeaseResponse: leaseResponse(claim.Lease)}}
+		if claim.Waiter != nil {
+			w := leaseWaiterResponse(*claim.Waiter)
+			resp.Status = http.StatusAccepted
+			resp.Body.Waiting = &w
+		}
+		return resp, nil
 	})
-}
 
-func registerWorkOutcomesPut(api huma.API, e engine.Engine) {
 	huma.Register(api, huma.Operation{
-		OperationID: "put-task-work-outcomes",
-		Method:      http.MethodPost,
-		Path:        "/projects/{project_id}/tasks/{id}/work-outcomes/put",
-		Summary:     "Set a work outcomes value",
+		OperationID: "get-task-lease",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/lease",
+		Summary:     "Read the task lease and the plan declared with it",
+		Description: "The lease owner may read it; anyone else needs lease.plan.read.",
 		Errors: []int{
-			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusConflict,
 			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string                 `path:"project_id"`
-		ID        string                 `path:"id"`
-		Body      WorkOutcomesPutRequest `json:"body"`
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
 	}) (*struct {
-		Body WorkOutcomesUpdateResponse `json:"body"`
+		Body LeaseResponse `json:"body"`
 	}, error) {
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
-		}
-		path := strings.TrimSpace(input.Body.Path)
-		if path == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "path is required", map[string]any{"field": "path"})
-		}
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		task, _, err := mutateWorkOutcomes(ctx, e, projectID, input.ID, actorID, func(workOutcomes map[string]any) (*int, error) {
-			workOutcomes[path] = input.Body.Value
-			return nil, nil
-		})
+		task, err := e.Store().GetTask(ctx, input.ID)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		resp := WorkOutcomesUpdateResponse{
-			Path:         path,
-			WorkOutcomes: taskResponse(task).WorkOutcomes,
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		lease, err := e.TaskLease(ctx, input.ID, actorID)
+		if err != nil {
+			return nil, handleError(err)
 		}
 		return &struct {
-			Body WorkOutcomesUpdateResponse `json:"body"`
-		}{Body: resp}, nil
+			Body LeaseResponse `json:"body"`
+		}{Body: leaseResponse(lease)}, nil
 	})
-}
 
-func registerWorkOutcomesMerge(api huma.API, e engine.Engine) {
 	huma.Register(api, huma.Operation{
-		OperationID: "merge-task-work-outcomes",
-		Method:      http.MethodPost,
-		Path:        "/projects/{project_id}/tasks/{id}/work-outcomes/merge",
-		Summary:     "Merge a work outcomes object",
+		OperationID: "list-project-leases",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/leases",
+		Summary:     "List the active leases of the project",
+		Description: "Leases on unfinished tasks that have not expired, soonest expiry first, with the plans declared with them. Needs lease.plan.read.",
 		Errors: []int{
 			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusConflict,
 			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string                   `path:"project_id"`
-		ID        string                   `path:"id"`
-		Body      WorkOutcomesMergeRequest `json:"body"`
+		ProjectID      string `path:"project_id"`
+		Owner          string `query:"owner" doc:"Only leases held by this actor"`
+		ExpiringWithin string `query:"expiring_within" doc:"Only leases expiring within this Go duration, e.g. 15m" example:"15m"`
 	}) (*struct {
-		Body WorkOutcomesUpdateResponse `json:"body"`
+		Body ProjectLeasesResponse `json:"body"`
 	}, error) {
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
-		}
-		path := strings.TrimSpace(input.Body.Path)
-		if path == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "path is required", map[string]any{"field": "path"})
-		}
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		task, _, err := mutateWorkOutcomes(ctx, e, projectID, input.ID, actorID, func(workOutcomes map[string]any) (*int, error) {
-			if input.Body.Value == nil {
-				return nil, fmt.Errorf("invalid work_outcomes.%s: value must be object", path)
-			}
-			existing, ok := workOutcomes[path]
-			if !ok || existing == nil {
-				workOutcomes[path] = input.Body.Value
-				return nil, nil
-			}
-			obj, ok := existing.(map[string]any)
-			if !ok {
-				return nil, fmt.Errorf("invalid work_outcomes.%s: must be object", path)
-			}
-			for k, v := range input.Body.Value {
-				obj[k] = v
+		f := engine.LeaseFilter{OwnerID: input.Owner}
+		if input.ExpiringWithin != "" {
+			d, err := time.ParseDuration(input.ExpiringWithin)
+			if err != nil || d <= 0 {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid expiring_within: must be a positive duration such as 15m", nil)
 			}
-			workOutcomes[path] = obj
-			return nil, nil
-		})
+			f.ExpiringWithin = d
+		}
+		leases, err := e.ProjectLeases(ctx, input.ProjectID, actorID, f)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		resp := WorkOutcomesUpdateResponse{
-			Path:         path,
-			WorkOutcomes: taskResponse(task).WorkOutcomes,
+		resp := ProjectLeasesResponse{ProjectID: input.ProjectID, Leases: []LeaseResponse{}}
+		for _, l := range leases {
+			resp.Leases = append(resp.Leases, leaseResponse(l))
 		}
 		return &struct {
-			Body WorkOutcomesUpdateResponse `json:"body"`
+			Body ProjectLeasesResponse `json:"body"`
 		}{Body: resp}, nil
 	})
-}
 
-func registerIterations(api huma.API, e engine.Engine) {
 	huma.Register(api, huma.Operation{
-		OperationID:   "create-iteration",
-		Method:        http.MethodPost,
-		Path:          "/projects/{project_id}/iterations",
-		Summary:       "Create iteration",
-		DefaultStatus: http.StatusCreated,
+		OperationID: "list-lease-waiters",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/lease/waiters",
+		Summary:     "List actors queued for the task lease",
 		Errors: []int{
-			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusConflict,
-			http.StatusUnprocessableEntity,
 			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string                 `path:"project_id"`
-		Body      CreateIterationRequest `json:"body"`
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
 	}) (*struct {
-		Body IterationResponse `json:"body"`
+		Body LeaseWaitersResponse `json:"body"`
 	}, error) {
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
-		}
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		if input.Body.ID == "" || input.Body.Goal == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "id and goal are required", nil)
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
 		}
-		bodyProject := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		it := domain.Iteration{
-			ID:        input.Body.ID,
-			ProjectID: bodyProject,
-			Goal:      input.Body.Goal,
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
 		}
-		res, err := e.CreateIteration(ctx, it, actorID)
+		waiters, err := e.LeaseWaiters(ctx, input.ID, actorID)
 		if err != nil {
 			return nil, handleError(err)
 		}
+		resp := LeaseWaitersResponse{TaskID: input.ID, Waiters: []LeaseWaiterResponse{}}
+		for _, w := range waiters {
+			resp.Waiters = append(resp.Waiters, leaseWaiterResponse(w))
+		}
 		return &struct {
-			Body IterationResponse `json:"body"`
-		}{Body: iterationResponse(res)}, nil
+			Body LeaseWaitersResponse `json:"body"`
+		}{Body: resp}, nil
 	})
 
 	huma.Register(api, huma.Operation{
-		OperationID: "list-iterations",
-		Method:      http.MethodGet,
-		Path:        "/projects/{project_id}/iterations",
-		Summary:     "List iterations",
+		OperationID: "leave-lease-queue",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/tasks/{id}/lease/waiters/me",
+		Summary:     "Leave the queue for the task lease",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		if err := e.LeaveLeaseQueue(ctx, input.ID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "release-task",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/release",
+		Summary:     "Release task lease",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusUnprocessableEntity,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		if err := e.ReleaseLease(ctx, input.ID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "cancel-task",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/cancel",
+		Summary:     "Cancel a task, optionally with its descendants and dependents",
+		Description: "cascade=children also cancels open descendants; cascade=dependents additionally cancels open tasks depending on any canceled task. Open dependents left behind are reported as blocked. dry_run=true returns the same report without changing anything.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+		Cascade   string `query:"cascade" enum:"none,children,dependents" default:"none"`
+		DryRun    bool   `query:"dry_run"`
+		Force     bool   `query:"force"`
+	}) (*struct {
+		Body engine.TaskCancellation `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		res, err := e.CancelTask(ctx, engine.TaskCancelOptions{
+			ID:      input.ID,
+			ActorID: actorID,
+			Cascade: input.Cascade,
+			DryRun:  input.DryRun,
+			Force:   input.Force,
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.TaskCancellation `json:"body"`
+		}{Body: res}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "import-task-dependencies",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/dependencies",
+		Summary:     "Import dependency edges between tasks",
+		Description: "Adds a batch of {from, to} edges, where to depends on from, as produced by an external planner. The batch is validated as a whole: every task must exist in the project and the dependencies, existing ones included, must stay free of cycles. Either every edge is applied or none is. Edges already present are reported under existing.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                    `path:"project_id"`
+		Body      ImportDependenciesRequest `json:"body"`
+	}) (*struct {
+		Body ImportDependenciesResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		out, err := e.ImportDependencies(ctx, projectID, input.Body.Edges, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ImportDependenciesResponse `json:"body"`
+		}{Body: ImportDependenciesResponse{Added: out.Added, Existing: out.Existing}}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "move-task",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/move",
+		Summary:     "Reorder task among its siblings",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string          `path:"project_id"`
+		ID        string          `path:"id"`
+		Body      MoveTaskRequest `json:"body"`
+	}) (*struct {
+		Body TaskResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		opts := engine.TaskMoveOptions{ID: input.ID, ActorID: actorID}
+		if input.Body.Before != nil {
+			opts.BeforeID = *input.Body.Before
+		}
+		if input.Body.After != nil {
+			opts.AfterID = *input.Body.After
+		}
+		t, err := e.MoveTask(ctx, opts)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body TaskResponse `json:"body"`
+		}{Body: taskResponse(t)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "transfer-task-lease",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/lease/transfer",
+		Summary:     "Hand the task lease to another actor",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string               `path:"project_id"`
+		ID        string               `path:"id"`
+		Body      LeaseTransferRequest `json:"body"`
+	}) (*struct {
+		Body LeaseResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		lease, err := e.TransferLease(ctx, engine.LeaseTransferOptions{
+			TaskID:         input.ID,
+			ActorID:        actorID,
+			ToActorID:      input.Body.ToActorID,
+			LeaseSeconds:   input.Body.LeaseSeconds,
+			RequireConsent: input.Body.RequireConsent,
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body LeaseResponse `json:"body"`
+		}{Body: leaseResponse(lease)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "accept-task-lease-transfer",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/lease/transfer/accept",
+		Summary:     "Accept a lease transfer offered to the caller",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID    string `path:"project_id"`
+		ID           string `path:"id"`
+		LeaseSeconds int    `query:"lease_seconds"`
+	}) (*struct {
+		Body LeaseResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		lease, err := e.AcceptLeaseTransfer(ctx, input.ID, actorID, input.LeaseSeconds)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body LeaseResponse `json:"body"`
+		}{Body: leaseResponse(lease)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "decline-task-lease-transfer",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/lease/transfer/decline",
+		Summary:     "Decline or withdraw a pending lease transfer",
+		Errors: []int{
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body LeaseResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		task, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, task.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		lease, err := e.DeclineLeaseTransfer(ctx, input.ID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body LeaseResponse `json:"body"`
+		}{Body: leaseResponse(lease)}, nil
+	})
+
+	type treeInput struct {
+		ProjectID string `path:"project_id"`
+		Iteration string `query:"iteration_id"`
+		Status    string `query:"status"`
+	}
+	registerList(api, huma.Operation{
+		OperationID: "task-tree",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/tree",
+		Summary:     "Task tree",
+		Description: "Tasks nested under their parents, siblings by rank. The tree is read from one snapshot, so a concurrent reparenting shows whole or not at all.",
 		Errors:      []int{http.StatusBadRequest},
+	}, func(ctx context.Context, input *treeInput) ([]treeNode, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.tree"); err != nil {
+			return nil, handleError(err)
+		}
+		nodes, err := e.TaskTree(ctx, repo.TaskFilters{ProjectID: projectID, Iteration: input.Iteration, Status: input.Status})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return mapTree(nodes), nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "task-validation-status",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/validation",
+		Summary:     "Task validation status",
+		Description: "Required entries with what is present, missing or waived. sources tells where each entry came from: an explicit override of the task's requirements, a policy preset applied to the task, or the project default preset for its type, with the actor and time of the change.",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body ValidationStatusResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.validation.read"); err != nil {
+			return nil, handleError(err)
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		status, err := taskValidationStatus(ctx, e, t)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ValidationStatusResponse `json:"body"`
+		}{Body: status}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-task-evidence",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/evidence",
+		Summary:     "Export signed task evidence bundle",
+		Description: "Bundles the task, its policy snapshot, attestations with payloads, waivers and events, signed with the server's Ed25519 evidence key.",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusServiceUnavailable,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body engine.EvidenceBundle `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		for _, perm := range []string{"task.read", "attestation.list", "project.events.read"} {
+			if err := requirePermission(ctx, e, projectID, perm); err != nil {
+				return nil, handleError(err)
+			}
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		bundle, err := e.TaskEvidence(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.EvidenceBundle `json:"body"`
+		}{Body: bundle}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "export-task-subtree",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/subtree",
+		Summary:     "Export a task with its descendants",
+		Description: "Snapshots the task and every descendant, parents first, with the dependencies among them and their attestations and countersignatures, payloads inlined. POST the snapshot to .../tasks/subtree to recreate it.",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body engine.SubtreeSnapshot `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		for _, perm := range []string{"task.read", "attestation.list"} {
+			if err := requirePermission(ctx, e, projectID, perm); err != nil {
+				return nil, handleError(err)
+			}
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		snap, err := e.ExportSubtree(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.SubtreeSnapshot `json:"body"`
+		}{Body: snap}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "import-task-subtree",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/tasks/subtree",
+		Summary:       "Recreate an exported task subtree",
+		Description:   "Creates the snapshot's tasks under new IDs in one transaction, with the dependencies among them. Tasks start planned. Without template, they keep their assignee and work outcomes, and attestations are added again with their original time, as made by the caller, through the usual authority checks; their attestation.added events name the original actor. Returns the new ID of every task and attestation.",
+		DefaultStatus: http.StatusCreated,
+		MaxBodyBytes:  subtreeMaxBytes,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusRequestEntityTooLarge,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string               `path:"project_id"`
+		Body      ImportSubtreeRequest `json:"body"`
+	}) (*struct {
+		Body engine.SubtreeImport `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		res, err := e.ImportSubtree(ctx, projectID, input.Body.Snapshot, engine.SubtreeImportOptions{
+			ParentID: input.Body.ParentID,
+			Template: input.Body.Template,
+			IDPrefix: input.Body.IDPrefix,
+		}, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.SubtreeImport `json:"body"`
+		}{Body: res}, nil
+	})
+}
+
+func registerWaivers(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-task-waiver",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/tasks/{id}/waivers",
+		Summary:       "Waive a missing attestation kind",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string              `path:"project_id"`
+		ID        string              `path:"id"`
+		Body      CreateWaiverRequest `json:"body"`
+	}) (*struct {
+		Body WaiverResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		w, err := e.WaiveValidation(ctx, engine.WaiverCreateOptions{
+			TaskID:        input.ID,
+			Kind:          input.Body.Kind,
+			Justification: input.Body.Justification,
+			ExpiresAt:     input.Body.ExpiresAt,
+			ActorID:       actorID,
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body WaiverResponse `json:"body"`
+		}{Body: waiverResponse(w)}, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-task-waivers",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/waivers",
+		Summary:     "List task waivers, including expired ones",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) ([]WaiverResponse, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.validation.read"); err != nil {
+			return nil, handleError(err)
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		waivers, err := e.Store().ListWaivers(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		res := []WaiverResponse{}
+		for _, w := range waivers {
+			res = append(res, waiverResponse(w))
+		}
+		return res, nil
+	})
+}
+
+type treeNode struct {
+	Task     TaskResponse `json:"task"`
+	Children []treeNode   `json:"children"`
+}
+
+func mapTree(nodes []engine.TaskTreeNode) []treeNode {
+	res := make([]treeNode, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, treeNode{Task: taskResponse(n.Task), Children: mapTree(n.Children)})
+	}
+	return res
+}
+
+func registerCapabilities(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "set-actor-capabilities",
+		Method:      http.MethodPut,
+		Path:        "/projects/{project_id}/actors/{actor_id}/capabilities",
+		Summary:     "Register the capabilities an actor offers",
+		Description: "Replaces the actor's capability set. Actors may set their own with task.claim; setting another actor's requires capability.manage.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string              `path:"project_id"`
+		ActorID   string              `path:"actor_id"`
+		Body      CapabilitiesRequest `json:"body"`
+	}) (*struct {
+		Body CapabilitiesResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		caps, err := e.SetActorCapabilities(ctx, projectID, input.ActorID, input.Body.Capabilities, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body CapabilitiesResponse `json:"body"`
+		}{Body: CapabilitiesResponse{ProjectID: projectID, ActorID: input.ActorID, Capabilities: nonNilSlice(caps)}}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-actor-capabilities",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/actors/{actor_id}/capabilities",
+		Summary:     "List the capabilities an actor offers",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ActorID   string `path:"actor_id"`
+	}) (*struct {
+		Body CapabilitiesResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if input.ActorID != actorID {
+			if err := requirePermission(ctx, e, projectID, "rbac.read"); err != nil {
+				return nil, handleError(err)
+			}
+		}
+		caps, err := e.Store().ListActorCapabilities(ctx, projectID, input.ActorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body CapabilitiesResponse `json:"body"`
+		}{Body: CapabilitiesResponse{ProjectID: projectID, ActorID: input.ActorID, Capabilities: nonNilSlice(caps)}}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-ready-tasks",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/ready",
+		Summary:     "List tasks the caller can claim",
+		Description: "Planned tasks whose dependencies are done, without an active lease, unassigned or assigned to the caller, and whose required capabilities the caller offers. Oldest first, or earliest effective deadline first when the project sets scheduler.mode deadline.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Limit     int    `query:"limit" default:"50"`
+	}) (*struct {
+		Body paginatedTasks `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.list"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		tasks, err := e.ReadyTasks(ctx, projectID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if len(tasks) > limit {
+			tasks = tasks[:limit]
+		}
+		return &struct {
+			Body paginatedTasks `json:"body"`
+		}{Body: paginatedTasks{Items: mapTasks(tasks)}}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "claim-next-task",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/claim-next",
+		Summary:     "Claim the first task of the caller's ready queue",
+		Description: "Leases the first task list-ready-tasks would return, so under scheduler.mode deadline the task closest to its effective deadline goes first.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID    string `path:"project_id"`
+		LeaseSeconds int    `query:"lease_seconds" doc:"Lease term; omitted or 0 uses config.leases.default_ttl"`
+	}) (*struct {
+		Body ClaimNextResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.claim"); err != nil {
+			return nil, handleError(err)
+		}
+		t, lease, err := e.ClaimNext(ctx, projectID, actorID, input.LeaseSeconds)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ClaimNextResponse `json:"body"`
+		}{Body: ClaimNextResponse{Task: taskResponse(t), Lease: leaseResponse(lease)}}, nil
+	})
+}
+
+func registerAssignees(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "add-task-assignee",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/tasks/{id}/assignees",
+		Summary:       "Assign a driver or reviewer",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string            `path:"project_id"`
+		ID        string            `path:"id"`
+		Body      AssignTaskRequest `json:"body"`
+	}) (*struct {
+		Body TaskAssigneeResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		a, err := e.AssignTask(ctx, engine.TaskAssignOptions{
+			TaskID:      t.ID,
+			AssigneeID:  input.Body.ActorID,
+			Role:        input.Body.Role,
+			ActorID:     actorID,
+			HandoffNote: input.Body.HandoffNote,
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body TaskAssigneeResponse `json:"body"`
+		}{Body: taskAssigneeResponse(a)}, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-task-assignees",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/assignees",
+		Summary:     "List task drivers and reviewers",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) ([]TaskAssigneeResponse, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.read"); err != nil {
+			return nil, handleError(err)
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		assignees, err := e.Store().ListTaskAssignees(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		res := []TaskAssigneeResponse{}
+		for _, a := range assignees {
+			res = append(res, taskAssigneeResponse(a))
+		}
+		return res, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-task-handoffs",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/tasks/{id}/handoffs",
+		Summary:     "List assignee changes and handoff notes, oldest first",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) ([]TaskHandoffResponse, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "task.read"); err != nil {
+			return nil, handleError(err)
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		handoffs, err := e.Store().ListTaskHandoffs(ctx, t.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		res := []TaskHandoffResponse{}
+		for _, h := range handoffs {
+			res = append(res, taskHandoffResponse(h))
+		}
+		return res, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "remove-task-assignee",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/tasks/{id}/assignees/{actor_id}",
+		Summary:     "Remove a driver or reviewer",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+		ActorID   string `path:"actor_id"`
+		Role      string `query:"role" enum:"driver,reviewer" required:"true"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		if err := e.UnassignTask(ctx, engine.TaskAssignOptions{
+			TaskID:     t.ID,
+			AssigneeID: input.ActorID,
+			Role:       input.Role,
+			ActorID:    actorID,
+		}); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "claim-task-review-lease",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/review-lease/claim",
+		Summary:     "Claim the review lease as an assigned reviewer",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID    string `path:"project_id"`
+		ID           string `path:"id"`
+		LeaseSeconds int    `query:"lease_seconds" doc:"Lease term; omitted or 0 uses config.leases.default_ttl"`
+	}) (*struct {
+		Body LeaseResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		lease, err := e.ClaimReviewLease(ctx, t.ID, actorID, input.LeaseSeconds)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body LeaseResponse `json:"body"`
+		}{Body: leaseResponse(lease)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "release-task-review-lease",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/review-lease/release",
+		Summary:     "Release the review lease",
+		Errors: []int{
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		t, err := e.Store().GetTask(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, t.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "task not found in project", nil)
+		}
+		if err := e.ReleaseReviewLease(ctx, t.ID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+}
+
+func registerWorkOutcomesUpdates(api huma.API, e Engine) {
+	registerWorkOutcomesAppend(api, e)
+	registerWorkOutcomesPut(api, e)
+	registerWorkOutcomesMerge(api, e)
+}
+
+func registerWorkOutcomesAppend(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "append-task-work-outcomes",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/work-outcomes/append",
+		Summary:     "Append work outcomes entry",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                    `path:"project_id"`
+		ID        string                    `path:"id"`
+		Body      WorkOutcomesAppendRequest `json:"body"`
+	}) (*struct {
+		Body WorkOutcomesUpdateResponse `json:"body"`
+	}, error) {
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		path := strings.TrimSpace(input.Body.Path)
+		if path == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "path is required", map[string]any{"field": "path"})
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		task, length, err := mutateWorkOutcomes(ctx, e, projectID, input.ID, actorID, func(workOutcomes map[string]any) (*int, error) {
+			existing, ok := workOutcomes[path]
+			if !ok || existing == nil {
+				workOutcomes[path] = []any{input.Body.Value}
+				l := 1
+				return &l, nil
+			}
+			list, ok := existing.([]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid work_outcomes.%s: must be array", path)
+			}
+			list = append(list, input.Body.Value)
+			workOutcomes[path] = list
+			l := len(list)
+			return &l, nil
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := WorkOutcomesUpdateResponse{
+			Path:         path,
+			WorkOutcomes: taskResponse(task).WorkOutcomes,
+			Length:       length,
+		}
+		return &struct {
+			Body WorkOutcomesUpdateResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+}
+
+func registerWorkOutcomesPut(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "put-task-work-outcomes",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/work-outcomes/put",
+		Summary:     "Set a work outcomes value",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                 `path:"project_id"`
+		ID        string                 `path:"id"`
+		Body      WorkOutcomesPutRequest `json:"body"`
+	}) (*struct {
+		Body WorkOutcomesUpdateResponse `json:"body"`
+	}, error) {
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		path := strings.TrimSpace(input.Body.Path)
+		if path == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "path is required", map[string]any{"field": "path"})
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		task, _, err := mutateWorkOutcomes(ctx, e, projectID, input.ID, actorID, func(workOutcomes map[string]any) (*int, error) {
+			workOutcomes[path] = input.Body.Value
+			return nil, nil
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := WorkOutcomesUpdateResponse{
+			Path:         path,
+			WorkOutcomes: taskResponse(task).WorkOutcomes,
+		}
+		return &struct {
+			Body WorkOutcomesUpdateResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+}
+
+func registerWorkOutcomesMerge(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "merge-task-work-outcomes",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/tasks/{id}/work-outcomes/merge",
+		Summary:     "Merge a work outcomes object",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                   `path:"project_id"`
+		ID        string                   `path:"id"`
+		Body      WorkOutcomesMergeRequest `json:"body"`
+	}) (*struct {
+		Body WorkOutcomesUpdateResponse `json:"body"`
+	}, error) {
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		path := strings.TrimSpace(input.Body.Path)
+		if path == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "path is required", map[string]any{"field": "path"})
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		task, _, err := mutateWorkOutcomes(ctx, e, projectID, input.ID, actorID, func(workOutcomes map[string]any) (*int, error) {
+			if input.Body.Value == nil {
+				return nil, fmt.Errorf("invalid work_outcomes.%s: value must be object", path)
+			}
+			existing, ok := workOutcomes[path]
+			if !ok || existing == nil {
+				workOutcomes[path] = input.Body.Value
+				return nil, nil
+			}
+			obj, ok := existing.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid work_outcomes.%s: must be object", path)
+			}
+			for k, v := range input.Body.Value {
+				obj[k] = v
+			}
+			workOutcomes[path] = obj
+			return nil, nil
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := WorkOutcomesUpdateResponse{
+			Path:         path,
+			WorkOutcomes: taskResponse(task).WorkOutcomes,
+		}
+		return &struct {
+			Body WorkOutcomesUpdateResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+}
+
+func registerIterations(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-iteration",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/iterations",
+		Summary:       "Create iteration",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusUnprocessableEntity,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                 `path:"project_id"`
+		Body      CreateIterationRequest `json:"body"`
+	}) (*struct {
+		Body IterationResponse `json:"body"`
+	}, error) {
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if input.Body.ID == "" || input.Body.Goal == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "id and goal are required", nil)
+		}
+		bodyProject := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		it := domain.Iteration{
+			ID:        input.Body.ID,
+			ProjectID: bodyProject,
+			Goal:      input.Body.Goal,
+		}
+		for _, kr := range input.Body.KeyResults {
+			if kr.Target == nil || kr.Remove {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "key results need a target", map[string]any{"metric": kr.Metric})
+			}
+			it.KeyResults = append(it.KeyResults, domain.KeyResult{
+				Metric:     kr.Metric,
+				Target:     *kr.Target,
+				Current:    floatPtrValue(kr.Current),
+				Direction:  strPtrValue(kr.Direction),
+				SourceKind: strPtrValue(kr.SourceKind),
+			})
+		}
+		res, err := e.CreateIteration(ctx, it, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body IterationResponse `json:"body"`
+		}{Body: iterationResponse(res)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-iterations",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/iterations",
+		Summary:     "List iterations",
+		Errors:      []int{http.StatusBadRequest},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Limit     int    `query:"limit" default:"50"`
+		Cursor    string `query:"cursor"`
+	}) (*struct {
+		Body paginatedIterations `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "iteration.list"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		cursorCreated, cursorID, err := parseCompositeCursor(input.Cursor)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
+		}
+		items, err := e.Store().ListIterationsWithCursor(ctx, projectID, limit+1, cursorCreated, cursorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := paginatedIterations{Items: []IterationResponse{}}
+		if len(items) > limit {
+			resp.NextCursor = composeCursor(items[limit].CreatedAt, items[limit].ID)
+			items = items[:limit]
+		}
+		for _, it := range items {
+			resp.Items = append(resp.Items, iterationResponse(it))
+		}
+		return &struct {
+			Body paginatedIterations `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-iteration-status",
+		Method:      http.MethodPatch,
+		Path:        "/projects/{project_id}/iterations/{id}/status",
+		Summary:     "Update iteration status",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusUnprocessableEntity,
+			http.StatusConflict,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                    `path:"project_id"`
+		ID        string                    `path:"id"`
+		Body      SetIterationStatusRequest `json:"body"`
+		Force     bool                      `query:"force"`
+	}) (*struct {
+		Body IterationResponse `json:"body"`
+	}, error) {
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if input.Body.Status == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "status is required", nil)
+		}
+		it, err := e.SetIterationStatus(ctx, input.ID, input.Body.Status, actorID, input.Force)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, it.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "iteration not found in project", nil)
+		}
+		return &struct {
+			Body IterationResponse `json:"body"`
+		}{Body: iterationResponse(it)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "update-iteration-key-results",
+		Method:      http.MethodPatch,
+		Path:        "/projects/{project_id}/iterations/{id}/key-results",
+		Summary:     "Add, change or remove iteration key results",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                  `path:"project_id"`
+		ID        string                  `path:"id"`
+		Body      UpdateKeyResultsRequest `json:"body"`
+	}) (*struct {
+		Body IterationResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		it, err := e.Store().GetIteration(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, it.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "iteration not found in project", nil)
+		}
+		it, err = e.UpdateIterationKeyResults(ctx, input.ID, keyResultPatches(input.Body.KeyResults), actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body IterationResponse `json:"body"`
+		}{Body: iterationResponse(it)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "carry-over-iteration",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/iterations/{id}/carry-over",
+		Summary:     "Move unfinished tasks to another iteration or the backlog",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string           `path:"project_id"`
+		ID        string           `path:"id"`
+		Body      CarryOverRequest `json:"body"`
+	}) (*struct {
+		Body CarryOverResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		it, err := e.Store().GetIteration(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, it.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "iteration not found in project", nil)
+		}
+		res, err := e.CarryOverIteration(ctx, input.ID, input.Body.TargetIterationID, input.Body.FreezeOverride, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := CarryOverResponse{Source: iterationResponse(res.Source), TaskIDs: nonNilSlice(res.TaskIDs)}
+		if res.Target != nil {
+			target := iterationResponse(*res.Target)
+			resp.Target = &target
+		}
+		return &struct {
+			Body CarryOverResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+}
+
+func keyResultPatches(reqs []KeyResultRequest) []engine.KeyResultPatch {
+	out := make([]engine.KeyResultPatch, 0, len(reqs))
+	for _, r := range reqs {
+		out = append(out, engine.KeyResultPatch{
+			Metric:     r.Metric,
+			Target:     r.Target,
+			Current:    r.Current,
+			Direction:  r.Direction,
+			SourceKind: r.SourceKind,
+			Remove:     r.Remove,
+		})
+	}
+	return out
+}
+
+func registerDecisions(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-decision",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/decisions",
+		Summary:       "Create decision",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusConflict,
+			http.StatusUnprocessableEntity,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                `path:"project_id"`
+		Body      CreateDecisionRequest `json:"body"`
+	}) (*struct {
+		Body DecisionResponse `json:"body"`
+	}, error) {
+		bodyMap := rawBodyMap(ctx)
+		if len(bodyBytes(ctx)) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		}
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if input.Body.ID == "" || input.Body.Title == "" || input.Body.Decision == "" || input.Body.DeciderID == "" {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "id, title, decision, and decider_id are required", nil)
+		}
+		if isNullRaw(bodyMap["rationale"]) {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "rationale must be array", map[string]any{"field": "rationale", "reason": "must be array"})
+		}
+		if isNullRaw(bodyMap["alternatives"]) {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "alternatives must be array", map[string]any{"field": "alternatives", "reason": "must be array"})
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		d := domain.Decision{
+			ID:        input.Body.ID,
+			ProjectID: projectID,
+			Title:     input.Body.Title,
+			Decision:  input.Body.Decision,
+			DeciderID: input.Body.DeciderID,
+			Status:    input.Body.Status,
+		}
+		if input.Body.Context != nil {
+			if data, err := json.Marshal(input.Body.Context); err == nil {
+				d.ContextJSON = string(data)
+			} else {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid context", map[string]any{"error": err.Error()})
+			}
+		}
+		if len(input.Body.Rationale) > 0 {
+			d.RationaleJSON = toJSONArray(input.Body.Rationale)
+		}
+		if len(input.Body.Alternatives) > 0 {
+			d.AlternativesJSON = toJSONArray(input.Body.Alternatives)
+		}
+		res, err := e.CreateDecision(ctx, d, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body DecisionResponse `json:"body"`
+		}{Body: decisionResponse(res)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-decisions",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/decisions",
+		Summary:     "List decisions, newest first",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		DeciderID string `query:"decider_id"`
+		Status    string `query:"status" enum:"proposed,accepted,superseded,rejected"`
+		From      string `query:"from" doc:"Only decisions created at or after this RFC3339 timestamp"`
+		To        string `query:"to" doc:"Only decisions created before this RFC3339 timestamp"`
+		Q         string `query:"q" doc:"Case-insensitive text search over title, decision, context, rationale and alternatives"`
+		Limit     int    `query:"limit" default:"50"`
+		Cursor    string `query:"cursor"`
+	}) (*struct {
+		Body paginatedDecisions `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "decision.list"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		cursorCreated, cursorID, err := parseCompositeCursor(input.Cursor)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
+		}
+		f := repo.DecisionFilters{
+			ProjectID:       projectID,
+			DeciderID:       input.DeciderID,
+			Status:          input.Status,
+			Query:           input.Q,
+			Limit:           limit + 1,
+			CursorCreatedAt: cursorCreated,
+			CursorID:        cursorID,
+		}
+		if input.From != "" {
+			ts, err := time.Parse(time.RFC3339, input.From)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid from", map[string]any{"from": input.From})
+			}
+			f.CreatedFrom = ts.UTC().Format(time.RFC3339)
+		}
+		if input.To != "" {
+			ts, err := time.Parse(time.RFC3339, input.To)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid to", map[string]any{"to": input.To})
+			}
+			f.CreatedTo = ts.UTC().Format(time.RFC3339)
+		}
+		items, err := e.Store().ListDecisions(ctx, f)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := paginatedDecisions{Items: []DecisionResponse{}}
+		if len(items) > limit {
+			items = items[:limit]
+			// The cursor is exclusive, so it names the last decision returned.
+			resp.NextCursor = composeCursor(items[limit-1].CreatedAt, items[limit-1].ID)
+		}
+		for _, d := range items {
+			resp.Items = append(resp.Items, decisionResponse(d))
+		}
+		return &struct {
+			Body paginatedDecisions `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-decision",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/decisions/{id}",
+		Summary:     "Get decision",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body DecisionResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "decision.read"); err != nil {
+			return nil, handleError(err)
+		}
+		d, err := e.Store().GetDecision(ctx, input.ID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if !projectMatches(input.ProjectID, d.ProjectID) {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "decision not found in project", nil)
+		}
+		return &struct {
+			Body DecisionResponse `json:"body"`
+		}{Body: decisionResponse(d)}, nil
+	})
+}
+
+func registerViews(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-view",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/views",
+		Summary:       "Save a named task view",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string            `path:"project_id"`
+		Body      CreateViewRequest `json:"body"`
+	}) (*struct {
+		Body ViewResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		v, err := e.CreateView(ctx, domain.View{
+			ProjectID:   projectID,
+			Name:        input.Body.Name,
+			Description: input.Body.Description,
+			Filters:     domain.ViewFilters(input.Body.Filters),
+			Visibility:  input.Body.Visibility,
+			Roles:       input.Body.Roles,
+		}, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ViewResponse `json:"body"`
+		}{Body: viewResponse(v)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-views",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/views",
+		Summary:     "List views visible to the caller",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) (*struct {
+		Body struct {
+			Items []ViewResponse `json:"items"`
+		} `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "view.read"); err != nil {
+			return nil, handleError(err)
+		}
+		views, err := e.VisibleViews(ctx, projectID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := &struct {
+			Body struct {
+				Items []ViewResponse `json:"items"`
+			} `json:"body"`
+		}{}
+		resp.Body.Items = []ViewResponse{}
+		for _, v := range views {
+			resp.Body.Items = append(resp.Body.Items, viewResponse(v))
+		}
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-view",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/views/{id}",
+		Summary:     "Get view",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body ViewResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "view.read"); err != nil {
+			return nil, handleError(err)
+		}
+		v, err := e.GetVisibleView(ctx, projectID, input.ID, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ViewResponse `json:"body"`
+		}{Body: viewResponse(v)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-view",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/views/{id}",
+		Summary:     "Delete view",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if _, err := e.GetVisibleView(ctx, projectID, input.ID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.DeleteView(ctx, projectID, input.ID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-view-results",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/views/{id}/results",
+		Summary:     "Run a view and list matching tasks",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
 	}, func(ctx context.Context, input *struct {
 		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
 		Limit     int    `query:"limit" default:"50"`
 		Cursor    string `query:"cursor"`
+		Snapshot  bool   `query:"snapshot" doc:"Page through a point-in-time copy of the results; next_cursor then keeps serving that copy"`
 	}) (*struct {
-		Body paginatedIterations `json:"body"`
+		Body paginatedTasks `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		if err := requirePermission(ctx, e, projectID, "iteration.list"); err != nil {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "view.read"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := requirePermission(ctx, e, projectID, "task.list"); err != nil {
+			return nil, handleError(err)
+		}
+		v, err := e.GetVisibleView(ctx, projectID, input.ID, actorID)
+		if err != nil {
 			return nil, handleError(err)
 		}
-		limit := normalizeLimit(input.Limit)
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		filter := engine.ViewTaskFilters(v, actorID)
+		if input.Snapshot || isSnapshotCursor(input.Cursor) {
+			resp, err := listTasksSnapshot(ctx, e, "view:"+projectID+":"+v.ID, actorID, input.Cursor, limit, filter)
+			if err != nil {
+				return nil, err
+			}
+			return &struct {
+				Body paginatedTasks `json:"body"`
+			}{Body: resp}, nil
+		}
 		cursorCreated, cursorID, err := parseCompositeCursor(input.Cursor)
 		if err != nil {
 			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
 		}
-		items, err := e.Repo.ListIterationsWithCursor(ctx, projectID, limit+1, cursorCreated, cursorID)
+		filter.Limit = limit + 1
+		filter.CursorCreatedAt = cursorCreated
+		filter.CursorID = cursorID
+		tasks, err := e.Store().ListTasks(ctx, filter)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		resp := paginatedIterations{Items: []IterationResponse{}}
-		if len(items) > limit {
-			resp.NextCursor = composeCursor(items[limit].CreatedAt, items[limit].ID)
-			items = items[:limit]
-		}
-		for _, it := range items {
-			resp.Items = append(resp.Items, iterationResponse(it))
+		resp := paginatedTasks{Items: []TaskResponse{}}
+		if len(tasks) > limit {
+			resp.NextCursor = composeCursor(tasks[limit].CreatedAt, tasks[limit].ID)
+			tasks = tasks[:limit]
 		}
+		resp.Items = mapTasks(tasks)
 		return &struct {
-			Body paginatedIterations `json:"body"`
+			Body paginatedTasks `json:"body"`
 		}{Body: resp}, nil
 	})
+}
 
+func registerAdmin(api huma.API, e Engine, stats *db.QueryStats, slowQuery time.Duration) {
 	huma.Register(api, huma.Operation{
-		OperationID: "set-iteration-status",
-		Method:      http.MethodPatch,
-		Path:        "/projects/{project_id}/iterations/{id}/status",
-		Summary:     "Update iteration status",
+		OperationID: "vacuum-db",
+		Method:      http.MethodPost,
+		Path:        "/admin/db/vacuum",
+		Summary:     "Reclaim free pages in the workspace database",
+		Description: "Incremental mode (default) releases free pages without rewriting the file and needs auto_vacuum=incremental. Full mode rebuilds the file, blocking writers meanwhile, and switches it to incremental auto-vacuum.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		Mode  string `query:"mode" enum:"incremental,full" default:"incremental"`
+		Pages int    `query:"pages" minimum:"0" doc:"Maximum pages to release in incremental mode; 0 releases all"`
+	}) (*struct {
+		Body db.VacuumResult `json:"body"`
+	}, error) {
+		if err := requireGlobalPermission(ctx, e, "db.maintain"); err != nil {
+			return nil, handleError(err)
+		}
+		res, err := e.Vacuum(ctx, input.Mode == "full", input.Pages)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body db.VacuumResult `json:"body"`
+		}{Body: res}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "check-db-integrity",
+		Method:      http.MethodGet,
+		Path:        "/admin/db/integrity",
+		Summary:     "Check workspace database integrity",
+		Description: "Runs PRAGMA integrity_check (quick_check with quick=true) and PRAGMA foreign_key_check.",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		Quick bool `query:"quick"`
+	}) (*struct {
+		Body db.IntegrityResult `json:"body"`
+	}, error) {
+		if err := requireGlobalPermission(ctx, e, "db.maintain"); err != nil {
+			return nil, handleError(err)
+		}
+		res, err := e.CheckIntegrity(ctx, input.Quick)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body db.IntegrityResult `json:"body"`
+		}{Body: res}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "check-consistency",
+		Method:      http.MethodGet,
+		Path:        "/admin/consistency",
+		Summary:     "Find orphaned and dangling rows",
+		Description: "Reports tasks whose parent or iteration is missing, dependencies on missing tasks, attestations on missing entities, and leases, review leases and lease queues whose task is missing or finished. Each issue names the repair POST /admin/consistency/repair would apply.",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct{}) (*struct {
+		Body engine.ConsistencyReport `json:"body"`
+	}, error) {
+		if err := requireGlobalPermission(ctx, e, "db.maintain"); err != nil {
+			return nil, handleError(err)
+		}
+		res, err := e.CheckConsistency(ctx)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.ConsistencyReport `json:"body"`
+		}{Body: res}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "repair-consistency",
+		Method:      http.MethodPost,
+		Path:        "/admin/consistency/repair",
+		Summary:     "Repair orphaned and dangling rows",
+		Description: "Clears missing parents and iterations from tasks, and deletes dependencies on missing tasks, attestations on missing entities and dangling leases. Each repair is recorded as a consistency.repaired event. issues lists what is left afterwards.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		Body RepairConsistencyRequest `json:"body"`
+	}) (*struct {
+		Body engine.ConsistencyReport `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if err := requireGlobalPermission(ctx, e, "db.maintain"); err != nil {
+			return nil, handleError(err)
+		}
+		res, err := e.RepairConsistency(ctx, input.Body.Checks, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.ConsistencyReport `json:"body"`
+		}{Body: res}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-db-stats",
+		Method:      http.MethodGet,
+		Path:        "/admin/db/stats",
+		Summary:     "Report query counts and latency percentiles per statement",
+		Description: "Statements are grouped by their SQL text with whitespace collapsed and sorted by total time spent. Percentiles cover the latest 1024 executions of each statement. Counters start when the server starts.",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct{}) (*struct {
+		Body db.QueryStatsReport `json:"body"`
+	}, error) {
+		if err := requireGlobalPermission(ctx, e, "db.maintain"); err != nil {
+			return nil, handleError(err)
+		}
+		if stats == nil {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "query statistics are not collected by this server", nil)
+		}
+		return &struct {
+			Body db.QueryStatsReport `json:"body"`
+		}{Body: stats.Report(slowQuery)}, nil
+	})
+}
+
+func registerIntegrations(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "integration-webhook",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/integrations/{provider}/webhook",
+		Summary:     "Receive a provider webhook and record mapped attestations",
+		Description: "Authenticated by the provider secret (X-Gitlab-Token or Bitbucket X-Hub-Signature), not by API credentials: any live webhook secret rotated in for the provider, or the one in the integration's secret_env.",
 		Errors: []int{
 			http.StatusBadRequest,
+			http.StatusUnauthorized,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusUnprocessableEntity,
-			http.StatusConflict,
 			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string                    `path:"project_id"`
-		ID        string                    `path:"id"`
-		Body      SetIterationStatusRequest `json:"body"`
-		Force     bool                      `query:"force"`
+		ProjectID string `path:"project_id"`
+		Provider  string `path:"provider" enum:"gitlab,bitbucket"`
 	}) (*struct {
-		Body IterationResponse `json:"body"`
+		Body WebhookResponse `json:"body"`
 	}, error) {
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
+		adapter, ok := integrations.Lookup(input.Provider)
+		if !ok {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "unknown integration provider", map[string]any{"provider": input.Provider})
+		}
+		cfg, err := e.Store().GetProjectConfig(ctx, input.ProjectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		integ, ok := cfg.Integrations[input.Provider]
+		if !ok {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "integration not configured for project", map[string]any{"provider": input.Provider})
+		}
+		req, _ := ctx.Value(requestKey{}).(*http.Request)
+		if req == nil {
+			return nil, newAPIError(http.StatusInternalServerError, "internal_error", "request unavailable", nil)
+		}
+		body := bodyBytes(ctx)
+		secrets, err := webhookSecrets(ctx, e.Store(), input.ProjectID, input.Provider, integ.SecretEnv)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		if err := verifyWebhook(adapter, req.Header, body, secrets); err != nil {
+			return nil, newAPIError(http.StatusUnauthorized, "invalid_signature", err.Error(), map[string]any{"provider": input.Provider})
+		}
+		resp := WebhookResponse{Provider: input.Provider, TaskIDs: []string{}, Attestations: []AttestationResponse{}, Links: []TaskLinkResponse{}}
+		evt, err := adapter.Parse(req.Header, body)
+		if errors.Is(err, integrations.ErrUnsupportedEvent) {
+			resp.Ignored = true
+			resp.Reason = err.Error()
+			return &struct {
+				Body WebhookResponse `json:"body"`
+			}{Body: resp}, nil
+		}
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", err.Error(), nil)
+		}
+		resp.Event = evt.Key
+		links, err := e.SyncTaskLinks(ctx, input.ProjectID, evt, integ.ActorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		for _, l := range links {
+			resp.Links = append(resp.Links, taskLinkResponse(l))
+		}
+		kind := integ.Kinds[evt.Key]
+		if kind == "" {
+			resp.Ignored = len(links) == 0
+			resp.Reason = "no kind mapping for event"
+			return &struct {
+				Body WebhookResponse `json:"body"`
+			}{Body: resp}, nil
+		}
+		resp.Kind = kind
+		refs, err := integrations.TaskRefs(integ.TaskRefPattern, evt.Texts)
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "internal_error", err.Error(), nil)
+		}
+		for _, ref := range refs {
+			t, err := e.Store().GetTask(ctx, ref)
+			if errors.Is(err, repo.ErrNotFound) || (err == nil && t.ProjectID != input.ProjectID) {
+				continue
+			}
+			if err != nil {
+				return nil, handleError(err)
+			}
+			resp.TaskIDs = append(resp.TaskIDs, t.ID)
+		}
+		if len(resp.TaskIDs) == 0 {
+			resp.Ignored = len(links) == 0
+			resp.Reason = "no task reference found"
+			return &struct {
+				Body WebhookResponse `json:"body"`
+			}{Body: resp}, nil
+		}
+		details := map[string]any{"provider": evt.Provider, "event": evt.Key}
+		for k, v := range evt.Details {
+			details[k] = v
+		}
+		payload, err := json.Marshal(details)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		// One transaction for the whole delivery: a refused task leaves no attestation on the
+		// others, so the provider's retry does not record them twice.
+		atts := make([]domain.Attestation, 0, len(resp.TaskIDs))
+		for _, taskID := range resp.TaskIDs {
+			atts = append(atts, domain.Attestation{
+				EntityKind:  "task",
+				EntityID:    taskID,
+				Kind:        kind,
+				ActorID:     integ.ActorID,
+				PayloadJSON: string(payload),
+			})
+		}
+		out, err := e.AddAttestations(ctx, input.ProjectID, atts, integ.ActorID, true)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		for _, res := range out.Results {
+			if res.Err != nil {
+				return nil, handleError(res.Err)
+			}
+			resp.Attestations = append(resp.Attestations, attestationResponse(res.Attestation))
 		}
+		return &struct {
+			Body WebhookResponse `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "ingest-test-report",
+		Method:        http.MethodPost,
+		Path:          "/integrations/test-reports",
+		Summary:       "Record a JUnit XML or TAP test report as an attestation on a task",
+		Description:   "The report is summarized (counts and the first failures) into a tests.passed attestation, or tests.failed when any test failed or errored. The format is detected from the body unless given. The caller needs attestation.add on the task's project and authority for the kind.",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		TaskID  string `query:"task_id" required:"true" doc:"Task the report attests"`
+		Format  string `query:"format" enum:"junit,tap" doc:"Report format; detected from the body when omitted"`
+		RawBody []byte `contentType:"application/xml"`
+	}) (*struct {
+		Body TestReportResponse `json:"body"`
+	}, error) {
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		if input.Body.Status == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "status is required", nil)
+		if len(input.RawBody) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
 		}
-		it, err := e.SetIterationStatus(ctx, input.ID, input.Body.Status, actorID, input.Force)
+		t, err := e.Store().GetTask(ctx, input.TaskID)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		if !projectMatches(input.ProjectID, it.ProjectID) {
-			return nil, newAPIError(http.StatusNotFound, "not_found", "iteration not found in project", nil)
+		if err := requirePermission(ctx, e, t.ProjectID, "attestation.add"); err != nil {
+			return nil, handleError(err)
+		}
+		report, err := integrations.ParseTestReport(input.Format, input.RawBody)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", err.Error(), nil)
+		}
+		payload, err := json.Marshal(report.Details())
+		if err != nil {
+			return nil, handleError(err)
+		}
+		att, err := e.AddAttestation(ctx, domain.Attestation{
+			ProjectID:   t.ProjectID,
+			EntityKind:  "task",
+			EntityID:    t.ID,
+			Kind:        report.Kind(),
+			PayloadJSON: string(payload),
+		}, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		failures := report.Failures
+		if failures == nil {
+			failures = []integrations.TestFailure{}
 		}
 		return &struct {
-			Body IterationResponse `json:"body"`
-		}{Body: iterationResponse(it)}, nil
+			Body TestReportResponse `json:"body"`
+		}{Body: TestReportResponse{
+			TaskID:      t.ID,
+			Format:      report.Format,
+			Kind:        att.Kind,
+			Tests:       report.Tests,
+			Passed:      report.Passed,
+			Failed:      report.Failed,
+			Errors:      report.Errors,
+			Skipped:     report.Skipped,
+			Failures:    failures,
+			Attestation: attestationResponse(att),
+		}}, nil
 	})
-}
 
-func registerDecisions(api huma.API, e engine.Engine) {
 	huma.Register(api, huma.Operation{
-		OperationID:   "create-decision",
+		OperationID:   "ingest-sarif",
 		Method:        http.MethodPost,
-		Path:          "/projects/{project_id}/decisions",
-		Summary:       "Create decision",
+		Path:          "/integrations/sarif",
+		Summary:       "Record a SARIF security scan as a security.scan attestation on a task",
+		Description:   "Findings are counted by severity (critical, high, medium, low, note) from each result's security-severity score, or its level when unscored; suppressed results are counted apart. The payload carries the counts and the most severe findings, so a policy can require security.scan[payload.critical == 0]. The caller needs attestation.add on the task's project and authority for security.scan.",
 		DefaultStatus: http.StatusCreated,
 		Errors: []int{
 			http.StatusBadRequest,
 			http.StatusForbidden,
 			http.StatusNotFound,
-			http.StatusConflict,
-			http.StatusUnprocessableEntity,
 			http.StatusInternalServerError,
 		},
 	}, func(ctx context.Context, input *struct {
-		ProjectID string                `path:"project_id"`
-		Body      CreateDecisionRequest `json:"body"`
+		TaskID  string `query:"task_id" required:"true" doc:"Task the scan attests"`
+		RawBody []byte `contentType:"application/sarif+json"`
 	}) (*struct {
-		Body DecisionResponse `json:"body"`
+		Body SARIFResponse `json:"body"`
 	}, error) {
-		bodyMap := rawBodyMap(ctx)
-		if len(bodyBytes(ctx)) == 0 {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
-		}
 		actorID, authErr := actorIDFromContext(ctx)
 		if authErr != nil {
 			return nil, authErr
 		}
-		if input.Body.ID == "" || input.Body.Title == "" || input.Body.Decision == "" || input.Body.DeciderID == "" {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "id, title, decision, and decider_id are required", nil)
-		}
-		if isNullRaw(bodyMap["rationale"]) {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "rationale must be array", map[string]any{"field": "rationale", "reason": "must be array"})
-		}
-		if isNullRaw(bodyMap["alternatives"]) {
-			return nil, newAPIError(http.StatusBadRequest, "bad_request", "alternatives must be array", map[string]any{"field": "alternatives", "reason": "must be array"})
+		if len(input.RawBody) == 0 {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		d := domain.Decision{
-			ID:        input.Body.ID,
-			ProjectID: projectID,
-			Title:     input.Body.Title,
-			Decision:  input.Body.Decision,
-			DeciderID: input.Body.DeciderID,
+		t, err := e.Store().GetTask(ctx, input.TaskID)
+		if err != nil {
+			return nil, handleError(err)
 		}
-		if input.Body.Context != nil {
-			if data, err := json.Marshal(input.Body.Context); err == nil {
-				d.ContextJSON = string(data)
-			} else {
-				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid context", map[string]any{"error": err.Error()})
-			}
+		if err := requirePermission(ctx, e, t.ProjectID, "attestation.add"); err != nil {
+			return nil, handleError(err)
 		}
-		if len(input.Body.Rationale) > 0 {
-			d.RationaleJSON = toJSONArray(input.Body.Rationale)
+		scan, err := integrations.ParseSARIF(input.RawBody)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", err.Error(), nil)
 		}
-		if len(input.Body.Alternatives) > 0 {
-			d.AlternativesJSON = toJSONArray(input.Body.Alternatives)
+		payload, err := json.Marshal(scan.Details())
+		if err != nil {
+			return nil, handleError(err)
 		}
-		res, err := e.CreateDecision(ctx, d, actorID)
+		att, err := e.AddAttestation(ctx, domain.Attestation{
+			ProjectID:   t.ProjectID,
+			EntityKind:  "task",
+			EntityID:    t.ID,
+			Kind:        integrations.KindSecurityScan,
+			PayloadJSON: string(payload),
+		}, actorID)
 		if err != nil {
 			return nil, handleError(err)
 		}
+		bySeverity := map[string]int{}
+		for _, sev := range integrations.Severities {
+			bySeverity[sev] = scan.BySeverity[sev]
+		}
+		top := scan.Top
+		if top == nil {
+			top = []integrations.ScanFinding{}
+		}
 		return &struct {
-			Body DecisionResponse `json:"body"`
-		}{Body: decisionResponse(res)}, nil
+			Body SARIFResponse `json:"body"`
+		}{Body: SARIFResponse{
+			TaskID:      t.ID,
+			Kind:        att.Kind,
+			Tools:       scan.Tools,
+			Findings:    scan.Findings,
+			BySeverity:  bySeverity,
+			Suppressed:  scan.Suppressed,
+			Top:         top,
+			Attestation: attestationResponse(att),
+		}}, nil
 	})
 }
 
-func registerAttestations(api huma.API, e engine.Engine) {
+func registerAttestations(api huma.API, e Engine) {
 	huma.Register(api, huma.Operation{
 		OperationID:   "add-attestation",
 		Method:        http.MethodPost,
@@ -1496,7 +4157,8 @@ func registerAttestations(api huma.API, e engine.Engine) {
 		ProjectID string                   `path:"project_id"`
 		Body      CreateAttestationRequest `json:"body"`
 	}) (*struct {
-		Body AttestationResponse `json:"body"`
+		Warning string              `header:"Warning" doc:"Set when the attestation kind is deprecated"`
+		Body    AttestationResponse `json:"body"`
 	}, error) {
 		if len(bodyBytes(ctx)) == 0 {
 			return nil, newAPIError(http.StatusBadRequest, "bad_request", "body required", nil)
@@ -1508,7 +4170,7 @@ func registerAttestations(api huma.API, e engine.Engine) {
 		if input.Body.EntityKind == "" || input.Body.EntityID == "" || input.Body.Kind == "" {
 			return nil, newAPIError(http.StatusBadRequest, "bad_request", "entity_kind, entity_id and kind are required", nil)
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		payload := ""
 		if input.Body.Payload != nil {
 			b, err := json.Marshal(input.Body.Payload)
@@ -1523,6 +4185,7 @@ func registerAttestations(api huma.API, e engine.Engine) {
 			EntityKind:  input.Body.EntityKind,
 			EntityID:    input.Body.EntityID,
 			Kind:        input.Body.Kind,
+			ActorID:     strPtrValue(input.Body.ActorID),
 			PayloadJSON: payload,
 		}
 		if input.Body.TS != nil {
@@ -1532,9 +4195,83 @@ func registerAttestations(api huma.API, e engine.Engine) {
 		if err != nil {
 			return nil, handleError(err)
 		}
+		out := &struct {
+			Warning string              `header:"Warning" doc:"Set when the attestation kind is deprecated"`
+			Body    AttestationResponse `json:"body"`
+		}{Body: attestationResponse(res)}
+		if msg := e.DeprecatedKindWarning(res.Kind); msg != "" {
+			out.Warning = fmt.Sprintf("299 - %q", msg)
+		}
+		return out, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-attestations-bulk",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/attestations/bulk",
+		Summary:     "Add many attestations in one transaction with per-item results",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string                 `path:"project_id"`
+		Body      BulkAttestationRequest `json:"body"`
+	}) (*struct {
+		Body BulkAttestationResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		atts := make([]domain.Attestation, 0, len(input.Body.Items))
+		for i, item := range input.Body.Items {
+			att := domain.Attestation{
+				ID:         strPtrValue(item.ID),
+				EntityKind: item.EntityKind,
+				EntityID:   item.EntityID,
+				Kind:       item.Kind,
+				ActorID:    strPtrValue(item.ActorID),
+			}
+			if item.Payload != nil {
+				b, err := json.Marshal(item.Payload)
+				if err != nil {
+					return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid payload", map[string]any{"index": i, "error": err.Error()})
+				}
+				att.PayloadJSON = string(b)
+			}
+			if item.TS != nil {
+				att.TS = *item.TS
+			}
+			atts = append(atts, att)
+		}
+		out, err := e.AddAttestations(ctx, projectID, atts, actorID, input.Body.Atomic)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := BulkAttestationResponse{Committed: out.Committed, Created: out.Created, Failed: out.Failed, Items: []BulkAttestationItemResponse{}}
+		for _, r := range out.Results {
+			item := BulkAttestationItemResponse{Index: r.Index, Status: r.Status}
+			switch {
+			case r.Err != nil:
+				var ae *apiError
+				if errors.As(handleError(r.Err), &ae) {
+					item.Error = &ae.Body
+					item.HTTPStatus = ae.status
+				}
+			case r.Status == engine.BulkCreated:
+				att := attestationResponse(r.Attestation)
+				item.Attestation = &att
+				item.Warning = e.DeprecatedKindWarning(att.Kind)
+			}
+			resp.Items = append(resp.Items, item)
+		}
 		return &struct {
-			Body AttestationResponse `json:"body"`
-		}{Body: attestationResponse(res)}, nil
+			Body BulkAttestationResponse `json:"body"`
+		}{Body: resp}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -1545,7 +4282,7 @@ func registerAttestations(api huma.API, e engine.Engine) {
 		Errors:      []int{http.StatusBadRequest},
 	}, func(ctx context.Context, input *struct {
 		ProjectID  string `path:"project_id"`
-		EntityKind string `query:"entity_kind" enum:"project,iteration,task,decision"`
+		EntityKind string `query:"entity_kind" enum:"project,iteration,task,decision,attestation"`
 		EntityID   string `query:"entity_id"`
 		Kind       string `query:"kind"`
 		Limit      int    `query:"limit" default:"50"`
@@ -1553,11 +4290,14 @@ func registerAttestations(api huma.API, e engine.Engine) {
 	}) (*struct {
 		Body paginatedAttestations `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "attestation.list"); err != nil {
 			return nil, handleError(err)
 		}
-		limit := normalizeLimit(input.Limit)
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
 		cursorTS, cursorID, err := parseCompositeCursor(input.Cursor)
 		if err != nil {
 			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
@@ -1571,7 +4311,7 @@ func registerAttestations(api huma.API, e engine.Engine) {
 			CursorTS:   cursorTS,
 			CursorID:   cursorID,
 		}
-		items, err := e.Repo.ListAttestations(ctx, f)
+		items, err := e.Store().ListAttestations(ctx, f)
 		if err != nil {
 			return nil, handleError(err)
 		}
@@ -1587,30 +4327,395 @@ func registerAttestations(api huma.API, e engine.Engine) {
 			Body paginatedAttestations `json:"body"`
 		}{Body: resp}, nil
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-attestation-required-by",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/attestation-kinds/{kind}/required-by",
+		Summary:     "List open tasks and iterations still missing an attestation kind",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Kind      string `path:"kind"`
+	}) (*struct {
+		Body engine.AttestationBacklog `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "attestation.list"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := requirePermission(ctx, e, projectID, "task.list"); err != nil {
+			return nil, handleError(err)
+		}
+		backlog, err := e.AttestationRequiredBy(ctx, projectID, input.Kind)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.AttestationBacklog `json:"body"`
+		}{Body: backlog}, nil
+	})
+}
+
+func registerBlobs(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-blob",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/blobs/{digest}",
+		Summary:     "Fetch an offloaded payload by its sha256 content address",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+		Responses: map[string]*huma.Response{
+			"200": {
+				Description: "The offloaded payload as originally submitted",
+				Content: map[string]*huma.MediaType{
+					"application/json": {Schema: &huma.Schema{Description: "Any JSON document"}},
+				},
+			},
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Digest    string `path:"digest" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	}) (*struct {
+		ContentType string `header:"Content-Type"`
+		Body        []byte
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "attestation.list"); err != nil {
+			return nil, handleError(err)
+		}
+		if !blob.ValidDigest(input.Digest) {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid digest: expected sha256:<64 hex>", map[string]any{"digest": input.Digest})
+		}
+		// Blobs are shared by content, so only serve digests this project cites.
+		referenced, err := e.Store().BlobReferenced(ctx, projectID, input.Digest)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		blobs := e.BlobStore()
+		if !referenced || blobs == nil {
+			return nil, newAPIError(http.StatusNotFound, "not_found", "blob not found in project", nil)
+		}
+		data, err := blobs.Get(ctx, input.Digest)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			ContentType string `header:"Content-Type"`
+			Body        []byte
+		}{ContentType: "application/json", Body: data}, nil
+	})
+}
+
+func registerArtifacts(api huma.API, e Engine) {
+	maxBytes := int64(config.DefaultArtifactMaxBytes)
+	if e.DefaultConfig() != nil {
+		maxBytes = int64(e.DefaultConfig().Payloads.ArtifactMax())
+	}
+	huma.Register(api, huma.Operation{
+		OperationID:   "upload-artifact",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/artifacts",
+		Summary:       "Upload an evidence file; the body is the raw file content",
+		DefaultStatus: http.StatusCreated,
+		MaxBodyBytes:  maxBytes,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+			http.StatusRequestEntityTooLarge,
+			http.StatusInternalServerError,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID   string `path:"project_id"`
+		Name        string `query:"name" required:"true" doc:"File name shown to readers, e.g. coverage.html"`
+		ContentType string `header:"Content-Type"`
+		RawBody     []byte `contentType:"application/octet-stream"`
+	}) (*struct {
+		Body ArtifactResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		a, err := e.UploadArtifact(ctx, engine.ArtifactUploadOptions{
+			ProjectID: projectID,
+			Name:      input.Name,
+			MediaType: input.ContentType,
+			Data:      input.RawBody,
+			ActorID:   actorID,
+		})
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body ArtifactResponse `json:"body"`
+		}{Body: artifactResponse(a)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-artifacts",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/artifacts",
+		Summary:     "List artifacts, newest first",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Limit     int    `query:"limit" default:"50"`
+		Cursor    string `query:"cursor"`
+	}) (*struct {
+		Body paginatedArtifacts `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "artifact.read"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		cursorCreated, cursorID, err := parseCompositeCursor(input.Cursor)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
+		}
+		items, err := e.Store().ListArtifacts(ctx, projectID, limit+1, cursorCreated, cursorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := paginatedArtifacts{Items: []ArtifactResponse{}}
+		if len(items) > limit {
+			items = items[:limit]
+			resp.NextCursor = composeCursor(items[limit-1].CreatedAt, items[limit-1].ID)
+		}
+		for _, a := range items {
+			resp.Items = append(resp.Items, artifactResponse(a))
+		}
+		return &struct {
+			Body paginatedArtifacts `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-artifact",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/artifacts/{id}",
+		Summary:     "Get artifact metadata",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		Body ArtifactResponse `json:"body"`
+	}, error) {
+		a, err := projectArtifact(ctx, e, input.ProjectID, input.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &struct {
+			Body ArtifactResponse `json:"body"`
+		}{Body: artifactResponse(a)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-artifact-content",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/artifacts/{id}/content",
+		Summary:     "Download artifact content with its stored media type",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ID        string `path:"id"`
+	}) (*struct {
+		ContentType        string `header:"Content-Type"`
+		ContentDisposition string `header:"Content-Disposition"`
+		Body               []byte
+	}, error) {
+		a, err := projectArtifact(ctx, e, input.ProjectID, input.ID)
+		if err != nil {
+			return nil, err
+		}
+		data, err := e.ArtifactContent(ctx, a)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			ContentType        string `header:"Content-Type"`
+			ContentDisposition string `header:"Content-Disposition"`
+			Body               []byte
+		}{
+			ContentType:        a.MediaType,
+			ContentDisposition: mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}),
+			Body:               data,
+		}, nil
+	})
+}
+
+// projectArtifact loads an artifact readable by the caller in the requested project.
+func projectArtifact(ctx context.Context, e Engine, pathProjectID, id string) (domain.Artifact, error) {
+	projectID := projectFromPathOrHeader(ctx, pathProjectID, e.DefaultConfig().Project.ID)
+	if err := requirePermission(ctx, e, projectID, "artifact.read"); err != nil {
+		return domain.Artifact{}, handleError(err)
+	}
+	a, err := e.Store().GetArtifact(ctx, id)
+	if err != nil {
+		return a, handleError(err)
+	}
+	if a.ProjectID != projectID {
+		return a, newAPIError(http.StatusNotFound, "not_found", "artifact not found in project", nil)
+	}
+	return a, nil
 }
 
-func registerEvents(api huma.API, e engine.Engine) {
+func registerEvents(api huma.API, e Engine) {
 	huma.Register(api, huma.Operation{
 		OperationID: "list-events",
 		Method:      http.MethodGet,
 		Path:        "/projects/{project_id}/events",
 		Summary:     "List recent events",
+		Description: "Lists events newest first, paged with cursor. With since_id, lists the events recorded after that id instead, oldest first; pass the last id returned as the next since_id. Adding wait (a Go duration, at most 60s) long-polls: the request is held until a matching event arrives or the wait passes, then answers with what it found, possibly nothing. Without since_id, a wait only returns events recorded after the request started.",
 		Errors:      []int{http.StatusBadRequest},
 	}, func(ctx context.Context, input *struct {
 		ProjectID  string `path:"project_id"`
 		Type       string `query:"type"`
-		EntityKind string `query:"entity_kind" enum:"project,iteration,task,decision,rbac"`
+		EntityKind string `query:"entity_kind" enum:"project,iteration,task,decision,attestation,rbac"`
 		EntityID   string `query:"entity_id"`
+		RequestID  string `query:"request_id" doc:"Only events recorded by the API request with this X-Request-Id"`
 		Limit      int    `query:"limit" default:"50"`
 		Cursor     string `query:"cursor"`
+		SinceID    string `query:"since_id" doc:"Only events recorded after this event id, oldest first"`
+		Wait       string `query:"wait" doc:"Hold the request until a matching event arrives, at most this long (at most 60s)" example:"30s"`
 	}) (*struct {
 		Body paginatedEvents `json:"body"`
 	}, error) {
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.events.read"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		var wait time.Duration
+		if input.Wait != "" {
+			if wait, err = time.ParseDuration(input.Wait); err != nil || wait < 0 || wait > maxEventWait {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid wait: must be a duration of at most "+maxEventWait.String(), map[string]any{"wait": input.Wait})
+			}
+		}
+		if input.SinceID != "" || wait > 0 {
+			if input.Cursor != "" {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor: not allowed with since_id or wait", map[string]any{"cursor": input.Cursor})
+			}
+			var sinceID int64
+			if input.SinceID != "" {
+				if sinceID, err = strconv.ParseInt(input.SinceID, 10, 64); err != nil || sinceID < 0 {
+					return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid since_id", map[string]any{"since_id": input.SinceID})
+				}
+			} else if sinceID, err = e.Store().MaxEventID(ctx, projectID); err != nil {
+				return nil, handleError(err)
+			}
+			items, err := waitForEvents(ctx, wait, func(ctx context.Context) ([]domain.Event, error) {
+				return e.Store().EventsSince(ctx, limit, sinceID, projectID, input.Type, input.EntityKind, input.EntityID, input.RequestID)
+			})
+			if err != nil {
+				return nil, handleError(err)
+			}
+			resp := paginatedEvents{Items: []EventResponse{}}
+			for _, evt := range items {
+				resp.Items = append(resp.Items, eventResponse(evt))
+			}
+			return &struct {
+				Body paginatedEvents `json:"body"`
+			}{Body: resp}, nil
+		}
+		var cursorID int64
+		if input.Cursor != "" {
+			parsed, err := strconv.ParseInt(input.Cursor, 10, 64)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": input.Cursor})
+			}
+			cursorID = parsed
+		}
+		items, err := e.Store().LatestEventsFrom(ctx, limit+1, cursorID, projectID, input.Type, input.EntityKind, input.EntityID, input.RequestID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := paginatedEvents{Items: []EventResponse{}}
+		if len(items) > limit {
+			resp.NextCursor = fmt.Sprintf("%d", items[limit].ID)
+			items = items[:limit]
+		}
+		for _, evt := range items {
+			resp.Items = append(resp.Items, eventResponse(evt))
+		}
+		return &struct {
+			Body paginatedEvents `json:"body"`
+		}{Body: resp}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "aggregate-events",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/events/aggregate",
+		Summary:     "Count events per time bucket and type",
+		Description: "Returns one entry per hour or day of the period, including empty ones, with event counts per type, for plotting activity without paging through raw events.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string   `path:"project_id"`
+		Bucket    string   `query:"bucket" enum:"hour,day" default:"hour"`
+		Type      []string `query:"type,explode" doc:"Only count these event types (repeatable)"`
+		From      string   `query:"from" doc:"Period start (RFC3339 or YYYY-MM-DD), defaults to 30 days before to"`
+		To        string   `query:"to" doc:"Period end, exclusive (RFC3339), or last day included (YYYY-MM-DD); defaults to today (UTC)"`
+	}) (*struct {
+		Body engine.EventAggregate `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.events.read"); err != nil {
+			return nil, handleError(err)
+		}
+		from, to, err := engine.ParsePeriod(input.From, input.To, time.Now())
+		if err != nil {
+			return nil, handleError(err)
+		}
+		agg, err := e.AggregateEvents(ctx, projectID, input.Bucket, input.Type, from, to)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.EventAggregate `json:"body"`
+		}{Body: agg}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "actor-activity",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/actors/{actor_id}/activity",
+		Summary:     "Actor activity feed",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		ActorID   string `path:"actor_id"`
+		Since     string `query:"since" doc:"Only include events at or after this RFC3339 timestamp"`
+		Limit     int    `query:"limit" default:"50"`
+		Cursor    string `query:"cursor"`
+	}) (*struct {
+		Body ActorActivityResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := requirePermission(ctx, e, projectID, "project.events.read"); err != nil {
 			return nil, handleError(err)
 		}
-		limit := normalizeLimit(input.Limit)
+		since := ""
+		if input.Since != "" {
+			ts, err := time.Parse(time.RFC3339, input.Since)
+			if err != nil {
+				return nil, newAPIError(http.StatusBadRequest, "bad_request", "invalid since", map[string]any{"since": input.Since})
+			}
+			since = ts.UTC().Format(time.RFC3339)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
 		var cursorID int64
 		if input.Cursor != "" {
 			parsed, err := strconv.ParseInt(input.Cursor, 10, 64)
@@ -1619,11 +4724,26 @@ func registerEvents(api huma.API, e engine.Engine) {
 			}
 			cursorID = parsed
 		}
-		items, err := e.Repo.LatestEventsFrom(ctx, limit+1, cursorID, projectID, input.Type, input.EntityKind, input.EntityID)
+		counts, err := e.Store().CountActorEventsByType(ctx, projectID, input.ActorID, since)
 		if err != nil {
 			return nil, handleError(err)
 		}
-		resp := paginatedEvents{Items: []EventResponse{}}
+		completed, err := e.Store().CountActorCompletions(ctx, projectID, input.ActorID, since)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		items, err := e.Store().ActorEventsFrom(ctx, limit+1, cursorID, projectID, input.ActorID, since)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := ActorActivityResponse{
+			ActorID:   input.ActorID,
+			ProjectID: projectID,
+			Since:     since,
+			Summary:   activitySummary(counts, completed),
+			Counts:    counts,
+			Items:     []EventResponse{},
+		}
 		if len(items) > limit {
 			resp.NextCursor = fmt.Sprintf("%d", items[limit].ID)
 			items = items[:limit]
@@ -1632,12 +4752,37 @@ func registerEvents(api huma.API, e engine.Engine) {
 			resp.Items = append(resp.Items, eventResponse(evt))
 		}
 		return &struct {
-			Body paginatedEvents `json:"body"`
+			Body ActorActivityResponse `json:"body"`
 		}{Body: resp}, nil
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "verify-events",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/events/verify",
+		Summary:     "Verify the event hash chain",
+		Description: "Walks the project's events oldest first, checking that each prev_hash matches the previous this_hash and that each this_hash matches the event content. Events recorded before chaining was introduced are counted as unchained.",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) (*struct {
+		Body EventChainResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "project.events.read"); err != nil {
+			return nil, handleError(err)
+		}
+		report, err := e.VerifyEventChain(ctx, projectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body EventChainResponse `json:"body"`
+		}{Body: EventChainResponse(report)}, nil
+	})
 }
 
-func registerRBAC(api huma.API, e engine.Engine) {
+func registerRBAC(api huma.API, e Engine) {
 	huma.Register(api, huma.Operation{
 		OperationID: "whoami",
 		Method:      http.MethodGet,
@@ -1656,7 +4801,7 @@ func registerRBAC(api huma.API, e engine.Engine) {
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		who, err := e.WhoAmI(ctx, projectID, principal.ActorID)
 		if err != nil {
 			return nil, handleError(err)
@@ -1671,6 +4816,44 @@ func registerRBAC(api huma.API, e engine.Engine) {
 		}}, nil
 	})
 
+	huma.Register(api, huma.Operation{
+		OperationID: "list-members",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/rbac/members",
+		Summary:     "List actors with their active role grants and effective permissions",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		Limit     int    `query:"limit" default:"50"`
+		Cursor    string `query:"cursor" doc:"Actor id of the last member on the previous page"`
+	}) (*struct {
+		Body paginatedMembers `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.read"); err != nil {
+			return nil, handleError(err)
+		}
+		limit, err := normalizeLimit(input.Limit)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		members, err := e.Store().ListMembers(ctx, projectID, time.Now().UTC().Format(time.RFC3339), limit+1, input.Cursor)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		resp := paginatedMembers{Items: []MemberResponse{}}
+		if len(members) > limit {
+			members = members[:limit]
+			resp.NextCursor = members[limit-1].ActorID
+		}
+		for _, m := range members {
+			resp.Items = append(resp.Items, memberResponse(m))
+		}
+		return &struct {
+			Body paginatedMembers `json:"body"`
+		}{Body: resp}, nil
+	})
+
 	huma.Register(api, huma.Operation{
 		OperationID: "grant-role",
 		Method:      http.MethodPost,
@@ -1692,8 +4875,8 @@ func registerRBAC(api huma.API, e engine.Engine) {
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
-		if err := e.GrantRole(ctx, projectID, actorID, input.Body.ActorID, input.Body.RoleID); err != nil {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := e.GrantRoleUntil(ctx, projectID, actorID, input.Body.ActorID, input.Body.RoleID, input.Body.ExpiresAt); err != nil {
 			return nil, handleError(err)
 		}
 		return &struct{}{}, nil
@@ -1720,7 +4903,7 @@ func registerRBAC(api huma.API, e engine.Engine) {
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := e.RevokeRole(ctx, projectID, actorID, input.Body.ActorID, input.Body.RoleID); err != nil {
 			return nil, handleError(err)
 		}
@@ -1748,7 +4931,7 @@ func registerRBAC(api huma.API, e engine.Engine) {
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := e.AllowAttestationRole(ctx, projectID, actorID, input.Body.Kind, input.Body.RoleID); err != nil {
 			return nil, handleError(err)
 		}
@@ -1776,20 +4959,300 @@ func registerRBAC(api huma.API, e engine.Engine) {
 		if authErr != nil {
 			return nil, authErr
 		}
-		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.Config.Project.ID)
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
 		if err := e.DenyAttestationRole(ctx, projectID, actorID, input.Body.Kind, input.Body.RoleID); err != nil {
 			return nil, handleError(err)
 		}
 		return &struct{}{}, nil
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-attestation-authorities",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/rbac/attestation-authorities",
+		Summary:     "Review who may record each attestation kind",
+		Description: "Lists every attestation kind to role authority with the actors holding the role now and the events that granted or took it away, plus the attestations whose actor lacks authority for their kind under the current grants. That list is expected to be empty; entries point at authority narrowed after the fact or attestations written around the API. Requires rbac.read.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) (*struct {
+		Body AttestationAuthorityReportResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.read"); err != nil {
+			return nil, handleError(err)
+		}
+		report, err := e.AttestationAuthorityReport(ctx, projectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body AttestationAuthorityReportResponse `json:"body"`
+		}{Body: attestationAuthorityReportResponse(report)}, nil
+	})
+
+	registerTeams(api, e)
+}
+
+// registerTeams exposes teams: groups of actors whose role grants apply to every member.
+func registerTeams(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-team",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/teams",
+		Summary:       "Create team",
+		DefaultStatus: http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string            `path:"project_id"`
+		Body      CreateTeamRequest `json:"body"`
+	}) (*struct {
+		Body TeamResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		team, err := e.CreateTeam(ctx, projectID, input.Body.ID, input.Body.Description, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body TeamResponse `json:"body"`
+		}{Body: teamResponse(team)}, nil
+	})
+
+	registerList(api, huma.Operation{
+		OperationID: "list-teams",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/teams",
+		Summary:     "List teams with their members and role grants",
+		Errors:      []int{http.StatusForbidden},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+	}) ([]TeamResponse, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.read"); err != nil {
+			return nil, handleError(err)
+		}
+		teams, err := e.Store().ListTeams(ctx, projectID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		res := []TeamResponse{}
+		for _, t := range teams {
+			res = append(res, teamResponse(t))
+		}
+		return res, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-team",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/teams/{team_id}",
+		Summary:     "Get team",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		TeamID    string `path:"team_id"`
+	}) (*struct {
+		Body TeamResponse `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.read"); err != nil {
+			return nil, handleError(err)
+		}
+		team, err := e.Store().GetTeam(ctx, projectID, input.TeamID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body TeamResponse `json:"body"`
+		}{Body: teamResponse(team)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "update-team",
+		Method:      http.MethodPatch,
+		Path:        "/projects/{project_id}/teams/{team_id}",
+		Summary:     "Update team description",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string            `path:"project_id"`
+		TeamID    string            `path:"team_id"`
+		Body      UpdateTeamRequest `json:"body"`
+	}) (*struct {
+		Body TeamResponse `json:"body"`
+	}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		team, err := e.UpdateTeam(ctx, projectID, input.TeamID, input.Body.Description, actorID)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body TeamResponse `json:"body"`
+		}{Body: teamResponse(team)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-team",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/teams/{team_id}",
+		Summary:     "Delete team; its members lose the roles held through it",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		TeamID    string `path:"team_id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.DeleteTeam(ctx, projectID, input.TeamID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-team-member",
+		Method:      http.MethodPut,
+		Path:        "/projects/{project_id}/teams/{team_id}/members/{actor_id}",
+		Summary:     "Add an actor to a team",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		TeamID    string `path:"team_id"`
+		MemberID  string `path:"actor_id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.AddTeamMember(ctx, projectID, input.TeamID, input.MemberID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "remove-team-member",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/teams/{team_id}/members/{actor_id}",
+		Summary:     "Remove an actor from a team",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		TeamID    string `path:"team_id"`
+		MemberID  string `path:"actor_id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.RemoveTeamMember(ctx, projectID, input.TeamID, input.MemberID, actorID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "grant-team-role",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/teams/{team_id}/roles",
+		Summary:     "Grant a role to every member of a team",
+		Errors: []int{
+			http.StatusBadRequest,
+			http.StatusForbidden,
+			http.StatusNotFound,
+		},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string          `path:"project_id"`
+		TeamID    string          `path:"team_id"`
+		Body      TeamRoleRequest `json:"body"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.GrantTeamRoleUntil(ctx, projectID, actorID, input.TeamID, input.Body.RoleID, input.Body.ExpiresAt); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "revoke-team-role",
+		Method:      http.MethodDelete,
+		Path:        "/projects/{project_id}/teams/{team_id}/roles/{role_id}",
+		Summary:     "Revoke a role from a team",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		TeamID    string `path:"team_id"`
+		RoleID    string `path:"role_id"`
+	}) (*struct{}, error) {
+		actorID, authErr := actorIDFromContext(ctx)
+		if authErr != nil {
+			return nil, authErr
+		}
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		if err := requirePermission(ctx, e, projectID, "rbac.manage"); err != nil {
+			return nil, handleError(err)
+		}
+		if err := e.RevokeTeamRole(ctx, projectID, actorID, input.TeamID, input.RoleID); err != nil {
+			return nil, handleError(err)
+		}
+		return &struct{}{}, nil
+	})
 }
 
-func registerMe(api huma.API, e engine.Engine) {
+func registerMe(api huma.API, e Engine) {
 	huma.Register(api, huma.Operation{
 		OperationID: "me",
 		Method:      http.MethodGet,
 		Path:        "/me",
 		Summary:     "Current principal",
+		Description: "Roles and permissions are those of the credentials, or else of the default project. projects lists every project of the credentials' org where the actor holds a role, directly or through a team, so an agent working across projects can find its scope in one call.",
 		Errors: []int{
 			http.StatusUnauthorized,
 		},
@@ -1802,14 +5265,22 @@ func registerMe(api huma.API, e engine.Engine) {
 		}
 		roles := principal.Roles
 		perms := principal.Permissions
-		if len(perms) == 0 && e.Config != nil {
-			if who, err := e.WhoAmI(ctx, e.Config.Project.ID, principal.ActorID); err == nil {
+		if len(perms) == 0 && e.DefaultConfig() != nil {
+			if who, err := e.WhoAmI(ctx, e.DefaultConfig().Project.ID, principal.ActorID); err == nil {
 				if len(roles) == 0 {
 					roles = who.Roles
 				}
 				perms = who.Permissions
 			}
 		}
+		memberships, err := e.Store().ListMemberships(ctx, principal.ActorID, principal.OrgID, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return nil, handleError(err)
+		}
+		projects := make([]MembershipResponse, 0, len(memberships))
+		for _, m := range memberships {
+			projects = append(projects, membershipResponse(m))
+		}
 		return &struct {
 			Body WhoAmIResponse `json:"body"`
 		}{Body: WhoAmIResponse{
@@ -1817,11 +5288,12 @@ func registerMe(api huma.API, e engine.Engine) {
 			OrgID:       principal.OrgID,
 			Roles:       nonNilSlice(roles),
 			Permissions: nonNilSlice(perms),
+			Projects:    projects,
 		}}, nil
 	})
 }
 
-func registerDevAuth(api huma.API, e engine.Engine, authCfg AuthConfig) {
+func registerDevAuth(api huma.API, e Engine, authCfg AuthConfig) {
 	huma.Register(api, huma.Operation{
 		OperationID: "dev-login",
 		Method:      http.MethodPost,
@@ -1906,7 +5378,7 @@ func parseWorkOutcomesMap(raw *string) (map[string]any, error) {
 
 func mutateWorkOutcomes(
 	ctx context.Context,
-	e engine.Engine,
+	e Engine,
 	projectID string,
 	taskID string,
 	actorID string,
@@ -1915,7 +5387,7 @@ func mutateWorkOutcomes(
 	if err := requirePermission(ctx, e, projectID, "task.update"); err != nil {
 		return domain.Task{}, nil, err
 	}
-	task, err := e.Repo.GetTask(ctx, taskID)
+	task, err := e.Store().GetTask(ctx, taskID)
 	if err != nil {
 		return domain.Task{}, nil, err
 	}
@@ -1928,7 +5400,7 @@ func mutateWorkOutcomes(
 	defer func() {
 		_ = e.ReleaseLease(ctx, taskID, actorID)
 	}()
-	task, err = e.Repo.GetTask(ctx, taskID)
+	task, err = e.Store().GetTask(ctx, taskID)
 	if err != nil {
 		return domain.Task{}, nil, err
 	}
@@ -1958,14 +5430,14 @@ func mutateWorkOutcomes(
 	return updated, length, nil
 }
 
-func normalizeLimit(in int) int {
+func normalizeLimit(in int) (int, error) {
 	if in <= 0 {
-		return 50
+		return 50, nil
 	}
-	if in > 200 {
-		return 200
+	if err := repo.CheckPageLimit(in); err != nil {
+		return 0, err
 	}
-	return in
+	return in, nil
 }
 
 func parseCompositeCursor(cursor string) (string, string, error) {
@@ -1986,6 +5458,49 @@ func composeCursor(ts, id string) string {
 	return ts + "|" + id
 }
 
+// createProject creates a project in orgID (the default org when empty) with the default
+// config, under the program the request names, in one transaction. Repeating it returns
+// the project already created, with created false.
+func createProject(ctx context.Context, e Engine, orgID string, body CreateProjectRequest) (domain.Project, bool, error) {
+	actorID, authErr := actorIDFromContext(ctx)
+	if authErr != nil {
+		return domain.Project{}, false, authErr
+	}
+	parentID := stringOrEmpty(body.ParentProjectID)
+	if parentID != "" {
+		parent, err := e.Store().GetProject(ctx, parentID)
+		if err != nil {
+			return domain.Project{}, false, handleError(err)
+		}
+		if err := requirePermission(ctx, e, parentID, "project.update"); err != nil {
+			return domain.Project{}, false, handleError(err)
+		}
+		if orgID != "" && parent.OrgID != orgID {
+			return domain.Project{}, false, newAPIError(http.StatusBadRequest, "bad_request", "parent project belongs to another org", map[string]any{"parent_project_id": parentID})
+		}
+	}
+	p, created, err := e.EnsureProject(ctx, engine.ProjectInitOptions{
+		ProjectID:       body.ID,
+		Description:     stringOrEmpty(body.Description),
+		ActorID:         actorID,
+		OrgID:           orgID,
+		ParentProjectID: parentID,
+		Config:          config.Default(body.ID),
+	})
+	if err != nil {
+		return domain.Project{}, false, handleError(err)
+	}
+	return p, created, nil
+}
+
+// createdStatus answers 201 for a created resource and 200 for one that already existed.
+func createdStatus(created bool) int {
+	if created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
 func mapProjects(items []domain.Project) []ProjectResponse {
 	res := make([]ProjectResponse, 0, len(items))
 	for _, p := range items {
@@ -2016,42 +5531,66 @@ func strPtrValue(ptr *string) string {
 	return *ptr
 }
 
+func floatPtrValue(ptr *float64) float64 {
+	if ptr == nil {
+		return 0
+	}
+	return *ptr
+}
+
 func toJSONArray(items []string) string {
 	b, _ := json.Marshal(items)
 	return string(b)
 }
 
-func taskValidationStatus(ctx context.Context, r repo.Repo, t domain.Task) (ValidationStatusResponse, error) {
+func taskValidationStatus(ctx context.Context, e Engine, t domain.Task) (ValidationStatusResponse, error) {
 	required := decodeStringSlice(t.RequiredAttestationsJSON)
+	sources, err := e.RequirementSources(ctx, t)
+	if err != nil {
+		return ValidationStatusResponse{}, err
+	}
+	risk, err := e.TaskRisk(ctx, t)
+	if err != nil {
+		return ValidationStatusResponse{}, err
+	}
 	resp := ValidationStatusResponse{
 		Required: nonNilSlice(required),
+		Sources:  sources,
 		Present:  []string{},
 		Missing:  []string{},
+		Waived:   []string{},
+		Waivers:  []WaiverResponse{},
+		Risk:     risk,
 	}
+	attestersMet := risk == nil || len(risk.MissingAttesters) == 0
 	if len(required) == 0 {
-		resp.Satisfied = true
+		resp.Satisfied = attestersMet
 		return resp, nil
 	}
-	atts, err := r.ListAttestations(ctx, repo.AttestationFilters{
-		EntityKind: "task",
-		EntityID:   t.ID,
-		ProjectID:  t.ProjectID,
-	})
+	found, err := e.PresentRequirements(ctx, t.ID, required)
 	if err != nil {
 		return resp, err
 	}
-	found := map[string]bool{}
-	for _, att := range atts {
-		found[att.Kind] = true
+	waivers, err := e.ActiveWaivers(ctx, t.ID)
+	if err != nil {
+		return resp, err
+	}
+	waived := map[string]bool{}
+	for _, w := range waivers {
+		waived[w.Kind] = true
+		resp.Waivers = append(resp.Waivers, waiverResponse(w))
 	}
 	for _, req := range required {
-		if found[req] {
+		switch {
+		case found[req]:
 			resp.Present = append(resp.Present, req)
-		} else {
+		case waived[req]:
+			resp.Waived = append(resp.Waived, req)
+		default:
 			resp.Missing = append(resp.Missing, req)
 		}
 	}
-	resp.Satisfied = len(resp.Missing) == 0
+	resp.Satisfied = len(resp.Missing) == 0 && attestersMet
 	return resp, nil
 }
 
@@ -2082,3 +5621,5 @@ func projectFromHeader(ctx context.Context, fallback string) string {
 	}
 	return fallback
 }
+
+// sortByRank orders siblings by rank, falling back to creation order.
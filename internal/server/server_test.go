@@ -2,23 +2,48 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
+	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
 
+	"workline/internal/blob"
 	"workline/internal/config"
 	"workline/internal/db"
 	"workline/internal/domain"
 	"workline/internal/engine"
+	"workline/internal/evidence"
 	"workline/internal/migrate"
 	"workline/internal/repo"
 )
@@ -35,6 +60,7 @@ type testServer struct {
 	client    *http.Client
 	jwtSecret string
 	apiKey    string
+	engine    engine.Engine
 	close     func()
 }
 
@@ -51,7 +77,18 @@ func newTestServer(t *testing.T) (*testServer, func()) {
 	return newTestServerWithAuth(t, AuthConfig{JWTSecret: "test-secret"})
 }
 
+// newCachedTestServer is newTestServer with the engine's lookup cache on, for tests of
+// the cache itself.
+func newCachedTestServer(t *testing.T, ttl time.Duration) (*testServer, func()) {
+	return startTestServer(t, AuthConfig{JWTSecret: "test-secret"}, ttl)
+}
+
 func newTestServerWithAuth(t *testing.T, authCfg AuthConfig) (*testServer, func()) {
+	return startTestServer(t, authCfg, 0)
+}
+
+// startTestServer serves a fresh workspace; a positive cacheTTL turns the lookup cache on.
+func startTestServer(t *testing.T, authCfg AuthConfig, cacheTTL time.Duration) (*testServer, func()) {
 	t.Helper()
 	defer func() {
 		if r := recover(); r != nil {
@@ -67,7 +104,8 @@ func newTestServerWithAuth(t *testing.T, authCfg AuthConfig) (*testServer, func(
 		t.Fatalf("ensure workspace: %v", err)
 	}
 	cfg := config.Default("workline")
-	conn, err := db.Open(db.Config{Workspace: workspace})
+	queryStats := db.NewQueryStats()
+	conn, err := db.Open(db.Config{Workspace: workspace, Stats: queryStats})
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
@@ -81,6 +119,10 @@ func newTestServerWithAuth(t *testing.T, authCfg AuthConfig) (*testServer, func(
 	}
 	orgID := "default-org"
 	e := engine.New(conn, cfg)
+	if cacheTTL > 0 {
+		e = e.WithCache(cacheTTL)
+	}
+	e.Blobs = blob.FS{Dir: filepath.Join(workspace, ".workline", "blobs")}
 	if _, err := e.InitProject(context.Background(), cfg.Project.ID, "", "tester"); err != nil {
 		t.Fatalf("init project: %v", err)
 	}
@@ -96,7 +138,10 @@ func newTestServerWithAuth(t *testing.T, authCfg AuthConfig) (*testServer, func(
 	}); err != nil {
 		t.Fatalf("insert api key: %v", err)
 	}
-	handler, err := New(Config{Engine: e, BasePath: "/v0", Auth: authCfg})
+	if e.Evidence, err = evidence.GenerateSigner(); err != nil {
+		t.Fatalf("evidence key: %v", err)
+	}
+	handler, err := New(Config{Engine: e, BasePath: "/v0", Auth: authCfg, ContractValidation: ContractEnforce, QueryStats: queryStats, SlowQuery: time.Second, Compression: true})
 	if err != nil {
 		t.Fatalf("build handler: %v", err)
 	}
@@ -108,6 +153,7 @@ func newTestServerWithAuth(t *testing.T, authCfg AuthConfig) (*testServer, func(
 		client:    ts.Client(),
 		jwtSecret: jwtSecret,
 		apiKey:    apiKeyValue,
+		engine:    e,
 		close: func() {
 			ts.Close()
 			conn.Close()
@@ -383,18 +429,34 @@ func TestPermissionGates(t *testing.T) {
 	}{
 		{"project list", http.MethodGet, srv.URL + "/v0/projects", nil, "project.list"},
 		{"project create", http.MethodPost, srv.URL + "/v0/projects", map[string]any{"id": "blocked-project"}, "project.create"},
+		{"org create", http.MethodPost, srv.URL + "/v0/orgs", map[string]any{"id": "blocked-org"}, "org.create"},
 		{"project read", http.MethodGet, srv.URL + "/v0/projects/perm-project", nil, "project.read"},
 		{"project update", http.MethodPatch, srv.URL + "/v0/projects/perm-project", map[string]any{"description": "blocked"}, "project.update"},
 		{"project delete", http.MethodDelete, srv.URL + "/v0/projects/perm-project", nil, "project.delete"},
 		{"project config", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/config", nil, "project.config.read"},
+		{"task types", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/task-types", nil, "project.config.read"},
+		{"project features", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/features", nil, "project.config.read"},
 		{"project status", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/status", nil, "project.status.read"},
 		{"project events", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/events", nil, "project.events.read"},
+		{"event aggregate", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/events/aggregate", nil, "project.events.read"},
 		{"task list", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks", nil, "task.list"},
 		{"task read", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID, nil, "task.read"},
 		{"task tree", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks/tree", nil, "task.tree"},
+		{"lease waiters", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID + "/lease/waiters", nil, "task.read"},
+		{"project leases", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/leases", nil, "lease.plan.read"},
+		{"task lease", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID + "/lease", nil, "lease.plan.read"},
 		{"task validation", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID + "/validation", nil, "task.validation.read"},
+		{"task completions", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID + "/completions", nil, "task.read"},
+		{"approve completion", http.MethodPost, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID + "/completion/approve", nil, "task.approve"},
+		{"reject completion", http.MethodPost, srv.URL + "/v0/projects/" + projectID + "/tasks/" + createdTask.ID + "/completion/reject", map[string]any{"reason": "no"}, "task.approve"},
 		{"iteration list", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/iterations", nil, "iteration.list"},
 		{"attestation list", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/attestations", nil, "attestation.list"},
+		{"attestation required-by", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/attestation-kinds/ci.passed/required-by", nil, "attestation.list"},
+		{"compliance report", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/reports/compliance", nil, "compliance.read"},
+		{"project usage", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/usage", nil, "usage.read"},
+		{"digest list", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/digests", nil, "digest.read"},
+		{"digest read", http.MethodGet, srv.URL + "/v0/projects/" + projectID + "/digests/2026-01-01", nil, "digest.read"},
+		{"digest generate", http.MethodPost, srv.URL + "/v0/projects/" + projectID + "/digests", map[string]any{}, "digest.generate"},
 	}
 	for _, tc := range cases {
 		tc := tc
@@ -619,6 +681,81 @@ func TestDecisionArraysPresent(t *testing.T) {
 	}
 }
 
+func TestDecisionListing(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	base := srv.URL + "/v0/projects/workline/decisions"
+	client := srv.Client()
+
+	for _, d := range []map[string]any{
+		{"id": "dec-1", "title": "Choose db", "decision": "Use sqlite", "decider_id": "cto", "rationale": []string{"single_file deploys"}},
+		{"id": "dec-2", "title": "Choose queue", "decision": "No queue yet", "decider_id": "cto", "status": "proposed"},
+		{"id": "dec-3", "title": "Choose CI", "decision": "GitHub Actions", "decider_id": "lead"},
+	} {
+		res, data := doJSON(t, client, http.MethodPost, base, d, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create decision: %d %s", res.StatusCode, string(data))
+		}
+	}
+
+	list := func(query string) paginatedDecisions {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+query, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("list %s: %d %s", query, res.StatusCode, string(data))
+		}
+		var page paginatedDecisions
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return page
+	}
+	ids := func(page paginatedDecisions) []string {
+		var out []string
+		for _, d := range page.Items {
+			out = append(out, d.ID)
+		}
+		return out
+	}
+	if got := ids(list("?decider_id=cto")); len(got) != 2 {
+		t.Fatalf("decider filter: %v", got)
+	}
+	if got := ids(list("?status=proposed")); len(got) != 1 || got[0] != "dec-2" {
+		t.Fatalf("status filter: %v", got)
+	}
+	if got := ids(list("?q=SINGLE_FILE")); len(got) != 1 || got[0] != "dec-1" {
+		t.Fatalf("text search: %v", got)
+	}
+	if got := ids(list("?q=_")); len(got) != 1 {
+		t.Fatalf("wildcards should be literal: %v", got)
+	}
+	if got := ids(list("?from=2000-01-01T00:00:00Z&to=2001-01-01T00:00:00Z")); len(got) != 0 {
+		t.Fatalf("created range: %v", got)
+	}
+	first := list("?limit=2")
+	if len(first.Items) != 2 || first.NextCursor == "" {
+		t.Fatalf("expected a second page: %+v", first)
+	}
+	second := list("?limit=2&cursor=" + first.NextCursor)
+	if len(second.Items) != 1 || second.NextCursor != "" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, base+"/dec-1", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get decision: %d %s", res.StatusCode, string(data))
+	}
+	var d DecisionResponse
+	_ = json.Unmarshal(data, &d)
+	if d.Status != "accepted" || len(d.Rationale) != 1 {
+		t.Fatalf("unexpected decision: %+v", d)
+	}
+	res, _ = doJSON(t, client, http.MethodGet, base+"/dec-missing", nil, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", res.StatusCode)
+	}
+}
+
 func TestTaskDoneWithAttestations(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
@@ -719,253 +856,704 @@ func TestLeaseConflict(t *testing.T) {
 	assertResponseDocumented(t, spec, "/v0/projects/{project_id}/tasks/{id}/claim", http.MethodPost, "409")
 }
 
-func TestIterationValidationBlocked(t *testing.T) {
+func TestLeaseQueue(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
 	projectID := "workline"
 	client := srv.Client()
+	ctx := context.Background()
 
-	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/iterations", map[string]any{
-		"id":   "iter-1",
-		"goal": "Test iteration",
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Queue for me",
+		"type":  "technical",
 	}, nil)
 	if res.StatusCode != http.StatusCreated {
-		t.Fatalf("create iteration: %d %s", res.StatusCode, string(data))
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
 	}
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+	taskURL := srv.URL + "/v0/projects/" + projectID + "/tasks/" + created.ID
 
-	runRes, runBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/iterations/iter-1/status", map[string]any{
-		"status": "running",
-	}, nil)
-	if runRes.StatusCode != http.StatusOK {
-		t.Fatalf("set running: %d %s", runRes.StatusCode, string(runBody))
+	headers := map[string]map[string]string{}
+	for _, actor := range []string{"w1", "w2", "w3"} {
+		if err := srv.engine.GrantRole(ctx, projectID, "tester", actor, "dev"); err != nil {
+			t.Fatalf("grant %s: %v", actor, err)
+		}
+		headers[actor] = bearerHeader(srv.bearerToken(t, actor, "default-org", time.Now().Add(time.Hour)))
 	}
 
-	deliveredRes, deliveredBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/iterations/iter-1/status", map[string]any{
-		"status": "delivered",
-	}, nil)
-	if deliveredRes.StatusCode != http.StatusOK {
-		t.Fatalf("set delivered: %d %s", deliveredRes.StatusCode, string(deliveredBody))
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim", nil, nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("claim: %d %s", res.StatusCode, string(data))
 	}
-
-	valRes, valBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/iterations/iter-1/status", map[string]any{
-		"status": "validated",
-	}, nil)
-	if valRes.StatusCode != http.StatusUnprocessableEntity {
-		t.Fatalf("expected validation block (422), got %d %s", valRes.StatusCode, string(valBody))
+	for i, actor := range []string{"w1", "w2", "w3"} {
+		res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim?wait=true&lease_seconds=600", nil, headers[actor])
+		if res.StatusCode != http.StatusAccepted {
+			t.Fatalf("queue %s: %d %s", actor, res.StatusCode, string(data))
+		}
+		var queued ClaimLeaseResponse
+		_ = json.Unmarshal(data, &queued)
+		if queued.OwnerID != "tester" || queued.Waiting == nil || queued.Waiting.Position != i+1 {
+			t.Fatalf("queue %s: %s", actor, string(data))
+		}
+	}
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim", nil, headers["w2"]); res.StatusCode != http.StatusConflict {
+		t.Fatalf("claim without wait: %d %s", res.StatusCode, string(data))
 	}
-}
 
-func TestUnauthorizedTaskCreate(t *testing.T) {
-	srv, cleanup := newTestServer(t)
-	defer cleanup()
-	projectID := "workline"
-	client := srv.Client()
+	res, data = doJSON(t, client, http.MethodGet, taskURL+"/lease/waiters", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list waiters: %d %s", res.StatusCode, string(data))
+	}
+	var list LeaseWaitersResponse
+	_ = json.Unmarshal(data, &list)
+	if len(list.Waiters) != 3 || list.Waiters[0].ActorID != "w1" || list.Waiters[2].ActorID != "w3" {
+		t.Fatalf("unexpected waiters: %s", string(data))
+	}
 
-	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Should fail",
-		"type":  "technical",
-	}, bearerHeader(srv.bearerToken(t, "intruder", "default-org", time.Now().Add(time.Hour))))
-	if res.StatusCode != http.StatusForbidden {
-		t.Fatalf("expected 403, got %d: %s", res.StatusCode, string(data))
+	// Releasing hands the lease to the first waiter.
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/release", nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("release: %d %s", res.StatusCode, string(data))
 	}
-	var apiErr struct {
-		Error apiErrorBody `json:"error"`
+	lease, err := srv.engine.Repo.GetLease(ctx, created.ID)
+	if err != nil || lease.OwnerID != "w1" {
+		t.Fatalf("expected w1 to hold the lease, got %+v (%v)", lease, err)
 	}
-	_ = json.Unmarshal(data, &apiErr)
-	if apiErr.Error.Code != "forbidden" {
-		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	granted, err := srv.engine.Repo.LatestEvents(ctx, 1, projectID, "lease.granted", "task", created.ID)
+	if err != nil || len(granted) != 1 || !strings.Contains(granted[0].Payload, `"owner_id":"w1"`) {
+		t.Fatalf("expected lease.granted event, got %+v (%v)", granted, err)
 	}
 
-	evtRes, evtData := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/events?type=auth.denied&limit=1", nil, nil)
-	if evtRes.StatusCode != http.StatusOK {
-		t.Fatalf("events status %d: %s", evtRes.StatusCode, string(evtData))
+	// A queued actor can leave; leaving twice is not found.
+	if res, data := doJSON(t, client, http.MethodDelete, taskURL+"/lease/waiters/me", nil, headers["w2"]); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("leave queue: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodDelete, taskURL+"/lease/waiters/me", nil, headers["w2"]); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("leave queue twice: %d %s", res.StatusCode, string(data))
+	}
+
+	// An expired lease goes to the next waiter on the sweep, skipping actors that lost task.claim.
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim?wait=true", nil, headers["w2"]); res.StatusCode != http.StatusAccepted {
+		t.Fatalf("requeue w2: %d %s", res.StatusCode, string(data))
+	}
+	if err := srv.engine.RevokeRole(ctx, projectID, "tester", "w3", "dev"); err != nil {
+		t.Fatalf("revoke w3: %v", err)
+	}
+	later := srv.engine
+	later.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	leases, err := later.GrantQueuedLeases(ctx)
+	if err != nil || len(leases) != 1 || leases[0].OwnerID != "w2" {
+		t.Fatalf("expected sweep to grant w2, got %+v (%v)", leases, err)
+	}
+	dequeued, err := srv.engine.Repo.LatestEvents(ctx, 1, projectID, "lease.dequeued", "task", created.ID)
+	if err != nil || len(dequeued) != 1 || !strings.Contains(dequeued[0].Payload, `"actor_id":"w3"`) {
+		t.Fatalf("expected w3 to be dropped, got %+v (%v)", dequeued, err)
+	}
+	if leases, err := later.GrantQueuedLeases(ctx); err != nil || len(leases) != 0 {
+		t.Fatalf("expected empty queue, got %+v (%v)", leases, err)
 	}
 }
 
-func TestUnauthorizedAttestationKind(t *testing.T) {
+func TestClaimWithPlan(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
 	projectID := "workline"
 	client := srv.Client()
+	ctx := context.Background()
 
-	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Secure task",
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Plan first",
 		"type":  "technical",
 	}, nil)
-	if taskRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
 	}
-	var task TaskResponse
-	_ = json.Unmarshal(taskData, &task)
-
-	grantRes, grantData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", map[string]any{
-		"actor_id": "rev1",
-		"role_id":  "reviewer",
-	}, nil)
-	if grantRes.StatusCode != http.StatusOK && grantRes.StatusCode != http.StatusNoContent {
-		t.Fatalf("grant role: %d %s", grantRes.StatusCode, string(grantData))
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+	taskURL := srv.URL + "/v0/projects/" + projectID + "/tasks/" + created.ID
+	headers := map[string]map[string]string{}
+	for _, actor := range []string{"w1", "w2"} {
+		if err := srv.engine.GrantRole(ctx, projectID, "tester", actor, "dev"); err != nil {
+			t.Fatalf("grant %s: %v", actor, err)
+		}
+		headers[actor] = bearerHeader(srv.bearerToken(t, actor, "default-org", time.Now().Add(time.Hour)))
 	}
 
-	attRes, attData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
-		"entity_kind": "task",
-		"entity_id":   task.ID,
-		"kind":        "security.ok",
-	}, bearerHeader(srv.bearerToken(t, "rev1", "default-org", time.Now().Add(time.Hour))))
-	if attRes.StatusCode != http.StatusForbidden {
-		t.Fatalf("expected 403, got %d: %s", attRes.StatusCode, string(attData))
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim", map[string]any{"plan": map[string]any{"approach": "  "}}, headers["w1"]); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected blank approach to be rejected: %d %s", res.StatusCode, string(data))
 	}
-	var apiErr struct {
-		Error apiErrorBody `json:"error"`
+	res, data = doJSON(t, client, http.MethodPost, taskURL+"/claim", map[string]any{
+		"plan": map[string]any{"approach": "Split the parser first", "estimated_seconds": 7200},
+	}, headers["w1"])
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("claim with plan: %d %s", res.StatusCode, string(data))
 	}
-	_ = json.Unmarshal(attData, &apiErr)
-	if apiErr.Error.Code != "forbidden_attestation_kind" {
-		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	var claimed ClaimLeaseResponse
+	_ = json.Unmarshal(data, &claimed)
+	if claimed.Plan == nil || claimed.Plan.Approach != "Split the parser first" || claimed.Plan.EstimatedSeconds != 7200 {
+		t.Fatalf("expected plan on the lease: %s", string(data))
 	}
-	spec := fetchOpenAPISpec(t, srv)
-	assertResponseDocumented(t, spec, "/v0/projects/{project_id}/attestations", http.MethodPost, "403")
-}
-
-func TestWhoAmIResponsesHaveArrays(t *testing.T) {
-	srv, cleanup := newTestServer(t)
-	defer cleanup()
-	projectID := "workline"
-	client := srv.Client()
-
-	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/me/permissions", nil, nil)
-	if res.StatusCode != http.StatusOK {
-		t.Fatalf("whoami status %d: %s", res.StatusCode, string(data))
+	claimedEvt, err := srv.engine.Repo.LatestEvents(ctx, 1, projectID, "lease.claimed", "task", created.ID)
+	if err != nil || len(claimedEvt) != 1 || !strings.Contains(claimedEvt[0].Payload, `"approach":"Split the parser first"`) {
+		t.Fatalf("expected plan in lease.claimed, got %+v (%v)", claimedEvt, err)
 	}
-	var payload map[string]any
-	if err := json.Unmarshal(data, &payload); err != nil {
-		t.Fatalf("unmarshal whoami: %v", err)
+
+	// Renewing without a plan keeps the one declared.
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim", nil, headers["w1"]); res.StatusCode != http.StatusOK || !strings.Contains(string(data), "Split the parser first") {
+		t.Fatalf("renew: %d %s", res.StatusCode, string(data))
 	}
-	for _, key := range []string{"roles", "permissions"} {
-		val, ok := payload[key]
-		if !ok {
-			t.Fatalf("%s missing", key)
-		}
-		arr, ok := val.([]any)
-		if !ok || arr == nil {
-			t.Fatalf("%s not array: %#v", key, val)
+
+	// Supervisors and the owner read the plan; other developers cannot.
+	for _, h := range []map[string]string{nil, headers["w1"]} {
+		res, data := doJSON(t, client, http.MethodGet, taskURL+"/lease", nil, h)
+		if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"estimated_seconds":7200`) {
+			t.Fatalf("read lease: %d %s", res.StatusCode, string(data))
 		}
 	}
-}
+	res, data = doJSON(t, client, http.MethodGet, taskURL+"/lease", nil, headers["w2"])
+	assertForbiddenPermission(t, res, data, "lease.plan.read")
 
-func TestProjectsListArrayShape(t *testing.T) {
-	srv, cleanup := newTestServer(t)
-	defer cleanup()
-	client := srv.Client()
-	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects", nil, nil)
-	if res.StatusCode != http.StatusOK {
-		t.Fatalf("list projects status %d: %s", res.StatusCode, string(data))
+	// A queued plan comes with the lease when it is granted.
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim?wait=true", map[string]any{"plan": map[string]any{"approach": "Pick up the tests"}}, headers["w2"]); res.StatusCode != http.StatusAccepted {
+		t.Fatalf("queue w2: %d %s", res.StatusCode, string(data))
 	}
-	var payload []any
-	if err := json.Unmarshal(data, &payload); err != nil {
-		t.Fatalf("unmarshal projects: %v", err)
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/release", nil, headers["w1"]); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("release: %d %s", res.StatusCode, string(data))
 	}
-	if payload == nil {
-		t.Fatalf("projects array is nil")
+	lease, err := srv.engine.Repo.GetLease(ctx, created.ID)
+	if err != nil || lease.OwnerID != "w2" || lease.Plan == nil || lease.Plan.Approach != "Pick up the tests" {
+		t.Fatalf("expected w2 to hold the lease with its plan, got %+v (%v)", lease, err)
 	}
 }
 
-func TestTreeChildrenIncludedForLeaves(t *testing.T) {
+func TestProjectLeases(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
 	projectID := "workline"
 	client := srv.Client()
-
-	parentRes, parentBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"id":    "parent-1",
-		"title": "Parent task",
-		"type":  "technical",
-	}, nil)
-	if parentRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create parent: %d %s", parentRes.StatusCode, string(parentBody))
+	ctx := context.Background()
+	base := srv.URL + "/v0/projects/" + projectID
+	headers := map[string]map[string]string{}
+	taskIDs := map[string]string{}
+	for actor, seconds := range map[string]int{"w1": 600, "w2": 3600} {
+		if err := srv.engine.GrantRole(ctx, projectID, "tester", actor, "dev"); err != nil {
+			t.Fatalf("grant %s: %v", actor, err)
+		}
+		headers[actor] = bearerHeader(srv.bearerToken(t, actor, "default-org", time.Now().Add(time.Hour)))
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Work of " + actor, "type": "technical"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var created TaskResponse
+		_ = json.Unmarshal(data, &created)
+		taskIDs[actor] = created.ID
+		if res, data := doJSON(t, client, http.MethodPost, base+"/tasks/"+created.ID+"/claim?lease_seconds="+fmt.Sprint(seconds), nil, headers[actor]); res.StatusCode != http.StatusOK {
+			t.Fatalf("claim as %s: %d %s", actor, res.StatusCode, string(data))
+		}
 	}
-	childRes, childBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"id":        "child-1",
-		"title":     "Child task",
-		"type":      "technical",
-		"parent_id": "parent-1",
-	}, nil)
-	if childRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create child: %d %s", childRes.StatusCode, string(childBody))
+	list := func(query string) []LeaseResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/leases"+query, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("list leases%s: %d %s", query, res.StatusCode, string(data))
+		}
+		var resp ProjectLeasesResponse
+		_ = json.Unmarshal(data, &resp)
+		return resp.Leases
 	}
 
-	treeRes, treeBody := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/tree", nil, nil)
-	if treeRes.StatusCode != http.StatusOK {
-		t.Fatalf("task tree status %d: %s", treeRes.StatusCode, string(treeBody))
+	if leases := list(""); len(leases) != 2 || leases[0].TaskID != taskIDs["w1"] || leases[1].OwnerID != "w2" {
+		t.Fatalf("expected both leases, soonest first: %+v", leases)
 	}
-	var tree []map[string]any
-	if err := json.Unmarshal(treeBody, &tree); err != nil {
-		t.Fatalf("unmarshal tree: %v", err)
+	if leases := list("?owner=w2"); len(leases) != 1 || leases[0].TaskID != taskIDs["w2"] {
+		t.Fatalf("expected the lease of w2: %+v", leases)
 	}
-	if len(tree) == 0 {
-		t.Fatalf("expected tree nodes")
+	if leases := list("?expiring_within=30m"); len(leases) != 1 || leases[0].OwnerID != "w1" {
+		t.Fatalf("expected the lease expiring within 30m: %+v", leases)
 	}
-	for _, node := range tree {
-		children, ok := node["children"]
-		if !ok {
-			t.Fatalf("children missing on node")
-		}
-		childArr, ok := children.([]any)
-		if !ok || childArr == nil {
-			t.Fatalf("children not array: %#v", children)
-		}
-		for _, maybeLeaf := range childArr {
-			leaf, ok := maybeLeaf.(map[string]any)
-			if !ok {
-				continue
-			}
-			leafChildren, ok := leaf["children"]
-			if !ok {
-				t.Fatalf("leaf children missing")
-			}
-			leafArr, ok := leafChildren.([]any)
-			if !ok || leafArr == nil {
-				t.Fatalf("leaf children not array: %#v", leafChildren)
-			}
+	if res, data := doJSON(t, client, http.MethodPost, base+"/tasks/"+taskIDs["w1"]+"/release", nil, headers["w1"]); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("release: %d %s", res.StatusCode, string(data))
+	}
+	if leases := list("?owner=w1"); len(leases) != 0 {
+		t.Fatalf("expected released lease to be gone: %+v", leases)
+	}
+	for _, query := range []string{"?expiring_within=soon", "?expiring_within=-5m"} {
+		if res, data := doJSON(t, client, http.MethodGet, base+"/leases"+query, nil, nil); res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s: %d %s", query, res.StatusCode, string(data))
 		}
 	}
+	res, data := doJSON(t, client, http.MethodGet, base+"/leases", nil, headers["w2"])
+	assertForbiddenPermission(t, res, data, "lease.plan.read")
 }
 
-func TestEventEntityKindEnum(t *testing.T) {
+func TestTwoPhaseCompletion(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
 	projectID := "workline"
 	client := srv.Client()
-	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/events?limit=1", nil, nil)
-	if res.StatusCode != http.StatusOK {
-		t.Fatalf("events status %d: %s", res.StatusCode, string(data))
+	ctx := context.Background()
+	srv.engine.Config.Completion.Mode = "approval"
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "rev-1", "reviewer"); err != nil {
+		t.Fatal(err)
 	}
-	var payload struct {
-		Items []EventResponse `json:"items"`
+	reviewer := bearerHeader(srv.bearerToken(t, "rev-1", "default-org", time.Now().Add(time.Hour)))
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Review me",
+		"type":  "technical",
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
 	}
-	if err := json.Unmarshal(data, &payload); err != nil {
-		t.Fatalf("unmarshal events: %v", err)
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+	if _, err := srv.engine.UpdateTask(ctx, engine.TaskUpdateOptions{ID: created.ID, ActorID: "tester", RequiredKindsSet: true, PolicyOverride: true}); err != nil {
+		t.Fatalf("clear policy: %v", err)
 	}
-	allowed := map[string]struct{}{
-		"project":   {},
-		"iteration": {},
-		"task":      {},
-		"decision":  {},
-		"rbac":      {},
+	taskURL := srv.URL + "/v0/projects/" + projectID + "/tasks/" + created.ID
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim", nil, nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("claim: %d %s", res.StatusCode, string(data))
 	}
-	for _, evt := range payload.Items {
-		if _, ok := allowed[evt.EntityKind]; !ok {
-			t.Fatalf("unexpected entity_kind: %s", evt.EntityKind)
-		}
+	res, data = doJSON(t, client, http.MethodPost, taskURL+"/done", map[string]any{"work_outcomes": map[string]any{"pr": "#7"}}, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"status":"review"`) || !strings.Contains(string(data), `"completed_at":null`) {
+		t.Fatalf("expected done to submit for review: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, taskURL+"/completions", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"status":"pending"`) {
+		t.Fatalf("list completions: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/completion/approve", nil, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected self-approval to be refused: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, taskURL+"/completion/approve", nil, reviewer)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"status":"done"`) || strings.Contains(string(data), `"completed_at":null`) {
+		t.Fatalf("approve: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/completion/reject", map[string]any{"reason": "late"}, reviewer); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected nothing left to reject: %d %s", res.StatusCode, string(data))
 	}
 }
 
-func TestRoleGrantAllowsClaim(t *testing.T) {
+func TestAggregateEvents(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
 	projectID := "workline"
 	client := srv.Client()
 
-	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Claim me",
+	for _, title := range []string{"One", "Two", "Three"} {
+		res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+			"title": title,
+			"type":  "technical",
+		}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+	}
+
+	now := time.Now().UTC()
+	base := srv.URL + "/v0/projects/" + projectID + "/events/aggregate"
+	from := now.Add(-3 * time.Hour).Format(time.RFC3339)
+	res, data := doJSON(t, client, http.MethodGet, base+"?bucket=hour&type=task.created&type=lease.claimed&from="+from, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("aggregate: %d %s", res.StatusCode, string(data))
+	}
+	var agg engine.EventAggregate
+	_ = json.Unmarshal(data, &agg)
+	if agg.Total != 3 || len(agg.Types) != 2 || agg.Types[0] != "lease.claimed" {
+		t.Fatalf("unexpected aggregate: %s", string(data))
+	}
+	// Empty hours are listed too, so the period spans at least four buckets.
+	if len(agg.Buckets) < 4 || agg.Buckets[0].Start != now.Add(-3*time.Hour).Truncate(time.Hour).Format(time.RFC3339) {
+		t.Fatalf("unexpected buckets: %s", string(data))
+	}
+	created := 0
+	for _, b := range agg.Buckets {
+		if b.Total != b.Counts["task.created"] {
+			t.Fatalf("bucket %s counts other types: %+v", b.Start, b)
+		}
+		created += b.Total
+	}
+	if created != 3 {
+		t.Fatalf("unexpected buckets: %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"?bucket=day&from="+now.Format(time.DateOnly), nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("aggregate by day: %d %s", res.StatusCode, string(data))
+	}
+	var daily engine.EventAggregate
+	_ = json.Unmarshal(data, &daily)
+	if len(daily.Buckets) != 1 || daily.Buckets[0].Counts["task.created"] != 3 || daily.Total <= 3 {
+		t.Fatalf("unexpected daily aggregate: %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"?bucket=hour&from=2020-01-01", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected too many buckets to be rejected, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestAttestationRequiredBy(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+	base := srv.URL + "/v0/projects/" + projectID
+
+	for _, id := range []string{"rb-pending", "rb-attested", "rb-waived", "rb-docs", "rb-canceled"} {
+		typ := "technical"
+		if id == "rb-docs" {
+			typ = "docs"
+		}
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"id": id, "title": "Task " + id, "type": typ}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", id, res.StatusCode, string(data))
+		}
+	}
+	res, data := doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "task", "entity_id": "rb-attested", "kind": "acceptance.passed"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest: %d %s", res.StatusCode, string(data))
+	}
+	if _, err := srv.engine.WaiveValidation(ctx, engine.WaiverCreateOptions{
+		TaskID:        "rb-waived",
+		Kind:          "acceptance.passed",
+		Justification: "covered by the pilot",
+		ExpiresAt:     time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		ActorID:       "tester",
+	}); err != nil {
+		t.Fatalf("waive: %v", err)
+	}
+	if _, err := srv.engine.CancelTask(ctx, engine.TaskCancelOptions{ID: "rb-canceled", ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations", map[string]any{"id": "rb-iter", "goal": "Pilot"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create iteration: %d %s", res.StatusCode, string(data))
+	}
+
+	get := func(kind string) engine.AttestationBacklog {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/attestation-kinds/"+kind+"/required-by", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("required-by %s: %d %s", kind, res.StatusCode, string(data))
+		}
+		var backlog engine.AttestationBacklog
+		_ = json.Unmarshal(data, &backlog)
+		return backlog
+	}
+	acceptance := get("acceptance.passed")
+	if len(acceptance.Tasks) != 1 || acceptance.Tasks[0].TaskID != "rb-pending" || !slices.Equal(acceptance.Tasks[0].Missing, []string{"acceptance.passed"}) {
+		t.Fatalf("unexpected acceptance backlog: %+v", acceptance)
+	}
+	if len(acceptance.Iterations) != 0 {
+		t.Fatalf("iterations do not require acceptance.passed: %+v", acceptance.Iterations)
+	}
+	review := get("review.approved")
+	var reviewIDs []string
+	for _, item := range review.Tasks {
+		reviewIDs = append(reviewIDs, item.TaskID)
+	}
+	for _, id := range []string{"rb-pending", "rb-attested", "rb-waived", "rb-docs"} {
+		if !slices.Contains(reviewIDs, id) {
+			t.Fatalf("expected %s to wait for review.approved, got %v", id, reviewIDs)
+		}
+	}
+	if slices.Contains(reviewIDs, "rb-canceled") {
+		t.Fatalf("canceled tasks are not pending: %v", reviewIDs)
+	}
+	approval := get("iteration.approved")
+	if len(approval.Iterations) != 1 || approval.Iterations[0].IterationID != "rb-iter" || len(approval.Tasks) != 0 {
+		t.Fatalf("unexpected iteration backlog: %+v", approval)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "iteration", "entity_id": "rb-iter", "kind": "iteration.approved"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest iteration: %d %s", res.StatusCode, string(data))
+	}
+	if approval := get("iteration.approved"); len(approval.Iterations) != 0 {
+		t.Fatalf("attested iteration still pending: %+v", approval.Iterations)
+	}
+}
+
+func TestListTasksSnapshot(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID + "/tasks"
+
+	create := func(id string) {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base, map[string]any{"id": id, "title": "Task " + id, "type": "technical"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", id, res.StatusCode, string(data))
+		}
+	}
+	list := func(query string, headers map[string]string) (int, paginatedTasks, string) {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+query, nil, headers)
+		var page paginatedTasks
+		_ = json.Unmarshal(data, &page)
+		return res.StatusCode, page, string(data)
+	}
+	ids := func(page paginatedTasks) []string {
+		out := []string{}
+		for _, item := range page.Items {
+			out = append(out, item.ID)
+		}
+		return out
+	}
+	for _, id := range []string{"snap-1", "snap-2", "snap-3"} {
+		create(id)
+	}
+	status, first, raw := list("?type=technical&limit=2&snapshot=true", nil)
+	if status != http.StatusOK || len(first.Items) != 2 || first.SnapshotAt == "" || !strings.HasPrefix(first.NextCursor, "snap:") {
+		t.Fatalf("unexpected first page: %d %s", status, raw)
+	}
+	seen := ids(first)
+
+	// Changes after the snapshot do not show up in its later pages.
+	create("snap-4")
+	var pending string
+	for _, id := range []string{"snap-1", "snap-2", "snap-3"} {
+		if !slices.Contains(seen, id) {
+			pending = id
+		}
+	}
+	if _, err := srv.engine.CancelTask(context.Background(), engine.TaskCancelOptions{ID: pending, ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	status, second, raw := list("?limit=2&cursor="+first.NextCursor, nil)
+	if status != http.StatusOK || second.NextCursor != "" || second.SnapshotAt != first.SnapshotAt {
+		t.Fatalf("unexpected second page: %d %s", status, raw)
+	}
+	if len(second.Items) != 1 || second.Items[0].ID != pending || second.Items[0].Status != "planned" {
+		t.Fatalf("expected %s as captured, got %s", pending, raw)
+	}
+	seen = append(seen, ids(second)...)
+	slices.Sort(seen)
+	if !slices.Contains(seen, "snap-1") || slices.Contains(seen, "snap-4") || len(seen) != len(slices.Compact(slices.Clone(seen))) {
+		t.Fatalf("snapshot pages are not consistent: %v", seen)
+	}
+	status, again, raw := list("?limit=2&cursor="+first.NextCursor, nil)
+	if status != http.StatusOK || !slices.Equal(ids(again), ids(second)) {
+		t.Fatalf("expected the snapshot page to be repeatable: %d %s", status, raw)
+	}
+
+	if err := srv.engine.GrantRole(context.Background(), projectID, "tester", "snap-reader", "dev"); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	token := srv.bearerToken(t, "snap-reader", "default-org", time.Now().Add(time.Hour))
+	if status, _, raw := list("?limit=2&cursor="+first.NextCursor, bearerHeader(token)); status != http.StatusBadRequest {
+		t.Fatalf("expected another actor's snapshot cursor to be rejected, got %d %s", status, raw)
+	}
+	if status, _, raw := list("?cursor=snap:unknown:0", nil); status != http.StatusBadRequest {
+		t.Fatalf("expected unknown snapshot to be rejected, got %d %s", status, raw)
+	}
+}
+
+func TestCancelTaskCascade(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+	base := srv.URL + "/v0/projects/" + projectID + "/tasks"
+
+	create := func(id string, extra map[string]any) {
+		t.Helper()
+		body := map[string]any{"id": id, "title": "Task " + id, "type": "technical"}
+		for k, v := range extra {
+			body[k] = v
+		}
+		res, data := doJSON(t, client, http.MethodPost, base, body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", id, res.StatusCode, string(data))
+		}
+	}
+	create("root", nil)
+	create("c1", map[string]any{"parent_id": "root"})
+	create("g1", map[string]any{"parent_id": "c1"})
+	create("c2", map[string]any{"parent_id": "root"})
+	create("d1", map[string]any{"depends_on": []string{"root"}})
+	create("d2", map[string]any{"depends_on": []string{"c1"}})
+	if _, err := srv.engine.ClaimLease(ctx, "root", "tester", 600); err != nil {
+		t.Fatalf("claim root: %v", err)
+	}
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "other", "dev"); err != nil {
+		t.Fatalf("grant other: %v", err)
+	}
+	if _, err := srv.engine.ClaimLease(ctx, "c2", "other", 600); err != nil {
+		t.Fatalf("claim c2: %v", err)
+	}
+
+	ids := func(items []engine.CancelItem) []string {
+		out := []string{}
+		for _, it := range items {
+			out = append(out, it.TaskID)
+		}
+		return out
+	}
+	res, data := doJSON(t, client, http.MethodPost, base+"/root/cancel?cascade=children&dry_run=true", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("dry run: %d %s", res.StatusCode, string(data))
+	}
+	var preview engine.TaskCancellation
+	_ = json.Unmarshal(data, &preview)
+	if !preview.DryRun || !slices.Equal(ids(preview.Canceled), []string{"root", "c1", "g1"}) ||
+		!slices.Equal(ids(preview.Blocked), []string{"d1", "d2"}) || !slices.Equal(ids(preview.Skipped), []string{"c2"}) {
+		t.Fatalf("unexpected preview: %s", string(data))
+	}
+	if preview.Skipped[0].Reason != "lease held by other" || preview.Blocked[1].Of != "c1" {
+		t.Fatalf("unexpected preview details: %s", string(data))
+	}
+	if root, _ := srv.engine.Repo.GetTask(ctx, "root"); root.Status != "planned" {
+		t.Fatalf("dry run changed root to %s", root.Status)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/root/cancel?cascade=dependents", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("cancel: %d %s", res.StatusCode, string(data))
+	}
+	var done engine.TaskCancellation
+	_ = json.Unmarshal(data, &done)
+	if !slices.Equal(ids(done.Canceled), []string{"root", "c1", "d1", "g1", "d2"}) || len(done.Blocked) != 0 {
+		t.Fatalf("unexpected cancellation: %s", string(data))
+	}
+	for _, id := range []string{"root", "c1", "g1", "d1", "d2"} {
+		if task, _ := srv.engine.Repo.GetTask(ctx, id); task.Status != "canceled" {
+			t.Fatalf("%s: expected canceled, got %s", id, task.Status)
+		}
+	}
+	if task, _ := srv.engine.Repo.GetTask(ctx, "c2"); task.Status != "planned" {
+		t.Fatalf("c2: expected planned, got %s", task.Status)
+	}
+	evts, _ := srv.engine.Repo.LatestEvents(ctx, 1, projectID, "task.updated", "task", "d2")
+	if len(evts) != 1 || !strings.Contains(evts[0].Payload, `"canceled_via":"dependent"`) || !strings.Contains(evts[0].Payload, `"of":"c1"`) {
+		t.Fatalf("unexpected d2 event: %+v", evts)
+	}
+
+	// Without cascade, dependents are left open and flagged as blocked.
+	create("x", nil)
+	create("y", map[string]any{"depends_on": []string{"x"}})
+	res, data = doJSON(t, client, http.MethodPost, base+"/x/cancel?force=true", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("cancel x: %d %s", res.StatusCode, string(data))
+	}
+	blocked, _ := srv.engine.Repo.LatestEvents(ctx, 1, projectID, "task.blocked", "task", "y")
+	if len(blocked) != 1 || !strings.Contains(blocked[0].Payload, `"canceled_dependency":"x"`) {
+		t.Fatalf("expected task.blocked for y, got %+v", blocked)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/x/cancel", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected canceling twice to be rejected, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestIterationValidationBlocked(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/iterations", map[string]any{
+		"id":   "iter-1",
+		"goal": "Test iteration",
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create iteration: %d %s", res.StatusCode, string(data))
+	}
+
+	runRes, runBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/iterations/iter-1/status", map[string]any{
+		"status": "running",
+	}, nil)
+	if runRes.StatusCode != http.StatusOK {
+		t.Fatalf("set running: %d %s", runRes.StatusCode, string(runBody))
+	}
+
+	deliveredRes, deliveredBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/iterations/iter-1/status", map[string]any{
+		"status": "delivered",
+	}, nil)
+	if deliveredRes.StatusCode != http.StatusOK {
+		t.Fatalf("set delivered: %d %s", deliveredRes.StatusCode, string(deliveredBody))
+	}
+
+	valRes, valBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/iterations/iter-1/status", map[string]any{
+		"status": "validated",
+	}, nil)
+	if valRes.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected validation block (422), got %d %s", valRes.StatusCode, string(valBody))
+	}
+}
+
+func TestUnauthorizedTaskCreate(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Should fail",
+		"type":  "technical",
+	}, bearerHeader(srv.bearerToken(t, "intruder", "default-org", time.Now().Add(time.Hour))))
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", res.StatusCode, string(data))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(data, &apiErr)
+	if apiErr.Error.Code != "forbidden" {
+		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	}
+
+	evtRes, evtData := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/events?type=auth.denied&limit=1", nil, nil)
+	if evtRes.StatusCode != http.StatusOK {
+		t.Fatalf("events status %d: %s", evtRes.StatusCode, string(evtData))
+	}
+	var denied paginatedEvents
+	if err := json.Unmarshal(evtData, &denied); err != nil {
+		t.Fatalf("unmarshal events: %v", err)
+	}
+	if len(denied.Items) != 1 || denied.Items[0].Payload["permission"] != "task.create" {
+		t.Fatalf("expected recorded auth.denied event, got %s", string(evtData))
+	}
+
+	// A project named only in the URL gets no events.
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/ghost/tasks", nil, bearerHeader(srv.bearerToken(t, "intruder", "default-org", time.Now().Add(time.Hour))))
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for unknown project, got %d: %s", res.StatusCode, string(data))
+	}
+	var ghostEvents int
+	if err := srv.engine.DB.QueryRow(`SELECT COUNT(*) FROM events WHERE project_id='ghost'`).Scan(&ghostEvents); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if ghostEvents != 0 {
+		t.Fatalf("expected no events for an unknown project, got %d", ghostEvents)
+	}
+}
+
+func TestStatusRecorderFlushes(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &statusRecorder{ResponseWriter: w}
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatalf("flush through the recorder: %v", err)
+	}
+	if !w.Flushed || rec.status != http.StatusOK {
+		t.Fatalf("expected the underlying writer to be flushed with 200, got flushed=%v status=%d", w.Flushed, rec.status)
+	}
+	if err := http.NewResponseController(rec).SetWriteDeadline(time.Now().Add(time.Second)); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("expected the controller to reach the underlying writer, got %v", err)
+	}
+}
+
+func TestUnauthorizedAttestationKind(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Secure task",
 		"type":  "technical",
 	}, nil)
 	if taskRes.StatusCode != http.StatusCreated {
@@ -975,265 +1563,5552 @@ func TestRoleGrantAllowsClaim(t *testing.T) {
 	_ = json.Unmarshal(taskData, &task)
 
 	grantRes, grantData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", map[string]any{
-		"actor_id": "dev-1",
-		"role_id":  "dev",
+		"actor_id": "rev1",
+		"role_id":  "reviewer",
 	}, nil)
 	if grantRes.StatusCode != http.StatusOK && grantRes.StatusCode != http.StatusNoContent {
 		t.Fatalf("grant role: %d %s", grantRes.StatusCode, string(grantData))
 	}
 
-	devToken := srv.bearerToken(t, "dev-1", "default-org", time.Now().Add(time.Hour))
-	claimRes, claimData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/claim", nil, bearerHeader(devToken))
-	if claimRes.StatusCode != http.StatusOK {
-		t.Fatalf("claim failed: %d %s", claimRes.StatusCode, string(claimData))
+	attRes, attData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
+		"entity_kind": "task",
+		"entity_id":   task.ID,
+		"kind":        "security.ok",
+	}, bearerHeader(srv.bearerToken(t, "rev1", "default-org", time.Now().Add(time.Hour))))
+	if attRes.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", attRes.StatusCode, string(attData))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(attData, &apiErr)
+	if apiErr.Error.Code != "forbidden_attestation_kind" {
+		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	}
+	spec := fetchOpenAPISpec(t, srv)
+	assertResponseDocumented(t, spec, "/v0/projects/{project_id}/attestations", http.MethodPost, "403")
+}
+
+func TestWhoAmIResponsesHaveArrays(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/me/permissions", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("whoami status %d: %s", res.StatusCode, string(data))
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("unmarshal whoami: %v", err)
+	}
+	for _, key := range []string{"roles", "permissions"} {
+		val, ok := payload[key]
+		if !ok {
+			t.Fatalf("%s missing", key)
+		}
+		arr, ok := val.([]any)
+		if !ok || arr == nil {
+			t.Fatalf("%s not array: %#v", key, val)
+		}
+	}
+}
+
+func TestMembersListing(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for _, grant := range []map[string]any{
+		{"actor_id": "zz-contractor", "role_id": "dev", "expires_at": expiresAt},
+		{"actor_id": "zz-viewer", "role_id": "observer"},
+	} {
+		res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", grant, nil)
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+			t.Fatalf("grant: %d %s", res.StatusCode, string(data))
+		}
+	}
+
+	var all []MemberResponse
+	cursor := ""
+	for page := 0; page < 10; page++ {
+		res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/rbac/members?limit=1&cursor="+cursor, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("members: %d %s", res.StatusCode, string(data))
+		}
+		var body paginatedMembers
+		_ = json.Unmarshal(data, &body)
+		if len(body.Items) > 1 {
+			t.Fatalf("expected pages of one, got %d", len(body.Items))
+		}
+		all = append(all, body.Items...)
+		if body.NextCursor == "" {
+			break
+		}
+		cursor = body.NextCursor
+	}
+	byActor := map[string]MemberResponse{}
+	for _, m := range all {
+		byActor[m.ActorID] = m
+	}
+	contractor, ok := byActor["zz-contractor"]
+	if !ok || len(contractor.Roles) != 1 || contractor.Roles[0].ExpiresAt == nil || *contractor.Roles[0].ExpiresAt != expiresAt {
+		t.Fatalf("unexpected contractor membership: %+v", contractor)
+	}
+	if !slices.Contains(contractor.Permissions, "task.claim") {
+		t.Fatalf("expected effective permissions, got %v", contractor.Permissions)
+	}
+	if viewer := byActor["zz-viewer"]; len(viewer.Roles) != 1 || viewer.Roles[0].ExpiresAt != nil || slices.Contains(viewer.Permissions, "task.claim") {
+		t.Fatalf("unexpected viewer membership: %+v", viewer)
+	}
+	if len(all) < 3 {
+		t.Fatalf("expected the project owner plus two grantees, got %d", len(all))
+	}
+}
+
+func TestAttestationAuthorityReport(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+	ctx := context.Background()
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Audited", "type": "technical"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "ci-bot", "qa"); err != nil {
+		t.Fatalf("grant qa: %v", err)
+	}
+	if err := srv.engine.AllowAttestationRole(ctx, projectID, "tester", "ci.lint", "qa"); err != nil {
+		t.Fatalf("allow ci.lint: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "task", "entity_id": task.ID, "kind": "ci.lint"},
+		bearerHeader(srv.bearerToken(t, "ci-bot", "", time.Now().Add(time.Hour))))
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest: %d %s", res.StatusCode, string(data))
+	}
+	var att AttestationResponse
+	_ = json.Unmarshal(data, &att)
+
+	report := func() AttestationAuthorityReportResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/rbac/attestation-authorities", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("report: %d %s", res.StatusCode, string(data))
+		}
+		var body AttestationAuthorityReportResponse
+		_ = json.Unmarshal(data, &body)
+		return body
+	}
+	body := report()
+	var lint *AttestationAuthorityResponse
+	for i, a := range body.Authorities {
+		if a.Kind == "ci.lint" && a.RoleID == "qa" {
+			lint = &body.Authorities[i]
+		}
+	}
+	if lint == nil || len(lint.Holders) != 1 || lint.Holders[0].ActorID != "ci-bot" {
+		t.Fatalf("expected ci-bot to hold the ci.lint authority, got %+v", body.Authorities)
+	}
+	var types []string
+	for _, evt := range lint.History {
+		types = append(types, evt.Type)
+	}
+	if !slices.Equal(types, []string{"rbac.role_granted", "rbac.attestation_allowed"}) {
+		t.Fatalf("unexpected authority history %v", types)
+	}
+	if len(body.Unauthorized) != 0 {
+		t.Fatalf("expected no attestation outside authority, got %+v", body.Unauthorized)
+	}
+
+	if err := srv.engine.RevokeRole(ctx, projectID, "tester", "ci-bot", "qa"); err != nil {
+		t.Fatalf("revoke qa: %v", err)
+	}
+	body = report()
+	if len(body.Unauthorized) != 1 || body.Unauthorized[0].ID != att.ID || body.Unauthorized[0].ActorID != "ci-bot" {
+		t.Fatalf("expected the ci-bot attestation reported, got %+v", body.Unauthorized)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/rbac/attestation-authorities", nil, bearerHeader(srv.bearerToken(t, "intruder", "", time.Now().Add(time.Hour))))
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 without rbac.read, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestProjectsListArrayShape(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list projects status %d: %s", res.StatusCode, string(data))
+	}
+	var payload []any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("unmarshal projects: %v", err)
+	}
+	if payload == nil {
+		t.Fatalf("projects array is nil")
+	}
+}
+
+func TestUpdateProjectMetadata(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	projectURL := srv.URL + "/v0/projects/" + projectID
+
+	res, data := doJSON(t, client, http.MethodPatch, projectURL, map[string]any{
+		"description":  "Proof ledger",
+		"display_name": "  Workline  ",
+		"tags":         []string{"platform", "tier-1", "platform"},
+		"metadata":     map[string]any{"cost_center": "R&D", "sla": map[string]any{"hours": 24}},
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("update project: %d %s", res.StatusCode, string(data))
+	}
+	var p ProjectResponse
+	_ = json.Unmarshal(data, &p)
+	if p.DisplayName != "Workline" || p.Description != "Proof ledger" || len(p.Tags) != 2 || p.Tags[1] != "tier-1" || p.Metadata["cost_center"] != "R&D" {
+		t.Fatalf("unexpected project: %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, projectURL, nil, nil)
+	var got ProjectResponse
+	_ = json.Unmarshal(data, &got)
+	if res.StatusCode != http.StatusOK || got.DisplayName != "Workline" || len(got.Tags) != 2 || got.Metadata["sla"] == nil {
+		t.Fatalf("get project: %d %s", res.StatusCode, string(data))
+	}
+
+	evts, err := srv.engine.Repo.LatestEvents(context.Background(), 5, projectID, "project.updated", "project", projectID)
+	if err != nil || len(evts) != 1 {
+		t.Fatalf("expected one project.updated event, got %+v (%v)", evts, err)
+	}
+	var payload map[string]any
+	_ = json.Unmarshal([]byte(evts[0].Payload), &payload)
+	previous, _ := payload["previous"].(map[string]any)
+	if payload["display_name"] != "Workline" || previous == nil || previous["display_name"] != "" || payload["status"] != nil {
+		t.Fatalf("unexpected event payload: %s", evts[0].Payload)
+	}
+
+	// Clearing tags and metadata; repeating the same change records nothing.
+	res, data = doJSON(t, client, http.MethodPatch, projectURL, map[string]any{"tags": []string{}, "metadata": map[string]any{}}, nil)
+	var cleared ProjectResponse
+	_ = json.Unmarshal(data, &cleared)
+	if res.StatusCode != http.StatusOK || cleared.Tags != nil || cleared.Metadata != nil || cleared.DisplayName != "Workline" {
+		t.Fatalf("clear tags: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPatch, projectURL, map[string]any{"tags": []string{}}, nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("no-op update: %d %s", res.StatusCode, string(data))
+	}
+	evts, _ = srv.engine.Repo.LatestEvents(context.Background(), 5, projectID, "project.updated", "project", projectID)
+	if len(evts) != 2 {
+		t.Fatalf("expected two project.updated events, got %d", len(evts))
+	}
+
+	res, data = doJSON(t, client, http.MethodPatch, projectURL, map[string]any{"tags": []string{"has space"}}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid tag to be rejected, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestTreeChildrenIncludedForLeaves(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	parentRes, parentBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":    "parent-1",
+		"title": "Parent task",
+		"type":  "technical",
+	}, nil)
+	if parentRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create parent: %d %s", parentRes.StatusCode, string(parentBody))
+	}
+	childRes, childBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":        "child-1",
+		"title":     "Child task",
+		"type":      "technical",
+		"parent_id": "parent-1",
+	}, nil)
+	if childRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create child: %d %s", childRes.StatusCode, string(childBody))
+	}
+
+	treeRes, treeBody := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/tree", nil, nil)
+	if treeRes.StatusCode != http.StatusOK {
+		t.Fatalf("task tree status %d: %s", treeRes.StatusCode, string(treeBody))
+	}
+	var tree []map[string]any
+	if err := json.Unmarshal(treeBody, &tree); err != nil {
+		t.Fatalf("unmarshal tree: %v", err)
+	}
+	if len(tree) == 0 {
+		t.Fatalf("expected tree nodes")
+	}
+	for _, node := range tree {
+		children, ok := node["children"]
+		if !ok {
+			t.Fatalf("children missing on node")
+		}
+		childArr, ok := children.([]any)
+		if !ok || childArr == nil {
+			t.Fatalf("children not array: %#v", children)
+		}
+		for _, maybeLeaf := range childArr {
+			leaf, ok := maybeLeaf.(map[string]any)
+			if !ok {
+				continue
+			}
+			leafChildren, ok := leaf["children"]
+			if !ok {
+				t.Fatalf("leaf children missing")
+			}
+			leafArr, ok := leafChildren.([]any)
+			if !ok || leafArr == nil {
+				t.Fatalf("leaf children not array: %#v", leafChildren)
+			}
+		}
+	}
+}
+
+func TestTreeSnapshotUnderConcurrentReparenting(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	for _, body := range []map[string]any{
+		{"id": "dep-x", "title": "Seed data", "type": "technical"},
+		{"id": "dep-y", "title": "Schema", "type": "technical"},
+		{"id": "epic-a", "title": "Epic A", "type": "feature"},
+		{"id": "epic-b", "title": "Epic B", "type": "feature"},
+		{"id": "moving", "title": "Moving task", "type": "technical", "parent_id": "epic-a", "depends_on": []string{"dep-x"}},
+	} {
+		if res, data := doJSON(t, client, http.MethodPost, base+"/tasks", body, nil); res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %v: %d %s", body["id"], res.StatusCode, string(data))
+		}
+	}
+
+	// Each PATCH moves the task and swaps its dependency together: under epic-a it depends
+	// on dep-x, under epic-b on dep-y. A tree read across a write would mix the two.
+	auth := "Bearer " + srv.bearerToken(t, "tester", "default-org", time.Now().Add(time.Hour))
+	readTree := func() ([]treeNode, error) {
+		req, err := http.NewRequest(http.MethodGet, base+"/tasks/tree", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("tree: %d %s", res.StatusCode, string(data))
+		}
+		var tree []treeNode
+		return tree, json.Unmarshal(data, &tree)
+	}
+	check := func(tree []treeNode) error {
+		var seen []string
+		var walk func(nodes []treeNode, parent string)
+		walk = func(nodes []treeNode, parent string) {
+			for _, n := range nodes {
+				if n.Task.ID == "moving" {
+					seen = append(seen, parent+" "+strings.Join(n.Task.DependsOn, ","))
+				}
+				walk(n.Children, n.Task.ID)
+			}
+		}
+		walk(tree, "")
+		if len(seen) != 1 || (seen[0] != "epic-a dep-x" && seen[0] != "epic-b dep-y") {
+			return fmt.Errorf("inconsistent tree: moving task seen as %q", seen)
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+	var reads atomic.Int64
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				tree, err := readTree()
+				if err == nil {
+					err = check(tree)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				reads.Add(1)
+			}
+		}()
+	}
+	for i := range 40 {
+		body := map[string]any{"parent_id": "epic-b", "add_depends_on": []string{"dep-y"}, "remove_depends_on": []string{"dep-x"}}
+		if i%2 == 1 {
+			body = map[string]any{"parent_id": "epic-a", "add_depends_on": []string{"dep-x"}, "remove_depends_on": []string{"dep-y"}}
+		}
+		if res, data := doJSON(t, client, http.MethodPatch, base+"/tasks/moving", body, nil); res.StatusCode != http.StatusOK {
+			close(done)
+			wg.Wait()
+			t.Fatalf("reparent %d: %d %s", i, res.StatusCode, string(data))
+		}
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if reads.Load() == 0 {
+		t.Fatal("expected tree reads during the reparenting")
+	}
+	tree, err := readTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := check(tree); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventEntityKindEnum(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/events?limit=1", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("events status %d: %s", res.StatusCode, string(data))
+	}
+	var payload struct {
+		Items []EventResponse `json:"items"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("unmarshal events: %v", err)
+	}
+	allowed := map[string]struct{}{
+		"project":   {},
+		"iteration": {},
+		"task":      {},
+		"decision":  {},
+		"rbac":      {},
+	}
+	for _, evt := range payload.Items {
+		if _, ok := allowed[evt.EntityKind]; !ok {
+			t.Fatalf("unexpected entity_kind: %s", evt.EntityKind)
+		}
+	}
+}
+
+func TestEventsLongPoll(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+	ctx := context.Background()
+
+	list := func(query string) paginatedEvents {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/events?"+query, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("events %s: %d %s", query, res.StatusCode, string(data))
+		}
+		var body paginatedEvents
+		_ = json.Unmarshal(data, &body)
+		return body
+	}
+	latest := list("limit=1").Items[0].ID
+
+	start := time.Now()
+	if body := list(fmt.Sprintf("since_id=%d&wait=300ms", latest)); len(body.Items) != 0 {
+		t.Fatalf("expected no events, got %+v", body.Items)
+	}
+	if waited := time.Since(start); waited < 300*time.Millisecond {
+		t.Fatalf("expected the request held for the wait, returned after %s", waited)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = srv.engine.CreateTask(ctx, engine.TaskCreateOptions{ID: "task-polled", ProjectID: projectID, Type: "technical", Title: "Polled", ActorID: "tester"})
+	}()
+	start = time.Now()
+	body := list(fmt.Sprintf("since_id=%d&wait=10s&type=task.created", latest))
+	if len(body.Items) != 1 || body.Items[0].EntityID != "task-polled" {
+		t.Fatalf("expected the new task event, got %+v", body.Items)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Fatalf("expected an early answer once the event arrived, took %s", waited)
+	}
+
+	all := list(fmt.Sprintf("since_id=%d", latest))
+	if len(all.Items) == 0 || all.Items[0].ID <= latest || !slices.IsSortedFunc(all.Items, func(a, b EventResponse) int { return int(a.ID - b.ID) }) {
+		t.Fatalf("expected events after since_id oldest first, got %+v", all.Items)
+	}
+
+	for _, query := range []string{"wait=2m", "wait=soon", "since_id=abc", "since_id=1&cursor=5"} {
+		res, data := doJSON(t, client, http.MethodGet, base+"/events?"+query, nil, nil)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d %s", query, res.StatusCode, string(data))
+		}
+	}
+}
+
+func TestEffectiveConfigProfiles(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, "workline")
+	if err != nil {
+		t.Fatalf("get config: %v", err)
+	}
+	devLogin := false
+	cfg.Leases = config.Leases{DefaultTTL: "20m"}
+	cfg.Profiles = map[string]config.Profile{
+		"dev": {},
+		"prod": {
+			Leases: config.Leases{DefaultTTL: "10m", MaxTTL: "1h"},
+			Auth:   config.Auth{DevLogin: &devLogin},
+			Quotas: config.Quotas{Roles: map[string]config.Quota{"viewer": {Requests: 100}}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, "workline", cfg); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+	if err := cfg.UseEnvironment("qa"); err == nil {
+		t.Fatalf("expected an unknown environment to fail")
+	}
+	if err := cfg.UseEnvironment("prod"); err != nil {
+		t.Fatalf("use prod: %v", err)
+	}
+
+	res, data := doJSON(t, srv.Client(), http.MethodGet, srv.URL+"/v0/admin/config/effective", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("effective config: %d %s", res.StatusCode, string(data))
+	}
+	var base EffectiveConfigResponse
+	_ = json.Unmarshal(data, &base)
+	if base.Environment != "" || len(base.Overrides) != 0 || base.Leases.DefaultSeconds != 1200 || !base.Auth.DevLogin {
+		t.Fatalf("unexpected base config: %+v", base)
+	}
+	if !slices.Equal(base.Profiles, []string{"dev", "prod"}) {
+		t.Fatalf("unexpected profiles: %v", base.Profiles)
+	}
+
+	handler, err := New(Config{Engine: srv.engine.WithEnvironment("prod"), BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, ContractValidation: ContractEnforce, DisableDevLogin: !cfg.Effective().Auth.DevLoginEnabled()})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	prod := httptest.NewServer(handler)
+	defer prod.Close()
+	auth := bearerHeader(srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour)))
+
+	res, data = doJSON(t, prod.Client(), http.MethodGet, prod.URL+"/v0/admin/config/effective", nil, auth)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("prod effective config: %d %s", res.StatusCode, string(data))
+	}
+	var eff EffectiveConfigResponse
+	_ = json.Unmarshal(data, &eff)
+	if eff.Environment != "prod" || eff.Leases.DefaultSeconds != 600 || eff.Leases.MaxSeconds != 3600 || eff.Auth.DevLogin {
+		t.Fatalf("unexpected prod config: %+v", eff)
+	}
+	if !slices.Equal(eff.Overrides, []string{"leases.default_ttl", "leases.max_ttl", "auth.dev_login", "quotas.roles.viewer"}) {
+		t.Fatalf("unexpected overrides: %v", eff.Overrides)
+	}
+	if eff.Quotas["viewer"].Requests != 100 {
+		t.Fatalf("expected the viewer quota from the profile, got %+v", eff.Quotas)
+	}
+
+	res, data = doJSON(t, prod.Client(), http.MethodPost, prod.URL+"/v0/auth/dev/login", map[string]any{"actor_id": "mallory"}, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected dev login to be off in prod, got %d %s", res.StatusCode, string(data))
+	}
+
+	for _, id := range []string{"task-default", "task-long"} {
+		res, data = doJSON(t, srv.Client(), http.MethodPost, srv.URL+"/v0/projects/workline/tasks", map[string]any{"id": id, "title": id, "type": "technical"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+	}
+	res, data = doJSON(t, prod.Client(), http.MethodPost, prod.URL+"/v0/projects/workline/tasks/task-default/claim", nil, auth)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("claim: %d %s", res.StatusCode, string(data))
+	}
+	var lease ClaimLeaseResponse
+	_ = json.Unmarshal(data, &lease)
+	acquired, _ := time.Parse(time.RFC3339, lease.AcquiredAt)
+	expires, _ := time.Parse(time.RFC3339, lease.ExpiresAt)
+	if term := expires.Sub(acquired); term != 10*time.Minute {
+		t.Fatalf("expected the prod default lease term, got %s", term)
+	}
+	res, data = doJSON(t, prod.Client(), http.MethodPost, prod.URL+"/v0/projects/workline/tasks/task-long/claim?lease_seconds=7200", nil, auth)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 above the prod max lease term, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, srv.Client(), http.MethodPost, srv.URL+"/v0/projects/workline/tasks/task-long/claim?lease_seconds=7200", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the base config to allow long leases, got %d %s", res.StatusCode, string(data))
+	}
+
+	stored, err := srv.engine.Repo.GetProjectConfig(ctx, "workline")
+	if err != nil {
+		t.Fatalf("get config: %v", err)
+	}
+	if stored.Leases.DefaultTTL != "20m" || stored.Environment != "" {
+		t.Fatalf("expected the stored config without overrides, got %+v %q", stored.Leases, stored.Environment)
+	}
+}
+
+func TestRoleGrantAllowsClaim(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Claim me",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(taskData, &task)
+
+	grantRes, grantData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", map[string]any{
+		"actor_id": "dev-1",
+		"role_id":  "dev",
+	}, nil)
+	if grantRes.StatusCode != http.StatusOK && grantRes.StatusCode != http.StatusNoContent {
+		t.Fatalf("grant role: %d %s", grantRes.StatusCode, string(grantData))
+	}
+
+	devToken := srv.bearerToken(t, "dev-1", "default-org", time.Now().Add(time.Hour))
+	claimRes, claimData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/claim", nil, bearerHeader(devToken))
+	if claimRes.StatusCode != http.StatusOK {
+		t.Fatalf("claim failed: %d %s", claimRes.StatusCode, string(claimData))
+	}
+}
+
+func TestMeListsProjects(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+
+	for _, id := range []string{"side", "third"} {
+		if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects", map[string]any{"id": id}, nil); res.StatusCode != http.StatusCreated {
+			t.Fatalf("create project %s: %d %s", id, res.StatusCode, string(data))
+		}
+	}
+	expires := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	if err := srv.engine.GrantRoleUntil(ctx, "workline", "tester", "multi", "dev", expires); err != nil {
+		t.Fatalf("grant dev: %v", err)
+	}
+	if _, err := srv.engine.CreateTeam(ctx, "side", "reviewers", "", "tester"); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+	if err := srv.engine.AddTeamMember(ctx, "side", "reviewers", "multi", "tester"); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	if err := srv.engine.GrantTeamRoleUntil(ctx, "side", "tester", "reviewers", "reviewer", ""); err != nil {
+		t.Fatalf("grant team role: %v", err)
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/me", nil, bearerHeader(srv.bearerToken(t, "multi", "default-org", time.Now().Add(time.Hour))))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("me: %d %s", res.StatusCode, string(data))
+	}
+	var me WhoAmIResponse
+	_ = json.Unmarshal(data, &me)
+	if len(me.Projects) != 2 || me.Projects[0].ProjectID != "side" || me.Projects[1].ProjectID != "workline" {
+		t.Fatalf("expected side and workline, got %s", string(data))
+	}
+	side, home := me.Projects[0], me.Projects[1]
+	if len(side.Roles) != 1 || side.Roles[0].RoleID != "reviewer" || side.Roles[0].TeamID == nil || *side.Roles[0].TeamID != "reviewers" || side.OrgID != "default-org" {
+		t.Fatalf("unexpected side membership: %+v", side)
+	}
+	if len(home.Roles) != 1 || home.Roles[0].RoleID != "dev" || home.Roles[0].ExpiresAt == nil || *home.Roles[0].ExpiresAt != expires || !slices.Contains(home.Permissions, "task.claim") {
+		t.Fatalf("unexpected workline membership: %+v", home)
+	}
+
+	others, err := srv.engine.Repo.ListMemberships(ctx, "multi", "other-org", time.Now().UTC().Format(time.RFC3339))
+	if err != nil || len(others) != 0 {
+		t.Fatalf("expected memberships scoped to the org, got %+v (%v)", others, err)
+	}
+}
+
+func TestNotificationInbox(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	projectID := "workline"
+
+	for actor, role := range map[string]string{"rita": "reviewer", "dave": "dev"} {
+		if err := srv.engine.GrantRole(ctx, projectID, "tester", actor, role); err != nil {
+			t.Fatalf("grant %s: %v", role, err)
+		}
+	}
+	// ghost is a registered actor, so it can be mentioned, but not a member.
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "ghost", "observer"); err != nil {
+		t.Fatalf("grant observer: %v", err)
+	}
+	if err := srv.engine.RevokeRole(ctx, projectID, "tester", "ghost", "observer"); err != nil {
+		t.Fatalf("revoke observer: %v", err)
+	}
+	// The first collection starts at the head of the log.
+	if added, err := srv.engine.CollectNotifications(ctx, projectID, time.Hour); err != nil || added != 0 {
+		t.Fatalf("first collection: %d %v", added, err)
+	}
+	task, err := srv.engine.CreateTask(ctx, engine.TaskCreateOptions{
+		ProjectID:      projectID,
+		Type:           "technical",
+		Title:          "Review the parser, @rita",
+		Description:    "cc @dave, and @ghost who is not a member",
+		RequiredKinds:  []string{"review.approved"},
+		ActorID:        "tester",
+		PolicyOverride: true,
+	})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	if _, err := srv.engine.ClaimLease(ctx, task.ID, "dave", 600); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	for _, status := range []string{"in_progress", "review"} {
+		if _, err := srv.engine.UpdateTask(ctx, engine.TaskUpdateOptions{ID: task.ID, Status: status, ActorID: "dave"}); err != nil {
+			t.Fatalf("move to %s: %v", status, err)
+		}
+	}
+	if _, err := srv.engine.CollectNotifications(ctx, projectID, time.Hour); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if added, err := srv.engine.CollectNotifications(ctx, projectID, time.Hour); err != nil || added != 0 {
+		t.Fatalf("repeated collection should add nothing: %d %v", added, err)
+	}
+
+	kinds := func(items []domain.Notification) []string {
+		var res []string
+		for _, n := range items {
+			res = append(res, n.Kind)
+		}
+		slices.Sort(res)
+		return res
+	}
+	list := func(actor, query string) paginatedNotifications {
+		t.Helper()
+		token := srv.bearerToken(t, actor, "default-org", time.Now().Add(time.Hour))
+		res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/notifications"+query, nil, bearerHeader(token))
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("list %s: %d %s", actor, res.StatusCode, string(data))
+		}
+		var page paginatedNotifications
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return page
+	}
+	rita := list("rita", "")
+	if got := kinds(rita.Items); !slices.Equal(got, []string{"attestation_requested", "mention"}) || rita.Unread != 2 {
+		t.Fatalf("rita inbox: %v unread %d", got, rita.Unread)
+	}
+	if got := kinds(list("dave", "").Items); !slices.Equal(got, []string{"lease_expiring", "mention"}) {
+		t.Fatalf("dave inbox: %v", got)
+	}
+	if page := list("rita", "?limit=1"); len(page.Items) != 1 || page.NextCursor == "" {
+		t.Fatalf("first page: %+v", page)
+	} else if next := list("rita", "?limit=1&cursor="+page.NextCursor); len(next.Items) != 1 || next.Items[0].ID >= page.Items[0].ID {
+		t.Fatalf("second page: %+v", next)
+	}
+
+	ritaAuth := bearerHeader(srv.bearerToken(t, "rita", "default-org", time.Now().Add(time.Hour)))
+	res, data := doJSON(t, client, http.MethodPost, fmt.Sprintf("%s/v0/projects/%s/notifications/%d/read", srv.URL, projectID, rita.Items[0].ID), nil, ritaAuth)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("read: %d %s", res.StatusCode, string(data))
+	}
+	var read domain.Notification
+	if err := json.Unmarshal(data, &read); err != nil || read.ReadAt == nil {
+		t.Fatalf("read notification: %v %s", err, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/status", nil, ritaAuth)
+	var status map[string]any
+	if err := json.Unmarshal(data, &status); err != nil || res.StatusCode != http.StatusOK || status["unread_notifications"] != float64(1) {
+		t.Fatalf("status: %d %s", res.StatusCode, string(data))
+	}
+	if page := list("rita", "?unread=true"); len(page.Items) != 1 || page.Items[0].ID == read.ID {
+		t.Fatalf("unread filter: %+v", page)
+	}
+	if res, data := doJSON(t, client, http.MethodPost, fmt.Sprintf("%s/v0/projects/%s/notifications/%d/read", srv.URL, projectID, rita.Items[0].ID), nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("reading another actor's notification: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/notifications/read", nil, ritaAuth)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"marked":1`) {
+		t.Fatalf("read all: %d %s", res.StatusCode, string(data))
+	}
+	if page := list("rita", ""); page.Unread != 0 {
+		t.Fatalf("unread after read all: %d", page.Unread)
+	}
+}
+
+func TestEmailPreferences(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	url := srv.URL + "/v0/me/email-preferences"
+
+	res, data := doJSON(t, client, http.MethodGet, url, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get preferences: %d %s", res.StatusCode, string(data))
+	}
+	var prefs domain.EmailPreferences
+	_ = json.Unmarshal(data, &prefs)
+	if prefs.ActorID != "tester" || prefs.Email != "" || len(prefs.Events) != 0 {
+		t.Fatalf("expected empty preferences, got %+v", prefs)
+	}
+
+	res, data = doJSON(t, client, http.MethodPut, url, map[string]any{
+		"email":  "tester@example.com",
+		"events": []string{"decision.created", "waiver.granted", "decision.created"},
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("set preferences: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, url, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get preferences: %d %s", res.StatusCode, string(data))
+	}
+	prefs = domain.EmailPreferences{}
+	_ = json.Unmarshal(data, &prefs)
+	if prefs.Email != "tester@example.com" || !slices.Equal(prefs.Events, []string{"decision.created", "waiver.granted"}) {
+		t.Fatalf("unexpected preferences: %+v", prefs)
+	}
+
+	for _, body := range []map[string]any{
+		{"email": "not an address", "events": []string{}},
+		{"email": "tester@example.com", "events": []string{"task.created"}},
+	} {
+		res, data = doJSON(t, client, http.MethodPut, url, body, nil)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d %s", body, res.StatusCode, string(data))
+		}
+	}
+}
+
+func TestTaskRiskRouting(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	projectID := "workline"
+	srv.engine.Config.Policies.Risk = config.RiskPolicy{
+		Types:  map[string]int{"feature": 2},
+		Labels: map[string]int{"security": 5},
+		Kinds:  map[string]int{"security.ok": 3},
+		Levels: []config.RiskLevel{
+			{Name: "low"},
+			{Name: "high", Min: 5, Preset: "high", Attesters: []string{"reviewer"}},
+		},
+	}
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "rev-1", "reviewer"); err != nil {
+		t.Fatalf("grant reviewer: %v", err)
+	}
+	base := srv.URL + "/v0/projects/" + projectID
+	validation := func(taskID string) ValidationStatusResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/tasks/"+taskID+"/validation", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("validation: %d %s", res.StatusCode, string(data))
+		}
+		var status ValidationStatusResponse
+		_ = json.Unmarshal(data, &status)
+		return status
+	}
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Plain", "type": "feature"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create plain task: %d %s", res.StatusCode, string(data))
+	}
+	var plain TaskResponse
+	_ = json.Unmarshal(data, &plain)
+	if plain.RiskScore == nil || *plain.RiskScore != 2 || plain.RiskLevel == nil || *plain.RiskLevel != "low" {
+		t.Fatalf("expected low risk of 2, got %v %v", plain.RiskScore, plain.RiskLevel)
+	}
+	if status := validation(plain.ID); status.Sources[0].Source != engine.RequirementDefault || len(status.Risk.Attesters) != 0 {
+		t.Fatalf("expected default preset without attesters: %+v", status)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Auth", "type": "feature", "labels": []string{"Security"}}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create risky task: %d %s", res.StatusCode, string(data))
+	}
+	var risky TaskResponse
+	_ = json.Unmarshal(data, &risky)
+	if !slices.Equal(risky.Labels, []string{"security"}) || !slices.Contains(risky.RequiredAttestations, "security.ok") {
+		t.Fatalf("expected the high preset: %+v", risky)
+	}
+	if risky.RiskScore == nil || *risky.RiskScore != 10 || *risky.RiskLevel != "high" {
+		t.Fatalf("expected high risk of 10, got %v %v", risky.RiskScore, risky.RiskLevel)
+	}
+	status := validation(risky.ID)
+	if status.Risk == nil || len(status.Risk.Factors) != 3 || !slices.Equal(status.Risk.MissingAttesters, []string{"reviewer"}) {
+		t.Fatalf("unexpected risk explanation: %+v", status.Risk)
+	}
+	for _, src := range status.Sources {
+		if src.Source != engine.RequirementRisk || src.Preset != "high" {
+			t.Fatalf("expected risk-routed requirements, got %+v", status.Sources)
+		}
+	}
+
+	for _, kind := range []string{"ci.passed", "review.approved", "security.ok"} {
+		res, data = doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "task", "entity_id": risky.ID, "kind": kind}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("attest %s: %d %s", kind, res.StatusCode, string(data))
+		}
+	}
+	if status = validation(risky.ID); len(status.Missing) != 0 || status.Satisfied {
+		t.Fatalf("expected only the reviewer attester missing: %+v", status)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "task", "entity_id": risky.ID, "kind": "review.approved", "actor_id": "rev-1"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest as reviewer: %d %s", res.StatusCode, string(data))
+	}
+	if status = validation(risky.ID); !status.Satisfied || len(status.Risk.MissingAttesters) != 0 {
+		t.Fatalf("expected satisfied validation: %+v", status)
+	}
+
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/"+plain.ID, map[string]any{"labels": []string{"security"}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("label plain task: %d %s", res.StatusCode, string(data))
+	}
+	_ = json.Unmarshal(data, &plain)
+	if *plain.RiskScore != 7 || *plain.RiskLevel != "high" || slices.Contains(plain.RequiredAttestations, "security.ok") {
+		t.Fatalf("expected rescored task keeping its preset: %+v", plain)
+	}
+	if status = validation(plain.ID); !slices.Equal(status.Risk.MissingAttesters, []string{"reviewer"}) {
+		t.Fatalf("expected the high level attesters: %+v", status.Risk)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	projectID := "workline"
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "dave", "dev"); err != nil {
+		t.Fatalf("grant dev: %v", err)
+	}
+	if _, err := srv.engine.CollectNotifications(ctx, projectID, 0); err != nil {
+		t.Fatalf("start inbox: %v", err)
+	}
+	tasksURL := srv.URL + "/v0/projects/" + projectID + "/tasks"
+
+	res, data := doJSON(t, client, http.MethodPost, tasksURL, map[string]any{"title": "Ping @nobody", "type": "technical"}, nil)
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(data), "@nobody") {
+		t.Fatalf("unknown mention: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, tasksURL, map[string]any{
+		"title":       "Parser",
+		"type":        "technical",
+		"description": "Pair with @dave. Mail dave@example.com, not a mention.",
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create: %d %s", res.StatusCode, string(data))
+	}
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+	res, data = doJSON(t, client, http.MethodPatch, tasksURL+"/"+created.ID, map[string]any{
+		"assignee_id":  "dave",
+		"handoff_note": "@dave: the lexer is done, @tester knows the grammar",
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("handoff: %d %s", res.StatusCode, string(data))
+	}
+
+	evts, err := srv.engine.Repo.LatestEvents(ctx, 10, projectID, "task.mentioned", "task", created.ID)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	got := map[string][]any{}
+	for _, evt := range evts {
+		var p map[string]any
+		_ = json.Unmarshal([]byte(evt.Payload), &p)
+		got[p["source"].(string)] = p["mentioned"].([]any)
+	}
+	if !reflect.DeepEqual(got, map[string][]any{"description": {"dave"}, "handoff_note": {"dave", "tester"}}) {
+		t.Fatalf("mention events: %v", got)
+	}
+	if _, err := srv.engine.CollectNotifications(ctx, projectID, 0); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	inbox, err := srv.engine.Repo.ListNotifications(ctx, projectID, "dave", false, 0, 0)
+	if err != nil || len(inbox) != 2 || !strings.Contains(inbox[0].Message, "handoff note") {
+		t.Fatalf("dave inbox: %+v %v", inbox, err)
+	}
+	if own, _ := srv.engine.Repo.ListNotifications(ctx, projectID, "tester", false, 0, 0); len(own) != 0 {
+		t.Fatalf("self mentions should not notify: %+v", own)
+	}
+}
+
+func TestTeamsGrantRoles(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	teams := srv.URL + "/v0/projects/" + projectID + "/teams"
+
+	res, data := doJSON(t, client, http.MethodPost, teams, map[string]any{"id": "backend", "description": "Backend devs"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create team: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, teams, map[string]any{"id": "backend"}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected duplicate team rejected, got %d %s", res.StatusCode, string(data))
+	}
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Team work",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(taskData, &task)
+	devToken := srv.bearerToken(t, "team-dev", "default-org", time.Now().Add(time.Hour))
+	claim := func() int {
+		res, _ := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/claim", nil, bearerHeader(devToken))
+		return res.StatusCode
+	}
+
+	if res, data := doJSON(t, client, http.MethodPut, teams+"/backend/members/team-dev", nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("add member: %d %s", res.StatusCode, string(data))
+	}
+	if status := claim(); status != http.StatusForbidden {
+		t.Fatalf("expected claim without team role forbidden, got %d", status)
+	}
+	if res, data := doJSON(t, client, http.MethodPost, teams+"/backend/roles", map[string]any{"role_id": "dev"}, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("grant team role: %d %s", res.StatusCode, string(data))
+	}
+	if status := claim(); status != http.StatusOK {
+		t.Fatalf("expected claim through the team role, got %d", status)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, teams+"/backend", nil, nil)
+	var team TeamResponse
+	_ = json.Unmarshal(data, &team)
+	if res.StatusCode != http.StatusOK || !slices.Equal(team.Members, []string{"team-dev"}) || len(team.Roles) != 1 || team.Roles[0].RoleID != "dev" {
+		t.Fatalf("get team: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/rbac/members?cursor=team-de", nil, nil)
+	var members paginatedMembers
+	_ = json.Unmarshal(data, &members)
+	if res.StatusCode != http.StatusOK || len(members.Items) == 0 || members.Items[0].ActorID != "team-dev" ||
+		members.Items[0].Roles[0].TeamID == nil || *members.Items[0].Roles[0].TeamID != "backend" {
+		t.Fatalf("expected the team member listed with its team grant: %d %s", res.StatusCode, string(data))
+	}
+
+	if res, data := doJSON(t, client, http.MethodDelete, teams+"/backend/members/team-dev", nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("remove member: %d %s", res.StatusCode, string(data))
+	}
+	if res, _ := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks", nil, bearerHeader(devToken)); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a removed member to lose the team role, got %d", res.StatusCode)
+	}
+	if res, data := doJSON(t, client, http.MethodDelete, teams+"/backend", nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete team: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPut, teams+"/backend/members/team-dev", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected missing team, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, teams, nil, nil)
+	var list []TeamResponse
+	_ = json.Unmarshal(data, &list)
+	if res.StatusCode != http.StatusOK || len(list) != 0 {
+		t.Fatalf("list teams: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestEscalationRuleEndpoints(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	rules := srv.URL + "/v0/projects/workline/escalation-rules"
+
+	res, data := doJSON(t, client, http.MethodPost, rules, map[string]any{
+		"id": "stale-work", "condition": "task.overdue", "after": "48h", "action": "notify", "role_id": "pm",
+	}, nil)
+	var rule domain.EscalationRule
+	_ = json.Unmarshal(data, &rule)
+	if res.StatusCode != http.StatusCreated || !rule.Enabled || rule.After != "48h" {
+		t.Fatalf("create rule: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, rules, map[string]any{
+		"id": "no-role", "condition": "task.overdue", "after": "48h", "action": "notify",
+	}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected notify without role rejected, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, rules+"/stale-work", map[string]any{"enabled": false, "after": "72h"}, nil)
+	_ = json.Unmarshal(data, &rule)
+	if res.StatusCode != http.StatusOK || rule.Enabled || rule.After != "72h" || rule.RoleID != "pm" {
+		t.Fatalf("update rule: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, rules, nil, nil)
+	var list []domain.EscalationRule
+	_ = json.Unmarshal(data, &list)
+	if res.StatusCode != http.StatusOK || len(list) != 1 || list[0].ID != "stale-work" {
+		t.Fatalf("list rules: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodDelete, rules+"/stale-work", nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete rule: %d %s", res.StatusCode, string(data))
+	}
+	if res, _ := doJSON(t, client, http.MethodGet, rules+"/stale-work", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected deleted rule missing, got %d", res.StatusCode)
+	}
+}
+
+func TestForceRequiresPermission(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Needs force",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(taskData, &task)
+
+	grantRes, grantData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", map[string]any{
+		"actor_id": "force-dev",
+		"role_id":  "dev",
+	}, nil)
+	if grantRes.StatusCode != http.StatusOK && grantRes.StatusCode != http.StatusNoContent {
+		t.Fatalf("grant role: %d %s", grantRes.StatusCode, string(grantData))
+	}
+
+	doneRes, doneData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/done?force=true", map[string]any{
+		"work_outcomes": map[string]any{"note": "force"},
+	}, bearerHeader(srv.bearerToken(t, "force-dev", "default-org", time.Now().Add(time.Hour))))
+	if doneRes.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", doneRes.StatusCode, string(doneData))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(doneData, &apiErr)
+	if apiErr.Error.Code != "forbidden" {
+		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	}
+}
+
+func TestCreateTaskValidation(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", res.StatusCode, string(data))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(data, &apiErr)
+	if apiErr.Error.Code != "bad_request" {
+		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	}
+}
+
+func TestDoneTaskRequiresValidation(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Needs validation",
+		"type":  "feature",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+
+	patchRes, patchBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID, map[string]any{
+		"validation": map[string]any{
+			"require": []string{"ci.passed"},
+		},
+	}, nil)
+	if patchRes.StatusCode != http.StatusOK {
+		t.Fatalf("patch validation: %d %s", patchRes.StatusCode, string(patchBody))
+	}
+
+	claimRes, claimBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/claim", nil, nil)
+	if claimRes.StatusCode != http.StatusOK {
+		t.Fatalf("claim lease: %d %s", claimRes.StatusCode, string(claimBody))
+	}
+
+	doneRes, doneBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/done", map[string]any{
+		"work_outcomes": map[string]any{"note": "testing"},
+	}, nil)
+	if doneRes.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", doneRes.StatusCode, string(doneBody))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(doneBody, &apiErr)
+	if apiErr.Error.Code != "validation_failed" {
+		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/config", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("config status %d: %s", res.StatusCode, string(data))
+	}
+	var cfg ProjectConfigResponse
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("unmarshal config: %v", err)
+	}
+	if len(cfg.Policies.Presets) == 0 || cfg.Policies.Defaults.Task["feature"] == "" {
+		t.Fatalf("config missing presets/defaults: %+v", cfg)
+	}
+	if got := cfg.Transitions.Task["review"]; len(got) != 2 {
+		t.Fatalf("config missing default transitions: %+v", cfg.Transitions)
+	}
+}
+
+func TestConditionalGets(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Cache me",
+		"type":  "technical",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+
+	for _, url := range []string{
+		srv.URL + "/v0/projects/" + projectID + "/tasks/" + created.ID,
+		srv.URL + "/v0/projects/" + projectID + "/tasks/tree",
+		srv.URL + "/v0/projects/" + projectID + "/config",
+	} {
+		res, body := doJSON(t, client, http.MethodGet, url, nil, nil)
+		etag := res.Header.Get("ETag")
+		if res.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("%s: status %d etag %q: %s", url, res.StatusCode, etag, string(body))
+		}
+		res, body = doJSON(t, client, http.MethodGet, url, nil, map[string]string{"If-None-Match": etag})
+		if res.StatusCode != http.StatusNotModified || len(body) != 0 {
+			t.Fatalf("%s: expected 304 without body, got %d: %s", url, res.StatusCode, string(body))
+		}
+		if res.Header.Get("ETag") != etag {
+			t.Fatalf("%s: 304 should repeat etag", url)
+		}
+	}
+
+	taskURL := srv.URL + "/v0/projects/" + projectID + "/tasks/" + created.ID
+	res, _ := doJSON(t, client, http.MethodGet, taskURL, nil, nil)
+	etag := res.Header.Get("ETag")
+	claimRes, claimData := doJSON(t, client, http.MethodPost, taskURL+"/claim", map[string]any{}, nil)
+	if claimRes.StatusCode != http.StatusOK {
+		t.Fatalf("claim: %d %s", claimRes.StatusCode, string(claimData))
+	}
+	patchRes, patchData := doJSON(t, client, http.MethodPatch, taskURL, map[string]any{"status": "in_progress"}, nil)
+	if patchRes.StatusCode != http.StatusOK {
+		t.Fatalf("patch: %d %s", patchRes.StatusCode, string(patchData))
+	}
+	res, body := doJSON(t, client, http.MethodGet, taskURL, nil, map[string]string{"If-None-Match": etag})
+	if res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Fatalf("expected fresh representation after update, got %d: %s", res.StatusCode, string(body))
+	}
+}
+
+func TestResponseCompression(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	for i := range 5 {
+		if _, err := srv.engine.CreateTask(ctx, engine.TaskCreateOptions{ProjectID: "workline", Type: "technical", Title: fmt.Sprintf("Compressed %d", i), ActorID: "tester"}); err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+	}
+
+	get := func(url, encoding string) (*http.Response, []byte) {
+		t.Helper()
+		// Setting Accept-Encoding ourselves keeps the transport from decoding the body.
+		res, data := doJSON(t, srv.Client(), http.MethodGet, url, nil, map[string]string{"Accept-Encoding": encoding})
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", url, res.StatusCode)
+		}
+		if !strings.Contains(res.Header.Get("Vary"), "Accept-Encoding") {
+			t.Fatalf("%s: missing Vary: Accept-Encoding", url)
+		}
+		return res, data
+	}
+	res, data := get(srv.URL+"/v0/projects/workline/tasks", "br;q=1.0, gzip;q=0.5")
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip list, got %q", res.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	var page paginatedTasks
+	if err := json.NewDecoder(zr).Decode(&page); err != nil || len(page.Items) != 5 {
+		t.Fatalf("decode gzip list: %v %d", err, len(page.Items))
+	}
+	res, _ = get(srv.URL+"/v0/projects/workline/tasks", "gzip;q=0")
+	if res.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("gzip;q=0 should disable compression")
+	}
+	res, _ = get(srv.URL+"/v0/health", "gzip")
+	if res.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("small bodies should not be compressed")
+	}
+}
+
+func TestStreamingJSONFormatMatchesDefault(t *testing.T) {
+	tasks := make([]TaskResponse, streamMinItems+1)
+	for i := range tasks {
+		tasks[i] = TaskResponse{ID: fmt.Sprintf("task-%d", i), Title: "<b>streamed</b> & listed", Status: "planned"}
+	}
+	nodes := []treeNode{}
+	for _, task := range tasks {
+		nodes = append(nodes, treeNode{Task: task, Children: []treeNode{}})
+	}
+	for _, body := range []any{
+		&paginatedTasks{Items: tasks, NextCursor: "next"},
+		&paginatedTasks{Items: tasks[:3]},
+		nodes,
+		itemsBody[treeNode]{Items: nodes},
+	} {
+		var want, got bytes.Buffer
+		if err := huma.DefaultJSONFormat.Marshal(&want, body); err != nil {
+			t.Fatalf("default marshal: %v", err)
+		}
+		if err := streamingJSONFormat.Marshal(&got, body); err != nil {
+			t.Fatalf("streaming marshal: %v", err)
+		}
+		if got.String() != want.String() {
+			t.Fatalf("streaming output differs for %T:\n%s\n%s", body, got.String(), want.String())
+		}
+	}
+}
+
+func TestPageLimitGuard(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/workline/tasks?limit=5000", nil, nil)
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for oversized page, got %d: %s", res.StatusCode, string(data))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(data, &apiErr)
+	if apiErr.Error.Code != "query_budget_exceeded" || apiErr.Error.Details["guard"] != "limit" {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+}
+
+func TestTaskHandoffs(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title":       "Relay",
+		"type":        "technical",
+		"assignee_id": "agent-a",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+	taskURL := srv.URL + "/v0/projects/" + projectID + "/tasks/" + created.ID
+
+	res, data := doJSON(t, client, http.MethodPatch, taskURL, map[string]any{
+		"assignee_id":  "agent-b",
+		"handoff_note": "parser done; tokenizer edge cases left in TODO.md",
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("reassign: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, taskURL, map[string]any{"handoff_note": "no change"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected note without reassignment to be rejected, got %d: %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, taskURL, map[string]any{"assignee_id": nil}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unassign: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, taskURL+"/assignees", map[string]any{
+		"actor_id":     "agent-c",
+		"role":         "reviewer",
+		"handoff_note": "check the error paths",
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("add reviewer: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodDelete, taskURL+"/assignees/agent-c?role=reviewer", nil, nil)
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("remove reviewer: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, taskURL+"/handoffs", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("handoffs: %d %s", res.StatusCode, string(data))
+	}
+	var handoffs []TaskHandoffResponse
+	if err := json.Unmarshal(data, &handoffs); err != nil {
+		t.Fatalf("unmarshal handoffs: %v", err)
+	}
+	if len(handoffs) != 5 {
+		t.Fatalf("expected 5 handoffs, got %s", string(data))
+	}
+	if initial := handoffs[0]; initial.FromAssigneeID != nil || initial.ToAssigneeID == nil || *initial.ToAssigneeID != "agent-a" {
+		t.Fatalf("unexpected handoff on create: %+v", initial)
+	}
+	first, second := handoffs[1], handoffs[2]
+	if first.FromAssigneeID == nil || *first.FromAssigneeID != "agent-a" || first.ToAssigneeID == nil || *first.ToAssigneeID != "agent-b" || first.Note == "" || first.ActorID != "tester" {
+		t.Fatalf("unexpected first handoff: %+v", first)
+	}
+	if second.FromAssigneeID == nil || *second.FromAssigneeID != "agent-b" || second.ToAssigneeID != nil {
+		t.Fatalf("unexpected second handoff: %+v", second)
+	}
+	added, removed := handoffs[3], handoffs[4]
+	if added.FromAssigneeID != nil || added.ToAssigneeID == nil || *added.ToAssigneeID != "agent-c" || added.Note != "check the error paths" {
+		t.Fatalf("unexpected handoff on assign: %+v", added)
+	}
+	if removed.FromAssigneeID == nil || *removed.FromAssigneeID != "agent-c" || removed.ToAssigneeID != nil {
+		t.Fatalf("unexpected handoff on unassign: %+v", removed)
+	}
+}
+
+func TestAttestationPayloadOffload(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	srv.engine.Config.Payloads.InlineMaxBytes = 64
+	srv.engine.Config.Payloads.MaxBytes = 1024
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Big report",
+		"type":  "technical",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+
+	report := map[string]any{"suite": "unit", "log": strings.Repeat("ok ", 100)}
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
+		"entity_kind": "task",
+		"entity_id":   task.ID,
+		"kind":        "ci.passed",
+		"payload":     report,
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest: %d %s", res.StatusCode, string(data))
+	}
+	var att AttestationResponse
+	_ = json.Unmarshal(data, &att)
+	digest, _ := att.Payload["$blob"].(string)
+	if !blob.ValidDigest(digest) {
+		t.Fatalf("expected offloaded payload reference, got %+v", att.Payload)
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/blobs/"+digest, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get blob: %d %s", res.StatusCode, string(data))
+	}
+	var fetched map[string]any
+	if err := json.Unmarshal(data, &fetched); err != nil || fetched["log"] != report["log"] {
+		t.Fatalf("unexpected blob content: %s", string(data))
+	}
+	res, _ = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/blobs/"+blob.Digest([]byte("other")), nil, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected unreferenced blob to be hidden, got %d", res.StatusCode)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
+		"entity_kind": "task",
+		"entity_id":   task.ID,
+		"kind":        "ci.passed",
+		"payload":     map[string]any{"log": strings.Repeat("x", 2048)},
+	}, nil)
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for oversized payload, got %d: %s", res.StatusCode, string(data))
+	}
+
+	ctx := context.Background()
+	secret, err := srv.engine.Blobs.Put(ctx, []byte(`{"secret":true}`))
+	if err != nil {
+		t.Fatalf("put blob: %v", err)
+	}
+	attest := func(payload map[string]any, headers map[string]string) (*http.Response, []byte) {
+		return doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
+			"entity_kind": "task", "entity_id": task.ID, "kind": "ci.passed", "payload": payload,
+		}, headers)
+	}
+	if res, data := attest(map[string]any{"$blob": secret, "bytes": 15}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a forged $blob payload to be rejected, got %d: %s", res.StatusCode, string(data))
+	}
+	if res, data := attest(map[string]any{"note": secret}, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest mentioning a digest: %d %s", res.StatusCode, string(data))
+	}
+	res, _ = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/blobs/"+secret, nil, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a digest merely mentioned in a payload to stay hidden, got %d", res.StatusCode)
+	}
+	countBlobs := func() int {
+		n := 0
+		_ = filepath.WalkDir(srv.engine.Blobs.(blob.FS).Dir, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				n++
+			}
+			return nil
+		})
+		return n
+	}
+	before := countBlobs()
+	stranger := bearerHeader(srv.bearerToken(t, "stranger", "", time.Now().Add(time.Hour)))
+	if res, data := attest(map[string]any{"log": strings.Repeat("no ", 100)}, stranger); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a caller without attestation.add, got %d: %s", res.StatusCode, string(data))
+	}
+	if after := countBlobs(); after != before {
+		t.Fatalf("expected a refused attestation to write no blob, got %d blobs instead of %d", after, before)
+	}
+}
+
+func TestBulkAttestations(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	var taskIDs []string
+	for _, title := range []string{"lint me", "test me"} {
+		res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{"title": title, "type": "technical"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		taskIDs = append(taskIDs, task.ID)
+	}
+	items := []map[string]any{
+		{"entity_kind": "task", "entity_id": taskIDs[0], "kind": "ci.passed", "payload": map[string]any{"job": "lint"}},
+		{"entity_kind": "task", "entity_id": taskIDs[1], "kind": "ci.passed"},
+		{"entity_kind": "task", "entity_id": taskIDs[1], "kind": "no.such.kind"},
+	}
+	count := func() int {
+		res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/attestations?entity_kind=task", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("list: %d %s", res.StatusCode, string(data))
+		}
+		var list paginatedAttestations
+		_ = json.Unmarshal(data, &list)
+		return len(list.Items)
+	}
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations/bulk", map[string]any{"atomic": true, "items": items}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("atomic bulk: %d %s", res.StatusCode, string(data))
+	}
+	var out BulkAttestationResponse
+	_ = json.Unmarshal(data, &out)
+	if out.Committed || out.Failed != 1 || out.Items[0].Status != "rolled_back" || out.Items[2].Status != "failed" || out.Items[2].Error == nil {
+		t.Fatalf("unexpected atomic outcome: %s", string(data))
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("expected atomic failure to write nothing, found %d attestations", n)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations/bulk", map[string]any{"items": items}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("bulk: %d %s", res.StatusCode, string(data))
+	}
+	out = BulkAttestationResponse{}
+	_ = json.Unmarshal(data, &out)
+	if !out.Committed || out.Created != 2 || out.Failed != 1 || out.Items[0].Attestation == nil || out.Items[2].HTTPStatus == 0 {
+		t.Fatalf("unexpected outcome: %s", string(data))
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("expected 2 attestations, found %d", n)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations/bulk", map[string]any{"items": []any{}}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected empty bulk to be rejected, got %d: %s", res.StatusCode, string(data))
+	}
+}
+
+func TestArtifacts(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	upload := func(name, contentType string, body []byte) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/artifacts?name="+name, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Api-Key", "test-api-key")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res, data
+	}
+
+	log := []byte("=== RUN TestAll\n--- PASS: TestAll\n")
+	res, data := upload("unit.log", "text/plain", log)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: %d %s", res.StatusCode, string(data))
+	}
+	var art ArtifactResponse
+	_ = json.Unmarshal(data, &art)
+	if art.Size != int64(len(log)) || art.MediaType != "text/plain" || art.Ref["$artifact"] != art.ID || art.Digest != blob.Digest(log) {
+		t.Fatalf("unexpected artifact: %+v", art)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/artifacts/"+art.ID+"/content", nil, nil)
+	if res.StatusCode != http.StatusOK || !bytes.Equal(data, log) || res.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("content: %d %q %s", res.StatusCode, res.Header.Get("Content-Type"), string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/artifacts", nil, nil)
+	var list paginatedArtifacts
+	_ = json.Unmarshal(data, &list)
+	if res.StatusCode != http.StatusOK || len(list.Items) != 1 || list.Items[0].ID != art.ID {
+		t.Fatalf("list: %d %s", res.StatusCode, string(data))
+	}
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Evidence",
+		"type":  "technical",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	attest := func(ref string) (*http.Response, []byte) {
+		return doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
+			"entity_kind": "task",
+			"entity_id":   task.ID,
+			"kind":        "ci.passed",
+			"payload":     map[string]any{"logs": []any{map[string]any{"$artifact": ref}}},
+		}, nil)
+	}
+	if res, data := attest(art.ID); res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest with artifact: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := attest("missing"); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected unknown artifact citation to be rejected, got %d: %s", res.StatusCode, string(data))
+	}
+
+	srv.engine.Config.Payloads.ArtifactMaxBytes = 8
+	if res, data := upload("big.log", "text/plain", log); res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for oversized artifact, got %d: %s", res.StatusCode, string(data))
+	}
+	if res, data := upload("a%2Fb", "text/plain", []byte("x")); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid name to be rejected, got %d: %s", res.StatusCode, string(data))
+	}
+
+	ctx := context.Background()
+	refused := []byte("refused")
+	_, err := srv.engine.UploadArtifact(ctx, engine.ArtifactUploadOptions{ProjectID: projectID, Name: "x.log", Data: refused, ActorID: "stranger"})
+	if err == nil || !strings.Contains(err.Error(), "artifact.upload") {
+		t.Fatalf("expected artifact.upload to be required, got %v", err)
+	}
+	if _, err := srv.engine.Blobs.Get(ctx, blob.Digest(refused)); err == nil {
+		t.Fatalf("expected a refused upload to write no blob")
+	}
+}
+
+func TestSeedEndpoint(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	seedYAML, err := os.ReadFile("../../seed.example.yml")
+	if err != nil {
+		t.Fatalf("read seed: %v", err)
+	}
+	post := func(body []byte) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/seed", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/yaml")
+		req.Header.Set("X-Api-Key", "test-api-key")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res, data
+	}
+	res, data := post(seedYAML)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("seed status %d: %s", res.StatusCode, string(data))
+	}
+	var out SeedResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal seed: %v", err)
+	}
+	if len(out.Tasks) != 4 || len(out.Actors) != 3 || len(out.Roles) != 1 {
+		t.Fatalf("unexpected seed result: %s", string(data))
+	}
+	var tokens TaskResponse
+	for _, tk := range out.Tasks {
+		if tk.Title == "Token storage" {
+			tokens = tk
+		}
+	}
+	if tokens.ParentID == nil || len(tokens.DependsOn) != 1 || len(tokens.RequiredAttestations) != 3 {
+		t.Fatalf("seeded task missing parent/deps/policy: %+v", tokens)
+	}
+
+	cfgRes, cfgData := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/config", nil, nil)
+	if cfgRes.StatusCode != http.StatusOK {
+		t.Fatalf("config status %d", cfgRes.StatusCode)
+	}
+	var cfg ProjectConfigResponse
+	_ = json.Unmarshal(cfgData, &cfg)
+	if _, ok := cfg.Attestations.Catalog["security.countersign"]; !ok {
+		t.Fatalf("seeded kind missing from config")
+	}
+	if _, ok := cfg.Policies.Presets["secure"]; !ok {
+		t.Fatalf("seeded preset missing from config")
+	}
+
+	bad, _ := post([]byte("actors:\n  - id: dave\n    roles: [wizard]\n"))
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected unknown role to be rejected, got %d", bad.StatusCode)
+	}
+	if res, data := post([]byte("roles:\n  security-lead:\n    permissions: [task.read]\n")); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected re-declaring a role's own permissions to pass, got %d: %s", res.StatusCode, string(data))
+	}
+	res, data = post([]byte("roles:\n  observer:\n    permissions: [rbac.manage]\n"))
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(data), "observer") {
+		t.Fatalf("expected widening an existing role to be rejected, got %d: %s", res.StatusCode, string(data))
+	}
+}
+
+func TestSyncEndpoint(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+
+	manifestYAML, err := os.ReadFile("../../sync.example.yml")
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	post := func(query string, body []byte) (*http.Response, engine.SyncPlan, []byte) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/projects/workline/sync"+query, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/yaml")
+		req.Header.Set("X-Api-Key", "test-api-key")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		var plan engine.SyncPlan
+		_ = json.Unmarshal(data, &plan)
+		return res, plan, data
+	}
+	res, plan, data := post("?dry_run=true", manifestYAML)
+	if res.StatusCode != http.StatusOK || !plan.DryRun || plan.Applied || len(plan.Changes) != 7 {
+		t.Fatalf("dry run %d: %s", res.StatusCode, string(data))
+	}
+	if res, _ := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/workline/tasks/auth-login", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("dry run created a task: %d", res.StatusCode)
+	}
+	res, plan, data = post("", manifestYAML)
+	if res.StatusCode != http.StatusOK || !plan.Applied || len(plan.Changes) != 7 {
+		t.Fatalf("sync %d: %s", res.StatusCode, string(data))
+	}
+	taskRes, taskData := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/workline/tasks/auth-tokens", nil, nil)
+	var tokens TaskResponse
+	_ = json.Unmarshal(taskData, &tokens)
+	if taskRes.StatusCode != http.StatusOK || tokens.ParentID == nil || *tokens.ParentID != "auth" || len(tokens.DependsOn) != 1 {
+		t.Fatalf("unexpected synced task %d: %s", taskRes.StatusCode, string(taskData))
+	}
+	if res, plan, data := post("", manifestYAML); res.StatusCode != http.StatusOK || plan.Applied || len(plan.Changes) != 0 {
+		t.Fatalf("expected an in-sync project, got %d: %s", res.StatusCode, string(data))
+	}
+
+	if bad, _, _ := post("", []byte("tasks:\n  - title: no id\n")); bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a task without id rejected, got %d", bad.StatusCode)
+	}
+	if bad, _, _ := post("", []byte("tasks:\n  - id: orphan\n    title: Orphan\n    depends_on: [missing]\n")); bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown dependency rejected, got %d", bad.StatusCode)
+	}
+}
+
+func TestConfigPlanEndpoint(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+
+	bugRes, bugData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/workline/tasks", map[string]any{"title": "Crash on save", "type": "bug"}, nil)
+	if bugRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create bug: %d %s", bugRes.StatusCode, string(bugData))
+	}
+	var bug TaskResponse
+	_ = json.Unmarshal(bugData, &bug)
+
+	next := config.Default("workline")
+	next.Policies.Presets["done.bugfix"] = config.PolicyPreset{Require: []string{"ci.passed", "review.approved", "security.ok"}}
+	next.Policies.Presets["strict"] = config.PolicyPreset{Require: []string{"ci.passed", "security.ok"}}
+	next.Policies.Defaults.Task["chore"] = "strict"
+	// An empty transitions table would forbid every status change, so spell out the default.
+	next.Transitions.Task = config.DefaultTaskTransitions
+	body, err := yaml.Marshal(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(method, path string, body []byte) (*http.Response, engine.ConfigPlan, []byte) {
+		req, err := http.NewRequest(method, srv.URL+"/v0/projects/workline"+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/yaml")
+		req.Header.Set("X-Api-Key", "test-api-key")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		var plan engine.ConfigPlan
+		_ = json.Unmarshal(data, &plan)
+		return res, plan, data
+	}
+
+	res, plan, data := send(http.MethodPost, "/config/plan", body)
+	if res.StatusCode != http.StatusOK || plan.Applied {
+		t.Fatalf("plan %d: %s", res.StatusCode, string(data))
+	}
+	want := []engine.ConfigChange{
+		{Section: "preset", Name: "done.bugfix", Action: "changed", Added: []string{"security.ok"}},
+		{Section: "preset", Name: "strict", Action: "added", Added: []string{"ci.passed", "security.ok"}},
+		{Section: "task_default", Name: "chore", Action: "changed", From: "low", To: "strict"},
+	}
+	if !reflect.DeepEqual(plan.Changes, want) {
+		t.Fatalf("unexpected changes: %s", string(data))
+	}
+	if len(plan.AffectedTasks) != 1 || plan.AffectedTasks[0].TaskID != bug.ID || !strings.Contains(plan.AffectedTasks[0].Reasons[0], "done.bugfix") {
+		t.Fatalf("expected the open bug reported, got %s", string(data))
+	}
+
+	res, plan, data = send(http.MethodPut, "/config", body)
+	if res.StatusCode != http.StatusOK || !plan.Applied || len(plan.Changes) != 3 {
+		t.Fatalf("update config %d: %s", res.StatusCode, string(data))
+	}
+	if res, plan, data := send(http.MethodPost, "/config/plan", body); res.StatusCode != http.StatusOK || len(plan.Changes) != 0 {
+		t.Fatalf("expected the stored config to match, got %d: %s", res.StatusCode, string(data))
+	}
+	if res, _, _ := send(http.MethodPost, "/config/plan", []byte("project:\n  id: other\n")); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected another project's config rejected, got %d", res.StatusCode)
+	}
+}
+
+func TestValidationEndpoint(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Validate me",
+		"type":  "feature",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+
+	patchRes, patchBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID, map[string]any{
+		"validation": map[string]any{
+			"require": []string{"ci.passed", "review.approved"},
+		},
+	}, nil)
+	if patchRes.StatusCode != http.StatusOK {
+		t.Fatalf("patch validation: %d %s", patchRes.StatusCode, string(patchBody))
+	}
+
+	attRes, attBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
+		"entity_kind": "task",
+		"entity_id":   task.ID,
+		"kind":        "ci.passed",
+	}, nil)
+	if attRes.StatusCode != http.StatusCreated {
+		t.Fatalf("attestation status %d: %s", attRes.StatusCode, string(attBody))
+	}
+
+	valRes, valBody := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/validation", nil, nil)
+	if valRes.StatusCode != http.StatusOK {
+		t.Fatalf("validation status %d: %s", valRes.StatusCode, string(valBody))
+	}
+	var status ValidationStatusResponse
+	if err := json.Unmarshal(valBody, &status); err != nil {
+		t.Fatalf("unmarshal validation: %v", err)
+	}
+	if status.Satisfied {
+		t.Fatalf("expected validation to be unsatisfied")
+	}
+	if len(status.Present) != 1 || len(status.Missing) != 1 {
+		t.Fatalf("unexpected present/missing: %+v", status)
+	}
+}
+
+func TestValidationRequirementSources(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	create := func(body map[string]any) TaskResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		return task
+	}
+	sources := func(taskID string) map[string]engine.RequirementSource {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/tasks/"+taskID+"/validation", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("validation status: %d %s", res.StatusCode, string(data))
+		}
+		var status ValidationStatusResponse
+		_ = json.Unmarshal(data, &status)
+		if len(status.Sources) != len(status.Required) {
+			t.Fatalf("expected a source per requirement: %s", string(data))
+		}
+		bySource := map[string]engine.RequirementSource{}
+		for i, src := range status.Sources {
+			if src.Requirement != status.Required[i] {
+				t.Fatalf("sources out of order: %s", string(data))
+			}
+			bySource[src.Requirement] = src
+		}
+		return bySource
+	}
+
+	bug := create(map[string]any{"title": "Crash on save", "type": "bug"})
+	got := sources(bug.ID)
+	if src := got["review.approved"]; src.Source != engine.RequirementDefault || src.Preset != "done.bugfix" || src.ActorID != "tester" || src.SetAt == "" {
+		t.Fatalf("expected the bug default preset, got %+v", src)
+	}
+
+	feature := create(map[string]any{"title": "Payments", "type": "feature", "policy": map[string]any{"preset": "high"}})
+	if src := sources(feature.ID)["security.ok"]; src.Source != engine.RequirementPreset || src.Preset != "high" {
+		t.Fatalf("expected the explicit preset, got %+v", src)
+	}
+
+	res, data := doJSON(t, client, http.MethodPatch, base+"/tasks/"+bug.ID, map[string]any{
+		"validation": map[string]any{"require": []string{"ci.passed", "review.approved", "security.ok"}},
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("override: %d %s", res.StatusCode, string(data))
+	}
+	got = sources(bug.ID)
+	if src := got["security.ok"]; src.Source != engine.RequirementOverride || src.Preset != "" || src.ActorID != "tester" {
+		t.Fatalf("expected security.ok from the override, got %+v", src)
+	}
+	if src := got["ci.passed"]; src.Source != engine.RequirementDefault || src.Preset != "done.bugfix" {
+		t.Fatalf("entries the override kept should keep their source, got %+v", src)
+	}
+
+	chore := create(map[string]any{"title": "Tidy up", "type": "chore", "validation": map[string]any{"require": []string{}}})
+	if got := sources(chore.ID); len(got) != 0 {
+		t.Fatalf("expected no sources without requirements, got %+v", got)
+	}
+}
+
+func TestWorkOutcomesAppendEndpoint(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Work outcomes append",
+		"type":  "docs",
+	}, nil)
+	if createRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+
+	appendRes, appendBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/work-outcomes/append", map[string]any{
+		"path":  "actions",
+		"value": map[string]any{"note": "first"},
+	}, nil)
+	if appendRes.StatusCode != http.StatusOK {
+		t.Fatalf("append work outcomes: %d %s", appendRes.StatusCode, string(appendBody))
+	}
+	var appendResp WorkOutcomesUpdateResponse
+	if err := json.Unmarshal(appendBody, &appendResp); err != nil {
+		t.Fatalf("unmarshal append response: %v", err)
+	}
+	if appendResp.Path != "actions" || appendResp.Length == nil || *appendResp.Length != 1 {
+		t.Fatalf("unexpected append response: %+v", appendResp)
+	}
+
+	getRes, getBody := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID, nil, nil)
+	if getRes.StatusCode != http.StatusOK {
+		t.Fatalf("get task: %d %s", getRes.StatusCode, string(getBody))
+	}
+	var updated TaskResponse
+	if err := json.Unmarshal(getBody, &updated); err != nil {
+		t.Fatalf("unmarshal task: %v", err)
+	}
+	actions, ok := updated.WorkOutcomes["actions"].([]any)
+	if !ok || len(actions) != 1 {
+		t.Fatalf("expected one action in work_outcomes, got %+v", updated.WorkOutcomes)
+	}
+}
+
+func TestPaginationProvidesCursor(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	for i := 0; i < 3; i++ {
+		res, body := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+			"title": fmt.Sprintf("Task %d", i),
+			"type":  "technical",
+		}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(body))
+		}
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks?limit=1", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list tasks: %d %s", res.StatusCode, string(data))
+	}
+	var page paginatedTasks
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("unmarshal page: %v", err)
+	}
+	if len(page.Items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(page.Items))
+	}
+	if page.NextCursor == "" {
+		t.Fatalf("expected next_cursor to be set")
+	}
+}
+
+func TestActorActivityFeed(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	for i := 0; i < 3; i++ {
+		res, body := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+			"title": fmt.Sprintf("Activity %d", i),
+			"type":  "technical",
+		}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(body))
+		}
+	}
+
+	url := srv.URL + "/v0/projects/" + projectID + "/actors/tester/activity?limit=2"
+	seen := map[int64]bool{}
+	var total int
+	for url != "" {
+		res, data := doJSON(t, client, http.MethodGet, url, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("activity: %d %s", res.StatusCode, string(data))
+		}
+		var page ActorActivityResponse
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatalf("unmarshal activity: %v", err)
+		}
+		if page.Summary.TasksCreated != 3 {
+			t.Fatalf("expected 3 tasks created, got %d", page.Summary.TasksCreated)
+		}
+		total = 0
+		for _, n := range page.Counts {
+			total += n
+		}
+		for _, item := range page.Items {
+			if item.ActorID != "tester" {
+				t.Fatalf("unexpected actor %s in feed", item.ActorID)
+			}
+			if seen[item.ID] {
+				t.Fatalf("event %d returned twice", item.ID)
+			}
+			seen[item.ID] = true
+		}
+		url = ""
+		if page.NextCursor != "" {
+			url = srv.URL + "/v0/projects/" + projectID + "/actors/tester/activity?limit=2&cursor=" + page.NextCursor
+		}
+	}
+	if len(seen) != total {
+		t.Fatalf("expected %d events across pages, got %d", total, len(seen))
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/actors/tester/activity?since=yesterday", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid since, got %d: %s", res.StatusCode, string(data))
+	}
+}
+
+func TestActorActivityCountsCompletionsThroughUpdate(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"title": "Finish by update",
+		"type":  "technical",
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	var created TaskResponse
+	_ = json.Unmarshal(data, &created)
+	for i := 0; i < 2; i++ {
+		if _, err := srv.engine.UpdateTask(ctx, engine.TaskUpdateOptions{ID: created.ID, ActorID: "tester", Status: "done", Force: true}); err != nil {
+			t.Fatalf("complete through update: %v", err)
+		}
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/actors/tester/activity", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("activity: %d %s", res.StatusCode, string(data))
+	}
+	var page ActorActivityResponse
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("unmarshal activity: %v", err)
+	}
+	if page.Summary.TasksCompleted != 1 {
+		t.Fatalf("expected 1 task completed, got %d", page.Summary.TasksCompleted)
+	}
+}
+
+func TestIntegrationWebhooks(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+
+	t.Setenv("WL_TEST_GITLAB_SECRET", "gl-secret")
+	t.Setenv("WL_TEST_BITBUCKET_SECRET", "bb-secret")
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, projectID)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Integrations = map[string]config.Integration{
+		"gitlab": {
+			SecretEnv: "WL_TEST_GITLAB_SECRET",
+			ActorID:   "ci-bot",
+			Kinds:     map[string]string{"pipeline.success": "ci.passed"},
+		},
+		"bitbucket": {
+			SecretEnv: "WL_TEST_BITBUCKET_SECRET",
+			ActorID:   "ci-bot",
+			Kinds:     map[string]string{"pullrequest.approved": "review.approved"},
+		},
+	}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	for _, role := range []string{"dev", "reviewer"} {
+		res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", map[string]any{
+			"actor_id": "ci-bot",
+			"role_id":  role,
+		}, nil)
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+			t.Fatalf("grant %s: %d %s", role, res.StatusCode, string(data))
+		}
+	}
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":    "task-hook",
+		"title": "Webhook target",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+
+	post := func(provider string, body []byte, headers map[string]string) (int, WebhookResponse, string) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/integrations/"+provider+"/webhook", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		var out WebhookResponse
+		_ = json.Unmarshal(data, &out)
+		return res.StatusCode, out, string(data)
+	}
+
+	pipeline := []byte(`{"object_attributes":{"id":7,"ref":"feature/wl:task-hook","status":"success"},"commit":{"message":"fix"}}`)
+	if status, _, body := post("gitlab", pipeline, map[string]string{"X-Gitlab-Event": "Pipeline Hook", "X-Gitlab-Token": "wrong"}); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad gitlab token, got %d: %s", status, body)
+	}
+	status, out, body := post("gitlab", pipeline, map[string]string{"X-Gitlab-Event": "Pipeline Hook", "X-Gitlab-Token": "gl-secret"})
+	if status != http.StatusOK || out.Ignored || len(out.Attestations) != 1 {
+		t.Fatalf("gitlab pipeline: %d %s", status, body)
+	}
+	if att := out.Attestations[0]; att.Kind != "ci.passed" || att.EntityID != "task-hook" || att.ActorID != "ci-bot" {
+		t.Fatalf("unexpected attestation %+v", att)
+	}
+
+	// A delivery naming several tasks records all their attestations or none.
+	taskRes, taskData = doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":    "task-hook-2",
+		"title": "Second webhook target",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	if _, err := srv.engine.DB.ExecContext(ctx, `CREATE TRIGGER refuse_hook_2 BEFORE INSERT ON attestations WHEN NEW.entity_id='task-hook-2' BEGIN SELECT RAISE(ABORT, 'refused'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	both := []byte(`{"object_attributes":{"id":8,"ref":"feature/wl:task-hook","status":"success"},"commit":{"message":"fix wl:task-hook-2"}}`)
+	hookCount := func() int {
+		t.Helper()
+		atts, err := srv.engine.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: projectID, EntityKind: "task", EntityID: "task-hook", Kind: "ci.passed"})
+		if err != nil {
+			t.Fatalf("list attestations: %v", err)
+		}
+		return len(atts)
+	}
+	if status, _, body := post("gitlab", both, map[string]string{"X-Gitlab-Event": "Pipeline Hook", "X-Gitlab-Token": "gl-secret"}); status < http.StatusInternalServerError {
+		t.Fatalf("expected the failing delivery refused, got %d: %s", status, body)
+	}
+	if n := hookCount(); n != 1 {
+		t.Fatalf("expected the failed delivery to leave no attestation, got %d", n)
+	}
+	if _, err := srv.engine.DB.ExecContext(ctx, `DROP TRIGGER refuse_hook_2`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	status, out, body = post("gitlab", both, map[string]string{"X-Gitlab-Event": "Pipeline Hook", "X-Gitlab-Token": "gl-secret"})
+	if status != http.StatusOK || len(out.Attestations) != 2 {
+		t.Fatalf("retried delivery: %d %s", status, body)
+	}
+	if n := hookCount(); n != 2 {
+		t.Fatalf("expected the retry to attest task-hook once more, got %d", n)
+	}
+
+	pr := []byte(`{"pullrequest":{"id":3,"title":"Add hook wl#task-hook","source":{"branch":{"name":"hook"}}}}`)
+	mac := hmac.New(sha256.New, []byte("bb-secret"))
+	mac.Write(pr)
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	status, out, body = post("bitbucket", pr, map[string]string{"X-Event-Key": "pullrequest:approved", "X-Hub-Signature": sig})
+	if status != http.StatusOK || len(out.Attestations) != 1 || out.Attestations[0].Kind != "review.approved" {
+		t.Fatalf("bitbucket approval: %d %s", status, body)
+	}
+	status, out, body = post("bitbucket", pr, map[string]string{"X-Event-Key": "pullrequest:created", "X-Hub-Signature": sig})
+	if status != http.StatusOK || !out.Ignored {
+		t.Fatalf("expected unmapped event to be ignored: %d %s", status, body)
+	}
+}
+
+func TestProjectSecretRotation(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	ctx := context.Background()
+	base := srv.URL + "/v0/projects/workline"
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, "workline")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Integrations = map[string]config.Integration{"gitlab": {ActorID: "ci-bot", Kinds: map[string]string{"pipeline.success": "ci.passed"}}}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, "workline", cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	rotate := func(body map[string]any) engine.SecretRotation {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/secrets/rotate", body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("rotate %v: %d %s", body, res.StatusCode, string(data))
+		}
+		var out engine.SecretRotation
+		_ = json.Unmarshal(data, &out)
+		return out
+	}
+	hook := func(token string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, base+"/integrations/gitlab/webhook", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gitlab-Event", "Push Hook")
+		req.Header.Set("X-Gitlab-Token", token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	first := rotate(map[string]any{"kind": "webhook", "name": "gitlab"})
+	if first.Secret.Version != 1 || first.Value == "" || hook(first.Value) != http.StatusOK || hook("wrong") != http.StatusUnauthorized {
+		t.Fatalf("unexpected first webhook secret: %+v", first)
+	}
+	second := rotate(map[string]any{"kind": "webhook", "name": "gitlab", "overlap": "1h"})
+	if second.Secret.Version != 2 || second.Superseded != 1 || hook(first.Value) != http.StatusOK || hook(second.Value) != http.StatusOK {
+		t.Fatalf("expected both versions accepted during the overlap: %+v", second)
+	}
+	third := rotate(map[string]any{"kind": "webhook", "name": "gitlab", "overlap": "0s"})
+	if hook(first.Value) != http.StatusUnauthorized || hook(second.Value) != http.StatusUnauthorized || hook(third.Value) != http.StatusOK {
+		t.Fatalf("expected only the newest version after a zero overlap")
+	}
+	evts, err := srv.engine.Repo.LatestEvents(ctx, 10, "workline", "secret.rotated", "", "")
+	if err != nil || len(evts) != 2 || strings.Contains(evts[0].Payload, third.Value) {
+		t.Fatalf("expected two secret.rotated events without secrets: %+v %v", evts, err)
+	}
+
+	token := rotate(map[string]any{"kind": "api_token", "name": "tester"})
+	me := func() int {
+		res, _ := doJSON(t, client, http.MethodGet, base+"/me/permissions", nil, map[string]string{"X-Api-Key": token.Value, "Authorization": ""})
+		return res.StatusCode
+	}
+	if me() != http.StatusOK {
+		t.Fatalf("expected the project token to authenticate")
+	}
+	// Tokens stay in their project and cannot be issued for other existing actors.
+	if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects", map[string]any{"id": "elsewhere"}, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("create project: %d %s", res.StatusCode, string(data))
+	}
+	tokenHeaders := map[string]string{"X-Api-Key": token.Value, "Authorization": ""}
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/elsewhere/tasks", nil, tokenHeaders); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the token to be refused in another project: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects", map[string]any{"id": "from-token"}, tokenHeaders); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the token to hold no global permission: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodGet, base+"/tasks", nil, tokenHeaders); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the token to work in its project: %d %s", res.StatusCode, string(data))
+	}
+	if err := srv.engine.GrantRole(ctx, "workline", "tester", "alice", "observer"); err != nil {
+		t.Fatalf("grant observer: %v", err)
+	}
+	for _, name := range []string{"alice", "bot"} {
+		if res, data := doJSON(t, client, http.MethodPost, base+"/secrets/rotate", map[string]any{"kind": "api_token", "name": name}, nil); res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for a token named %s: %d %s", name, res.StatusCode, string(data))
+		}
+	}
+	bot := rotate(map[string]any{"kind": "api_token", "name": "svc-bot"})
+	if res, data := doJSON(t, client, http.MethodGet, base+"/me/permissions", nil, map[string]string{"X-Api-Key": bot.Value, "Authorization": ""}); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the service token to authenticate: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/elsewhere/secrets/rotate", map[string]any{"kind": "api_token", "name": "svc-bot"}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected another project to be refused the service actor: %d %s", res.StatusCode, string(data))
+	}
+	res, data := doJSON(t, client, http.MethodPost, base+"/secrets/"+token.Secret.ID+"/revoke", nil, nil)
+	if res.StatusCode != http.StatusOK || me() != http.StatusUnauthorized {
+		t.Fatalf("revoke: %d %s", res.StatusCode, string(data))
+	}
+
+	signing := rotate(map[string]any{"kind": "signing"})
+	if signing.Value != "" || signing.Secret.PublicKey == "" {
+		t.Fatalf("signing keys must stay on the server: %+v", signing)
+	}
+	task, err := srv.engine.CreateTask(ctx, engine.TaskCreateOptions{ProjectID: "workline", Title: "Signed", ActorID: "tester"})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/tasks/"+task.ID+"/evidence", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("evidence: %d %s", res.StatusCode, string(data))
+	}
+	if sig, err := evidence.Verify(data); err != nil || sig.KeyID != signing.Secret.KeyID {
+		t.Fatalf("expected bundle signed with the project key %s: %+v %v", signing.Secret.KeyID, sig, err)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v1/projects/workline/secrets", nil, nil)
+	if res.StatusCode != http.StatusOK || strings.Contains(string(data), third.Value) || strings.Count(string(data), `"kind":`) != 6 {
+		t.Fatalf("list secrets: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/secrets/rotate", map[string]any{"kind": "webhook", "name": "github"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown provider, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestTaskLinkStatusSync(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+
+	t.Setenv("WL_TEST_GITLAB_SECRET", "gl-secret")
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, projectID)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Integrations = map[string]config.Integration{
+		"gitlab": {SecretEnv: "WL_TEST_GITLAB_SECRET", ActorID: "ci-bot", Kinds: map[string]string{"pipeline.success": "ci.passed"}},
+	}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":    "task-linked",
+		"title": "Linked work",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	linksURL := srv.URL + "/v0/projects/" + projectID + "/tasks/task-linked/links"
+	mrURL := "https://gitlab.example.com/team/app/-/merge_requests/42"
+	res, data := doJSON(t, client, http.MethodPost, linksURL, map[string]any{"kind": "pr", "target": mrURL + "/"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("link pr: %d %s", res.StatusCode, string(data))
+	}
+	var prLink TaskLinkResponse
+	_ = json.Unmarshal(data, &prLink)
+	if prLink.Target != mrURL || prLink.CreatedBy == "" {
+		t.Fatalf("unexpected pr link %+v", prLink)
+	}
+	res, data = doJSON(t, client, http.MethodPost, linksURL, map[string]any{"kind": "commit", "target": "ABCDEF1"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("link commit: %d %s", res.StatusCode, string(data))
+	}
+	var commitLink TaskLinkResponse
+	_ = json.Unmarshal(data, &commitLink)
+	for _, bad := range []map[string]any{
+		{"kind": "pr", "target": mrURL},
+		{"kind": "commit", "target": "not-a-sha"},
+		{"kind": "tag", "target": "v1"},
+	} {
+		if res, data := doJSON(t, client, http.MethodPost, linksURL, bad, nil); res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d %s", bad, res.StatusCode, string(data))
+		}
+	}
+
+	post := func(event string, body string) WebhookResponse {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/integrations/gitlab/webhook", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gitlab-Event", event)
+		req.Header.Set("X-Gitlab-Token", "gl-secret")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		var out WebhookResponse
+		_ = json.Unmarshal(data, &out)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %d %s", event, res.StatusCode, string(data))
+		}
+		return out
+	}
+
+	// Neither event references the task nor maps to a kind: the links alone match them.
+	out := post("Merge Request Hook", `{"object_attributes":{"iid":42,"title":"Login","source_branch":"feature/login","action":"approved","url":"`+mrURL+`"}}`)
+	if out.Ignored || len(out.Links) != 1 || out.Links[0].ReviewStatus != "approved" || out.Links[0].HeadRef != "feature/login" {
+		t.Fatalf("unexpected merge request sync %+v", out)
+	}
+	out = post("Pipeline Hook", `{"object_attributes":{"id":9,"ref":"feature/login","sha":"abcdef1234567890","status":"failed","url":"https://gitlab.example.com/pipelines/9"}}`)
+	if out.Ignored || len(out.Links) != 2 {
+		t.Fatalf("expected the pipeline to update both links: %+v", out)
+	}
+	out = post("Pipeline Hook", `{"object_attributes":{"id":10,"ref":"main","sha":"0123456789","status":"success"}}`)
+	if !out.Ignored || len(out.Links) != 0 {
+		t.Fatalf("expected an unrelated pipeline to be ignored: %+v", out)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/task-linked", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get task: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	if len(task.Links) != 2 {
+		t.Fatalf("expected 2 links on the task, got %+v", task.Links)
+	}
+	for _, l := range task.Links {
+		if l.CIStatus != "failed" || l.CIURL != "https://gitlab.example.com/pipelines/9" || l.Provider != "gitlab" {
+			t.Fatalf("unexpected link status %+v", l)
+		}
+	}
+	if task.Links[0].ReviewStatus != "approved" || task.Links[1].ReviewStatus != "" {
+		t.Fatalf("unexpected review status %+v", task.Links)
+	}
+	evts, err := srv.engine.Repo.LatestEvents(ctx, 10, projectID, "task.link.status", "task", "task-linked")
+	if err != nil || len(evts) != 3 {
+		t.Fatalf("expected 3 task.link.status events, got %d (%v)", len(evts), err)
+	}
+
+	res, data = doJSON(t, client, http.MethodDelete, fmt.Sprintf("%s/%d", linksURL, commitLink.ID), nil, nil)
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("remove link: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodDelete, fmt.Sprintf("%s/%d", linksURL, commitLink.ID), nil, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 removing a removed link, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, linksURL, nil, nil)
+	var links []TaskLinkResponse
+	_ = json.Unmarshal(data, &links)
+	if res.StatusCode != http.StatusOK || len(links) != 1 || links[0].ID != prLink.ID {
+		t.Fatalf("list links: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestTestReportIngestion(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":    "task-tests",
+		"title": "Tested work",
+		"type":  "technical",
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	post := func(query, contentType, body string) (int, TestReportResponse, string) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/integrations/test-reports?"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Api-Key", "test-api-key")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		var out TestReportResponse
+		_ = json.Unmarshal(data, &out)
+		return res.StatusCode, out, string(data)
+	}
+
+	junit := `<?xml version="1.0"?>
+<testsuites time="1.5">
+  <testsuite name="auth">
+    <testcase classname="auth.Login" name="ok" time="0.5"/>
+    <testcase classname="auth.Login" name="bad password" time="0.5"><failure message="expected 401, got 500">trace</failure></testcase>
+    <testcase classname="auth.Login" name="sso" time="0.5"><skipped/></testcase>
+  </testsuite>
+</testsuites>`
+	status, out, body := post("task_id=task-tests", "application/xml", junit)
+	if status != http.StatusCreated {
+		t.Fatalf("junit report: %d %s", status, body)
+	}
+	if out.Format != "junit" || out.Kind != "tests.failed" || out.Tests != 3 || out.Passed != 1 || out.Failed != 1 || out.Skipped != 1 {
+		t.Fatalf("unexpected junit summary: %s", body)
+	}
+	if len(out.Failures) != 1 || out.Failures[0].Name != "bad password" || out.Failures[0].Message != "expected 401, got 500" {
+		t.Fatalf("unexpected junit failures: %+v", out.Failures)
+	}
+	payload := out.Attestation.Payload
+	if out.Attestation.EntityID != "task-tests" || payload["failed"] != float64(1) || payload["duration_seconds"] != 1.5 {
+		t.Fatalf("unexpected attestation: %+v", out.Attestation)
+	}
+
+	tap := "TAP version 13\n1..3\nok 1 - parses\nok 2 - formats # SKIP no locale\nok 3 - rounds\n    not ok 1 - nested subtest\n"
+	status, out, body = post("task_id=task-tests", "text/plain", tap)
+	if status != http.StatusCreated || out.Format != "tap" || out.Kind != "tests.passed" || out.Tests != 3 || out.Passed != 2 || out.Skipped != 1 {
+		t.Fatalf("tap report: %d %s", status, body)
+	}
+	status, out, body = post("task_id=task-tests&format=tap", "text/plain", "1..2\nok 1 - only one\n")
+	if status != http.StatusCreated || out.Kind != "tests.failed" || out.Errors != 1 {
+		t.Fatalf("short tap plan: %d %s", status, body)
+	}
+
+	if status, _, body := post("task_id=task-tests", "application/xml", "<testsuites></testsuites>"); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty report, got %d: %s", status, body)
+	}
+	if status, _, body := post("task_id=missing", "application/xml", junit); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown task, got %d: %s", status, body)
+	}
+}
+
+func TestSARIFIngestion(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+		"id":         "task-scan",
+		"title":      "Scanned work",
+		"type":       "technical",
+		"validation": map[string]any{"require": []string{"security.scan[payload.critical == 0]"}},
+	}, nil)
+	if taskRes.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	}
+	post := func(taskID, body string) (int, SARIFResponse, string) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v0/integrations/sarif?task_id="+taskID, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/sarif+json")
+		req.Header.Set("X-Api-Key", "test-api-key")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		var out SARIFResponse
+		_ = json.Unmarshal(data, &out)
+		return res.StatusCode, out, string(data)
+	}
+	validation := func() map[string]any {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/task-scan/validation", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("validation: %d %s", res.StatusCode, string(data))
+		}
+		var out map[string]any
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	dirty := `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"CodeQL","rules":[
+		{"id":"js/sql-injection","properties":{"security-severity":"9.8"}},
+		{"id":"js/unused","defaultConfiguration":{"level":"note"}}]}},
+	"results":[
+		{"ruleId":"js/sql-injection","message":{"text":"query built from user input"},"locations":[{"physicalLocation":{"artifactLocation":{"uri":"src/db.js"},"region":{"startLine":12}}}]},
+		{"ruleId":"js/unused","message":{"text":"unused variable"}},
+		{"ruleId":"js/sql-injection","level":"error","message":{"text":"reviewed"},"suppressions":[{"kind":"inSource"}]},
+		{"ruleId":"semgrep.xss","level":"error","message":{"text":"unescaped output"}}]}]}`
+	status, out, body := post("task-scan", dirty)
+	if status != http.StatusCreated {
+		t.Fatalf("sarif upload: %d %s", status, body)
+	}
+	if out.Kind != "security.scan" || out.Findings != 3 || out.Suppressed != 1 || out.BySeverity["critical"] != 1 || out.BySeverity["high"] != 1 || out.BySeverity["note"] != 1 {
+		t.Fatalf("unexpected scan summary: %s", body)
+	}
+	if len(out.Top) != 3 || out.Top[0].Severity != "critical" || out.Top[0].Location != "src/db.js:12" {
+		t.Fatalf("expected the critical finding first: %+v", out.Top)
+	}
+	if out.Attestation.Payload["critical"] != float64(1) {
+		t.Fatalf("expected severity counts in the payload: %+v", out.Attestation.Payload)
+	}
+	if v := validation(); v["satisfied"] != false {
+		t.Fatalf("expected a critical finding to leave the requirement unmet: %v", v)
+	}
+
+	clean := `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"CodeQL"}},"results":[{"ruleId":"js/unused","level":"warning","message":{"text":"unused variable"}}]}]}`
+	if status, out, body = post("task-scan", clean); status != http.StatusCreated || out.BySeverity["critical"] != 0 || out.BySeverity["medium"] != 1 {
+		t.Fatalf("clean upload: %d %s", status, body)
+	}
+	if v := validation(); v["satisfied"] != true {
+		t.Fatalf("expected a scan without critical findings to meet the requirement: %v", v)
+	}
+
+	if status, _, body := post("task-scan", `{"version":"2.1.0","runs":[]}`); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a log without runs, got %d: %s", status, body)
+	}
+	if status, _, body := post("missing", clean); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown task, got %d: %s", status, body)
+	}
+}
+
+func TestCycleTimeAnalytics(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+
+	var ids []string
+	for _, typ := range []string{"technical", "bug"} {
+		res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{"title": "Flow " + typ, "type": typ}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		ids = append(ids, task.ID)
+	}
+	for _, id := range ids {
+		if _, err := srv.engine.UpdateTask(ctx, engine.TaskUpdateOptions{ID: id, Status: "in_progress", ActorID: "tester", Force: true}); err != nil {
+			t.Fatalf("start %s: %v", id, err)
+		}
+	}
+	if _, err := srv.engine.UpdateTask(ctx, engine.TaskUpdateOptions{ID: ids[0], Status: "done", ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/analytics/cycle-time?type=technical", nil, nil)
+	var rep engine.CycleTimeReport
+	_ = json.Unmarshal(data, &rep)
+	if res.StatusCode != http.StatusOK || len(rep.Completed) != 1 || rep.Completed[0].TaskID != ids[0] || rep.CycleTime.Count != 1 || len(rep.WIP) != 0 {
+		t.Fatalf("cycle time: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/analytics/cycle-time", nil, nil)
+	_ = json.Unmarshal(data, &rep)
+	if res.StatusCode != http.StatusOK || len(rep.WIP) != 1 || rep.WIP[0].TaskID != ids[1] || rep.WIP[0].StartedAt == nil {
+		t.Fatalf("expected the started bug as work in progress: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/analytics/cycle-time?from=yesterday", nil, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad period, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestStatsTimeseries(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		res, body := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+			"title": fmt.Sprintf("Stat %d", i),
+			"type":  "technical",
+		}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(body))
+		}
+	}
+	snap, err := srv.engine.RecordDailyStats(ctx, projectID)
+	if err != nil {
+		t.Fatalf("record stats: %v", err)
+	}
+	if err := srv.engine.Repo.UpsertStatsSnapshot(ctx, domain.StatsSnapshot{ProjectID: projectID, Day: "2000-01-01", TasksOpen: 9, RecordedAt: snap.RecordedAt}); err != nil {
+		t.Fatalf("seed old snapshot: %v", err)
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/stats/timeseries?metric=tasks_open", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("timeseries: %d %s", res.StatusCode, string(data))
+	}
+	var series TimeseriesResponse
+	if err := json.Unmarshal(data, &series); err != nil {
+		t.Fatalf("unmarshal timeseries: %v", err)
+	}
+	if len(series.Points) != 1 || series.Points[0].Day != snap.Day || series.Points[0].Value == nil || *series.Points[0].Value != 2 {
+		t.Fatalf("unexpected series %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/stats/timeseries?metric=lead_time_seconds&from=1999-12-01&to=2000-01-31", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("timeseries range: %d %s", res.StatusCode, string(data))
+	}
+	if err := json.Unmarshal(data, &series); err != nil {
+		t.Fatalf("unmarshal timeseries: %v", err)
+	}
+	if len(series.Points) != 1 || series.Points[0].Value != nil {
+		t.Fatalf("expected one point without lead time, got %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/stats/timeseries?metric=velocity", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown metric, got %d: %s", res.StatusCode, string(data))
+	}
+}
+
+func TestEventChainVerify(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+
+	for i := 0; i < 3; i++ {
+		res, body := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
+			"title": fmt.Sprintf("Chained %d", i),
+			"type":  "technical",
+		}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(body))
+		}
+	}
+	verifyURL := srv.URL + "/v0/projects/" + projectID + "/events/verify"
+	res, data := doJSON(t, client, http.MethodGet, verifyURL, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("verify: %d %s", res.StatusCode, string(data))
+	}
+	var report EventChainResponse
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("unmarshal verify: %v", err)
+	}
+	if !report.Valid || report.Checked < 3 || report.HeadHash == "" {
+		t.Fatalf("expected valid chain, got %+v", report)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/events?limit=1", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("events: %d %s", res.StatusCode, string(data))
+	}
+	var page paginatedEvents
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("unmarshal events: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ThisHash != report.HeadHash || page.Items[0].PrevHash == "" {
+		t.Fatalf("expected latest event to carry the head hash, got %+v", page.Items)
+	}
+
+	var tampered int64
+	if err := srv.engine.DB.QueryRow(`SELECT id FROM events WHERE project_id=? AND type='task.created' ORDER BY id LIMIT 1`, projectID).Scan(&tampered); err != nil {
+		t.Fatalf("find event: %v", err)
+	}
+	if _, err := srv.engine.DB.Exec(`UPDATE events SET payload_json='{"title":"forged"}' WHERE id=?`, tampered); err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodGet, verifyURL, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("verify: %d %s", res.StatusCode, string(data))
+	}
+	report = EventChainResponse{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("unmarshal verify: %v", err)
+	}
+	if report.Valid || report.BrokenAt != tampered {
+		t.Fatalf("expected chain broken at %d, got %+v", tampered, report)
+	}
+}
+
+func TestClientCertAuthentication(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	if err := srv.engine.Repo.UpsertClientCertMapping(ctx, domain.ClientCertMapping{Kind: "cn", Value: "agent-7", ActorID: "agent-7"}); err != nil {
+		t.Fatalf("map cn: %v", err)
+	}
+	if err := srv.engine.Repo.UpsertClientCertMapping(ctx, domain.ClientCertMapping{Kind: "dns", Value: "builder.fleet.local", ActorID: "builder", OrgID: "fleet"}); err != nil {
+		t.Fatalf("map san: %v", err)
+	}
+	var got Principal
+	handler := newAuthMiddleware("/v0", AuthConfig{JWTSecret: "test-secret"}, srv.engine.Repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = principalFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	call := func(cert *x509.Certificate) int {
+		req := httptest.NewRequest(http.MethodGet, "/v0/projects/workline/tasks", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		got = Principal{}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := call(&x509.Certificate{Subject: pkix.Name{CommonName: "agent-7"}}); code != http.StatusNoContent || got.ActorID != "agent-7" || got.OrgID != "default-org" || got.Source != "mtls" {
+		t.Fatalf("cn mapping: %d %+v", code, got)
+	}
+	if code := call(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}, DNSNames: []string{"builder.fleet.local"}}); code != http.StatusNoContent || got.ActorID != "builder" || got.OrgID != "fleet" {
+		t.Fatalf("san mapping: %d %+v", code, got)
+	}
+	if code := call(&x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unmapped certificate, got %d", code)
+	}
+	if code := call(nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", code)
+	}
+}
+
+func TestContractValidationFlagsDrift(t *testing.T) {
+	api := humachi.New(chi.NewRouter(), huma.DefaultConfig("contract", "1.0.0"))
+	huma.Register(api, huma.Operation{
+		OperationID: "get-widget",
+		Method:      http.MethodGet,
+		Path:        "/widgets/{id}",
+	}, func(ctx context.Context, input *struct {
+		ID string `path:"id"`
+	}) (*struct {
+		Body struct {
+			Name string `json:"name"`
+		}
+	}, error) {
+		return nil, nil
+	})
+	serve := func(mode, body string, status int) *httptest.ResponseRecorder {
+		drifting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_, _ = io.WriteString(w, body)
+		})
+		var logs bytes.Buffer
+		handler := newContractMiddleware(mode, log.New(&logs, "", 0), api.OpenAPI)(drifting)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets/w1", nil))
+		if rec.Code == http.StatusInternalServerError && !strings.Contains(logs.String(), "get-widget") {
+			t.Fatalf("expected violation to be logged, got %q", logs.String())
+		}
+		return rec
+	}
+
+	if rec := serve(ContractEnforce, `{"name":"gear"}`, http.StatusOK); rec.Code != http.StatusOK {
+		t.Fatalf("conforming response rejected: %d %s", rec.Code, rec.Body.String())
+	}
+	rec := serve(ContractEnforce, `{"name":5}`, http.StatusOK)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "contract_violation") {
+		t.Fatalf("expected contract violation, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(ContractEnforce, `{"name":"gear"}`, http.StatusAccepted); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected undocumented status to be flagged, got %d", rec.Code)
+	}
+	if rec := serve(ContractLog, `{"name":5}`, http.StatusOK); rec.Code != http.StatusOK || rec.Body.String() != `{"name":5}` {
+		t.Fatalf("log mode must pass the response through, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIterationCarryOver(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+
+	for _, id := range []string{"iter-1", "iter-2"} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/iterations", map[string]any{"id": id, "goal": id}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create iteration %s: %d %s", id, res.StatusCode, string(data))
+		}
+	}
+	var open []string
+	for i := 0; i < 3; i++ {
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{
+			"title":        fmt.Sprintf("Sprint task %d", i),
+			"type":         "technical",
+			"iteration_id": "iter-1",
+		}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		open = append(open, task.ID)
+	}
+	if _, err := srv.engine.UpdateTask(context.Background(), engine.TaskUpdateOptions{ID: open[0], Status: "done", ActorID: "tester", Force: true}); err != nil {
+		t.Fatalf("finish task: %v", err)
+	}
+	open = open[1:]
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/iterations/iter-1/carry-over", map[string]any{"target_iteration_id": "iter-2"}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("carry over: %d %s", res.StatusCode, string(data))
+	}
+	var out CarryOverResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal carry-over: %v", err)
+	}
+	if !slices.Equal(out.TaskIDs, open) || out.Source.CarriedOut != 2 || out.Target == nil || out.Target.CarriedIn != 2 {
+		t.Fatalf("unexpected carry-over result: %+v", out)
+	}
+	for _, id := range open {
+		task, err := srv.engine.Repo.GetTask(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.IterationID == nil || *task.IterationID != "iter-2" {
+			t.Fatalf("task %s not moved: %v", id, task.IterationID)
+		}
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations/iter-2/carry-over", map[string]any{}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("carry over to backlog: %d %s", res.StatusCode, string(data))
+	}
+	out = CarryOverResponse{}
+	_ = json.Unmarshal(data, &out)
+	if len(out.TaskIDs) != 2 || out.Target != nil {
+		t.Fatalf("unexpected backlog carry-over: %+v", out)
+	}
+	task, _ := srv.engine.Repo.GetTask(context.Background(), open[0])
+	if task.IterationID != nil {
+		t.Fatalf("expected task in backlog, got %s", *task.IterationID)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations/iter-1/carry-over", map[string]any{"target_iteration_id": "iter-1"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for self carry-over, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestIterationFreeze(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+
+	for _, id := range []string{"iter-1", "iter-2"} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/iterations", map[string]any{"id": id, "goal": id}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create iteration %s: %d %s", id, res.StatusCode, string(data))
+		}
+	}
+	res, data := doJSON(t, client, http.MethodPost, base+"/iterations/iter-1/freeze", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 freezing a pending iteration, got %d %s", res.StatusCode, string(data))
+	}
+	if _, err := srv.engine.SetIterationStatus(context.Background(), "iter-1", "running", "tester", false); err != nil {
+		t.Fatalf("start iteration: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{
+		"id":           "task-scoped",
+		"title":        "Scoped before the freeze",
+		"type":         "technical",
+		"iteration_id": "iter-1",
+		"validation":   map[string]any{"require": []string{"ci.passed", "review.approved"}},
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations/iter-1/freeze", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("freeze: %d %s", res.StatusCode, string(data))
+	}
+	var it IterationResponse
+	_ = json.Unmarshal(data, &it)
+	if it.FrozenAt == nil || it.FrozenBy == nil || *it.FrozenBy != "tester" {
+		t.Fatalf("expected frozen iteration, got %+v", it)
+	}
+
+	late := map[string]any{"id": "task-late", "title": "Late addition", "type": "technical", "iteration_id": "iter-1"}
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", late, nil)
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 adding to a frozen iteration, got %d %s", res.StatusCode, string(data))
+	}
+	var apiErr struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(data, &apiErr)
+	if apiErr.Error.Code != "iteration_frozen" {
+		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	}
+	late["freeze_override"] = "hotfix for the launch blocker"
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", late, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create with override: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/task-scoped", map[string]any{"validation": map[string]any{"require": []string{"ci.passed"}}}, nil)
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 loosening a frozen task's policy, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/task-scoped", map[string]any{"validation": map[string]any{"require": []string{"ci.passed", "review.approved", "security.ok"}}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("tightening should pass: %d %s", res.StatusCode, string(data))
+	}
+
+	if _, err := srv.engine.CreateTask(context.Background(), engine.TaskCreateOptions{ID: "task-next", ProjectID: projectID, Type: "technical", Title: "Next", IterationID: "iter-2", ActorID: "tester"}); err != nil {
+		t.Fatalf("create task in iter-2: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations/iter-2/carry-over", map[string]any{"target_iteration_id": "iter-1"}, nil)
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 carrying into a frozen iteration, got %d %s", res.StatusCode, string(data))
+	}
+
+	evtRes, evtData := doJSON(t, client, http.MethodGet, base+"/events?type=iteration.freeze.overridden", nil, nil)
+	if evtRes.StatusCode != http.StatusOK {
+		t.Fatalf("events status %d: %s", evtRes.StatusCode, string(evtData))
+	}
+	var overridden paginatedEvents
+	_ = json.Unmarshal(evtData, &overridden)
+	if len(overridden.Items) != 1 || overridden.Items[0].Payload["reason"] != "hotfix for the launch blocker" || overridden.Items[0].Payload["task_id"] != "task-late" {
+		t.Fatalf("expected the override recorded, got %s", string(evtData))
+	}
+
+	res, data = doJSON(t, client, http.MethodDelete, base+"/iterations/iter-1/freeze", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unfreeze: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations/iter-2/carry-over", map[string]any{"target_iteration_id": "iter-1"}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("carry over after unfreeze: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestSavedViews(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+
+	for i, spec := range []map[string]any{
+		{"title": "Feature mine", "type": "feature", "assignee_id": "tester"},
+		{"title": "Feature other", "type": "feature", "assignee_id": "dev-1"},
+		{"title": "Plumbing", "type": "technical"},
+	} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", spec, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(data))
+		}
+	}
+	if err := srv.engine.GrantRole(context.Background(), projectID, "tester", "dev-1", "dev"); err != nil {
+		t.Fatalf("grant dev: %v", err)
+	}
+	devHeaders := bearerHeader(srv.bearerToken(t, "dev-1", "default-org", time.Now().Add(time.Hour)))
+
+	create := func(body map[string]any) ViewResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/views", body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create view %v: %d %s", body["name"], res.StatusCode, string(data))
+		}
+		var v ViewResponse
+		_ = json.Unmarshal(data, &v)
+		return v
+	}
+	mine := create(map[string]any{"name": "my features", "visibility": "private", "filters": map[string]any{"type": "feature", "assignee_id": "$me"}})
+	shared := create(map[string]any{"name": "features", "filters": map[string]any{"type": "feature"}})
+	create(map[string]any{"name": "qa only", "roles": []string{"qa"}, "filters": map[string]any{"status": "review"}})
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/views", map[string]any{"name": "features"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for duplicate name, got %d %s", res.StatusCode, string(data))
+	}
+
+	results := func(id string, headers map[string]string) []TaskResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/views/"+id+"/results", nil, headers)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("view results %s: %d %s", id, res.StatusCode, string(data))
+		}
+		var page paginatedTasks
+		_ = json.Unmarshal(data, &page)
+		return page.Items
+	}
+	if items := results(mine.ID, nil); len(items) != 1 || items[0].Title != "Feature mine" {
+		t.Fatalf("unexpected results for my features: %+v", items)
+	}
+	if items := results(shared.ID, devHeaders); len(items) != 2 {
+		t.Fatalf("expected 2 shared results, got %+v", items)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/views", nil, devHeaders)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list views: %d %s", res.StatusCode, string(data))
+	}
+	var list struct {
+		Items []ViewResponse `json:"items"`
+	}
+	_ = json.Unmarshal(data, &list)
+	if len(list.Items) != 1 || list.Items[0].ID != shared.ID {
+		t.Fatalf("dev should only see the shared view: %+v", list.Items)
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/views/"+mine.ID, nil, devHeaders)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for private view, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodDelete, base+"/views/"+shared.ID, nil, devHeaders)
+	assertForbiddenPermission(t, res, data, "rbac.manage")
+	res, data = doJSON(t, client, http.MethodDelete, base+"/views/"+shared.ID, nil, nil)
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete view: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestLocalizedErrorMessages(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	messages, err := LoadMessageCatalog("../../messages.example.yml")
+	if err != nil {
+		t.Fatalf("load example catalog: %v", err)
+	}
+	handler, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, ContractValidation: ContractEnforce, Messages: messages})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	token := srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour))
+	stranger := srv.bearerToken(t, "stranger", "", time.Now().Add(time.Hour))
+
+	check := func(name, method, path, acceptLanguage, bearer string, body any, status int, code, message, lang string) {
+		t.Helper()
+		headers := map[string]string{}
+		if acceptLanguage != "" {
+			headers["Accept-Language"] = acceptLanguage
+		}
+		if bearer != "" {
+			headers["Authorization"] = "Bearer " + bearer
+		}
+		res, data := doJSON(t, ts.Client(), method, ts.URL+path, body, headers)
+		if res.StatusCode != status {
+			t.Fatalf("%s: expected %d, got %d %s", name, status, res.StatusCode, string(data))
+		}
+		var env struct {
+			Error apiErrorBody `json:"error"`
+		}
+		if err := json.Unmarshal(data, &env); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if env.Error.Code != code || env.Error.Message != message {
+			t.Fatalf("%s: expected %s %q, got %s %q", name, code, message, env.Error.Code, env.Error.Message)
+		}
+		if got := res.Header.Get("Content-Language"); got != lang {
+			t.Fatalf("%s: expected Content-Language %q, got %q", name, lang, got)
+		}
+	}
+
+	check("english by default", http.MethodGet, "/v0/projects/workline/tasks/missing", "", token, nil, http.StatusNotFound, "not_found", "not found", "")
+	check("english when unsupported", http.MethodGet, "/v0/projects/workline/tasks/missing", "ja, en;q=0.5", token, nil, http.StatusNotFound, "not_found", "not found", "")
+	check("region falls back", http.MethodGet, "/v0/projects/workline/tasks/missing", "de;q=0.5, fr-CH", token, nil, http.StatusNotFound, "not_found", "Introuvable : not found", "fr")
+	check("details in template", http.MethodPost, "/v0/projects/workline/tasks", "de", stranger, map[string]any{"title": "x", "type": "bug"}, http.StatusForbidden, "forbidden", "Aktion verweigert: Berechtigung task.create erforderlich", "de")
+	check("middleware errors", http.MethodGet, "/v0/projects/workline/tasks", "fr", "", nil, http.StatusUnauthorized, "unauthorized", "Authentification requise", "fr")
+	check("uncovered code", http.MethodPost, "/v0/projects/workline/tasks", "fr", token, map[string]any{"title": "x", "type": "bug", "dependson": []string{}}, http.StatusBadRequest, "bad_request", `Requête invalide : unknown field "dependson"`, "fr")
+
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.yml")
+	if err := os.WriteFile(bad, []byte("fr:\n  not_found: \"\"\n"), 0o644); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+	if _, err := LoadMessageCatalog(bad); err == nil {
+		t.Fatalf("expected empty message to be rejected")
+	}
+}
+
+func TestJSONDecodingModes(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	res, data := doJSON(t, srv.Client(), http.MethodPost, srv.URL+"/v0/projects/workline/tasks", map[string]any{"title": "typo", "type": "bug", "dependson": []string{"x"}}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown field, got %d %s", res.StatusCode, string(data))
+	}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Details struct {
+				UnknownFields []string `json:"unknown_fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if body.Error.Code != "bad_request" || body.Error.Message != `unknown field "dependson"` || !slices.Equal(body.Error.Details.UnknownFields, []string{"dependson"}) {
+		t.Fatalf("unexpected error body: %s", string(data))
+	}
+
+	res, data = doJSON(t, srv.Client(), http.MethodPost, srv.URL+"/v0/projects/workline/tasks", map[string]any{"title": "nested typo", "type": "bug", "policy": map[string]any{"presett": "low"}}, nil)
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(data), `unknown field \"policy.presett\"`) {
+		t.Fatalf("expected nested unknown field rejected, got %d %s", res.StatusCode, string(data))
+	}
+
+	if _, err := New(Config{Engine: srv.engine, JSONDecoding: "sloppy"}); err == nil {
+		t.Fatalf("expected unknown decoding mode to be rejected")
+	}
+	handler, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, ContractValidation: ContractEnforce, JSONDecoding: JSONLenient})
+	if err != nil {
+		t.Fatalf("build lenient handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	token := srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour))
+	res, data = doJSON(t, ts.Client(), http.MethodPost, ts.URL+"/v0/projects/workline/tasks", map[string]any{"title": "lenient", "type": "bug", "dependson": []string{"x"}}, bearerHeader(token))
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("lenient create: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	if err := json.Unmarshal(data, &task); err != nil {
+		t.Fatalf("unmarshal task: %v", err)
+	}
+	if task.Title != "lenient" || len(task.DependsOn) != 0 {
+		t.Fatalf("expected unknown field ignored: %+v", task)
+	}
+}
+
+func TestReadReplicaRouting(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	base := "/v0/projects/workline/tasks"
+
+	res, data := doJSON(t, srv.Client(), http.MethodPost, srv.URL+base, map[string]any{"title": "replicated", "type": "bug"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	// A point-in-time copy stands in for a replica restored by litestream.
+	path := filepath.Join(t.TempDir(), "replica.db")
+	if _, err := srv.engine.DB.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		t.Fatalf("copy database: %v", err)
+	}
+	replica, err := db.OpenReplica(db.Config{}, path)
+	if err != nil {
+		t.Fatalf("open replica: %v", err)
+	}
+	defer replica.Close()
+	if _, err := db.OpenReplica(db.Config{}, filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatalf("expected a missing replica to be rejected")
+	}
+	if _, err := replica.ExecContext(ctx, `DELETE FROM tasks`); err == nil {
+		t.Fatalf("expected the replica to be read-only")
+	}
+	primaryVersion, err := migrate.Version(ctx, srv.engine.DB)
+	if err != nil {
+		t.Fatalf("primary version: %v", err)
+	}
+	if v, err := migrate.Version(ctx, replica); err != nil || v != primaryVersion {
+		t.Fatalf("replica version %d (%v), primary %d", v, err, primaryVersion)
+	}
+
+	handler, err := New(Config{Engine: srv.engine.WithReplicas(replica), BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, ContractValidation: ContractEnforce})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	headers := bearerHeader(srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour)))
+
+	res, data = doJSON(t, ts.Client(), http.MethodPost, ts.URL+base, map[string]any{"title": "after copy", "type": "bug"}, headers)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("writes go to the primary: %d %s", res.StatusCode, string(data))
+	}
+	titles := func(extra map[string]string) []string {
+		t.Helper()
+		h := map[string]string{}
+		maps.Copy(h, headers)
+		maps.Copy(h, extra)
+		res, data := doJSON(t, ts.Client(), http.MethodGet, ts.URL+base, nil, h)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("list tasks: %d %s", res.StatusCode, string(data))
+		}
+		var page paginatedTasks
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatalf("unmarshal tasks: %v", err)
+		}
+		var out []string
+		for _, task := range page.Items {
+			out = append(out, task.Title)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got := titles(nil); !slices.Equal(got, []string{"replicated"}) {
+		t.Fatalf("expected GET served by the replica, got %v", got)
+	}
+	if got := titles(map[string]string{"X-Read-From": "primary"}); !slices.Equal(got, []string{"after copy", "replicated"}) {
+		t.Fatalf("expected X-Read-From: primary to see the latest write, got %v", got)
+	}
+}
+
+func TestRequestTimeoutInterruptsQueries(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// An endless recursive query only stops when SQLite interrupts it.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var n int
+	err := srv.engine.DB.QueryRowContext(ctx, `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c) SELECT count(*) FROM c`).Scan(&n)
+	if err == nil || !repo.IsTimeout(err) {
+		t.Fatalf("expected interrupted query, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("query ran %s past its deadline", elapsed)
+	}
+	var apiErr *apiError
+	if !errors.As(handleError(err), &apiErr) || apiErr.status != http.StatusGatewayTimeout || apiErr.Body.Code != "timeout" {
+		t.Fatalf("expected 504 timeout, got %+v", handleError(err))
+	}
+	if repo.IsTimeout(fmt.Errorf("list tasks: %w", context.Canceled)) {
+		t.Fatalf("expected a canceled request not to count as a timeout")
+	}
+
+	// The interrupted statement gave the connection back.
+	res, data := doJSON(t, srv.Client(), http.MethodGet, srv.URL+"/v0/projects/workline/tasks", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list after interrupt: %d %s", res.StatusCode, string(data))
+	}
+
+	handler, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, RequestTimeout: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	token := srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour))
+	res, data = doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v0/projects/workline/tasks", nil, bearerHeader(token))
+	if res.StatusCode != http.StatusGatewayTimeout || !strings.Contains(string(data), `"code":"timeout"`) {
+		t.Fatalf("expected 504 timeout, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestAdminDBMaintenance(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/admin/db"
+
+	res, data := doJSON(t, client, http.MethodGet, base+"/integrity", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("integrity: %d %s", res.StatusCode, string(data))
+	}
+	var check db.IntegrityResult
+	if err := json.Unmarshal(data, &check); err != nil {
+		t.Fatalf("unmarshal integrity: %v", err)
+	}
+	if !check.OK || len(check.Problems) != 0 {
+		t.Fatalf("expected healthy database: %+v", check)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/vacuum", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for incremental vacuum before conversion, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/vacuum?mode=full", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("full vacuum: %d %s", res.StatusCode, string(data))
+	}
+	var vac db.VacuumResult
+	_ = json.Unmarshal(data, &vac)
+	if vac.Mode != "full" || vac.After.AutoVacuum != "incremental" {
+		t.Fatalf("unexpected full vacuum result: %+v", vac)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/vacuum?pages=10", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("incremental vacuum: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/stats", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("stats: %d %s", res.StatusCode, string(data))
+	}
+	var stats db.QueryStatsReport
+	_ = json.Unmarshal(data, &stats)
+	if stats.Queries == 0 || stats.SlowThresholdMS != 1000 || len(stats.Statements) == 0 {
+		t.Fatalf("unexpected stats: %s", string(data))
+	}
+	found := false
+	for i, st := range stats.Statements {
+		if i > 0 && st.TotalMS > stats.Statements[i-1].TotalMS {
+			t.Fatalf("statements not sorted by total time: %s", string(data))
+		}
+		if st.Count <= 0 || st.P50MS > st.P95MS || st.P95MS > st.P99MS || st.P99MS > st.MaxMS {
+			t.Fatalf("inconsistent statement stats: %+v", st)
+		}
+		found = found || strings.HasPrefix(st.Statement, "PRAGMA integrity_check")
+	}
+	if !found {
+		t.Fatalf("expected the integrity check among statements: %s", string(data))
+	}
+
+	var logged bytes.Buffer
+	slowDB, err := db.Open(db.Config{Workspace: t.TempDir(), SlowQuery: time.Nanosecond, Logger: log.New(&logged, "", 0)})
+	if err != nil {
+		t.Fatalf("open slow db: %v", err)
+	}
+	defer slowDB.Close()
+	var echoed string
+	if err := slowDB.QueryRowContext(context.Background(), "SELECT ?", strings.Repeat("secret", 20)).Scan(&echoed); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	line := logged.String()
+	if !strings.Contains(line, "slow query") || !strings.Contains(line, "server.TestAdminDBMaintenance (server_test.go:") ||
+		!strings.Contains(line, "SELECT ?") || strings.Contains(line, strings.Repeat("secret", 6)) || !strings.Contains(line, "(120 chars)") {
+		t.Fatalf("unexpected slow query log: %q", line)
+	}
+
+	intruder := bearerHeader(srv.bearerToken(t, "intruder", "default-org", time.Now().Add(time.Hour)))
+	res, data = doJSON(t, client, http.MethodGet, base+"/integrity", nil, intruder)
+	assertForbiddenPermission(t, res, data, "db.maintain")
+	res, data = doJSON(t, client, http.MethodGet, base+"/stats", nil, intruder)
+	assertForbiddenPermission(t, res, data, "db.maintain")
+}
+
+func TestAdminConsistency(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	base := srv.URL + "/v0/admin/consistency"
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/workline/tasks", map[string]any{"title": "Child", "type": "feature"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	var child TaskResponse
+	_ = json.Unmarshal(data, &child)
+	// Foreign keys are off while the rows are planted, as after a manual edit of the file.
+	for _, stmt := range []string{`PRAGMA foreign_keys=OFF`, `UPDATE tasks SET parent_id='ghost' WHERE id='` + child.ID + `'`, `PRAGMA foreign_keys=ON`} {
+		if _, err := srv.engine.DB.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := srv.engine.Repo.InsertAttestation(ctx, domain.Attestation{ID: "att-ghost", ProjectID: "workline", EntityKind: "task", EntityID: "gone", Kind: "ci.passed", ActorID: "tester", TS: time.Now().UTC().Format(time.RFC3339)}); err != nil {
+		t.Fatalf("insert attestation: %v", err)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("check: %d %s", res.StatusCode, string(data))
+	}
+	var report engine.ConsistencyReport
+	_ = json.Unmarshal(data, &report)
+	if len(report.Issues) != 2 || report.Issues[0].Check != "task_parent" || report.Issues[0].RowID != child.ID || report.Issues[0].Repair != "clear_parent" ||
+		report.Issues[1].Check != "attestation_entity" || report.Issues[1].RowID != "att-ghost" || report.Issues[1].Repair != "delete_attestation" {
+		t.Fatalf("unexpected issues: %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/repair", map[string]any{"checks": []string{"bogus"}}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown check, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/repair", map[string]any{"checks": []string{"task_parent"}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("repair: %d %s", res.StatusCode, string(data))
+	}
+	report = engine.ConsistencyReport{}
+	_ = json.Unmarshal(data, &report)
+	if len(report.Repaired) != 1 || report.Repaired[0].RowID != child.ID || len(report.Issues) != 1 || report.Issues[0].RowID != "att-ghost" {
+		t.Fatalf("unexpected partial repair: %s", string(data))
+	}
+	task, err := srv.engine.Repo.GetTask(ctx, child.ID)
+	if err != nil || task.ParentID != nil {
+		t.Fatalf("expected parent cleared: %+v %v", task, err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/repair", map[string]any{}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("repair all: %d %s", res.StatusCode, string(data))
+	}
+	report = engine.ConsistencyReport{}
+	_ = json.Unmarshal(data, &report)
+	if len(report.Repaired) != 1 || len(report.Issues) != 0 {
+		t.Fatalf("unexpected repair: %s", string(data))
+	}
+	evs, err := srv.engine.Repo.LatestEvents(ctx, 5, "workline", "consistency.repaired", "attestation", "att-ghost")
+	if err != nil || len(evs) != 1 {
+		t.Fatalf("expected consistency.repaired event: %+v %v", evs, err)
+	}
+
+	intruder := bearerHeader(srv.bearerToken(t, "intruder", "default-org", time.Now().Add(time.Hour)))
+	res, data = doJSON(t, client, http.MethodGet, base, nil, intruder)
+	assertForbiddenPermission(t, res, data, "db.maintain")
+	res, data = doJSON(t, client, http.MethodPost, base+"/repair", map[string]any{}, intruder)
+	assertForbiddenPermission(t, res, data, "db.maintain")
+}
+
+func TestImportTaskDependencies(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline/tasks"
+
+	ids := make([]string, 3)
+	for i := range ids {
+		res, data := doJSON(t, client, http.MethodPost, base, map[string]any{"title": fmt.Sprintf("Step %d", i), "type": "feature"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		ids[i] = task.ID
+	}
+	edge := func(from, to string) map[string]any { return map[string]any{"from": from, "to": to} }
+	dependsOn := func(id string) []string {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/"+id, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("get task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		return task.DependsOn
+	}
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/dependencies", map[string]any{"edges": []any{edge(ids[0], ids[1]), edge(ids[1], ids[2]), edge(ids[0], ids[1])}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("import: %d %s", res.StatusCode, string(data))
+	}
+	var out ImportDependenciesResponse
+	_ = json.Unmarshal(data, &out)
+	if len(out.Added) != 2 || len(out.Existing) != 0 {
+		t.Fatalf("unexpected import result: %s", string(data))
+	}
+	if deps := dependsOn(ids[2]); len(deps) != 1 || deps[0] != ids[1] {
+		t.Fatalf("expected %s to depend on %s, got %v", ids[2], ids[1], deps)
+	}
+
+	// The cycle only appears with the existing edges; nothing of the batch is applied.
+	res, data = doJSON(t, client, http.MethodPost, base+"/dependencies", map[string]any{"edges": []any{edge(ids[0], ids[2]), edge(ids[2], ids[0])}}, nil)
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(data), "cycle") {
+		t.Fatalf("expected 400 for cycle, got %d %s", res.StatusCode, string(data))
+	}
+	if deps := dependsOn(ids[0]); len(deps) != 0 {
+		t.Fatalf("expected rejected batch to be rolled back, got %v", deps)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/dependencies", map[string]any{"edges": []any{edge(ids[0], ids[2]), edge("missing", ids[0])}}, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing task, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/dependencies", map[string]any{"edges": []any{edge(ids[1], ids[2]), edge(ids[0], ids[2])}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("import: %d %s", res.StatusCode, string(data))
+	}
+	out = ImportDependenciesResponse{}
+	_ = json.Unmarshal(data, &out)
+	if len(out.Added) != 1 || len(out.Existing) != 1 || out.Existing[0].From != ids[1] {
+		t.Fatalf("unexpected import result: %s", string(data))
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Traced", "type": "feature"}, map[string]string{RequestIDHeader: "trace-123"})
+	if res.StatusCode != http.StatusCreated || res.Header.Get(RequestIDHeader) != "trace-123" {
+		t.Fatalf("create task: %d %q %s", res.StatusCode, res.Header.Get(RequestIDHeader), string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/events?request_id=trace-123", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list events: %d %s", res.StatusCode, string(data))
+	}
+	var page struct {
+		Items []EventResponse `json:"items"`
+	}
+	_ = json.Unmarshal(data, &page)
+	if len(page.Items) == 0 || page.Items[0].Type != "task.created" || page.Items[0].RequestID != "trace-123" {
+		t.Fatalf("expected task.created tagged with the request id: %s", string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/events/verify", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"valid":true`) {
+		t.Fatalf("expected the chain to verify with request ids: %d %s", res.StatusCode, string(data))
+	}
+
+	// Unusable incoming IDs are replaced, and error bodies carry the ID.
+	res, data = doJSON(t, client, http.MethodGet, base+"/tasks/missing", nil, map[string]string{RequestIDHeader: "bad id"})
+	generated := res.Header.Get(RequestIDHeader)
+	if res.StatusCode != http.StatusNotFound || len(generated) != 32 {
+		t.Fatalf("expected 404 with a generated request id: %d %q %s", res.StatusCode, generated, string(data))
+	}
+	var body struct {
+		Error apiErrorBody `json:"error"`
+	}
+	_ = json.Unmarshal(data, &body)
+	if body.Error.RequestID != generated {
+		t.Fatalf("expected error body to carry %s: %s", generated, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/tasks", nil, map[string]string{"Authorization": "Bearer nope", RequestIDHeader: "trace-456"})
+	if res.StatusCode != http.StatusUnauthorized || !strings.Contains(string(data), `"request_id":"trace-456"`) {
+		t.Fatalf("expected 401 carrying the request id: %d %s", res.StatusCode, string(data))
+	}
+
+	var logged bytes.Buffer
+	handler := newRequestLogger(slog.New(slog.NewJSONHandler(&logged, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		withPrincipal(r.Context(), Principal{ActorID: "alice", OrgID: "default-org"})
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/v0/projects/workline/tasks", nil)
+	req.Header.Set(RequestIDHeader, "trace-789")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	var line map[string]any
+	if err := json.Unmarshal(logged.Bytes(), &line); err != nil {
+		t.Fatalf("unmarshal log line %q: %v", logged.String(), err)
+	}
+	if line["msg"] != "request" || line["request_id"] != "trace-789" || line["method"] != "POST" || line["path"] != "/v0/projects/workline/tasks" ||
+		line["actor"] != "alice" || line["status"] != float64(http.StatusTeapot) || line["duration_ms"] == nil {
+		t.Fatalf("unexpected log line: %s", logged.String())
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+
+	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/versions", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("versions: %d %s", res.StatusCode, string(data))
+	}
+	var listing struct {
+		Versions []APIVersionInfo `json:"versions"`
+	}
+	_ = json.Unmarshal(data, &listing)
+	if len(listing.Versions) != 2 || listing.Versions[0].BasePath != "/v1" || listing.Versions[0].Status != "current" ||
+		listing.Versions[1].BasePath != "/v0" || listing.Versions[1].Status != "deprecated" {
+		t.Fatalf("unexpected versions: %s", string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, srv.URL+"/v1/projects/workline/tasks", map[string]any{"title": "Versioned", "type": "feature"}, nil)
+	if res.StatusCode != http.StatusCreated || res.Header.Get("Deprecation") != "" {
+		t.Fatalf("create task on v1: %d %q %s", res.StatusCode, res.Header.Get("Deprecation"), string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v1/projects/workline/tasks/tree", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("v1 tree: %d %s", res.StatusCode, string(data))
+	}
+	var wrapped struct {
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil || len(wrapped.Items) != 1 {
+		t.Fatalf("expected v1 tree wrapped in items: %v %s", err, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/workline/tasks/tree", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("v0 tree: %d %s", res.StatusCode, string(data))
+	}
+	var bare []map[string]any
+	if err := json.Unmarshal(data, &bare); err != nil || len(bare) != 1 {
+		t.Fatalf("expected v0 tree as a bare array: %v %s", err, string(data))
+	}
+	if res.Header.Get("Deprecation") != fmt.Sprintf("@%d", v0DeprecatedAt.Unix()) ||
+		res.Header.Get("Link") != `</v1/projects/workline/tasks/tree>; rel="successor-version"` {
+		t.Fatalf("unexpected v0 deprecation headers: %v", res.Header)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v1/openapi.json", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"version":"1.0.0"`) || !strings.Contains(string(data), `"/v1/projects/{project_id}/tasks/tree"`) {
+		t.Fatalf("unexpected v1 spec: %d", res.StatusCode)
+	}
+	spec := fetchOpenAPISpec(t, srv)
+	op := spec["paths"].(map[string]any)["/v0/projects/{project_id}/tasks/tree"].(map[string]any)["get"].(map[string]any)
+	if op["deprecated"] != true {
+		t.Fatalf("expected the v0 tree operation to be deprecated: %v", op)
+	}
+
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+	handler, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, V0Sunset: sunset})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v0/health", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" {
+		t.Fatalf("expected Sunset on v0: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestCapabilityRouting(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+
+	create := func(body map[string]any) TaskResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		return task
+	}
+	goTask := create(map[string]any{"title": "Go service", "type": "technical", "required_capabilities": []string{"Golang"}})
+	if !slices.Equal(goTask.RequiredCapabilities, []string{"golang"}) {
+		t.Fatalf("expected normalized capabilities, got %v", goTask.RequiredCapabilities)
+	}
+	uiTask := create(map[string]any{"title": "UI polish", "type": "technical", "required_capabilities": []string{"frontend", "golang"}})
+	blocker := create(map[string]any{"title": "Blocker", "type": "technical"})
+	create(map[string]any{"title": "Blocked", "type": "technical", "depends_on": []string{blocker.ID}})
+
+	ready := func() []string {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/tasks/ready", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("ready: %d %s", res.StatusCode, string(data))
+		}
+		var page paginatedTasks
+		_ = json.Unmarshal(data, &page)
+		var ids []string
+		for _, task := range page.Items {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+	if got := ready(); !slices.Equal(got, []string{blocker.ID}) {
+		t.Fatalf("without capabilities only the blocker is ready, got %v", got)
+	}
+
+	res, data := doJSON(t, client, http.MethodPut, base+"/actors/tester/capabilities", map[string]any{"capabilities": []string{"golang"}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("set capabilities: %d %s", res.StatusCode, string(data))
+	}
+	// Both tasks are top-level, so the ready queue lists them in creation (rank) order.
+	if got := ready(); !slices.Equal(got, []string{goTask.ID, blocker.ID}) {
+		t.Fatalf("golang actor should see the go task, got %v", got)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks/claim-next", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("claim next: %d %s", res.StatusCode, string(data))
+	}
+	var claimed ClaimNextResponse
+	_ = json.Unmarshal(data, &claimed)
+	if claimed.Task.ID != goTask.ID || claimed.Lease.OwnerID != "tester" {
+		t.Fatalf("unexpected claim: %+v", claimed)
+	}
+	if got := ready(); slices.Contains(got, goTask.ID) || slices.Contains(got, uiTask.ID) {
+		t.Fatalf("leased and unmatched tasks must not be ready, got %v", got)
+	}
+
+	res, data = doJSON(t, client, http.MethodPut, base+"/actors/tester/capabilities", map[string]any{"capabilities": []string{"not valid"}}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid capability, got %d %s", res.StatusCode, string(data))
+	}
+	devHeaders := bearerHeader(srv.bearerToken(t, "dev-1", "default-org", time.Now().Add(time.Hour)))
+	if err := srv.engine.GrantRole(context.Background(), projectID, "tester", "dev-1", "dev"); err != nil {
+		t.Fatalf("grant dev: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodPut, base+"/actors/tester/capabilities", map[string]any{"capabilities": []string{}}, devHeaders)
+	assertForbiddenPermission(t, res, data, "capability.manage")
+}
+
+func TestDeferredTasks(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	create := func(body map[string]any) TaskResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		return task
+	}
+	ready := func() []string {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/tasks/ready", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("ready: %d %s", res.StatusCode, string(data))
+		}
+		var page paginatedTasks
+		_ = json.Unmarshal(data, &page)
+		var ids []string
+		for _, task := range page.Items {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	later := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	gated := create(map[string]any{"title": "Renew the certificate", "type": "technical", "defer_until": later.Format(time.RFC3339)})
+	if gated.DeferUntil == nil || *gated.DeferUntil != later.Format(time.RFC3339) {
+		t.Fatalf("expected defer_until on the created task: %+v", gated)
+	}
+	snoozed := create(map[string]any{"title": "Follow up with the vendor", "type": "technical"})
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks/"+snoozed.ID+"/defer", map[string]any{"for": "48h"}, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"defer_until"`) {
+		t.Fatalf("snooze: %d %s", res.StatusCode, string(data))
+	}
+	if got := ready(); len(got) != 0 {
+		t.Fatalf("deferred tasks must not be ready, got %v", got)
+	}
+	if res, data := doJSON(t, client, http.MethodPost, base+"/tasks/claim-next", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected nothing to claim, got %d %s", res.StatusCode, string(data))
+	}
+
+	for _, body := range []map[string]any{{}, {"until": "2001-01-01T00:00:00Z"}, {"for": "soon"}} {
+		if res, data := doJSON(t, client, http.MethodPost, base+"/tasks/"+snoozed.ID+"/defer", body, nil); res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d %s", body, res.StatusCode, string(data))
+		}
+	}
+
+	res, data = doJSON(t, client, http.MethodDelete, base+"/tasks/"+snoozed.ID+"/defer", nil, nil)
+	if res.StatusCode != http.StatusOK || strings.Contains(string(data), `"defer_until"`) {
+		t.Fatalf("undefer: %d %s", res.StatusCode, string(data))
+	}
+	if got := ready(); !slices.Equal(got, []string{snoozed.ID}) {
+		t.Fatalf("expected the undeferred task ready, got %v", got)
+	}
+	if evts, err := srv.engine.Repo.LatestEvents(ctx, 1, "workline", "task.undeferred", "task", snoozed.ID); err != nil || len(evts) != 1 {
+		t.Fatalf("expected task.undeferred, got %v (%v)", evts, err)
+	}
+
+	srv.engine.Now = func() time.Time { return later.Add(time.Minute) }
+	woken, err := srv.engine.WakeDeferredTasks(ctx)
+	if err != nil || len(woken) != 1 || woken[0].TaskID != gated.ID || !woken[0].Ready {
+		t.Fatalf("wake: %+v (%v)", woken, err)
+	}
+	evts, err := srv.engine.Repo.LatestEvents(ctx, 1, "workline", "task.ready", "task", gated.ID)
+	if err != nil || len(evts) != 1 || !strings.Contains(evts[0].Payload, later.Format(time.RFC3339)) {
+		t.Fatalf("expected task.ready, got %v (%v)", evts, err)
+	}
+	if got, _ := srv.engine.Repo.GetTask(ctx, gated.ID); got.DeferUntil != nil {
+		t.Fatalf("deferral not cleared: %v", *got.DeferUntil)
+	}
+	if woken, err := srv.engine.WakeDeferredTasks(ctx); err != nil || len(woken) != 0 {
+		t.Fatalf("expected nothing left to wake, got %+v (%v)", woken, err)
+	}
+}
+
+func TestDeadlineScheduler(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, "workline")
+	if err != nil {
+		t.Fatalf("project config: %v", err)
+	}
+	cfg.Scheduler = config.Scheduler{Mode: "deadline", ChainWeight: "4h"}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, "workline", cfg); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+
+	create := func(body map[string]any) TaskResponse {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		return task
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	routine := create(map[string]any{"title": "Routine cleanup", "type": "chore"})
+	notes := create(map[string]any{"title": "Release notes", "type": "docs", "due_at": at(72 * time.Hour)})
+	login := create(map[string]any{"title": "Fix login", "type": "bug", "due_at": at(24 * time.Hour)})
+	if login.DueAt == nil || *login.DueAt != at(24*time.Hour) {
+		t.Fatalf("expected due_at on the created task: %+v", login)
+	}
+	migration := create(map[string]any{"title": "Schema migration", "type": "technical"})
+	create(map[string]any{"title": "Launch", "type": "feature", "depends_on": []string{migration.ID}, "due_at": at(26 * time.Hour)})
+
+	ready := func() []string {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/tasks/ready", nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("ready: %d %s", res.StatusCode, string(data))
+		}
+		var page paginatedTasks
+		_ = json.Unmarshal(data, &page)
+		var ids []string
+		for _, task := range page.Items {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+	// The migration inherits the launch deadline less one chain step, ahead of the login fix.
+	if got, want := ready(), []string{migration.ID, login.ID, notes.ID, routine.ID}; !slices.Equal(got, want) {
+		t.Fatalf("expected earliest deadline first %v, got %v", want, got)
+	}
+
+	res, data := doJSON(t, client, http.MethodPatch, base+"/tasks/"+notes.ID, map[string]any{"due_at": at(time.Hour)}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("set due_at: %d %s", res.StatusCode, string(data))
+	}
+	if evts, err := srv.engine.Repo.LatestEvents(ctx, 1, "workline", "task.updated", "task", notes.ID); err != nil || len(evts) != 1 || !strings.Contains(evts[0].Payload, `"due_at"`) {
+		t.Fatalf("expected due_at on task.updated, got %v (%v)", evts, err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks/claim-next", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("claim next: %d %s", res.StatusCode, string(data))
+	}
+	var claimed ClaimNextResponse
+	_ = json.Unmarshal(data, &claimed)
+	if claimed.Task.ID != notes.ID {
+		t.Fatalf("expected the task due soonest claimed, got %s", claimed.Task.ID)
+	}
+
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/"+login.ID, map[string]any{"due_at": nil}, nil)
+	if res.StatusCode != http.StatusOK || strings.Contains(string(data), `"due_at"`) {
+		t.Fatalf("clear due_at: %d %s", res.StatusCode, string(data))
+	}
+	if got := ready(); len(got) != 3 || got[0] != migration.ID || !slices.Contains(got, login.ID) {
+		t.Fatalf("expected tasks without a deadline after the migration, got %v", got)
+	}
+	if res, data := doJSON(t, client, http.MethodPatch, base+"/tasks/"+login.ID, map[string]any{"due_at": "tomorrow"}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid due_at rejected, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestCreateProjectIsIdempotent(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0"
+
+	body := map[string]any{"id": "svc", "description": "Service", "parent_project_id": "workline"}
+	res, data := doJSON(t, client, http.MethodPost, base+"/projects", body, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create project: %d %s", res.StatusCode, string(data))
+	}
+	var first ProjectResponse
+	_ = json.Unmarshal(data, &first)
+	if first.ParentProjectID != "workline" {
+		t.Fatalf("expected the parent set on creation: %s", string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodGet, base+"/projects/svc/config", nil, nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("config seeded with the project: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/projects", body, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("repeat create: %d %s", res.StatusCode, string(data))
+	}
+	var again ProjectResponse
+	_ = json.Unmarshal(data, &again)
+	if again.ID != "svc" || again.CreatedAt != first.CreatedAt || again.ParentProjectID != "workline" {
+		t.Fatalf("expected the existing project, got %s", string(data))
+	}
+	inits, err := srv.engine.Repo.LatestEvents(context.Background(), 10, "svc", "project.init", "project", "svc")
+	if err != nil || len(inits) != 1 {
+		t.Fatalf("expected one project.init event, got %d (%v)", len(inits), err)
+	}
+
+	if res, data := doJSON(t, client, http.MethodPost, base+"/projects", map[string]any{"id": "svc"}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a different program refused, got %d %s", res.StatusCode, string(data))
+	}
+	if err := srv.engine.GrantRole(context.Background(), "workline", "tester", "outsider", "owner"); err != nil {
+		t.Fatalf("grant role: %v", err)
+	}
+	if res, data := doJSON(t, client, http.MethodPost, base+"/projects", map[string]any{"id": "solo"}, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("create solo: %d %s", res.StatusCode, string(data))
+	}
+	outsider := bearerHeader(srv.bearerToken(t, "outsider", "default-org", time.Now().Add(time.Hour)))
+	if res, data := doJSON(t, client, http.MethodPost, base+"/projects", map[string]any{"id": "solo"}, outsider); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the project hidden from an actor who cannot read it, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestProgramSummary(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0"
+
+	for _, p := range []map[string]any{
+		{"id": "platform"},
+		{"id": "api", "parent_project_id": "platform"},
+		{"id": "web", "parent_project_id": "api"},
+	} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/projects", p, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create project %v: %d %s", p["id"], res.StatusCode, string(data))
+		}
+	}
+	for i, pid := range []string{"platform", "api", "web", "web"} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/projects/"+pid+"/tasks", map[string]any{"title": fmt.Sprintf("Work %d in %s", i, pid), "type": "technical"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task in %s: %d %s", pid, res.StatusCode, string(data))
+		}
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, base+"/programs/platform/summary", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("summary: %d %s", res.StatusCode, string(data))
+	}
+	var sum engine.ProgramSummary
+	if err := json.Unmarshal(data, &sum); err != nil {
+		t.Fatalf("unmarshal summary: %v", err)
+	}
+	var ids []string
+	for _, p := range sum.Projects {
+		ids = append(ids, p.ProjectID)
+	}
+	if !slices.Equal(ids, []string{"platform", "api", "web"}) || sum.Totals.Projects != 3 || sum.Totals.OpenTasks != 4 || sum.Totals.TaskCounts["planned"] != 4 {
+		t.Fatalf("unexpected rollup: %+v", sum)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/projects?parent_project_id=platform", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list children: %d %s", res.StatusCode, string(data))
+	}
+	var children []ProjectResponse
+	_ = json.Unmarshal(data, &children)
+	if len(children) != 1 || children[0].ID != "api" || children[0].ParentProjectID != "platform" {
+		t.Fatalf("unexpected children: %+v", children)
+	}
+
+	res, data = doJSON(t, client, http.MethodPatch, base+"/projects/platform", map[string]any{"parent_project_id": "web"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for cyclic parent, got %d %s", res.StatusCode, string(data))
+	}
+
+	hidden, err := srv.engine.SummarizeProgram(context.Background(), "platform", func(projectID string) bool { return projectID != "web" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hidden.Hidden != 1 || hidden.Totals.OpenTasks != 2 {
+		t.Fatalf("expected web hidden from rollup: %+v", hidden)
+	}
+}
+
+func TestTaskEvidenceBundle(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+	srv.engine.Config.Payloads.InlineMaxBytes = 64
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Ship audit log", "type": "technical"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	report := map[string]any{"suite": "unit", "log": strings.Repeat("ok ", 40)}
+	res, data = doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{
+		"entity_kind": "task",
+		"entity_id":   task.ID,
+		"kind":        "ci.passed",
+		"payload":     report,
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest: %d %s", res.StatusCode, string(data))
+	}
+	if _, err := srv.engine.WaiveValidation(context.Background(), engine.WaiverCreateOptions{
+		TaskID:        task.ID,
+		Kind:          "review.approved",
+		Justification: "reviewer on leave",
+		ExpiresAt:     time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+		ActorID:       "tester",
+	}); err != nil {
+		t.Fatalf("waive: %v", err)
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/tasks/"+task.ID+"/evidence", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("evidence: %d %s", res.StatusCode, string(data))
+	}
+	sig, err := evidence.Verify(data)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if sig.KeyID != evidence.KeyID(srv.engine.Evidence.PublicKey()) {
+		t.Fatalf("unexpected key id %s", sig.KeyID)
+	}
+	var bundle engine.EvidenceBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatalf("unmarshal bundle: %v", err)
+	}
+	if bundle.Format != engine.EvidenceFormat || bundle.Task.ID != task.ID || bundle.TaskHash == "" {
+		t.Fatalf("unexpected bundle header: %+v", bundle)
+	}
+	if !slices.Contains(bundle.Policy.Present, "ci.passed") || !slices.Contains(bundle.Policy.Waived, "review.approved") {
+		t.Fatalf("unexpected policy: %+v", bundle.Policy)
+	}
+	if len(bundle.Attestations) != 1 || bundle.Attestations[0].PayloadBlob == "" {
+		t.Fatalf("expected one offloaded attestation, got %+v", bundle.Attestations)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(bundle.Attestations[0].Payload, &payload); err != nil || payload["log"] != report["log"] {
+		t.Fatalf("payload not resolved: %s", string(bundle.Attestations[0].Payload))
+	}
+	if len(bundle.Waivers) != 1 || bundle.Waivers[0].Kind != "review.approved" {
+		t.Fatalf("unexpected waivers: %+v", bundle.Waivers)
+	}
+	types := map[string]bool{}
+	for _, ev := range bundle.Events {
+		types[ev.Type] = true
+	}
+	if !types["task.created"] || !types["attestation.added"] || !types["validation.waived"] {
+		t.Fatalf("missing events in bundle: %v", types)
+	}
+
+	tampered := strings.Replace(string(data), "reviewer on leave", "reviewed", 1)
+	if _, err := evidence.Verify([]byte(tampered)); err == nil {
+		t.Fatalf("expected tampered bundle to fail verification")
+	}
+
+	reader := "evidence-reader"
+	if err := srv.engine.GrantRole(context.Background(), projectID, "tester", reader, "dev"); err != nil {
+		t.Fatalf("grant role: %v", err)
+	}
+	token := srv.bearerToken(t, reader, "default-org", time.Now().Add(time.Hour))
+	res, data = doJSON(t, client, http.MethodGet, base+"/tasks/"+task.ID+"/evidence", nil, bearerHeader(token))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("dev evidence: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestTaskSubtreeSnapshot(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline/tasks"
+	create := func(body map[string]any) string {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base, body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		return task.ID
+	}
+	outside := create(map[string]any{"title": "Outside", "type": "technical"})
+	epic := create(map[string]any{"title": "Epic", "type": "feature"})
+	first := create(map[string]any{"title": "Step one", "type": "technical", "parent_id": epic, "depends_on": []string{outside}})
+	second := create(map[string]any{"title": "Step two", "type": "technical", "parent_id": epic, "depends_on": []string{first}})
+	create(map[string]any{"title": "Sub-step", "type": "technical", "parent_id": second})
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/workline/attestations", map[string]any{
+		"entity_kind": "task", "entity_id": first, "kind": "ci.passed", "payload": map[string]any{"suite": "unit"},
+	}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/"+epic+"/subtree", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("export: %d %s", res.StatusCode, string(data))
+	}
+	var snap engine.SubtreeSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("unmarshal snapshot: %v", err)
+	}
+	if snap.Format != engine.SubtreeFormat || snap.RootID != epic || len(snap.Tasks) != 4 || snap.Tasks[0].ID != epic {
+		t.Fatalf("unexpected snapshot tasks: %s", string(data))
+	}
+	if len(snap.Dependencies) != 1 || snap.Dependencies[0] != (engine.DependencyEdge{From: first, To: second}) {
+		t.Fatalf("expected only the dependency inside the subtree, got %+v", snap.Dependencies)
+	}
+	if len(snap.Attestations) != 1 || !strings.Contains(string(snap.Attestations[0].Payload), "unit") {
+		t.Fatalf("unexpected snapshot attestations: %+v", snap.Attestations)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/subtree", map[string]any{"snapshot": snap, "parent_id": outside}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("import: %d %s", res.StatusCode, string(data))
+	}
+	var copied engine.SubtreeImport
+	_ = json.Unmarshal(data, &copied)
+	if len(copied.Tasks) != 4 || len(copied.Attestations) != 1 || copied.RootID == epic || copied.Tasks[epic] != copied.RootID {
+		t.Fatalf("unexpected import: %s", string(data))
+	}
+	ctx := context.Background()
+	root, err := srv.engine.Repo.GetTask(ctx, copied.RootID)
+	if err != nil || root.ParentID == nil || *root.ParentID != outside {
+		t.Fatalf("expected imported root under %s: %+v %v", outside, root, err)
+	}
+	newSecond, err := srv.engine.Repo.GetTask(ctx, copied.Tasks[second])
+	if err != nil || *newSecond.ParentID != copied.RootID || !slices.Equal(newSecond.DependsOn, []string{copied.Tasks[first]}) {
+		t.Fatalf("unexpected imported task: %+v %v", newSecond, err)
+	}
+	atts, err := srv.engine.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: "workline", EntityKind: "task", EntityID: copied.Tasks[first]})
+	if err != nil || len(atts) != 1 || atts[0].ID != copied.Attestations[snap.Attestations[0].ID] || atts[0].TS != snap.Attestations[0].TS {
+		t.Fatalf("unexpected imported attestations: %+v %v", atts, err)
+	}
+	evts, err := srv.engine.Repo.LatestEvents(ctx, 10, "workline", "task.imported", "", copied.RootID)
+	if err != nil || len(evts) != 1 || !strings.Contains(evts[0].Payload, epic) {
+		t.Fatalf("expected task.imported naming the source: %+v %v", evts, err)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/subtree", map[string]any{"snapshot": snap, "template": true, "id_prefix": "q3-"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("template import: %d %s", res.StatusCode, string(data))
+	}
+	var templated engine.SubtreeImport
+	_ = json.Unmarshal(data, &templated)
+	if templated.RootID != "q3-"+epic || len(templated.Attestations) != 0 {
+		t.Fatalf("unexpected template import: %s", string(data))
+	}
+
+	done := snap
+	done.Tasks = slices.Clone(snap.Tasks)
+	done.Tasks[0].Status = "done"
+	done.Attestations = []engine.EvidenceAttestation{}
+	res, data = doJSON(t, client, http.MethodPost, base+"/subtree", map[string]any{"snapshot": done}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("done import: %d %s", res.StatusCode, string(data))
+	}
+	var reopened engine.SubtreeImport
+	_ = json.Unmarshal(data, &reopened)
+	if root, err := srv.engine.Repo.GetTask(ctx, reopened.RootID); err != nil || root.Status != "planned" || root.CompletedAt != nil {
+		t.Fatalf("expected a done source task to import as planned: %+v %v", root, err)
+	}
+
+	foreign := snap
+	foreign.Attestations = slices.Clone(snap.Attestations)
+	foreign.Attestations[0].ActorID = "mallory"
+	res, data = doJSON(t, client, http.MethodPost, base+"/subtree", map[string]any{"snapshot": foreign}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("import of another actor's attestation: %d %s", res.StatusCode, string(data))
+	}
+	var reattested engine.SubtreeImport
+	_ = json.Unmarshal(data, &reattested)
+	if atts, err := srv.engine.Repo.ListAttestations(ctx, repo.AttestationFilters{ProjectID: "workline", EntityKind: "task", EntityID: reattested.Tasks[first]}); err != nil || len(atts) != 1 || atts[0].ActorID != "tester" {
+		t.Fatalf("expected the importer as actor of the copy: %+v %v", atts, err)
+	}
+	evts, err = srv.engine.Repo.LatestEvents(ctx, 1, "workline", "attestation.added", "task", reattested.Tasks[first])
+	if err != nil || len(evts) != 1 || !strings.Contains(evts[0].Payload, `"original_actor_id":"mallory"`) || !strings.Contains(evts[0].Payload, `"imported_from":"`+snap.Attestations[0].ID+`"`) {
+		t.Fatalf("expected attestation.added naming the original actor: %+v %v", evts, err)
+	}
+	importer := bearerHeader(srv.bearerToken(t, "importer", "", time.Now().Add(time.Hour)))
+	if err := srv.engine.GrantRole(ctx, "workline", "tester", "importer", "po"); err != nil {
+		t.Fatalf("grant po: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/subtree", map[string]any{"snapshot": snap}, importer)
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for an importer without authority for the kind, got %d %s", res.StatusCode, string(data))
+	}
+	if !strings.Contains(string(data), "ci.passed") {
+		t.Fatalf("expected the refusal to name the kind, got %s", string(data))
+	}
+
+	snap.Tasks[1], snap.Tasks[3] = snap.Tasks[3], snap.Tasks[1]
+	res, data = doJSON(t, client, http.MethodPost, base+"/subtree", map[string]any{"snapshot": snap}, nil)
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(data), "must follow its parent") {
+		t.Fatalf("expected 400 for misordered snapshot, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestUsageAndQuotas(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	base := srv.URL + "/v0/projects/" + projectID
+	ctx := context.Background()
+
+	cfg := *srv.engine.Config
+	cfg.Quotas = config.Quotas{Roles: map[string]config.Quota{
+		"dev":      {Mutations: 2},
+		"observer": {Requests: 2},
+	}}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, &cfg); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+	bad := cfg
+	bad.Quotas = config.Quotas{Roles: map[string]config.Quota{"dev": {Requests: -1}}}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, &bad); err == nil {
+		t.Fatalf("expected negative quota to be rejected")
+	}
+	for actor, role := range map[string]string{"agent-1": "dev", "watcher": "observer"} {
+		if err := srv.engine.GrantRole(ctx, projectID, "tester", actor, role); err != nil {
+			t.Fatalf("grant %s: %v", role, err)
+		}
+	}
+	agent := bearerHeader(srv.bearerToken(t, "agent-1", "", time.Now().Add(time.Hour)))
+	watcher := bearerHeader(srv.bearerToken(t, "watcher", "", time.Now().Add(time.Hour)))
+
+	// Mutations count even when the handler refuses them.
+	for i := 0; i < 2; i++ {
+		res, data := doJSON(t, srv.Client(), http.MethodPost, base+"/tasks", map[string]any{"title": "runaway", "type": "bug"}, agent)
+		assertForbiddenPermission(t, res, data, "task.create")
+	}
+	for i := 0; i < 2; i++ {
+		res, data := doJSON(t, srv.Client(), http.MethodPost, base+"/tasks", map[string]any{"title": "runaway", "type": "bug"}, agent)
+		if res.StatusCode != http.StatusTooManyRequests || !strings.Contains(string(data), `"code":"quota_exceeded"`) || !strings.Contains(string(data), `"quota":"mutations"`) {
+			t.Fatalf("expected mutation quota to be enforced, got %d %s", res.StatusCode, string(data))
+		}
+		if res.Header.Get("Retry-After") == "" {
+			t.Fatalf("expected Retry-After on quota rejection")
+		}
+	}
+	res, data := doJSON(t, srv.Client(), http.MethodGet, base+"/tasks", nil, agent)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("reads stay allowed under a mutation quota: %d %s", res.StatusCode, string(data))
+	}
+
+	for i := 0; i < 2; i++ {
+		if res, data := doJSON(t, srv.Client(), http.MethodGet, base+"/tasks", nil, watcher); res.StatusCode != http.StatusOK {
+			t.Fatalf("watcher read %d: %d %s", i, res.StatusCode, string(data))
+		}
+	}
+	res, data = doJSON(t, srv.Client(), http.MethodGet, base+"/tasks", nil, watcher)
+	if res.StatusCode != http.StatusTooManyRequests || !strings.Contains(string(data), `"quota":"requests"`) {
+		t.Fatalf("expected request quota to be enforced, got %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, srv.Client(), http.MethodGet, base+"/usage", nil, agent)
+	assertForbiddenPermission(t, res, data, "usage.read")
+	res, data = doJSON(t, srv.Client(), http.MethodGet, base+"/usage?actor=agent-1", nil, agent)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("own usage: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, srv.Client(), http.MethodGet, base+"/usage", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("usage: %d %s", res.StatusCode, string(data))
+	}
+	var rep engine.UsageReport
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("unmarshal usage: %v", err)
+	}
+	today := time.Now().UTC().Format(time.DateOnly)
+	if rep.To != today {
+		t.Fatalf("expected period to end today, got %s", rep.To)
+	}
+	byActor := map[string]domain.ActorUsage{}
+	for _, u := range rep.Usage {
+		byActor[u.ActorID] = u
+	}
+	// agent-1: two refused creates, one task list and two usage reads.
+	if got := byActor["agent-1"]; got.Day != today || got.Requests != 5 || got.Mutations != 2 || got.Rejected != 2 {
+		t.Fatalf("unexpected agent usage: %+v", got)
+	}
+	if got := byActor["watcher"]; got.Requests != 2 || got.Mutations != 0 || got.Rejected != 1 {
+		t.Fatalf("unexpected watcher usage: %+v", got)
+	}
+	if byActor["tester"].Requests == 0 {
+		t.Fatalf("expected the owner's calls to be counted: %+v", rep.Usage)
+	}
+	if rep.Quotas["agent-1"] != (engine.ActorQuota{Mutations: 2}) || rep.Quotas["watcher"] != (engine.ActorQuota{Requests: 2}) {
+		t.Fatalf("unexpected quotas: %+v", rep.Quotas)
+	}
+	if _, ok := rep.Quotas["tester"]; ok {
+		t.Fatalf("owner has no quota: %+v", rep.Quotas)
+	}
+
+	var exceeded int
+	if err := srv.engine.DB.QueryRowContext(ctx, `SELECT count(*) FROM events WHERE type='quota.exceeded' AND project_id=?`, projectID).Scan(&exceeded); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if exceeded != 2 {
+		t.Fatalf("expected one quota.exceeded event per actor and day, got %d", exceeded)
+	}
+}
+
+func TestComplianceReport(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+	ctx := context.Background()
+
+	res, data := doJSON(t, client, http.MethodGet, base+"/reports/compliance", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without controls, got %d %s", res.StatusCode, string(data))
+	}
+	cfg := *srv.engine.Config
+	cfg.Compliance = config.Compliance{
+		Framework: "SOC2",
+		Controls: map[string]config.ComplianceControl{
+			"CC8.1": {Description: "Changes are tested and approved", Kinds: []string{"ci.passed", "review.approved"}},
+			"CC3.4": {Kinds: []string{"iteration.approved"}, AppliesTo: []string{"iteration"}},
+		},
+	}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, &cfg); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+
+	attest := func(kind, entityKind, entityID string) {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": entityKind, "entity_id": entityID, "kind": kind}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("attest %s: %d %s", kind, res.StatusCode, string(data))
+		}
+	}
+	var tasks []TaskResponse
+	for _, title := range []string{"Approved change", "Untested change"} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": title, "type": "technical"}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+		}
+		var task TaskResponse
+		_ = json.Unmarshal(data, &task)
+		tasks = append(tasks, task)
+	}
+	attest("ci.passed", "task", tasks[0].ID)
+	attest("review.approved", "task", tasks[0].ID)
+	attest("review.approved", "task", tasks[1].ID)
+	res, data = doJSON(t, client, http.MethodPost, base+"/iterations", map[string]any{"id": "iter-audit", "goal": "Audit sprint"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create iteration: %d %s", res.StatusCode, string(data))
+	}
+	attest("iteration.approved", "iteration", "iter-audit")
+
+	from := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	res, data = doJSON(t, client, http.MethodGet, base+"/reports/compliance?from="+from, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("report: %d %s", res.StatusCode, string(data))
+	}
+	var rep engine.ComplianceReport
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("unmarshal report: %v", err)
+	}
+	if rep.Framework != "SOC2" || len(rep.Controls) != 2 || len(rep.Rows) != 3 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	byID := map[string]engine.ControlSummary{}
+	for _, c := range rep.Controls {
+		byID[c.ID] = c
+	}
+	if byID["CC8.1"].Entities != 2 || byID["CC8.1"].Satisfied != 1 || byID["CC3.4"].Entities != 1 || byID["CC3.4"].Satisfied != 1 {
+		t.Fatalf("unexpected control totals: %+v", rep.Controls)
+	}
+	for _, row := range rep.Rows {
+		if row.EntityID == tasks[1].ID && (row.Satisfied || !slices.Equal(row.Missing, []string{"ci.passed"})) {
+			t.Fatalf("expected untested change to miss ci.passed: %+v", row)
+		}
+		if row.EntityID == tasks[0].ID && (!row.Satisfied || len(row.Attestations) != 2) {
+			t.Fatalf("expected approved change to satisfy CC8.1: %+v", row)
+		}
+	}
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/reports/compliance?format=csv&from="+from, nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv report: %d %s %s", res.StatusCode, res.Header.Get("Content-Type"), string(data))
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil || len(records) != 4 || records[0][0] != "control" {
+		t.Fatalf("unexpected csv: %v %q", err, string(data))
+	}
+
+	future := time.Now().UTC().AddDate(0, 0, 2).Format(time.DateOnly)
+	res, data = doJSON(t, client, http.MethodGet, base+"/reports/compliance?from="+future, nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty period, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestCustomTaskTypes(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	srv.engine.Config.TaskTypes = map[string]config.TaskType{
+		"incident": {
+			Description: "Production incident follow-up",
+			Fields: map[string]any{
+				"type":     "object",
+				"required": []any{"severity"},
+				"properties": map[string]any{
+					"severity": map[string]any{"type": "string", "enum": []any{"sev1", "sev2", "sev3"}},
+					"service":  map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, base+"/task-types", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list task types: %d %s", res.StatusCode, string(data))
+	}
+	var types TaskTypesResponse
+	_ = json.Unmarshal(data, &types)
+	if n := len(types.Types); n != len(config.BuiltinTaskTypes)+1 || types.Types[n-1].Name != "incident" || types.Types[n-1].Builtin || types.Types[n-1].Fields == nil {
+		t.Fatalf("unexpected task types: %+v", types.Types)
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Unknown type", "type": "spike"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for undeclared type, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Bad incident", "type": "incident", "custom_fields": map[string]any{"severity": "minor"}}, nil)
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(data), "custom_fields") {
+		t.Fatalf("expected 400 for invalid custom fields, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Missing severity", "type": "incident"}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without required field, got %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Checkout outage", "type": "incident", "custom_fields": map[string]any{"severity": "sev1", "service": "checkout"}}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create incident: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	if task.Type != "incident" || task.CustomFields["severity"] != "sev1" || task.CustomFields["service"] != "checkout" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	hash := task.ContentHash
+
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/"+task.ID, map[string]any{"custom_fields": map[string]any{"severity": "sev4"}}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid update, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/"+task.ID, map[string]any{"custom_fields": map[string]any{"severity": "sev2"}}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("update custom fields: %d %s", res.StatusCode, string(data))
+	}
+	var updated TaskResponse
+	_ = json.Unmarshal(data, &updated)
+	if updated.CustomFields["severity"] != "sev2" || updated.CustomFields["service"] != nil || updated.ContentHash == hash {
+		t.Fatalf("custom fields not replaced: %+v", updated)
+	}
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/"+task.ID, map[string]any{"custom_fields": nil}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 clearing required fields, got %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Tagged chore", "type": "chore", "custom_fields": map[string]any{"ticket": "OPS-1"}}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create chore with fields: %d %s", res.StatusCode, string(data))
+	}
+	var chore TaskResponse
+	_ = json.Unmarshal(data, &chore)
+	res, data = doJSON(t, client, http.MethodPatch, base+"/tasks/"+chore.ID, map[string]any{"custom_fields": nil}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("clear custom fields: %d %s", res.StatusCode, string(data))
+	}
+	var cleared TaskResponse
+	_ = json.Unmarshal(data, &cleared)
+	if cleared.CustomFields != nil {
+		t.Fatalf("expected custom fields cleared, got %+v", cleared.CustomFields)
+	}
+}
+
+func TestRequiredOutcomes(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	srv.engine.Config.TaskTypes = map[string]config.TaskType{
+		"chore": {RequiredOutcomes: []string{"pr", "demo_url"}},
+	}
+	res, data := doJSON(t, client, http.MethodGet, base+"/task-types", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"required_outcomes":["pr","demo_url"]`) {
+		t.Fatalf("list task types: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Ship the banner", "type": "chore"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	if _, err := srv.engine.UpdateTask(ctx, engine.TaskUpdateOptions{ID: task.ID, ActorID: "tester", RequiredKindsSet: true, PolicyOverride: true}); err != nil {
+		t.Fatalf("clear policy: %v", err)
+	}
+	taskURL := base + "/tasks/" + task.ID
+	if res, data := doJSON(t, client, http.MethodPost, taskURL+"/claim", nil, nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("claim: %d %s", res.StatusCode, string(data))
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, taskURL+"/done", map[string]any{"work_outcomes": map[string]any{"pr": "#12", "demo_url": " "}}, nil)
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without a demo, got %d %s", res.StatusCode, string(data))
+	}
+	var apiErr struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				TaskType string `json:"task_type"`
+				Fields   []struct {
+					Field string `json:"field"`
+					Error string `json:"error"`
+				} `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(data, &apiErr)
+	if d := apiErr.Error.Details; apiErr.Error.Code != "missing_work_outcomes" || d.TaskType != "chore" || len(d.Fields) != 1 || d.Fields[0].Field != "work_outcomes.demo_url" || d.Fields[0].Error != "required" {
+		t.Fatalf("unexpected error: %s", string(data))
+	}
+	if got, _ := srv.engine.Repo.GetTask(ctx, task.ID); got.Status == "done" {
+		t.Fatalf("task completed without its outcomes")
+	}
+
+	res, data = doJSON(t, client, http.MethodPost, taskURL+"/done", map[string]any{"work_outcomes": map[string]any{"pr": "#12", "demo_url": "https://demo.example/banner"}}, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"status":"done"`) {
+		t.Fatalf("complete with outcomes: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestCustomFieldFilters(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+	ctx := context.Background()
+
+	cfg := *srv.engine.Config
+	cfg.TaskTypes = map[string]config.TaskType{
+		"incident": {Indexed: []string{"component"}},
+	}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, &cfg); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+	srv.engine.Config.TaskTypes = cfg.TaskTypes
+	if cols, _ := srv.engine.DB.QueryContext(ctx, `SELECT 1 FROM pragma_table_xinfo('tasks') WHERE name='cf_component'`); cols != nil {
+		if cols.Next() {
+			t.Fatalf("expected storing a config to leave the schema alone")
+		}
+		cols.Close()
+	}
+	if fields, err := srv.engine.IndexCustomFields(ctx); err != nil || !slices.Equal(fields, []string{"component"}) {
+		t.Fatalf("index custom fields: %v %v", fields, err)
+	}
+	wide := cfg
+	wide.TaskTypes = map[string]config.TaskType{"incident": {Indexed: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"}}}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, &wide); err == nil {
+		t.Fatalf("expected more than %d indexed fields to be rejected", config.MaxIndexedCustomFields)
+	}
+	var plan string
+	rows, err := srv.engine.DB.QueryContext(ctx, `EXPLAIN QUERY PLAN SELECT id FROM tasks WHERE project_id=? AND cf_component=?`, projectID, "billing")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		_ = rows.Scan(&id, &parent, &notused, &detail)
+		plan += detail
+	}
+	rows.Close()
+	if !strings.Contains(plan, "idx_tasks_cf_component") {
+		t.Fatalf("expected component index in plan, got %q", plan)
+	}
+
+	for _, tc := range []struct {
+		title  string
+		fields map[string]any
+	}{
+		{"Billing outage", map[string]any{"component": "billing", "points": 5, "customer_facing": true}},
+		{"Billing slowness", map[string]any{"component": "billing", "points": 2}},
+		{"Search outage", map[string]any{"component": "search", "points": 8, "customer_facing": false}},
+	} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": tc.title, "type": "incident", "custom_fields": tc.fields}, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", tc.title, res.StatusCode, string(data))
+		}
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodGet, base+"/tasks?"+query, nil, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("list %s: %d %s", query, res.StatusCode, string(data))
+		}
+		var page paginatedTasks
+		_ = json.Unmarshal(data, &page)
+		var titles []string
+		for _, task := range page.Items {
+			titles = append(titles, task.Title)
+		}
+		slices.Sort(titles)
+		return titles
+	}
+	for query, want := range map[string][]string{
+		"field=component%3Dbilling":                          {"Billing outage", "Billing slowness"},
+		"field=component%3Dbilling&field=points%3E%3D3":      {"Billing outage"},
+		"field=points%3C8":                                   {"Billing outage", "Billing slowness"},
+		"field=customer_facing%3Dtrue":                       {"Billing outage"},
+		"field=component%3D%22search%22&field=points%3E%3D8": {"Search outage"},
+	} {
+		if got := list(query); !slices.Equal(got, want) {
+			t.Fatalf("%s: got %v, want %v", query, got, want)
+		}
+	}
+
+	res, data := doJSON(t, client, http.MethodGet, base+"/tasks?field=Component", nil, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed filter, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestRBACCacheInvalidation(t *testing.T) {
+	srv, cleanup := newCachedTestServer(t, time.Minute)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	ctx := context.Background()
+	configURL := srv.URL + "/v0/projects/" + projectID + "/config"
+	headers := bearerHeader(srv.bearerToken(t, "auditor", "", time.Now().Add(time.Hour)))
+
+	res, data := doJSON(t, client, http.MethodGet, configURL, nil, headers)
+	assertForbiddenPermission(t, res, data, "project.config.read")
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "auditor", "observer"); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodGet, configURL, nil, headers)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected grant to apply at once, got %d %s", res.StatusCode, string(data))
+	}
+
+	// Rows changed behind the cache's back, as by another process, are not seen until reload.
+	if _, err := srv.engine.DB.ExecContext(ctx, `DELETE FROM actor_roles WHERE project_id=? AND actor_id=?`, projectID, "auditor"); err != nil {
+		t.Fatalf("delete grant: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodGet, configURL, nil, headers)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected cached grant, got %d %s", res.StatusCode, string(data))
+	}
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "auditor", "observer"); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if err := srv.engine.RevokeRole(ctx, projectID, "tester", "auditor", "observer"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodGet, configURL, nil, headers)
+	assertForbiddenPermission(t, res, data, "project.config.read")
+
+	res, data = doJSON(t, client, http.MethodGet, configURL, nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get config: %d %s", res.StatusCode, string(data))
+	}
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, projectID)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	entry := cfg.Attestations.Catalog["ci.passed"]
+	entry.Description = "CI green on main"
+	cfg.Attestations.Catalog["ci.passed"] = entry
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, projectID, cfg); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+	res, data = doJSON(t, client, http.MethodGet, configURL, nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), "CI green on main") {
+		t.Fatalf("expected updated config, got %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestAttestationAuthorityWildcards(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+	ctx := context.Background()
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"title": "Wildcard target", "type": "technical"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	var task TaskResponse
+	_ = json.Unmarshal(data, &task)
+	if err := srv.engine.GrantRole(ctx, projectID, "tester", "ci-bot", "qa"); err != nil {
+		t.Fatalf("grant qa: %v", err)
+	}
+	botHeaders := bearerHeader(srv.bearerToken(t, "ci-bot", "", time.Now().Add(time.Hour)))
+	attest := func(kind string) int {
+		t.Helper()
+		res, _ := doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "task", "entity_id": task.ID, "kind": kind}, botHeaders)
+		return res.StatusCode
+	}
+	allow := func(kind, role string) {
+		t.Helper()
+		res, data := doJSON(t, client, http.MethodPost, base+"/rbac/attestations/allow", map[string]any{"kind": kind, "role_id": role}, nil)
+		if res.StatusCode >= 300 {
+			t.Fatalf("allow %s for %s: %d %s", kind, role, res.StatusCode, string(data))
+		}
+	}
+
+	for _, bad := range []string{"ci*", "*", ".*", "ci.*.passed"} {
+		res, data := doJSON(t, client, http.MethodPost, base+"/rbac/attestations/allow", map[string]any{"kind": bad, "role_id": "qa"}, nil)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for pattern %q, got %d %s", bad, res.StatusCode, string(data))
+		}
+	}
+	if code := attest("ci.nightly.smoke"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 before wildcard grant, got %d", code)
+	}
+	allow("ci.*", "qa")
+	if code := attest("ci.nightly.smoke"); code != http.StatusCreated {
+		t.Fatalf("expected ci.* to cover ci.nightly.smoke, got %d", code)
+	}
+	// ci.passed has its own authority list, which wins over ci.*.
+	if code := attest("ci.passed"); code != http.StatusForbidden {
+		t.Fatalf("expected exact authority to take precedence, got %d", code)
+	}
+	allow("ci.nightly.*", "security")
+	if code := attest("ci.nightly.smoke"); code != http.StatusForbidden {
+		t.Fatalf("expected the longer pattern to take precedence, got %d", code)
+	}
+	if code := attest("ci.lint"); code != http.StatusCreated {
+		t.Fatalf("expected ci.* to still cover ci.lint, got %d", code)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/rbac/attestations/deny", map[string]any{"kind": "ci.*", "role_id": "qa"}, nil)
+	if res.StatusCode >= 300 {
+		t.Fatalf("deny: %d %s", res.StatusCode, string(data))
+	}
+	if code := attest("ci.lint"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 after removing the wildcard, got %d", code)
+	}
+}
+
+func TestGraphQLReads(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/graphql", map[string]any{"query": "{ tasks { id } }"}, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected GraphQL off by default, got %d %s", res.StatusCode, string(data))
+	}
+	for _, req := range []struct {
+		path string
+		body map[string]any
+	}{
+		{"/iterations", map[string]any{"id": "gq-iter", "goal": "Dashboards"}},
+		{"/tasks", map[string]any{"id": "gq-epic", "title": "Epic", "type": "feature"}},
+		{"/tasks", map[string]any{"id": "gq-dep", "title": "Dependency", "type": "chore"}},
+		{"/tasks", map[string]any{"id": "gq-1", "title": "Chart", "type": "feature", "iteration_id": "gq-iter", "parent_id": "gq-epic", "assignee_id": "tester", "depends_on": []string{"gq-dep"}, "custom_fields": map[string]any{"points": 3}}},
+		{"/attestations", map[string]any{"entity_kind": "task", "entity_id": "gq-1", "kind": "ci.passed"}},
+		{"/decisions", map[string]any{"id": "gq-dec", "title": "Charts", "decision": "Use SVG", "decider_id": "cto"}},
+	} {
+		res, data := doJSON(t, client, http.MethodPost, base+req.path, req.body, nil)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", req.path, res.StatusCode, string(data))
+		}
+	}
+
+	handler, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, ContractValidation: ContractEnforce, GraphQL: true})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	headers := bearerHeader(srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour)))
+	query := func(headers map[string]string, body map[string]any) string {
+		t.Helper()
+		res, data := doJSON(t, ts.Client(), http.MethodPost, ts.URL+"/v0/graphql", body, headers)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("graphql: %d %s", res.StatusCode, string(data))
+		}
+		return string(data)
+	}
+
+	got := query(headers, map[string]any{
+		"query": `query Dashboard($id: ID!) {
+			task(id: $id) {
+				title
+				iteration { goal }
+				parent { id }
+				dependsOn { id }
+				custom: customFields
+				assignee { id capabilities }
+				attestations { kind actor { id } task { id } }
+			}
+			epic: task(id: "gq-epic") { children { ...Brief } }
+			decisions { title decider { id } }
+		}
+		fragment Brief on Task { id status }`,
+		"variables": map[string]any{"id": "gq-1"},
+	})
+	want := `{"data":{"task":{"title":"Chart","iteration":{"goal":"Dashboards"},"parent":{"id":"gq-epic"},"dependsOn":[{"id":"gq-dep"}],"custom":{"points":3},"assignee":{"id":"tester","capabilities":[]},"attestations":[{"kind":"ci.passed","actor":{"id":"tester"},"task":{"id":"gq-1"}}]},"epic":{"children":[{"id":"gq-1","status":"planned"}]},"decisions":[{"title":"Charts","decider":{"id":"cto"}}]}}`
+	if strings.TrimSpace(got) != want {
+		t.Fatalf("nested query:\n got %s\nwant %s", got, want)
+	}
+
+	// Fields the caller may not read are null with the REST error code and permission.
+	stranger := bearerHeader(srv.bearerToken(t, "stranger", "", time.Now().Add(time.Hour)))
+	got = query(stranger, map[string]any{"query": `{ task(id: "gq-1") { id } }`})
+	if !strings.Contains(got, `"data":{"task":null}`) || !strings.Contains(got, `"path":["task"],"extensions":{"code":"forbidden","permission":"task.read"}`) {
+		t.Fatalf("expected a forbidden field error, got %s", got)
+	}
+	got = query(headers, map[string]any{"query": `{ tasks { nope } }`})
+	if strings.Contains(got, `"data"`) || !strings.Contains(got, `cannot query field \"nope\" on type Task`) {
+		t.Fatalf("expected a validation error, got %s", got)
+	}
+
+	res, data = doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v0/graphql?query="+url.QueryEscape(`{ iterations { id } }`), nil, headers)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `{"data":{"iterations":[{"id":"gq-iter"}]}}`) {
+		t.Fatalf("graphql over GET: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v0/graphql/schema", nil, headers)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), "attestations(kind: String, first: Int = 50): [Attestation!]!") {
+		t.Fatalf("schema: %d %s", res.StatusCode, string(data))
+	}
+}
+
+func TestAttestationOnBehalf(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+	projectID := "workline"
+	client := srv.Client()
+	base := srv.URL + "/v0/projects/" + projectID
+
+	res, data := doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"id": "ob-1", "title": "Build", "type": "technical"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
+	}
+	for actor, role := range map[string]string{"ci-bot": "dev", "lead": "pm"} {
+		if err := srv.engine.GrantRole(ctx, projectID, "tester", actor, role); err != nil {
+			t.Fatalf("grant %s: %v", actor, err)
+		}
+	}
+	attest := func(headers map[string]string, body map[string]any) (*http.Response, []byte) {
+		t.Helper()
+		body["entity_kind"], body["entity_id"] = "task", "ob-1"
+		return doJSON(t, client, http.MethodPost, base+"/attestations", body, headers)
+	}
+	lead := bearerHeader(srv.bearerToken(t, "lead", "", time.Now().Add(time.Hour)))
+
+	// A caller may name itself, but attributing a proof to someone else needs attestation.on_behalf.
+	res, data = attest(lead, map[string]any{"kind": "ci.passed", "actor_id": "ci-bot"})
+	assertForbiddenPermission(t, res, data, "attestation.on_behalf")
+	res, data = attest(lead, map[string]any{"kind": "ci.passed", "actor_id": "lead"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest as self: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/attestations/bulk", map[string]any{"items": []map[string]any{
+		{"entity_kind": "task", "entity_id": "ob-1", "kind": "ci.passed", "actor_id": "ci-bot"},
+	}}, lead)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"permission":"attestation.on_behalf"`) {
+		t.Fatalf("expected the bulk item refused, got %d %s", res.StatusCode, string(data))
+	}
+
+	// The owner holds the permission; the attributed actor still needs authority for the kind.
+	res, data = attest(nil, map[string]any{"kind": "ci.passed", "actor_id": "ci-bot"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest on behalf: %d %s", res.StatusCode, string(data))
+	}
+	var att AttestationResponse
+	if err := json.Unmarshal(data, &att); err != nil {
+		t.Fatalf("unmarshal attestation: %v", err)
+	}
+	if att.ActorID != "ci-bot" {
+		t.Fatalf("expected the attestation attributed to ci-bot, got %+v", att)
+	}
+	res, data = attest(nil, map[string]any{"kind": "review.approved", "actor_id": "ci-bot"})
+	if res.StatusCode != http.StatusForbidden || !strings.Contains(string(data), "forbidden_attestation_kind") {
+		t.Fatalf("expected ci-bot to lack review authority, got %d %s", res.StatusCode, string(data))
+	}
+
+	// The event names the caller and who the proof was recorded for.
+	res, data = doJSON(t, client, http.MethodGet, base+"/events?type=attestation.added", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"on_behalf_of":"ci-bot"`) || !strings.Contains(string(data), `"actor_id":"tester"`) {
+		t.Fatalf("expected the event to record the caller and on_behalf_of, got %d %s", res.StatusCode, string(data))
 	}
 }
 
-func TestForceRequiresPermission(t *testing.T) {
+func TestIterationKeyResults(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
-	projectID := "workline"
 	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
 
-	taskRes, taskData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Needs force",
-		"type":  "technical",
+	res, data := doJSON(t, client, http.MethodPost, base+"/iterations", map[string]any{
+		"id":   "iter-kr",
+		"goal": "Faster checkout",
+		"key_results": []map[string]any{
+			{"metric": "p95_ms", "target": 200, "current": 340, "direction": "decrease", "source_kind": "ci.passed"},
+		},
 	}, nil)
-	if taskRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create task: %d %s", taskRes.StatusCode, string(taskData))
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create iteration: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, base+"/iterations/iter-kr/key-results", map[string]any{
+		"key_results": []map[string]any{{"metric": "coverage", "target": 80, "current": 70}},
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("add key result: %d %s", res.StatusCode, string(data))
+	}
+	var it IterationResponse
+	_ = json.Unmarshal(data, &it)
+	if len(it.KeyResults) != 2 || it.KeyResults[0].Met || it.KeyResults[1].Direction != "increase" || it.KeyResults[1].Met {
+		t.Fatalf("unexpected key results: %+v", it.KeyResults)
+	}
+	res, data = doJSON(t, client, http.MethodPatch, base+"/iterations/iter-kr/key-results", map[string]any{
+		"key_results": []map[string]any{{"metric": "latency"}},
+	}, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a key result without target, got %d %s", res.StatusCode, string(data))
 	}
-	var task TaskResponse
-	_ = json.Unmarshal(taskData, &task)
 
-	grantRes, grantData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/rbac/roles/grant", map[string]any{
-		"actor_id": "force-dev",
-		"role_id":  "dev",
+	res, data = doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{
+		"entity_kind": "iteration", "entity_id": "iter-kr", "kind": "ci.passed", "payload": map[string]any{"p95_ms": 180},
 	}, nil)
-	if grantRes.StatusCode != http.StatusOK && grantRes.StatusCode != http.StatusNoContent {
-		t.Fatalf("grant role: %d %s", grantRes.StatusCode, string(grantData))
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("attest: %d %s", res.StatusCode, string(data))
+	}
+	var att AttestationResponse
+	_ = json.Unmarshal(data, &att)
+	for _, status := range []string{"running", "delivered"} {
+		if res, data := doJSON(t, client, http.MethodPatch, base+"/iterations/iter-kr/status", map[string]any{"status": status}, nil); res.StatusCode != http.StatusOK {
+			t.Fatalf("set %s: %d %s", status, res.StatusCode, string(data))
+		}
+	}
+	if res, data := doJSON(t, client, http.MethodPost, base+"/attestations", map[string]any{"entity_kind": "iteration", "entity_id": "iter-kr", "kind": "iteration.approved"}, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("approve: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPatch, base+"/iterations/iter-kr/status", map[string]any{"status": "validated"}, nil)
+	if res.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(data), "key results coverage not met") {
+		t.Fatalf("expected unmet coverage to block validation, got %d %s", res.StatusCode, string(data))
 	}
 
-	doneRes, doneData := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/done?force=true", map[string]any{
-		"work_outcomes": map[string]any{"note": "force"},
-	}, bearerHeader(srv.bearerToken(t, "force-dev", "default-org", time.Now().Add(time.Hour))))
-	if doneRes.StatusCode != http.StatusForbidden {
-		t.Fatalf("expected 403, got %d: %s", doneRes.StatusCode, string(doneData))
+	res, data = doJSON(t, client, http.MethodPatch, base+"/iterations/iter-kr/key-results", map[string]any{
+		"key_results": []map[string]any{{"metric": "coverage", "current": 84}},
+	}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("update coverage: %d %s", res.StatusCode, string(data))
 	}
-	var apiErr struct {
-		Error apiErrorBody `json:"error"`
+	it = IterationResponse{}
+	_ = json.Unmarshal(data, &it)
+	p95 := it.KeyResults[0]
+	if p95.Current != 180 || !p95.Met || p95.AttestationID != att.ID || !it.KeyResults[1].Met {
+		t.Fatalf("unexpected key results: %+v", it.KeyResults)
 	}
-	_ = json.Unmarshal(doneData, &apiErr)
-	if apiErr.Error.Code != "forbidden" {
-		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	res, data = doJSON(t, client, http.MethodPatch, base+"/iterations/iter-kr/status", map[string]any{"status": "validated"}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("validate: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/events?type=iteration.validation.checked", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), "coverage") {
+		t.Fatalf("validation event lacks key results: %d %s", res.StatusCode, string(data))
 	}
 }
 
-func TestCreateTaskValidation(t *testing.T) {
+func TestDigests(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
-	projectID := "workline"
 	client := srv.Client()
+	base := srv.URL + "/v0/projects/workline"
+	today := time.Now().UTC().Format(time.DateOnly)
 
-	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{}, nil)
+	res, data := doJSON(t, client, http.MethodPost, base+"/digests", map[string]any{"day": today}, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate digest: %d %s", res.StatusCode, string(data))
+	}
+	var d engine.Digest
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Day != today || d.CompletedTasks == nil || d.StuckLeases == nil {
+		t.Fatalf("unexpected digest %+v", d)
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/digests", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)) {
+		t.Fatalf("expected yesterday's digest by default: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, base+"/digests", map[string]any{"day": "2999-01-01"}, nil)
 	if res.StatusCode != http.StatusBadRequest {
-		t.Fatalf("expected 400, got %d: %s", res.StatusCode, string(data))
+		t.Fatalf("expected future day to be rejected: %d %s", res.StatusCode, string(data))
 	}
-	var apiErr struct {
-		Error apiErrorBody `json:"error"`
+
+	res, data = doJSON(t, client, http.MethodGet, base+"/digests/"+today, nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"validation_failures":[]`) {
+		t.Fatalf("get digest: %d %s", res.StatusCode, string(data))
 	}
-	_ = json.Unmarshal(data, &apiErr)
-	if apiErr.Error.Code != "bad_request" {
-		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	res, data = doJSON(t, client, http.MethodGet, base+"/digests/2020-01-01", nil, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected missing digest to be 404: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v1/projects/workline/digests", nil, nil)
+	var page struct {
+		Items []engine.Digest `json:"items"`
+	}
+	if err := json.Unmarshal(data, &page); err != nil || res.StatusCode != http.StatusOK || len(page.Items) != 2 || page.Items[0].Day != today {
+		t.Fatalf("list digests: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, base+"/events?type=digest.generated", nil, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"stuck_leases":0`) {
+		t.Fatalf("expected digest.generated events: %d %s", res.StatusCode, string(data))
 	}
 }
 
-func TestDoneTaskRequiresValidation(t *testing.T) {
+func TestFeatureFlags(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
-	projectID := "workline"
 	client := srv.Client()
+	ctx := context.Background()
+	base := srv.URL + "/v0/projects/workline"
 
-	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Needs validation",
-		"type":  "feature",
-	}, nil)
-	if createRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
-	}
-	var task TaskResponse
-	_ = json.Unmarshal(data, &task)
-
-	patchRes, patchBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID, map[string]any{
-		"validation": map[string]any{
-			"require": []string{"ci.passed"},
-		},
-	}, nil)
-	if patchRes.StatusCode != http.StatusOK {
-		t.Fatalf("patch validation: %d %s", patchRes.StatusCode, string(patchBody))
+	res, data := doJSON(t, client, http.MethodGet, base+"/features", nil, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list features: %d %s", res.StatusCode, string(data))
 	}
-
-	claimRes, claimBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/claim", nil, nil)
-	if claimRes.StatusCode != http.StatusOK {
-		t.Fatalf("claim lease: %d %s", claimRes.StatusCode, string(claimBody))
+	var features ProjectFeaturesResponse
+	_ = json.Unmarshal(data, &features)
+	if features.ProjectID != "workline" || len(features.Features) != 2 ||
+		features.Features[0].Name != "graphql" || features.Features[0].Enabled || features.Features[0].Reason != "not served by this server" ||
+		features.Features[1].Name != "lease_queue" || !features.Features[1].Enabled {
+		t.Fatalf("unexpected features: %s", string(data))
 	}
 
-	doneRes, doneBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/done", map[string]any{
-		"work_outcomes": map[string]any{"note": "testing"},
-	}, nil)
-	if doneRes.StatusCode != http.StatusUnprocessableEntity {
-		t.Fatalf("expected 422, got %d: %s", doneRes.StatusCode, string(doneBody))
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks", map[string]any{"id": "ff-1", "title": "Flagged", "type": "technical"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
 	}
-	var apiErr struct {
-		Error apiErrorBody `json:"error"`
+	cfg, err := srv.engine.Repo.GetProjectConfig(ctx, "workline")
+	if err != nil {
+		t.Fatalf("project config: %v", err)
 	}
-	_ = json.Unmarshal(doneBody, &apiErr)
-	if apiErr.Error.Code != "validation_failed" {
-		t.Fatalf("unexpected error code: %s", apiErr.Error.Code)
+	cfg.Flags = map[string]bool{"graphql": false, "lease_queue": false}
+	if err := srv.engine.Repo.UpsertProjectConfig(ctx, "workline", cfg); err != nil {
+		t.Fatalf("store flags: %v", err)
 	}
-}
 
-func TestConfigEndpoint(t *testing.T) {
-	srv, cleanup := newTestServer(t)
-	defer cleanup()
-	projectID := "workline"
-	client := srv.Client()
+	res, data = doJSON(t, client, http.MethodPost, base+"/tasks/ff-1/claim?wait=true", nil, nil)
+	if res.StatusCode != http.StatusNotFound || !strings.Contains(string(data), `"code":"feature_disabled"`) || !strings.Contains(string(data), `"feature":"lease_queue"`) {
+		t.Fatalf("expected queued claim to be refused: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, base+"/tasks/ff-1/claim", nil, nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("plain claim: %d %s", res.StatusCode, string(data))
+	}
 
-	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/config", nil, nil)
-	if res.StatusCode != http.StatusOK {
-		t.Fatalf("config status %d: %s", res.StatusCode, string(data))
+	handler, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: AuthConfig{JWTSecret: srv.jwtSecret}, GraphQL: true})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
 	}
-	var cfg ProjectConfigResponse
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		t.Fatalf("unmarshal config: %v", err)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	headers := bearerHeader(srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour)))
+	res, data = doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v0/projects/workline/features", nil, headers)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"reason":"disabled by the project's flags"`) {
+		t.Fatalf("expected flags in features: %d %s", res.StatusCode, string(data))
 	}
-	if len(cfg.Policies.Presets) == 0 || cfg.Policies.Defaults.Task["feature"] == "" {
-		t.Fatalf("config missing presets/defaults: %+v", cfg)
+	res, data = doJSON(t, ts.Client(), http.MethodPost, ts.URL+"/v0/graphql", map[string]any{"query": `{ task(id: "ff-1") { id } }`}, headers)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"task":null`) || !strings.Contains(string(data), `"code":"feature_disabled"`) {
+		t.Fatalf("expected GraphQL to be off for the project: %d %s", res.StatusCode, string(data))
 	}
 }
 
-func TestValidationEndpoint(t *testing.T) {
+func TestChaosMode(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
-	projectID := "workline"
-	client := srv.Client()
+	ctx := context.Background()
+	auth := AuthConfig{JWTSecret: srv.jwtSecret}
+	token := bearerHeader(srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour)))
 
-	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Validate me",
-		"type":  "feature",
-	}, nil)
-	if createRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	if _, err := New(Config{Engine: srv.engine, Auth: auth, Chaos: ChaosConfig{ErrorRate: 1.5}}); err == nil {
+		t.Fatalf("expected an error rate above 1 to be rejected")
 	}
-	var task TaskResponse
-	_ = json.Unmarshal(data, &task)
 
-	patchRes, patchBody := doJSON(t, client, http.MethodPatch, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID, map[string]any{
-		"validation": map[string]any{
-			"require": []string{"ci.passed", "review.approved"},
-		},
-	}, nil)
-	if patchRes.StatusCode != http.StatusOK {
-		t.Fatalf("patch validation: %d %s", patchRes.StatusCode, string(patchBody))
+	failing, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: auth, Chaos: ChaosConfig{ErrorRate: 1, Seed: 7}})
+	if err != nil {
+		t.Fatalf("build chaos handler: %v", err)
+	}
+	ts := httptest.NewServer(failing)
+	defer ts.Close()
+	res, data := doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v0/health", nil, nil)
+	if res.StatusCode != http.StatusOK || res.Header.Get(ChaosHeader) != "" {
+		t.Fatalf("expected health check left alone, got %d %s", res.StatusCode, string(data))
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 12; i++ {
+		id := fmt.Sprintf("chaos-%d", i)
+		res, data := doJSON(t, ts.Client(), http.MethodPost, ts.URL+"/v0/projects/workline/tasks", map[string]any{"id": id, "title": "chaos", "type": "bug"}, token)
+		var apiErr struct {
+			Error apiErrorBody `json:"error"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		if res.StatusCode != http.StatusInternalServerError || apiErr.Error.Code != "chaos_injected" {
+			t.Fatalf("expected injected failure, got %d %s", res.StatusCode, string(data))
+		}
+		injected := res.Header.Get(ChaosHeader)
+		seen[injected] = true
+		_, err := srv.engine.Repo.GetTask(ctx, id)
+		switch injected {
+		case "error":
+			if err == nil || apiErr.Error.Details["processed"] != false {
+				t.Fatalf("expected %s not created: %v %s", id, err, string(data))
+			}
+		case "error_after":
+			if err != nil || apiErr.Error.Details["processed"] != true {
+				t.Fatalf("expected %s created before the response was dropped: %v %s", id, err, string(data))
+			}
+		default:
+			t.Fatalf("unexpected %s header %q", ChaosHeader, injected)
+		}
 	}
-
-	attRes, attBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/attestations", map[string]any{
-		"entity_kind": "task",
-		"entity_id":   task.ID,
-		"kind":        "ci.passed",
-	}, nil)
-	if attRes.StatusCode != http.StatusCreated {
-		t.Fatalf("attestation status %d: %s", attRes.StatusCode, string(attBody))
+	if !seen["error"] || !seen["error_after"] {
+		t.Fatalf("expected failures both before and after processing, got %v", seen)
 	}
 
-	valRes, valBody := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/validation", nil, nil)
-	if valRes.StatusCode != http.StatusOK {
-		t.Fatalf("validation status %d: %s", valRes.StatusCode, string(valBody))
+	res, data = doJSON(t, srv.Client(), http.MethodPost, srv.URL+"/v0/projects/workline/tasks", map[string]any{"id": "raced", "title": "raced", "type": "bug"}, nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create task: %d %s", res.StatusCode, string(data))
 	}
-	var status ValidationStatusResponse
-	if err := json.Unmarshal(valBody, &status); err != nil {
-		t.Fatalf("unmarshal validation: %v", err)
+	racing, err := New(Config{Engine: srv.engine, BasePath: "/v0", Auth: auth, Chaos: ChaosConfig{Latency: time.Millisecond, LeaseRaceRate: 1, Seed: 7}})
+	if err != nil {
+		t.Fatalf("build chaos handler: %v", err)
 	}
-	if status.Satisfied {
-		t.Fatalf("expected validation to be unsatisfied")
+	rs := httptest.NewServer(racing)
+	defer rs.Close()
+	res, data = doJSON(t, rs.Client(), http.MethodPost, rs.URL+"/v0/projects/workline/tasks/raced/claim", nil, token)
+	if res.StatusCode != http.StatusConflict || !strings.Contains(string(data), `"lease_conflict"`) {
+		t.Fatalf("expected injected lease race, got %d %s", res.StatusCode, string(data))
 	}
-	if len(status.Present) != 1 || len(status.Missing) != 1 {
-		t.Fatalf("unexpected present/missing: %+v", status)
+	if got := res.Header.Values(ChaosHeader); len(got) != 2 || !strings.HasPrefix(got[0], "latency=") || got[1] != "lease_race" {
+		t.Fatalf("unexpected %s headers %v", ChaosHeader, got)
+	}
+	if _, err := srv.engine.Repo.GetLease(ctx, "raced"); err == nil {
+		t.Fatalf("expected the raced claim not to run")
+	}
+	res, data = doJSON(t, rs.Client(), http.MethodGet, rs.URL+"/v0/projects/workline/tasks/raced", nil, token)
+	if res.StatusCode != http.StatusOK || res.Header.Values(ChaosHeader)[0] == "lease_race" {
+		t.Fatalf("expected reads to pass lease races, got %d %s", res.StatusCode, string(data))
 	}
 }
 
-func TestWorkOutcomesAppendEndpoint(t *testing.T) {
+func TestCORSAndSecurityHeaders(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
-	projectID := "workline"
-	client := srv.Client()
+	handler, err := New(Config{
+		Engine:          srv.engine,
+		BasePath:        "/v0",
+		Auth:            AuthConfig{JWTSecret: srv.jwtSecret},
+		CORS:            config.CORS{AllowedOrigins: []string{"https://dash.example.com"}, AllowCredentials: true},
+		SecurityHeaders: true,
+	})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	token := srv.bearerToken(t, "tester", "", time.Now().Add(time.Hour))
+	send := func(method, path string, headers map[string]string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		res.Body.Close()
+		return res
+	}
 
-	createRes, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-		"title": "Work outcomes append",
-		"type":  "docs",
-	}, nil)
-	if createRes.StatusCode != http.StatusCreated {
-		t.Fatalf("create task: %d %s", createRes.StatusCode, string(data))
+	// A preflight is answered without credentials.
+	res := send(http.MethodOptions, "/v1/projects/workline/tasks", map[string]string{
+		"Origin":                         "https://dash.example.com",
+		"Access-Control-Request-Method":  "PATCH",
+		"Access-Control-Request-Headers": "authorization, content-type",
+	})
+	if res.StatusCode != http.StatusNoContent || res.Header.Get("Access-Control-Allow-Origin") != "https://dash.example.com" {
+		t.Fatalf("expected allowed preflight, got %d %v", res.StatusCode, res.Header)
+	}
+	if !strings.Contains(res.Header.Get("Access-Control-Allow-Methods"), "PATCH") || res.Header.Get("Access-Control-Allow-Credentials") != "true" || res.Header.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("unexpected preflight headers: %v", res.Header)
+	}
+	res = send(http.MethodOptions, "/v1/projects/workline/tasks", map[string]string{
+		"Origin":                         "https://dash.example.com",
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "x-unknown",
+	})
+	if res.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected an undeclared request header refused, got %v", res.Header)
+	}
+	res = send(http.MethodOptions, "/v1/projects/workline/tasks", map[string]string{
+		"Origin":                        "https://evil.example.com",
+		"Access-Control-Request-Method": "GET",
+	})
+	if res.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected another origin refused, got %v", res.Header)
 	}
-	var task TaskResponse
-	_ = json.Unmarshal(data, &task)
 
-	appendRes, appendBody := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID+"/work-outcomes/append", map[string]any{
-		"path":  "actions",
-		"value": map[string]any{"note": "first"},
-	}, nil)
-	if appendRes.StatusCode != http.StatusOK {
-		t.Fatalf("append work outcomes: %d %s", appendRes.StatusCode, string(appendBody))
+	res = send(http.MethodGet, "/v1/projects/workline/tasks", map[string]string{"Origin": "https://dash.example.com", "Authorization": "Bearer " + token})
+	if res.StatusCode != http.StatusOK || res.Header.Get("Access-Control-Allow-Origin") != "https://dash.example.com" {
+		t.Fatalf("expected allowed cross-origin read, got %d %v", res.StatusCode, res.Header)
 	}
-	var appendResp WorkOutcomesUpdateResponse
-	if err := json.Unmarshal(appendBody, &appendResp); err != nil {
-		t.Fatalf("unmarshal append response: %v", err)
+	if !strings.Contains(res.Header.Get("Access-Control-Expose-Headers"), "X-Request-Id") || !slices.Contains(res.Header.Values("Vary"), "Origin") {
+		t.Fatalf("unexpected CORS headers: %v", res.Header)
 	}
-	if appendResp.Path != "actions" || appendResp.Length == nil || *appendResp.Length != 1 {
-		t.Fatalf("unexpected append response: %+v", appendResp)
+	if res.Header.Get("X-Content-Type-Options") != "nosniff" || res.Header.Get("X-Frame-Options") != "DENY" || res.Header.Get("Content-Security-Policy") == "" || res.Header.Get("Strict-Transport-Security") != "" {
+		t.Fatalf("unexpected security headers: %v", res.Header)
 	}
-
-	getRes, getBody := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks/"+task.ID, nil, nil)
-	if getRes.StatusCode != http.StatusOK {
-		t.Fatalf("get task: %d %s", getRes.StatusCode, string(getBody))
+	res = send(http.MethodGet, "/v1/projects/workline/tasks", map[string]string{"Origin": "https://evil.example.com", "Authorization": "Bearer " + token})
+	if res.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected no CORS headers for another origin, got %v", res.Header)
 	}
-	var updated TaskResponse
-	if err := json.Unmarshal(getBody, &updated); err != nil {
-		t.Fatalf("unmarshal task: %v", err)
+	res = send(http.MethodGet, "/docs", nil)
+	if res.Header.Get("Content-Security-Policy") != "" || res.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("expected docs page without the API content security policy, got %v", res.Header)
 	}
-	actions, ok := updated.WorkOutcomes["actions"].([]any)
-	if !ok || len(actions) != 1 {
-		t.Fatalf("expected one action in work_outcomes, got %+v", updated.WorkOutcomes)
+
+	res = send(http.MethodGet, "/versions", map[string]string{"Origin": "https://dash.example.com"})
+	if res.Header.Get("Access-Control-Allow-Origin") == "" {
+		t.Fatalf("expected the version listing to carry CORS headers, got %v", res.Header)
+	}
+
+	// The test server's handler is built without CORS or security headers.
+	res, _ = doJSON(t, srv.Client(), http.MethodGet, srv.URL+"/v0/health", nil, map[string]string{"Origin": "https://dash.example.com"})
+	if res.Header.Get("Access-Control-Allow-Origin") != "" || res.Header.Get("X-Frame-Options") != "" {
+		t.Fatalf("expected no CORS or security headers by default, got %v", res.Header)
 	}
 }
 
-func TestPaginationProvidesCursor(t *testing.T) {
+func TestOrgScoping(t *testing.T) {
 	srv, cleanup := newTestServer(t)
 	defer cleanup()
-	projectID := "workline"
 	client := srv.Client()
+	token := func(actor, org string) map[string]string {
+		return bearerHeader(srv.bearerToken(t, actor, org, time.Now().Add(time.Hour)))
+	}
+	owner := token("tester", "team-b")
 
-	for i := 0; i < 3; i++ {
-		res, body := doJSON(t, client, http.MethodPost, srv.URL+"/v0/projects/"+projectID+"/tasks", map[string]any{
-			"title": fmt.Sprintf("Task %d", i),
-			"type":  "technical",
-		}, nil)
-		if res.StatusCode != http.StatusCreated {
-			t.Fatalf("create task %d: %d %s", i, res.StatusCode, string(body))
-		}
+	res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/orgs", map[string]any{"id": "team-b", "name": "Team B"}, nil)
+	if res.StatusCode != http.StatusCreated || !strings.Contains(string(data), `"name":"Team B"`) {
+		t.Fatalf("create org: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/orgs", map[string]any{"id": "team-b"}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected duplicate org refused, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodPost, srv.URL+"/v0/orgs/team-b/projects", map[string]any{"id": "b-proj"}, owner)
+	var project ProjectResponse
+	_ = json.Unmarshal(data, &project)
+	if res.StatusCode != http.StatusCreated || project.OrgID != "team-b" {
+		t.Fatalf("create org project: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/orgs/team-b/projects", map[string]any{"id": "b-child", "parent_project_id": "workline"}, owner); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a parent of another org hidden, got %d %s", res.StatusCode, string(data))
 	}
 
-	res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/"+projectID+"/tasks?limit=1", nil, nil)
-	if res.StatusCode != http.StatusOK {
-		t.Fatalf("list tasks: %d %s", res.StatusCode, string(data))
+	// Credentials reach only the projects of their org.
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/b-proj", nil, owner); res.StatusCode != http.StatusOK {
+		t.Fatalf("read own org project: %d %s", res.StatusCode, string(data))
 	}
-	var page paginatedTasks
-	if err := json.Unmarshal(data, &page); err != nil {
-		t.Fatalf("unmarshal page: %v", err)
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/workline/tasks", nil, owner); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected default org project hidden from team-b credentials, got %d %s", res.StatusCode, string(data))
 	}
-	if len(page.Items) != 1 {
-		t.Fatalf("expected 1 item, got %d", len(page.Items))
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/b-proj", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected team-b project hidden from default org credentials, got %d %s", res.StatusCode, string(data))
 	}
-	if page.NextCursor == "" {
-		t.Fatalf("expected next_cursor to be set")
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects", nil, owner); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected server-wide project list refused to team-b credentials, got %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/orgs/team-b/projects", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected team-b hidden from default org credentials, got %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v1/orgs/team-b/projects", nil, owner)
+	var listed struct {
+		Items []ProjectResponse `json:"items"`
+	}
+	_ = json.Unmarshal(data, &listed)
+	if res.StatusCode != http.StatusOK || len(listed.Items) != 1 || listed.Items[0].ID != "b-proj" {
+		t.Fatalf("list org projects: %d %s", res.StatusCode, string(data))
+	}
+
+	// Org roles: members read, owners manage.
+	res, data = doJSON(t, client, http.MethodPut, srv.URL+"/v0/orgs/team-b/members/bob", map[string]any{"role": "member"}, owner)
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(data), `"role":"member"`) {
+		t.Fatalf("add member: %d %s", res.StatusCode, string(data))
+	}
+	bob := token("bob", "team-b")
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/orgs/team-b", nil, bob); res.StatusCode != http.StatusOK {
+		t.Fatalf("member reads org: %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodPost, srv.URL+"/v0/orgs/team-b/projects", map[string]any{"id": "bob-proj"}, bob); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected member refused project creation, got %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/projects/b-proj/tasks", nil, bob); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected org membership to grant nothing in projects, got %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodGet, srv.URL+"/v0/orgs/team-b", nil, token("carol", "team-b")); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected outsider refused, got %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodDelete, srv.URL+"/v0/orgs/team-b/members/tester", nil, owner); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected last owner kept, got %d %s", res.StatusCode, string(data))
+	}
+	if res, data := doJSON(t, client, http.MethodDelete, srv.URL+"/v0/orgs/team-b/members/bob", nil, owner); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("remove member: %d %s", res.StatusCode, string(data))
+	}
+	res, data = doJSON(t, client, http.MethodGet, srv.URL+"/v0/orgs/team-b/members", nil, owner)
+	if res.StatusCode != http.StatusOK || strings.Contains(string(data), "bob") || !strings.Contains(string(data), `"actor_id":"tester"`) {
+		t.Fatalf("list members: %d %s", res.StatusCode, string(data))
+	}
+
+	// Each org change is audited under the org.
+	rows, err := srv.engine.DB.Query(`SELECT type, actor_id, payload_json FROM events WHERE org_id=? AND project_id IS NULL ORDER BY id`, "team-b")
+	if err != nil {
+		t.Fatalf("query org events: %v", err)
+	}
+	defer rows.Close()
+	var audit []string
+	for rows.Next() {
+		var typ, actor, payload string
+		if err := rows.Scan(&typ, &actor, &payload); err != nil {
+			t.Fatalf("scan org event: %v", err)
+		}
+		audit = append(audit, typ+" "+actor+" "+payload)
+	}
+	if len(audit) != 3 ||
+		!strings.HasPrefix(audit[0], `org.created tester `) ||
+		!strings.Contains(audit[1], `org.role_set tester `) || !strings.Contains(audit[1], `"member":"bob"`) || !strings.Contains(audit[1], `"role":"member"`) ||
+		!strings.Contains(audit[2], `org.member_removed tester `) || !strings.Contains(audit[2], `"member":"bob"`) {
+		t.Fatalf("unexpected org audit: %q", audit)
 	}
 }
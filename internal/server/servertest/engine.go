@@ -0,0 +1,1039 @@
+package servertest
+
+import (
+	"context"
+	"time"
+
+	"workline/internal/blob"
+	"workline/internal/config"
+	"workline/internal/db"
+	"workline/internal/domain"
+	"workline/internal/engine"
+	"workline/internal/events"
+	"workline/internal/integrations"
+	"workline/internal/manifest"
+	"workline/internal/repo"
+	"workline/internal/seed"
+	"workline/internal/server"
+)
+
+// Engine is a server.Engine whose methods call its Func fields. The zero value is ready
+// to use: its Store stubs nothing and its default project is "workline".
+type Engine struct {
+	calls
+	// Repo is returned by Store; nil returns an empty Store.
+	Repo *Store
+	// Config is returned by DefaultConfig; nil returns config.Default("workline").
+	Config *config.Config
+	// Blobs is returned by BlobStore.
+	Blobs blob.Store
+
+	ActorHasPermissionFunc         func(ctx context.Context, projectID, actorID, perm string) (bool, error)
+	RecordDenialFunc               func(ctx context.Context, projectID, actorID string, payload events.EventPayload) error
+	InitProjectFunc                func(ctx context.Context, projectID, description, actorID string) (domain.Project, error)
+	InitProjectWithOptionsFunc     func(ctx context.Context, opts engine.ProjectInitOptions) (domain.Project, error)
+	EnsureProjectFunc              func(ctx context.Context, opts engine.ProjectInitOptions) (domain.Project, bool, error)
+	UpdateProjectFunc              func(ctx context.Context, opts engine.ProjectUpdateOptions) (domain.Project, error)
+	SetProjectParentFunc           func(ctx context.Context, projectID, parentID, actorID string) (domain.Project, error)
+	ProjectFeaturesFunc            func(ctx context.Context, projectID string) ([]engine.FeatureState, error)
+	RequireFeatureFunc             func(ctx context.Context, projectID, feature string) error
+	SummarizeProgramFunc           func(ctx context.Context, programID string, visible func(projectID string) bool) (engine.ProgramSummary, error)
+	ApplySeedFunc                  func(ctx context.Context, projectID, actorID string, s seed.File) (engine.SeedResult, error)
+	SyncProjectFunc                func(ctx context.Context, projectID, actorID string, m manifest.File, dryRun bool) (engine.SyncPlan, error)
+	PlanConfigChangeFunc           func(ctx context.Context, projectID string, next *config.Config) (engine.ConfigPlan, error)
+	UpdateProjectConfigFunc        func(ctx context.Context, projectID, actorID string, next *config.Config) (engine.ConfigPlan, error)
+	WhoAmIFunc                     func(ctx context.Context, projectID, actorID string) (engine.WhoAmI, error)
+	CreateOrgFunc                  func(ctx context.Context, id, name, actorID string) (domain.Org, error)
+	GetOrgFunc                     func(ctx context.Context, orgID, actorID string) (domain.Org, error)
+	ListOrgProjectsFunc            func(ctx context.Context, orgID, actorID string) ([]domain.Project, error)
+	ListOrgMembersFunc             func(ctx context.Context, orgID, actorID string) ([]domain.OrgMember, error)
+	SetOrgRoleFunc                 func(ctx context.Context, orgID, target, role, actorID string) (domain.OrgMember, error)
+	RemoveOrgMemberFunc            func(ctx context.Context, orgID, target, actorID string) error
+	CreateTaskFunc                 func(ctx context.Context, opts engine.TaskCreateOptions) (domain.Task, error)
+	UpdateTaskFunc                 func(ctx context.Context, opts engine.TaskUpdateOptions) (domain.Task, error)
+	MoveTaskFunc                   func(ctx context.Context, opts engine.TaskMoveOptions) (domain.Task, error)
+	CancelTaskFunc                 func(ctx context.Context, opts engine.TaskCancelOptions) (engine.TaskCancellation, error)
+	TaskDoneFunc                   func(ctx context.Context, taskID, workOutcomesJSON, actorID string, force bool) (domain.Task, error)
+	ApproveTaskCompletionFunc      func(ctx context.Context, taskID, actorID string) (domain.Task, error)
+	RejectTaskCompletionFunc       func(ctx context.Context, taskID, actorID, reason string) (domain.Task, error)
+	AddTaskLinkFunc                func(ctx context.Context, taskID, kind, target, actorID string) (domain.TaskLink, error)
+	RemoveTaskLinkFunc             func(ctx context.Context, taskID string, linkID int64, actorID string) error
+	SyncTaskLinksFunc              func(ctx context.Context, projectID string, evt integrations.Event, actorID string) ([]domain.TaskLink, error)
+	ReadyTasksFunc                 func(ctx context.Context, projectID, actorID string) ([]domain.Task, error)
+	CaptureTaskSnapshotFunc        func(ctx context.Context, scope, actorID string, f repo.TaskFilters) (domain.TaskListSnapshot, error)
+	TaskSnapshotPageFunc           func(ctx context.Context, id, scope, actorID string, after, limit int) (domain.TaskListSnapshot, []domain.Task, error)
+	ImportDependenciesFunc         func(ctx context.Context, projectID string, edges []engine.DependencyEdge, actorID string) (engine.DependencyImport, error)
+	AssignTaskFunc                 func(ctx context.Context, opts engine.TaskAssignOptions) (domain.TaskAssignee, error)
+	UnassignTaskFunc               func(ctx context.Context, opts engine.TaskAssignOptions) error
+	WaiveValidationFunc            func(ctx context.Context, opts engine.WaiverCreateOptions) (domain.Waiver, error)
+	ActiveWaiversFunc              func(ctx context.Context, taskID string) ([]domain.Waiver, error)
+	PresentRequirementsFunc        func(ctx context.Context, taskID string, required []string) (map[string]bool, error)
+	RequirementSourcesFunc         func(ctx context.Context, t domain.Task) ([]engine.RequirementSource, error)
+	TaskRiskFunc                   func(ctx context.Context, t domain.Task) (*engine.TaskRiskReport, error)
+	TaskEvidenceFunc               func(ctx context.Context, taskID string) (engine.EvidenceBundle, error)
+	ExportSubtreeFunc              func(ctx context.Context, taskID string) (engine.SubtreeSnapshot, error)
+	TaskTreeFunc                   func(ctx context.Context, f repo.TaskFilters) ([]engine.TaskTreeNode, error)
+	ImportSubtreeFunc              func(ctx context.Context, projectID string, snap engine.SubtreeSnapshot, opts engine.SubtreeImportOptions, actorID string) (engine.SubtreeImport, error)
+	ClaimLeaseFunc                 func(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error)
+	ClaimLeaseWithOptionsFunc      func(ctx context.Context, opts engine.LeaseClaimOptions) (engine.LeaseClaim, error)
+	ClaimNextFunc                  func(ctx context.Context, projectID, actorID string, leaseSeconds int) (domain.Task, domain.Lease, error)
+	DeferTaskFunc                  func(ctx context.Context, taskID, until, actorID string) (domain.Task, error)
+	ReleaseLeaseFunc               func(ctx context.Context, taskID, actorID string) error
+	TaskLeaseFunc                  func(ctx context.Context, taskID, actorID string) (domain.Lease, error)
+	ProjectLeasesFunc              func(ctx context.Context, projectID, actorID string, f engine.LeaseFilter) ([]domain.Lease, error)
+	LeaseWaitersFunc               func(ctx context.Context, taskID, actorID string) ([]domain.LeaseWaiter, error)
+	LeaveLeaseQueueFunc            func(ctx context.Context, taskID, actorID string) error
+	TransferLeaseFunc              func(ctx context.Context, opts engine.LeaseTransferOptions) (domain.Lease, error)
+	AcceptLeaseTransferFunc        func(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error)
+	DeclineLeaseTransferFunc       func(ctx context.Context, taskID, actorID string) (domain.Lease, error)
+	ClaimReviewLeaseFunc           func(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error)
+	ReleaseReviewLeaseFunc         func(ctx context.Context, taskID, actorID string) error
+	CreateIterationFunc            func(ctx context.Context, it domain.Iteration, actorID string) (domain.Iteration, error)
+	SetIterationStatusFunc         func(ctx context.Context, id, status, actorID string, force bool) (domain.Iteration, error)
+	UpdateIterationKeyResultsFunc  func(ctx context.Context, iterationID string, patches []engine.KeyResultPatch, actorID string) (domain.Iteration, error)
+	CarryOverIterationFunc         func(ctx context.Context, sourceID, targetID, freezeOverride, actorID string) (engine.CarryOverResult, error)
+	SetIterationFrozenFunc         func(ctx context.Context, id string, frozen bool, actorID string) (domain.Iteration, error)
+	CreateDecisionFunc             func(ctx context.Context, d domain.Decision, actorID string) (domain.Decision, error)
+	AddAttestationFunc             func(ctx context.Context, att domain.Attestation, actorID string) (domain.Attestation, error)
+	AddAttestationsFunc            func(ctx context.Context, projectID string, atts []domain.Attestation, actorID string, atomic bool) (engine.BulkAttestationOutcome, error)
+	AttestationRequiredByFunc      func(ctx context.Context, projectID, kind string) (engine.AttestationBacklog, error)
+	DeprecatedKindWarningFunc      func(kind string) string
+	AllowAttestationRoleFunc       func(ctx context.Context, projectID, actorID, kind, roleID string) error
+	DenyAttestationRoleFunc        func(ctx context.Context, projectID, actorID, kind, roleID string) error
+	AttestationAuthorityReportFunc func(ctx context.Context, projectID string) (engine.AttestationAuthorityReport, error)
+	UploadArtifactFunc             func(ctx context.Context, opts engine.ArtifactUploadOptions) (domain.Artifact, error)
+	ArtifactContentFunc            func(ctx context.Context, a domain.Artifact) ([]byte, error)
+	CreateViewFunc                 func(ctx context.Context, v domain.View, actorID string) (domain.View, error)
+	DeleteViewFunc                 func(ctx context.Context, projectID, id, actorID string) error
+	GetVisibleViewFunc             func(ctx context.Context, projectID, id, actorID string) (domain.View, error)
+	VisibleViewsFunc               func(ctx context.Context, projectID, actorID string) ([]domain.View, error)
+	GrantRoleUntilFunc             func(ctx context.Context, projectID, actorID, targetActor, roleID, expiresAt string) error
+	RevokeRoleFunc                 func(ctx context.Context, projectID, actorID, targetActor, roleID string) error
+	CreateTeamFunc                 func(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error)
+	UpdateTeamFunc                 func(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error)
+	DeleteTeamFunc                 func(ctx context.Context, projectID, teamID, actorID string) error
+	AddTeamMemberFunc              func(ctx context.Context, projectID, teamID, memberID, actorID string) error
+	RemoveTeamMemberFunc           func(ctx context.Context, projectID, teamID, memberID, actorID string) error
+	GrantTeamRoleUntilFunc         func(ctx context.Context, projectID, actorID, teamID, roleID, expiresAt string) error
+	RevokeTeamRoleFunc             func(ctx context.Context, projectID, actorID, teamID, roleID string) error
+	CreateEscalationRuleFunc       func(ctx context.Context, rule domain.EscalationRule, actorID string) (domain.EscalationRule, error)
+	UpdateEscalationRuleFunc       func(ctx context.Context, projectID, ruleID string, u engine.EscalationRuleUpdate, actorID string) (domain.EscalationRule, error)
+	DeleteEscalationRuleFunc       func(ctx context.Context, projectID, ruleID, actorID string) error
+	RotateSecretFunc               func(ctx context.Context, projectID, kind, name string, overlap time.Duration, actorID string) (engine.SecretRotation, error)
+	RevokeSecretFunc               func(ctx context.Context, projectID, secretID, actorID string) (domain.ProjectSecret, error)
+	SetActorCapabilitiesFunc       func(ctx context.Context, projectID, target string, caps []string, actorID string) ([]string, error)
+	ReadNotificationFunc           func(ctx context.Context, projectID string, id int64, actorID string) (domain.Notification, error)
+	ReadAllNotificationsFunc       func(ctx context.Context, projectID, actorID string) (int, error)
+	SetEmailPreferencesFunc        func(ctx context.Context, prefs domain.EmailPreferences) (domain.EmailPreferences, error)
+	ComplianceReportFunc           func(ctx context.Context, projectID string, from, to time.Time) (engine.ComplianceReport, error)
+	AggregateEventsFunc            func(ctx context.Context, projectID, bucket string, types []string, from, to time.Time) (engine.EventAggregate, error)
+	VerifyEventChainFunc           func(ctx context.Context, projectID string) (engine.EventChainReport, error)
+	GenerateDigestFunc             func(ctx context.Context, projectID string, day time.Time, actorID string) (engine.Digest, error)
+	GetDigestFunc                  func(ctx context.Context, projectID, day string) (engine.Digest, error)
+	ListDigestsFunc                func(ctx context.Context, projectID, from, to string) ([]engine.Digest, error)
+	RecordUsageFunc                func(ctx context.Context, projectID, actorID string, mutation bool) error
+	UsageFunc                      func(ctx context.Context, projectID, actorID string, from, to time.Time) (engine.UsageReport, error)
+	CycleTimeFunc                  func(ctx context.Context, opts engine.CycleTimeOptions) (engine.CycleTimeReport, error)
+	CheckConsistencyFunc           func(ctx context.Context) (engine.ConsistencyReport, error)
+	RepairConsistencyFunc          func(ctx context.Context, checks []string, actorID string) (engine.ConsistencyReport, error)
+	VacuumFunc                     func(ctx context.Context, full bool, pages int) (db.VacuumResult, error)
+	CheckIntegrityFunc             func(ctx context.Context, quick bool) (db.IntegrityResult, error)
+}
+
+var _ server.Engine = (*Engine)(nil)
+
+func (m *Engine) Store() repo.Store {
+	if m.Repo == nil {
+		return &Store{}
+	}
+	return m.Repo
+}
+
+func (m *Engine) DefaultConfig() *config.Config {
+	if m.Config == nil {
+		return config.Default("workline")
+	}
+	return m.Config
+}
+
+func (m *Engine) BlobStore() blob.Store { return m.Blobs }
+
+func (m *Engine) ActorHasPermission(ctx context.Context, projectID, actorID, perm string) (bool, error) {
+	m.record("ActorHasPermission")
+	if m.ActorHasPermissionFunc == nil {
+		return zero[bool](), notStubbed("ActorHasPermission")
+	}
+	return m.ActorHasPermissionFunc(ctx, projectID, actorID, perm)
+}
+
+func (m *Engine) RecordDenial(ctx context.Context, projectID, actorID string, payload events.EventPayload) error {
+	m.record("RecordDenial")
+	if m.RecordDenialFunc == nil {
+		return notStubbed("RecordDenial")
+	}
+	return m.RecordDenialFunc(ctx, projectID, actorID, payload)
+}
+
+func (m *Engine) InitProject(ctx context.Context, projectID, description, actorID string) (domain.Project, error) {
+	m.record("InitProject")
+	if m.InitProjectFunc == nil {
+		return zero[domain.Project](), notStubbed("InitProject")
+	}
+	return m.InitProjectFunc(ctx, projectID, description, actorID)
+}
+
+func (m *Engine) InitProjectWithOptions(ctx context.Context, opts engine.ProjectInitOptions) (domain.Project, error) {
+	m.record("InitProjectWithOptions")
+	if m.InitProjectWithOptionsFunc == nil {
+		return zero[domain.Project](), notStubbed("InitProjectWithOptions")
+	}
+	return m.InitProjectWithOptionsFunc(ctx, opts)
+}
+
+func (m *Engine) EnsureProject(ctx context.Context, opts engine.ProjectInitOptions) (domain.Project, bool, error) {
+	m.record("EnsureProject")
+	if m.EnsureProjectFunc == nil {
+		return zero[domain.Project](), false, notStubbed("EnsureProject")
+	}
+	return m.EnsureProjectFunc(ctx, opts)
+}
+
+func (m *Engine) UpdateProject(ctx context.Context, opts engine.ProjectUpdateOptions) (domain.Project, error) {
+	m.record("UpdateProject")
+	if m.UpdateProjectFunc == nil {
+		return zero[domain.Project](), notStubbed("UpdateProject")
+	}
+	return m.UpdateProjectFunc(ctx, opts)
+}
+
+func (m *Engine) SetProjectParent(ctx context.Context, projectID, parentID, actorID string) (domain.Project, error) {
+	m.record("SetProjectParent")
+	if m.SetProjectParentFunc == nil {
+		return zero[domain.Project](), notStubbed("SetProjectParent")
+	}
+	return m.SetProjectParentFunc(ctx, projectID, parentID, actorID)
+}
+
+func (m *Engine) ProjectFeatures(ctx context.Context, projectID string) ([]engine.FeatureState, error) {
+	m.record("ProjectFeatures")
+	if m.ProjectFeaturesFunc == nil {
+		return zero[[]engine.FeatureState](), notStubbed("ProjectFeatures")
+	}
+	return m.ProjectFeaturesFunc(ctx, projectID)
+}
+
+func (m *Engine) RequireFeature(ctx context.Context, projectID, feature string) error {
+	m.record("RequireFeature")
+	if m.RequireFeatureFunc == nil {
+		return notStubbed("RequireFeature")
+	}
+	return m.RequireFeatureFunc(ctx, projectID, feature)
+}
+
+func (m *Engine) SummarizeProgram(ctx context.Context, programID string, visible func(projectID string) bool) (engine.ProgramSummary, error) {
+	m.record("SummarizeProgram")
+	if m.SummarizeProgramFunc == nil {
+		return zero[engine.ProgramSummary](), notStubbed("SummarizeProgram")
+	}
+	return m.SummarizeProgramFunc(ctx, programID, visible)
+}
+
+func (m *Engine) ApplySeed(ctx context.Context, projectID, actorID string, s seed.File) (engine.SeedResult, error) {
+	m.record("ApplySeed")
+	if m.ApplySeedFunc == nil {
+		return zero[engine.SeedResult](), notStubbed("ApplySeed")
+	}
+	return m.ApplySeedFunc(ctx, projectID, actorID, s)
+}
+
+func (m *Engine) SyncProject(ctx context.Context, projectID, actorID string, mf manifest.File, dryRun bool) (engine.SyncPlan, error) {
+	m.record("SyncProject")
+	if m.SyncProjectFunc == nil {
+		return zero[engine.SyncPlan](), notStubbed("SyncProject")
+	}
+	return m.SyncProjectFunc(ctx, projectID, actorID, mf, dryRun)
+}
+
+func (m *Engine) PlanConfigChange(ctx context.Context, projectID string, next *config.Config) (engine.ConfigPlan, error) {
+	m.record("PlanConfigChange")
+	if m.PlanConfigChangeFunc == nil {
+		return zero[engine.ConfigPlan](), notStubbed("PlanConfigChange")
+	}
+	return m.PlanConfigChangeFunc(ctx, projectID, next)
+}
+
+func (m *Engine) UpdateProjectConfig(ctx context.Context, projectID, actorID string, next *config.Config) (engine.ConfigPlan, error) {
+	m.record("UpdateProjectConfig")
+	if m.UpdateProjectConfigFunc == nil {
+		return zero[engine.ConfigPlan](), notStubbed("UpdateProjectConfig")
+	}
+	return m.UpdateProjectConfigFunc(ctx, projectID, actorID, next)
+}
+
+func (m *Engine) WhoAmI(ctx context.Context, projectID, actorID string) (engine.WhoAmI, error) {
+	m.record("WhoAmI")
+	if m.WhoAmIFunc == nil {
+		return zero[engine.WhoAmI](), notStubbed("WhoAmI")
+	}
+	return m.WhoAmIFunc(ctx, projectID, actorID)
+}
+
+func (m *Engine) CreateOrg(ctx context.Context, id, name, actorID string) (domain.Org, error) {
+	m.record("CreateOrg")
+	if m.CreateOrgFunc == nil {
+		return zero[domain.Org](), notStubbed("CreateOrg")
+	}
+	return m.CreateOrgFunc(ctx, id, name, actorID)
+}
+
+func (m *Engine) GetOrg(ctx context.Context, orgID, actorID string) (domain.Org, error) {
+	m.record("GetOrg")
+	if m.GetOrgFunc == nil {
+		return zero[domain.Org](), notStubbed("GetOrg")
+	}
+	return m.GetOrgFunc(ctx, orgID, actorID)
+}
+
+func (m *Engine) ListOrgProjects(ctx context.Context, orgID, actorID string) ([]domain.Project, error) {
+	m.record("ListOrgProjects")
+	if m.ListOrgProjectsFunc == nil {
+		return zero[[]domain.Project](), notStubbed("ListOrgProjects")
+	}
+	return m.ListOrgProjectsFunc(ctx, orgID, actorID)
+}
+
+func (m *Engine) ListOrgMembers(ctx context.Context, orgID, actorID string) ([]domain.OrgMember, error) {
+	m.record("ListOrgMembers")
+	if m.ListOrgMembersFunc == nil {
+		return zero[[]domain.OrgMember](), notStubbed("ListOrgMembers")
+	}
+	return m.ListOrgMembersFunc(ctx, orgID, actorID)
+}
+
+func (m *Engine) SetOrgRole(ctx context.Context, orgID, target, role, actorID string) (domain.OrgMember, error) {
+	m.record("SetOrgRole")
+	if m.SetOrgRoleFunc == nil {
+		return zero[domain.OrgMember](), notStubbed("SetOrgRole")
+	}
+	return m.SetOrgRoleFunc(ctx, orgID, target, role, actorID)
+}
+
+func (m *Engine) RemoveOrgMember(ctx context.Context, orgID, target, actorID string) error {
+	m.record("RemoveOrgMember")
+	if m.RemoveOrgMemberFunc == nil {
+		return notStubbed("RemoveOrgMember")
+	}
+	return m.RemoveOrgMemberFunc(ctx, orgID, target, actorID)
+}
+
+func (m *Engine) CreateTask(ctx context.Context, opts engine.TaskCreateOptions) (domain.Task, error) {
+	m.record("CreateTask")
+	if m.CreateTaskFunc == nil {
+		return zero[domain.Task](), notStubbed("CreateTask")
+	}
+	return m.CreateTaskFunc(ctx, opts)
+}
+
+func (m *Engine) UpdateTask(ctx context.Context, opts engine.TaskUpdateOptions) (domain.Task, error) {
+	m.record("UpdateTask")
+	if m.UpdateTaskFunc == nil {
+		return zero[domain.Task](), notStubbed("UpdateTask")
+	}
+	return m.UpdateTaskFunc(ctx, opts)
+}
+
+func (m *Engine) MoveTask(ctx context.Context, opts engine.TaskMoveOptions) (domain.Task, error) {
+	m.record("MoveTask")
+	if m.MoveTaskFunc == nil {
+		return zero[domain.Task](), notStubbed("MoveTask")
+	}
+	return m.MoveTaskFunc(ctx, opts)
+}
+
+func (m *Engine) CancelTask(ctx context.Context, opts engine.TaskCancelOptions) (engine.TaskCancellation, error) {
+	m.record("CancelTask")
+	if m.CancelTaskFunc == nil {
+		return zero[engine.TaskCancellation](), notStubbed("CancelTask")
+	}
+	return m.CancelTaskFunc(ctx, opts)
+}
+
+func (m *Engine) TaskDone(ctx context.Context, taskID, workOutcomesJSON, actorID string, force bool) (domain.Task, error) {
+	m.record("TaskDone")
+	if m.TaskDoneFunc == nil {
+		return zero[domain.Task](), notStubbed("TaskDone")
+	}
+	return m.TaskDoneFunc(ctx, taskID, workOutcomesJSON, actorID, force)
+}
+
+func (m *Engine) ApproveTaskCompletion(ctx context.Context, taskID, actorID string) (domain.Task, error) {
+	m.record("ApproveTaskCompletion")
+	if m.ApproveTaskCompletionFunc == nil {
+		return zero[domain.Task](), notStubbed("ApproveTaskCompletion")
+	}
+	return m.ApproveTaskCompletionFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) RejectTaskCompletion(ctx context.Context, taskID, actorID, reason string) (domain.Task, error) {
+	m.record("RejectTaskCompletion")
+	if m.RejectTaskCompletionFunc == nil {
+		return zero[domain.Task](), notStubbed("RejectTaskCompletion")
+	}
+	return m.RejectTaskCompletionFunc(ctx, taskID, actorID, reason)
+}
+
+func (m *Engine) AddTaskLink(ctx context.Context, taskID, kind, target, actorID string) (domain.TaskLink, error) {
+	m.record("AddTaskLink")
+	if m.AddTaskLinkFunc == nil {
+		return zero[domain.TaskLink](), notStubbed("AddTaskLink")
+	}
+	return m.AddTaskLinkFunc(ctx, taskID, kind, target, actorID)
+}
+
+func (m *Engine) RemoveTaskLink(ctx context.Context, taskID string, linkID int64, actorID string) error {
+	m.record("RemoveTaskLink")
+	if m.RemoveTaskLinkFunc == nil {
+		return notStubbed("RemoveTaskLink")
+	}
+	return m.RemoveTaskLinkFunc(ctx, taskID, linkID, actorID)
+}
+
+func (m *Engine) SyncTaskLinks(ctx context.Context, projectID string, evt integrations.Event, actorID string) ([]domain.TaskLink, error) {
+	m.record("SyncTaskLinks")
+	if m.SyncTaskLinksFunc == nil {
+		return zero[[]domain.TaskLink](), notStubbed("SyncTaskLinks")
+	}
+	return m.SyncTaskLinksFunc(ctx, projectID, evt, actorID)
+}
+
+func (m *Engine) ReadyTasks(ctx context.Context, projectID, actorID string) ([]domain.Task, error) {
+	m.record("ReadyTasks")
+	if m.ReadyTasksFunc == nil {
+		return zero[[]domain.Task](), notStubbed("ReadyTasks")
+	}
+	return m.ReadyTasksFunc(ctx, projectID, actorID)
+}
+
+func (m *Engine) CaptureTaskSnapshot(ctx context.Context, scope, actorID string, f repo.TaskFilters) (domain.TaskListSnapshot, error) {
+	m.record("CaptureTaskSnapshot")
+	if m.CaptureTaskSnapshotFunc == nil {
+		return zero[domain.TaskListSnapshot](), notStubbed("CaptureTaskSnapshot")
+	}
+	return m.CaptureTaskSnapshotFunc(ctx, scope, actorID, f)
+}
+
+func (m *Engine) TaskSnapshotPage(ctx context.Context, id, scope, actorID string, after, limit int) (domain.TaskListSnapshot, []domain.Task, error) {
+	m.record("TaskSnapshotPage")
+	if m.TaskSnapshotPageFunc == nil {
+		return zero[domain.TaskListSnapshot](), nil, notStubbed("TaskSnapshotPage")
+	}
+	return m.TaskSnapshotPageFunc(ctx, id, scope, actorID, after, limit)
+}
+
+func (m *Engine) ImportDependencies(ctx context.Context, projectID string, edges []engine.DependencyEdge, actorID string) (engine.DependencyImport, error) {
+	m.record("ImportDependencies")
+	if m.ImportDependenciesFunc == nil {
+		return zero[engine.DependencyImport](), notStubbed("ImportDependencies")
+	}
+	return m.ImportDependenciesFunc(ctx, projectID, edges, actorID)
+}
+
+func (m *Engine) AssignTask(ctx context.Context, opts engine.TaskAssignOptions) (domain.TaskAssignee, error) {
+	m.record("AssignTask")
+	if m.AssignTaskFunc == nil {
+		return zero[domain.TaskAssignee](), notStubbed("AssignTask")
+	}
+	return m.AssignTaskFunc(ctx, opts)
+}
+
+func (m *Engine) UnassignTask(ctx context.Context, opts engine.TaskAssignOptions) error {
+	m.record("UnassignTask")
+	if m.UnassignTaskFunc == nil {
+		return notStubbed("UnassignTask")
+	}
+	return m.UnassignTaskFunc(ctx, opts)
+}
+
+func (m *Engine) WaiveValidation(ctx context.Context, opts engine.WaiverCreateOptions) (domain.Waiver, error) {
+	m.record("WaiveValidation")
+	if m.WaiveValidationFunc == nil {
+		return zero[domain.Waiver](), notStubbed("WaiveValidation")
+	}
+	return m.WaiveValidationFunc(ctx, opts)
+}
+
+func (m *Engine) ActiveWaivers(ctx context.Context, taskID string) ([]domain.Waiver, error) {
+	m.record("ActiveWaivers")
+	if m.ActiveWaiversFunc == nil {
+		return zero[[]domain.Waiver](), notStubbed("ActiveWaivers")
+	}
+	return m.ActiveWaiversFunc(ctx, taskID)
+}
+
+func (m *Engine) PresentRequirements(ctx context.Context, taskID string, required []string) (map[string]bool, error) {
+	m.record("PresentRequirements")
+	if m.PresentRequirementsFunc == nil {
+		return zero[map[string]bool](), notStubbed("PresentRequirements")
+	}
+	return m.PresentRequirementsFunc(ctx, taskID, required)
+}
+
+func (m *Engine) RequirementSources(ctx context.Context, t domain.Task) ([]engine.RequirementSource, error) {
+	m.record("RequirementSources")
+	if m.RequirementSourcesFunc == nil {
+		return zero[[]engine.RequirementSource](), notStubbed("RequirementSources")
+	}
+	return m.RequirementSourcesFunc(ctx, t)
+}
+
+func (m *Engine) TaskRisk(ctx context.Context, t domain.Task) (*engine.TaskRiskReport, error) {
+	m.record("TaskRisk")
+	if m.TaskRiskFunc == nil {
+		return zero[*engine.TaskRiskReport](), notStubbed("TaskRisk")
+	}
+	return m.TaskRiskFunc(ctx, t)
+}
+
+func (m *Engine) TaskEvidence(ctx context.Context, taskID string) (engine.EvidenceBundle, error) {
+	m.record("TaskEvidence")
+	if m.TaskEvidenceFunc == nil {
+		return zero[engine.EvidenceBundle](), notStubbed("TaskEvidence")
+	}
+	return m.TaskEvidenceFunc(ctx, taskID)
+}
+
+func (m *Engine) ExportSubtree(ctx context.Context, taskID string) (engine.SubtreeSnapshot, error) {
+	m.record("ExportSubtree")
+	if m.ExportSubtreeFunc == nil {
+		return zero[engine.SubtreeSnapshot](), notStubbed("ExportSubtree")
+	}
+	return m.ExportSubtreeFunc(ctx, taskID)
+}
+
+func (m *Engine) TaskTree(ctx context.Context, f repo.TaskFilters) ([]engine.TaskTreeNode, error) {
+	m.record("TaskTree")
+	if m.TaskTreeFunc == nil {
+		return zero[[]engine.TaskTreeNode](), notStubbed("TaskTree")
+	}
+	return m.TaskTreeFunc(ctx, f)
+}
+
+func (m *Engine) ImportSubtree(ctx context.Context, projectID string, snap engine.SubtreeSnapshot, opts engine.SubtreeImportOptions, actorID string) (engine.SubtreeImport, error) {
+	m.record("ImportSubtree")
+	if m.ImportSubtreeFunc == nil {
+		return zero[engine.SubtreeImport](), notStubbed("ImportSubtree")
+	}
+	return m.ImportSubtreeFunc(ctx, projectID, snap, opts, actorID)
+}
+
+func (m *Engine) ClaimLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
+	m.record("ClaimLease")
+	if m.ClaimLeaseFunc == nil {
+		return zero[domain.Lease](), notStubbed("ClaimLease")
+	}
+	return m.ClaimLeaseFunc(ctx, taskID, actorID, leaseSeconds)
+}
+
+func (m *Engine) ClaimLeaseWithOptions(ctx context.Context, opts engine.LeaseClaimOptions) (engine.LeaseClaim, error) {
+	m.record("ClaimLeaseWithOptions")
+	if m.ClaimLeaseWithOptionsFunc == nil {
+		return zero[engine.LeaseClaim](), notStubbed("ClaimLeaseWithOptions")
+	}
+	return m.ClaimLeaseWithOptionsFunc(ctx, opts)
+}
+
+func (m *Engine) ClaimNext(ctx context.Context, projectID, actorID string, leaseSeconds int) (domain.Task, domain.Lease, error) {
+	m.record("ClaimNext")
+	if m.ClaimNextFunc == nil {
+		return zero[domain.Task](), zero[domain.Lease](), notStubbed("ClaimNext")
+	}
+	return m.ClaimNextFunc(ctx, projectID, actorID, leaseSeconds)
+}
+
+func (m *Engine) DeferTask(ctx context.Context, taskID, until, actorID string) (domain.Task, error) {
+	m.record("DeferTask")
+	if m.DeferTaskFunc == nil {
+		return zero[domain.Task](), notStubbed("DeferTask")
+	}
+	return m.DeferTaskFunc(ctx, taskID, until, actorID)
+}
+
+func (m *Engine) ReleaseLease(ctx context.Context, taskID, actorID string) error {
+	m.record("ReleaseLease")
+	if m.ReleaseLeaseFunc == nil {
+		return notStubbed("ReleaseLease")
+	}
+	return m.ReleaseLeaseFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) TaskLease(ctx context.Context, taskID, actorID string) (domain.Lease, error) {
+	m.record("TaskLease")
+	if m.TaskLeaseFunc == nil {
+		return zero[domain.Lease](), notStubbed("TaskLease")
+	}
+	return m.TaskLeaseFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) ProjectLeases(ctx context.Context, projectID, actorID string, f engine.LeaseFilter) ([]domain.Lease, error) {
+	m.record("ProjectLeases")
+	if m.ProjectLeasesFunc == nil {
+		return zero[[]domain.Lease](), notStubbed("ProjectLeases")
+	}
+	return m.ProjectLeasesFunc(ctx, projectID, actorID, f)
+}
+
+func (m *Engine) LeaseWaiters(ctx context.Context, taskID, actorID string) ([]domain.LeaseWaiter, error) {
+	m.record("LeaseWaiters")
+	if m.LeaseWaitersFunc == nil {
+		return zero[[]domain.LeaseWaiter](), notStubbed("LeaseWaiters")
+	}
+	return m.LeaseWaitersFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) LeaveLeaseQueue(ctx context.Context, taskID, actorID string) error {
+	m.record("LeaveLeaseQueue")
+	if m.LeaveLeaseQueueFunc == nil {
+		return notStubbed("LeaveLeaseQueue")
+	}
+	return m.LeaveLeaseQueueFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) TransferLease(ctx context.Context, opts engine.LeaseTransferOptions) (domain.Lease, error) {
+	m.record("TransferLease")
+	if m.TransferLeaseFunc == nil {
+		return zero[domain.Lease](), notStubbed("TransferLease")
+	}
+	return m.TransferLeaseFunc(ctx, opts)
+}
+
+func (m *Engine) AcceptLeaseTransfer(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
+	m.record("AcceptLeaseTransfer")
+	if m.AcceptLeaseTransferFunc == nil {
+		return zero[domain.Lease](), notStubbed("AcceptLeaseTransfer")
+	}
+	return m.AcceptLeaseTransferFunc(ctx, taskID, actorID, leaseSeconds)
+}
+
+func (m *Engine) DeclineLeaseTransfer(ctx context.Context, taskID, actorID string) (domain.Lease, error) {
+	m.record("DeclineLeaseTransfer")
+	if m.DeclineLeaseTransferFunc == nil {
+		return zero[domain.Lease](), notStubbed("DeclineLeaseTransfer")
+	}
+	return m.DeclineLeaseTransferFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) ClaimReviewLease(ctx context.Context, taskID, actorID string, leaseSeconds int) (domain.Lease, error) {
+	m.record("ClaimReviewLease")
+	if m.ClaimReviewLeaseFunc == nil {
+		return zero[domain.Lease](), notStubbed("ClaimReviewLease")
+	}
+	return m.ClaimReviewLeaseFunc(ctx, taskID, actorID, leaseSeconds)
+}
+
+func (m *Engine) ReleaseReviewLease(ctx context.Context, taskID, actorID string) error {
+	m.record("ReleaseReviewLease")
+	if m.ReleaseReviewLeaseFunc == nil {
+		return notStubbed("ReleaseReviewLease")
+	}
+	return m.ReleaseReviewLeaseFunc(ctx, taskID, actorID)
+}
+
+func (m *Engine) CreateIteration(ctx context.Context, it domain.Iteration, actorID string) (domain.Iteration, error) {
+	m.record("CreateIteration")
+	if m.CreateIterationFunc == nil {
+		return zero[domain.Iteration](), notStubbed("CreateIteration")
+	}
+	return m.CreateIterationFunc(ctx, it, actorID)
+}
+
+func (m *Engine) SetIterationStatus(ctx context.Context, id, status, actorID string, force bool) (domain.Iteration, error) {
+	m.record("SetIterationStatus")
+	if m.SetIterationStatusFunc == nil {
+		return zero[domain.Iteration](), notStubbed("SetIterationStatus")
+	}
+	return m.SetIterationStatusFunc(ctx, id, status, actorID, force)
+}
+
+func (m *Engine) UpdateIterationKeyResults(ctx context.Context, iterationID string, patches []engine.KeyResultPatch, actorID string) (domain.Iteration, error) {
+	m.record("UpdateIterationKeyResults")
+	if m.UpdateIterationKeyResultsFunc == nil {
+		return zero[domain.Iteration](), notStubbed("UpdateIterationKeyResults")
+	}
+	return m.UpdateIterationKeyResultsFunc(ctx, iterationID, patches, actorID)
+}
+
+func (m *Engine) CarryOverIteration(ctx context.Context, sourceID, targetID, freezeOverride, actorID string) (engine.CarryOverResult, error) {
+	m.record("CarryOverIteration")
+	if m.CarryOverIterationFunc == nil {
+		return zero[engine.CarryOverResult](), notStubbed("CarryOverIteration")
+	}
+	return m.CarryOverIterationFunc(ctx, sourceID, targetID, freezeOverride, actorID)
+}
+
+func (m *Engine) SetIterationFrozen(ctx context.Context, id string, frozen bool, actorID string) (domain.Iteration, error) {
+	m.record("SetIterationFrozen")
+	if m.SetIterationFrozenFunc == nil {
+		return zero[domain.Iteration](), notStubbed("SetIterationFrozen")
+	}
+	return m.SetIterationFrozenFunc(ctx, id, frozen, actorID)
+}
+
+func (m *Engine) CreateDecision(ctx context.Context, d domain.Decision, actorID string) (domain.Decision, error) {
+	m.record("CreateDecision")
+	if m.CreateDecisionFunc == nil {
+		return zero[domain.Decision](), notStubbed("CreateDecision")
+	}
+	return m.CreateDecisionFunc(ctx, d, actorID)
+}
+
+func (m *Engine) AddAttestation(ctx context.Context, att domain.Attestation, actorID string) (domain.Attestation, error) {
+	m.record("AddAttestation")
+	if m.AddAttestationFunc == nil {
+		return zero[domain.Attestation](), notStubbed("AddAttestation")
+	}
+	return m.AddAttestationFunc(ctx, att, actorID)
+}
+
+func (m *Engine) AddAttestations(ctx context.Context, projectID string, atts []domain.Attestation, actorID string, atomic bool) (engine.BulkAttestationOutcome, error) {
+	m.record("AddAttestations")
+	if m.AddAttestationsFunc == nil {
+		return zero[engine.BulkAttestationOutcome](), notStubbed("AddAttestations")
+	}
+	return m.AddAttestationsFunc(ctx, projectID, atts, actorID, atomic)
+}
+
+func (m *Engine) AttestationRequiredBy(ctx context.Context, projectID, kind string) (engine.AttestationBacklog, error) {
+	m.record("AttestationRequiredBy")
+	if m.AttestationRequiredByFunc == nil {
+		return zero[engine.AttestationBacklog](), notStubbed("AttestationRequiredBy")
+	}
+	return m.AttestationRequiredByFunc(ctx, projectID, kind)
+}
+
+func (m *Engine) DeprecatedKindWarning(kind string) string {
+	m.record("DeprecatedKindWarning")
+	if m.DeprecatedKindWarningFunc == nil {
+		return zero[string]()
+	}
+	return m.DeprecatedKindWarningFunc(kind)
+}
+
+func (m *Engine) AllowAttestationRole(ctx context.Context, projectID, actorID, kind, roleID string) error {
+	m.record("AllowAttestationRole")
+	if m.AllowAttestationRoleFunc == nil {
+		return notStubbed("AllowAttestationRole")
+	}
+	return m.AllowAttestationRoleFunc(ctx, projectID, actorID, kind, roleID)
+}
+
+func (m *Engine) DenyAttestationRole(ctx context.Context, projectID, actorID, kind, roleID string) error {
+	m.record("DenyAttestationRole")
+	if m.DenyAttestationRoleFunc == nil {
+		return notStubbed("DenyAttestationRole")
+	}
+	return m.DenyAttestationRoleFunc(ctx, projectID, actorID, kind, roleID)
+}
+
+func (m *Engine) AttestationAuthorityReport(ctx context.Context, projectID string) (engine.AttestationAuthorityReport, error) {
+	m.record("AttestationAuthorityReport")
+	if m.AttestationAuthorityReportFunc == nil {
+		return zero[engine.AttestationAuthorityReport](), notStubbed("AttestationAuthorityReport")
+	}
+	return m.AttestationAuthorityReportFunc(ctx, projectID)
+}
+
+func (m *Engine) UploadArtifact(ctx context.Context, opts engine.ArtifactUploadOptions) (domain.Artifact, error) {
+	m.record("UploadArtifact")
+	if m.UploadArtifactFunc == nil {
+		return zero[domain.Artifact](), notStubbed("UploadArtifact")
+	}
+	return m.UploadArtifactFunc(ctx, opts)
+}
+
+func (m *Engine) ArtifactContent(ctx context.Context, a domain.Artifact) ([]byte, error) {
+	m.record("ArtifactContent")
+	if m.ArtifactContentFunc == nil {
+		return zero[[]byte](), notStubbed("ArtifactContent")
+	}
+	return m.ArtifactContentFunc(ctx, a)
+}
+
+func (m *Engine) CreateView(ctx context.Context, v domain.View, actorID string) (domain.View, error) {
+	m.record("CreateView")
+	if m.CreateViewFunc == nil {
+		return zero[domain.View](), notStubbed("CreateView")
+	}
+	return m.CreateViewFunc(ctx, v, actorID)
+}
+
+func (m *Engine) DeleteView(ctx context.Context, projectID, id, actorID string) error {
+	m.record("DeleteView")
+	if m.DeleteViewFunc == nil {
+		return notStubbed("DeleteView")
+	}
+	return m.DeleteViewFunc(ctx, projectID, id, actorID)
+}
+
+func (m *Engine) GetVisibleView(ctx context.Context, projectID, id, actorID string) (domain.View, error) {
+	m.record("GetVisibleView")
+	if m.GetVisibleViewFunc == nil {
+		return zero[domain.View](), notStubbed("GetVisibleView")
+	}
+	return m.GetVisibleViewFunc(ctx, projectID, id, actorID)
+}
+
+func (m *Engine) VisibleViews(ctx context.Context, projectID, actorID string) ([]domain.View, error) {
+	m.record("VisibleViews")
+	if m.VisibleViewsFunc == nil {
+		return zero[[]domain.View](), notStubbed("VisibleViews")
+	}
+	return m.VisibleViewsFunc(ctx, projectID, actorID)
+}
+
+func (m *Engine) GrantRoleUntil(ctx context.Context, projectID, actorID, targetActor, roleID, expiresAt string) error {
+	m.record("GrantRoleUntil")
+	if m.GrantRoleUntilFunc == nil {
+		return notStubbed("GrantRoleUntil")
+	}
+	return m.GrantRoleUntilFunc(ctx, projectID, actorID, targetActor, roleID, expiresAt)
+}
+
+func (m *Engine) RevokeRole(ctx context.Context, projectID, actorID, targetActor, roleID string) error {
+	m.record("RevokeRole")
+	if m.RevokeRoleFunc == nil {
+		return notStubbed("RevokeRole")
+	}
+	return m.RevokeRoleFunc(ctx, projectID, actorID, targetActor, roleID)
+}
+
+func (m *Engine) CreateTeam(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error) {
+	m.record("CreateTeam")
+	if m.CreateTeamFunc == nil {
+		return zero[domain.Team](), notStubbed("CreateTeam")
+	}
+	return m.CreateTeamFunc(ctx, projectID, teamID, description, actorID)
+}
+
+func (m *Engine) UpdateTeam(ctx context.Context, projectID, teamID, description, actorID string) (domain.Team, error) {
+	m.record("UpdateTeam")
+	if m.UpdateTeamFunc == nil {
+		return zero[domain.Team](), notStubbed("UpdateTeam")
+	}
+	return m.UpdateTeamFunc(ctx, projectID, teamID, description, actorID)
+}
+
+func (m *Engine) DeleteTeam(ctx context.Context, projectID, teamID, actorID string) error {
+	m.record("DeleteTeam")
+	if m.DeleteTeamFunc == nil {
+		return notStubbed("DeleteTeam")
+	}
+	return m.DeleteTeamFunc(ctx, projectID, teamID, actorID)
+}
+
+func (m *Engine) AddTeamMember(ctx context.Context, projectID, teamID, memberID, actorID string) error {
+	m.record("AddTeamMember")
+	if m.AddTeamMemberFunc == nil {
+		return notStubbed("AddTeamMember")
+	}
+	return m.AddTeamMemberFunc(ctx, projectID, teamID, memberID, actorID)
+}
+
+func (m *Engine) RemoveTeamMember(ctx context.Context, projectID, teamID, memberID, actorID string) error {
+	m.record("RemoveTeamMember")
+	if m.RemoveTeamMemberFunc == nil {
+		return notStubbed("RemoveTeamMember")
+	}
+	return m.RemoveTeamMemberFunc(ctx, projectID, teamID, memberID, actorID)
+}
+
+func (m *Engine) GrantTeamRoleUntil(ctx context.Context, projectID, actorID, teamID, roleID, expiresAt string) error {
+	m.record("GrantTeamRoleUntil")
+	if m.GrantTeamRoleUntilFunc == nil {
+		return notStubbed("GrantTeamRoleUntil")
+	}
+	return m.GrantTeamRoleUntilFunc(ctx, projectID, actorID, teamID, roleID, expiresAt)
+}
+
+func (m *Engine) RevokeTeamRole(ctx context.Context, projectID, actorID, teamID, roleID string) error {
+	m.record("RevokeTeamRole")
+	if m.RevokeTeamRoleFunc == nil {
+		return notStubbed("RevokeTeamRole")
+	}
+	return m.RevokeTeamRoleFunc(ctx, projectID, actorID, teamID, roleID)
+}
+
+func (m *Engine) CreateEscalationRule(ctx context.Context, rule domain.EscalationRule, actorID string) (domain.EscalationRule, error) {
+	m.record("CreateEscalationRule")
+	if m.CreateEscalationRuleFunc == nil {
+		return zero[domain.EscalationRule](), notStubbed("CreateEscalationRule")
+	}
+	return m.CreateEscalationRuleFunc(ctx, rule, actorID)
+}
+
+func (m *Engine) UpdateEscalationRule(ctx context.Context, projectID, ruleID string, u engine.EscalationRuleUpdate, actorID string) (domain.EscalationRule, error) {
+	m.record("UpdateEscalationRule")
+	if m.UpdateEscalationRuleFunc == nil {
+		return zero[domain.EscalationRule](), notStubbed("UpdateEscalationRule")
+	}
+	return m.UpdateEscalationRuleFunc(ctx, projectID, ruleID, u, actorID)
+}
+
+func (m *Engine) DeleteEscalationRule(ctx context.Context, projectID, ruleID, actorID string) error {
+	m.record("DeleteEscalationRule")
+	if m.DeleteEscalationRuleFunc == nil {
+		return notStubbed("DeleteEscalationRule")
+	}
+	return m.DeleteEscalationRuleFunc(ctx, projectID, ruleID, actorID)
+}
+
+func (m *Engine) RotateSecret(ctx context.Context, projectID, kind, name string, overlap time.Duration, actorID string) (engine.SecretRotation, error) {
+	m.record("RotateSecret")
+	if m.RotateSecretFunc == nil {
+		return zero[engine.SecretRotation](), notStubbed("RotateSecret")
+	}
+	return m.RotateSecretFunc(ctx, projectID, kind, name, overlap, actorID)
+}
+
+func (m *Engine) RevokeSecret(ctx context.Context, projectID, secretID, actorID string) (domain.ProjectSecret, error) {
+	m.record("RevokeSecret")
+	if m.RevokeSecretFunc == nil {
+		return zero[domain.ProjectSecret](), notStubbed("RevokeSecret")
+	}
+	return m.RevokeSecretFunc(ctx, projectID, secretID, actorID)
+}
+
+func (m *Engine) SetActorCapabilities(ctx context.Context, projectID, target string, caps []string, actorID string) ([]string, error) {
+	m.record("SetActorCapabilities")
+	if m.SetActorCapabilitiesFunc == nil {
+		return zero[[]string](), notStubbed("SetActorCapabilities")
+	}
+	return m.SetActorCapabilitiesFunc(ctx, projectID, target, caps, actorID)
+}
+
+func (m *Engine) ReadNotification(ctx context.Context, projectID string, id int64, actorID string) (domain.Notification, error) {
+	m.record("ReadNotification")
+	if m.ReadNotificationFunc == nil {
+		return zero[domain.Notification](), notStubbed("ReadNotification")
+	}
+	return m.ReadNotificationFunc(ctx, projectID, id, actorID)
+}
+
+func (m *Engine) ReadAllNotifications(ctx context.Context, projectID, actorID string) (int, error) {
+	m.record("ReadAllNotifications")
+	if m.ReadAllNotificationsFunc == nil {
+		return zero[int](), notStubbed("ReadAllNotifications")
+	}
+	return m.ReadAllNotificationsFunc(ctx, projectID, actorID)
+}
+
+func (m *Engine) SetEmailPreferences(ctx context.Context, prefs domain.EmailPreferences) (domain.EmailPreferences, error) {
+	m.record("SetEmailPreferences")
+	if m.SetEmailPreferencesFunc == nil {
+		return zero[domain.EmailPreferences](), notStubbed("SetEmailPreferences")
+	}
+	return m.SetEmailPreferencesFunc(ctx, prefs)
+}
+
+func (m *Engine) ComplianceReport(ctx context.Context, projectID string, from, to time.Time) (engine.ComplianceReport, error) {
+	m.record("ComplianceReport")
+	if m.ComplianceReportFunc == nil {
+		return zero[engine.ComplianceReport](), notStubbed("ComplianceReport")
+	}
+	return m.ComplianceReportFunc(ctx, projectID, from, to)
+}
+
+func (m *Engine) AggregateEvents(ctx context.Context, projectID, bucket string, types []string, from, to time.Time) (engine.EventAggregate, error) {
+	m.record("AggregateEvents")
+	if m.AggregateEventsFunc == nil {
+		return zero[engine.EventAggregate](), notStubbed("AggregateEvents")
+	}
+	return m.AggregateEventsFunc(ctx, projectID, bucket, types, from, to)
+}
+
+func (m *Engine) VerifyEventChain(ctx context.Context, projectID string) (engine.EventChainReport, error) {
+	m.record("VerifyEventChain")
+	if m.VerifyEventChainFunc == nil {
+		return zero[engine.EventChainReport](), notStubbed("VerifyEventChain")
+	}
+	return m.VerifyEventChainFunc(ctx, projectID)
+}
+
+func (m *Engine) GenerateDigest(ctx context.Context, projectID string, day time.Time, actorID string) (engine.Digest, error) {
+	m.record("GenerateDigest")
+	if m.GenerateDigestFunc == nil {
+		return zero[engine.Digest](), notStubbed("GenerateDigest")
+	}
+	return m.GenerateDigestFunc(ctx, projectID, day, actorID)
+}
+
+func (m *Engine) GetDigest(ctx context.Context, projectID, day string) (engine.Digest, error) {
+	m.record("GetDigest")
+	if m.GetDigestFunc == nil {
+		return zero[engine.Digest](), notStubbed("GetDigest")
+	}
+	return m.GetDigestFunc(ctx, projectID, day)
+}
+
+func (m *Engine) ListDigests(ctx context.Context, projectID, from, to string) ([]engine.Digest, error) {
+	m.record("ListDigests")
+	if m.ListDigestsFunc == nil {
+		return zero[[]engine.Digest](), notStubbed("ListDigests")
+	}
+	return m.ListDigestsFunc(ctx, projectID, from, to)
+}
+
+func (m *Engine) RecordUsage(ctx context.Context, projectID, actorID string, mutation bool) error {
+	m.record("RecordUsage")
+	if m.RecordUsageFunc == nil {
+		return notStubbed("RecordUsage")
+	}
+	return m.RecordUsageFunc(ctx, projectID, actorID, mutation)
+}
+
+func (m *Engine) Usage(ctx context.Context, projectID, actorID string, from, to time.Time) (engine.UsageReport, error) {
+	m.record("Usage")
+	if m.UsageFunc == nil {
+		return zero[engine.UsageReport](), notStubbed("Usage")
+	}
+	return m.UsageFunc(ctx, projectID, actorID, from, to)
+}
+
+func (m *Engine) CycleTime(ctx context.Context, opts engine.CycleTimeOptions) (engine.CycleTimeReport, error) {
+	m.record("CycleTime")
+	if m.CycleTimeFunc == nil {
+		return zero[engine.CycleTimeReport](), notStubbed("CycleTime")
+	}
+	return m.CycleTimeFunc(ctx, opts)
+}
+
+func (m *Engine) CheckConsistency(ctx context.Context) (engine.ConsistencyReport, error) {
+	m.record("CheckConsistency")
+	if m.CheckConsistencyFunc == nil {
+		return zero[engine.ConsistencyReport](), notStubbed("CheckConsistency")
+	}
+	return m.CheckConsistencyFunc(ctx)
+}
+
+func (m *Engine) RepairConsistency(ctx context.Context, checks []string, actorID string) (engine.ConsistencyReport, error) {
+	m.record("RepairConsistency")
+	if m.RepairConsistencyFunc == nil {
+		return zero[engine.ConsistencyReport](), notStubbed("RepairConsistency")
+	}
+	return m.RepairConsistencyFunc(ctx, checks, actorID)
+}
+
+func (m *Engine) Vacuum(ctx context.Context, full bool, pages int) (db.VacuumResult, error) {
+	m.record("Vacuum")
+	if m.VacuumFunc == nil {
+		return zero[db.VacuumResult](), notStubbed("Vacuum")
+	}
+	return m.VacuumFunc(ctx, full, pages)
+}
+
+func (m *Engine) CheckIntegrity(ctx context.Context, quick bool) (db.IntegrityResult, error) {
+	m.record("CheckIntegrity")
+	if m.CheckIntegrityFunc == nil {
+		return zero[db.IntegrityResult](), notStubbed("CheckIntegrity")
+	}
+	return m.CheckIntegrityFunc(ctx, quick)
+}
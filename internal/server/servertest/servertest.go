@@ -0,0 +1,45 @@
+// Package servertest provides stubs of the server's dependencies, so handlers and the
+// middleware wrapped around them can be unit-tested without a SQLite workspace.
+//
+// Each stub method calls the function in the matching Func field. Calls whose field is
+// unset return zero values and an error wrapping ErrNotStubbed, which the handlers answer
+// with 500 internal_error. Every call is recorded, in order, and reported by Calls.
+package servertest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ErrNotStubbed is wrapped by the error of a call whose Func field is unset.
+var ErrNotStubbed = errors.New("servertest: call not stubbed")
+
+func notStubbed(method string) error {
+	return fmt.Errorf("%w: %s", ErrNotStubbed, method)
+}
+
+func zero[T any]() T {
+	var v T
+	return v
+}
+
+// calls records the methods called on a stub.
+type calls struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (c *calls) record(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = append(c.names, method)
+}
+
+// Calls returns the names of the methods called so far, in order.
+func (c *calls) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.names)
+}
@@ -0,0 +1,109 @@
+package servertest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"workline/internal/domain"
+	"workline/internal/repo"
+	"workline/internal/server"
+	"workline/internal/server/servertest"
+)
+
+func TestEngineStubsHandlers(t *testing.T) {
+	store := &servertest.Store{
+		GetProjectFunc: func(_ context.Context, id string) (domain.Project, error) {
+			return domain.Project{ID: id, OrgID: "default-org"}, nil
+		},
+		GetTaskFunc: func(_ context.Context, id string) (domain.Task, error) {
+			if id != "task-1" {
+				return domain.Task{}, repo.ErrNotFound
+			}
+			return domain.Task{ID: id, ProjectID: "workline", Title: "stubbed", Type: "bug", Status: "planned"}, nil
+		},
+		ListTaskLinksFunc: func(_ context.Context, taskID string) ([]domain.TaskLink, error) {
+			return nil, nil
+		},
+	}
+	e := &servertest.Engine{
+		Repo: store,
+		ActorHasPermissionFunc: func(_ context.Context, projectID, actorID, perm string) (bool, error) {
+			return actorID == "tester" && perm == "task.read", nil
+		},
+	}
+	handler, err := server.New(server.Config{Engine: e, BasePath: "/v0", Auth: server.AuthConfig{JWTSecret: "secret"}})
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	// Custom middleware under test wraps the handlers as it would in an embedding program.
+	var seen []int
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		seen = append(seen, rec.Code)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	})
+	ts := httptest.NewServer(wrapped)
+	defer ts.Close()
+
+	get := func(path, actorID string) (int, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": actorID,
+			"org": "default-org",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		signed, err := token.SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signed)
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, data
+	}
+
+	status, data := get("/v0/projects/workline/tasks/task-1", "tester")
+	var task server.TaskResponse
+	if err := json.Unmarshal(data, &task); err != nil || status != http.StatusOK || task.Title != "stubbed" {
+		t.Fatalf("expected stubbed task, got %d %s", status, string(data))
+	}
+	if status, data := get("/v0/projects/workline/tasks/task-2", "tester"); status != http.StatusNotFound {
+		t.Fatalf("expected missing task, got %d %s", status, string(data))
+	}
+	if status, data := get("/v0/projects/workline/tasks/task-1", "intruder"); status != http.StatusForbidden {
+		t.Fatalf("expected intruder refused, got %d %s", status, string(data))
+	}
+	if !slices.Equal(seen, []int{http.StatusOK, http.StatusNotFound, http.StatusForbidden}) {
+		t.Fatalf("middleware saw %v", seen)
+	}
+	if got := store.Calls(); !slices.Equal(got, []string{"GetProject", "GetTask", "ListTaskLinks", "GetProject", "GetTask", "GetProject"}) {
+		t.Fatalf("unexpected store calls %v", got)
+	}
+	if !slices.Contains(e.Calls(), "RecordDenial") {
+		t.Fatalf("expected the denial recorded, got %v", e.Calls())
+	}
+
+	// Calls left unstubbed fail instead of touching a database.
+	if _, err := e.ClaimLease(context.Background(), "task-1", "tester", 60); !errors.Is(err, servertest.ErrNotStubbed) {
+		t.Fatalf("expected ErrNotStubbed, got %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+package servertest
+
+import (
+	"context"
+
+	"workline/internal/config"
+	"workline/internal/domain"
+	"workline/internal/repo"
+)
+
+// Store is a repo.Store whose methods call its Func fields.
+type Store struct {
+	calls
+
+	GetProjectFunc               func(ctx context.Context, id string) (domain.Project, error)
+	ListProjectsFunc             func(ctx context.Context) ([]domain.Project, error)
+	DeleteProjectFunc            func(ctx context.Context, id string) error
+	GetProjectConfigFunc         func(ctx context.Context, projectID string) (*config.Config, error)
+	UpsertProjectConfigFunc      func(ctx context.Context, projectID string, cfg *config.Config) error
+	ListMembersFunc              func(ctx context.Context, projectID, now string, limit int, cursorActorID string) ([]domain.Member, error)
+	ListMembershipsFunc          func(ctx context.Context, actorID, orgID, now string) ([]domain.Membership, error)
+	GetTeamFunc                  func(ctx context.Context, projectID, teamID string) (domain.Team, error)
+	ListTeamsFunc                func(ctx context.Context, projectID string) ([]domain.Team, error)
+	GetEscalationRuleFunc        func(ctx context.Context, projectID, ruleID string) (domain.EscalationRule, error)
+	ListEscalationRulesFunc      func(ctx context.Context, projectID string) ([]domain.EscalationRule, error)
+	ListNotificationsFunc        func(ctx context.Context, projectID, actorID string, unreadOnly bool, limit int, beforeID int64) ([]domain.Notification, error)
+	CountUnreadNotificationsFunc func(ctx context.Context, projectID, actorID string) (int, error)
+	GetEmailPreferencesFunc      func(ctx context.Context, actorID string) (domain.EmailPreferences, error)
+	ListProjectSecretsFunc       func(ctx context.Context, projectID string) ([]domain.ProjectSecret, error)
+	LiveSecretsFunc              func(ctx context.Context, projectID, kind, name, now string) ([]domain.ProjectSecret, error)
+	ListActorCapabilitiesFunc    func(ctx context.Context, projectID, actorID string) ([]string, error)
+	GetTaskFunc                  func(ctx context.Context, id string) (domain.Task, error)
+	ListTasksFunc                func(ctx context.Context, f repo.TaskFilters) ([]domain.Task, error)
+	CountTasksByStatusFunc       func(ctx context.Context, projectID string) (map[string]int, error)
+	ListTaskDependenciesFunc     func(ctx context.Context, taskID string) ([]string, error)
+	ListTaskAssigneesFunc        func(ctx context.Context, taskID string) ([]domain.TaskAssignee, error)
+	ListTaskHandoffsFunc         func(ctx context.Context, taskID string) ([]domain.TaskHandoff, error)
+	ListTaskCompletionsFunc      func(ctx context.Context, taskID string) ([]domain.TaskCompletion, error)
+	ListTaskLinksFunc            func(ctx context.Context, taskID string) ([]domain.TaskLink, error)
+	ListWaiversFunc              func(ctx context.Context, taskID string) ([]domain.Waiver, error)
+	GetIterationFunc             func(ctx context.Context, id string) (domain.Iteration, error)
+	ListIterationsWithCursorFunc func(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Iteration, error)
+	LatestRunningIterationFunc   func(ctx context.Context, projectID string) (*domain.Iteration, error)
+	GetDecisionFunc              func(ctx context.Context, id string) (domain.Decision, error)
+	ListDecisionsFunc            func(ctx context.Context, f repo.DecisionFilters) ([]domain.Decision, error)
+	ListAttestationsFunc         func(ctx context.Context, f repo.AttestationFilters) ([]domain.Attestation, error)
+	BlobReferencedFunc           func(ctx context.Context, projectID, digest string) (bool, error)
+	GetArtifactFunc              func(ctx context.Context, id string) (domain.Artifact, error)
+	ListArtifactsFunc            func(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Artifact, error)
+	LatestEventsFromFunc         func(ctx context.Context, limit int, cursor int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error)
+	EventsSinceFunc              func(ctx context.Context, limit int, afterID int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error)
+	MaxEventIDFunc               func(ctx context.Context, projectID string) (int64, error)
+	ActorEventsFromFunc          func(ctx context.Context, limit int, cursor int64, projectID, actorID, since string) ([]domain.Event, error)
+	CountActorEventsByTypeFunc   func(ctx context.Context, projectID, actorID, since string) (map[string]int, error)
+	CountActorCompletionsFunc    func(ctx context.Context, projectID, actorID, since string) (int, error)
+	ListStatsSnapshotsFunc       func(ctx context.Context, projectID, from, to string) ([]domain.StatsSnapshot, error)
+	GetAPIKeyByHashFunc          func(ctx context.Context, hash string) (domain.APIKey, error)
+	GetLiveAPITokenFunc          func(ctx context.Context, hash, now string) (domain.ProjectSecret, error)
+	GetClientCertMappingFunc     func(ctx context.Context, kind, value string) (domain.ClientCertMapping, error)
+}
+
+var _ repo.Store = (*Store)(nil)
+
+func (m *Store) GetProject(ctx context.Context, id string) (domain.Project, error) {
+	m.record("GetProject")
+	if m.GetProjectFunc == nil {
+		return zero[domain.Project](), notStubbed("GetProject")
+	}
+	return m.GetProjectFunc(ctx, id)
+}
+
+func (m *Store) ListProjects(ctx context.Context) ([]domain.Project, error) {
+	m.record("ListProjects")
+	if m.ListProjectsFunc == nil {
+		return zero[[]domain.Project](), notStubbed("ListProjects")
+	}
+	return m.ListProjectsFunc(ctx)
+}
+
+func (m *Store) DeleteProject(ctx context.Context, id string) error {
+	m.record("DeleteProject")
+	if m.DeleteProjectFunc == nil {
+		return notStubbed("DeleteProject")
+	}
+	return m.DeleteProjectFunc(ctx, id)
+}
+
+func (m *Store) GetProjectConfig(ctx context.Context, projectID string) (*config.Config, error) {
+	m.record("GetProjectConfig")
+	if m.GetProjectConfigFunc == nil {
+		return zero[*config.Config](), notStubbed("GetProjectConfig")
+	}
+	return m.GetProjectConfigFunc(ctx, projectID)
+}
+
+func (m *Store) UpsertProjectConfig(ctx context.Context, projectID string, cfg *config.Config) error {
+	m.record("UpsertProjectConfig")
+	if m.UpsertProjectConfigFunc == nil {
+		return notStubbed("UpsertProjectConfig")
+	}
+	return m.UpsertProjectConfigFunc(ctx, projectID, cfg)
+}
+
+func (m *Store) ListMembers(ctx context.Context, projectID, now string, limit int, cursorActorID string) ([]domain.Member, error) {
+	m.record("ListMembers")
+	if m.ListMembersFunc == nil {
+		return zero[[]domain.Member](), notStubbed("ListMembers")
+	}
+	return m.ListMembersFunc(ctx, projectID, now, limit, cursorActorID)
+}
+
+func (m *Store) ListMemberships(ctx context.Context, actorID, orgID, now string) ([]domain.Membership, error) {
+	m.record("ListMemberships")
+	if m.ListMembershipsFunc == nil {
+		return zero[[]domain.Membership](), notStubbed("ListMemberships")
+	}
+	return m.ListMembershipsFunc(ctx, actorID, orgID, now)
+}
+
+func (m *Store) GetTeam(ctx context.Context, projectID, teamID string) (domain.Team, error) {
+	m.record("GetTeam")
+	if m.GetTeamFunc == nil {
+		return zero[domain.Team](), notStubbed("GetTeam")
+	}
+	return m.GetTeamFunc(ctx, projectID, teamID)
+}
+
+func (m *Store) ListTeams(ctx context.Context, projectID string) ([]domain.Team, error) {
+	m.record("ListTeams")
+	if m.ListTeamsFunc == nil {
+		return zero[[]domain.Team](), notStubbed("ListTeams")
+	}
+	return m.ListTeamsFunc(ctx, projectID)
+}
+
+func (m *Store) GetEscalationRule(ctx context.Context, projectID, ruleID string) (domain.EscalationRule, error) {
+	m.record("GetEscalationRule")
+	if m.GetEscalationRuleFunc == nil {
+		return zero[domain.EscalationRule](), notStubbed("GetEscalationRule")
+	}
+	return m.GetEscalationRuleFunc(ctx, projectID, ruleID)
+}
+
+func (m *Store) ListEscalationRules(ctx context.Context, projectID string) ([]domain.EscalationRule, error) {
+	m.record("ListEscalationRules")
+	if m.ListEscalationRulesFunc == nil {
+		return zero[[]domain.EscalationRule](), notStubbed("ListEscalationRules")
+	}
+	return m.ListEscalationRulesFunc(ctx, projectID)
+}
+
+func (m *Store) ListNotifications(ctx context.Context, projectID, actorID string, unreadOnly bool, limit int, beforeID int64) ([]domain.Notification, error) {
+	m.record("ListNotifications")
+	if m.ListNotificationsFunc == nil {
+		return zero[[]domain.Notification](), notStubbed("ListNotifications")
+	}
+	return m.ListNotificationsFunc(ctx, projectID, actorID, unreadOnly, limit, beforeID)
+}
+
+func (m *Store) CountUnreadNotifications(ctx context.Context, projectID, actorID string) (int, error) {
+	m.record("CountUnreadNotifications")
+	if m.CountUnreadNotificationsFunc == nil {
+		return zero[int](), notStubbed("CountUnreadNotifications")
+	}
+	return m.CountUnreadNotificationsFunc(ctx, projectID, actorID)
+}
+
+func (m *Store) GetEmailPreferences(ctx context.Context, actorID string) (domain.EmailPreferences, error) {
+	m.record("GetEmailPreferences")
+	if m.GetEmailPreferencesFunc == nil {
+		return zero[domain.EmailPreferences](), notStubbed("GetEmailPreferences")
+	}
+	return m.GetEmailPreferencesFunc(ctx, actorID)
+}
+
+func (m *Store) ListProjectSecrets(ctx context.Context, projectID string) ([]domain.ProjectSecret, error) {
+	m.record("ListProjectSecrets")
+	if m.ListProjectSecretsFunc == nil {
+		return zero[[]domain.ProjectSecret](), notStubbed("ListProjectSecrets")
+	}
+	return m.ListProjectSecretsFunc(ctx, projectID)
+}
+
+func (m *Store) LiveSecrets(ctx context.Context, projectID, kind, name, now string) ([]domain.ProjectSecret, error) {
+	m.record("LiveSecrets")
+	if m.LiveSecretsFunc == nil {
+		return zero[[]domain.ProjectSecret](), notStubbed("LiveSecrets")
+	}
+	return m.LiveSecretsFunc(ctx, projectID, kind, name, now)
+}
+
+func (m *Store) ListActorCapabilities(ctx context.Context, projectID, actorID string) ([]string, error) {
+	m.record("ListActorCapabilities")
+	if m.ListActorCapabilitiesFunc == nil {
+		return zero[[]string](), notStubbed("ListActorCapabilities")
+	}
+	return m.ListActorCapabilitiesFunc(ctx, projectID, actorID)
+}
+
+func (m *Store) GetTask(ctx context.Context, id string) (domain.Task, error) {
+	m.record("GetTask")
+	if m.GetTaskFunc == nil {
+		return zero[domain.Task](), notStubbed("GetTask")
+	}
+	return m.GetTaskFunc(ctx, id)
+}
+
+func (m *Store) ListTasks(ctx context.Context, f repo.TaskFilters) ([]domain.Task, error) {
+	m.record("ListTasks")
+	if m.ListTasksFunc == nil {
+		return zero[[]domain.Task](), notStubbed("ListTasks")
+	}
+	return m.ListTasksFunc(ctx, f)
+}
+
+func (m *Store) CountTasksByStatus(ctx context.Context, projectID string) (map[string]int, error) {
+	m.record("CountTasksByStatus")
+	if m.CountTasksByStatusFunc == nil {
+		return zero[map[string]int](), notStubbed("CountTasksByStatus")
+	}
+	return m.CountTasksByStatusFunc(ctx, projectID)
+}
+
+func (m *Store) ListTaskDependencies(ctx context.Context, taskID string) ([]string, error) {
+	m.record("ListTaskDependencies")
+	if m.ListTaskDependenciesFunc == nil {
+		return zero[[]string](), notStubbed("ListTaskDependencies")
+	}
+	return m.ListTaskDependenciesFunc(ctx, taskID)
+}
+
+func (m *Store) ListTaskAssignees(ctx context.Context, taskID string) ([]domain.TaskAssignee, error) {
+	m.record("ListTaskAssignees")
+	if m.ListTaskAssigneesFunc == nil {
+		return zero[[]domain.TaskAssignee](), notStubbed("ListTaskAssignees")
+	}
+	return m.ListTaskAssigneesFunc(ctx, taskID)
+}
+
+func (m *Store) ListTaskHandoffs(ctx context.Context, taskID string) ([]domain.TaskHandoff, error) {
+	m.record("ListTaskHandoffs")
+	if m.ListTaskHandoffsFunc == nil {
+		return zero[[]domain.TaskHandoff](), notStubbed("ListTaskHandoffs")
+	}
+	return m.ListTaskHandoffsFunc(ctx, taskID)
+}
+
+func (m *Store) ListTaskCompletions(ctx context.Context, taskID string) ([]domain.TaskCompletion, error) {
+	m.record("ListTaskCompletions")
+	if m.ListTaskCompletionsFunc == nil {
+		return zero[[]domain.TaskCompletion](), notStubbed("ListTaskCompletions")
+	}
+	return m.ListTaskCompletionsFunc(ctx, taskID)
+}
+
+func (m *Store) ListTaskLinks(ctx context.Context, taskID string) ([]domain.TaskLink, error) {
+	m.record("ListTaskLinks")
+	if m.ListTaskLinksFunc == nil {
+		return zero[[]domain.TaskLink](), notStubbed("ListTaskLinks")
+	}
+	return m.ListTaskLinksFunc(ctx, taskID)
+}
+
+func (m *Store) ListWaivers(ctx context.Context, taskID string) ([]domain.Waiver, error) {
+	m.record("ListWaivers")
+	if m.ListWaiversFunc == nil {
+		return zero[[]domain.Waiver](), notStubbed("ListWaivers")
+	}
+	return m.ListWaiversFunc(ctx, taskID)
+}
+
+func (m *Store) GetIteration(ctx context.Context, id string) (domain.Iteration, error) {
+	m.record("GetIteration")
+	if m.GetIterationFunc == nil {
+		return zero[domain.Iteration](), notStubbed("GetIteration")
+	}
+	return m.GetIterationFunc(ctx, id)
+}
+
+func (m *Store) ListIterationsWithCursor(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Iteration, error) {
+	m.record("ListIterationsWithCursor")
+	if m.ListIterationsWithCursorFunc == nil {
+		return zero[[]domain.Iteration](), notStubbed("ListIterationsWithCursor")
+	}
+	return m.ListIterationsWithCursorFunc(ctx, projectID, limit, cursorCreatedAt, cursorID)
+}
+
+func (m *Store) LatestRunningIteration(ctx context.Context, projectID string) (*domain.Iteration, error) {
+	m.record("LatestRunningIteration")
+	if m.LatestRunningIterationFunc == nil {
+		return zero[*domain.Iteration](), notStubbed("LatestRunningIteration")
+	}
+	return m.LatestRunningIterationFunc(ctx, projectID)
+}
+
+func (m *Store) GetDecision(ctx context.Context, id string) (domain.Decision, error) {
+	m.record("GetDecision")
+	if m.GetDecisionFunc == nil {
+		return zero[domain.Decision](), notStubbed("GetDecision")
+	}
+	return m.GetDecisionFunc(ctx, id)
+}
+
+func (m *Store) ListDecisions(ctx context.Context, f repo.DecisionFilters) ([]domain.Decision, error) {
+	m.record("ListDecisions")
+	if m.ListDecisionsFunc == nil {
+		return zero[[]domain.Decision](), notStubbed("ListDecisions")
+	}
+	return m.ListDecisionsFunc(ctx, f)
+}
+
+func (m *Store) ListAttestations(ctx context.Context, f repo.AttestationFilters) ([]domain.Attestation, error) {
+	m.record("ListAttestations")
+	if m.ListAttestationsFunc == nil {
+		return zero[[]domain.Attestation](), notStubbed("ListAttestations")
+	}
+	return m.ListAttestationsFunc(ctx, f)
+}
+
+func (m *Store) BlobReferenced(ctx context.Context, projectID, digest string) (bool, error) {
+	m.record("BlobReferenced")
+	if m.BlobReferencedFunc == nil {
+		return zero[bool](), notStubbed("BlobReferenced")
+	}
+	return m.BlobReferencedFunc(ctx, projectID, digest)
+}
+
+func (m *Store) GetArtifact(ctx context.Context, id string) (domain.Artifact, error) {
+	m.record("GetArtifact")
+	if m.GetArtifactFunc == nil {
+		return zero[domain.Artifact](), notStubbed("GetArtifact")
+	}
+	return m.GetArtifactFunc(ctx, id)
+}
+
+func (m *Store) ListArtifacts(ctx context.Context, projectID string, limit int, cursorCreatedAt, cursorID string) ([]domain.Artifact, error) {
+	m.record("ListArtifacts")
+	if m.ListArtifactsFunc == nil {
+		return zero[[]domain.Artifact](), notStubbed("ListArtifacts")
+	}
+	return m.ListArtifactsFunc(ctx, projectID, limit, cursorCreatedAt, cursorID)
+}
+
+func (m *Store) LatestEventsFrom(ctx context.Context, limit int, cursor int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error) {
+	m.record("LatestEventsFrom")
+	if m.LatestEventsFromFunc == nil {
+		return zero[[]domain.Event](), notStubbed("LatestEventsFrom")
+	}
+	return m.LatestEventsFromFunc(ctx, limit, cursor, projectID, evtType, entityKind, entityID, requestID)
+}
+
+func (m *Store) EventsSince(ctx context.Context, limit int, afterID int64, projectID, evtType, entityKind, entityID, requestID string) ([]domain.Event, error) {
+	m.record("EventsSince")
+	if m.EventsSinceFunc == nil {
+		return zero[[]domain.Event](), notStubbed("EventsSince")
+	}
+	return m.EventsSinceFunc(ctx, limit, afterID, projectID, evtType, entityKind, entityID, requestID)
+}
+
+func (m *Store) MaxEventID(ctx context.Context, projectID string) (int64, error) {
+	m.record("MaxEventID")
+	if m.MaxEventIDFunc == nil {
+		return zero[int64](), notStubbed("MaxEventID")
+	}
+	return m.MaxEventIDFunc(ctx, projectID)
+}
+
+func (m *Store) ActorEventsFrom(ctx context.Context, limit int, cursor int64, projectID, actorID, since string) ([]domain.Event, error) {
+	m.record("ActorEventsFrom")
+	if m.ActorEventsFromFunc == nil {
+		return zero[[]domain.Event](), notStubbed("ActorEventsFrom")
+	}
+	return m.ActorEventsFromFunc(ctx, limit, cursor, projectID, actorID, since)
+}
+
+func (m *Store) CountActorEventsByType(ctx context.Context, projectID, actorID, since string) (map[string]int, error) {
+	m.record("CountActorEventsByType")
+	if m.CountActorEventsByTypeFunc == nil {
+		return zero[map[string]int](), notStubbed("CountActorEventsByType")
+	}
+	return m.CountActorEventsByTypeFunc(ctx, projectID, actorID, since)
+}
+
+func (m *Store) CountActorCompletions(ctx context.Context, projectID, actorID, since string) (int, error) {
+	m.record("CountActorCompletions")
+	if m.CountActorCompletionsFunc == nil {
+		return zero[int](), notStubbed("CountActorCompletions")
+	}
+	return m.CountActorCompletionsFunc(ctx, projectID, actorID, since)
+}
+
+func (m *Store) ListStatsSnapshots(ctx context.Context, projectID, from, to string) ([]domain.StatsSnapshot, error) {
+	m.record("ListStatsSnapshots")
+	if m.ListStatsSnapshotsFunc == nil {
+		return zero[[]domain.StatsSnapshot](), notStubbed("ListStatsSnapshots")
+	}
+	return m.ListStatsSnapshotsFunc(ctx, projectID, from, to)
+}
+
+func (m *Store) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	m.record("GetAPIKeyByHash")
+	if m.GetAPIKeyByHashFunc == nil {
+		return zero[domain.APIKey](), notStubbed("GetAPIKeyByHash")
+	}
+	return m.GetAPIKeyByHashFunc(ctx, hash)
+}
+
+func (m *Store) GetLiveAPIToken(ctx context.Context, hash, now string) (domain.ProjectSecret, error) {
+	m.record("GetLiveAPIToken")
+	if m.GetLiveAPITokenFunc == nil {
+		return zero[domain.ProjectSecret](), notStubbed("GetLiveAPIToken")
+	}
+	return m.GetLiveAPITokenFunc(ctx, hash, now)
+}
+
+func (m *Store) GetClientCertMapping(ctx context.Context, kind, value string) (domain.ClientCertMapping, error) {
+	m.record("GetClientCertMapping")
+	if m.GetClientCertMappingFunc == nil {
+		return zero[domain.ClientCertMapping](), notStubbed("GetClientCertMapping")
+	}
+	return m.GetClientCertMappingFunc(ctx, kind, value)
+}
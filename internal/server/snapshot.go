@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"workline/internal/repo"
+)
+
+const snapshotCursorPrefix = "snap:"
+
+// isSnapshotCursor reports whether cursor pages through a snapshot rather than the live table.
+func isSnapshotCursor(cursor string) bool {
+	return strings.HasPrefix(cursor, snapshotCursorPrefix)
+}
+
+func composeSnapshotCursor(token string, offset int) string {
+	return snapshotCursorPrefix + token + ":" + strconv.Itoa(offset)
+}
+
+func parseSnapshotCursor(cursor string) (string, int, bool) {
+	token, rawOffset, ok := strings.Cut(strings.TrimPrefix(cursor, snapshotCursorPrefix), ":")
+	if !ok || token == "" {
+		return "", 0, false
+	}
+	offset, err := strconv.Atoi(rawOffset)
+	if err != nil || offset < 0 {
+		return "", 0, false
+	}
+	return token, offset, true
+}
+
+// listTasksSnapshot serves one page of a task list pinned to a snapshot. With an empty
+// cursor it captures the tasks filter matches into a new snapshot in the database;
+// otherwise it reads the page the snapshot cursor points at. Filters only apply when the
+// snapshot is taken.
+func listTasksSnapshot(ctx context.Context, e Engine, scope, actorID, cursor string, limit int, filter repo.TaskFilters) (paginatedTasks, error) {
+	var token string
+	offset := 0
+	if cursor == "" {
+		snap, err := e.CaptureTaskSnapshot(ctx, scope, actorID, filter)
+		if err != nil {
+			return paginatedTasks{}, handleError(err)
+		}
+		token = snap.ID
+	} else {
+		var ok bool
+		token, offset, ok = parseSnapshotCursor(cursor)
+		if !ok {
+			return paginatedTasks{}, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor", map[string]any{"cursor": cursor})
+		}
+	}
+	snap, items, err := e.TaskSnapshotPage(ctx, token, scope, actorID, offset, limit+1)
+	if errors.Is(err, repo.ErrNotFound) {
+		return paginatedTasks{}, newAPIError(http.StatusBadRequest, "bad_request", "invalid cursor: snapshot expired or unknown", map[string]any{"cursor": cursor})
+	}
+	if err != nil {
+		return paginatedTasks{}, handleError(err)
+	}
+	resp := paginatedTasks{Items: []TaskResponse{}, SnapshotAt: snap.TakenAt}
+	if len(items) > limit {
+		items = items[:limit]
+		resp.NextCursor = composeSnapshotCursor(token, offset+limit)
+	}
+	resp.Items = mapTasks(items)
+	return resp, nil
+}
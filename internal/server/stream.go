@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// streamMinItems is the slice length from which a response body is encoded item by item.
+const streamMinItems = 100
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// streamingJSONFormat renders the same bytes as huma.DefaultJSONFormat, but a long slice at
+// the top of the body or in one of its fields (list items, tree roots) is encoded one element
+// at a time through a buffered writer, so a 50k-task list or tree is never held in memory as
+// one encoded document.
+var streamingJSONFormat = huma.Format{
+	Marshal: func(w io.Writer, v any) error {
+		s := newStreamEncoder(w)
+		if err := s.encode(reflect.ValueOf(v)); err != nil {
+			return err
+		}
+		if err := s.w.WriteByte('\n'); err != nil {
+			return err
+		}
+		return s.w.Flush()
+	},
+	Unmarshal: json.Unmarshal,
+}
+
+type streamEncoder struct {
+	w   *bufio.Writer
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+func newStreamEncoder(w io.Writer) *streamEncoder {
+	s := &streamEncoder{w: bufio.NewWriterSize(w, 32<<10)}
+	s.enc = json.NewEncoder(&s.buf)
+	s.enc.SetEscapeHTML(false)
+	return s
+}
+
+func (s *streamEncoder) encode(v reflect.Value) error {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() && !hasJSONMarshaler(v.Type()) {
+		v = v.Elem()
+	}
+	switch {
+	case streamable(v):
+		return s.items(v)
+	case v.IsValid() && v.Kind() == reflect.Struct && streamableFields(v):
+		return s.fields(v)
+	case !v.IsValid():
+		return s.value(nil)
+	}
+	return s.value(v.Interface())
+}
+
+// value writes one JSON value without the newline json.Encoder appends.
+func (s *streamEncoder) value(v any) error {
+	s.buf.Reset()
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := s.w.Write(bytes.TrimSuffix(s.buf.Bytes(), []byte("\n")))
+	return err
+}
+
+func (s *streamEncoder) items(v reflect.Value) error {
+	if err := s.w.WriteByte('['); err != nil {
+		return err
+	}
+	for i := range v.Len() {
+		if i > 0 {
+			if err := s.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if err := s.value(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return s.w.WriteByte(']')
+}
+
+// fields writes a struct whose fields streamableFields accepted, following the json tags.
+func (s *streamEncoder) fields(v reflect.Value) error {
+	if err := s.w.WriteByte('{'); err != nil {
+		return err
+	}
+	first := true
+	for i := range v.NumField() {
+		name, omitEmpty, ok := jsonField(v.Type().Field(i))
+		fv := v.Field(i)
+		if !ok || omitEmpty && emptyJSONValue(fv) {
+			continue
+		}
+		if !first {
+			if err := s.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		first = false
+		if err := s.value(name); err != nil {
+			return err
+		}
+		if err := s.w.WriteByte(':'); err != nil {
+			return err
+		}
+		var err error
+		if streamable(fv) {
+			err = s.items(fv)
+		} else {
+			err = s.value(fv.Interface())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return s.w.WriteByte('}')
+}
+
+// streamable reports whether v is a slice long enough to encode item by item that
+// encoding/json would render as an array.
+func streamable(v reflect.Value) bool {
+	return v.IsValid() && v.Kind() == reflect.Slice && !v.IsNil() && v.Len() >= streamMinItems &&
+		v.Type().Elem().Kind() != reflect.Uint8 && !hasJSONMarshaler(v.Type())
+}
+
+// streamableFields reports whether a struct has a streamable field and is plain enough for
+// fields to write: no embedding, no ",string" options and no custom marshaling.
+func streamableFields(v reflect.Value) bool {
+	t := v.Type()
+	if hasJSONMarshaler(t) {
+		return false
+	}
+	found := false
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous {
+			return false
+		}
+		if _, _, ok := jsonField(f); !ok {
+			continue
+		}
+		if _, opts, _ := strings.Cut(f.Tag.Get("json"), ","); strings.Contains(","+opts+",", ",string,") {
+			return false
+		}
+		found = found || streamable(v.Field(i))
+	}
+	return found
+}
+
+func jsonField(f reflect.StructField) (name string, omitEmpty, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(","+opts+",", ",omitempty,"), true
+}
+
+func hasJSONMarshaler(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(jsonMarshalerType)
+}
+
+// emptyJSONValue mirrors the omitempty rule of encoding/json.
+func emptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"workline/internal/engine"
+)
+
+// newUsageRecorder counts every authenticated call against its actor's daily usage in the
+// project it targets and answers 429 quota_exceeded once a role quota is used up. Accounting
+// is best effort: a failure to record a call never fails the call itself.
+func newUsageRecorder(basePath string, e Engine) func(http.Handler) http.Handler {
+	prefix := strings.TrimSuffix(basePath, "/") + "/"
+	projectsPrefix := prefix + "projects/"
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			principal, ok := principalFromContext(req.Context())
+			if !ok || principal.ActorID == "" || req.Method == http.MethodOptions || !strings.HasPrefix(req.URL.Path, prefix) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			projectID := ""
+			if rest, ok := strings.CutPrefix(req.URL.Path, projectsPrefix); ok {
+				projectID, _, _ = strings.Cut(rest, "/")
+			}
+			if projectID == "" {
+				projectID = strings.TrimSpace(req.Header.Get("X-Project-Id"))
+			}
+			if projectID == "" && e.DefaultConfig() != nil {
+				projectID = e.DefaultConfig().Project.ID
+			}
+			if projectID == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+			// GraphQL queries are posted but only read.
+			mutation := req.Method != http.MethodGet && req.Method != http.MethodHead && req.URL.Path != prefix+"graphql"
+			var qe engine.QuotaExceededError
+			if err := e.RecordUsage(req.Context(), projectID, principal.ActorID, mutation); errors.As(err, &qe) {
+				retryAfter := int(time.Until(qe.ResetAt).Seconds()) + 1
+				w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+				respondStatusError(w, req, newAPIError(http.StatusTooManyRequests, "quota_exceeded", qe.Error(), map[string]any{
+					"quota":    qe.Quota,
+					"limit":    qe.Limit,
+					"reset_at": qe.ResetAt.UTC().Format(time.RFC3339),
+				}))
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func registerUsage(api huma.API, e Engine) {
+	huma.Register(api, huma.Operation{
+		OperationID: "project-usage",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/usage",
+		Summary:     "Per-actor API usage",
+		Description: "Daily request, mutation and rejected call counts per actor, with the quotas currently applying to them. Requires usage.read, except for an actor reading its own usage with actor set to itself.",
+		Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	}, func(ctx context.Context, input *struct {
+		ProjectID string `path:"project_id"`
+		From      string `query:"from" doc:"Period start (RFC3339 or YYYY-MM-DD), defaults to 30 days before to"`
+		To        string `query:"to" doc:"Period end, exclusive (RFC3339), or last day included (YYYY-MM-DD); defaults to today (UTC)"`
+		Actor     string `query:"actor" doc:"Only this actor"`
+	}) (*struct {
+		Body engine.UsageReport `json:"body"`
+	}, error) {
+		projectID := projectFromPathOrHeader(ctx, input.ProjectID, e.DefaultConfig().Project.ID)
+		actorID, aerr := actorIDFromContext(ctx)
+		if aerr != nil {
+			return nil, aerr
+		}
+		if input.Actor != actorID {
+			if err := requirePermission(ctx, e, projectID, "usage.read"); err != nil {
+				return nil, handleError(err)
+			}
+		}
+		from, to, err := engine.ParsePeriod(input.From, input.To, time.Now())
+		if err != nil {
+			return nil, handleError(err)
+		}
+		rep, err := e.Usage(ctx, projectID, input.Actor, from, to)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		return &struct {
+			Body engine.UsageReport `json:"body"`
+		}{Body: rep}, nil
+	})
+}
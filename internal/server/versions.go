@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// API versions served side by side. v1 is current; v0 keeps the response shapes existing
+// agents were built against and is deprecated.
+const (
+	APIVersionV0 = "v0"
+	APIVersionV1 = "v1"
+)
+
+// specVersions is the info.version of each API version's OpenAPI document.
+var specVersions = map[string]string{
+	APIVersionV0: "0.1.1",
+	APIVersionV1: "1.0.0",
+}
+
+// v0DeprecatedAt is when v1 superseded v0, announced in the Deprecation header of v0
+// responses.
+var v0DeprecatedAt = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+// versionedAPI is the group an API version's operations are registered on, so operations
+// whose shape changed between versions can register the right one.
+type versionedAPI struct {
+	*huma.Group
+	version string
+}
+
+// apiVersion returns the API version operations registered on api belong to.
+func apiVersion(api huma.API) string {
+	if v, ok := api.(versionedAPI); ok {
+		return v.version
+	}
+	return APIVersionV1
+}
+
+// itemsBody is how v1 returns lists that are not paginated, like the items of paginated
+// lists.
+type itemsBody[T any] struct {
+	Items []T `json:"items"`
+}
+
+// registerList registers an operation returning an unpaginated list. v1 wraps the list in
+// {"items": [...]}; v0 keeps the bare array it always returned and marks the operation
+// deprecated.
+func registerList[I, T any](api huma.API, op huma.Operation, handler func(context.Context, *I) ([]T, error)) {
+	if apiVersion(api) == APIVersionV0 {
+		op.Deprecated = true
+		huma.Register(api, op, func(ctx context.Context, input *I) (*struct {
+			Body []T `json:"body"`
+		}, error) {
+			items, err := handler(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			return &struct {
+				Body []T `json:"body"`
+			}{Body: items}, nil
+		})
+		return
+	}
+	huma.Register(api, op, func(ctx context.Context, input *I) (*struct {
+		Body itemsBody[T] `json:"body"`
+	}, error) {
+		items, err := handler(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return &struct {
+			Body itemsBody[T] `json:"body"`
+		}{Body: itemsBody[T]{Items: items}}, nil
+	})
+}
+
+// newDeprecationMiddleware marks every response below basePath as coming from a deprecated
+// API version: Deprecation (RFC 9745) with the date it was superseded, Sunset (RFC 8594)
+// when a removal date is set, and a successor-version link to the same path below
+// successor.
+func newDeprecationMiddleware(basePath, successor string, sunset time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rest, ok := strings.CutPrefix(r.URL.Path, basePath); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", v0DeprecatedAt.Unix()))
+				if !sunset.IsZero() {
+					w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				}
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor+rest))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIVersionInfo describes an API version in GET /versions.
+type APIVersionInfo struct {
+	Version      string `json:"version"`
+	BasePath     string `json:"base_path"`
+	OpenAPI      string `json:"openapi"`
+	Status       string `json:"status"`
+	DeprecatedAt string `json:"deprecated_at,omitempty"`
+	Sunset       string `json:"sunset,omitempty"`
+}
+
+// newVersionRouter sends requests below v1Path to the v1 handler, answers GET /versions
+// and leaves everything else, including /docs, to the v0 handler.
+func newVersionRouter(cfg Config, v0Path, v1Path string, v0, v1 http.Handler) http.Handler {
+	versions := []APIVersionInfo{
+		{Version: APIVersionV1, BasePath: v1Path, OpenAPI: path.Join(v1Path, "openapi.json"), Status: "current"},
+		{Version: APIVersionV0, BasePath: v0Path, OpenAPI: path.Join(v0Path, "openapi.json"), Status: "deprecated", DeprecatedAt: v0DeprecatedAt.Format(time.RFC3339)},
+	}
+	if !cfg.V0Sunset.IsZero() {
+		versions[1].Sunset = cfg.V0Sunset.UTC().Format(time.RFC3339)
+	}
+	listing, _ := json.Marshal(map[string]any{"versions": versions})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == v1Path || strings.HasPrefix(r.URL.Path, v1Path+"/"):
+			v1.ServeHTTP(w, r)
+		case r.URL.Path == "/versions" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(listing)
+		default:
+			v0.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+// Package telemetry exports workflow metrics to an OpenTelemetry collector.
+//
+// Metrics are pushed with OTLP over HTTP in its JSON encoding, which every collector accepts
+// at /v1/metrics, so no OpenTelemetry SDK is needed.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric kinds.
+const (
+	// Gauge is a value sampled at collection time.
+	Gauge = "gauge"
+	// Counter is a cumulative, monotonic sum.
+	Counter = "counter"
+)
+
+// Metric is an instrument with its data points at one collection.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Kind        string
+	Points      []Point
+}
+
+// Point is one value of a metric, for one set of attributes.
+type Point struct {
+	Attributes map[string]string
+	Value      int64
+}
+
+// Exporter pushes metrics to an OTLP/HTTP endpoint, e.g. http://collector:4318/v1/metrics.
+type Exporter struct {
+	Endpoint string
+	// Headers are sent with every export, e.g. a collector's API key.
+	Headers map[string]string
+	// ServiceName identifies this server in the service.name resource attribute.
+	ServiceName string
+	Client      *http.Client
+	// Start is when the cumulative counters started; it defaults to the first export.
+	Start time.Time
+}
+
+// FromEnv configures an exporter from the standard OpenTelemetry environment variables:
+// OTEL_EXPORTER_OTLP_METRICS_ENDPOINT, or OTEL_EXPORTER_OTLP_ENDPOINT with /v1/metrics
+// appended, the matching _HEADERS variables (key=value pairs separated by commas) and
+// OTEL_SERVICE_NAME. It returns nil when no endpoint is set.
+func FromEnv(getenv func(string) string) *Exporter {
+	endpoint := getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT")
+	if endpoint == "" {
+		if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/metrics"
+		}
+	}
+	if endpoint == "" {
+		return nil
+	}
+	headers := parseHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for k, v := range parseHeaders(getenv("OTEL_EXPORTER_OTLP_METRICS_HEADERS")) {
+		headers[k] = v
+	}
+	return &Exporter{Endpoint: endpoint, Headers: headers, ServiceName: getenv("OTEL_SERVICE_NAME")}
+}
+
+func parseHeaders(s string) map[string]string {
+	res := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); ok && k != "" {
+			res[k] = strings.TrimSpace(v)
+		}
+	}
+	return res
+}
+
+// Export sends the metrics, stamped with now, in one request.
+func (x *Exporter) Export(ctx context.Context, metrics []Metric, now time.Time) error {
+	if x.Start.IsZero() {
+		x.Start = now
+	}
+	service := x.ServiceName
+	if service == "" {
+		service = "workline"
+	}
+	body := map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource": map[string]any{"attributes": attributes(map[string]string{"service.name": service})},
+		"scopeMetrics": []any{map[string]any{
+			"scope":   map[string]any{"name": "workline/engine"},
+			"metrics": encodeMetrics(metrics, x.Start, now),
+		}},
+	}}}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, x.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range x.Headers {
+		req.Header.Set(k, v)
+	}
+	client := x.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("otlp export: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// encodeMetrics renders metrics in the OTLP JSON mapping, where 64-bit integers and
+// timestamps are strings and cumulative sums use aggregation temporality 2.
+func encodeMetrics(metrics []Metric, start, now time.Time) []any {
+	startNano := strconv.FormatInt(start.UnixNano(), 10)
+	nowNano := strconv.FormatInt(now.UnixNano(), 10)
+	res := make([]any, 0, len(metrics))
+	for _, m := range metrics {
+		points := make([]any, 0, len(m.Points))
+		for _, p := range m.Points {
+			point := map[string]any{
+				"attributes":   attributes(p.Attributes),
+				"timeUnixNano": nowNano,
+				"asInt":        strconv.FormatInt(p.Value, 10),
+			}
+			if m.Kind == Counter {
+				point["startTimeUnixNano"] = startNano
+			}
+			points = append(points, point)
+		}
+		out := map[string]any{"name": m.Name, "description": m.Description, "unit": m.Unit}
+		if m.Kind == Counter {
+			out["sum"] = map[string]any{"dataPoints": points, "aggregationTemporality": 2, "isMonotonic": true}
+		} else {
+			out["gauge"] = map[string]any{"dataPoints": points}
+		}
+		res = append(res, out)
+	}
+	return res
+}
+
+func attributes(attrs map[string]string) []any {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]any, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, map[string]any{"key": k, "value": map[string]any{"stringValue": attrs[k]}})
+	}
+	return res
+}
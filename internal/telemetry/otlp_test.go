@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"workline/internal/engine"
+)
+
+func TestExportWorkflowMetrics(t *testing.T) {
+	var got struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name  string `json:"name"`
+					Gauge *struct {
+						DataPoints []struct {
+							AsInt string `json:"asInt"`
+						} `json:"dataPoints"`
+					} `json:"gauge"`
+					Sum *struct {
+						AggregationTemporality int  `json:"aggregationTemporality"`
+						IsMonotonic            bool `json:"isMonotonic"`
+						DataPoints             []struct {
+							AsInt             string `json:"asInt"`
+							StartTimeUnixNano string `json:"startTimeUnixNano"`
+						} `json:"dataPoints"`
+					} `json:"sum"`
+				} `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	var apiKey string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-Api-Key")
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer collector.Close()
+
+	env := map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL + "/", "OTEL_EXPORTER_OTLP_HEADERS": "x-api-key=secret"}
+	x := FromEnv(func(k string) string { return env[k] })
+	if x == nil || x.Endpoint != collector.URL+"/v1/metrics" {
+		t.Fatalf("unexpected exporter %+v", x)
+	}
+	ms := []engine.WorkflowMetrics{{ProjectID: "p", OpenTasks: map[string]int{"planned": 2, "review": 1}, ActiveLeases: 1, ValidationBlocked: 1, Attestations: 7}}
+	if err := x.Export(context.Background(), WorkflowInstruments(ms), time.Unix(100, 0)); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if apiKey != "secret" {
+		t.Fatalf("expected configured headers sent, got %q", apiKey)
+	}
+	metrics := got.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 4 || metrics[0].Name != "workline.tasks.open" || len(metrics[0].Gauge.DataPoints) != 2 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	counter := metrics[3]
+	if counter.Name != "workline.attestations" || counter.Sum == nil || !counter.Sum.IsMonotonic || counter.Sum.AggregationTemporality != 2 {
+		t.Fatalf("expected a cumulative attestation counter, got %+v", counter)
+	}
+	if p := counter.Sum.DataPoints[0]; p.AsInt != "7" || p.StartTimeUnixNano != "100000000000" {
+		t.Fatalf("unexpected counter point %+v", p)
+	}
+
+	if FromEnv(func(string) string { return "" }) != nil {
+		t.Fatalf("expected no exporter without an endpoint")
+	}
+	failing := &Exporter{Endpoint: collector.URL + "/elsewhere"}
+	if err := failing.Export(context.Background(), nil, time.Now()); err == nil {
+		t.Fatalf("expected a refused export to fail")
+	}
+}
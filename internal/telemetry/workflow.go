@@ -0,0 +1,33 @@
+package telemetry
+
+import (
+	"sort"
+
+	"workline/internal/engine"
+)
+
+// WorkflowInstruments turns the engine's workflow metrics into the exported instruments:
+// workline.tasks.open by project and status, workline.leases.active,
+// workline.tasks.validation_blocked and the workline.attestations counter, whose rate is
+// the attestation rate.
+func WorkflowInstruments(ms []engine.WorkflowMetrics) []Metric {
+	open := Metric{Name: "workline.tasks.open", Description: "Unfinished tasks by status", Unit: "{task}", Kind: Gauge}
+	leases := Metric{Name: "workline.leases.active", Description: "Unexpired task leases", Unit: "{lease}", Kind: Gauge}
+	blocked := Metric{Name: "workline.tasks.validation_blocked", Description: "Tasks in review with requirements neither attested nor waived", Unit: "{task}", Kind: Gauge}
+	attestations := Metric{Name: "workline.attestations", Description: "Attestations recorded", Unit: "{attestation}", Kind: Counter}
+	for _, m := range ms {
+		project := map[string]string{"project_id": m.ProjectID}
+		statuses := make([]string, 0, len(m.OpenTasks))
+		for status := range m.OpenTasks {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			open.Points = append(open.Points, Point{Attributes: map[string]string{"project_id": m.ProjectID, "status": status}, Value: int64(m.OpenTasks[status])})
+		}
+		leases.Points = append(leases.Points, Point{Attributes: project, Value: int64(m.ActiveLeases)})
+		blocked.Points = append(blocked.Points, Point{Attributes: project, Value: int64(m.ValidationBlocked)})
+		attestations.Points = append(attestations.Points, Point{Attributes: project, Value: int64(m.Attestations)})
+	}
+	return []Metric{open, leases, blocked, attestations}
+}
@@ -0,0 +1,322 @@
+// Package proofline embeds Workline in-process, without the HTTP server.
+//
+// It wraps the engine and SQLite workspace so Go programs (test harnesses,
+// agents, CI helpers) get the same RBAC checks, validation gates, and event
+// log entries as requests served by `wl serve`.
+package proofline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"workline/internal/blob"
+	"workline/internal/config"
+	"workline/internal/db"
+	"workline/internal/domain"
+	"workline/internal/engine"
+	"workline/internal/migrate"
+	"workline/internal/repo"
+)
+
+// Re-exported domain types so callers outside this module can name them.
+type (
+	Project     = domain.Project
+	Iteration   = domain.Iteration
+	Task        = domain.Task
+	Decision    = domain.Decision
+	Lease       = domain.Lease
+	Attestation = domain.Attestation
+	Event       = domain.Event
+	Config      = config.Config
+
+	TaskCreateOptions  = engine.TaskCreateOptions
+	TaskUpdateOptions  = engine.TaskUpdateOptions
+	TaskFilters        = repo.TaskFilters
+	AttestationFilters = repo.AttestationFilters
+)
+
+// ErrNotFound is returned when a requested entity does not exist.
+var ErrNotFound = repo.ErrNotFound
+
+// Options configure an embedded instance.
+type Options struct {
+	// Workspace is the directory holding .workline/workline.db. Defaults to ".".
+	Workspace string
+	// ProjectID is the project every call is scoped to. Required.
+	ProjectID string
+	// ActorID is the default actor for mutations. Defaults to "local-user".
+	ActorID string
+	// Description is used when the project has to be created.
+	Description string
+	// Config seeds the project config when the project is created. Defaults to config.Default.
+	Config *config.Config
+}
+
+// Local is an embedded Workline instance bound to a single project.
+type Local struct {
+	conn      *sql.DB
+	engine    engine.Engine
+	projectID string
+	actorID   string
+}
+
+// Open opens (and migrates) the workspace database, creating the project with
+// seeded config and RBAC when it does not exist yet.
+func Open(ctx context.Context, opts Options) (*Local, error) {
+	projectID := strings.TrimSpace(opts.ProjectID)
+	if projectID == "" {
+		return nil, errors.New("project id is required")
+	}
+	actorID := strings.TrimSpace(opts.ActorID)
+	if actorID == "" {
+		actorID = "local-user"
+	}
+	workspace := opts.Workspace
+	if workspace == "" {
+		workspace = "."
+	}
+	conn, err := db.Open(db.Config{Workspace: workspace})
+	if err != nil {
+		return nil, err
+	}
+	l, err := open(ctx, conn, projectID, actorID, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func open(ctx context.Context, conn *sql.DB, projectID, actorID string, opts Options) (*Local, error) {
+	if err := migrate.Migrate(conn); err != nil {
+		return nil, err
+	}
+	r := repo.Repo{DB: conn}
+	if _, err := r.GetProject(ctx, projectID); err != nil {
+		if !errors.Is(err, repo.ErrNotFound) {
+			return nil, err
+		}
+		seed := opts.Config
+		if seed == nil {
+			seed = config.Default(projectID)
+		}
+		if _, err := engine.New(conn, seed).InitProject(ctx, projectID, opts.Description, actorID); err != nil {
+			return nil, fmt.Errorf("init project: %w", err)
+		}
+	}
+	cfg, err := r.GetProjectConfig(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("load project config: %w", err)
+	}
+	cfg.Project.ID = projectID
+	eng := engine.New(conn, cfg)
+	if eng.Blobs, err = blob.Open(cfg.Blobs, opts.Workspace); err != nil {
+		return nil, err
+	}
+	return &Local{
+		conn:      conn,
+		engine:    eng,
+		projectID: projectID,
+		actorID:   actorID,
+	}, nil
+}
+
+// Close releases the underlying database handle.
+func (l *Local) Close() error {
+	return l.conn.Close()
+}
+
+// ProjectID returns the project this instance is bound to.
+func (l *Local) ProjectID() string { return l.projectID }
+
+// ActorID returns the default actor used for mutations.
+func (l *Local) ActorID() string { return l.actorID }
+
+// Engine exposes the underlying engine for operations not covered by the facade.
+func (l *Local) Engine() engine.Engine { return l.engine }
+
+// As returns a copy of the instance that acts as a different actor.
+func (l *Local) As(actorID string) *Local {
+	cp := *l
+	cp.actorID = actorID
+	return &cp
+}
+
+// Config returns the project config loaded at open time.
+func (l *Local) Config() *Config { return l.engine.Config }
+
+// ImportConfig replaces the stored project config and reloads it.
+func (l *Local) ImportConfig(ctx context.Context, cfg *Config) error {
+	if err := l.engine.Repo.UpsertProjectConfig(ctx, l.projectID, cfg); err != nil {
+		return err
+	}
+	l.engine.Config = cfg
+	return nil
+}
+
+// Project returns the bound project.
+func (l *Local) Project(ctx context.Context) (Project, error) {
+	return l.engine.Repo.GetProject(ctx, l.projectID)
+}
+
+// CreateTask creates a task in the bound project. ProjectID and ActorID default to the
+// instance values; a task for another project is refused as not found.
+func (l *Local) CreateTask(ctx context.Context, opts TaskCreateOptions) (Task, error) {
+	if opts.ProjectID == "" {
+		opts.ProjectID = l.projectID
+	}
+	if opts.ProjectID != l.projectID {
+		return Task{}, ErrNotFound
+	}
+	if opts.ActorID == "" {
+		opts.ActorID = l.actorID
+	}
+	return l.engine.CreateTask(ctx, opts)
+}
+
+// GetTask returns a task of the bound project.
+func (l *Local) GetTask(ctx context.Context, id string) (Task, error) {
+	t, err := l.engine.Repo.GetTask(ctx, id)
+	if err != nil {
+		return t, err
+	}
+	if t.ProjectID != l.projectID {
+		return Task{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// ListTasks lists tasks of the bound project.
+func (l *Local) ListTasks(ctx context.Context, f TaskFilters) ([]Task, error) {
+	f.ProjectID = l.projectID
+	return l.engine.Repo.ListTasks(ctx, f)
+}
+
+// UpdateTask applies updates to a task. ActorID defaults to the instance actor.
+func (l *Local) UpdateTask(ctx context.Context, opts TaskUpdateOptions) (Task, error) {
+	if _, err := l.GetTask(ctx, opts.ID); err != nil {
+		return Task{}, err
+	}
+	if opts.ActorID == "" {
+		opts.ActorID = l.actorID
+	}
+	return l.engine.UpdateTask(ctx, opts)
+}
+
+// CompleteTask records work outcomes and moves the task to done, enforcing the same gates as the API.
+func (l *Local) CompleteTask(ctx context.Context, id, workOutcomesJSON string, force bool) (Task, error) {
+	if _, err := l.GetTask(ctx, id); err != nil {
+		return Task{}, err
+	}
+	return l.engine.TaskDone(ctx, id, workOutcomesJSON, l.actorID, force)
+}
+
+// ClaimTask acquires a lease on the task for the instance actor.
+func (l *Local) ClaimTask(ctx context.Context, id string, leaseSeconds int) (Lease, error) {
+	if _, err := l.GetTask(ctx, id); err != nil {
+		return Lease{}, err
+	}
+	if leaseSeconds <= 0 {
+		leaseSeconds = 900
+	}
+	return l.engine.ClaimLease(ctx, id, l.actorID, leaseSeconds)
+}
+
+// ReleaseTask drops the lease on the task.
+func (l *Local) ReleaseTask(ctx context.Context, id string) error {
+	if _, err := l.GetTask(ctx, id); err != nil {
+		return err
+	}
+	return l.engine.ReleaseLease(ctx, id, l.actorID)
+}
+
+// CreateIteration creates an iteration in the bound project.
+func (l *Local) CreateIteration(ctx context.Context, id, goal string) (Iteration, error) {
+	return l.engine.CreateIteration(ctx, Iteration{ID: id, ProjectID: l.projectID, Goal: goal}, l.actorID)
+}
+
+// ListIterations lists iterations of the bound project.
+func (l *Local) ListIterations(ctx context.Context) ([]Iteration, error) {
+	return l.engine.Repo.ListIterations(ctx, l.projectID)
+}
+
+// GetIteration returns an iteration of the bound project.
+func (l *Local) GetIteration(ctx context.Context, id string) (Iteration, error) {
+	it, err := l.engine.Repo.GetIteration(ctx, id)
+	if err != nil {
+		return it, err
+	}
+	if it.ProjectID != l.projectID {
+		return Iteration{}, ErrNotFound
+	}
+	return it, nil
+}
+
+// SetIterationStatus transitions an iteration of the bound project.
+func (l *Local) SetIterationStatus(ctx context.Context, id, status string, force bool) (Iteration, error) {
+	if _, err := l.GetIteration(ctx, id); err != nil {
+		return Iteration{}, err
+	}
+	return l.engine.SetIterationStatus(ctx, id, status, l.actorID, force)
+}
+
+// CreateDecision records a decision in the bound project.
+func (l *Local) CreateDecision(ctx context.Context, d Decision) (Decision, error) {
+	d.ProjectID = l.projectID
+	return l.engine.CreateDecision(ctx, d, l.actorID)
+}
+
+// Attest records an attestation on an entity of the bound project. A task, iteration or
+// decision of another project is not found.
+func (l *Local) Attest(ctx context.Context, entityKind, entityID, kind, payloadJSON string) (Attestation, error) {
+	if err := l.checkEntity(ctx, entityKind, entityID); err != nil {
+		return Attestation{}, err
+	}
+	return l.engine.AddAttestation(ctx, Attestation{
+		ProjectID:   l.projectID,
+		EntityKind:  entityKind,
+		EntityID:    entityID,
+		Kind:        kind,
+		ActorID:     l.actorID,
+		PayloadJSON: payloadJSON,
+	}, l.actorID)
+}
+
+// checkEntity reports ErrNotFound unless the task, iteration or decision id belongs to the
+// bound project. Other entity kinds are left to the engine.
+func (l *Local) checkEntity(ctx context.Context, kind, id string) error {
+	switch kind {
+	case "task":
+		_, err := l.GetTask(ctx, id)
+		return err
+	case "iteration":
+		_, err := l.GetIteration(ctx, id)
+		return err
+	case "decision":
+		d, err := l.engine.Repo.GetDecision(ctx, id)
+		if err != nil {
+			return err
+		}
+		if d.ProjectID != l.projectID {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// ListAttestations lists attestations of the bound project.
+func (l *Local) ListAttestations(ctx context.Context, f AttestationFilters) ([]Attestation, error) {
+	f.ProjectID = l.projectID
+	return l.engine.Repo.ListAttestations(ctx, f)
+}
+
+// Events returns the most recent events of the bound project, newest first.
+func (l *Local) Events(ctx context.Context, limit int) ([]Event, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return l.engine.Repo.LatestEvents(ctx, limit, l.projectID, "", "", "")
+}
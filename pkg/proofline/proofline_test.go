@@ -0,0 +1,117 @@
+package proofline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"workline/pkg/proofline"
+)
+
+func TestEmbeddedTaskLifecycle(t *testing.T) {
+	ctx := context.Background()
+	l, err := proofline.Open(ctx, proofline.Options{Workspace: t.TempDir(), ProjectID: "embedded", ActorID: "harness"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer l.Close()
+
+	task, err := l.CreateTask(ctx, proofline.TaskCreateOptions{Title: "Record proof", Type: "bug"})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	if task.ProjectID != "embedded" {
+		t.Fatalf("unexpected project %s", task.ProjectID)
+	}
+	if _, err := l.ClaimTask(ctx, task.ID, 60); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := l.CompleteTask(ctx, task.ID, `{"notes":"done"}`, false); err == nil {
+		t.Fatalf("expected validation failure without attestations")
+	}
+	for _, kind := range []string{"ci.passed", "review.approved"} {
+		if _, err := l.Attest(ctx, "task", task.ID, kind, ""); err != nil {
+			t.Fatalf("attest %s: %v", kind, err)
+		}
+	}
+	done, err := l.CompleteTask(ctx, task.ID, `{"notes":"done"}`, false)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if done.Status != "done" {
+		t.Fatalf("expected done, got %s", done.Status)
+	}
+	events, err := l.Events(ctx, 10)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if len(events) == 0 || events[0].Type != "task.done" {
+		t.Fatalf("expected task.done as latest event, got %+v", events)
+	}
+}
+
+func TestEmbeddedReopenKeepsState(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l, err := proofline.Open(ctx, proofline.Options{Workspace: dir, ProjectID: "embedded"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	task, err := l.CreateTask(ctx, proofline.TaskCreateOptions{Title: "Persist"})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	l.Close()
+
+	l, err = proofline.Open(ctx, proofline.Options{Workspace: dir, ProjectID: "embedded"})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	if _, err := l.GetTask(ctx, task.ID); err != nil {
+		t.Fatalf("get task after reopen: %v", err)
+	}
+	if _, err := l.As("stranger").CreateTask(ctx, proofline.TaskCreateOptions{Title: "Denied"}); err == nil {
+		t.Fatalf("expected forbidden for actor without roles")
+	}
+	if _, err := l.GetTask(ctx, "missing"); !errors.Is(err, proofline.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEmbeddedRefusesOtherProjects(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	other, err := proofline.Open(ctx, proofline.Options{Workspace: dir, ProjectID: "other", ActorID: "harness"})
+	if err != nil {
+		t.Fatalf("open other: %v", err)
+	}
+	defer other.Close()
+	it, err := other.CreateIteration(ctx, "iter-other", "Elsewhere")
+	if err != nil {
+		t.Fatalf("create iteration: %v", err)
+	}
+	task, err := other.CreateTask(ctx, proofline.TaskCreateOptions{Title: "Elsewhere"})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+
+	l, err := proofline.Open(ctx, proofline.Options{Workspace: dir, ProjectID: "embedded", ActorID: "harness"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer l.Close()
+	if _, err := l.SetIterationStatus(ctx, it.ID, "running", true); !errors.Is(err, proofline.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another project's iteration, got %v", err)
+	}
+	if _, err := l.Attest(ctx, "task", task.ID, "ci.passed", ""); !errors.Is(err, proofline.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound attesting another project's task, got %v", err)
+	}
+	if _, err := l.CreateTask(ctx, proofline.TaskCreateOptions{ProjectID: "other", Title: "Smuggled"}); !errors.Is(err, proofline.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound creating in another project, got %v", err)
+	}
+	got, err := other.GetIteration(ctx, it.ID)
+	if err != nil || got.Status != it.Status {
+		t.Fatalf("expected iteration untouched, got %+v %v", got, err)
+	}
+}
@@ -38,18 +38,45 @@ type Task struct {
 	Title     string `json:"title"`
 	Type      string `json:"type"`
 	Status    string `json:"status"`
+	// ContentHash is "sha256:<hex>" over the canonical JSON form of the task.
+	ContentHash string `json:"content_hash,omitempty"`
+	// RequiredCapabilities must all be offered by an actor for the task to reach its ready queue.
+	RequiredCapabilities []string `json:"required_capabilities,omitempty"`
+	// CustomFields holds the values declared by the task type's fields schema.
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	// DeferUntil keeps the task out of ready queues until it passes.
+	DeferUntil string `json:"defer_until,omitempty"`
+	// DueAt is the deadline the deadline scheduler orders ready queues by.
+	DueAt string `json:"due_at,omitempty"`
+}
+
+// Lease is a claim on a task.
+type Lease struct {
+	TaskID    string `json:"task_id"`
+	OwnerID   string `json:"owner_id"`
+	ExpiresAt string `json:"expires_at"`
+	// Plan is what the owner declared it would do with the claim.
+	Plan *LeasePlan `json:"plan,omitempty"`
+}
+
+// LeasePlan is the intended approach declared with a claim, for supervisors to review
+// before work starts.
+type LeasePlan struct {
+	Approach         string `json:"approach"`
+	EstimatedSeconds int    `json:"estimated_seconds,omitempty"`
 }
 
 // Attestation represents a proof entry.
 type Attestation struct {
-	ID         string         `json:"id"`
-	ProjectID  string         `json:"project_id"`
-	EntityKind string         `json:"entity_kind"`
-	EntityID   string         `json:"entity_id"`
-	Kind       string         `json:"kind"`
-	ActorID    string         `json:"actor_id"`
-	Payload    map[string]any `json:"payload,omitempty"`
-	TS         string         `json:"ts"`
+	ID          string         `json:"id"`
+	ProjectID   string         `json:"project_id"`
+	EntityKind  string         `json:"entity_kind"`
+	EntityID    string         `json:"entity_id"`
+	Kind        string         `json:"kind"`
+	ActorID     string         `json:"actor_id"`
+	Payload     map[string]any `json:"payload,omitempty"`
+	TS          string         `json:"ts"`
+	ContentHash string         `json:"content_hash,omitempty"`
 }
 
 // Event represents a log entry.
@@ -90,14 +117,88 @@ func (c *Client) CreateTask(ctx context.Context, title, taskType string) (Task,
 	return resp, err
 }
 
+// ClaimNext claims the first task of the caller's ready queue, i.e. a claimable task whose
+// required capabilities the caller offers. It returns an *APIError with status 404 when
+// nothing is ready.
+func (c *Client) ClaimNext(ctx context.Context, leaseSeconds int) (Task, Lease, error) {
+	endpoint := c.projectPath("tasks/claim-next")
+	if leaseSeconds > 0 {
+		endpoint = fmt.Sprintf("%s?lease_seconds=%d", endpoint, leaseSeconds)
+	}
+	var resp struct {
+		Task  Task  `json:"task"`
+		Lease Lease `json:"lease"`
+	}
+	err := c.do(ctx, http.MethodPost, endpoint, nil, &resp)
+	return resp.Task, resp.Lease, err
+}
+
+// ClaimTask acquires the lease on a task. It returns an *APIError with status 409 when
+// another actor holds it.
+func (c *Client) ClaimTask(ctx context.Context, taskID string, leaseSeconds int) (Lease, error) {
+	endpoint := c.projectPath("tasks/" + url.PathEscape(taskID) + "/claim")
+	if leaseSeconds > 0 {
+		endpoint = fmt.Sprintf("%s?lease_seconds=%d", endpoint, leaseSeconds)
+	}
+	var resp Lease
+	err := c.do(ctx, http.MethodPost, endpoint, nil, &resp)
+	return resp, err
+}
+
+// ClaimTaskWithPlan acquires the lease on a task like ClaimTask, declaring how the caller
+// intends to work it.
+func (c *Client) ClaimTaskWithPlan(ctx context.Context, taskID string, leaseSeconds int, plan LeasePlan) (Lease, error) {
+	endpoint := c.projectPath("tasks/" + url.PathEscape(taskID) + "/claim")
+	if leaseSeconds > 0 {
+		endpoint = fmt.Sprintf("%s?lease_seconds=%d", endpoint, leaseSeconds)
+	}
+	var resp Lease
+	err := c.do(ctx, http.MethodPost, endpoint, map[string]any{"plan": plan}, &resp)
+	return resp, err
+}
+
+// DeferTask keeps a task out of ready queues until the given time; a zero time ends the
+// deferral now.
+func (c *Client) DeferTask(ctx context.Context, taskID string, until time.Time) (Task, error) {
+	endpoint := c.projectPath("tasks/" + url.PathEscape(taskID) + "/defer")
+	var resp Task
+	if until.IsZero() {
+		err := c.do(ctx, http.MethodDelete, endpoint, nil, &resp)
+		return resp, err
+	}
+	err := c.do(ctx, http.MethodPost, endpoint, map[string]any{"until": until.UTC().Format(time.RFC3339)}, &resp)
+	return resp, err
+}
+
+// CompleteTask records work outcomes and moves the task to done once its required
+// attestations are present.
+func (c *Client) CompleteTask(ctx context.Context, taskID string, workOutcomes map[string]any) (Task, error) {
+	if workOutcomes == nil {
+		workOutcomes = map[string]any{}
+	}
+	var resp Task
+	err := c.do(ctx, http.MethodPost, c.projectPath("tasks/"+url.PathEscape(taskID)+"/done"), map[string]any{"work_outcomes": workOutcomes}, &resp)
+	return resp, err
+}
+
 // AddAttestation adds a proof.
 func (c *Client) AddAttestation(ctx context.Context, entityKind, entityID, kind string, payload any) (Attestation, error) {
+	return c.AddAttestationFor(ctx, "", entityKind, entityID, kind, payload)
+}
+
+// AddAttestationFor adds a proof attributed to actorID, which requires the
+// attestation.on_behalf permission when it is not the caller. An empty actorID
+// attributes it to the caller.
+func (c *Client) AddAttestationFor(ctx context.Context, actorID, entityKind, entityID, kind string, payload any) (Attestation, error) {
 	body := map[string]any{
 		"entity_kind": entityKind,
 		"entity_id":   entityID,
 		"kind":        kind,
 		"payload":     payload,
 	}
+	if actorID != "" {
+		body["actor_id"] = actorID
+	}
 	var resp Attestation
 	err := c.do(ctx, http.MethodPost, c.projectPath("attestations"), body, &resp)
 	return resp, err